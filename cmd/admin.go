@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	goruntime "runtime"
+	"time"
+)
+
+// runAdminServer serves the pprof profiles and the expvar variables on a port of the loopback
+// interface only, they reveal the internals of the bridge and are never exposed remotely
+func runAdminServer(adminPort int) {
+	// a growing count is the first sign of a goroutine leak
+	expvar.Publish("goroutines", expvar.Func(func() any { return goruntime.NumGoroutine() }))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	slog.Info("Admin server listening", "port", adminPort)
+	server := &http.Server{
+		Addr:        fmt.Sprintf("127.0.0.1:%d", adminPort),
+		Handler:     mux,
+		ReadTimeout: 5 * time.Second,
+		// the CPU profiles and traces last 30 seconds by default
+		WriteTimeout: 5 * time.Minute,
+	}
+	if err := server.ListenAndServe(); err != nil {
+		slog.Error("Admin server stopped", "error", err)
+	}
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+	"github.com/opiproject/opi-marvell-bridge/pkg/apply"
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+	"github.com/opiproject/opi-marvell-bridge/pkg/export"
+	"github.com/opiproject/opi-marvell-bridge/pkg/oidc"
+	"github.com/opiproject/opi-marvell-bridge/pkg/ratelimit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// newApplyServer applies the configs with the gRPC server of the bridge, through loopback, so
+// the steps go through its interceptors as the calls of the caller, the resources of the bridge
+// being the exported ones of the tenant of the caller
+func newApplyServer(custom *customServers, loopback grpc.ClientConnInterface) *apply.Server {
+	snapshot := func(ctx context.Context) ([]*apply.Existing, error) {
+		exported, err := custom.export.ExportResources(ctx, &export.ExportResourcesRequest{})
+		if err != nil {
+			return nil, err
+		}
+		tenantID := ""
+		if md, ok := metadata.FromOutgoingContext(ctx); ok {
+			if values := md.Get(tenant.MetadataKey); len(values) != 0 {
+				tenantID = values[0]
+			}
+		}
+		existing := make([]*apply.Existing, 0, len(exported.Resources))
+		for _, r := range exported.Resources {
+			resource, ok := r.Resource.(proto.Message)
+			if !ok {
+				return nil, fmt.Errorf("%s isn't a resource of the API", r.ID)
+			}
+			e := &apply.Existing{Type: r.Type, Name: r.ID, ResourceID: r.ResourceID, Parent: r.Parent, Resource: resource}
+			// the tenants only see their own resources, with the names they know
+			if tenantID != "" {
+				if e.Name, ok = tenant.UnscopeName(tenantID, r.ID); !ok {
+					continue
+				}
+				e.ResourceID = path.Base(e.Name)
+				if r.Parent != "" {
+					e.Parent, _ = tenant.UnscopeName(tenantID, r.Parent)
+				}
+				e.Resource = proto.Clone(resource)
+				tenant.Unscope(tenantID, e.Resource)
+			}
+			existing = append(existing, e)
+		}
+		return existing, nil
+	}
+	return apply.NewServer(snapshot, newApplyKinds(loopback))
+}
+
+// loopbackMethods are the custom methods calling the gRPC server of the bridge as their caller,
+// the tenant of the caller is passed on so its resources are scoped by the calls rather than
+// in the request and the response of the method
+var loopbackMethods = map[string]bool{
+	"marvell.apply/ApplyConfig": true,
+}
+
+// loopbackContext passes the credentials, the identity, the tenant, the rate limit delegation and
+// the ID of an HTTP request on to the calls to the gRPC server made while serving it
+func loopbackContext(ctx context.Context, header func(string) string, identity string, tenantID string, delegation string) context.Context {
+	md := metadata.Pairs(requestid.MetadataKey, requestid.FromContext(ctx))
+	if value := header(oidc.HeaderKey); value != "" {
+		md.Set(oidc.MetadataKey, value)
+	}
+	if value := header(apikey.HeaderKey); value != "" {
+		md.Set(apikey.MetadataKey, value)
+	}
+	// trusted from the connection of the bridge only, as for the calls of the HTTP gateway
+	if identity != "" {
+		md.Set(authz.MetadataKey, identity)
+	}
+	if tenantID != "" {
+		md.Set(tenant.MetadataKey, tenantID)
+	}
+	if delegation != "" {
+		md.Set(ratelimit.MetadataKey, delegation)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// localConn calls the methods of the servers directly, without the interceptors of the gRPC
+// server, i.e. to restore a backup before the server is serving
+type localConn struct {
+	services map[string]localService
+}
+
+// localService is a service of a localConn and its server
+type localService struct {
+	desc   *grpc.ServiceDesc
+	server interface{}
+}
+
+// newLocalConn returns a localConn to the services of the resources of the config
+func newLocalConn(custom *customServers) *localConn {
+	c := &localConn{services: make(map[string]localService)}
+	for _, s := range []localService{
+		{desc: &pb.NullVolumeService_ServiceDesc, server: custom.backend},
+		{desc: &pb.MallocVolumeService_ServiceDesc, server: custom.backend},
+		{desc: &pb.AioVolumeService_ServiceDesc, server: custom.backend},
+		{desc: &pb.NvmeRemoteControllerService_ServiceDesc, server: custom.backend},
+		{desc: &pb.MiddleendEncryptionService_ServiceDesc, server: custom.middleend},
+		{desc: &pb.FrontendNvmeService_ServiceDesc, server: custom.frontend},
+	} {
+		c.services[s.desc.ServiceName] = s
+	}
+	return c
+}
+
+// Invoke implements grpc.ClientConnInterface
+func (c *localConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, _ ...grpc.CallOption) error {
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	s, ok := c.services[service]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown service %s", service)
+	}
+	for _, m := range s.desc.Methods {
+		if m.MethodName != name {
+			continue
+		}
+		decode := func(in interface{}) error {
+			proto.Merge(in.(proto.Message), args.(proto.Message))
+			return nil
+		}
+		out, err := m.Handler(s.server, ctx, decode, nil)
+		if err != nil {
+			return err
+		}
+		proto.Merge(reply.(proto.Message), out.(proto.Message))
+		return nil
+	}
+	return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+}
+
+// NewStream implements grpc.ClientConnInterface, the resources have no streaming method
+func (c *localConn) NewStream(_ context.Context, _ *grpc.StreamDesc, method string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+}
+
+// newApplyKinds returns the kinds of the exported resources, created, updated and deleted
+// through conn, the volumes before the namespaces using them and the parents before their
+// children. The encrypted volumes can't be pruned, their key isn't exported
+func newApplyKinds(conn grpc.ClientConnInterface) []apply.Kind {
+	nullVolumes := pb.NewNullVolumeServiceClient(conn)
+	mallocVolumes := pb.NewMallocVolumeServiceClient(conn)
+	aioVolumes := pb.NewAioVolumeServiceClient(conn)
+	remoteControllers := pb.NewNvmeRemoteControllerServiceClient(conn)
+	encryption := pb.NewMiddleendEncryptionServiceClient(conn)
+	nvme := pb.NewFrontendNvmeServiceClient(conn)
+	encryptedVolumes := apply.NewKind("opi_encrypted_volume", "EncryptedVolume",
+		func(ctx context.Context, _ string, id string, volume *pb.EncryptedVolume) (*pb.EncryptedVolume, error) {
+			return encryption.CreateEncryptedVolume(ctx, &pb.CreateEncryptedVolumeRequest{EncryptedVolume: volume, EncryptedVolumeId: id})
+		},
+		func(ctx context.Context, volume *pb.EncryptedVolume) (*pb.EncryptedVolume, error) {
+			return encryption.UpdateEncryptedVolume(ctx, &pb.UpdateEncryptedVolumeRequest{EncryptedVolume: volume})
+		},
+		func(ctx context.Context, name string) error {
+			_, err := encryption.DeleteEncryptedVolume(ctx, &pb.DeleteEncryptedVolumeRequest{Name: name})
+			return err
+		})
+	encryptedVolumes.Unrecoverable = true
+	return []apply.Kind{
+		apply.NewKind("opi_null_volume", "NullVolume",
+			func(ctx context.Context, _ string, id string, volume *pb.NullVolume) (*pb.NullVolume, error) {
+				return nullVolumes.CreateNullVolume(ctx, &pb.CreateNullVolumeRequest{NullVolume: volume, NullVolumeId: id})
+			},
+			func(ctx context.Context, volume *pb.NullVolume) (*pb.NullVolume, error) {
+				return nullVolumes.UpdateNullVolume(ctx, &pb.UpdateNullVolumeRequest{NullVolume: volume})
+			},
+			func(ctx context.Context, name string) error {
+				_, err := nullVolumes.DeleteNullVolume(ctx, &pb.DeleteNullVolumeRequest{Name: name})
+				return err
+			}),
+		apply.NewKind("opi_malloc_volume", "MallocVolume",
+			func(ctx context.Context, _ string, id string, volume *pb.MallocVolume) (*pb.MallocVolume, error) {
+				return mallocVolumes.CreateMallocVolume(ctx, &pb.CreateMallocVolumeRequest{MallocVolume: volume, MallocVolumeId: id})
+			},
+			func(ctx context.Context, volume *pb.MallocVolume) (*pb.MallocVolume, error) {
+				return mallocVolumes.UpdateMallocVolume(ctx, &pb.UpdateMallocVolumeRequest{MallocVolume: volume})
+			},
+			func(ctx context.Context, name string) error {
+				_, err := mallocVolumes.DeleteMallocVolume(ctx, &pb.DeleteMallocVolumeRequest{Name: name})
+				return err
+			}),
+		apply.NewKind("opi_aio_volume", "AioVolume",
+			func(ctx context.Context, _ string, id string, volume *pb.AioVolume) (*pb.AioVolume, error) {
+				return aioVolumes.CreateAioVolume(ctx, &pb.CreateAioVolumeRequest{AioVolume: volume, AioVolumeId: id})
+			},
+			func(ctx context.Context, volume *pb.AioVolume) (*pb.AioVolume, error) {
+				return aioVolumes.UpdateAioVolume(ctx, &pb.UpdateAioVolumeRequest{AioVolume: volume})
+			},
+			func(ctx context.Context, name string) error {
+				_, err := aioVolumes.DeleteAioVolume(ctx, &pb.DeleteAioVolumeRequest{Name: name})
+				return err
+			}),
+		apply.NewKind("opi_nvme_remote_controller", "NvmeRemoteController",
+			func(ctx context.Context, _ string, id string, controller *pb.NvmeRemoteController) (*pb.NvmeRemoteController, error) {
+				return remoteControllers.CreateNvmeRemoteController(ctx, &pb.CreateNvmeRemoteControllerRequest{NvmeRemoteController: controller, NvmeRemoteControllerId: id})
+			},
+			func(ctx context.Context, controller *pb.NvmeRemoteController) (*pb.NvmeRemoteController, error) {
+				return remoteControllers.UpdateNvmeRemoteController(ctx, &pb.UpdateNvmeRemoteControllerRequest{NvmeRemoteController: controller})
+			},
+			func(ctx context.Context, name string) error {
+				_, err := remoteControllers.DeleteNvmeRemoteController(ctx, &pb.DeleteNvmeRemoteControllerRequest{Name: name})
+				return err
+			}),
+		apply.NewKind("opi_nvme_path", "NvmePath",
+			func(ctx context.Context, parent string, id string, path *pb.NvmePath) (*pb.NvmePath, error) {
+				return remoteControllers.CreateNvmePath(ctx, &pb.CreateNvmePathRequest{Parent: parent, NvmePath: path, NvmePathId: id})
+			},
+			func(ctx context.Context, path *pb.NvmePath) (*pb.NvmePath, error) {
+				return remoteControllers.UpdateNvmePath(ctx, &pb.UpdateNvmePathRequest{NvmePath: path})
+			},
+			func(ctx context.Context, name string) error {
+				_, err := remoteControllers.DeleteNvmePath(ctx, &pb.DeleteNvmePathRequest{Name: name})
+				return err
+			}),
+		encryptedVolumes,
+		apply.NewKind("opi_nvme_subsystem", "NvmeSubsystem",
+			func(ctx context.Context, _ string, id string, subsystem *pb.NvmeSubsystem) (*pb.NvmeSubsystem, error) {
+				return nvme.CreateNvmeSubsystem(ctx, &pb.CreateNvmeSubsystemRequest{NvmeSubsystem: subsystem, NvmeSubsystemId: id})
+			},
+			func(ctx context.Context, subsystem *pb.NvmeSubsystem) (*pb.NvmeSubsystem, error) {
+				return nvme.UpdateNvmeSubsystem(ctx, &pb.UpdateNvmeSubsystemRequest{NvmeSubsystem: subsystem})
+			},
+			func(ctx context.Context, name string) error {
+				_, err := nvme.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: name})
+				return err
+			}),
+		apply.NewKind("opi_nvme_controller", "NvmeController",
+			func(ctx context.Context, parent string, id string, controller *pb.NvmeController) (*pb.NvmeController, error) {
+				return nvme.CreateNvmeController(ctx, &pb.CreateNvmeControllerRequest{Parent: parent, NvmeController: controller, NvmeControllerId: id})
+			},
+			func(ctx context.Context, controller *pb.NvmeController) (*pb.NvmeController, error) {
+				return nvme.UpdateNvmeController(ctx, &pb.UpdateNvmeControllerRequest{NvmeController: controller})
+			},
+			func(ctx context.Context, name string) error {
+				_, err := nvme.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{Name: name})
+				return err
+			}),
+		apply.NewKind("opi_nvme_namespace", "NvmeNamespace",
+			func(ctx context.Context, parent string, id string, namespace *pb.NvmeNamespace) (*pb.NvmeNamespace, error) {
+				return nvme.CreateNvmeNamespace(ctx, &pb.CreateNvmeNamespaceRequest{Parent: parent, NvmeNamespace: namespace, NvmeNamespaceId: id})
+			},
+			func(ctx context.Context, namespace *pb.NvmeNamespace) (*pb.NvmeNamespace, error) {
+				return nvme.UpdateNvmeNamespace(ctx, &pb.UpdateNvmeNamespaceRequest{NvmeNamespace: namespace})
+			},
+			func(ctx context.Context, name string) error {
+				_, err := nvme.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{Name: name})
+				return err
+			}),
+	}
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/apply"
+	"github.com/opiproject/opi-marvell-bridge/pkg/backup"
+	"github.com/opiproject/opi-marvell-bridge/pkg/export"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultBackupRegion is the region the requests to the bucket are signed for when AWS_REGION
+// isn't set, the S3 compatible stores usually ignore it
+const defaultBackupRegion = "us-east-1"
+
+// newBackupAgent backs the store at redisAddress and the exported resources up to the bucket
+// at bucketURL, in http(s)://host/bucket/prefix format, keeping the keep latest backups. The
+// credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables and the region from AWS_REGION
+func newBackupAgent(bucketURL string, redisAddress string, keep int, custom *customServers) (*backup.Agent, *backup.RedisStore, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = defaultBackupRegion
+	}
+	bucket, err := backup.NewBucket(bucketURL, region, backup.Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	source, err := os.Hostname()
+	if err != nil {
+		return nil, nil, err
+	}
+	store := backup.NewRedisStore(redisAddress)
+	config := func(ctx context.Context) (interface{}, error) {
+		return custom.export.ExportResources(ctx, &export.ExportResourcesRequest{})
+	}
+	return backup.NewAgent(bucket, store, config, source, keep), store, nil
+}
+
+// restoreBackup provisions the resources of a backup again, on the replacement of the DPU it
+// was taken on. The keys of the store which aren't resources, i.e. their options, sharing or
+// limits, are loaded first then the resources are created in dependency order, so the firmware
+// gets them as well as the store. The resources failing to be created are reported, the others
+// are kept
+func restoreBackup(ctx context.Context, agent *backup.Agent, store backup.Store, key string, custom *customServers) error {
+	b, err := agent.Fetch(ctx, key)
+	if err != nil {
+		return err
+	}
+	var config struct {
+		Resources []*export.Resource `json:"resources"`
+	}
+	if len(b.Config) == 0 {
+		return fmt.Errorf("backup %s of %s has no snapshot of the resources", key, b.Source)
+	}
+	if err := json.Unmarshal(b.Config, &config); err != nil {
+		return fmt.Errorf("invalid snapshot of the resources of backup %s: %w", key, err)
+	}
+	resources := make(map[string]bool)
+	for _, r := range config.Resources {
+		resources[r.ID] = true
+	}
+	entries := make(map[string][]byte)
+	for name, value := range b.Store {
+		if !resources[name] {
+			entries[name] = value
+		}
+	}
+	if err := store.Load(ctx, entries); err != nil {
+		return fmt.Errorf("could not load the store of backup %s: %w", key, err)
+	}
+
+	var errs []error
+	restored := 0
+	for _, kind := range newApplyKinds(newLocalConn(custom)) {
+		for _, r := range config.Resources {
+			if r.Type != kind.Type {
+				continue
+			}
+			data, ok := b.Store[r.ID]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s isn't in the store of the backup", r.ID))
+				continue
+			}
+			if err := restoreResource(ctx, kind, r, data); err != nil {
+				errs = append(errs, fmt.Errorf("could not restore %s: %w", r.ID, err))
+				continue
+			}
+			restored++
+		}
+	}
+	slog.InfoContext(ctx, "Restored backup", "key", key, "source", b.Source, "time", b.Time, "resources", restored, "failed", len(errs))
+	return errors.Join(errs...)
+}
+
+// restoreResource creates a resource from its value in the store, without its status the
+// firmware reports again once it is created
+func restoreResource(ctx context.Context, kind apply.Kind, r *export.Resource, data []byte) error {
+	resource := kind.New()
+	if err := proto.Unmarshal(data, resource); err != nil {
+		return err
+	}
+	message := resource.ProtoReflect()
+	if status := message.Descriptor().Fields().ByName("status"); status != nil {
+		message.Clear(status)
+	}
+	_, err := kind.Create(ctx, r.Parent, r.ResourceID, resource)
+	return err
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+	"github.com/opiproject/opi-marvell-bridge/pkg/config"
+	"github.com/opiproject/opi-marvell-bridge/pkg/ipfilter"
+	"github.com/opiproject/opi-marvell-bridge/pkg/logger"
+	"github.com/opiproject/opi-marvell-bridge/pkg/metrics"
+	"github.com/opiproject/opi-marvell-bridge/pkg/ratelimit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tlsreload"
+)
+
+// errEnabledAtRuntime refuses to enable or disable a component on reload, its interceptors are
+// only chained when the bridge starts
+var errEnabledAtRuntime = errors.New("can't be enabled or disabled at runtime, restart the bridge")
+
+// reloadableComponents are the components whose settings can change at runtime, nil when disabled
+type reloadableComponents struct {
+	logControl   *logger.Control
+	certificates *tlsreload.Reloader
+	tlsFiles     string
+	policy       *authz.Policy
+	authzPolicy  string
+	keys         *apikey.Keys
+	apiKeysFile  string
+	limiter      *ratelimit.Limiter
+	filter       *ipfilter.Filter
+	metrics      *metrics.Metrics
+}
+
+// handleReloadableSettings registers the settings applied again on reload, the log level and
+// target, the TLS files, the authorization policy, the API keys, the rate limits, the client CIDRs
+// and the latency budgets. The components disabled at start stay so
+func handleReloadableSettings(reloader *config.Reloader, c reloadableComponents) {
+	reloader.Handle([]string{"log_level", "log_target", "log_path"}, func(values []string) (func() error, error) {
+		return func() error {
+			_, err := c.logControl.UpdateLogConfig(context.Background(), &logger.UpdateLogConfigRequest{Level: values[0], Target: values[1], Path: values[2]})
+			return err
+		}, nil
+	})
+	// the files are read again, their paths are kept
+	reloader.Handle([]string{"tls"}, func(values []string) (func() error, error) {
+		if values[0] != c.tlsFiles {
+			return nil, errors.New("the TLS files can't change at runtime, restart the bridge")
+		}
+		if c.certificates == nil {
+			return func() error { return nil }, nil
+		}
+		return c.certificates.Reload, nil
+	})
+	reloader.Handle([]string{"authz_policy"}, func(values []string) (func() error, error) {
+		if (c.authzPolicy == "") != (values[0] == "") {
+			return nil, errEnabledAtRuntime
+		}
+		if c.policy == nil {
+			return func() error { return nil }, nil
+		}
+		policy, err := authz.LoadPolicy(values[0])
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			c.policy.Replace(policy)
+			return nil
+		}, nil
+	})
+	reloader.Handle([]string{"api_keys"}, func(values []string) (func() error, error) {
+		if (c.apiKeysFile == "") != (values[0] == "") {
+			return nil, errEnabledAtRuntime
+		}
+		if c.keys == nil {
+			return func() error { return nil }, nil
+		}
+		keys, err := apikey.LoadKeys(values[0])
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			c.keys.Replace(keys)
+			return nil
+		}, nil
+	})
+	reloader.Handle([]string{"rate_limit", "rate_limit_burst", "max_mutating_calls"}, func(values []string) (func() error, error) {
+		rate, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		burst, err := strconv.Atoi(values[1])
+		if err != nil {
+			return nil, err
+		}
+		maxInFlight, err := strconv.Atoi(values[2])
+		if err != nil {
+			return nil, err
+		}
+		if (c.limiter == nil) != (rate == 0 && maxInFlight == 0) {
+			return nil, errEnabledAtRuntime
+		}
+		if c.limiter == nil {
+			return func() error { return nil }, nil
+		}
+		if err := ratelimit.Validate(rate, burst, maxInFlight); err != nil {
+			return nil, err
+		}
+		return func() error {
+			c.limiter.SetLimits(rate, burst, maxInFlight)
+			return nil
+		}, nil
+	})
+	// the connections already accepted are kept
+	reloader.Handle([]string{"allowed_cidrs", "denied_cidrs"}, func(values []string) (func() error, error) {
+		if _, err := ipfilter.New(values[0], values[1]); err != nil {
+			return nil, err
+		}
+		return func() error {
+			return c.filter.SetPrefixes(values[0], values[1])
+		}, nil
+	})
+	reloader.Handle([]string{"latency_budgets"}, func(values []string) (func() error, error) {
+		var budgets *metrics.LatencyBudgets
+		if values[0] != "" {
+			var err error
+			if budgets, err = metrics.ParseLatencyBudgets(values[0]); err != nil {
+				return nil, err
+			}
+		}
+		return func() error {
+			c.metrics.SetLatencyBudgets(budgets)
+			return nil
+		}, nil
+	})
+}
+
+// reloadConfigOnSignal reloads the configuration file on every SIGHUP
+func reloadConfigOnSignal(reloader *config.Reloader) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if _, err := reloader.ReloadConfig(context.Background(), &config.ReloadConfigRequest{}); err != nil {
+			slog.Error("Could not reload the configuration, keeping the current one", "error", err)
+		}
+	}
+}
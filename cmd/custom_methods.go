@@ -0,0 +1,570 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	goruntime "runtime"
+	"strings"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+	"github.com/opiproject/opi-marvell-bridge/pkg/apiversion"
+	"github.com/opiproject/opi-marvell-bridge/pkg/apply"
+	"github.com/opiproject/opi-marvell-bridge/pkg/audit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+	be "github.com/opiproject/opi-marvell-bridge/pkg/backend"
+	"github.com/opiproject/opi-marvell-bridge/pkg/cache"
+	"github.com/opiproject/opi-marvell-bridge/pkg/config"
+	"github.com/opiproject/opi-marvell-bridge/pkg/events"
+	"github.com/opiproject/opi-marvell-bridge/pkg/export"
+	"github.com/opiproject/opi-marvell-bridge/pkg/faults"
+	fe "github.com/opiproject/opi-marvell-bridge/pkg/frontend"
+	"github.com/opiproject/opi-marvell-bridge/pkg/gnmi"
+	"github.com/opiproject/opi-marvell-bridge/pkg/gnoi"
+	"github.com/opiproject/opi-marvell-bridge/pkg/health"
+	"github.com/opiproject/opi-marvell-bridge/pkg/logger"
+	"github.com/opiproject/opi-marvell-bridge/pkg/metrics"
+	me "github.com/opiproject/opi-marvell-bridge/pkg/middleend"
+	"github.com/opiproject/opi-marvell-bridge/pkg/oidc"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-marvell-bridge/pkg/placement"
+	"github.com/opiproject/opi-marvell-bridge/pkg/platform"
+	"github.com/opiproject/opi-marvell-bridge/pkg/ratelimit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/recorder"
+	"github.com/opiproject/opi-marvell-bridge/pkg/redfish"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// storageResourcePrefix is prepended to resource names taken from the HTTP path
+const storageResourcePrefix = "//storage.opiproject.org/"
+
+// streamWriteTimeout bounds the time to write each response of a stream
+const streamWriteTimeout = 10 * time.Second
+
+// customServers holds the servers implementing the Marvell specific methods
+type customServers struct {
+	frontend   *fe.Server
+	middleend  *me.Server
+	backend    *be.Server
+	operations *operations.Manager
+	platform   *platform.Server
+	metrics    *metrics.Metrics
+	logging    *logger.Control
+	audit      *audit.Log
+	health     *health.Checker
+	recorder   *recorder.Recorder
+	events     *events.History
+	config     *config.Reloader
+	redfish    *redfish.Server
+	gnmi       *gnmi.Server
+	gnoi       *gnoi.Server
+	versions   *apiversion.Layer
+	export     *export.Server
+	apply      *apply.Server
+	faults     *faults.Injector
+	cache      *cache.Cache
+	// policy restricts the methods the identities can call, nil when any call is allowed
+	policy *authz.Policy
+	// verifier authenticates the callers with their bearer token when set
+	verifier *oidc.Verifier
+	// keys authenticate the callers with their API key when set
+	keys *apikey.Keys
+	// limiter limits the calls of each identity when set
+	limiter *ratelimit.Limiter
+	// router places the calls on the instances of the firmware when set
+	router *placement.Router
+	// engine decides on the calls from their requests when set
+	engine authz.Engine
+}
+
+// registerCustomMethods registers HTTP handlers for the Marvell specific methods
+func registerCustomMethods(mux *runtime.ServeMux, custom *customServers) {
+	registerCustomMethod(mux, http.MethodGet, "/v1/operations", customMethodHandler(custom, custom.operations.ListOperations))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=operations/*}", customMethodHandler(custom, custom.operations.GetOperation))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=operations/*}:cancel", customMethodHandler(custom, custom.operations.CancelOperation))
+
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}:nvmeMiPassthru", customMethodHandler(custom, custom.frontend.NvmeMiPassthru))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}/options", customMethodHandler(custom, custom.frontend.GetNvmeControllerOptions))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}/options", customMethodHandler(custom, custom.frontend.UpdateNvmeControllerOptions))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}/deletionProtection", customMethodHandler(custom, custom.frontend.GetNvmeDeletionProtection))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}/deletionProtection", customMethodHandler(custom, custom.frontend.UpdateNvmeDeletionProtection))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}:pause", customMethodHandler(custom, custom.frontend.PauseNvmeController))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}:resume", customMethodHandler(custom, custom.frontend.ResumeNvmeController))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}:suspend", customMethodHandler(custom, custom.frontend.SuspendNvmeController))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}:saveState", customMethodHandler(custom, custom.frontend.SaveNvmeControllerState))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}:restoreState", customMethodHandler(custom, custom.frontend.RestoreNvmeControllerState))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}:reset", customMethodHandler(custom, custom.frontend.ResetNvmeController))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}/queueStats", customMethodHandler(custom, custom.frontend.StatsNvmeControllerQueues))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}/pcieStatus", customMethodHandler(custom, custom.frontend.GetNvmeControllerPcieStatus))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*/nvmeControllers/*}/warnings", customMethodHandler(custom, custom.frontend.GetValidationWarnings))
+	registerCustomMethod(mux, http.MethodGet, "/v1/nvmeStats", customMethodHandler(custom, custom.frontend.StatsNvmeBulk))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*}:reset", customMethodHandler(custom, custom.frontend.ResetNvmeSubsystem))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*}/limits", customMethodHandler(custom, custom.frontend.GetNvmeSubsystemLimits))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeSubsystems/*}/limits", customMethodHandler(custom, custom.frontend.UpdateNvmeSubsystemLimits))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*}/deletionProtection", customMethodHandler(custom, custom.frontend.GetNvmeDeletionProtection))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeSubsystems/*}/deletionProtection", customMethodHandler(custom, custom.frontend.UpdateNvmeDeletionProtection))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*}/warnings", customMethodHandler(custom, custom.frontend.GetValidationWarnings))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*/nvmeNamespaces/*}/sharing", customMethodHandler(custom, custom.frontend.GetNvmeNamespaceSharing))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeSubsystems/*/nvmeNamespaces/*}/sharing", customMethodHandler(custom, custom.frontend.UpdateNvmeNamespaceSharing))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeSubsystems/*/nvmeNamespaces/*}/deletionProtection", customMethodHandler(custom, custom.frontend.GetNvmeDeletionProtection))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeSubsystems/*/nvmeNamespaces/*}/deletionProtection", customMethodHandler(custom, custom.frontend.UpdateNvmeDeletionProtection))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeNamespaces/*}:notifyChange", customMethodHandler(custom, custom.frontend.NotifyNvmeNamespaceChange))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeNamespaces/*}:migrate", customMethodHandler(custom, custom.frontend.MigrateNvmeNamespace))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=nvmeSubsystems/*/nvmeNamespaces/*}:trace", customStreamHandler(custom, custom.frontend.TraceNvmeNamespace))
+	registerCustomMethod(mux, http.MethodPost, "/v1/quotas", customMethodHandler(custom, custom.frontend.CreateQuota))
+	registerCustomMethod(mux, http.MethodGet, "/v1/quotas", customMethodHandler(custom, custom.frontend.ListQuotas))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=quotas/*}", customMethodHandler(custom, custom.frontend.GetQuota))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=quotas/*}", customMethodHandler(custom, custom.frontend.DeleteQuota))
+	registerCustomMethod(mux, http.MethodGet, "/v1/nvmePhysicalFunctions", customMethodHandler(custom, custom.frontend.ListNvmePhysicalFunctions))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmePhysicalFunctions/*}", customMethodHandler(custom, custom.frontend.GetNvmePhysicalFunction))
+	registerCustomMethod(mux, http.MethodPost, "/v1/pcieReservations", customMethodHandler(custom, custom.frontend.CreatePcieReservation))
+	registerCustomMethod(mux, http.MethodGet, "/v1/pcieReservations", customMethodHandler(custom, custom.frontend.ListPcieReservations))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=pcieReservations/*}", customMethodHandler(custom, custom.frontend.GetPcieReservation))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=pcieReservations/*}", customMethodHandler(custom, custom.frontend.DeletePcieReservation))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=volumes/*}:throttlingStats", customMethodHandler(custom, custom.middleend.StatsQosVolumeThrottling))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=volumes/*}:rekey", customMethodHandler(custom, custom.middleend.RekeyEncryptedVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/volumes:createWithKms", customMethodHandler(custom, custom.middleend.CreateKmsEncryptedVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/compressedVolumes", customMethodHandler(custom, custom.middleend.CreateCompressedVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/compressedVolumes", customMethodHandler(custom, custom.middleend.ListCompressedVolumes))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=compressedVolumes/*}", customMethodHandler(custom, custom.middleend.GetCompressedVolume))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=compressedVolumes/*}", customMethodHandler(custom, custom.middleend.DeleteCompressedVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=compressedVolumes/*}:stats", customMethodHandler(custom, custom.middleend.StatsCompressedVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/deduplicatedVolumes", customMethodHandler(custom, custom.middleend.CreateDeduplicatedVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/deduplicatedVolumes", customMethodHandler(custom, custom.middleend.ListDeduplicatedVolumes))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=deduplicatedVolumes/*}", customMethodHandler(custom, custom.middleend.GetDeduplicatedVolume))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=deduplicatedVolumes/*}", customMethodHandler(custom, custom.middleend.DeleteDeduplicatedVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=deduplicatedVolumes/*}:stats", customMethodHandler(custom, custom.middleend.StatsDeduplicatedVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/snapshots", customMethodHandler(custom, custom.middleend.CreateSnapshot))
+	registerCustomMethod(mux, http.MethodGet, "/v1/snapshots", customMethodHandler(custom, custom.middleend.ListSnapshots))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=snapshots/*}", customMethodHandler(custom, custom.middleend.GetSnapshot))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=snapshots/*}", customMethodHandler(custom, custom.middleend.DeleteSnapshot))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=snapshots/*}:revert", customMethodHandler(custom, custom.middleend.RevertSnapshot))
+	registerCustomMethod(mux, http.MethodPost, "/v1/clones", customMethodHandler(custom, custom.middleend.CreateClone))
+	registerCustomMethod(mux, http.MethodGet, "/v1/clones", customMethodHandler(custom, custom.middleend.ListClones))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=clones/*}", customMethodHandler(custom, custom.middleend.GetClone))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=clones/*}", customMethodHandler(custom, custom.middleend.DeleteClone))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=clones/*}/lineage", customMethodHandler(custom, custom.middleend.GetCloneLineage))
+	registerCustomMethod(mux, http.MethodPost, "/v1/raidVolumes", customMethodHandler(custom, custom.middleend.CreateRaidVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/raidVolumes", customMethodHandler(custom, custom.middleend.ListRaidVolumes))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=raidVolumes/*}", customMethodHandler(custom, custom.middleend.GetRaidVolume))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=raidVolumes/*}", customMethodHandler(custom, custom.middleend.DeleteRaidVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=raidVolumes/*}:replaceMember", customMethodHandler(custom, custom.middleend.ReplaceRaidVolumeMember))
+	registerCustomMethod(mux, http.MethodPost, "/v1/cachedVolumes", customMethodHandler(custom, custom.middleend.CreateCachedVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/cachedVolumes", customMethodHandler(custom, custom.middleend.ListCachedVolumes))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=cachedVolumes/*}", customMethodHandler(custom, custom.middleend.GetCachedVolume))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=cachedVolumes/*}", customMethodHandler(custom, custom.middleend.DeleteCachedVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=cachedVolumes/*}:setMode", customMethodHandler(custom, custom.middleend.SetCachedVolumeMode))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=cachedVolumes/*}:stats", customMethodHandler(custom, custom.middleend.StatsCachedVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/throttleGroups", customMethodHandler(custom, custom.middleend.CreateThrottleGroup))
+	registerCustomMethod(mux, http.MethodGet, "/v1/throttleGroups", customMethodHandler(custom, custom.middleend.ListThrottleGroups))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=throttleGroups/*}", customMethodHandler(custom, custom.middleend.GetThrottleGroup))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=throttleGroups/*}", customMethodHandler(custom, custom.middleend.UpdateThrottleGroup))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=throttleGroups/*}", customMethodHandler(custom, custom.middleend.DeleteThrottleGroup))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeRemoteControllers/*}/reconnectOptions", customMethodHandler(custom, custom.backend.GetNvmeRemoteControllerReconnectOptions))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeRemoteControllers/*}/reconnectOptions", customMethodHandler(custom, custom.backend.UpdateNvmeRemoteControllerReconnectOptions))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeRemoteControllers/*}/dhchap", customMethodHandler(custom, custom.backend.GetNvmeRemoteControllerDhchap))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeRemoteControllers/*}/dhchap", customMethodHandler(custom, custom.backend.UpdateNvmeRemoteControllerDhchap))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=nvmeRemoteControllers/*}/dhchap", customMethodHandler(custom, custom.backend.DeleteNvmeRemoteControllerDhchap))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeRemoteControllers/*}/qos", customMethodHandler(custom, custom.backend.GetNvmeRemoteControllerQos))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeRemoteControllers/*}/qos", customMethodHandler(custom, custom.backend.UpdateNvmeRemoteControllerQos))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=nvmeRemoteControllers/*}/qos", customMethodHandler(custom, custom.backend.DeleteNvmeRemoteControllerQos))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeRemoteControllers/*}/status", customMethodHandler(custom, custom.backend.GetNvmeRemoteControllerStatus))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeRemoteControllers/*/nvmePaths/*}/rdmaOptions", customMethodHandler(custom, custom.backend.GetNvmePathRdmaOptions))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/{name=nvmeRemoteControllers/*/nvmePaths/*}/rdmaOptions", customMethodHandler(custom, custom.backend.UpdateNvmePathRdmaOptions))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeRemoteControllers/*/nvmePaths/*}/status", customMethodHandler(custom, custom.backend.GetNvmePathStatus))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=nvmeRemoteControllers/*/nvmePaths/*}:fabricStats", customMethodHandler(custom, custom.backend.StatsNvmePathFabric))
+	registerCustomMethod(mux, http.MethodGet, "/v1/nvmePathEvents", customMethodHandler(custom, custom.backend.ListNvmePathEvents))
+	registerCustomMethod(mux, http.MethodPost, "/v1/tlsPsks", customMethodHandler(custom, custom.backend.CreateTLSPsk))
+	registerCustomMethod(mux, http.MethodGet, "/v1/tlsPsks", customMethodHandler(custom, custom.backend.ListTLSPsks))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=tlsPsks/*}", customMethodHandler(custom, custom.backend.GetTLSPsk))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=tlsPsks/*}", customMethodHandler(custom, custom.backend.DeleteTLSPsk))
+	registerCustomMethod(mux, http.MethodPost, "/v1/lvolStores", customMethodHandler(custom, custom.backend.CreateLvolStore))
+	registerCustomMethod(mux, http.MethodGet, "/v1/lvolStores", customMethodHandler(custom, custom.backend.ListLvolStores))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=lvolStores/*}", customMethodHandler(custom, custom.backend.GetLvolStore))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=lvolStores/*}", customMethodHandler(custom, custom.backend.DeleteLvolStore))
+	registerCustomMethod(mux, http.MethodPost, "/v1/lvols", customMethodHandler(custom, custom.backend.CreateLvol))
+	registerCustomMethod(mux, http.MethodGet, "/v1/lvols", customMethodHandler(custom, custom.backend.ListLvols))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=volumes/*}/lvol", customMethodHandler(custom, custom.backend.GetLvol))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=volumes/*}/lvol", customMethodHandler(custom, custom.backend.DeleteLvol))
+	registerCustomMethod(mux, http.MethodPost, "/v1/xnvmeVolumes", customMethodHandler(custom, custom.backend.CreateXnvmeVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/xnvmeVolumes", customMethodHandler(custom, custom.backend.ListXnvmeVolumes))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=volumes/*}/xnvme", customMethodHandler(custom, custom.backend.GetXnvmeVolume))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=volumes/*}/xnvme", customMethodHandler(custom, custom.backend.DeleteXnvmeVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/iscsiVolumes", customMethodHandler(custom, custom.backend.CreateIscsiVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/iscsiVolumes", customMethodHandler(custom, custom.backend.ListIscsiVolumes))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=volumes/*}/iscsi", customMethodHandler(custom, custom.backend.GetIscsiVolume))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=volumes/*}/iscsi", customMethodHandler(custom, custom.backend.DeleteIscsiVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/rbdClusters", customMethodHandler(custom, custom.backend.CreateRbdCluster))
+	registerCustomMethod(mux, http.MethodGet, "/v1/rbdClusters", customMethodHandler(custom, custom.backend.ListRbdClusters))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=rbdClusters/*}", customMethodHandler(custom, custom.backend.GetRbdCluster))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=rbdClusters/*}", customMethodHandler(custom, custom.backend.DeleteRbdCluster))
+	registerCustomMethod(mux, http.MethodPost, "/v1/rbdVolumes", customMethodHandler(custom, custom.backend.CreateRbdVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/rbdVolumes", customMethodHandler(custom, custom.backend.ListRbdVolumes))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=volumes/*}/rbd", customMethodHandler(custom, custom.backend.GetRbdVolume))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=volumes/*}/rbd", customMethodHandler(custom, custom.backend.DeleteRbdVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/storagePools", customMethodHandler(custom, custom.backend.CreateStoragePool))
+	registerCustomMethod(mux, http.MethodGet, "/v1/storagePools", customMethodHandler(custom, custom.backend.ListStoragePools))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=storagePools/*}", customMethodHandler(custom, custom.backend.GetStoragePool))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=storagePools/*}", customMethodHandler(custom, custom.backend.DeleteStoragePool))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=storagePools/*}/rebalanceReport", customMethodHandler(custom, custom.backend.GetStoragePoolRebalanceReport))
+	registerCustomMethod(mux, http.MethodPost, "/v1/{name=storagePools/*}:rebalance", customMethodHandler(custom, custom.backend.RebalanceStoragePool))
+	registerCustomMethod(mux, http.MethodPost, "/v1/discoveryServices", customMethodHandler(custom, custom.backend.CreateDiscoveryService))
+	registerCustomMethod(mux, http.MethodGet, "/v1/discoveryServices", customMethodHandler(custom, custom.backend.ListDiscoveryServices))
+	registerCustomMethod(mux, http.MethodGet, "/v1/{name=discoveryServices/*}", customMethodHandler(custom, custom.backend.GetDiscoveryService))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=discoveryServices/*}", customMethodHandler(custom, custom.backend.DeleteDiscoveryService))
+	registerCustomMethod(mux, http.MethodGet, "/v1/nvmeDevices/{device}/firmware", customMethodHandler(custom, custom.backend.GetNvmeFirmware))
+	registerCustomMethod(mux, http.MethodPost, "/v1/nvmeDevices/{device}/firmware:update", customMethodHandler(custom, custom.backend.UpdateNvmeFirmware))
+	registerCustomMethod(mux, http.MethodGet, "/v1/nvmeDevices/{device}/opal", customMethodHandler(custom, custom.backend.GetNvmeOpal))
+	registerCustomMethod(mux, http.MethodPost, "/v1/nvmeDevices/{device}/opal:takeOwnership", customMethodHandler(custom, custom.backend.TakeNvmeOpalOwnership))
+	registerCustomMethod(mux, http.MethodPost, "/v1/nvmeDevices/{device}/opal:updateLockingRange", customMethodHandler(custom, custom.backend.UpdateNvmeOpalLockingRange))
+	registerCustomMethod(mux, http.MethodPost, "/v1/nvmeDevices/{device}/opal:setLockState", customMethodHandler(custom, custom.backend.SetNvmeOpalLockState))
+	registerCustomMethod(mux, http.MethodPost, "/v1/nvmeDevices/{device}:telemetryLog", customStreamHandler(custom, custom.backend.GetNvmeTelemetryLog))
+	registerCustomMethod(mux, http.MethodGet, "/v1/volumeAllocations/{volume}", customMethodHandler(custom, custom.backend.StatsVolumeAllocation))
+	registerCustomMethod(mux, http.MethodPost, "/v1/volumeOffloads/{volume}:copy", customMethodHandler(custom, custom.backend.CopyVolume))
+	registerCustomMethod(mux, http.MethodPost, "/v1/volumeOffloads/{volume}:writeZeroes", customMethodHandler(custom, custom.backend.WriteZeroesVolume))
+	registerCustomMethod(mux, http.MethodGet, "/v1/poolAllocations", customMethodHandler(custom, custom.backend.ListPoolAllocations))
+	registerCustomMethod(mux, http.MethodGet, "/v1/poolEvents", customMethodHandler(custom, custom.backend.ListPoolEvents))
+	registerCustomMethod(mux, http.MethodPost, "/v1/volumes:rescan", customMethodHandler(custom, custom.backend.RescanVolumes))
+	registerCustomMethod(mux, http.MethodGet, "/v1/volumeResizeEvents", customMethodHandler(custom, custom.backend.ListVolumeResizeEvents))
+	registerCustomMethod(mux, http.MethodGet, "/v1/volumeScrubs", customMethodHandler(custom, custom.backend.ListVolumeScrubs))
+	registerCustomMethod(mux, http.MethodGet, "/v1/volumeScrubs/{volume}", customMethodHandler(custom, custom.backend.GetVolumeScrub))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/volumeScrubs/{volume}", customMethodHandler(custom, custom.backend.UpdateVolumeScrub))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/volumeScrubs/{volume}", customMethodHandler(custom, custom.backend.DeleteVolumeScrub))
+	registerCustomMethod(mux, http.MethodPost, "/v1/volumeScrubs/{volume}:start", customMethodHandler(custom, custom.backend.StartVolumeScrub))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/dpu/telemetry", customMethodHandler(custom, custom.platform.GetDpuTelemetry))
+	registerCustomMethod(mux, http.MethodGet, "/v1/dpu/resourceUsage", customMethodHandler(custom, custom.platform.GetDpuResourceUsage))
+	registerCustomMethod(mux, http.MethodGet, "/v1/dpu/inventory", customMethodHandler(custom, custom.platform.GetDpuInventory))
+	registerCustomMethod(mux, http.MethodPost, "/v1/dpu:setup", customMethodHandler(custom, custom.platform.SetupDpu))
+	registerCustomMethod(mux, http.MethodPost, "/v1/dpu:attest", customMethodHandler(custom, custom.platform.GetDpuAttestation))
+	registerCustomMethod(mux, http.MethodGet, "/v1/capabilities", customMethodHandler(custom, custom.platform.GetCapabilities))
+	registerCustomMethod(mux, http.MethodGet, "/v1/version", customMethodHandler(custom, custom.platform.GetBridgeVersion))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/auditEntries", customMethodHandler(custom, custom.audit.ListAuditEntries))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/flightRecord", customMethodHandler(custom, custom.recorder.GetFlightRecord))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/events", customMethodHandler(custom, custom.events.ListEvents))
+	registerCustomMethod(mux, http.MethodGet, "/v1/events:watch", customStreamHandler(custom, custom.events.WatchEvents))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/apiVersions", customMethodHandler(custom, custom.versions.ListAPIVersions))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/export", customMethodHandler(custom, custom.export.ExportResources))
+	registerCustomMethod(mux, http.MethodPost, "/v1/config:apply", customMethodHandler(custom, custom.apply.ApplyConfig))
+
+	registerCustomMethod(mux, http.MethodPost, "/v1/faults", customMethodHandler(custom, custom.faults.CreateFault))
+	registerCustomMethod(mux, http.MethodGet, "/v1/faults", customMethodHandler(custom, custom.faults.ListFaults))
+	registerCustomMethod(mux, http.MethodDelete, "/v1/{name=faults/*}", customMethodHandler(custom, custom.faults.DeleteFault))
+
+	registerCustomMethod(mux, http.MethodGet, "/v1/logConfig", customMethodHandler(custom, custom.logging.GetLogConfig))
+	registerCustomMethod(mux, http.MethodPatch, "/v1/logConfig", customMethodHandler(custom, custom.logging.UpdateLogConfig))
+
+	registerCustomMethod(mux, http.MethodPost, "/v1/config:reload", customMethodHandler(custom, custom.config.ReloadConfig))
+
+	registerCustomMethod(mux, http.MethodPost, "/v1/telemetry:subscribe", customStreamHandler(custom, custom.gnmi.Subscribe))
+	registerCustomMethod(mux, http.MethodGet, "/v1/telemetry:subscribe", customStreamHandler(custom, custom.gnmi.Subscribe))
+
+	registerCustomMethod(mux, http.MethodPost, "/v1/system:ping", customMethodHandler(custom, custom.gnoi.Ping))
+	registerCustomMethod(mux, http.MethodPost, "/v1/system:reboot", customMethodHandler(custom, custom.gnoi.Reboot))
+	registerCustomMethod(mux, http.MethodPost, "/v1/system:clearStats", customMethodHandler(custom, custom.gnoi.ClearStats))
+	registerCustomMethod(mux, http.MethodPost, "/v1/system:factoryReset", customMethodHandler(custom, custom.gnoi.FactoryReset))
+
+	registerCustomMethod(mux, http.MethodGet, "/redfish/v1", customMethodHandler(custom, custom.redfish.GetServiceRoot))
+	registerCustomMethod(mux, http.MethodGet, "/redfish/v1/Systems", customMethodHandler(custom, custom.redfish.ListSystems))
+	registerCustomMethod(mux, http.MethodGet, "/redfish/v1/Systems/{system}", customMethodHandler(custom, custom.redfish.GetSystem))
+	registerCustomMethod(mux, http.MethodGet, "/redfish/v1/Systems/{system}/Storage", customMethodHandler(custom, custom.redfish.ListStorage))
+	registerCustomMethod(mux, http.MethodGet, "/redfish/v1/Systems/{system}/Storage/{storage}", customMethodHandler(custom, custom.redfish.GetStorage))
+	registerCustomMethod(mux, http.MethodGet, "/redfish/v1/Systems/{system}/Storage/{storage}/Volumes", customMethodHandler(custom, custom.redfish.ListVolumes))
+	registerCustomMethod(mux, http.MethodPost, "/redfish/v1/Systems/{system}/Storage/{storage}/Volumes", customMethodHandler(custom, custom.redfish.CreateVolume))
+	registerCustomMethod(mux, http.MethodGet, "/redfish/v1/Systems/{system}/Storage/{storage}/Volumes/{volume}", customMethodHandler(custom, custom.redfish.GetVolume))
+	registerCustomMethod(mux, http.MethodDelete, "/redfish/v1/Systems/{system}/Storage/{storage}/Volumes/{volume}", customMethodHandler(custom, custom.redfish.DeleteVolume))
+}
+
+func registerCustomMethod(mux *runtime.ServeMux, method string, pattern string, handler runtime.HandlerFunc) {
+	err := mux.HandlePath(method, pattern, handler)
+	if err != nil {
+		log.Panicf("cannot register %s %s handler: %v", method, pattern, err)
+	}
+}
+
+// customMethodHandler adapts a server method to an HTTP handler, the request is decoded
+// from the JSON body and the path parameters, the response is encoded as JSON
+func customMethodHandler[T any, R any](custom *customServers, method func(context.Context, *T) (*R, error)) runtime.HandlerFunc {
+	name := customMethodName(method)
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		serveCustomMethod(custom, name, w, r, pathParams, func(ctx context.Context, in *T, tenantID string) (interface{}, error) {
+			out, err := method(ctx, in)
+			if err != nil {
+				writeCustomMethodError(w, tenant.UnscopeError(tenantID, err))
+				return nil, err
+			}
+			var response interface{} = out
+			if tenantID != "" && !loopbackMethods[name] && !filtersTenant(name) {
+				response, err = tenant.UnscopeJSON(tenantID, out)
+				if err != nil {
+					writeCustomMethodError(w, err)
+					return nil, err
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				slog.Warn("cannot encode response", "path", r.URL.Path, "error", err)
+			}
+			return out, nil
+		})
+	}
+}
+
+// customStreamHandler adapts a server method sending its responses as they come to an HTTP
+// handler, the request is decoded as for customMethodHandler and each response is sent as it
+// comes, {"result": ...}, see serveStream. A failure after the first response is sent as a
+// last {"error": ...}, as the gateway does for the gRPC server streams
+func customStreamHandler[T any, R any](custom *customServers, method func(context.Context, *T, func(*R) error) error) runtime.HandlerFunc {
+	name := customMethodName(method)
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		serveCustomMethod(custom, name, w, r, pathParams, func(ctx context.Context, in *T, tenantID string) (interface{}, error) {
+			err := serveStream(ctx, w, r, func(ctx context.Context, send func(response interface{}) error) error {
+				return tenant.UnscopeError(tenantID, method(ctx, in, func(out *R) error {
+					var response interface{} = out
+					if tenantID != "" && !filtersTenant(name) {
+						var err error
+						if response, err = tenant.UnscopeJSON(tenantID, out); err != nil {
+							return err
+						}
+					}
+					return send(response)
+				}))
+			})
+			return nil, err
+		})
+	}
+}
+
+// serveCustomMethod decodes the request of a custom method from the JSON body and the path
+// parameters, checks the caller may make it and has call write the response. The calls
+// changing the resources are audited and their events recorded, the refused ones too
+func serveCustomMethod[T any](custom *customServers, name string, w http.ResponseWriter, r *http.Request, pathParams map[string]string, call func(ctx context.Context, in *T, tenantID string) (interface{}, error)) {
+	ctx := requestid.FromHTTPRequest(w, r)
+	tenantID := r.Header.Get(tenant.HeaderKey)
+	// the identity is authenticated, the Opi-Identity header any client could set is ignored
+	identity := ""
+	// the calls changing the resources are audited, the refused ones too
+	var resource string
+	var audited []byte
+	var err error
+	if r.Method != http.MethodGet {
+		defer func() {
+			custom.audit.Record(ctx, identity, tenantID, name, resource, audited, err)
+		}()
+	}
+	// the identity is the one of the bearer token, as for the gRPC calls
+	if custom.verifier != nil {
+		var token *oidc.Token
+		if token, err = custom.verifier.VerifyHeader(ctx, r.Header.Get(oidc.HeaderKey)); err != nil {
+			identity = ""
+			writeCustomMethodError(w, err)
+			return
+		}
+		identity = token.Identity
+		ctx = oidc.NewContext(ctx, token)
+	}
+	// or the name of the API key, its scope has to allow the method
+	if custom.keys != nil {
+		var key *apikey.Key
+		if key, err = custom.keys.Check(r.Header.Get(apikey.HeaderKey), name); err != nil {
+			identity = ""
+			writeCustomMethodError(w, err)
+			return
+		}
+		identity = key.Name
+		ctx = authz.NewContext(ctx, key.Name)
+	}
+	if err = tenant.Validate(tenantID); err != nil {
+		writeCustomMethodError(w, err)
+		return
+	}
+	if custom.policy != nil {
+		if err = custom.policy.Authorize(identity, name, tenantID); err != nil {
+			writeCustomMethodError(w, err)
+			return
+		}
+	}
+	// the calls to the gRPC server made while serving the loopback methods hold their slot, they
+	// aren't limited again
+	delegation := ""
+	if custom.limiter != nil {
+		var release func()
+		if loopbackMethods[name] {
+			release, delegation, err = custom.limiter.AcquireDelegation(identity, name)
+		} else {
+			release, err = custom.limiter.Acquire(identity, name)
+		}
+		if err != nil {
+			writeCustomMethodError(w, err)
+			return
+		}
+		defer release()
+	}
+	// numbers are kept as is, float64 can't hold all the 64 bits integers
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	request := make(map[string]interface{})
+	// an empty body is an empty request
+	if err = decoder.Decode(&request); errors.Is(err, io.EOF) {
+		err = nil
+	}
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, err.Error())
+		writeCustomMethodError(w, err)
+		return
+	}
+	// the browsers can't send a body with their GET requests, i.e. EventSource
+	if r.Method == http.MethodGet {
+		if err = decodeQueryParams[T](r.URL.Query(), request); err != nil {
+			err = status.Error(codes.InvalidArgument, err.Error())
+			writeCustomMethodError(w, err)
+			return
+		}
+	}
+	// path parameters take precedence over the body
+	for key, value := range pathParams {
+		if key == "name" {
+			value = storageResourcePrefix + value
+		}
+		request[key] = value
+	}
+	if custom.engine != nil {
+		input := &authz.Input{Identity: identity, Method: name, Tenant: tenantID}
+		input.Request, _ = json.Marshal(request)
+		if err = custom.engine.Decide(ctx, input); err != nil {
+			writeCustomMethodError(w, err)
+			return
+		}
+	}
+	// the audit entries have the names the tenant knows, as the gRPC ones
+	if r.Method != http.MethodGet {
+		resource = customMethodResource(request)
+		audited, _ = json.Marshal(request)
+	}
+	if loopbackMethods[name] {
+		ctx = loopbackContext(ctx, r.Header.Get, identity, tenantID, delegation)
+	} else if filtersTenant(name) {
+		ctx = tenant.NewContext(ctx, tenantID)
+	} else if tenantID != "" {
+		tenant.ScopeJSON(tenantID, request)
+	}
+	params, err := json.Marshal(request)
+	if err != nil {
+		writeCustomMethodError(w, err)
+		return
+	}
+	in := new(T)
+	if err = json.Unmarshal(params, in); err != nil {
+		err = status.Error(codes.InvalidArgument, err.Error())
+		writeCustomMethodError(w, err)
+		return
+	}
+	if custom.router != nil {
+		if resource := customMethodResource(request); resource != "" {
+			ctx = custom.router.Place(ctx, resource)
+		}
+	}
+	// the events have the names of the resources in the store, as the gRPC ones
+	_, err = custom.events.Observe(ctx, name, customMethodResource(request), func(ctx context.Context) (interface{}, error) {
+		return call(ctx, in, tenantID)
+	})
+}
+
+// decodeQueryParams adds the query parameters to a request, the ones of the string fields of T
+// as is and the other ones as JSON, i.e. ?name=...&pageSize=10
+func decodeQueryParams[T any](query url.Values, request map[string]interface{}) error {
+	fields := make(map[string]reflect.Kind)
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for i := 0; t.Kind() == reflect.Struct && i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+			fields[name] = field.Type.Kind()
+		}
+	}
+	for key, values := range query {
+		kind, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("unknown query parameter %s", key)
+		}
+		value := values[len(values)-1]
+		if kind == reflect.String {
+			request[key] = value
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(value))
+		decoder.UseNumber()
+		var decoded interface{}
+		if err := decoder.Decode(&decoded); err != nil {
+			return fmt.Errorf("query parameter %s: %w", key, err)
+		}
+		request[key] = decoded
+	}
+	return nil
+}
+
+// customMethodResource returns the name of the resource of a custom method request, its
+// parent for the creations
+func customMethodResource(request map[string]interface{}) string {
+	if name, ok := request["name"].(string); ok && name != "" {
+		return name
+	}
+	parent, _ := request["parent"].(string)
+	return parent
+}
+
+// customMethodName names a Marvell specific method for the authorization policies after
+// the package and the method of its server, i.e. marvell.frontend/CreateQuota
+func customMethodName(method interface{}) string {
+	// i.e. github.com/opiproject/opi-marvell-bridge/pkg/frontend.(*Server).CreateQuota-fm
+	name := goruntime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
+	name = strings.TrimSuffix(name[strings.LastIndex(name, "/")+1:], "-fm")
+	pkg := name[:strings.Index(name, ".")]
+	return "marvell." + pkg + "/" + name[strings.LastIndex(name, ".")+1:]
+}
+
+// filteringServers are the servers of the custom methods filtering the resources of the tenant
+// of the context themselves, as the tenant.Filtering gRPC servers, by package
+var filteringServers = map[string]bool{
+	"marvell.gnmi":    true,
+	"marvell.redfish": true,
+}
+
+// filtersTenant tells whether a custom method filters the resources of the tenant itself, its
+// request and its response aren't rewritten
+func filtersTenant(name string) bool {
+	return filteringServers[name[:strings.Index(name, "/")]]
+}
+
+func writeCustomMethodError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(runtime.HTTPStatusFromCode(st.Code()))
+	if err := json.NewEncoder(w).Encode(st.Proto()); err != nil {
+		slog.Warn("cannot encode error response", "error", err)
+	}
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/recorder"
+)
+
+// dumpFlightRecordOnSignal dumps the flight recorder to a new file of the temporary directory on
+// every SIGUSR1, the path of the file is logged
+func dumpFlightRecordOnSignal(flightRecorder *recorder.Recorder) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	for range signals {
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("opi-marvell-bridge-flight-record-%s.json", time.Now().UTC().Format("20060102T150405.000Z")))
+		if err := dumpFlightRecord(flightRecorder, path); err != nil {
+			slog.Error("Could not dump the flight recorder", "path", path, "error", err)
+			continue
+		}
+		slog.Info("Flight recorder dumped", "path", path)
+	}
+}
+
+// dumpFlightRecord dumps the flight recorder to a file only readable by the bridge user
+func dumpFlightRecord(flightRecorder *recorder.Recorder, path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := flightRecorder.Dump(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"net/http"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/health"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// registerHealth exposes the liveness and readiness of the bridge on /healthz and /readyz for
+// the environments which can't probe the gRPC health service, they need no authorization
+func registerHealth(mux *runtime.ServeMux, checker *health.Checker) {
+	registerCustomMethod(mux, http.MethodGet, "/healthz", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		checker.ServeLive(w, r)
+	})
+	registerCustomMethod(mux, http.MethodGet, "/readyz", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		checker.ServeReady(w, r)
+	})
+}
@@ -7,23 +7,75 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/opiproject/gospdk/spdk"
 
+	"github.com/opiproject/opi-marvell-bridge/pkg/alert"
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+	"github.com/opiproject/opi-marvell-bridge/pkg/apiversion"
+	"github.com/opiproject/opi-marvell-bridge/pkg/apply"
+	"github.com/opiproject/opi-marvell-bridge/pkg/audit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+	be "github.com/opiproject/opi-marvell-bridge/pkg/backend"
+	"github.com/opiproject/opi-marvell-bridge/pkg/cache"
+	"github.com/opiproject/opi-marvell-bridge/pkg/cloudevents"
+	"github.com/opiproject/opi-marvell-bridge/pkg/compat"
+	"github.com/opiproject/opi-marvell-bridge/pkg/config"
+	"github.com/opiproject/opi-marvell-bridge/pkg/consistency"
+	"github.com/opiproject/opi-marvell-bridge/pkg/emulator"
+	"github.com/opiproject/opi-marvell-bridge/pkg/etag"
+	"github.com/opiproject/opi-marvell-bridge/pkg/events"
+	"github.com/opiproject/opi-marvell-bridge/pkg/export"
+	"github.com/opiproject/opi-marvell-bridge/pkg/fanout"
+	"github.com/opiproject/opi-marvell-bridge/pkg/faults"
 	fe "github.com/opiproject/opi-marvell-bridge/pkg/frontend"
+	"github.com/opiproject/opi-marvell-bridge/pkg/gnmi"
+	"github.com/opiproject/opi-marvell-bridge/pkg/gnoi"
+	"github.com/opiproject/opi-marvell-bridge/pkg/handoff"
+	"github.com/opiproject/opi-marvell-bridge/pkg/health"
+	"github.com/opiproject/opi-marvell-bridge/pkg/ipfilter"
+	"github.com/opiproject/opi-marvell-bridge/pkg/ipmi"
+	"github.com/opiproject/opi-marvell-bridge/pkg/jsonrpc"
+	"github.com/opiproject/opi-marvell-bridge/pkg/kms"
+	"github.com/opiproject/opi-marvell-bridge/pkg/logger"
+	"github.com/opiproject/opi-marvell-bridge/pkg/metrics"
+	me "github.com/opiproject/opi-marvell-bridge/pkg/middleend"
+	"github.com/opiproject/opi-marvell-bridge/pkg/oidc"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operator"
+	"github.com/opiproject/opi-marvell-bridge/pkg/pagination"
+	"github.com/opiproject/opi-marvell-bridge/pkg/placement"
+	"github.com/opiproject/opi-marvell-bridge/pkg/platform"
+	"github.com/opiproject/opi-marvell-bridge/pkg/ratelimit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/recorder"
+	"github.com/opiproject/opi-marvell-bridge/pkg/redfish"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/sku"
+	"github.com/opiproject/opi-marvell-bridge/pkg/supervisor"
+	"github.com/opiproject/opi-marvell-bridge/pkg/systemd"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tlsreload"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tracing"
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
 	"github.com/opiproject/opi-smbios-bridge/pkg/inventory"
-	"github.com/opiproject/opi-spdk-bridge/pkg/backend"
 	"github.com/opiproject/opi-spdk-bridge/pkg/frontend"
 	"github.com/opiproject/opi-spdk-bridge/pkg/middleend"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 	"github.com/opiproject/opi-strongswan-bridge/pkg/ipsec"
 
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
 	pc "github.com/opiproject/opi-api/inventory/v1/gen/go"
 	ps "github.com/opiproject/opi-api/security/v1/gen/go"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
@@ -32,32 +84,337 @@ import (
 	"github.com/philippgille/gokv/redis"
 
 	"google.golang.org/grpc"
+	channelzservice "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/encoding/protojson"
 
-	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
 )
 
+// poolThresholdsInterval is how often the usage of the pools is checked against the thresholds
+const poolThresholdsInterval = 30 * time.Second
+
+// volumeScrubsInterval is how often the running scrubs are followed and the scheduled ones started
+const volumeScrubsInterval = time.Minute
+
+// nvmePathsInterval is how often the state of the paths to the remote targets is checked
+const nvmePathsInterval = 5 * time.Second
+
+// discoveryServicesInterval is how often the discovery log pages are checked for changes
+const discoveryServicesInterval = 10 * time.Second
+
+// healthProbeInterval is how often the firmware is probed for the health service
+const healthProbeInterval = 5 * time.Second
+
 func main() {
+	log.SetOutput(redactingWriter{w: os.Stderr})
+
+	var configFile string
+	flag.StringVar(&configFile, "config", "", "YAML file of the settings, keyed by flag name, the flags set on the command line take precedence")
+
+	var applySigningKey string
+	flag.StringVar(&applySigningKey, "apply_signing_key", "", "PEM file of the public keys of the pipelines allowed to apply declarative configs, the configs then have to be signed, unsigned when empty")
+
 	var grpcPort int
 	flag.IntVar(&grpcPort, "grpc_port", 50051, "The gRPC server port")
 
 	var httpPort int
 	flag.IntVar(&httpPort, "http_port", 8082, "The HTTP server port")
 
+	var httpProtoNames bool
+	flag.BoolVar(&httpProtoNames, "http_proto_names", false, "Use the field names of the protos in the JSON of the HTTP server, i.e. volume_name_ref as in the custom methods, rather than lowerCamelCase")
+
+	var defaultAPIVersion string
+	flag.StringVar(&defaultAPIVersion, "default_api_version", apiversion.V1Alpha1, "Version of the storage API of the calls not giving any in the opi-api-version gRPC metadata or Opi-Api-Version HTTP header, v1alpha1 or v1")
+
+	var grpcReflection bool
+	flag.BoolVar(&grpcReflection, "grpc_reflection", true, "Serve the gRPC server reflection, so grpcurl and grpc-cli can list and call the services")
+
+	var k8sOperator bool
+	flag.BoolVar(&k8sOperator, "k8s_operator", false, "Reconcile the NvmeSubsystem, NvmeController and NvmeNamespace Kubernetes custom resources into the bridge, with the service account of its pod")
+
+	var k8sNamespace string
+	flag.StringVar(&k8sNamespace, "k8s_namespace", "", "Kubernetes namespace of the custom resources the -k8s_operator reconciles, the one of the pod when empty")
+
+	var k8sManifests bool
+	flag.BoolVar(&k8sManifests, "k8s_manifests", false, "Print the CustomResourceDefinitions the -k8s_operator reconciles and the RBAC of its service account, to be applied with kubectl apply -f -, and exit")
+
+	var k8sResyncSec int
+	flag.IntVar(&k8sResyncSec, "k8s_resync_sec", 300, "Seconds between the full reconciliations of the Kubernetes custom resources, their changes are watched in between")
+
+	var grpcChannelz bool
+	flag.BoolVar(&grpcChannelz, "grpc_channelz", false, "Serve the gRPC channelz service, to inspect the connections and the calls of the gRPC server")
+
+	var shutdownTimeoutSec int
+	flag.IntVar(&shutdownTimeoutSec, "shutdown_timeout_sec", 30, "Time given to the requests in flight to complete on SIGTERM, in seconds, the servers are closed after")
+
+	var adminPort int
+	flag.IntVar(&adminPort, "admin_port", 0, "The port of the pprof and expvar debug server, listening on localhost only, disabled when 0")
+
 	var spdkAddress string
 	flag.StringVar(&spdkAddress, "spdk_addr", "/var/tmp/spdk.sock", "Points to SPDK unix socket/tcp socket to interact with")
 
+	var emulate bool
+	flag.BoolVar(&emulate, "emulate", false, "Emulate the Marvell firmware in memory instead of calling the one at spdk_addr, to try out or test the whole API without a DPU nor SPDK, nothing is kept across restarts of the bridge but the database")
+
+	var faultInjection bool
+	flag.BoolVar(&faultInjection, "fault_injection", false, "Allow faults to be injected in the calls to the firmware with /v1/faults, to validate the rollback and retry logic, never in production")
+
+	var spdkApp string
+	flag.StringVar(&spdkApp, "spdk_app", "", "Command line of the Marvell SPDK application the bridge launches and restarts when it crashes or hangs, replaying its configuration, disabled when empty")
+
+	var spdkAppPingIntervalSec int
+	flag.IntVar(&spdkAppPingIntervalSec, "spdk_app_ping_interval_sec", 5, "Interval of the pings of the -spdk_app, in seconds, it is restarted after 3 missed pings")
+
+	var spdkRestartCheckIntervalSec int
+	flag.IntVar(&spdkRestartCheckIntervalSec, "spdk_restart_check_interval_sec", 5, "Interval of the checks of the SPDK application the bridge doesn't launch, in seconds, once it restarted without the configuration of the bridge the controllers and namespaces are reported inactive and replayed, disabled when 0")
+
+	var spdkInstances string
+	flag.StringVar(&spdkInstances, "spdk_instances", "", "Other SPDK instances the bridge manages in name=address format, comma separated, i.e. numa1=/var/tmp/spdk1.sock, the one of spdk_addr is named default")
+
+	var spdkPlacement string
+	flag.StringVar(&spdkPlacement, "spdk_placement", "", "Rules placing the resources on the spdk_instances in prefix=instance format, comma separated, the resources whose top level ID starts with prefix go to instance, the others to the default one")
+
+	var rpcCapture string
+	flag.StringVar(&rpcCapture, "rpc_capture", "", "File all the JSON-RPC calls to the firmware are appended to, key material redacted, disabled when empty")
+
+	var firmwareShims string
+	flag.StringVar(&firmwareShims, "firmware_shims", "", "YAML file of the shims renaming or re-shaping the firmware methods for ranges of Marvell SDK releases, the release of the firmware is detected on the first call of a shimmed method")
+
+	var skuProfiles string
+	flag.StringVar(&skuProfiles, "sku_profiles", "", "YAML file of the profiles of the Marvell SKUs, with their features, limits and firmware method variants, matched before the built-in ones")
+
+	var skuProfile string
+	flag.StringVar(&skuProfile, "sku_profile", "", "Profile of the SKU used whatever the SKU the firmware reports, i.e. cn106, detected when empty")
+
+	var rpcReplay string
+	flag.StringVar(&rpcReplay, "rpc_replay", "", "Capture of JSON-RPC calls re-issued to the firmware at spdk_addr, the bridge exits once they are replayed, the divergent responses are logged")
+
+	var setupProfile string
+	flag.StringVar(&setupProfile, "setup_profile", "", "Profile the hugepages and the cores of the DPU are set up for, small, medium or large, the bridge exits once they are, printing the core mask of the SPDK application")
+
 	var tlsFiles string
 	flag.StringVar(&tlsFiles, "tls", "", "TLS files in server_cert:server_key:ca_cert format.")
 
+	var tlsReloadIntervalSec int
+	flag.IntVar(&tlsReloadIntervalSec, "tls_reload_interval_sec", 60, "Interval of the checks of the TLS files, in seconds, the certificates are reloaded when they changed, disabled when 0")
+
+	var spiffeTrustDomain string
+	flag.StringVar(&spiffeTrustDomain, "spiffe_trust_domain", "", "SPIFFE trust domain the gRPC clients have to present an X.509 SVID of, the SVID of the bridge and the trust bundle coming from spiffe_endpoint_socket or the TLS files, disabled when empty")
+
+	var spiffeEndpointSocket string
+	flag.StringVar(&spiffeEndpointSocket, "spiffe_endpoint_socket", "", "SPIFFE Workload API of the agent the X.509 SVID of the bridge and the trust bundle are fetched from instead of the TLS files, i.e. unix:///run/spire/sockets/agent.sock, disabled when empty")
+
 	var redisAddress string
 	flag.StringVar(&redisAddress, "redis_addr", "127.0.0.1:6379", "Redis address in ip_address:port format")
 
+	var nsChangeAen bool
+	flag.BoolVar(&nsChangeAen, "ns_change_aen", true, "Raise the Namespace Attribute Changed event to the hosts when namespaces are attached or detached")
+
+	var thinProvisioning bool
+	flag.BoolVar(&thinProvisioning, "thin_provisioning", false, "Create thin provisioned namespaces, reporting the capacity allocated on their volume")
+
+	var pcieFunctionPool string
+	flag.StringVar(&pcieFunctionPool, "pcie_function_pool", "", "PCIe functions given to the Nvme controllers created without a PcieId in port:pf:vf format, comma separated, the virtual functions being a number or a range, i.e. 0:0:0,0:1:1-63, the PcieId is required when empty")
+
+	var defaultProfile string
+	flag.StringVar(&defaultProfile, "default_profile", "", "Defaults of the Nvme controllers and subsystems created without them in key=value format, comma separated, the keys being max_nsq, max_ncq, sqes, cqes and serial_prefix, i.e. max_nsq=16,max_ncq=16,sqes=10,cqes=4,serial_prefix=MRVL, the firmware defaults are kept when empty")
+
+	var validationMode string
+	flag.StringVar(&validationMode, "validation_mode", string(validation.Strict), "Handling of the values of the Nvme subsystem and controller specs the firmware doesn't support, strict refusing them or permissive ignoring and clamping them with warnings readable with the warnings custom method")
+
+	var readConsistency string
+	flag.StringVar(&readConsistency, "read_consistency", string(consistency.Strong), "Consistency of the Get and List methods of the Nvme subsystems, controllers and namespaces not giving any in the opi-read-consistency gRPC metadata or Opi-Read-Consistency HTTP header, strong verifying them with the firmware or cached answering from the database of the bridge")
+
+	var firmwareCacheTTLMs int
+	flag.IntVar(&firmwareCacheTTLMs, "firmware_cache_ttl_ms", 0, "Time the responses of the queries of the firmware, i.e. the info of the controllers or the lists of the namespaces, are answered from the cache, in milliseconds, the calls changing the firmware invalidate it, disabled when 0")
+
+	var fanoutWorkers int
+	flag.IntVar(&fanoutWorkers, "fanout_workers", fanout.DefaultWorkers, "Calls to the firmware made at once when reading the stats of many controllers or namespaces, i.e. in the metrics, the gNMI subscriptions or the clearing of the stats")
+
+	var maxPageSize int
+	flag.IntVar(&maxPageSize, "max_page_size", pagination.DefaultLimits.MaxPageSize, "Highest page size of the List methods, the requests of bigger pages get pages of this size")
+
+	var defaultPageSize int
+	flag.IntVar(&defaultPageSize, "default_page_size", pagination.DefaultLimits.DefaultPageSize, "Page size of the List methods for the requests without one")
+
+	var pageTokenLifetimeSec int
+	flag.IntVar(&pageTokenLifetimeSec, "page_token_lifetime_sec", int(pagination.DefaultLimits.TokenLifetime.Seconds()), "Time the page tokens of the List methods can be used, in seconds, they don't expire when 0")
+
+	var keepAliveTimeoutMs int
+	flag.IntVar(&keepAliveTimeoutMs, "keep_alive_timeout_ms", 0, "Keep alive timeout of the fabrics connections to the remote targets, in milliseconds, the remote controllers can override it, firmware default when 0")
+
+	var reconnectDelaySec int
+	flag.IntVar(&reconnectDelaySec, "reconnect_delay_sec", 0, "Delay before reconnecting a lost fabrics connection, in seconds, the remote controllers can override it, firmware default when 0")
+
+	var poolThresholds string
+	flag.StringVar(&poolThresholds, "pool_thresholds", "80,90,95", "Comma separated usage levels of the pools, in percent, raising an event when crossed, disabled when empty")
+
+	var kmsURL string
+	flag.StringVar(&kmsURL, "kms_url", "", "Key management service URL the keys of the encrypted volumes are fetched from, disabled when empty")
+
+	var attestationKey string
+	flag.StringVar(&attestationKey, "attestation_key", "", "PEM file of the private key signing the attestation evidences of the DPU, set with attestation_cert, the DPU can't be attested when empty")
+
+	var attestationCert string
+	flag.StringVar(&attestationCert, "attestation_cert", "", "PEM file of the certificates of the attestation_key, leaf first")
+
+	var volumeRescanIntervalSec int
+	flag.IntVar(&volumeRescanIntervalSec, "volume_rescan_interval_sec", 0, "Interval of the periodic rescan of the backend volumes picking up their size changes, in seconds, disabled when 0")
+
+	var rebalanceIntervalSec int
+	flag.IntVar(&rebalanceIntervalSec, "rebalance_interval_sec", 0, "Interval of the periodic reports on the storage pools, logging the skewed ones, in seconds, disabled when 0")
+
+	var rebalanceSkewPercent int
+	flag.IntVar(&rebalanceSkewPercent, "rebalance_skew_percent", 20, "Usage gap between the lvol stores of a storage pool, in percent, above which migrations are proposed")
+
+	var authzPolicy string
+	flag.StringVar(&authzPolicy, "authz_policy", "", "JSON file of the roles granted to the identities calling the bridge, per tenant, disabled when empty")
+
+	var authzOpaURL string
+	flag.StringVar(&authzOpaURL, "authz_opa_url", "", "URL of the Open Policy Agent document deciding on each call from its identity, method, tenant and request, i.e. http://localhost:8181/v1/data/opi/bridge/allow, disabled when empty")
+
+	var oidcIssuer string
+	flag.StringVar(&oidcIssuer, "oidc_issuer", "", "OpenID Connect issuer URL the bearer tokens of the callers are verified against, its keys are discovered from its configuration, disabled when empty")
+
+	var oidcAudience string
+	flag.StringVar(&oidcAudience, "oidc_audience", "", "Audience the bearer tokens have to be issued for, required with -oidc_issuer")
+
+	var oidcIdentityClaim string
+	flag.StringVar(&oidcIdentityClaim, "oidc_identity_claim", "sub", "Claim of the bearer tokens identifying the callers in the -authz_policy and the audit")
+
+	var apiKeysFile string
+	flag.StringVar(&apiKeysFile, "api_keys", "", "JSON file of the SHA-256 of the API keys the callers have to present, each with its read-only, provisioning or admin scope, disabled when empty")
+
+	var allowedCIDRs string
+	flag.StringVar(&allowedCIDRs, "allowed_cidrs", "", "Comma separated CIDRs the gRPC and HTTP clients can connect from, the connections from the others are closed when accepted, every address when empty")
+
+	var deniedCIDRs string
+	flag.StringVar(&deniedCIDRs, "denied_cidrs", "", "Comma separated CIDRs the gRPC and HTTP clients can't connect from, taking precedence over -allowed_cidrs, none when empty")
+
+	var rateLimit float64
+	flag.Float64Var(&rateLimit, "rate_limit", 0, "Requests per second allowed to each client identity, disabled when 0")
+
+	var rateLimitBurst int
+	flag.IntVar(&rateLimitBurst, "rate_limit_burst", 20, "Requests each client identity can make at once over the -rate_limit")
+
+	var maxMutatingCalls int
+	flag.IntVar(&maxMutatingCalls, "max_mutating_calls", 0, "Calls changing the resources each client identity can have in flight, disabled when 0")
+
+	var auditFile string
+	flag.StringVar(&auditFile, "audit_file", "", "File the calls changing the resources are appended to, as JSON lines, they can be listed from it, disabled when empty")
+
+	var auditURL string
+	flag.StringVar(&auditURL, "audit_url", "", "Remote audit service URL the calls changing the resources are posted to, disabled when empty")
+
+	var alertWebhookURLs string
+	flag.StringVar(&alertWebhookURLs, "alert_webhook_urls", "", "Comma separated webhook URLs the alerts are posted to when a controller becomes inactive, an Nvme path fails or the remote controllers drift from their discovery log page, disabled when empty")
+
+	var cloudEventsSinks string
+	flag.StringVar(&cloudEventsSinks, "cloudevents_sinks", "", "Comma separated http(s), nats://host:port/subject or kafka://host:port/topic URLs the lifecycle and failure events of the resources are published to as CloudEvents, disabled when empty")
+
+	hostname, _ := os.Hostname()
+	var cloudEventsSource string
+	flag.StringVar(&cloudEventsSource, "cloudevents_source", "urn:opi-marvell-bridge:"+hostname, "Source of the published CloudEvents, identifying the bridge")
+
+	var ipmiSensors string
+	flag.StringVar(&ipmiSensors, "ipmi_sensors", "", "Comma separated indicator=sensor numbers of the BMC sensors the storage health is pushed to with ipmitool, the indicators being controllers_active, media_wear and path_failures, i.e. controllers_active=0x60,media_wear=0x61, disabled when empty")
+
+	var ipmitoolArgs string
+	flag.StringVar(&ipmitoolArgs, "ipmitool_args", "", "Space separated arguments of ipmitool selecting the BMC, i.e. -I lanplus -H bmc -U admin -E, the local BMC when empty")
+
+	var ipmiIntervalSec int
+	flag.IntVar(&ipmiIntervalSec, "ipmi_interval_sec", 30, "Interval of the pushes of the storage health to the BMC sensors, in seconds")
+
+	var otlpMetricsEndpoint string
+	flag.StringVar(&otlpMetricsEndpoint, "otlp_metrics_endpoint", "", "OTLP/HTTP collector in host:port format the DPU telemetry and the controller and namespace stats are pushed to, disabled when empty")
+
+	var otlpMetricsInsecure bool
+	flag.BoolVar(&otlpMetricsInsecure, "otlp_metrics_insecure", false, "Push the OTLP metrics over plain HTTP instead of HTTPS")
+
+	var otlpMetricsIntervalSec int
+	flag.IntVar(&otlpMetricsIntervalSec, "otlp_metrics_interval_sec", 60, "Interval of the pushes of the OTLP metrics, in seconds")
+
+	var statsPushURL string
+	flag.StringVar(&statsPushURL, "stats_push_url", "", "statsd://host:port, graphite://host:port or Prometheus remote-write http(s) URL the controller and namespace stats are pushed to, disabled when empty")
+
+	var statsPushPrefix string
+	flag.StringVar(&statsPushPrefix, "stats_push_prefix", "", "Prefix of the names of the pushed stats, i.e. the site or the DPU, none when empty")
+
+	var statsPushIntervalSec int
+	flag.IntVar(&statsPushIntervalSec, "stats_push_interval_sec", 60, "Interval of the pushes of the stats, in seconds")
+
+	var backupURL string
+	flag.StringVar(&backupURL, "backup_url", "", "S3 compatible bucket in http(s)://host/bucket/prefix format the store and the resources are backed up to, with the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION credentials, disabled when empty")
+
+	var backupIntervalSec int
+	flag.IntVar(&backupIntervalSec, "backup_interval_sec", 3600, "Interval of the backups, in seconds")
+
+	var backupKeep int
+	flag.IntVar(&backupKeep, "backup_keep", 24, "Number of the latest backups kept in the bucket, the older ones are deleted")
+
+	var restoreBackupKey string
+	flag.StringVar(&restoreBackupKey, "restore_backup", "", "Key of the backup of the -backup_url, or latest, the resources are provisioned again from on startup, i.e. on the replacement of a failed DPU, disabled when empty")
+
+	var latencyBudgets string
+	flag.StringVar(&latencyBudgets, "latency_budgets", "", "Comma separated method=duration latency budgets of the gRPC methods, i.e. *=1s,CreateNvmeController=5s, the slower requests are counted and logged, disabled when empty")
+
+	var flightRecorderSize int
+	flag.IntVar(&flightRecorderSize, "flight_recorder_size", 100, "Number of the last gRPC requests kept in memory with the Marvell RPCs they made, dumped on SIGUSR1, disabled when 0")
+
+	var logLevel string
+	flag.StringVar(&logLevel, "log_level", "info", "Level of the logged records, debug, info, warn or error, the payloads of the requests are only logged at debug level")
+
+	var logFormat string
+	flag.StringVar(&logFormat, "log_format", "text", "Format of the logged records, text or json")
+
+	var logTarget string
+	flag.StringVar(&logTarget, "log_target", logger.TargetStderr, "Target the records are written to, stderr, file, syslog or journal, syslog and the journal get the priorities of the levels and the journal every attribute as a field")
+
+	var logPath string
+	flag.StringVar(&logPath, "log_path", "", "File the records are appended to, for the file target")
+
 	flag.Parse()
+	if k8sManifests {
+		if err := operator.WriteManifests(os.Stdout); err != nil {
+			log.Panic(err)
+		}
+		return
+	}
+	// the reloader tells the flags of the command line apart before the file sets the others
+	reloader := config.NewReloader(flag.CommandLine, configFile)
+	if configFile != "" {
+		if err := config.Apply(flag.CommandLine, configFile); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	// the level and the target of the logs can be changed at runtime, every target is redacted
+	logControl, err := logger.NewControl(os.Stderr, logLevel, logFormat, func(w io.Writer) io.Writer { return redactingWriter{w: w} })
+	if err != nil {
+		log.Panic(err)
+	}
+	bridgeLogger := logControl.Logger()
+	// the log package writes through the structured logger too
+	slog.SetDefault(bridgeLogger)
+	if _, err := logControl.UpdateLogConfig(context.Background(), &logger.UpdateLogConfigRequest{Target: logTarget, Path: logPath}); err != nil {
+		log.Panic(err)
+	}
+
+	if rpcReplay != "" {
+		replayRPCCapture(rpcReplay, spdkAddress)
+		return
+	}
+
+	if setupProfile != "" {
+		setupDpu(os.Stdout, setupProfile, spdkAddress)
+		return
+	}
 
 	// Create KV store for persistence
 	options := redis.DefaultOptions
@@ -74,11 +431,501 @@ func main() {
 		}
 	}(store)
 
-	go runGatewayServer(grpcPort, httpPort)
-	runGrpcServer(grpcPort, spdkAddress, tlsFiles, store)
-}
+	var policy *authz.Policy
+	if authzPolicy != "" {
+		policy, err = authz.LoadPolicy(authzPolicy)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	var engine authz.Engine
+	if authzOpaURL != "" {
+		engine = authz.NewOPAEngine(authzOpaURL, &http.Client{Timeout: 5 * time.Second})
+	}
+
+	var verifier *oidc.Verifier
+	if oidcIssuer != "" {
+		if oidcAudience == "" {
+			log.Panic("invalid OIDC audience, have to be set with the issuer")
+		}
+		verifier = oidc.NewVerifier(oidcIssuer, oidcAudience, oidcIdentityClaim, &http.Client{Timeout: 10 * time.Second})
+	}
+
+	var keys *apikey.Keys
+	if apiKeysFile != "" {
+		if verifier != nil {
+			log.Panic("invalid authentication, have to be either OIDC or API keys")
+		}
+		keys, err = apikey.LoadKeys(apiKeysFile)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	var limiter *ratelimit.Limiter
+	if rateLimit != 0 || maxMutatingCalls != 0 {
+		if err := ratelimit.Validate(rateLimit, rateLimitBurst, maxMutatingCalls); err != nil {
+			log.Panic(err)
+		}
+		limiter = ratelimit.New(rateLimit, rateLimitBurst, maxMutatingCalls)
+	}
+
+	// the loopback addresses are always allowed, the gateway calls the gRPC server through them
+	filter, err := ipfilter.New(allowedCIDRs, deniedCIDRs)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var auditSink audit.Sink
+	switch {
+	case auditFile != "" && auditURL != "":
+		log.Panic("invalid audit sink, have to be either a file or a URL")
+	case auditFile != "":
+		auditSink, err = audit.NewFileSink(auditFile)
+		if err != nil {
+			log.Panic(err)
+		}
+	case auditURL != "":
+		auditSink = audit.NewHTTPSink(auditURL, &http.Client{Timeout: 10 * time.Second})
+	}
+	auditLog := audit.New(auditSink)
+
+	if flightRecorderSize < 0 || flightRecorderSize > 10000 {
+		log.Panicf("invalid flight recorder size %d, have to be between 0 and 10000", flightRecorderSize)
+	}
+	// the recorded payloads are redacted as the logs
+	flightRecorder := recorder.New(flightRecorderSize, func(w io.Writer) io.Writer { return redactingWriter{w: w} })
+	if flightRecorderSize != 0 {
+		go dumpFlightRecordOnSignal(flightRecorder)
+	}
+
+	bridgeMetrics := metrics.New()
+	if latencyBudgets != "" {
+		budgets, err := metrics.ParseLatencyBudgets(latencyBudgets)
+		if err != nil {
+			log.Panic(err)
+		}
+		bridgeMetrics.SetLatencyBudgets(budgets)
+	}
+	// the lifecycle events of the resources are kept in memory, the calls failing in the firmware included
+	eventHistory := events.New()
+	// the calls to the firmware can be captured as sent and received, to be replayed with -rpc_replay,
+	// with the key material redacted as in the logs
+	var capture *jsonrpc.Capture
+	if rpcCapture != "" {
+		file, err := os.OpenFile(rpcCapture, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer func() { _ = file.Close() }()
+		capture = jsonrpc.NewCapture(redactingWriter{w: file})
+	}
+	// the firmware can be emulated in memory, i.e. in CI, there is then nothing to launch nor to
+	// spread the resources over
+	var spdkClient spdk.JSONRPC
+	if emulate {
+		if spdkApp != "" || spdkInstances != "" {
+			log.Panic("invalid -emulate, the emulated firmware can't be used with -spdk_app nor -spdk_instances")
+		}
+		spdkClient = emulator.New()
+	} else {
+		client := jsonrpc.NewClient(spdkAddress)
+		client.SetCapture(capture)
+		spdkClient = client
+	}
+	var shims []compat.Shim
+	if firmwareShims != "" {
+		if shims, err = compat.LoadShims(firmwareShims); err != nil {
+			log.Panic(err)
+		}
+	}
+	// the profile of the SKU is detected on the default instance of the firmware
+	var profiles []*sku.Profile
+	if skuProfiles != "" {
+		if profiles, err = sku.LoadProfiles(skuProfiles); err != nil {
+			log.Panic(err)
+		}
+	}
+	skuSelector, err := sku.NewSelector(spdkClient, profiles, skuProfile)
+	if err != nil {
+		log.Panicf("invalid -sku_profile: %v", err)
+	}
+	shims = append(shims, skuSelector.Shims()...)
+	// the resources can be spread over several instances of the firmware
+	var firmware spdk.JSONRPC = compat.WrapJSONRPC(spdkClient, shims)
+	var router *placement.Router
+	if spdkInstances != "" {
+		if spdkApp != "" {
+			log.Panic("invalid SPDK instances, the SPDK application launched with -spdk_app has to be the only one")
+		}
+		router, err = newPlacementRouter(spdkClient, spdkInstances, spdkPlacement, capture, shims)
+		if err != nil {
+			log.Panic(err)
+		}
+		firmware = router
+	} else if spdkPlacement != "" {
+		log.Panic("invalid placement rules, have to be set with -spdk_instances")
+	}
+	// the faults are injected as close to the firmware as possible, so everything else sees them
+	// as failures of the firmware
+	faultInjector := faults.New(faultInjection)
+	if faultInjection {
+		slog.Warn("Faults can be injected in the calls to the firmware")
+	}
+	if firmwareCacheTTLMs < 0 || firmwareCacheTTLMs > 3600000 {
+		log.Panicf("invalid firmware cache TTL %d, have to be between 0 and 3600000", firmwareCacheTTLMs)
+	}
+	// the queries answered from the cache are neither traced, counted nor logged as calls to the
+	// firmware, as they never reach it
+	firmwareCache := cache.New(time.Duration(firmwareCacheTTLMs) * time.Millisecond)
+	// the calls to the firmware are traced as children of the spans of the gRPC requests,
+	// the tracer provider set up with the gRPC server is picked up through the global one
+	jsonRPC := firmwareCache.WrapJSONRPC(tracing.WrapJSONRPC(bridgeMetrics.WrapJSONRPC(logger.WrapJSONRPC(flightRecorder.WrapJSONRPC(eventHistory.WrapJSONRPC(apply.WrapJSONRPC(faultInjector.WrapJSONRPC(firmware)))), bridgeLogger)), otel.GetTracerProvider()))
+	pageLimits := pagination.Limits{
+		MaxPageSize:     maxPageSize,
+		DefaultPageSize: defaultPageSize,
+		TokenLifetime:   time.Duration(pageTokenLifetimeSec) * time.Second,
+	}
+	if err := pageLimits.Validate(); err != nil {
+		log.Panic(err)
+	}
+	operationsManager := operations.NewManager()
+	frontendOpiMarvellServer := fe.NewServer(jsonRPC, store, operationsManager)
+	frontendOpiMarvellServer.SetNamespaceChangeAen(nsChangeAen)
+	frontendOpiMarvellServer.SetThinProvisioning(thinProvisioning)
+	if fanoutWorkers < 1 || fanoutWorkers > 1024 {
+		log.Panicf("invalid fan-out workers %d, have to be between 1 and 1024", fanoutWorkers)
+	}
+	frontendOpiMarvellServer.SetFanoutWorkers(fanoutWorkers)
+	frontendOpiMarvellServer.SetSkuLimiter(skuSelector)
+	if pcieFunctionPool != "" {
+		pool, err := fe.ParsePcieFunctionPool(pcieFunctionPool)
+		if err != nil {
+			log.Panic(err)
+		}
+		frontendOpiMarvellServer.SetPcieFunctionPool(pool)
+	}
+	mode, err := validation.ParseMode(validationMode)
+	if err != nil {
+		log.Panicf("invalid -validation_mode: %v", err)
+	}
+	frontendOpiMarvellServer.SetValidationMode(mode)
+	if defaultProfile != "" {
+		profile, err := fe.ParseDefaultProfile(defaultProfile)
+		if err != nil {
+			log.Panic(err)
+		}
+		frontendOpiMarvellServer.SetDefaultProfile(profile)
+	}
+	middleendOpiMarvellServer := me.NewServer(jsonRPC, store, operationsManager)
+	if kmsURL != "" {
+		middleendOpiMarvellServer.SetKeyManager(kms.NewHTTPKeyManager(kmsURL, &http.Client{Timeout: 10 * time.Second}))
+	}
+	backendOpiMarvellServer := be.NewServer(jsonRPC, store, operationsManager)
+	// each server keeps the page tokens of its List methods
+	for _, paginator := range []*pagination.Paginator{frontendOpiMarvellServer.Pagination, middleendOpiMarvellServer.Pagination, backendOpiMarvellServer.Pagination, auditLog.Pagination, eventHistory.Pagination} {
+		if err := paginator.SetLimits(pageLimits); err != nil {
+			log.Panic(err)
+		}
+	}
+	if keepAliveTimeoutMs < 0 || keepAliveTimeoutMs > 3600000 {
+		log.Panicf("invalid keep alive timeout %d, have to be between 0 and 3600000", keepAliveTimeoutMs)
+	}
+	if reconnectDelaySec < 0 || reconnectDelaySec > 3600 {
+		log.Panicf("invalid reconnect delay %d, have to be between 0 and 3600", reconnectDelaySec)
+	}
+	backendOpiMarvellServer.SetDefaultReconnectOptions(int32(keepAliveTimeoutMs), int32(reconnectDelaySec))
+	if poolThresholds != "" {
+		thresholds, err := parsePoolThresholds(poolThresholds)
+		if err != nil {
+			log.Panic(err)
+		}
+		backendOpiMarvellServer.SetPoolThresholds(thresholds)
+		go backendOpiMarvellServer.WatchPoolThresholds(context.Background(), poolThresholdsInterval)
+	}
+	go backendOpiMarvellServer.WatchVolumeScrubs(context.Background(), volumeScrubsInterval)
+	go backendOpiMarvellServer.WatchNvmePaths(context.Background(), nvmePathsInterval)
+	// the remote controllers need their last reconciliation with the discovery log pages to succeed
+	healthChecker := health.NewChecker(jsonRPC, "opi_api.storage.v1.NvmeRemoteControllerService")
+	healthChecker.SetStore(store)
+	backendOpiMarvellServer.SetReconcileReporter(healthChecker.ReportReconcile)
+	go healthChecker.Watch(context.Background(), healthProbeInterval)
+	go backendOpiMarvellServer.WatchDiscoveryServices(context.Background(), discoveryServicesInterval)
+	var notifier *alert.Notifier
+	if alertWebhookURLs != "" {
+		notifier = alert.NewNotifier(strings.Split(alertWebhookURLs, ","), &http.Client{Timeout: 10 * time.Second})
+		go notifier.Run(context.Background())
+		frontendOpiMarvellServer.SetControllerStateReporter(func(name string, active bool) {
+			if !active {
+				notifier.Notify(alert.KindControllerInactive, name, "Controller is inactive")
+			}
+		})
+		backendOpiMarvellServer.SetNvmePathFailureReporter(func(event *be.NvmePathEvent) {
+			message := fmt.Sprintf("Path failed, it was %s", event.PreviousState)
+			if event.Reason != "" {
+				message += ", " + event.Reason
+			}
+			notifier.Notify(alert.KindNvmePathFailed, event.Path, message)
+		})
+	}
+	if cloudEventsSinks != "" {
+		var sinks []cloudevents.Sink
+		for _, sinkURL := range strings.Split(cloudEventsSinks, ",") {
+			sink, err := cloudevents.NewSink(sinkURL, &http.Client{Timeout: 10 * time.Second})
+			if err != nil {
+				log.Panic(err)
+			}
+			sinks = append(sinks, sink)
+		}
+		publisher := cloudevents.NewPublisher(cloudEventsSource, sinks)
+		go publisher.Run(context.Background())
+		eventHistory.SetPublisher(publisher.Publish)
+	}
+	if ipmiSensors != "" {
+		if ipmiIntervalSec < 1 || ipmiIntervalSec > 3600 {
+			log.Panicf("invalid IPMI interval %d, have to be between 1 and 3600", ipmiIntervalSec)
+		}
+		sensors, err := ipmi.ParseSensors(ipmiSensors)
+		if err != nil {
+			log.Panic(err)
+		}
+		sender := ipmi.NewIpmitoolSender(strings.Fields(ipmitoolArgs))
+		reporter := ipmi.NewReporter(sender, sensors, frontendOpiMarvellServer, backendOpiMarvellServer, jsonRPC)
+		go reporter.Run(context.Background(), time.Duration(ipmiIntervalSec)*time.Second)
+	}
+	// the path transitions and the reconciliations are kept in the history of their resources
+	backendOpiMarvellServer.SetNvmePathEventReporter(func(event *be.NvmePathEvent) {
+		eventType := events.TypePathUp
+		if !event.Connected() {
+			eventType = events.TypePathDown
+		}
+		eventHistory.Record(event.Path, eventType, fmt.Sprintf("Path is %s, it was %s", event.State, event.PreviousState))
+	})
+	backendOpiMarvellServer.SetDriftReporter(func(name string, generation uint64) {
+		message := fmt.Sprintf("Remote controllers reconciled with discovery log page generation %d", generation)
+		eventHistory.Record(name, events.TypeReconciled, message)
+		if notifier != nil {
+			notifier.Notify(alert.KindReconcileDrift, name, message)
+		}
+	})
+	backendOpiMarvellServer.SetVolumeResizeHandler(frontendOpiMarvellServer.NotifyVolumeResize)
+	frontendOpiMarvellServer.SetVolumePlacer(backendOpiMarvellServer)
+	backendOpiMarvellServer.SetNamespaceMigrator(frontendOpiMarvellServer)
+	if rebalanceSkewPercent < 1 || rebalanceSkewPercent > 100 {
+		log.Panicf("invalid rebalance skew %d, have to be between 1 and 100", rebalanceSkewPercent)
+	}
+	backendOpiMarvellServer.SetRebalanceSkew(rebalanceSkewPercent)
+	if rebalanceIntervalSec < 0 || rebalanceIntervalSec > 86400 {
+		log.Panicf("invalid rebalance interval %d, have to be between 0 and 86400", rebalanceIntervalSec)
+	}
+	if rebalanceIntervalSec != 0 {
+		go backendOpiMarvellServer.WatchStoragePoolBalance(context.Background(), time.Duration(rebalanceIntervalSec)*time.Second)
+	}
+	if volumeRescanIntervalSec < 0 || volumeRescanIntervalSec > 86400 {
+		log.Panicf("invalid volume rescan interval %d, have to be between 0 and 86400", volumeRescanIntervalSec)
+	}
+	if volumeRescanIntervalSec != 0 {
+		go backendOpiMarvellServer.WatchVolumeResizes(context.Background(), time.Duration(volumeRescanIntervalSec)*time.Second)
+	}
+	gnoiServer := gnoi.NewServer(jsonRPC)
+	gnoiServer.SetStatsClearer(frontendOpiMarvellServer)
+	// the namespaces use the volumes
+	gnoiServer.SetFactoryReset(frontendOpiMarvellServer.DeleteNvmeResources, backendOpiMarvellServer.DeleteVolumes)
+	restoreSpdkApp := func(ctx context.Context) error {
+		// the restarted firmware lost what the cache reports, the namespaces need their volumes
+		firmwareCache.Invalidate()
+		return errors.Join(backendOpiMarvellServer.ReplayVolumes(ctx), frontendOpiMarvellServer.ReplayNvmeResources(ctx))
+	}
+	// the bridge owns the SPDK application, it is stopped with the bridge
+	if spdkApp != "" {
+		if spdkAppPingIntervalSec < 1 || spdkAppPingIntervalSec > 3600 {
+			log.Panicf("invalid SPDK application ping interval %d, have to be between 1 and 3600", spdkAppPingIntervalSec)
+		}
+		spdkSupervisor := supervisor.New(strings.Fields(spdkApp), spdkClient, restoreSpdkApp)
+		gnoiServer.SetRestarter(spdkSupervisor)
+		ctx, cancel := context.WithCancel(context.Background())
+		supervised := make(chan struct{})
+		go func() {
+			spdkSupervisor.Run(ctx, time.Duration(spdkAppPingIntervalSec)*time.Second)
+			close(supervised)
+		}()
+		defer func() {
+			cancel()
+			<-supervised
+		}()
+	}
+	if spdkRestartCheckIntervalSec < 0 || spdkRestartCheckIntervalSec > 3600 {
+		log.Panicf("invalid SPDK restart check interval %d, have to be between 0 and 3600", spdkRestartCheckIntervalSec)
+	}
+	// the restarts of the SPDK application launched by someone else are detected from the
+	// subsystems it lost, the emulated firmware never restarts
+	if spdkApp == "" && !emulate && spdkRestartCheckIntervalSec != 0 {
+		spdkSupervisor := supervisor.Attach(firmware, frontendOpiMarvellServer.NvmeSubsystemNqns, frontendOpiMarvellServer.DeactivateNvmeResources, restoreSpdkApp)
+		go spdkSupervisor.Run(context.Background(), time.Duration(spdkRestartCheckIntervalSec)*time.Second)
+	}
+	platformServer := platform.NewServer(jsonRPC)
+	platformServer.SetSkuSelector(skuSelector)
+	if attestationKey != "" || attestationCert != "" {
+		if err := platformServer.LoadAttestationKey(attestationKey, attestationCert); err != nil {
+			log.Panic(err)
+		}
+	}
+	defaultConsistency, err := consistency.Parse(readConsistency)
+	if err != nil {
+		log.Panicf("invalid -read_consistency: %v", err)
+	}
+	versions, err := apiversion.New(defaultAPIVersion)
+	if err != nil {
+		log.Panicf("invalid -default_api_version: %v", err)
+	}
+	gnmiServer := gnmi.NewServer(frontendOpiMarvellServer)
+	gnmiServer.SetFanoutWorkers(fanoutWorkers)
+	custom := &customServers{
+		frontend:   frontendOpiMarvellServer,
+		middleend:  middleendOpiMarvellServer,
+		backend:    backendOpiMarvellServer,
+		operations: operationsManager,
+		platform:   platformServer,
+		metrics:    bridgeMetrics,
+		logging:    logControl,
+		audit:      auditLog,
+		health:     healthChecker,
+		recorder:   flightRecorder,
+		events:     eventHistory,
+		config:     reloader,
+		redfish:    redfish.NewServer(frontendOpiMarvellServer),
+		gnmi:       gnmiServer,
+		gnoi:       gnoiServer,
+		versions:   versions,
+		export:     export.NewServer(frontendOpiMarvellServer, backendOpiMarvellServer, middleendOpiMarvellServer),
+		faults:     faultInjector,
+		cache:      firmwareCache,
+		policy:     policy,
+		verifier:   verifier,
+		keys:       keys,
+		limiter:    limiter,
+		router:     router,
+		engine:     engine,
+	}
+
+	if otlpMetricsEndpoint != "" {
+		if otlpMetricsIntervalSec < 1 || otlpMetricsIntervalSec > 86400 {
+			log.Panicf("invalid OTLP metrics interval %d, have to be between 1 and 86400", otlpMetricsIntervalSec)
+		}
+		provider, err := newTelemetryExporter(otlpMetricsEndpoint, otlpMetricsInsecure, time.Duration(otlpMetricsIntervalSec)*time.Second, custom)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer func() {
+			if err := provider.Shutdown(context.Background()); err != nil {
+				slog.Error("Could not flush the OTLP metrics", "error", err)
+			}
+		}()
+	}
+
+	if statsPushURL != "" {
+		if statsPushIntervalSec < 1 || statsPushIntervalSec > 86400 {
+			log.Panicf("invalid stats push interval %d, have to be between 1 and 86400", statsPushIntervalSec)
+		}
+		provider, err := newStatsPushExporter(statsPushURL, statsPushPrefix, time.Duration(statsPushIntervalSec)*time.Second, custom)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer func() {
+			if err := provider.Shutdown(context.Background()); err != nil {
+				slog.Error("Could not push the last stats", "error", err)
+			}
+		}()
+	}
+
+	if restoreBackupKey != "" && backupURL == "" {
+		log.Panic("invalid backup to restore, the -backup_url has to be set")
+	}
+	if backupURL != "" {
+		if backupIntervalSec < 60 || backupIntervalSec > 604800 {
+			log.Panicf("invalid backup interval %d, have to be between 60 and 604800", backupIntervalSec)
+		}
+		if backupKeep < 1 || backupKeep > 10000 {
+			log.Panicf("invalid number of kept backups %d, have to be between 1 and 10000", backupKeep)
+		}
+		agent, backupStore, err := newBackupAgent(backupURL, redisAddress, backupKeep, custom)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer func() { _ = backupStore.Close() }()
+		// the resources which can't be restored are reported, the bridge serves the others
+		if restoreBackupKey != "" {
+			if err := restoreBackup(context.Background(), agent, backupStore, restoreBackupKey, custom); err != nil {
+				slog.Error("Could not restore the whole backup", "key", restoreBackupKey, "error", err)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go agent.Run(ctx, time.Duration(backupIntervalSec)*time.Second)
+	}
+
+	// the clients of the gRPC server present a certificate signed by the CA, the certificates
+	// are reloaded when their files change so they can be rotated without a restart
+	var certificates *tlsreload.Reloader
+	if tlsFiles != "" && spiffeEndpointSocket != "" {
+		log.Panic("invalid SPIFFE endpoint socket, the SVID comes from the TLS files")
+	}
+	if tlsFiles == "" && spiffeEndpointSocket == "" && spiffeTrustDomain != "" {
+		log.Panic("invalid SPIFFE trust domain, the SPIFFE endpoint socket or the TLS files of the SVID have to be set")
+	}
+	switch {
+	case spiffeEndpointSocket != "":
+		// the agent streams the SVID again each time it rotates it
+		slog.Info("Use the X.509 SVID of the SPIFFE Workload API", "socket", spiffeEndpointSocket)
+		certificates, err = tlsreload.NewWorkloadAPI(context.Background(), spiffeEndpointSocket)
+		if err != nil {
+			log.Panic("Failed to get the X.509 SVID from the Workload API:", err)
+		}
+		certificates.SetTrustDomain(spiffeTrustDomain)
+	case tlsFiles == "":
+		slog.Warn("TLS files are not specified. Use insecure connection.")
+	default:
+		slog.Info("Use TLS certificate files", "files", tlsFiles)
+		config, err := utils.ParseTLSFiles(tlsFiles)
+		if err != nil {
+			log.Panic("Failed to parse string with tls paths:", err)
+		}
+		slog.Debug("TLS config", "config", config)
+		certificates, err = tlsreload.New(config.ServerCertPath, config.ServerKeyPath, config.CaCertPath)
+		if err != nil {
+			log.Panic("Failed to setup TLS:", err)
+		}
+		certificates.SetTrustDomain(spiffeTrustDomain)
+		if tlsReloadIntervalSec < 0 || tlsReloadIntervalSec > 86400 {
+			log.Panicf("invalid TLS reload interval %d, have to be between 0 and 86400", tlsReloadIntervalSec)
+		}
+		if tlsReloadIntervalSec != 0 {
+			go certificates.Watch(context.Background(), time.Duration(tlsReloadIntervalSec)*time.Second)
+		}
+	}
+
+	handleReloadableSettings(reloader, reloadableComponents{
+		logControl:   logControl,
+		certificates: certificates,
+		tlsFiles:     tlsFiles,
+		policy:       policy,
+		authzPolicy:  authzPolicy,
+		keys:         keys,
+		apiKeysFile:  apiKeysFile,
+		limiter:      limiter,
+		filter:       filter,
+		metrics:      bridgeMetrics,
+	})
+	go reloadConfigOnSignal(reloader)
+
+	if adminPort != 0 {
+		go runAdminServer(adminPort)
+	}
+	if shutdownTimeoutSec < 1 || shutdownTimeoutSec > 3600 {
+		log.Panicf("invalid shutdown timeout %d, have to be between 1 and 3600", shutdownTimeoutSec)
+	}
 
-func runGrpcServer(grpcPort int, spdkAddress string, tlsFiles string, store gokv.Store) {
 	tp := utils.InitTracerProvider("opi-marvell-bridge")
 	defer func() {
 		if err := tp.Shutdown(context.Background()); err != nil {
@@ -86,75 +933,214 @@ func runGrpcServer(grpcPort int, spdkAddress string, tlsFiles string, store gokv
 		}
 	}()
 
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	// both listeners are bound, or inherited from the bridge being upgraded, before systemd is
+	// told the bridge is ready
+	lis, err := handoff.Listen("grpc", fmt.Sprintf(":%d", grpcPort))
 	if err != nil {
 		log.Panicf("failed to listen: %v", err)
 	}
+	httpLis, err := handoff.Listen("http", fmt.Sprintf(":%d", httpPort))
+	if err != nil {
+		log.Panic("cannot start HTTP gateway server")
+	}
+	restoreUpgradeState(eventHistory, operationsManager)
+	// the steps of the declarative configs are calls of their caller to the gRPC server
+	loopback, err := grpc.Dial(fmt.Sprintf("localhost:%d", grpcPort),
+		append(loopbackDialOptions(certificates), grpc.WithChainUnaryInterceptor(apply.UnaryClientInterceptor()))...)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer func() { _ = loopback.Close() }()
+	custom.apply = newApplyServer(custom, loopback)
+	if applySigningKey != "" {
+		signatures, err := apply.LoadVerifier(applySigningKey)
+		if err != nil {
+			log.Panic(err)
+		}
+		custom.apply.SetVerifier(signatures)
+	}
+	grpcServer := newGrpcServer(jsonRPC, frontendOpiMarvellServer, middleendOpiMarvellServer, backendOpiMarvellServer, gnmiServer, certificates, store, policy, engine, verifier, keys, limiter, bridgeMetrics, bridgeLogger, auditLog, healthChecker, flightRecorder, eventHistory, router, versions, defaultConsistency, grpcReflection, grpcChannelz)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	httpServer := newGatewayServer(ctx, grpcPort, httpProtoNames, custom, certificates)
+	go serveGateway(httpServer, filter.Listen(httpLis))
+	if k8sOperator {
+		if k8sResyncSec < 1 || k8sResyncSec > 3600 {
+			log.Panicf("invalid Kubernetes resync interval %d, have to be between 1 and 3600", k8sResyncSec)
+		}
+		kube, err := operator.InClusterKube(k8sNamespace)
+		if err != nil {
+			log.Panic(err)
+		}
+		go operator.New(kube, frontendOpiMarvellServer).Run(ctx, time.Duration(k8sResyncSec)*time.Second)
+	}
+
+	// the deferred closes of the store and flushes of the telemetry run once the servers are drained
+	drained := make(chan struct{})
+	upgraded := make(chan struct{})
+	go func() {
+		drainOnSignal(grpcServer, httpServer, healthChecker, time.Duration(shutdownTimeoutSec)*time.Second, upgraded)
+		close(drained)
+	}()
+	go upgradeOnSignal(map[string]net.Listener{"grpc": lis, "http": httpLis}, eventHistory, operationsManager, spdkApp != "", upgraded)
 
-	jsonRPC := spdk.NewClient(spdkAddress)
-	frontendOpiMarvellServer := fe.NewServer(jsonRPC, store)
+	slog.Info("gRPC server listening", "address", lis.Addr())
+	notifySystemd(systemd.Ready)
+	// the bridge being upgraded drains once told
+	if err := handoff.Ready(); err != nil {
+		slog.Warn("Could not tell the previous bridge this one serves", "error", err)
+	}
+	if err := grpcServer.Serve(filter.Listen(lis)); err != nil {
+		log.Panicf("failed to serve: %v", err)
+	}
+	<-drained
+	slog.Info("Bridge stopped")
+}
+
+// newPlacementRouter returns the router of the calls to the default instance of the firmware and
+// to the other instances, their calls are captured with the ones of the default instance and the
+// SDK release of each instance is detected on its own
+func newPlacementRouter(defaultClient spdk.JSONRPC, instances string, rules string, capture *jsonrpc.Capture, shims []compat.Shim) (*placement.Router, error) {
+	addresses, err := placement.ParseInstances(instances)
+	if err != nil {
+		return nil, err
+	}
+	var placementRules []placement.Rule
+	if rules != "" {
+		if placementRules, err = placement.ParseRules(rules); err != nil {
+			return nil, err
+		}
+	}
+	clients := map[string]spdk.JSONRPC{placement.DefaultInstance: compat.WrapJSONRPC(defaultClient, shims)}
+	for name, address := range addresses {
+		client := jsonrpc.NewClient(address)
+		client.SetCapture(capture)
+		clients[name] = compat.WrapJSONRPC(client, shims)
+	}
+	return placement.NewRouter(clients, placementRules)
+}
+
+// parsePoolThresholds parses comma separated percents, i.e. 80,90,95
+func parsePoolThresholds(value string) ([]int, error) {
+	var thresholds []int
+	for _, field := range strings.Split(value, ",") {
+		threshold, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || threshold < 1 || threshold > 100 {
+			return nil, fmt.Errorf("invalid pool threshold %q, have to be between 1 and 100", field)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
+
+// newGrpcServer returns the gRPC server of all the services, with their interceptors
+func newGrpcServer(jsonRPC spdk.JSONRPC, frontendOpiMarvellServer *fe.Server, middleendOpiMarvellServer *me.Server, backendOpiMarvellServer *be.Server, gnmiServer *gnmi.Server, certificates *tlsreload.Reloader, store gokv.Store, policy *authz.Policy, engine authz.Engine, verifier *oidc.Verifier, keys *apikey.Keys, limiter *ratelimit.Limiter, bridgeMetrics *metrics.Metrics, bridgeLogger *slog.Logger, auditLog *audit.Log, healthChecker *health.Checker, flightRecorder *recorder.Recorder, eventHistory *events.History, router *placement.Router, versions *apiversion.Layer, defaultConsistency consistency.Level, grpcReflection bool, grpcChannelz bool) *grpc.Server {
 	frontendOpiSpdkServer := frontend.NewServer(jsonRPC, store)
-	backendOpiSpdkServer := backend.NewServer(jsonRPC, store)
 	middleendOpiSpdkServer := middleend.NewServer(jsonRPC, store)
 
 	var serverOptions []grpc.ServerOption
-	if tlsFiles == "" {
-		log.Println("TLS files are not specified. Use insecure connection.")
-	} else {
-		log.Println("Use TLS certificate files:", tlsFiles)
-		config, err := utils.ParseTLSFiles(tlsFiles)
-		if err != nil {
-			log.Panic("Failed to parse string with tls paths:", err)
-		}
-		log.Println("TLS config:", config)
-		var option grpc.ServerOption
-		if option, err = utils.SetupTLSCredentials(config); err != nil {
-			log.Panic("Failed to setup TLS:", err)
-		}
-		serverOptions = append(serverOptions, option)
+	if certificates != nil {
+		serverOptions = append(serverOptions, grpc.Creds(certificates.ServerCredentials()))
+	}
+	// the requests of the other versions of the API are translated before anything else sees them
+	interceptors := []grpc.UnaryServerInterceptor{
+		requestid.UnaryServerInterceptor(),
+		versions.UnaryServerInterceptor(),
+		consistency.UnaryServerInterceptor(defaultConsistency),
+		flightRecorder.UnaryServerInterceptor(),
+		bridgeMetrics.UnaryServerInterceptor(),
+		logger.UnaryServerInterceptor(),
+		logger.LoggingUnaryServerInterceptor(bridgeLogger),
+	}
+	serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(versions.StreamServerInterceptor()))
+	// the audit and the authorization need the identity of the bearer token or of the API key
+	if verifier != nil {
+		interceptors = append(interceptors, verifier.UnaryServerInterceptor())
+		serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(verifier.StreamServerInterceptor()))
+	}
+	if keys != nil {
+		interceptors = append(interceptors, keys.UnaryServerInterceptor())
+		serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(keys.StreamServerInterceptor()))
+	}
+	interceptors = append(interceptors, audit.UnaryServerInterceptor(auditLog))
+	if policy != nil {
+		interceptors = append(interceptors, authz.UnaryServerInterceptor(policy))
+		serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(authz.StreamServerInterceptor(policy)))
+	}
+	if engine != nil {
+		interceptors = append(interceptors, authz.EngineUnaryServerInterceptor(engine))
+		serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(authz.EngineStreamServerInterceptor(engine)))
 	}
+	// the calls are limited once authorized, per identity
+	if limiter != nil {
+		interceptors = append(interceptors, limiter.UnaryServerInterceptor())
+		serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(limiter.StreamServerInterceptor()))
+	}
+	interceptors = append(interceptors, tenant.UnaryServerInterceptor())
+	serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(tenant.StreamServerInterceptor()))
+	// the resources are placed after their IDs are scoped to the tenant
+	if router != nil {
+		interceptors = append(interceptors, router.UnaryServerInterceptor())
+	}
+	// the etags are checked on the names the servers know the resources by
+	interceptors = append(interceptors, etag.UnaryServerInterceptor())
+	interceptors = append(interceptors, eventHistory.UnaryServerInterceptor())
+	// the steps of the declarative configs are told the firmware calls they made
+	interceptors = append(interceptors, apply.UnaryServerInterceptor())
+	// the requests wait for the replay of the configuration to a restarted SPDK application
+	interceptors = append(interceptors,
+		backendOpiMarvellServer.UnaryServerInterceptor(),
+		frontendOpiMarvellServer.UnaryServerInterceptor(),
+	)
 	serverOptions = append(serverOptions,
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-		grpc.UnaryInterceptor(
-			logging.UnaryServerInterceptor(utils.InterceptorLogger(log.Default()),
-				logging.WithLogOnEvents(
-					logging.StartCall,
-					logging.FinishCall,
-					logging.PayloadReceived,
-					logging.PayloadSent,
-				),
-			)),
+		grpc.ChainUnaryInterceptor(interceptors...),
 	)
 	s := grpc.NewServer(serverOptions...)
 
 	pb.RegisterFrontendNvmeServiceServer(s, frontendOpiMarvellServer)
 	pb.RegisterFrontendVirtioBlkServiceServer(s, frontendOpiSpdkServer)
 	pb.RegisterFrontendVirtioScsiServiceServer(s, frontendOpiSpdkServer)
-	pb.RegisterNvmeRemoteControllerServiceServer(s, backendOpiSpdkServer)
-	pb.RegisterNullVolumeServiceServer(s, backendOpiSpdkServer)
-	pb.RegisterMallocVolumeServiceServer(s, backendOpiSpdkServer)
-	pb.RegisterAioVolumeServiceServer(s, backendOpiSpdkServer)
-	pb.RegisterMiddleendEncryptionServiceServer(s, middleendOpiSpdkServer)
+	pb.RegisterNvmeRemoteControllerServiceServer(s, backendOpiMarvellServer)
+	pb.RegisterNullVolumeServiceServer(s, backendOpiMarvellServer)
+	pb.RegisterMallocVolumeServiceServer(s, backendOpiMarvellServer)
+	pb.RegisterAioVolumeServiceServer(s, backendOpiMarvellServer)
+	pb.RegisterMiddleendEncryptionServiceServer(s, middleendOpiMarvellServer)
+	pb.RegisterMiddleendQosVolumeServiceServer(s, middleendOpiSpdkServer)
 	pc.RegisterInventoryServiceServer(s, &inventory.Server{})
 	ps.RegisterIPsecServiceServer(s, &ipsec.Server{})
+	gpb.RegisterGNMIServer(s, gnmi.NewService(gnmiServer))
+	healthChecker.Register(s)
 
-	reflection.Register(s)
-
-	log.Printf("gRPC server listening at %v", lis.Addr())
-	if err := s.Serve(lis); err != nil {
-		log.Panicf("failed to serve: %v", err)
+	if grpcReflection {
+		reflection.Register(s)
 	}
+	if grpcChannelz {
+		channelzservice.RegisterChannelzServiceToServer(s)
+	}
+	return s
 }
 
-func runGatewayServer(grpcPort int, httpPort int) {
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
+// newGatewayServer returns the HTTP server proxying the calls to the gRPC server, the handlers
+// are registered until ctx is done
+func newGatewayServer(ctx context.Context, grpcPort int, httpProtoNames bool, custom *customServers, certificates *tlsreload.Reloader) *http.Server {
 	// Register gRPC server endpoint
 	// Note: Make sure the gRPC server is running properly and accessible
-	mux := runtime.NewServeMux()
-	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	muxOptions := []runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+	}
+	if httpProtoNames {
+		// same as the default marshaler of the gateway, but the field names
+		muxOptions = append(muxOptions, runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.HTTPBodyMarshaler{
+			Marshaler: &runtime.JSONPb{
+				MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+				UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+			},
+		}))
+	}
+	mux := runtime.NewServeMux(muxOptions...)
+	opts := loopbackDialOptions(certificates)
 	endpoint := fmt.Sprintf("localhost:%d", grpcPort)
 	registerGatewayHandler(ctx, mux, endpoint, opts, pc.RegisterInventoryServiceHandlerFromEndpoint, "inventory")
 
@@ -170,20 +1156,74 @@ func runGatewayServer(grpcPort int, httpPort int) {
 	registerGatewayHandler(ctx, mux, endpoint, opts, pb.RegisterFrontendVirtioScsiServiceHandlerFromEndpoint, "frontend virtio-scsi")
 	registerGatewayHandler(ctx, mux, endpoint, opts, pb.RegisterFrontendNvmeServiceHandlerFromEndpoint, "frontend nvme")
 
-	// Start HTTP server (and proxy calls to gRPC server endpoint)
-	log.Printf("HTTP Server listening at %v", httpPort)
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", httpPort),
+	// Register Marvell specific methods which are not part of the OPI APIs
+	registerCustomMethods(mux, custom)
+	registerMetrics(mux, custom)
+	registerHealth(mux, custom.health)
+
+	return &http.Server{
 		Handler:      mux,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
-	err := server.ListenAndServe()
-	if err != nil {
+}
+
+// loopbackDialOptions returns the options of the connections of the bridge to its gRPC server,
+// i.e. of the HTTP gateway, a client of the gRPC server as any other, with the certificate of the
+// bridge
+func loopbackDialOptions(certificates *tlsreload.Reloader) []grpc.DialOption {
+	if certificates == nil {
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(certificates.LoopbackConfig()))}
+}
+
+// serveGateway serves the HTTP server (and proxy calls to gRPC server endpoint) until it is shut down
+func serveGateway(server *http.Server, lis net.Listener) {
+	slog.Info("HTTP Server listening", "address", lis.Addr())
+	if err := server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Panic("cannot start HTTP gateway server")
 	}
 }
 
+// headerMatcher forwards the tenant, the API key, the ID, the If-Match, the read consistency and the API version of the HTTP requests to the gRPC servers
+func headerMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, apiversion.HeaderKey) {
+		return apiversion.MetadataKey, true
+	}
+	if strings.EqualFold(key, etag.IfMatchHeaderKey) {
+		return etag.IfMatchMetadataKey, true
+	}
+	if strings.EqualFold(key, consistency.HeaderKey) {
+		return consistency.MetadataKey, true
+	}
+	if strings.EqualFold(key, requestid.HeaderKey) {
+		return requestid.MetadataKey, true
+	}
+	if strings.EqualFold(key, tenant.HeaderKey) {
+		return tenant.MetadataKey, true
+	}
+	if strings.EqualFold(key, apikey.HeaderKey) {
+		return apikey.MetadataKey, true
+	}
+	// the gRPC server trusts the identity metadata of the gateway, the clients can't set it
+	if strings.EqualFold(key, runtime.MetadataHeaderPrefix+authz.MetadataKey) {
+		return "", false
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaderMatcher returns the etag of the resources and the version of the API served in the Etag and Opi-Api-Version headers of the HTTP responses
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if key == etag.MetadataKey {
+		return etag.HeaderKey, true
+	}
+	if key == apiversion.MetadataKey {
+		return apiversion.HeaderKey, true
+	}
+	return fmt.Sprintf("%s%s", runtime.MetadataHeaderPrefix, key), true
+}
+
 type registerHandlerFunc func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error
 
 func registerGatewayHandler(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption, registerFunc registerHandlerFunc, serviceName string) {
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"net/http"
+
+	fe "github.com/opiproject/opi-marvell-bridge/pkg/frontend"
+	"github.com/opiproject/opi-marvell-bridge/pkg/metrics"
+	"github.com/opiproject/opi-marvell-bridge/pkg/platform"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registerMetrics exposes the bridge metrics in the Prometheus format on /metrics, the DPU
+// telemetry, the gRPC and Marvell RPC metrics, the resource counts, the controller stats and the
+// queries answered by the firmware cache
+func registerMetrics(mux *runtime.ServeMux, custom *customServers) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		platform.NewTelemetryCollector(custom.platform),
+		custom.metrics,
+		metrics.NewResourceCollector(custom.frontend, custom.backend),
+		fe.NewControllerStatsCollector(custom.frontend),
+		custom.cache,
+	)
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	registerCustomMethod(mux, http.MethodGet, "/metrics", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		handler.ServeHTTP(w, r)
+	})
+}
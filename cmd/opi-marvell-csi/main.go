@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of opi-marvell-csi, the CSI plugin of the NVMe emulated by the DPU
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/csi"
+
+	csipb "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	var endpoint string
+	flag.StringVar(&endpoint, "endpoint", "unix:///csi/csi.sock", "Unix socket the CSI services are served on")
+
+	var bridgeURL string
+	flag.StringVar(&bridgeURL, "bridge_url", "", "HTTP server of the bridge, i.e. http://10.10.10.10:8082, the Controller service is served when set")
+
+	var apiKeyFile string
+	flag.StringVar(&apiKeyFile, "api_key_file", "", "File of the API key sent to the bridge, none when empty")
+
+	var lvolStore string
+	flag.StringVar(&lvolStore, "lvol_store", "", "Lvol store the volumes are carved from unless their storage class sets another, i.e. //storage.opiproject.org/lvolStores/lvs0")
+
+	var nodeID string
+	flag.StringVar(&nodeID, "node_id", "", "ID of the Nvme subsystem emulated to the host, the Node service is served when set")
+
+	var sysfs string
+	flag.StringVar(&sysfs, "sysfs", "/sys", "Where the sysfs of the host is mounted")
+
+	flag.Parse()
+
+	if bridgeURL == "" && nodeID == "" {
+		log.Fatalf("error: -bridge_url or -node_id have to be set")
+	}
+
+	server := grpc.NewServer()
+	var driver *csi.Driver
+	if bridgeURL != "" {
+		apiKey := ""
+		if apiKeyFile != "" {
+			data, err := os.ReadFile(apiKeyFile)
+			if err != nil {
+				log.Fatalf("error: failed to read the API key: %v", err)
+			}
+			apiKey = strings.TrimSpace(string(data))
+		}
+		driver = csi.NewDriver(csi.NewClient(bridgeURL, &http.Client{Timeout: time.Minute}, apiKey), lvolStore)
+		csipb.RegisterControllerServer(server, csi.NewControllerServer(driver))
+	}
+	if nodeID != "" {
+		csipb.RegisterNodeServer(server, csi.NewNodeServer(csi.NewNode(sysfs), csi.NewMounter(), nodeID))
+	}
+	csipb.RegisterIdentityServer(server, csi.NewIdentityServer(driver))
+
+	socket := strings.TrimPrefix(endpoint, "unix://")
+	if err := os.Remove(socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("error: failed to remove the stale socket: %v", err)
+	}
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-signals
+		server.GracefulStop()
+	}()
+
+	log.Printf("CSI plugin %s serving at %v", csi.DriverName, lis.Addr())
+	if err := server.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of opi-marvell-ctl, the administrative command line of the bridge
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/ctl"
+
+	// the descriptors of the services are registered on import
+	_ "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func main() {
+	if err := ctl.NewCommand(protoregistry.GlobalFiles).Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
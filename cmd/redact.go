@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"io"
+	"regexp"
+)
+
+// keyMaterialPattern matches the key material of encrypted volumes, the Opal passwords of
+// the drives, the TLS PSKs, the DH-HMAC-CHAP secrets of the remote controllers, the cephx keys and
+// the iSCSI CHAP secrets, either in the requests sent to SPDK (json) or in the logged gRPC payloads
+// (proto text format)
+var keyMaterialPattern = regexp.MustCompile(`("(?:key2?|ctrlrKey|chapSecret|password|psk)":\s*"|\b(?:key2?|ctrlrKey|chapSecret|password|psk):\s*")(?:[^"\\]|\\.)*"`)
+
+// escapedKeyMaterialPattern matches the same key material once quoted again by the structured
+// logger, i.e. a payload logged as a value of the text or json format
+var escapedKeyMaterialPattern = regexp.MustCompile(`(\\"(?:key2?|ctrlrKey|chapSecret|password|psk)\\":\s*\\"|\b(?:key2?|ctrlrKey|chapSecret|password|psk):\s*\\")(?:[^"\\]|\\[^"])*\\"`)
+
+// redactingWriter keeps the key material out of the logs and the captures of the firmware calls
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	redacted := escapedKeyMaterialPattern.ReplaceAll(p, []byte(`${1}<redacted>\"`))
+	if _, err := r.w.Write(keyMaterialPattern.ReplaceAll(redacted, []byte(`${1}<redacted>"`))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
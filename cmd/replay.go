@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/jsonrpc"
+)
+
+// replayRPCCapture re-issues the calls of a capture to the firmware and logs the divergent
+// responses, the bridge exits with 1 when some diverged
+func replayRPCCapture(path string, spdkAddress string) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer func() { _ = file.Close() }()
+	replayed, divergences, err := jsonrpc.NewClient(spdkAddress).Replay(context.Background(), file)
+	for _, d := range divergences {
+		slog.Warn("Replayed call diverged", "index", d.Index, "rpc", d.Method, "captured", d.Captured, "replayed", d.Replayed)
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+	slog.Info("Replay done", "calls", replayed, "divergences", len(divergences))
+	if len(divergences) != 0 {
+		_ = file.Close()
+		os.Exit(1)
+	}
+}
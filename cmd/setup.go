@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/jsonrpc"
+	"github.com/opiproject/opi-marvell-bridge/pkg/platform"
+)
+
+// setupDpu reserves the hugepages of a profile and writes the core mask the SPDK application
+// has to be started with to out, as core_mask=0x1e, the bridge exits once the DPU is set up
+func setupDpu(out io.Writer, profile string, spdkAddress string) {
+	setup, err := platform.NewServer(jsonrpc.NewClient(spdkAddress)).SetupDpu(context.Background(), &platform.SetupDpuRequest{Profile: profile})
+	if err != nil {
+		log.Panic(err)
+	}
+	if _, err := fmt.Fprintf(out, "core_mask=%s\n", setup.CoreMask); err != nil {
+		log.Panic(err)
+	}
+}
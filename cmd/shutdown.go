@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/health"
+	"github.com/opiproject/opi-marvell-bridge/pkg/systemd"
+
+	"google.golang.org/grpc"
+)
+
+// drainOnSignal stops the servers on SIGTERM or SIGINT, or once upgraded is closed, when their
+// requests in flight complete, or timeout elapsed, the servers are then closed. A second signal
+// kills the bridge
+func drainOnSignal(grpcServer *grpc.Server, httpServer *http.Server, healthChecker *health.Checker, timeout time.Duration, upgraded <-chan struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case <-signals:
+		notifySystemd(systemd.Stopping)
+	case <-upgraded:
+		// the new bridge is the service now, it isn't stopping
+	}
+	signal.Stop(signals)
+	slog.Info("Draining the requests in flight before stopping", "timeout", timeout)
+	healthChecker.Drain()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// the gateway calls the gRPC server, it is drained first
+	if err := httpServer.Shutdown(ctx); err != nil {
+		slog.Warn("HTTP requests still in flight, closing the server", "error", err)
+		_ = httpServer.Close()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("gRPC requests still in flight, stopping the server", "error", ctx.Err())
+		grpcServer.Stop()
+	}
+}
+
+// notifySystemd tells systemd the state of the bridge, the bridge runs on when it can't
+func notifySystemd(state string) {
+	if err := systemd.Notify(state); err != nil {
+		slog.Warn("Could not notify systemd", "state", state, "error", err)
+	}
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/status"
+)
+
+// streamFunc runs a stream, giving send its responses as they come
+type streamFunc func(ctx context.Context, send func(response interface{}) error) error
+
+// serveStream writes the responses of a stream as they come, over a WebSocket when the client
+// upgrades the connection, as server-sent events when it accepts text/event-stream and as JSON
+// lines otherwise, so the browsers can subscribe without speaking gRPC. Each response is a
+// {"result": ...} message, a failure after the first response a last {"error": ...} one
+func serveStream(ctx context.Context, w http.ResponseWriter, r *http.Request, stream streamFunc) error {
+	switch {
+	case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"):
+		return serveWebSocketStream(ctx, w, r, stream)
+	case strings.Contains(r.Header.Get("Accept"), "text/event-stream"):
+		// the proxies mustn't buffer the events
+		w.Header().Set("Cache-Control", "no-cache")
+		return serveHTTPStream(ctx, w, stream, "text/event-stream", writeServerSentEvent)
+	default:
+		return serveHTTPStream(ctx, w, stream, "application/x-ndjson", writeJSONLine)
+	}
+}
+
+// serveHTTPStream writes and flushes each response of a stream in the body of the response
+func serveHTTPStream(ctx context.Context, w http.ResponseWriter, stream streamFunc, contentType string, write func(w io.Writer, kind string, payload interface{}) error) error {
+	controller := http.NewResponseController(w)
+	started := false
+	err := stream(ctx, func(response interface{}) error {
+		if !started {
+			w.Header().Set("Content-Type", contentType)
+			started = true
+		}
+		// the stream outlives the write timeout of the server
+		_ = controller.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err := write(w, "result", response); err != nil {
+			return err
+		}
+		return controller.Flush()
+	})
+	switch {
+	case err != nil && !started:
+		writeCustomMethodError(w, err)
+	case err != nil:
+		if err := write(w, "error", status.Convert(err).Proto()); err != nil {
+			slog.Warn("cannot encode error response", "error", err)
+		}
+	case !started:
+		// nothing was sent, the stream is empty
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+	}
+	return err
+}
+
+// serveWebSocketStream sends each response of a stream as a text message of a WebSocket, the
+// stream ends when the client closes the connection
+func serveWebSocketStream(ctx context.Context, w http.ResponseWriter, r *http.Request, stream streamFunc) error {
+	var err error
+	// the dashboards are served from other origins, the callers are authenticated as for the
+	// other methods
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		// the request context isn't cancelled once the connection is hijacked
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			_, _ = io.Copy(io.Discard, conn)
+			cancel()
+		}()
+		err = stream(ctx, func(response interface{}) error {
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			return websocket.JSON.Send(conn, map[string]interface{}{"result": response})
+		})
+		if err != nil && ctx.Err() == nil {
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := websocket.JSON.Send(conn, map[string]interface{}{"error": status.Convert(err).Proto()}); err != nil {
+				slog.Warn("cannot encode error response", "error", err)
+			}
+		}
+	}}
+	server.ServeHTTP(w, r)
+	return err
+}
+
+// writeJSONLine writes {"<kind>": payload} as a line
+func writeJSONLine(w io.Writer, kind string, payload interface{}) error {
+	return json.NewEncoder(w).Encode(map[string]interface{}{kind: payload})
+}
+
+// writeServerSentEvent writes a server-sent event of type kind whose data is {"<kind>": payload}
+func writeServerSentEvent(w io.Writer, kind string, payload interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{kind: payload})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", kind, data)
+	return err
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/statspush"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// telemetryMeterName names the meter of the telemetry pushed to the collector
+const telemetryMeterName = "github.com/opiproject/opi-marvell-bridge"
+
+// newTelemetryExporter pushes the DPU telemetry and the stats of the Nvme controllers and
+// namespaces as OTLP metrics to the collector at endpoint, in host:port format, every interval.
+// The OTEL_EXPORTER_OTLP_* environment variables, i.e. the headers, are honored too
+func newTelemetryExporter(endpoint string, insecure bool, interval time.Duration, custom *customServers) (*sdkmetric.MeterProvider, error) {
+	options := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlpmetrichttp.WithInsecure())
+	}
+	exporter, err := otlpmetrichttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+	bridgeResource, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", "opi-marvell-bridge")))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(bridgeResource),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))),
+	)
+	meter := provider.Meter(telemetryMeterName)
+	if _, err := custom.platform.RegisterTelemetryInstruments(meter); err != nil {
+		return nil, err
+	}
+	if _, err := custom.frontend.RegisterStatsInstruments(meter); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// newStatsPushExporter pushes the stats of the Nvme controllers and namespaces, labeled with
+// their volumes, to the statsd, Graphite or Prometheus remote-write endpoint at url every
+// interval, for the sites which can't scrape the DPUs
+func newStatsPushExporter(url string, prefix string, interval time.Duration, custom *customServers) (*sdkmetric.MeterProvider, error) {
+	exporter, err := statspush.New(url, prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))),
+	)
+	meter := provider.Meter(telemetryMeterName)
+	if _, err := custom.frontend.RegisterStatsInstruments(meter); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
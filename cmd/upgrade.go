@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the application
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/events"
+	"github.com/opiproject/opi-marvell-bridge/pkg/handoff"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+)
+
+// upgradeReadyTimeout is how long the new bridge has to serve before the upgrade is abandoned
+const upgradeReadyTimeout = time.Minute
+
+// upgradeState is the in-memory state handed over to the new bridge, the resources are in the store
+type upgradeState struct {
+	Events     []*events.Event         `json:"events"`
+	Operations []*operations.Operation `json:"operations"`
+}
+
+// upgradeOnSignal starts the binary of the bridge again on SIGUSR2, with the listeners and the
+// in-memory state, and closes upgraded once it serves so this bridge drains. The bridge
+// supervising the SPDK application isn't upgraded, the application would stop with it
+func upgradeOnSignal(listeners map[string]net.Listener, eventHistory *events.History, operationsManager *operations.Manager, supervising bool, upgraded chan<- struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for range signals {
+		if supervising {
+			slog.Error("Could not upgrade the bridge, the SPDK application it supervises would stop with it, restart it instead")
+			continue
+		}
+		ops, err := operationsManager.Export()
+		if err != nil {
+			slog.Error("Could not upgrade the bridge, retry once the operation is done", "error", err)
+			continue
+		}
+		state, err := json.Marshal(upgradeState{Events: eventHistory.Export(), Operations: ops})
+		if err != nil {
+			slog.Error("Could not upgrade the bridge", "error", err)
+			continue
+		}
+		slog.Info("Upgrading the bridge")
+		process, err := handoff.Upgrade(listeners, state, upgradeReadyTimeout)
+		if err != nil {
+			slog.Error("Could not upgrade the bridge, keeping it running", "error", err)
+			continue
+		}
+		slog.Info("New bridge serving, draining the requests in flight of this one", "pid", process.Pid)
+		notifySystemd(fmt.Sprintf("MAINPID=%d", process.Pid))
+		close(upgraded)
+		return
+	}
+}
+
+// restoreUpgradeState restores the in-memory state of the previous bridge when started by an upgrade
+func restoreUpgradeState(eventHistory *events.History, operationsManager *operations.Manager) {
+	data, err := handoff.State()
+	if data == nil && err == nil {
+		return
+	}
+	var state upgradeState
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		slog.Warn("Could not read the state of the previous bridge, starting without", "error", err)
+		return
+	}
+	eventHistory.Import(state.Events)
+	operationsManager.Import(state.Operations)
+	slog.Info("Restored the state of the previous bridge", "events", len(state.Events), "operations", len(state.Operations))
+}
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package alert posts the failures of the resources to webhooks as they are detected, so the
+// alerting doesn't depend on the scrape interval of the metrics
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxPendingAlerts bounds the alerts waiting to be posted, the newer ones are dropped
+const maxPendingAlerts = 256
+
+// maxAttempts is the number of times an alert is posted to a webhook before it is dropped
+const maxAttempts = 3
+
+// retryDelay is the delay before posting an alert again, doubled on each attempt
+var retryDelay = time.Second
+
+// Kinds of the alerts
+const (
+	// KindControllerInactive is raised when an Nvme controller stops fetching host commands
+	KindControllerInactive = "CONTROLLER_INACTIVE"
+	// KindNvmePathFailed is raised when the firmware gives up reconnecting an Nvme path
+	KindNvmePathFailed = "NVME_PATH_FAILED"
+	// KindReconcileDrift is raised when the remote controllers no longer match the discovery
+	// log page of their discovery service and are reconciled
+	KindReconcileDrift = "RECONCILE_DRIFT"
+)
+
+// Alert represents a failure of a resource, posted to the webhooks as a JSON object
+type Alert struct {
+	// Kind of the failure, i.e. NVME_PATH_FAILED
+	Kind string `json:"kind"`
+	// Resource is the name of the failed resource
+	Resource string `json:"resource"`
+	// Message describes the failure
+	Message string `json:"message"`
+	// Time the failure was detected, UTC
+	Time time.Time `json:"time"`
+}
+
+// Notifier posts the alerts to webhooks in the background, the detection of the failures is
+// not held up by slow or unreachable webhooks
+type Notifier struct {
+	urls    []string
+	client  *http.Client
+	pending chan *Alert
+}
+
+// NewNotifier creates initialized instance of alert notifier posting to the urls
+func NewNotifier(urls []string, client *http.Client) *Notifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Notifier{
+		urls:    urls,
+		client:  client,
+		pending: make(chan *Alert, maxPendingAlerts),
+	}
+}
+
+// Notify queues an alert of kind on resource, it is dropped when too many alerts are pending
+func (n *Notifier) Notify(kind string, resource string, message string) {
+	alert := &Alert{
+		Kind:     kind,
+		Resource: resource,
+		Message:  message,
+		Time:     time.Now().UTC(),
+	}
+	select {
+	case n.pending <- alert:
+	default:
+		slog.Warn("Dropping alert, too many alerts pending", "kind", kind, "resource", resource)
+	}
+}
+
+// Run posts the queued alerts to the webhooks until ctx is done
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-n.pending:
+			for _, url := range n.urls {
+				n.post(ctx, url, alert)
+			}
+		}
+	}
+}
+
+// post posts an alert to a webhook, retrying the failed attempts
+func (n *Notifier) post(ctx context.Context, url string, alert *Alert) {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err := n.send(ctx, url, alert)
+		if err == nil {
+			return
+		}
+		if attempt == maxAttempts {
+			slog.Error("Could not post alert", "url", url, "kind", alert.Kind, "resource", alert.Resource, "error", err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// send posts an alert to a webhook once
+func (n *Notifier) send(ctx context.Context, url string, alert *Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package alert posts the failures of the resources to webhooks as they are detected, so the
+// alerting doesn't depend on the scrape interval of the metrics
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAlert_Notifier(t *testing.T) {
+	retryDelay = time.Millisecond
+	tests := map[string]struct {
+		statuses []int
+		attempts int
+	}{
+		"delivered": {
+			statuses: []int{http.StatusOK},
+			attempts: 1,
+		},
+		"delivered on retry": {
+			statuses: []int{http.StatusServiceUnavailable, http.StatusNoContent},
+			attempts: 2,
+		},
+		"dropped after the retries": {
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			attempts: maxAttempts,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			received := make(chan *Alert, len(tt.statuses))
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Error("request: expected a JSON POST, received", r.Method, r.Header.Get("Content-Type"))
+				}
+				alert := new(Alert)
+				if err := json.NewDecoder(r.Body).Decode(alert); err != nil {
+					t.Error(err)
+				}
+				w.WriteHeader(tt.statuses[attempts])
+				attempts++
+				received <- alert
+			}))
+			defer server.Close()
+
+			notifier := NewNotifier([]string{server.URL}, server.Client())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go notifier.Run(ctx)
+			notifier.Notify(KindNvmePathFailed, "//storage.opiproject.org/volumes/nvmetcp12/nvmepaths/p0", "Reconnect retries exhausted")
+
+			for i := 0; i < tt.attempts; i++ {
+				select {
+				case alert := <-received:
+					if alert.Kind != KindNvmePathFailed || alert.Resource != "//storage.opiproject.org/volumes/nvmetcp12/nvmepaths/p0" ||
+						alert.Message != "Reconnect retries exhausted" || alert.Time.IsZero() {
+						t.Error("alert: expected the path failure, received", alert)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("attempts: expected", tt.attempts, "received", i)
+				}
+			}
+		})
+	}
+}
+
+func TestAlert_NotifyFull(t *testing.T) {
+	notifier := NewNotifier([]string{"http://localhost:0"}, nil)
+	// nothing posts the alerts, the ones over the limit are dropped without blocking
+	for i := 0; i < maxPendingAlerts+1; i++ {
+		notifier.Notify(KindControllerInactive, "//storage.opiproject.org/subsystems/subsystem-test/controllers/controller-test", "Controller paused")
+	}
+	if len(notifier.pending) != maxPendingAlerts {
+		t.Error("pending: expected", maxPendingAlerts, "received", len(notifier.pending))
+	}
+}
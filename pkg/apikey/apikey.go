@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apikey authenticates the callers of the bridge with static API keys, each key limited
+// to a scope, for the environments without identity provider
+package apikey
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/audit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the gRPC metadata carrying the API key
+const MetadataKey = "opi-api-key"
+
+// HeaderKey is the HTTP header carrying the API key
+const HeaderKey = "Opi-Api-Key"
+
+// Scopes of the keys
+const (
+	// ScopeReadOnly allows the Get, List and Stats methods, but the admin ones
+	ScopeReadOnly = "read-only"
+	// ScopeProvisioning allows all the methods on the storage resources, but the admin ones
+	ScopeProvisioning = "provisioning"
+	// ScopeAdmin allows all the methods
+	ScopeAdmin = "admin"
+)
+
+// adminServices are the services managing the bridge itself rather than the storage, i.e. its
+// logs, its flight recorder, its audit entries, its latency budgets, its configuration, its
+// connections and the setup of the DPU
+var adminServices = []string{"marvell.logger/", "marvell.recorder/", "marvell.audit/", "marvell.metrics/", "marvell.config/", "grpc.channelz.v1.Channelz/", "marvell.platform/SetupDpu"}
+
+// Key represents an API key, only its hash is configured
+type Key struct {
+	// Name of the key, the identity of its callers for the authorization and the audit
+	Name string `json:"name"`
+	// Sha256 is the SHA-256 of the key, in hexadecimal
+	Sha256 string `json:"sha256"`
+	// Scope of the key, read-only, provisioning or admin
+	Scope string `json:"scope"`
+}
+
+// Allows tells whether the scope of the key allows a method, i.e.
+// opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem or marvell.frontend/CreateQuota
+func (k *Key) Allows(method string) bool {
+	switch k.Scope {
+	case ScopeAdmin:
+		return true
+	case ScopeProvisioning:
+		return !isAdmin(method)
+	case ScopeReadOnly:
+		return !isAdmin(method) && !audit.IsMutating(method)
+	default:
+		return false
+	}
+}
+
+func isAdmin(method string) bool {
+	for _, service := range adminServices {
+		if strings.HasPrefix(method, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys holds the configured API keys by hash
+type Keys struct {
+	mu     sync.RWMutex
+	byHash map[string]*Key
+}
+
+// LoadKeys reads the API keys from a JSON file, i.e. {"keys": [{"name": "ci", "sha256": "...", "scope": "provisioning"}]}
+func LoadKeys(file string) (*Keys, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var config struct {
+		Keys []*Key `json:"keys"`
+	}
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid API keys %s: %v", file, err)
+	}
+	keys, err := NewKeys(config.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API keys %s: %v", file, err)
+	}
+	return keys, nil
+}
+
+// NewKeys checks the names, the hashes and the scopes of keys
+func NewKeys(keys []*Key) (*Keys, error) {
+	k := &Keys{byHash: make(map[string]*Key)}
+	names := make(map[string]bool)
+	for _, key := range keys {
+		if key.Name == "" || names[key.Name] {
+			return nil, fmt.Errorf("key name %q is empty or not unique", key.Name)
+		}
+		names[key.Name] = true
+		hash, err := hex.DecodeString(key.Sha256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("key %s: invalid SHA-256 %q", key.Name, key.Sha256)
+		}
+		switch key.Scope {
+		case ScopeReadOnly, ScopeProvisioning, ScopeAdmin:
+		default:
+			return nil, fmt.Errorf("key %s: unknown scope %q", key.Name, key.Scope)
+		}
+		if _, ok := k.byHash[string(hash)]; ok {
+			return nil, fmt.Errorf("key %s: SHA-256 not unique", key.Name)
+		}
+		k.byHash[string(hash)] = key
+	}
+	return k, nil
+}
+
+// Replace replaces the keys with the ones of another, i.e. the keys file loaded again
+func (k *Keys) Replace(other *Keys) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.byHash = other.byHash
+}
+
+// Check authenticates an API key and checks its scope allows a method
+func (k *Keys) Check(value string, method string) (*Key, error) {
+	if value == "" {
+		msg := "Missing API key, set the opi-api-key metadata or the Opi-Api-Key header"
+		return nil, status.Errorf(codes.Unauthenticated, msg)
+	}
+	// the keys are looked up by their hash, the lookup time tells nothing about the keys
+	hash := sha256.Sum256([]byte(value))
+	k.mu.RLock()
+	key, ok := k.byHash[string(hash[:])]
+	k.mu.RUnlock()
+	if !ok {
+		msg := "Invalid API key"
+		return nil, status.Errorf(codes.Unauthenticated, msg)
+	}
+	if !key.Allows(method) {
+		msg := fmt.Sprintf("API key %s with scope %s is not allowed to call %s", key.Name, key.Scope, method)
+		return nil, status.Errorf(codes.PermissionDenied, msg)
+	}
+	return key, nil
+}
+
+// UnaryServerInterceptor refuses the gRPC calls without an API key allowing them, the name of
+// the key is passed down as the identity of the caller
+func (k *Keys) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := k.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor refuses the gRPC streams without an API key allowing them
+func (k *Keys) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := k.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate checks the API key of the metadata of a call
+func (k *Keys) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	var value string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(MetadataKey); len(values) != 0 {
+			value = values[0]
+		}
+	}
+	key, err := k.Check(value, strings.TrimPrefix(fullMethod, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return authz.NewContext(ctx, key.Name), nil
+}
+
+// authenticatedStream is a server stream with the identity of the key in its context
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
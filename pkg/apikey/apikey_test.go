@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apikey authenticates the callers of the bridge with static API keys, each key limited
+// to a scope, for the environments without identity provider
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func testKeys(t *testing.T) *Keys {
+	keys, err := NewKeys([]*Key{
+		{Name: "monitoring", Sha256: hash("read-key"), Scope: ScopeReadOnly},
+		{Name: "ci", Sha256: hash("provisioning-key"), Scope: ScopeProvisioning},
+		{Name: "ops", Sha256: hash("admin-key"), Scope: ScopeAdmin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestAPIKey_Check(t *testing.T) {
+	keys := testKeys(t)
+	tests := map[string]struct {
+		key     string
+		method  string
+		name    string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"read-only get": {
+			key:     "read-key",
+			method:  "opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem",
+			name:    "monitoring",
+			errCode: codes.OK,
+		},
+		"read-only stats": {
+			key:     "read-key",
+			method:  "marvell.frontend/StatsNvmeNamespaces",
+			name:    "monitoring",
+			errCode: codes.OK,
+		},
+		"read-only create": {
+			key:     "read-key",
+			method:  "opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem",
+			errCode: codes.PermissionDenied,
+			errMsg:  "API key monitoring with scope read-only is not allowed to call opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem",
+		},
+		"read-only admin get": {
+			key:     "read-key",
+			method:  "marvell.audit/ListAuditEntries",
+			errCode: codes.PermissionDenied,
+			errMsg:  "API key monitoring with scope read-only is not allowed to call marvell.audit/ListAuditEntries",
+		},
+		"provisioning channelz": {
+			key:     "provisioning-key",
+			method:  "grpc.channelz.v1.Channelz/GetServers",
+			errCode: codes.PermissionDenied,
+			errMsg:  "API key ci with scope provisioning is not allowed to call grpc.channelz.v1.Channelz/GetServers",
+		},
+		"provisioning create": {
+			key:     "provisioning-key",
+			method:  "opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem",
+			name:    "ci",
+			errCode: codes.OK,
+		},
+		"provisioning admin update": {
+			key:     "provisioning-key",
+			method:  "marvell.logger/UpdateLogConfig",
+			errCode: codes.PermissionDenied,
+			errMsg:  "API key ci with scope provisioning is not allowed to call marvell.logger/UpdateLogConfig",
+		},
+		"admin update": {
+			key:     "admin-key",
+			method:  "marvell.logger/UpdateLogConfig",
+			name:    "ops",
+			errCode: codes.OK,
+		},
+		"missing key": {
+			key:     "",
+			method:  "opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem",
+			errCode: codes.Unauthenticated,
+			errMsg:  "Missing API key, set the opi-api-key metadata or the Opi-Api-Key header",
+		},
+		"unknown key": {
+			key:     "other-key",
+			method:  "opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem",
+			errCode: codes.Unauthenticated,
+			errMsg:  "Invalid API key",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := keys.Check(tt.key, tt.method)
+			keyName := ""
+			if key != nil {
+				keyName = key.Name
+			}
+			if keyName != tt.name {
+				t.Error("key: expected", tt.name, "received", keyName)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestAPIKey_LoadKeys(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		errMsg string
+	}{
+		"valid": {
+			config: `{"keys": [{"name": "ci", "sha256": "` + hash("key") + `", "scope": "provisioning"}]}`,
+			errMsg: "",
+		},
+		"unknown scope": {
+			config: `{"keys": [{"name": "ci", "sha256": "` + hash("key") + `", "scope": "root"}]}`,
+			errMsg: `key ci: unknown scope "root"`,
+		},
+		"invalid hash": {
+			config: `{"keys": [{"name": "ci", "sha256": "key", "scope": "admin"}]}`,
+			errMsg: `key ci: invalid SHA-256 "key"`,
+		},
+		"duplicate name": {
+			config: `{"keys": [{"name": "ci", "sha256": "` + hash("key") + `", "scope": "admin"}, {"name": "ci", "sha256": "` + hash("other") + `", "scope": "admin"}]}`,
+			errMsg: `key name "ci" is empty or not unique`,
+		},
+		"duplicate hash": {
+			config: `{"keys": [{"name": "ci", "sha256": "` + hash("key") + `", "scope": "admin"}, {"name": "ops", "sha256": "` + hash("key") + `", "scope": "admin"}]}`,
+			errMsg: "key ops: SHA-256 not unique",
+		},
+		"plain key": {
+			config: `{"keys": [{"name": "ci", "key": "key", "scope": "admin"}]}`,
+			errMsg: `json: unknown field "key"`,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "api_keys.json")
+			if err := os.WriteFile(file, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadKeys(file)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			expected := ""
+			if tt.errMsg != "" {
+				expected = "invalid API keys " + file + ": " + tt.errMsg
+			}
+			if errMsg != expected {
+				t.Error("error: expected", expected, "received", errMsg)
+			}
+		})
+	}
+}
+
+func TestAPIKey_UnaryServerInterceptor(t *testing.T) {
+	interceptor := testKeys(t).UnaryServerInterceptor()
+	tests := map[string]struct {
+		md       metadata.MD
+		identity string
+		errCode  codes.Code
+	}{
+		"valid key": {
+			md:       metadata.Pairs(MetadataKey, "provisioning-key", authz.MetadataKey, "admin"),
+			identity: "ci",
+			errCode:  codes.OK,
+		},
+		"without key": {
+			md:      metadata.Pairs(authz.MetadataKey, "admin"),
+			errCode: codes.Unauthenticated,
+		},
+		"scope not allowing the method": {
+			md:      metadata.Pairs(MetadataKey, "read-key"),
+			errCode: codes.PermissionDenied,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			identity := ""
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				identity = authz.IdentityFromContext(ctx)
+				return req, nil
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem"}, handler)
+			if identity != tt.identity {
+				t.Error("identity: expected", tt.identity, "received", identity)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+		})
+	}
+}
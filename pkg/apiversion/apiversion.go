@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apiversion serves several versions of the storage API of opi-api at once, the version
+// of each call being chosen by the client, and translates the requests of the other versions to
+// the v1alpha1 one the servers implement, and their responses back, so the clients move to a new
+// version on their own schedule
+package apiversion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the gRPC metadata carrying the version of the API of a call, and sent back in
+// the headers of the response
+const MetadataKey = "opi-api-version"
+
+// HeaderKey is the HTTP header carrying the version of the API of a call
+const HeaderKey = "Opi-Api-Version"
+
+const (
+	// V1Alpha1 is the version of the storage API the servers implement
+	V1Alpha1 = "v1alpha1"
+	// V1 is the upcoming version of the storage API, its protos keep the opi_api.storage.v1
+	// package and the /v1 paths, they are served as v1alpha1 until translations are registered
+	V1 = "v1"
+)
+
+// Translation converts the request of a method from a version of the API to v1alpha1, and its
+// response back to the version, either can be nil when the messages are the same
+type Translation struct {
+	Request  func(req interface{}) (interface{}, error)
+	Response func(resp interface{}) (interface{}, error)
+}
+
+// Layer serves the supported versions of the API, the default one to the clients not giving any
+type Layer struct {
+	mu           sync.RWMutex
+	defaultValue string
+	// translations are by version, then by full method name
+	translations map[string]map[string]Translation
+}
+
+// New returns a Layer serving v1alpha1 and v1, the one of defaultVersion to the clients not
+// giving any
+func New(defaultVersion string) (*Layer, error) {
+	l := &Layer{translations: map[string]map[string]Translation{
+		V1Alpha1: {},
+		V1:       {},
+	}}
+	if _, ok := l.translations[defaultVersion]; !ok {
+		return nil, fmt.Errorf("unsupported API version %q, supported versions are %s", defaultVersion, strings.Join(l.Versions(), ", "))
+	}
+	l.defaultValue = defaultVersion
+	return l, nil
+}
+
+// Register sets the translation of a method, i.e. /opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem,
+// from version to v1alpha1
+func (l *Layer) Register(version string, fullMethod string, translation Translation) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	methods, ok := l.translations[version]
+	if !ok {
+		return fmt.Errorf("unsupported API version %q", version)
+	}
+	if version == V1Alpha1 {
+		return fmt.Errorf("%s is served as is", V1Alpha1)
+	}
+	methods[fullMethod] = translation
+	return nil
+}
+
+// Versions returns the supported versions of the API, sorted
+func (l *Layer) Versions() []string {
+	versions := make([]string, 0, len(l.translations))
+	for version := range l.translations {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Default returns the version of the API of the calls not giving any
+func (l *Layer) Default() string {
+	return l.defaultValue
+}
+
+// version returns the version of the API of a call, the default one when the client gives none
+func (l *Layer) version(ctx context.Context) (string, error) {
+	version := l.defaultValue
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(MetadataKey); len(values) != 0 && values[0] != "" {
+			version = strings.ToLower(strings.TrimSpace(values[0]))
+		}
+	}
+	if _, ok := l.translations[version]; !ok {
+		msg := fmt.Sprintf("unsupported API version %q, supported versions are %s", version, strings.Join(l.Versions(), ", "))
+		return "", status.Errorf(codes.InvalidArgument, msg)
+	}
+	return version, nil
+}
+
+// translation returns the translation of a method of a version, the zero one when the messages
+// are the same
+func (l *Layer) translation(version string, fullMethod string) Translation {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.translations[version][fullMethod]
+}
+
+// UnaryServerInterceptor translates the requests of the version of the API given by the client
+// before they are handled, and the responses after, the version served is sent back in the headers
+func (l *Layer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		version, err := l.version(ctx)
+		if err != nil {
+			return nil, err
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, version))
+		translation := l.translation(version, info.FullMethod)
+		if translation.Request != nil {
+			if req, err = translation.Request(req); err != nil {
+				msg := fmt.Sprintf("cannot translate the %s request of %s: %v", version, info.FullMethod, err)
+				return nil, status.Errorf(codes.InvalidArgument, msg)
+			}
+		}
+		resp, err := handler(ctx, req)
+		if err != nil || translation.Response == nil {
+			return resp, err
+		}
+		if resp, err = translation.Response(resp); err != nil {
+			msg := fmt.Sprintf("cannot translate the response of %s to %s: %v", info.FullMethod, version, err)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor refuses the streams of the unsupported versions of the API, the
+// messages of the streams are the same in all the versions
+func (l *Layer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		version, err := l.version(ss.Context())
+		if err != nil {
+			return err
+		}
+		_ = ss.SetHeader(metadata.Pairs(MetadataKey, version))
+		return handler(srv, ss)
+	}
+}
+
+// ListAPIVersionsRequest represents a request to list the versions of the API
+type ListAPIVersionsRequest struct{}
+
+// ListAPIVersionsResponse represents the versions of the API served by the bridge
+type ListAPIVersionsResponse struct {
+	Versions []string `json:"versions"`
+	// Default is the version of the calls not giving any
+	Default string `json:"default"`
+}
+
+// ListAPIVersions lists the versions of the API served by the bridge
+func (l *Layer) ListAPIVersions(_ context.Context, _ *ListAPIVersionsRequest) (*ListAPIVersionsResponse, error) {
+	return &ListAPIVersionsResponse{Versions: l.Versions(), Default: l.defaultValue}, nil
+}
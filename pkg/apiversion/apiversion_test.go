@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apiversion serves several versions of the storage API of opi-api at once, the version
+// of each call being chosen by the client, and translates the requests of the other versions to
+// the v1alpha1 one the servers implement, and their responses back, so the clients move to a new
+// version on their own schedule
+package apiversion
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+const testMethod = "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"
+
+// testStream records the headers set by the interceptor
+type testStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *testStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestAPIVersion_New(t *testing.T) {
+	if _, err := New("v2"); err == nil {
+		t.Error("expected an error for an unsupported default version")
+	}
+	l, err := New(V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, _ := l.ListAPIVersions(context.Background(), &ListAPIVersionsRequest{})
+	expected := &ListAPIVersionsResponse{Versions: []string{V1, V1Alpha1}, Default: V1}
+	if !reflect.DeepEqual(response, expected) {
+		t.Error("response: expected", expected, "received", response)
+	}
+	if err := l.Register(V1Alpha1, testMethod, Translation{}); err == nil {
+		t.Error("expected an error for a translation of v1alpha1")
+	}
+	if err := l.Register("v2", testMethod, Translation{}); err == nil {
+		t.Error("expected an error for a translation of an unsupported version")
+	}
+}
+
+func TestAPIVersion_UnaryServerInterceptor(t *testing.T) {
+	l, err := New(V1Alpha1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the v1 request names the subsystem by its ID only
+	err = l.Register(V1, testMethod, Translation{
+		Request: func(req interface{}) (interface{}, error) {
+			in := req.(*pb.GetNvmeSubsystemRequest)
+			if in.Name == "" {
+				return nil, errors.New("missing name")
+			}
+			return &pb.GetNvmeSubsystemRequest{Name: "//storage.opiproject.org/nvmeSubsystems/" + in.Name}, nil
+		},
+		Response: func(resp interface{}) (interface{}, error) {
+			out := resp.(*pb.NvmeSubsystem)
+			return &pb.NvmeSubsystem{Name: out.Name[len("//storage.opiproject.org/nvmeSubsystems/"):]}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		version  string
+		in       string
+		received string
+		out      string
+		errCode  codes.Code
+	}{
+		"default version": {
+			version:  "",
+			in:       "//storage.opiproject.org/nvmeSubsystems/subsys0",
+			received: "//storage.opiproject.org/nvmeSubsystems/subsys0",
+			out:      "//storage.opiproject.org/nvmeSubsystems/subsys0",
+		},
+		"v1alpha1": {
+			version:  "v1alpha1",
+			in:       "//storage.opiproject.org/nvmeSubsystems/subsys0",
+			received: "//storage.opiproject.org/nvmeSubsystems/subsys0",
+			out:      "//storage.opiproject.org/nvmeSubsystems/subsys0",
+		},
+		"v1 translated": {
+			version:  " V1 ",
+			in:       "subsys0",
+			received: "//storage.opiproject.org/nvmeSubsystems/subsys0",
+			out:      "subsys0",
+		},
+		"v1 untranslatable": {
+			version: "v1",
+			in:      "",
+			errCode: codes.InvalidArgument,
+		},
+		"unsupported version": {
+			version: "v2",
+			in:      "subsys0",
+			errCode: codes.InvalidArgument,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			stream := &testStream{}
+			ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+			if tt.version != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, tt.version))
+			}
+			received := ""
+			handler := func(_ context.Context, req interface{}) (interface{}, error) {
+				received = req.(*pb.GetNvmeSubsystemRequest).Name
+				return &pb.NvmeSubsystem{Name: received}, nil
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+			out, err := l.UnaryServerInterceptor()(ctx, &pb.GetNvmeSubsystemRequest{Name: tt.in}, info, handler)
+			if status.Code(err) != tt.errCode {
+				t.Error("error: expected", tt.errCode, "received", err)
+			}
+			if received != tt.received {
+				t.Error("request: expected", tt.received, "received", received)
+			}
+			if tt.errCode != codes.OK {
+				return
+			}
+			if name := out.(*pb.NvmeSubsystem).Name; name != tt.out {
+				t.Error("response: expected", tt.out, "received", name)
+			}
+			if header := stream.header.Get(MetadataKey); len(header) != 1 || header[0] == "" {
+				t.Error("header: expected the version served, received", header)
+			}
+		})
+	}
+}
@@ -0,0 +1,479 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apply applies a declarative config of the resources of the bridge atomically, the
+// declared resources missing are created, the changed ones updated and the undeclared ones
+// pruned, and when a step fails the executed ones are undone, last first, so the bridge is back
+// to the snapshot it was in before the config
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrorDomain is the domain of the google.rpc.ErrorInfo details of the configs which failed
+const ErrorDomain = "storage.opiproject.org"
+
+// Reasons of the google.rpc.ErrorInfo details of the configs which failed
+const (
+	// ReasonStepFailed tells a step failed and the executed ones were rolled back
+	ReasonStepFailed = "APPLY_STEP_FAILED"
+	// ReasonRollbackFailed tells a step failed and some of the executed ones couldn't be undone
+	ReasonRollbackFailed = "APPLY_ROLLBACK_FAILED"
+)
+
+// Resource represents a resource declared in a config
+type Resource struct {
+	// Type of the resource, as exported, i.e. opi_nvme_subsystem
+	Type string `json:"type"`
+	// ResourceID is the ID the resource is created with, the last segment of its name
+	ResourceID string `json:"resourceId"`
+	// Parent is the name of the parent of the resource, empty for the ones which have none
+	Parent string `json:"parent,omitempty"`
+	// Resource is the resource as in the bodies of the HTTP gateway, its name and status are
+	// ignored
+	Resource json.RawMessage `json:"resource"`
+}
+
+// Existing represents a resource of the bridge, as exported
+type Existing struct {
+	// Type of the resource, i.e. opi_nvme_subsystem
+	Type string
+	// Name of the resource
+	Name string
+	// ResourceID is the last segment of the name of the resource
+	ResourceID string
+	// Parent is the name of the parent of the resource, empty for the ones which have none
+	Parent string
+	// Resource is the resource as returned by its Get
+	Resource proto.Message
+}
+
+// Config represents a declarative config, as signed by the pipelines allowed to apply it
+type Config struct {
+	// Resources are the resources the bridge has once the config is applied
+	Resources []*Resource `json:"resources"`
+	// Prune deletes the resources of the bridge the config doesn't declare, they are kept
+	// otherwise
+	Prune bool `json:"prune"`
+}
+
+// ApplyConfigRequest represents a request to apply a config
+type ApplyConfigRequest struct {
+	// Resources are the resources the bridge has once the config is applied
+	Resources []*Resource `json:"resources"`
+	// Prune deletes the resources of the bridge the config doesn't declare, they are kept
+	// otherwise
+	Prune bool `json:"prune"`
+	// Config is a signed config, the JSON encoding of a Config, set instead of Resources and
+	// Prune. It is required when the server checks the signatures
+	Config []byte `json:"config,omitempty"`
+	// Signature is the detached signature of Config
+	Signature []byte `json:"signature,omitempty"`
+	// ValidateOnly returns the steps of the config without executing them
+	ValidateOnly bool `json:"validateOnly"`
+}
+
+// ApplyConfigResponse represents the steps a config was applied with
+type ApplyConfigResponse struct {
+	// Steps are the steps in the order they are executed, the deletions first then the
+	// creations and the updates, the parents before their children
+	Steps []*Step `json:"steps"`
+}
+
+// Step represents a call applying a resource of a config
+type Step struct {
+	// Method of the step, i.e. CreateNvmeController
+	Method string `json:"method"`
+	// Type of the resource
+	Type string `json:"type"`
+	// Name of the resource, set for the creations once executed
+	Name string `json:"name,omitempty"`
+	// ResourceID is the last segment of the name of the resource
+	ResourceID string `json:"resourceId"`
+	// Parent is the name of the parent of the resource
+	Parent string `json:"parent,omitempty"`
+	// FirmwareCalls are the methods of the firmware the step called, in order
+	FirmwareCalls []string `json:"firmwareCalls,omitempty"`
+}
+
+// Kind creates, updates and deletes the resources of a type
+type Kind struct {
+	// Type of the resources, as exported, i.e. opi_nvme_subsystem
+	Type string
+	// Resource names the methods of the resources, i.e. NvmeSubsystem
+	Resource string
+	// New returns an empty resource
+	New func() proto.Message
+	// Create creates a resource under parent with id, it returns the created resource
+	Create func(ctx context.Context, parent string, id string, resource proto.Message) (proto.Message, error)
+	// Update replaces a resource, given by its name
+	Update func(ctx context.Context, resource proto.Message) (proto.Message, error)
+	// Delete deletes a resource
+	Delete func(ctx context.Context, name string) error
+	// Unrecoverable tells the resources can't be created again from their snapshot, i.e. the
+	// encrypted volumes whose key isn't returned, so the configs pruning them are refused
+	Unrecoverable bool
+}
+
+// NewKind returns the kind of the resources of type R, created, updated and deleted with the
+// methods of a server
+func NewKind[R proto.Message](resourceType string, resource string,
+	create func(ctx context.Context, parent string, id string, resource R) (R, error),
+	update func(ctx context.Context, resource R) (R, error),
+	remove func(ctx context.Context, name string) error) Kind {
+	return Kind{
+		Type:     resourceType,
+		Resource: resource,
+		New: func() proto.Message {
+			var r R
+			return r.ProtoReflect().New().Interface()
+		},
+		Create: func(ctx context.Context, parent string, id string, resource proto.Message) (proto.Message, error) {
+			return create(ctx, parent, id, resource.(R))
+		},
+		Update: func(ctx context.Context, resource proto.Message) (proto.Message, error) {
+			return update(ctx, resource.(R))
+		},
+		Delete: remove,
+	}
+}
+
+// Server applies the configs
+type Server struct {
+	snapshot func(ctx context.Context) ([]*Existing, error)
+	// kinds are in dependency order, the parents and the volumes before the resources using them
+	kinds []Kind
+
+	// mu applies one config at a time
+	mu sync.Mutex
+	// verifier checks the signature of the configs when set
+	verifier *Verifier
+}
+
+// NewServer returns a Server applying the configs of the kinds, in dependency order, the
+// resources of the bridge being listed by snapshot
+func NewServer(snapshot func(ctx context.Context) ([]*Existing, error), kinds []Kind) *Server {
+	return &Server{snapshot: snapshot, kinds: kinds}
+}
+
+// SetVerifier has the configs signed by one of the keys of verifier, the unsigned ones are refused
+func (s *Server) SetVerifier(verifier *Verifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verifier = verifier
+}
+
+// action is a step and the call undoing it
+type action struct {
+	step *Step
+	do   func(ctx context.Context) error
+	// undoMethod is the method of undo, i.e. DeleteNvmeController for a creation
+	undoMethod string
+	undo       func(ctx context.Context) error
+}
+
+// ApplyConfig applies a config, when a step fails the executed ones are undone and the error
+// tells the method and the resource of the step, as google.rpc.ErrorInfo and
+// google.rpc.ResourceInfo details, along with the details of the error of the step
+func (s *Server) ApplyConfig(ctx context.Context, in *ApplyConfigRequest) (*ApplyConfigResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in, err := s.verify(in)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := s.plan(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	response := &ApplyConfigResponse{Steps: make([]*Step, 0, len(actions))}
+	for _, a := range actions {
+		response.Steps = append(response.Steps, a.step)
+	}
+	if in.ValidateOnly {
+		return response, nil
+	}
+	for i, a := range actions {
+		calls := new(firmwareCalls)
+		err := a.do(withFirmwareCalls(ctx, calls))
+		methods, failed := calls.list()
+		a.step.FirmwareCalls = methods
+		if err != nil {
+			return nil, s.rollback(ctx, actions[:i], a, failed, err)
+		}
+	}
+	slog.InfoContext(ctx, "Applied the config", "steps", len(actions))
+	return response, nil
+}
+
+// verify checks the signature of the config of a request when the server checks the signatures,
+// and returns the request with the resources of its config
+func (s *Server) verify(in *ApplyConfigRequest) (*ApplyConfigRequest, error) {
+	if in.Config == nil {
+		if s.verifier != nil {
+			return nil, status.Error(codes.PermissionDenied, "Unsigned config, the resources have to be set in a signed config")
+		}
+		return in, nil
+	}
+	if len(in.Resources) != 0 || in.Prune {
+		return nil, status.Error(codes.InvalidArgument, "Config set along with resources or prune, have to be set in the config only")
+	}
+	if s.verifier != nil {
+		if err := s.verifier.Verify(in.Config, in.Signature); err != nil {
+			return nil, status.Errorf(codes.PermissionDenied, "Invalid signature of the config: %v", err)
+		}
+	}
+	var config Config
+	if err := json.Unmarshal(in.Config, &config); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid config: %v", err)
+	}
+	return &ApplyConfigRequest{Resources: config.Resources, Prune: config.Prune, ValidateOnly: in.ValidateOnly}, nil
+}
+
+// plan validates a config and returns its steps, the deletions of the undeclared resources,
+// children first, then the creations and the updates, parents first
+func (s *Server) plan(ctx context.Context, in *ApplyConfigRequest) ([]*action, error) {
+	order := make(map[string]int)
+	for i, kind := range s.kinds {
+		order[kind.Type] = i
+	}
+	var v validation.Validator
+	declared := make(map[string]proto.Message)
+	for i, r := range in.Resources {
+		field := fmt.Sprintf("resources[%d]", i)
+		i, ok := order[r.Type]
+		if !v.Check(ok, field+".type", "unknown resource type %q", r.Type) {
+			continue
+		}
+		if !v.Required(field+".resource_id", r.ResourceID != "") || !v.ResourceID(field+".resource_id", r.ResourceID) {
+			continue
+		}
+		key := resourceKey(r.Type, r.Parent, r.ResourceID)
+		if !v.Check(declared[key] == nil, field, "%s %s is declared twice", r.Type, r.ResourceID) {
+			continue
+		}
+		resource := s.kinds[i].New()
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(r.Resource, resource); err != nil {
+			v.Violation(field+".resource", "invalid %s: %v", r.Type, err)
+			continue
+		}
+		clearField(resource, "name")
+		clearField(resource, "status")
+		declared[key] = resource
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]*Existing)
+	var pruned []*Existing
+	for _, e := range existing {
+		if _, ok := order[e.Type]; !ok {
+			continue
+		}
+		key := resourceKey(e.Type, e.Parent, e.ResourceID)
+		current[key] = e
+		if declared[key] == nil && in.Prune {
+			// the deletion couldn't be rolled back when a later step fails
+			if s.kinds[order[e.Type]].Unrecoverable {
+				msg := fmt.Sprintf("%s %s can't be pruned, it can't be created again when the config is rolled back, delete it first", e.Type, e.Name)
+				return nil, status.Errorf(codes.FailedPrecondition, msg)
+			}
+			pruned = append(pruned, e)
+		}
+	}
+
+	var actions []*action
+	for i := len(s.kinds) - 1; i >= 0; i-- {
+		kind := s.kinds[i]
+		for j := len(pruned) - 1; j >= 0; j-- {
+			if pruned[j].Type == kind.Type {
+				actions = append(actions, deletion(kind, pruned[j]))
+			}
+		}
+	}
+	for _, kind := range s.kinds {
+		for _, r := range in.Resources {
+			if r.Type != kind.Type {
+				continue
+			}
+			key := resourceKey(r.Type, r.Parent, r.ResourceID)
+			e, ok := current[key]
+			if !ok {
+				actions = append(actions, creation(kind, r, declared[key]))
+				continue
+			}
+			// the fields the config doesn't set keep their value, i.e. the defaults of the bridge
+			prior := withoutStatus(e.Resource)
+			updated := proto.Clone(prior)
+			proto.Merge(updated, declared[key])
+			if !proto.Equal(updated, prior) {
+				actions = append(actions, update(kind, e, prior, updated))
+			}
+		}
+	}
+	return actions, nil
+}
+
+// creation creates a declared resource, it is undone by deleting it
+func creation(kind Kind, r *Resource, resource proto.Message) *action {
+	a := &action{
+		step:       &Step{Method: "Create" + kind.Resource, Type: kind.Type, ResourceID: r.ResourceID, Parent: r.Parent},
+		undoMethod: "Delete" + kind.Resource,
+	}
+	a.do = func(ctx context.Context) error {
+		created, err := kind.Create(ctx, r.Parent, r.ResourceID, proto.Clone(resource))
+		if err != nil {
+			return err
+		}
+		a.step.Name = nameOf(created)
+		return nil
+	}
+	a.undo = func(ctx context.Context) error {
+		return kind.Delete(ctx, a.step.Name)
+	}
+	return a
+}
+
+// update replaces an existing resource, it is undone by replacing it with its prior value
+func update(kind Kind, e *Existing, prior proto.Message, updated proto.Message) *action {
+	return &action{
+		step: &Step{Method: "Update" + kind.Resource, Type: kind.Type, Name: e.Name, ResourceID: e.ResourceID, Parent: e.Parent},
+		do: func(ctx context.Context) error {
+			_, err := kind.Update(ctx, proto.Clone(updated))
+			return err
+		},
+		undoMethod: "Update" + kind.Resource,
+		undo: func(ctx context.Context) error {
+			_, err := kind.Update(ctx, proto.Clone(prior))
+			return err
+		},
+	}
+}
+
+// deletion deletes an undeclared resource, it is undone by creating it again
+func deletion(kind Kind, e *Existing) *action {
+	prior := withoutStatus(e.Resource)
+	clearField(prior, "name")
+	return &action{
+		step: &Step{Method: "Delete" + kind.Resource, Type: kind.Type, Name: e.Name, ResourceID: e.ResourceID, Parent: e.Parent},
+		do: func(ctx context.Context) error {
+			return kind.Delete(ctx, e.Name)
+		},
+		undoMethod: "Create" + kind.Resource,
+		undo: func(ctx context.Context) error {
+			_, err := kind.Create(ctx, e.Parent, e.ResourceID, proto.Clone(prior))
+			return err
+		},
+	}
+}
+
+// rollback undoes the executed actions, last first, and returns the error of the failed one,
+// with the code of its error
+func (s *Server) rollback(ctx context.Context, executed []*action, failed *action, failedCall string, cause error) error {
+	// the rollback completes even when the caller is gone
+	ctx = context.WithoutCancel(ctx)
+	var failures []string
+	for i := len(executed) - 1; i >= 0; i-- {
+		a := executed[i]
+		if err := a.undo(ctx); err != nil {
+			slog.ErrorContext(ctx, "Could not roll back a step of the config", "method", a.undoMethod, "name", a.step.Name, "error", err)
+			failures = append(failures, fmt.Sprintf("%s of %s: %s", a.undoMethod, a.step.Name, status.Convert(err).Message()))
+		}
+	}
+	resource := failed.step.Name
+	if resource == "" {
+		resource = failed.step.ResourceID
+	}
+	cst := status.Convert(cause)
+	code := cst.Code()
+	if code == codes.OK || code == codes.Unknown {
+		code = codes.Internal
+	}
+	reason := ReasonStepFailed
+	msg := fmt.Sprintf("%s of %s failed: %s, the %d executed steps were rolled back",
+		failed.step.Method, resource, cst.Message(), len(executed))
+	if len(failures) != 0 {
+		reason = ReasonRollbackFailed
+		msg = fmt.Sprintf("%s of %s failed: %s, %d of the %d executed steps could not be rolled back: %s",
+			failed.step.Method, resource, cst.Message(), len(failures), len(executed), strings.Join(failures, "; "))
+	}
+	slog.WarnContext(ctx, "Could not apply the config", "method", failed.step.Method, "resource", resource, "rolled_back", len(executed)-len(failures), "error", cause)
+	metadata := map[string]string{
+		"method":      failed.step.Method,
+		"type":        failed.step.Type,
+		"resource":    resource,
+		"parent":      failed.step.Parent,
+		"step":        strconv.Itoa(len(executed) + 1),
+		"rolled_back": strconv.Itoa(len(executed) - len(failures)),
+	}
+	if failedCall != "" {
+		metadata["firmware_method"] = failedCall
+	}
+	if len(failures) != 0 {
+		metadata["rollback_failures"] = strings.Join(failures, "; ")
+	}
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{Reason: reason, Domain: ErrorDomain, Metadata: metadata},
+		&errdetails.ResourceInfo{ResourceType: failed.step.Type, ResourceName: resource, Description: cst.Message()},
+	}
+	for _, detail := range cst.Details() {
+		if d, ok := detail.(protoadapt.MessageV1); ok {
+			details = append(details, d)
+		}
+	}
+	st, err := status.New(code, msg).WithDetails(details...)
+	if err != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// resourceKey identifies a resource of a config
+func resourceKey(resourceType string, parent string, id string) string {
+	return resourceType + " " + parent + " " + id
+}
+
+// withoutStatus returns a copy of a resource without its status, output only
+func withoutStatus(resource proto.Message) proto.Message {
+	clone := proto.Clone(resource)
+	clearField(clone, "status")
+	return clone
+}
+
+// clearField clears a field of a resource, given by its name, when it has one
+func clearField(resource proto.Message, name protoreflect.Name) {
+	message := resource.ProtoReflect()
+	if field := message.Descriptor().Fields().ByName(name); field != nil {
+		message.Clear(field)
+	}
+}
+
+// nameOf returns the name of a resource
+func nameOf(resource proto.Message) string {
+	if r, ok := resource.(interface{ GetName() string }); ok {
+		return r.GetName()
+	}
+	return ""
+}
@@ -0,0 +1,343 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apply applies a declarative config of the resources of the bridge atomically, the
+// declared resources missing are created, the changed ones updated and the undeclared ones
+// pruned, and when a step fails the executed ones are undone, last first, so the bridge is back
+// to the snapshot it was in before the config
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// testFirmware fails the creations of the controllers with the ID fail
+type testFirmware struct {
+	spdk.JSONRPC
+}
+
+func (f *testFirmware) Call(_ context.Context, _ string, args, result interface{}) error {
+	if args == "fail" {
+		result.(*testResult).Status = int(models.StatusNoDevice)
+	}
+	return nil
+}
+
+type testResult struct {
+	Status int
+}
+
+// testBridge keeps the subsystems and their controllers in memory
+type testBridge struct {
+	firmware  spdk.JSONRPC
+	resources map[string]proto.Message
+	parents   map[string]string
+}
+
+func newTestBridge() *testBridge {
+	b := &testBridge{firmware: WrapJSONRPC(&testFirmware{}), resources: make(map[string]proto.Message), parents: make(map[string]string)}
+	b.resources["//storage.opiproject.org/subsystems/subsys0"] = &pb.NvmeSubsystem{Name: "//storage.opiproject.org/subsystems/subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi0"}, Status: &pb.NvmeSubsystemStatus{FirmwareRevision: "1.0"}}
+	b.resources["//storage.opiproject.org/subsystems/subsys1"] = &pb.NvmeSubsystem{Name: "//storage.opiproject.org/subsystems/subsys1", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi1"}}
+	b.resources["//storage.opiproject.org/subsystems/subsys0/controllers/ctrl0"] = &pb.NvmeController{Name: "//storage.opiproject.org/subsystems/subsys0/controllers/ctrl0", Spec: &pb.NvmeControllerSpec{MaxNsq: 4}}
+	b.parents["//storage.opiproject.org/subsystems/subsys0/controllers/ctrl0"] = "//storage.opiproject.org/subsystems/subsys0"
+	return b
+}
+
+func (b *testBridge) call(ctx context.Context, method string, id string) error {
+	result := new(testResult)
+	if err := b.firmware.Call(ctx, method, id, result); err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		return models.Status(result.Status).Err(codes.FailedPrecondition, "could not create "+id, method)
+	}
+	return nil
+}
+
+func (b *testBridge) kinds() []Kind {
+	remove := func(method string) func(context.Context, string) error {
+		return func(ctx context.Context, name string) error {
+			if b.resources[name] == nil {
+				return status.Errorf(codes.NotFound, "unable to find key %s", name)
+			}
+			delete(b.resources, name)
+			delete(b.parents, name)
+			return b.call(ctx, method, name)
+		}
+	}
+	return []Kind{
+		NewKind("opi_nvme_subsystem", "NvmeSubsystem",
+			func(ctx context.Context, _ string, id string, subsystem *pb.NvmeSubsystem) (*pb.NvmeSubsystem, error) {
+				subsystem.Name = "//storage.opiproject.org/subsystems/" + id
+				b.resources[subsystem.Name] = subsystem
+				return subsystem, b.call(ctx, "mrvl_nvm_subsys_create", id)
+			},
+			func(_ context.Context, subsystem *pb.NvmeSubsystem) (*pb.NvmeSubsystem, error) {
+				b.resources[subsystem.Name] = subsystem
+				return subsystem, nil
+			},
+			remove("mrvl_nvm_subsys_delete")),
+		NewKind("opi_nvme_controller", "NvmeController",
+			func(ctx context.Context, parent string, id string, controller *pb.NvmeController) (*pb.NvmeController, error) {
+				if err := b.call(ctx, "mrvl_nvm_ctrlr_create", id); err != nil {
+					return nil, err
+				}
+				controller.Name = parent + "/controllers/" + id
+				b.resources[controller.Name] = controller
+				b.parents[controller.Name] = parent
+				return controller, nil
+			},
+			func(_ context.Context, controller *pb.NvmeController) (*pb.NvmeController, error) {
+				b.resources[controller.Name] = controller
+				return controller, nil
+			},
+			remove("mrvl_nvm_ctrlr_delete")),
+	}
+}
+
+func (b *testBridge) snapshot(context.Context) ([]*Existing, error) {
+	var existing []*Existing
+	for name, resource := range b.resources {
+		e := &Existing{Type: "opi_nvme_subsystem", Name: name, ResourceID: name[strings.LastIndex(name, "/")+1:], Parent: b.parents[name], Resource: proto.Clone(resource)}
+		if e.Parent != "" {
+			e.Type = "opi_nvme_controller"
+		}
+		existing = append(existing, e)
+	}
+	sort.Slice(existing, func(i int, j int) bool { return existing[i].Name < existing[j].Name })
+	return existing, nil
+}
+
+// state describes the resources of the bridge, the NQNs of the subsystems and the queues of
+// the controllers
+func (b *testBridge) state() map[string]string {
+	state := make(map[string]string)
+	for name, resource := range b.resources {
+		switch r := resource.(type) {
+		case *pb.NvmeSubsystem:
+			state[name] = r.GetSpec().GetNqn()
+		case *pb.NvmeController:
+			state[name] = r.GetSpec().String()
+		}
+	}
+	return state
+}
+
+func TestApply_ApplyConfig(t *testing.T) {
+	declared := []*Resource{
+		{Type: "opi_nvme_subsystem", ResourceID: "subsys0", Resource: json.RawMessage(`{"spec":{"nqn":"nqn.2022-09.io.spdk:opi2"}}`)},
+		{Type: "opi_nvme_subsystem", ResourceID: "subsys2", Resource: json.RawMessage(`{"name":"ignored","spec":{"nqn":"nqn.2022-09.io.spdk:opi3"}}`)},
+		{Type: "opi_nvme_controller", ResourceID: "ctrl1", Parent: "//storage.opiproject.org/subsystems/subsys2", Resource: json.RawMessage(`{"spec":{"maxNsq":8}}`)},
+	}
+	initial := newTestBridge().state()
+	tests := map[string]struct {
+		in      *ApplyConfigRequest
+		steps   []string
+		state   map[string]string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"created updated and kept": {
+			in: &ApplyConfigRequest{Resources: declared},
+			steps: []string{
+				"UpdateNvmeSubsystem subsys0",
+				"CreateNvmeSubsystem subsys2 mrvl_nvm_subsys_create",
+				"CreateNvmeController ctrl1 mrvl_nvm_ctrlr_create",
+			},
+			state: map[string]string{
+				"//storage.opiproject.org/subsystems/subsys0":                   "nqn.2022-09.io.spdk:opi2",
+				"//storage.opiproject.org/subsystems/subsys1":                   "nqn.2022-09.io.spdk:opi1",
+				"//storage.opiproject.org/subsystems/subsys2":                   "nqn.2022-09.io.spdk:opi3",
+				"//storage.opiproject.org/subsystems/subsys0/controllers/ctrl0": "max_nsq:4",
+				"//storage.opiproject.org/subsystems/subsys2/controllers/ctrl1": "max_nsq:8",
+			},
+		},
+		"pruned children first": {
+			in: &ApplyConfigRequest{Resources: declared, Prune: true},
+			steps: []string{
+				"DeleteNvmeController ctrl0 mrvl_nvm_ctrlr_delete",
+				"DeleteNvmeSubsystem subsys1 mrvl_nvm_subsys_delete",
+				"UpdateNvmeSubsystem subsys0",
+				"CreateNvmeSubsystem subsys2 mrvl_nvm_subsys_create",
+				"CreateNvmeController ctrl1 mrvl_nvm_ctrlr_create",
+			},
+			state: map[string]string{
+				"//storage.opiproject.org/subsystems/subsys0":                   "nqn.2022-09.io.spdk:opi2",
+				"//storage.opiproject.org/subsystems/subsys2":                   "nqn.2022-09.io.spdk:opi3",
+				"//storage.opiproject.org/subsystems/subsys2/controllers/ctrl1": "max_nsq:8",
+			},
+		},
+		"unchanged": {
+			in: &ApplyConfigRequest{Resources: []*Resource{
+				{Type: "opi_nvme_subsystem", ResourceID: "subsys0", Resource: json.RawMessage(`{"spec":{"nqn":"nqn.2022-09.io.spdk:opi0"}}`)},
+			}},
+			steps: []string{},
+			state: initial,
+		},
+		"validate only": {
+			in: &ApplyConfigRequest{Resources: declared, Prune: true, ValidateOnly: true},
+			steps: []string{
+				"DeleteNvmeController ctrl0",
+				"DeleteNvmeSubsystem subsys1",
+				"UpdateNvmeSubsystem subsys0",
+				"CreateNvmeSubsystem subsys2",
+				"CreateNvmeController ctrl1",
+			},
+			state: initial,
+		},
+		"rolled back": {
+			in: &ApplyConfigRequest{Resources: append(declared[:len(declared):len(declared)],
+				&Resource{Type: "opi_nvme_controller", ResourceID: "fail", Parent: "//storage.opiproject.org/subsystems/subsys2", Resource: json.RawMessage(`{}`)},
+			), Prune: true},
+			state:   initial,
+			errCode: codes.FailedPrecondition,
+			errMsg:  "CreateNvmeController of fail failed: could not create fail, the 5 executed steps were rolled back",
+		},
+		"unknown type": {
+			in:      &ApplyConfigRequest{Resources: []*Resource{{Type: "opi_virtio_blk", ResourceID: "blk0"}}},
+			state:   initial,
+			errCode: codes.InvalidArgument,
+			errMsg:  `unknown resource type "opi_virtio_blk"`,
+		},
+		"missing ID": {
+			in:      &ApplyConfigRequest{Resources: []*Resource{{Type: "opi_nvme_subsystem", Resource: json.RawMessage(`{}`)}}},
+			state:   initial,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: resources[0].resource_id",
+		},
+		"invalid resource": {
+			in:      &ApplyConfigRequest{Resources: []*Resource{{Type: "opi_nvme_subsystem", ResourceID: "subsys0", Resource: json.RawMessage(`{"spec":{"nqn":1}}`)}}},
+			state:   initial,
+			errCode: codes.InvalidArgument,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bridge := newTestBridge()
+			server := NewServer(bridge.snapshot, bridge.kinds())
+
+			response, err := server.ApplyConfig(context.Background(), tt.in)
+			if er, _ := status.FromError(err); er.Code() != tt.errCode {
+				t.Fatal("error code: expected", tt.errCode, "received", er.Code(), err)
+			}
+			if tt.errMsg != "" && !strings.HasPrefix(status.Convert(err).Message(), tt.errMsg) {
+				t.Error("error message: expected", tt.errMsg, "received", status.Convert(err).Message())
+			}
+			if err == nil {
+				steps := []string{}
+				for _, step := range response.Steps {
+					steps = append(steps, strings.TrimSpace(step.Method+" "+step.ResourceID+" "+strings.Join(step.FirmwareCalls, " ")))
+				}
+				if !reflect.DeepEqual(steps, tt.steps) {
+					t.Error("steps: expected", tt.steps, "received", steps)
+				}
+			}
+			if state := bridge.state(); !reflect.DeepEqual(state, tt.state) {
+				t.Error("state: expected", tt.state, "received", state)
+			}
+		})
+	}
+}
+
+func TestApply_ApplyConfigErrorDetails(t *testing.T) {
+	bridge := newTestBridge()
+	kinds := bridge.kinds()
+	// the rollback of the creation of the subsystem fails
+	remove := kinds[0].Delete
+	kinds[0].Delete = func(ctx context.Context, name string) error {
+		if strings.HasSuffix(name, "subsys2") {
+			return errors.New("subsystem in use")
+		}
+		return remove(ctx, name)
+	}
+	server := NewServer(bridge.snapshot, kinds)
+
+	_, err := server.ApplyConfig(context.Background(), &ApplyConfigRequest{Resources: []*Resource{
+		{Type: "opi_nvme_subsystem", ResourceID: "subsys2", Resource: json.RawMessage(`{"spec":{"nqn":"nqn.2022-09.io.spdk:opi3"}}`)},
+		{Type: "opi_nvme_controller", ResourceID: "fail", Parent: "//storage.opiproject.org/subsystems/subsys2", Resource: json.RawMessage(`{}`)},
+	}})
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatal("error code: expected", codes.FailedPrecondition, "received", st.Code())
+	}
+	var infos []*errdetails.ErrorInfo
+	var resource *errdetails.ResourceInfo
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			infos = append(infos, d)
+		case *errdetails.ResourceInfo:
+			resource = d
+		}
+	}
+	if len(infos) != 2 {
+		t.Fatal("error infos: expected the one of the config and the one of the firmware, received", infos)
+	}
+	expected := map[string]string{
+		"method":            "CreateNvmeController",
+		"type":              "opi_nvme_controller",
+		"resource":          "fail",
+		"parent":            "//storage.opiproject.org/subsystems/subsys2",
+		"step":              "2",
+		"rolled_back":       "0",
+		"firmware_method":   "mrvl_nvm_ctrlr_create",
+		"rollback_failures": "DeleteNvmeSubsystem of //storage.opiproject.org/subsystems/subsys2: subsystem in use",
+	}
+	if infos[0].Reason != ReasonRollbackFailed || infos[0].Domain != ErrorDomain || !reflect.DeepEqual(infos[0].Metadata, expected) {
+		t.Error("error info: expected", ReasonRollbackFailed, expected, "received", infos[0])
+	}
+	if infos[1].Reason != "CTRLR_NO_DEVICE" || infos[1].Domain != models.StatusDomain {
+		t.Error("firmware error info: expected", "CTRLR_NO_DEVICE", "received", infos[1])
+	}
+	if resource.GetResourceType() != "opi_nvme_controller" || resource.GetResourceName() != "fail" {
+		t.Error("resource info: expected the controller, received", resource)
+	}
+}
+
+func TestApply_ApplyConfigUnrecoverable(t *testing.T) {
+	bridge := newTestBridge()
+	kinds := bridge.kinds()
+	// the deleted subsystems can't be created again, as the encrypted volumes without their key
+	kinds[0].Unrecoverable = true
+	server := NewServer(bridge.snapshot, kinds)
+	initial := bridge.state()
+
+	_, err := server.ApplyConfig(context.Background(), &ApplyConfigRequest{Resources: []*Resource{
+		{Type: "opi_nvme_subsystem", ResourceID: "subsys0", Resource: json.RawMessage(`{"spec":{"nqn":"nqn.2022-09.io.spdk:opi2"}}`)},
+	}, Prune: true})
+	er, _ := status.FromError(err)
+	if er.Code() != codes.FailedPrecondition {
+		t.Error("error code: expected", codes.FailedPrecondition, "received", er.Code())
+	}
+	expected := "opi_nvme_subsystem //storage.opiproject.org/subsystems/subsys1 can't be pruned, it can't be created again when the config is rolled back, delete it first"
+	if er.Message() != expected {
+		t.Error("error message: expected", expected, "received", er.Message())
+	}
+	if state := bridge.state(); !reflect.DeepEqual(state, initial) {
+		t.Error("state: expected", initial, "received", state)
+	}
+
+	// the resources which aren't pruned are applied
+	if _, err := server.ApplyConfig(context.Background(), &ApplyConfigRequest{Resources: []*Resource{
+		{Type: "opi_nvme_subsystem", ResourceID: "subsys0", Resource: json.RawMessage(`{"spec":{"nqn":"nqn.2022-09.io.spdk:opi2"}}`)},
+	}}); err != nil {
+		t.Error("unexpected error", err)
+	}
+}
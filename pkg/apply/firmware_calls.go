@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apply applies a declarative config of the resources of the bridge atomically, the
+// declared resources missing are created, the changed ones updated and the undeclared ones
+// pruned, and when a step fails the executed ones are undone, last first, so the bridge is back
+// to the snapshot it was in before the config
+package apply
+
+import (
+	"context"
+	"sync"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys of the firmware calls of the steps made through a gRPC server, the client asks
+// for them and the server sends them back in the trailer of the call
+const (
+	// firmwareCallsMetadataKey asks for the firmware calls, and carries the methods called
+	firmwareCallsMetadataKey = "opi-firmware-calls"
+	// firmwareFailedMetadataKey carries the last method which failed
+	firmwareFailedMetadataKey = "opi-firmware-failed"
+)
+
+// firmwareCallsKey is the context key of the firmware calls of a step
+type firmwareCallsKey struct{}
+
+// firmwareCalls collects the firmware calls of a step
+type firmwareCalls struct {
+	mu      sync.Mutex
+	methods []string
+	// failed is the last method which failed
+	failed string
+}
+
+// withFirmwareCalls collects the firmware calls made with the returned context in calls
+func withFirmwareCalls(ctx context.Context, calls *firmwareCalls) context.Context {
+	return context.WithValue(ctx, firmwareCallsKey{}, calls)
+}
+
+// list returns the methods called and the last one which failed
+func (c *firmwareCalls) list() ([]string, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.methods, c.failed
+}
+
+// WrapJSONRPC tells the steps of the configs the firmware calls they made, either answered or
+// failed
+func WrapJSONRPC(rpc spdk.JSONRPC) spdk.JSONRPC {
+	return &journaledJSONRPC{JSONRPC: rpc}
+}
+
+// journaledJSONRPC is a JSON-RPC client noting the calls to the firmware of the steps
+type journaledJSONRPC struct {
+	spdk.JSONRPC
+}
+
+// Call implements spdk.JSONRPC
+func (c *journaledJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	calls, ok := ctx.Value(firmwareCallsKey{}).(*firmwareCalls)
+	if !ok {
+		return err
+	}
+	calls.mu.Lock()
+	defer calls.mu.Unlock()
+	calls.methods = append(calls.methods, method)
+	if err != nil || models.ResultStatus(result) != models.StatusSuccess {
+		calls.failed = method
+	}
+	return err
+}
+
+// add notes the firmware calls of a step made through a gRPC server
+func (c *firmwareCalls) add(methods []string, failed string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.methods = append(c.methods, methods...)
+	if failed != "" {
+		c.failed = failed
+	}
+}
+
+// UnaryClientInterceptor asks the gRPC server the steps are made through for their firmware
+// calls, the server has to run UnaryServerInterceptor
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		calls, ok := ctx.Value(firmwareCallsKey{}).(*firmwareCalls)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		var trailer metadata.MD
+		ctx = metadata.AppendToOutgoingContext(ctx, firmwareCallsMetadataKey, "true")
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+		failed := ""
+		if values := trailer.Get(firmwareFailedMetadataKey); len(values) != 0 {
+			failed = values[0]
+		}
+		calls.add(trailer.Get(firmwareCallsMetadataKey), failed)
+		return err
+	}
+}
+
+// UnaryServerInterceptor sends the firmware calls of the calls asking for them back in their
+// trailer, failed or not
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok || len(md.Get(firmwareCallsMetadataKey)) == 0 {
+			return handler(ctx, req)
+		}
+		calls := new(firmwareCalls)
+		resp, err := handler(withFirmwareCalls(ctx, calls), req)
+		methods, failed := calls.list()
+		trailer := metadata.MD{}
+		if len(methods) != 0 {
+			trailer.Set(firmwareCallsMetadataKey, methods...)
+		}
+		if failed != "" {
+			trailer.Set(firmwareFailedMetadataKey, failed)
+		}
+		_ = grpc.SetTrailer(ctx, trailer)
+		return resp, err
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apply applies a declarative config of the resources of the bridge atomically, the
+// declared resources missing are created, the changed ones updated and the undeclared ones
+// pruned, and when a step fails the executed ones are undone, last first, so the bridge is back
+// to the snapshot it was in before the config
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testFrontend serves the subsystems and the controllers of a testBridge
+type testFrontend struct {
+	pb.UnimplementedFrontendNvmeServiceServer
+	kinds []Kind
+}
+
+func (s *testFrontend) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsystemRequest) (*pb.NvmeSubsystem, error) {
+	subsystem, err := s.kinds[0].Create(ctx, "", in.NvmeSubsystemId, in.NvmeSubsystem)
+	if err != nil {
+		return nil, err
+	}
+	return subsystem.(*pb.NvmeSubsystem), nil
+}
+
+func (s *testFrontend) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsystemRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, s.kinds[0].Delete(ctx, in.Name)
+}
+
+func (s *testFrontend) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeControllerRequest) (*pb.NvmeController, error) {
+	controller, err := s.kinds[1].Create(ctx, in.Parent, in.NvmeControllerId, in.NvmeController)
+	if err != nil {
+		return nil, err
+	}
+	return controller.(*pb.NvmeController), nil
+}
+
+// newTestKinds serves the kinds of a testBridge on a gRPC server and returns the kinds calling it
+func newTestKinds(t *testing.T, bridge *testBridge) []Kind {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(UnaryServerInterceptor()))
+	pb.RegisterFrontendNvmeServiceServer(server, &testFrontend{kinds: bridge.kinds()})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	client := pb.NewFrontendNvmeServiceClient(conn)
+	return []Kind{
+		NewKind("opi_nvme_subsystem", "NvmeSubsystem",
+			func(ctx context.Context, _ string, id string, subsystem *pb.NvmeSubsystem) (*pb.NvmeSubsystem, error) {
+				return client.CreateNvmeSubsystem(ctx, &pb.CreateNvmeSubsystemRequest{NvmeSubsystem: subsystem, NvmeSubsystemId: id})
+			},
+			nil,
+			func(ctx context.Context, name string) error {
+				_, err := client.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: name})
+				return err
+			}),
+		NewKind("opi_nvme_controller", "NvmeController",
+			func(ctx context.Context, parent string, id string, controller *pb.NvmeController) (*pb.NvmeController, error) {
+				return client.CreateNvmeController(ctx, &pb.CreateNvmeControllerRequest{Parent: parent, NvmeController: controller, NvmeControllerId: id})
+			},
+			nil, nil),
+	}
+}
+
+func TestApply_FirmwareCallsThroughServer(t *testing.T) {
+	tests := map[string]struct {
+		in             []*Resource
+		steps          []string
+		errCode        codes.Code
+		firmwareMethod string
+	}{
+		"created": {
+			in: []*Resource{
+				{Type: "opi_nvme_subsystem", ResourceID: "subsys2", Resource: json.RawMessage(`{"spec":{"nqn":"nqn.2022-09.io.spdk:opi3"}}`)},
+				{Type: "opi_nvme_controller", ResourceID: "ctrl1", Parent: "//storage.opiproject.org/subsystems/subsys2", Resource: json.RawMessage(`{}`)},
+			},
+			steps: []string{
+				"CreateNvmeSubsystem subsys2 mrvl_nvm_subsys_create",
+				"CreateNvmeController ctrl1 mrvl_nvm_ctrlr_create",
+			},
+			errCode: codes.OK,
+		},
+		"rolled back": {
+			in: []*Resource{
+				{Type: "opi_nvme_subsystem", ResourceID: "subsys2", Resource: json.RawMessage(`{"spec":{"nqn":"nqn.2022-09.io.spdk:opi3"}}`)},
+				{Type: "opi_nvme_controller", ResourceID: "fail", Parent: "//storage.opiproject.org/subsystems/subsys2", Resource: json.RawMessage(`{}`)},
+			},
+			errCode:        codes.FailedPrecondition,
+			firmwareMethod: "mrvl_nvm_ctrlr_create",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bridge := newTestBridge()
+			initial := bridge.state()
+			server := NewServer(bridge.snapshot, newTestKinds(t, bridge))
+
+			response, err := server.ApplyConfig(context.Background(), &ApplyConfigRequest{Resources: tt.in})
+
+			st := status.Convert(err)
+			if st.Code() != tt.errCode {
+				t.Fatal("error code: expected", tt.errCode, "received", st.Code(), err)
+			}
+			if err == nil {
+				var steps []string
+				for _, step := range response.Steps {
+					steps = append(steps, strings.TrimSpace(step.Method+" "+step.ResourceID+" "+strings.Join(step.FirmwareCalls, " ")))
+				}
+				if !reflect.DeepEqual(steps, tt.steps) {
+					t.Error("steps: expected", tt.steps, "received", steps)
+				}
+				return
+			}
+			firmwareMethod := ""
+			for _, detail := range st.Details() {
+				if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == ErrorDomain {
+					firmwareMethod = info.Metadata["firmware_method"]
+				}
+			}
+			if firmwareMethod != tt.firmwareMethod {
+				t.Error("firmware method: expected", tt.firmwareMethod, "received", firmwareMethod)
+			}
+			if state := bridge.state(); !reflect.DeepEqual(state, initial) {
+				t.Error("state: expected", initial, "received", state)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apply applies a declarative config of the resources of the bridge atomically, the
+// declared resources missing are created, the changed ones updated and the undeclared ones
+// pruned, and when a step fails the executed ones are undone, last first, so the bridge is back
+// to the snapshot it was in before the config
+package apply
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Verifier checks the configs are signed by one of the pipelines allowed to apply them, their
+// signature is detached, i.e. for config.json:
+//
+//	openssl dgst -sha256 -sign pipeline.key config.json | base64 -w0
+//
+// The Ed25519 keys sign the config itself, the ECDSA and RSA PKCS #1 v1.5 keys its SHA-256
+type Verifier struct {
+	keys []crypto.PublicKey
+}
+
+// LoadVerifier returns a Verifier of the signatures of the public keys of a PEM file
+func LoadVerifier(file string) (*Verifier, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	verifier := &Verifier{}
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key %s: %v", file, err)
+		}
+		switch key.(type) {
+		case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+			verifier.keys = append(verifier.keys, key)
+		default:
+			return nil, fmt.Errorf("invalid signing key %s, have to be Ed25519, ECDSA or RSA", file)
+		}
+	}
+	if len(verifier.keys) == 0 {
+		return nil, fmt.Errorf("invalid signing key %s, have to be PEM public keys", file)
+	}
+	return verifier, nil
+}
+
+// Verify checks the signature of data was made by one of the keys
+func (v *Verifier) Verify(data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+	for _, key := range v.keys {
+		switch key := key.(type) {
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, data, signature) {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, digest[:], signature) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		}
+	}
+	return errors.New("signed by none of the signing keys")
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package apply applies a declarative config of the resources of the bridge atomically, the
+// declared resources missing are created, the changed ones updated and the undeclared ones
+// pruned, and when a step fails the executed ones are undone, last first, so the bridge is back
+// to the snapshot it was in before the config
+package apply
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// writeTestSigningKeys writes the public keys of signers as a PEM file
+func writeTestSigningKeys(t *testing.T, signers ...crypto.Signer) string {
+	var data []byte
+	for _, signer := range signers {
+		der, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+	}
+	file := filepath.Join(t.TempDir(), "pipelines.pem")
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// testSignature returns the detached signature of a config by signer
+func testSignature(t *testing.T, config []byte, signer crypto.Signer) []byte {
+	var signature []byte
+	var err error
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		signature, err = signer.Sign(rand.Reader, config, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(config)
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+func TestApply_ApplyConfigSigned(t *testing.T) {
+	pipeline, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, unknown, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := LoadVerifier(writeTestSigningKeys(t, pipeline, other))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := []*Resource{
+		{Type: "opi_nvme_subsystem", ResourceID: "subsys2", Resource: json.RawMessage(`{"spec":{"nqn":"nqn.2022-09.io.spdk:opi3"}}`)},
+	}
+	config, err := json.Marshal(&Config{Resources: resources})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := json.Marshal(&Config{Resources: resources, Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := newTestBridge().state()
+	applied := newTestBridge().state()
+	applied["//storage.opiproject.org/subsystems/subsys2"] = "nqn.2022-09.io.spdk:opi3"
+	tests := map[string]struct {
+		verifier *Verifier
+		in       *ApplyConfigRequest
+		state    map[string]string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"signed by ECDSA key": {
+			verifier: verifier,
+			in:       &ApplyConfigRequest{Config: config, Signature: testSignature(t, config, pipeline)},
+			state:    applied,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"signed by Ed25519 key": {
+			verifier: verifier,
+			in:       &ApplyConfigRequest{Config: config, Signature: testSignature(t, config, other)},
+			state:    applied,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"unsigned resources": {
+			verifier: verifier,
+			in:       &ApplyConfigRequest{Resources: resources},
+			state:    initial,
+			errCode:  codes.PermissionDenied,
+			errMsg:   "Unsigned config, the resources have to be set in a signed config",
+		},
+		"unsigned config": {
+			verifier: verifier,
+			in:       &ApplyConfigRequest{Config: config},
+			state:    initial,
+			errCode:  codes.PermissionDenied,
+			errMsg:   "Invalid signature of the config: signed by none of the signing keys",
+		},
+		"signed by unknown key": {
+			verifier: verifier,
+			in:       &ApplyConfigRequest{Config: config, Signature: testSignature(t, config, unknown)},
+			state:    initial,
+			errCode:  codes.PermissionDenied,
+			errMsg:   "Invalid signature of the config: signed by none of the signing keys",
+		},
+		"tampered": {
+			verifier: verifier,
+			in:       &ApplyConfigRequest{Config: tampered, Signature: testSignature(t, config, pipeline)},
+			state:    initial,
+			errCode:  codes.PermissionDenied,
+			errMsg:   "Invalid signature of the config: signed by none of the signing keys",
+		},
+		"signed config along with prune": {
+			verifier: verifier,
+			in:       &ApplyConfigRequest{Config: config, Signature: testSignature(t, config, pipeline), Prune: true},
+			state:    initial,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "Config set along with resources or prune, have to be set in the config only",
+		},
+		"config without verifier": {
+			verifier: nil,
+			in:       &ApplyConfigRequest{Config: config},
+			state:    applied,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bridge := newTestBridge()
+			server := NewServer(bridge.snapshot, bridge.kinds())
+			server.SetVerifier(tt.verifier)
+
+			_, err := server.ApplyConfig(context.Background(), tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if state := bridge.state(); !reflect.DeepEqual(state, tt.state) {
+				t.Error("state: expected", tt.state, "received", state)
+			}
+		})
+	}
+}
+
+func TestApply_LoadVerifier(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pipelines.pem")
+	if err := os.WriteFile(file, []byte("not a key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadVerifier(file)
+	expected := "invalid signing key " + file + ", have to be PEM public keys"
+	if err == nil || err.Error() != expected {
+		t.Error("error: expected", expected, "received", err)
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package audit records the calls changing the resources of the bridge, who made them, when,
+// on what and with which outcome, to an append-only sink
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+	"github.com/opiproject/opi-marvell-bridge/pkg/pagination"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Entry represents a call changing the resources of the bridge
+type Entry struct {
+	// Time of the call, UTC
+	Time time.Time `json:"time"`
+	// RequestID is the ID of the request, as in the logs
+	RequestID string `json:"requestId"`
+	// Identity of the caller, empty when the bridge has no authorization policy
+	Identity string `json:"identity"`
+	// Tenant of the call, empty for the administrator
+	Tenant string `json:"tenant"`
+	// Method called, i.e. opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem
+	Method string `json:"method"`
+	// Resource is the name of the resource called, its parent for the creations
+	Resource string `json:"resource"`
+	// Digest is the SHA-256 of the request, it proves what was asked without keeping the secrets
+	Digest string `json:"digest"`
+	// Code is the gRPC status code of the outcome, OK on success
+	Code string `json:"code"`
+	// Message is the error message of a failed call
+	Message string `json:"message,omitempty"`
+}
+
+// Sink keeps the entries, it only appends them. Other sinks (i.e. a SIEM) are plugged in by
+// implementing it
+type Sink interface {
+	// Append adds an entry after the previous ones
+	Append(entry *Entry) error
+}
+
+// Reader is a sink whose entries can be read back, they are listed by the audit API
+type Reader interface {
+	// Read returns the entries in the order they were appended
+	Read() ([]*Entry, error)
+}
+
+// ListAuditEntriesRequest represents a request to list the audit entries, oldest first
+type ListAuditEntriesRequest struct {
+	// Identity only lists the calls of an identity when set
+	Identity string `json:"identity"`
+	// Resource only lists the calls on a resource when set
+	Resource string `json:"resource"`
+	// PageSize is the maximum number of entries returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListAuditEntriesResponse represents a list of audit entries
+type ListAuditEntriesResponse struct {
+	// Entries is the page of entries
+	Entries []*Entry `json:"entries"`
+	// NextPageToken is set when more entries are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// Log records the calls changing the resources to a sink
+type Log struct {
+	mu         sync.Mutex
+	sink       Sink
+	Pagination *pagination.Paginator
+}
+
+// New creates a log appending to a sink, nil disables the audit
+func New(sink Sink) *Log {
+	return &Log{
+		sink:       sink,
+		Pagination: pagination.New(),
+	}
+}
+
+// Record appends the entry of a call, identified by its method, on a resource with a request,
+// err is its outcome. A sink failure doesn't fail the call which is already done, it is logged
+func (l *Log) Record(ctx context.Context, identity string, tenantID string, method string, resource string, req interface{}, err error) {
+	if l.sink == nil {
+		return
+	}
+	entry := &Entry{
+		Time:      time.Now().UTC(),
+		RequestID: requestid.FromContext(ctx),
+		Identity:  identity,
+		Tenant:    tenantID,
+		Method:    method,
+		Resource:  resource,
+		Digest:    Digest(req),
+		Code:      status.Code(err).String(),
+	}
+	if err != nil {
+		entry.Message = status.Convert(err).Message()
+	}
+	if err := l.sink.Append(entry); err != nil {
+		slog.ErrorContext(ctx, "Could not append audit entry", "method", method, "error", err)
+	}
+}
+
+// Digest returns the SHA-256 of the JSON encoding of a request, the JSON requests of the custom
+// methods are hashed as is
+func Digest(req interface{}) string {
+	data, ok := req.([]byte)
+	if !ok {
+		data, _ = json.Marshal(req)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ListAuditEntries lists the audit entries, it needs a sink which can be read back
+func (l *Log) ListAuditEntries(_ context.Context, in *ListAuditEntriesRequest) (*ListAuditEntriesResponse, error) {
+	reader, ok := l.sink.(Reader)
+	if !ok {
+		msg := "Audit entries can only be listed from an audit file"
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	size, offset, perr := l.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	entries, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	Blobarray := make([]*Entry, 0, len(entries))
+	for _, entry := range entries {
+		if (in.Identity != "" && entry.Identity != in.Identity) || (in.Resource != "" && entry.Resource != in.Resource) {
+			continue
+		}
+		Blobarray = append(Blobarray, entry)
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(Blobarray), "offset", offset, "size", size)
+	Blobarray, hasMoreElements = utils.LimitPagination(Blobarray, offset, size)
+	if hasMoreElements {
+		token = l.Pagination.NewToken(offset + size)
+	}
+	return &ListAuditEntriesResponse{Entries: Blobarray, NextPageToken: token}, nil
+}
+
+// readOnlyPrefixes are the methods which don't change the resources, they are not audited
+var readOnlyPrefixes = []string{"Get", "List", "Stats"}
+
+// IsMutating tells whether a method, i.e. opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem,
+// can change the resources
+func IsMutating(method string) bool {
+	name := method[strings.LastIndex(method, "/")+1:]
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// UnaryServerInterceptor records the gRPC calls changing the resources, it comes before the
+// authorization so the denied calls are recorded too
+func UnaryServerInterceptor(l *Log) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method := strings.TrimPrefix(info.FullMethod, "/")
+		if l.sink == nil || !IsMutating(method) {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+		// an invalid tenant fails the call, it is recorded without tenant
+		tenantID, _ := tenant.FromContext(ctx)
+		l.Record(ctx, authz.IdentityFromContext(ctx), tenantID, method, resourceName(req), req, err)
+		return resp, err
+	}
+}
+
+// resourceName returns the name of the resource of a request, its parent for the creations
+func resourceName(req interface{}) string {
+	if r, ok := req.(interface{ GetName() string }); ok && r.GetName() != "" {
+		return r.GetName()
+	}
+	if r, ok := req.(interface{ GetParent() string }); ok {
+		return r.GetParent()
+	}
+	return ""
+}
@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package audit records the calls changing the resources of the bridge, who made them, when,
+// on what and with which outcome, to an append-only sink
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testSink keeps the entries in memory
+type testSink struct {
+	entries []*Entry
+	err     error
+}
+
+func (s *testSink) Append(entry *Entry) error {
+	s.entries = append(s.entries, entry)
+	return s.err
+}
+
+func TestAudit_IsMutating(t *testing.T) {
+	tests := map[string]bool{
+		"opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem":               true,
+		"opi_api.storage.v1.FrontendNvmeService/DeleteNvmeController":              true,
+		"opi_api.storage.v1.NvmeRemoteControllerService/NvmeRemoteControllerReset": true,
+		"opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem":                  false,
+		"opi_api.storage.v1.FrontendNvmeService/ListNvmeNamespaces":                false,
+		"opi_api.storage.v1.FrontendNvmeService/StatsNvmeController":               false,
+		"marvell.backend/UpdateNvmeRemoteControllerQos":                            true,
+	}
+
+	// run tests
+	for method, mutating := range tests {
+		t.Run(method, func(t *testing.T) {
+			if IsMutating(method) != mutating {
+				t.Error("mutating: expected", mutating, "received", !mutating)
+			}
+		})
+	}
+}
+
+func TestAudit_UnaryServerInterceptor(t *testing.T) {
+	testSubsystemName := "//storage.opiproject.org/nvmeSubsystems/subsys0"
+	tests := map[string]struct {
+		method  string
+		req     interface{}
+		err     error
+		entries int
+		code    string
+		message string
+	}{
+		"successful creation": {
+			method:  "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeController",
+			req:     &pb.CreateNvmeControllerRequest{Parent: testSubsystemName, NvmeControllerId: "ctrl0"},
+			err:     nil,
+			entries: 1,
+			code:    "OK",
+			message: "",
+		},
+		"failed deletion": {
+			method:  "/opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem",
+			req:     &pb.DeleteNvmeSubsystemRequest{Name: testSubsystemName},
+			err:     status.Error(codes.NotFound, "unable to find key "+testSubsystemName),
+			entries: 1,
+			code:    "NotFound",
+			message: "unable to find key " + testSubsystemName,
+		},
+		"read not audited": {
+			method:  "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem",
+			req:     &pb.GetNvmeSubsystemRequest{Name: testSubsystemName},
+			err:     nil,
+			entries: 0,
+			code:    "",
+			message: "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sink := &testSink{}
+			// the caller is authenticated, i.e. with an API key
+			md := metadata.Pairs(tenant.MetadataKey, "acme")
+			ctx := requestid.NewContext(authz.NewContext(metadata.NewIncomingContext(context.Background(), md), "admin"), "c0ffee")
+			handler := func(_ context.Context, req interface{}) (interface{}, error) {
+				return req, tt.err
+			}
+			_, err := UnaryServerInterceptor(New(sink))(ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if !errors.Is(err, tt.err) {
+				t.Error("error: expected", tt.err, "received", err)
+			}
+
+			if len(sink.entries) != tt.entries {
+				t.Fatal("entries: expected", tt.entries, "received", len(sink.entries))
+			}
+			if tt.entries == 0 {
+				return
+			}
+			entry := sink.entries[0]
+			expected := &Entry{
+				Time:      entry.Time,
+				RequestID: "c0ffee",
+				Identity:  "admin",
+				Tenant:    "acme",
+				Method:    tt.method[1:],
+				Resource:  resourceName(tt.req),
+				Digest:    Digest(tt.req),
+				Code:      tt.code,
+				Message:   tt.message,
+			}
+			if !reflect.DeepEqual(entry, expected) {
+				t.Error("entry: expected", expected, "received", entry)
+			}
+			if entry.Time.IsZero() || len(entry.Digest) != 64 {
+				t.Error("entry: expected a time and a digest, received", entry)
+			}
+		})
+	}
+}
+
+func TestAudit_Disabled(t *testing.T) {
+	called := false
+	handler := func(_ context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return req, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem"}
+	if _, err := UnaryServerInterceptor(New(nil))(context.Background(), &pb.CreateNvmeSubsystemRequest{}, info, handler); err != nil || !called {
+		t.Error("expected the call to go through, received", err)
+	}
+	_, err := New(nil).ListAuditEntries(context.Background(), &ListAuditEntriesRequest{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Error("error code: expected", codes.FailedPrecondition, "received", status.Code(err))
+	}
+}
+
+func TestAudit_ListAuditEntries(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sink.Close() }()
+	l := New(sink)
+	for _, identity := range []string{"admin", "operator", "admin"} {
+		l.Record(context.Background(), identity, "", "marvell.frontend/CreateQuota", "//storage.opiproject.org/", []byte(`{}`), nil)
+	}
+
+	tests := map[string]struct {
+		in         *ListAuditEntriesRequest
+		identities []string
+		hasMore    bool
+		errCode    codes.Code
+	}{
+		"all entries": {
+			in:         &ListAuditEntriesRequest{},
+			identities: []string{"admin", "operator", "admin"},
+			hasMore:    false,
+			errCode:    codes.OK,
+		},
+		"entries of an identity": {
+			in:         &ListAuditEntriesRequest{Identity: "admin"},
+			identities: []string{"admin", "admin"},
+			hasMore:    false,
+			errCode:    codes.OK,
+		},
+		"pagination": {
+			in:         &ListAuditEntriesRequest{PageSize: 2},
+			identities: []string{"admin", "operator"},
+			hasMore:    true,
+			errCode:    codes.OK,
+		},
+		"pagination negative": {
+			in:         &ListAuditEntriesRequest{PageSize: -10},
+			identities: nil,
+			hasMore:    false,
+			errCode:    codes.InvalidArgument,
+		},
+		"pagination error": {
+			in:         &ListAuditEntriesRequest{PageToken: "unknown-pagination-token"},
+			identities: nil,
+			hasMore:    false,
+			errCode:    codes.NotFound,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			response, err := l.ListAuditEntries(context.Background(), tt.in)
+			if status.Code(err) != tt.errCode {
+				t.Fatal("error code: expected", tt.errCode, "received", status.Code(err), err)
+			}
+			if err != nil {
+				return
+			}
+			identities := make([]string, 0, len(response.Entries))
+			for _, entry := range response.Entries {
+				identities = append(identities, entry.Identity)
+			}
+			if !reflect.DeepEqual(identities, tt.identities) {
+				t.Error("identities: expected", tt.identities, "received", identities)
+			}
+			if (response.NextPageToken != "") != tt.hasMore {
+				t.Error("next page token: expected", tt.hasMore, "received", response.NextPageToken)
+			}
+		})
+	}
+}
+
+func TestAudit_HTTPSink(t *testing.T) {
+	tests := map[string]struct {
+		status int
+		err    bool
+	}{
+		"accepted entry": {
+			status: http.StatusNoContent,
+			err:    false,
+		},
+		"refused entry": {
+			status: http.StatusServiceUnavailable,
+			err:    true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var received Entry
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					t.Error(err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			entry := &Entry{Identity: "admin", Method: "marvell.frontend/CreateQuota", Code: "OK"}
+			err := NewHTTPSink(server.URL, server.Client()).Append(entry)
+			if (err != nil) != tt.err {
+				t.Error("error: expected", tt.err, "received", err)
+			}
+			if !reflect.DeepEqual(&received, entry) {
+				t.Error("entry: expected", entry, "received", received)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package audit records the calls changing the resources of the bridge, who made them, when,
+// on what and with which outcome, to an append-only sink
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// FileSink appends the entries to a file, one JSON object per line, the file is only ever
+// appended to by the bridge
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// build time check that struct implements interface
+var _ Reader = (*FileSink)(nil)
+
+// NewFileSink opens the audit file at path, it is created when missing
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{path: path, file: file}, nil
+}
+
+// Append implements Sink, the entry is synced to the disk before returning
+func (f *FileSink) Append(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+// Read implements Reader
+func (f *FileSink) Read() ([]*Entry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := new(Entry)
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry %d in %s: %w", len(entries)+1, f.path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Close closes the audit file
+func (f *FileSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// HTTPSink posts the entries to a remote audit service, i.e. a log collector, one JSON object
+// per request
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink creates initialized instance of HTTP audit sink
+func NewHTTPSink(url string, client *http.Client) *HTTPSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPSink{
+		url:    url,
+		client: client,
+	}
+}
+
+// Append implements Sink
+func (h *HTTPSink) Append(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, h.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit service answered %s", resp.Status)
+	}
+	return nil
+}
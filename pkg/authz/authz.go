@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package authz restricts the methods the identities calling the bridge can use, per tenant
+package authz
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/oidc"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the gRPC metadata carrying the identity of a client of the HTTP gateway, only
+// trusted on the connections of the bridge to itself
+const MetadataKey = "opi-identity"
+
+// spiffeScheme is the scheme of the SPIFFE IDs in the URI SANs of the X.509 SVIDs
+const spiffeScheme = "spiffe"
+
+// allTenants grants a role on all the tenants and on the requests without tenant
+const allTenants = "*"
+
+// Role represents a set of methods, i.e. the read-only methods for monitoring
+type Role struct {
+	// Methods are patterns of the method names, i.e. "*/Get*", "*/*" or "opi_api.storage.v1.FrontendNvmeService/*",
+	// the Marvell specific methods are named like marvell.frontend/CreateQuota, see path.Match
+	Methods []string `json:"methods"`
+}
+
+// Binding represents a role granted to an identity on tenants
+type Binding struct {
+	// Identity is the identity claim of the bearer token, the SPIFFE ID or the common name of the
+	// client certificate, or the opi-identity metadata
+	Identity string `json:"identity"`
+	// Role granted to the identity
+	Role string `json:"role"`
+	// Tenants the role is granted on, "*" for all of them and the requests without tenant,
+	// "" for the requests without tenant only
+	Tenants []string `json:"tenants"`
+}
+
+// Policy represents the roles and the identities they are granted to, a method is denied
+// unless a binding of the identity allows it
+type Policy struct {
+	// Roles by name
+	Roles map[string]*Role `json:"roles"`
+	// Bindings of the roles to the identities
+	Bindings []*Binding `json:"bindings"`
+
+	mu sync.RWMutex
+}
+
+// LoadPolicy reads a policy from a JSON file
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	policy := new(Policy)
+	if err := decoder.Decode(policy); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %v", file, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %v", file, err)
+	}
+	return policy, nil
+}
+
+// validate checks the bindings refer to existing roles and the patterns are well formed
+func (p *Policy) validate() error {
+	for name, role := range p.Roles {
+		for _, pattern := range role.Methods {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("role %s: method pattern %q: %v", name, pattern, err)
+			}
+		}
+	}
+	for _, binding := range p.Bindings {
+		if binding.Identity == "" {
+			return fmt.Errorf("binding of role %s has no identity", binding.Role)
+		}
+		if _, ok := p.Roles[binding.Role]; !ok {
+			return fmt.Errorf("binding of %s refers to unknown role %s", binding.Identity, binding.Role)
+		}
+	}
+	return nil
+}
+
+// Replace replaces the roles and the bindings of the policy with the ones of another, i.e. the
+// policy file loaded again
+func (p *Policy) Replace(other *Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Roles, p.Bindings = other.Roles, other.Bindings
+}
+
+// Authorize checks an identity can call a method on the resources of a tenant,
+// the tenant is empty for the requests without tenant
+func (p *Policy) Authorize(identity string, method string, tenantID string) error {
+	if identity == "" {
+		msg := "Missing identity, set a bearer token, an API key or a client certificate"
+		return status.Errorf(codes.Unauthenticated, msg)
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, binding := range p.Bindings {
+		if binding.Identity != identity || !grantsTenant(binding.Tenants, tenantID) {
+			continue
+		}
+		for _, pattern := range p.Roles[binding.Role].Methods {
+			if ok, _ := path.Match(pattern, method); ok {
+				return nil
+			}
+		}
+	}
+	msg := fmt.Sprintf("Identity %s is not allowed to call %s", identity, method)
+	if tenantID != "" {
+		msg += fmt.Sprintf(" for tenant %s", tenantID)
+	}
+	return status.Errorf(codes.PermissionDenied, msg)
+}
+
+// grantsTenant tells whether a binding applies to a tenant
+func grantsTenant(tenants []string, tenantID string) bool {
+	for _, t := range tenants {
+		if t == allTenants || t == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// loopbackInfo is the authentication information of the connections of the bridge to itself,
+// see tlsreload.LoopbackInfo
+type loopbackInfo interface {
+	credentials.AuthInfo
+	// Loopback tells the peer presented the certificate of the bridge
+	Loopback() bool
+}
+
+// identityKey is the context key of the identity of a caller authenticated by the bridge
+type identityKey struct{}
+
+// NewContext returns a context carrying the identity of a caller authenticated by the bridge,
+// i.e. the name of its API key
+func NewContext(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity of a gRPC client, the identity claim of its verified
+// bearer token or the identity it was authenticated with, or else the SPIFFE ID or the common
+// name of its verified certificate. The opi-identity metadata is only taken from the HTTP gateway,
+// on the connections of the bridge to itself, the other clients could set any
+func IdentityFromContext(ctx context.Context) string {
+	if token, ok := oidc.FromContext(ctx); ok {
+		return token.Identity
+	}
+	if identity, ok := ctx.Value(identityKey{}).(string); ok {
+		return identity
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+		certificate := info.State.VerifiedChains[0][0]
+		if id := SpiffeID(certificate); id != "" {
+			return id
+		}
+		return certificate.Subject.CommonName
+	}
+	if info, ok := p.AuthInfo.(loopbackInfo); !ok || !info.Loopback() {
+		return ""
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// SpiffeID returns the SPIFFE ID of an X.509 SVID, i.e. spiffe://example.org/ns/ops/sa/provisioner,
+// empty when the certificate isn't one
+func SpiffeID(certificate *x509.Certificate) string {
+	for _, uri := range certificate.URIs {
+		if uri.Scheme == spiffeScheme && uri.Host != "" {
+			return uri.String()
+		}
+	}
+	return ""
+}
+
+// UnaryServerInterceptor denies the gRPC calls the policy doesn't allow
+func UnaryServerInterceptor(p *Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := p.authorizeCall(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor denies the gRPC streams the policy doesn't allow
+func StreamServerInterceptor(p *Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := p.authorizeCall(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authorizeCall checks the caller of a gRPC method can call it on the tenant of the call
+func (p *Policy) authorizeCall(ctx context.Context, fullMethod string) error {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return p.Authorize(IdentityFromContext(ctx), strings.TrimPrefix(fullMethod, "/"), tenantID)
+}
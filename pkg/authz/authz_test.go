@@ -0,0 +1,388 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package authz restricts the methods the identities calling the bridge can use, per tenant
+package authz
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/oidc"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// testLoopbackInfo mimics the authentication information of the connections of the HTTP gateway,
+// see tlsreload.LoopbackInfo
+type testLoopbackInfo struct {
+	credentials.TLSInfo
+}
+
+func (testLoopbackInfo) Loopback() bool {
+	return true
+}
+
+// gatewayContext returns the context of a call of the HTTP gateway with metadata
+func gatewayContext(md metadata.MD) context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	return peer.NewContext(ctx, &peer.Peer{AuthInfo: testLoopbackInfo{}})
+}
+
+var testPolicy = &Policy{
+	Roles: map[string]*Role{
+		"monitor":     {Methods: []string{"*/Get*", "*/List*", "*/Stats*"}},
+		"provisioner": {Methods: []string{"*/*"}},
+	},
+	Bindings: []*Binding{
+		{Identity: "prometheus", Role: "monitor", Tenants: []string{"*"}},
+		{Identity: "acme-admin", Role: "provisioner", Tenants: []string{"acme"}},
+		{Identity: "admin", Role: "provisioner", Tenants: []string{""}},
+	},
+}
+
+func TestAuthz_Authorize(t *testing.T) {
+	tests := map[string]struct {
+		identity string
+		method   string
+		tenant   string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"missing identity": {
+			identity: "",
+			method:   "opi_api.storage.v1.FrontendNvmeService/ListNvmeSubsystems",
+			tenant:   "",
+			errCode:  codes.Unauthenticated,
+			errMsg:   "Missing identity, set a bearer token, an API key or a client certificate",
+		},
+		"unknown identity": {
+			identity: "guest",
+			method:   "opi_api.storage.v1.FrontendNvmeService/ListNvmeSubsystems",
+			tenant:   "",
+			errCode:  codes.PermissionDenied,
+			errMsg:   fmt.Sprintf("Identity %s is not allowed to call %s", "guest", "opi_api.storage.v1.FrontendNvmeService/ListNvmeSubsystems"),
+		},
+		"monitor reading any tenant": {
+			identity: "prometheus",
+			method:   "opi_api.storage.v1.FrontendNvmeService/StatsNvmeController",
+			tenant:   "acme",
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"monitor reading custom method": {
+			identity: "prometheus",
+			method:   "marvell.frontend/GetQuota",
+			tenant:   "",
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"monitor writing": {
+			identity: "prometheus",
+			method:   "opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem",
+			tenant:   "acme",
+			errCode:  codes.PermissionDenied,
+			errMsg:   fmt.Sprintf("Identity %s is not allowed to call %s for tenant %s", "prometheus", "opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem", "acme"),
+		},
+		"provisioner writing own tenant": {
+			identity: "acme-admin",
+			method:   "opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem",
+			tenant:   "acme",
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"provisioner writing other tenant": {
+			identity: "acme-admin",
+			method:   "opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem",
+			tenant:   "other",
+			errCode:  codes.PermissionDenied,
+			errMsg:   fmt.Sprintf("Identity %s is not allowed to call %s for tenant %s", "acme-admin", "opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem", "other"),
+		},
+		"provisioner writing without tenant": {
+			identity: "acme-admin",
+			method:   "opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem",
+			tenant:   "",
+			errCode:  codes.PermissionDenied,
+			errMsg:   fmt.Sprintf("Identity %s is not allowed to call %s", "acme-admin", "opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem"),
+		},
+		"requests without tenant only": {
+			identity: "admin",
+			method:   "marvell.middleend/CreateRaidVolume",
+			tenant:   "acme",
+			errCode:  codes.PermissionDenied,
+			errMsg:   fmt.Sprintf("Identity %s is not allowed to call %s for tenant %s", "admin", "marvell.middleend/CreateRaidVolume", "acme"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := testPolicy.Authorize(tt.identity, tt.method, tt.tenant)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestAuthz_LoadPolicy(t *testing.T) {
+	tests := map[string]struct {
+		in     string
+		errMsg string
+	}{
+		"valid policy": {
+			in:     `{"roles": {"monitor": {"methods": ["*/Get*"]}}, "bindings": [{"identity": "prometheus", "role": "monitor", "tenants": ["*"]}]}`,
+			errMsg: "",
+		},
+		"unknown field": {
+			in:     `{"roles": {}, "users": []}`,
+			errMsg: `json: unknown field "users"`,
+		},
+		"malformed pattern": {
+			in:     `{"roles": {"monitor": {"methods": ["*/[Get"]}}}`,
+			errMsg: `role monitor: method pattern "*/[Get": syntax error in pattern`,
+		},
+		"missing identity": {
+			in:     `{"roles": {"monitor": {"methods": ["*/Get*"]}}, "bindings": [{"role": "monitor", "tenants": ["*"]}]}`,
+			errMsg: "binding of role monitor has no identity",
+		},
+		"unknown role": {
+			in:     `{"roles": {}, "bindings": [{"identity": "prometheus", "role": "monitor", "tenants": ["*"]}]}`,
+			errMsg: "binding of prometheus refers to unknown role monitor",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(file, []byte(tt.in), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			policy, err := LoadPolicy(file)
+			if tt.errMsg == "" {
+				if err != nil || policy == nil {
+					t.Error("expected policy, received", err)
+				}
+				return
+			}
+			expected := fmt.Sprintf("invalid policy %s: %s", file, tt.errMsg)
+			if err == nil || err.Error() != expected {
+				t.Error("error: expected", expected, "received", err)
+			}
+		})
+	}
+}
+
+func TestAuthz_UnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(testPolicy)
+	tests := map[string]struct {
+		md      metadata.MD
+		method  string
+		called  bool
+		errCode codes.Code
+	}{
+		"without identity": {
+			md:      nil,
+			method:  "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem",
+			called:  false,
+			errCode: codes.Unauthenticated,
+		},
+		"allowed": {
+			md:      metadata.Pairs(MetadataKey, "prometheus", tenant.MetadataKey, "acme"),
+			method:  "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem",
+			called:  true,
+			errCode: codes.OK,
+		},
+		"denied": {
+			md:      metadata.Pairs(MetadataKey, "acme-admin", tenant.MetadataKey, "other"),
+			method:  "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem",
+			called:  false,
+			errCode: codes.PermissionDenied,
+		},
+		"invalid tenant": {
+			md:      metadata.Pairs(MetadataKey, "prometheus", tenant.MetadataKey, "Acme"),
+			method:  "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem",
+			called:  false,
+			errCode: codes.InvalidArgument,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = gatewayContext(tt.md)
+			}
+			called := false
+			handler := func(_ context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return req, nil
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if called != tt.called {
+				t.Error("handler called: expected", tt.called, "received", called)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+		})
+	}
+}
+
+// testServerStream is a stream carrying the context of a call
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthz_StreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(testPolicy)
+	tests := map[string]struct {
+		md      metadata.MD
+		called  bool
+		errCode codes.Code
+	}{
+		"without identity": {
+			md:      metadata.Pairs(tenant.MetadataKey, "acme"),
+			called:  false,
+			errCode: codes.Unauthenticated,
+		},
+		"allowed": {
+			md:      metadata.Pairs(MetadataKey, "prometheus", tenant.MetadataKey, "acme"),
+			called:  true,
+			errCode: codes.OK,
+		},
+		"denied": {
+			md:      metadata.Pairs(MetadataKey, "acme-admin", tenant.MetadataKey, "other"),
+			called:  false,
+			errCode: codes.PermissionDenied,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			called := false
+			handler := func(_ interface{}, _ grpc.ServerStream) error {
+				called = true
+				return nil
+			}
+			info := &grpc.StreamServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"}
+			err := interceptor(nil, &testServerStream{ctx: gatewayContext(tt.md)}, info, handler)
+			if called != tt.called {
+				t.Error("handler called: expected", tt.called, "received", called)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+		})
+	}
+}
+
+func TestAuthz_IdentityFromContext(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/ops/sa/provisioner")
+	other, _ := url.Parse("https://example.org/provisioner")
+	tests := map[string]struct {
+		token       *oidc.Token
+		apiKey      string
+		certificate *x509.Certificate
+		loopback    bool
+		md          metadata.MD
+		identity    string
+	}{
+		"bearer token": {
+			token:       &oidc.Token{Identity: "ops@example.org"},
+			certificate: &x509.Certificate{Subject: pkix.Name{CommonName: "provisioner"}, URIs: []*url.URL{spiffeID}},
+			md:          metadata.Pairs(MetadataKey, "admin"),
+			identity:    "ops@example.org",
+		},
+		"API key": {
+			apiKey:      "ci",
+			certificate: &x509.Certificate{Subject: pkix.Name{CommonName: "provisioner"}},
+			md:          metadata.Pairs(MetadataKey, "admin"),
+			identity:    "ci",
+		},
+		"SPIFFE ID": {
+			certificate: &x509.Certificate{Subject: pkix.Name{CommonName: "provisioner"}, URIs: []*url.URL{other, spiffeID}},
+			md:          metadata.Pairs(MetadataKey, "admin"),
+			identity:    "spiffe://example.org/ns/ops/sa/provisioner",
+		},
+		"common name": {
+			certificate: &x509.Certificate{Subject: pkix.Name{CommonName: "provisioner"}, URIs: []*url.URL{other}},
+			md:          metadata.Pairs(MetadataKey, "admin"),
+			identity:    "provisioner",
+		},
+		"metadata of the HTTP gateway": {
+			certificate: nil,
+			loopback:    true,
+			md:          metadata.Pairs(MetadataKey, "admin"),
+			identity:    "admin",
+		},
+		"metadata of another client": {
+			certificate: nil,
+			loopback:    false,
+			md:          metadata.Pairs(MetadataKey, "admin"),
+			identity:    "",
+		},
+		"none": {
+			certificate: nil,
+			md:          nil,
+			identity:    "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			if tt.token != nil {
+				ctx = oidc.NewContext(ctx, tt.token)
+			}
+			if tt.apiKey != "" {
+				ctx = NewContext(ctx, tt.apiKey)
+			}
+			if tt.certificate != nil {
+				state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.certificate}}}
+				ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+			}
+			if tt.loopback {
+				ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: testLoopbackInfo{}})
+			}
+			identity := IdentityFromContext(ctx)
+			if identity != tt.identity {
+				t.Error("identity: expected", tt.identity, "received", identity)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package authz restricts the methods the identities calling the bridge can use, per tenant
+package authz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Input represents a call a policy engine decides on
+type Input struct {
+	// Identity of the caller
+	Identity string `json:"identity"`
+	// Method called, i.e. opi_api.storage.v1.FrontendNvmeService/CreateNvmeController or
+	// marvell.frontend/CreateQuota
+	Method string `json:"method"`
+	// Tenant of the request, empty for the requests without tenant
+	Tenant string `json:"tenant"`
+	// Request as JSON, with the names the tenant knows
+	Request json.RawMessage `json:"request"`
+}
+
+// Engine decides on the calls from their requests, i.e. "tenant0 may only create controllers on
+// PF 1", on top of the roles of the Policy
+type Engine interface {
+	// Decide returns a PermissionDenied error when the call isn't allowed
+	Decide(ctx context.Context, input *Input) error
+}
+
+// OPAEngine delegates the decisions to an Open Policy Agent, usually a sidecar of the bridge
+type OPAEngine struct {
+	url    string
+	client *http.Client
+}
+
+// NewOPAEngine returns an Engine querying the decision of an OPA document, i.e.
+// http://localhost:8181/v1/data/opi/bridge/allow, its result is either a boolean or an object
+// with the allow boolean and the reason of a denial
+func NewOPAEngine(url string, client *http.Client) *OPAEngine {
+	return &OPAEngine{url: url, client: client}
+}
+
+// opaDecision is the result of an OPA document, undefined when nil
+type opaDecision struct {
+	Result json.RawMessage `json:"result"`
+}
+
+// Decide queries OPA, the calls are denied when OPA can't be reached or the document is undefined
+func (e *OPAEngine) Decide(ctx context.Context, input *Input) error {
+	body, err := json.Marshal(map[string]*Input{"input": input})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		msg := fmt.Sprintf("Could not get the authorization decision of %s: %v", input.Method, err)
+		return status.Errorf(codes.Unavailable, msg)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var decision opaDecision
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status %s", resp.Status)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&decision)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Could not get the authorization decision of %s: %v", input.Method, err)
+		return status.Errorf(codes.Unavailable, msg)
+	}
+	allow, reason := false, ""
+	var result struct {
+		Allow  bool   `json:"allow"`
+		Reason string `json:"reason"`
+	}
+	switch {
+	case json.Unmarshal(decision.Result, &allow) == nil:
+	case json.Unmarshal(decision.Result, &result) == nil:
+		allow, reason = result.Allow, result.Reason
+	}
+	if allow {
+		return nil
+	}
+	return denied(input, reason)
+}
+
+// denied returns the error of a call the engine doesn't allow
+func denied(input *Input, reason string) error {
+	msg := fmt.Sprintf("Identity %s is not allowed to call %s", input.Identity, input.Method)
+	if input.Tenant != "" {
+		msg += fmt.Sprintf(" for tenant %s", input.Tenant)
+	}
+	if reason != "" {
+		msg += ": " + reason
+	}
+	return status.Errorf(codes.PermissionDenied, msg)
+}
+
+// requestJSON encodes a request as the HTTP gateway does, with the names of the proto fields
+func requestJSON(req interface{}) ([]byte, error) {
+	if message, ok := req.(proto.Message); ok {
+		return protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+	}
+	return json.Marshal(req)
+}
+
+// EngineUnaryServerInterceptor denies the gRPC calls the engine doesn't allow
+func EngineUnaryServerInterceptor(e Engine) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		tenantID, err := tenant.FromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		request, err := requestJSON(req)
+		if err != nil {
+			return nil, err
+		}
+		input := &Input{
+			Identity: IdentityFromContext(ctx),
+			Method:   strings.TrimPrefix(info.FullMethod, "/"),
+			Tenant:   tenantID,
+			Request:  request,
+		}
+		if err := e.Decide(ctx, input); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// EngineStreamServerInterceptor denies the gRPC streams the engine doesn't allow, their input has
+// no request since the stream is decided before its first message
+func EngineStreamServerInterceptor(e Engine) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		tenantID, err := tenant.FromContext(ss.Context())
+		if err != nil {
+			return err
+		}
+		input := &Input{
+			Identity: IdentityFromContext(ss.Context()),
+			Method:   strings.TrimPrefix(info.FullMethod, "/"),
+			Tenant:   tenantID,
+		}
+		if err := e.Decide(ss.Context(), input); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
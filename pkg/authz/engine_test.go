@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package authz restricts the methods the identities calling the bridge can use, per tenant
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testControllerRequest is the part of a CreateNvmeController request the test document decides on
+type testControllerRequest struct {
+	PhysicalFunction int `json:"pf"`
+}
+
+// newTestOPA serves a document allowing tenant0 to create controllers on PF 1 only
+func newTestOPA(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input *Input `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		var request testControllerRequest
+		_ = json.Unmarshal(body.Input.Request, &request)
+		switch {
+		case body.Input.Identity == "undefined":
+			_, _ = w.Write([]byte(`{}`))
+		case body.Input.Identity == "error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case body.Input.Tenant != "tenant0":
+			_, _ = w.Write([]byte(`{"result": true}`))
+		case request.PhysicalFunction == 1:
+			_, _ = w.Write([]byte(`{"result": {"allow": true}}`))
+		default:
+			_, _ = w.Write([]byte(`{"result": {"allow": false, "reason": "tenant0 may only create controllers on PF 1"}}`))
+		}
+	}))
+}
+
+func TestAuthz_OPAEngine(t *testing.T) {
+	opa := newTestOPA(t)
+	defer opa.Close()
+	interceptor := EngineUnaryServerInterceptor(NewOPAEngine(opa.URL, opa.Client()))
+	tests := map[string]struct {
+		md      metadata.MD
+		req     *testControllerRequest
+		called  bool
+		errCode codes.Code
+		errMsg  string
+	}{
+		"allowed": {
+			md:      metadata.Pairs(MetadataKey, "ci", tenant.MetadataKey, "tenant0"),
+			req:     &testControllerRequest{PhysicalFunction: 1},
+			called:  true,
+			errCode: codes.OK,
+		},
+		"denied with reason": {
+			md:      metadata.Pairs(MetadataKey, "ci", tenant.MetadataKey, "tenant0"),
+			req:     &testControllerRequest{PhysicalFunction: 0},
+			called:  false,
+			errCode: codes.PermissionDenied,
+			errMsg:  "Identity ci is not allowed to call opi_api.storage.v1.FrontendNvmeService/CreateNvmeController for tenant tenant0: tenant0 may only create controllers on PF 1",
+		},
+		"other tenant": {
+			md:      metadata.Pairs(MetadataKey, "ci", tenant.MetadataKey, "tenant1"),
+			req:     &testControllerRequest{PhysicalFunction: 0},
+			called:  true,
+			errCode: codes.OK,
+		},
+		"undefined document": {
+			md:      metadata.Pairs(MetadataKey, "undefined"),
+			req:     &testControllerRequest{PhysicalFunction: 1},
+			called:  false,
+			errCode: codes.PermissionDenied,
+			errMsg:  "Identity undefined is not allowed to call opi_api.storage.v1.FrontendNvmeService/CreateNvmeController",
+		},
+		"engine failure": {
+			md:      metadata.Pairs(MetadataKey, "error"),
+			req:     &testControllerRequest{PhysicalFunction: 1},
+			called:  false,
+			errCode: codes.Unavailable,
+			errMsg:  "Could not get the authorization decision of opi_api.storage.v1.FrontendNvmeService/CreateNvmeController: unexpected status 500 Internal Server Error",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := gatewayContext(tt.md)
+			called := false
+			handler := func(_ context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return req, nil
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeController"}
+			_, err := interceptor(ctx, tt.req, info, handler)
+			if called != tt.called {
+				t.Error("handler called: expected", tt.called, "received", called)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/fieldmask"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// aioVolumeType is the type of the volumes backed by a file or a block device
+const aioVolumeType = "aio"
+
+func sortAioVolumes(volumes []*pb.AioVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// resourceIDToAioVolumeName builds the name of an Aio volume from its ID
+func resourceIDToAioVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"volumes", resourceID,
+	)
+}
+
+// CreateAioVolume creates a volume backed by a file or a block device of the DPU SoC, the
+// volume is named after its ID, which frontend namespaces can use as volume_name_ref
+func (s *Server) CreateAioVolume(ctx context.Context, in *pb.CreateAioVolumeRequest) (*pb.AioVolume, error) {
+	// check input correctness
+	if err := s.validateCreateAioVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.AioVolumeId != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.AioVolumeId, "name", in.AioVolume.Name)
+		resourceID = in.AioVolumeId
+	}
+	in.AioVolume.Name = resourceIDToAioVolumeName(resourceID)
+	if err := s.checkVolumeType(in.AioVolume.Name, aioVolumeType); err != nil {
+		return nil, err
+	}
+	// idempotent API when called with same key, should return same object
+	volume := new(pb.AioVolume)
+	found, err := s.store.Get(in.AioVolume.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing AioVolume", "name", in.AioVolume.Name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	params := models.MrvlBdevAioCreateParams{
+		Name:      resourceID,
+		Filename:  in.AioVolume.Filename,
+		BlockSize: in.AioVolume.BlockSize,
+	}
+	var result models.MrvlBdevAioCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_aio_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Aio Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_aio_create")
+	}
+	// the firmware reports the geometry of the file or the device
+	response := utils.ProtoClone(in.AioVolume)
+	response.BlockSize = result.BlockSize
+	response.BlocksCount = result.NumBlocks
+	response.Uuid = result.UUID
+	err = s.store.Set(in.AioVolume.Name, response)
+	if err != nil {
+		return nil, err
+	}
+	s.volumeTypes[in.AioVolume.Name] = aioVolumeType
+	return response, nil
+}
+
+// DeleteAioVolume deletes an Aio volume, the file or the block device is left untouched
+func (s *Server) DeleteAioVolume(ctx context.Context, in *pb.DeleteAioVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteAioVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, aioVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.AioVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevAioDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevAioDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_aio_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Aio Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_aio_delete")
+	}
+	// remove from the Database
+	delete(s.volumeTypes, volume.Name)
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UpdateAioVolume updates an Aio volume
+func (s *Server) UpdateAioVolume(ctx context.Context, in *pb.UpdateAioVolumeRequest) (*pb.AioVolume, error) {
+	// check input correctness
+	if err := s.validateUpdateAioVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.AioVolume.Name, aioVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.AioVolume)
+	found, err := s.store.Get(in.AioVolume.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// see https://google.aip.dev/134#create-or-update
+		if in.AllowMissing {
+			return s.CreateAioVolume(ctx, &pb.CreateAioVolumeRequest{AioVolume: in.AioVolume, AioVolumeId: path.Base(in.AioVolume.Name)})
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.AioVolume.Name)
+		return nil, err
+	}
+	// update_mask = 2
+	if err := fieldmask.Validate(in.UpdateMask, in.AioVolume); err != nil {
+		return nil, err
+	}
+	return nil, status.Errorf(codes.Unimplemented, "UpdateAioVolume method is not implemented")
+}
+
+// ListAioVolumes lists Aio volumes
+func (s *Server) ListAioVolumes(_ context.Context, in *pb.ListAioVolumesRequest) (*pb.ListAioVolumesResponse, error) {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.volumeNames(aioVolumeType)
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*pb.AioVolume, 0, len(names))
+	for _, name := range names {
+		volume := new(pb.AioVolume)
+		found, err := s.store.Get(name, volume)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, volume)
+	}
+	sortAioVolumes(Blobarray)
+	return &pb.ListAioVolumesResponse{AioVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetAioVolume gets an Aio volume
+func (s *Server) GetAioVolume(_ context.Context, in *pb.GetAioVolumeRequest) (*pb.AioVolume, error) {
+	// check input correctness
+	if err := s.validateGetAioVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, aioVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.AioVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// StatsAioVolume gets the IO counters of an Aio volume
+func (s *Server) StatsAioVolume(ctx context.Context, in *pb.StatsAioVolumeRequest) (*pb.StatsAioVolumeResponse, error) {
+	// check input correctness
+	if err := s.validateStatsAioVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, aioVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.AioVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	params := models.MrvlBdevGetIostatParams{
+		Name: path.Base(volume.Name),
+	}
+	stats, err := s.bdevStats(ctx, &params)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StatsAioVolumeResponse{Stats: stats}, nil
+}
+
+// bdevStats gets the IO counters of a volume created by the bridge, i.e. an Aio, a Null or a Malloc volume
+func (s *Server) bdevStats(ctx context.Context, params *models.MrvlBdevGetIostatParams) (*pb.VolumeStats, error) {
+	var result models.MrvlBdevGetIostatResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_get_iostat", params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not stats Volume: %s", params.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_get_iostat")
+	}
+	return &pb.VolumeStats{
+		ReadBytesCount:    int32(result.BytesRead),
+		ReadOpsCount:      int32(result.NumReadOps),
+		WriteBytesCount:   int32(result.BytesWritten),
+		WriteOpsCount:     int32(result.NumWriteOps),
+		UnmapBytesCount:   int32(result.BytesUnmapped),
+		UnmapOpsCount:     int32(result.NumUnmapOps),
+		ReadLatencyTicks:  int32(result.TotalReadLatencyInUs),
+		WriteLatencyTicks: int32(result.TotalWriteLatencyInUs),
+		UnmapLatencyTicks: int32(result.TotalUnmapLatencyInUs),
+	}, nil
+}
+
+// checkVolumeType fails when a volume exists with another type, volumes of all types share
+// the volumes collection
+func (s *Server) checkVolumeType(name string, volumeType string) error {
+	if existing, ok := s.volumeTypes[name]; ok && existing != volumeType {
+		msg := fmt.Sprintf("Volume %s is a %s volume, not a %s volume", name, existing, volumeType)
+		return status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return nil
+}
+
+// volumeNames returns the sorted names of the volumes of a type
+func (s *Server) volumeNames(volumeType string) []string {
+	var names []string
+	for name, existing := range s.volumeTypes {
+		if existing == volumeType {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
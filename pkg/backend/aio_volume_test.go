@@ -0,0 +1,481 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var (
+	testAioVolumeID   = "mytest"
+	testAioVolumeName = resourceIDToAioVolumeName(testAioVolumeID)
+	testAioVolume     = pb.AioVolume{
+		Name:        testAioVolumeName,
+		BlockSize:   512,
+		BlocksCount: 2048,
+		Uuid:        "e0a8eb3c-8b3e-4f7e-9c1d-4d6b2b1b6c11",
+		Filename:    "/dev/nvme0n1",
+	}
+)
+
+func TestBackEnd_CreateAioVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *pb.AioVolume
+		out     *pb.AioVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &testAioVolume,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"relative filename": {
+			id:      testAioVolumeID,
+			in:      &pb.AioVolume{Filename: "disk.img"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Filename value (%s) is not an absolute path", "disk.img"),
+			exist:   false,
+		},
+		"unsupported block size": {
+			id:      testAioVolumeID,
+			in:      &pb.AioVolume{Filename: testAioVolume.Filename, BlockSize: 1024},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("BlockSize value (%d) is not supported, have to be 512 or 4096", 1024),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			id:      testAioVolumeID,
+			in:      &testAioVolume,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Aio Volume: %v", testAioVolumeID),
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			id:      testAioVolumeID,
+			in:      &pb.AioVolume{Filename: testAioVolume.Filename},
+			out:     &testAioVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "e0a8eb3c-8b3e-4f7e-9c1d-4d6b2b1b6c11", "block_size": 512, "num_blocks": 2048}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testAioVolumeID,
+			in:      &pb.AioVolume{Filename: testAioVolume.Filename},
+			out:     &testAioVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"no required field": {
+			id:      testAioVolumeID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: aio_volume",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.store.Set(testAioVolumeName, &testAioVolume)
+			}
+			if tt.in != nil {
+				tt.in = utils.ProtoClone(tt.in)
+				tt.in.Name = ""
+			}
+
+			request := &pb.CreateAioVolumeRequest{AioVolume: tt.in, AioVolumeId: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateAioVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteAioVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testAioVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Aio Volume: %v", testAioVolumeID),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testAioVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToAioVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToAioVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToAioVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testAioVolumeName, &testAioVolume)
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &pb.DeleteAioVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteAioVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_UpdateAioVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *pb.AioVolume
+		out     *pb.AioVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      &pb.AioVolume{Name: testAioVolumeName, Filename: testAioVolume.Filename},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unimplemented,
+			errMsg:  "UpdateAioVolume method is not implemented",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      &pb.AioVolume{Name: resourceIDToAioVolumeName("unknown-id"), Filename: testAioVolume.Filename},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToAioVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      &pb.AioVolume{Name: resourceIDToAioVolumeName("unknown-id"), Filename: testAioVolume.Filename},
+			out:     &pb.AioVolume{Name: resourceIDToAioVolumeName("unknown-id"), BlockSize: 512, BlocksCount: 2048, Uuid: testAioVolume.Uuid, Filename: testAioVolume.Filename},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "e0a8eb3c-8b3e-4f7e-9c1d-4d6b2b1b6c11", "block_size": 512, "num_blocks": 2048}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      &pb.AioVolume{Name: "-ABC-DEF", Filename: testAioVolume.Filename},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testAioVolumeName, &testAioVolume)
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &pb.UpdateAioVolumeRequest{AioVolume: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.UpdateAioVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListAioVolumes(t *testing.T) {
+	testAioVolume1 := utils.ProtoClone(&testAioVolume)
+	testAioVolume1.Name = resourceIDToAioVolumeName("mytest1")
+	tests := map[string]struct {
+		size    int32
+		token   string
+		out     []*pb.AioVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			size:    0,
+			token:   "",
+			out:     []*pb.AioVolume{&testAioVolume, testAioVolume1},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination": {
+			size:    1,
+			token:   "",
+			out:     []*pb.AioVolume{&testAioVolume},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination negative": {
+			size:    -10,
+			token:   "",
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+		},
+		"pagination error": {
+			size:    0,
+			token:   "unknown-pagination-token",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			for _, volume := range []*pb.AioVolume{&testAioVolume, testAioVolume1} {
+				_ = testEnv.opiSpdkServer.store.Set(volume.Name, volume)
+				testEnv.opiSpdkServer.volumeTypes[volume.Name] = aioVolumeType
+			}
+			// null volumes share the volumes collection
+			testEnv.opiSpdkServer.volumeTypes[resourceIDToAioVolumeName("null0")] = nullVolumeType
+
+			request := &pb.ListAioVolumesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListAioVolumes(testEnv.ctx, request)
+
+			if len(response.GetAioVolumes()) != len(tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetAioVolumes())
+			}
+			for i := range tt.out {
+				if !proto.Equal(response.GetAioVolumes()[i], tt.out[i]) {
+					t.Error("response: expected", tt.out[i], "received", response.GetAioVolumes()[i])
+				}
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetAioVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.AioVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testAioVolumeName,
+			out:     &testAioVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToAioVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToAioVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testAioVolumeName, &testAioVolume)
+
+			request := &pb.GetAioVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetAioVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_StatsAioVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.VolumeStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testAioVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not stats Volume: %v", testAioVolumeID),
+		},
+		"valid request with valid SPDK response": {
+			in: testAioVolumeName,
+			out: &pb.VolumeStats{
+				ReadBytesCount:    4096,
+				ReadOpsCount:      1,
+				WriteBytesCount:   8192,
+				WriteOpsCount:     2,
+				UnmapBytesCount:   512,
+				UnmapOpsCount:     1,
+				ReadLatencyTicks:  10,
+				WriteLatencyTicks: 20,
+				UnmapLatencyTicks: 5,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "num_read_ops": 1, "bytes_read": 4096, "num_write_ops": 2, "bytes_written": 8192, "num_unmap_ops": 1, "bytes_unmapped": 512, "total_read_latency_in_us": 10, "total_write_latency_in_us": 20, "total_unmap_latency_in_us": 5}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToAioVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToAioVolumeName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testAioVolumeName, &testAioVolume)
+
+			request := &pb.StatsAioVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsAioVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response.GetStats(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetStats())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
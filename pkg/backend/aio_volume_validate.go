@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"path"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func (s *Server) validateCreateAioVolumeRequest(in *pb.CreateAioVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.AioVolumeId != "" {
+		if err := resourceid.ValidateUserSettable(in.AioVolumeId); err != nil {
+			return err
+		}
+	}
+	// check Filename, the firmware doesn't know the working directory of the bridge
+	if !path.IsAbs(in.AioVolume.Filename) {
+		msg := fmt.Sprintf("Filename value (%s) is not an absolute path", in.AioVolume.Filename)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check BlockSize, 0 uses the logical block size of the device
+	if in.AioVolume.BlockSize != 0 && in.AioVolume.BlockSize != 512 && in.AioVolume.BlockSize != 4096 {
+		msg := fmt.Sprintf("BlockSize value (%d) is not supported, have to be 512 or 4096", in.AioVolume.BlockSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteAioVolumeRequest(in *pb.DeleteAioVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateUpdateAioVolumeRequest(in *pb.UpdateAioVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.AioVolume.Name)
+}
+
+func (s *Server) validateGetAioVolumeRequest(in *pb.GetAioVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateStatsAioVolumeRequest(in *pb.StatsAioVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// maxPoolEvents is the number of threshold crossings kept
+const maxPoolEvents = 100
+
+// StatsVolumeAllocationRequest represents a request to get the allocation of a volume
+type StatsVolumeAllocationRequest struct {
+	// Volume is the name of the volume, as used in the namespaces
+	Volume string `json:"volume"`
+}
+
+// VolumeAllocation represents the capacity allocated to a volume
+type VolumeAllocation struct {
+	// Volume is the name of the volume
+	Volume string `json:"volume"`
+	// Pool the volume is allocated from
+	Pool string `json:"pool"`
+	// ThinProvisioned is set when the blocks are allocated on first write
+	ThinProvisioned bool `json:"thinProvisioned"`
+	// AdvertisedBytes is the size of the volume seen by the hosts
+	AdvertisedBytes uint64 `json:"advertisedBytes"`
+	// AllocatedBytes is the capacity of the pool used by the volume
+	AllocatedBytes uint64 `json:"allocatedBytes"`
+}
+
+// ListPoolAllocationsRequest represents a request to list the allocation of the pools
+type ListPoolAllocationsRequest struct{}
+
+// PoolAllocation represents the capacity allocated from a pool
+type PoolAllocation struct {
+	// Pool is the name of the pool
+	Pool string `json:"pool"`
+	// CapacityBytes is the usable capacity of the pool
+	CapacityBytes uint64 `json:"capacityBytes"`
+	// AllocatedBytes is the capacity used by the volumes of the pool
+	AllocatedBytes uint64 `json:"allocatedBytes"`
+	// AdvertisedBytes is the sum of the sizes of the volumes of the pool, it exceeds
+	// CapacityBytes when the pool is overcommitted
+	AdvertisedBytes uint64 `json:"advertisedBytes"`
+	// UsedPercent is AllocatedBytes in percent of CapacityBytes
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+// ListPoolAllocationsResponse represents the allocation of the pools
+type ListPoolAllocationsResponse struct {
+	Pools []*PoolAllocation `json:"pools"`
+}
+
+// PoolEvent represents a pool crossing one of the usage thresholds
+type PoolEvent struct {
+	// Pool is the name of the pool
+	Pool string `json:"pool"`
+	// ThresholdPercent is the crossed threshold
+	ThresholdPercent int `json:"thresholdPercent"`
+	// UsedPercent is the usage of the pool when the threshold was crossed
+	UsedPercent float64 `json:"usedPercent"`
+	// Time is when the crossing was detected, in RFC 3339 format
+	Time string `json:"time"`
+}
+
+// ListPoolEventsRequest represents a request to list the pool threshold crossings
+type ListPoolEventsRequest struct{}
+
+// ListPoolEventsResponse represents the last pool threshold crossings
+type ListPoolEventsResponse struct {
+	Events []*PoolEvent `json:"events"`
+}
+
+// StatsVolumeAllocation gets the advertised and the allocated capacity of a volume
+func (s *Server) StatsVolumeAllocation(ctx context.Context, in *StatsVolumeAllocationRequest) (*VolumeAllocation, error) {
+	// check input correctness
+	if err := s.validateStatsVolumeAllocationRequest(in); err != nil {
+		return nil, err
+	}
+	params := models.MrvlBdevGetAllocationParams{
+		Name: in.Volume,
+	}
+	var result models.MrvlBdevGetAllocationResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_get_allocation", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get allocation of %s", in.Volume)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_get_allocation")
+	}
+	return &VolumeAllocation{
+		Volume:          in.Volume,
+		Pool:            result.Pool,
+		ThinProvisioned: result.ThinProvision,
+		AdvertisedBytes: result.SizeBytes,
+		AllocatedBytes:  result.AllocatedBytes,
+	}, nil
+}
+
+// ListPoolAllocations lists the capacity of the pools, allocated to and advertised by their volumes
+func (s *Server) ListPoolAllocations(ctx context.Context, _ *ListPoolAllocationsRequest) (*ListPoolAllocationsResponse, error) {
+	var result models.MrvlPoolGetListResult
+	err := s.rpc.Call(ctx, "mrvl_pool_get_list", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list pools"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_pool_get_list")
+	}
+	Blobarray := make([]*PoolAllocation, len(result.PoolList))
+	for i := range result.PoolList {
+		r := &result.PoolList[i]
+		Blobarray[i] = &PoolAllocation{
+			Pool:            r.Name,
+			CapacityBytes:   r.CapacityBytes,
+			AllocatedBytes:  r.AllocatedBytes,
+			AdvertisedBytes: r.AdvertisedBytes,
+		}
+		if r.CapacityBytes != 0 {
+			Blobarray[i].UsedPercent = float64(r.AllocatedBytes) * 100 / float64(r.CapacityBytes)
+		}
+	}
+	sort.Slice(Blobarray, func(i int, j int) bool {
+		return Blobarray[i].Pool < Blobarray[j].Pool
+	})
+	return &ListPoolAllocationsResponse{Pools: Blobarray}, nil
+}
+
+// ListPoolEvents lists the last times pools crossed the usage thresholds
+func (s *Server) ListPoolEvents(_ context.Context, _ *ListPoolEventsRequest) (*ListPoolEventsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &ListPoolEventsResponse{Events: append([]*PoolEvent{}, s.poolEvents...)}, nil
+}
+
+// SetPoolThresholds sets the usage levels of the pools, in percent, raising an event when crossed
+func (s *Server) SetPoolThresholds(thresholds []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.poolThresholds = append([]int(nil), thresholds...)
+	sort.Ints(s.poolThresholds)
+	s.poolLevels = make(map[string]int)
+}
+
+// WatchPoolThresholds checks the usage of the pools against the thresholds every interval until ctx is done
+func (s *Server) WatchPoolThresholds(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.checkPoolThresholds(ctx); err != nil {
+			slog.Warn("Could not check pool thresholds", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkPoolThresholds raises an event for each pool whose usage crossed a higher threshold
+// since the last check, a pool going back below a threshold can raise it again later
+func (s *Server) checkPoolThresholds(ctx context.Context) error {
+	pools, err := s.ListPoolAllocations(ctx, &ListPoolAllocationsRequest{})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pool := range pools.Pools {
+		level := 0
+		for _, threshold := range s.poolThresholds {
+			if pool.UsedPercent >= float64(threshold) {
+				level = threshold
+			}
+		}
+		if level > s.poolLevels[pool.Pool] {
+			slog.Warn("Pool is used above a threshold", "pool", pool.Pool, "used_percent", pool.UsedPercent, "threshold_percent", level)
+			s.poolEvents = append(s.poolEvents, &PoolEvent{
+				Pool:             pool.Pool,
+				ThresholdPercent: level,
+				UsedPercent:      pool.UsedPercent,
+				Time:             time.Now().UTC().Format(time.RFC3339),
+			})
+			if len(s.poolEvents) > maxPoolEvents {
+				s.poolEvents = s.poolEvents[len(s.poolEvents)-maxPoolEvents:]
+			}
+		}
+		s.poolLevels[pool.Pool] = level
+	}
+	return nil
+}
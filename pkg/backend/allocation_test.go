@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBackEnd_StatsVolumeAllocation(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *VolumeAllocation
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get allocation of %v", "Malloc0"),
+		},
+		"valid request with empty SPDK response": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_get_allocation: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_get_allocation: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_get_allocation: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: "Malloc0",
+			out: &VolumeAllocation{
+				Volume:          "Malloc0",
+				Pool:            "lvs0",
+				ThinProvisioned: true,
+				AdvertisedBytes: 1073741824,
+				AllocatedBytes:  4194304,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "pool": "lvs0", "thin_provision": true, "size_bytes": 1073741824, "allocated_bytes": 4194304}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: volume",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &StatsVolumeAllocationRequest{Volume: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsVolumeAllocation(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListPoolAllocations(t *testing.T) {
+	tests := map[string]struct {
+		out     []*PoolAllocation
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list pools",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_pool_get_list: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_pool_get_list: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			out: []*PoolAllocation{
+				{Pool: "lvs0", CapacityBytes: 1000, AllocatedBytes: 250, AdvertisedBytes: 4000, UsedPercent: 25},
+				{Pool: "lvs1", CapacityBytes: 0, AllocatedBytes: 0, AdvertisedBytes: 0, UsedPercent: 0},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "pool_list": [{"name": "lvs1"},{"name": "lvs0", "capacity_bytes": 1000, "allocated_bytes": 250, "advertised_bytes": 4000}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			response, err := testEnv.opiSpdkServer.ListPoolAllocations(testEnv.ctx, &ListPoolAllocationsRequest{})
+
+			var pools []*PoolAllocation
+			if response != nil {
+				pools = response.Pools
+			}
+			if !reflect.DeepEqual(pools, tt.out) {
+				t.Error("response: expected", tt.out, "received", pools)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_CheckPoolThresholds(t *testing.T) {
+	poolUsage := func(allocated int) string {
+		return fmt.Sprintf(`{"id":%%d,"error":{"code":0,"message":""},"result":{"status": 0, "pool_list": [{"name": "lvs0", "capacity_bytes": 100, "allocated_bytes": %d}]}}`, allocated)
+	}
+	tests := map[string]struct {
+		usage  []int
+		events []int
+	}{
+		"below thresholds": {
+			usage:  []int{10, 79},
+			events: nil,
+		},
+		"crossing thresholds one by one": {
+			usage:  []int{80, 85, 90, 96},
+			events: []int{80, 90, 95},
+		},
+		"crossing several thresholds at once": {
+			usage:  []int{50, 92},
+			events: []int{90},
+		},
+		"going back below a threshold": {
+			usage:  []int{91, 70, 91},
+			events: []int{90, 90},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			spdk := make([]string, len(tt.usage))
+			for i, allocated := range tt.usage {
+				spdk[i] = poolUsage(allocated)
+			}
+			testEnv := createTestEnvironment(spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.SetPoolThresholds([]int{95, 80, 90})
+			for range tt.usage {
+				if err := testEnv.opiSpdkServer.checkPoolThresholds(testEnv.ctx); err != nil {
+					t.Fatal("unexpected error", err)
+				}
+			}
+
+			response, _ := testEnv.opiSpdkServer.ListPoolEvents(testEnv.ctx, &ListPoolEventsRequest{})
+			var events []int
+			for _, event := range response.Events {
+				if event.Pool != "lvs0" {
+					t.Error("event pool: expected", "lvs0", "received", event.Pool)
+				}
+				events = append(events, event.ThresholdPercent)
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Error("events: expected", tt.events, "received", events)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+)
+
+func (s *Server) validateStatsVolumeAllocationRequest(in *StatsVolumeAllocationRequest) error {
+	// check required fields
+	if in.Volume == "" {
+		return errors.New("missing required field: volume")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/philippgille/gokv"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-marvell-bridge/pkg/pagination"
+)
+
+// defaultOffloadPollInterval is how often the progress of a copy or write zeroes is polled
+const defaultOffloadPollInterval = time.Second
+
+// Server contains backend related Marvell services
+type Server struct {
+	pb.UnimplementedNvmeRemoteControllerServiceServer
+	pb.UnimplementedAioVolumeServiceServer
+	pb.UnimplementedNullVolumeServiceServer
+	pb.UnimplementedMallocVolumeServiceServer
+	// ListHelper tracks the remote controllers, their paths, the TLS PSKs, the lvol stores, the
+	// Ceph clusters and the storage pools, which the store can't list
+	ListHelper map[string]bool
+	Pagination *pagination.Paginator
+	store      gokv.Store
+	rpc        spdk.JSONRPC
+	operations *operations.Manager
+	// offloadPollInterval is how often the progress of a copy or write zeroes is polled
+	offloadPollInterval time.Duration
+	// defaultReconnectOptions fill the options the remote controllers leave to 0
+	defaultReconnectOptions NvmeRemoteControllerReconnectOptions
+
+	// mu protects the pool thresholds, the scrubs state, the path states, the discovery services,
+	// the volume resizes and the rebalancing of the storage pools
+	mu sync.Mutex
+	// poolThresholds are the usage levels of the pools, in percent, raising an event when crossed
+	poolThresholds []int
+	// poolLevels is the highest threshold each pool has crossed
+	poolLevels map[string]int
+	// poolEvents are the last threshold crossings, the oldest first
+	poolEvents []*PoolEvent
+	// scrubs are the scrub schedule and status of the volumes, by volume name
+	scrubs map[string]*VolumeScrub
+	// nvmePathStates are the last known states of the Nvme paths, by path name
+	nvmePathStates map[string]*NvmePathStatus
+	// nvmePathEvents are the last path state transitions, the oldest first
+	nvmePathEvents []*NvmePathEvent
+	// discoveryServices tracks the names of the discovery services, which the store can't list
+	discoveryServices map[string]bool
+	// volumeTypes tracks the names of the Aio, xNVMe, Null, Malloc, RBD and iSCSI volumes and the
+	// lvols and their type, they share the volumes collection and the store can't list
+	volumeTypes map[string]string
+	// volumeResizeEvents are the last size changes of the volumes, the oldest first
+	volumeResizeEvents []*VolumeResizeEvent
+	// volumeResizeHandler propagates the size changes of the volumes to the namespaces
+	volumeResizeHandler VolumeResizeHandler
+	// namespaceMigrator moves the namespaces when the storage pools are rebalanced
+	namespaceMigrator NamespaceMigrator
+	// reconcileReporter is told the outcome of each reconciliation with the discovery log pages
+	reconcileReporter ReconcileReporter
+	// driftReporter is told the discovery services whose remote controllers drifted from their log page
+	driftReporter DriftReporter
+	// nvmePathFailureReporter is told the Nvme paths the firmware gave up reconnecting
+	nvmePathFailureReporter NvmePathFailureReporter
+	// nvmePathEventReporter is told the state transitions of the Nvme paths
+	nvmePathEventReporter NvmePathEventReporter
+	// rebalanceSkewPercent is the usage gap between lvol stores above which migrations are proposed
+	rebalanceSkewPercent float64
+	// rebalanceReports are the last rebalance reports, by storage pool name
+	rebalanceReports map[string]*StoragePoolRebalanceReport
+	// requestsMu is held for reading by the requests and for writing by the replay of the
+	// volumes and the reconciliation with the discovery log pages in the background, so they
+	// never update ListHelper at the same time
+	requestsMu sync.RWMutex
+}
+
+// NewServer creates initialized instance of backend server
+func NewServer(jsonRPC spdk.JSONRPC, store gokv.Store, ops *operations.Manager) *Server {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	if store == nil {
+		log.Panic("nil for Store is not allowed")
+	}
+	if ops == nil {
+		log.Panic("nil for Operations is not allowed")
+	}
+	return &Server{
+		ListHelper:           make(map[string]bool),
+		Pagination:           pagination.New(),
+		store:                store,
+		rpc:                  jsonRPC,
+		operations:           ops,
+		poolLevels:           make(map[string]int),
+		scrubs:               make(map[string]*VolumeScrub),
+		nvmePathStates:       make(map[string]*NvmePathStatus),
+		discoveryServices:    make(map[string]bool),
+		volumeTypes:          make(map[string]string),
+		rebalanceReports:     make(map[string]*StoragePoolRebalanceReport),
+		rebalanceSkewPercent: defaultRebalanceSkewPercent,
+		offloadPollInterval:  defaultOffloadPollInterval,
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/philippgille/gokv/gomap"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+type testEnv struct {
+	opiSpdkServer *Server
+	ln            net.Listener
+	testSocket    string
+	ctx           context.Context
+	jsonRPC       spdk.JSONRPC
+}
+
+func (e *testEnv) Close() {
+	utils.CloseListener(e.ln)
+	if err := os.RemoveAll(e.testSocket); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func createTestEnvironment(spdkResponses []string) *testEnv {
+	env := &testEnv{}
+	env.testSocket = utils.GenerateSocketName("backend")
+	env.ln, env.jsonRPC = utils.CreateTestSpdkServer(env.testSocket, spdkResponses)
+	options := gomap.DefaultOptions
+	options.Codec = utils.ProtoCodec{}
+	store := gomap.NewStore(options)
+	env.opiSpdkServer = NewServer(env.jsonRPC, store, operations.NewManager())
+	env.opiSpdkServer.offloadPollInterval = time.Millisecond
+	env.ctx = context.Background()
+	return env
+}
+
+var (
+	testDevice          = "Nvme0"
+	testSlotInfoSlot1   = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "active_slot": 1, "num_slots": 2, "slot_list": [{"slot": 1, "revision": "1.0"}, {"slot": 2, "revision": "2.0"}]}}`
+	testSlotInfoSlot2   = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "active_slot": 2, "num_slots": 2, "slot_list": [{"slot": 1, "revision": "1.0"}, {"slot": 2, "revision": "2.1"}]}}`
+	testSuccessResponse = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`
+	testFailureResponse = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`
+)
+
+var (
+	testNvmeRemoteControllerID   = "remote0"
+	testNvmeRemoteControllerName = resourceIDToRemoteControllerName(testNvmeRemoteControllerID)
+	testNvmeRemoteController     = pb.NvmeRemoteController{
+		Name:          testNvmeRemoteControllerName,
+		Multipath:     pb.NvmeMultipath_NVME_MULTIPATH_FAILOVER,
+		IoQueuesCount: 4,
+		QueueSize:     128,
+		Tcp:           &pb.TcpController{Hdgst: true},
+	}
+	testNvmePathID   = "path0"
+	testNvmePathName = testNvmeRemoteControllerName + "/nvmePaths/" + testNvmePathID
+	testNvmePath     = pb.NvmePath{
+		Name:   testNvmePathName,
+		Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+		Traddr: "10.10.10.11",
+		Fabrics: &pb.FabricsPath{
+			Trsvcid: 4420,
+			Subnqn:  "nqn.2016-06.io.spdk:cnode1",
+			Adrfam:  pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
+		},
+	}
+)
@@ -0,0 +1,573 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// defaultDiscoveryTrsvcid is the port IANA assigned to the NVMe-oF discovery service
+const defaultDiscoveryTrsvcid = 8009
+
+// discoveryTransportTypes maps the transports of the discovery log entries to the OPI ones,
+// the entries of the other transports are ignored
+var discoveryTransportTypes = map[string]pb.NvmeTransportType{
+	"TCP":  pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP,
+	"RDMA": pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA,
+}
+
+// discoveryAddressFamilies maps the address families of the discovery log entries to the OPI ones
+var discoveryAddressFamilies = map[string]pb.NvmeAddressFamily{
+	"IPv4": pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4,
+	"IPv6": pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV6,
+}
+
+// DiscoveredSubsystem represents a subsystem found in the discovery log page and the
+// remote controller created to reach it
+type DiscoveredSubsystem struct {
+	// Subnqn is the NQN of the subsystem
+	Subnqn string `json:"subnqn"`
+	// RemoteController is the name of the remote controller connected to the subsystem
+	RemoteController string `json:"remoteController"`
+	// Paths are the names of the paths of the remote controller, one per port of the subsystem
+	Paths []string `json:"paths"`
+}
+
+// DiscoveryService represents a persistent connection to an NVMe-oF discovery controller, the
+// subsystems of its log page get a remote controller with a path per port, updated on the
+// discovery AENs
+type DiscoveryService struct {
+	// Name of the discovery service
+	Name string `json:"name"`
+	// Trtype is the transport of the discovery controller, TCP or RDMA
+	Trtype string `json:"trtype"`
+	// Traddr is the address of the discovery controller
+	Traddr string `json:"traddr"`
+	// Adrfam is the address family of Traddr, IPv4 or IPv6
+	Adrfam string `json:"adrfam"`
+	// Trsvcid is the port of the discovery controller, 8009 when 0
+	Trsvcid int64 `json:"trsvcid"`
+	// Hostnqn is the NQN the DPU connects with, to the discovery controller and the subsystems
+	Hostnqn string `json:"hostnqn,omitempty"`
+	// SubnqnFilter are the glob patterns of the subsystems to connect to, all when empty
+	SubnqnFilter []string `json:"subnqnFilter,omitempty"`
+	// Generation is the generation counter of the last applied log page, output only
+	Generation uint64 `json:"generation"`
+	// Subsystems are the subsystems connected to, output only
+	Subsystems []*DiscoveredSubsystem `json:"subsystems"`
+	// SyncError is set when the remote controllers could not be updated after the last
+	// log page change, they are retried at the next check, output only
+	SyncError string `json:"syncError,omitempty"`
+}
+
+// CreateDiscoveryServiceRequest represents a request to connect to a discovery controller
+type CreateDiscoveryServiceRequest struct {
+	// DiscoveryServiceID is the ID of the discovery service, generated when empty
+	DiscoveryServiceID string `json:"discoveryServiceId"`
+	// DiscoveryService to create
+	DiscoveryService *DiscoveryService `json:"discoveryService"`
+}
+
+// DeleteDiscoveryServiceRequest represents a request to disconnect from a discovery controller
+type DeleteDiscoveryServiceRequest struct {
+	// Name of the discovery service
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the discovery service doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetDiscoveryServiceRequest represents a request to get a discovery service
+type GetDiscoveryServiceRequest struct {
+	// Name of the discovery service
+	Name string `json:"name"`
+}
+
+// ListDiscoveryServicesRequest represents a request to list discovery services
+type ListDiscoveryServicesRequest struct {
+	// PageSize is the maximum number of discovery services returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListDiscoveryServicesResponse represents a list of discovery services
+type ListDiscoveryServicesResponse struct {
+	// DiscoveryServices is the page of discovery services
+	DiscoveryServices []*DiscoveryService `json:"discoveryServices"`
+	// NextPageToken is set when more discovery services are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToDiscoveryServiceName builds the name of a discovery service, they have their
+// own collection as they are not part of the OPI APIs
+func resourceIDToDiscoveryServiceName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"discoveryServices", resourceID,
+	)
+}
+
+// CreateDiscoveryService connects to a discovery controller and creates a remote controller
+// for each subsystem of its log page matching the filter
+func (s *Server) CreateDiscoveryService(ctx context.Context, in *CreateDiscoveryServiceRequest) (*DiscoveryService, error) {
+	// check input correctness
+	if err := s.validateCreateDiscoveryServiceRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.DiscoveryServiceID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.DiscoveryServiceID, "name", in.DiscoveryService.Name)
+		resourceID = in.DiscoveryServiceID
+	}
+	name := resourceIDToDiscoveryServiceName(resourceID)
+	// idempotent API when called with same key, should return same object
+	discovery, found, err := s.getDiscoveryService(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing DiscoveryService", "name", name)
+		return discovery, nil
+	}
+	// not found, so create a new one
+	discovery = &DiscoveryService{
+		Name:         name,
+		Trtype:       in.DiscoveryService.Trtype,
+		Traddr:       in.DiscoveryService.Traddr,
+		Adrfam:       in.DiscoveryService.Adrfam,
+		Trsvcid:      in.DiscoveryService.Trsvcid,
+		Hostnqn:      in.DiscoveryService.Hostnqn,
+		SubnqnFilter: in.DiscoveryService.SubnqnFilter,
+	}
+	if discovery.Trsvcid == 0 {
+		discovery.Trsvcid = defaultDiscoveryTrsvcid
+	}
+	params := models.MrvlBdevNvmeStartDiscoveryParams{
+		Name:    resourceID,
+		Trtype:  discovery.Trtype,
+		Traddr:  discovery.Traddr,
+		Adrfam:  discovery.Adrfam,
+		Trsvcid: strconv.FormatInt(discovery.Trsvcid, 10),
+		Hostnqn: discovery.Hostnqn,
+	}
+	var result models.MrvlBdevNvmeStartDiscoveryResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_nvme_start_discovery", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not start NVMe discovery: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_start_discovery")
+	}
+	s.syncDiscoveryService(ctx, discovery, true)
+	err = s.saveDiscoveryService(discovery)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.discoveryServices[name] = true
+	s.mu.Unlock()
+	return discovery, nil
+}
+
+// DeleteDiscoveryService disconnects from a discovery controller, the remote controllers it
+// created are kept so the volumes they back stay online
+func (s *Server) DeleteDiscoveryService(ctx context.Context, in *DeleteDiscoveryServiceRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteDiscoveryServiceRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	discovery, found, err := s.getDiscoveryService(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(discovery.Name)
+	params := models.MrvlBdevNvmeStopDiscoveryParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevNvmeStopDiscoveryResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_nvme_stop_discovery", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not stop NVMe discovery: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_stop_discovery")
+	}
+	// remove from the Database
+	s.mu.Lock()
+	delete(s.discoveryServices, discovery.Name)
+	s.mu.Unlock()
+	err = s.store.Delete(discovery.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListDiscoveryServices lists discovery services
+func (s *Server) ListDiscoveryServices(_ context.Context, in *ListDiscoveryServicesRequest) (*ListDiscoveryServicesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.discoveryServiceNames()
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*DiscoveryService, 0, len(names))
+	for _, name := range names {
+		discovery, found, err := s.getDiscoveryService(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, discovery)
+	}
+	return &ListDiscoveryServicesResponse{DiscoveryServices: Blobarray, NextPageToken: token}, nil
+}
+
+// GetDiscoveryService gets a discovery service and the subsystems it connected to
+func (s *Server) GetDiscoveryService(_ context.Context, in *GetDiscoveryServiceRequest) (*DiscoveryService, error) {
+	// check input correctness
+	if err := s.validateGetDiscoveryServiceRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	discovery, found, err := s.getDiscoveryService(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return discovery, nil
+}
+
+// ReconcileReporter is told the outcome of each reconciliation of the remote controllers with the
+// discovery log pages, nil when all the discovery services are in sync
+type ReconcileReporter func(err error)
+
+// DriftReporter is told the discovery services whose log page changed since the last
+// reconciliation, with the generation of the log page their remote controllers now match
+type DriftReporter func(name string, generation uint64)
+
+// WatchDiscoveryServices checks the discovery log pages every interval until ctx is done, the
+// firmware reads the log page again on each discovery AEN and bumps its generation counter
+func (s *Server) WatchDiscoveryServices(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.checkDiscoveryServices(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDiscoveryServices updates the remote controllers of the discovery services whose log
+// page changed, or whose last update failed, the reconcile reporter is told the outcome
+func (s *Server) checkDiscoveryServices(ctx context.Context) {
+	var errs []error
+	for _, name := range s.discoveryServiceNames() {
+		discovery, found, err := s.getDiscoveryService(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		// the remote controllers and the paths are created and deleted as the requests do, none
+		// runs meanwhile
+		s.requestsMu.Lock()
+		changed := s.syncDiscoveryService(ctx, discovery, false)
+		s.requestsMu.Unlock()
+		if discovery.SyncError != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", name, discovery.SyncError))
+		}
+		if !changed {
+			continue
+		}
+		s.mu.Lock()
+		// the discovery service may have been deleted while it was synced
+		if s.discoveryServices[name] {
+			if err := s.saveDiscoveryService(discovery); err != nil {
+				slog.Warn("Could not save DiscoveryService", "name", name, "error", err)
+			}
+		}
+		driftReporter := s.driftReporter
+		s.mu.Unlock()
+		// the log page changed since the last sync, the remote controllers were reconciled with it
+		if driftReporter != nil && discovery.SyncError == "" {
+			driftReporter(name, discovery.Generation)
+		}
+	}
+	s.mu.Lock()
+	reporter := s.reconcileReporter
+	s.mu.Unlock()
+	if reporter != nil {
+		reporter(errors.Join(errs...))
+	}
+}
+
+// SetReconcileReporter sets who is told the outcome of each reconciliation of the remote
+// controllers with the discovery log pages
+func (s *Server) SetReconcileReporter(reporter ReconcileReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reconcileReporter = reporter
+}
+
+// SetDriftReporter sets who is told the discovery services whose remote controllers drifted
+// from their log page
+func (s *Server) SetDriftReporter(reporter DriftReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.driftReporter = reporter
+}
+
+// syncDiscoveryService creates and deletes remote controllers and paths to match the log page
+// of a discovery service, unless forced only when the log page changed since the last sync or
+// the last sync failed, it returns whether the discovery service changed
+func (s *Server) syncDiscoveryService(ctx context.Context, discovery *DiscoveryService, force bool) bool {
+	resourceID := path.Base(discovery.Name)
+	params := models.MrvlBdevNvmeGetDiscoveryLogParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevNvmeGetDiscoveryLogResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_nvme_get_discovery_log", &params, &result)
+	if err == nil {
+		if result.Status != 0 {
+			err = fmt.Errorf("discovery log of %s not available, status %s", resourceID, result.Status.Name("mrvl_bdev_nvme_get_discovery_log"))
+		}
+	}
+	if err != nil {
+		slog.Warn("Could not sync DiscoveryService", "name", discovery.Name, "error", err)
+		discovery.SyncError = err.Error()
+		return true
+	}
+	if !force && result.Genctr == discovery.Generation && discovery.SyncError == "" {
+		return false
+	}
+	slog.Info("Applying discovery log", "name", discovery.Name, "generation", result.Genctr)
+	// group the ports of the subsystems matching the filter
+	ports := make(map[string][]*pb.NvmePath)
+	for _, entry := range result.Entries {
+		// referrals to other discovery controllers are not followed
+		if entry.Subtype != "nvme" || !discoveryFilterMatch(discovery.SubnqnFilter, entry.Subnqn) {
+			continue
+		}
+		trtype, ok := discoveryTransportTypes[entry.Trtype]
+		if !ok {
+			continue
+		}
+		trsvcid, err := strconv.ParseInt(entry.Trsvcid, 10, 64)
+		if err != nil {
+			slog.Warn("Ignoring port", "traddr", entry.Traddr, "trsvcid", entry.Trsvcid, "subnqn", entry.Subnqn, "error", err)
+			continue
+		}
+		ports[entry.Subnqn] = append(ports[entry.Subnqn], &pb.NvmePath{
+			Trtype: trtype,
+			Traddr: entry.Traddr,
+			Fabrics: &pb.FabricsPath{
+				Trsvcid: trsvcid,
+				Subnqn:  entry.Subnqn,
+				Adrfam:  discoveryAddressFamilies[entry.Adrfam],
+				Hostnqn: discovery.Hostnqn,
+			},
+		})
+	}
+	subnqns := make([]string, 0, len(ports))
+	for subnqn := range ports {
+		subnqns = append(subnqns, subnqn)
+	}
+	sort.Strings(subnqns)
+	var syncErr error
+	wanted := make(map[string]bool)
+	subsystems := make([]*DiscoveredSubsystem, 0, len(subnqns))
+	for _, subnqn := range subnqns {
+		subsystem, err := s.connectDiscoveredSubsystem(ctx, discovery, subnqn, ports[subnqn])
+		if err != nil && syncErr == nil {
+			syncErr = err
+		}
+		if subsystem == nil {
+			continue
+		}
+		wanted[subsystem.RemoteController] = true
+		for _, name := range subsystem.Paths {
+			wanted[name] = true
+		}
+		subsystems = append(subsystems, subsystem)
+	}
+	// disconnect the ports and the subsystems which left the log page
+	for _, subsystem := range discovery.Subsystems {
+		var kept []string
+		for _, name := range subsystem.Paths {
+			if wanted[name] {
+				continue
+			}
+			_, err := s.DeleteNvmePath(ctx, &pb.DeleteNvmePathRequest{Name: name, AllowMissing: true})
+			if err != nil {
+				slog.Warn("Could not delete discovered NvmePath", "name", name, "error", err)
+				kept = append(kept, name)
+				if syncErr == nil {
+					syncErr = err
+				}
+			}
+		}
+		if wanted[subsystem.RemoteController] {
+			continue
+		}
+		if len(kept) == 0 {
+			_, err := s.DeleteNvmeRemoteController(ctx, &pb.DeleteNvmeRemoteControllerRequest{Name: subsystem.RemoteController, AllowMissing: true})
+			if err == nil {
+				continue
+			}
+			slog.Warn("Could not delete discovered NvmeRemoteController", "name", subsystem.RemoteController, "error", err)
+			if syncErr == nil {
+				syncErr = err
+			}
+		}
+		// keep track of what is left to retry at the next check
+		subsystems = append(subsystems, &DiscoveredSubsystem{Subnqn: subsystem.Subnqn, RemoteController: subsystem.RemoteController, Paths: kept})
+	}
+	discovery.Generation = result.Genctr
+	discovery.Subsystems = subsystems
+	discovery.SyncError = ""
+	if syncErr != nil {
+		discovery.SyncError = syncErr.Error()
+	}
+	return true
+}
+
+// connectDiscoveredSubsystem creates the remote controller of a discovered subsystem and a
+// path per port, it returns what was created even when some ports failed
+func (s *Server) connectDiscoveredSubsystem(ctx context.Context, discovery *DiscoveryService, subnqn string, ports []*pb.NvmePath) (*DiscoveredSubsystem, error) {
+	controller, err := s.CreateNvmeRemoteController(ctx, &pb.CreateNvmeRemoteControllerRequest{
+		NvmeRemoteController: &pb.NvmeRemoteController{
+			Multipath: pb.NvmeMultipath_NVME_MULTIPATH_FAILOVER,
+		},
+		NvmeRemoteControllerId: discoveredResourceID(discovery.Name, subnqn),
+	})
+	if err != nil {
+		slog.Warn("Could not create remote controller of discovered subsystem", "subnqn", subnqn, "error", err)
+		return nil, err
+	}
+	subsystem := &DiscoveredSubsystem{Subnqn: subnqn, RemoteController: controller.Name, Paths: []string{}}
+	var firstErr error
+	for _, port := range ports {
+		nvmePath, err := s.CreateNvmePath(ctx, &pb.CreateNvmePathRequest{
+			Parent:     controller.Name,
+			NvmePath:   port,
+			NvmePathId: discoveredResourceID(controller.Name, port.Traddr+":"+strconv.FormatInt(port.Fabrics.Trsvcid, 10)),
+		})
+		if err != nil {
+			slog.Warn("Could not create path of discovered subsystem", "traddr", port.Traddr, "trsvcid", port.Fabrics.Trsvcid, "subnqn", subnqn, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		subsystem.Paths = append(subsystem.Paths, nvmePath.Name)
+	}
+	sort.Strings(subsystem.Paths)
+	return subsystem, firstErr
+}
+
+// discoveredResourceID builds a stable ID for the remote controllers and the paths created by
+// a discovery service, so the same subsystem or port always maps to the same resource
+func discoveredResourceID(parent string, key string) string {
+	sum := sha256.Sum256([]byte(parent + "/" + key))
+	return "discovered-" + hex.EncodeToString(sum[:6])
+}
+
+// discoveryFilterMatch checks a subsystem NQN against the glob patterns of a discovery service
+func discoveryFilterMatch(filter []string, subnqn string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, pattern := range filter {
+		if matched, _ := path.Match(pattern, subnqn); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// discoveryServiceNames returns the sorted names of the discovery services
+func (s *Server) discoveryServiceNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.discoveryServices))
+	for name := range s.discoveryServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getDiscoveryService fetches a discovery service from the database,
+// discovery services are not protobufs so they are stored JSON encoded
+func (s *Server) getDiscoveryService(name string) (*DiscoveryService, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	discovery := new(DiscoveryService)
+	if err := json.Unmarshal(value.Value, discovery); err != nil {
+		return nil, false, err
+	}
+	return discovery, true, nil
+}
+
+func (s *Server) saveDiscoveryService(discovery *DiscoveryService) error {
+	data, err := json.Marshal(discovery)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(discovery.Name, wrapperspb.Bytes(data))
+}
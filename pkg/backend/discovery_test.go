@@ -0,0 +1,415 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var (
+	testDiscoveryServiceID   = "cdc0"
+	testDiscoveryServiceName = resourceIDToDiscoveryServiceName(testDiscoveryServiceID)
+	testDiscoveryService     = DiscoveryService{
+		Trtype:       "TCP",
+		Traddr:       "10.10.10.1",
+		Adrfam:       "IPv4",
+		SubnqnFilter: []string{"nqn.2016-06.io.spdk:tenant*"},
+	}
+	testDiscoveryLog = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "genctr": 4, "entries": [` +
+		`{"trtype": "TCP", "adrfam": "IPv4", "subtype": "nvme", "traddr": "10.10.10.11", "trsvcid": "4420", "subnqn": "nqn.2016-06.io.spdk:tenant1"},` +
+		`{"trtype": "TCP", "adrfam": "IPv4", "subtype": "nvme", "traddr": "10.10.10.12", "trsvcid": "4420", "subnqn": "nqn.2016-06.io.spdk:tenant1"},` +
+		`{"trtype": "TCP", "adrfam": "IPv4", "subtype": "nvme", "traddr": "10.10.10.11", "trsvcid": "4420", "subnqn": "nqn.2016-06.io.spdk:other"},` +
+		`{"trtype": "TCP", "adrfam": "IPv4", "subtype": "discovery", "traddr": "10.10.10.2", "trsvcid": "8009", "subnqn": "nqn.2014-08.org.nvmexpress.discovery"}]}}`
+	testAttachResponse = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": []}}`
+)
+
+// testDiscoveredSubsystem builds the subsystem the test discovery service creates for the given ports
+func testDiscoveredSubsystem(subnqn string, ports ...string) *DiscoveredSubsystem {
+	controllerName := resourceIDToRemoteControllerName(discoveredResourceID(testDiscoveryServiceName, subnqn))
+	subsystem := &DiscoveredSubsystem{Subnqn: subnqn, RemoteController: controllerName, Paths: []string{}}
+	for _, port := range ports {
+		subsystem.Paths = append(subsystem.Paths, controllerName+"/nvmePaths/"+discoveredResourceID(controllerName, port))
+	}
+	return subsystem
+}
+
+func TestBackEnd_CreateDiscoveryService(t *testing.T) {
+	testDiscoveryServiceRdma := testDiscoveryService
+	testDiscoveryServiceRdma.Trtype = "FC"
+	testDiscoveryServiceFilter := testDiscoveryService
+	testDiscoveryServiceFilter.SubnqnFilter = []string{"nqn.2016-06.io.spdk:[tenant"}
+	tests := map[string]struct {
+		in      *DiscoveryService
+		out     *DiscoveryService
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"unsupported transport": {
+			in:      &testDiscoveryServiceRdma,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Trtype value (%s) is not supported, have to be TCP or RDMA", "FC"),
+			exist:   false,
+		},
+		"invalid filter": {
+			in:      &testDiscoveryServiceFilter,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("SubnqnFilter value (%s) is not a valid pattern", "nqn.2016-06.io.spdk:[tenant"),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			in:      &testDiscoveryService,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not start NVMe discovery: %v", testDiscoveryServiceID),
+			exist:   false,
+		},
+		"valid request with empty SPDK response": {
+			in:      &testDiscoveryService,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_nvme_start_discovery: %v", "EOF"),
+			exist:   false,
+		},
+		"log page not available": {
+			in: &testDiscoveryService,
+			out: &DiscoveryService{
+				Name:         testDiscoveryServiceName,
+				Trtype:       "TCP",
+				Traddr:       "10.10.10.1",
+				Adrfam:       "IPv4",
+				Trsvcid:      8009,
+				SubnqnFilter: testDiscoveryService.SubnqnFilter,
+				SyncError:    fmt.Sprintf("discovery log of %s not available, status STATUS(1)", testDiscoveryServiceID),
+			},
+			spdk:    []string{testSuccessResponse, testFailureResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			in: &testDiscoveryService,
+			out: &DiscoveryService{
+				Name:         testDiscoveryServiceName,
+				Trtype:       "TCP",
+				Traddr:       "10.10.10.1",
+				Adrfam:       "IPv4",
+				Trsvcid:      8009,
+				SubnqnFilter: testDiscoveryService.SubnqnFilter,
+				Generation:   4,
+				Subsystems: []*DiscoveredSubsystem{
+					testDiscoveredSubsystem("nqn.2016-06.io.spdk:tenant1", "10.10.10.11:4420", "10.10.10.12:4420"),
+				},
+			},
+			spdk:    []string{testSuccessResponse, testDiscoveryLog, testAttachResponse, testAttachResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			in: &testDiscoveryService,
+			out: &DiscoveryService{
+				Name:    testDiscoveryServiceName,
+				Trtype:  "TCP",
+				Traddr:  "10.10.10.1",
+				Adrfam:  "IPv4",
+				Trsvcid: 8009,
+			},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveDiscoveryService(tt.out)
+			}
+
+			request := &CreateDiscoveryServiceRequest{DiscoveryService: tt.in, DiscoveryServiceID: testDiscoveryServiceID}
+			response, err := testEnv.opiSpdkServer.CreateDiscoveryService(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			if tt.out != nil && !tt.exist {
+				for _, subsystem := range tt.out.Subsystems {
+					if paths := testEnv.opiSpdkServer.nvmePathNames(subsystem.RemoteController); !reflect.DeepEqual(paths, subsystem.Paths) {
+						t.Error("paths: expected", subsystem.Paths, "received", paths)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBackEnd_CheckDiscoveryServices(t *testing.T) {
+	tenant1 := testDiscoveredSubsystem("nqn.2016-06.io.spdk:tenant1", "10.10.10.11:4420", "10.10.10.12:4420")
+	tenant2 := testDiscoveredSubsystem("nqn.2016-06.io.spdk:tenant2", "10.10.10.13:4420")
+	tests := map[string]struct {
+		spdk       []string
+		generation uint64
+		subsystems []*DiscoveredSubsystem
+		syncError  string
+	}{
+		"unchanged log page": {
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "genctr": 3, "entries": []}}`},
+			generation: 3,
+			subsystems: []*DiscoveredSubsystem{tenant1, tenant2},
+			syncError:  "",
+		},
+		"port and subsystem removed": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "genctr": 4, "entries": [{"trtype": "TCP", "adrfam": "IPv4", "subtype": "nvme", "traddr": "10.10.10.11", "trsvcid": "4420", "subnqn": "nqn.2016-06.io.spdk:tenant1"}]}}`,
+				testSuccessResponse,
+				testSuccessResponse,
+			},
+			generation: 4,
+			subsystems: []*DiscoveredSubsystem{testDiscoveredSubsystem("nqn.2016-06.io.spdk:tenant1", "10.10.10.11:4420")},
+			syncError:  "",
+		},
+		"failed detach": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "genctr": 4, "entries": [{"trtype": "TCP", "adrfam": "IPv4", "subtype": "nvme", "traddr": "10.10.10.11", "trsvcid": "4420", "subnqn": "nqn.2016-06.io.spdk:tenant1"}, {"trtype": "TCP", "adrfam": "IPv4", "subtype": "nvme", "traddr": "10.10.10.12", "trsvcid": "4420", "subnqn": "nqn.2016-06.io.spdk:tenant1"}]}}`,
+				testFailureResponse,
+			},
+			generation: 4,
+			subsystems: []*DiscoveredSubsystem{tenant1, tenant2},
+			syncError:  fmt.Sprintf("rpc error: code = InvalidArgument desc = Could not detach NVMe Ctrl: %v", path.Base(tenant2.RemoteController)),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			reported := errors.New("not reported")
+			testEnv.opiSpdkServer.SetReconcileReporter(func(err error) { reported = err })
+			drifted := uint64(0)
+			testEnv.opiSpdkServer.SetDriftReporter(func(name string, generation uint64) {
+				if name != testDiscoveryServiceName {
+					t.Error("drift name: expected", testDiscoveryServiceName, "received", name)
+				}
+				drifted = generation
+			})
+
+			discovery := &DiscoveryService{
+				Name:       testDiscoveryServiceName,
+				Trtype:     "TCP",
+				Traddr:     "10.10.10.1",
+				Adrfam:     "IPv4",
+				Trsvcid:    8009,
+				Generation: 3,
+				Subsystems: []*DiscoveredSubsystem{tenant1, tenant2},
+			}
+			_ = testEnv.opiSpdkServer.saveDiscoveryService(discovery)
+			testEnv.opiSpdkServer.discoveryServices[testDiscoveryServiceName] = true
+			for _, subsystem := range discovery.Subsystems {
+				controller := utils.ProtoClone(&testNvmeRemoteController)
+				controller.Name = subsystem.RemoteController
+				_ = testEnv.opiSpdkServer.store.Set(controller.Name, controller)
+				testEnv.opiSpdkServer.ListHelper[controller.Name] = false
+				for _, name := range subsystem.Paths {
+					nvmePath := utils.ProtoClone(&testNvmePath)
+					nvmePath.Name = name
+					_ = testEnv.opiSpdkServer.store.Set(nvmePath.Name, nvmePath)
+					testEnv.opiSpdkServer.ListHelper[nvmePath.Name] = false
+				}
+			}
+
+			testEnv.opiSpdkServer.checkDiscoveryServices(testEnv.ctx)
+
+			response, err := testEnv.opiSpdkServer.GetDiscoveryService(testEnv.ctx, &GetDiscoveryServiceRequest{Name: testDiscoveryServiceName})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if response.Generation != tt.generation {
+				t.Error("generation: expected", tt.generation, "received", response.Generation)
+			}
+			if !reflect.DeepEqual(response.Subsystems, tt.subsystems) {
+				t.Error("subsystems: expected", tt.subsystems, "received", response.Subsystems)
+			}
+			if response.SyncError != tt.syncError {
+				t.Error("sync error: expected", tt.syncError, "received", response.SyncError)
+			}
+			expectedReport := ""
+			if tt.syncError != "" {
+				expectedReport = testDiscoveryServiceName + ": " + tt.syncError
+			}
+			if (reported == nil && expectedReport != "") || (reported != nil && reported.Error() != expectedReport) {
+				t.Error("reported: expected", expectedReport, "received", reported)
+			}
+			// only the log pages applied without error are reported as drift
+			expectedDrift := uint64(0)
+			if tt.generation != 3 && tt.syncError == "" {
+				expectedDrift = tt.generation
+			}
+			if drifted != expectedDrift {
+				t.Error("drift: expected", expectedDrift, "received", drifted)
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteDiscoveryService(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testDiscoveryServiceName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not stop NVMe discovery: %v", testDiscoveryServiceID),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testDiscoveryServiceName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToDiscoveryServiceName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToDiscoveryServiceName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToDiscoveryServiceName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			discovery := testDiscoveryService
+			discovery.Name = testDiscoveryServiceName
+			_ = testEnv.opiSpdkServer.saveDiscoveryService(&discovery)
+			testEnv.opiSpdkServer.discoveryServices[testDiscoveryServiceName] = true
+
+			request := &DeleteDiscoveryServiceRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteDiscoveryService(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			list, _ := testEnv.opiSpdkServer.ListDiscoveryServices(testEnv.ctx, &ListDiscoveryServicesRequest{})
+			if deleted := len(list.DiscoveryServices) == 0; deleted != (tt.errCode == codes.OK && !tt.missing) {
+				t.Error("deleted: expected", !deleted, "received", deleted)
+			}
+		})
+	}
+}
+
+// run with -race, the requests run while the discovery log pages are applied in the background
+func TestBackEnd_CheckDiscoveryServicesWhileListing(t *testing.T) {
+	testEnv := createTestEnvironment([]string{testDiscoveryLog, testAttachResponse, testAttachResponse})
+	defer testEnv.Close()
+
+	discovery := &DiscoveryService{
+		Name:         testDiscoveryServiceName,
+		Trtype:       "TCP",
+		Traddr:       "10.10.10.1",
+		Adrfam:       "IPv4",
+		Trsvcid:      8009,
+		SubnqnFilter: testDiscoveryService.SubnqnFilter,
+		Generation:   3,
+	}
+	_ = testEnv.opiSpdkServer.saveDiscoveryService(discovery)
+	testEnv.opiSpdkServer.discoveryServices[testDiscoveryServiceName] = true
+
+	interceptor := testEnv.opiSpdkServer.UnaryServerInterceptor()
+	list := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return testEnv.opiSpdkServer.ListNvmeRemoteControllers(ctx, req.(*pb.ListNvmeRemoteControllersRequest))
+	}
+	listed := make(chan error)
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := interceptor(testEnv.ctx, &pb.ListNvmeRemoteControllersRequest{}, &grpc.UnaryServerInfo{}, list); err != nil {
+				listed <- err
+				return
+			}
+		}
+		listed <- nil
+	}()
+
+	testEnv.opiSpdkServer.checkDiscoveryServices(testEnv.ctx)
+
+	if err := <-listed; err != nil {
+		t.Error("unexpected error", err)
+	}
+	response, err := testEnv.opiSpdkServer.GetDiscoveryService(testEnv.ctx, &GetDiscoveryServiceRequest{Name: testDiscoveryServiceName})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	expected := []*DiscoveredSubsystem{testDiscoveredSubsystem("nqn.2016-06.io.spdk:tenant1", "10.10.10.11:4420", "10.10.10.12:4420")}
+	if !reflect.DeepEqual(response.Subsystems, expected) {
+		t.Error("subsystems: expected", expected, "received", response.Subsystems)
+	}
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateDiscoveryServiceRequest(in *CreateDiscoveryServiceRequest) error {
+	// check required fields
+	if in.DiscoveryService == nil {
+		return errors.New("missing required field: discovery_service")
+	}
+	if in.DiscoveryService.Traddr == "" {
+		return errors.New("missing required field: discovery_service.traddr")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.DiscoveryServiceID != "" {
+		if err := resourceid.ValidateUserSettable(in.DiscoveryServiceID); err != nil {
+			return err
+		}
+	}
+	// check Trtype, discovery controllers are only reached over the fabrics
+	if _, ok := discoveryTransportTypes[in.DiscoveryService.Trtype]; !ok {
+		msg := fmt.Sprintf("Trtype value (%s) is not supported, have to be TCP or RDMA", in.DiscoveryService.Trtype)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if _, ok := discoveryAddressFamilies[in.DiscoveryService.Adrfam]; !ok {
+		msg := fmt.Sprintf("Adrfam value (%s) is not supported, have to be IPv4 or IPv6", in.DiscoveryService.Adrfam)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check Trsvcid, 0 uses the discovery service port
+	if in.DiscoveryService.Trsvcid < 0 || in.DiscoveryService.Trsvcid > 65535 {
+		msg := fmt.Sprintf("Trsvcid value (%d) is out of range, have to be between 0 and 65535", in.DiscoveryService.Trsvcid)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check SubnqnFilter patterns
+	for _, pattern := range in.DiscoveryService.SubnqnFilter {
+		if _, err := path.Match(pattern, ""); err != nil {
+			msg := fmt.Sprintf("SubnqnFilter value (%s) is not a valid pattern", pattern)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteDiscoveryServiceRequest(in *DeleteDiscoveryServiceRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetDiscoveryServiceRequest(in *GetDiscoveryServiceRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+// DeleteVolumes deletes the Nvme paths, the remote controllers and the Null, Malloc and Aio
+// volumes, in the firmware and in the store, i.e. to bring the storage configuration back to its
+// factory state. The resources which can't be deleted are kept, their errors are returned
+func (s *Server) DeleteVolumes(ctx context.Context) error {
+	var errs []error
+	for _, name := range s.resetNames("nvmePaths") {
+		_, err := s.DeleteNvmePath(ctx, &pb.DeleteNvmePathRequest{Name: name, AllowMissing: true})
+		errs = append(errs, err)
+	}
+	for _, name := range s.resetNames("nvmeRemoteControllers") {
+		_, err := s.DeleteNvmeRemoteController(ctx, &pb.DeleteNvmeRemoteControllerRequest{Name: name, AllowMissing: true})
+		errs = append(errs, err)
+	}
+	for _, name := range s.replayVolumeNames(nullVolumeType) {
+		_, err := s.DeleteNullVolume(ctx, &pb.DeleteNullVolumeRequest{Name: name, AllowMissing: true})
+		errs = append(errs, err)
+	}
+	for _, name := range s.replayVolumeNames(mallocVolumeType) {
+		_, err := s.DeleteMallocVolume(ctx, &pb.DeleteMallocVolumeRequest{Name: name, AllowMissing: true})
+		errs = append(errs, err)
+	}
+	for _, name := range s.replayVolumeNames(aioVolumeType) {
+		_, err := s.DeleteAioVolume(ctx, &pb.DeleteAioVolumeRequest{Name: name, AllowMissing: true})
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// resetNames returns the sorted names of the resources of a collection
+func (s *Server) resetNames(collection string) []string {
+	var names []string
+	for name := range s.ListHelper {
+		if path.Base(path.Dir(name)) == collection {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// firmwareChunkSize is the size of the image pieces transferred with each download call
+const firmwareChunkSize = 32 * 1024
+
+// Firmware Commit actions, see NVMe Base Specification
+const (
+	firmwareCommitReplaceAndActivate = 1 // replace the image in slot, activated at next reset
+	firmwareCommitActivate           = 2 // activate the image in slot at next reset
+	firmwareCommitActivateNow        = 3 // replace the image in slot and activate immediately
+)
+
+// NvmeFirmwareSlot represents a firmware slot of an NVMe device
+type NvmeFirmwareSlot struct {
+	// Slot number
+	Slot int32 `json:"slot"`
+	// Revision of the firmware image in the slot
+	Revision string `json:"revision"`
+}
+
+// GetNvmeFirmwareRequest represents a request to get the firmware slots of an NVMe device
+type GetNvmeFirmwareRequest struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+}
+
+// NvmeFirmware represents the firmware slots of an NVMe device
+type NvmeFirmware struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// ActiveSlot is the slot the running firmware was loaded from
+	ActiveSlot int32 `json:"activeSlot"`
+	// Slots lists the firmware slots of the device
+	Slots []*NvmeFirmwareSlot `json:"slots"`
+}
+
+// UpdateNvmeFirmwareRequest represents a request to download and commit firmware on an NVMe device
+type UpdateNvmeFirmwareRequest struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// Image is the firmware image
+	Image []byte `json:"image"`
+	// Slot the image is committed to, 0 lets the device select the slot
+	Slot int32 `json:"slot"`
+	// Activate the image immediately instead of at the next reset
+	Activate bool `json:"activate"`
+}
+
+// UpdateNvmeFirmwareMetadata describes a firmware update operation
+type UpdateNvmeFirmwareMetadata struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// Slot the image is committed to
+	Slot int32 `json:"slot"`
+	// PreviousSlot is the slot the firmware is rolled back to if activation fails
+	PreviousSlot int32 `json:"previousSlot"`
+}
+
+// GetNvmeFirmware gets the firmware slots of an NVMe device
+func (s *Server) GetNvmeFirmware(ctx context.Context, in *GetNvmeFirmwareRequest) (*NvmeFirmware, error) {
+	// check input correctness
+	if err := s.validateGetNvmeFirmwareRequest(in); err != nil {
+		return nil, err
+	}
+	return s.getNvmeFirmware(ctx, in.Device)
+}
+
+// UpdateNvmeFirmware downloads and commits firmware on an NVMe device, returning a long-running operation
+func (s *Server) UpdateNvmeFirmware(ctx context.Context, in *UpdateNvmeFirmwareRequest) (*operations.Operation, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeFirmwareRequest(in); err != nil {
+		return nil, err
+	}
+	// remember the active slot to rollback if the new image doesn't activate
+	firmware, err := s.getNvmeFirmware(ctx, in.Device)
+	if err != nil {
+		return nil, err
+	}
+	if int(in.Slot) > len(firmware.Slots) {
+		msg := fmt.Sprintf("Slot value (%d) is out of range, have to be between 0 and %d", in.Slot, len(firmware.Slots))
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	metadata := &UpdateNvmeFirmwareMetadata{
+		Device:       in.Device,
+		Slot:         in.Slot,
+		PreviousSlot: firmware.ActiveSlot,
+	}
+	image := append([]byte(nil), in.Image...)
+	activate := in.Activate
+	return s.operations.Start(metadata, func(ctx context.Context) (interface{}, error) {
+		return s.updateNvmeFirmware(ctx, metadata, image, activate)
+	}), nil
+}
+
+func (s *Server) updateNvmeFirmware(ctx context.Context, metadata *UpdateNvmeFirmwareMetadata, image []byte, activate bool) (*NvmeFirmware, error) {
+	for offset := 0; offset < len(image); offset += firmwareChunkSize {
+		end := offset + firmwareChunkSize
+		if end > len(image) {
+			end = len(image)
+		}
+		params := models.MrvlNvmFwDownloadParams{
+			Device: metadata.Device,
+			Offset: offset,
+			Data:   image[offset:end],
+		}
+		var result models.MrvlNvmFwDownloadResult
+		err := s.rpc.Call(ctx, "mrvl_nvm_fw_download", &params, &result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not download firmware to %s at offset %d", metadata.Device, offset)
+			return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_fw_download")
+		}
+	}
+	slog.Info("Downloaded firmware", "bytes", len(image), "device", metadata.Device)
+	action := firmwareCommitReplaceAndActivate
+	if activate {
+		action = firmwareCommitActivateNow
+	}
+	// on commit failure the previously active image keeps running
+	if err := s.commitNvmeFirmware(ctx, metadata.Device, int(metadata.Slot), action); err != nil {
+		return nil, err
+	}
+	firmware, err := s.getNvmeFirmware(ctx, metadata.Device)
+	if err != nil {
+		return nil, err
+	}
+	if !activate || metadata.Slot == 0 || firmware.ActiveSlot == metadata.Slot {
+		return firmware, nil
+	}
+	// the new image didn't activate, go back to the previous one
+	slog.Warn("Firmware did not activate, rolling back", "slot", metadata.Slot, "device", metadata.Device, "previous_slot", metadata.PreviousSlot)
+	if err := s.commitNvmeFirmware(ctx, metadata.Device, int(metadata.PreviousSlot), firmwareCommitActivate); err != nil {
+		return nil, err
+	}
+	msg := fmt.Sprintf("Firmware in slot %d did not activate on %s, rolled back to slot %d", metadata.Slot, metadata.Device, metadata.PreviousSlot)
+	return nil, status.Errorf(codes.Aborted, msg)
+}
+
+func (s *Server) commitNvmeFirmware(ctx context.Context, device string, slot int, action int) error {
+	params := models.MrvlNvmFwCommitParams{
+		Device: device,
+		Slot:   slot,
+		Action: action,
+	}
+	var result models.MrvlNvmFwCommitResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_fw_commit", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not commit firmware to slot %d on %s", slot, device)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_fw_commit")
+	}
+	return nil
+}
+
+func (s *Server) getNvmeFirmware(ctx context.Context, device string) (*NvmeFirmware, error) {
+	params := models.MrvlNvmFwGetSlotInfoParams{
+		Device: device,
+	}
+	var result models.MrvlNvmFwGetSlotInfoResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_fw_get_slot_info", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get firmware slots of %s", device)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_fw_get_slot_info")
+	}
+	firmware := &NvmeFirmware{
+		Device:     device,
+		ActiveSlot: int32(result.ActiveSlot),
+		Slots:      make([]*NvmeFirmwareSlot, len(result.SlotList)),
+	}
+	for i := range result.SlotList {
+		r := &result.SlotList[i]
+		firmware.Slots[i] = &NvmeFirmwareSlot{Slot: int32(r.Slot), Revision: r.Revision}
+	}
+	return firmware, nil
+}
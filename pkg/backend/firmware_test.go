@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+)
+
+func TestBackEnd_GetNvmeFirmware(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *NvmeFirmware
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testDevice,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get firmware slots of %v", testDevice),
+		},
+		"valid request with empty SPDK response": {
+			in:      testDevice,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_fw_get_slot_info: %v", "EOF"),
+		},
+		"valid request with valid SPDK response": {
+			in: testDevice,
+			out: &NvmeFirmware{
+				Device:     testDevice,
+				ActiveSlot: 1,
+				Slots: []*NvmeFirmwareSlot{
+					{Slot: 1, Revision: "1.0"},
+					{Slot: 2, Revision: "2.0"},
+				},
+			},
+			spdk:    []string{testSlotInfoSlot1},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: device",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &GetNvmeFirmwareRequest{Device: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmeFirmware(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_UpdateNvmeFirmware(t *testing.T) {
+	image := []byte{0x01, 0x02, 0x03, 0x04}
+	tests := map[string]struct {
+		in      *UpdateNvmeFirmwareRequest
+		out     *NvmeFirmware
+		opErr   *operations.Error
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with activation": {
+			in: &UpdateNvmeFirmwareRequest{Device: testDevice, Image: image, Slot: 2, Activate: true},
+			out: &NvmeFirmware{
+				Device:     testDevice,
+				ActiveSlot: 2,
+				Slots: []*NvmeFirmwareSlot{
+					{Slot: 1, Revision: "1.0"},
+					{Slot: 2, Revision: "2.1"},
+				},
+			},
+			opErr:   nil,
+			spdk:    []string{testSlotInfoSlot1, testSuccessResponse, testSuccessResponse, testSlotInfoSlot2},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"activation failure rolls back": {
+			in:  &UpdateNvmeFirmwareRequest{Device: testDevice, Image: image, Slot: 2, Activate: true},
+			out: nil,
+			opErr: &operations.Error{
+				Code:    codes.Aborted,
+				Message: fmt.Sprintf("Firmware in slot 2 did not activate on %v, rolled back to slot 1", testDevice),
+			},
+			spdk:    []string{testSlotInfoSlot1, testSuccessResponse, testSuccessResponse, testSlotInfoSlot1, testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"download failure": {
+			in:  &UpdateNvmeFirmwareRequest{Device: testDevice, Image: image, Slot: 2},
+			out: nil,
+			opErr: &operations.Error{
+				Code:    codes.InvalidArgument,
+				Message: fmt.Sprintf("Could not download firmware to %v at offset 0", testDevice),
+			},
+			spdk:    []string{testSlotInfoSlot1, testFailureResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"commit failure": {
+			in:  &UpdateNvmeFirmwareRequest{Device: testDevice, Image: image, Slot: 2},
+			out: nil,
+			opErr: &operations.Error{
+				Code:    codes.InvalidArgument,
+				Message: fmt.Sprintf("Could not commit firmware to slot 2 on %v", testDevice),
+			},
+			spdk:    []string{testSlotInfoSlot1, testSuccessResponse, testFailureResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"slot out of device range": {
+			in:      &UpdateNvmeFirmwareRequest{Device: testDevice, Image: image, Slot: 3},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{testSlotInfoSlot1},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Slot value (3) is out of range, have to be between 0 and 2",
+		},
+		"slot out of range": {
+			in:      &UpdateNvmeFirmwareRequest{Device: testDevice, Image: image, Slot: 8},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Slot value (8) is out of range, have to be between 0 and 7",
+		},
+		"image not dword aligned": {
+			in:      &UpdateNvmeFirmwareRequest{Device: testDevice, Image: image[:3]},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Image size (3) have to be a multiple of 4",
+		},
+		"no required image field": {
+			in:      &UpdateNvmeFirmwareRequest{Device: testDevice},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: image",
+		},
+		"no required device field": {
+			in:      &UpdateNvmeFirmwareRequest{Image: image},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: device",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			op, err := testEnv.opiSpdkServer.UpdateNvmeFirmware(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err != nil {
+				return
+			}
+
+			testEnv.opiSpdkServer.operations.Wait()
+			response, err := testEnv.opiSpdkServer.operations.GetOperation(testEnv.ctx, &operations.GetOperationRequest{Name: op.Name})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !response.Done {
+				t.Error("expected operation to be done")
+			}
+			if !reflect.DeepEqual(response.Error, tt.opErr) {
+				t.Error("operation error: expected", tt.opErr, "received", response.Error)
+			}
+			if tt.out != nil && !reflect.DeepEqual(response.Response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response.Response)
+			}
+		})
+	}
+}
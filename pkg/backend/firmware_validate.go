@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateGetNvmeFirmwareRequest(in *GetNvmeFirmwareRequest) error {
+	// check required fields
+	if in.Device == "" {
+		return errors.New("missing required field: device")
+	}
+	return nil
+}
+
+func (s *Server) validateUpdateNvmeFirmwareRequest(in *UpdateNvmeFirmwareRequest) error {
+	// check required fields
+	if in.Device == "" {
+		return errors.New("missing required field: device")
+	}
+	if len(in.Image) == 0 {
+		return errors.New("missing required field: image")
+	}
+	// check Image size, the firmware is transferred in dwords
+	if len(in.Image)%4 != 0 {
+		msg := fmt.Sprintf("Image size (%d) have to be a multiple of 4", len(in.Image))
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check Slot range, up to 7 slots are defined by NVMe
+	if in.Slot < 0 || in.Slot > 7 {
+		msg := fmt.Sprintf("Slot value (%d) is out of range, have to be between 0 and 7", in.Slot)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"path"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// iscsiVolumeType is the type of the volumes backed by a LUN of an iSCSI target
+const iscsiVolumeType = "iscsi"
+
+// defaultIscsiPort is the port of the iSCSI targets when the portal has none
+const defaultIscsiPort = "3260"
+
+// IscsiVolume represents a LUN of a legacy iSCSI target attached as a backend volume, which
+// frontend namespaces can use as volume_name_ref. The CHAP secret is only used to program the
+// keyring of the firmware, the bridge keeps its fingerprint and never returns it
+type IscsiVolume struct {
+	// Name of the iSCSI volume
+	Name string `json:"name"`
+	// TargetPortal is the address of the target, host or host:port, 3260 when no port is given
+	TargetPortal string `json:"targetPortal"`
+	// TargetIqn is the name of the target, iqn., eui. or naa. format
+	TargetIqn string `json:"targetIqn"`
+	// Lun is the logical unit of the target
+	Lun int32 `json:"lun"`
+	// InitiatorIqn is the name the DPU logs in with, the ACLs of the target have to allow it
+	InitiatorIqn string `json:"initiatorIqn"`
+	// ChapUser is the CHAP user name, set when the target requires CHAP
+	ChapUser string `json:"chapUser,omitempty"`
+	// ChapSecret is the CHAP secret, 12 to 16 characters, input only
+	ChapSecret string `json:"chapSecret,omitempty"`
+	// ChapSecretFingerprint is the SHA-256 of the CHAP secret, output only
+	ChapSecretFingerprint string `json:"chapSecretFingerprint,omitempty"`
+	// BlockSize is the block size of the LUN, output only
+	BlockSize int64 `json:"blockSize"`
+	// BlocksCount is the size of the LUN in blocks, output only
+	BlocksCount int64 `json:"blocksCount"`
+	// UUID of the volume, output only
+	UUID string `json:"uuid"`
+}
+
+// CreateIscsiVolumeRequest represents a request to attach a LUN of an iSCSI target
+type CreateIscsiVolumeRequest struct {
+	// IscsiVolumeID is the ID of the iSCSI volume, generated when empty
+	IscsiVolumeID string `json:"iscsiVolumeId"`
+	// IscsiVolume to attach
+	IscsiVolume *IscsiVolume `json:"iscsiVolume"`
+}
+
+// DeleteIscsiVolumeRequest represents a request to detach a LUN of an iSCSI target
+type DeleteIscsiVolumeRequest struct {
+	// Name of the iSCSI volume
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the iSCSI volume doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetIscsiVolumeRequest represents a request to get an iSCSI volume
+type GetIscsiVolumeRequest struct {
+	// Name of the iSCSI volume
+	Name string `json:"name"`
+}
+
+// ListIscsiVolumesRequest represents a request to list iSCSI volumes
+type ListIscsiVolumesRequest struct {
+	// PageSize is the maximum number of iSCSI volumes returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListIscsiVolumesResponse represents a list of iSCSI volumes
+type ListIscsiVolumesResponse struct {
+	// IscsiVolumes is the page of iSCSI volumes
+	IscsiVolumes []*IscsiVolume `json:"iscsiVolumes"`
+	// NextPageToken is set when more iSCSI volumes are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToIscsiVolumeName builds the name of an iSCSI volume, they share the volumes collection
+// with the other backend volumes
+func resourceIDToIscsiVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"volumes", resourceID,
+	)
+}
+
+// CreateIscsiVolume logs in to an iSCSI target and attaches one of its LUNs as a backend volume,
+// so namespaces of iSCSI SANs can be migrated to NVMe emulation
+func (s *Server) CreateIscsiVolume(ctx context.Context, in *CreateIscsiVolumeRequest) (*IscsiVolume, error) {
+	// check input correctness
+	if err := s.validateCreateIscsiVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.IscsiVolumeID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.IscsiVolumeID, "name", in.IscsiVolume.Name)
+		resourceID = in.IscsiVolumeID
+	}
+	name := resourceIDToIscsiVolumeName(resourceID)
+	if err := s.checkVolumeType(name, iscsiVolumeType); err != nil {
+		return nil, err
+	}
+	// idempotent API when called with same key, should return same object
+	volume, found, err := s.getIscsiVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing IscsiVolume", "name", name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	params := models.MrvlBdevIscsiCreateParams{
+		Name:         resourceID,
+		URL:          iscsiURL(in.IscsiVolume),
+		InitiatorIqn: in.IscsiVolume.InitiatorIqn,
+	}
+	volume = &IscsiVolume{
+		Name:         name,
+		TargetPortal: in.IscsiVolume.TargetPortal,
+		TargetIqn:    in.IscsiVolume.TargetIqn,
+		Lun:          in.IscsiVolume.Lun,
+		InitiatorIqn: in.IscsiVolume.InitiatorIqn,
+	}
+	if in.IscsiVolume.ChapUser != "" {
+		if err := s.addKeyringKey(ctx, iscsiChapKeyName(resourceID), in.IscsiVolume.ChapSecret); err != nil {
+			return nil, err
+		}
+		params.ChapUser = in.IscsiVolume.ChapUser
+		params.ChapKeyName = iscsiChapKeyName(resourceID)
+		sum := sha256.Sum256([]byte(in.IscsiVolume.ChapSecret))
+		volume.ChapUser = in.IscsiVolume.ChapUser
+		volume.ChapSecretFingerprint = hex.EncodeToString(sum[:])
+	}
+	var result models.MrvlBdevIscsiCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_iscsi_create", &params, &result)
+	if err == nil {
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not create Iscsi Volume: %s", resourceID)
+			err = result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_iscsi_create")
+		}
+	}
+	if err != nil {
+		if params.ChapKeyName != "" {
+			if err := s.removeKeyringKey(ctx, params.ChapKeyName); err != nil {
+				slog.Error("Could not roll back key", "name", name, "error", err)
+			}
+		}
+		return nil, err
+	}
+	volume.BlockSize = result.BlockSize
+	volume.BlocksCount = result.NumBlocks
+	volume.UUID = result.UUID
+	if err := s.saveIscsiVolume(volume); err != nil {
+		return nil, err
+	}
+	s.volumeTypes[name] = iscsiVolumeType
+	return volume, nil
+}
+
+// DeleteIscsiVolume detaches a LUN of an iSCSI target and logs out, the LUN is left untouched
+func (s *Server) DeleteIscsiVolume(ctx context.Context, in *DeleteIscsiVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteIscsiVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, iscsiVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getIscsiVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevIscsiDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevIscsiDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_iscsi_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Iscsi Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_iscsi_delete")
+	}
+	if volume.ChapUser != "" {
+		if err := s.removeKeyringKey(ctx, iscsiChapKeyName(resourceID)); err != nil {
+			return nil, err
+		}
+	}
+	// remove from the Database
+	delete(s.volumeTypes, volume.Name)
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListIscsiVolumes lists iSCSI volumes
+func (s *Server) ListIscsiVolumes(_ context.Context, in *ListIscsiVolumesRequest) (*ListIscsiVolumesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.volumeNames(iscsiVolumeType)
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*IscsiVolume, 0, len(names))
+	for _, name := range names {
+		volume, found, err := s.getIscsiVolume(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, volume)
+	}
+	return &ListIscsiVolumesResponse{IscsiVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetIscsiVolume gets an iSCSI volume
+func (s *Server) GetIscsiVolume(_ context.Context, in *GetIscsiVolumeRequest) (*IscsiVolume, error) {
+	// check input correctness
+	if err := s.validateGetIscsiVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, iscsiVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getIscsiVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// iscsiURL builds the URL of a LUN the firmware logs in to
+func iscsiURL(volume *IscsiVolume) string {
+	portal := volume.TargetPortal
+	if _, _, err := net.SplitHostPort(portal); err != nil {
+		portal = net.JoinHostPort(portal, defaultIscsiPort)
+	}
+	return fmt.Sprintf("iscsi://%s/%s/%d", portal, volume.TargetIqn, volume.Lun)
+}
+
+// iscsiChapKeyName is the name of the keyring key holding the CHAP secret of an iSCSI volume
+func iscsiChapKeyName(resourceID string) string {
+	return "iscsi_chap_" + resourceID
+}
+
+// getIscsiVolume fetches an iSCSI volume from the database,
+// iSCSI volumes are not protobufs so they are stored JSON encoded
+func (s *Server) getIscsiVolume(name string) (*IscsiVolume, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	volume := new(IscsiVolume)
+	if err := json.Unmarshal(value.Value, volume); err != nil {
+		return nil, false, err
+	}
+	return volume, true, nil
+}
+
+func (s *Server) saveIscsiVolume(volume *IscsiVolume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(volume.Name, wrapperspb.Bytes(data))
+}
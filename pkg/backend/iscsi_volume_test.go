@@ -0,0 +1,340 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	testIscsiVolumeID   = "iscsi0"
+	testIscsiVolumeName = resourceIDToIscsiVolumeName(testIscsiVolumeID)
+	testIscsiChapSecret = "0123456789abcdef"
+	testIscsiVolume     = IscsiVolume{
+		Name:                  testIscsiVolumeName,
+		TargetPortal:          "10.10.30.1",
+		TargetIqn:             "iqn.2003-01.org.linux-iscsi.target:sn.1234",
+		Lun:                   1,
+		InitiatorIqn:          "iqn.2024-01.com.marvell:dpu0",
+		ChapUser:              "dpu0",
+		ChapSecretFingerprint: dhchapFingerprint(testIscsiChapSecret),
+		BlockSize:             512,
+		BlocksCount:           2097152,
+		UUID:                  "4d2a7c1e-5b3f-4e86-9a0d-1c6e8f2b7a53",
+	}
+)
+
+func TestBackEnd_CreateIscsiVolume(t *testing.T) {
+	valid := &IscsiVolume{
+		TargetPortal: testIscsiVolume.TargetPortal,
+		TargetIqn:    testIscsiVolume.TargetIqn,
+		Lun:          testIscsiVolume.Lun,
+		InitiatorIqn: testIscsiVolume.InitiatorIqn,
+		ChapUser:     testIscsiVolume.ChapUser,
+		ChapSecret:   testIscsiChapSecret,
+	}
+	tests := map[string]struct {
+		in      *IscsiVolume
+		out     *IscsiVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   string
+	}{
+		"missing target portal": {
+			in:      &IscsiVolume{TargetIqn: valid.TargetIqn, InitiatorIqn: valid.InitiatorIqn},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: iscsi_volume.target_portal",
+		},
+		"missing initiator iqn": {
+			in:      &IscsiVolume{TargetPortal: valid.TargetPortal, TargetIqn: valid.TargetIqn},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: iscsi_volume.initiator_iqn",
+		},
+		"malformed target portal": {
+			in:      &IscsiVolume{TargetPortal: "10.10.30.1/24", TargetIqn: valid.TargetIqn, InitiatorIqn: valid.InitiatorIqn},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("TargetPortal value (%s) is not a host or a host:port", "10.10.30.1/24"),
+		},
+		"malformed target iqn": {
+			in:      &IscsiVolume{TargetPortal: valid.TargetPortal, TargetIqn: "target0", InitiatorIqn: valid.InitiatorIqn},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Iqn value (%s) is not an iSCSI name, have to start with iqn., eui. or naa.", "target0"),
+		},
+		"negative lun": {
+			in:      &IscsiVolume{TargetPortal: valid.TargetPortal, TargetIqn: valid.TargetIqn, InitiatorIqn: valid.InitiatorIqn, Lun: -1},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Lun value (%d) is not supported, have to be between 0 and %d", -1, maxIscsiLun),
+		},
+		"chap user without secret": {
+			in:      &IscsiVolume{TargetPortal: valid.TargetPortal, TargetIqn: valid.TargetIqn, InitiatorIqn: valid.InitiatorIqn, ChapUser: valid.ChapUser},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "ChapUser and ChapSecret have to be set together",
+		},
+		"short chap secret": {
+			in:      &IscsiVolume{TargetPortal: valid.TargetPortal, TargetIqn: valid.TargetIqn, InitiatorIqn: valid.InitiatorIqn, ChapUser: valid.ChapUser, ChapSecret: "secret"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "ChapSecret is not supported, have to be 12 to 16 characters long",
+		},
+		"keyring failure": {
+			in:      valid,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not add key %v to the keyring", iscsiChapKeyName(testIscsiVolumeID)),
+		},
+		"valid request with invalid SPDK response": {
+			in:      valid,
+			out:     nil,
+			spdk:    []string{testSuccessResponse, testFailureResponse, testSuccessResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Iscsi Volume: %v", testIscsiVolumeID),
+		},
+		"valid request with valid SPDK response": {
+			in:      valid,
+			out:     &testIscsiVolume,
+			spdk:    []string{testSuccessResponse, `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "4d2a7c1e-5b3f-4e86-9a0d-1c6e8f2b7a53", "block_size": 512, "num_blocks": 2097152}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      valid,
+			out:     &testIscsiVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   iscsiVolumeType,
+		},
+		"already exists as rbd volume": {
+			in:      valid,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testIscsiVolumeName, rbdVolumeType, iscsiVolumeType),
+			exist:   rbdVolumeType,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist != "" {
+				testEnv.opiSpdkServer.volumeTypes[testIscsiVolumeName] = tt.exist
+			}
+			if tt.exist == iscsiVolumeType {
+				_ = testEnv.opiSpdkServer.saveIscsiVolume(&testIscsiVolume)
+			}
+
+			request := &CreateIscsiVolumeRequest{IscsiVolume: tt.in, IscsiVolumeID: testIscsiVolumeID}
+			response, err := testEnv.opiSpdkServer.CreateIscsiVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_IscsiURL(t *testing.T) {
+	tests := map[string]struct {
+		in  string
+		out string
+	}{
+		"host":      {in: "10.10.30.1", out: "iscsi://10.10.30.1:3260/iqn.2003-01.org.linux-iscsi.target:sn.1234/1"},
+		"host:port": {in: "10.10.30.1:3261", out: "iscsi://10.10.30.1:3261/iqn.2003-01.org.linux-iscsi.target:sn.1234/1"},
+		"ipv6":      {in: "fd00::1", out: "iscsi://[fd00::1]:3260/iqn.2003-01.org.linux-iscsi.target:sn.1234/1"},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			volume := testIscsiVolume
+			volume.TargetPortal = tt.in
+			if url := iscsiURL(&volume); url != tt.out {
+				t.Error("url: expected", tt.out, "received", url)
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteIscsiVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testIscsiVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Iscsi Volume: %v", testIscsiVolumeID),
+		},
+		"keyring failure": {
+			in:      testIscsiVolumeName,
+			out:     nil,
+			spdk:    []string{testSuccessResponse, testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not remove key %v from the keyring", iscsiChapKeyName(testIscsiVolumeID)),
+		},
+		"valid request with valid SPDK response": {
+			in:      testIscsiVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse, testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"aio volume": {
+			in:      testAioVolumeName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testAioVolumeName, aioVolumeType, iscsiVolumeType),
+		},
+		"unknown key": {
+			in:      resourceIDToIscsiVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToIscsiVolumeName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToIscsiVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveIscsiVolume(&testIscsiVolume)
+			testEnv.opiSpdkServer.volumeTypes[testIscsiVolumeName] = iscsiVolumeType
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &DeleteIscsiVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteIscsiVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListIscsiVolumes(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveIscsiVolume(&testIscsiVolume)
+	testEnv.opiSpdkServer.volumeTypes[testIscsiVolumeName] = iscsiVolumeType
+	testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+	response, err := testEnv.opiSpdkServer.ListIscsiVolumes(testEnv.ctx, &ListIscsiVolumesRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	expected := &ListIscsiVolumesResponse{IscsiVolumes: []*IscsiVolume{&testIscsiVolume}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Error("response: expected", expected, "received", response)
+	}
+}
+
+func TestBackEnd_GetIscsiVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *IscsiVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testIscsiVolumeName,
+			out:     &testIscsiVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      resourceIDToIscsiVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToIscsiVolumeName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveIscsiVolume(&testIscsiVolume)
+			testEnv.opiSpdkServer.volumeTypes[testIscsiVolumeName] = iscsiVolumeType
+
+			request := &GetIscsiVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetIscsiVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxIscsiLun is the highest LUN of the flat addressing of SAM
+const maxIscsiLun = 16383
+
+func (s *Server) validateCreateIscsiVolumeRequest(in *CreateIscsiVolumeRequest) error {
+	// check required fields
+	if in.IscsiVolume == nil {
+		return errors.New("missing required field: iscsi_volume")
+	}
+	if in.IscsiVolume.TargetPortal == "" {
+		return errors.New("missing required field: iscsi_volume.target_portal")
+	}
+	if in.IscsiVolume.TargetIqn == "" {
+		return errors.New("missing required field: iscsi_volume.target_iqn")
+	}
+	if in.IscsiVolume.InitiatorIqn == "" {
+		return errors.New("missing required field: iscsi_volume.initiator_iqn")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.IscsiVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(in.IscsiVolumeID); err != nil {
+			return err
+		}
+	}
+	// check TargetPortal, the firmware gets it in the URL of the LUN
+	host := in.IscsiVolume.TargetPortal
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" || strings.ContainsAny(host, "/[] ") {
+		msg := fmt.Sprintf("TargetPortal value (%s) is not a host or a host:port", in.IscsiVolume.TargetPortal)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check the names, see RFC 3720 iSCSI Names
+	for _, iqn := range []string{in.IscsiVolume.TargetIqn, in.IscsiVolume.InitiatorIqn} {
+		if !isIscsiName(iqn) {
+			msg := fmt.Sprintf("Iqn value (%s) is not an iSCSI name, have to start with iqn., eui. or naa.", iqn)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	// check Lun
+	if in.IscsiVolume.Lun < 0 || in.IscsiVolume.Lun > maxIscsiLun {
+		msg := fmt.Sprintf("Lun value (%d) is not supported, have to be between 0 and %d", in.IscsiVolume.Lun, maxIscsiLun)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check CHAP, the user and the secret go together, without logging the secret
+	if (in.IscsiVolume.ChapUser == "") != (in.IscsiVolume.ChapSecret == "") {
+		msg := "ChapUser and ChapSecret have to be set together"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.IscsiVolume.ChapSecret != "" && (len(in.IscsiVolume.ChapSecret) < 12 || len(in.IscsiVolume.ChapSecret) > 16) {
+		msg := "ChapSecret is not supported, have to be 12 to 16 characters long"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteIscsiVolumeRequest(in *DeleteIscsiVolumeRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetIscsiVolumeRequest(in *GetIscsiVolumeRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+// isIscsiName tells if a name has one of the iSCSI name formats, without spaces or slashes
+// which would break the URL of the LUN
+func isIscsiName(name string) bool {
+	if strings.ContainsAny(name, "/ ") {
+		return false
+	}
+	for _, prefix := range []string{"iqn.", "eui.", "naa."} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// addKeyringKey loads a secret in the keyring of the firmware, the connections refer to
+// the secrets by their key name so they don't travel in the attach requests
+func (s *Server) addKeyringKey(ctx context.Context, name string, key string) error {
+	params := models.MrvlKeyringAddKeyParams{
+		Name: name,
+		Key:  key,
+	}
+	var result models.MrvlKeyringAddKeyResult
+	err := s.rpc.Call(ctx, "mrvl_keyring_add_key", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not add key %s to the keyring", name)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_keyring_add_key")
+	}
+	return nil
+}
+
+// removeKeyringKey removes a secret from the keyring of the firmware
+func (s *Server) removeKeyringKey(ctx context.Context, name string) error {
+	params := models.MrvlKeyringRemoveKeyParams{
+		Name: name,
+	}
+	var result models.MrvlKeyringRemoveKeyResult
+	err := s.rpc.Call(ctx, "mrvl_keyring_remove_key", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not remove key %s from the keyring", name)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_keyring_remove_key")
+	}
+	return nil
+}
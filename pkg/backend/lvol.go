@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// lvolVolumeType is the type of the volumes carved from an lvol store
+const lvolVolumeType = "lvol"
+
+// Lvol represents a logical volume carved from an lvol store, it is a backend volume which
+// frontend namespaces can use as volume_name_ref
+type Lvol struct {
+	// Name of the lvol
+	Name string `json:"name"`
+	// LvolStore is the name of the lvol store the lvol is carved from
+	LvolStore string `json:"lvolStore"`
+	// SizeBytes is the size of the lvol, rounded up to the cluster size of the lvol store
+	SizeBytes int64 `json:"sizeBytes"`
+	// ThinProvisioned lvols only allocate clusters when they are written
+	ThinProvisioned bool `json:"thinProvisioned"`
+	// UUID of the lvol, output only
+	UUID string `json:"uuid"`
+}
+
+// CreateLvolRequest represents a request to create an lvol
+type CreateLvolRequest struct {
+	// LvolID is the ID of the lvol, generated when empty
+	LvolID string `json:"lvolId"`
+	// Lvol to create
+	Lvol *Lvol `json:"lvol"`
+}
+
+// DeleteLvolRequest represents a request to delete an lvol
+type DeleteLvolRequest struct {
+	// Name of the lvol
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the lvol doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetLvolRequest represents a request to get an lvol
+type GetLvolRequest struct {
+	// Name of the lvol
+	Name string `json:"name"`
+}
+
+// ListLvolsRequest represents a request to list lvols
+type ListLvolsRequest struct {
+	// PageSize is the maximum number of lvols returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListLvolsResponse represents a list of lvols
+type ListLvolsResponse struct {
+	// Lvols is the page of lvols
+	Lvols []*Lvol `json:"lvols"`
+	// NextPageToken is set when more lvols are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToLvolName builds the name of an lvol, lvols are volumes like the Aio, Null and
+// Malloc volumes
+func resourceIDToLvolName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"volumes", resourceID,
+	)
+}
+
+// CreateLvol carves an lvol from an lvol store
+func (s *Server) CreateLvol(ctx context.Context, in *CreateLvolRequest) (*Lvol, error) {
+	// check input correctness
+	if err := s.validateCreateLvolRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.LvolID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.LvolID, "name", in.Lvol.Name)
+		resourceID = in.LvolID
+	}
+	name := resourceIDToLvolName(resourceID)
+	if err := s.checkVolumeType(name, lvolVolumeType); err != nil {
+		return nil, err
+	}
+	// idempotent API when called with same key, should return same object
+	lvol, found, err := s.getLvol(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing Lvol", "name", name)
+		return lvol, nil
+	}
+	// not found, so create a new one
+	_, found, err = s.getLvolStore(in.Lvol.LvolStore)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Lvol.LvolStore)
+		return nil, err
+	}
+	params := models.MrvlLvolCreateParams{
+		LvsName:       path.Base(in.Lvol.LvolStore),
+		LvolName:      resourceID,
+		Size:          in.Lvol.SizeBytes,
+		ThinProvision: in.Lvol.ThinProvisioned,
+	}
+	var result models.MrvlLvolCreateResult
+	err = s.rpc.Call(ctx, "mrvl_lvol_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Lvol: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_lvol_create")
+	}
+	lvol = &Lvol{
+		Name:            name,
+		LvolStore:       in.Lvol.LvolStore,
+		SizeBytes:       in.Lvol.SizeBytes,
+		ThinProvisioned: in.Lvol.ThinProvisioned,
+		UUID:            result.UUID,
+	}
+	if err := s.saveLvol(lvol); err != nil {
+		return nil, err
+	}
+	s.volumeTypes[name] = lvolVolumeType
+	return lvol, nil
+}
+
+// DeleteLvol deletes an lvol, its clusters are returned to the lvol store
+func (s *Server) DeleteLvol(ctx context.Context, in *DeleteLvolRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteLvolRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, lvolVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	lvol, found, err := s.getLvol(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(lvol.Name)
+	params := models.MrvlLvolDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlLvolDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_lvol_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Lvol: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_lvol_delete")
+	}
+	// remove from the Database
+	delete(s.volumeTypes, lvol.Name)
+	err = s.store.Delete(lvol.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListLvols lists lvols, of all the lvol stores
+func (s *Server) ListLvols(_ context.Context, in *ListLvolsRequest) (*ListLvolsResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.volumeNames(lvolVolumeType)
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*Lvol, 0, len(names))
+	for _, name := range names {
+		lvol, found, err := s.getLvol(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, lvol)
+	}
+	return &ListLvolsResponse{Lvols: Blobarray, NextPageToken: token}, nil
+}
+
+// GetLvol gets an lvol, the space it allocated is reported by the volume allocation
+func (s *Server) GetLvol(_ context.Context, in *GetLvolRequest) (*Lvol, error) {
+	// check input correctness
+	if err := s.validateGetLvolRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, lvolVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	lvol, found, err := s.getLvol(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return lvol, nil
+}
+
+// lvolNames returns the sorted names of the lvols carved from an lvol store
+func (s *Server) lvolNames(lvsName string) ([]string, error) {
+	var names []string
+	for _, name := range s.volumeNames(lvolVolumeType) {
+		lvol, found, err := s.getLvol(name)
+		if err != nil {
+			return nil, err
+		}
+		if found && lvol.LvolStore == lvsName {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// getLvol fetches an lvol from the database,
+// lvols are not protobufs so they are stored JSON encoded
+func (s *Server) getLvol(name string) (*Lvol, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	lvol := new(Lvol)
+	if err := json.Unmarshal(value.Value, lvol); err != nil {
+		return nil, false, err
+	}
+	return lvol, true, nil
+}
+
+func (s *Server) saveLvol(lvol *Lvol) error {
+	data, err := json.Marshal(lvol)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(lvol.Name, wrapperspb.Bytes(data))
+}
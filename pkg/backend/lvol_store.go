@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// LvolStore represents a logical volume store on an NVMe device attached to the DPU, lvols
+// are carved from it as backend volumes and it is reported as a pool
+type LvolStore struct {
+	// Name of the lvol store
+	Name string `json:"name"`
+	// Device is the name of the NVMe device attached to the DPU the lvol store is created on,
+	// its content is lost
+	Device string `json:"device"`
+	// ClusterSizeBytes is the allocation unit of the lvols, a power of 2, 4 MiB when 0
+	ClusterSizeBytes int64 `json:"clusterSizeBytes"`
+	// CapacityBytes is the space available to the lvols, output only
+	CapacityBytes int64 `json:"capacityBytes"`
+	// UUID of the lvol store, output only
+	UUID string `json:"uuid"`
+}
+
+// CreateLvolStoreRequest represents a request to create an lvol store
+type CreateLvolStoreRequest struct {
+	// LvolStoreID is the ID of the lvol store, generated when empty
+	LvolStoreID string `json:"lvolStoreId"`
+	// LvolStore to create
+	LvolStore *LvolStore `json:"lvolStore"`
+}
+
+// DeleteLvolStoreRequest represents a request to delete an lvol store
+type DeleteLvolStoreRequest struct {
+	// Name of the lvol store
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the lvol store doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetLvolStoreRequest represents a request to get an lvol store
+type GetLvolStoreRequest struct {
+	// Name of the lvol store
+	Name string `json:"name"`
+}
+
+// ListLvolStoresRequest represents a request to list lvol stores
+type ListLvolStoresRequest struct {
+	// PageSize is the maximum number of lvol stores returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListLvolStoresResponse represents a list of lvol stores
+type ListLvolStoresResponse struct {
+	// LvolStores is the page of lvol stores
+	LvolStores []*LvolStore `json:"lvolStores"`
+	// NextPageToken is set when more lvol stores are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToLvolStoreName builds the name of an lvol store, they have their own collection
+// as they are not part of the OPI APIs
+func resourceIDToLvolStoreName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"lvolStores", resourceID,
+	)
+}
+
+// CreateLvolStore creates an lvol store on an NVMe device attached to the DPU
+func (s *Server) CreateLvolStore(ctx context.Context, in *CreateLvolStoreRequest) (*LvolStore, error) {
+	// check input correctness
+	if err := s.validateCreateLvolStoreRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.LvolStoreID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.LvolStoreID, "name", in.LvolStore.Name)
+		resourceID = in.LvolStoreID
+	}
+	name := resourceIDToLvolStoreName(resourceID)
+	// idempotent API when called with same key, should return same object
+	lvs, found, err := s.getLvolStore(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing LvolStore", "name", name)
+		return lvs, nil
+	}
+	// not found, so create a new one
+	params := models.MrvlLvolCreateLvstoreParams{
+		BdevName:  in.LvolStore.Device,
+		LvsName:   resourceID,
+		ClusterSz: in.LvolStore.ClusterSizeBytes,
+	}
+	var result models.MrvlLvolCreateLvstoreResult
+	err = s.rpc.Call(ctx, "mrvl_lvol_create_lvstore", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Lvol Store: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_lvol_create_lvstore")
+	}
+	lvs = &LvolStore{
+		Name:             name,
+		Device:           in.LvolStore.Device,
+		ClusterSizeBytes: result.ClusterSize,
+		CapacityBytes:    result.ClusterSize * result.TotalDataClusters,
+		UUID:             result.UUID,
+	}
+	if err := s.saveLvolStore(lvs); err != nil {
+		return nil, err
+	}
+	s.ListHelper[name] = false
+	return lvs, nil
+}
+
+// DeleteLvolStore deletes an lvol store, its lvols have to be deleted first
+func (s *Server) DeleteLvolStore(ctx context.Context, in *DeleteLvolStoreRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteLvolStoreRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	lvs, found, err := s.getLvolStore(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	lvols, err := s.lvolNames(lvs.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(lvols) != 0 {
+		msg := fmt.Sprintf("LvolStore %v still has %d lvols, delete them first", lvs.Name, len(lvols))
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	resourceID := path.Base(lvs.Name)
+	params := models.MrvlLvolDeleteLvstoreParams{
+		LvsName: resourceID,
+	}
+	var result models.MrvlLvolDeleteLvstoreResult
+	err = s.rpc.Call(ctx, "mrvl_lvol_delete_lvstore", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Lvol Store: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_lvol_delete_lvstore")
+	}
+	// remove from the Database
+	delete(s.ListHelper, lvs.Name)
+	err = s.store.Delete(lvs.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListLvolStores lists lvol stores
+func (s *Server) ListLvolStores(_ context.Context, in *ListLvolStoresRequest) (*ListLvolStoresResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.lvolStoreNames()
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*LvolStore, 0, len(names))
+	for _, name := range names {
+		lvs, found, err := s.getLvolStore(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, lvs)
+	}
+	return &ListLvolStoresResponse{LvolStores: Blobarray, NextPageToken: token}, nil
+}
+
+// GetLvolStore gets an lvol store, the space used by its lvols is reported by the pools
+func (s *Server) GetLvolStore(_ context.Context, in *GetLvolStoreRequest) (*LvolStore, error) {
+	// check input correctness
+	if err := s.validateGetLvolStoreRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	lvs, found, err := s.getLvolStore(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return lvs, nil
+}
+
+// lvolStoreNames returns the sorted names of the lvol stores
+func (s *Server) lvolStoreNames() []string {
+	prefix := resourceIDToLvolStoreName("") + "/"
+	var names []string
+	for key := range s.ListHelper {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getLvolStore fetches an lvol store from the database,
+// lvol stores are not protobufs so they are stored JSON encoded
+func (s *Server) getLvolStore(name string) (*LvolStore, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	lvs := new(LvolStore)
+	if err := json.Unmarshal(value.Value, lvs); err != nil {
+		return nil, false, err
+	}
+	return lvs, true, nil
+}
+
+func (s *Server) saveLvolStore(lvs *LvolStore) error {
+	data, err := json.Marshal(lvs)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(lvs.Name, wrapperspb.Bytes(data))
+}
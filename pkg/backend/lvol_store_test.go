@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	testLvolStoreID   = "lvs0"
+	testLvolStoreName = resourceIDToLvolStoreName(testLvolStoreID)
+	testLvolStore     = LvolStore{
+		Name:             testLvolStoreName,
+		Device:           "nvme0n1",
+		ClusterSizeBytes: 4194304,
+		CapacityBytes:    4194304 * 1000,
+		UUID:             "a9e0c7c4-4f8e-4d0a-b0a4-0e3cf5a4c9a1",
+	}
+)
+
+func TestBackEnd_CreateLvolStore(t *testing.T) {
+	tests := map[string]struct {
+		in      *LvolStore
+		out     *LvolStore
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"missing device": {
+			in:      &LvolStore{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: lvol_store.device",
+		},
+		"cluster size not a power of 2": {
+			in:      &LvolStore{Device: testLvolStore.Device, ClusterSizeBytes: 3000000},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("ClusterSizeBytes value (%d) is not supported, have to be a power of 2 between 4096 and %d", 3000000, maxLvolStoreClusterSize),
+		},
+		"valid request with invalid SPDK response": {
+			in:      &LvolStore{Device: testLvolStore.Device},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Lvol Store: %v", testLvolStoreID),
+		},
+		"valid request with valid SPDK response": {
+			in:      &LvolStore{Device: testLvolStore.Device},
+			out:     &testLvolStore,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "a9e0c7c4-4f8e-4d0a-b0a4-0e3cf5a4c9a1", "cluster_size": 4194304, "total_data_clusters": 1000}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      &LvolStore{Device: testLvolStore.Device},
+			out:     &testLvolStore,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveLvolStore(&testLvolStore)
+				testEnv.opiSpdkServer.ListHelper[testLvolStoreName] = false
+			}
+
+			request := &CreateLvolStoreRequest{LvolStore: tt.in, LvolStoreID: testLvolStoreID}
+			response, err := testEnv.opiSpdkServer.CreateLvolStore(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteLvolStore(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+		lvols   bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testLvolStoreName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Lvol Store: %v", testLvolStoreID),
+		},
+		"valid request with valid SPDK response": {
+			in:      testLvolStoreName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"lvol store with lvols": {
+			in:      testLvolStoreName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("LvolStore %v still has %d lvols, delete them first", testLvolStoreName, 1),
+			lvols:   true,
+		},
+		"unknown key": {
+			in:      resourceIDToLvolStoreName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToLvolStoreName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToLvolStoreName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveLvolStore(&testLvolStore)
+			testEnv.opiSpdkServer.ListHelper[testLvolStoreName] = false
+			if tt.lvols {
+				_ = testEnv.opiSpdkServer.saveLvol(&testLvol)
+				testEnv.opiSpdkServer.volumeTypes[testLvolName] = lvolVolumeType
+			}
+
+			request := &DeleteLvolStoreRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteLvolStore(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListLvolStores(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveLvolStore(&testLvolStore)
+	testEnv.opiSpdkServer.ListHelper[testLvolStoreName] = false
+	_ = testEnv.opiSpdkServer.saveTLSPsk(&testTLSPsk)
+	testEnv.opiSpdkServer.ListHelper[testTLSPskName] = false
+
+	response, err := testEnv.opiSpdkServer.ListLvolStores(testEnv.ctx, &ListLvolStoresRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	expected := &ListLvolStoresResponse{LvolStores: []*LvolStore{&testLvolStore}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Error("response: expected", expected, "received", response)
+	}
+}
+
+func TestBackEnd_GetLvolStore(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *LvolStore
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testLvolStoreName,
+			out:     &testLvolStore,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      resourceIDToLvolStoreName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToLvolStoreName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveLvolStore(&testLvolStore)
+
+			request := &GetLvolStoreRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetLvolStore(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
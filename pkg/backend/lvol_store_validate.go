@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxLvolStoreClusterSize is the largest cluster size of an lvol store, 1 GiB
+const maxLvolStoreClusterSize = 1 << 30
+
+func (s *Server) validateCreateLvolStoreRequest(in *CreateLvolStoreRequest) error {
+	// check required fields
+	if in.LvolStore == nil {
+		return errors.New("missing required field: lvol_store")
+	}
+	if in.LvolStore.Device == "" {
+		return errors.New("missing required field: lvol_store.device")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.LvolStoreID != "" {
+		if err := resourceid.ValidateUserSettable(in.LvolStoreID); err != nil {
+			return err
+		}
+	}
+	// check ClusterSizeBytes, 0 uses the default of the firmware
+	size := in.LvolStore.ClusterSizeBytes
+	if size != 0 && (size < 4096 || size > maxLvolStoreClusterSize || size&(size-1) != 0) {
+		msg := fmt.Sprintf("ClusterSizeBytes value (%d) is not supported, have to be a power of 2 between 4096 and %d", size, maxLvolStoreClusterSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteLvolStoreRequest(in *DeleteLvolStoreRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetLvolStoreRequest(in *GetLvolStoreRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
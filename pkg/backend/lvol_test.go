@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	testLvolID   = "lvol0"
+	testLvolName = resourceIDToLvolName(testLvolID)
+	testLvol     = Lvol{
+		Name:            testLvolName,
+		LvolStore:       testLvolStoreName,
+		SizeBytes:       1073741824,
+		ThinProvisioned: true,
+		UUID:            "3f1d5e2a-8c7b-4a61-9e0f-6d2b4c8a7e15",
+	}
+)
+
+func TestBackEnd_CreateLvol(t *testing.T) {
+	tests := map[string]struct {
+		in      *Lvol
+		out     *Lvol
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   string
+	}{
+		"missing lvol store": {
+			in:      &Lvol{SizeBytes: testLvol.SizeBytes},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: lvol.lvol_store",
+		},
+		"zero size": {
+			in:      &Lvol{LvolStore: testLvolStoreName},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("SizeBytes value (%d) has to be positive", 0),
+		},
+		"unknown lvol store": {
+			in:      &Lvol{LvolStore: resourceIDToLvolStoreName("unknown-id"), SizeBytes: testLvol.SizeBytes},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToLvolStoreName("unknown-id")),
+		},
+		"valid request with invalid SPDK response": {
+			in:      &Lvol{LvolStore: testLvolStoreName, SizeBytes: testLvol.SizeBytes, ThinProvisioned: true},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Lvol: %v", testLvolID),
+		},
+		"valid request with valid SPDK response": {
+			in:      &Lvol{LvolStore: testLvolStoreName, SizeBytes: testLvol.SizeBytes, ThinProvisioned: true},
+			out:     &testLvol,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "3f1d5e2a-8c7b-4a61-9e0f-6d2b4c8a7e15"}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      &Lvol{LvolStore: testLvolStoreName, SizeBytes: testLvol.SizeBytes, ThinProvisioned: true},
+			out:     &testLvol,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   lvolVolumeType,
+		},
+		"already exists as malloc volume": {
+			in:      &Lvol{LvolStore: testLvolStoreName, SizeBytes: testLvol.SizeBytes, ThinProvisioned: true},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testLvolName, mallocVolumeType, lvolVolumeType),
+			exist:   mallocVolumeType,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveLvolStore(&testLvolStore)
+			testEnv.opiSpdkServer.ListHelper[testLvolStoreName] = false
+			if tt.exist != "" {
+				testEnv.opiSpdkServer.volumeTypes[testLvolName] = tt.exist
+			}
+			if tt.exist == lvolVolumeType {
+				_ = testEnv.opiSpdkServer.saveLvol(&testLvol)
+			}
+
+			request := &CreateLvolRequest{Lvol: tt.in, LvolID: testLvolID}
+			response, err := testEnv.opiSpdkServer.CreateLvol(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteLvol(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testLvolName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Lvol: %v", testLvolID),
+		},
+		"valid request with valid SPDK response": {
+			in:      testLvolName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"aio volume": {
+			in:      testAioVolumeName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testAioVolumeName, aioVolumeType, lvolVolumeType),
+		},
+		"unknown key": {
+			in:      resourceIDToLvolName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToLvolName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToLvolName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveLvol(&testLvol)
+			testEnv.opiSpdkServer.volumeTypes[testLvolName] = lvolVolumeType
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &DeleteLvolRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteLvol(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListLvols(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveLvol(&testLvol)
+	testEnv.opiSpdkServer.volumeTypes[testLvolName] = lvolVolumeType
+	testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+	response, err := testEnv.opiSpdkServer.ListLvols(testEnv.ctx, &ListLvolsRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	expected := &ListLvolsResponse{Lvols: []*Lvol{&testLvol}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Error("response: expected", expected, "received", response)
+	}
+}
+
+func TestBackEnd_GetLvol(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *Lvol
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testLvolName,
+			out:     &testLvol,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      resourceIDToLvolName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToLvolName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveLvol(&testLvol)
+			testEnv.opiSpdkServer.volumeTypes[testLvolName] = lvolVolumeType
+
+			request := &GetLvolRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetLvol(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
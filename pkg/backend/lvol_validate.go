@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateLvolRequest(in *CreateLvolRequest) error {
+	// check required fields
+	if in.Lvol == nil {
+		return errors.New("missing required field: lvol")
+	}
+	if in.Lvol.LvolStore == "" {
+		return errors.New("missing required field: lvol.lvol_store")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.LvolID != "" {
+		if err := resourceid.ValidateUserSettable(in.LvolID); err != nil {
+			return err
+		}
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	if err := resourcename.Validate(in.Lvol.LvolStore); err != nil {
+		return err
+	}
+	// check SizeBytes
+	if in.Lvol.SizeBytes <= 0 {
+		msg := fmt.Sprintf("SizeBytes value (%d) has to be positive", in.Lvol.SizeBytes)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteLvolRequest(in *DeleteLvolRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetLvolRequest(in *GetLvolRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
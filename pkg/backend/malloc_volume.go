@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/fieldmask"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// mallocVolumeType is the type of the volumes in the memory of the DPU SoC
+const mallocVolumeType = "malloc"
+
+func sortMallocVolumes(volumes []*pb.MallocVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// resourceIDToMallocVolumeName builds the name of a Malloc volume from its ID
+func resourceIDToMallocVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"volumes", resourceID,
+	)
+}
+
+// CreateMallocVolume creates a volume in the memory of the DPU SoC, for functional tests and
+// demos on DPUs without storage, its content is lost when the firmware restarts
+func (s *Server) CreateMallocVolume(ctx context.Context, in *pb.CreateMallocVolumeRequest) (*pb.MallocVolume, error) {
+	// check input correctness
+	if err := s.validateCreateMallocVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.MallocVolumeId != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.MallocVolumeId, "name", in.MallocVolume.Name)
+		resourceID = in.MallocVolumeId
+	}
+	in.MallocVolume.Name = resourceIDToMallocVolumeName(resourceID)
+	if err := s.checkVolumeType(in.MallocVolume.Name, mallocVolumeType); err != nil {
+		return nil, err
+	}
+	// idempotent API when called with same key, should return same object
+	volume := new(pb.MallocVolume)
+	found, err := s.store.Get(in.MallocVolume.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing MallocVolume", "name", in.MallocVolume.Name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	params := models.MrvlBdevMallocCreateParams{
+		Name:      resourceID,
+		BlockSize: in.MallocVolume.BlockSize,
+		NumBlocks: in.MallocVolume.BlocksCount,
+		MdSize:    in.MallocVolume.MetadataSize,
+		UUID:      in.MallocVolume.Uuid,
+	}
+	var result models.MrvlBdevMallocCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_malloc_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Malloc Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_malloc_create")
+	}
+	// the firmware generates the UUID when none is given
+	response := utils.ProtoClone(in.MallocVolume)
+	response.Uuid = result.UUID
+	err = s.store.Set(in.MallocVolume.Name, response)
+	if err != nil {
+		return nil, err
+	}
+	s.volumeTypes[in.MallocVolume.Name] = mallocVolumeType
+	return response, nil
+}
+
+// DeleteMallocVolume deletes a Malloc volume
+func (s *Server) DeleteMallocVolume(ctx context.Context, in *pb.DeleteMallocVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteMallocVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, mallocVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.MallocVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevMallocDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevMallocDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_malloc_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Malloc Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_malloc_delete")
+	}
+	// remove from the Database
+	delete(s.volumeTypes, volume.Name)
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UpdateMallocVolume updates a Malloc volume
+func (s *Server) UpdateMallocVolume(ctx context.Context, in *pb.UpdateMallocVolumeRequest) (*pb.MallocVolume, error) {
+	// check input correctness
+	if err := s.validateUpdateMallocVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.MallocVolume.Name, mallocVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.MallocVolume)
+	found, err := s.store.Get(in.MallocVolume.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// see https://google.aip.dev/134#create-or-update
+		if in.AllowMissing {
+			return s.CreateMallocVolume(ctx, &pb.CreateMallocVolumeRequest{MallocVolume: in.MallocVolume, MallocVolumeId: path.Base(in.MallocVolume.Name)})
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.MallocVolume.Name)
+		return nil, err
+	}
+	// update_mask = 2
+	if err := fieldmask.Validate(in.UpdateMask, in.MallocVolume); err != nil {
+		return nil, err
+	}
+	return nil, status.Errorf(codes.Unimplemented, "UpdateMallocVolume method is not implemented")
+}
+
+// ListMallocVolumes lists Malloc volumes
+func (s *Server) ListMallocVolumes(_ context.Context, in *pb.ListMallocVolumesRequest) (*pb.ListMallocVolumesResponse, error) {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.volumeNames(mallocVolumeType)
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*pb.MallocVolume, 0, len(names))
+	for _, name := range names {
+		volume := new(pb.MallocVolume)
+		found, err := s.store.Get(name, volume)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, volume)
+	}
+	sortMallocVolumes(Blobarray)
+	return &pb.ListMallocVolumesResponse{MallocVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetMallocVolume gets a Malloc volume
+func (s *Server) GetMallocVolume(_ context.Context, in *pb.GetMallocVolumeRequest) (*pb.MallocVolume, error) {
+	// check input correctness
+	if err := s.validateGetMallocVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, mallocVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.MallocVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// StatsMallocVolume gets the IO counters of a Malloc volume
+func (s *Server) StatsMallocVolume(ctx context.Context, in *pb.StatsMallocVolumeRequest) (*pb.StatsMallocVolumeResponse, error) {
+	// check input correctness
+	if err := s.validateStatsMallocVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, mallocVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.MallocVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	params := models.MrvlBdevGetIostatParams{
+		Name: path.Base(volume.Name),
+	}
+	stats, err := s.bdevStats(ctx, &params)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StatsMallocVolumeResponse{Stats: stats}, nil
+}
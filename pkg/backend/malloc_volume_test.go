@@ -0,0 +1,516 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var (
+	testMallocVolumeID   = "malloc0"
+	testMallocVolumeName = resourceIDToMallocVolumeName(testMallocVolumeID)
+	testMallocVolume     = pb.MallocVolume{
+		Name:         testMallocVolumeName,
+		BlockSize:    512,
+		BlocksCount:  131072,
+		MetadataSize: 8,
+		Uuid:         "9d2c6a3e-41f0-4c55-8a7e-2f7c0d9b3e84",
+	}
+)
+
+func TestBackEnd_CreateMallocVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *pb.MallocVolume
+		out     *pb.MallocVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   string
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &testMallocVolume,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   "",
+		},
+		"unsupported block size": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: 520, BlocksCount: testMallocVolume.BlocksCount},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("BlockSize value (%d) is not supported, have to be 512 or 4096", 520),
+			exist:   "",
+		},
+		"negative blocks count": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: testMallocVolume.BlockSize, BlocksCount: -1},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("BlocksCount value (%d) has to be positive", -1),
+			exist:   "",
+		},
+		"unsupported metadata size": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: 12},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("MetadataSize value (%d) is not supported, have to be 0, 8, 16, 32, 64 or 128", 12),
+			exist:   "",
+		},
+		"malformed uuid": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, Uuid: "not-a-uuid"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Uuid value (%s) is not a UUID", "not-a-uuid"),
+			exist:   "",
+		},
+		"valid request with invalid SPDK response": {
+			id:      testMallocVolumeID,
+			in:      &testMallocVolume,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Malloc Volume: %v", testMallocVolumeID),
+			exist:   "",
+		},
+		"valid request with valid SPDK response": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: testMallocVolume.MetadataSize},
+			out:     &testMallocVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "9d2c6a3e-41f0-4c55-8a7e-2f7c0d9b3e84"}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   "",
+		},
+		"already exists": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: testMallocVolume.MetadataSize},
+			out:     &testMallocVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   mallocVolumeType,
+		},
+		"already exists as null volume": {
+			id:      testMallocVolumeID,
+			in:      &pb.MallocVolume{BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: testMallocVolume.MetadataSize},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testMallocVolumeName, nullVolumeType, mallocVolumeType),
+			exist:   nullVolumeType,
+		},
+		"no required field": {
+			id:      testMallocVolumeID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: malloc_volume",
+			exist:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist != "" {
+				testEnv.opiSpdkServer.volumeTypes[testMallocVolumeName] = tt.exist
+			}
+			if tt.exist == mallocVolumeType {
+				_ = testEnv.opiSpdkServer.store.Set(testMallocVolumeName, &testMallocVolume)
+			}
+			if tt.in != nil {
+				tt.in = utils.ProtoClone(tt.in)
+				tt.in.Name = ""
+			}
+
+			request := &pb.CreateMallocVolumeRequest{MallocVolume: tt.in, MallocVolumeId: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateMallocVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteMallocVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testMallocVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Malloc Volume: %v", testMallocVolumeID),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testMallocVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"aio volume": {
+			in:      testAioVolumeName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testAioVolumeName, aioVolumeType, mallocVolumeType),
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToMallocVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToMallocVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToMallocVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testMallocVolumeName, &testMallocVolume)
+			testEnv.opiSpdkServer.volumeTypes[testMallocVolumeName] = mallocVolumeType
+			_ = testEnv.opiSpdkServer.store.Set(testAioVolumeName, &testAioVolume)
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &pb.DeleteMallocVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteMallocVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_UpdateMallocVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *pb.MallocVolume
+		out     *pb.MallocVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      &pb.MallocVolume{Name: testMallocVolumeName, BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: testMallocVolume.MetadataSize},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unimplemented,
+			errMsg:  "UpdateMallocVolume method is not implemented",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      &pb.MallocVolume{Name: resourceIDToMallocVolumeName("unknown-id"), BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: testMallocVolume.MetadataSize},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToMallocVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      &pb.MallocVolume{Name: resourceIDToMallocVolumeName("unknown-id"), BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: testMallocVolume.MetadataSize},
+			out:     &pb.MallocVolume{Name: resourceIDToMallocVolumeName("unknown-id"), BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: testMallocVolume.MetadataSize, Uuid: testMallocVolume.Uuid},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "9d2c6a3e-41f0-4c55-8a7e-2f7c0d9b3e84"}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      &pb.MallocVolume{Name: "-ABC-DEF", BlockSize: testMallocVolume.BlockSize, BlocksCount: testMallocVolume.BlocksCount, MetadataSize: testMallocVolume.MetadataSize},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testMallocVolumeName, &testMallocVolume)
+			testEnv.opiSpdkServer.volumeTypes[testMallocVolumeName] = mallocVolumeType
+
+			request := &pb.UpdateMallocVolumeRequest{MallocVolume: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.UpdateMallocVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListMallocVolumes(t *testing.T) {
+	testMallocVolume1 := utils.ProtoClone(&testMallocVolume)
+	testMallocVolume1.Name = resourceIDToMallocVolumeName("malloc1")
+	tests := map[string]struct {
+		size    int32
+		token   string
+		out     []*pb.MallocVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			size:    0,
+			token:   "",
+			out:     []*pb.MallocVolume{&testMallocVolume, testMallocVolume1},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination": {
+			size:    1,
+			token:   "",
+			out:     []*pb.MallocVolume{&testMallocVolume},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination negative": {
+			size:    -10,
+			token:   "",
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+		},
+		"pagination error": {
+			size:    0,
+			token:   "unknown-pagination-token",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			for _, volume := range []*pb.MallocVolume{&testMallocVolume, testMallocVolume1} {
+				_ = testEnv.opiSpdkServer.store.Set(volume.Name, volume)
+				testEnv.opiSpdkServer.volumeTypes[volume.Name] = mallocVolumeType
+			}
+			// aio volumes share the volumes collection
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &pb.ListMallocVolumesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListMallocVolumes(testEnv.ctx, request)
+
+			if len(response.GetMallocVolumes()) != len(tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetMallocVolumes())
+			}
+			for i := range tt.out {
+				if !proto.Equal(response.GetMallocVolumes()[i], tt.out[i]) {
+					t.Error("response: expected", tt.out[i], "received", response.GetMallocVolumes()[i])
+				}
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetMallocVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.MallocVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testMallocVolumeName,
+			out:     &testMallocVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToMallocVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToMallocVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testMallocVolumeName, &testMallocVolume)
+
+			request := &pb.GetMallocVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetMallocVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_StatsMallocVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.VolumeStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testMallocVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not stats Volume: %v", testMallocVolumeID),
+		},
+		"valid request with valid SPDK response": {
+			in: testMallocVolumeName,
+			out: &pb.VolumeStats{
+				ReadBytesCount:  5120,
+				ReadOpsCount:    10,
+				WriteBytesCount: 10240,
+				WriteOpsCount:   20,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "num_read_ops": 10, "bytes_read": 5120, "num_write_ops": 20, "bytes_written": 10240}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToMallocVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToMallocVolumeName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testMallocVolumeName, &testMallocVolume)
+
+			request := &pb.StatsMallocVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsMallocVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response.GetStats(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetStats())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+// mallocMetadataSizes are the metadata sizes of the blocks of a Malloc volume, in bytes
+var mallocMetadataSizes = map[int64]bool{
+	0:   true,
+	8:   true,
+	16:  true,
+	32:  true,
+	64:  true,
+	128: true,
+}
+
+func (s *Server) validateCreateMallocVolumeRequest(in *pb.CreateMallocVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.MallocVolumeId != "" {
+		if err := resourceid.ValidateUserSettable(in.MallocVolumeId); err != nil {
+			return err
+		}
+	}
+	// check BlockSize, the emulated namespaces use 512 or 4096 bytes blocks
+	if in.MallocVolume.BlockSize != 512 && in.MallocVolume.BlockSize != 4096 {
+		msg := fmt.Sprintf("BlockSize value (%d) is not supported, have to be 512 or 4096", in.MallocVolume.BlockSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check BlocksCount
+	if in.MallocVolume.BlocksCount <= 0 {
+		msg := fmt.Sprintf("BlocksCount value (%d) has to be positive", in.MallocVolume.BlocksCount)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check MetadataSize, the metadata is interleaved with the data of the blocks
+	if !mallocMetadataSizes[in.MallocVolume.MetadataSize] {
+		msg := fmt.Sprintf("MetadataSize value (%d) is not supported, have to be 0, 8, 16, 32, 64 or 128", in.MallocVolume.MetadataSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check Uuid, the firmware generates one when it is empty
+	if in.MallocVolume.Uuid != "" {
+		if _, err := uuid.Parse(in.MallocVolume.Uuid); err != nil {
+			msg := fmt.Sprintf("Uuid value (%s) is not a UUID", in.MallocVolume.Uuid)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteMallocVolumeRequest(in *pb.DeleteMallocVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateUpdateMallocVolumeRequest(in *pb.UpdateMallocVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.MallocVolume.Name)
+}
+
+func (s *Server) validateGetMallocVolumeRequest(in *pb.GetMallocVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateStatsMallocVolumeRequest(in *pb.StatsMallocVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
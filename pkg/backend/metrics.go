@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"github.com/opiproject/opi-marvell-bridge/pkg/metrics"
+)
+
+// ResourceCounts counts the backend resources by collection, the volumes of all the types
+// are counted together as they share the volumes collection
+func (s *Server) ResourceCounts() map[string]int {
+	counts := metrics.CountCollections(s.ListHelper)
+	if len(s.volumeTypes) != 0 {
+		counts["volumes"] += len(s.volumeTypes)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.discoveryServices) != 0 {
+		counts["discoveryServices"] += len(s.discoveryServices)
+	}
+	return counts
+}
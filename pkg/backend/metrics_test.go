@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBackEnd_ResourceCounts(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+	testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+	testEnv.opiSpdkServer.ListHelper[testLvolStoreName] = false
+	testEnv.opiSpdkServer.volumeTypes[testLvolName] = lvolVolumeType
+	testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+	testEnv.opiSpdkServer.discoveryServices[testDiscoveryServiceName] = true
+
+	expected := map[string]int{
+		"nvmeRemoteControllers": 1,
+		"nvmePaths":             1,
+		"lvolStores":            1,
+		"volumes":               2,
+		"discoveryServices":     1,
+	}
+	if counts := testEnv.opiSpdkServer.ResourceCounts(); !reflect.DeepEqual(counts, expected) {
+		t.Error("counts: expected", expected, "received", counts)
+	}
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/fieldmask"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// nullVolumeType is the type of the volumes discarding the writes and reading zeroes
+const nullVolumeType = "null"
+
+func sortNullVolumes(volumes []*pb.NullVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// resourceIDToNullVolumeName builds the name of a Null volume from its ID
+func resourceIDToNullVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"volumes", resourceID,
+	)
+}
+
+// CreateNullVolume creates a volume without media, the writes are discarded and the reads
+// return zeroes, to test the performance and the scale of the emulation without drives
+func (s *Server) CreateNullVolume(ctx context.Context, in *pb.CreateNullVolumeRequest) (*pb.NullVolume, error) {
+	// check input correctness
+	if err := s.validateCreateNullVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.NullVolumeId != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.NullVolumeId, "name", in.NullVolume.Name)
+		resourceID = in.NullVolumeId
+	}
+	in.NullVolume.Name = resourceIDToNullVolumeName(resourceID)
+	if err := s.checkVolumeType(in.NullVolume.Name, nullVolumeType); err != nil {
+		return nil, err
+	}
+	// idempotent API when called with same key, should return same object
+	volume := new(pb.NullVolume)
+	found, err := s.store.Get(in.NullVolume.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing NullVolume", "name", in.NullVolume.Name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	params := models.MrvlBdevNullCreateParams{
+		Name:      resourceID,
+		BlockSize: in.NullVolume.BlockSize,
+		NumBlocks: in.NullVolume.BlocksCount,
+		UUID:      in.NullVolume.Uuid,
+	}
+	var result models.MrvlBdevNullCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_null_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Null Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_null_create")
+	}
+	// the firmware generates the UUID when none is given
+	response := utils.ProtoClone(in.NullVolume)
+	response.Uuid = result.UUID
+	err = s.store.Set(in.NullVolume.Name, response)
+	if err != nil {
+		return nil, err
+	}
+	s.volumeTypes[in.NullVolume.Name] = nullVolumeType
+	return response, nil
+}
+
+// DeleteNullVolume deletes a Null volume
+func (s *Server) DeleteNullVolume(ctx context.Context, in *pb.DeleteNullVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteNullVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, nullVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.NullVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevNullDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevNullDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_null_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Null Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_null_delete")
+	}
+	// remove from the Database
+	delete(s.volumeTypes, volume.Name)
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UpdateNullVolume updates a Null volume
+func (s *Server) UpdateNullVolume(ctx context.Context, in *pb.UpdateNullVolumeRequest) (*pb.NullVolume, error) {
+	// check input correctness
+	if err := s.validateUpdateNullVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.NullVolume.Name, nullVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.NullVolume)
+	found, err := s.store.Get(in.NullVolume.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// see https://google.aip.dev/134#create-or-update
+		if in.AllowMissing {
+			return s.CreateNullVolume(ctx, &pb.CreateNullVolumeRequest{NullVolume: in.NullVolume, NullVolumeId: path.Base(in.NullVolume.Name)})
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NullVolume.Name)
+		return nil, err
+	}
+	// update_mask = 2
+	if err := fieldmask.Validate(in.UpdateMask, in.NullVolume); err != nil {
+		return nil, err
+	}
+	return nil, status.Errorf(codes.Unimplemented, "UpdateNullVolume method is not implemented")
+}
+
+// ListNullVolumes lists Null volumes
+func (s *Server) ListNullVolumes(_ context.Context, in *pb.ListNullVolumesRequest) (*pb.ListNullVolumesResponse, error) {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.volumeNames(nullVolumeType)
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*pb.NullVolume, 0, len(names))
+	for _, name := range names {
+		volume := new(pb.NullVolume)
+		found, err := s.store.Get(name, volume)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, volume)
+	}
+	sortNullVolumes(Blobarray)
+	return &pb.ListNullVolumesResponse{NullVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetNullVolume gets a Null volume
+func (s *Server) GetNullVolume(_ context.Context, in *pb.GetNullVolumeRequest) (*pb.NullVolume, error) {
+	// check input correctness
+	if err := s.validateGetNullVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, nullVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.NullVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// StatsNullVolume gets the IO counters of a Null volume
+func (s *Server) StatsNullVolume(ctx context.Context, in *pb.StatsNullVolumeRequest) (*pb.StatsNullVolumeResponse, error) {
+	// check input correctness
+	if err := s.validateStatsNullVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, nullVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.NullVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	params := models.MrvlBdevGetIostatParams{
+		Name: path.Base(volume.Name),
+	}
+	stats, err := s.bdevStats(ctx, &params)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StatsNullVolumeResponse{Stats: stats}, nil
+}
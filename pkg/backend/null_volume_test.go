@@ -0,0 +1,506 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var (
+	testNullVolumeID   = "null0"
+	testNullVolumeName = resourceIDToNullVolumeName(testNullVolumeID)
+	testNullVolume     = pb.NullVolume{
+		Name:        testNullVolumeName,
+		BlockSize:   4096,
+		BlocksCount: 262144,
+		Uuid:        "5ac0bba0-b2f4-4e4d-9a3b-1b1c8e0c1f2d",
+	}
+)
+
+func TestBackEnd_CreateNullVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *pb.NullVolume
+		out     *pb.NullVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   string
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &testNullVolume,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   "",
+		},
+		"unsupported block size": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: 520, BlocksCount: testNullVolume.BlocksCount},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("BlockSize value (%d) is not supported, have to be 512 or 4096", 520),
+			exist:   "",
+		},
+		"negative blocks count": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: testNullVolume.BlockSize, BlocksCount: -1},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("BlocksCount value (%d) has to be positive", -1),
+			exist:   "",
+		},
+		"malformed uuid": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount, Uuid: "not-a-uuid"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Uuid value (%s) is not a UUID", "not-a-uuid"),
+			exist:   "",
+		},
+		"valid request with invalid SPDK response": {
+			id:      testNullVolumeID,
+			in:      &testNullVolume,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Null Volume: %v", testNullVolumeID),
+			exist:   "",
+		},
+		"valid request with valid SPDK response": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount},
+			out:     &testNullVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "5ac0bba0-b2f4-4e4d-9a3b-1b1c8e0c1f2d"}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   "",
+		},
+		"already exists": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount},
+			out:     &testNullVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   nullVolumeType,
+		},
+		"already exists as aio volume": {
+			id:      testNullVolumeID,
+			in:      &pb.NullVolume{BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testNullVolumeName, aioVolumeType, nullVolumeType),
+			exist:   aioVolumeType,
+		},
+		"no required field": {
+			id:      testNullVolumeID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: null_volume",
+			exist:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist != "" {
+				testEnv.opiSpdkServer.volumeTypes[testNullVolumeName] = tt.exist
+			}
+			if tt.exist == nullVolumeType {
+				_ = testEnv.opiSpdkServer.store.Set(testNullVolumeName, &testNullVolume)
+			}
+			if tt.in != nil {
+				tt.in = utils.ProtoClone(tt.in)
+				tt.in.Name = ""
+			}
+
+			request := &pb.CreateNullVolumeRequest{NullVolume: tt.in, NullVolumeId: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateNullVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteNullVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNullVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Null Volume: %v", testNullVolumeID),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testNullVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"aio volume": {
+			in:      testAioVolumeName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testAioVolumeName, aioVolumeType, nullVolumeType),
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToNullVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToNullVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToNullVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNullVolumeName, &testNullVolume)
+			testEnv.opiSpdkServer.volumeTypes[testNullVolumeName] = nullVolumeType
+			_ = testEnv.opiSpdkServer.store.Set(testAioVolumeName, &testAioVolume)
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &pb.DeleteNullVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteNullVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_UpdateNullVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *pb.NullVolume
+		out     *pb.NullVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      &pb.NullVolume{Name: testNullVolumeName, BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unimplemented,
+			errMsg:  "UpdateNullVolume method is not implemented",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      &pb.NullVolume{Name: resourceIDToNullVolumeName("unknown-id"), BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToNullVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      &pb.NullVolume{Name: resourceIDToNullVolumeName("unknown-id"), BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount},
+			out:     &pb.NullVolume{Name: resourceIDToNullVolumeName("unknown-id"), BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount, Uuid: testNullVolume.Uuid},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "5ac0bba0-b2f4-4e4d-9a3b-1b1c8e0c1f2d"}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      &pb.NullVolume{Name: "-ABC-DEF", BlockSize: testNullVolume.BlockSize, BlocksCount: testNullVolume.BlocksCount},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNullVolumeName, &testNullVolume)
+			testEnv.opiSpdkServer.volumeTypes[testNullVolumeName] = nullVolumeType
+
+			request := &pb.UpdateNullVolumeRequest{NullVolume: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.UpdateNullVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListNullVolumes(t *testing.T) {
+	testNullVolume1 := utils.ProtoClone(&testNullVolume)
+	testNullVolume1.Name = resourceIDToNullVolumeName("null1")
+	tests := map[string]struct {
+		size    int32
+		token   string
+		out     []*pb.NullVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			size:    0,
+			token:   "",
+			out:     []*pb.NullVolume{&testNullVolume, testNullVolume1},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination": {
+			size:    1,
+			token:   "",
+			out:     []*pb.NullVolume{&testNullVolume},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination negative": {
+			size:    -10,
+			token:   "",
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+		},
+		"pagination error": {
+			size:    0,
+			token:   "unknown-pagination-token",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			for _, volume := range []*pb.NullVolume{&testNullVolume, testNullVolume1} {
+				_ = testEnv.opiSpdkServer.store.Set(volume.Name, volume)
+				testEnv.opiSpdkServer.volumeTypes[volume.Name] = nullVolumeType
+			}
+			// aio volumes share the volumes collection
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &pb.ListNullVolumesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListNullVolumes(testEnv.ctx, request)
+
+			if len(response.GetNullVolumes()) != len(tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetNullVolumes())
+			}
+			for i := range tt.out {
+				if !proto.Equal(response.GetNullVolumes()[i], tt.out[i]) {
+					t.Error("response: expected", tt.out[i], "received", response.GetNullVolumes()[i])
+				}
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNullVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.NullVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testNullVolumeName,
+			out:     &testNullVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToNullVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToNullVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNullVolumeName, &testNullVolume)
+
+			request := &pb.GetNullVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNullVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_StatsNullVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.VolumeStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNullVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not stats Volume: %v", testNullVolumeID),
+		},
+		"valid request with valid SPDK response": {
+			in: testNullVolumeName,
+			out: &pb.VolumeStats{
+				ReadBytesCount:  40960,
+				ReadOpsCount:    10,
+				WriteBytesCount: 81920,
+				WriteOpsCount:   20,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "num_read_ops": 10, "bytes_read": 40960, "num_write_ops": 20, "bytes_written": 81920}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToNullVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToNullVolumeName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNullVolumeName, &testNullVolume)
+
+			request := &pb.StatsNullVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsNullVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response.GetStats(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetStats())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func (s *Server) validateCreateNullVolumeRequest(in *pb.CreateNullVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.NullVolumeId != "" {
+		if err := resourceid.ValidateUserSettable(in.NullVolumeId); err != nil {
+			return err
+		}
+	}
+	// check BlockSize, the emulated namespaces use 512 or 4096 bytes blocks
+	if in.NullVolume.BlockSize != 512 && in.NullVolume.BlockSize != 4096 {
+		msg := fmt.Sprintf("BlockSize value (%d) is not supported, have to be 512 or 4096", in.NullVolume.BlockSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check BlocksCount
+	if in.NullVolume.BlocksCount <= 0 {
+		msg := fmt.Sprintf("BlocksCount value (%d) has to be positive", in.NullVolume.BlocksCount)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check Uuid, the firmware generates one when it is empty
+	if in.NullVolume.Uuid != "" {
+		if _, err := uuid.Parse(in.NullVolume.Uuid); err != nil {
+			msg := fmt.Sprintf("Uuid value (%s) is not a UUID", in.NullVolume.Uuid)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteNullVolumeRequest(in *pb.DeleteNullVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateUpdateNullVolumeRequest(in *pb.UpdateNullVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.NullVolume.Name)
+}
+
+func (s *Server) validateGetNullVolumeRequest(in *pb.GetNullVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateStatsNullVolumeRequest(in *pb.StatsNullVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
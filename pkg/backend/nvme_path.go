@@ -0,0 +1,440 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/fieldmask"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// nvmeTransportTypes maps the transports the initiator supports to the firmware ones, RDMA
+// runs over RoCEv2 and PCIe is used for the drives local to the DPU
+var nvmeTransportTypes = map[pb.NvmeTransportType]string{
+	pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP:  "TCP",
+	pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA: "RDMA",
+	pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE: "PCIe",
+}
+
+// nvmeAddressFamilies maps the address families the initiator supports to the firmware ones
+var nvmeAddressFamilies = map[pb.NvmeAddressFamily]string{
+	pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV4: "IPv4",
+	pb.NvmeAddressFamily_NVME_ADDRESS_FAMILY_IPV6: "IPv6",
+}
+
+// nvmeMultipathModes maps the multipath modes of the remote controllers to the firmware ones,
+// the path selection is ANA aware: failover controllers send the IOs on a single optimized
+// path and only fail over to a non-optimized one when no optimized path is left, multipath
+// controllers spread them across the optimized paths
+var nvmeMultipathModes = map[pb.NvmeMultipath]string{
+	pb.NvmeMultipath_NVME_MULTIPATH_DISABLE:   "disable",
+	pb.NvmeMultipath_NVME_MULTIPATH_FAILOVER:  "failover",
+	pb.NvmeMultipath_NVME_MULTIPATH_MULTIPATH: "multipath",
+}
+
+func sortNvmePaths(paths []*pb.NvmePath) {
+	sort.Slice(paths, func(i int, j int) bool {
+		return paths[i].Name < paths[j].Name
+	})
+}
+
+// CreateNvmePath connects a remote controller to a target or a local drive, their namespaces
+// become volumes named after the controller ID and the namespace ID, i.e. Nvme0n1
+func (s *Server) CreateNvmePath(ctx context.Context, in *pb.CreateNvmePathRequest) (*pb.NvmePath, error) {
+	// check input correctness
+	if err := s.validateCreateNvmePathRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.NvmePathId != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.NvmePathId, "name", in.NvmePath.Name)
+		resourceID = in.NvmePathId
+	}
+	in.NvmePath.Name = resourcename.Join(in.Parent, "nvmePaths", resourceID)
+	// idempotent API when called with same key, should return same object
+	nvmePath := new(pb.NvmePath)
+	found, err := s.store.Get(in.NvmePath.Name, nvmePath)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing NvmePath", "name", in.NvmePath.Name)
+		return nvmePath, nil
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err = s.store.Get(in.Parent, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Parent)
+		return nil, err
+	}
+	if controller.Multipath == pb.NvmeMultipath_NVME_MULTIPATH_DISABLE && len(s.nvmePathNames(controller.Name)) != 0 {
+		msg := fmt.Sprintf("NvmeRemoteController %s has multipath disabled and already has a path", controller.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	rdmaOptions, found, err := s.getNvmePathRdmaOptions(in.NvmePath.Name)
+	if err != nil {
+		return nil, err
+	}
+	if found && in.NvmePath.Trtype != pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA {
+		msg := fmt.Sprintf("NvmePath %s has RDMA options but doesn't use the RDMA transport", in.NvmePath.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	psk, err := s.nvmePathPsk(controller, in.NvmePath)
+	if err != nil {
+		return nil, err
+	}
+	reconnectOptions, _, err := s.getNvmeRemoteControllerReconnectOptions(controller.Name)
+	if err != nil {
+		return nil, err
+	}
+	qos, _, err := s.getNvmeRemoteControllerQos(controller.Name)
+	if err != nil {
+		return nil, err
+	}
+	dhchap, found, err := s.getNvmeRemoteControllerDhchap(controller.Name)
+	if err != nil {
+		return nil, err
+	}
+	if found && in.NvmePath.Trtype == pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE {
+		msg := fmt.Sprintf("NvmeRemoteController %s has DH-CHAP keys but NvmePath %s doesn't use a fabrics transport", controller.Name, in.NvmePath.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	// not found, so create a new one
+	ctrlrID := path.Base(controller.Name)
+	params := models.MrvlBdevNvmeAttachControllerParams{
+		Name:        ctrlrID,
+		Trtype:      nvmeTransportTypes[in.NvmePath.Trtype],
+		Traddr:      in.NvmePath.Traddr,
+		Adrfam:      nvmeAddressFamilies[in.NvmePath.Fabrics.GetAdrfam()],
+		Trsvcid:     nvmePathTrsvcid(in.NvmePath),
+		Subnqn:      in.NvmePath.Fabrics.GetSubnqn(),
+		Hostnqn:     in.NvmePath.Fabrics.GetHostnqn(),
+		Hdgst:       controller.GetTcp().GetHdgst(),
+		Ddgst:       controller.GetTcp().GetDdgst(),
+		Psk:         psk,
+		Multipath:   nvmeMultipathModes[controller.Multipath],
+		NumIoQueues: controller.IoQueuesCount,
+		IoQueueSize: controller.QueueSize,
+	}
+	if rdmaOptions != nil {
+		params.HcaName = rdmaOptions.Hca
+		params.HcaPort = int(rdmaOptions.Port)
+		params.RdmaCqSize = int(rdmaOptions.CqSize)
+	}
+	if dhchap != nil {
+		params.DhchapKey = dhchapKeyName(ctrlrID)
+		if dhchap.CtrlrKeyFingerprint != "" {
+			params.DhchapCtrlrKey = dhchapCtrlrKeyName(ctrlrID)
+		}
+		params.DhchapDigests = dhchap.Digests
+		params.DhchapDhgroups = dhchap.Dhgroups
+	}
+	effective := s.effectiveReconnectOptions(reconnectOptions)
+	params.KeepAliveTimeoutMs = int(effective.KeepAliveTimeoutMs)
+	params.ReconnectRetryCount = int(effective.ReconnectRetryCount)
+	params.ReconnectDelaySec = int(effective.ReconnectDelaySec)
+	params.MaxReconnectDelaySec = int(effective.MaxReconnectDelaySec)
+	params.FastIoFailTimeoutSec = int(effective.FastIoFailTimeoutSec)
+	var result models.MrvlBdevNvmeAttachControllerResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_nvme_attach_controller", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not attach NVMe Ctrl: %s", ctrlrID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_attach_controller")
+	}
+	if controller.Multipath == pb.NvmeMultipath_NVME_MULTIPATH_MULTIPATH {
+		if err := s.setNvmeMultipathPolicy(ctx, ctrlrID); err != nil {
+			return nil, err
+		}
+	}
+	// the limits are shared by all the paths of the controller
+	if qos != nil {
+		if err := s.setNvmeRemoteControllerQos(ctx, ctrlrID, qos); err != nil {
+			return nil, err
+		}
+	}
+	response := utils.ProtoClone(in.NvmePath)
+	err = s.store.Set(in.NvmePath.Name, response)
+	if err != nil {
+		return nil, err
+	}
+	s.ListHelper[in.NvmePath.Name] = false
+	s.trackNvmePath(in.NvmePath.Name)
+	return response, nil
+}
+
+// DeleteNvmePath disconnects a remote controller from a target, the remote controller
+// loses its namespaces when its last path is deleted
+func (s *Server) DeleteNvmePath(ctx context.Context, in *pb.DeleteNvmePathRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteNvmePathRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	nvmePath := new(pb.NvmePath)
+	found, err := s.store.Get(in.Name, nvmePath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	// the firmware doesn't have the paths the bridge couldn't connect again
+	if s.failedNvmePath(nvmePath.Name) == nil {
+		ctrlrID := path.Base(path.Dir(path.Dir(nvmePath.Name)))
+		params := models.MrvlBdevNvmeDetachControllerParams{
+			Name:    ctrlrID,
+			Trtype:  nvmeTransportTypes[nvmePath.Trtype],
+			Traddr:  nvmePath.Traddr,
+			Adrfam:  nvmeAddressFamilies[nvmePath.Fabrics.GetAdrfam()],
+			Trsvcid: nvmePathTrsvcid(nvmePath),
+			Subnqn:  nvmePath.Fabrics.GetSubnqn(),
+		}
+		var result models.MrvlBdevNvmeDetachControllerResult
+		err = s.rpc.Call(ctx, "mrvl_bdev_nvme_detach_controller", &params, &result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not detach NVMe Ctrl: %s", ctrlrID)
+			return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_detach_controller")
+		}
+	}
+	// remove from the Database
+	delete(s.ListHelper, nvmePath.Name)
+	s.untrackNvmePath(nvmePath.Name)
+	err = s.store.Delete(nvmePath.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.deleteNvmePathRdmaOptions(nvmePath.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UpdateNvmePath updates an Nvme path
+func (s *Server) UpdateNvmePath(ctx context.Context, in *pb.UpdateNvmePathRequest) (*pb.NvmePath, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmePathRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	nvmePath := new(pb.NvmePath)
+	found, err := s.store.Get(in.NvmePath.Name, nvmePath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// see https://google.aip.dev/134#create-or-update
+		if in.AllowMissing {
+			parent, resourceID, _ := strings.Cut(in.NvmePath.Name, "/nvmePaths/")
+			return s.CreateNvmePath(ctx, &pb.CreateNvmePathRequest{Parent: parent, NvmePath: in.NvmePath, NvmePathId: resourceID})
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmePath.Name)
+		return nil, err
+	}
+	// update_mask = 2
+	if err := fieldmask.Validate(in.UpdateMask, in.NvmePath); err != nil {
+		return nil, err
+	}
+	return nil, status.Errorf(codes.Unimplemented, "UpdateNvmePath method is not implemented")
+}
+
+// ListNvmePaths lists the Nvme paths of a remote controller
+func (s *Server) ListNvmePaths(_ context.Context, in *pb.ListNvmePathsRequest) (*pb.ListNvmePathsResponse, error) {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Parent, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Parent)
+		return nil, err
+	}
+	names := s.nvmePathNames(controller.Name)
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*pb.NvmePath, 0, len(names))
+	for _, name := range names {
+		nvmePath := new(pb.NvmePath)
+		found, err := s.store.Get(name, nvmePath)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, nvmePath)
+	}
+	sortNvmePaths(Blobarray)
+	return &pb.ListNvmePathsResponse{NvmePaths: Blobarray, NextPageToken: token}, nil
+}
+
+// GetNvmePath gets an Nvme path
+func (s *Server) GetNvmePath(_ context.Context, in *pb.GetNvmePathRequest) (*pb.NvmePath, error) {
+	// check input correctness
+	if err := s.validateGetNvmePathRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	nvmePath := new(pb.NvmePath)
+	found, err := s.store.Get(in.Name, nvmePath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return nvmePath, nil
+}
+
+// StatsNvmePath gets the stats of an Nvme path
+func (s *Server) StatsNvmePath(ctx context.Context, in *pb.StatsNvmePathRequest) (*pb.StatsNvmePathResponse, error) {
+	// check input correctness
+	if err := s.validateStatsNvmePathRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	nvmePath := new(pb.NvmePath)
+	found, err := s.store.Get(in.Name, nvmePath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	params := models.MrvlBdevNvmeGetStatsParams{
+		Name:    path.Base(path.Dir(path.Dir(nvmePath.Name))),
+		Traddr:  nvmePath.Traddr,
+		Trsvcid: nvmePathTrsvcid(nvmePath),
+	}
+	stats, err := s.nvmeStats(ctx, &params)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StatsNvmePathResponse{Stats: stats}, nil
+}
+
+// setNvmeMultipathPolicy makes the firmware use all the optimized paths of a multipath
+// controller, picking the one with the fewest outstanding IOs
+func (s *Server) setNvmeMultipathPolicy(ctx context.Context, ctrlrID string) error {
+	params := models.MrvlBdevNvmeSetMultipathPolicyParams{
+		Name:     ctrlrID,
+		Policy:   "active_active",
+		Selector: "queue_depth",
+	}
+	var result models.MrvlBdevNvmeSetMultipathPolicyResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_nvme_set_multipath_policy", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not set multipath policy of NVMe Ctrl: %s", ctrlrID)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_set_multipath_policy")
+	}
+	return nil
+}
+
+// nvmePathTrsvcid returns the port of a fabrics path, empty for the PCIe paths
+func nvmePathTrsvcid(nvmePath *pb.NvmePath) string {
+	if nvmePath.Fabrics == nil {
+		return ""
+	}
+	return strconv.FormatInt(nvmePath.Fabrics.Trsvcid, 10)
+}
+
+// nvmePathNames returns the sorted names of the paths of a remote controller
+func (s *Server) nvmePathNames(controllerName string) []string {
+	prefix := resourcename.Join(controllerName, "nvmePaths") + "/"
+	var names []string
+	for key := range s.ListHelper {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nvmePathPsk gets the TLS PSK an Nvme path connects with, either the name of a key of the
+// keyring or, for the remote controllers created before the TLS PSKs, the PSK in the NVMe TLS
+// PSK interchange format, i.e. NVMeTLSkey-1:01:...:
+func (s *Server) nvmePathPsk(controller *pb.NvmeRemoteController, nvmePath *pb.NvmePath) (string, error) {
+	ref := controller.GetTcp().GetPsk()
+	if len(ref) == 0 {
+		return "", nil
+	}
+	if nvmePath.Trtype != pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP {
+		msg := fmt.Sprintf("NvmeRemoteController %s has a TLS PSK but NvmePath %s doesn't use the TCP transport", controller.Name, nvmePath.Name)
+		return "", status.Errorf(codes.FailedPrecondition, msg)
+	}
+	if !isTLSPskName(ref) {
+		return string(ref), nil
+	}
+	psk, found, err := s.getTLSPsk(string(ref))
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", ref)
+		return "", err
+	}
+	// the identity of the PSK binds it to a host and a subsystem
+	if nvmePath.Fabrics.GetSubnqn() != psk.Subnqn {
+		msg := fmt.Sprintf("TLSPsk %s is for subsystem %s, not %s", psk.Name, psk.Subnqn, nvmePath.Fabrics.GetSubnqn())
+		return "", status.Errorf(codes.FailedPrecondition, msg)
+	}
+	if hostnqn := nvmePath.Fabrics.GetHostnqn(); hostnqn != "" && hostnqn != psk.Hostnqn {
+		msg := fmt.Sprintf("TLSPsk %s is for host %s, not %s", psk.Name, psk.Hostnqn, hostnqn)
+		return "", status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return tlsPskKeyName(path.Base(psk.Name)), nil
+}
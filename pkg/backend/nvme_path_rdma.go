@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// NvmePathRdmaOptions represents the RDMA settings of an NVMe/RDMA (RoCEv2) path which are
+// not part of the OPI NvmePath. Options are set before the path is created (with a user
+// specified id) and are applied when the path connects
+type NvmePathRdmaOptions struct {
+	// Hca is the name of the RDMA device the queue pairs are created on, i.e. mrvl_roce0,
+	// empty lets the firmware pick the device routing to the target address
+	Hca string `json:"hca"`
+	// Port is the port of the RDMA device, starting at 1, 0 picks the first active port
+	Port int32 `json:"port"`
+	// CqSize is the number of entries of the completion queue of each IO queue pair,
+	// 0 sizes it after the IO queue size of the remote controller
+	CqSize int32 `json:"cqSize"`
+}
+
+// GetNvmePathRdmaOptionsRequest represents a request to get the RDMA options of an Nvme path
+type GetNvmePathRdmaOptionsRequest struct {
+	// Name of the Nvme path
+	Name string `json:"name"`
+}
+
+// UpdateNvmePathRdmaOptionsRequest represents a request to set the RDMA options of an Nvme path
+type UpdateNvmePathRdmaOptionsRequest struct {
+	// Name of the Nvme path
+	Name string `json:"name"`
+	// Options to set on the Nvme path
+	Options *NvmePathRdmaOptions `json:"options"`
+}
+
+// GetNvmePathRdmaOptions gets the RDMA options of an Nvme path
+func (s *Server) GetNvmePathRdmaOptions(_ context.Context, in *GetNvmePathRdmaOptionsRequest) (*NvmePathRdmaOptions, error) {
+	// check input correctness
+	if err := s.validateGetNvmePathRdmaOptionsRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	options, found, err := s.getNvmePathRdmaOptions(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return options, nil
+}
+
+// UpdateNvmePathRdmaOptions sets the RDMA options of an Nvme path, the queue pairs are
+// bound to the device when the path connects so the path must not exist yet
+func (s *Server) UpdateNvmePathRdmaOptions(_ context.Context, in *UpdateNvmePathRdmaOptionsRequest) (*NvmePathRdmaOptions, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmePathRdmaOptionsRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	nvmePath := new(pb.NvmePath)
+	found, err := s.store.Get(in.Name, nvmePath)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		msg := fmt.Sprintf("RDMA options of NvmePath %s can only be set before it is created", in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	// save object to the database
+	options := *in.Options
+	if err := s.saveNvmePathRdmaOptions(in.Name, &options); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// nvmePathRdmaOptionsKey is the database key of the RDMA options of an Nvme path
+func nvmePathRdmaOptionsKey(name string) string {
+	return name + "/rdmaOptions"
+}
+
+// getNvmePathRdmaOptions fetches the RDMA options of an Nvme path from the database,
+// options are not protobufs so they are stored JSON encoded
+func (s *Server) getNvmePathRdmaOptions(name string) (*NvmePathRdmaOptions, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(nvmePathRdmaOptionsKey(name), value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	options := new(NvmePathRdmaOptions)
+	if err := json.Unmarshal(value.Value, options); err != nil {
+		return nil, false, err
+	}
+	return options, true, nil
+}
+
+func (s *Server) saveNvmePathRdmaOptions(name string, options *NvmePathRdmaOptions) error {
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(nvmePathRdmaOptionsKey(name), wrapperspb.Bytes(data))
+}
+
+func (s *Server) deleteNvmePathRdmaOptions(name string) error {
+	return s.store.Delete(nvmePathRdmaOptionsKey(name))
+}
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var testNvmePathRdmaOptions = NvmePathRdmaOptions{
+	Hca:    "mrvl_roce0",
+	Port:   1,
+	CqSize: 256,
+}
+
+func TestBackEnd_UpdateNvmePathRdmaOptions(t *testing.T) {
+	tests := map[string]struct {
+		in      *UpdateNvmePathRdmaOptionsRequest
+		out     *NvmePathRdmaOptions
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"missing options": {
+			in:      &UpdateNvmePathRdmaOptionsRequest{Name: testNvmePathName},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: options",
+			exist:   false,
+		},
+		"invalid device name": {
+			in:      &UpdateNvmePathRdmaOptionsRequest{Name: testNvmePathName, Options: &NvmePathRdmaOptions{Hca: "mrvl roce0"}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Hca value (%s) is not a valid RDMA device name", "mrvl roce0"),
+			exist:   false,
+		},
+		"port out of range": {
+			in:      &UpdateNvmePathRdmaOptionsRequest{Name: testNvmePathName, Options: &NvmePathRdmaOptions{Port: 256}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Port value (%d) is out of range, have to be between 0 and 255", 256),
+			exist:   false,
+		},
+		"negative completion queue size": {
+			in:      &UpdateNvmePathRdmaOptionsRequest{Name: testNvmePathName, Options: &NvmePathRdmaOptions{CqSize: -1}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("CqSize value (%d) is out of range, have to be between 0 and 65536", -1),
+			exist:   false,
+		},
+		"existing path": {
+			in:      &UpdateNvmePathRdmaOptionsRequest{Name: testNvmePathName, Options: &testNvmePathRdmaOptions},
+			out:     nil,
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("RDMA options of NvmePath %v can only be set before it is created", testNvmePathName),
+			exist:   true,
+		},
+		"valid request": {
+			in:      &UpdateNvmePathRdmaOptionsRequest{Name: testNvmePathName, Options: &testNvmePathRdmaOptions},
+			out:     &testNvmePathRdmaOptions,
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+			}
+
+			response, err := testEnv.opiSpdkServer.UpdateNvmePathRdmaOptions(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNvmePathRdmaOptions(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *NvmePathRdmaOptions
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testNvmePathName,
+			out:     &testNvmePathRdmaOptions,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      testNvmeRemoteControllerName + "/nvmePaths/unknown-id",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testNvmeRemoteControllerName+"/nvmePaths/unknown-id"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveNvmePathRdmaOptions(testNvmePathName, &testNvmePathRdmaOptions)
+
+			request := &GetNvmePathRdmaOptionsRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmePathRdmaOptions(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateNvmePathWithRdmaOptions(t *testing.T) {
+	testNvmePathRdma := utils.ProtoClone(&testNvmePath)
+	testNvmePathRdma.Trtype = pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA
+	tests := map[string]struct {
+		in      *pb.NvmePath
+		out     *pb.NvmePath
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"options on a tcp path": {
+			in:      &testNvmePath,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("NvmePath %v has RDMA options but doesn't use the RDMA transport", testNvmePathName),
+		},
+		"options on an rdma path": {
+			in:      testNvmePathRdma,
+			out:     testNvmePathRdma,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			_ = testEnv.opiSpdkServer.saveNvmePathRdmaOptions(testNvmePathName, &testNvmePathRdmaOptions)
+			in := utils.ProtoClone(tt.in)
+			in.Name = ""
+
+			request := &pb.CreateNvmePathRequest{Parent: testNvmeRemoteControllerName, NvmePath: in, NvmePathId: testNvmePathID}
+			response, err := testEnv.opiSpdkServer.CreateNvmePath(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatsNvmePathFabricRequest represents a request to get the fabric counters of an Nvme path
+type StatsNvmePathFabricRequest struct {
+	// Name of the Nvme path
+	Name string `json:"name"`
+}
+
+// NvmePathFabricStats represents the counters of an Nvme path, including the ones the
+// OPI VolumeStats don't carry, to find which path of a remote controller is degraded
+type NvmePathFabricStats struct {
+	// State is CONNECTED or DISCONNECTED
+	State string `json:"state"`
+	// AnaState is the Asymmetric Namespace Access state the target reports for the path
+	AnaState string `json:"anaState,omitempty"`
+	// ReadOpsCount is the number of read commands completed on the path
+	ReadOpsCount uint64 `json:"readOpsCount"`
+	// WriteOpsCount is the number of write commands completed on the path
+	WriteOpsCount uint64 `json:"writeOpsCount"`
+	// ReadBytesCount is the number of bytes read on the path
+	ReadBytesCount uint64 `json:"readBytesCount"`
+	// WriteBytesCount is the number of bytes written on the path
+	WriteBytesCount uint64 `json:"writeBytesCount"`
+	// IoErrors is the number of commands completed with an error, retried on another path if any
+	IoErrors uint64 `json:"ioErrors"`
+	// Timeouts is the number of commands aborted because the target didn't complete them in time
+	Timeouts uint64 `json:"timeouts"`
+	// Reconnects is the number of times the path was lost and connected again
+	Reconnects uint64 `json:"reconnects"`
+	// RttUs is the last round trip time of the keep alive commands, in microseconds
+	RttUs uint64 `json:"rttUs"`
+	// MaxRttUs is the highest round trip time of the keep alive commands, in microseconds
+	MaxRttUs uint64 `json:"maxRttUs"`
+}
+
+// StatsNvmePathFabric gets the IO, error and reconnect counters, the ANA state and the
+// round trip time of an Nvme path, for troubleshooting fabric problems
+func (s *Server) StatsNvmePathFabric(ctx context.Context, in *StatsNvmePathFabricRequest) (*NvmePathFabricStats, error) {
+	// check input correctness
+	if err := s.validateStatsNvmePathFabricRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	nvmePath := new(pb.NvmePath)
+	found, err := s.store.Get(in.Name, nvmePath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	ctrlrID := path.Base(path.Dir(path.Dir(nvmePath.Name)))
+	params := models.MrvlBdevNvmeGetPathStatsParams{
+		Name:    ctrlrID,
+		Traddr:  nvmePath.Traddr,
+		Trsvcid: nvmePathTrsvcid(nvmePath),
+	}
+	var result models.MrvlBdevNvmeGetPathStatsResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_nvme_get_path_stats", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not stats path of NVMe Ctrl: %s", ctrlrID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_get_path_stats")
+	}
+	state := nvmePathStateDisconnected
+	if result.Connected {
+		state = nvmePathStateConnected
+	}
+	return &NvmePathFabricStats{
+		State:           state,
+		AnaState:        strings.ToUpper(result.AnaState),
+		ReadOpsCount:    result.NumReadCmds,
+		WriteOpsCount:   result.NumWriteCmds,
+		ReadBytesCount:  result.NumReadBytes,
+		WriteBytesCount: result.NumWriteBytes,
+		IoErrors:        result.IoErrors,
+		Timeouts:        result.Timeouts,
+		Reconnects:      result.Reconnects,
+		RttUs:           result.RttUs,
+		MaxRttUs:        result.MaxRttUs,
+	}, nil
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBackEnd_StatsNvmePathFabric(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *NvmePathFabricStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not stats path of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+		},
+		"valid request with empty SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_nvme_get_path_stats: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_nvme_get_path_stats: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: testNvmePathName,
+			out: &NvmePathFabricStats{
+				State:           nvmePathStateConnected,
+				AnaState:        "NON_OPTIMIZED",
+				ReadOpsCount:    5000000000,
+				WriteOpsCount:   12,
+				ReadBytesCount:  20480000000000,
+				WriteBytesCount: 49152,
+				IoErrors:        3,
+				Timeouts:        1,
+				Reconnects:      2,
+				RttUs:           45,
+				MaxRttUs:        1200,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "connected": true, "ana_state": "non_optimized", "num_read_cmds": 5000000000, "num_write_cmds": 12, "num_read_bytes": 20480000000000, "num_write_bytes": 49152, "io_errors": 3, "timeouts": 1, "reconnects": 2, "rtt_us": 45, "max_rtt_us": 1200}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"disconnected path": {
+			in:      testNvmePathName,
+			out:     &NvmePathFabricStats{State: nvmePathStateDisconnected, Reconnects: 7},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "connected": false, "reconnects": 7}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      testNvmeRemoteControllerName + "/nvmePaths/unknown-id",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testNvmeRemoteControllerName+"/nvmePaths/unknown-id"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+
+			request := &StatsNvmePathFabricRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsNvmePathFabric(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
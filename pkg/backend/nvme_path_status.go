@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxNvmePathEvents is the number of path state transitions kept
+const maxNvmePathEvents = 100
+
+// States of an Nvme path
+const (
+	nvmePathStateConnected    = "CONNECTED"
+	nvmePathStateDisconnected = "DISCONNECTED"
+	// the firmware gave up reconnecting, the path has to be deleted and created again
+	nvmePathStateFailed = "FAILED"
+)
+
+// NvmePathStatus represents the state of an Nvme path as seen by the multipath layer
+type NvmePathStatus struct {
+	// Name of the Nvme path
+	Name string `json:"name"`
+	// State is CONNECTED, DISCONNECTED while the firmware reconnects the path, or FAILED
+	// once the reconnect retries of the remote controller are exhausted
+	State string `json:"state"`
+	// AnaState is the Asymmetric Namespace Access state the target reports for the path,
+	// OPTIMIZED, NON_OPTIMIZED, INACCESSIBLE, PERSISTENT_LOSS or CHANGE
+	AnaState string `json:"anaState,omitempty"`
+	// Current is set when the IOs are sent on the path
+	Current bool `json:"current"`
+	// Reason tells why the bridge couldn't connect the path again, it stays FAILED until created again
+	Reason string `json:"reason,omitempty"`
+}
+
+// NvmePathEvent represents a state transition of an Nvme path
+type NvmePathEvent struct {
+	// Path is the name of the Nvme path
+	Path string `json:"path"`
+	// State is the new state of the path
+	State string `json:"state"`
+	// AnaState is the new ANA state of the path
+	AnaState string `json:"anaState,omitempty"`
+	// PreviousState is the state of the path before the transition
+	PreviousState string `json:"previousState"`
+	// PreviousAnaState is the ANA state of the path before the transition
+	PreviousAnaState string `json:"previousAnaState,omitempty"`
+	// Reason tells why the bridge couldn't connect the path again
+	Reason string `json:"reason,omitempty"`
+	// Time is when the transition was detected, in RFC 3339 format
+	Time string `json:"time"`
+}
+
+// GetNvmePathStatusRequest represents a request to get the state of an Nvme path
+type GetNvmePathStatusRequest struct {
+	// Name of the Nvme path
+	Name string `json:"name"`
+}
+
+// ListNvmePathEventsRequest represents a request to list the Nvme path state transitions
+type ListNvmePathEventsRequest struct{}
+
+// ListNvmePathEventsResponse represents the last Nvme path state transitions
+type ListNvmePathEventsResponse struct {
+	Events []*NvmePathEvent `json:"events"`
+}
+
+// GetNvmePathStatus gets the connection and ANA state of an Nvme path
+func (s *Server) GetNvmePathStatus(ctx context.Context, in *GetNvmePathStatusRequest) (*NvmePathStatus, error) {
+	// check input correctness
+	if err := s.validateGetNvmePathStatusRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	nvmePath := new(pb.NvmePath)
+	found, err := s.store.Get(in.Name, nvmePath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if failed := s.failedNvmePath(nvmePath.Name); failed != nil {
+		return failed, nil
+	}
+	result, err := s.nvmeIoPaths(ctx, path.Base(path.Dir(path.Dir(nvmePath.Name))))
+	if err != nil {
+		return nil, err
+	}
+	return nvmePathStatus(nvmePath, result), nil
+}
+
+// ListNvmePathEvents lists the last state transitions of the Nvme paths
+func (s *Server) ListNvmePathEvents(_ context.Context, _ *ListNvmePathEventsRequest) (*ListNvmePathEventsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &ListNvmePathEventsResponse{Events: append([]*NvmePathEvent{}, s.nvmePathEvents...)}, nil
+}
+
+// WatchNvmePaths checks the state of the Nvme paths every interval until ctx is done
+func (s *Server) WatchNvmePaths(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.checkNvmePaths(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkNvmePaths raises an event for each Nvme path whose state changed since the last check,
+// i.e. when a target node reboots and the firmware fails over to the paths of another node
+func (s *Server) checkNvmePaths(ctx context.Context) {
+	s.mu.Lock()
+	controllers := make(map[string][]string)
+	for name := range s.nvmePathStates {
+		controllerName := path.Dir(path.Dir(name))
+		controllers[controllerName] = append(controllers[controllerName], name)
+	}
+	s.mu.Unlock()
+	for controllerName, names := range controllers {
+		result, err := s.nvmeIoPaths(ctx, path.Base(controllerName))
+		if err != nil {
+			slog.Warn("Could not check paths", "name", controllerName, "error", err)
+			continue
+		}
+		for _, name := range names {
+			nvmePath := new(pb.NvmePath)
+			found, err := s.store.Get(name, nvmePath)
+			if err != nil || !found {
+				continue
+			}
+			s.recordNvmePathStatus(nvmePathStatus(nvmePath, result))
+		}
+	}
+}
+
+// NvmePathFailureReporter is told the Nvme paths the firmware gave up reconnecting
+type NvmePathFailureReporter func(event *NvmePathEvent)
+
+// NvmePathEventReporter is told the state transitions of the Nvme paths
+type NvmePathEventReporter func(event *NvmePathEvent)
+
+// Connected tells whether the path is connected after the transition
+func (e *NvmePathEvent) Connected() bool {
+	return e.State == nvmePathStateConnected
+}
+
+// CountDisconnectedNvmePaths counts the Nvme paths not connected when last checked
+func (s *Server) CountDisconnectedNvmePaths() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, pathStatus := range s.nvmePathStates {
+		if pathStatus.State != nvmePathStateConnected {
+			count++
+		}
+	}
+	return count
+}
+
+// SetNvmePathEventReporter sets who is told the state transitions of the Nvme paths
+func (s *Server) SetNvmePathEventReporter(reporter NvmePathEventReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nvmePathEventReporter = reporter
+}
+
+// SetNvmePathFailureReporter sets who is told the Nvme paths the firmware gave up reconnecting
+func (s *Server) SetNvmePathFailureReporter(reporter NvmePathFailureReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nvmePathFailureReporter = reporter
+}
+
+// recordNvmePathStatus saves the state of an Nvme path, raising an event when it changed
+func (s *Server) recordNvmePathStatus(pathStatus *NvmePathStatus) {
+	event := s.updateNvmePathStatus(pathStatus)
+	if event == nil {
+		return
+	}
+	s.mu.Lock()
+	eventReporter, failureReporter := s.nvmePathEventReporter, s.nvmePathFailureReporter
+	s.mu.Unlock()
+	if eventReporter != nil {
+		eventReporter(event)
+	}
+	if failureReporter != nil && event.State == nvmePathStateFailed {
+		failureReporter(event)
+	}
+}
+
+// updateNvmePathStatus saves the state of an Nvme path, it returns the raised event, nil when
+// the state didn't change
+func (s *Server) updateNvmePathStatus(pathStatus *NvmePathStatus) *NvmePathEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.nvmePathStates[pathStatus.Name]
+	if !ok {
+		// the path was deleted while its controller was checked
+		return nil
+	}
+	if previous.Reason != "" && pathStatus.Reason == "" {
+		// the firmware doesn't have the path the bridge couldn't connect again
+		return nil
+	}
+	if previous.State == pathStatus.State && previous.AnaState == pathStatus.AnaState {
+		s.nvmePathStates[pathStatus.Name] = pathStatus
+		return nil
+	}
+	slog.Info("NvmePath changed state", "name", pathStatus.Name, "state", pathStatus.State, "ana_state", pathStatus.AnaState, "previous_state", previous.State, "previous_ana_state", previous.AnaState)
+	event := &NvmePathEvent{
+		Path:             pathStatus.Name,
+		State:            pathStatus.State,
+		AnaState:         pathStatus.AnaState,
+		PreviousState:    previous.State,
+		PreviousAnaState: previous.AnaState,
+		Reason:           pathStatus.Reason,
+		Time:             time.Now().UTC().Format(time.RFC3339),
+	}
+	s.nvmePathEvents = append(s.nvmePathEvents, event)
+	if len(s.nvmePathEvents) > maxNvmePathEvents {
+		s.nvmePathEvents = s.nvmePathEvents[len(s.nvmePathEvents)-maxNvmePathEvents:]
+	}
+	s.nvmePathStates[pathStatus.Name] = pathStatus
+	return event
+}
+
+// trackNvmePath starts watching the state of a connected Nvme path
+func (s *Server) trackNvmePath(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nvmePathStates[name] = &NvmePathStatus{Name: name, State: nvmePathStateConnected}
+}
+
+// failNvmePath reports an Nvme path the bridge couldn't connect again as FAILED, for reason
+func (s *Server) failNvmePath(name string, reason string) {
+	s.mu.Lock()
+	if _, ok := s.nvmePathStates[name]; !ok {
+		s.nvmePathStates[name] = &NvmePathStatus{Name: name, State: nvmePathStateConnected}
+	}
+	s.mu.Unlock()
+	s.recordNvmePathStatus(&NvmePathStatus{Name: name, State: nvmePathStateFailed, Reason: reason})
+}
+
+// failedNvmePath returns the state of an Nvme path the bridge couldn't connect again, nil for
+// the other paths
+func (s *Server) failedNvmePath(name string) *NvmePathStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pathStatus, ok := s.nvmePathStates[name]; ok && pathStatus.Reason != "" {
+		return pathStatus
+	}
+	return nil
+}
+
+// untrackNvmePath stops watching the state of a deleted Nvme path
+func (s *Server) untrackNvmePath(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.nvmePathStates, name)
+}
+
+// nvmeIoPaths fetches the state of the paths of a remote controller from the firmware
+func (s *Server) nvmeIoPaths(ctx context.Context, ctrlrID string) (*models.MrvlBdevNvmeGetIoPathsResult, error) {
+	params := models.MrvlBdevNvmeGetIoPathsParams{
+		Name: ctrlrID,
+	}
+	var result models.MrvlBdevNvmeGetIoPathsResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_nvme_get_io_paths", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get IO paths of NVMe Ctrl: %s", ctrlrID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_get_io_paths")
+	}
+	return &result, nil
+}
+
+// nvmePathStatus finds an Nvme path in the IO paths reported by the firmware, a path
+// the firmware doesn't report is disconnected
+func nvmePathStatus(nvmePath *pb.NvmePath, result *models.MrvlBdevNvmeGetIoPathsResult) *NvmePathStatus {
+	pathStatus := &NvmePathStatus{Name: nvmePath.Name, State: nvmePathStateDisconnected}
+	trsvcid := nvmePathTrsvcid(nvmePath)
+	for _, ioPath := range result.IoPaths {
+		if ioPath.Traddr != nvmePath.Traddr || ioPath.Trsvcid != trsvcid {
+			continue
+		}
+		switch {
+		case ioPath.Connected:
+			pathStatus.State = nvmePathStateConnected
+		case ioPath.Failed:
+			pathStatus.State = nvmePathStateFailed
+		}
+		pathStatus.AnaState = strings.ToUpper(ioPath.AnaState)
+		pathStatus.Current = ioPath.Current
+		break
+	}
+	return pathStatus
+}
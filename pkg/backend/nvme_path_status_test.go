@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testIoPaths builds a firmware response reporting the test path with the given state
+func testIoPaths(connected bool, anaState string, current bool) string {
+	return fmt.Sprintf(`{"id":%%d,"error":{"code":0,"message":""},"result":{"status": 0, "io_paths": [{"traddr": "10.10.10.11", "trsvcid": "4420", "connected": %v, "ana_state": "%s", "current": %v}, {"traddr": "10.10.10.12", "trsvcid": "4420", "connected": true, "ana_state": "optimized", "current": %v}]}}`, connected, anaState, current, !current)
+}
+
+func TestBackEnd_GetNvmePathStatus(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *NvmePathStatus
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get IO paths of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+		},
+		"valid request with empty SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_nvme_get_io_paths: %v", "EOF"),
+		},
+		"current path": {
+			in:      testNvmePathName,
+			out:     &NvmePathStatus{Name: testNvmePathName, State: nvmePathStateConnected, AnaState: "OPTIMIZED", Current: true},
+			spdk:    []string{testIoPaths(true, "optimized", true)},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"standby path": {
+			in:      testNvmePathName,
+			out:     &NvmePathStatus{Name: testNvmePathName, State: nvmePathStateConnected, AnaState: "NON_OPTIMIZED", Current: false},
+			spdk:    []string{testIoPaths(true, "non_optimized", false)},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"path not reported": {
+			in:      testNvmePathName,
+			out:     &NvmePathStatus{Name: testNvmePathName, State: nvmePathStateDisconnected},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "io_paths": []}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      testNvmeRemoteControllerName + "/nvmePaths/unknown-id",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testNvmeRemoteControllerName+"/nvmePaths/unknown-id"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+
+			request := &GetNvmePathStatusRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmePathStatus(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_CheckNvmePaths(t *testing.T) {
+	tests := map[string]struct {
+		spdk     []string
+		events   []string
+		failures []string
+	}{
+		"stable path": {
+			spdk:   []string{testIoPaths(true, "optimized", true), testIoPaths(true, "optimized", true)},
+			events: []string{"CONNECTED (OPTIMIZED)"},
+		},
+		"reconnect retries exhausted": {
+			spdk: []string{
+				testIoPaths(true, "optimized", true),
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "io_paths": [{"traddr": "10.10.10.11", "trsvcid": "4420", "connected": false, "failed": true, "ana_state": "inaccessible", "current": false}]}}`,
+			},
+			events:   []string{"CONNECTED (OPTIMIZED)", "FAILED (INACCESSIBLE)"},
+			failures: []string{"CONNECTED (OPTIMIZED)"},
+		},
+		"target node reboot": {
+			spdk: []string{
+				testIoPaths(true, "optimized", true),
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "io_paths": [{"traddr": "10.10.10.12", "trsvcid": "4420", "connected": true, "ana_state": "optimized", "current": true}]}}`,
+				testIoPaths(true, "inaccessible", false),
+				testIoPaths(true, "optimized", true),
+			},
+			events: []string{"CONNECTED (OPTIMIZED)", "DISCONNECTED ()", "CONNECTED (INACCESSIBLE)", "CONNECTED (OPTIMIZED)"},
+		},
+		"firmware failure": {
+			spdk:   []string{testFailureResponse, testIoPaths(true, "optimized", true)},
+			events: []string{"CONNECTED (OPTIMIZED)"},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+			testEnv.opiSpdkServer.trackNvmePath(testNvmePathName)
+			var failures []string
+			testEnv.opiSpdkServer.SetNvmePathFailureReporter(func(event *NvmePathEvent) {
+				failures = append(failures, fmt.Sprintf("%s (%s)", event.PreviousState, event.PreviousAnaState))
+			})
+			var reported []string
+			testEnv.opiSpdkServer.SetNvmePathEventReporter(func(event *NvmePathEvent) {
+				reported = append(reported, fmt.Sprintf("%s (%s) %v", event.State, event.AnaState, event.Connected()))
+			})
+			for range tt.spdk {
+				testEnv.opiSpdkServer.checkNvmePaths(testEnv.ctx)
+			}
+
+			response, _ := testEnv.opiSpdkServer.ListNvmePathEvents(testEnv.ctx, &ListNvmePathEventsRequest{})
+			var events []string
+			for _, event := range response.Events {
+				if event.Path != testNvmePathName {
+					t.Error("event path: expected", testNvmePathName, "received", event.Path)
+				}
+				events = append(events, fmt.Sprintf("%s (%s)", event.State, event.AnaState))
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Error("events: expected", tt.events, "received", events)
+			}
+			disconnected := 0
+			if len(tt.events) != 0 && !strings.HasPrefix(tt.events[len(tt.events)-1], "CONNECTED") {
+				disconnected = 1
+			}
+			if count := testEnv.opiSpdkServer.CountDisconnectedNvmePaths(); count != disconnected {
+				t.Error("disconnected paths: expected", disconnected, "received", count)
+			}
+			if !reflect.DeepEqual(failures, tt.failures) {
+				t.Error("failures: expected", tt.failures, "received", failures)
+			}
+			// every transition is reported
+			var expectedReported []string
+			for _, event := range tt.events {
+				expectedReported = append(expectedReported, fmt.Sprintf("%s %v", event, strings.HasPrefix(event, "CONNECTED")))
+			}
+			if !reflect.DeepEqual(reported, expectedReported) {
+				t.Error("reported: expected", expectedReported, "received", reported)
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestBackEnd_CreateNvmePath(t *testing.T) {
+	testNvmePathFc := utils.ProtoClone(&testNvmePath)
+	testNvmePathFc.Trtype = pb.NvmeTransportType_NVME_TRANSPORT_TYPE_FC
+	testNvmePathRdma := utils.ProtoClone(&testNvmePath)
+	testNvmePathRdma.Trtype = pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA
+	testNvmePathPort := utils.ProtoClone(&testNvmePath)
+	testNvmePathPort.Fabrics.Trsvcid = 65536
+	testNvmePathNoFabrics := utils.ProtoClone(&testNvmePath)
+	testNvmePathNoFabrics.Fabrics = nil
+	testNvmePathPcie := &pb.NvmePath{
+		Name:   testNvmePathName,
+		Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+		Traddr: "0000:01:00.0",
+	}
+	testNvmeRemoteControllerSinglePath := utils.ProtoClone(&testNvmeRemoteController)
+	testNvmeRemoteControllerSinglePath.Multipath = pb.NvmeMultipath_NVME_MULTIPATH_DISABLE
+	testNvmeRemoteControllerMultipath := utils.ProtoClone(&testNvmeRemoteController)
+	testNvmeRemoteControllerMultipath.Multipath = pb.NvmeMultipath_NVME_MULTIPATH_MULTIPATH
+	tests := map[string]struct {
+		parent     string
+		in         *pb.NvmePath
+		out        *pb.NvmePath
+		controller *pb.NvmeRemoteController
+		spdk       []string
+		errCode    codes.Code
+		errMsg     string
+		exist      bool
+	}{
+		"unsupported transport": {
+			parent:     testNvmeRemoteControllerName,
+			in:         testNvmePathFc,
+			out:        nil,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{},
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("Trtype value (%v) is not supported, have to be TCP, RDMA or PCIE", pb.NvmeTransportType_NVME_TRANSPORT_TYPE_FC),
+			exist:      false,
+		},
+		"port out of range": {
+			parent:     testNvmeRemoteControllerName,
+			in:         testNvmePathPort,
+			out:        nil,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{},
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("Trsvcid value (%d) is out of range, have to be between 1 and 65535", 65536),
+			exist:      false,
+		},
+		"missing fabrics": {
+			parent:     testNvmeRemoteControllerName,
+			in:         testNvmePathNoFabrics,
+			out:        nil,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{},
+			errCode:    codes.Unknown,
+			errMsg:     "missing required field: nvme_path.fabrics",
+			exist:      false,
+		},
+		"unknown controller": {
+			parent:     resourceIDToRemoteControllerName("unknown-id"),
+			in:         &testNvmePath,
+			out:        nil,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{},
+			errCode:    codes.NotFound,
+			errMsg:     fmt.Sprintf("unable to find key %v", resourceIDToRemoteControllerName("unknown-id")),
+			exist:      false,
+		},
+		"second path with multipath disabled": {
+			parent:     testNvmeRemoteControllerName,
+			in:         &testNvmePath,
+			out:        nil,
+			controller: testNvmeRemoteControllerSinglePath,
+			spdk:       []string{},
+			errCode:    codes.FailedPrecondition,
+			errMsg:     fmt.Sprintf("NvmeRemoteController %v has multipath disabled and already has a path", testNvmeRemoteControllerName),
+			exist:      true,
+		},
+		"valid request with invalid SPDK response": {
+			parent:     testNvmeRemoteControllerName,
+			in:         &testNvmePath,
+			out:        nil,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{testFailureResponse},
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("Could not attach NVMe Ctrl: %v", testNvmeRemoteControllerID),
+			exist:      false,
+		},
+		"valid request with empty SPDK response": {
+			parent:     testNvmeRemoteControllerName,
+			in:         &testNvmePath,
+			out:        nil,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{""},
+			errCode:    codes.Unknown,
+			errMsg:     fmt.Sprintf("mrvl_bdev_nvme_attach_controller: %v", "EOF"),
+			exist:      false,
+		},
+		"local drive": {
+			parent:     testNvmeRemoteControllerName,
+			in:         testNvmePathPcie,
+			out:        testNvmePathPcie,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`},
+			errCode:    codes.OK,
+			errMsg:     "",
+			exist:      false,
+		},
+		"valid request with valid SPDK response": {
+			parent:     testNvmeRemoteControllerName,
+			in:         &testNvmePath,
+			out:        &testNvmePath,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`},
+			errCode:    codes.OK,
+			errMsg:     "",
+			exist:      false,
+		},
+		"multipath with invalid SPDK policy response": {
+			parent:     testNvmeRemoteControllerName,
+			in:         &testNvmePath,
+			out:        nil,
+			controller: testNvmeRemoteControllerMultipath,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`, testFailureResponse},
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("Could not set multipath policy of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+			exist:      false,
+		},
+		"multipath": {
+			parent:     testNvmeRemoteControllerName,
+			in:         &testNvmePath,
+			out:        &testNvmePath,
+			controller: testNvmeRemoteControllerMultipath,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`, testSuccessResponse},
+			errCode:    codes.OK,
+			errMsg:     "",
+			exist:      false,
+		},
+		"rdma target": {
+			parent:     testNvmeRemoteControllerName,
+			in:         testNvmePathRdma,
+			out:        testNvmePathRdma,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`},
+			errCode:    codes.OK,
+			errMsg:     "",
+			exist:      false,
+		},
+		"already exists": {
+			parent:     testNvmeRemoteControllerName,
+			in:         &testNvmePath,
+			out:        &testNvmePath,
+			controller: &testNvmeRemoteController,
+			spdk:       []string{},
+			errCode:    codes.OK,
+			errMsg:     "",
+			exist:      true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, tt.controller)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			if tt.exist {
+				otherPathName := testNvmeRemoteControllerName + "/nvmePaths/path1"
+				if tt.controller.Multipath != pb.NvmeMultipath_NVME_MULTIPATH_DISABLE {
+					otherPathName = testNvmePathName
+				}
+				_ = testEnv.opiSpdkServer.store.Set(otherPathName, &testNvmePath)
+				testEnv.opiSpdkServer.ListHelper[otherPathName] = false
+			}
+			in := utils.ProtoClone(tt.in)
+			in.Name = ""
+
+			request := &pb.CreateNvmePathRequest{Parent: tt.parent, NvmePath: in, NvmePathId: testNvmePathID}
+			response, err := testEnv.opiSpdkServer.CreateNvmePath(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			if _, ok := testEnv.opiSpdkServer.ListHelper[testNvmePathName]; ok != (tt.out != nil) {
+				t.Error("path tracked: expected", tt.out != nil, "received", ok)
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteNvmePath(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not detach NVMe Ctrl: %v", testNvmeRemoteControllerID),
+			missing: false,
+		},
+		"valid request with empty SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_nvme_detach_controller: %v", "EOF"),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testNvmePathName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      testNvmeRemoteControllerName + "/nvmePaths/unknown-id",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testNvmeRemoteControllerName+"/nvmePaths/unknown-id"),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      testNvmeRemoteControllerName + "/nvmePaths/unknown-id",
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+			testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+
+			request := &pb.DeleteNvmePathRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteNvmePath(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_UpdateNvmePath(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		created bool
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      testNvmePathName,
+			created: false,
+			spdk:    []string{},
+			errCode: codes.Unimplemented,
+			errMsg:  "UpdateNvmePath method is not implemented",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      testNvmeRemoteControllerName + "/nvmePaths/unknown-id",
+			created: false,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testNvmeRemoteControllerName+"/nvmePaths/unknown-id"),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      testNvmeRemoteControllerName + "/nvmePaths/unknown-id",
+			created: true,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			created: false,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			if !tt.missing {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+				testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+			}
+			in := utils.ProtoClone(&testNvmePath)
+			in.Name = tt.in
+			var out *pb.NvmePath
+			if tt.created {
+				out = in
+			}
+
+			request := &pb.UpdateNvmePathRequest{NvmePath: in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.UpdateNvmePath(testEnv.ctx, request)
+
+			if !proto.Equal(response, out) {
+				t.Error("response: expected", out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			if _, ok := testEnv.opiSpdkServer.ListHelper[tt.in]; tt.created && !ok {
+				t.Error("path tracked: expected", true, "received", ok)
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListNvmePaths(t *testing.T) {
+	testNvmePath1 := utils.ProtoClone(&testNvmePath)
+	testNvmePath1.Name = testNvmeRemoteControllerName + "/nvmePaths/path1"
+	testNvmePath1.Traddr = "10.10.10.12"
+	testOtherPath := utils.ProtoClone(&testNvmePath)
+	testOtherPath.Name = resourceIDToRemoteControllerName("remote1") + "/nvmePaths/path0"
+	tests := map[string]struct {
+		in      string
+		out     []*pb.NvmePath
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testNvmeRemoteControllerName,
+			out:     []*pb.NvmePath{&testNvmePath, testNvmePath1},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRemoteControllerName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			for _, nvmePath := range []*pb.NvmePath{testNvmePath1, &testNvmePath, testOtherPath} {
+				_ = testEnv.opiSpdkServer.store.Set(nvmePath.Name, nvmePath)
+				testEnv.opiSpdkServer.ListHelper[nvmePath.Name] = false
+			}
+
+			request := &pb.ListNvmePathsRequest{Parent: tt.in}
+			response, err := testEnv.opiSpdkServer.ListNvmePaths(testEnv.ctx, request)
+
+			if !utils.EqualProtoSlices(response.GetNvmePaths(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetNvmePaths())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_StatsNvmePath(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.VolumeStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmePathName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not stats NVMe Ctrl: %v", testNvmeRemoteControllerID),
+		},
+		"valid request with valid SPDK response": {
+			in:      testNvmePathName,
+			out:     &pb.VolumeStats{ReadBytesCount: 512, ReadOpsCount: 1},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "num_read_cmds": 1, "num_read_bytes": 512}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      testNvmeRemoteControllerName + "/nvmePaths/unknown-id",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testNvmeRemoteControllerName+"/nvmePaths/unknown-id"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+
+			request := &pb.StatsNvmePathRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsNvmePath(testEnv.ctx, request)
+
+			if !proto.Equal(response.GetStats(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetStats())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
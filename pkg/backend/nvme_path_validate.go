@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func (s *Server) validateCreateNvmePathRequest(in *pb.CreateNvmePathRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.NvmePathId != "" {
+		if err := resourceid.ValidateUserSettable(in.NvmePathId); err != nil {
+			return err
+		}
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	if err := resourcename.Validate(in.Parent); err != nil {
+		return err
+	}
+	// check Trtype, only the NVMe/TCP and NVMe/RDMA initiators and the local drives are offloaded
+	if _, ok := nvmeTransportTypes[in.NvmePath.Trtype]; !ok {
+		msg := fmt.Sprintf("Trtype value (%v) is not supported, have to be TCP, RDMA or PCIE", in.NvmePath.Trtype)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.NvmePath.Traddr == "" {
+		return errors.New("missing required field: nvme_path.traddr")
+	}
+	if in.NvmePath.Trtype == pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE {
+		return nil
+	}
+	// the fabrics fields are only required for the fabrics transports
+	if in.NvmePath.Fabrics == nil {
+		return errors.New("missing required field: nvme_path.fabrics")
+	}
+	if in.NvmePath.Fabrics.Subnqn == "" {
+		return errors.New("missing required field: nvme_path.fabrics.subnqn")
+	}
+	if _, ok := nvmeAddressFamilies[in.NvmePath.Fabrics.Adrfam]; !ok {
+		msg := fmt.Sprintf("Adrfam value (%v) is not supported, have to be IPv4 or IPv6", in.NvmePath.Fabrics.Adrfam)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.NvmePath.Fabrics.Trsvcid < 1 || in.NvmePath.Fabrics.Trsvcid > 65535 {
+		msg := fmt.Sprintf("Trsvcid value (%d) is out of range, have to be between 1 and 65535", in.NvmePath.Fabrics.Trsvcid)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// rdmaDeviceName matches the names of the RDMA devices, IB_DEVICE_NAME_MAX (64) includes the terminating NUL
+var rdmaDeviceName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,63}$`)
+
+func (s *Server) validateUpdateNvmePathRdmaOptionsRequest(in *UpdateNvmePathRdmaOptionsRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	if in.Options == nil {
+		return errors.New("missing required field: options")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	if err := resourcename.Validate(in.Name); err != nil {
+		return err
+	}
+	// check Hca, empty lets the firmware pick the device
+	if in.Options.Hca != "" && !rdmaDeviceName.MatchString(in.Options.Hca) {
+		msg := fmt.Sprintf("Hca value (%s) is not a valid RDMA device name", in.Options.Hca)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check Port, the port number is 8 bits wide
+	if in.Options.Port < 0 || in.Options.Port > 255 {
+		msg := fmt.Sprintf("Port value (%d) is out of range, have to be between 0 and 255", in.Options.Port)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check CqSize
+	if in.Options.CqSize < 0 || in.Options.CqSize > 65536 {
+		msg := fmt.Sprintf("CqSize value (%d) is out of range, have to be between 0 and 65536", in.Options.CqSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateGetNvmePathRdmaOptionsRequest(in *GetNvmePathRdmaOptionsRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetNvmePathStatusRequest(in *GetNvmePathStatusRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateStatsNvmePathFabricRequest(in *StatsNvmePathFabricRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateDeleteNvmePathRequest(in *pb.DeleteNvmePathRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateUpdateNvmePathRequest(in *pb.UpdateNvmePathRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.NvmePath.Name)
+}
+
+func (s *Server) validateGetNvmePathRequest(in *pb.GetNvmePathRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateStatsNvmePathRequest(in *pb.StatsNvmePathRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
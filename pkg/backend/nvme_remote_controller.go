@@ -0,0 +1,359 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/fieldmask"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func sortNvmeRemoteControllers(controllers []*pb.NvmeRemoteController) {
+	sort.Slice(controllers, func(i int, j int) bool {
+		return controllers[i].Name < controllers[j].Name
+	})
+}
+
+// resourceIDToRemoteControllerName builds the name of a remote controller from its ID
+func resourceIDToRemoteControllerName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"nvmeRemoteControllers", resourceID,
+	)
+}
+
+// CreateNvmeRemoteController creates an Nvme remote controller, the firmware connects to the
+// target when the first path is created
+func (s *Server) CreateNvmeRemoteController(_ context.Context, in *pb.CreateNvmeRemoteControllerRequest) (*pb.NvmeRemoteController, error) {
+	// check input correctness
+	if err := s.validateCreateNvmeRemoteControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.NvmeRemoteControllerId != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.NvmeRemoteControllerId, "name", in.NvmeRemoteController.Name)
+		resourceID = in.NvmeRemoteControllerId
+	}
+	in.NvmeRemoteController.Name = resourceIDToRemoteControllerName(resourceID)
+	// idempotent API when called with same key, should return same object
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.NvmeRemoteController.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing NvmeRemoteController", "name", in.NvmeRemoteController.Name)
+		return controller, nil
+	}
+	if ref := in.NvmeRemoteController.GetTcp().GetPsk(); isTLSPskName(ref) {
+		_, found, err := s.getTLSPsk(string(ref))
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", ref)
+			return nil, err
+		}
+	}
+	// not found, so create a new one
+	response := utils.ProtoClone(in.NvmeRemoteController)
+	err = s.store.Set(in.NvmeRemoteController.Name, response)
+	if err != nil {
+		return nil, err
+	}
+	s.ListHelper[in.NvmeRemoteController.Name] = false
+	return response, nil
+}
+
+// DeleteNvmeRemoteController deletes an Nvme remote controller, its paths have to be deleted first
+func (s *Server) DeleteNvmeRemoteController(ctx context.Context, in *pb.DeleteNvmeRemoteControllerRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteNvmeRemoteControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if paths := s.nvmePathNames(controller.Name); len(paths) != 0 {
+		msg := fmt.Sprintf("NvmeRemoteController %s still has %d paths, delete them first", controller.Name, len(paths))
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	if err := s.removeNvmeRemoteControllerDhchap(ctx, controller.Name); err != nil {
+		return nil, err
+	}
+	// remove from the Database
+	delete(s.ListHelper, controller.Name)
+	err = s.store.Delete(controller.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.deleteNvmeRemoteControllerReconnectOptions(controller.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.Delete(nvmeRemoteControllerQosKey(controller.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UpdateNvmeRemoteController updates an Nvme remote controller
+func (s *Server) UpdateNvmeRemoteController(ctx context.Context, in *pb.UpdateNvmeRemoteControllerRequest) (*pb.NvmeRemoteController, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeRemoteControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.NvmeRemoteController.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// see https://google.aip.dev/134#create-or-update
+		if in.AllowMissing {
+			return s.CreateNvmeRemoteController(ctx, &pb.CreateNvmeRemoteControllerRequest{NvmeRemoteController: in.NvmeRemoteController, NvmeRemoteControllerId: path.Base(in.NvmeRemoteController.Name)})
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmeRemoteController.Name)
+		return nil, err
+	}
+	// update_mask = 2
+	if err := fieldmask.Validate(in.UpdateMask, in.NvmeRemoteController); err != nil {
+		return nil, err
+	}
+	return nil, status.Errorf(codes.Unimplemented, "UpdateNvmeRemoteController method is not implemented")
+}
+
+// ListNvmeRemoteControllers lists Nvme remote controllers
+func (s *Server) ListNvmeRemoteControllers(_ context.Context, in *pb.ListNvmeRemoteControllersRequest) (*pb.ListNvmeRemoteControllersResponse, error) {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.nvmeRemoteControllerNames()
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*pb.NvmeRemoteController, 0, len(names))
+	for _, name := range names {
+		controller := new(pb.NvmeRemoteController)
+		found, err := s.store.Get(name, controller)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, controller)
+	}
+	sortNvmeRemoteControllers(Blobarray)
+	return &pb.ListNvmeRemoteControllersResponse{NvmeRemoteControllers: Blobarray, NextPageToken: token}, nil
+}
+
+// GetNvmeRemoteController gets an Nvme remote controller
+func (s *Server) GetNvmeRemoteController(_ context.Context, in *pb.GetNvmeRemoteControllerRequest) (*pb.NvmeRemoteController, error) {
+	// check input correctness
+	if err := s.validateGetNvmeRemoteControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return controller, nil
+}
+
+// ResetNvmeRemoteController resets the connections of an Nvme remote controller
+func (s *Server) ResetNvmeRemoteController(ctx context.Context, in *pb.ResetNvmeRemoteControllerRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateResetNvmeRemoteControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(controller.Name)
+	params := models.MrvlBdevNvmeResetControllerParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevNvmeResetControllerResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_nvme_reset_controller", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not reset NVMe Ctrl: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_reset_controller")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// StatsNvmeRemoteController gets the stats of an Nvme remote controller, across its paths
+func (s *Server) StatsNvmeRemoteController(ctx context.Context, in *pb.StatsNvmeRemoteControllerRequest) (*pb.StatsNvmeRemoteControllerResponse, error) {
+	// check input correctness
+	if err := s.validateStatsNvmeRemoteControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	params := models.MrvlBdevNvmeGetStatsParams{
+		Name: path.Base(controller.Name),
+	}
+	stats, err := s.nvmeStats(ctx, &params)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StatsNvmeRemoteControllerResponse{Stats: stats}, nil
+}
+
+// ListNvmeRemoteNamespaces lists the namespaces of an Nvme remote controller, each of them
+// is a volume, named after the last segment of the namespace name, frontend namespaces can
+// be backed by
+func (s *Server) ListNvmeRemoteNamespaces(ctx context.Context, in *pb.ListNvmeRemoteNamespacesRequest) (*pb.ListNvmeRemoteNamespacesResponse, error) {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Parent, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Parent)
+		return nil, err
+	}
+	resourceID := path.Base(controller.Name)
+	params := models.MrvlBdevNvmeGetNsListParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevNvmeGetNsListResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_nvme_get_ns_list", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not list NS of NVMe Ctrl: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_get_ns_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.NsList), "offset", offset, "size", size)
+	result.NsList, hasMoreElements = utils.LimitPagination(result.NsList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*pb.NvmeRemoteNamespace, len(result.NsList))
+	for i := range result.NsList {
+		r := &result.NsList[i]
+		Blobarray[i] = &pb.NvmeRemoteNamespace{
+			Name:  resourcename.Join(controller.Name, "nvmeRemoteNamespaces", r.BdevName),
+			Nsid:  int32(r.Nsid),
+			Nguid: r.Nguid,
+			Eui64: r.Eui64,
+			Uuid:  r.UUID,
+		}
+	}
+	sort.Slice(Blobarray, func(i int, j int) bool {
+		return Blobarray[i].Nsid < Blobarray[j].Nsid
+	})
+	return &pb.ListNvmeRemoteNamespacesResponse{NvmeRemoteNamespaces: Blobarray, NextPageToken: token}, nil
+}
+
+// nvmeStats fetches the stats of a remote controller, or of one of its paths
+func (s *Server) nvmeStats(ctx context.Context, params *models.MrvlBdevNvmeGetStatsParams) (*pb.VolumeStats, error) {
+	var result models.MrvlBdevNvmeGetStatsResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_nvme_get_stats", params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not stats NVMe Ctrl: %s", params.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_get_stats")
+	}
+	return &pb.VolumeStats{
+		ReadBytesCount:    int32(result.NumReadBytes),
+		ReadOpsCount:      int32(result.NumReadCmds),
+		WriteBytesCount:   int32(result.NumWriteBytes),
+		WriteOpsCount:     int32(result.NumWriteCmds),
+		ReadLatencyTicks:  int32(result.TotalReadLatencyInUs),
+		WriteLatencyTicks: int32(result.TotalWriteLatencyInUs),
+	}, nil
+}
+
+// nvmeRemoteControllerNames returns the sorted names of the remote controllers
+func (s *Server) nvmeRemoteControllerNames() []string {
+	prefix := resourceIDToRemoteControllerName("") + "/"
+	var names []string
+	for key := range s.ListHelper {
+		if strings.HasPrefix(key, prefix) && !strings.Contains(key[len(prefix):], "/") {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// nvmeDhchapDigests are the hash functions of DH-HMAC-CHAP
+var nvmeDhchapDigests = map[string]bool{
+	"sha256": true,
+	"sha384": true,
+	"sha512": true,
+}
+
+// nvmeDhchapDhgroups are the Diffie-Hellman groups of DH-HMAC-CHAP, null disables the key exchange
+var nvmeDhchapDhgroups = map[string]bool{
+	"null":      true,
+	"ffdhe2048": true,
+	"ffdhe3072": true,
+	"ffdhe4096": true,
+	"ffdhe6144": true,
+	"ffdhe8192": true,
+}
+
+// NvmeRemoteControllerDhchap represents the DH-HMAC-CHAP authentication of the fabrics connections
+// of an Nvme remote controller. The secrets are only used to program the keyring of the firmware,
+// the bridge keeps their fingerprints and never returns them
+type NvmeRemoteControllerDhchap struct {
+	// Key is the secret of the host, in the DHHC-1:xx:<base64>: representation, input only
+	Key string `json:"key,omitempty"`
+	// CtrlrKey is the secret of the target, set for bidirectional authentication, input only
+	CtrlrKey string `json:"ctrlrKey,omitempty"`
+	// Digests are the hash functions offered to the target, sha256, sha384 or sha512, all when empty
+	Digests []string `json:"digests,omitempty"`
+	// Dhgroups are the Diffie-Hellman groups offered to the target, null or ffdheN, all when empty
+	Dhgroups []string `json:"dhgroups,omitempty"`
+	// KeyFingerprint is the SHA-256 of the secret of the host, output only
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
+	// CtrlrKeyFingerprint is the SHA-256 of the secret of the target, output only
+	CtrlrKeyFingerprint string `json:"ctrlrKeyFingerprint,omitempty"`
+}
+
+// UpdateNvmeRemoteControllerDhchapRequest represents a request to set the DH-HMAC-CHAP keys of an Nvme remote controller
+type UpdateNvmeRemoteControllerDhchapRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+	// Dhchap keys to set on the Nvme remote controller
+	Dhchap *NvmeRemoteControllerDhchap `json:"dhchap"`
+}
+
+// GetNvmeRemoteControllerDhchapRequest represents a request to get the DH-HMAC-CHAP settings of an Nvme remote controller
+type GetNvmeRemoteControllerDhchapRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+}
+
+// DeleteNvmeRemoteControllerDhchapRequest represents a request to remove the DH-HMAC-CHAP keys of an Nvme remote controller
+type DeleteNvmeRemoteControllerDhchapRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+	// AllowMissing makes the request succeed when the remote controller has no keys
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// UpdateNvmeRemoteControllerDhchap sets the DH-HMAC-CHAP keys the paths of an Nvme remote controller
+// authenticate with, the paths authenticate when they connect so the controller must have none yet
+func (s *Server) UpdateNvmeRemoteControllerDhchap(ctx context.Context, in *UpdateNvmeRemoteControllerDhchapRequest) (*NvmeRemoteControllerDhchap, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeRemoteControllerDhchapRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if len(s.nvmePathNames(controller.Name)) != 0 {
+		msg := fmt.Sprintf("DH-CHAP keys of NvmeRemoteController %s can only be changed when it has no path", controller.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	// replace the keys of the keyring
+	if err := s.removeNvmeRemoteControllerDhchap(ctx, controller.Name); err != nil {
+		return nil, err
+	}
+	ctrlrID := path.Base(controller.Name)
+	if err := s.addKeyringKey(ctx, dhchapKeyName(ctrlrID), in.Dhchap.Key); err != nil {
+		return nil, err
+	}
+	dhchap := &NvmeRemoteControllerDhchap{
+		Digests:        in.Dhchap.Digests,
+		Dhgroups:       in.Dhchap.Dhgroups,
+		KeyFingerprint: dhchapFingerprint(in.Dhchap.Key),
+	}
+	if in.Dhchap.CtrlrKey != "" {
+		if err := s.addKeyringKey(ctx, dhchapCtrlrKeyName(ctrlrID), in.Dhchap.CtrlrKey); err != nil {
+			if err := s.removeKeyringKey(ctx, dhchapKeyName(ctrlrID)); err != nil {
+				slog.Error("Could not roll back key", "name", controller.Name, "error", err)
+			}
+			return nil, err
+		}
+		dhchap.CtrlrKeyFingerprint = dhchapFingerprint(in.Dhchap.CtrlrKey)
+	}
+	// save object to the database
+	if err := s.saveNvmeRemoteControllerDhchap(controller.Name, dhchap); err != nil {
+		return nil, err
+	}
+	return dhchap, nil
+}
+
+// GetNvmeRemoteControllerDhchap gets the DH-HMAC-CHAP settings of an Nvme remote controller,
+// without the secrets
+func (s *Server) GetNvmeRemoteControllerDhchap(_ context.Context, in *GetNvmeRemoteControllerDhchapRequest) (*NvmeRemoteControllerDhchap, error) {
+	// check input correctness
+	if err := s.validateGetNvmeRemoteControllerDhchapRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	dhchap, found, err := s.getNvmeRemoteControllerDhchap(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return dhchap, nil
+}
+
+// DeleteNvmeRemoteControllerDhchap removes the DH-HMAC-CHAP keys of an Nvme remote controller,
+// its paths connect without authentication afterwards
+func (s *Server) DeleteNvmeRemoteControllerDhchap(ctx context.Context, in *DeleteNvmeRemoteControllerDhchapRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteNvmeRemoteControllerDhchapRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	_, found, err := s.getNvmeRemoteControllerDhchap(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if len(s.nvmePathNames(in.Name)) != 0 {
+		msg := fmt.Sprintf("DH-CHAP keys of NvmeRemoteController %s can only be changed when it has no path", in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	if err := s.removeNvmeRemoteControllerDhchap(ctx, in.Name); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// removeNvmeRemoteControllerDhchap removes the keys of an Nvme remote controller from the keyring
+// and the database, if it has any
+func (s *Server) removeNvmeRemoteControllerDhchap(ctx context.Context, name string) error {
+	dhchap, found, err := s.getNvmeRemoteControllerDhchap(name)
+	if err != nil || !found {
+		return err
+	}
+	ctrlrID := path.Base(name)
+	if dhchap.CtrlrKeyFingerprint != "" {
+		if err := s.removeKeyringKey(ctx, dhchapCtrlrKeyName(ctrlrID)); err != nil {
+			return err
+		}
+	}
+	if err := s.removeKeyringKey(ctx, dhchapKeyName(ctrlrID)); err != nil {
+		return err
+	}
+	return s.store.Delete(nvmeRemoteControllerDhchapKey(name))
+}
+
+// dhchapKeyName is the name of the keyring key holding the secret of the host
+func dhchapKeyName(ctrlrID string) string {
+	return ctrlrID + "_dhchap_key"
+}
+
+// dhchapCtrlrKeyName is the name of the keyring key holding the secret of the target
+func dhchapCtrlrKeyName(ctrlrID string) string {
+	return ctrlrID + "_dhchap_ctrlr_key"
+}
+
+// dhchapFingerprint identifies a secret without revealing it
+func dhchapFingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// nvmeRemoteControllerDhchapKey is the database key of the DH-HMAC-CHAP settings of an Nvme remote controller
+func nvmeRemoteControllerDhchapKey(name string) string {
+	return name + "/dhchap"
+}
+
+// getNvmeRemoteControllerDhchap fetches the DH-HMAC-CHAP settings of an Nvme remote controller
+// from the database, they are not protobufs so they are stored JSON encoded
+func (s *Server) getNvmeRemoteControllerDhchap(name string) (*NvmeRemoteControllerDhchap, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(nvmeRemoteControllerDhchapKey(name), value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	dhchap := new(NvmeRemoteControllerDhchap)
+	if err := json.Unmarshal(value.Value, dhchap); err != nil {
+		return nil, false, err
+	}
+	return dhchap, true, nil
+}
+
+func (s *Server) saveNvmeRemoteControllerDhchap(name string, dhchap *NvmeRemoteControllerDhchap) error {
+	data, err := json.Marshal(dhchap)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(nvmeRemoteControllerDhchapKey(name), wrapperspb.Bytes(data))
+}
@@ -0,0 +1,343 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var (
+	testDhchapKey      = "DHHC-1:00:ia6zGodOr4SEG0Zzaw398rpY0wqipUWj4jWjUh4HWUz6aQ2n:"
+	testDhchapCtrlrKey = "DHHC-1:01:cGFzc3dvcmRwYXNzd29yZHBhc3N3b3JkcGFzc3dvcmQxMjM0:"
+	testDhchap         = NvmeRemoteControllerDhchap{
+		Digests:        []string{"sha384"},
+		Dhgroups:       []string{"ffdhe4096"},
+		KeyFingerprint: dhchapFingerprint(testDhchapKey),
+	}
+)
+
+func TestBackEnd_UpdateNvmeRemoteControllerDhchap(t *testing.T) {
+	testDhchapBidirectional := testDhchap
+	testDhchapBidirectional.CtrlrKeyFingerprint = dhchapFingerprint(testDhchapCtrlrKey)
+	tests := map[string]struct {
+		in      *NvmeRemoteControllerDhchap
+		out     *NvmeRemoteControllerDhchap
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		path    bool
+		stored  bool
+	}{
+		"missing key": {
+			in:      &NvmeRemoteControllerDhchap{CtrlrKey: testDhchapCtrlrKey},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: dhchap.key",
+		},
+		"malformed key": {
+			in:      &NvmeRemoteControllerDhchap{Key: "secret"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Key value is not a DH-HMAC-CHAP secret, have to be DHHC-1:xx:<base64>:",
+		},
+		"malformed controller key": {
+			in:      &NvmeRemoteControllerDhchap{Key: testDhchapKey, CtrlrKey: "DHHC-1:04:c2VjcmV0:"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "CtrlrKey value is not a DH-HMAC-CHAP secret, have to be DHHC-1:xx:<base64>:",
+		},
+		"unsupported digest": {
+			in:      &NvmeRemoteControllerDhchap{Key: testDhchapKey, Digests: []string{"md5"}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Digests value (%s) is not supported, have to be sha256, sha384 or sha512", "md5"),
+		},
+		"unsupported dhgroup": {
+			in:      &NvmeRemoteControllerDhchap{Key: testDhchapKey, Dhgroups: []string{"ffdhe1024"}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Dhgroups value (%s) is not supported, have to be null, ffdhe2048, ffdhe3072, ffdhe4096, ffdhe6144 or ffdhe8192", "ffdhe1024"),
+		},
+		"controller with path": {
+			in:      &NvmeRemoteControllerDhchap{Key: testDhchapKey},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("DH-CHAP keys of NvmeRemoteController %v can only be changed when it has no path", testNvmeRemoteControllerName),
+			path:    true,
+		},
+		"valid request with invalid SPDK response": {
+			in:      &NvmeRemoteControllerDhchap{Key: testDhchapKey},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not add key %v to the keyring", dhchapKeyName(testNvmeRemoteControllerID)),
+		},
+		"host key": {
+			in:      &NvmeRemoteControllerDhchap{Key: testDhchapKey, Digests: []string{"sha384"}, Dhgroups: []string{"ffdhe4096"}},
+			out:     &testDhchap,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"bidirectional keys": {
+			in:  &NvmeRemoteControllerDhchap{Key: testDhchapKey, CtrlrKey: testDhchapCtrlrKey, Digests: []string{"sha384"}, Dhgroups: []string{"ffdhe4096"}},
+			out: &testDhchapBidirectional,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"controller key rejected": {
+			in:  &NvmeRemoteControllerDhchap{Key: testDhchapKey, CtrlrKey: testDhchapCtrlrKey},
+			out: nil,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				testFailureResponse,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not add key %v to the keyring", dhchapCtrlrKeyName(testNvmeRemoteControllerID)),
+		},
+		"replaced keys": {
+			in:  &NvmeRemoteControllerDhchap{Key: testDhchapKey, Digests: []string{"sha384"}, Dhgroups: []string{"ffdhe4096"}},
+			out: &testDhchap,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			stored:  true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			if tt.path {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+				testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+			}
+			if tt.stored {
+				_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerDhchap(testNvmeRemoteControllerName, &NvmeRemoteControllerDhchap{KeyFingerprint: "0123"})
+			}
+
+			request := &UpdateNvmeRemoteControllerDhchapRequest{Name: testNvmeRemoteControllerName, Dhchap: tt.in}
+			response, err := testEnv.opiSpdkServer.UpdateNvmeRemoteControllerDhchap(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			// the secrets are never stored
+			stored, _, _ := testEnv.opiSpdkServer.getNvmeRemoteControllerDhchap(testNvmeRemoteControllerName)
+			if stored != nil && (stored.Key != "" || stored.CtrlrKey != "") {
+				t.Error("stored secrets: expected none, received", stored)
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNvmeRemoteControllerDhchap(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *NvmeRemoteControllerDhchap
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testNvmeRemoteControllerName,
+			out:     &testDhchap,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      "unknown-id",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "unknown-id"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerDhchap(testNvmeRemoteControllerName, &testDhchap)
+
+			request := &GetNvmeRemoteControllerDhchapRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmeRemoteControllerDhchap(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteNvmeRemoteControllerDhchap(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmeRemoteControllerName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not remove key %v from the keyring", dhchapKeyName(testNvmeRemoteControllerID)),
+		},
+		"valid request": {
+			in:      testNvmeRemoteControllerName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      "unknown-id",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "unknown-id"),
+		},
+		"unknown key with missing allowed": {
+			in:      "unknown-id",
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerDhchap(testNvmeRemoteControllerName, &testDhchap)
+
+			request := &DeleteNvmeRemoteControllerDhchapRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteNvmeRemoteControllerDhchap(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateNvmePathWithDhchap(t *testing.T) {
+	testNvmePathPcie := utils.ProtoClone(&testNvmePath)
+	testNvmePathPcie.Trtype = pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE
+	testNvmePathPcie.Traddr = "0000:01:00.0"
+	testNvmePathPcie.Fabrics = nil
+	tests := map[string]struct {
+		in      *pb.NvmePath
+		out     *pb.NvmePath
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"keys on a pcie path": {
+			in:      testNvmePathPcie,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("NvmeRemoteController %v has DH-CHAP keys but NvmePath %v doesn't use a fabrics transport", testNvmeRemoteControllerName, testNvmePathName),
+		},
+		"keys on a tcp path": {
+			in:      &testNvmePath,
+			out:     &testNvmePath,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerDhchap(testNvmeRemoteControllerName, &testDhchap)
+			in := utils.ProtoClone(tt.in)
+			in.Name = ""
+
+			request := &pb.CreateNvmePathRequest{Parent: testNvmeRemoteControllerName, NvmePath: in, NvmePathId: testNvmePathID}
+			response, err := testEnv.opiSpdkServer.CreateNvmePath(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// NvmeRemoteControllerQos represents the shaping of the IOs the DPU sends to the target of an
+// Nvme remote controller, over all its paths, so one DPU doesn't overwhelm a target shared with
+// others. A limit of 0 is unlimited
+type NvmeRemoteControllerQos struct {
+	// RwIopsKiops is the maximum of read and write I/Os per second, in thousands
+	RwIopsKiops int64 `json:"rwIopsKiops"`
+	// RdBandwidthMbs is the maximum read bandwidth in MB/s
+	RdBandwidthMbs int64 `json:"rdBandwidthMbs"`
+	// WrBandwidthMbs is the maximum write bandwidth in MB/s
+	WrBandwidthMbs int64 `json:"wrBandwidthMbs"`
+	// RwBandwidthMbs is the maximum read and write bandwidth in MB/s
+	RwBandwidthMbs int64 `json:"rwBandwidthMbs"`
+	// BackgroundBandwidthMbs is the maximum bandwidth in MB/s of the IOs the DPU issues on its
+	// own, i.e. rebuilds, migrations, copies and scrubs, leaving the rest to the hosts
+	BackgroundBandwidthMbs int64 `json:"backgroundBandwidthMbs"`
+}
+
+// GetNvmeRemoteControllerQosRequest represents a request to get the QoS limits of an Nvme remote controller
+type GetNvmeRemoteControllerQosRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+}
+
+// UpdateNvmeRemoteControllerQosRequest represents a request to set the QoS limits of an Nvme remote controller
+type UpdateNvmeRemoteControllerQosRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+	// Qos limits to set on the Nvme remote controller
+	Qos *NvmeRemoteControllerQos `json:"qos"`
+}
+
+// DeleteNvmeRemoteControllerQosRequest represents a request to remove the QoS limits of an Nvme remote controller
+type DeleteNvmeRemoteControllerQosRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+	// AllowMissing makes the request succeed when the remote controller has no limits
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetNvmeRemoteControllerQos gets the QoS limits of an Nvme remote controller
+func (s *Server) GetNvmeRemoteControllerQos(_ context.Context, in *GetNvmeRemoteControllerQosRequest) (*NvmeRemoteControllerQos, error) {
+	// check input correctness
+	if err := s.validateGetNvmeRemoteControllerQosRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	qos, found, err := s.getNvmeRemoteControllerQos(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return qos, nil
+}
+
+// UpdateNvmeRemoteControllerQos sets the QoS limits of an Nvme remote controller, they are applied
+// to the connected paths and to the paths created afterwards
+func (s *Server) UpdateNvmeRemoteControllerQos(ctx context.Context, in *UpdateNvmeRemoteControllerQosRequest) (*NvmeRemoteControllerQos, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeRemoteControllerQosRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	qos := *in.Qos
+	// the firmware only knows the controllers having paths
+	if len(s.nvmePathNames(controller.Name)) != 0 {
+		if err := s.setNvmeRemoteControllerQos(ctx, path.Base(controller.Name), &qos); err != nil {
+			return nil, err
+		}
+	}
+	// save object to the database
+	if err := s.saveNvmeRemoteControllerQos(controller.Name, &qos); err != nil {
+		return nil, err
+	}
+	return &qos, nil
+}
+
+// DeleteNvmeRemoteControllerQos removes the QoS limits of an Nvme remote controller, its paths
+// are no longer shaped
+func (s *Server) DeleteNvmeRemoteControllerQos(ctx context.Context, in *DeleteNvmeRemoteControllerQosRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteNvmeRemoteControllerQosRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	_, found, err := s.getNvmeRemoteControllerQos(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if len(s.nvmePathNames(in.Name)) != 0 {
+		if err := s.setNvmeRemoteControllerQos(ctx, path.Base(in.Name), &NvmeRemoteControllerQos{}); err != nil {
+			return nil, err
+		}
+	}
+	// remove from the Database
+	if err := s.store.Delete(nvmeRemoteControllerQosKey(in.Name)); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// setNvmeRemoteControllerQos programs the QoS limits of an Nvme remote controller in the firmware,
+// all 0 removes them
+func (s *Server) setNvmeRemoteControllerQos(ctx context.Context, ctrlrID string, qos *NvmeRemoteControllerQos) error {
+	params := models.MrvlBdevNvmeSetQosLimitsParams{
+		Name:                   ctrlrID,
+		RwIosPerSec:            qos.RwIopsKiops * 1000,
+		RMbytesPerSec:          qos.RdBandwidthMbs,
+		WMbytesPerSec:          qos.WrBandwidthMbs,
+		RwMbytesPerSec:         qos.RwBandwidthMbs,
+		BackgroundMbytesPerSec: qos.BackgroundBandwidthMbs,
+	}
+	var result models.MrvlBdevNvmeSetQosLimitsResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_nvme_set_qos_limits", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not set QoS limits of NVMe Ctrl: %s", ctrlrID)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_set_qos_limits")
+	}
+	return nil
+}
+
+// nvmeRemoteControllerQosKey is the database key of the QoS limits of an Nvme remote controller
+func nvmeRemoteControllerQosKey(name string) string {
+	return name + "/qos"
+}
+
+// getNvmeRemoteControllerQos fetches the QoS limits of an Nvme remote controller from the database,
+// they are not protobufs so they are stored JSON encoded
+func (s *Server) getNvmeRemoteControllerQos(name string) (*NvmeRemoteControllerQos, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(nvmeRemoteControllerQosKey(name), value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	qos := new(NvmeRemoteControllerQos)
+	if err := json.Unmarshal(value.Value, qos); err != nil {
+		return nil, false, err
+	}
+	return qos, true, nil
+}
+
+func (s *Server) saveNvmeRemoteControllerQos(name string, qos *NvmeRemoteControllerQos) error {
+	data, err := json.Marshal(qos)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(nvmeRemoteControllerQosKey(name), wrapperspb.Bytes(data))
+}
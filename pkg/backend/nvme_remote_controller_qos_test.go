@@ -0,0 +1,321 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var testNvmeRemoteControllerQos = NvmeRemoteControllerQos{
+	RwIopsKiops:            200,
+	RwBandwidthMbs:         2000,
+	BackgroundBandwidthMbs: 500,
+}
+
+func TestBackEnd_UpdateNvmeRemoteControllerQos(t *testing.T) {
+	tests := map[string]struct {
+		in      *UpdateNvmeRemoteControllerQosRequest
+		out     *NvmeRemoteControllerQos
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+		path    bool
+	}{
+		"missing qos": {
+			in:      &UpdateNvmeRemoteControllerQosRequest{Name: testNvmeRemoteControllerName},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: qos",
+			exist:   true,
+			path:    false,
+		},
+		"negative limit": {
+			in:      &UpdateNvmeRemoteControllerQosRequest{Name: testNvmeRemoteControllerName, Qos: &NvmeRemoteControllerQos{WrBandwidthMbs: -1}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("WrBandwidthMbs value (%d) can't be negative", -1),
+			exist:   true,
+			path:    false,
+		},
+		"no limit": {
+			in:      &UpdateNvmeRemoteControllerQosRequest{Name: testNvmeRemoteControllerName, Qos: &NvmeRemoteControllerQos{}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Qos has to set at least one of RwIopsKiops, RdBandwidthMbs, WrBandwidthMbs, RwBandwidthMbs or BackgroundBandwidthMbs",
+			exist:   true,
+			path:    false,
+		},
+		"background above total": {
+			in:      &UpdateNvmeRemoteControllerQosRequest{Name: testNvmeRemoteControllerName, Qos: &NvmeRemoteControllerQos{RwBandwidthMbs: 100, BackgroundBandwidthMbs: 200}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("BackgroundBandwidthMbs value (%d) is higher than RwBandwidthMbs (%d)", 200, 100),
+			exist:   true,
+			path:    false,
+		},
+		"unknown key": {
+			in:      &UpdateNvmeRemoteControllerQosRequest{Name: testNvmeRemoteControllerName, Qos: &testNvmeRemoteControllerQos},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testNvmeRemoteControllerName),
+			exist:   false,
+			path:    false,
+		},
+		"controller without path": {
+			in:      &UpdateNvmeRemoteControllerQosRequest{Name: testNvmeRemoteControllerName, Qos: &testNvmeRemoteControllerQos},
+			out:     &testNvmeRemoteControllerQos,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+			path:    false,
+		},
+		"controller with path and invalid SPDK response": {
+			in:      &UpdateNvmeRemoteControllerQosRequest{Name: testNvmeRemoteControllerName, Qos: &testNvmeRemoteControllerQos},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not set QoS limits of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+			exist:   true,
+			path:    true,
+		},
+		"controller with path": {
+			in:      &UpdateNvmeRemoteControllerQosRequest{Name: testNvmeRemoteControllerName, Qos: &testNvmeRemoteControllerQos},
+			out:     &testNvmeRemoteControllerQos,
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+			path:    true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+				testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			}
+			if tt.path {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+				testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+			}
+
+			response, err := testEnv.opiSpdkServer.UpdateNvmeRemoteControllerQos(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			// the limits are only saved when the firmware took them
+			_, found, _ := testEnv.opiSpdkServer.getNvmeRemoteControllerQos(testNvmeRemoteControllerName)
+			if found != (tt.errCode == codes.OK) {
+				t.Error("qos saved: expected", tt.errCode == codes.OK, "received", found)
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNvmeRemoteControllerQos(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *NvmeRemoteControllerQos
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testNvmeRemoteControllerName,
+			out:     &testNvmeRemoteControllerQos,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      "unknown-id",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "unknown-id"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerQos(testNvmeRemoteControllerName, &testNvmeRemoteControllerQos)
+
+			request := &GetNvmeRemoteControllerQosRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmeRemoteControllerQos(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteNvmeRemoteControllerQos(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+		path    bool
+	}{
+		"controller without path": {
+			in:      testNvmeRemoteControllerName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"controller with path": {
+			in:      testNvmeRemoteControllerName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+			path:    true,
+		},
+		"controller with path and invalid SPDK response": {
+			in:      testNvmeRemoteControllerName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not set QoS limits of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+			path:    true,
+		},
+		"unknown key": {
+			in:      "unknown-id",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "unknown-id"),
+		},
+		"unknown key with missing allowed": {
+			in:      "unknown-id",
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerQos(testNvmeRemoteControllerName, &testNvmeRemoteControllerQos)
+			if tt.path {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+				testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+			}
+
+			request := &DeleteNvmeRemoteControllerQosRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteNvmeRemoteControllerQos(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateNvmePathWithQos(t *testing.T) {
+	tests := map[string]struct {
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"limits applied": {
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`, testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"limits not applied": {
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`, testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not set QoS limits of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			controller := utils.ProtoClone(&testNvmeRemoteController)
+			controller.Multipath = pb.NvmeMultipath_NVME_MULTIPATH_DISABLE
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, controller)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerQos(testNvmeRemoteControllerName, &testNvmeRemoteControllerQos)
+			in := utils.ProtoClone(&testNvmePath)
+			in.Name = ""
+
+			request := &pb.CreateNvmePathRequest{Parent: testNvmeRemoteControllerName, NvmePath: in, NvmePathId: testNvmePathID}
+			_, err := testEnv.opiSpdkServer.CreateNvmePath(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
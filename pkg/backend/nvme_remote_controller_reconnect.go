@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// States of an Nvme remote controller
+const (
+	nvmeRemoteControllerStateConnected    = "CONNECTED"
+	nvmeRemoteControllerStateDegraded     = "DEGRADED"
+	nvmeRemoteControllerStateDisconnected = "DISCONNECTED"
+)
+
+// NvmeRemoteControllerReconnectOptions represents the keep alive and reconnect policy of the
+// fabrics connections of an Nvme remote controller which is not part of the OPI NvmeRemoteController,
+// 0 keeps the default of the bridge if set, else the firmware default
+type NvmeRemoteControllerReconnectOptions struct {
+	// KeepAliveTimeoutMs is the keep alive timeout negotiated with the target, a connection
+	// is lost when no keep alive completes within the timeout
+	KeepAliveTimeoutMs int32 `json:"keepAliveTimeoutMs"`
+	// ReconnectRetryCount is the number of reconnect attempts before the path is failed,
+	// 0 retries until the path is deleted
+	ReconnectRetryCount int32 `json:"reconnectRetryCount"`
+	// ReconnectDelaySec is the delay before the first reconnect attempt
+	ReconnectDelaySec int32 `json:"reconnectDelaySec"`
+	// MaxReconnectDelaySec caps the delay between the attempts, doubled after each failed
+	// attempt, 0 keeps retrying every ReconnectDelaySec
+	MaxReconnectDelaySec int32 `json:"maxReconnectDelaySec"`
+	// FastIoFailTimeoutSec is the time after which the IOs queued on a lost connection fail
+	// instead of waiting for the reconnect, so the host multipath can use another path
+	FastIoFailTimeoutSec int32 `json:"fastIoFailTimeoutSec"`
+}
+
+// NvmeRemoteControllerStatus represents the connectivity of an Nvme remote controller
+type NvmeRemoteControllerStatus struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+	// State is CONNECTED when all the paths are connected, DEGRADED when some are,
+	// DISCONNECTED when none is or the controller has no path
+	State string `json:"state"`
+	// ConnectedPaths is the number of connected paths
+	ConnectedPaths int32 `json:"connectedPaths"`
+	// Paths is the state of each path
+	Paths []*NvmePathStatus `json:"paths"`
+}
+
+// GetNvmeRemoteControllerReconnectOptionsRequest represents a request to get the reconnect options of an Nvme remote controller
+type GetNvmeRemoteControllerReconnectOptionsRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+}
+
+// UpdateNvmeRemoteControllerReconnectOptionsRequest represents a request to set the reconnect options of an Nvme remote controller
+type UpdateNvmeRemoteControllerReconnectOptionsRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+	// Options to set on the Nvme remote controller
+	Options *NvmeRemoteControllerReconnectOptions `json:"options"`
+}
+
+// GetNvmeRemoteControllerStatusRequest represents a request to get the connectivity of an Nvme remote controller
+type GetNvmeRemoteControllerStatusRequest struct {
+	// Name of the Nvme remote controller
+	Name string `json:"name"`
+}
+
+// GetNvmeRemoteControllerReconnectOptions gets the reconnect options of an Nvme remote controller
+func (s *Server) GetNvmeRemoteControllerReconnectOptions(_ context.Context, in *GetNvmeRemoteControllerReconnectOptionsRequest) (*NvmeRemoteControllerReconnectOptions, error) {
+	// check input correctness
+	if err := s.validateGetNvmeRemoteControllerReconnectOptionsRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	options, found, err := s.getNvmeRemoteControllerReconnectOptions(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return options, nil
+}
+
+// UpdateNvmeRemoteControllerReconnectOptions sets the reconnect options of an Nvme remote controller,
+// they are applied to the paths created afterwards and to the connected paths
+func (s *Server) UpdateNvmeRemoteControllerReconnectOptions(ctx context.Context, in *UpdateNvmeRemoteControllerReconnectOptionsRequest) (*NvmeRemoteControllerReconnectOptions, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeRemoteControllerReconnectOptionsRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	options := *in.Options
+	// the firmware only knows the controllers having paths
+	if len(s.nvmePathNames(controller.Name)) != 0 {
+		ctrlrID := path.Base(controller.Name)
+		effective := s.effectiveReconnectOptions(&options)
+		params := models.MrvlBdevNvmeSetReconnectOptionsParams{
+			Name:                 ctrlrID,
+			KeepAliveTimeoutMs:   int(effective.KeepAliveTimeoutMs),
+			ReconnectRetryCount:  int(effective.ReconnectRetryCount),
+			ReconnectDelaySec:    int(effective.ReconnectDelaySec),
+			MaxReconnectDelaySec: int(effective.MaxReconnectDelaySec),
+			FastIoFailTimeoutSec: int(effective.FastIoFailTimeoutSec),
+		}
+		var result models.MrvlBdevNvmeSetReconnectOptionsResult
+		err = s.rpc.Call(ctx, "mrvl_bdev_nvme_set_reconnect_options", &params, &result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not set reconnect options of NVMe Ctrl: %s", ctrlrID)
+			return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_nvme_set_reconnect_options")
+		}
+	}
+	// save object to the database
+	if err := s.saveNvmeRemoteControllerReconnectOptions(controller.Name, &options); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// GetNvmeRemoteControllerStatus gets the connectivity of an Nvme remote controller from the
+// firmware, so a lost target shows up before the next IO fails
+func (s *Server) GetNvmeRemoteControllerStatus(ctx context.Context, in *GetNvmeRemoteControllerStatusRequest) (*NvmeRemoteControllerStatus, error) {
+	// check input correctness
+	if err := s.validateGetNvmeRemoteControllerStatusRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeRemoteController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	controllerStatus := &NvmeRemoteControllerStatus{
+		Name:  controller.Name,
+		State: nvmeRemoteControllerStateDisconnected,
+		Paths: []*NvmePathStatus{},
+	}
+	names := s.nvmePathNames(controller.Name)
+	if len(names) == 0 {
+		return controllerStatus, nil
+	}
+	result, err := s.nvmeIoPaths(ctx, path.Base(controller.Name))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		nvmePath := new(pb.NvmePath)
+		found, err := s.store.Get(name, nvmePath)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		pathStatus := nvmePathStatus(nvmePath, result)
+		if pathStatus.State == nvmePathStateConnected {
+			controllerStatus.ConnectedPaths++
+		}
+		controllerStatus.Paths = append(controllerStatus.Paths, pathStatus)
+	}
+	if controllerStatus.ConnectedPaths != 0 {
+		controllerStatus.State = nvmeRemoteControllerStateDegraded
+		if int(controllerStatus.ConnectedPaths) == len(controllerStatus.Paths) {
+			controllerStatus.State = nvmeRemoteControllerStateConnected
+		}
+	}
+	return controllerStatus, nil
+}
+
+// SetDefaultReconnectOptions sets the keep alive timeout and the reconnect delay of the remote
+// controllers which don't set them, the firmware defaults only suit targets in the same rack
+func (s *Server) SetDefaultReconnectOptions(keepAliveTimeoutMs int32, reconnectDelaySec int32) {
+	s.defaultReconnectOptions.KeepAliveTimeoutMs = keepAliveTimeoutMs
+	s.defaultReconnectOptions.ReconnectDelaySec = reconnectDelaySec
+}
+
+// effectiveReconnectOptions fills the options of a remote controller left to 0 with the
+// defaults of the bridge, options is nil when the remote controller has none
+func (s *Server) effectiveReconnectOptions(options *NvmeRemoteControllerReconnectOptions) NvmeRemoteControllerReconnectOptions {
+	effective := s.defaultReconnectOptions
+	if options == nil {
+		return effective
+	}
+	if options.KeepAliveTimeoutMs != 0 {
+		effective.KeepAliveTimeoutMs = options.KeepAliveTimeoutMs
+	}
+	if options.ReconnectDelaySec != 0 {
+		effective.ReconnectDelaySec = options.ReconnectDelaySec
+	}
+	effective.ReconnectRetryCount = options.ReconnectRetryCount
+	effective.MaxReconnectDelaySec = options.MaxReconnectDelaySec
+	effective.FastIoFailTimeoutSec = options.FastIoFailTimeoutSec
+	// the backoff can't start above its cap
+	if effective.MaxReconnectDelaySec != 0 && effective.MaxReconnectDelaySec < effective.ReconnectDelaySec {
+		effective.MaxReconnectDelaySec = effective.ReconnectDelaySec
+	}
+	return effective
+}
+
+// nvmeRemoteControllerReconnectOptionsKey is the database key of the reconnect options of an Nvme remote controller
+func nvmeRemoteControllerReconnectOptionsKey(name string) string {
+	return name + "/reconnectOptions"
+}
+
+// getNvmeRemoteControllerReconnectOptions fetches the reconnect options of an Nvme remote controller
+// from the database, options are not protobufs so they are stored JSON encoded
+func (s *Server) getNvmeRemoteControllerReconnectOptions(name string) (*NvmeRemoteControllerReconnectOptions, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(nvmeRemoteControllerReconnectOptionsKey(name), value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	options := new(NvmeRemoteControllerReconnectOptions)
+	if err := json.Unmarshal(value.Value, options); err != nil {
+		return nil, false, err
+	}
+	return options, true, nil
+}
+
+func (s *Server) saveNvmeRemoteControllerReconnectOptions(name string, options *NvmeRemoteControllerReconnectOptions) error {
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(nvmeRemoteControllerReconnectOptionsKey(name), wrapperspb.Bytes(data))
+}
+
+func (s *Server) deleteNvmeRemoteControllerReconnectOptions(name string) error {
+	return s.store.Delete(nvmeRemoteControllerReconnectOptionsKey(name))
+}
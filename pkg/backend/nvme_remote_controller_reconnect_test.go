@@ -0,0 +1,367 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var testNvmeRemoteControllerReconnectOptions = NvmeRemoteControllerReconnectOptions{
+	KeepAliveTimeoutMs:   10000,
+	ReconnectRetryCount:  30,
+	ReconnectDelaySec:    1,
+	MaxReconnectDelaySec: 16,
+	FastIoFailTimeoutSec: 5,
+}
+
+func TestBackEnd_UpdateNvmeRemoteControllerReconnectOptions(t *testing.T) {
+	tests := map[string]struct {
+		in      *UpdateNvmeRemoteControllerReconnectOptionsRequest
+		out     *NvmeRemoteControllerReconnectOptions
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+		path    bool
+	}{
+		"missing options": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: options",
+			exist:   true,
+			path:    false,
+		},
+		"keep alive timeout out of range": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName, Options: &NvmeRemoteControllerReconnectOptions{KeepAliveTimeoutMs: 3600001}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("KeepAliveTimeoutMs value (%d) is out of range, have to be between 0 and 3600000", 3600001),
+			exist:   true,
+			path:    false,
+		},
+		"negative retry count": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName, Options: &NvmeRemoteControllerReconnectOptions{ReconnectRetryCount: -1}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("ReconnectRetryCount value (%d) can't be negative", -1),
+			exist:   true,
+			path:    false,
+		},
+		"max delay lower than delay": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName, Options: &NvmeRemoteControllerReconnectOptions{ReconnectDelaySec: 10, MaxReconnectDelaySec: 5}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("MaxReconnectDelaySec value (%d) is lower than ReconnectDelaySec (%d)", 5, 10),
+			exist:   true,
+			path:    false,
+		},
+		"negative fast io fail timeout": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName, Options: &NvmeRemoteControllerReconnectOptions{FastIoFailTimeoutSec: -1}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("FastIoFailTimeoutSec value (%d) can't be negative", -1),
+			exist:   true,
+			path:    false,
+		},
+		"unknown key": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName, Options: &testNvmeRemoteControllerReconnectOptions},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testNvmeRemoteControllerName),
+			exist:   false,
+			path:    false,
+		},
+		"controller without path": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName, Options: &testNvmeRemoteControllerReconnectOptions},
+			out:     &testNvmeRemoteControllerReconnectOptions,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+			path:    false,
+		},
+		"controller with path and invalid SPDK response": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName, Options: &testNvmeRemoteControllerReconnectOptions},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not set reconnect options of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+			exist:   true,
+			path:    true,
+		},
+		"controller with path": {
+			in:      &UpdateNvmeRemoteControllerReconnectOptionsRequest{Name: testNvmeRemoteControllerName, Options: &testNvmeRemoteControllerReconnectOptions},
+			out:     &testNvmeRemoteControllerReconnectOptions,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+			path:    true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+				testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			}
+			if tt.path {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+				testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+			}
+
+			response, err := testEnv.opiSpdkServer.UpdateNvmeRemoteControllerReconnectOptions(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			// the options are only saved when the firmware took them
+			_, found, _ := testEnv.opiSpdkServer.getNvmeRemoteControllerReconnectOptions(testNvmeRemoteControllerName)
+			if found != (tt.errCode == codes.OK) {
+				t.Error("options saved: expected", tt.errCode == codes.OK, "received", found)
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNvmeRemoteControllerReconnectOptions(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *NvmeRemoteControllerReconnectOptions
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testNvmeRemoteControllerName,
+			out:     &testNvmeRemoteControllerReconnectOptions,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      "unknown-id",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "unknown-id"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerReconnectOptions(testNvmeRemoteControllerName, &testNvmeRemoteControllerReconnectOptions)
+
+			request := &GetNvmeRemoteControllerReconnectOptionsRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmeRemoteControllerReconnectOptions(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNvmeRemoteControllerStatus(t *testing.T) {
+	testNvmePath1 := utils.ProtoClone(&testNvmePath)
+	testNvmePath1.Name = testNvmeRemoteControllerName + "/nvmePaths/path1"
+	testNvmePath1.Traddr = "10.10.10.12"
+	ioPaths := func(first string, second string) string {
+		return fmt.Sprintf(`{"id":%%d,"error":{"code":0,"message":""},"result":{"status": 0, "io_paths": [{"traddr": "10.10.10.11", "trsvcid": "4420", %s, "ana_state": "optimized"}, {"traddr": "10.10.10.12", "trsvcid": "4420", %s, "ana_state": "optimized"}]}}`, first, second)
+	}
+	tests := map[string]struct {
+		in      string
+		out     *NvmeRemoteControllerStatus
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		paths   bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmeRemoteControllerName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get IO paths of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+			paths:   true,
+		},
+		"controller without path": {
+			in: testNvmeRemoteControllerName,
+			out: &NvmeRemoteControllerStatus{
+				Name:  testNvmeRemoteControllerName,
+				State: nvmeRemoteControllerStateDisconnected,
+				Paths: []*NvmePathStatus{},
+			},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			paths:   false,
+		},
+		"all paths connected": {
+			in: testNvmeRemoteControllerName,
+			out: &NvmeRemoteControllerStatus{
+				Name:           testNvmeRemoteControllerName,
+				State:          nvmeRemoteControllerStateConnected,
+				ConnectedPaths: 2,
+				Paths: []*NvmePathStatus{
+					{Name: testNvmePathName, State: nvmePathStateConnected, AnaState: "OPTIMIZED"},
+					{Name: testNvmePath1.Name, State: nvmePathStateConnected, AnaState: "OPTIMIZED"},
+				},
+			},
+			spdk:    []string{ioPaths(`"connected": true`, `"connected": true`)},
+			errCode: codes.OK,
+			errMsg:  "",
+			paths:   true,
+		},
+		"one path reconnecting": {
+			in: testNvmeRemoteControllerName,
+			out: &NvmeRemoteControllerStatus{
+				Name:           testNvmeRemoteControllerName,
+				State:          nvmeRemoteControllerStateDegraded,
+				ConnectedPaths: 1,
+				Paths: []*NvmePathStatus{
+					{Name: testNvmePathName, State: nvmePathStateDisconnected, AnaState: "OPTIMIZED"},
+					{Name: testNvmePath1.Name, State: nvmePathStateConnected, AnaState: "OPTIMIZED"},
+				},
+			},
+			spdk:    []string{ioPaths(`"connected": false`, `"connected": true`)},
+			errCode: codes.OK,
+			errMsg:  "",
+			paths:   true,
+		},
+		"all paths failed": {
+			in: testNvmeRemoteControllerName,
+			out: &NvmeRemoteControllerStatus{
+				Name:  testNvmeRemoteControllerName,
+				State: nvmeRemoteControllerStateDisconnected,
+				Paths: []*NvmePathStatus{
+					{Name: testNvmePathName, State: nvmePathStateFailed, AnaState: "OPTIMIZED"},
+					{Name: testNvmePath1.Name, State: nvmePathStateFailed, AnaState: "OPTIMIZED"},
+				},
+			},
+			spdk:    []string{ioPaths(`"connected": false, "failed": true`, `"connected": false, "failed": true`)},
+			errCode: codes.OK,
+			errMsg:  "",
+			paths:   true,
+		},
+		"unknown key": {
+			in:      "unknown-id",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "unknown-id"),
+			paths:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			if tt.paths {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+				testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePath1.Name, testNvmePath1)
+				testEnv.opiSpdkServer.ListHelper[testNvmePath1.Name] = false
+			}
+
+			request := &GetNvmeRemoteControllerStatusRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmeRemoteControllerStatus(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_EffectiveReconnectOptions(t *testing.T) {
+	tests := map[string]struct {
+		in  *NvmeRemoteControllerReconnectOptions
+		out NvmeRemoteControllerReconnectOptions
+	}{
+		"controller without options": {
+			in:  nil,
+			out: NvmeRemoteControllerReconnectOptions{KeepAliveTimeoutMs: 30000, ReconnectDelaySec: 10},
+		},
+		"options left to 0": {
+			in:  &NvmeRemoteControllerReconnectOptions{ReconnectRetryCount: 5, FastIoFailTimeoutSec: 20},
+			out: NvmeRemoteControllerReconnectOptions{KeepAliveTimeoutMs: 30000, ReconnectRetryCount: 5, ReconnectDelaySec: 10, FastIoFailTimeoutSec: 20},
+		},
+		"options overriding the defaults": {
+			in:  &testNvmeRemoteControllerReconnectOptions,
+			out: testNvmeRemoteControllerReconnectOptions,
+		},
+		"max delay below the default delay": {
+			in:  &NvmeRemoteControllerReconnectOptions{MaxReconnectDelaySec: 5},
+			out: NvmeRemoteControllerReconnectOptions{KeepAliveTimeoutMs: 30000, ReconnectDelaySec: 10, MaxReconnectDelaySec: 10},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.SetDefaultReconnectOptions(30000, 10)
+
+			response := testEnv.opiSpdkServer.effectiveReconnectOptions(tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+		})
+	}
+}
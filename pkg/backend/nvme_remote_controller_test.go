@@ -0,0 +1,579 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestBackEnd_CreateNvmeRemoteController(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *pb.NvmeRemoteController
+		out     *pb.NvmeRemoteController
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &testNvmeRemoteController,
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"unknown multipath": {
+			id:      testNvmeRemoteControllerID,
+			in:      &pb.NvmeRemoteController{Multipath: pb.NvmeMultipath(42)},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Multipath value (%v) is not supported, have to be disable, failover or multipath", pb.NvmeMultipath(42)),
+			exist:   false,
+		},
+		"negative queue size": {
+			id:      testNvmeRemoteControllerID,
+			in:      &pb.NvmeRemoteController{Multipath: pb.NvmeMultipath_NVME_MULTIPATH_DISABLE, QueueSize: -1},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("QueueSize value (%d) can't be negative", -1),
+			exist:   false,
+		},
+		"valid request": {
+			id:      testNvmeRemoteControllerID,
+			in:      &testNvmeRemoteController,
+			out:     &testNvmeRemoteController,
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testNvmeRemoteControllerID,
+			in:      &pb.NvmeRemoteController{Multipath: pb.NvmeMultipath_NVME_MULTIPATH_DISABLE},
+			out:     &testNvmeRemoteController,
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"no required field": {
+			id:      testNvmeRemoteControllerID,
+			in:      nil,
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: nvme_remote_controller",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			}
+			if tt.in != nil {
+				tt.in = utils.ProtoClone(tt.in)
+				tt.in.Name = ""
+			}
+
+			request := &pb.CreateNvmeRemoteControllerRequest{NvmeRemoteController: tt.in, NvmeRemoteControllerId: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateNvmeRemoteController(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteNvmeRemoteController(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		errCode codes.Code
+		errMsg  string
+		missing bool
+		paths   bool
+	}{
+		"valid request": {
+			in:      testNvmeRemoteControllerName,
+			out:     &emptypb.Empty{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+			paths:   false,
+		},
+		"controller with paths": {
+			in:      testNvmeRemoteControllerName,
+			out:     nil,
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("NvmeRemoteController %v still has %d paths, delete them first", testNvmeRemoteControllerName, 1),
+			missing: false,
+			paths:   true,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRemoteControllerName("unknown-id")),
+			missing: false,
+			paths:   false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			out:     &emptypb.Empty{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+			paths:   false,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+			paths:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			if tt.paths {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+				testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+			}
+
+			request := &pb.DeleteNvmeRemoteControllerRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteNvmeRemoteController(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_UpdateNvmeRemoteController(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		created bool
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      testNvmeRemoteControllerName,
+			created: false,
+			errCode: codes.Unimplemented,
+			errMsg:  "UpdateNvmeRemoteController method is not implemented",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			created: false,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRemoteControllerName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			created: true,
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			created: false,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			in := utils.ProtoClone(&testNvmeRemoteController)
+			in.Name = tt.in
+			var out *pb.NvmeRemoteController
+			if tt.created {
+				out = in
+			}
+
+			request := &pb.UpdateNvmeRemoteControllerRequest{NvmeRemoteController: in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.UpdateNvmeRemoteController(testEnv.ctx, request)
+
+			if !proto.Equal(response, out) {
+				t.Error("response: expected", out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListNvmeRemoteControllers(t *testing.T) {
+	testNvmeRemoteController1 := utils.ProtoClone(&testNvmeRemoteController)
+	testNvmeRemoteController1.Name = resourceIDToRemoteControllerName("remote1")
+	tests := map[string]struct {
+		size    int32
+		token   string
+		out     []*pb.NvmeRemoteController
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			size:    0,
+			token:   "",
+			out:     []*pb.NvmeRemoteController{&testNvmeRemoteController, testNvmeRemoteController1},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination": {
+			size:    1,
+			token:   "",
+			out:     []*pb.NvmeRemoteController{&testNvmeRemoteController},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pagination negative": {
+			size:    -10,
+			token:   "",
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+		},
+		"pagination error": {
+			size:    0,
+			token:   "unknown-pagination-token",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			for _, controller := range []*pb.NvmeRemoteController{testNvmeRemoteController1, &testNvmeRemoteController} {
+				_ = testEnv.opiSpdkServer.store.Set(controller.Name, controller)
+				testEnv.opiSpdkServer.ListHelper[controller.Name] = false
+			}
+			// paths are not listed as controllers
+			_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+			testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+
+			request := &pb.ListNvmeRemoteControllersRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListNvmeRemoteControllers(testEnv.ctx, request)
+
+			if !utils.EqualProtoSlices(response.GetNvmeRemoteControllers(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetNvmeRemoteControllers())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			// Empty NextPageToken indicates end of results list
+			if tt.size != 1 && response.GetNextPageToken() != "" {
+				t.Error("Expected end of results, received non-empty next page token", response.GetNextPageToken())
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetNvmeRemoteController(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.NvmeRemoteController
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testNvmeRemoteControllerName,
+			out:     &testNvmeRemoteController,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRemoteControllerName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+
+			request := &pb.GetNvmeRemoteControllerRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmeRemoteController(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ResetNvmeRemoteController(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmeRemoteControllerName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not reset NVMe Ctrl: %v", testNvmeRemoteControllerID),
+		},
+		"valid request with empty SPDK response": {
+			in:      testNvmeRemoteControllerName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_nvme_reset_controller: %v", "EOF"),
+		},
+		"valid request with valid SPDK response": {
+			in:      testNvmeRemoteControllerName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRemoteControllerName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+
+			request := &pb.ResetNvmeRemoteControllerRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.ResetNvmeRemoteController(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_StatsNvmeRemoteController(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.VolumeStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmeRemoteControllerName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not stats NVMe Ctrl: %v", testNvmeRemoteControllerID),
+		},
+		"valid request with valid SPDK response": {
+			in: testNvmeRemoteControllerName,
+			out: &pb.VolumeStats{
+				ReadBytesCount:    4096,
+				ReadOpsCount:      1,
+				WriteBytesCount:   8192,
+				WriteOpsCount:     2,
+				ReadLatencyTicks:  10,
+				WriteLatencyTicks: 20,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "num_read_cmds": 1, "num_read_bytes": 4096, "num_write_cmds": 2, "num_write_bytes": 8192, "total_read_latency_in_us": 10, "total_write_latency_in_us": 20}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRemoteControllerName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+
+			request := &pb.StatsNvmeRemoteControllerRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsNvmeRemoteController(testEnv.ctx, request)
+
+			if !proto.Equal(response.GetStats(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetStats())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListNvmeRemoteNamespaces(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     []*pb.NvmeRemoteNamespace
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testNvmeRemoteControllerName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not list NS of NVMe Ctrl: %v", testNvmeRemoteControllerID),
+		},
+		"valid request with valid SPDK response": {
+			in: testNvmeRemoteControllerName,
+			out: []*pb.NvmeRemoteNamespace{
+				{Name: testNvmeRemoteControllerName + "/nvmeRemoteNamespaces/remote0n1", Nsid: 1, Uuid: "1b4e28ba-2fa1-11d2-883f-b9a761bde3fb"},
+				{Name: testNvmeRemoteControllerName + "/nvmeRemoteNamespaces/remote0n2", Nsid: 2, Eui64: 1234},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ns_list": [{"nsid": 2, "bdev_name": "remote0n2", "eui64": 1234}, {"nsid": 1, "bdev_name": "remote0n1", "uuid": "1b4e28ba-2fa1-11d2-883f-b9a761bde3fb"}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRemoteControllerName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRemoteControllerName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+
+			request := &pb.ListNvmeRemoteNamespacesRequest{Parent: tt.in}
+			response, err := testEnv.opiSpdkServer.ListNvmeRemoteNamespaces(testEnv.ctx, request)
+
+			if !utils.EqualProtoSlices(response.GetNvmeRemoteNamespaces(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetNvmeRemoteNamespaces())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func (s *Server) validateCreateNvmeRemoteControllerRequest(in *pb.CreateNvmeRemoteControllerRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.NvmeRemoteControllerId != "" {
+		if err := resourceid.ValidateUserSettable(in.NvmeRemoteControllerId); err != nil {
+			return err
+		}
+	}
+	// check Multipath, the firmware has no default mode
+	if _, ok := nvmeMultipathModes[in.NvmeRemoteController.Multipath]; !ok {
+		msg := fmt.Sprintf("Multipath value (%v) is not supported, have to be disable, failover or multipath", in.NvmeRemoteController.Multipath)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check queues, 0 leaves the firmware defaults
+	if in.NvmeRemoteController.IoQueuesCount < 0 {
+		msg := fmt.Sprintf("IoQueuesCount value (%d) can't be negative", in.NvmeRemoteController.IoQueuesCount)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.NvmeRemoteController.QueueSize < 0 {
+		msg := fmt.Sprintf("QueueSize value (%d) can't be negative", in.NvmeRemoteController.QueueSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteNvmeRemoteControllerRequest(in *pb.DeleteNvmeRemoteControllerRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateUpdateNvmeRemoteControllerRequest(in *pb.UpdateNvmeRemoteControllerRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.NvmeRemoteController.Name)
+}
+
+func (s *Server) validateGetNvmeRemoteControllerRequest(in *pb.GetNvmeRemoteControllerRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateResetNvmeRemoteControllerRequest(in *pb.ResetNvmeRemoteControllerRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateStatsNvmeRemoteControllerRequest(in *pb.StatsNvmeRemoteControllerRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateUpdateNvmeRemoteControllerReconnectOptionsRequest(in *UpdateNvmeRemoteControllerReconnectOptionsRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	if in.Options == nil {
+		return errors.New("missing required field: options")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	if err := resourcename.Validate(in.Name); err != nil {
+		return err
+	}
+	// check KeepAliveTimeoutMs, the firmware sends a keep alive every half timeout
+	if in.Options.KeepAliveTimeoutMs < 0 || in.Options.KeepAliveTimeoutMs > 3600000 {
+		msg := fmt.Sprintf("KeepAliveTimeoutMs value (%d) is out of range, have to be between 0 and 3600000", in.Options.KeepAliveTimeoutMs)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.Options.ReconnectRetryCount < 0 {
+		msg := fmt.Sprintf("ReconnectRetryCount value (%d) can't be negative", in.Options.ReconnectRetryCount)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check the reconnect delays, the backoff doubles the delay up to the max
+	if in.Options.ReconnectDelaySec < 0 || in.Options.ReconnectDelaySec > 3600 {
+		msg := fmt.Sprintf("ReconnectDelaySec value (%d) is out of range, have to be between 0 and 3600", in.Options.ReconnectDelaySec)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.Options.MaxReconnectDelaySec < 0 || in.Options.MaxReconnectDelaySec > 3600 {
+		msg := fmt.Sprintf("MaxReconnectDelaySec value (%d) is out of range, have to be between 0 and 3600", in.Options.MaxReconnectDelaySec)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.Options.MaxReconnectDelaySec != 0 && in.Options.MaxReconnectDelaySec < in.Options.ReconnectDelaySec {
+		msg := fmt.Sprintf("MaxReconnectDelaySec value (%d) is lower than ReconnectDelaySec (%d)", in.Options.MaxReconnectDelaySec, in.Options.ReconnectDelaySec)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.Options.FastIoFailTimeoutSec < 0 {
+		msg := fmt.Sprintf("FastIoFailTimeoutSec value (%d) can't be negative", in.Options.FastIoFailTimeoutSec)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateGetNvmeRemoteControllerReconnectOptionsRequest(in *GetNvmeRemoteControllerReconnectOptionsRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetNvmeRemoteControllerStatusRequest(in *GetNvmeRemoteControllerStatusRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+// dhchapSecret matches the DH-HMAC-CHAP secret representation, the base64 encoded secret and
+// its CRC-32 with the hash function used to transform it, 00 when not transformed
+var dhchapSecret = regexp.MustCompile(`^DHHC-1:0[0-3]:[A-Za-z0-9+/]+={0,2}:$`)
+
+func (s *Server) validateUpdateNvmeRemoteControllerDhchapRequest(in *UpdateNvmeRemoteControllerDhchapRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	if in.Dhchap == nil {
+		return errors.New("missing required field: dhchap")
+	}
+	if in.Dhchap.Key == "" {
+		return errors.New("missing required field: dhchap.key")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	if err := resourcename.Validate(in.Name); err != nil {
+		return err
+	}
+	// check the secrets, without logging them
+	if !dhchapSecret.MatchString(in.Dhchap.Key) {
+		msg := "Key value is not a DH-HMAC-CHAP secret, have to be DHHC-1:xx:<base64>:"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.Dhchap.CtrlrKey != "" && !dhchapSecret.MatchString(in.Dhchap.CtrlrKey) {
+		msg := "CtrlrKey value is not a DH-HMAC-CHAP secret, have to be DHHC-1:xx:<base64>:"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	for _, digest := range in.Dhchap.Digests {
+		if !nvmeDhchapDigests[digest] {
+			msg := fmt.Sprintf("Digests value (%s) is not supported, have to be sha256, sha384 or sha512", digest)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	for _, dhgroup := range in.Dhchap.Dhgroups {
+		if !nvmeDhchapDhgroups[dhgroup] {
+			msg := fmt.Sprintf("Dhgroups value (%s) is not supported, have to be null, ffdhe2048, ffdhe3072, ffdhe4096, ffdhe6144 or ffdhe8192", dhgroup)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	return nil
+}
+
+func (s *Server) validateGetNvmeRemoteControllerDhchapRequest(in *GetNvmeRemoteControllerDhchapRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateDeleteNvmeRemoteControllerDhchapRequest(in *DeleteNvmeRemoteControllerDhchapRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateUpdateNvmeRemoteControllerQosRequest(in *UpdateNvmeRemoteControllerQosRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	if in.Qos == nil {
+		return errors.New("missing required field: qos")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	if err := resourcename.Validate(in.Name); err != nil {
+		return err
+	}
+	// check the limits, removing all of them is done by deleting the QoS
+	values := []struct {
+		field string
+		value int64
+	}{
+		{"RwIopsKiops", in.Qos.RwIopsKiops},
+		{"RdBandwidthMbs", in.Qos.RdBandwidthMbs},
+		{"WrBandwidthMbs", in.Qos.WrBandwidthMbs},
+		{"RwBandwidthMbs", in.Qos.RwBandwidthMbs},
+		{"BackgroundBandwidthMbs", in.Qos.BackgroundBandwidthMbs},
+	}
+	set := false
+	for _, v := range values {
+		if v.value < 0 {
+			msg := fmt.Sprintf("%s value (%d) can't be negative", v.field, v.value)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		set = set || v.value != 0
+	}
+	if !set {
+		msg := "Qos has to set at least one of RwIopsKiops, RdBandwidthMbs, WrBandwidthMbs, RwBandwidthMbs or BackgroundBandwidthMbs"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// the background IOs are part of the read and write IOs
+	if in.Qos.RwBandwidthMbs != 0 && in.Qos.BackgroundBandwidthMbs > in.Qos.RwBandwidthMbs {
+		msg := fmt.Sprintf("BackgroundBandwidthMbs value (%d) is higher than RwBandwidthMbs (%d)", in.Qos.BackgroundBandwidthMbs, in.Qos.RwBandwidthMbs)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateGetNvmeRemoteControllerQosRequest(in *GetNvmeRemoteControllerQosRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateDeleteNvmeRemoteControllerQosRequest(in *DeleteNvmeRemoteControllerQosRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// States of a copy or write zeroes offloaded to the firmware
+const (
+	offloadStateRunning = "running"
+	offloadStateDone    = "done"
+	offloadStateAborted = "aborted"
+)
+
+// CopyVolumeRequest represents a request to copy a volume to another one on the DPU
+type CopyVolumeRequest struct {
+	// Volume is the name of the volume copied from
+	Volume string `json:"volume"`
+	// DestinationVolume is the name of the volume copied to, at least as large as Volume
+	DestinationVolume string `json:"destinationVolume"`
+}
+
+// CopyVolumeMetadata describes a copy operation
+type CopyVolumeMetadata struct {
+	// Volume is the name of the volume copied from
+	Volume string `json:"volume"`
+	// DestinationVolume is the name of the volume copied to
+	DestinationVolume string `json:"destinationVolume"`
+}
+
+// WriteZeroesVolumeRequest represents a request to zero a range of a volume on the DPU
+type WriteZeroesVolumeRequest struct {
+	// Volume is the name of the volume, as used in the namespaces
+	Volume string `json:"volume"`
+	// OffsetBytes is the start of the range, a multiple of 512
+	OffsetBytes uint64 `json:"offsetBytes"`
+	// LengthBytes is the size of the range, a multiple of 512, up to the end of the volume when 0
+	LengthBytes uint64 `json:"lengthBytes"`
+	// Deallocate the range instead of writing zeroes, it reads back as zeroes and the
+	// capacity is released on thin provisioned volumes
+	Deallocate bool `json:"deallocate"`
+}
+
+// WriteZeroesVolumeMetadata describes a write zeroes operation
+type WriteZeroesVolumeMetadata struct {
+	// Volume is the name of the volume
+	Volume string `json:"volume"`
+	// OffsetBytes is the start of the range
+	OffsetBytes uint64 `json:"offsetBytes"`
+	// LengthBytes is the size of the range, up to the end of the volume when 0
+	LengthBytes uint64 `json:"lengthBytes"`
+	// Deallocate is set when the range is deallocated instead of written
+	Deallocate bool `json:"deallocate"`
+}
+
+// CopyVolume copies a volume to another one, returning a long-running operation. The data is
+// moved by the DPU without going through the hosts, i.e. to clone a volume across backends.
+// The operation can be cancelled, the data copied so far is left on the destination volume
+func (s *Server) CopyVolume(ctx context.Context, in *CopyVolumeRequest) (*operations.Operation, error) {
+	// check input correctness
+	if err := s.validateCopyVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	params := models.MrvlBdevCopyStartParams{
+		SrcBdev: in.Volume,
+		DstBdev: in.DestinationVolume,
+	}
+	var result models.MrvlBdevCopyStartResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_copy_start", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not copy %s to %s", in.Volume, in.DestinationVolume)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_copy_start")
+	}
+	metadata := &CopyVolumeMetadata{
+		Volume:            in.Volume,
+		DestinationVolume: in.DestinationVolume,
+	}
+	return s.operations.StartCancellable(metadata, func(ctx context.Context) (interface{}, error) {
+		err := s.waitVolumeOffload(ctx, "mrvl_bdev_copy_get_status", in.DestinationVolume)
+		if err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	}, func(ctx context.Context) error {
+		return s.abortVolumeOffload(ctx, "mrvl_bdev_copy_abort", in.DestinationVolume)
+	}), nil
+}
+
+// WriteZeroesVolume zeroes or deallocates a range of a volume, returning a long-running
+// operation. The firmware issues the Write Zeroes or Deallocate commands itself, i.e. to
+// wipe a volume before handing it to another tenant. The operation can be cancelled, the
+// range is then partially zeroed
+func (s *Server) WriteZeroesVolume(ctx context.Context, in *WriteZeroesVolumeRequest) (*operations.Operation, error) {
+	// check input correctness
+	if err := s.validateWriteZeroesVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	params := models.MrvlBdevWriteZeroesStartParams{
+		Name:        in.Volume,
+		OffsetBytes: in.OffsetBytes,
+		LengthBytes: in.LengthBytes,
+		Deallocate:  in.Deallocate,
+	}
+	var result models.MrvlBdevWriteZeroesStartResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_write_zeroes_start", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not write zeroes to %s", in.Volume)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_write_zeroes_start")
+	}
+	metadata := &WriteZeroesVolumeMetadata{
+		Volume:      in.Volume,
+		OffsetBytes: in.OffsetBytes,
+		LengthBytes: in.LengthBytes,
+		Deallocate:  in.Deallocate,
+	}
+	return s.operations.StartCancellable(metadata, func(ctx context.Context) (interface{}, error) {
+		err := s.waitVolumeOffload(ctx, "mrvl_bdev_write_zeroes_get_status", in.Volume)
+		if err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	}, func(ctx context.Context) error {
+		return s.abortVolumeOffload(ctx, "mrvl_bdev_write_zeroes_abort", in.Volume)
+	}), nil
+}
+
+// waitVolumeOffload polls the firmware until the copy or write zeroes on a volume is done
+func (s *Server) waitVolumeOffload(ctx context.Context, method string, volume string) error {
+	for {
+		params := models.MrvlBdevOffloadGetStatusParams{
+			Name: volume,
+		}
+		var result models.MrvlBdevOffloadGetStatusResult
+		err := s.rpc.Call(ctx, method, &params, &result)
+		if err != nil {
+			return err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not get offload status of %s", volume)
+			return result.Status.Err(codes.InvalidArgument, msg, method)
+		}
+		switch result.State {
+		case offloadStateRunning:
+			operations.ReportProgress(ctx, int32(result.Progress))
+			operations.ReportBytes(ctx, result.BytesDone, result.TotalBytes)
+		case offloadStateDone:
+			slog.Info("Offload is done", "volume", volume)
+			return nil
+		case offloadStateAborted:
+			msg := fmt.Sprintf("Offload on %s cancelled, the volume is partially written", volume)
+			return status.Errorf(codes.Canceled, msg)
+		default:
+			// the data written so far is left as is
+			msg := fmt.Sprintf("Offload on %s failed, the volume is partially written", volume)
+			return status.Errorf(codes.Aborted, msg)
+		}
+		time.Sleep(s.offloadPollInterval)
+	}
+}
+
+// abortVolumeOffload makes the firmware stop the copy or write zeroes on a volume
+func (s *Server) abortVolumeOffload(ctx context.Context, method string, volume string) error {
+	params := models.MrvlBdevOffloadAbortParams{
+		Name: volume,
+	}
+	var result models.MrvlBdevOffloadAbortResult
+	err := s.rpc.Call(ctx, method, &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not abort offload on %s", volume)
+		return result.Status.Err(codes.FailedPrecondition, msg, method)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+)
+
+var (
+	testOffloadRunning = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "running", "progress": 50}}`
+	testOffloadDone    = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "done", "progress": 100}}`
+	testOffloadFailed  = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "failed", "progress": 50}}`
+	testOffloadAborted = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "aborted", "progress": 50}}`
+)
+
+func TestBackEnd_CopyVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *CopyVolumeRequest
+		out     *CopyVolumeMetadata
+		opErr   *operations.Error
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with valid SPDK response": {
+			in:      &CopyVolumeRequest{Volume: "Malloc0", DestinationVolume: "Malloc1"},
+			out:     &CopyVolumeMetadata{Volume: "Malloc0", DestinationVolume: "Malloc1"},
+			opErr:   nil,
+			spdk:    []string{testSuccessResponse, testOffloadRunning, testOffloadDone},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"copy failure": {
+			in:  &CopyVolumeRequest{Volume: "Malloc0", DestinationVolume: "Malloc1"},
+			out: nil,
+			opErr: &operations.Error{
+				Code:    codes.Aborted,
+				Message: fmt.Sprintf("Offload on %v failed, the volume is partially written", "Malloc1"),
+			},
+			spdk:    []string{testSuccessResponse, testOffloadRunning, testOffloadFailed},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"copy cancelled": {
+			in:  &CopyVolumeRequest{Volume: "Malloc0", DestinationVolume: "Malloc1"},
+			out: nil,
+			opErr: &operations.Error{
+				Code:    codes.Canceled,
+				Message: fmt.Sprintf("Offload on %v cancelled, the volume is partially written", "Malloc1"),
+			},
+			spdk:    []string{testSuccessResponse, testOffloadRunning, testOffloadAborted},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with invalid SPDK response": {
+			in:      &CopyVolumeRequest{Volume: "Malloc0", DestinationVolume: "Malloc1"},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not copy %v to %v", "Malloc0", "Malloc1"),
+		},
+		"valid request with empty SPDK response": {
+			in:      &CopyVolumeRequest{Volume: "Malloc0", DestinationVolume: "Malloc1"},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_copy_start: %v", "EOF"),
+		},
+		"copy to itself": {
+			in:      &CopyVolumeRequest{Volume: "Malloc0", DestinationVolume: "Malloc0"},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Volume %v can't be copied to itself", "Malloc0"),
+		},
+		"no required field": {
+			in:      &CopyVolumeRequest{Volume: "Malloc0"},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: destination_volume",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			op, err := testEnv.opiSpdkServer.CopyVolume(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err != nil {
+				return
+			}
+
+			testEnv.opiSpdkServer.operations.Wait()
+			response, err := testEnv.opiSpdkServer.operations.GetOperation(testEnv.ctx, &operations.GetOperationRequest{Name: op.Name})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !response.Done {
+				t.Error("expected operation to be done")
+			}
+			if !reflect.DeepEqual(response.Error, tt.opErr) {
+				t.Error("operation error: expected", tt.opErr, "received", response.Error)
+			}
+			if tt.out != nil && !reflect.DeepEqual(response.Response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response.Response)
+			}
+		})
+	}
+}
+
+func TestBackEnd_WriteZeroesVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *WriteZeroesVolumeRequest
+		out     *WriteZeroesVolumeMetadata
+		opErr   *operations.Error
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with valid SPDK response": {
+			in:      &WriteZeroesVolumeRequest{Volume: "Malloc0", OffsetBytes: 4096, LengthBytes: 8192},
+			out:     &WriteZeroesVolumeMetadata{Volume: "Malloc0", OffsetBytes: 4096, LengthBytes: 8192},
+			opErr:   nil,
+			spdk:    []string{testSuccessResponse, testOffloadDone},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"deallocate whole volume": {
+			in:      &WriteZeroesVolumeRequest{Volume: "Malloc0", Deallocate: true},
+			out:     &WriteZeroesVolumeMetadata{Volume: "Malloc0", Deallocate: true},
+			opErr:   nil,
+			spdk:    []string{testSuccessResponse, testOffloadRunning, testOffloadDone},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"status failure": {
+			in:  &WriteZeroesVolumeRequest{Volume: "Malloc0"},
+			out: nil,
+			opErr: &operations.Error{
+				Code:    codes.InvalidArgument,
+				Message: fmt.Sprintf("Could not get offload status of %v", "Malloc0"),
+			},
+			spdk:    []string{testSuccessResponse, testFailureResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with invalid SPDK response": {
+			in:      &WriteZeroesVolumeRequest{Volume: "Malloc0"},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not write zeroes to %v", "Malloc0"),
+		},
+		"valid request with empty SPDK response": {
+			in:      &WriteZeroesVolumeRequest{Volume: "Malloc0"},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_write_zeroes_start: %v", "EOF"),
+		},
+		"unaligned offset": {
+			in:      &WriteZeroesVolumeRequest{Volume: "Malloc0", OffsetBytes: 100},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "OffsetBytes value (100) have to be a multiple of 512",
+		},
+		"unaligned length": {
+			in:      &WriteZeroesVolumeRequest{Volume: "Malloc0", LengthBytes: 1000},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "LengthBytes value (1000) have to be a multiple of 512",
+		},
+		"no required field": {
+			in:      &WriteZeroesVolumeRequest{},
+			out:     nil,
+			opErr:   nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: volume",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			op, err := testEnv.opiSpdkServer.WriteZeroesVolume(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err != nil {
+				return
+			}
+
+			testEnv.opiSpdkServer.operations.Wait()
+			response, err := testEnv.opiSpdkServer.operations.GetOperation(testEnv.ctx, &operations.GetOperationRequest{Name: op.Name})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !response.Done {
+				t.Error("expected operation to be done")
+			}
+			if !reflect.DeepEqual(response.Error, tt.opErr) {
+				t.Error("operation error: expected", tt.opErr, "received", response.Error)
+			}
+			if tt.out != nil && !reflect.DeepEqual(response.Response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response.Response)
+			}
+		})
+	}
+}
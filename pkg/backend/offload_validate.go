@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// offloadAlignment is the sector size the offloaded ranges are aligned to
+const offloadAlignment = 512
+
+func (s *Server) validateCopyVolumeRequest(in *CopyVolumeRequest) error {
+	// check required fields
+	if in.Volume == "" {
+		return errors.New("missing required field: volume")
+	}
+	if in.DestinationVolume == "" {
+		return errors.New("missing required field: destination_volume")
+	}
+	if in.Volume == in.DestinationVolume {
+		msg := fmt.Sprintf("Volume %s can't be copied to itself", in.Volume)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateWriteZeroesVolumeRequest(in *WriteZeroesVolumeRequest) error {
+	// check required fields
+	if in.Volume == "" {
+		return errors.New("missing required field: volume")
+	}
+	// check the range is sector aligned
+	if in.OffsetBytes%offloadAlignment != 0 {
+		msg := fmt.Sprintf("OffsetBytes value (%d) have to be a multiple of %d", in.OffsetBytes, offloadAlignment)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.LengthBytes%offloadAlignment != 0 {
+		msg := fmt.Sprintf("LengthBytes value (%d) have to be a multiple of %d", in.LengthBytes, offloadAlignment)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
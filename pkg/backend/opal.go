@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Lock states of an Opal locking range, see TCG Storage Security Subsystem Class: Opal
+const (
+	opalLockStateReadWrite = "READWRITE" // unlocked
+	opalLockStateReadOnly  = "READONLY"  // write locked
+	opalLockStateRWLocked  = "RWLOCKED"  // read and write locked
+)
+
+// NvmeOpalLockingRange represents an Opal locking range of an NVMe device
+type NvmeOpalLockingRange struct {
+	// LockingRangeID is the locking range number, 0 is the global range
+	LockingRangeID int32 `json:"lockingRangeId"`
+	// RangeStart is the first LBA of the range
+	RangeStart uint64 `json:"rangeStart"`
+	// RangeLength is the number of LBAs of the range
+	RangeLength uint64 `json:"rangeLength"`
+	// ReadLocked is set when the range can't be read
+	ReadLocked bool `json:"readLocked"`
+	// WriteLocked is set when the range can't be written
+	WriteLocked bool `json:"writeLocked"`
+}
+
+// NvmeOpal represents the Opal state of a self-encrypting NVMe device
+type NvmeOpal struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// Supported is set when the device implements the Opal SSC
+	Supported bool `json:"supported"`
+	// Owned is set once the ownership of the device is taken
+	Owned bool `json:"owned"`
+	// LockingEnabled is set once the Locking SP of the device is activated
+	LockingEnabled bool `json:"lockingEnabled"`
+	// LockingRanges lists the configured locking ranges of the device
+	LockingRanges []*NvmeOpalLockingRange `json:"lockingRanges"`
+}
+
+// GetNvmeOpalRequest represents a request to get the Opal state of an NVMe device
+type GetNvmeOpalRequest struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+}
+
+// TakeNvmeOpalOwnershipRequest represents a request to take the ownership of an NVMe device
+type TakeNvmeOpalOwnershipRequest struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// Password set as the admin credential of the device, it is never stored nor logged
+	Password string `json:"password"`
+}
+
+// UpdateNvmeOpalLockingRangeRequest represents a request to configure an Opal locking range of an NVMe device
+type UpdateNvmeOpalLockingRangeRequest struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// Password is the admin credential of the device
+	Password string `json:"password"`
+	// LockingRangeID is the locking range number, between 1 and 8
+	LockingRangeID int32 `json:"lockingRangeId"`
+	// RangeStart is the first LBA of the range
+	RangeStart uint64 `json:"rangeStart"`
+	// RangeLength is the number of LBAs of the range
+	RangeLength uint64 `json:"rangeLength"`
+}
+
+// SetNvmeOpalLockStateRequest represents a request to lock or unlock an Opal locking range of an NVMe device
+type SetNvmeOpalLockStateRequest struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// Password is the admin credential of the device
+	Password string `json:"password"`
+	// LockingRangeID is the locking range number, 0 is the global range
+	LockingRangeID int32 `json:"lockingRangeId"`
+	// LockState is one of READWRITE (unlocked), READONLY or RWLOCKED
+	LockState string `json:"lockState"`
+}
+
+// GetNvmeOpal gets the Opal state of an NVMe device
+func (s *Server) GetNvmeOpal(ctx context.Context, in *GetNvmeOpalRequest) (*NvmeOpal, error) {
+	// check input correctness
+	if err := s.validateGetNvmeOpalRequest(in); err != nil {
+		return nil, err
+	}
+	return s.getNvmeOpal(ctx, in.Device)
+}
+
+// TakeNvmeOpalOwnership takes the ownership of a self-encrypting NVMe device and activates its Locking SP,
+// so the encryption is done by the media
+func (s *Server) TakeNvmeOpalOwnership(ctx context.Context, in *TakeNvmeOpalOwnershipRequest) (*NvmeOpal, error) {
+	// check input correctness
+	if err := s.validateTakeNvmeOpalOwnershipRequest(in); err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmOpalTakeOwnershipParams{
+		Device:   in.Device,
+		Password: in.Password,
+	}
+	var result models.MrvlNvmOpalTakeOwnershipResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_opal_take_ownership", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not take Opal ownership of %s", in.Device)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_opal_take_ownership")
+	}
+	return s.getNvmeOpal(ctx, in.Device)
+}
+
+// UpdateNvmeOpalLockingRange configures the LBA range covered by an Opal locking range of an NVMe device
+func (s *Server) UpdateNvmeOpalLockingRange(ctx context.Context, in *UpdateNvmeOpalLockingRangeRequest) (*NvmeOpal, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeOpalLockingRangeRequest(in); err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmOpalSetLockingRangeParams{
+		Device:         in.Device,
+		Password:       in.Password,
+		LockingRangeID: int(in.LockingRangeID),
+		RangeStart:     in.RangeStart,
+		RangeLength:    in.RangeLength,
+	}
+	var result models.MrvlNvmOpalSetLockingRangeResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_opal_set_locking_range", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not configure Opal locking range %d of %s", in.LockingRangeID, in.Device)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_opal_set_locking_range")
+	}
+	return s.getNvmeOpal(ctx, in.Device)
+}
+
+// SetNvmeOpalLockState locks or unlocks an Opal locking range of an NVMe device
+func (s *Server) SetNvmeOpalLockState(ctx context.Context, in *SetNvmeOpalLockStateRequest) (*NvmeOpal, error) {
+	// check input correctness
+	if err := s.validateSetNvmeOpalLockStateRequest(in); err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmOpalSetLockStateParams{
+		Device:         in.Device,
+		Password:       in.Password,
+		LockingRangeID: int(in.LockingRangeID),
+		LockState:      in.LockState,
+	}
+	var result models.MrvlNvmOpalSetLockStateResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_opal_set_lock_state", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not set lock state of Opal locking range %d of %s", in.LockingRangeID, in.Device)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_opal_set_lock_state")
+	}
+	return s.getNvmeOpal(ctx, in.Device)
+}
+
+func (s *Server) getNvmeOpal(ctx context.Context, device string) (*NvmeOpal, error) {
+	params := models.MrvlNvmOpalGetInfoParams{
+		Device: device,
+	}
+	var result models.MrvlNvmOpalGetInfoResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_opal_get_info", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get Opal info of %s", device)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_opal_get_info")
+	}
+	opal := &NvmeOpal{
+		Device:         device,
+		Supported:      result.Supported,
+		Owned:          result.Owned,
+		LockingEnabled: result.LockingEnabled,
+		LockingRanges:  make([]*NvmeOpalLockingRange, len(result.LockingRanges)),
+	}
+	for i := range result.LockingRanges {
+		r := &result.LockingRanges[i]
+		opal.LockingRanges[i] = &NvmeOpalLockingRange{
+			LockingRangeID: int32(r.LockingRangeID),
+			RangeStart:     r.RangeStart,
+			RangeLength:    r.RangeLength,
+			ReadLocked:     r.ReadLocked,
+			WriteLocked:    r.WriteLocked,
+		}
+	}
+	return opal, nil
+}
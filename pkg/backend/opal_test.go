@@ -0,0 +1,344 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	testOpalPassword = "admin-secret"
+	testOpalInfo     = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "supported": true, "owned": true, "locking_enabled": true, "locking_ranges": [{"locking_range_id": 1, "range_start": 0, "range_length": 2048, "read_locked": false, "write_locked": false}]}}`
+	testOpal         = NvmeOpal{
+		Device:         testDevice,
+		Supported:      true,
+		Owned:          true,
+		LockingEnabled: true,
+		LockingRanges: []*NvmeOpalLockingRange{
+			{LockingRangeID: 1, RangeStart: 0, RangeLength: 2048},
+		},
+	}
+)
+
+func TestBackEnd_GetNvmeOpal(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *NvmeOpal
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testDevice,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get Opal info of %v", testDevice),
+		},
+		"valid request with empty SPDK response": {
+			in:      testDevice,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_opal_get_info: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      testDevice,
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_opal_get_info: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      testDevice,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_opal_get_info: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      testDevice,
+			out:     &testOpal,
+			spdk:    []string{testOpalInfo},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: device",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &GetNvmeOpalRequest{Device: tt.in}
+			response, err := testEnv.opiSpdkServer.GetNvmeOpal(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_TakeNvmeOpalOwnership(t *testing.T) {
+	tests := map[string]struct {
+		in      *TakeNvmeOpalOwnershipRequest
+		out     *NvmeOpal
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &TakeNvmeOpalOwnershipRequest{Device: testDevice, Password: testOpalPassword},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not take Opal ownership of %v", testDevice),
+		},
+		"valid request with empty SPDK response": {
+			in:      &TakeNvmeOpalOwnershipRequest{Device: testDevice, Password: testOpalPassword},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_opal_take_ownership: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &TakeNvmeOpalOwnershipRequest{Device: testDevice, Password: testOpalPassword},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_opal_take_ownership: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      &TakeNvmeOpalOwnershipRequest{Device: testDevice, Password: testOpalPassword},
+			out:     &testOpal,
+			spdk:    []string{testSuccessResponse, testOpalInfo},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"password too long": {
+			in:      &TakeNvmeOpalOwnershipRequest{Device: testDevice, Password: strings.Repeat("a", 33)},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Password length (33) is too long, have to be between 1 and 32",
+		},
+		"no required password field": {
+			in:      &TakeNvmeOpalOwnershipRequest{Device: testDevice},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: password",
+		},
+		"no required device field": {
+			in:      &TakeNvmeOpalOwnershipRequest{Password: testOpalPassword},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: device",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			response, err := testEnv.opiSpdkServer.TakeNvmeOpalOwnership(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_UpdateNvmeOpalLockingRange(t *testing.T) {
+	tests := map[string]struct {
+		in      *UpdateNvmeOpalLockingRangeRequest
+		out     *NvmeOpal
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &UpdateNvmeOpalLockingRangeRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 1, RangeLength: 2048},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not configure Opal locking range 1 of %v", testDevice),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &UpdateNvmeOpalLockingRangeRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 1, RangeLength: 2048},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_opal_set_locking_range: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      &UpdateNvmeOpalLockingRangeRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 1, RangeLength: 2048},
+			out:     &testOpal,
+			spdk:    []string{testSuccessResponse, testOpalInfo},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"global locking range": {
+			in:      &UpdateNvmeOpalLockingRangeRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 0, RangeLength: 2048},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "LockingRangeID value (0) is out of range, have to be between 1 and 8",
+		},
+		"no required password field": {
+			in:      &UpdateNvmeOpalLockingRangeRequest{Device: testDevice, LockingRangeID: 1, RangeLength: 2048},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: password",
+		},
+		"no required device field": {
+			in:      &UpdateNvmeOpalLockingRangeRequest{Password: testOpalPassword, LockingRangeID: 1, RangeLength: 2048},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: device",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			response, err := testEnv.opiSpdkServer.UpdateNvmeOpalLockingRange(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_SetNvmeOpalLockState(t *testing.T) {
+	tests := map[string]struct {
+		in      *SetNvmeOpalLockStateRequest
+		out     *NvmeOpal
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &SetNvmeOpalLockStateRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 1, LockState: "READWRITE"},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not set lock state of Opal locking range 1 of %v", testDevice),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &SetNvmeOpalLockStateRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 1, LockState: "READWRITE"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_opal_set_lock_state: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      &SetNvmeOpalLockStateRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 1, LockState: "READWRITE"},
+			out:     &testOpal,
+			spdk:    []string{testSuccessResponse, testOpalInfo},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unsupported lock state": {
+			in:      &SetNvmeOpalLockStateRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 1, LockState: "WRITEONLY"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "LockState value (WRITEONLY) is not supported, have to be READWRITE, READONLY or RWLOCKED",
+		},
+		"locking range out of range": {
+			in:      &SetNvmeOpalLockStateRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 9, LockState: "RWLOCKED"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "LockingRangeID value (9) is out of range, have to be between 0 and 8",
+		},
+		"no required lock_state field": {
+			in:      &SetNvmeOpalLockStateRequest{Device: testDevice, Password: testOpalPassword, LockingRangeID: 1},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: lock_state",
+		},
+		"no required device field": {
+			in:      &SetNvmeOpalLockStateRequest{Password: testOpalPassword, LockingRangeID: 1, LockState: "READWRITE"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: device",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			response, err := testEnv.opiSpdkServer.SetNvmeOpalLockState(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// opalMaxLockingRangeID is the last locking range the Opal SSC requires devices to support
+const opalMaxLockingRangeID = 8
+
+// opalMaxPasswordLength is the size of the Opal credentials
+const opalMaxPasswordLength = 32
+
+func (s *Server) validateGetNvmeOpalRequest(in *GetNvmeOpalRequest) error {
+	// check required fields
+	if in.Device == "" {
+		return errors.New("missing required field: device")
+	}
+	return nil
+}
+
+func (s *Server) validateTakeNvmeOpalOwnershipRequest(in *TakeNvmeOpalOwnershipRequest) error {
+	// check required fields
+	if in.Device == "" {
+		return errors.New("missing required field: device")
+	}
+	return validateOpalPassword(in.Password)
+}
+
+func (s *Server) validateUpdateNvmeOpalLockingRangeRequest(in *UpdateNvmeOpalLockingRangeRequest) error {
+	// check required fields
+	if in.Device == "" {
+		return errors.New("missing required field: device")
+	}
+	if err := validateOpalPassword(in.Password); err != nil {
+		return err
+	}
+	// check LockingRangeID range, the global range always covers the whole device
+	if in.LockingRangeID < 1 || in.LockingRangeID > opalMaxLockingRangeID {
+		msg := fmt.Sprintf("LockingRangeID value (%d) is out of range, have to be between 1 and %d", in.LockingRangeID, opalMaxLockingRangeID)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateSetNvmeOpalLockStateRequest(in *SetNvmeOpalLockStateRequest) error {
+	// check required fields
+	if in.Device == "" {
+		return errors.New("missing required field: device")
+	}
+	if err := validateOpalPassword(in.Password); err != nil {
+		return err
+	}
+	if in.LockState == "" {
+		return errors.New("missing required field: lock_state")
+	}
+	// check LockingRangeID range
+	if in.LockingRangeID < 0 || in.LockingRangeID > opalMaxLockingRangeID {
+		msg := fmt.Sprintf("LockingRangeID value (%d) is out of range, have to be between 0 and %d", in.LockingRangeID, opalMaxLockingRangeID)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check LockState value
+	switch in.LockState {
+	case opalLockStateReadWrite, opalLockStateReadOnly, opalLockStateRWLocked:
+	default:
+		msg := fmt.Sprintf("LockState value (%s) is not supported, have to be READWRITE, READONLY or RWLOCKED", in.LockState)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func validateOpalPassword(password string) error {
+	if password == "" {
+		return errors.New("missing required field: password")
+	}
+	// check Password length
+	if len(password) > opalMaxPasswordLength {
+		msg := fmt.Sprintf("Password length (%d) is too long, have to be between 1 and %d", len(password), opalMaxPasswordLength)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// defaultRbdUserID is the Ceph user the DPU authenticates as when none is given
+const defaultRbdUserID = "admin"
+
+// RbdCluster represents a connection of the DPU to a Ceph cluster, RBD volumes are images of
+// its pools. The cephx key is only used to program the keyring of the firmware, the bridge
+// keeps its fingerprint and never returns it
+type RbdCluster struct {
+	// Name of the Ceph cluster
+	Name string `json:"name"`
+	// MonHosts are the addresses of the monitors, host or host:port
+	MonHosts []string `json:"monHosts"`
+	// UserID is the Ceph user the DPU authenticates as, admin when empty
+	UserID string `json:"userId"`
+	// Key is the cephx secret of the user, base64 encoded, input only
+	Key string `json:"key,omitempty"`
+	// KeyFingerprint is the SHA-256 of the cephx secret, output only
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
+}
+
+// CreateRbdClusterRequest represents a request to connect to a Ceph cluster
+type CreateRbdClusterRequest struct {
+	// RbdClusterID is the ID of the Ceph cluster, generated when empty
+	RbdClusterID string `json:"rbdClusterId"`
+	// RbdCluster to connect to
+	RbdCluster *RbdCluster `json:"rbdCluster"`
+}
+
+// DeleteRbdClusterRequest represents a request to disconnect from a Ceph cluster
+type DeleteRbdClusterRequest struct {
+	// Name of the Ceph cluster
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the Ceph cluster doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetRbdClusterRequest represents a request to get a Ceph cluster
+type GetRbdClusterRequest struct {
+	// Name of the Ceph cluster
+	Name string `json:"name"`
+}
+
+// ListRbdClustersRequest represents a request to list Ceph clusters
+type ListRbdClustersRequest struct {
+	// PageSize is the maximum number of Ceph clusters returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListRbdClustersResponse represents a list of Ceph clusters
+type ListRbdClustersResponse struct {
+	// RbdClusters is the page of Ceph clusters
+	RbdClusters []*RbdCluster `json:"rbdClusters"`
+	// NextPageToken is set when more Ceph clusters are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToRbdClusterName builds the name of a Ceph cluster, they have their own collection
+// as they are not part of the OPI APIs
+func resourceIDToRbdClusterName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"rbdClusters", resourceID,
+	)
+}
+
+// CreateRbdCluster loads the cephx key of a Ceph cluster in the keyring of the firmware and
+// connects the DPU to the monitors
+func (s *Server) CreateRbdCluster(ctx context.Context, in *CreateRbdClusterRequest) (*RbdCluster, error) {
+	// check input correctness
+	if err := s.validateCreateRbdClusterRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.RbdClusterID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.RbdClusterID, "name", in.RbdCluster.Name)
+		resourceID = in.RbdClusterID
+	}
+	name := resourceIDToRbdClusterName(resourceID)
+	// idempotent API when called with same key, should return same object
+	cluster, found, err := s.getRbdCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing RbdCluster", "name", name)
+		return cluster, nil
+	}
+	// not found, so create a new one
+	sum := sha256.Sum256([]byte(in.RbdCluster.Key))
+	cluster = &RbdCluster{
+		Name:           name,
+		MonHosts:       in.RbdCluster.MonHosts,
+		UserID:         in.RbdCluster.UserID,
+		KeyFingerprint: hex.EncodeToString(sum[:]),
+	}
+	if cluster.UserID == "" {
+		cluster.UserID = defaultRbdUserID
+	}
+	if err := s.addKeyringKey(ctx, rbdKeyName(resourceID), in.RbdCluster.Key); err != nil {
+		return nil, err
+	}
+	params := models.MrvlBdevRbdRegisterClusterParams{
+		Name:    resourceID,
+		UserID:  cluster.UserID,
+		MonHost: strings.Join(cluster.MonHosts, ","),
+		KeyName: rbdKeyName(resourceID),
+	}
+	var result models.MrvlBdevRbdRegisterClusterResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_rbd_register_cluster", &params, &result)
+	if err == nil {
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not register Ceph cluster: %s", resourceID)
+			err = result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_rbd_register_cluster")
+		}
+	}
+	if err != nil {
+		if err := s.removeKeyringKey(ctx, rbdKeyName(resourceID)); err != nil {
+			slog.Error("Could not roll back key", "name", name, "error", err)
+		}
+		return nil, err
+	}
+	if err := s.saveRbdCluster(cluster); err != nil {
+		return nil, err
+	}
+	s.ListHelper[name] = false
+	return cluster, nil
+}
+
+// DeleteRbdCluster disconnects the DPU from a Ceph cluster and removes its key from the
+// keyring, its RBD volumes have to be deleted first
+func (s *Server) DeleteRbdCluster(ctx context.Context, in *DeleteRbdClusterRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteRbdClusterRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	cluster, found, err := s.getRbdCluster(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	volumes, err := s.rbdVolumeNames(cluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes) != 0 {
+		msg := fmt.Sprintf("RbdCluster %v still has %d volumes, delete them first", cluster.Name, len(volumes))
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	resourceID := path.Base(cluster.Name)
+	params := models.MrvlBdevRbdUnregisterClusterParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevRbdUnregisterClusterResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_rbd_unregister_cluster", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not unregister Ceph cluster: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_rbd_unregister_cluster")
+	}
+	if err := s.removeKeyringKey(ctx, rbdKeyName(resourceID)); err != nil {
+		return nil, err
+	}
+	// remove from the Database
+	delete(s.ListHelper, cluster.Name)
+	err = s.store.Delete(cluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListRbdClusters lists Ceph clusters
+func (s *Server) ListRbdClusters(_ context.Context, in *ListRbdClustersRequest) (*ListRbdClustersResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.rbdClusterNames()
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*RbdCluster, 0, len(names))
+	for _, name := range names {
+		cluster, found, err := s.getRbdCluster(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, cluster)
+	}
+	return &ListRbdClustersResponse{RbdClusters: Blobarray, NextPageToken: token}, nil
+}
+
+// GetRbdCluster gets a Ceph cluster, without the key
+func (s *Server) GetRbdCluster(_ context.Context, in *GetRbdClusterRequest) (*RbdCluster, error) {
+	// check input correctness
+	if err := s.validateGetRbdClusterRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	cluster, found, err := s.getRbdCluster(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// rbdKeyName is the name of the keyring key holding the cephx secret of a Ceph cluster
+func rbdKeyName(resourceID string) string {
+	return "rbd_" + resourceID
+}
+
+// rbdClusterNames returns the sorted names of the Ceph clusters
+func (s *Server) rbdClusterNames() []string {
+	prefix := resourceIDToRbdClusterName("") + "/"
+	var names []string
+	for key := range s.ListHelper {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getRbdCluster fetches a Ceph cluster from the database,
+// Ceph clusters are not protobufs so they are stored JSON encoded
+func (s *Server) getRbdCluster(name string) (*RbdCluster, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	cluster := new(RbdCluster)
+	if err := json.Unmarshal(value.Value, cluster); err != nil {
+		return nil, false, err
+	}
+	return cluster, true, nil
+}
+
+func (s *Server) saveRbdCluster(cluster *RbdCluster) error {
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(cluster.Name, wrapperspb.Bytes(data))
+}
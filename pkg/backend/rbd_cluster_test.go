@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	testRbdClusterID   = "ceph0"
+	testRbdClusterName = resourceIDToRbdClusterName(testRbdClusterID)
+	testRbdClusterKey  = "AQBAMo1VqE1OMhAAVpERPcyQU5pzU6IOJ22x1w=="
+	testRbdCluster     = RbdCluster{
+		Name:           testRbdClusterName,
+		MonHosts:       []string{"10.0.0.1", "10.0.0.2:6789"},
+		UserID:         defaultRbdUserID,
+		KeyFingerprint: dhchapFingerprint(testRbdClusterKey),
+	}
+)
+
+func TestBackEnd_CreateRbdCluster(t *testing.T) {
+	tests := map[string]struct {
+		in      *RbdCluster
+		out     *RbdCluster
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"missing mon hosts": {
+			in:      &RbdCluster{Key: testRbdClusterKey},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: rbd_cluster.mon_hosts",
+		},
+		"missing key": {
+			in:      &RbdCluster{MonHosts: testRbdCluster.MonHosts},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: rbd_cluster.key",
+		},
+		"malformed mon host": {
+			in:      &RbdCluster{MonHosts: []string{"10.0.0.1,10.0.0.2"}, Key: testRbdClusterKey},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("MonHosts value (%s) is not a host or a host:port", "10.0.0.1,10.0.0.2"),
+		},
+		"malformed key": {
+			in:      &RbdCluster{MonHosts: testRbdCluster.MonHosts, Key: "not a cephx key"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Key value is not a cephx secret, have to be base64 encoded",
+		},
+		"keyring failure": {
+			in:      &RbdCluster{MonHosts: testRbdCluster.MonHosts, Key: testRbdClusterKey},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not add key %v to the keyring", rbdKeyName(testRbdClusterID)),
+		},
+		"valid request with invalid SPDK response": {
+			in:      &RbdCluster{MonHosts: testRbdCluster.MonHosts, Key: testRbdClusterKey},
+			out:     nil,
+			spdk:    []string{testSuccessResponse, testFailureResponse, testSuccessResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not register Ceph cluster: %v", testRbdClusterID),
+		},
+		"valid request with valid SPDK response": {
+			in:      &RbdCluster{MonHosts: testRbdCluster.MonHosts, Key: testRbdClusterKey},
+			out:     &testRbdCluster,
+			spdk:    []string{testSuccessResponse, testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      &RbdCluster{MonHosts: testRbdCluster.MonHosts, Key: testRbdClusterKey},
+			out:     &testRbdCluster,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveRbdCluster(&testRbdCluster)
+				testEnv.opiSpdkServer.ListHelper[testRbdClusterName] = false
+			}
+
+			request := &CreateRbdClusterRequest{RbdCluster: tt.in, RbdClusterID: testRbdClusterID}
+			response, err := testEnv.opiSpdkServer.CreateRbdCluster(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteRbdCluster(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+		volumes bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testRbdClusterName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not unregister Ceph cluster: %v", testRbdClusterID),
+		},
+		"keyring failure": {
+			in:      testRbdClusterName,
+			out:     nil,
+			spdk:    []string{testSuccessResponse, testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not remove key %v from the keyring", rbdKeyName(testRbdClusterID)),
+		},
+		"valid request with valid SPDK response": {
+			in:      testRbdClusterName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse, testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"cluster with volumes": {
+			in:      testRbdClusterName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("RbdCluster %v still has %d volumes, delete them first", testRbdClusterName, 1),
+			volumes: true,
+		},
+		"unknown key": {
+			in:      resourceIDToRbdClusterName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRbdClusterName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToRbdClusterName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveRbdCluster(&testRbdCluster)
+			testEnv.opiSpdkServer.ListHelper[testRbdClusterName] = false
+			if tt.volumes {
+				_ = testEnv.opiSpdkServer.saveRbdVolume(&testRbdVolume)
+				testEnv.opiSpdkServer.volumeTypes[testRbdVolumeName] = rbdVolumeType
+			}
+
+			request := &DeleteRbdClusterRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteRbdCluster(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListRbdClusters(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveRbdCluster(&testRbdCluster)
+	testEnv.opiSpdkServer.ListHelper[testRbdClusterName] = false
+	_ = testEnv.opiSpdkServer.saveLvolStore(&testLvolStore)
+	testEnv.opiSpdkServer.ListHelper[testLvolStoreName] = false
+
+	response, err := testEnv.opiSpdkServer.ListRbdClusters(testEnv.ctx, &ListRbdClustersRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	expected := &ListRbdClustersResponse{RbdClusters: []*RbdCluster{&testRbdCluster}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Error("response: expected", expected, "received", response)
+	}
+}
+
+func TestBackEnd_GetRbdCluster(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *RbdCluster
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testRbdClusterName,
+			out:     &testRbdCluster,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      resourceIDToRbdClusterName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRbdClusterName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveRbdCluster(&testRbdCluster)
+
+			request := &GetRbdClusterRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetRbdCluster(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateRbdClusterRequest(in *CreateRbdClusterRequest) error {
+	// check required fields
+	if in.RbdCluster == nil {
+		return errors.New("missing required field: rbd_cluster")
+	}
+	if len(in.RbdCluster.MonHosts) == 0 {
+		return errors.New("missing required field: rbd_cluster.mon_hosts")
+	}
+	if in.RbdCluster.Key == "" {
+		return errors.New("missing required field: rbd_cluster.key")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.RbdClusterID != "" {
+		if err := resourceid.ValidateUserSettable(in.RbdClusterID); err != nil {
+			return err
+		}
+	}
+	// check MonHosts, the firmware gets them comma separated
+	for _, host := range in.RbdCluster.MonHosts {
+		if host == "" || strings.ContainsAny(host, ", ") {
+			msg := fmt.Sprintf("MonHosts value (%s) is not a host or a host:port", host)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	// check the key, without logging it
+	if _, err := base64.StdEncoding.DecodeString(in.RbdCluster.Key); err != nil {
+		msg := "Key value is not a cephx secret, have to be base64 encoded"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteRbdClusterRequest(in *DeleteRbdClusterRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetRbdClusterRequest(in *GetRbdClusterRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
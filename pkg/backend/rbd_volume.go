@@ -0,0 +1,289 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// rbdVolumeType is the type of the volumes backed by an RBD image
+const rbdVolumeType = "rbd"
+
+// RbdVolume represents an RBD image of a Ceph cluster attached as a backend volume, which
+// frontend namespaces can use as volume_name_ref
+type RbdVolume struct {
+	// Name of the RBD volume
+	Name string `json:"name"`
+	// RbdCluster is the name of the Ceph cluster the image is in
+	RbdCluster string `json:"rbdCluster"`
+	// Pool is the Ceph pool of the image
+	Pool string `json:"pool"`
+	// Image is the name of the RBD image, it has to exist
+	Image string `json:"image"`
+	// BlockSize of the volume, 512 when 0
+	BlockSize int64 `json:"blockSize"`
+	// BlocksCount is the size of the image in blocks, output only
+	BlocksCount int64 `json:"blocksCount"`
+	// UUID of the volume, output only
+	UUID string `json:"uuid"`
+}
+
+// CreateRbdVolumeRequest represents a request to attach an RBD image
+type CreateRbdVolumeRequest struct {
+	// RbdVolumeID is the ID of the RBD volume, generated when empty
+	RbdVolumeID string `json:"rbdVolumeId"`
+	// RbdVolume to attach
+	RbdVolume *RbdVolume `json:"rbdVolume"`
+}
+
+// DeleteRbdVolumeRequest represents a request to detach an RBD image
+type DeleteRbdVolumeRequest struct {
+	// Name of the RBD volume
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the RBD volume doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetRbdVolumeRequest represents a request to get an RBD volume
+type GetRbdVolumeRequest struct {
+	// Name of the RBD volume
+	Name string `json:"name"`
+}
+
+// ListRbdVolumesRequest represents a request to list RBD volumes
+type ListRbdVolumesRequest struct {
+	// PageSize is the maximum number of RBD volumes returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListRbdVolumesResponse represents a list of RBD volumes
+type ListRbdVolumesResponse struct {
+	// RbdVolumes is the page of RBD volumes
+	RbdVolumes []*RbdVolume `json:"rbdVolumes"`
+	// NextPageToken is set when more RBD volumes are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToRbdVolumeName builds the name of an RBD volume, they share the volumes collection
+// with the other backend volumes
+func resourceIDToRbdVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"volumes", resourceID,
+	)
+}
+
+// CreateRbdVolume attaches an existing RBD image of a Ceph cluster as a backend volume
+func (s *Server) CreateRbdVolume(ctx context.Context, in *CreateRbdVolumeRequest) (*RbdVolume, error) {
+	// check input correctness
+	if err := s.validateCreateRbdVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.RbdVolumeID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.RbdVolumeID, "name", in.RbdVolume.Name)
+		resourceID = in.RbdVolumeID
+	}
+	name := resourceIDToRbdVolumeName(resourceID)
+	if err := s.checkVolumeType(name, rbdVolumeType); err != nil {
+		return nil, err
+	}
+	// idempotent API when called with same key, should return same object
+	volume, found, err := s.getRbdVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing RbdVolume", "name", name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	_, found, err = s.getRbdCluster(in.RbdVolume.RbdCluster)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.RbdVolume.RbdCluster)
+		return nil, err
+	}
+	params := models.MrvlBdevRbdCreateParams{
+		Name:        resourceID,
+		ClusterName: path.Base(in.RbdVolume.RbdCluster),
+		PoolName:    in.RbdVolume.Pool,
+		RbdName:     in.RbdVolume.Image,
+		BlockSize:   in.RbdVolume.BlockSize,
+	}
+	var result models.MrvlBdevRbdCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_rbd_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Rbd Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_rbd_create")
+	}
+	volume = &RbdVolume{
+		Name:        name,
+		RbdCluster:  in.RbdVolume.RbdCluster,
+		Pool:        in.RbdVolume.Pool,
+		Image:       in.RbdVolume.Image,
+		BlockSize:   result.BlockSize,
+		BlocksCount: result.NumBlocks,
+		UUID:        result.UUID,
+	}
+	if err := s.saveRbdVolume(volume); err != nil {
+		return nil, err
+	}
+	s.volumeTypes[name] = rbdVolumeType
+	return volume, nil
+}
+
+// DeleteRbdVolume detaches an RBD image, the image is left in the Ceph pool
+func (s *Server) DeleteRbdVolume(ctx context.Context, in *DeleteRbdVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteRbdVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, rbdVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getRbdVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevRbdDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevRbdDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_rbd_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Rbd Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_rbd_delete")
+	}
+	// remove from the Database
+	delete(s.volumeTypes, volume.Name)
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListRbdVolumes lists RBD volumes, of all the Ceph clusters
+func (s *Server) ListRbdVolumes(_ context.Context, in *ListRbdVolumesRequest) (*ListRbdVolumesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.volumeNames(rbdVolumeType)
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*RbdVolume, 0, len(names))
+	for _, name := range names {
+		volume, found, err := s.getRbdVolume(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, volume)
+	}
+	return &ListRbdVolumesResponse{RbdVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetRbdVolume gets an RBD volume
+func (s *Server) GetRbdVolume(_ context.Context, in *GetRbdVolumeRequest) (*RbdVolume, error) {
+	// check input correctness
+	if err := s.validateGetRbdVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, rbdVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getRbdVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// rbdVolumeNames returns the sorted names of the RBD volumes of a Ceph cluster
+func (s *Server) rbdVolumeNames(clusterName string) ([]string, error) {
+	var names []string
+	for _, name := range s.volumeNames(rbdVolumeType) {
+		volume, found, err := s.getRbdVolume(name)
+		if err != nil {
+			return nil, err
+		}
+		if found && volume.RbdCluster == clusterName {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// getRbdVolume fetches an RBD volume from the database,
+// RBD volumes are not protobufs so they are stored JSON encoded
+func (s *Server) getRbdVolume(name string) (*RbdVolume, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	volume := new(RbdVolume)
+	if err := json.Unmarshal(value.Value, volume); err != nil {
+		return nil, false, err
+	}
+	return volume, true, nil
+}
+
+func (s *Server) saveRbdVolume(volume *RbdVolume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(volume.Name, wrapperspb.Bytes(data))
+}
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	testRbdVolumeID   = "rbd0"
+	testRbdVolumeName = resourceIDToRbdVolumeName(testRbdVolumeID)
+	testRbdVolume     = RbdVolume{
+		Name:        testRbdVolumeName,
+		RbdCluster:  testRbdClusterName,
+		Pool:        "rbd",
+		Image:       "image0",
+		BlockSize:   512,
+		BlocksCount: 2097152,
+		UUID:        "6c1e2b5a-0d4f-4e93-a8b7-2f5c9d1e3a64",
+	}
+)
+
+func TestBackEnd_CreateRbdVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *RbdVolume
+		out     *RbdVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   string
+	}{
+		"missing cluster": {
+			in:      &RbdVolume{Pool: testRbdVolume.Pool, Image: testRbdVolume.Image},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: rbd_volume.rbd_cluster",
+		},
+		"missing image": {
+			in:      &RbdVolume{RbdCluster: testRbdClusterName, Pool: testRbdVolume.Pool},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: rbd_volume.image",
+		},
+		"unsupported block size": {
+			in:      &RbdVolume{RbdCluster: testRbdClusterName, Pool: testRbdVolume.Pool, Image: testRbdVolume.Image, BlockSize: 1024},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("BlockSize value (%d) is not supported, have to be 512 or 4096", 1024),
+		},
+		"unknown cluster": {
+			in:      &RbdVolume{RbdCluster: resourceIDToRbdClusterName("unknown-id"), Pool: testRbdVolume.Pool, Image: testRbdVolume.Image},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRbdClusterName("unknown-id")),
+		},
+		"valid request with invalid SPDK response": {
+			in:      &RbdVolume{RbdCluster: testRbdClusterName, Pool: testRbdVolume.Pool, Image: testRbdVolume.Image},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Rbd Volume: %v", testRbdVolumeID),
+		},
+		"valid request with valid SPDK response": {
+			in:      &RbdVolume{RbdCluster: testRbdClusterName, Pool: testRbdVolume.Pool, Image: testRbdVolume.Image},
+			out:     &testRbdVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "6c1e2b5a-0d4f-4e93-a8b7-2f5c9d1e3a64", "block_size": 512, "num_blocks": 2097152}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      &RbdVolume{RbdCluster: testRbdClusterName, Pool: testRbdVolume.Pool, Image: testRbdVolume.Image},
+			out:     &testRbdVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   rbdVolumeType,
+		},
+		"already exists as lvol": {
+			in:      &RbdVolume{RbdCluster: testRbdClusterName, Pool: testRbdVolume.Pool, Image: testRbdVolume.Image},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testRbdVolumeName, lvolVolumeType, rbdVolumeType),
+			exist:   lvolVolumeType,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveRbdCluster(&testRbdCluster)
+			testEnv.opiSpdkServer.ListHelper[testRbdClusterName] = false
+			if tt.exist != "" {
+				testEnv.opiSpdkServer.volumeTypes[testRbdVolumeName] = tt.exist
+			}
+			if tt.exist == rbdVolumeType {
+				_ = testEnv.opiSpdkServer.saveRbdVolume(&testRbdVolume)
+			}
+
+			request := &CreateRbdVolumeRequest{RbdVolume: tt.in, RbdVolumeID: testRbdVolumeID}
+			response, err := testEnv.opiSpdkServer.CreateRbdVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteRbdVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testRbdVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Rbd Volume: %v", testRbdVolumeID),
+		},
+		"valid request with valid SPDK response": {
+			in:      testRbdVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"aio volume": {
+			in:      testAioVolumeName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testAioVolumeName, aioVolumeType, rbdVolumeType),
+		},
+		"unknown key": {
+			in:      resourceIDToRbdVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRbdVolumeName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToRbdVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveRbdVolume(&testRbdVolume)
+			testEnv.opiSpdkServer.volumeTypes[testRbdVolumeName] = rbdVolumeType
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &DeleteRbdVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteRbdVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListRbdVolumes(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveRbdVolume(&testRbdVolume)
+	testEnv.opiSpdkServer.volumeTypes[testRbdVolumeName] = rbdVolumeType
+	testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+	response, err := testEnv.opiSpdkServer.ListRbdVolumes(testEnv.ctx, &ListRbdVolumesRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	expected := &ListRbdVolumesResponse{RbdVolumes: []*RbdVolume{&testRbdVolume}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Error("response: expected", expected, "received", response)
+	}
+}
+
+func TestBackEnd_GetRbdVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *RbdVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testRbdVolumeName,
+			out:     &testRbdVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      resourceIDToRbdVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRbdVolumeName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveRbdVolume(&testRbdVolume)
+			testEnv.opiSpdkServer.volumeTypes[testRbdVolumeName] = rbdVolumeType
+
+			request := &GetRbdVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetRbdVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
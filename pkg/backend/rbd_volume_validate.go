@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateRbdVolumeRequest(in *CreateRbdVolumeRequest) error {
+	// check required fields
+	if in.RbdVolume == nil {
+		return errors.New("missing required field: rbd_volume")
+	}
+	if in.RbdVolume.RbdCluster == "" {
+		return errors.New("missing required field: rbd_volume.rbd_cluster")
+	}
+	if in.RbdVolume.Pool == "" {
+		return errors.New("missing required field: rbd_volume.pool")
+	}
+	if in.RbdVolume.Image == "" {
+		return errors.New("missing required field: rbd_volume.image")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.RbdVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(in.RbdVolumeID); err != nil {
+			return err
+		}
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	if err := resourcename.Validate(in.RbdVolume.RbdCluster); err != nil {
+		return err
+	}
+	// check BlockSize, 0 uses 512 bytes blocks
+	if in.RbdVolume.BlockSize != 0 && in.RbdVolume.BlockSize != 512 && in.RbdVolume.BlockSize != 4096 {
+		msg := fmt.Sprintf("BlockSize value (%d) is not supported, have to be 512 or 4096", in.RbdVolume.BlockSize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteRbdVolumeRequest(in *DeleteRbdVolumeRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetRbdVolumeRequest(in *GetRbdVolumeRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
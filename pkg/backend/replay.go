@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// ReplayVolumes creates the Null, Malloc and Aio volumes and the Nvme paths of the store again in
+// the firmware, i.e. once the SPDK application restarted, so the namespaces find their volumes
+// back. The resources which can't be created are kept, their errors are returned, the paths of
+// the remote controllers with DH-CHAP keys are reported FAILED since their secrets aren't kept
+func (s *Server) ReplayVolumes(ctx context.Context) error {
+	// the volumes are created as the requests do, none runs meanwhile
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+	var errs []error
+	for _, name := range s.replayVolumeNames(nullVolumeType) {
+		forget, remember := s.volumeTracking(name, nullVolumeType)
+		errs = append(errs, replayResource(s, name, new(pb.NullVolume), forget, remember, func(volume *pb.NullVolume) error {
+			_, err := s.CreateNullVolume(ctx, &pb.CreateNullVolumeRequest{NullVolume: volume, NullVolumeId: path.Base(name)})
+			return err
+		}))
+	}
+	for _, name := range s.replayVolumeNames(mallocVolumeType) {
+		forget, remember := s.volumeTracking(name, mallocVolumeType)
+		errs = append(errs, replayResource(s, name, new(pb.MallocVolume), forget, remember, func(volume *pb.MallocVolume) error {
+			_, err := s.CreateMallocVolume(ctx, &pb.CreateMallocVolumeRequest{MallocVolume: volume, MallocVolumeId: path.Base(name)})
+			return err
+		}))
+	}
+	for _, name := range s.replayVolumeNames(aioVolumeType) {
+		forget, remember := s.volumeTracking(name, aioVolumeType)
+		errs = append(errs, replayResource(s, name, new(pb.AioVolume), forget, remember, func(volume *pb.AioVolume) error {
+			_, err := s.CreateAioVolume(ctx, &pb.CreateAioVolumeRequest{AioVolume: volume, AioVolumeId: path.Base(name)})
+			return err
+		}))
+	}
+	// the remote controllers only live in the store, their paths connect them
+	var paths []string
+	for name := range s.ListHelper {
+		if path.Base(path.Dir(name)) == "nvmePaths" {
+			paths = append(paths, name)
+		}
+	}
+	sort.Strings(paths)
+	// only the fingerprints of the DH-CHAP secrets are kept, the keyring of the firmware lost them
+	// so the paths of their controllers can't authenticate until the secrets are set again
+	lostDhchap := make(map[string]bool)
+	for name := range s.ListHelper {
+		_, found, err := s.getNvmeRemoteControllerDhchap(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if err := s.store.Delete(nvmeRemoteControllerDhchapKey(name)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Warn("NvmeRemoteController lost its DH-CHAP keys", "name", name)
+		lostDhchap[name] = true
+	}
+	for _, name := range paths {
+		if controllerName := path.Dir(path.Dir(name)); lostDhchap[controllerName] {
+			reason := fmt.Sprintf("the firmware lost the DH-CHAP keys of NvmeRemoteController %s, delete its paths, set its keys again and create its paths again", controllerName)
+			s.failNvmePath(name, reason)
+			errs = append(errs, fmt.Errorf("could not replay %s: %s", name, reason))
+			continue
+		}
+		forget := func() {
+			delete(s.ListHelper, name)
+			s.untrackNvmePath(name)
+		}
+		remember := func() {
+			s.ListHelper[name] = false
+			s.trackNvmePath(name)
+		}
+		errs = append(errs, replayResource(s, name, new(pb.NvmePath), forget, remember, func(nvmePath *pb.NvmePath) error {
+			_, err := s.CreateNvmePath(ctx, &pb.CreateNvmePathRequest{Parent: path.Dir(path.Dir(name)), NvmePath: nvmePath, NvmePathId: path.Base(name)})
+			return err
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// UnaryServerInterceptor holds the requests while the volumes are replayed
+func (s *Server) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		s.requestsMu.RLock()
+		defer s.requestsMu.RUnlock()
+		return handler(ctx, req)
+	}
+}
+
+// replayVolumeNames returns the sorted names of the volumes of a type
+func (s *Server) replayVolumeNames(volumeType string) []string {
+	var names []string
+	for name, existing := range s.volumeTypes {
+		if existing == volumeType {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// volumeTracking returns the functions removing and adding a volume in the tracked volume types
+func (s *Server) volumeTracking(name string, volumeType string) (func(), func()) {
+	forget := func() {
+		delete(s.volumeTypes, name)
+	}
+	remember := func() {
+		s.volumeTypes[name] = volumeType
+	}
+	return forget, remember
+}
+
+// replayResource creates a resource of the store again as a client would, under the same name,
+// the stored resource is kept when the creation fails. forget and remember remove and add the
+// resource in the names the bridge tracks
+func replayResource[T proto.Message](s *Server, name string, resource T, forget func(), remember func(), create func(resource T) error) error {
+	found, err := s.store.Get(name, resource)
+	if err != nil {
+		return err
+	}
+	if !found {
+		forget()
+		return nil
+	}
+	if err := s.store.Delete(name); err != nil {
+		return err
+	}
+	forget()
+	if err := create(utils.ProtoClone(resource)); err != nil {
+		remember()
+		if err := s.store.Set(name, resource); err != nil {
+			return err
+		}
+		return fmt.Errorf("could not replay %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBackEnd_ReplayVolumes(t *testing.T) {
+	tests := map[string]struct {
+		spdk   []string
+		errMsg string
+	}{
+		"replayed": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "5ac0bba0-b2f4-4e4d-9a3b-1b1c8e0c1f2d"}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`,
+			},
+			errMsg: "",
+		},
+		"path not replayed": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "5ac0bba0-b2f4-4e4d-9a3b-1b1c8e0c1f2d"}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+			},
+			errMsg: fmt.Sprintf("could not replay %s: rpc error: code = InvalidArgument desc = Could not attach NVMe Ctrl: %s", testNvmePathName, testNvmeRemoteControllerID),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testNullVolumeName, &testNullVolume)
+			testEnv.opiSpdkServer.volumeTypes[testNullVolumeName] = nullVolumeType
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+			testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+
+			err := testEnv.opiSpdkServer.ReplayVolumes(testEnv.ctx)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+
+			// the resources are kept, replayed or not
+			volume := new(pb.NullVolume)
+			if found, _ := testEnv.opiSpdkServer.store.Get(testNullVolumeName, volume); !found || !proto.Equal(volume, &testNullVolume) {
+				t.Error("volume: expected", &testNullVolume, "received", volume)
+			}
+			if testEnv.opiSpdkServer.volumeTypes[testNullVolumeName] != nullVolumeType {
+				t.Error("volume type: expected", nullVolumeType, "received", testEnv.opiSpdkServer.volumeTypes[testNullVolumeName])
+			}
+			nvmePath := new(pb.NvmePath)
+			if found, _ := testEnv.opiSpdkServer.store.Get(testNvmePathName, nvmePath); !found || !proto.Equal(nvmePath, &testNvmePath) {
+				t.Error("path: expected", &testNvmePath, "received", nvmePath)
+			}
+			if _, ok := testEnv.opiSpdkServer.ListHelper[testNvmePathName]; !ok {
+				t.Error("list helper: expected", testNvmePathName)
+			}
+		})
+	}
+}
+
+// run with -race, the requests run while the volumes are replayed in the background
+func TestBackEnd_ReplayVolumesWhileListing(t *testing.T) {
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`,
+	})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+	testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+	_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+	testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+
+	interceptor := testEnv.opiSpdkServer.UnaryServerInterceptor()
+	list := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return testEnv.opiSpdkServer.ListNvmeRemoteControllers(ctx, req.(*pb.ListNvmeRemoteControllersRequest))
+	}
+	listed := make(chan error)
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := interceptor(testEnv.ctx, &pb.ListNvmeRemoteControllersRequest{}, &grpc.UnaryServerInfo{}, list); err != nil {
+				listed <- err
+				return
+			}
+		}
+		listed <- nil
+	}()
+
+	if err := testEnv.opiSpdkServer.ReplayVolumes(testEnv.ctx); err != nil {
+		t.Error("unexpected error", err)
+	}
+	if err := <-listed; err != nil {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestBackEnd_ReplayVolumesDhchap(t *testing.T) {
+	// the path isn't attached again, the keyring lost its keys, and the firmware doesn't report it
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "io_paths": []}}`,
+	})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+	testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+	_ = testEnv.opiSpdkServer.saveNvmeRemoteControllerDhchap(testNvmeRemoteControllerName, &NvmeRemoteControllerDhchap{
+		KeyFingerprint: dhchapFingerprint("DHHC-1:00:ia6zGodOr4SEG0Zzaw398rpY0wqipUWj4jWjUh4HWUz6aQ2n:"),
+	})
+	_ = testEnv.opiSpdkServer.store.Set(testNvmePathName, &testNvmePath)
+	testEnv.opiSpdkServer.ListHelper[testNvmePathName] = false
+	testEnv.opiSpdkServer.trackNvmePath(testNvmePathName)
+	var failures []*NvmePathEvent
+	testEnv.opiSpdkServer.SetNvmePathFailureReporter(func(event *NvmePathEvent) {
+		failures = append(failures, event)
+	})
+
+	reason := fmt.Sprintf("the firmware lost the DH-CHAP keys of NvmeRemoteController %s, delete its paths, set its keys again and create its paths again", testNvmeRemoteControllerName)
+	err := testEnv.opiSpdkServer.ReplayVolumes(testEnv.ctx)
+	errMsg := fmt.Sprintf("could not replay %s: %s", testNvmePathName, reason)
+	if err == nil || err.Error() != errMsg {
+		t.Error("error: expected", errMsg, "received", err)
+	}
+
+	// the path is kept, failed until created again
+	nvmePath := new(pb.NvmePath)
+	if found, _ := testEnv.opiSpdkServer.store.Get(testNvmePathName, nvmePath); !found || !proto.Equal(nvmePath, &testNvmePath) {
+		t.Error("path: expected", &testNvmePath, "received", nvmePath)
+	}
+	pathStatus, err := testEnv.opiSpdkServer.GetNvmePathStatus(testEnv.ctx, &GetNvmePathStatusRequest{Name: testNvmePathName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pathStatus.State != nvmePathStateFailed || pathStatus.Reason != reason {
+		t.Error("status: expected", nvmePathStateFailed, reason, "received", pathStatus.State, pathStatus.Reason)
+	}
+	if len(failures) != 1 || failures[0].Reason != reason {
+		t.Error("failures: expected", reason, "received", failures)
+	}
+	testEnv.opiSpdkServer.checkNvmePaths(testEnv.ctx)
+	if pathStatus, _ := testEnv.opiSpdkServer.GetNvmePathStatus(testEnv.ctx, &GetNvmePathStatusRequest{Name: testNvmePathName}); pathStatus.State != nvmePathStateFailed {
+		t.Error("status after check: expected", nvmePathStateFailed, "received", pathStatus.State)
+	}
+
+	// the keys have to be set again, the path is deleted without the firmware
+	if _, err := testEnv.opiSpdkServer.GetNvmeRemoteControllerDhchap(testEnv.ctx, &GetNvmeRemoteControllerDhchapRequest{Name: testNvmeRemoteControllerName}); status.Code(err) != codes.NotFound {
+		t.Error("dhchap: expected", codes.NotFound, "received", err)
+	}
+	if _, err := testEnv.opiSpdkServer.DeleteNvmePath(testEnv.ctx, &pb.DeleteNvmePathRequest{Name: testNvmePathName}); err != nil {
+		t.Error("delete: expected no error, received", err)
+	}
+}
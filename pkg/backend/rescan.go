@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// maxVolumeResizeEvents is the number of volume size changes kept
+const maxVolumeResizeEvents = 100
+
+// VolumeResizeHandler propagates the new size of a volume to the namespaces it backs
+type VolumeResizeHandler func(ctx context.Context, volume string) error
+
+// RescanVolumesRequest represents a request to rescan the size of the backend volumes
+type RescanVolumesRequest struct {
+	// Volume is the name of the volume to rescan, as used in the namespaces, all the volumes when empty
+	Volume string `json:"volume"`
+}
+
+// RescanVolumesResponse represents the volumes whose size changed
+type RescanVolumesResponse struct {
+	Resized []*VolumeResizeEvent `json:"resized"`
+}
+
+// VolumeResizeEvent represents a change of the size of a backend volume, i.e. a LUN grown on the target
+type VolumeResizeEvent struct {
+	// Volume is the name of the volume, as used in the namespaces
+	Volume string `json:"volume"`
+	// OldSizeBytes is the size of the volume before the rescan
+	OldSizeBytes uint64 `json:"oldSizeBytes"`
+	// SizeBytes is the size of the volume found by the rescan
+	SizeBytes uint64 `json:"sizeBytes"`
+	// Notified is set when the hosts of the namespaces backed by the volume were notified
+	Notified bool `json:"notified"`
+	// Time is when the change was detected, in RFC 3339 format
+	Time string `json:"time"`
+}
+
+// ListVolumeResizeEventsRequest represents a request to list the volume size changes
+type ListVolumeResizeEventsRequest struct{}
+
+// ListVolumeResizeEventsResponse represents the last volume size changes
+type ListVolumeResizeEventsResponse struct {
+	Events []*VolumeResizeEvent `json:"events"`
+}
+
+// RescanVolumes makes the firmware read the size of the backend volumes again, the volumes
+// whose size changed are propagated to their namespaces, so the hosts see the new capacity
+// without detaching them
+func (s *Server) RescanVolumes(ctx context.Context, in *RescanVolumesRequest) (*RescanVolumesResponse, error) {
+	params := models.MrvlBdevRescanParams{
+		Name: in.Volume,
+	}
+	var result models.MrvlBdevRescanResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_rescan", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not rescan volumes"
+		if in.Volume != "" {
+			msg = fmt.Sprintf("Could not rescan volume %s", in.Volume)
+		}
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_rescan")
+	}
+	s.mu.Lock()
+	handler := s.volumeResizeHandler
+	s.mu.Unlock()
+	Blobarray := make([]*VolumeResizeEvent, len(result.Resized))
+	for i := range result.Resized {
+		r := &result.Resized[i]
+		slog.Info("Volume was resized", "name", r.Name, "old_size_bytes", r.OldSizeBytes, "size_bytes", r.SizeBytes)
+		event := &VolumeResizeEvent{
+			Volume:       r.Name,
+			OldSizeBytes: r.OldSizeBytes,
+			SizeBytes:    r.SizeBytes,
+			Time:         time.Now().UTC().Format(time.RFC3339),
+		}
+		// the firmware reports a resize once, a failed notification is only recorded
+		if handler != nil {
+			if err := handler(ctx, r.Name); err != nil {
+				slog.Warn("Could not notify resize", "name", r.Name, "error", err)
+			} else {
+				event.Notified = true
+			}
+		}
+		s.mu.Lock()
+		s.volumeResizeEvents = append(s.volumeResizeEvents, event)
+		if len(s.volumeResizeEvents) > maxVolumeResizeEvents {
+			s.volumeResizeEvents = s.volumeResizeEvents[len(s.volumeResizeEvents)-maxVolumeResizeEvents:]
+		}
+		s.mu.Unlock()
+		copied := *event
+		Blobarray[i] = &copied
+	}
+	return &RescanVolumesResponse{Resized: Blobarray}, nil
+}
+
+// ListVolumeResizeEvents lists the last size changes of the backend volumes
+func (s *Server) ListVolumeResizeEvents(_ context.Context, _ *ListVolumeResizeEventsRequest) (*ListVolumeResizeEventsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &ListVolumeResizeEventsResponse{Events: append([]*VolumeResizeEvent{}, s.volumeResizeEvents...)}, nil
+}
+
+// SetVolumeResizeHandler sets how the size changes of the volumes are propagated to the namespaces
+func (s *Server) SetVolumeResizeHandler(handler VolumeResizeHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.volumeResizeHandler = handler
+}
+
+// WatchVolumeResizes rescans all the volumes every interval until ctx is done
+func (s *Server) WatchVolumeResizes(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RescanVolumes(ctx, &RescanVolumesRequest{}); err != nil {
+			slog.Warn("Could not rescan volumes", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
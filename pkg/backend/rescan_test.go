@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBackEnd_RescanVolumes(t *testing.T) {
+	tests := map[string]struct {
+		in        *RescanVolumesRequest
+		spdk      []string
+		notifyErr error
+		resized   []string
+		notified  []bool
+		errCode   codes.Code
+		errMsg    string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &RescanVolumesRequest{},
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not rescan volumes",
+		},
+		"one volume with invalid SPDK response": {
+			in:      &RescanVolumesRequest{Volume: "iscsi0"},
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not rescan volume %s", "iscsi0"),
+		},
+		"no volume resized": {
+			in:       &RescanVolumesRequest{},
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "resized": []}}`},
+			resized:  nil,
+			notified: nil,
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"volumes resized": {
+			in:       &RescanVolumesRequest{},
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "resized": [{"name": "iscsi0", "old_size_bytes": 1073741824, "size_bytes": 2147483648}, {"name": "rbd0", "old_size_bytes": 1073741824, "size_bytes": 4294967296}]}}`},
+			resized:  []string{"iscsi0", "rbd0"},
+			notified: []bool{true, true},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"notification failure": {
+			in:        &RescanVolumesRequest{Volume: "iscsi0"},
+			spdk:      []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "resized": [{"name": "iscsi0", "old_size_bytes": 1073741824, "size_bytes": 2147483648}]}}`},
+			notifyErr: errors.New("notify failed"),
+			resized:   []string{"iscsi0"},
+			notified:  []bool{false},
+			errCode:   codes.OK,
+			errMsg:    "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			var volumes []string
+			testEnv.opiSpdkServer.SetVolumeResizeHandler(func(_ context.Context, volume string) error {
+				volumes = append(volumes, volume)
+				return tt.notifyErr
+			})
+
+			response, err := testEnv.opiSpdkServer.RescanVolumes(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(volumes, tt.resized) {
+				t.Error("notified volumes: expected", tt.resized, "received", volumes)
+			}
+			var notified []bool
+			for _, event := range response.Resized {
+				notified = append(notified, event.Notified)
+			}
+			if !reflect.DeepEqual(notified, tt.notified) {
+				t.Error("notified: expected", tt.notified, "received", notified)
+			}
+			events, _ := testEnv.opiSpdkServer.ListVolumeResizeEvents(testEnv.ctx, &ListVolumeResizeEventsRequest{})
+			if len(events.Events) != len(tt.resized) {
+				t.Error("events: expected", len(tt.resized), "received", len(events.Events))
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// maxScrubMediaErrors is the number of media errors kept per volume
+const maxScrubMediaErrors = 100
+
+// States of a volume scrub
+const (
+	scrubStateIdle    = "IDLE"
+	scrubStateRunning = "RUNNING"
+)
+
+// States of a volume verify reported by the firmware
+const (
+	verifyStateRunning = "running"
+	verifyStateDone    = "done"
+)
+
+// MediaError represents unreadable blocks found by a scrub
+type MediaError struct {
+	// Lba is the first unreadable block
+	Lba uint64 `json:"lba"`
+	// Blocks is the number of unreadable blocks
+	Blocks uint32 `json:"blocks"`
+	// Time is when the blocks were found, in RFC 3339 format
+	Time string `json:"time"`
+}
+
+// VolumeScrub represents the scrub schedule and status of a volume
+type VolumeScrub struct {
+	// Volume is the name of the volume, as used in the namespaces
+	Volume string `json:"volume"`
+	// IntervalHours is the time between two scrubs, the volume is only scrubbed on demand when 0
+	IntervalHours int32 `json:"intervalHours"`
+	// State is IDLE or RUNNING
+	State string `json:"state"`
+	// ProgressPercent is the completion of the running scrub
+	ProgressPercent int32 `json:"progressPercent,omitempty"`
+	// LastStartTime is when the last scrub started, in RFC 3339 format
+	LastStartTime string `json:"lastStartTime,omitempty"`
+	// LastEndTime is when the last scrub completed, in RFC 3339 format
+	LastEndTime string `json:"lastEndTime,omitempty"`
+	// MediaErrors are the last unreadable blocks found on the volume, the oldest first
+	MediaErrors []*MediaError `json:"mediaErrors"`
+}
+
+// UpdateVolumeScrubRequest represents a request to schedule the scrub of a volume
+type UpdateVolumeScrubRequest struct {
+	// Volume is the name of the volume, as used in the namespaces
+	Volume string `json:"volume"`
+	// IntervalHours is the time between two scrubs, 0 stops the periodic scrubs
+	IntervalHours int32 `json:"intervalHours"`
+}
+
+// StartVolumeScrubRequest represents a request to scrub a volume now
+type StartVolumeScrubRequest struct {
+	// Volume is the name of the volume, as used in the namespaces
+	Volume string `json:"volume"`
+}
+
+// GetVolumeScrubRequest represents a request to get the scrub status of a volume
+type GetVolumeScrubRequest struct {
+	// Volume is the name of the volume, as used in the namespaces
+	Volume string `json:"volume"`
+}
+
+// DeleteVolumeScrubRequest represents a request to forget the scrub schedule and status of a volume
+type DeleteVolumeScrubRequest struct {
+	// Volume is the name of the volume, as used in the namespaces
+	Volume string `json:"volume"`
+	// AllowMissing makes the deletion succeed when the volume is not scrubbed
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// ListVolumeScrubsRequest represents a request to list the scrub status of the volumes
+type ListVolumeScrubsRequest struct{}
+
+// ListVolumeScrubsResponse represents the scrub status of the volumes
+type ListVolumeScrubsResponse struct {
+	Scrubs []*VolumeScrub `json:"scrubs"`
+}
+
+// copyVolumeScrub copies the status of a scrub so it can be returned while the scrub goes on
+func copyVolumeScrub(scrub *VolumeScrub) *VolumeScrub {
+	response := *scrub
+	response.MediaErrors = append([]*MediaError{}, scrub.MediaErrors...)
+	return &response
+}
+
+// UpdateVolumeScrub schedules the periodic scrub of a volume, the first one starts
+// at the next check, i.e. for long-lived archival volumes which are rarely read
+func (s *Server) UpdateVolumeScrub(_ context.Context, in *UpdateVolumeScrubRequest) (*VolumeScrub, error) {
+	// check input correctness
+	if err := s.validateUpdateVolumeScrubRequest(in); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scrub, ok := s.scrubs[in.Volume]
+	if !ok {
+		scrub = &VolumeScrub{
+			Volume: in.Volume,
+			State:  scrubStateIdle,
+		}
+		s.scrubs[in.Volume] = scrub
+	}
+	scrub.IntervalHours = in.IntervalHours
+	return copyVolumeScrub(scrub), nil
+}
+
+// StartVolumeScrub starts the scrub of a volume now, the firmware reads and verifies
+// all the blocks of the volume in the background
+func (s *Server) StartVolumeScrub(ctx context.Context, in *StartVolumeScrubRequest) (*VolumeScrub, error) {
+	// check input correctness
+	if err := s.validateStartVolumeScrubRequest(in); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	scrub, ok := s.scrubs[in.Volume]
+	running := ok && scrub.State == scrubStateRunning
+	s.mu.Unlock()
+	if running {
+		msg := fmt.Sprintf("Scrub of %s is already running", in.Volume)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return s.startVolumeScrub(ctx, in.Volume)
+}
+
+// GetVolumeScrub gets the scrub status of a volume and the media errors found so far
+func (s *Server) GetVolumeScrub(_ context.Context, in *GetVolumeScrubRequest) (*VolumeScrub, error) {
+	// check input correctness
+	if err := s.validateGetVolumeScrubRequest(in); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scrub, ok := s.scrubs[in.Volume]
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Volume)
+		return nil, err
+	}
+	return copyVolumeScrub(scrub), nil
+}
+
+// DeleteVolumeScrub stops the periodic scrub of a volume and forgets its media errors,
+// a running scrub is completed by the firmware
+func (s *Server) DeleteVolumeScrub(_ context.Context, in *DeleteVolumeScrubRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteVolumeScrubRequest(in); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.scrubs[in.Volume]; !ok {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Volume)
+		return nil, err
+	}
+	delete(s.scrubs, in.Volume)
+	return &emptypb.Empty{}, nil
+}
+
+// ListVolumeScrubs lists the scrub status of the volumes
+func (s *Server) ListVolumeScrubs(_ context.Context, _ *ListVolumeScrubsRequest) (*ListVolumeScrubsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	Blobarray := make([]*VolumeScrub, 0, len(s.scrubs))
+	for _, scrub := range s.scrubs {
+		Blobarray = append(Blobarray, copyVolumeScrub(scrub))
+	}
+	sort.Slice(Blobarray, func(i int, j int) bool {
+		return Blobarray[i].Volume < Blobarray[j].Volume
+	})
+	return &ListVolumeScrubsResponse{Scrubs: Blobarray}, nil
+}
+
+// WatchVolumeScrubs follows the running scrubs and starts the scheduled ones every interval until ctx is done
+func (s *Server) WatchVolumeScrubs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.checkVolumeScrubs(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkVolumeScrubs records the progress and the media errors of the running scrubs
+// and starts the scrubs which are due
+func (s *Server) checkVolumeScrubs(ctx context.Context) {
+	var running, due []string
+	s.mu.Lock()
+	for volume, scrub := range s.scrubs {
+		switch {
+		case scrub.State == scrubStateRunning:
+			running = append(running, volume)
+		case scrub.IntervalHours > 0 && isVolumeScrubDue(scrub):
+			due = append(due, volume)
+		}
+	}
+	s.mu.Unlock()
+	sort.Strings(running)
+	sort.Strings(due)
+	for _, volume := range running {
+		if err := s.updateVolumeScrub(ctx, volume); err != nil {
+			slog.Warn("Could not get scrub status", "volume", volume, "error", err)
+		}
+	}
+	for _, volume := range due {
+		if _, err := s.startVolumeScrub(ctx, volume); err != nil {
+			slog.Warn("Could not start scrub", "volume", volume, "error", err)
+		}
+	}
+}
+
+// isVolumeScrubDue checks if the interval has elapsed since the last scrub started
+func isVolumeScrubDue(scrub *VolumeScrub) bool {
+	if scrub.LastStartTime == "" {
+		return true
+	}
+	last, err := time.Parse(time.RFC3339, scrub.LastStartTime)
+	if err != nil {
+		return true
+	}
+	return time.Since(last) >= time.Duration(scrub.IntervalHours)*time.Hour
+}
+
+// startVolumeScrub makes the firmware verify all the blocks of a volume
+func (s *Server) startVolumeScrub(ctx context.Context, volume string) (*VolumeScrub, error) {
+	params := models.MrvlBdevVerifyStartParams{
+		Name: volume,
+	}
+	var result models.MrvlBdevVerifyStartResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_verify_start", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not start scrub of %s", volume)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_verify_start")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scrub, ok := s.scrubs[volume]
+	if !ok {
+		scrub = &VolumeScrub{Volume: volume}
+		s.scrubs[volume] = scrub
+	}
+	scrub.State = scrubStateRunning
+	scrub.ProgressPercent = 0
+	scrub.LastStartTime = time.Now().UTC().Format(time.RFC3339)
+	return copyVolumeScrub(scrub), nil
+}
+
+// updateVolumeScrub polls the firmware for the progress of a scrub and records the media errors found
+func (s *Server) updateVolumeScrub(ctx context.Context, volume string) error {
+	params := models.MrvlBdevVerifyGetStatusParams{
+		Name: volume,
+	}
+	var result models.MrvlBdevVerifyGetStatusResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_verify_get_status", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get scrub status of %s", volume)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_verify_get_status")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scrub, ok := s.scrubs[volume]
+	if !ok {
+		// deleted meanwhile
+		return nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, e := range result.MediaErrors {
+		slog.Warn("Scrub found unreadable blocks", "volume", volume, "blocks", e.Blocks, "lba", e.Lba)
+		scrub.MediaErrors = append(scrub.MediaErrors, &MediaError{Lba: e.Lba, Blocks: e.Blocks, Time: now})
+	}
+	if len(scrub.MediaErrors) > maxScrubMediaErrors {
+		scrub.MediaErrors = scrub.MediaErrors[len(scrub.MediaErrors)-maxScrubMediaErrors:]
+	}
+	switch result.State {
+	case verifyStateRunning:
+		scrub.ProgressPercent = int32(result.Progress)
+	case verifyStateDone:
+		slog.Info("Scrub is done", "volume", volume)
+		scrub.State = scrubStateIdle
+		scrub.ProgressPercent = 0
+		scrub.LastEndTime = now
+	default:
+		// the scrub is retried at the next interval
+		slog.Warn("Scrub failed", "volume", volume, "state", result.State)
+		scrub.State = scrubStateIdle
+		scrub.ProgressPercent = 0
+	}
+	return nil
+}
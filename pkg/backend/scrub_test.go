@@ -0,0 +1,360 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// clearVolumeScrubTimes drops the times of a scrub status, they depend on when the test runs
+func clearVolumeScrubTimes(scrub *VolumeScrub) {
+	if scrub == nil {
+		return
+	}
+	scrub.LastStartTime = ""
+	scrub.LastEndTime = ""
+	for _, e := range scrub.MediaErrors {
+		e.Time = ""
+	}
+}
+
+func TestBackEnd_StartVolumeScrub(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *VolumeScrub
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		running bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not start scrub of %v", "Malloc0"),
+		},
+		"valid request with empty SPDK response": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_verify_start: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_verify_start: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_verify_start: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      "Malloc0",
+			out:     &VolumeScrub{Volume: "Malloc0", State: "RUNNING", MediaErrors: []*MediaError{}},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already running": {
+			in:      "Malloc0",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Scrub of %v is already running", "Malloc0"),
+			running: true,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: volume",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.running {
+				testEnv.opiSpdkServer.scrubs["Malloc0"] = &VolumeScrub{Volume: "Malloc0", State: "RUNNING"}
+			}
+
+			request := &StartVolumeScrubRequest{Volume: tt.in}
+			response, err := testEnv.opiSpdkServer.StartVolumeScrub(testEnv.ctx, request)
+
+			if response != nil && response.LastStartTime == "" {
+				t.Error("expected start time to be set")
+			}
+			clearVolumeScrubTimes(response)
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_UpdateVolumeScrub(t *testing.T) {
+	tests := map[string]struct {
+		in      *UpdateVolumeScrubRequest
+		out     *VolumeScrub
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      &UpdateVolumeScrubRequest{Volume: "Malloc0", IntervalHours: 168},
+			out:     &VolumeScrub{Volume: "Malloc0", IntervalHours: 168, State: "IDLE", MediaErrors: []*MediaError{}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"interval out of range": {
+			in:      &UpdateVolumeScrubRequest{Volume: "Malloc0", IntervalHours: 8761},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "IntervalHours value (8761) is out of range, have to be between 0 and 8760",
+		},
+		"negative interval": {
+			in:      &UpdateVolumeScrubRequest{Volume: "Malloc0", IntervalHours: -1},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "IntervalHours value (-1) is out of range, have to be between 0 and 8760",
+		},
+		"no required field": {
+			in:      &UpdateVolumeScrubRequest{IntervalHours: 168},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: volume",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			response, err := testEnv.opiSpdkServer.UpdateVolumeScrub(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_GetVolumeScrub(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *VolumeScrub
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      "Malloc0",
+			out:     &VolumeScrub{Volume: "Malloc0", IntervalHours: 24, State: "IDLE", MediaErrors: []*MediaError{}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      "Malloc1",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "Malloc1"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: volume",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.scrubs["Malloc0"] = &VolumeScrub{Volume: "Malloc0", IntervalHours: 24, State: "IDLE"}
+
+			response, err := testEnv.opiSpdkServer.GetVolumeScrub(testEnv.ctx, &GetVolumeScrubRequest{Volume: tt.in})
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteVolumeScrub(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      "Malloc0",
+			out:     &emptypb.Empty{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      "Malloc1",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "Malloc1"),
+		},
+		"unknown key with missing allowed": {
+			in:      "Malloc1",
+			out:     &emptypb.Empty{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: volume",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.scrubs["Malloc0"] = &VolumeScrub{Volume: "Malloc0", IntervalHours: 24, State: "IDLE"}
+
+			request := &DeleteVolumeScrubRequest{Volume: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteVolumeScrub(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_CheckVolumeScrubs(t *testing.T) {
+	recently := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	longAgo := time.Now().UTC().Add(-48 * time.Hour).Format(time.RFC3339)
+	tests := map[string]struct {
+		scrub *VolumeScrub
+		out   *VolumeScrub
+		spdk  []string
+	}{
+		"running scrub progressing": {
+			scrub: &VolumeScrub{Volume: "Malloc0", State: "RUNNING"},
+			out:   &VolumeScrub{Volume: "Malloc0", State: "RUNNING", ProgressPercent: 40, MediaErrors: []*MediaError{}},
+			spdk:  []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "running", "progress": 40}}`},
+		},
+		"running scrub done with media errors": {
+			scrub: &VolumeScrub{Volume: "Malloc0", State: "RUNNING", ProgressPercent: 40},
+			out: &VolumeScrub{Volume: "Malloc0", State: "IDLE", MediaErrors: []*MediaError{
+				{Lba: 1024, Blocks: 8},
+				{Lba: 4096, Blocks: 1},
+			}},
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "done", "progress": 100, "media_errors": [{"lba": 1024, "blocks": 8},{"lba": 4096, "blocks": 1}]}}`},
+		},
+		"running scrub failed": {
+			scrub: &VolumeScrub{Volume: "Malloc0", State: "RUNNING", ProgressPercent: 40},
+			out:   &VolumeScrub{Volume: "Malloc0", State: "IDLE", MediaErrors: []*MediaError{}},
+			spdk:  []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "failed", "progress": 40}}`},
+		},
+		"running scrub with invalid SPDK response": {
+			scrub: &VolumeScrub{Volume: "Malloc0", State: "RUNNING", ProgressPercent: 40},
+			out:   &VolumeScrub{Volume: "Malloc0", State: "RUNNING", ProgressPercent: 40, MediaErrors: []*MediaError{}},
+			spdk:  []string{testFailureResponse},
+		},
+		"scheduled scrub due": {
+			scrub: &VolumeScrub{Volume: "Malloc0", IntervalHours: 24, State: "IDLE", LastStartTime: longAgo},
+			out:   &VolumeScrub{Volume: "Malloc0", IntervalHours: 24, State: "RUNNING", MediaErrors: []*MediaError{}},
+			spdk:  []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+		},
+		"scheduled scrub not due": {
+			scrub: &VolumeScrub{Volume: "Malloc0", IntervalHours: 24, State: "IDLE", LastStartTime: recently},
+			out:   &VolumeScrub{Volume: "Malloc0", IntervalHours: 24, State: "IDLE", MediaErrors: []*MediaError{}},
+			spdk:  []string{},
+		},
+		"scrub on demand only": {
+			scrub: &VolumeScrub{Volume: "Malloc0", State: "IDLE"},
+			out:   &VolumeScrub{Volume: "Malloc0", State: "IDLE", MediaErrors: []*MediaError{}},
+			spdk:  []string{},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.scrubs["Malloc0"] = tt.scrub
+			testEnv.opiSpdkServer.checkVolumeScrubs(testEnv.ctx)
+
+			response, _ := testEnv.opiSpdkServer.ListVolumeScrubs(testEnv.ctx, &ListVolumeScrubsRequest{})
+			if len(response.Scrubs) != 1 {
+				t.Fatal("scrubs: expected", 1, "received", len(response.Scrubs))
+			}
+			scrub := response.Scrubs[0]
+			clearVolumeScrubTimes(scrub)
+			if !reflect.DeepEqual(scrub, tt.out) {
+				t.Error("scrub: expected", tt.out, "received", scrub)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxScrubIntervalHours is a year, archival volumes are scrubbed at least once a year
+const maxScrubIntervalHours = 365 * 24
+
+func (s *Server) validateUpdateVolumeScrubRequest(in *UpdateVolumeScrubRequest) error {
+	// check required fields
+	if in.Volume == "" {
+		return errors.New("missing required field: volume")
+	}
+	// check IntervalHours
+	if in.IntervalHours < 0 || in.IntervalHours > maxScrubIntervalHours {
+		msg := fmt.Sprintf("IntervalHours value (%d) is out of range, have to be between 0 and %d", in.IntervalHours, maxScrubIntervalHours)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateStartVolumeScrubRequest(in *StartVolumeScrubRequest) error {
+	// check required fields
+	if in.Volume == "" {
+		return errors.New("missing required field: volume")
+	}
+	return nil
+}
+
+func (s *Server) validateGetVolumeScrubRequest(in *GetVolumeScrubRequest) error {
+	// check required fields
+	if in.Volume == "" {
+		return errors.New("missing required field: volume")
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteVolumeScrubRequest(in *DeleteVolumeScrubRequest) error {
+	// check required fields
+	if in.Volume == "" {
+		return errors.New("missing required field: volume")
+	}
+	return nil
+}
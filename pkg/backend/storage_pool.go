@@ -0,0 +1,389 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Placement policies of the storage pools
+const (
+	placementLeastUsed  = "LEAST_USED"
+	placementRoundRobin = "ROUND_ROBIN"
+	placementLocality   = "LOCALITY"
+)
+
+// StoragePoolMember represents a volume or an lvol store of a storage pool
+type StoragePoolMember struct {
+	// Volume is an existing volume, as used in the namespaces, which namespaces are placed on
+	Volume string `json:"volume,omitempty"`
+	// LvolStore is the name of an lvol store, lvols of LvolSizeBytes are carved from it for the namespaces
+	LvolStore string `json:"lvolStore,omitempty"`
+	// Locality is a free form label of where the member is, i.e. local or rack1
+	Locality string `json:"locality,omitempty"`
+}
+
+// StoragePool represents a group of backend volumes and lvol stores, namespaces referencing
+// the pool as volume_name_ref are placed on one of its members by the bridge
+type StoragePool struct {
+	// Name of the storage pool
+	Name string `json:"name"`
+	// Policy is LEAST_USED, ROUND_ROBIN or LOCALITY, LEAST_USED when empty
+	Policy string `json:"policy"`
+	// Members are the volumes and lvol stores namespaces are placed on
+	Members []*StoragePoolMember `json:"members"`
+	// LvolSizeBytes is the size of the thin provisioned lvols carved from the lvol stores
+	LvolSizeBytes int64 `json:"lvolSizeBytes,omitempty"`
+	// PreferredLocality is the locality the LOCALITY policy places namespaces on first
+	PreferredLocality string `json:"preferredLocality,omitempty"`
+	// NextMember is the member the ROUND_ROBIN policy places the next namespace on, output only
+	NextMember int32 `json:"nextMember"`
+}
+
+// CreateStoragePoolRequest represents a request to create a storage pool
+type CreateStoragePoolRequest struct {
+	// StoragePoolID is the ID of the storage pool, generated when empty
+	StoragePoolID string `json:"storagePoolId"`
+	// StoragePool to create
+	StoragePool *StoragePool `json:"storagePool"`
+}
+
+// DeleteStoragePoolRequest represents a request to delete a storage pool
+type DeleteStoragePoolRequest struct {
+	// Name of the storage pool
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the storage pool doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetStoragePoolRequest represents a request to get a storage pool
+type GetStoragePoolRequest struct {
+	// Name of the storage pool
+	Name string `json:"name"`
+}
+
+// ListStoragePoolsRequest represents a request to list storage pools
+type ListStoragePoolsRequest struct {
+	// PageSize is the maximum number of storage pools returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListStoragePoolsResponse represents a list of storage pools
+type ListStoragePoolsResponse struct {
+	// StoragePools is the page of storage pools
+	StoragePools []*StoragePool `json:"storagePools"`
+	// NextPageToken is set when more storage pools are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToStoragePoolName builds the name of a storage pool, they have their own collection
+// as they are not part of the OPI APIs
+func resourceIDToStoragePoolName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"storagePools", resourceID,
+	)
+}
+
+// CreateStoragePool creates a storage pool, its lvol stores have to exist
+func (s *Server) CreateStoragePool(_ context.Context, in *CreateStoragePoolRequest) (*StoragePool, error) {
+	// check input correctness
+	if err := s.validateCreateStoragePoolRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.StoragePoolID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.StoragePoolID, "name", in.StoragePool.Name)
+		resourceID = in.StoragePoolID
+	}
+	name := resourceIDToStoragePoolName(resourceID)
+	// idempotent API when called with same key, should return same object
+	pool, found, err := s.getStoragePool(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing StoragePool", "name", name)
+		return pool, nil
+	}
+	// not found, so create a new one
+	for _, member := range in.StoragePool.Members {
+		if member.LvolStore == "" {
+			continue
+		}
+		_, found, err := s.getLvolStore(member.LvolStore)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", member.LvolStore)
+			return nil, err
+		}
+	}
+	pool = &StoragePool{
+		Name:              name,
+		Policy:            in.StoragePool.Policy,
+		Members:           in.StoragePool.Members,
+		LvolSizeBytes:     in.StoragePool.LvolSizeBytes,
+		PreferredLocality: in.StoragePool.PreferredLocality,
+	}
+	if pool.Policy == "" {
+		pool.Policy = placementLeastUsed
+	}
+	if err := s.saveStoragePool(pool); err != nil {
+		return nil, err
+	}
+	s.ListHelper[name] = false
+	return pool, nil
+}
+
+// DeleteStoragePool deletes a storage pool, the namespaces placed on its members are left untouched
+func (s *Server) DeleteStoragePool(_ context.Context, in *DeleteStoragePoolRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteStoragePoolRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	pool, found, err := s.getStoragePool(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	// remove from the Database
+	delete(s.ListHelper, pool.Name)
+	err = s.store.Delete(pool.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListStoragePools lists storage pools
+func (s *Server) ListStoragePools(_ context.Context, in *ListStoragePoolsRequest) (*ListStoragePoolsResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.storagePoolNames()
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*StoragePool, 0, len(names))
+	for _, name := range names {
+		pool, found, err := s.getStoragePool(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, pool)
+	}
+	return &ListStoragePoolsResponse{StoragePools: Blobarray, NextPageToken: token}, nil
+}
+
+// GetStoragePool gets a storage pool
+func (s *Server) GetStoragePool(_ context.Context, in *GetStoragePoolRequest) (*StoragePool, error) {
+	// check input correctness
+	if err := s.validateGetStoragePoolRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	pool, found, err := s.getStoragePool(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return pool, nil
+}
+
+// PlaceVolume chooses the volume a namespace referencing a storage pool is placed on, following
+// the policy of the pool, an lvol is carved when the chosen member is an lvol store
+func (s *Server) PlaceVolume(ctx context.Context, poolName string) (string, error) {
+	// fetch object from the database
+	pool, found, err := s.getStoragePool(poolName)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", poolName)
+		return "", err
+	}
+	var member *StoragePoolMember
+	switch pool.Policy {
+	case placementRoundRobin:
+		member = pool.Members[int(pool.NextMember)%len(pool.Members)]
+		pool.NextMember = int32((int(pool.NextMember) + 1) % len(pool.Members))
+		if err := s.saveStoragePool(pool); err != nil {
+			return "", err
+		}
+	case placementLocality:
+		var preferred []*StoragePoolMember
+		for _, m := range pool.Members {
+			if m.Locality == pool.PreferredLocality {
+				preferred = append(preferred, m)
+			}
+		}
+		// the other members are only used once the preferred ones are full
+		member, err = s.leastUsedStoragePoolMember(ctx, preferred)
+		if err == nil && member == nil {
+			member, err = s.leastUsedStoragePoolMember(ctx, pool.Members)
+		}
+	default:
+		member, err = s.leastUsedStoragePoolMember(ctx, pool.Members)
+	}
+	if err != nil {
+		return "", err
+	}
+	if member == nil {
+		msg := fmt.Sprintf("StoragePool %s has no member with free capacity", pool.Name)
+		return "", status.Errorf(codes.ResourceExhausted, msg)
+	}
+	if member.Volume != "" {
+		slog.Info("Placing namespace on volume", "pool", pool.Name, "volume", member.Volume)
+		return member.Volume, nil
+	}
+	lvol, err := s.CreateLvol(ctx, &CreateLvolRequest{
+		Lvol: &Lvol{
+			LvolStore:       member.LvolStore,
+			SizeBytes:       pool.LvolSizeBytes,
+			ThinProvisioned: true,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	slog.Info("Placing namespace on lvol", "pool", pool.Name, "lvol", lvol.Name)
+	return path.Base(lvol.Name), nil
+}
+
+// ReleaseVolume undoes the placement of a namespace whose creation failed, the lvol carved
+// for it is deleted
+func (s *Server) ReleaseVolume(ctx context.Context, poolName string, volume string) error {
+	name := resourceIDToLvolName(volume)
+	if s.volumeTypes[name] != lvolVolumeType {
+		return nil
+	}
+	lvol, found, err := s.getLvol(name)
+	if err != nil || !found {
+		return err
+	}
+	pool, found, err := s.getStoragePool(poolName)
+	if err != nil || !found {
+		return err
+	}
+	for _, member := range pool.Members {
+		if member.LvolStore == lvol.LvolStore {
+			_, err := s.DeleteLvol(ctx, &DeleteLvolRequest{Name: lvol.Name})
+			return err
+		}
+	}
+	return nil
+}
+
+// leastUsedStoragePoolMember returns the member with the lowest allocated capacity in percent,
+// the first one on a tie, or nil when all of them are full
+func (s *Server) leastUsedStoragePoolMember(ctx context.Context, members []*StoragePoolMember) (*StoragePoolMember, error) {
+	var pools map[string]float64
+	var chosen *StoragePoolMember
+	lowest := float64(100)
+	for _, member := range members {
+		var used float64
+		if member.Volume != "" {
+			allocation, err := s.StatsVolumeAllocation(ctx, &StatsVolumeAllocationRequest{Volume: member.Volume})
+			if err != nil {
+				return nil, err
+			}
+			if allocation.AdvertisedBytes != 0 {
+				used = float64(allocation.AllocatedBytes) * 100 / float64(allocation.AdvertisedBytes)
+			}
+		} else {
+			// the lvol stores are reported as pools, listed once for all of them
+			if pools == nil {
+				allocations, err := s.ListPoolAllocations(ctx, &ListPoolAllocationsRequest{})
+				if err != nil {
+					return nil, err
+				}
+				pools = make(map[string]float64)
+				for _, p := range allocations.Pools {
+					pools[p.Pool] = p.UsedPercent
+				}
+			}
+			used = pools[path.Base(member.LvolStore)]
+		}
+		if used < lowest {
+			chosen, lowest = member, used
+		}
+	}
+	return chosen, nil
+}
+
+// storagePoolNames returns the sorted names of the storage pools
+func (s *Server) storagePoolNames() []string {
+	prefix := resourceIDToStoragePoolName("") + "/"
+	var names []string
+	for key := range s.ListHelper {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getStoragePool fetches a storage pool from the database,
+// storage pools are not protobufs so they are stored JSON encoded
+func (s *Server) getStoragePool(name string) (*StoragePool, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	pool := new(StoragePool)
+	if err := json.Unmarshal(value.Value, pool); err != nil {
+		return nil, false, err
+	}
+	return pool, true, nil
+}
+
+func (s *Server) saveStoragePool(pool *StoragePool) error {
+	data, err := json.Marshal(pool)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(pool.Name, wrapperspb.Bytes(data))
+}
@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"path"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+
+	"go.einride.tech/aip/resourceid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultRebalanceSkewPercent is the usage gap between the lvol stores of a storage pool,
+// in percent, above which migrations are proposed
+const defaultRebalanceSkewPercent = 20
+
+// NamespaceMigrator moves the namespaces of the frontend between volumes, the storage pools
+// are rebalanced by moving namespaces off their most used lvol stores
+type NamespaceMigrator interface {
+	// NvmeNamespaceVolumes returns the volume backing each namespace, by namespace name
+	NvmeNamespaceVolumes(ctx context.Context) (map[string]string, error)
+	// MigrateNvmeNamespaceVolume moves a namespace to another volume, returning once it is backed by it
+	MigrateNvmeNamespaceVolume(ctx context.Context, namespace string, volume string) error
+}
+
+// StoragePoolMemberUsage represents the usage of an lvol store of a storage pool
+type StoragePoolMemberUsage struct {
+	// LvolStore is the name of the lvol store
+	LvolStore string `json:"lvolStore"`
+	// CapacityBytes is the usable capacity of the lvol store
+	CapacityBytes uint64 `json:"capacityBytes"`
+	// AllocatedBytes is the capacity used by the lvols of the lvol store
+	AllocatedBytes uint64 `json:"allocatedBytes"`
+	// UsedPercent is AllocatedBytes in percent of CapacityBytes
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+// StoragePoolMigration represents the move of a namespace to another lvol store of its storage pool
+type StoragePoolMigration struct {
+	// Namespace is the name of the Nvme namespace moved
+	Namespace string `json:"namespace"`
+	// Volume is the lvol the namespace is moved from, it is deleted once the namespace moved
+	Volume string `json:"volume"`
+	// AllocatedBytes is the capacity of the lvol store used by the lvol
+	AllocatedBytes uint64 `json:"allocatedBytes"`
+	// From is the lvol store the namespace is moved from
+	From string `json:"from"`
+	// To is the lvol store the namespace is moved to, an lvol is carved from it
+	To string `json:"to"`
+	// TargetVolume is the lvol carved for the namespace, set once it moved, output only
+	TargetVolume string `json:"targetVolume,omitempty"`
+}
+
+// StoragePoolRebalanceReport represents the usage of the lvol stores of a storage pool and the
+// migrations proposed to even it out
+type StoragePoolRebalanceReport struct {
+	// ID of the report, rebalancing the pool has to approve it
+	ID string `json:"id"`
+	// Pool is the name of the storage pool
+	Pool string `json:"pool"`
+	// SkewPercent is the usage gap between the most and the least used lvol stores
+	SkewPercent float64 `json:"skewPercent"`
+	// Members is the usage of the lvol stores of the pool
+	Members []*StoragePoolMemberUsage `json:"members"`
+	// Migrations are the proposed moves of namespaces, none when the pool is balanced
+	Migrations []*StoragePoolMigration `json:"migrations"`
+	// Time is when the report was made, in RFC 3339 format
+	Time string `json:"time"`
+}
+
+// GetStoragePoolRebalanceReportRequest represents a request to get the rebalance report of a storage pool
+type GetStoragePoolRebalanceReportRequest struct {
+	// Name of the storage pool
+	Name string `json:"name"`
+}
+
+// RebalanceStoragePoolRequest represents a request to execute the migrations proposed for a storage pool
+type RebalanceStoragePoolRequest struct {
+	// Name of the storage pool
+	Name string `json:"name"`
+	// ReportID is the ID of the last report of the pool, approving its migrations
+	ReportID string `json:"reportId"`
+}
+
+// RebalanceStoragePoolMetadata describes a rebalance operation
+type RebalanceStoragePoolMetadata struct {
+	// Name of the storage pool
+	Name string `json:"name"`
+	// ReportID is the ID of the report whose migrations are executed
+	ReportID string `json:"reportId"`
+}
+
+// SetNamespaceMigrator sets how the namespaces are moved when the storage pools are rebalanced
+func (s *Server) SetNamespaceMigrator(migrator NamespaceMigrator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.namespaceMigrator = migrator
+}
+
+// SetRebalanceSkew sets the usage gap between the lvol stores of a storage pool, in percent,
+// above which migrations are proposed
+func (s *Server) SetRebalanceSkew(percent int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rebalanceSkewPercent = float64(percent)
+}
+
+// GetStoragePoolRebalanceReport reports the usage of the lvol stores of a storage pool and, when
+// it is skewed, proposes to move namespaces from the most used lvol stores to the least used
+// ones. Only the namespaces on lvols carved for them are moved, the volume members are shared
+// by their namespaces. The migrations are only executed once the report is approved
+func (s *Server) GetStoragePoolRebalanceReport(ctx context.Context, in *GetStoragePoolRebalanceReportRequest) (*StoragePoolRebalanceReport, error) {
+	// check input correctness
+	if err := s.validateGetStoragePoolRebalanceReportRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	pool, found, err := s.getStoragePool(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	s.mu.Lock()
+	migrator := s.namespaceMigrator
+	skew := s.rebalanceSkewPercent
+	s.mu.Unlock()
+	if migrator == nil {
+		msg := fmt.Sprintf("Rebalancing is not enabled, can't report on StoragePool %s", in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	report, err := s.reportStoragePoolBalance(ctx, pool, migrator, skew)
+	if err != nil {
+		return nil, err
+	}
+	// the last report is the one which can be approved
+	s.mu.Lock()
+	s.rebalanceReports[pool.Name] = report
+	s.mu.Unlock()
+	return report, nil
+}
+
+// RebalanceStoragePool executes the migrations of the last report of a storage pool, returning
+// a long-running operation. The namespaces are moved one at a time, an lvol is carved for each of
+// them on its new lvol store and the lvol it is moved from is deleted once it moved
+func (s *Server) RebalanceStoragePool(_ context.Context, in *RebalanceStoragePoolRequest) (*operations.Operation, error) {
+	// check input correctness
+	if err := s.validateRebalanceStoragePoolRequest(in); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	migrator := s.namespaceMigrator
+	report := s.rebalanceReports[in.Name]
+	if report != nil && report.ID == in.ReportID {
+		// a report is only approved once
+		delete(s.rebalanceReports, in.Name)
+	}
+	s.mu.Unlock()
+	if migrator == nil {
+		msg := fmt.Sprintf("Rebalancing is not enabled, can't rebalance StoragePool %s", in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	if report == nil || report.ID != in.ReportID {
+		msg := fmt.Sprintf("Report %s is not the last report of StoragePool %s, get a new one", in.ReportID, in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	metadata := &RebalanceStoragePoolMetadata{
+		Name:     in.Name,
+		ReportID: in.ReportID,
+	}
+	return s.operations.Start(metadata, func(ctx context.Context) (interface{}, error) {
+		for i, migration := range report.Migrations {
+			if err := s.executeStoragePoolMigration(ctx, migrator, migration); err != nil {
+				return nil, err
+			}
+			operations.ReportProgress(ctx, int32((i+1)*100/len(report.Migrations)))
+		}
+		return report, nil
+	}), nil
+}
+
+// WatchStoragePoolBalance periodically reports on the storage pools, logging the skewed ones,
+// their migrations are left to be approved
+func (s *Server) WatchStoragePoolBalance(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, name := range s.storagePoolNames() {
+			report, err := s.GetStoragePoolRebalanceReport(ctx, &GetStoragePoolRebalanceReportRequest{Name: name})
+			if err != nil {
+				slog.Warn("Could not report on StoragePool", "name", name, "error", err)
+				continue
+			}
+			if len(report.Migrations) != 0 {
+				slog.Info("StoragePool is skewed", "name", name, "skew_percent", report.SkewPercent, "migrations", len(report.Migrations), "report", report.ID)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// executeStoragePoolMigration moves a namespace to an lvol carved for it on another lvol store
+func (s *Server) executeStoragePoolMigration(ctx context.Context, migrator NamespaceMigrator, migration *StoragePoolMigration) error {
+	source, found, err := s.getLvol(resourceIDToLvolName(migration.Volume))
+	if err != nil {
+		return err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", resourceIDToLvolName(migration.Volume))
+		return err
+	}
+	target, err := s.CreateLvol(ctx, &CreateLvolRequest{
+		Lvol: &Lvol{
+			LvolStore:       migration.To,
+			SizeBytes:       source.SizeBytes,
+			ThinProvisioned: source.ThinProvisioned,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := migrator.MigrateNvmeNamespaceVolume(ctx, migration.Namespace, path.Base(target.Name)); err != nil {
+		if _, err := s.DeleteLvol(ctx, &DeleteLvolRequest{Name: target.Name}); err != nil {
+			slog.Error("Could not roll back lvol", "name", target.Name, "error", err)
+		}
+		return err
+	}
+	slog.Info("NS moved", "name", migration.Namespace, "from", migration.From, "to", migration.To)
+	migration.TargetVolume = path.Base(target.Name)
+	_, err = s.DeleteLvol(ctx, &DeleteLvolRequest{Name: source.Name})
+	return err
+}
+
+// reportStoragePoolBalance computes the usage of the lvol stores of a storage pool and greedily
+// proposes moving namespaces from the most used lvol store to the least used one, as long as
+// the gap is above skew and each move narrows it
+func (s *Server) reportStoragePoolBalance(ctx context.Context, pool *StoragePool, migrator NamespaceMigrator, skew float64) (*StoragePoolRebalanceReport, error) {
+	report := &StoragePoolRebalanceReport{
+		ID:         resourceid.NewSystemGenerated(),
+		Pool:       pool.Name,
+		Members:    []*StoragePoolMemberUsage{},
+		Migrations: []*StoragePoolMigration{},
+		Time:       time.Now().UTC().Format(time.RFC3339),
+	}
+	var lvolStores []string
+	for _, member := range pool.Members {
+		if member.LvolStore != "" {
+			lvolStores = append(lvolStores, member.LvolStore)
+		}
+	}
+	if len(lvolStores) == 0 {
+		return report, nil
+	}
+	allocations, err := s.ListPoolAllocations(ctx, &ListPoolAllocationsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	capacities := make(map[string]*PoolAllocation)
+	for _, p := range allocations.Pools {
+		capacities[p.Pool] = p
+	}
+	for _, lvsName := range lvolStores {
+		usage := &StoragePoolMemberUsage{LvolStore: lvsName}
+		if p, ok := capacities[path.Base(lvsName)]; ok {
+			usage.CapacityBytes = p.CapacityBytes
+			usage.AllocatedBytes = p.AllocatedBytes
+			usage.UsedPercent = p.UsedPercent
+		}
+		report.Members = append(report.Members, usage)
+	}
+	report.SkewPercent = storagePoolSkew(report.Members)
+	if report.SkewPercent <= skew {
+		return report, nil
+	}
+	// the lvols backing a namespace can be moved, by lvol store
+	volumes, err := migrator.NvmeNamespaceVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string]string)
+	for namespace, volume := range volumes {
+		namespaces[volume] = namespace
+	}
+	candidates := make(map[string][]*StoragePoolMigration)
+	for _, lvsName := range lvolStores {
+		names, err := s.lvolNames(lvsName)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			namespace, ok := namespaces[path.Base(name)]
+			if !ok {
+				continue
+			}
+			allocation, err := s.StatsVolumeAllocation(ctx, &StatsVolumeAllocationRequest{Volume: path.Base(name)})
+			if err != nil {
+				return nil, err
+			}
+			candidates[lvsName] = append(candidates[lvsName], &StoragePoolMigration{
+				Namespace:      namespace,
+				Volume:         path.Base(name),
+				AllocatedBytes: allocation.AllocatedBytes,
+				From:           lvsName,
+			})
+		}
+	}
+	// simulate the moves on a copy of the usage
+	members := make([]*StoragePoolMemberUsage, 0, len(report.Members))
+	for _, usage := range report.Members {
+		if usage.CapacityBytes != 0 {
+			copied := *usage
+			members = append(members, &copied)
+		}
+	}
+	for len(members) > 1 {
+		most, least := members[0], members[0]
+		for _, usage := range members {
+			if usage.UsedPercent > most.UsedPercent {
+				most = usage
+			}
+			if usage.UsedPercent < least.UsedPercent {
+				least = usage
+			}
+		}
+		gap := most.UsedPercent - least.UsedPercent
+		if gap <= skew {
+			break
+		}
+		// the move leaving the narrowest gap between both lvol stores
+		best := -1
+		for i, candidate := range candidates[most.LvolStore] {
+			if least.AllocatedBytes+candidate.AllocatedBytes > least.CapacityBytes {
+				continue
+			}
+			after := math.Abs(usedPercent(most.AllocatedBytes-candidate.AllocatedBytes, most.CapacityBytes) -
+				usedPercent(least.AllocatedBytes+candidate.AllocatedBytes, least.CapacityBytes))
+			if after < gap {
+				best, gap = i, after
+			}
+		}
+		if best < 0 {
+			break
+		}
+		migration := candidates[most.LvolStore][best]
+		candidates[most.LvolStore] = append(candidates[most.LvolStore][:best], candidates[most.LvolStore][best+1:]...)
+		migration.To = least.LvolStore
+		most.AllocatedBytes -= migration.AllocatedBytes
+		most.UsedPercent = usedPercent(most.AllocatedBytes, most.CapacityBytes)
+		least.AllocatedBytes += migration.AllocatedBytes
+		least.UsedPercent = usedPercent(least.AllocatedBytes, least.CapacityBytes)
+		report.Migrations = append(report.Migrations, migration)
+	}
+	return report, nil
+}
+
+// storagePoolSkew is the usage gap between the most and the least used lvol stores, the ones
+// whose capacity is unknown are left out
+func storagePoolSkew(members []*StoragePoolMemberUsage) float64 {
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, usage := range members {
+		if usage.CapacityBytes == 0 {
+			continue
+		}
+		lowest = math.Min(lowest, usage.UsedPercent)
+		highest = math.Max(highest, usage.UsedPercent)
+	}
+	if highest < lowest {
+		return 0
+	}
+	return highest - lowest
+}
+
+// usedPercent is allocated in percent of capacity
+func usedPercent(allocated uint64, capacity uint64) float64 {
+	return float64(allocated) * 100 / float64(capacity)
+}
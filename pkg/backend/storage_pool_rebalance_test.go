@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	testRebalancePool = StoragePool{
+		Name:   testStoragePoolName,
+		Policy: placementLeastUsed,
+		Members: []*StoragePoolMember{
+			{LvolStore: testLvolStoreName},
+			{LvolStore: resourceIDToLvolStoreName("lvs1")},
+		},
+		LvolSizeBytes: 1073741824,
+	}
+	testRebalancePoolList   = `{"id":%%d,"error":{"code":0,"message":""},"result":{"status": 0, "pool_list": [{"name": "lvs0", "capacity_bytes": 1000, "allocated_bytes": %d},{"name": "lvs1", "capacity_bytes": 1000, "allocated_bytes": %d}]}}`
+	testRebalanceAllocation = `{"id":%%d,"error":{"code":0,"message":""},"result":{"status": 0, "pool": "lvs0", "thin_provision": true, "size_bytes": 1073741824, "allocated_bytes": %d}}`
+)
+
+// testNamespaceMigrator moves the namespaces of a map and records their migrations
+type testNamespaceMigrator struct {
+	volumes  map[string]string
+	err      error
+	migrated []string
+}
+
+func (m *testNamespaceMigrator) NvmeNamespaceVolumes(_ context.Context) (map[string]string, error) {
+	return m.volumes, nil
+}
+
+func (m *testNamespaceMigrator) MigrateNvmeNamespaceVolume(_ context.Context, namespace string, volume string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.volumes[namespace] = volume
+	m.migrated = append(m.migrated, namespace)
+	return nil
+}
+
+// setupRebalanceEnvironment creates a pool of two lvol stores, lvolA and lvolB back a namespace
+// and are carved from lvs0, lvolC is carved from lvs0 for no namespace
+func setupRebalanceEnvironment(testEnv *testEnv) *testNamespaceMigrator {
+	lvs1 := testLvolStore
+	lvs1.Name = resourceIDToLvolStoreName("lvs1")
+	for _, lvs := range []*LvolStore{&testLvolStore, &lvs1} {
+		_ = testEnv.opiSpdkServer.saveLvolStore(lvs)
+		testEnv.opiSpdkServer.ListHelper[lvs.Name] = false
+	}
+	for _, id := range []string{"lvolA", "lvolB", "lvolC"} {
+		lvol := testLvol
+		lvol.Name = resourceIDToLvolName(id)
+		_ = testEnv.opiSpdkServer.saveLvol(&lvol)
+		testEnv.opiSpdkServer.volumeTypes[lvol.Name] = lvolVolumeType
+	}
+	_ = testEnv.opiSpdkServer.saveStoragePool(&testRebalancePool)
+	testEnv.opiSpdkServer.ListHelper[testStoragePoolName] = false
+	return &testNamespaceMigrator{volumes: map[string]string{"ns0": "lvolA", "ns1": "lvolB", "ns2": "Malloc0"}}
+}
+
+func TestBackEnd_GetStoragePoolRebalanceReport(t *testing.T) {
+	tests := map[string]struct {
+		in         string
+		spdk       []string
+		members    []*StoragePoolMemberUsage
+		migrations []*StoragePoolMigration
+		errCode    codes.Code
+		errMsg     string
+		disabled   bool
+	}{
+		"balanced pool": {
+			in:   testStoragePoolName,
+			spdk: []string{fmt.Sprintf(testRebalancePoolList, 500, 400)},
+			members: []*StoragePoolMemberUsage{
+				{LvolStore: testLvolStoreName, CapacityBytes: 1000, AllocatedBytes: 500, UsedPercent: 50},
+				{LvolStore: resourceIDToLvolStoreName("lvs1"), CapacityBytes: 1000, AllocatedBytes: 400, UsedPercent: 40},
+			},
+			migrations: []*StoragePoolMigration{},
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"skewed pool": {
+			in:   testStoragePoolName,
+			spdk: []string{fmt.Sprintf(testRebalancePoolList, 800, 200), fmt.Sprintf(testRebalanceAllocation, 100), fmt.Sprintf(testRebalanceAllocation, 300)},
+			members: []*StoragePoolMemberUsage{
+				{LvolStore: testLvolStoreName, CapacityBytes: 1000, AllocatedBytes: 800, UsedPercent: 80},
+				{LvolStore: resourceIDToLvolStoreName("lvs1"), CapacityBytes: 1000, AllocatedBytes: 200, UsedPercent: 20},
+			},
+			migrations: []*StoragePoolMigration{
+				{Namespace: "ns1", Volume: "lvolB", AllocatedBytes: 300, From: testLvolStoreName, To: resourceIDToLvolStoreName("lvs1")},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"pool list failure": {
+			in:      testStoragePoolName,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list pools",
+		},
+		"rebalancing not enabled": {
+			in:       testStoragePoolName,
+			spdk:     []string{},
+			errCode:  codes.FailedPrecondition,
+			errMsg:   fmt.Sprintf("Rebalancing is not enabled, can't report on StoragePool %s", testStoragePoolName),
+			disabled: true,
+		},
+		"unknown pool": {
+			in:      resourceIDToStoragePoolName("unknown-id"),
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToStoragePoolName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			migrator := setupRebalanceEnvironment(testEnv)
+			if !tt.disabled {
+				testEnv.opiSpdkServer.SetNamespaceMigrator(migrator)
+			}
+
+			request := &GetStoragePoolRebalanceReportRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetStoragePoolRebalanceReport(testEnv.ctx, request)
+
+			var members []*StoragePoolMemberUsage
+			var migrations []*StoragePoolMigration
+			if response != nil {
+				members, migrations = response.Members, response.Migrations
+			}
+			if !reflect.DeepEqual(members, tt.members) {
+				t.Error("members: expected", tt.members, "received", members)
+			}
+			if !reflect.DeepEqual(migrations, tt.migrations) {
+				t.Error("migrations: expected", tt.migrations, "received", migrations)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_RebalanceStoragePool(t *testing.T) {
+	tests := map[string]struct {
+		spdk     []string
+		reportID string
+		errCode  codes.Code
+		errMsg   string
+		opErr    *operations.Error
+		migrate  error
+		migrated []string
+		lvols    []string
+	}{
+		"approved report": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "3f1d5e2a-8c7b-4a61-9e0f-6d2b4c8a7e15"}}`,
+				testSuccessResponse,
+			},
+			errCode:  codes.OK,
+			errMsg:   "",
+			migrated: []string{"ns1"},
+			lvols:    []string{"lvolA", "lvolC"},
+		},
+		"migration failure": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "3f1d5e2a-8c7b-4a61-9e0f-6d2b4c8a7e15"}}`,
+				testSuccessResponse,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.Aborted, Message: "failed"},
+			migrate: status.Error(codes.Aborted, "failed"),
+			lvols:   []string{"lvolA", "lvolB", "lvolC"},
+		},
+		"outdated report": {
+			spdk:     []string{},
+			reportID: "outdated",
+			errCode:  codes.FailedPrecondition,
+			errMsg:   fmt.Sprintf("Report %s is not the last report of StoragePool %s, get a new one", "outdated", testStoragePoolName),
+			lvols:    []string{"lvolA", "lvolB", "lvolC"},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(append([]string{fmt.Sprintf(testRebalancePoolList, 800, 200), fmt.Sprintf(testRebalanceAllocation, 100), fmt.Sprintf(testRebalanceAllocation, 300)}, tt.spdk...))
+			defer testEnv.Close()
+
+			migrator := setupRebalanceEnvironment(testEnv)
+			migrator.err = tt.migrate
+			testEnv.opiSpdkServer.SetNamespaceMigrator(migrator)
+			report, err := testEnv.opiSpdkServer.GetStoragePoolRebalanceReport(testEnv.ctx, &GetStoragePoolRebalanceReportRequest{Name: testStoragePoolName})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			reportID := tt.reportID
+			if reportID == "" {
+				reportID = report.ID
+			}
+
+			request := &RebalanceStoragePoolRequest{Name: testStoragePoolName, ReportID: reportID}
+			response, err := testEnv.opiSpdkServer.RebalanceStoragePool(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err == nil {
+				testEnv.opiSpdkServer.operations.Wait()
+				op, _ := testEnv.opiSpdkServer.operations.GetOperation(testEnv.ctx, &operations.GetOperationRequest{Name: response.Name})
+				if (op.Error == nil) != (tt.opErr == nil) || (tt.opErr != nil && *op.Error != *tt.opErr) {
+					t.Error("operation error: expected", tt.opErr, "received", op.Error)
+				}
+				// a report is only approved once
+				_, err := testEnv.opiSpdkServer.RebalanceStoragePool(testEnv.ctx, request)
+				if er, _ := status.FromError(err); er.Code() != codes.FailedPrecondition {
+					t.Error("error code: expected", codes.FailedPrecondition, "received", er.Code())
+				}
+			}
+
+			if !reflect.DeepEqual(migrator.migrated, tt.migrated) {
+				t.Error("migrated: expected", tt.migrated, "received", migrator.migrated)
+			}
+			lvols := map[string]bool{}
+			names, _ := testEnv.opiSpdkServer.lvolNames(testLvolStoreName)
+			for _, name := range names {
+				lvols[name] = true
+			}
+			for _, id := range tt.lvols {
+				if !lvols[resourceIDToLvolName(id)] {
+					t.Error("expected lvol", id, "to be left on", testLvolStoreName)
+				}
+			}
+			if len(names) != len(tt.lvols) {
+				t.Error("lvols: expected", tt.lvols, "received", names)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	testStoragePoolID   = "pool0"
+	testStoragePoolName = resourceIDToStoragePoolName(testStoragePoolID)
+	testStoragePool     = StoragePool{
+		Name:   testStoragePoolName,
+		Policy: placementLeastUsed,
+		Members: []*StoragePoolMember{
+			{Volume: "Malloc0", Locality: "local"},
+			{Volume: "Malloc1", Locality: "rack1"},
+		},
+	}
+)
+
+func TestBackEnd_CreateStoragePool(t *testing.T) {
+	tests := map[string]struct {
+		in      *StoragePool
+		out     *StoragePool
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"missing members": {
+			in:      &StoragePool{},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: storage_pool.members",
+		},
+		"missing preferred locality": {
+			in:      &StoragePool{Policy: placementLocality, Members: testStoragePool.Members},
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: storage_pool.preferred_locality",
+		},
+		"unsupported policy": {
+			in:      &StoragePool{Policy: "RANDOM", Members: testStoragePool.Members},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Policy value (RANDOM) is not supported, have to be LEAST_USED, ROUND_ROBIN or LOCALITY",
+		},
+		"member with volume and lvol store": {
+			in:      &StoragePool{Members: []*StoragePoolMember{{Volume: "Malloc0", LvolStore: testLvolStoreName}}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Members[0] has to be either a volume or an lvol store",
+		},
+		"lvol store without lvol size": {
+			in:      &StoragePool{Members: []*StoragePoolMember{{LvolStore: testLvolStoreName}}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("LvolSizeBytes value (%d) has to be positive when the pool has lvol stores", 0),
+		},
+		"unknown lvol store": {
+			in:      &StoragePool{Members: []*StoragePoolMember{{LvolStore: resourceIDToLvolStoreName("unknown-id")}}, LvolSizeBytes: 1073741824},
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToLvolStoreName("unknown-id")),
+		},
+		"valid request": {
+			in:      &StoragePool{Members: testStoragePool.Members},
+			out:     &testStoragePool,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      &StoragePool{Policy: placementRoundRobin, Members: testStoragePool.Members},
+			out:     &testStoragePool,
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveStoragePool(&testStoragePool)
+				testEnv.opiSpdkServer.ListHelper[testStoragePoolName] = false
+			}
+
+			request := &CreateStoragePoolRequest{StoragePool: tt.in, StoragePoolID: testStoragePoolID}
+			response, err := testEnv.opiSpdkServer.CreateStoragePool(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteStoragePool(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      testStoragePoolName,
+			out:     &emptypb.Empty{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToStoragePoolName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToStoragePoolName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToStoragePoolName("unknown-id"),
+			out:     &emptypb.Empty{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveStoragePool(&testStoragePool)
+			testEnv.opiSpdkServer.ListHelper[testStoragePoolName] = false
+
+			request := &DeleteStoragePoolRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteStoragePool(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListStoragePools(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveStoragePool(&testStoragePool)
+	testEnv.opiSpdkServer.ListHelper[testStoragePoolName] = false
+
+	response, err := testEnv.opiSpdkServer.ListStoragePools(testEnv.ctx, &ListStoragePoolsRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !reflect.DeepEqual(response.StoragePools, []*StoragePool{&testStoragePool}) {
+		t.Error("response: expected", []*StoragePool{&testStoragePool}, "received", response.StoragePools)
+	}
+}
+
+func TestBackEnd_GetStoragePool(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveStoragePool(&testStoragePool)
+	testEnv.opiSpdkServer.ListHelper[testStoragePoolName] = false
+
+	response, err := testEnv.opiSpdkServer.GetStoragePool(testEnv.ctx, &GetStoragePoolRequest{Name: testStoragePoolName})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !reflect.DeepEqual(response, &testStoragePool) {
+		t.Error("response: expected", &testStoragePool, "received", response)
+	}
+
+	_, err = testEnv.opiSpdkServer.GetStoragePool(testEnv.ctx, &GetStoragePoolRequest{Name: resourceIDToStoragePoolName("unknown-id")})
+	if er, _ := status.FromError(err); er.Code() != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", er.Code())
+	}
+}
+
+func TestBackEnd_PlaceVolume(t *testing.T) {
+	allocation := `{"id":%%d,"error":{"code":0,"message":""},"result":{"status": 0, "size_bytes": 1000, "allocated_bytes": %d}}`
+	tests := map[string]struct {
+		policy  string
+		placed  []string
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"least used": {
+			policy:  placementLeastUsed,
+			placed:  []string{"Malloc1"},
+			spdk:    []string{fmt.Sprintf(allocation, 600), fmt.Sprintf(allocation, 200)},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"least used when all full": {
+			policy:  placementLeastUsed,
+			placed:  []string{""},
+			spdk:    []string{fmt.Sprintf(allocation, 1000), fmt.Sprintf(allocation, 1000)},
+			errCode: codes.ResourceExhausted,
+			errMsg:  fmt.Sprintf("StoragePool %s has no member with free capacity", testStoragePoolName),
+		},
+		"round robin": {
+			policy:  placementRoundRobin,
+			placed:  []string{"Malloc0", "Malloc1", "Malloc0"},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"locality": {
+			policy:  placementLocality,
+			placed:  []string{"Malloc1"},
+			spdk:    []string{fmt.Sprintf(allocation, 600)},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"locality when preferred full": {
+			policy:  placementLocality,
+			placed:  []string{"Malloc0"},
+			spdk:    []string{fmt.Sprintf(allocation, 1000), fmt.Sprintf(allocation, 600), fmt.Sprintf(allocation, 1000)},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"allocation failure": {
+			policy:  placementLeastUsed,
+			placed:  []string{""},
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not get allocation of Malloc0",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			pool := testStoragePool
+			pool.Policy = tt.policy
+			pool.PreferredLocality = "rack1"
+			_ = testEnv.opiSpdkServer.saveStoragePool(&pool)
+
+			var err error
+			placed := make([]string, len(tt.placed))
+			for i := range tt.placed {
+				placed[i], err = testEnv.opiSpdkServer.PlaceVolume(testEnv.ctx, testStoragePoolName)
+			}
+
+			if !reflect.DeepEqual(placed, tt.placed) {
+				t.Error("placed: expected", tt.placed, "received", placed)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_PlaceVolumeOnLvolStore(t *testing.T) {
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "pool_list": [{"name": "lvs0", "capacity_bytes": 1000, "allocated_bytes": 250}]}}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "3f1d5e2a-8c7b-4a61-9e0f-6d2b4c8a7e15"}}`,
+		testSuccessResponse,
+	})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveLvolStore(&testLvolStore)
+	testEnv.opiSpdkServer.ListHelper[testLvolStoreName] = false
+	pool := StoragePool{
+		Name:          testStoragePoolName,
+		Policy:        placementLeastUsed,
+		Members:       []*StoragePoolMember{{LvolStore: testLvolStoreName}},
+		LvolSizeBytes: 1073741824,
+	}
+	_ = testEnv.opiSpdkServer.saveStoragePool(&pool)
+
+	volume, err := testEnv.opiSpdkServer.PlaceVolume(testEnv.ctx, testStoragePoolName)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	lvol, found, err := testEnv.opiSpdkServer.getLvol(resourceIDToLvolName(volume))
+	if err != nil || !found {
+		t.Fatal("expected lvol", volume, "to be carved", err)
+	}
+	if lvol.LvolStore != testLvolStoreName || lvol.SizeBytes != pool.LvolSizeBytes || !lvol.ThinProvisioned {
+		t.Error("lvol: expected to be carved from", testLvolStoreName, "received", lvol)
+	}
+
+	// the lvol is deleted when the namespace can't be created on it
+	if err := testEnv.opiSpdkServer.ReleaseVolume(testEnv.ctx, testStoragePoolName, volume); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if _, ok := testEnv.opiSpdkServer.volumeTypes[lvol.Name]; ok {
+		t.Error("expected lvol", lvol.Name, "to be released")
+	}
+}
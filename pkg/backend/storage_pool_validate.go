@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// placementPolicies are the placement policies of the storage pools
+var placementPolicies = map[string]bool{
+	placementLeastUsed:  true,
+	placementRoundRobin: true,
+	placementLocality:   true,
+}
+
+func (s *Server) validateCreateStoragePoolRequest(in *CreateStoragePoolRequest) error {
+	// check required fields
+	if in.StoragePool == nil {
+		return errors.New("missing required field: storage_pool")
+	}
+	if len(in.StoragePool.Members) == 0 {
+		return errors.New("missing required field: storage_pool.members")
+	}
+	if in.StoragePool.Policy == placementLocality && in.StoragePool.PreferredLocality == "" {
+		return errors.New("missing required field: storage_pool.preferred_locality")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.StoragePoolID != "" {
+		if err := resourceid.ValidateUserSettable(in.StoragePoolID); err != nil {
+			return err
+		}
+	}
+	// check Policy, empty uses LEAST_USED
+	if in.StoragePool.Policy != "" && !placementPolicies[in.StoragePool.Policy] {
+		msg := fmt.Sprintf("Policy value (%s) is not supported, have to be LEAST_USED, ROUND_ROBIN or LOCALITY", in.StoragePool.Policy)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check Members, each one is either a volume or an lvol store
+	hasLvolStores := false
+	for i, member := range in.StoragePool.Members {
+		if member == nil || (member.Volume == "") == (member.LvolStore == "") {
+			msg := fmt.Sprintf("Members[%d] has to be either a volume or an lvol store", i)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		if member.LvolStore != "" {
+			// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+			if err := resourcename.Validate(member.LvolStore); err != nil {
+				return err
+			}
+			hasLvolStores = true
+		}
+	}
+	// check LvolSizeBytes, the lvols carved for the namespaces have this size
+	if hasLvolStores && in.StoragePool.LvolSizeBytes <= 0 {
+		msg := fmt.Sprintf("LvolSizeBytes value (%d) has to be positive when the pool has lvol stores", in.StoragePool.LvolSizeBytes)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteStoragePoolRequest(in *DeleteStoragePoolRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetStoragePoolRequest(in *GetStoragePoolRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetStoragePoolRebalanceReportRequest(in *GetStoragePoolRebalanceReportRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateRebalanceStoragePoolRequest(in *RebalanceStoragePoolRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	if in.ReportID == "" {
+		return errors.New("missing required field: report_id")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
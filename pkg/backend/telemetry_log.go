@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Initiators of an NVMe telemetry log, see the Telemetry Host-Initiated and
+// Telemetry Controller-Initiated log pages of the NVMe base specification
+const (
+	telemetryInitiatorHost       = "HOST"
+	telemetryInitiatorController = "CONTROLLER"
+)
+
+// telemetryBlockBytes is the size of the blocks the telemetry log is made of
+const telemetryBlockBytes = 512
+
+// defaultTelemetryChunkBytes is the size of the chunks the log is read by when the request doesn't say
+const defaultTelemetryChunkBytes = 64 * 1024
+
+// maxTelemetryChunkBytes is the largest chunk read from the firmware at once
+const maxTelemetryChunkBytes = 1024 * 1024
+
+// GetNvmeTelemetryLogRequest represents a request to get the telemetry log of an NVMe device
+type GetNvmeTelemetryLogRequest struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// Initiator is HOST, which captures a new log, or CONTROLLER, which reads the log the
+	// controller saved on its own, HOST when empty
+	Initiator string `json:"initiator"`
+	// ChunkBytes is the size of the sent chunks, a multiple of 512 up to 1MiB, 64KiB when 0
+	ChunkBytes int32 `json:"chunkBytes"`
+}
+
+// NvmeTelemetryLogChunk represents a chunk of the telemetry log of an NVMe device
+type NvmeTelemetryLogChunk struct {
+	// Device is the name of the NVMe device attached to the DPU
+	Device string `json:"device"`
+	// Initiator is HOST or CONTROLLER
+	Initiator string `json:"initiator"`
+	// DataGeneration is the generation number of the log, it changes when a new log is captured
+	DataGeneration int32 `json:"dataGeneration"`
+	// OffsetBytes is the offset of the chunk in the log
+	OffsetBytes uint64 `json:"offsetBytes"`
+	// TotalBytes is the size of the whole log
+	TotalBytes uint64 `json:"totalBytes"`
+	// Data of the chunk, base64 encoded in JSON
+	Data []byte `json:"data"`
+}
+
+// GetNvmeTelemetryLog reads the telemetry log of an NVMe device and sends it in chunks, so the
+// support bundles of the device vendors can be collected without access to the host. A HOST
+// request captures a new log first
+func (s *Server) GetNvmeTelemetryLog(ctx context.Context, in *GetNvmeTelemetryLogRequest, send func(*NvmeTelemetryLogChunk) error) error {
+	// check input correctness
+	if err := s.validateGetNvmeTelemetryLogRequest(in); err != nil {
+		return err
+	}
+	initiator := in.Initiator
+	if initiator == "" {
+		initiator = telemetryInitiatorHost
+	}
+	chunkBytes := uint32(in.ChunkBytes)
+	if chunkBytes == 0 {
+		chunkBytes = defaultTelemetryChunkBytes
+	}
+	var offset uint64
+	generation := -1
+	for {
+		params := models.MrvlNvmGetTelemetryLogParams{
+			Device:        in.Device,
+			HostInitiated: initiator == telemetryInitiatorHost,
+			Create:        initiator == telemetryInitiatorHost && generation < 0,
+			Offset:        offset,
+			Length:        chunkBytes,
+		}
+		var result models.MrvlNvmGetTelemetryLogResult
+		err := s.rpc.Call(ctx, "mrvl_nvm_get_telemetry_log", &params, &result)
+		if err != nil {
+			return err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not get telemetry log of %s", in.Device)
+			return result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_telemetry_log")
+		}
+		if !result.Available {
+			msg := fmt.Sprintf("No %s telemetry log is available on %s", initiator, in.Device)
+			return status.Errorf(codes.FailedPrecondition, msg)
+		}
+		// a new log was captured while the previous chunks were read
+		if generation >= 0 && result.DataGeneration != generation {
+			msg := fmt.Sprintf("Telemetry log of %s changed while it was read", in.Device)
+			return status.Errorf(codes.Aborted, msg)
+		}
+		generation = result.DataGeneration
+		if len(result.Data) == 0 {
+			if offset < result.TotalBytes {
+				msg := fmt.Sprintf("Telemetry log of %s ended at %d of %d bytes", in.Device, offset, result.TotalBytes)
+				return status.Errorf(codes.DataLoss, msg)
+			}
+			break
+		}
+		err = send(&NvmeTelemetryLogChunk{
+			Device:         in.Device,
+			Initiator:      initiator,
+			DataGeneration: int32(result.DataGeneration),
+			OffsetBytes:    offset,
+			TotalBytes:     result.TotalBytes,
+			Data:           result.Data,
+		})
+		if err != nil {
+			return err
+		}
+		offset += uint64(len(result.Data))
+		if offset >= result.TotalBytes {
+			break
+		}
+	}
+	slog.InfoContext(ctx, "Sent telemetry log", "device", in.Device, "initiator", initiator, "bytes", offset, "generation", generation)
+	return nil
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBackEnd_GetNvmeTelemetryLog(t *testing.T) {
+	tests := map[string]struct {
+		in      *GetNvmeTelemetryLogRequest
+		out     []*NvmeTelemetryLogChunk
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &GetNvmeTelemetryLogRequest{Device: testDevice},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get telemetry log of %v", testDevice),
+		},
+		"valid request with empty SPDK response": {
+			in:      &GetNvmeTelemetryLogRequest{Device: testDevice},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_get_telemetry_log: %v", "EOF"),
+		},
+		"valid request with unavailable log SPDK response": {
+			in:      &GetNvmeTelemetryLogRequest{Device: testDevice, Initiator: "CONTROLLER"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "available": false}}`},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("No CONTROLLER telemetry log is available on %v", testDevice),
+		},
+		"valid request with valid SPDK response": {
+			in: &GetNvmeTelemetryLogRequest{Device: testDevice},
+			out: []*NvmeTelemetryLogChunk{
+				{Device: testDevice, Initiator: "HOST", DataGeneration: 3, OffsetBytes: 0, TotalBytes: 6, Data: []byte{0, 1, 2}},
+				{Device: testDevice, Initiator: "HOST", DataGeneration: 3, OffsetBytes: 3, TotalBytes: 6, Data: []byte{3, 4, 5}},
+			},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "available": true, "total_bytes": 6, "data_generation": 3, "data": "AAEC"}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "available": true, "total_bytes": 6, "data_generation": 3, "data": "AwQF"}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with log changed SPDK response": {
+			in: &GetNvmeTelemetryLogRequest{Device: testDevice, Initiator: "CONTROLLER"},
+			out: []*NvmeTelemetryLogChunk{
+				{Device: testDevice, Initiator: "CONTROLLER", DataGeneration: 3, OffsetBytes: 0, TotalBytes: 6, Data: []byte{0, 1, 2}},
+			},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "available": true, "total_bytes": 6, "data_generation": 3, "data": "AAEC"}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "available": true, "total_bytes": 6, "data_generation": 4, "data": "AwQF"}}`,
+			},
+			errCode: codes.Aborted,
+			errMsg:  fmt.Sprintf("Telemetry log of %v changed while it was read", testDevice),
+		},
+		"valid request with truncated log SPDK response": {
+			in: &GetNvmeTelemetryLogRequest{Device: testDevice},
+			out: []*NvmeTelemetryLogChunk{
+				{Device: testDevice, Initiator: "HOST", DataGeneration: 3, OffsetBytes: 0, TotalBytes: 6, Data: []byte{0, 1, 2}},
+			},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "available": true, "total_bytes": 6, "data_generation": 3, "data": "AAEC"}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "available": true, "total_bytes": 6, "data_generation": 3, "data": ""}}`,
+			},
+			errCode: codes.DataLoss,
+			errMsg:  fmt.Sprintf("Telemetry log of %v ended at 3 of 6 bytes", testDevice),
+		},
+		"not supported initiator": {
+			in:      &GetNvmeTelemetryLogRequest{Device: testDevice, Initiator: "VENDOR"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Initiator value (VENDOR) is not supported, have to be HOST or CONTROLLER",
+		},
+		"out of range chunk size": {
+			in:      &GetNvmeTelemetryLogRequest{Device: testDevice, ChunkBytes: 2 * 1024 * 1024},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("ChunkBytes value (%d) is out of range, have to be between 0 and %d", 2*1024*1024, maxTelemetryChunkBytes),
+		},
+		"not aligned chunk size": {
+			in:      &GetNvmeTelemetryLogRequest{Device: testDevice, ChunkBytes: 1000},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "ChunkBytes value (1000) is not a multiple of 512",
+		},
+		"no required field": {
+			in:      &GetNvmeTelemetryLogRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: device",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			var received []*NvmeTelemetryLogChunk
+			err := testEnv.opiSpdkServer.GetNvmeTelemetryLog(testEnv.ctx, tt.in, func(chunk *NvmeTelemetryLogChunk) error {
+				received = append(received, chunk)
+				return nil
+			})
+
+			if !reflect.DeepEqual(received, tt.out) {
+				t.Error("response: expected", tt.out, "received", received)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
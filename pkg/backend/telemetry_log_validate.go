@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateGetNvmeTelemetryLogRequest(in *GetNvmeTelemetryLogRequest) error {
+	// check required fields
+	if in.Device == "" {
+		return errors.New("missing required field: device")
+	}
+	// check Initiator value
+	switch in.Initiator {
+	case "", telemetryInitiatorHost, telemetryInitiatorController:
+	default:
+		msg := fmt.Sprintf("Initiator value (%s) is not supported, have to be HOST or CONTROLLER", in.Initiator)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check ChunkBytes range, the log is made of 512 bytes blocks
+	if in.ChunkBytes < 0 || in.ChunkBytes > maxTelemetryChunkBytes {
+		msg := fmt.Sprintf("ChunkBytes value (%d) is out of range, have to be between 0 and %d", in.ChunkBytes, maxTelemetryChunkBytes)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.ChunkBytes%telemetryBlockBytes != 0 {
+		msg := fmt.Sprintf("ChunkBytes value (%d) is not a multiple of %d", in.ChunkBytes, telemetryBlockBytes)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// tlsPskHashes maps the hash of the PSK interchange format to the hash of the PSK identity,
+// a PSK which is not transformed (00) is 32 bytes long and uses SHA-256
+var tlsPskHashes = map[string]string{
+	"00": "01",
+	"01": "01",
+	"02": "02",
+}
+
+// TLSPsk represents a TLS pre-shared key of NVMe/TCP, shared by a host and a subsystem.
+// The key is only used to program the keyring of the firmware, the bridge keeps its
+// fingerprint and never returns it
+type TLSPsk struct {
+	// Name of the TLS PSK
+	Name string `json:"name"`
+	// Key is the PSK in the NVMe TLS PSK interchange format, NVMeTLSkey-1:xx:<base64>:, input only
+	Key string `json:"key,omitempty"`
+	// Hostnqn is the NQN of the host the PSK is configured for
+	Hostnqn string `json:"hostnqn"`
+	// Subnqn is the NQN of the subsystem the PSK is configured for
+	Subnqn string `json:"subnqn"`
+	// Identity is the TLS PSK identity presented to the target, output only
+	Identity string `json:"identity,omitempty"`
+	// KeyFingerprint is the SHA-256 of the PSK, output only
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
+}
+
+// CreateTLSPskRequest represents a request to create a TLS PSK
+type CreateTLSPskRequest struct {
+	// TLSPsk to create
+	TLSPsk *TLSPsk `json:"tlsPsk"`
+	// TLSPskID is the user specified id of the TLS PSK
+	TLSPskID string `json:"tlsPskId"`
+}
+
+// DeleteTLSPskRequest represents a request to delete a TLS PSK
+type DeleteTLSPskRequest struct {
+	// Name of the TLS PSK
+	Name string `json:"name"`
+	// AllowMissing makes the request succeed when the TLS PSK doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetTLSPskRequest represents a request to get a TLS PSK
+type GetTLSPskRequest struct {
+	// Name of the TLS PSK
+	Name string `json:"name"`
+}
+
+// ListTLSPsksRequest represents a request to list TLS PSKs
+type ListTLSPsksRequest struct {
+	// PageSize is the maximum number of TLS PSKs returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListTLSPsksResponse represents a list of TLS PSKs
+type ListTLSPsksResponse struct {
+	// TLSPsks is the page of TLS PSKs
+	TLSPsks []*TLSPsk `json:"tlsPsks"`
+	// NextPageToken is set when more TLS PSKs are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToTLSPskName builds the name of a TLS PSK, they have their own collection
+// as they are not part of the OPI APIs
+func resourceIDToTLSPskName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"tlsPsks", resourceID,
+	)
+}
+
+// CreateTLSPsk loads a TLS PSK in the keyring of the firmware, remote controllers use it by
+// setting its name as their psk
+func (s *Server) CreateTLSPsk(ctx context.Context, in *CreateTLSPskRequest) (*TLSPsk, error) {
+	// check input correctness
+	if err := s.validateCreateTLSPskRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.TLSPskID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.TLSPskID, "name", in.TLSPsk.Name)
+		resourceID = in.TLSPskID
+	}
+	name := resourceIDToTLSPskName(resourceID)
+	// idempotent API when called with same key, should return same object
+	psk, found, err := s.getTLSPsk(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing TLSPsk", "name", name)
+		return psk, nil
+	}
+	// not found, so create a new one
+	if err := s.addKeyringKey(ctx, tlsPskKeyName(resourceID), in.TLSPsk.Key); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(in.TLSPsk.Key))
+	psk = &TLSPsk{
+		Name:    name,
+		Hostnqn: in.TLSPsk.Hostnqn,
+		Subnqn:  in.TLSPsk.Subnqn,
+		// see NVMe TCP Transport Specification, TLS PSK identity
+		Identity:       fmt.Sprintf("NVMe0R%s %s %s", tlsPskHashes[strings.Split(in.TLSPsk.Key, ":")[1]], in.TLSPsk.Hostnqn, in.TLSPsk.Subnqn),
+		KeyFingerprint: hex.EncodeToString(sum[:]),
+	}
+	if err := s.saveTLSPsk(psk); err != nil {
+		return nil, err
+	}
+	s.ListHelper[name] = false
+	return psk, nil
+}
+
+// DeleteTLSPsk removes a TLS PSK from the keyring of the firmware, the remote controllers
+// using it have to be deleted first
+func (s *Server) DeleteTLSPsk(ctx context.Context, in *DeleteTLSPskRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteTLSPskRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	_, found, err := s.getTLSPsk(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	for _, controllerName := range s.nvmeRemoteControllerNames() {
+		controller := new(pb.NvmeRemoteController)
+		found, err := s.store.Get(controllerName, controller)
+		if err != nil {
+			return nil, err
+		}
+		if found && string(controller.GetTcp().GetPsk()) == in.Name {
+			msg := fmt.Sprintf("TLSPsk %s is used by NvmeRemoteController %s", in.Name, controllerName)
+			return nil, status.Errorf(codes.FailedPrecondition, msg)
+		}
+	}
+	if err := s.removeKeyringKey(ctx, tlsPskKeyName(path.Base(in.Name))); err != nil {
+		return nil, err
+	}
+	// remove from the Database
+	delete(s.ListHelper, in.Name)
+	err = s.store.Delete(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListTLSPsks lists TLS PSKs
+func (s *Server) ListTLSPsks(_ context.Context, in *ListTLSPsksRequest) (*ListTLSPsksResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.tlsPskNames()
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*TLSPsk, 0, len(names))
+	for _, name := range names {
+		psk, found, err := s.getTLSPsk(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, psk)
+	}
+	return &ListTLSPsksResponse{TLSPsks: Blobarray, NextPageToken: token}, nil
+}
+
+// GetTLSPsk gets a TLS PSK, without the key
+func (s *Server) GetTLSPsk(_ context.Context, in *GetTLSPskRequest) (*TLSPsk, error) {
+	// check input correctness
+	if err := s.validateGetTLSPskRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	psk, found, err := s.getTLSPsk(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return psk, nil
+}
+
+// tlsPskKeyName is the name of the keyring key holding a TLS PSK
+func tlsPskKeyName(resourceID string) string {
+	return "tls_psk_" + resourceID
+}
+
+// isTLSPskName tells if the psk of a remote controller refers to a TLS PSK, older
+// remote controllers have the PSK itself in the interchange format
+func isTLSPskName(psk []byte) bool {
+	return strings.HasPrefix(string(psk), resourceIDToTLSPskName("")+"/")
+}
+
+func (s *Server) tlsPskNames() []string {
+	prefix := resourceIDToTLSPskName("") + "/"
+	var names []string
+	for key := range s.ListHelper {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getTLSPsk fetches a TLS PSK from the database,
+// TLS PSKs are not protobufs so they are stored JSON encoded
+func (s *Server) getTLSPsk(name string) (*TLSPsk, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	psk := new(TLSPsk)
+	if err := json.Unmarshal(value.Value, psk); err != nil {
+		return nil, false, err
+	}
+	return psk, true, nil
+}
+
+func (s *Server) saveTLSPsk(psk *TLSPsk) error {
+	data, err := json.Marshal(psk)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(psk.Name, wrapperspb.Bytes(data))
+}
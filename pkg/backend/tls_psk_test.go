@@ -0,0 +1,352 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var (
+	testTLSPskID   = "psk0"
+	testTLSPskName = resourceIDToTLSPskName(testTLSPskID)
+	testTLSPskKey  = "NVMeTLSkey-1:01:VRLbtnN9AQb2WXW3c9+wEf/DRLz0QuLdbYvEhwtdWwNf9LrZ:"
+	testTLSPsk     = TLSPsk{
+		Name:           testTLSPskName,
+		Hostnqn:        "nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c",
+		Subnqn:         "nqn.2016-06.io.spdk:cnode1",
+		Identity:       "NVMe0R01 nqn.2014-08.org.nvmexpress:uuid:feb98abe-d51f-40c8-b348-2753f3571d3c nqn.2016-06.io.spdk:cnode1",
+		KeyFingerprint: dhchapFingerprint(testTLSPskKey),
+	}
+)
+
+func TestBackEnd_CreateTLSPsk(t *testing.T) {
+	tests := map[string]struct {
+		in      *TLSPsk
+		out     *TLSPsk
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"missing key": {
+			in:      &TLSPsk{Hostnqn: testTLSPsk.Hostnqn, Subnqn: testTLSPsk.Subnqn},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: tls_psk.key",
+		},
+		"missing subnqn": {
+			in:      &TLSPsk{Key: testTLSPskKey, Hostnqn: testTLSPsk.Hostnqn},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: tls_psk.subnqn",
+		},
+		"malformed key": {
+			in:      &TLSPsk{Key: "NVMeTLSkey-1:03:c2VjcmV0:", Hostnqn: testTLSPsk.Hostnqn, Subnqn: testTLSPsk.Subnqn},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Key value is not a TLS PSK, have to be NVMeTLSkey-1:xx:<base64>:",
+		},
+		"valid request with invalid SPDK response": {
+			in:      &TLSPsk{Key: testTLSPskKey, Hostnqn: testTLSPsk.Hostnqn, Subnqn: testTLSPsk.Subnqn},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not add key %v to the keyring", tlsPskKeyName(testTLSPskID)),
+		},
+		"valid request": {
+			in:      &TLSPsk{Key: testTLSPskKey, Hostnqn: testTLSPsk.Hostnqn, Subnqn: testTLSPsk.Subnqn},
+			out:     &testTLSPsk,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      &TLSPsk{Key: testTLSPskKey, Hostnqn: testTLSPsk.Hostnqn, Subnqn: testTLSPsk.Subnqn},
+			out:     &testTLSPsk,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveTLSPsk(&testTLSPsk)
+				testEnv.opiSpdkServer.ListHelper[testTLSPskName] = false
+			}
+
+			request := &CreateTLSPskRequest{TLSPsk: tt.in, TLSPskID: testTLSPskID}
+			response, err := testEnv.opiSpdkServer.CreateTLSPsk(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteTLSPsk(t *testing.T) {
+	testNvmeRemoteControllerTLS := utils.ProtoClone(&testNvmeRemoteController)
+	testNvmeRemoteControllerTLS.Tcp.Psk = []byte(testTLSPskName)
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+		used    bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testTLSPskName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not remove key %v from the keyring", tlsPskKeyName(testTLSPskID)),
+		},
+		"valid request": {
+			in:      testTLSPskName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"used by a remote controller": {
+			in:      testTLSPskName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("TLSPsk %v is used by NvmeRemoteController %v", testTLSPskName, testNvmeRemoteControllerName),
+			used:    true,
+		},
+		"unknown key": {
+			in:      resourceIDToTLSPskName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToTLSPskName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToTLSPskName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveTLSPsk(&testTLSPsk)
+			testEnv.opiSpdkServer.ListHelper[testTLSPskName] = false
+			if tt.used {
+				_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, testNvmeRemoteControllerTLS)
+				testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			}
+
+			request := &DeleteTLSPskRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteTLSPsk(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListTLSPsks(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveTLSPsk(&testTLSPsk)
+	testEnv.opiSpdkServer.ListHelper[testTLSPskName] = false
+	_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, &testNvmeRemoteController)
+	testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+
+	response, err := testEnv.opiSpdkServer.ListTLSPsks(testEnv.ctx, &ListTLSPsksRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	expected := &ListTLSPsksResponse{TLSPsks: []*TLSPsk{&testTLSPsk}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Error("response: expected", expected, "received", response)
+	}
+}
+
+func TestBackEnd_GetTLSPsk(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *TLSPsk
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testTLSPskName,
+			out:     &testTLSPsk,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      resourceIDToTLSPskName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToTLSPskName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveTLSPsk(&testTLSPsk)
+			testEnv.opiSpdkServer.ListHelper[testTLSPskName] = false
+
+			request := &GetTLSPskRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetTLSPsk(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_CreateNvmePathWithTLSPsk(t *testing.T) {
+	testNvmePathRdma := utils.ProtoClone(&testNvmePath)
+	testNvmePathRdma.Trtype = pb.NvmeTransportType_NVME_TRANSPORT_TYPE_RDMA
+	testNvmePathOtherSubsystem := utils.ProtoClone(&testNvmePath)
+	testNvmePathOtherSubsystem.Fabrics.Subnqn = "nqn.2016-06.io.spdk:cnode2"
+	tests := map[string]struct {
+		psk     string
+		in      *pb.NvmePath
+		out     *pb.NvmePath
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"psk on an rdma path": {
+			psk:     testTLSPskName,
+			in:      testNvmePathRdma,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("NvmeRemoteController %v has a TLS PSK but NvmePath %v doesn't use the TCP transport", testNvmeRemoteControllerName, testNvmePathName),
+		},
+		"psk of another subsystem": {
+			psk:     testTLSPskName,
+			in:      testNvmePathOtherSubsystem,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("TLSPsk %v is for subsystem %v, not %v", testTLSPskName, testTLSPsk.Subnqn, "nqn.2016-06.io.spdk:cnode2"),
+		},
+		"psk on a tcp path": {
+			psk:     testTLSPskName,
+			in:      &testNvmePath,
+			out:     &testNvmePath,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"psk in the interchange format": {
+			psk:     testTLSPskKey,
+			in:      testNvmePathOtherSubsystem,
+			out:     testNvmePathOtherSubsystem,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "bdev_list": ["remote0n1"]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown psk": {
+			psk:     resourceIDToTLSPskName("unknown"),
+			in:      &testNvmePath,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToTLSPskName("unknown")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testNvmeRemoteControllerTLS := utils.ProtoClone(&testNvmeRemoteController)
+			testNvmeRemoteControllerTLS.Tcp.Psk = []byte(tt.psk)
+			_ = testEnv.opiSpdkServer.store.Set(testNvmeRemoteControllerName, testNvmeRemoteControllerTLS)
+			testEnv.opiSpdkServer.ListHelper[testNvmeRemoteControllerName] = false
+			_ = testEnv.opiSpdkServer.saveTLSPsk(&testTLSPsk)
+			testEnv.opiSpdkServer.ListHelper[testTLSPskName] = false
+			in := utils.ProtoClone(tt.in)
+			in.Name = ""
+
+			request := &pb.CreateNvmePathRequest{Parent: testNvmeRemoteControllerName, NvmePath: in, NvmePathId: testNvmePathID}
+			response, err := testEnv.opiSpdkServer.CreateNvmePath(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"regexp"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tlsPskInterchange matches the NVMe TLS PSK interchange format, the base64 encoded PSK and
+// its CRC-32 with the hash function of the PSK, 00 when it is not transformed
+var tlsPskInterchange = regexp.MustCompile(`^NVMeTLSkey-1:0[0-2]:[A-Za-z0-9+/]+={0,2}:$`)
+
+func (s *Server) validateCreateTLSPskRequest(in *CreateTLSPskRequest) error {
+	// check required fields
+	if in.TLSPsk == nil {
+		return errors.New("missing required field: tls_psk")
+	}
+	if in.TLSPsk.Key == "" {
+		return errors.New("missing required field: tls_psk.key")
+	}
+	if in.TLSPsk.Hostnqn == "" {
+		return errors.New("missing required field: tls_psk.hostnqn")
+	}
+	if in.TLSPsk.Subnqn == "" {
+		return errors.New("missing required field: tls_psk.subnqn")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.TLSPskID != "" {
+		if err := resourceid.ValidateUserSettable(in.TLSPskID); err != nil {
+			return err
+		}
+	}
+	// check the key, without logging it
+	if !tlsPskInterchange.MatchString(in.TLSPsk.Key) {
+		msg := "Key value is not a TLS PSK, have to be NVMeTLSkey-1:xx:<base64>:"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteTLSPskRequest(in *DeleteTLSPskRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetTLSPskRequest(in *GetTLSPskRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// xnvmeVolumeType is the type of the volumes backed by a local device through xNVMe
+const xnvmeVolumeType = "xnvme"
+
+// defaultXnvmeIoMechanism is the io mechanism of xNVMe volumes when none is given
+const defaultXnvmeIoMechanism = "io_uring"
+
+// xnvmeIoMechanisms are the io mechanisms xNVMe supports on the DPU SoC, io_uring_cmd
+// passes NVMe commands through to the device and needs its character device
+var xnvmeIoMechanisms = map[string]bool{
+	"libaio":       true,
+	"io_uring":     true,
+	"io_uring_cmd": true,
+}
+
+// XnvmeVolume represents a file or a device of the DPU SoC accessed through xNVMe, a faster
+// alternative to Aio volumes which frontend namespaces can use as volume_name_ref
+type XnvmeVolume struct {
+	// Name of the xNVMe volume
+	Name string `json:"name"`
+	// Filename is the path of the file or the device, /dev/ngXnY for io_uring_cmd
+	Filename string `json:"filename"`
+	// IoMechanism is libaio, io_uring or io_uring_cmd, io_uring when empty
+	IoMechanism string `json:"ioMechanism"`
+	// ConserveCPU makes the poller sleep when idle instead of busy polling
+	ConserveCPU bool `json:"conserveCpu"`
+	// BlockSize is the logical block size of the device, output only
+	BlockSize int64 `json:"blockSize"`
+	// BlocksCount is the size of the device in blocks, output only
+	BlocksCount int64 `json:"blocksCount"`
+	// UUID of the volume, output only
+	UUID string `json:"uuid"`
+}
+
+// CreateXnvmeVolumeRequest represents a request to create an xNVMe volume
+type CreateXnvmeVolumeRequest struct {
+	// XnvmeVolumeID is the ID of the xNVMe volume, generated when empty
+	XnvmeVolumeID string `json:"xnvmeVolumeId"`
+	// XnvmeVolume to create
+	XnvmeVolume *XnvmeVolume `json:"xnvmeVolume"`
+}
+
+// DeleteXnvmeVolumeRequest represents a request to delete an xNVMe volume
+type DeleteXnvmeVolumeRequest struct {
+	// Name of the xNVMe volume
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the xNVMe volume doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetXnvmeVolumeRequest represents a request to get an xNVMe volume
+type GetXnvmeVolumeRequest struct {
+	// Name of the xNVMe volume
+	Name string `json:"name"`
+}
+
+// ListXnvmeVolumesRequest represents a request to list xNVMe volumes
+type ListXnvmeVolumesRequest struct {
+	// PageSize is the maximum number of xNVMe volumes returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListXnvmeVolumesResponse represents a list of xNVMe volumes
+type ListXnvmeVolumesResponse struct {
+	// XnvmeVolumes is the page of xNVMe volumes
+	XnvmeVolumes []*XnvmeVolume `json:"xnvmeVolumes"`
+	// NextPageToken is set when more xNVMe volumes are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToXnvmeVolumeName builds the name of an xNVMe volume, they share the volumes collection
+// with the other backend volumes
+func resourceIDToXnvmeVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"volumes", resourceID,
+	)
+}
+
+// CreateXnvmeVolume creates a volume backed by a file or a device of the DPU SoC through xNVMe,
+// the io mechanism is selected per volume
+func (s *Server) CreateXnvmeVolume(ctx context.Context, in *CreateXnvmeVolumeRequest) (*XnvmeVolume, error) {
+	// check input correctness
+	if err := s.validateCreateXnvmeVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.XnvmeVolumeID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.XnvmeVolumeID, "name", in.XnvmeVolume.Name)
+		resourceID = in.XnvmeVolumeID
+	}
+	name := resourceIDToXnvmeVolumeName(resourceID)
+	if err := s.checkVolumeType(name, xnvmeVolumeType); err != nil {
+		return nil, err
+	}
+	// idempotent API when called with same key, should return same object
+	volume, found, err := s.getXnvmeVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing XnvmeVolume", "name", name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	ioMechanism := in.XnvmeVolume.IoMechanism
+	if ioMechanism == "" {
+		ioMechanism = defaultXnvmeIoMechanism
+	}
+	params := models.MrvlBdevXnvmeCreateParams{
+		Name:        resourceID,
+		Filename:    in.XnvmeVolume.Filename,
+		IoMechanism: ioMechanism,
+		ConserveCPU: in.XnvmeVolume.ConserveCPU,
+	}
+	var result models.MrvlBdevXnvmeCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_xnvme_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Xnvme Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_xnvme_create")
+	}
+	volume = &XnvmeVolume{
+		Name:        name,
+		Filename:    in.XnvmeVolume.Filename,
+		IoMechanism: ioMechanism,
+		ConserveCPU: in.XnvmeVolume.ConserveCPU,
+		BlockSize:   result.BlockSize,
+		BlocksCount: result.NumBlocks,
+		UUID:        result.UUID,
+	}
+	if err := s.saveXnvmeVolume(volume); err != nil {
+		return nil, err
+	}
+	s.volumeTypes[name] = xnvmeVolumeType
+	return volume, nil
+}
+
+// DeleteXnvmeVolume deletes an xNVMe volume, the file or the device is left untouched
+func (s *Server) DeleteXnvmeVolume(ctx context.Context, in *DeleteXnvmeVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteXnvmeVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, xnvmeVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getXnvmeVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevXnvmeDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevXnvmeDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_xnvme_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Xnvme Volume: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_xnvme_delete")
+	}
+	// remove from the Database
+	delete(s.volumeTypes, volume.Name)
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListXnvmeVolumes lists xNVMe volumes
+func (s *Server) ListXnvmeVolumes(_ context.Context, in *ListXnvmeVolumesRequest) (*ListXnvmeVolumesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.volumeNames(xnvmeVolumeType)
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*XnvmeVolume, 0, len(names))
+	for _, name := range names {
+		volume, found, err := s.getXnvmeVolume(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, volume)
+	}
+	return &ListXnvmeVolumesResponse{XnvmeVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetXnvmeVolume gets an xNVMe volume
+func (s *Server) GetXnvmeVolume(_ context.Context, in *GetXnvmeVolumeRequest) (*XnvmeVolume, error) {
+	// check input correctness
+	if err := s.validateGetXnvmeVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkVolumeType(in.Name, xnvmeVolumeType); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getXnvmeVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// getXnvmeVolume fetches an xNVMe volume from the database,
+// xNVMe volumes are not protobufs so they are stored JSON encoded
+func (s *Server) getXnvmeVolume(name string) (*XnvmeVolume, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	volume := new(XnvmeVolume)
+	if err := json.Unmarshal(value.Value, volume); err != nil {
+		return nil, false, err
+	}
+	return volume, true, nil
+}
+
+func (s *Server) saveXnvmeVolume(volume *XnvmeVolume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(volume.Name, wrapperspb.Bytes(data))
+}
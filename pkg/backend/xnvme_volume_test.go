@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	testXnvmeVolumeID   = "xnvme0"
+	testXnvmeVolumeName = resourceIDToXnvmeVolumeName(testXnvmeVolumeID)
+	testXnvmeVolume     = XnvmeVolume{
+		Name:        testXnvmeVolumeName,
+		Filename:    "/dev/nvme0n1",
+		IoMechanism: defaultXnvmeIoMechanism,
+		BlockSize:   4096,
+		BlocksCount: 262144,
+		UUID:        "9b3f6a1c-2e7d-4c85-b0f4-8a1d5e6c7b92",
+	}
+)
+
+func TestBackEnd_CreateXnvmeVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *XnvmeVolume
+		out     *XnvmeVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   string
+	}{
+		"missing filename": {
+			in:      &XnvmeVolume{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: xnvme_volume.filename",
+		},
+		"relative filename": {
+			in:      &XnvmeVolume{Filename: "nvme0n1"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Filename value (%s) is not an absolute path", "nvme0n1"),
+		},
+		"unsupported io mechanism": {
+			in:      &XnvmeVolume{Filename: testXnvmeVolume.Filename, IoMechanism: "spdk_async"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("IoMechanism value (%s) is not supported, have to be libaio, io_uring or io_uring_cmd", "spdk_async"),
+		},
+		"io_uring_cmd on a block device": {
+			in:      &XnvmeVolume{Filename: testXnvmeVolume.Filename, IoMechanism: "io_uring_cmd"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Filename value (%s) is not an NVMe generic device, io_uring_cmd needs /dev/ngXnY", testXnvmeVolume.Filename),
+		},
+		"valid request with invalid SPDK response": {
+			in:      &XnvmeVolume{Filename: testXnvmeVolume.Filename},
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Xnvme Volume: %v", testXnvmeVolumeID),
+		},
+		"valid request with valid SPDK response": {
+			in:      &XnvmeVolume{Filename: testXnvmeVolume.Filename},
+			out:     &testXnvmeVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "uuid": "9b3f6a1c-2e7d-4c85-b0f4-8a1d5e6c7b92", "block_size": 4096, "num_blocks": 262144}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      &XnvmeVolume{Filename: testXnvmeVolume.Filename},
+			out:     &testXnvmeVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   xnvmeVolumeType,
+		},
+		"already exists as aio volume": {
+			in:      &XnvmeVolume{Filename: testXnvmeVolume.Filename},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testXnvmeVolumeName, aioVolumeType, xnvmeVolumeType),
+			exist:   aioVolumeType,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist != "" {
+				testEnv.opiSpdkServer.volumeTypes[testXnvmeVolumeName] = tt.exist
+			}
+			if tt.exist == xnvmeVolumeType {
+				_ = testEnv.opiSpdkServer.saveXnvmeVolume(&testXnvmeVolume)
+			}
+
+			request := &CreateXnvmeVolumeRequest{XnvmeVolume: tt.in, XnvmeVolumeID: testXnvmeVolumeID}
+			response, err := testEnv.opiSpdkServer.CreateXnvmeVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_DeleteXnvmeVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testXnvmeVolumeName,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Xnvme Volume: %v", testXnvmeVolumeID),
+		},
+		"valid request with valid SPDK response": {
+			in:      testXnvmeVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{testSuccessResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"aio volume": {
+			in:      testAioVolumeName,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Volume %s is a %s volume, not a %s volume", testAioVolumeName, aioVolumeType, xnvmeVolumeType),
+		},
+		"unknown key": {
+			in:      resourceIDToXnvmeVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToXnvmeVolumeName("unknown-id")),
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToXnvmeVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveXnvmeVolume(&testXnvmeVolume)
+			testEnv.opiSpdkServer.volumeTypes[testXnvmeVolumeName] = xnvmeVolumeType
+			testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+			request := &DeleteXnvmeVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteXnvmeVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestBackEnd_ListXnvmeVolumes(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.saveXnvmeVolume(&testXnvmeVolume)
+	testEnv.opiSpdkServer.volumeTypes[testXnvmeVolumeName] = xnvmeVolumeType
+	testEnv.opiSpdkServer.volumeTypes[testAioVolumeName] = aioVolumeType
+
+	response, err := testEnv.opiSpdkServer.ListXnvmeVolumes(testEnv.ctx, &ListXnvmeVolumesRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	expected := &ListXnvmeVolumesResponse{XnvmeVolumes: []*XnvmeVolume{&testXnvmeVolume}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Error("response: expected", expected, "received", response)
+	}
+}
+
+func TestBackEnd_GetXnvmeVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *XnvmeVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testXnvmeVolumeName,
+			out:     &testXnvmeVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			in:      resourceIDToXnvmeVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToXnvmeVolumeName("unknown-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveXnvmeVolume(&testXnvmeVolume)
+			testEnv.opiSpdkServer.volumeTypes[testXnvmeVolumeName] = xnvmeVolumeType
+
+			request := &GetXnvmeVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetXnvmeVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backend implememnts the BackEnd APIs (network facing) of the storage Server
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateXnvmeVolumeRequest(in *CreateXnvmeVolumeRequest) error {
+	// check required fields
+	if in.XnvmeVolume == nil {
+		return errors.New("missing required field: xnvme_volume")
+	}
+	if in.XnvmeVolume.Filename == "" {
+		return errors.New("missing required field: xnvme_volume.filename")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.XnvmeVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(in.XnvmeVolumeID); err != nil {
+			return err
+		}
+	}
+	// check Filename, the firmware doesn't know the working directory of the bridge
+	if !path.IsAbs(in.XnvmeVolume.Filename) {
+		msg := fmt.Sprintf("Filename value (%s) is not an absolute path", in.XnvmeVolume.Filename)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check IoMechanism, empty uses io_uring
+	if in.XnvmeVolume.IoMechanism != "" && !xnvmeIoMechanisms[in.XnvmeVolume.IoMechanism] {
+		msg := fmt.Sprintf("IoMechanism value (%s) is not supported, have to be libaio, io_uring or io_uring_cmd", in.XnvmeVolume.IoMechanism)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// io_uring_cmd passes NVMe commands through the generic character device of the namespace
+	if in.XnvmeVolume.IoMechanism == "io_uring_cmd" && !strings.HasPrefix(path.Base(in.XnvmeVolume.Filename), "ng") {
+		msg := fmt.Sprintf("Filename value (%s) is not an NVMe generic device, io_uring_cmd needs /dev/ngXnY", in.XnvmeVolume.Filename)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteXnvmeVolumeRequest(in *DeleteXnvmeVolumeRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetXnvmeVolumeRequest(in *GetXnvmeVolumeRequest) error {
+	// check required fields
+	if in.Name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backup backs the persistent store of the bridge and a declarative snapshot of its
+// resources up to S3 compatible object storage on a schedule, keeping the latest ones, so the
+// configuration of a failed DPU is provisioned again on its replacement
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Latest fetches the most recent backup
+const Latest = "latest"
+
+// keySuffix ends the keys of the backups, after the time they were taken at
+const keySuffix = ".json"
+
+// keyTimeFormat formats the time of the backups in their keys, so they sort in time order
+const keyTimeFormat = "20060102T150405.000Z"
+
+// Backup represents the state of a bridge at a time
+type Backup struct {
+	// Time the backup was taken at
+	Time time.Time `json:"time"`
+	// Source is the bridge the backup was taken on, its host name
+	Source string `json:"source"`
+	// Store are the raw values of the keys of the persistent store
+	Store map[string][]byte `json:"store"`
+	// Config is the declarative snapshot of the resources, as exported
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// Store is the persistent store of the bridge, dumped and loaded as raw values
+type Store interface {
+	// Dump returns the values of all the keys
+	Dump(ctx context.Context) (map[string][]byte, error)
+	// Load sets the values of keys
+	Load(ctx context.Context, entries map[string][]byte) error
+}
+
+// Agent backs the bridge up to a bucket
+type Agent struct {
+	bucket *Bucket
+	store  Store
+	config func(ctx context.Context) (interface{}, error)
+	source string
+	keep   int
+}
+
+// NewAgent returns an agent backing store and the declarative snapshot config returns up to
+// bucket, keeping the keep latest backups
+func NewAgent(bucket *Bucket, store Store, config func(ctx context.Context) (interface{}, error), source string, keep int) *Agent {
+	return &Agent{bucket: bucket, store: store, config: config, source: source, keep: keep}
+}
+
+// BackUp uploads a backup and deletes the older ones beyond the ones kept, it returns the key
+// of the backup
+func (a *Agent) BackUp(ctx context.Context) (string, error) {
+	backup := &Backup{Time: time.Now().UTC(), Source: a.source}
+	var err error
+	backup.Store, err = a.store.Dump(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not dump the store: %w", err)
+	}
+	if a.config != nil {
+		config, err := a.config(ctx)
+		if err != nil {
+			return "", fmt.Errorf("could not export the resources: %w", err)
+		}
+		backup.Config, err = json.Marshal(config)
+		if err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(backup)
+	if err != nil {
+		return "", err
+	}
+	key := backup.Time.Format(keyTimeFormat) + keySuffix
+	if err := a.bucket.put(ctx, key, data); err != nil {
+		return "", fmt.Errorf("could not upload backup %s: %w", key, err)
+	}
+	slog.InfoContext(ctx, "Backed up", "bucket", a.bucket.String(), "key", key, "keys", len(backup.Store))
+	return key, a.prune(ctx)
+}
+
+// Run backs up every interval until ctx is done
+func (a *Agent) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := a.BackUp(ctx); err != nil {
+			slog.Warn("Could not back up", "bucket", a.bucket.String(), "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fetch downloads a backup of the bucket, the most recent one when key is Latest
+func (a *Agent) Fetch(ctx context.Context, key string) (*Backup, error) {
+	if key == Latest {
+		keys, err := a.keys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no backup in %s", a.bucket)
+		}
+		key = keys[len(keys)-1]
+	}
+	data, err := a.bucket.get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("could not download backup %s: %w", key, err)
+	}
+	backup := new(Backup)
+	if err := json.Unmarshal(data, backup); err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %w", key, err)
+	}
+	return backup, nil
+}
+
+// prune deletes the backups older than the kept ones
+func (a *Agent) prune(ctx context.Context) error {
+	keys, err := a.keys(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(keys) > a.keep {
+		if err := a.bucket.delete(ctx, keys[0]); err != nil {
+			errs = append(errs, fmt.Errorf("could not delete backup %s: %w", keys[0], err))
+		}
+		keys = keys[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// keys returns the keys of the backups of the bucket, oldest first
+func (a *Agent) keys(ctx context.Context) ([]string, error) {
+	objects, err := a.bucket.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, key := range objects {
+		if strings.HasSuffix(key, keySuffix) && !strings.Contains(key, "/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backup backs the persistent store of the bridge and a declarative snapshot of its
+// resources up to S3 compatible object storage on a schedule, keeping the latest ones, so the
+// configuration of a failed DPU is provisioned again on its replacement
+package backup
+
+import (
+	"context"
+
+	"github.com/go-redis/redis"
+)
+
+// scanCount is the number of keys asked per SCAN of the store
+const scanCount = 1000
+
+// RedisStore is the Redis database of the bridge
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore returns the Redis database at address, in host:port format
+func NewRedisStore(address string) *RedisStore {
+	return &RedisStore{client: redis.NewClient(&redis.Options{Addr: address})}
+}
+
+// Dump returns the values of the keys of the database, scanned so the bridge serving meanwhile
+// isn't blocked
+func (s *RedisStore) Dump(ctx context.Context) (map[string][]byte, error) {
+	client := s.client.WithContext(ctx)
+	entries := make(map[string][]byte)
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, "*", scanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			value, err := client.Get(key).Bytes()
+			if err == redis.Nil {
+				// deleted since scanned
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+		}
+		if next == 0 {
+			return entries, nil
+		}
+		cursor = next
+	}
+}
+
+// Load sets the values of keys of the database
+func (s *RedisStore) Load(ctx context.Context, entries map[string][]byte) error {
+	client := s.client.WithContext(ctx)
+	for key, value := range entries {
+		if err := client.Set(key, value, 0).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connections to the database
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
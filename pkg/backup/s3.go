@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package backup backs the persistent store of the bridge and a declarative snapshot of its
+// resources up to S3 compatible object storage on a schedule, keeping the latest ones, so the
+// configuration of a failed DPU is provisioned again on its replacement
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials sign the requests to the object storage
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of the temporary credentials, empty for the long term ones
+	SessionToken string
+}
+
+// Bucket is a prefix of a bucket of an S3 compatible object storage, addressed in the path
+// style most of the on-premises ones require, the requests being signed with AWS Signature V4
+type Bucket struct {
+	endpoint    *url.URL
+	bucket      string
+	prefix      string
+	region      string
+	credentials Credentials
+	client      *http.Client
+}
+
+// NewBucket returns the bucket of a URL, i.e. https://minio:9000/backups/dpu0 for the dpu0/ prefix
+// of the backups bucket
+func NewBucket(bucketURL string, region string, credentials Credentials, client *http.Client) (*Bucket, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, err
+	}
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || bucket == "" {
+		return nil, fmt.Errorf("invalid bucket %s, have to be http(s)://host:port/bucket[/prefix]", bucketURL)
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	endpoint := &url.URL{Scheme: u.Scheme, Host: u.Host}
+	return &Bucket{endpoint: endpoint, bucket: bucket, prefix: prefix, region: region, credentials: credentials, client: client}, nil
+}
+
+// String returns the URL of the bucket
+func (b *Bucket) String() string {
+	return b.endpoint.String() + "/" + b.bucket + "/" + b.prefix
+}
+
+// put uploads an object under the prefix
+func (b *Bucket) put(ctx context.Context, key string, data []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, "/"+b.bucket+"/"+b.prefix+key, nil, data)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// get downloads an object under the prefix
+func (b *Bucket) get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, "/"+b.bucket+"/"+b.prefix+key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return io.ReadAll(resp.Body)
+}
+
+// delete deletes an object under the prefix
+func (b *Bucket) delete(ctx context.Context, key string) error {
+	resp, err := b.do(ctx, http.MethodDelete, "/"+b.bucket+"/"+b.prefix+key, nil, nil)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the response of ListObjectsV2
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns the keys of the objects under the prefix, without it
+func (b *Bucket) list(ctx context.Context) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {b.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := b.do(ctx, http.MethodGet, "/"+b.bucket, query, nil)
+		if err != nil {
+			return nil, err
+		}
+		result := new(listBucketResult)
+		err = xml.NewDecoder(resp.Body).Decode(result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(object.Key, b.prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request, the responses which aren't a success are errors
+func (b *Bucket) do(ctx context.Context, method string, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := *b.endpoint
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	b.sign(req, body, time.Now().UTC())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("object storage answered %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// sign signs a request with AWS Signature V4
+func (b *Bucket) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.credentials.SessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+b.credentials.SecretAccessKey), date)
+	for _, part := range []string{b.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes a query sorted by name, as signed
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes all but the unreserved characters, and the slashes when not encodeSlash
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash):
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
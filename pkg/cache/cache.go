@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package cache answers the repeated queries of the firmware, i.e. the info of the controllers or
+// the lists of the namespaces, from the responses of the last seconds, so the dashboards polling
+// the Get and List methods don't saturate the SPDK socket
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache keeps the responses of the queries of the firmware for a TTL, every other call to the
+// firmware invalidates them as it may change what they report
+type Cache struct {
+	ttl time.Duration
+	now func() time.Time
+	mu  sync.Mutex
+	// generation is incremented by the calls invalidating the cache, the responses of the
+	// queries made across an invalidation aren't kept
+	generation uint64
+	entries    map[string]entry
+	hits       uint64
+	misses     uint64
+}
+
+// entry is a response of the firmware kept in the cache
+type entry struct {
+	result  json.RawMessage
+	expires time.Time
+}
+
+// New creates a cache keeping the responses for ttl, disabled when 0
+func New(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]entry),
+	}
+}
+
+// Cacheable tells whether the responses of a method can be kept, the queries of the info and the
+// lists of the resources, not their stats which change on every call
+func Cacheable(method string) bool {
+	return ReadOnly(method) && (strings.HasSuffix(method, "_info") || strings.HasSuffix(method, "_list"))
+}
+
+// ReadOnly tells whether a method only queries the firmware, the other ones invalidate the cache
+func ReadOnly(method string) bool {
+	return strings.Contains(method, "_get_") || strings.HasPrefix(method, "get_")
+}
+
+var queriesDesc = prometheus.NewDesc(
+	"opi_firmware_cache_queries_total",
+	"Number of queries of the firmware looked up in the cache, by result, hit or miss.",
+	[]string{"result"}, nil,
+)
+
+// Describe implements prometheus.Collector
+func (c *Cache) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queriesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Cache) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	hits, misses := c.hits, c.misses
+	c.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(queriesDesc, prometheus.CounterValue, float64(hits), "hit")
+	ch <- prometheus.MustNewConstMetric(queriesDesc, prometheus.CounterValue, float64(misses), "miss")
+}
+
+// Invalidate drops all the responses kept
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	clear(c.entries)
+}
+
+// lookup returns the response kept for a query and the generation of the cache
+func (c *Cache) lookup(key string) (json.RawMessage, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.entries[key]
+	if ok && c.now().Before(cached.expires) {
+		c.hits++
+		return cached.result, c.generation, true
+	}
+	if ok {
+		delete(c.entries, key)
+	}
+	c.misses++
+	return nil, c.generation, false
+}
+
+// store keeps the response of a query unless the cache was invalidated while it was made
+func (c *Cache) store(key string, generation uint64, result json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.entries[key] = entry{result: result, expires: c.now().Add(c.ttl)}
+}
+
+// WrapJSONRPC answers the queries of a JSON-RPC client from the cache
+func (c *Cache) WrapJSONRPC(rpc spdk.JSONRPC) spdk.JSONRPC {
+	if c.ttl <= 0 {
+		return rpc
+	}
+	return &cachedJSONRPC{JSONRPC: rpc, cache: c}
+}
+
+// cachedJSONRPC implements spdk.JSONRPC, answering the queries from the cache
+type cachedJSONRPC struct {
+	spdk.JSONRPC
+	cache *Cache
+}
+
+// Call implements spdk.JSONRPC
+func (c *cachedJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	if !Cacheable(method) {
+		if !ReadOnly(method) {
+			// the failed calls may have changed the firmware as well
+			defer c.cache.Invalidate()
+		}
+		return c.JSONRPC.Call(ctx, method, args, result)
+	}
+	params, err := json.Marshal(args)
+	if err != nil {
+		return c.JSONRPC.Call(ctx, method, args, result)
+	}
+	key := method + " " + string(params)
+	cached, generation, ok := c.cache.lookup(key)
+	if ok {
+		if err := json.Unmarshal(cached, result); err == nil {
+			return nil
+		}
+	}
+	if err := c.JSONRPC.Call(ctx, method, args, result); err != nil {
+		return err
+	}
+	// the failures reported in the status aren't kept, the next call retries
+	if models.ResultStatus(result) != 0 {
+		return nil
+	}
+	response, err := json.Marshal(result)
+	if err != nil {
+		slog.DebugContext(ctx, "Could not cache the response of the firmware", "method", method, "error", err)
+		return nil
+	}
+	c.cache.store(key, generation, response)
+	return nil
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package cache answers the repeated queries of the firmware, i.e. the info of the controllers or
+// the lists of the namespaces, from the responses of the last seconds, so the dashboards polling
+// the Get and List methods don't saturate the SPDK socket
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+// testJSONRPC is a firmware answering the number of its calls as the controller ID, it fails the
+// calls when status is set
+type testJSONRPC struct {
+	spdk.JSONRPC
+	calls  int
+	status models.Status
+}
+
+func (c *testJSONRPC) Call(_ context.Context, _ string, _, result interface{}) error {
+	c.calls++
+	if info, ok := result.(*models.MrvlNvmGetCtrlrInfoResult); ok {
+		info.Status = c.status
+		info.CtrlrID = c.calls
+	}
+	return nil
+}
+
+func TestCache_WrapJSONRPC(t *testing.T) {
+	tests := map[string]struct {
+		methods  []string
+		status   models.Status
+		expired  bool
+		firmware int
+		ctrlrID  int
+	}{
+		"cached": {
+			methods:  []string{"mrvl_nvm_ctrlr_get_info", "mrvl_nvm_ctrlr_get_info"},
+			firmware: 1,
+			ctrlrID:  1,
+		},
+		"expired": {
+			methods:  []string{"mrvl_nvm_ctrlr_get_info", "mrvl_nvm_ctrlr_get_info"},
+			expired:  true,
+			firmware: 2,
+			ctrlrID:  2,
+		},
+		"invalidated": {
+			methods:  []string{"mrvl_nvm_ctrlr_get_info", "mrvl_nvm_subsys_create_ctrlr", "mrvl_nvm_ctrlr_get_info"},
+			firmware: 3,
+			ctrlrID:  3,
+		},
+		"stats not invalidating": {
+			methods:  []string{"mrvl_nvm_ctrlr_get_info", "mrvl_nvm_get_ctrlr_stats", "mrvl_nvm_ctrlr_get_info"},
+			firmware: 2,
+			ctrlrID:  1,
+		},
+		"failed status": {
+			methods:  []string{"mrvl_nvm_ctrlr_get_info", "mrvl_nvm_ctrlr_get_info"},
+			status:   -1,
+			firmware: 2,
+			ctrlrID:  2,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			cache := New(time.Second)
+			cache.now = func() time.Time { return now }
+			firmware := &testJSONRPC{status: tt.status}
+			rpc := cache.WrapJSONRPC(firmware)
+			var info models.MrvlNvmGetCtrlrInfoResult
+			for _, method := range tt.methods {
+				if tt.expired {
+					now = now.Add(2 * time.Second)
+				}
+				info = models.MrvlNvmGetCtrlrInfoResult{}
+				params := models.MrvlNvmGetCtrlrInfoParams{Subnqn: "nqn.2022-09.io.spdk:opi3", CtrlrID: 17}
+				if err := rpc.Call(context.Background(), method, &params, &info); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if firmware.calls != tt.firmware {
+				t.Error("firmware calls: expected", tt.firmware, "received", firmware.calls)
+			}
+			if info.CtrlrID != tt.ctrlrID {
+				t.Error("controller ID: expected", tt.ctrlrID, "received", info.CtrlrID)
+			}
+		})
+	}
+}
+
+func TestCache_Methods(t *testing.T) {
+	tests := map[string]struct {
+		cacheable bool
+		readOnly  bool
+	}{
+		"mrvl_nvm_ctrlr_get_info":     {cacheable: true, readOnly: true},
+		"mrvl_nvm_subsys_get_ns_list": {cacheable: true, readOnly: true},
+		"mrvl_nvm_get_subsys_list":    {cacheable: true, readOnly: true},
+		"mrvl_nvm_get_ctrlr_stats":    {cacheable: false, readOnly: true},
+		"spdk_get_version":            {cacheable: false, readOnly: true},
+		"mrvl_nvm_ctrlr_attach_ns":    {cacheable: false, readOnly: false},
+		"bdev_malloc_create":          {cacheable: false, readOnly: false},
+	}
+
+	// run tests
+	for method, tt := range tests {
+		t.Run(method, func(t *testing.T) {
+			if Cacheable(method) != tt.cacheable {
+				t.Error("cacheable: expected", tt.cacheable, "received", Cacheable(method))
+			}
+			if ReadOnly(method) != tt.readOnly {
+				t.Error("read-only: expected", tt.readOnly, "received", ReadOnly(method))
+			}
+		})
+	}
+}
+
+func TestCache_Disabled(t *testing.T) {
+	firmware := &testJSONRPC{}
+	if rpc := New(0).WrapJSONRPC(firmware); rpc != firmware {
+		t.Error("disabled: expected the firmware as is")
+	}
+}
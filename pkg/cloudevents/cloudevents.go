@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package cloudevents publishes the lifecycle and the failure events of the resources as
+// CloudEvents to HTTP endpoints, NATS subjects or Kafka topics, so the inventory systems follow
+// the bridges without polling them
+package cloudevents
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/events"
+)
+
+// SpecVersion is the version of the CloudEvents specification of the events
+const SpecVersion = "1.0"
+
+// ContentType is the media type of the events in the structured mode
+const ContentType = "application/cloudevents+json"
+
+// TypePrefix prefixes the types of the events of the history, i.e.
+// org.opiproject.storage.resource.created
+const TypePrefix = "org.opiproject.storage.resource."
+
+// maxPendingEvents bounds the events waiting to be published, the newer ones are dropped
+const maxPendingEvents = 1024
+
+// maxAttempts is the number of times an event is sent to a sink before it is dropped
+const maxAttempts = 3
+
+// retryDelay is the delay before sending an event again, doubled on each attempt
+var retryDelay = time.Second
+
+// Event represents a CloudEvent in its JSON format
+type Event struct {
+	SpecVersion     string        `json:"specversion"`
+	ID              string        `json:"id"`
+	Source          string        `json:"source"`
+	Type            string        `json:"type"`
+	Subject         string        `json:"subject,omitempty"`
+	Time            time.Time     `json:"time"`
+	DataContentType string        `json:"datacontenttype,omitempty"`
+	Data            *events.Event `json:"data,omitempty"`
+}
+
+// Sink sends the events to a message broker or an endpoint
+type Sink interface {
+	// Send sends an event, it is sent again when failed
+	Send(ctx context.Context, event *Event) error
+	// String describes the sink in the logs
+	String() string
+}
+
+// NewSink returns the sink of a URL, http and https URLs are posted to, nats://host:port/subject
+// publishes to a NATS subject and kafka://host:port/topic produces to a Kafka topic, host:port
+// being a bootstrap broker of the cluster
+func NewSink(sinkURL string, client *http.Client) (Sink, error) {
+	u, err := url.Parse(sinkURL)
+	if err != nil {
+		return nil, err
+	}
+	topic := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case "http", "https":
+		return newHTTPSink(sinkURL, client), nil
+	case "nats":
+		if u.Host == "" || topic == "" {
+			return nil, fmt.Errorf("invalid NATS sink %s, have to be nats://host:port/subject", sinkURL)
+		}
+		return newNATSSink(u.Host, topic, u.User), nil
+	case "kafka":
+		if u.Host == "" || topic == "" {
+			return nil, fmt.Errorf("invalid Kafka sink %s, have to be kafka://host:port/topic", sinkURL)
+		}
+		return newKafkaSink(u.Host, topic), nil
+	default:
+		return nil, fmt.Errorf("invalid sink %s, have to be an http, https, nats or kafka URL", sinkURL)
+	}
+}
+
+// Publisher publishes the events to the sinks in the background, the recording of the events is
+// not held up by slow or unreachable sinks
+type Publisher struct {
+	source  string
+	sinks   []Sink
+	pending chan *Event
+}
+
+// NewPublisher returns a Publisher of the events of source to sinks
+func NewPublisher(source string, sinks []Sink) *Publisher {
+	return &Publisher{
+		source:  source,
+		sinks:   sinks,
+		pending: make(chan *Event, maxPendingEvents),
+	}
+}
+
+// Publish queues an event of the history, it is dropped when too many events are pending
+func (p *Publisher) Publish(event *events.Event) {
+	cloudEvent := &Event{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.New().String(),
+		Source:          p.source,
+		Type:            TypePrefix + strings.ToLower(event.Type),
+		Subject:         event.Name,
+		Time:            event.Time,
+		DataContentType: "application/json",
+		Data:            event,
+	}
+	select {
+	case p.pending <- cloudEvent:
+	default:
+		slog.Warn("Dropping CloudEvent, too many events pending", "type", cloudEvent.Type, "subject", cloudEvent.Subject)
+	}
+}
+
+// Run sends the queued events to the sinks until ctx is done
+func (p *Publisher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-p.pending:
+			for _, sink := range p.sinks {
+				p.send(ctx, sink, event)
+			}
+		}
+	}
+}
+
+// send sends an event to a sink, retrying the failed attempts
+func (p *Publisher) send(ctx context.Context, sink Sink, event *Event) {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err := sink.Send(ctx, event)
+		if err == nil {
+			return
+		}
+		if attempt == maxAttempts {
+			slog.Error("Could not publish CloudEvent", "sink", sink.String(), "type", event.Type, "subject", event.Subject, "error", err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
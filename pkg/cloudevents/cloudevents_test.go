@@ -0,0 +1,388 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package cloudevents publishes the lifecycle and the failure events of the resources as
+// CloudEvents to HTTP endpoints, NATS subjects or Kafka topics, so the inventory systems follow
+// the bridges without polling them
+package cloudevents
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/events"
+)
+
+const testVolumeName = "//storage.opiproject.org/volumes/nvmetcp12"
+
+// testEvent is the event of the history published in the tests
+var testEvent = &events.Event{
+	Time:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	Name:    testVolumeName,
+	Type:    events.TypePathDown,
+	Message: "Connection lost",
+}
+
+// checkEvent checks the CloudEvent of testEvent
+func checkEvent(t *testing.T, event *Event) {
+	t.Helper()
+	if event.SpecVersion != SpecVersion || event.ID == "" || event.Source != "urn:opi-marvell-bridge:dpu0" ||
+		event.Type != "org.opiproject.storage.resource.path_down" || event.Subject != testVolumeName ||
+		!event.Time.Equal(testEvent.Time) || event.Data == nil || event.Data.Message != testEvent.Message {
+		t.Error("event: expected the path down of", testVolumeName, "received", event)
+	}
+}
+
+func TestCloudEvents_HTTP(t *testing.T) {
+	retryDelay = time.Millisecond
+	tests := map[string]struct {
+		statuses []int
+		attempts int
+	}{
+		"delivered": {
+			statuses: []int{http.StatusAccepted},
+			attempts: 1,
+		},
+		"delivered on retry": {
+			statuses: []int{http.StatusBadGateway, http.StatusOK},
+			attempts: 2,
+		},
+		"dropped after the retries": {
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			attempts: maxAttempts,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			received := make(chan *Event, len(tt.statuses))
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != ContentType {
+					t.Error("request: expected a CloudEvents POST, received", r.Method, r.Header.Get("Content-Type"))
+				}
+				event := new(Event)
+				if err := json.NewDecoder(r.Body).Decode(event); err != nil {
+					t.Error(err)
+				}
+				w.WriteHeader(tt.statuses[attempts])
+				attempts++
+				received <- event
+			}))
+			defer server.Close()
+
+			sink, err := NewSink(server.URL, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			publisher := NewPublisher("urn:opi-marvell-bridge:dpu0", []Sink{sink})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go publisher.Run(ctx)
+			publisher.Publish(testEvent)
+
+			for i := 0; i < tt.attempts; i++ {
+				select {
+				case event := <-received:
+					checkEvent(t, event)
+				case <-time.After(5 * time.Second):
+					t.Fatal("attempts: expected", tt.attempts, "received", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCloudEvents_NewSink(t *testing.T) {
+	tests := map[string]struct {
+		url      string
+		expected string
+		errMsg   string
+	}{
+		"http": {
+			url:      "https://inventory.example.com/events",
+			expected: "https://inventory.example.com/events",
+		},
+		"nats": {
+			url:      "nats://nats.example.com:4222/opi.storage",
+			expected: "nats://nats.example.com:4222/opi.storage",
+		},
+		"kafka": {
+			url:      "kafka://kafka0:9092/opi-storage",
+			expected: "kafka://kafka0:9092/opi-storage",
+		},
+		"nats without subject": {
+			url:    "nats://nats.example.com:4222",
+			errMsg: "invalid NATS sink nats://nats.example.com:4222, have to be nats://host:port/subject",
+		},
+		"kafka without topic": {
+			url:    "kafka://kafka0:9092/",
+			errMsg: "invalid Kafka sink kafka://kafka0:9092/, have to be kafka://host:port/topic",
+		},
+		"unknown scheme": {
+			url:    "amqp://rabbit/events",
+			errMsg: "invalid sink amqp://rabbit/events, have to be an http, https, nats or kafka URL",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sink, err := NewSink(tt.url, nil)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sink.String() != tt.expected {
+				t.Error("sink: expected", tt.expected, "received", sink.String())
+			}
+		})
+	}
+}
+
+func TestCloudEvents_NATS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	received := make(chan *Event, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		reader := bufio.NewReader(conn)
+		connect, _ := reader.ReadString('\n')
+		if !strings.HasPrefix(connect, "CONNECT ") || !strings.Contains(connect, "\"user\":\"opi\"") {
+			t.Error("connect: expected the options with the user, received", connect)
+		}
+		pub, _ := reader.ReadString('\n')
+		fields := strings.Fields(pub)
+		if len(fields) != 3 || fields[0] != "PUB" || fields[1] != "opi.storage" {
+			t.Error("publish: expected PUB opi.storage, received", pub)
+			return
+		}
+		size, _ := strconv.Atoi(fields[2])
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			t.Error(err)
+			return
+		}
+		if ping, _ := reader.ReadString('\n'); ping != "PING\r\n" {
+			t.Error("ping: expected PING, received", ping)
+		}
+		_, _ = conn.Write([]byte("PONG\r\n"))
+		event := new(Event)
+		if err := json.Unmarshal(data[:size], event); err != nil {
+			t.Error(err)
+		}
+		received <- event
+	}()
+
+	sink, err := NewSink("nats://opi:secret@"+listener.Addr().String()+"/opi.storage", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := NewPublisher("urn:opi-marvell-bridge:dpu0", []Sink{sink})
+	publisher.Publish(testEvent)
+	if err := sink.Send(context.Background(), <-publisher.pending); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-received:
+		checkEvent(t, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event: expected to be published")
+	}
+}
+
+// testKafkaRecord is a record produced to the test broker
+type testKafkaRecord struct {
+	partition   int32
+	key         string
+	contentType string
+	event       *Event
+}
+
+// serveKafka serves the metadata of a topic of two partitions led by the broker itself, and
+// decodes the produced records
+func serveKafka(t *testing.T, conn net.Conn, topic string, produced chan<- *testKafkaRecord) {
+	defer func() { _ = conn.Close() }()
+	host, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	brokerPort, _ := strconv.Atoi(port)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+		r := &kafkaReader{data: request}
+		apiKey, apiVersion, correlationID, clientID := r.int16(), r.int16(), r.int32(), r.string()
+		if clientID != kafkaClientID {
+			t.Error("client ID: expected", kafkaClientID, "received", clientID)
+		}
+		w := &kafkaWriter{}
+		w.int32(correlationID)
+		switch {
+		case apiKey == kafkaMetadataKey && apiVersion == kafkaMetadataVersion:
+			if count, name := r.int32(), r.string(); count != 1 || name != topic {
+				t.Error("metadata: expected the topic", topic, "received", count, name)
+			}
+			w.int32(1)
+			w.int32(1)
+			w.string(host)
+			w.int32(int32(brokerPort))
+			w.int32(1)
+			w.int16(0)
+			w.string(topic)
+			w.int32(2)
+			for partition := int32(0); partition < 2; partition++ {
+				w.int16(0)
+				w.int32(partition)
+				w.int32(1)
+				w.int32(1)
+				w.int32(1)
+				w.int32(1)
+				w.int32(1)
+			}
+		case apiKey == kafkaProduceKey && apiVersion == kafkaProduceVersion:
+			record := decodeProduce(t, r, topic)
+			w.int32(1)
+			w.string(topic)
+			w.int32(1)
+			w.int32(record.partition)
+			w.int16(0)
+			w.int64(0)
+			w.int64(-1)
+			w.int32(0)
+			produced <- record
+		default:
+			t.Error("request: unexpected API key", apiKey, "version", apiVersion)
+			return
+		}
+		response := binary.BigEndian.AppendUint32(nil, uint32(w.buf.Len()))
+		if _, err := conn.Write(append(response, w.buf.Bytes()...)); err != nil {
+			return
+		}
+	}
+}
+
+// decodeProduce decodes the record of a produce request, checking the CRC of its batch
+func decodeProduce(t *testing.T, r *kafkaReader, topic string) *testKafkaRecord {
+	t.Helper()
+	record := &testKafkaRecord{}
+	if transactionalID, acks := r.int16(), r.int16(); transactionalID != -1 || acks != 1 {
+		t.Error("produce: expected no transaction and acks 1, received", transactionalID, acks)
+	}
+	r.int32()
+	if count, name := r.int32(), r.string(); count != 1 || name != topic {
+		t.Error("produce: expected the topic", topic, "received", count, name)
+	}
+	r.int32()
+	record.partition = r.int32()
+	batch := &kafkaReader{data: r.next(int(r.int32()))}
+	batch.int64()
+	length := batch.int32()
+	batch.int32()
+	if magic := batch.int8(); magic != 2 {
+		t.Error("magic: expected 2, received", magic)
+	}
+	crc := uint32(batch.int32())
+	if int(length) != 9+len(batch.data) || crc != crc32.Checksum(batch.data, castagnoli) {
+		t.Error("batch: expected a valid length and CRC, received", length, crc)
+	}
+	batch.int16()
+	batch.int32()
+	batch.int64()
+	batch.int64()
+	batch.int64()
+	batch.int16()
+	batch.int32()
+	if count := batch.int32(); count != 1 {
+		t.Error("records: expected 1, received", count)
+	}
+	batch.varint()
+	batch.int8()
+	batch.varint()
+	batch.varint()
+	record.key = string(batch.next(int(batch.varint())))
+	value := batch.next(int(batch.varint()))
+	if headers := batch.varint(); headers != 1 {
+		t.Error("headers: expected 1, received", headers)
+	}
+	if header := string(batch.next(int(batch.varint()))); header != "content-type" {
+		t.Error("header: expected content-type, received", header)
+	}
+	record.contentType = string(batch.next(int(batch.varint())))
+	if batch.err != nil || r.err != nil {
+		t.Error("produce: expected a valid request, received", batch.err, r.err)
+	}
+	record.event = new(Event)
+	if err := json.Unmarshal(value, record.event); err != nil {
+		t.Error(err)
+	}
+	return record
+}
+
+func TestCloudEvents_Kafka(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	produced := make(chan *testKafkaRecord, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveKafka(t, conn, "opi-storage", produced)
+		}
+	}()
+
+	sink, err := NewSink("kafka://"+listener.Addr().String()+"/opi-storage", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := NewPublisher("urn:opi-marvell-bridge:dpu0", []Sink{sink})
+	expectedPartition := int32(crc32.ChecksumIEEE([]byte(testVolumeName)) % 2)
+	for i := 0; i < 2; i++ {
+		publisher.Publish(testEvent)
+		if err := sink.Send(context.Background(), <-publisher.pending); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case record := <-produced:
+			if record.partition != expectedPartition || record.key != testVolumeName || record.contentType != ContentType {
+				t.Error("record: expected partition", expectedPartition, "keyed by", testVolumeName, "received", record.partition, record.key, record.contentType)
+			}
+			checkEvent(t, record.event)
+		case <-time.After(5 * time.Second):
+			t.Fatal("event: expected to be produced")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package cloudevents publishes the lifecycle and the failure events of the resources as
+// CloudEvents to HTTP endpoints, NATS subjects or Kafka topics, so the inventory systems follow
+// the bridges without polling them
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// httpSink posts the events in the structured mode of the HTTP binding
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+// newHTTPSink returns a sink posting to url
+func newHTTPSink(url string, client *http.Client) *httpSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpSink{url: url, client: client}
+}
+
+// Send posts an event
+func (s *httpSink) Send(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sink answered %s", resp.Status)
+	}
+	return nil
+}
+
+// String returns the URL of the sink
+func (s *httpSink) String() string {
+	return s.url
+}
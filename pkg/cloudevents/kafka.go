@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package cloudevents publishes the lifecycle and the failure events of the resources as
+// CloudEvents to HTTP endpoints, NATS subjects or Kafka topics, so the inventory systems follow
+// the bridges without polling them
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// API keys and versions of the Kafka requests
+const (
+	kafkaProduceKey      = 0
+	kafkaProduceVersion  = 3
+	kafkaMetadataKey     = 3
+	kafkaMetadataVersion = 0
+)
+
+// kafkaClientID identifies the bridge to the brokers
+const kafkaClientID = "opi-marvell-bridge"
+
+// kafkaMaxResponseSize bounds the responses of the brokers
+const kafkaMaxResponseSize = 16 << 20
+
+// castagnoli is the CRC of the record batches
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaSink produces the events in the structured mode to a Kafka topic, keyed by their
+// subject so the events of a resource stay in order. The leaders of the partitions are asked
+// again after a failure, to the bootstrap broker or to the brokers of the cluster it returned
+type kafkaSink struct {
+	mu            sync.Mutex
+	bootstrap     string
+	brokers       []string
+	topic         string
+	leaders       map[int32]string
+	conns         map[string]net.Conn
+	correlationID int32
+}
+
+// newKafkaSink returns a sink producing to topic, the cluster is discovered from the bootstrap
+// broker
+func newKafkaSink(bootstrap string, topic string) *kafkaSink {
+	return &kafkaSink{bootstrap: bootstrap, brokers: []string{bootstrap}, topic: topic, conns: make(map[string]net.Conn)}
+}
+
+// Send produces an event, it is sent once the leader of its partition acknowledged it
+func (s *kafkaSink) Send(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.leaders) == 0 {
+		if err := s.refreshLeaders(ctx); err != nil {
+			return err
+		}
+	}
+	partitions := make([]int32, 0, len(s.leaders))
+	for partition := range s.leaders {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	partition := partitions[crc32.ChecksumIEEE([]byte(event.Subject))%uint32(len(partitions))]
+	leader := s.leaders[partition]
+	if err := s.produce(ctx, leader, partition, []byte(event.Subject), data, event.Time); err != nil {
+		s.leaders = nil
+		return err
+	}
+	return nil
+}
+
+// refreshLeaders asks the brokers the leaders of the partitions of the topic
+func (s *kafkaSink) refreshLeaders(ctx context.Context) error {
+	var errs []error
+	for _, broker := range s.brokers {
+		w := &kafkaWriter{}
+		w.int32(1)
+		w.string(s.topic)
+		r, err := s.call(ctx, broker, kafkaMetadataKey, kafkaMetadataVersion, w.buf.Bytes())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		addresses := make(map[int32]string)
+		for i := r.int32(); i > 0 && r.err == nil; i-- {
+			nodeID, host, port := r.int32(), r.string(), r.int32()
+			addresses[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+		}
+		leaders := make(map[int32]string)
+		for i := r.int32(); i > 0 && r.err == nil; i-- {
+			topicError, name := r.int16(), r.string()
+			if topicError != 0 && name == s.topic {
+				errs = append(errs, fmt.Errorf("kafka topic %s: error %d", s.topic, topicError))
+			}
+			for j := r.int32(); j > 0 && r.err == nil; j-- {
+				partitionError, partition, leader := r.int16(), r.int32(), r.int32()
+				r.int32s()
+				r.int32s()
+				if name == s.topic && partitionError == 0 && addresses[leader] != "" {
+					leaders[partition] = addresses[leader]
+				}
+			}
+		}
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		if len(leaders) != 0 {
+			s.leaders = leaders
+			s.brokers = []string{s.bootstrap}
+			for _, address := range addresses {
+				if address != s.bootstrap {
+					s.brokers = append(s.brokers, address)
+				}
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no leader for the partitions of kafka topic %s: %w", s.topic, errors.Join(errs...))
+}
+
+// produce produces a record with key and value to a partition of the topic
+func (s *kafkaSink) produce(ctx context.Context, leader string, partition int32, key []byte, value []byte, timestamp time.Time) error {
+	batch := recordBatch(key, value, timestamp)
+	w := &kafkaWriter{}
+	// no transactional ID, the leader acknowledges, 10s timeout
+	w.int16(-1)
+	w.int16(1)
+	w.int32(int32(brokerTimeout / time.Millisecond))
+	w.int32(1)
+	w.string(s.topic)
+	w.int32(1)
+	w.int32(partition)
+	w.int32(int32(len(batch)))
+	w.buf.Write(batch)
+	r, err := s.call(ctx, leader, kafkaProduceKey, kafkaProduceVersion, w.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	for i := r.int32(); i > 0 && r.err == nil; i-- {
+		r.string()
+		for j := r.int32(); j > 0 && r.err == nil; j-- {
+			r.int32()
+			errorCode := r.int16()
+			r.int64()
+			r.int64()
+			if errorCode != 0 && r.err == nil {
+				return fmt.Errorf("kafka topic %s partition %d: error %d", s.topic, partition, errorCode)
+			}
+		}
+	}
+	return r.err
+}
+
+// call sends a request to a broker and returns the reader of its response, the connection
+// to the broker is closed after a failure
+func (s *kafkaSink) call(ctx context.Context, broker string, apiKey int16, apiVersion int16, body []byte) (*kafkaReader, error) {
+	conn, ok := s.conns[broker]
+	if !ok {
+		dialer := &net.Dialer{Timeout: brokerTimeout}
+		var err error
+		if conn, err = dialer.DialContext(ctx, "tcp", broker); err != nil {
+			return nil, err
+		}
+		s.conns[broker] = conn
+	}
+	r, err := s.exchange(conn, apiKey, apiVersion, body)
+	if err != nil {
+		_ = conn.Close()
+		delete(s.conns, broker)
+	}
+	return r, err
+}
+
+// exchange writes a request on a connection and reads its response
+func (s *kafkaSink) exchange(conn net.Conn, apiKey int16, apiVersion int16, body []byte) (*kafkaReader, error) {
+	s.correlationID++
+	w := &kafkaWriter{}
+	w.int16(apiKey)
+	w.int16(apiVersion)
+	w.int32(s.correlationID)
+	w.string(kafkaClientID)
+	w.buf.Write(body)
+	request := binary.BigEndian.AppendUint32(nil, uint32(w.buf.Len()))
+	request = append(request, w.buf.Bytes()...)
+	_ = conn.SetDeadline(time.Now().Add(brokerTimeout + time.Second))
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length < 4 || length > kafkaMaxResponseSize {
+		return nil, fmt.Errorf("invalid kafka response size %d", length)
+	}
+	response := make([]byte, length)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	r := &kafkaReader{data: response}
+	if correlationID := r.int32(); correlationID != s.correlationID {
+		return nil, fmt.Errorf("kafka response to request %d, expected %d", correlationID, s.correlationID)
+	}
+	return r, nil
+}
+
+// String returns the bootstrap broker and the topic of the sink
+func (s *kafkaSink) String() string {
+	return "kafka://" + s.bootstrap + "/" + s.topic
+}
+
+// recordBatch returns a record batch, magic 2, of a record with key and value, and the
+// content type header of the structured mode
+func recordBatch(key []byte, value []byte, timestamp time.Time) []byte {
+	record := &kafkaWriter{}
+	// attributes, timestamp delta, offset delta
+	record.int8(0)
+	record.varint(0)
+	record.varint(0)
+	record.varint(int64(len(key)))
+	record.buf.Write(key)
+	record.varint(int64(len(value)))
+	record.buf.Write(value)
+	record.varint(1)
+	record.varint(int64(len("content-type")))
+	record.buf.WriteString("content-type")
+	record.varint(int64(len(ContentType)))
+	record.buf.WriteString(ContentType)
+
+	// from the attributes to the end, covered by the CRC
+	crced := &kafkaWriter{}
+	crced.int16(0)
+	crced.int32(0)
+	crced.int64(timestamp.UnixMilli())
+	crced.int64(timestamp.UnixMilli())
+	// no producer ID, epoch nor sequence, one record
+	crced.int64(-1)
+	crced.int16(-1)
+	crced.int32(-1)
+	crced.int32(1)
+	crced.varint(int64(record.buf.Len()))
+	crced.buf.Write(record.buf.Bytes())
+
+	batch := &kafkaWriter{}
+	batch.int64(0)
+	// partition leader epoch, magic and CRC precede the CRCed bytes
+	batch.int32(int32(4 + 1 + 4 + crced.buf.Len()))
+	batch.int32(-1)
+	batch.int8(2)
+	batch.int32(int32(crc32.Checksum(crced.buf.Bytes(), castagnoli)))
+	batch.buf.Write(crced.buf.Bytes())
+	return batch.buf.Bytes()
+}
+
+// kafkaWriter encodes the fields of the Kafka protocol, big endian
+type kafkaWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *kafkaWriter) int8(v int8) {
+	w.buf.WriteByte(byte(v))
+}
+
+func (w *kafkaWriter) int16(v int16) {
+	w.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(v)))
+}
+
+func (w *kafkaWriter) int32(v int32) {
+	w.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(v)))
+}
+
+func (w *kafkaWriter) int64(v int64) {
+	w.buf.Write(binary.BigEndian.AppendUint64(nil, uint64(v)))
+}
+
+// varint writes a zig-zag encoded variable length integer, as in the records
+func (w *kafkaWriter) varint(v int64) {
+	w.buf.Write(binary.AppendVarint(nil, v))
+}
+
+func (w *kafkaWriter) string(v string) {
+	w.int16(int16(len(v)))
+	w.buf.WriteString(v)
+}
+
+// kafkaReader decodes the fields of the Kafka protocol, the first error sticks and the
+// following fields are zero
+type kafkaReader struct {
+	data []byte
+	err  error
+}
+
+// next returns the next n bytes
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return make([]byte, max(n, 0))
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kafkaReader) int8() int8 {
+	return int8(r.next(1)[0])
+}
+
+func (r *kafkaReader) int16() int16 {
+	return int16(binary.BigEndian.Uint16(r.next(2)))
+}
+
+func (r *kafkaReader) int32() int32 {
+	return int32(binary.BigEndian.Uint32(r.next(4)))
+}
+
+func (r *kafkaReader) int64() int64 {
+	return int64(binary.BigEndian.Uint64(r.next(8)))
+}
+
+// varint reads a zig-zag encoded variable length integer
+func (r *kafkaReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// string reads a string, empty when null
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+// int32s reads an array of int32
+func (r *kafkaReader) int32s() []int32 {
+	var values []int32
+	for i := r.int32(); i > 0 && r.err == nil; i-- {
+		values = append(values, r.int32())
+	}
+	return values
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package cloudevents publishes the lifecycle and the failure events of the resources as
+// CloudEvents to HTTP endpoints, NATS subjects or Kafka topics, so the inventory systems follow
+// the bridges without polling them
+package cloudevents
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// brokerTimeout bounds the exchanges with the brokers
+const brokerTimeout = 10 * time.Second
+
+// natsSink publishes the events in the structured mode to a NATS subject, the connection is
+// made again after a failure
+type natsSink struct {
+	mu      sync.Mutex
+	address string
+	subject string
+	user    *url.Userinfo
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+// natsConnect are the options of the CONNECT message of the NATS protocol
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+}
+
+// newNATSSink returns a sink publishing to subject on the server at address
+func newNATSSink(address string, subject string, user *url.Userinfo) *natsSink {
+	return &natsSink{address: address, subject: subject, user: user}
+}
+
+// Send publishes an event, it is sent once the server answered the PING following it
+func (s *natsSink) Send(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+	_ = s.conn.SetDeadline(time.Now().Add(brokerTimeout))
+	_, err = fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\nPING\r\n", s.subject, len(data), data)
+	if err == nil {
+		err = s.waitPong()
+	}
+	if err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// connect connects to the server, reads its INFO and sends the CONNECT options
+func (s *natsSink) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: brokerTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(brokerTimeout))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		_ = conn.Close()
+		return fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(line))
+	}
+	options := natsConnect{Name: "opi-marvell-bridge"}
+	if s.user != nil {
+		options.User = s.user.Username()
+		options.Pass, _ = s.user.Password()
+	}
+	data, err := json.Marshal(&options)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", data); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	s.conn, s.reader = conn, reader
+	return nil
+}
+
+// waitPong reads the messages of the server until its PONG, answering its PINGs
+func (s *natsSink) waitPong() error {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := s.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// String returns the server and the subject of the sink
+func (s *natsSink) String() string {
+	return "nats://" + s.address + "/" + s.subject
+}
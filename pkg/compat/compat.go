@@ -0,0 +1,324 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package compat adapts the calls of the bridge to the Marvell SDK release of the firmware, some
+// releases renaming or re-shaping mrvl_nvm_* methods: the release is detected once and the shims
+// of the methods it renamed are applied to their calls, instead of failing with Method not found
+package compat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// detectMethod reports the SDK release of the firmware
+const detectMethod = "mrvl_platform_get_inventory"
+
+// Shim adapts a method the bridge calls to the firmware of a range of SDK releases
+type Shim struct {
+	// Method is the name the bridge calls the method by, i.e. mrvl_nvm_ctrlr_get_stats
+	Method string `yaml:"method"`
+	// MinSdk is the first SDK release of the shim, unbounded when empty
+	MinSdk string `yaml:"min_sdk"`
+	// MaxSdk is the last SDK release of the shim, unbounded when empty
+	MaxSdk string `yaml:"max_sdk"`
+	// Skus are the patterns of the SKUs of the shim, i.e. CN106*, any SKU when empty
+	Skus []string `yaml:"skus"`
+	// Rename is the name of the method in these releases, unchanged when empty
+	Rename string `yaml:"rename"`
+	// Params renames the parameters, from the name of the bridge to the one of these releases,
+	// a dotted name is a field of an object, i.e. ctrlr_id: ctrlr.id
+	Params map[string]string `yaml:"params"`
+	// Result renames the fields of the result, from the name of these releases to the one of
+	// the bridge
+	Result map[string]string `yaml:"result"`
+}
+
+// shimsFile is the YAML file of the shims
+type shimsFile struct {
+	Shims []Shim `yaml:"shims"`
+}
+
+// LoadShims reads the shims of a YAML file, a list of them under shims
+func LoadShims(file string) ([]Shim, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var content shimsFile
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("invalid firmware shims %s: %w", file, err)
+	}
+	for i, shim := range content.Shims {
+		if shim.Method == "" {
+			return nil, fmt.Errorf("invalid firmware shim %d, the method has to be set", i+1)
+		}
+		if shim.Rename == "" && len(shim.Params) == 0 && len(shim.Result) == 0 {
+			return nil, fmt.Errorf("invalid firmware shim of %s, a rename, params or result has to be set", shim.Method)
+		}
+		if err := shim.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return content.Shims, nil
+}
+
+// Validate checks the range of SDK releases and the SKU patterns of a shim
+func (s *Shim) Validate() error {
+	if s.MinSdk != "" && s.MaxSdk != "" && compareVersions(s.MinSdk, s.MaxSdk) > 0 {
+		return fmt.Errorf("invalid firmware shim of %s, min_sdk %s is after max_sdk %s", s.Method, s.MinSdk, s.MaxSdk)
+	}
+	for _, pattern := range s.Skus {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid firmware shim of %s, bad SKU pattern %q", s.Method, pattern)
+		}
+	}
+	return nil
+}
+
+// applies tells whether the shim applies to an SDK release of a SKU
+func (s *Shim) applies(sdk string, sku string) bool {
+	if s.MinSdk != "" && compareVersions(sdk, s.MinSdk) < 0 || s.MaxSdk != "" && compareVersions(sdk, s.MaxSdk) > 0 {
+		return false
+	}
+	return len(s.Skus) == 0 || MatchSku(s.Skus, sku)
+}
+
+// MatchSku tells whether a SKU matches one of the patterns, i.e. CN106*, case insensitive
+func MatchSku(patterns []string, sku string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(sku)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONRPC implements spdk.JSONRPC, applying the shims of the SDK release of the firmware
+type JSONRPC struct {
+	spdk.JSONRPC
+	// shims are by method
+	shims map[string][]Shim
+	mu    sync.Mutex
+	// sdk and sku are the SDK release and the SKU of the firmware, valid once detected
+	sdk      string
+	sku      string
+	detected bool
+}
+
+// build time check that struct implements interface
+var _ spdk.JSONRPC = (*JSONRPC)(nil)
+
+// WrapJSONRPC applies shims to the calls of a JSON-RPC client
+func WrapJSONRPC(rpc spdk.JSONRPC, shims []Shim) *JSONRPC {
+	c := &JSONRPC{JSONRPC: rpc, shims: make(map[string][]Shim)}
+	for _, shim := range shims {
+		c.shims[shim.Method] = append(c.shims[shim.Method], shim)
+	}
+	return c
+}
+
+// SdkVersion returns the SDK release of the firmware, it is detected on the first call and
+// empty as long as the firmware doesn't answer
+func (c *JSONRPC) SdkVersion(ctx context.Context) string {
+	sdk, _ := c.detect(ctx)
+	return sdk
+}
+
+// detect returns the SDK release and the SKU of the firmware, detected on the first call
+func (c *JSONRPC) detect(ctx context.Context) (string, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.detected {
+		return c.sdk, c.sku
+	}
+	var result models.MrvlPlatformGetInventoryResult
+	if err := c.JSONRPC.Call(ctx, detectMethod, nil, &result); err != nil || result.Status != 0 {
+		slog.WarnContext(ctx, "Could not detect the SDK release of the firmware", "error", err, "status", result.Status)
+		return "", ""
+	}
+	c.sdk = result.SdkVersion
+	c.sku = result.Sku
+	c.detected = true
+	slog.InfoContext(ctx, "Detected the SDK release of the firmware", "sdk_version", c.sdk, "sku", c.sku)
+	return c.sdk, c.sku
+}
+
+// forget drops the detected SDK release, so it is detected again on the next call
+func (c *JSONRPC) forget() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.detected = false
+}
+
+// shim returns the shim of a method for the SDK release of the firmware, nil when the method is
+// called as is
+func (c *JSONRPC) shim(ctx context.Context, method string) *Shim {
+	shims, ok := c.shims[method]
+	if !ok {
+		return nil
+	}
+	sdk, sku := c.detect(ctx)
+	if sdk == "" {
+		return nil
+	}
+	for i := range shims {
+		if shims[i].applies(sdk, sku) {
+			return &shims[i]
+		}
+	}
+	return nil
+}
+
+// Call implements spdk.JSONRPC, a call failing with Method not found is retried once with the
+// shim of a newly detected release, the firmware could have been upgraded since it was detected
+func (c *JSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	if _, ok := c.shims[method]; !ok {
+		return c.JSONRPC.Call(ctx, method, args, result)
+	}
+	shim := c.shim(ctx, method)
+	err := c.call(ctx, method, shim, args, result)
+	if err == nil || !strings.Contains(strings.ToLower(err.Error()), "method not found") {
+		return err
+	}
+	c.forget()
+	if retry := c.shim(ctx, method); retry != shim {
+		slog.InfoContext(ctx, "Retrying with the shim of the firmware release", "method", method, "sdk_version", c.SdkVersion(ctx))
+		return c.call(ctx, method, retry, args, result)
+	}
+	return err
+}
+
+// call calls a method through a shim, as is when shim is nil
+func (c *JSONRPC) call(ctx context.Context, method string, shim *Shim, args, result interface{}) error {
+	if shim == nil {
+		return c.JSONRPC.Call(ctx, method, args, result)
+	}
+	name := method
+	if shim.Rename != "" {
+		name = shim.Rename
+	}
+	if len(shim.Params) != 0 && args != nil {
+		params, err := reshape(args, shim.Params)
+		if err != nil {
+			return fmt.Errorf("%s: %s", method, err)
+		}
+		args = params
+	}
+	if len(shim.Result) == 0 {
+		return c.JSONRPC.Call(ctx, name, args, result)
+	}
+	var raw json.RawMessage
+	if err := c.JSONRPC.Call(ctx, name, args, &raw); err != nil {
+		return err
+	}
+	reshaped, err := reshape(raw, shim.Result)
+	if err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	data, err := json.Marshal(reshaped)
+	if err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	return nil
+}
+
+// reshape renames the fields of a JSON object, the values which aren't objects are left as is
+func reshape(value interface{}, renames map[string]string) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil || object == nil {
+		return value, nil
+	}
+	// all the fields are taken before any is put, so the renames don't chain
+	taken := make(map[string]interface{}, len(renames))
+	for from, to := range renames {
+		if v, ok := take(object, strings.Split(from, ".")); ok {
+			taken[to] = v
+		}
+	}
+	for to, v := range taken {
+		put(object, strings.Split(to, "."), v)
+	}
+	return object, nil
+}
+
+// take removes a field of an object, the path goes through its nested objects
+func take(object map[string]interface{}, path []string) (interface{}, bool) {
+	if len(path) > 1 {
+		nested, ok := object[path[0]].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok := take(nested, path[1:])
+		if ok && len(nested) == 0 {
+			delete(object, path[0])
+		}
+		return v, ok
+	}
+	v, ok := object[path[0]]
+	delete(object, path[0])
+	return v, ok
+}
+
+// put sets a field of an object, creating the nested objects of the path
+func put(object map[string]interface{}, path []string, v interface{}) {
+	for _, key := range path[:len(path)-1] {
+		nested, ok := object[key].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			object[key] = nested
+		}
+		object = nested
+	}
+	object[path[len(path)-1]] = v
+}
+
+// compareVersions compares the numbers of two releases, i.e. 12.23.09 is before 12.24.03 and
+// SDK-12.24 is the same as 12.24.0, it returns -1, 0 or 1
+func compareVersions(a, b string) int {
+	numbers := func(version string) []int {
+		var n []int
+		for _, field := range strings.FieldsFunc(version, func(r rune) bool { return !unicode.IsDigit(r) }) {
+			i, _ := strconv.Atoi(field)
+			n = append(n, i)
+		}
+		return n
+	}
+	x, y := numbers(a), numbers(b)
+	for i := 0; i < max(len(x), len(y)); i++ {
+		var u, v int
+		if i < len(x) {
+			u = x[i]
+		}
+		if i < len(y) {
+			v = y[i]
+		}
+		if u != v {
+			if u < v {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package compat adapts the calls of the bridge to the Marvell SDK release of the firmware, some
+// releases renaming or re-shaping mrvl_nvm_* methods: the release is detected once and the shims
+// of the methods it renamed are applied to their calls, instead of failing with Method not found
+package compat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+// testJSONRPC is a CN106 firmware of an SDK release knowing some methods, it records the calls
+type testJSONRPC struct {
+	spdk.JSONRPC
+	sdk     string
+	methods map[string]string
+	calls   []string
+	params  []string
+}
+
+func (c *testJSONRPC) Call(_ context.Context, method string, args, result interface{}) error {
+	c.calls = append(c.calls, method)
+	if method == detectMethod {
+		if c.sdk == "" {
+			return errors.New("mrvl_platform_get_inventory: connection refused")
+		}
+		result.(*models.MrvlPlatformGetInventoryResult).SdkVersion = c.sdk
+		result.(*models.MrvlPlatformGetInventoryResult).Sku = "CN106XX"
+		return nil
+	}
+	response, ok := c.methods[method]
+	if !ok {
+		return errors.New(method + ": json response error: Method not found")
+	}
+	params, _ := json.Marshal(args)
+	c.params = append(c.params, string(params))
+	return json.Unmarshal([]byte(response), result)
+}
+
+// testShims rename the statistics of the controllers in 12.24 and nest their ID
+var testShims = []Shim{
+	{
+		Method: "mrvl_nvm_get_ctrlr_stats",
+		MinSdk: "12.24",
+		Rename: "mrvl_nvm_ctrlr_get_stats",
+		Params: map[string]string{"ctrlr_id": "ctrlr.id"},
+		Result: map[string]string{"stats.num_read_cmds": "num_read_cmds", "num_write_cmds": "writes", "writes": "num_write_cmds"},
+	},
+}
+
+func TestCompat_Call(t *testing.T) {
+	type stats struct {
+		Status      int `json:"status"`
+		NumReadCmds int `json:"num_read_cmds"`
+		Writes      int `json:"writes"`
+	}
+	tests := map[string]struct {
+		sdk     string
+		skus    []string
+		methods map[string]string
+		calls   []string
+		params  []string
+		result  stats
+		errMsg  string
+	}{
+		"release before the shim": {
+			sdk:     "12.23.09",
+			methods: map[string]string{"mrvl_nvm_get_ctrlr_stats": `{"status": 0, "num_read_cmds": 1, "writes": 2}`},
+			calls:   []string{detectMethod, "mrvl_nvm_get_ctrlr_stats"},
+			params:  []string{`{"ctrlr_id":3}`},
+			result:  stats{NumReadCmds: 1, Writes: 2},
+		},
+		"release of the shim": {
+			sdk:     "SDK-12.24.03",
+			methods: map[string]string{"mrvl_nvm_ctrlr_get_stats": `{"status": 0, "stats": {"num_read_cmds": 1}, "num_write_cmds": 2}`},
+			calls:   []string{detectMethod, "mrvl_nvm_ctrlr_get_stats"},
+			params:  []string{`{"ctrlr":{"id":3}}`},
+			result:  stats{NumReadCmds: 1, Writes: 2},
+		},
+		"other SKU": {
+			sdk:     "12.24.03",
+			skus:    []string{"CN98*"},
+			methods: map[string]string{"mrvl_nvm_get_ctrlr_stats": `{"status": 0, "num_read_cmds": 1}`},
+			calls:   []string{detectMethod, "mrvl_nvm_get_ctrlr_stats"},
+			params:  []string{`{"ctrlr_id":3}`},
+			result:  stats{NumReadCmds: 1},
+		},
+		"undetected release": {
+			sdk:     "",
+			methods: map[string]string{"mrvl_nvm_get_ctrlr_stats": `{"status": 0, "num_read_cmds": 1}`},
+			calls:   []string{detectMethod, "mrvl_nvm_get_ctrlr_stats"},
+			params:  []string{`{"ctrlr_id":3}`},
+			result:  stats{NumReadCmds: 1},
+		},
+		"unknown method": {
+			sdk:     "12.23.09",
+			methods: map[string]string{},
+			calls:   []string{detectMethod, "mrvl_nvm_get_ctrlr_stats", detectMethod},
+			errMsg:  "mrvl_nvm_get_ctrlr_stats: json response error: Method not found",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			firmware := &testJSONRPC{sdk: tt.sdk, methods: tt.methods}
+			shims := []Shim{testShims[0]}
+			shims[0].Skus = tt.skus
+			rpc := WrapJSONRPC(firmware, shims)
+			var result stats
+			params := struct {
+				CtrlrID int `json:"ctrlr_id"`
+			}{CtrlrID: 3}
+			err := rpc.Call(context.Background(), "mrvl_nvm_get_ctrlr_stats", &params, &result)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if tt.errMsg == "" && !reflect.DeepEqual(firmware.calls, tt.calls) {
+				t.Error("calls: expected", tt.calls, "received", firmware.calls)
+			}
+			if tt.errMsg == "" && !reflect.DeepEqual(firmware.params, tt.params) {
+				t.Error("params: expected", tt.params, "received", firmware.params)
+			}
+			if result != tt.result {
+				t.Error("result: expected", tt.result, "received", result)
+			}
+		})
+	}
+}
+
+func TestCompat_Upgrade(t *testing.T) {
+	firmware := &testJSONRPC{
+		sdk:     "12.23.09",
+		methods: map[string]string{"mrvl_nvm_get_ctrlr_stats": `{"status": 0, "num_read_cmds": 1}`, "mrvl_nvm_create_subsystem": `{"status": 0}`},
+	}
+	rpc := WrapJSONRPC(firmware, testShims)
+	var result struct {
+		NumReadCmds int `json:"num_read_cmds"`
+	}
+	if err := rpc.Call(context.Background(), "mrvl_nvm_get_ctrlr_stats", nil, &result); err != nil {
+		t.Fatal(err)
+	}
+	// the firmware is upgraded to the release renaming the method
+	firmware.sdk = "12.24.03"
+	firmware.methods = map[string]string{"mrvl_nvm_ctrlr_get_stats": `{"status": 0, "stats": {"num_read_cmds": 4}}`, "mrvl_nvm_create_subsystem": `{"status": 0}`}
+	if err := rpc.Call(context.Background(), "mrvl_nvm_get_ctrlr_stats", nil, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.NumReadCmds != 4 || rpc.SdkVersion(context.Background()) != "12.24.03" {
+		t.Error("result: expected 4 read commands with 12.24.03, received", result.NumReadCmds, rpc.SdkVersion(context.Background()))
+	}
+	expected := []string{detectMethod, "mrvl_nvm_get_ctrlr_stats", "mrvl_nvm_get_ctrlr_stats", detectMethod, "mrvl_nvm_ctrlr_get_stats"}
+	if !reflect.DeepEqual(firmware.calls, expected) {
+		t.Error("calls: expected", expected, "received", firmware.calls)
+	}
+	// the methods without shims are called as is, without detection
+	firmware.calls = nil
+	var status struct{}
+	if err := rpc.Call(context.Background(), "mrvl_nvm_create_subsystem", nil, &status); err != nil || len(firmware.calls) != 1 {
+		t.Error("calls: expected mrvl_nvm_create_subsystem only, received", firmware.calls, err)
+	}
+}
+
+func TestCompat_LoadShims(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		shims   []Shim
+		errMsg  string
+	}{
+		"valid": {
+			content: "shims:\n- method: mrvl_nvm_get_ctrlr_stats\n  min_sdk: \"12.24\"\n  rename: mrvl_nvm_ctrlr_get_stats\n  params:\n    ctrlr_id: ctrlr.id\n",
+			shims:   []Shim{{Method: "mrvl_nvm_get_ctrlr_stats", MinSdk: "12.24", Rename: "mrvl_nvm_ctrlr_get_stats", Params: map[string]string{"ctrlr_id": "ctrlr.id"}}},
+		},
+		"missing method": {
+			content: "shims:\n- rename: mrvl_nvm_ctrlr_get_stats\n",
+			errMsg:  "invalid firmware shim 1, the method has to be set",
+		},
+		"nothing to do": {
+			content: "shims:\n- method: mrvl_nvm_get_ctrlr_stats\n",
+			errMsg:  "invalid firmware shim of mrvl_nvm_get_ctrlr_stats, a rename, params or result has to be set",
+		},
+		"inverted range": {
+			content: "shims:\n- method: mrvl_nvm_get_ctrlr_stats\n  min_sdk: \"12.24\"\n  max_sdk: \"12.23.09\"\n  rename: mrvl_nvm_ctrlr_get_stats\n",
+			errMsg:  "invalid firmware shim of mrvl_nvm_get_ctrlr_stats, min_sdk 12.24 is after max_sdk 12.23.09",
+		},
+		"invalid yaml": {
+			content: "shims: {",
+			errMsg:  "invalid firmware shims",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "shims.yaml")
+			if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			shims, err := LoadShims(file)
+			if tt.errMsg != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.errMsg) {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(shims, tt.shims) {
+				t.Error("shims: expected", tt.shims, "received", shims)
+			}
+		})
+	}
+}
+
+func TestCompat_CompareVersions(t *testing.T) {
+	tests := map[string]struct {
+		a, b     string
+		expected int
+	}{
+		"older":          {"12.23.09", "12.24.03", -1},
+		"newer":          {"12.24.10", "12.24.9", 1},
+		"trailing zeros": {"SDK-12.24", "12.24.0", 0},
+		"longer":         {"12.24.0.1", "12.24", 1},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		if result := compareVersions(tt.a, tt.b); result != tt.expected {
+			t.Error(name, "expected", tt.expected, "received", result)
+		}
+	}
+}
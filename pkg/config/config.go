@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package config sets the flags of the bridge from a YAML configuration file, each setting being
+// validated as the flag of the same name
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Apply sets the flags of fs from the YAML file, but the ones already set on the command line.
+// The keys are the flag names, the keys of a section are prefixed with the section name, i.e.
+//
+//	grpc_port: 50051
+//	otlp_metrics:
+//	  endpoint: collector:4318
+//
+// sets -grpc_port and -otlp_metrics_endpoint. The lists are joined with commas
+func Apply(fs *flag.FlagSet, file string) error {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return err
+	}
+	settings, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid configuration %s: %v", file, err)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, setting := range settings {
+		if fs.Lookup(setting.Name) == nil {
+			return fmt.Errorf("invalid configuration %s: line %d: unknown setting %s", file, setting.Line, setting.Name)
+		}
+		// the command line overrides the file
+		if set[setting.Name] {
+			continue
+		}
+		if err := fs.Set(setting.Name, setting.Value); err != nil {
+			return fmt.Errorf("invalid configuration %s: line %d: invalid value %q for %s: %v", file, setting.Line, setting.Value, setting.Name, err)
+		}
+	}
+	return nil
+}
+
+// Setting is a flag value of a configuration file
+type Setting struct {
+	Name  string
+	Value string
+	// Line of the setting in the file
+	Line int
+}
+
+// Parse returns the settings of a YAML configuration in order, an empty one has none
+func Parse(data []byte) ([]Setting, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var document yaml.Node
+	if err := decoder.Decode(&document); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping of settings", root.Line)
+	}
+	var settings []Setting
+	if err := flatten(root, "", &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// flatten appends the settings of a mapping, the keys prefixed with the name of its section
+func flatten(mapping *yaml.Node, prefix string, settings *[]Setting) error {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		name := prefix + key.Value
+		switch value.Kind {
+		case yaml.MappingNode:
+			if err := flatten(value, name+"_", settings); err != nil {
+				return err
+			}
+		case yaml.SequenceNode:
+			var items []string
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return fmt.Errorf("line %d: %s items have to be scalars", item.Line, name)
+				}
+				items = append(items, item.Value)
+			}
+			*settings = append(*settings, Setting{Name: name, Value: strings.Join(items, ","), Line: key.Line})
+		case yaml.ScalarNode:
+			*settings = append(*settings, Setting{Name: name, Value: value.Value, Line: key.Line})
+		default:
+			return fmt.Errorf("line %d: unsupported value of %s", value.Line, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package config sets the flags of the bridge from a YAML configuration file, each setting being
+// validated as the flag of the same name
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFlags are some of the flags of the bridge
+type testFlags struct {
+	fs                  *flag.FlagSet
+	grpcPort            int
+	spdkAddress         string
+	thinProvisioning    bool
+	otlpMetricsEndpoint string
+	alertWebhookURLs    string
+}
+
+func newTestFlags() *testFlags {
+	f := &testFlags{fs: flag.NewFlagSet("test", flag.ContinueOnError)}
+	f.fs.SetOutput(io.Discard)
+	f.fs.IntVar(&f.grpcPort, "grpc_port", 50051, "")
+	f.fs.StringVar(&f.spdkAddress, "spdk_addr", "/var/tmp/spdk.sock", "")
+	f.fs.BoolVar(&f.thinProvisioning, "thin_provisioning", false, "")
+	f.fs.StringVar(&f.otlpMetricsEndpoint, "otlp_metrics_endpoint", "", "")
+	f.fs.StringVar(&f.alertWebhookURLs, "alert_webhook_urls", "", "")
+	return f
+}
+
+func TestConfig_Apply(t *testing.T) {
+	tests := map[string]struct {
+		config   string
+		args     []string
+		expected testFlags
+		errMsg   string
+	}{
+		"settings": {
+			config: "grpc_port: 50052\nspdk_addr: /var/run/spdk.sock\nthin_provisioning: true\n",
+			expected: testFlags{
+				grpcPort:         50052,
+				spdkAddress:      "/var/run/spdk.sock",
+				thinProvisioning: true,
+			},
+		},
+		"sections and lists": {
+			config: "otlp_metrics:\n  endpoint: collector:4318\nalert_webhook_urls:\n  - https://a.example.org\n  - https://b.example.org\n",
+			expected: testFlags{
+				grpcPort:            50051,
+				spdkAddress:         "/var/tmp/spdk.sock",
+				otlpMetricsEndpoint: "collector:4318",
+				alertWebhookURLs:    "https://a.example.org,https://b.example.org",
+			},
+		},
+		"command line overrides": {
+			config: "grpc_port: 50052\nspdk_addr: /var/run/spdk.sock\n",
+			args:   []string{"-grpc_port", "50053"},
+			expected: testFlags{
+				grpcPort:    50053,
+				spdkAddress: "/var/run/spdk.sock",
+			},
+		},
+		"empty": {
+			config: "",
+			expected: testFlags{
+				grpcPort:    50051,
+				spdkAddress: "/var/tmp/spdk.sock",
+			},
+		},
+		"unknown setting": {
+			config: "grpc_port: 50052\ngrpc_prot: 50053\n",
+			errMsg: "line 2: unknown setting grpc_prot",
+		},
+		"invalid value": {
+			config: "grpc_port: fast\n",
+			errMsg: `line 1: invalid value "fast" for grpc_port: parse error`,
+		},
+		"not a mapping": {
+			config: "- grpc_port\n",
+			errMsg: "line 1: expected a mapping of settings",
+		},
+		"nested list": {
+			config: "alert_webhook_urls:\n  - [https://a.example.org]\n",
+			errMsg: "line 2: alert_webhook_urls items have to be scalars",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(file, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			f := newTestFlags()
+			if err := f.fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := Apply(f.fs, file)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			expected := ""
+			if tt.errMsg != "" {
+				expected = "invalid configuration " + file + ": " + tt.errMsg
+			}
+			if errMsg != expected {
+				t.Error("error: expected", expected, "received", errMsg)
+			}
+			if err != nil {
+				return
+			}
+			f.fs = nil
+			if *f != tt.expected {
+				t.Error("flags: expected", tt.expected, "received", *f)
+			}
+		})
+	}
+}
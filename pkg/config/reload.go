@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package config sets the flags of the bridge from a YAML configuration file, each setting being
+// validated as the flag of the same name
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReloadConfigRequest represents a request to apply the configuration file again
+type ReloadConfigRequest struct{}
+
+// ReloadConfigResponse represents the settings a reload applied
+type ReloadConfigResponse struct {
+	// Reloaded are the settings applied again
+	Reloaded []string `json:"reloaded"`
+	// RestartRequired are the settings changed in the file which only apply on restart
+	RestartRequired []string `json:"restart_required"`
+}
+
+// reloadable is a group of settings applied together at runtime
+type reloadable struct {
+	names []string
+	// check validates the values of the settings, in the order of names, and returns the function
+	// applying them
+	check func(values []string) (func() error, error)
+}
+
+// Reloader applies the configuration file again at runtime, i.e. the log level, the TLS files or
+// the authorization policy, while the listeners and the resources are kept
+type Reloader struct {
+	fs   *flag.FlagSet
+	file string
+	// commandLine are the flags set on the command line, the file doesn't override them
+	commandLine map[string]bool
+
+	mu          sync.Mutex
+	reloadables []reloadable
+}
+
+// NewReloader returns a Reloader of the configuration file, created before the file is applied
+// to the flags of fs so the ones set on the command line are told apart
+func NewReloader(fs *flag.FlagSet, file string) *Reloader {
+	commandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		commandLine[f.Name] = true
+	})
+	return &Reloader{fs: fs, file: file, commandLine: commandLine}
+}
+
+// Handle has check validate and apply the settings of names on every reload, check is called
+// with their values, from the command line, or else from the file, or else the defaults. The
+// settings without handler only apply on restart, a check without names is called on every reload
+func (r *Reloader) Handle(names []string, check func(values []string) (func() error, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reloadables = append(r.reloadables, reloadable{names: names, check: check})
+}
+
+// ReloadConfig reads the configuration file again and applies the settings which can change at
+// runtime, none is applied when one is invalid
+func (r *Reloader) ReloadConfig(_ context.Context, _ *ReloadConfigRequest) (*ReloadConfigResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == "" {
+		msg := "No configuration file to reload, start the bridge with -config"
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	values, err := r.read()
+	if err != nil {
+		msg := fmt.Sprintf("Could not reload configuration %s: %v", r.file, err)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	response := &ReloadConfigResponse{Reloaded: []string{}, RestartRequired: []string{}}
+	handled := make(map[string]bool)
+	var applies []func() error
+	for _, reloadable := range r.reloadables {
+		var reloadableValues []string
+		for _, name := range reloadable.names {
+			reloadableValues = append(reloadableValues, r.value(name, values))
+			handled[name] = true
+		}
+		apply, err := reloadable.check(reloadableValues)
+		if err != nil {
+			msg := fmt.Sprintf("Could not reload configuration %s: %s: %v", r.file, strings.Join(reloadable.names, ", "), err)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+		applies = append(applies, apply)
+		response.Reloaded = append(response.Reloaded, reloadable.names...)
+	}
+	for _, apply := range applies {
+		if err := apply(); err != nil {
+			msg := fmt.Sprintf("Could not apply configuration %s: %v", r.file, err)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
+	}
+	// the other settings are the ones the bridge was started with
+	r.fs.VisitAll(func(f *flag.Flag) {
+		if !handled[f.Name] && r.value(f.Name, values) != f.Value.String() {
+			response.RestartRequired = append(response.RestartRequired, f.Name)
+		}
+	})
+	sort.Strings(response.Reloaded)
+	slog.Info("Reloaded configuration", "file", r.file, "reloaded", response.Reloaded, "restart_required", response.RestartRequired)
+	return response, nil
+}
+
+// read returns the values of the settings of the file by name
+func (r *Reloader) read() (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Clean(r.file))
+	if err != nil {
+		return nil, err
+	}
+	settings, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, setting := range settings {
+		if r.fs.Lookup(setting.Name) == nil {
+			return nil, fmt.Errorf("line %d: unknown setting %s", setting.Line, setting.Name)
+		}
+		values[setting.Name] = setting.Value
+	}
+	return values, nil
+}
+
+// value returns the value a setting has with the file values
+func (r *Reloader) value(name string, values map[string]string) string {
+	f := r.fs.Lookup(name)
+	if f == nil {
+		return ""
+	}
+	if r.commandLine[name] {
+		return f.Value.String()
+	}
+	if value, ok := values[name]; ok {
+		return value
+	}
+	return f.DefValue
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package config sets the flags of the bridge from a YAML configuration file, each setting being
+// validated as the flag of the same name
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConfig_ReloadConfig(t *testing.T) {
+	tests := map[string]struct {
+		args            []string
+		config          string
+		reloaded        string
+		restartRequired []string
+		errCode         codes.Code
+		errMsg          string
+	}{
+		"reloaded": {
+			config:          "grpc_port: 50051\nspdk_addr: /var/run/spdk.sock\n",
+			reloaded:        "/var/run/spdk.sock",
+			restartRequired: []string{},
+			errCode:         codes.OK,
+		},
+		"restart required": {
+			config:          "grpc_port: 50052\n",
+			reloaded:        "/var/tmp/spdk.sock",
+			restartRequired: []string{"grpc_port"},
+			errCode:         codes.OK,
+		},
+		"command line": {
+			args:            []string{"-spdk_addr", "/run/spdk.sock"},
+			config:          "grpc_port: 50051\nspdk_addr: /var/run/spdk.sock\n",
+			reloaded:        "/run/spdk.sock",
+			restartRequired: []string{},
+			errCode:         codes.OK,
+		},
+		"invalid value": {
+			config:  "spdk_addr: \"\"\n",
+			errCode: codes.InvalidArgument,
+			errMsg:  "spdk_addr: empty address",
+		},
+		"unknown setting": {
+			config:  "grpc_prot: 50052\n",
+			errCode: codes.InvalidArgument,
+			errMsg:  "line 1: unknown setting grpc_prot",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(file, []byte("grpc_port: 50051\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			f := newTestFlags()
+			if err := f.fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			reloader := NewReloader(f.fs, file)
+			if err := Apply(f.fs, file); err != nil {
+				t.Fatal(err)
+			}
+			reloaded := ""
+			reloader.Handle([]string{"spdk_addr"}, func(values []string) (func() error, error) {
+				if values[0] == "" {
+					return nil, errors.New("empty address")
+				}
+				return func() error {
+					reloaded = values[0]
+					return nil
+				}, nil
+			})
+			if err := os.WriteFile(file, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := reloader.ReloadConfig(context.Background(), &ReloadConfigRequest{})
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if err != nil {
+				expected := "Could not reload configuration " + file + ": " + tt.errMsg
+				if er.Message() != expected {
+					t.Error("error message: expected", expected, "received", er.Message())
+				}
+			} else {
+				if !reflect.DeepEqual(response.Reloaded, []string{"spdk_addr"}) {
+					t.Error("reloaded settings: expected [spdk_addr], received", response.Reloaded)
+				}
+				if !reflect.DeepEqual(response.RestartRequired, tt.restartRequired) {
+					t.Error("restart required: expected", tt.restartRequired, "received", response.RestartRequired)
+				}
+			}
+			if reloaded != tt.reloaded {
+				t.Error("reloaded: expected", tt.reloaded, "received", reloaded)
+			}
+		})
+	}
+}
+
+func TestConfig_ReloadConfigWithoutFile(t *testing.T) {
+	reloader := NewReloader(newTestFlags().fs, "")
+	_, err := reloader.ReloadConfig(context.Background(), &ReloadConfigRequest{})
+	er, _ := status.FromError(err)
+	if er.Code() != codes.FailedPrecondition {
+		t.Error("error code: expected", codes.FailedPrecondition, "received", er.Code())
+	}
+}
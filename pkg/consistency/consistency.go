@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package consistency lets the clients choose whether the Get and List methods answer from the
+// database of the bridge, fast and while the firmware can't be reached, or verify the resources
+// with the firmware, per call or for all of them
+package consistency
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the gRPC metadata carrying the read consistency of a call
+const MetadataKey = "opi-read-consistency"
+
+// HeaderKey is the HTTP header carrying the read consistency of a call
+const HeaderKey = "Opi-Read-Consistency"
+
+// Level is how consistent with the firmware the resources read are
+type Level string
+
+const (
+	// Strong verifies the resources with the firmware, the calls fail when it can't be reached
+	Strong Level = "strong"
+	// Cached answers from the database of the bridge, without calling the firmware, the
+	// resources changed behind the back of the bridge aren't seen
+	Cached Level = "cached"
+)
+
+// Parse returns the level of a value, strong or cached
+func Parse(value string) (Level, error) {
+	switch level := Level(value); level {
+	case Strong, Cached:
+		return level, nil
+	default:
+		msg := fmt.Sprintf("Read consistency value (%s) is not supported, have to be strong or cached", value)
+		return "", status.Errorf(codes.InvalidArgument, msg)
+	}
+}
+
+// levelKey is the context key of the read consistency of a call
+type levelKey struct{}
+
+// NewContext returns a context carrying a read consistency
+func NewContext(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, level)
+}
+
+// FromContext returns the read consistency of a context, strong when it has none
+func FromContext(ctx context.Context) Level {
+	if level, ok := ctx.Value(levelKey{}).(Level); ok {
+		return level
+	}
+	return Strong
+}
+
+// UnaryServerInterceptor sets the read consistency of the gRPC requests, the one in the metadata
+// or defaultLevel, the requests with an unknown one are refused
+func UnaryServerInterceptor(defaultLevel Level) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		level := defaultLevel
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(MetadataKey); len(values) > 0 {
+				var err error
+				if level, err = Parse(values[0]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return handler(NewContext(ctx, level), req)
+	}
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package consistency lets the clients choose whether the Get and List methods answer from the
+// database of the bridge, fast and while the firmware can't be reached, or verify the resources
+// with the firmware, per call or for all of them
+package consistency
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestConsistency_UnaryServerInterceptor(t *testing.T) {
+	tests := map[string]struct {
+		md           metadata.MD
+		defaultLevel Level
+		level        Level
+		errCode      codes.Code
+	}{
+		"default level": {
+			md:           nil,
+			defaultLevel: Cached,
+			level:        Cached,
+			errCode:      codes.OK,
+		},
+		"level of the client": {
+			md:           metadata.Pairs(MetadataKey, "strong"),
+			defaultLevel: Cached,
+			level:        Strong,
+			errCode:      codes.OK,
+		},
+		"cached by the client": {
+			md:           metadata.Pairs(MetadataKey, "cached"),
+			defaultLevel: Strong,
+			level:        Cached,
+			errCode:      codes.OK,
+		},
+		"invalid level": {
+			md:           metadata.Pairs(MetadataKey, "eventual"),
+			defaultLevel: Strong,
+			level:        "",
+			errCode:      codes.InvalidArgument,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			var level Level
+			handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+				level = FromContext(ctx)
+				return nil, nil
+			}
+			_, err := UnaryServerInterceptor(tt.defaultLevel)(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			if status.Code(err) != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", status.Code(err))
+			}
+			if level != tt.level {
+				t.Error("level: expected", tt.level, "received", level)
+			}
+		})
+	}
+
+	if FromContext(context.Background()) != Strong {
+		t.Error("no level: expected", Strong, "received", FromContext(context.Background()))
+	}
+}
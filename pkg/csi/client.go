@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package csi maps the volumes of a Container Storage Interface plugin to the lvols and the Nvme
+// namespaces of the bridge, so Kubernetes clusters consume the NVMe emulated by the DPU natively
+package csi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client calls the HTTP server of the bridge, its OPI and Marvell specific methods alike
+type Client struct {
+	url    string
+	client *http.Client
+	apiKey string
+}
+
+// NewClient returns a Client of the bridge serving HTTP at url, i.e. http://10.10.10.10:8082,
+// the API key is sent when set
+func NewClient(url string, client *http.Client, apiKey string) *Client {
+	return &Client{url: url, client: client, apiKey: apiKey}
+}
+
+// bridgeError is the JSON of the status of a failed call
+type bridgeError struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+// do sends in as the JSON body of the request and decodes the response in out, when set. The
+// failures are returned as the status the bridge returned
+func (c *Client) do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set(apikey.HeaderKey, c.apiKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		msg := fmt.Sprintf("Could not call the bridge: %v", err)
+		return status.Errorf(codes.Unavailable, msg)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		var e bridgeError
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Code == codes.OK {
+			msg := fmt.Sprintf("Could not call the bridge: %s %s: %s", method, path, resp.Status)
+			return status.Errorf(codes.Unknown, msg)
+		}
+		return status.Errorf(e.Code, e.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
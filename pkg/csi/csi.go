@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package csi maps the volumes of a Container Storage Interface plugin to the lvols and the Nvme
+// namespaces of the bridge, so Kubernetes clusters consume the NVMe emulated by the DPU natively
+package csi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"go.einride.tech/aip/resourceid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Parameters of the storage classes
+const (
+	// ParameterLvolStore is the name of the lvol store the volumes are carved from, i.e.
+	// //storage.opiproject.org/lvolStores/lvs0
+	ParameterLvolStore = "lvolStore"
+	// ParameterThinProvisioned only allocates the clusters of the volumes when they are written
+	ParameterThinProvisioned = "thinProvisioned"
+)
+
+// Keys of the publish context of a volume, the node finds the device of the volume from them
+const (
+	// PublishContextNqn is the NQN of the subsystem the volume is a namespace of
+	PublishContextNqn = "nqn"
+	// PublishContextNsid is the namespace ID of the volume for the host
+	PublishContextNsid = "nsid"
+)
+
+// volumePrefix is the prefix of the names of the lvols
+const volumePrefix = "//storage.opiproject.org/volumes/"
+
+// CreateVolumeRequest represents a request to provision a volume, as CSI CreateVolume
+type CreateVolumeRequest struct {
+	// Name of the volume, i.e. the name of the persistent volume, the idempotency key
+	Name string
+	// CapacityBytes is the size of the volume
+	CapacityBytes int64
+	// Parameters of the storage class
+	Parameters map[string]string
+}
+
+// Volume represents a provisioned volume
+type Volume struct {
+	// VolumeID is the ID of the lvol of the volume
+	VolumeID string
+	// CapacityBytes is the size of the lvol
+	CapacityBytes int64
+}
+
+// lvol is the part of an lvol of the bridge the driver uses
+type lvol struct {
+	Name            string `json:"name"`
+	LvolStore       string `json:"lvolStore"`
+	SizeBytes       int64  `json:"sizeBytes"`
+	ThinProvisioned bool   `json:"thinProvisioned"`
+}
+
+// namespaceSpec is the part of the spec of an Nvme namespace the driver uses
+type namespaceSpec struct {
+	VolumeNameRef string `json:"volume_name_ref"`
+	HostNsid      int32  `json:"host_nsid"`
+}
+
+// namespace is the part of an Nvme namespace the driver uses
+type namespace struct {
+	Name string         `json:"name,omitempty"`
+	Spec *namespaceSpec `json:"spec"`
+}
+
+// listNamespacesResponse is a page of the Nvme namespaces of a subsystem
+type listNamespacesResponse struct {
+	NvmeNamespaces []*namespace `json:"nvme_namespaces"`
+	NextPageToken  string       `json:"next_page_token"`
+}
+
+// subsystem is the part of an Nvme subsystem the driver uses
+type subsystem struct {
+	Spec struct {
+		Nqn string `json:"nqn"`
+	} `json:"spec"`
+}
+
+// Driver provisions the volumes as lvols and publishes them as Nvme namespaces of the subsystem
+// of each node, the ID of a node is the ID of the subsystem of its emulated controller
+type Driver struct {
+	client *Client
+	// lvolStore the volumes are carved from unless their storage class sets another
+	lvolStore string
+	// mu serializes the publications, as the namespace IDs of a subsystem are chosen by the driver
+	mu sync.Mutex
+}
+
+// NewDriver returns a Driver calling the bridge with client, the volumes are carved from
+// lvolStore unless their storage class sets another
+func NewDriver(client *Client, lvolStore string) *Driver {
+	return &Driver{client: client, lvolStore: lvolStore}
+}
+
+// CreateVolume carves the lvol of a volume, its ID is the name of the volume so a retry
+// returns the same lvol
+func (d *Driver) CreateVolume(ctx context.Context, in *CreateVolumeRequest) (*Volume, error) {
+	if err := resourceid.ValidateUserSettable(in.Name); err != nil {
+		msg := fmt.Sprintf("Volume name %s is invalid: %v", in.Name, err)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.CapacityBytes <= 0 {
+		msg := fmt.Sprintf("Capacity (%d) is invalid, have to be positive", in.CapacityBytes)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	request := &struct {
+		LvolID string `json:"lvolId"`
+		Lvol   *lvol  `json:"lvol"`
+	}{
+		LvolID: in.Name,
+		Lvol:   &lvol{LvolStore: d.lvolStore, SizeBytes: in.CapacityBytes},
+	}
+	if lvolStore, ok := in.Parameters[ParameterLvolStore]; ok {
+		request.Lvol.LvolStore = lvolStore
+	}
+	if thin, ok := in.Parameters[ParameterThinProvisioned]; ok {
+		var err error
+		if request.Lvol.ThinProvisioned, err = strconv.ParseBool(thin); err != nil {
+			msg := fmt.Sprintf("Parameter %s (%s) is invalid, have to be true or false", ParameterThinProvisioned, thin)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	var created lvol
+	if err := d.client.do(ctx, http.MethodPost, "/v1/lvols", request, &created); err != nil {
+		return nil, err
+	}
+	// a retry with another capacity gets the lvol of the first call
+	if created.SizeBytes < in.CapacityBytes {
+		msg := fmt.Sprintf("Volume %s already exists with %d bytes", in.Name, created.SizeBytes)
+		return nil, status.Errorf(codes.AlreadyExists, msg)
+	}
+	return &Volume{VolumeID: in.Name, CapacityBytes: created.SizeBytes}, nil
+}
+
+// DeleteVolume deletes the lvol of a volume, deleting it again succeeds
+func (d *Driver) DeleteVolume(ctx context.Context, volumeID string) error {
+	request := &struct {
+		AllowMissing bool `json:"allowMissing"`
+	}{AllowMissing: true}
+	return d.client.do(ctx, http.MethodDelete, "/v1/volumes/"+url.PathEscape(volumeID)+"/lvol", request, nil)
+}
+
+// GetVolume returns the lvol of a volume, NotFound when it doesn't exist
+func (d *Driver) GetVolume(ctx context.Context, volumeID string) (*Volume, error) {
+	var existing lvol
+	if err := d.client.do(ctx, http.MethodGet, "/v1/volumes/"+url.PathEscape(volumeID)+"/lvol", nil, &existing); err != nil {
+		return nil, err
+	}
+	return &Volume{VolumeID: volumeID, CapacityBytes: existing.SizeBytes}, nil
+}
+
+// Probe returns an error unless the bridge answers
+func (d *Driver) Probe(ctx context.Context) error {
+	return d.client.do(ctx, http.MethodGet, "/v1/lvolStores", nil, nil)
+}
+
+// ControllerPublishVolume makes a volume a namespace of the subsystem of a node, its ID is the
+// one of the volume so a retry returns the same namespace. The returned publish context tells
+// the node the NQN of the subsystem and the namespace ID
+func (d *Driver) ControllerPublishVolume(ctx context.Context, volumeID string, nodeID string) (map[string]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	parent := "/v1/nvmeSubsystems/" + url.PathEscape(nodeID)
+	var subsys subsystem
+	if err := d.client.do(ctx, http.MethodGet, parent, nil, &subsys); err != nil {
+		return nil, err
+	}
+	namespaces, err := d.listNamespaces(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[int32]bool)
+	for _, ns := range namespaces {
+		used[ns.Spec.GetHostNsid()] = true
+	}
+	nsid := int32(1)
+	for used[nsid] {
+		nsid++
+	}
+	request := &namespace{Spec: &namespaceSpec{VolumeNameRef: volumePrefix + volumeID, HostNsid: nsid}}
+	var created namespace
+	path := parent + "/nvmeNamespaces?nvme_namespace_id=" + url.QueryEscape(volumeID)
+	if err := d.client.do(ctx, http.MethodPost, path, request, &created); err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		PublishContextNqn:  subsys.Spec.Nqn,
+		PublishContextNsid: strconv.Itoa(int(created.Spec.GetHostNsid())),
+	}, nil
+}
+
+// ControllerUnpublishVolume removes the namespace of a volume from the subsystem of a node,
+// removing it again succeeds
+func (d *Driver) ControllerUnpublishVolume(ctx context.Context, volumeID string, nodeID string) error {
+	path := "/v1/nvmeSubsystems/" + url.PathEscape(nodeID) + "/nvmeNamespaces/" + url.PathEscape(volumeID) + "?allow_missing=true"
+	return d.client.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// listNamespaces lists all the Nvme namespaces of a subsystem
+func (d *Driver) listNamespaces(ctx context.Context, parent string) ([]*namespace, error) {
+	var namespaces []*namespace
+	pageToken := ""
+	for {
+		var page listNamespacesResponse
+		path := parent + "/nvmeNamespaces?page_token=" + url.QueryEscape(pageToken)
+		if err := d.client.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, page.NvmeNamespaces...)
+		if page.NextPageToken == "" {
+			return namespaces, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// GetHostNsid returns the namespace ID of a namespace, 0 when it has no spec
+func (s *namespaceSpec) GetHostNsid() int32 {
+	if s == nil {
+		return 0
+	}
+	return s.HostNsid
+}
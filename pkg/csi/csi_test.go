@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package csi maps the volumes of a Container Storage Interface plugin to the lvols and the Nvme
+// namespaces of the bridge, so Kubernetes clusters consume the NVMe emulated by the DPU natively
+package csi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testBridge serves the methods of the bridge the driver calls, for the subsystem subsys0
+type testBridge struct {
+	mu         sync.Mutex
+	lvols      map[string]*lvol
+	namespaces map[string]*namespace
+}
+
+func newTestBridge(t *testing.T) *httptest.Server {
+	bridge := &testBridge{lvols: make(map[string]*lvol), namespaces: make(map[string]*namespace)}
+	mux := http.NewServeMux()
+	writeJSON := func(w http.ResponseWriter, code int, v interface{}) {
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("/v1/lvols", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			LvolID string `json:"lvolId"`
+			Lvol   *lvol  `json:"lvol"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		bridge.mu.Lock()
+		defer bridge.mu.Unlock()
+		if r.Header.Get(apikey.HeaderKey) != "secret" {
+			t.Error("API key: expected secret, received", r.Header.Get(apikey.HeaderKey))
+		}
+		if in.Lvol.LvolStore != "//storage.opiproject.org/lvolStores/lvs0" {
+			writeJSON(w, http.StatusNotFound, &bridgeError{Code: codes.NotFound, Message: "unable to find key " + in.Lvol.LvolStore})
+			return
+		}
+		if existing, ok := bridge.lvols[in.LvolID]; ok {
+			writeJSON(w, http.StatusOK, existing)
+			return
+		}
+		in.Lvol.Name = volumePrefix + in.LvolID
+		bridge.lvols[in.LvolID] = in.Lvol
+		writeJSON(w, http.StatusOK, in.Lvol)
+	})
+	mux.HandleFunc("/v1/volumes/pvc-0001/lvol", func(w http.ResponseWriter, r *http.Request) {
+		bridge.mu.Lock()
+		defer bridge.mu.Unlock()
+		if r.Method == http.MethodGet {
+			existing, ok := bridge.lvols["pvc-0001"]
+			if !ok {
+				writeJSON(w, http.StatusNotFound, &bridgeError{Code: codes.NotFound, Message: "unable to find key pvc-0001"})
+				return
+			}
+			writeJSON(w, http.StatusOK, existing)
+			return
+		}
+		delete(bridge.lvols, "pvc-0001")
+		writeJSON(w, http.StatusOK, struct{}{})
+	})
+	mux.HandleFunc("/v1/lvolStores", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, struct{}{})
+	})
+	mux.HandleFunc("/v1/nvmeSubsystems/subsys0", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name": "//storage.opiproject.org/nvmeSubsystems/subsys0", "spec": {"nqn": "nqn.2022-09.io.spdk:opi0"}}`))
+	})
+	mux.HandleFunc("/v1/nvmeSubsystems/subsys0/nvmeNamespaces", func(w http.ResponseWriter, r *http.Request) {
+		bridge.mu.Lock()
+		defer bridge.mu.Unlock()
+		if r.Method == http.MethodGet {
+			response := &listNamespacesResponse{}
+			for _, ns := range bridge.namespaces {
+				response.NvmeNamespaces = append(response.NvmeNamespaces, ns)
+			}
+			writeJSON(w, http.StatusOK, response)
+			return
+		}
+		var in namespace
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		id := r.URL.Query().Get("nvme_namespace_id")
+		if existing, ok := bridge.namespaces[id]; ok {
+			writeJSON(w, http.StatusOK, existing)
+			return
+		}
+		bridge.namespaces[id] = &in
+		writeJSON(w, http.StatusOK, &in)
+	})
+	mux.HandleFunc("/v1/nvmeSubsystems/subsys0/nvmeNamespaces/", func(w http.ResponseWriter, r *http.Request) {
+		bridge.mu.Lock()
+		defer bridge.mu.Unlock()
+		delete(bridge.namespaces, r.URL.Path[len("/v1/nvmeSubsystems/subsys0/nvmeNamespaces/"):])
+		writeJSON(w, http.StatusOK, struct{}{})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCSI_CreateVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *CreateVolumeRequest
+		out     *Volume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      &CreateVolumeRequest{Name: "pvc-0001", CapacityBytes: 1 << 30},
+			out:     &Volume{VolumeID: "pvc-0001", CapacityBytes: 1 << 30},
+			errCode: codes.OK,
+		},
+		"thin provisioned": {
+			in:      &CreateVolumeRequest{Name: "pvc-0002", CapacityBytes: 1 << 30, Parameters: map[string]string{ParameterThinProvisioned: "true"}},
+			out:     &Volume{VolumeID: "pvc-0002", CapacityBytes: 1 << 30},
+			errCode: codes.OK,
+		},
+		"invalid name": {
+			in:      &CreateVolumeRequest{Name: "PVC_0001", CapacityBytes: 1 << 30},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Volume name PVC_0001 is invalid: user-settable ID must only contain lowercase, numbers and hyphens (got: 'P' in position 0)",
+		},
+		"invalid capacity": {
+			in:      &CreateVolumeRequest{Name: "pvc-0001", CapacityBytes: 0},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Capacity (0) is invalid, have to be positive",
+		},
+		"invalid parameter": {
+			in:      &CreateVolumeRequest{Name: "pvc-0001", CapacityBytes: 1 << 30, Parameters: map[string]string{ParameterThinProvisioned: "maybe"}},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Parameter thinProvisioned (maybe) is invalid, have to be true or false",
+		},
+		"unknown lvol store": {
+			in:      &CreateVolumeRequest{Name: "pvc-0001", CapacityBytes: 1 << 30, Parameters: map[string]string{ParameterLvolStore: "//storage.opiproject.org/lvolStores/lvs1"}},
+			errCode: codes.NotFound,
+			errMsg:  "unable to find key //storage.opiproject.org/lvolStores/lvs1",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bridge := newTestBridge(t)
+			defer bridge.Close()
+			driver := NewDriver(NewClient(bridge.URL, bridge.Client(), "secret"), "//storage.opiproject.org/lvolStores/lvs0")
+
+			volume, err := driver.CreateVolume(context.Background(), tt.in)
+
+			if !reflect.DeepEqual(volume, tt.out) {
+				t.Error("response: expected", tt.out, "received", volume)
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestCSI_VolumeLifecycle(t *testing.T) {
+	bridge := newTestBridge(t)
+	defer bridge.Close()
+	driver := NewDriver(NewClient(bridge.URL, bridge.Client(), "secret"), "//storage.opiproject.org/lvolStores/lvs0")
+	ctx := context.Background()
+
+	if _, err := driver.CreateVolume(ctx, &CreateVolumeRequest{Name: "pvc-0001", CapacityBytes: 1 << 30}); err != nil {
+		t.Fatal(err)
+	}
+	// a retry asking for more than the existing volume conflicts
+	_, err := driver.CreateVolume(ctx, &CreateVolumeRequest{Name: "pvc-0001", CapacityBytes: 2 << 30})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Error("error code: expected", codes.AlreadyExists, "received", status.Code(err))
+	}
+	if _, err := driver.CreateVolume(ctx, &CreateVolumeRequest{Name: "pvc-0002", CapacityBytes: 1 << 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := driver.ControllerPublishVolume(ctx, "pvc-0001", "subsys0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{PublishContextNqn: "nqn.2022-09.io.spdk:opi0", PublishContextNsid: "1"}
+	if !reflect.DeepEqual(first, expected) {
+		t.Error("publish context: expected", expected, "received", first)
+	}
+	second, err := driver.ControllerPublishVolume(ctx, "pvc-0002", "subsys0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second[PublishContextNsid] != "2" {
+		t.Error("namespace ID: expected 2, received", second[PublishContextNsid])
+	}
+	// a retry gets the namespace of the first call
+	retried, err := driver.ControllerPublishVolume(ctx, "pvc-0001", "subsys0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(retried, expected) {
+		t.Error("publish context: expected", expected, "received", retried)
+	}
+
+	if err := driver.ControllerUnpublishVolume(ctx, "pvc-0001", "subsys0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.DeleteVolume(ctx, "pvc-0001"); err != nil {
+		t.Fatal(err)
+	}
+	// the namespace ID is reused once free
+	if _, err := driver.CreateVolume(ctx, &CreateVolumeRequest{Name: "pvc-0003", CapacityBytes: 1 << 30}); err != nil {
+		t.Fatal(err)
+	}
+	third, err := driver.ControllerPublishVolume(ctx, "pvc-0003", "subsys0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third[PublishContextNsid] != "1" {
+		t.Error("namespace ID: expected 1, received", third[PublishContextNsid])
+	}
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package csi maps the volumes of a Container Storage Interface plugin to the lvols and the Nvme
+// namespaces of the bridge, so Kubernetes clusters consume the NVMe emulated by the DPU natively
+package csi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Mounter formats and mounts the block devices of the volumes on the host
+type Mounter interface {
+	// Format makes a filesystem of fsType on device, unless it has one already
+	Format(device string, fsType string) error
+	// Mount mounts source on target, fsType is empty for the bind mounts
+	Mount(source string, target string, fsType string, options []string) error
+	// Unmount unmounts target
+	Unmount(target string) error
+	// IsMounted tells whether something is mounted on target
+	IsMounted(target string) (bool, error)
+}
+
+// execMounter runs the blkid, mkfs, mount and umount commands of the host
+type execMounter struct {
+	// mounts is the mount table, /proc/self/mounts
+	mounts string
+}
+
+// NewMounter returns a Mounter running the commands of the host, the plugin has to run in its
+// mount namespace
+func NewMounter() Mounter {
+	return &execMounter{mounts: "/proc/self/mounts"}
+}
+
+// run runs a command, its output is returned in the error when it fails
+func run(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (m *execMounter) Format(device string, fsType string) error {
+	// blkid exits with 2 when the device has no filesystem
+	err := exec.Command("blkid", "-p", device).Run()
+	var exitErr *exec.ExitError
+	if err == nil {
+		return nil
+	} else if !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+		return fmt.Errorf("could not probe the filesystem of %s: %w", device, err)
+	}
+	return run("mkfs."+fsType, device)
+}
+
+func (m *execMounter) Mount(source string, target string, fsType string, options []string) error {
+	var args []string
+	if fsType != "" {
+		args = append(args, "-t", fsType)
+	}
+	if len(options) != 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+	return run("mount", append(args, source, target)...)
+}
+
+func (m *execMounter) Unmount(target string) error {
+	return run("umount", target)
+}
+
+func (m *execMounter) IsMounted(target string) (bool, error) {
+	f, err := os.Open(m.mounts)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	target = filepath.Clean(target)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 1 && fields[1] == target {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
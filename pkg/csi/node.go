@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package csi maps the volumes of a Container Storage Interface plugin to the lvols and the Nvme
+// namespaces of the bridge, so Kubernetes clusters consume the NVMe emulated by the DPU natively
+package csi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// namespaceDevice matches the block devices of the namespaces in a subsystem, i.e. nvme0n1
+var namespaceDevice = regexp.MustCompile(`^nvme\d+n\d+$`)
+
+// Node finds the block devices of the volumes published to the host, from its sysfs
+type Node struct {
+	// sysfs is where sysfs is mounted, /sys
+	sysfs string
+}
+
+// NewNode returns a Node reading sysfs at sysfs, /sys unless the plugin runs in a container
+// mounting it elsewhere
+func NewNode(sysfs string) *Node {
+	return &Node{sysfs: sysfs}
+}
+
+// FindDevice returns the block device of a published volume, i.e. /dev/nvme0n1, from its
+// publish context. It is NotFound until the host sees the namespace, the caller retries
+func (n *Node) FindDevice(publishContext map[string]string) (string, error) {
+	nqn := publishContext[PublishContextNqn]
+	nsid, err := strconv.Atoi(publishContext[PublishContextNsid])
+	if nqn == "" || err != nil {
+		msg := fmt.Sprintf("Publish context %v is invalid, have to set the %s and the %s", publishContext, PublishContextNqn, PublishContextNsid)
+		return "", status.Errorf(codes.InvalidArgument, msg)
+	}
+	subsystems, err := filepath.Glob(filepath.Join(n.sysfs, "class", "nvme-subsystem", "*"))
+	if err != nil {
+		return "", err
+	}
+	for _, subsystem := range subsystems {
+		if readAttribute(filepath.Join(subsystem, "subsysnqn")) != nqn {
+			continue
+		}
+		entries, err := os.ReadDir(subsystem)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			if !namespaceDevice.MatchString(entry.Name()) {
+				continue
+			}
+			if readAttribute(filepath.Join(subsystem, entry.Name(), "nsid")) == strconv.Itoa(nsid) {
+				return "/dev/" + entry.Name(), nil
+			}
+		}
+	}
+	msg := fmt.Sprintf("Namespace %d of %s is not seen by the host yet", nsid, nqn)
+	return "", status.Errorf(codes.NotFound, msg)
+}
+
+// readAttribute returns the value of a sysfs attribute, empty when it can't be read
+func readAttribute(file string) string {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package csi maps the volumes of a Container Storage Interface plugin to the lvols and the Nvme
+// namespaces of the bridge, so Kubernetes clusters consume the NVMe emulated by the DPU natively
+package csi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// writeTestSysfs writes the attributes of a subsystem and of its namespaces, by device name
+func writeTestSysfs(t *testing.T, sysfs string, subsystem string, nqn string, namespaces map[string]string) {
+	dir := filepath.Join(sysfs, "class", "nvme-subsystem", subsystem)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "subsysnqn"), []byte(nqn+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for device, nsid := range namespaces {
+		if err := os.MkdirAll(filepath.Join(dir, device), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, device, "nsid"), []byte(nsid+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCSI_FindDevice(t *testing.T) {
+	sysfs := t.TempDir()
+	writeTestSysfs(t, sysfs, "nvme-subsys0", "nqn.2014-08.org.nvmexpress:local", map[string]string{"nvme0n1": "1"})
+	writeTestSysfs(t, sysfs, "nvme-subsys1", "nqn.2022-09.io.spdk:opi0", map[string]string{"nvme1n1": "1", "nvme1n2": "2"})
+	tests := map[string]struct {
+		publishContext map[string]string
+		device         string
+		errCode        codes.Code
+		errMsg         string
+	}{
+		"found": {
+			publishContext: map[string]string{PublishContextNqn: "nqn.2022-09.io.spdk:opi0", PublishContextNsid: "2"},
+			device:         "/dev/nvme1n2",
+			errCode:        codes.OK,
+		},
+		"not seen yet": {
+			publishContext: map[string]string{PublishContextNqn: "nqn.2022-09.io.spdk:opi0", PublishContextNsid: "3"},
+			errCode:        codes.NotFound,
+			errMsg:         "Namespace 3 of nqn.2022-09.io.spdk:opi0 is not seen by the host yet",
+		},
+		"invalid publish context": {
+			publishContext: map[string]string{PublishContextNqn: "nqn.2022-09.io.spdk:opi0"},
+			errCode:        codes.InvalidArgument,
+			errMsg:         "Publish context map[nqn:nqn.2022-09.io.spdk:opi0] is invalid, have to set the nqn and the nsid",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			device, err := NewNode(sysfs).FindDevice(tt.publishContext)
+
+			if device != tt.device {
+				t.Error("device: expected", tt.device, "received", device)
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
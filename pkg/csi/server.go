@@ -0,0 +1,342 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package csi maps the volumes of a Container Storage Interface plugin to the lvols and the Nvme
+// namespaces of the bridge, so Kubernetes clusters consume the NVMe emulated by the DPU natively
+package csi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	csipb "github.com/container-storage-interface/spec/lib/go/csi"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DriverName is the name of the plugin, the provisioner of its storage classes
+const DriverName = "csi.opi-marvell-bridge.opiproject.org"
+
+// DriverVersion is the version of the plugin
+const DriverVersion = "0.1.0"
+
+// DefaultCapacityBytes is the size of the volumes whose capacity range sets no size
+const DefaultCapacityBytes = 1 << 30
+
+// DefaultFsType is the filesystem the mounted volumes are formatted with unless their
+// capability sets another
+const DefaultFsType = "ext4"
+
+// fsTypes are the filesystems the mounted volumes can be formatted with, the ones the mkfs
+// commands of the image make
+var fsTypes = map[string]bool{
+	"ext2": true,
+	"ext3": true,
+	"ext4": true,
+	"xfs":  true,
+}
+
+// singleNodeModes are the access modes supported, a namespace being in the subsystem of a
+// single node
+var singleNodeModes = map[csipb.VolumeCapability_AccessMode_Mode]bool{
+	csipb.VolumeCapability_AccessMode_SINGLE_NODE_WRITER:        true,
+	csipb.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY:   true,
+	csipb.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER: true,
+	csipb.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER:  true,
+}
+
+// validateCapabilities returns an error unless the volumes can have all the capabilities
+func validateCapabilities(capabilities []*csipb.VolumeCapability) error {
+	if len(capabilities) == 0 {
+		msg := "Missing volume capabilities"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	for _, capability := range capabilities {
+		if capability.GetBlock() == nil && capability.GetMount() == nil {
+			msg := "Volume capability is invalid, have to set block or mount"
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		if mode := capability.GetAccessMode().GetMode(); !singleNodeModes[mode] {
+			msg := fmt.Sprintf("Access mode %v is not supported, have to be a single node mode", mode)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		if fsType := capability.GetMount().GetFsType(); fsType != "" && !fsTypes[fsType] {
+			msg := fmt.Sprintf("Filesystem %q is not supported, have to be ext2, ext3, ext4 or xfs", fsType)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	return nil
+}
+
+// IdentityServer serves the CSI Identity service, the plugin is probed through the bridge when
+// it serves the Controller service
+type IdentityServer struct {
+	csipb.UnimplementedIdentityServer
+	driver *Driver
+}
+
+// NewIdentityServer returns an IdentityServer of a plugin serving the Controller service of
+// driver, or only the Node service when nil
+func NewIdentityServer(driver *Driver) *IdentityServer {
+	return &IdentityServer{driver: driver}
+}
+
+// GetPluginInfo implements csipb.IdentityServer
+func (s *IdentityServer) GetPluginInfo(_ context.Context, _ *csipb.GetPluginInfoRequest) (*csipb.GetPluginInfoResponse, error) {
+	return &csipb.GetPluginInfoResponse{Name: DriverName, VendorVersion: DriverVersion}, nil
+}
+
+// GetPluginCapabilities implements csipb.IdentityServer
+func (s *IdentityServer) GetPluginCapabilities(_ context.Context, _ *csipb.GetPluginCapabilitiesRequest) (*csipb.GetPluginCapabilitiesResponse, error) {
+	response := &csipb.GetPluginCapabilitiesResponse{}
+	if s.driver != nil {
+		response.Capabilities = append(response.Capabilities, &csipb.PluginCapability{
+			Type: &csipb.PluginCapability_Service_{Service: &csipb.PluginCapability_Service{Type: csipb.PluginCapability_Service_CONTROLLER_SERVICE}},
+		})
+	}
+	return response, nil
+}
+
+// Probe implements csipb.IdentityServer, the Controller service isn't ready until the bridge
+// answers
+func (s *IdentityServer) Probe(ctx context.Context, _ *csipb.ProbeRequest) (*csipb.ProbeResponse, error) {
+	if s.driver != nil {
+		if err := s.driver.Probe(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return &csipb.ProbeResponse{}, nil
+}
+
+// ControllerServer serves the CSI Controller service with a Driver, the volumes are lvols of
+// the bridge and are published as Nvme namespaces of the subsystems of the nodes
+type ControllerServer struct {
+	csipb.UnimplementedControllerServer
+	driver *Driver
+}
+
+// NewControllerServer returns a ControllerServer of driver
+func NewControllerServer(driver *Driver) *ControllerServer {
+	return &ControllerServer{driver: driver}
+}
+
+// CreateVolume implements csipb.ControllerServer
+func (s *ControllerServer) CreateVolume(ctx context.Context, in *csipb.CreateVolumeRequest) (*csipb.CreateVolumeResponse, error) {
+	if in.Name == "" {
+		msg := "Missing volume name"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.VolumeContentSource != nil {
+		msg := "Volume content sources are not supported"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if err := validateCapabilities(in.VolumeCapabilities); err != nil {
+		return nil, err
+	}
+	capacity := in.CapacityRange.GetRequiredBytes()
+	if capacity == 0 {
+		capacity = in.CapacityRange.GetLimitBytes()
+	}
+	if capacity == 0 {
+		capacity = DefaultCapacityBytes
+	}
+	if limit := in.CapacityRange.GetLimitBytes(); limit != 0 && capacity > limit {
+		msg := fmt.Sprintf("Capacity (%d) is out of range, have to be at most the limit (%d)", capacity, limit)
+		return nil, status.Errorf(codes.OutOfRange, msg)
+	}
+	volume, err := s.driver.CreateVolume(ctx, &CreateVolumeRequest{Name: in.Name, CapacityBytes: capacity, Parameters: in.Parameters})
+	if err != nil {
+		return nil, err
+	}
+	return &csipb.CreateVolumeResponse{Volume: &csipb.Volume{VolumeId: volume.VolumeID, CapacityBytes: volume.CapacityBytes}}, nil
+}
+
+// DeleteVolume implements csipb.ControllerServer
+func (s *ControllerServer) DeleteVolume(ctx context.Context, in *csipb.DeleteVolumeRequest) (*csipb.DeleteVolumeResponse, error) {
+	if in.VolumeId == "" {
+		msg := "Missing volume ID"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if err := s.driver.DeleteVolume(ctx, in.VolumeId); err != nil {
+		return nil, err
+	}
+	return &csipb.DeleteVolumeResponse{}, nil
+}
+
+// ControllerPublishVolume implements csipb.ControllerServer
+func (s *ControllerServer) ControllerPublishVolume(ctx context.Context, in *csipb.ControllerPublishVolumeRequest) (*csipb.ControllerPublishVolumeResponse, error) {
+	if in.VolumeId == "" || in.NodeId == "" {
+		msg := "Missing volume ID or node ID"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if err := validateCapabilities([]*csipb.VolumeCapability{in.VolumeCapability}); err != nil {
+		return nil, err
+	}
+	publishContext, err := s.driver.ControllerPublishVolume(ctx, in.VolumeId, in.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	return &csipb.ControllerPublishVolumeResponse{PublishContext: publishContext}, nil
+}
+
+// ControllerUnpublishVolume implements csipb.ControllerServer
+func (s *ControllerServer) ControllerUnpublishVolume(ctx context.Context, in *csipb.ControllerUnpublishVolumeRequest) (*csipb.ControllerUnpublishVolumeResponse, error) {
+	if in.VolumeId == "" || in.NodeId == "" {
+		msg := "Missing volume ID or node ID"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if err := s.driver.ControllerUnpublishVolume(ctx, in.VolumeId, in.NodeId); err != nil {
+		return nil, err
+	}
+	return &csipb.ControllerUnpublishVolumeResponse{}, nil
+}
+
+// ValidateVolumeCapabilities implements csipb.ControllerServer
+func (s *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, in *csipb.ValidateVolumeCapabilitiesRequest) (*csipb.ValidateVolumeCapabilitiesResponse, error) {
+	if in.VolumeId == "" {
+		msg := "Missing volume ID"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if _, err := s.driver.GetVolume(ctx, in.VolumeId); err != nil {
+		return nil, err
+	}
+	if err := validateCapabilities(in.VolumeCapabilities); err != nil {
+		return &csipb.ValidateVolumeCapabilitiesResponse{Message: status.Convert(err).Message()}, nil
+	}
+	return &csipb.ValidateVolumeCapabilitiesResponse{Confirmed: &csipb.ValidateVolumeCapabilitiesResponse_Confirmed{
+		VolumeContext:      in.VolumeContext,
+		VolumeCapabilities: in.VolumeCapabilities,
+		Parameters:         in.Parameters,
+	}}, nil
+}
+
+// ControllerGetCapabilities implements csipb.ControllerServer
+func (s *ControllerServer) ControllerGetCapabilities(_ context.Context, _ *csipb.ControllerGetCapabilitiesRequest) (*csipb.ControllerGetCapabilitiesResponse, error) {
+	response := &csipb.ControllerGetCapabilitiesResponse{}
+	for _, capability := range []csipb.ControllerServiceCapability_RPC_Type{
+		csipb.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csipb.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME,
+	} {
+		response.Capabilities = append(response.Capabilities, &csipb.ControllerServiceCapability{
+			Type: &csipb.ControllerServiceCapability_Rpc{Rpc: &csipb.ControllerServiceCapability_RPC{Type: capability}},
+		})
+	}
+	return response, nil
+}
+
+// NodeServer serves the CSI Node service of a host, the block devices of the volumes published
+// to its subsystem are bound, or formatted and mounted, on the target paths
+type NodeServer struct {
+	csipb.UnimplementedNodeServer
+	node    *Node
+	mounter Mounter
+	// nodeID is the ID of the subsystem emulated to the host
+	nodeID string
+}
+
+// NewNodeServer returns a NodeServer of the host whose emulated subsystem is nodeID, finding
+// the devices with node and mounting them with mounter
+func NewNodeServer(node *Node, mounter Mounter, nodeID string) *NodeServer {
+	return &NodeServer{node: node, mounter: mounter, nodeID: nodeID}
+}
+
+// NodeGetInfo implements csipb.NodeServer
+func (s *NodeServer) NodeGetInfo(_ context.Context, _ *csipb.NodeGetInfoRequest) (*csipb.NodeGetInfoResponse, error) {
+	return &csipb.NodeGetInfoResponse{NodeId: s.nodeID}, nil
+}
+
+// NodeGetCapabilities implements csipb.NodeServer, the volumes aren't staged
+func (s *NodeServer) NodeGetCapabilities(_ context.Context, _ *csipb.NodeGetCapabilitiesRequest) (*csipb.NodeGetCapabilitiesResponse, error) {
+	return &csipb.NodeGetCapabilitiesResponse{}, nil
+}
+
+// NodePublishVolume implements csipb.NodeServer, a block volume is bound on the target file, a
+// mounted one is formatted unless it has a filesystem already and mounted on the target
+// directory. It succeeds again once published
+func (s *NodeServer) NodePublishVolume(_ context.Context, in *csipb.NodePublishVolumeRequest) (*csipb.NodePublishVolumeResponse, error) {
+	if in.VolumeId == "" || in.TargetPath == "" {
+		msg := "Missing volume ID or target path"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if err := validateCapabilities([]*csipb.VolumeCapability{in.VolumeCapability}); err != nil {
+		return nil, err
+	}
+	device, err := s.node.FindDevice(in.PublishContext)
+	if err != nil {
+		return nil, err
+	}
+	mounted, err := s.mounter.IsMounted(in.TargetPath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	if mounted {
+		return &csipb.NodePublishVolumeResponse{}, nil
+	}
+	var options []string
+	if in.Readonly {
+		options = append(options, "ro")
+	}
+	if in.VolumeCapability.GetBlock() != nil {
+		if err := createFile(in.TargetPath); err != nil {
+			return nil, status.Errorf(codes.Internal, err.Error())
+		}
+		if err := s.mounter.Mount(device, in.TargetPath, "", append(options, "bind")); err != nil {
+			return nil, status.Errorf(codes.Internal, err.Error())
+		}
+		return &csipb.NodePublishVolumeResponse{}, nil
+	}
+	mount := in.VolumeCapability.GetMount()
+	fsType := mount.FsType
+	if fsType == "" {
+		fsType = DefaultFsType
+	}
+	if err := s.mounter.Format(device, fsType); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	if err := os.MkdirAll(in.TargetPath, 0o750); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	if err := s.mounter.Mount(device, in.TargetPath, fsType, append(options, mount.MountFlags...)); err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return &csipb.NodePublishVolumeResponse{}, nil
+}
+
+// NodeUnpublishVolume implements csipb.NodeServer, the target is unmounted and removed. It
+// succeeds again once unpublished
+func (s *NodeServer) NodeUnpublishVolume(_ context.Context, in *csipb.NodeUnpublishVolumeRequest) (*csipb.NodeUnpublishVolumeResponse, error) {
+	if in.VolumeId == "" || in.TargetPath == "" {
+		msg := "Missing volume ID or target path"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	mounted, err := s.mounter.IsMounted(in.TargetPath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	if mounted {
+		if err := s.mounter.Unmount(in.TargetPath); err != nil {
+			return nil, status.Errorf(codes.Internal, err.Error())
+		}
+	}
+	if err := os.Remove(in.TargetPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return &csipb.NodeUnpublishVolumeResponse{}, nil
+}
+
+// createFile creates the file a block device is bound on, with its directory
+func createFile(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Clean(file), os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,300 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package csi maps the volumes of a Container Storage Interface plugin to the lvols and the Nvme
+// namespaces of the bridge, so Kubernetes clusters consume the NVMe emulated by the DPU natively
+package csi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	csipb "github.com/container-storage-interface/spec/lib/go/csi"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testMounter records the calls and the mounts instead of running the commands
+type testMounter struct {
+	mounts map[string]string
+	calls  []string
+}
+
+func (m *testMounter) Format(device string, fsType string) error {
+	m.calls = append(m.calls, "format "+device+" "+fsType)
+	return nil
+}
+
+func (m *testMounter) Mount(source string, target string, fsType string, options []string) error {
+	m.calls = append(m.calls, "mount "+source+" "+fsType+" "+strings.Join(options, ","))
+	m.mounts[target] = source
+	return nil
+}
+
+func (m *testMounter) Unmount(target string) error {
+	m.calls = append(m.calls, "umount")
+	delete(m.mounts, target)
+	return nil
+}
+
+func (m *testMounter) IsMounted(target string) (bool, error) {
+	_, ok := m.mounts[target]
+	return ok, nil
+}
+
+// testCapability returns a capability of access type block or mount, and of access mode
+func testCapability(block bool, mode csipb.VolumeCapability_AccessMode_Mode) *csipb.VolumeCapability {
+	capability := &csipb.VolumeCapability{AccessMode: &csipb.VolumeCapability_AccessMode{Mode: mode}}
+	if block {
+		capability.AccessType = &csipb.VolumeCapability_Block{Block: &csipb.VolumeCapability_BlockVolume{}}
+	} else {
+		capability.AccessType = &csipb.VolumeCapability_Mount{Mount: &csipb.VolumeCapability_MountVolume{}}
+	}
+	return capability
+}
+
+func TestCSI_IdentityServer(t *testing.T) {
+	bridge := newTestBridge(t)
+	defer bridge.Close()
+	driver := NewDriver(NewClient(bridge.URL, bridge.Client(), "secret"), "//storage.opiproject.org/lvolStores/lvs0")
+	ctx := context.Background()
+
+	controller, err := NewIdentityServer(driver).GetPluginCapabilities(ctx, &csipb.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(controller.Capabilities) != 1 || controller.Capabilities[0].GetService().GetType() != csipb.PluginCapability_Service_CONTROLLER_SERVICE {
+		t.Error("capabilities: expected the controller service, received", controller.Capabilities)
+	}
+	node, err := NewIdentityServer(nil).GetPluginCapabilities(ctx, &csipb.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Capabilities) != 0 {
+		t.Error("capabilities: expected none, received", node.Capabilities)
+	}
+	if _, err := NewIdentityServer(driver).Probe(ctx, &csipb.ProbeRequest{}); err != nil {
+		t.Error("probe: expected ready, received", err)
+	}
+	bridge.Close()
+	_, err = NewIdentityServer(driver).Probe(ctx, &csipb.ProbeRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Error("error code: expected", codes.Unavailable, "received", status.Code(err))
+	}
+}
+
+func TestCSI_ControllerCreateVolume(t *testing.T) {
+	writer := []*csipb.VolumeCapability{testCapability(false, csipb.VolumeCapability_AccessMode_SINGLE_NODE_WRITER)}
+	tests := map[string]struct {
+		in      *csipb.CreateVolumeRequest
+		out     *csipb.Volume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"required bytes": {
+			in:      &csipb.CreateVolumeRequest{Name: "pvc-0001", CapacityRange: &csipb.CapacityRange{RequiredBytes: 2 << 30}, VolumeCapabilities: writer},
+			out:     &csipb.Volume{VolumeId: "pvc-0001", CapacityBytes: 2 << 30},
+			errCode: codes.OK,
+		},
+		"default capacity": {
+			in:      &csipb.CreateVolumeRequest{Name: "pvc-0001", VolumeCapabilities: writer},
+			out:     &csipb.Volume{VolumeId: "pvc-0001", CapacityBytes: DefaultCapacityBytes},
+			errCode: codes.OK,
+		},
+		"limit below required bytes": {
+			in:      &csipb.CreateVolumeRequest{Name: "pvc-0001", CapacityRange: &csipb.CapacityRange{RequiredBytes: 2 << 30, LimitBytes: 1 << 30}, VolumeCapabilities: writer},
+			errCode: codes.OutOfRange,
+			errMsg:  "Capacity (2147483648) is out of range, have to be at most the limit (1073741824)",
+		},
+		"missing capabilities": {
+			in:      &csipb.CreateVolumeRequest{Name: "pvc-0001"},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Missing volume capabilities",
+		},
+		"multi node mode": {
+			in: &csipb.CreateVolumeRequest{Name: "pvc-0001", VolumeCapabilities: []*csipb.VolumeCapability{
+				testCapability(true, csipb.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER),
+			}},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Access mode MULTI_NODE_MULTI_WRITER is not supported, have to be a single node mode",
+		},
+		"unsupported filesystem": {
+			in: &csipb.CreateVolumeRequest{Name: "pvc-0001", VolumeCapabilities: []*csipb.VolumeCapability{{
+				AccessType: &csipb.VolumeCapability_Mount{Mount: &csipb.VolumeCapability_MountVolume{FsType: "ext4 -E root_owner"}},
+				AccessMode: &csipb.VolumeCapability_AccessMode{Mode: csipb.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+			}}},
+			errCode: codes.InvalidArgument,
+			errMsg:  `Filesystem "ext4 -E root_owner" is not supported, have to be ext2, ext3, ext4 or xfs`,
+		},
+		"content source": {
+			in: &csipb.CreateVolumeRequest{Name: "pvc-0001", VolumeCapabilities: writer, VolumeContentSource: &csipb.VolumeContentSource{
+				Type: &csipb.VolumeContentSource_Snapshot{Snapshot: &csipb.VolumeContentSource_SnapshotSource{SnapshotId: "snap0"}},
+			}},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Volume content sources are not supported",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bridge := newTestBridge(t)
+			defer bridge.Close()
+			server := NewControllerServer(NewDriver(NewClient(bridge.URL, bridge.Client(), "secret"), "//storage.opiproject.org/lvolStores/lvs0"))
+
+			response, err := server.CreateVolume(context.Background(), tt.in)
+
+			if volume := response.GetVolume(); !reflect.DeepEqual(volume, tt.out) {
+				t.Error("response: expected", tt.out, "received", volume)
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestCSI_ControllerVolumeLifecycle(t *testing.T) {
+	bridge := newTestBridge(t)
+	defer bridge.Close()
+	server := NewControllerServer(NewDriver(NewClient(bridge.URL, bridge.Client(), "secret"), "//storage.opiproject.org/lvolStores/lvs0"))
+	ctx := context.Background()
+	capability := testCapability(true, csipb.VolumeCapability_AccessMode_SINGLE_NODE_WRITER)
+
+	_, err := server.CreateVolume(ctx, &csipb.CreateVolumeRequest{Name: "pvc-0001", VolumeCapabilities: []*csipb.VolumeCapability{capability}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validated, err := server.ValidateVolumeCapabilities(ctx, &csipb.ValidateVolumeCapabilitiesRequest{VolumeId: "pvc-0001", VolumeCapabilities: []*csipb.VolumeCapability{capability}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if validated.Confirmed == nil {
+		t.Error("validation: expected confirmed, received", validated.Message)
+	}
+	published, err := server.ControllerPublishVolume(ctx, &csipb.ControllerPublishVolumeRequest{VolumeId: "pvc-0001", NodeId: "subsys0", VolumeCapability: capability})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{PublishContextNqn: "nqn.2022-09.io.spdk:opi0", PublishContextNsid: "1"}
+	if !reflect.DeepEqual(published.PublishContext, expected) {
+		t.Error("publish context: expected", expected, "received", published.PublishContext)
+	}
+	if _, err := server.ControllerUnpublishVolume(ctx, &csipb.ControllerUnpublishVolumeRequest{VolumeId: "pvc-0001", NodeId: "subsys0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.DeleteVolume(ctx, &csipb.DeleteVolumeRequest{VolumeId: "pvc-0001"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = server.ValidateVolumeCapabilities(ctx, &csipb.ValidateVolumeCapabilitiesRequest{VolumeId: "pvc-0001", VolumeCapabilities: []*csipb.VolumeCapability{capability}})
+	if status.Code(err) != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", status.Code(err))
+	}
+}
+
+func TestCSI_NodePublishVolume(t *testing.T) {
+	sysfs := t.TempDir()
+	writeTestSysfs(t, sysfs, "nvme-subsys1", "nqn.2022-09.io.spdk:opi0", map[string]string{"nvme1n1": "1"})
+	publishContext := map[string]string{PublishContextNqn: "nqn.2022-09.io.spdk:opi0", PublishContextNsid: "1"}
+	tests := map[string]struct {
+		capability *csipb.VolumeCapability
+		readonly   bool
+		calls      []string
+	}{
+		"mount": {
+			capability: testCapability(false, csipb.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+			readonly:   false,
+			calls:      []string{"format /dev/nvme1n1 ext4", "mount /dev/nvme1n1 ext4 "},
+		},
+		"mount with flags": {
+			capability: &csipb.VolumeCapability{
+				AccessType: &csipb.VolumeCapability_Mount{Mount: &csipb.VolumeCapability_MountVolume{FsType: "xfs", MountFlags: []string{"noatime"}}},
+				AccessMode: &csipb.VolumeCapability_AccessMode{Mode: csipb.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY},
+			},
+			readonly: true,
+			calls:    []string{"format /dev/nvme1n1 xfs", "mount /dev/nvme1n1 xfs ro,noatime"},
+		},
+		"block": {
+			capability: testCapability(true, csipb.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+			readonly:   false,
+			calls:      []string{"mount /dev/nvme1n1  bind"},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mounter := &testMounter{mounts: make(map[string]string)}
+			server := NewNodeServer(NewNode(sysfs), mounter, "subsys0")
+			target := filepath.Join(t.TempDir(), "pods", "pvc-0001")
+			in := &csipb.NodePublishVolumeRequest{
+				VolumeId:         "pvc-0001",
+				PublishContext:   publishContext,
+				TargetPath:       target,
+				VolumeCapability: tt.capability,
+				Readonly:         tt.readonly,
+			}
+
+			// publishing again succeeds without mounting again
+			for i := 0; i < 2; i++ {
+				if _, err := server.NodePublishVolume(context.Background(), in); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual(mounter.calls, tt.calls) {
+				t.Error("calls: expected", tt.calls, "received", mounter.calls)
+			}
+			if _, err := os.Stat(target); err != nil {
+				t.Error("target: expected to exist, received", err)
+			}
+
+			// unpublishing again succeeds
+			for i := 0; i < 2; i++ {
+				if _, err := server.NodeUnpublishVolume(context.Background(), &csipb.NodeUnpublishVolumeRequest{VolumeId: "pvc-0001", TargetPath: target}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if len(mounter.mounts) != 0 {
+				t.Error("mounts: expected none, received", mounter.mounts)
+			}
+			if _, err := os.Stat(target); !os.IsNotExist(err) {
+				t.Error("target: expected to be removed, received", err)
+			}
+		})
+	}
+}
+
+func TestCSI_NodePublishVolumeFsType(t *testing.T) {
+	sysfs := t.TempDir()
+	writeTestSysfs(t, sysfs, "nvme-subsys1", "nqn.2022-09.io.spdk:opi0", map[string]string{"nvme1n1": "1"})
+	mounter := &testMounter{mounts: make(map[string]string)}
+	server := NewNodeServer(NewNode(sysfs), mounter, "subsys0")
+
+	_, err := server.NodePublishVolume(context.Background(), &csipb.NodePublishVolumeRequest{
+		VolumeId:       "pvc-0001",
+		PublishContext: map[string]string{PublishContextNqn: "nqn.2022-09.io.spdk:opi0", PublishContextNsid: "1"},
+		TargetPath:     filepath.Join(t.TempDir(), "pods", "pvc-0001"),
+		VolumeCapability: &csipb.VolumeCapability{
+			AccessType: &csipb.VolumeCapability_Mount{Mount: &csipb.VolumeCapability_MountVolume{FsType: "../../tmp/x"}},
+			AccessMode: &csipb.VolumeCapability_AccessMode{Mode: csipb.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+	})
+
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error("error code: expected", codes.InvalidArgument, "received", status.Code(err))
+	}
+	if len(mounter.calls) != 0 {
+		t.Error("calls: expected none, received", mounter.calls)
+	}
+}
@@ -0,0 +1,506 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"gopkg.in/yaml.v3"
+)
+
+// Actions taken on the declared resources
+const (
+	actionCreate    = "create"
+	actionUpdate    = "update"
+	actionDelete    = "delete"
+	actionUnchanged = "unchanged"
+)
+
+// stateAbsent is the state of the declared resources which are deleted
+const stateAbsent = "absent"
+
+// kind is a type of resource of the bridge, with the methods managing it
+type kind struct {
+	name   protoreflect.Name
+	create protoreflect.MethodDescriptor
+	get    protoreflect.MethodDescriptor
+	update protoreflect.MethodDescriptor
+	delete protoreflect.MethodDescriptor
+	list   protoreflect.MethodDescriptor
+	// field is the field of the resource in the create, update and list requests and responses
+	field protoreflect.FieldDescriptor
+	// listField is the field of the resources in the list responses
+	listField protoreflect.FieldDescriptor
+}
+
+// declaration is a resource declared in the applied file
+type declaration struct {
+	kind  *kind
+	name  string
+	state string
+	// resource are the declared fields of the resource, with the names of the proto fields
+	resource map[string]interface{}
+}
+
+// change is what apply does to a resource
+type change struct {
+	action   string
+	kind     *kind
+	name     string
+	resource protoreflect.Message
+	// prior is the resource before the change, to roll the updates and the deletions back
+	prior protoreflect.Message
+}
+
+// undo returns the change rolling c back
+func (c *change) undo() *change {
+	switch c.action {
+	case actionCreate:
+		return &change{action: actionDelete, kind: c.kind, name: c.name}
+	case actionDelete:
+		// the status is output only, the resource reports it again once created
+		resource := proto.Clone(c.prior.Interface()).ProtoReflect()
+		if field := resource.Descriptor().Fields().ByName("status"); field != nil {
+			resource.Clear(field)
+		}
+		return &change{action: actionCreate, kind: c.kind, name: c.name, resource: resource}
+	default:
+		return &change{action: actionUpdate, kind: c.kind, name: c.name, resource: c.prior}
+	}
+}
+
+// findKinds returns the kinds of resources which can be created by the services of the bridge
+// found in files, by name
+func findKinds(files *protoregistry.Files) map[string]*kind {
+	kinds := make(map[string]*kind)
+	for _, group := range serviceGroups {
+		for _, name := range group.services {
+			descriptor, err := files.FindDescriptorByName(name)
+			if err != nil {
+				continue
+			}
+			service, ok := descriptor.(protoreflect.ServiceDescriptor)
+			if !ok {
+				continue
+			}
+			methods := service.Methods()
+			for i := 0; i < methods.Len(); i++ {
+				create := methods.Get(i)
+				resource, found := strings.CutPrefix(string(create.Name()), "Create")
+				if !found {
+					continue
+				}
+				k := &kind{
+					name:   protoreflect.Name(resource),
+					create: create,
+					get:    methods.ByName(protoreflect.Name("Get" + resource)),
+					update: methods.ByName(protoreflect.Name("Update" + resource)),
+					delete: methods.ByName(protoreflect.Name("Delete" + resource)),
+					field:  messageField(create.Input(), create.Output().FullName()),
+				}
+				for j := 0; j < methods.Len(); j++ {
+					list := methods.Get(j)
+					if strings.HasPrefix(string(list.Name()), "List") {
+						if field := messageField(list.Output(), create.Output().FullName()); field != nil && field.IsList() {
+							k.list, k.listField = list, field
+						}
+					}
+				}
+				if k.get == nil || k.delete == nil || k.field == nil {
+					continue
+				}
+				kinds[resource] = k
+			}
+		}
+	}
+	return kinds
+}
+
+// messageField returns the field of a message holding a message of type name, nil when none does
+func messageField(message protoreflect.MessageDescriptor, name protoreflect.FullName) protoreflect.FieldDescriptor {
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if field := fields.Get(i); field.Message() != nil && field.Message().FullName() == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// nameParent returns the parent of a resource and its ID, i.e.
+// //storage.opiproject.org/nvmeSubsystems/subsys0 and ctrl0 for
+// //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0, the parent being empty
+// for the resources which have none
+func nameParent(name string) (string, string) {
+	segments := strings.Split(strings.TrimPrefix(name, "//"), "/")
+	id := segments[len(segments)-1]
+	if len(segments) <= 3 {
+		return "", id
+	}
+	return "//" + strings.Join(segments[:len(segments)-2], "/"), id
+}
+
+// newApplyCommand returns the command applying a file of desired resources
+func (o *options) newApplyCommand(files *protoregistry.Files) *cobra.Command {
+	var file string
+	var prune, dryRun bool
+	kinds := findKinds(files)
+	kindNames := make([]string, 0, len(kinds))
+	for name := range kinds {
+		kindNames = append(kindNames, name)
+	}
+	sort.Strings(kindNames)
+	command := &cobra.Command{
+		Use:   "apply",
+		Short: "Create, update or delete the resources of the bridge to match a YAML file of desired resources",
+		Long: "Create, update or delete the resources of the bridge to match a YAML file of desired resources\n\n" +
+			"The file has a document per resource, its kind, its full name and its fields as in the API. " +
+			"The resources which don't exist are created, the ones whose declared fields differ are updated and " +
+			"the ones declared with state: absent are deleted, the children first. With --prune, the resources of " +
+			"the declared kinds under the same parents which aren't declared are deleted too. Applying the same " +
+			"file again changes nothing. When a change fails, the ones made are rolled back, the last first, so the " +
+			"bridge is back to the resources it had\n\nThe kinds are " + strings.Join(kindNames, ", "),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if file == "" {
+				return errors.New("the file of the resources has to be given with --file")
+			}
+			content, err := readFile(cmd.InOrStdin(), file)
+			if err != nil {
+				return err
+			}
+			declarations, err := parseDeclarations(content, kinds)
+			if err != nil {
+				return err
+			}
+			profile, err := o.resolveProfile()
+			if err != nil {
+				return err
+			}
+			conn, err := dial(profile)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = conn.Close() }()
+			ctx, cancel := context.WithTimeout(cmd.Context(), o.timeout)
+			defer cancel()
+			ctx = withCredentials(ctx, profile)
+			changes, err := plan(ctx, conn, declarations, prune)
+			if err != nil {
+				return err
+			}
+			return applyChanges(ctx, conn, cmd.OutOrStdout(), changes, dryRun, o.timeout)
+		},
+	}
+	command.Flags().StringVarP(&file, "file", "f", "", "YAML file of the resources, stdin when -")
+	command.Flags().BoolVar(&prune, "prune", false, "Delete the resources of the declared kinds, under the same parents, which aren't declared")
+	command.Flags().BoolVar(&dryRun, "dry_run", false, "Print the changes without making them")
+	return command
+}
+
+// readFile reads file, stdin when -
+func readFile(stdin io.Reader, file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(file)
+}
+
+// parseDeclarations parses the documents of the applied file
+func parseDeclarations(content []byte, kinds map[string]*kind) ([]*declaration, error) {
+	var declarations []*declaration
+	seen := make(map[string]bool)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for i := 1; ; i++ {
+		document := make(map[string]interface{})
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return declarations, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid document %d: %w", i, err)
+		}
+		if len(document) == 0 {
+			continue
+		}
+		kindName, _ := document["kind"].(string)
+		name, _ := document["name"].(string)
+		state, _ := document["state"].(string)
+		delete(document, "kind")
+		delete(document, "state")
+		k, ok := kinds[kindName]
+		if !ok {
+			return nil, fmt.Errorf("invalid document %d: unknown kind %q", i, kindName)
+		}
+		if !strings.HasPrefix(name, "//") {
+			return nil, fmt.Errorf("invalid document %d: the full name of the %s has to be set, i.e. //storage.opiproject.org/...", i, kindName)
+		}
+		if state != "" && state != stateAbsent {
+			return nil, fmt.Errorf("invalid document %d: invalid state %q, have to be empty or %s", i, state, stateAbsent)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid document %d: %s is declared twice", i, name)
+		}
+		seen[name] = true
+		// the fields are checked and named as in the proto
+		resource, err := newResource(k, document)
+		if err != nil {
+			return nil, fmt.Errorf("invalid document %d: %w", i, err)
+		}
+		fields, err := toMap(resource)
+		if err != nil {
+			return nil, err
+		}
+		declarations = append(declarations, &declaration{kind: k, name: name, state: state, resource: fields})
+	}
+}
+
+// newResource returns the resource of a kind with fields
+func newResource(k *kind, fields map[string]interface{}) (*dynamicpb.Message, error) {
+	content, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", k.name, err)
+	}
+	resource := dynamicpb.NewMessage(k.field.Message())
+	if err := protojson.Unmarshal(content, resource); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", k.name, err)
+	}
+	return resource, nil
+}
+
+// plan compares the declared resources with the ones of the bridge and returns the changes, the
+// creations and updates in the declared order followed by the deletions, the children first
+func plan(ctx context.Context, conn *grpc.ClientConn, declarations []*declaration, prune bool) ([]*change, error) {
+	var changes, deletions []*change
+	declared := make(map[string]bool)
+	for _, d := range declarations {
+		declared[d.name] = true
+		existing, err := get(ctx, conn, d.kind, d.name)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case d.state == stateAbsent && existing == nil:
+		case d.state == stateAbsent:
+			deletions = append(deletions, &change{action: actionDelete, kind: d.kind, name: d.name, prior: existing})
+		case existing == nil:
+			resource, err := newResource(d.kind, d.resource)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, &change{action: actionCreate, kind: d.kind, name: d.name, resource: resource})
+		default:
+			current, err := toMap(existing)
+			if err != nil {
+				return nil, err
+			}
+			action := actionUnchanged
+			if !contains(current, d.resource) {
+				action = actionUpdate
+			}
+			resource, err := newResource(d.kind, d.resource)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, &change{action: action, kind: d.kind, name: d.name, resource: resource, prior: existing})
+		}
+	}
+	if prune {
+		pruned, err := listUndeclared(ctx, conn, declarations, declared)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, pruned...)
+	}
+	// the children are deleted before their parent
+	sort.SliceStable(deletions, func(i, j int) bool {
+		return strings.Count(deletions[i].name, "/") > strings.Count(deletions[j].name, "/")
+	})
+	return append(changes, deletions...), nil
+}
+
+// get returns a resource of the bridge, nil when it doesn't exist
+func get(ctx context.Context, conn *grpc.ClientConn, k *kind, name string) (*dynamicpb.Message, error) {
+	in, err := newRequest(k.get, map[string]interface{}{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	out := dynamicpb.NewMessage(k.get.Output())
+	fullMethod := fmt.Sprintf("/%s/%s", k.get.Parent().FullName(), k.get.Name())
+	if err := conn.Invoke(ctx, fullMethod, in, out); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s %s: %s: %s", k.get.Name(), name, status.Code(err), status.Convert(err).Message())
+	}
+	return out, nil
+}
+
+// listUndeclared returns the deletions of the resources of the declared kinds, under the
+// parents of the declared ones, which aren't declared
+func listUndeclared(ctx context.Context, conn *grpc.ClientConn, declarations []*declaration, declared map[string]bool) ([]*change, error) {
+	var deletions []*change
+	listed := make(map[string]bool)
+	for _, d := range declarations {
+		parent, _ := nameParent(d.name)
+		key := string(d.kind.name) + " " + parent
+		if d.kind.list == nil || listed[key] {
+			continue
+		}
+		listed[key] = true
+		token := ""
+		for {
+			request := map[string]interface{}{"page_token": token}
+			if parent != "" {
+				request["parent"] = parent
+			}
+			in, err := newRequest(d.kind.list, request)
+			if err != nil {
+				return nil, err
+			}
+			out, err := invoke(ctx, conn, d.kind.list, in)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", d.kind.list.Name(), err)
+			}
+			resources := out.Get(d.kind.listField).List()
+			for i := 0; i < resources.Len(); i++ {
+				resource := resources.Get(i).Message()
+				name := resource.Get(resource.Descriptor().Fields().ByName("name")).String()
+				if !declared[name] {
+					declared[name] = true
+					deletions = append(deletions, &change{action: actionDelete, kind: d.kind, name: name, prior: resource})
+				}
+			}
+			token = out.Get(d.kind.list.Output().Fields().ByName(nextPageTokenField)).String()
+			if token == "" {
+				break
+			}
+		}
+	}
+	return deletions, nil
+}
+
+// applyChanges makes the changes, printing each of them, stopping at the first failure and
+// rolling back the ones made, each in timeout
+func applyChanges(ctx context.Context, conn *grpc.ClientConn, w io.Writer, changes []*change, dryRun bool, timeout time.Duration) error {
+	var applied []*change
+	for _, c := range changes {
+		if c.action != actionUnchanged && !dryRun {
+			if err := applyChange(ctx, conn, c); err != nil {
+				err = fmt.Errorf("%s %s %s: %w", c.action, c.kind.name, c.name, err)
+				return rollbackChanges(ctx, conn, w, applied, timeout, err)
+			}
+			applied = append(applied, c)
+		}
+		action := c.action + "d"
+		switch {
+		case c.action == actionUnchanged:
+			action = c.action
+		case dryRun:
+			action = "would " + c.action
+		}
+		fmt.Fprintf(w, "%s %s %s\n", action, c.kind.name, c.name)
+	}
+	return nil
+}
+
+// rollbackChanges undoes the applied changes, the last first, printing each of them, and returns
+// the error of the change which failed. The rollback goes on when the apply timed out
+func rollbackChanges(ctx context.Context, conn *grpc.ClientConn, w io.Writer, applied []*change, timeout time.Duration, cause error) error {
+	if len(applied) == 0 {
+		return cause
+	}
+	var failures []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		c := applied[i]
+		undoCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		err := applyChange(undoCtx, conn, c.undo())
+		cancel()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s %s %s: %v", c.undo().action, c.kind.name, c.name, err))
+			continue
+		}
+		fmt.Fprintf(w, "rolled back %sd %s %s\n", c.action, c.kind.name, c.name)
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("%w, %d of the %d applied changes could not be rolled back: %s", cause, len(failures), len(applied), strings.Join(failures, "; "))
+	}
+	return fmt.Errorf("%w, the %d applied changes were rolled back", cause, len(applied))
+}
+
+// applyChange creates, updates or deletes a resource
+func applyChange(ctx context.Context, conn *grpc.ClientConn, c *change) error {
+	switch c.action {
+	case actionCreate:
+		parent, id := nameParent(c.name)
+		in := dynamicpb.NewMessage(c.kind.create.Input())
+		in.Set(c.kind.field, protoreflect.ValueOfMessage(c.resource))
+		fields := c.kind.create.Input().Fields()
+		if field := fields.ByName(c.kind.field.Name() + "_id"); field != nil {
+			in.Set(field, protoreflect.ValueOfString(id))
+		}
+		if field := fields.ByName("parent"); field != nil && parent != "" {
+			in.Set(field, protoreflect.ValueOfString(parent))
+		}
+		_, err := invoke(ctx, conn, c.kind.create, in)
+		return err
+	case actionUpdate:
+		if c.kind.update == nil {
+			return fmt.Errorf("%s can't be updated, it has to be deleted and created again", c.kind.name)
+		}
+		in := dynamicpb.NewMessage(c.kind.update.Input())
+		in.Set(messageField(c.kind.update.Input(), c.kind.field.Message().FullName()), protoreflect.ValueOfMessage(c.resource))
+		_, err := invoke(ctx, conn, c.kind.update, in)
+		return err
+	case actionDelete:
+		in, err := newRequest(c.kind.delete, map[string]interface{}{"name": c.name})
+		if err != nil {
+			return err
+		}
+		_, err = invoke(ctx, conn, c.kind.delete, in)
+		return err
+	}
+	return nil
+}
+
+// contains tells whether the declared fields of a resource have the same values in the current
+// one, the fields which aren't declared being ignored
+func contains(current map[string]interface{}, declared map[string]interface{}) bool {
+	for key, value := range declared {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			if !reflect.DeepEqual(current[key], value) {
+				return false
+			}
+			continue
+		}
+		currentNested, ok := current[key].(map[string]interface{})
+		if !ok || !contains(currentNested, nested) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// testStore are the resources of the stateful test server, by name
+type testStore struct {
+	mu        sync.Mutex
+	resources map[string]protoreflect.Message
+}
+
+// names returns the names of the resources, sorted
+func (s *testStore) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.resources))
+	for name := range s.resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// startStoreServer serves the subsystems and the controllers of the test frontend Nvme service
+// from a store, and returns its address
+func startStoreServer(t *testing.T, files *protoregistry.Files) (string, *testStore) {
+	t.Helper()
+	descriptor, err := files.FindDescriptorByName("opi_api.storage.v1.FrontendNvmeService")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := descriptor.(protoreflect.ServiceDescriptor)
+	store := &testStore{resources: make(map[string]protoreflect.Message)}
+	collections := map[string]string{"NvmeSubsystem": "nvmeSubsystems", "NvmeController": "nvmeControllers"}
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		fullMethod, _ := grpc.MethodFromServerStream(stream)
+		method := service.Methods().ByName(protoreflect.Name(fullMethod[strings.LastIndex(fullMethod, "/")+1:]))
+		if method == nil {
+			return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+		}
+		in := dynamicpb.NewMessage(method.Input())
+		if err := stream.RecvMsg(in); err != nil {
+			return err
+		}
+		fields := method.Input().Fields()
+		text := func(field protoreflect.Name) string {
+			if fd := fields.ByName(field); fd != nil {
+				return in.Get(fd).String()
+			}
+			return ""
+		}
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		var out protoreflect.Message = dynamicpb.NewMessage(method.Output())
+		switch name := string(method.Name()); {
+		case strings.HasPrefix(name, "Create"):
+			kind := strings.TrimPrefix(name, "Create")
+			parent := text("parent")
+			if parent == "" {
+				parent = "//storage.opiproject.org"
+			}
+			resource := in.Get(messageField(method.Input(), method.Output().FullName())).Message()
+			resourceName := parent + "/" + collections[kind] + "/" + in.Get(fields.ByName(protoreflect.Name(strings.ToLower(kind[:4])+"_"+strings.ToLower(kind[4:])+"_id"))).String()
+			if _, ok := store.resources[resourceName]; ok {
+				return status.Errorf(codes.AlreadyExists, "%s already exists", resourceName)
+			}
+			resource.Set(resource.Descriptor().Fields().ByName("name"), protoreflect.ValueOfString(resourceName))
+			store.resources[resourceName] = resource
+			out = resource
+		case strings.HasPrefix(name, "Update"):
+			resource := in.Get(messageField(method.Input(), method.Output().FullName())).Message()
+			resourceName := resource.Get(resource.Descriptor().Fields().ByName("name")).String()
+			if _, ok := store.resources[resourceName]; !ok {
+				return status.Errorf(codes.NotFound, "unable to find key %s", resourceName)
+			}
+			store.resources[resourceName] = resource
+			out = resource
+		case strings.HasPrefix(name, "Get"):
+			resource, ok := store.resources[text("name")]
+			if !ok {
+				return status.Errorf(codes.NotFound, "unable to find key %s", text("name"))
+			}
+			out = resource
+		case strings.HasPrefix(name, "Delete"):
+			if _, ok := store.resources[text("name")]; !ok {
+				return status.Errorf(codes.NotFound, "unable to find key %s", text("name"))
+			}
+			delete(store.resources, text("name"))
+		case strings.HasPrefix(name, "List"):
+			listField := method.Output().Fields().ByName(protoreflect.Name(strings.ToLower(name[4:8]) + "_" + strings.ToLower(name[8:])))
+			list := out.Mutable(listField).List()
+			for resourceName, resource := range store.resources {
+				parent, _ := nameParent(resourceName)
+				if resource.Descriptor() == listField.Message() && parent == text("parent") {
+					list.Append(protoreflect.ValueOfMessage(resource))
+				}
+			}
+		}
+		return stream.SendMsg(out)
+	}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), store
+}
+
+func TestCtl_Apply(t *testing.T) {
+	files := testFiles(t)
+	address, store := startStoreServer(t, files)
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	resources := `kind: NvmeSubsystem
+name: //storage.opiproject.org/nvmeSubsystems/subsys0
+spec:
+  nqn: nqn.2022-09.io.spdk:opi0
+  maxNamespaces: 32
+---
+kind: NvmeController
+name: //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0
+spec:
+  pcie_id:
+    physical_function: 1
+`
+	steps := []struct {
+		resources string
+		flags     []string
+		expected  []string
+		errMsg    string
+		names     []string
+	}{
+		{
+			resources: resources,
+			flags:     []string{"--dry_run"},
+			expected: []string{
+				"would create NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys0",
+				"would create NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+			},
+		},
+		{
+			resources: resources,
+			expected: []string{
+				"created NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys0",
+				"created NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+			},
+			names: []string{"//storage.opiproject.org/nvmeSubsystems/subsys0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0"},
+		},
+		{
+			resources: resources,
+			expected: []string{
+				"unchanged NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys0",
+				"unchanged NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+			},
+			names: []string{"//storage.opiproject.org/nvmeSubsystems/subsys0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0"},
+		},
+		{
+			resources: strings.Replace(resources, "maxNamespaces: 32", "maxNamespaces: 64", 1) + `---
+kind: NvmeController
+name: //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl1
+`,
+			expected: []string{
+				"updated NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys0",
+				"unchanged NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0",
+				"created NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl1",
+			},
+			names: []string{"//storage.opiproject.org/nvmeSubsystems/subsys0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl1"},
+		},
+		{
+			// the update of the subsystem is rolled back
+			resources: strings.Replace(resources, "physical_function: 1", "physical_function: 2", 1),
+			expected:  []string{"rolled back updated NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys0"},
+			errMsg:    "update NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0: NvmeController can't be updated, it has to be deleted and created again, the 1 applied changes were rolled back",
+			names:     []string{"//storage.opiproject.org/nvmeSubsystems/subsys0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl1"},
+		},
+		{
+			// the creation and the update of the subsystems are rolled back, the controller isn't deleted
+			resources: "kind: NvmeSubsystem\nname: //storage.opiproject.org/nvmeSubsystems/subsys1\n---\n" + strings.Replace(resources, "physical_function: 1", "physical_function: 2", 1) + `---
+kind: NvmeController
+name: //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl1
+state: absent
+`,
+			expected: []string{
+				"rolled back updated NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys0",
+				"rolled back created NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys1",
+			},
+			errMsg: "update NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0: NvmeController can't be updated, it has to be deleted and created again, the 2 applied changes were rolled back",
+			names:  []string{"//storage.opiproject.org/nvmeSubsystems/subsys0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl1"},
+		},
+		{
+			resources: resources,
+			flags:     []string{"--prune"},
+			expected: []string{
+				"updated NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys0",
+				"deleted NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl1",
+			},
+			names: []string{"//storage.opiproject.org/nvmeSubsystems/subsys0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0"},
+		},
+		{
+			resources: strings.ReplaceAll(resources, "\nspec:", "\nstate: absent\nspec:"),
+			expected: []string{
+				"deleted NvmeController //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0\ndeleted NvmeSubsystem //storage.opiproject.org/nvmeSubsystems/subsys0",
+			},
+			names: []string{},
+		},
+		{
+			resources: "kind: NvmeNamespace\nname: //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeNamespaces/ns0\n",
+			errMsg:    `invalid document 1: unknown kind "NvmeNamespace"`,
+		},
+		{
+			resources: "kind: NvmeSubsystem\nname: subsys0\n",
+			errMsg:    "invalid document 1: the full name of the NvmeSubsystem has to be set",
+		},
+		{
+			resources: "kind: NvmeSubsystem\nname: //storage.opiproject.org/nvmeSubsystems/subsys0\nspec:\n  unknown: 1\n",
+			errMsg:    "invalid document 1: invalid NvmeSubsystem",
+		},
+	}
+
+	// run tests
+	for i, step := range steps {
+		file := filepath.Join(dir, "resources.yaml")
+		if err := os.WriteFile(file, []byte(step.resources), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		output, err := run(files, append([]string{"apply", "-f", file, "-a", address, "--config", config}, step.flags...)...)
+		if step.errMsg != "" && (err == nil || !strings.HasPrefix(err.Error(), step.errMsg)) {
+			t.Error("step", i, "error: expected", step.errMsg, "received", err)
+		}
+		if step.errMsg == "" && err != nil {
+			t.Fatal("step", i, err)
+		}
+		for _, expected := range step.expected {
+			if !strings.Contains(output, expected+"\n") {
+				t.Error("step", i, "output: expected", expected, "received", output)
+			}
+		}
+		if step.names != nil && strings.Join(store.names(), " ") != strings.Join(step.names, " ") {
+			t.Error("step", i, "resources: expected", step.names, "received", store.names())
+		}
+	}
+}
+
+func TestCtl_NameParent(t *testing.T) {
+	tests := map[string]struct {
+		parent string
+		id     string
+	}{
+		"//storage.opiproject.org/nvmeSubsystems/subsys0":                       {"", "subsys0"},
+		"//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0": {"//storage.opiproject.org/nvmeSubsystems/subsys0", "ctrl0"},
+		"//storage.opiproject.org/volumes/nvmetcp12":                            {"", "nvmetcp12"},
+		"//storage.opiproject.org/volumes/nvmetcp12/nvmePaths/nvmetcp12path0":   {"//storage.opiproject.org/volumes/nvmetcp12", "nvmetcp12path0"},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		parent, id := nameParent(name)
+		if parent != tt.parent || id != tt.id {
+			t.Error("name", name, "expected", tt.parent, tt.id, "received", parent, id)
+		}
+	}
+}
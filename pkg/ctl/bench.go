@@ -0,0 +1,420 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"gopkg.in/yaml.v3"
+)
+
+// benchService is the service the benchmark calls the methods of the subsystems of
+const benchService protoreflect.FullName = "opi_api.storage.v1.FrontendNvmeService"
+
+// benchNqnPrefix prefixes the ID of the subsystems created by the benchmark in their NQN
+const benchNqnPrefix = "nqn.2022-09.io.spdk:"
+
+// maxBenchErrors is the number of distinct errors reported
+const maxBenchErrors = 5
+
+// benchMethods are the methods of the operations of the benchmark, by operation, the operations
+// other than create and list call them on a subsystem created by the benchmark
+var benchMethods = map[string]protoreflect.Name{
+	"create": "CreateNvmeSubsystem",
+	"delete": "DeleteNvmeSubsystem",
+	"get":    "GetNvmeSubsystem",
+	"list":   "ListNvmeSubsystems",
+	"stats":  "StatsNvmeSubsystem",
+}
+
+// benchOptions are the flags of the benchmark
+type benchOptions struct {
+	mix         string
+	duration    time.Duration
+	requests    int64
+	concurrency int
+	prefix      string
+	keep        bool
+}
+
+// benchResult are the calls of an operation, or of all of them, and their latencies
+type benchResult struct {
+	Operation   string  `json:"operation" yaml:"operation"`
+	Calls       int     `json:"calls" yaml:"calls"`
+	Errors      int     `json:"errors" yaml:"errors"`
+	CallsPerSec float64 `json:"callsPerSec" yaml:"callsPerSec"`
+	MinMs       float64 `json:"minMs" yaml:"minMs"`
+	P50Ms       float64 `json:"p50Ms" yaml:"p50Ms"`
+	P90Ms       float64 `json:"p90Ms" yaml:"p90Ms"`
+	P99Ms       float64 `json:"p99Ms" yaml:"p99Ms"`
+	MaxMs       float64 `json:"maxMs" yaml:"maxMs"`
+}
+
+// benchReport is what the benchmark reports once done
+type benchReport struct {
+	DurationSec float64        `json:"durationSec" yaml:"durationSec"`
+	Concurrency int            `json:"concurrency" yaml:"concurrency"`
+	Operations  []*benchResult `json:"operations" yaml:"operations"`
+	Total       *benchResult   `json:"total" yaml:"total"`
+	// Errors are the first distinct errors
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// benchmark calls the methods of the subsystems from concurrent workers, each going through the
+// schedule of the mix
+type benchmark struct {
+	service  protoreflect.ServiceDescriptor
+	profile  *Profile
+	conn     *grpc.ClientConn
+	options  benchOptions
+	timeout  time.Duration
+	schedule []string
+	calls    atomic.Int64
+	mu       sync.Mutex
+	// created are the names of the subsystems created and not deleted yet
+	created   []string
+	next      int
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	messages  []string
+}
+
+// newBenchCommand returns the command benchmarking the bridge, none when the frontend Nvme
+// service isn't described in files
+func (o *options) newBenchCommand(files *protoregistry.Files) *cobra.Command {
+	descriptor, err := files.FindDescriptorByName(benchService)
+	if err != nil {
+		return nil
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	b := benchOptions{}
+	command := &cobra.Command{
+		Use:   "bench",
+		Short: "Load the bridge with a mix of calls on the Nvme subsystems and report their throughput and latencies",
+		Long: "Load the bridge with a mix of calls on the Nvme subsystems and report their throughput and latencies\n\n" +
+			"The --concurrency workers call the operations of --mix, create, delete, get, list and stats, in proportion " +
+			"of their weights, for --duration or until --requests calls are made. The delete, get and stats operations " +
+			"are called on the subsystems created by the benchmark, a subsystem is created instead when there is none. " +
+			"The subsystems left are deleted once done, unless --keep",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			schedule, err := parseMix(b.mix)
+			if err != nil {
+				return err
+			}
+			if b.concurrency <= 0 {
+				return fmt.Errorf("invalid concurrency %d, have to be positive", b.concurrency)
+			}
+			if b.duration <= 0 && b.requests <= 0 {
+				return fmt.Errorf("invalid duration %v and requests %d, one of them has to be positive", b.duration, b.requests)
+			}
+			for _, operation := range schedule {
+				if service.Methods().ByName(benchMethods[operation]) == nil {
+					return fmt.Errorf("%s: unknown method", benchMethods[operation])
+				}
+			}
+			profile, err := o.resolveProfile()
+			if err != nil {
+				return err
+			}
+			if err := checkOutputFormat(profile.Output); err != nil {
+				return err
+			}
+			conn, err := dial(profile)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = conn.Close() }()
+			bench := &benchmark{
+				service:   service,
+				profile:   profile,
+				conn:      conn,
+				options:   b,
+				timeout:   o.timeout,
+				schedule:  schedule,
+				latencies: make(map[string][]time.Duration),
+				errors:    make(map[string]int),
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			report := bench.run(ctx)
+			return printBenchReport(cmd.OutOrStdout(), profile.Output, report)
+		},
+	}
+	command.Flags().StringVar(&b.mix, "mix", "create=1,delete=1,get=2,list=4,stats=2", "Operations called, with their weights, in operation=weight format, comma separated")
+	command.Flags().DurationVar(&b.duration, "duration", 10*time.Second, "Duration of the benchmark, until --requests calls are made when 0")
+	command.Flags().Int64Var(&b.requests, "requests", 0, "Number of calls made, for --duration when 0")
+	command.Flags().IntVar(&b.concurrency, "concurrency", 4, "Number of concurrent workers")
+	command.Flags().StringVar(&b.prefix, "prefix", "bench", "Prefix of the IDs of the subsystems created by the benchmark")
+	command.Flags().BoolVar(&b.keep, "keep", false, "Keep the subsystems created by the benchmark")
+	return command
+}
+
+// parseMix returns the schedule of a mix, each operation as many times as its weight
+func parseMix(mix string) ([]string, error) {
+	var schedule []string
+	for _, entry := range strings.Split(mix, ",") {
+		operation, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+		weight, err := strconv.Atoi(value)
+		if _, ok := benchMethods[operation]; !ok || !found || err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid mix entry %q, have to be operation=weight, the operations being create, delete, get, list and stats", entry)
+		}
+		for i := 0; i < weight; i++ {
+			schedule = append(schedule, operation)
+		}
+	}
+	if len(schedule) == 0 {
+		return nil, fmt.Errorf("invalid mix %q, no operation has a weight", mix)
+	}
+	return schedule, nil
+}
+
+// run runs the workers until the duration elapsed, the requests are made or ctx is done, and
+// reports the calls
+func (b *benchmark) run(ctx context.Context) *benchReport {
+	if b.options.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.options.duration)
+		defer cancel()
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for worker := 0; worker < b.options.concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			b.work(ctx, worker)
+		}(worker)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if !b.options.keep {
+		b.cleanup()
+	}
+	return b.report(elapsed)
+}
+
+// work calls the operations of the schedule, from an offset of its own so the workers don't call
+// the same operation at the same time
+func (b *benchmark) work(ctx context.Context, worker int) {
+	for i := worker; ctx.Err() == nil; i++ {
+		if b.options.requests > 0 && b.calls.Add(1) > b.options.requests {
+			return
+		}
+		b.call(ctx, b.schedule[i%len(b.schedule)], fmt.Sprintf("%s-%d-%d", b.options.prefix, worker, i))
+	}
+}
+
+// call calls an operation and records its latency, id being the ID of the subsystem created
+func (b *benchmark) call(ctx context.Context, operation string, id string) {
+	var name string
+	if operation != "create" && operation != "list" {
+		if name = b.pick(operation == "delete"); name == "" {
+			operation = "create"
+		}
+	}
+	request := map[string]interface{}{}
+	switch operation {
+	case "create":
+		request["nvme_subsystem_id"] = id
+		request["nvme_subsystem"] = map[string]interface{}{"spec": map[string]interface{}{"nqn": benchNqnPrefix + id}}
+	case "list":
+	default:
+		request["name"] = name
+	}
+	method := b.service.Methods().ByName(benchMethods[operation])
+	in, err := newRequest(method, request)
+	if err != nil {
+		b.record(operation, 0, err)
+		return
+	}
+	callCtx, cancel := context.WithTimeout(withCredentials(ctx, b.profile), b.timeout)
+	defer cancel()
+	start := time.Now()
+	out, err := invoke(callCtx, b.conn, method, in)
+	latency := time.Since(start)
+	// the calls cut by the end of the benchmark aren't counted
+	if ctx.Err() != nil && err != nil {
+		return
+	}
+	b.record(operation, latency, err)
+	if operation != "create" || err != nil {
+		return
+	}
+	if value, err := toMap(out); err == nil && lookup(value, "name") != "" {
+		b.mu.Lock()
+		b.created = append(b.created, lookup(value, "name"))
+		b.mu.Unlock()
+	}
+}
+
+// pick returns a subsystem created by the benchmark, in turn, removed from them when taken, empty
+// when there is none
+func (b *benchmark) pick(take bool) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.created) == 0 {
+		return ""
+	}
+	b.next = (b.next + 1) % len(b.created)
+	name := b.created[b.next]
+	if take {
+		b.created = append(b.created[:b.next], b.created[b.next+1:]...)
+	}
+	return name
+}
+
+// record records the latency of a call, or its error
+func (b *benchmark) record(operation string, latency time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.errors[operation]++
+		message := fmt.Sprintf("%s: %v", benchMethods[operation], err)
+		for _, m := range b.messages {
+			if m == message {
+				return
+			}
+		}
+		if len(b.messages) < maxBenchErrors {
+			b.messages = append(b.messages, message)
+		}
+		return
+	}
+	b.latencies[operation] = append(b.latencies[operation], latency)
+}
+
+// cleanup deletes the subsystems created by the benchmark and left
+func (b *benchmark) cleanup() {
+	method := b.service.Methods().ByName(benchMethods["delete"])
+	for _, name := range b.created {
+		in, err := newRequest(method, map[string]interface{}{"name": name})
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(withCredentials(context.Background(), b.profile), b.timeout)
+		if _, err := invoke(ctx, b.conn, method, in); err != nil {
+			b.messages = append(b.messages, fmt.Sprintf("cleanup of %s: %v", name, err))
+		}
+		cancel()
+	}
+	b.created = nil
+}
+
+// report computes the throughput and the latency distribution of each operation, and of all
+func (b *benchmark) report(elapsed time.Duration) *benchReport {
+	report := &benchReport{
+		DurationSec: elapsed.Seconds(),
+		Concurrency: b.options.concurrency,
+		Errors:      b.messages,
+	}
+	operations := make([]string, 0, len(benchMethods))
+	for operation := range benchMethods {
+		if len(b.latencies[operation]) != 0 || b.errors[operation] != 0 {
+			operations = append(operations, operation)
+		}
+	}
+	sort.Strings(operations)
+	var all []time.Duration
+	errors := 0
+	for _, operation := range operations {
+		report.Operations = append(report.Operations, newBenchResult(operation, b.latencies[operation], b.errors[operation], elapsed))
+		all = append(all, b.latencies[operation]...)
+		errors += b.errors[operation]
+	}
+	report.Total = newBenchResult("total", all, errors, elapsed)
+	return report
+}
+
+// newBenchResult returns the result of the calls of an operation, from the latencies of the ones
+// which succeeded
+func newBenchResult(operation string, latencies []time.Duration, errors int, elapsed time.Duration) *benchResult {
+	result := &benchResult{Operation: operation, Calls: len(latencies) + errors, Errors: errors}
+	if elapsed > 0 {
+		result.CallsPerSec = float64(result.Calls) / elapsed.Seconds()
+	}
+	if len(latencies) == 0 {
+		return result
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	result.MinMs = milliseconds(sorted[0])
+	result.P50Ms = milliseconds(percentile(sorted, 50))
+	result.P90Ms = milliseconds(percentile(sorted, 90))
+	result.P99Ms = milliseconds(percentile(sorted, 99))
+	result.MaxMs = milliseconds(sorted[len(sorted)-1])
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// milliseconds returns a duration in milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// printBenchReport prints a report as JSON, as YAML or as a table of the operations
+func printBenchReport(w io.Writer, format string, report *benchReport) error {
+	switch format {
+	case "json", "":
+		content, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(content))
+		return err
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATION\tCALLS\tERRORS\tCALLS/S\tMIN MS\tP50 MS\tP90 MS\tP99 MS\tMAX MS")
+	for _, result := range append(report.Operations, report.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n", result.Operation, result.Calls, result.Errors,
+			result.CallsPerSec, result.MinMs, result.P50Ms, result.P90Ms, result.P99Ms, result.MaxMs)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d workers for %.1fs\n", report.Concurrency, report.DurationSec)
+	for _, message := range report.Errors {
+		fmt.Fprintln(w, "Error:", message)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCtl_Bench(t *testing.T) {
+	files := testFiles(t)
+	address, store := startStoreServer(t, files)
+	config := filepath.Join(t.TempDir(), "config.yaml")
+
+	output, err := run(files, "bench", "--requests", "20", "--duration", "0s", "--concurrency", "1", "-o", "json", "-a", address, "--config", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report benchReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Calls != 20 || report.Total.Errors != 0 || len(report.Errors) != 0 {
+		t.Error("total: expected 20 calls without errors, received", report.Total, report.Errors)
+	}
+	calls := make(map[string]int)
+	for _, result := range report.Operations {
+		calls[result.Operation] = result.Calls
+		if result.MinMs > result.P50Ms || result.P50Ms > result.P99Ms || result.P99Ms > result.MaxMs {
+			t.Error(result.Operation, ": expected ordered latencies, received", result)
+		}
+	}
+	// a worker goes through the schedule create, delete, get, get, list x4, stats x2 twice, the
+	// first get after the first delete creating a subsystem instead
+	expected := map[string]int{"create": 3, "delete": 2, "get": 3, "list": 8, "stats": 4}
+	for operation, count := range expected {
+		if calls[operation] != count {
+			t.Error(operation, ": expected", count, "calls, received", calls[operation])
+		}
+	}
+	if names := store.names(); len(names) != 0 {
+		t.Error("store: expected the subsystems to be deleted, received", names)
+	}
+
+	output, err = run(files, "bench", "--mix", "create=1", "--requests", "3", "--concurrency", "3", "--keep", "--prefix", "keep", "-o", "table", "-a", address, "--config", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "create     3      0") || !strings.Contains(output, "3 workers for") {
+		t.Error("output: expected a table of 3 creations, received", output)
+	}
+	if names := store.names(); len(names) != 3 || !strings.HasPrefix(names[0], "//storage.opiproject.org/nvmeSubsystems/keep-") {
+		t.Error("store: expected the subsystems to be kept, received", names)
+	}
+
+	for _, args := range [][]string{
+		{"--mix", "create=1,format=2"},
+		{"--mix", "create=0"},
+		{"--concurrency", "0"},
+		{"--duration", "0s"},
+	} {
+		if _, err := run(files, append([]string{"bench", "-a", address, "--config", config}, args...)...); err == nil {
+			t.Error("expected an error for", args)
+		}
+	}
+}
+
+func TestCtl_BenchMix(t *testing.T) {
+	tests := map[string]struct {
+		mix      string
+		schedule string
+		errMsg   string
+	}{
+		"weights": {
+			mix:      "create=1, list=3,stats=0",
+			schedule: "create list list list",
+		},
+		"unknown operation": {
+			mix:    "create=1,update=1",
+			errMsg: `invalid mix entry "update=1"`,
+		},
+		"missing weight": {
+			mix:    "list",
+			errMsg: `invalid mix entry "list"`,
+		},
+		"negative weight": {
+			mix:    "list=-1",
+			errMsg: `invalid mix entry "list=-1"`,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			schedule, err := parseMix(tt.mix)
+			if tt.errMsg != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.errMsg) {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if strings.Join(schedule, " ") != tt.schedule {
+				t.Error("schedule: expected", tt.schedule, "received", schedule)
+			}
+		})
+	}
+}
+
+func TestCtl_BenchPercentiles(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	result := newBenchResult("get", latencies, 10, 2*time.Second)
+	expected := benchResult{Operation: "get", Calls: 110, Errors: 10, CallsPerSec: 55, MinMs: 1, P50Ms: 50, P90Ms: 90, P99Ms: 99, MaxMs: 100}
+	if *result != expected {
+		t.Error("result: expected", expected, "received", *result)
+	}
+}
@@ -0,0 +1,325 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/oidc"
+	"github.com/spf13/cobra"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultAddress is the address of the bridge when neither set nor saved in a profile
+const defaultAddress = "localhost:50051"
+
+// serviceGroup is a command grouping the methods of services of the bridge
+type serviceGroup struct {
+	name     string
+	short    string
+	services []protoreflect.FullName
+}
+
+// serviceGroups are the services served by the bridge
+var serviceGroups = []serviceGroup{
+	{
+		name:  "frontend",
+		short: "Nvme and virtio devices exposed to the host",
+		services: []protoreflect.FullName{
+			"opi_api.storage.v1.FrontendNvmeService",
+			"opi_api.storage.v1.FrontendVirtioBlkService",
+			"opi_api.storage.v1.FrontendVirtioScsiService",
+		},
+	},
+	{
+		name:  "middleend",
+		short: "Encryption and QoS of the volumes",
+		services: []protoreflect.FullName{
+			"opi_api.storage.v1.MiddleendEncryptionService",
+			"opi_api.storage.v1.MiddleendQosVolumeService",
+		},
+	},
+	{
+		name:  "backend",
+		short: "Remote controllers and local volumes",
+		services: []protoreflect.FullName{
+			"opi_api.storage.v1.NvmeRemoteControllerService",
+			"opi_api.storage.v1.NullVolumeService",
+			"opi_api.storage.v1.MallocVolumeService",
+			"opi_api.storage.v1.AioVolumeService",
+		},
+	},
+}
+
+// options are the global flags of the command line
+type options struct {
+	config  string
+	profile string
+	timeout time.Duration
+	// connection overrides the saved profile with the flags which are set
+	connection Profile
+}
+
+// NewCommand returns the root command of opi-marvell-ctl, with a command for each method of the
+// services of the bridge found in files, the generated descriptors once imported
+func NewCommand(files *protoregistry.Files) *cobra.Command {
+	o := &options{}
+	root := &cobra.Command{
+		Use:           "opi-marvell-ctl",
+		Short:         "Administrative command line of the OPI Marvell bridge",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&o.config, "config", defaultConfigPath(), "File the connection profiles are saved in")
+	flags.StringVarP(&o.profile, "profile", "p", "", "Connection profile, the current one when empty")
+	flags.DurationVar(&o.timeout, "timeout", 30*time.Second, "Timeout of a call")
+	flags.StringVarP(&o.connection.Address, "address", "a", "", "Address of the gRPC server of the bridge, "+defaultAddress+" when neither set nor saved")
+	flags.StringVar(&o.connection.CaCert, "ca_cert", "", "CA certificate of the bridge, the connection is plaintext when empty")
+	flags.StringVar(&o.connection.Cert, "cert", "", "Client certificate presented to the bridge")
+	flags.StringVar(&o.connection.Key, "key", "", "Key of the client certificate")
+	flags.StringVar(&o.connection.APIKey, "api_key", "", "API key sent with the calls")
+	flags.StringVar(&o.connection.Token, "token", "", "OIDC bearer token sent with the calls")
+	flags.StringVarP(&o.connection.Output, "output", "o", "", "Output format, json, yaml or table, json when neither set nor saved")
+	_ = root.RegisterFlagCompletionFunc("output", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return outputFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = root.RegisterFlagCompletionFunc("profile", o.completeProfiles)
+
+	for _, group := range serviceGroups {
+		groupCommand := &cobra.Command{
+			Use:   group.name,
+			Short: group.short,
+		}
+		for _, name := range group.services {
+			descriptor, err := files.FindDescriptorByName(name)
+			if err != nil {
+				continue
+			}
+			service, ok := descriptor.(protoreflect.ServiceDescriptor)
+			if !ok {
+				continue
+			}
+			for i := 0; i < service.Methods().Len(); i++ {
+				groupCommand.AddCommand(o.newMethodCommand(service.Methods().Get(i)))
+			}
+		}
+		if groupCommand.HasSubCommands() {
+			root.AddCommand(groupCommand)
+		}
+	}
+	root.AddCommand(o.newApplyCommand(files))
+	if dashboard := o.newDashboardCommand(files); dashboard != nil {
+		root.AddCommand(dashboard)
+	}
+	if bench := o.newBenchCommand(files); bench != nil {
+		root.AddCommand(bench)
+	}
+	root.AddCommand(o.newProfileCommand())
+	return root
+}
+
+// newMethodCommand returns the command calling a method, the request is given as JSON or YAML
+func (o *options) newMethodCommand(method protoreflect.MethodDescriptor) *cobra.Command {
+	var data, file, name, parent string
+	fields := method.Input().Fields()
+	fieldNames := make([]string, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		fieldNames = append(fieldNames, string(fields.Get(i).Name()))
+	}
+	command := &cobra.Command{
+		Use:   kebabCase(string(method.Name())),
+		Short: fmt.Sprintf("Call %s.%s", method.Parent().Name(), method.Name()),
+		Long: fmt.Sprintf("Call %s.%s\n\nThe %s request has the fields %s",
+			method.Parent().Name(), method.Name(), method.Input().Name(), strings.Join(fieldNames, ", ")),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			request, err := readRequest(cmd.InOrStdin(), data, file)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("name") {
+				request["name"] = name
+			}
+			if cmd.Flags().Changed("parent") {
+				request["parent"] = parent
+			}
+			return o.call(cmd, method, request)
+		},
+	}
+	command.Flags().StringVarP(&data, "data", "d", "", "Request as JSON or YAML")
+	command.Flags().StringVarP(&file, "file", "f", "", "File of the request as JSON or YAML, stdin when -")
+	if fields.ByName("name") != nil {
+		command.Flags().StringVar(&name, "name", "", "Name of the resource, set in the request")
+	}
+	if fields.ByName("parent") != nil {
+		command.Flags().StringVar(&parent, "parent", "", "Parent of the resources, set in the request")
+	}
+	return command
+}
+
+// readRequest reads the fields of a request from data or from file
+func readRequest(stdin io.Reader, data string, file string) (map[string]interface{}, error) {
+	if data != "" && file != "" {
+		return nil, errors.New("the request can't be given both with --data and --file")
+	}
+	content := []byte(data)
+	if file != "" {
+		var err error
+		if content, err = readFile(stdin, file); err != nil {
+			return nil, err
+		}
+	}
+	request := make(map[string]interface{})
+	// YAML is a superset of JSON
+	if err := yaml.Unmarshal(content, &request); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if request == nil {
+		request = make(map[string]interface{})
+	}
+	return request, nil
+}
+
+// call calls a method with the fields of a request and prints its response
+func (o *options) call(cmd *cobra.Command, method protoreflect.MethodDescriptor, request map[string]interface{}) error {
+	in, err := newRequest(method, request)
+	if err != nil {
+		return err
+	}
+	profile, err := o.resolveProfile()
+	if err != nil {
+		return err
+	}
+	if err := checkOutputFormat(profile.Output); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), o.timeout)
+	defer cancel()
+	conn, err := dial(profile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	out, err := invoke(withCredentials(ctx, profile), conn, method, in)
+	if err != nil {
+		return err
+	}
+	return printMessage(cmd.OutOrStdout(), profile.Output, out)
+}
+
+// newRequest returns the request of a method with the fields of request
+func newRequest(method protoreflect.MethodDescriptor, request map[string]interface{}) (*dynamicpb.Message, error) {
+	content, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	in := dynamicpb.NewMessage(method.Input())
+	if err := protojson.Unmarshal(content, in); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", method.Input().Name(), err)
+	}
+	return in, nil
+}
+
+// withCredentials adds the API key and the bearer token of a profile to the metadata of the calls
+func withCredentials(ctx context.Context, profile *Profile) context.Context {
+	if profile.APIKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, apikey.MetadataKey, profile.APIKey)
+	}
+	if profile.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, oidc.MetadataKey, "Bearer "+profile.Token)
+	}
+	return ctx
+}
+
+// invoke calls a method and returns its response, the errors prefixed with their code
+func invoke(ctx context.Context, conn *grpc.ClientConn, method protoreflect.MethodDescriptor, in *dynamicpb.Message) (*dynamicpb.Message, error) {
+	out := dynamicpb.NewMessage(method.Output())
+	fullMethod := fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name())
+	if err := conn.Invoke(ctx, fullMethod, in, out); err != nil {
+		if s, ok := status.FromError(err); ok {
+			// the failures of the firmware tell the method which failed and its status
+			if info := models.ErrorInfo(err); info != nil {
+				return nil, fmt.Errorf("%s: %s (%s of %s)", s.Code(), s.Message(), info.Reason, info.Metadata["method"])
+			}
+			return nil, fmt.Errorf("%s: %s", s.Code(), s.Message())
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// dial returns a connection to the bridge of a profile, over TLS when its CA is set
+func dial(profile *Profile) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if profile.CaCert != "" {
+		config, err := tlsConfig(profile)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(config)
+	}
+	return grpc.Dial(profile.Address, grpc.WithTransportCredentials(creds))
+}
+
+// tlsConfig returns the TLS configuration of a profile, trusting its CA and presenting its client
+// certificate when set
+func tlsConfig(profile *Profile) (*tls.Config, error) {
+	ca, err := os.ReadFile(profile.CaCert)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{RootCAs: x509.NewCertPool(), MinVersion: tls.VersionTLS12}
+	if !config.RootCAs.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate in %s", profile.CaCert)
+	}
+	if profile.Cert != "" {
+		certificate, err := tls.LoadX509KeyPair(profile.Cert, profile.Key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// kebabCase returns the command name of a method, i.e. create-nvme-subsystem for
+// CreateNvmeSubsystem
+func kebabCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
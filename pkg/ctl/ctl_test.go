@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const testSubsystemName = "//storage.opiproject.org/nvmeSubsystems/subsys0"
+
+// testResponses are the responses of the test service, by method
+var testResponses = map[string]string{
+	"ListNvmeSubsystems": `{"nvme_subsystems": [
+		{"name": "//storage.opiproject.org/nvmeSubsystems/subsys0", "spec": {"nqn": "nqn.2022-09.io.spdk:opi0"}},
+		{"name": "//storage.opiproject.org/nvmeSubsystems/subsys1", "spec": {"nqn": "nqn.2022-09.io.spdk:opi1"}}],
+		"next_page_token": "page2"}`,
+	"ListNvmeControllers": `{"nvme_controllers": [
+		{"name": "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0", "spec": {"pcie_id": {"physical_function": 1}}}]}`,
+	"ListNvmeNamespaces": `{"nvme_namespaces": [
+		{"name": "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeNamespaces/ns0", "spec": {"host_nsid": 1, "volume_name_ref": "Malloc0"}}]}`,
+	"StatsNvmeController": `{"stats": {"read_ops_count": 10, "write_ops_count": 20, "read_bytes_count": 4096, "write_bytes_count": 8192}}`,
+	"StatsNvmeNamespace":  `{"stats": {"read_ops_count": 5}}`,
+}
+
+// testFiles describes a part of the frontend Nvme service
+func testFiles(t *testing.T) *protoregistry.Files {
+	t.Helper()
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	stringType := descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+	int32Type := descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum()
+	messageType := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	field := func(name string, number int32, label *descriptorpb.FieldDescriptorProto_Label, fieldType *descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Label: label, Type: fieldType}
+		if typeName != "" {
+			f.TypeName = proto.String(".opi_api.storage.v1." + typeName)
+		}
+		return f
+	}
+	message := func(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+	}
+	listMessages := func(resource string, listField string) []*descriptorpb.DescriptorProto {
+		return []*descriptorpb.DescriptorProto{
+			message("List"+resource+"sRequest",
+				field("parent", 1, optional, stringType, ""),
+				field("page_size", 2, optional, int32Type, ""),
+				field("page_token", 3, optional, stringType, "")),
+			message("List"+resource+"sResponse",
+				field(listField, 1, repeated, messageType, resource),
+				field("next_page_token", 2, optional, stringType, "")),
+		}
+	}
+	method := func(name string, input string, output string) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{Name: proto.String(name), InputType: proto.String(".opi_api.storage.v1." + input), OutputType: proto.String(".opi_api.storage.v1." + output)}
+	}
+	messages := []*descriptorpb.DescriptorProto{
+		message("VolumeStats",
+			field("read_bytes_count", 1, optional, int32Type, ""),
+			field("read_ops_count", 2, optional, int32Type, ""),
+			field("write_bytes_count", 3, optional, int32Type, ""),
+			field("write_ops_count", 4, optional, int32Type, "")),
+		message("PciEndpoint",
+			field("physical_function", 1, optional, int32Type, ""),
+			field("virtual_function", 2, optional, int32Type, "")),
+		message("NvmeSubsystemSpec",
+			field("nqn", 1, optional, stringType, ""),
+			field("max_namespaces", 2, optional, int32Type, "")),
+		message("NvmeSubsystem",
+			field("name", 1, optional, stringType, ""),
+			field("spec", 2, optional, messageType, "NvmeSubsystemSpec")),
+		message("NvmeControllerSpec",
+			field("pcie_id", 1, optional, messageType, "PciEndpoint")),
+		message("NvmeController",
+			field("name", 1, optional, stringType, ""),
+			field("spec", 2, optional, messageType, "NvmeControllerSpec")),
+		message("NvmeNamespaceSpec",
+			field("host_nsid", 1, optional, int32Type, ""),
+			field("volume_name_ref", 2, optional, stringType, "")),
+		message("NvmeNamespace",
+			field("name", 1, optional, stringType, ""),
+			field("spec", 2, optional, messageType, "NvmeNamespaceSpec")),
+		message("GetNvmeSubsystemRequest", field("name", 1, optional, stringType, "")),
+		message("NameRequest", field("name", 1, optional, stringType, "")),
+		message("StatsResponse", field("stats", 1, optional, messageType, "VolumeStats")),
+		message("Empty"),
+		message("CreateNvmeSubsystemRequest",
+			field("nvme_subsystem", 1, optional, messageType, "NvmeSubsystem"),
+			field("nvme_subsystem_id", 2, optional, stringType, "")),
+		message("UpdateNvmeSubsystemRequest",
+			field("nvme_subsystem", 1, optional, messageType, "NvmeSubsystem")),
+		message("CreateNvmeControllerRequest",
+			field("parent", 1, optional, stringType, ""),
+			field("nvme_controller", 2, optional, messageType, "NvmeController"),
+			field("nvme_controller_id", 3, optional, stringType, "")),
+	}
+	messages = append(messages, listMessages("NvmeSubsystem", "nvme_subsystems")...)
+	messages = append(messages, listMessages("NvmeController", "nvme_controllers")...)
+	messages = append(messages, listMessages("NvmeNamespace", "nvme_namespaces")...)
+	file := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("test_frontend_nvme.proto"),
+		Package:     proto.String("opi_api.storage.v1"),
+		Syntax:      proto.String("proto3"),
+		MessageType: messages,
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{Name: proto.String("FrontendNvmeService"), Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetNvmeSubsystem", "GetNvmeSubsystemRequest", "NvmeSubsystem"),
+				method("ListNvmeSubsystems", "ListNvmeSubsystemsRequest", "ListNvmeSubsystemsResponse"),
+				method("ListNvmeControllers", "ListNvmeControllersRequest", "ListNvmeControllersResponse"),
+				method("ListNvmeNamespaces", "ListNvmeNamespacesRequest", "ListNvmeNamespacesResponse"),
+				method("StatsNvmeController", "NameRequest", "StatsResponse"),
+				method("StatsNvmeNamespace", "NameRequest", "StatsResponse"),
+				method("CreateNvmeSubsystem", "CreateNvmeSubsystemRequest", "NvmeSubsystem"),
+				method("UpdateNvmeSubsystem", "UpdateNvmeSubsystemRequest", "NvmeSubsystem"),
+				method("DeleteNvmeSubsystem", "NameRequest", "Empty"),
+				method("StatsNvmeSubsystem", "NameRequest", "StatsResponse"),
+				method("CreateNvmeController", "CreateNvmeControllerRequest", "NvmeController"),
+				method("GetNvmeController", "NameRequest", "NvmeController"),
+				method("DeleteNvmeController", "NameRequest", "Empty"),
+			}},
+		},
+	}
+	descriptor, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := new(protoregistry.Files)
+	if err := files.RegisterFile(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// startTestServer serves the test frontend Nvme service, the calls without the API key are
+// refused, and returns its address. The subsystems are listed on a single page, the next page
+// being empty, and only the controllers and namespaces of subsys0 are listed
+func startTestServer(t *testing.T, files *protoregistry.Files) string {
+	t.Helper()
+	descriptor, err := files.FindDescriptorByName("opi_api.storage.v1.FrontendNvmeService")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := descriptor.(protoreflect.ServiceDescriptor)
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		fullMethod, _ := grpc.MethodFromServerStream(stream)
+		method := service.Methods().ByName(protoreflect.Name(fullMethod[strings.LastIndex(fullMethod, "/")+1:]))
+		if method == nil {
+			return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+		}
+		if md, _ := metadata.FromIncomingContext(stream.Context()); len(md.Get(apikey.MetadataKey)) == 0 || md.Get(apikey.MetadataKey)[0] != "secret" {
+			return status.Error(codes.Unauthenticated, "Missing API key")
+		}
+		in := dynamicpb.NewMessage(method.Input())
+		if err := stream.RecvMsg(in); err != nil {
+			return err
+		}
+		out := dynamicpb.NewMessage(method.Output())
+		text := func(field protoreflect.Name) string {
+			if fd := method.Input().Fields().ByName(field); fd != nil {
+				return in.Get(fd).String()
+			}
+			return ""
+		}
+		response := testResponses[string(method.Name())]
+		switch {
+		case method.Name() == "GetNvmeSubsystem":
+			if text("name") != testSubsystemName {
+				return status.Errorf(codes.NotFound, "unable to find key %s", text("name"))
+			}
+			response = `{"name": "` + testSubsystemName + `", "spec": {"nqn": "nqn.2022-09.io.spdk:opi0"}}`
+		case text("page_token") == "page2", text("parent") != "" && text("parent") != testSubsystemName:
+			response = "{}"
+		}
+		if err := protojson.Unmarshal([]byte(response), out); err != nil {
+			return err
+		}
+		return stream.SendMsg(out)
+	}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+// run runs the command line with args, returning its output
+func run(files *protoregistry.Files, args ...string) (string, error) {
+	command := NewCommand(files)
+	var out bytes.Buffer
+	command.SetOut(&out)
+	command.SetArgs(args)
+	err := command.Execute()
+	return out.String(), err
+}
+
+func TestCtl_Methods(t *testing.T) {
+	files := testFiles(t)
+	address := startTestServer(t, files)
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	tests := map[string]struct {
+		args     []string
+		expected []string
+		errMsg   string
+	}{
+		"get as json": {
+			args:     []string{"frontend", "get-nvme-subsystem", "--name", testSubsystemName, "--api_key", "secret"},
+			expected: []string{`"name":"//storage.opiproject.org/nvmeSubsystems/subsys0"`, `"spec":{"nqn":"nqn.2022-09.io.spdk:opi0"}`},
+		},
+		"get as yaml from data": {
+			args:     []string{"frontend", "get-nvme-subsystem", "-d", "name: " + testSubsystemName, "--api_key", "secret", "-o", "yaml"},
+			expected: []string{"name: //storage.opiproject.org/nvmeSubsystems/subsys0\n", "spec:\n  nqn: nqn.2022-09.io.spdk:opi0\n"},
+		},
+		"list as table": {
+			args: []string{"frontend", "list-nvme-subsystems", "-d", `{"pageSize": 2}`, "--api_key", "secret", "-o", "table"},
+			expected: []string{
+				"NAME" + strings.Repeat(" ", 45) + "SPEC.NQN\n",
+				"//storage.opiproject.org/nvmeSubsystems/subsys0  nqn.2022-09.io.spdk:opi0\n",
+				"//storage.opiproject.org/nvmeSubsystems/subsys1  nqn.2022-09.io.spdk:opi1\n",
+				"Next page token: page2\n",
+			},
+		},
+		"not found": {
+			args:   []string{"frontend", "get-nvme-subsystem", "--name", "//storage.opiproject.org/nvmeSubsystems/unknown", "--api_key", "secret"},
+			errMsg: "NotFound: unable to find key //storage.opiproject.org/nvmeSubsystems/unknown",
+		},
+		"missing API key": {
+			args:   []string{"frontend", "get-nvme-subsystem", "--name", testSubsystemName},
+			errMsg: "Unauthenticated: Missing API key",
+		},
+		"unknown field": {
+			args:   []string{"frontend", "get-nvme-subsystem", "-d", `{"nqn": "nqn.2022-09.io.spdk:opi0"}`, "--api_key", "secret"},
+			errMsg: "invalid GetNvmeSubsystemRequest",
+		},
+		"invalid output": {
+			args:   []string{"frontend", "get-nvme-subsystem", "--name", testSubsystemName, "-o", "xml"},
+			errMsg: "invalid output format xml, have to be json, yaml, table",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			output, err := run(files, append(tt.args, "--config", config, "-a", address)...)
+			if tt.errMsg != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.errMsg) {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			compact := output
+			if strings.HasPrefix(output, "{") {
+				var buf bytes.Buffer
+				if err := json.Compact(&buf, []byte(output)); err != nil {
+					t.Fatal(err)
+				}
+				compact = buf.String()
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(compact, expected) {
+					t.Error("output: expected", expected, "received", output)
+				}
+			}
+		})
+	}
+}
+
+func TestCtl_Commands(t *testing.T) {
+	command := NewCommand(testFiles(t))
+	var names []string
+	for _, c := range command.Commands() {
+		names = append(names, c.Name())
+	}
+	// the services which aren't described have no command
+	if strings.Join(names, " ") != "apply bench dashboard frontend profile" {
+		t.Error("commands: expected apply, bench, dashboard, frontend and profile, received", names)
+	}
+	frontend, _, err := command.Find([]string{"frontend", "list-nvme-subsystems"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frontend.Flags().Lookup("name") != nil || frontend.Flags().Lookup("data") == nil {
+		t.Error("flags: expected --data without --name for list-nvme-subsystems")
+	}
+}
+
+func TestCtl_KebabCase(t *testing.T) {
+	tests := map[string]string{
+		"CreateNvmeSubsystem":       "create-nvme-subsystem",
+		"ListNvmeRemoteNamespaces":  "list-nvme-remote-namespaces",
+		"StatsVirtioScsiController": "stats-virtio-scsi-controller",
+		"ResetNvmeRemoteController": "reset-nvme-remote-controller",
+	}
+
+	// run tests
+	for name, expected := range tests {
+		if received := kebabCase(name); received != expected {
+			t.Error("command: expected", expected, "received", received)
+		}
+	}
+}
@@ -0,0 +1,559 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+	"github.com/opiproject/opi-marvell-bridge/pkg/events"
+	"github.com/opiproject/opi-marvell-bridge/pkg/oidc"
+	"github.com/spf13/cobra"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// dashboardService is the service the dashboard shows the resources of
+const dashboardService protoreflect.FullName = "opi_api.storage.v1.FrontendNvmeService"
+
+// eventsPageSize is the size of the pages the events are listed with, the maximum one
+const eventsPageSize = 250
+
+// dashboardOptions are the flags of the dashboard
+type dashboardOptions struct {
+	interval time.Duration
+	httpPort int
+	events   int
+	once     bool
+}
+
+// volumeStats are the counters of a controller or of a namespace at a time
+type volumeStats struct {
+	time       time.Time
+	readOps    float64
+	writeOps   float64
+	readBytes  float64
+	writeBytes float64
+}
+
+// volumeRates are the rates of a controller or of a namespace between two refreshes
+type volumeRates struct {
+	readIops      float64
+	writeIops     float64
+	readBytesSec  float64
+	writeBytesSec float64
+}
+
+// subsystemRow is a subsystem shown by the dashboard
+type subsystemRow struct {
+	name        string
+	nqn         string
+	controllers int
+	namespaces  int
+	resource    map[string]interface{}
+}
+
+// deviceRow is a controller or a namespace shown by the dashboard, with its rates unless they
+// aren't known yet
+type deviceRow struct {
+	name      string
+	subsystem string
+	columns   []string
+	rates     *volumeRates
+	resource  map[string]interface{}
+}
+
+// dashboardTable is a table of the dashboard, its header and its rows tab separated cells
+type dashboardTable struct {
+	header string
+	rows   []dashboardRow
+}
+
+// dashboardRow is a row of a table, with the subsystem it belongs to and the details shown when
+// it is selected
+type dashboardRow struct {
+	cells     string
+	subsystem string
+	details   []string
+}
+
+// dashboardFrame is what a refresh of the dashboard shows
+type dashboardFrame struct {
+	time        time.Time
+	subsystems  []subsystemRow
+	controllers []deviceRow
+	namespaces  []deviceRow
+	// events are the last events, the newest first
+	events []*events.Event
+	errors []string
+}
+
+// dashboard fetches the resources of the bridge and renders them with their rates
+type dashboard struct {
+	service   protoreflect.ServiceDescriptor
+	profile   *Profile
+	conn      *grpc.ClientConn
+	client    *http.Client
+	eventsURL string
+	options   dashboardOptions
+	// previous are the counters of the last refresh, the rates are computed from them
+	previous map[string]volumeStats
+	now      func() time.Time
+}
+
+// newDashboardCommand returns the command showing the dashboard, none when the frontend Nvme
+// service isn't described in files
+func (o *options) newDashboardCommand(files *protoregistry.Files) *cobra.Command {
+	descriptor, err := files.FindDescriptorByName(dashboardService)
+	if err != nil {
+		return nil
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	d := dashboardOptions{}
+	command := &cobra.Command{
+		Use:   "dashboard",
+		Short: "Show the Nvme subsystems, controllers and namespaces with their live stats and the last events",
+		Long: "Show the Nvme subsystems, controllers and namespaces with their live stats and the last events\n\n" +
+			"The dashboard is refreshed every --interval until quit with q, the rates being computed between two refreshes. " +
+			"Tab moves to the next table, the arrows select a row whose details are shown below the tables, Enter shows " +
+			"the controllers and the namespaces of the selected subsystem only and Esc all of them again, r refreshes at once. " +
+			"The events are listed from the HTTP server of the bridge, on the host of --address",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if d.interval <= 0 {
+				return fmt.Errorf("invalid interval %v, have to be positive", d.interval)
+			}
+			if d.httpPort < 0 || d.httpPort > 65535 {
+				return fmt.Errorf("invalid HTTP port %d, have to be between 0 and 65535", d.httpPort)
+			}
+			profile, err := o.resolveProfile()
+			if err != nil {
+				return err
+			}
+			conn, err := dial(profile)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = conn.Close() }()
+			board := &dashboard{
+				service:  service,
+				profile:  profile,
+				conn:     conn,
+				client:   &http.Client{Timeout: o.timeout},
+				options:  d,
+				previous: make(map[string]volumeStats),
+				now:      time.Now,
+			}
+			if d.httpPort != 0 {
+				scheme := "http"
+				if profile.CaCert != "" {
+					config, err := tlsConfig(profile)
+					if err != nil {
+						return err
+					}
+					board.client.Transport = &http.Transport{TLSClientConfig: config}
+					scheme = "https"
+				}
+				host, _, err := net.SplitHostPort(profile.Address)
+				if err != nil {
+					return fmt.Errorf("invalid address %s: %w", profile.Address, err)
+				}
+				board.eventsURL = fmt.Sprintf("%s://%s/v1/events", scheme, net.JoinHostPort(host, strconv.Itoa(d.httpPort)))
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return board.run(ctx, cmd.OutOrStdout(), o.timeout)
+		},
+	}
+	command.Flags().DurationVar(&d.interval, "interval", 2*time.Second, "Interval between two refreshes")
+	command.Flags().IntVar(&d.httpPort, "http_port", 8082, "HTTP port of the bridge the events are listed from, the events are hidden when 0")
+	command.Flags().IntVar(&d.events, "events", 10, "Number of events shown")
+	command.Flags().BoolVar(&d.once, "once", false, "Print the dashboard once, as text, instead of showing it interactively")
+	return command
+}
+
+// run shows the dashboard on the terminal until ctx is done or it is quit, or prints it once
+func (d *dashboard) run(ctx context.Context, w io.Writer, timeout time.Duration) error {
+	if !d.options.once {
+		screen, err := tcell.NewScreen()
+		if err != nil {
+			return err
+		}
+		if err := screen.Init(); err != nil {
+			return err
+		}
+		defer screen.Fini()
+		return d.show(ctx, screen, timeout)
+	}
+	refreshCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return d.render(w, d.refresh(refreshCtx))
+}
+
+// refresh fetches the resources, their stats and the events, the failures are reported in the
+// frame
+func (d *dashboard) refresh(ctx context.Context) *dashboardFrame {
+	ctx = withCredentials(ctx, d.profile)
+	frame := &dashboardFrame{time: d.now()}
+	current := make(map[string]volumeStats)
+	defer func() { d.previous = current }()
+	subsystems, err := d.list(ctx, "ListNvmeSubsystems", "", "nvme_subsystems")
+	if err != nil {
+		frame.errors = append(frame.errors, err.Error())
+	}
+	for _, subsystem := range subsystems {
+		row := subsystemRow{name: lookup(subsystem, "name"), nqn: lookup(subsystem, "spec.nqn"), resource: subsystem}
+		controllers, err := d.list(ctx, "ListNvmeControllers", row.name, "nvme_controllers")
+		if err != nil {
+			frame.errors = append(frame.errors, err.Error())
+		}
+		for _, controller := range controllers {
+			name := lookup(controller, "name")
+			pcie := fmt.Sprintf("PF %s VF %s", orDash(lookup(controller, "spec.pcie_id.physical_function")), orDash(lookup(controller, "spec.pcie_id.virtual_function")))
+			frame.controllers = append(frame.controllers, deviceRow{
+				name:      name,
+				subsystem: row.name,
+				columns:   []string{pcie},
+				rates:     d.stats(ctx, "StatsNvmeController", name, current, frame),
+				resource:  controller,
+			})
+		}
+		namespaces, err := d.list(ctx, "ListNvmeNamespaces", row.name, "nvme_namespaces")
+		if err != nil {
+			frame.errors = append(frame.errors, err.Error())
+		}
+		for _, namespace := range namespaces {
+			name := lookup(namespace, "name")
+			frame.namespaces = append(frame.namespaces, deviceRow{
+				name:      name,
+				subsystem: row.name,
+				columns:   []string{orDash(lookup(namespace, "spec.host_nsid")), orDash(lookup(namespace, "spec.volume_name_ref"))},
+				rates:     d.stats(ctx, "StatsNvmeNamespace", name, current, frame),
+				resource:  namespace,
+			})
+		}
+		row.controllers, row.namespaces = len(controllers), len(namespaces)
+		frame.subsystems = append(frame.subsystems, row)
+	}
+	if d.eventsURL != "" {
+		frame.events, err = d.listEvents(ctx)
+		if err != nil {
+			frame.errors = append(frame.errors, err.Error())
+		}
+	}
+	return frame
+}
+
+// list lists all the resources of a list method, following its pages
+func (d *dashboard) list(ctx context.Context, methodName string, parent string, field string) ([]map[string]interface{}, error) {
+	method := d.service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("%s: unknown method", methodName)
+	}
+	var resources []map[string]interface{}
+	token := ""
+	for {
+		request := map[string]interface{}{"page_token": token}
+		if parent != "" {
+			request["parent"] = parent
+		}
+		in, err := newRequest(method, request)
+		if err != nil {
+			return nil, err
+		}
+		out, err := invoke(ctx, d.conn, method, in)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", methodName, err)
+		}
+		value, err := toMap(out)
+		if err != nil {
+			return nil, err
+		}
+		items, _ := value[field].([]interface{})
+		for _, item := range items {
+			if resource, ok := item.(map[string]interface{}); ok {
+				resources = append(resources, resource)
+			}
+		}
+		token, _ = value[nextPageTokenField].(string)
+		if token == "" {
+			return resources, nil
+		}
+	}
+}
+
+// stats fetches the counters of a controller or of a namespace, keeping them in current, and
+// returns its rates since the previous refresh
+func (d *dashboard) stats(ctx context.Context, methodName string, name string, current map[string]volumeStats, frame *dashboardFrame) *volumeRates {
+	method := d.service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil
+	}
+	in, err := newRequest(method, map[string]interface{}{"name": name})
+	if err != nil {
+		return nil
+	}
+	out, err := invoke(ctx, d.conn, method, in)
+	if err != nil {
+		frame.errors = append(frame.errors, fmt.Sprintf("%s %s: %v", methodName, name, err))
+		return nil
+	}
+	value, err := toMap(out)
+	if err != nil {
+		return nil
+	}
+	sample := volumeStats{
+		time:       d.now(),
+		readOps:    number(value, "stats.read_ops_count"),
+		writeOps:   number(value, "stats.write_ops_count"),
+		readBytes:  number(value, "stats.read_bytes_count"),
+		writeBytes: number(value, "stats.write_bytes_count"),
+	}
+	current[name] = sample
+	previous, ok := d.previous[name]
+	if !ok {
+		return nil
+	}
+	return rates(previous, sample)
+}
+
+// rates returns the rates between two samples of counters, none when they were cleared in
+// between
+func rates(previous volumeStats, current volumeStats) *volumeRates {
+	elapsed := current.time.Sub(previous.time).Seconds()
+	if elapsed <= 0 || current.readOps < previous.readOps || current.writeOps < previous.writeOps ||
+		current.readBytes < previous.readBytes || current.writeBytes < previous.writeBytes {
+		return nil
+	}
+	return &volumeRates{
+		readIops:      (current.readOps - previous.readOps) / elapsed,
+		writeIops:     (current.writeOps - previous.writeOps) / elapsed,
+		readBytesSec:  (current.readBytes - previous.readBytes) / elapsed,
+		writeBytesSec: (current.writeBytes - previous.writeBytes) / elapsed,
+	}
+}
+
+// listEvents lists the events from the HTTP server of the bridge, keeping the last ones, the
+// newest first
+func (d *dashboard) listEvents(ctx context.Context) ([]*events.Event, error) {
+	var last []*events.Event
+	token := ""
+	for {
+		body, err := json.Marshal(&events.ListEventsRequest{PageSize: eventsPageSize, PageToken: token})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.eventsURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if d.profile.APIKey != "" {
+			req.Header.Set(apikey.HeaderKey, d.profile.APIKey)
+		}
+		if d.profile.Token != "" {
+			req.Header.Set(oidc.HeaderKey, "Bearer "+d.profile.Token)
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("events: %w", err)
+		}
+		var out events.ListEventsResponse
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("events: %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("events: %w", err)
+		}
+		last = append(last, out.Events...)
+		if len(last) > d.options.events {
+			last = last[len(last)-d.options.events:]
+		}
+		if out.NextPageToken == "" {
+			break
+		}
+		token = out.NextPageToken
+	}
+	for i, j := 0, len(last)-1; i < j; i, j = i+1, j-1 {
+		last[i], last[j] = last[j], last[i]
+	}
+	return last, nil
+}
+
+// render writes a frame of the dashboard as text
+func (d *dashboard) render(w io.Writer, frame *dashboardFrame) error {
+	fmt.Fprintf(w, "%s\n", d.header(frame))
+	for _, table := range d.tables(frame) {
+		lines, err := table.lines()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\n%s\n", strings.Join(lines, "\n"))
+	}
+	for _, message := range frame.errors {
+		fmt.Fprintln(w, "\nError:", message)
+	}
+	return nil
+}
+
+// header returns the first line of a frame
+func (d *dashboard) header(frame *dashboardFrame) string {
+	return fmt.Sprintf("OPI Marvell bridge %s  %s UTC", d.profile.Address, frame.time.UTC().Format(time.DateTime))
+}
+
+// tables returns the tables of a frame, the subsystems, the controllers, the namespaces and the
+// events when they are listed
+func (d *dashboard) tables(frame *dashboardFrame) []dashboardTable {
+	subsystems := dashboardTable{header: "SUBSYSTEM\tNQN\tCONTROLLERS\tNAMESPACES"}
+	for _, row := range frame.subsystems {
+		subsystems.rows = append(subsystems.rows, dashboardRow{
+			cells:     fmt.Sprintf("%s\t%s\t%d\t%d", shortName(row.name), row.nqn, row.controllers, row.namespaces),
+			subsystem: row.name,
+			details:   resourceDetails(row.resource, nil),
+		})
+	}
+	controllers := dashboardTable{header: "CONTROLLER\tSUBSYSTEM\tPCIE\tREAD IOPS\tWRITE IOPS\tREAD MB/S\tWRITE MB/S"}
+	for _, row := range frame.controllers {
+		controllers.rows = append(controllers.rows, dashboardRow{cells: deviceCells(row), subsystem: row.subsystem, details: resourceDetails(row.resource, row.rates)})
+	}
+	namespaces := dashboardTable{header: "NAMESPACE\tSUBSYSTEM\tNSID\tVOLUME\tREAD IOPS\tWRITE IOPS\tREAD MB/S\tWRITE MB/S"}
+	for _, row := range frame.namespaces {
+		namespaces.rows = append(namespaces.rows, dashboardRow{cells: deviceCells(row), subsystem: row.subsystem, details: resourceDetails(row.resource, row.rates)})
+	}
+	tables := []dashboardTable{subsystems, controllers, namespaces}
+	if d.eventsURL == "" {
+		return tables
+	}
+	events := dashboardTable{header: "EVENT TIME\tRESOURCE\tTYPE\tMESSAGE"}
+	for _, event := range frame.events {
+		events.rows = append(events.rows, dashboardRow{
+			cells:   fmt.Sprintf("%s\t%s\t%s\t%s", event.Time.UTC().Format(time.TimeOnly), shortName(event.Name), event.Type, event.Message),
+			details: resourceDetails(event, nil),
+		})
+	}
+	return append(tables, events)
+}
+
+// lines returns the header and the rows of a table, their columns aligned
+func (t dashboardTable) lines() ([]string, error) {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, t.header)
+	for _, row := range t.rows {
+		fmt.Fprintln(tw, row.cells)
+	}
+	if err := tw.Flush(); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), nil
+}
+
+// resourceDetails returns the fields of a resource, indented, after its rates when known
+func resourceDetails(resource interface{}, rates *volumeRates) []string {
+	var details []string
+	if rates != nil {
+		details = append(details, fmt.Sprintf("read %.0f IOPS %.1f MB/s, write %.0f IOPS %.1f MB/s",
+			rates.readIops, rates.readBytesSec/1e6, rates.writeIops, rates.writeBytesSec/1e6))
+	}
+	content, err := json.MarshalIndent(resource, "", "  ")
+	if err != nil {
+		return append(details, err.Error())
+	}
+	return append(details, strings.Split(string(content), "\n")...)
+}
+
+// deviceCells returns the cells of a controller or of a namespace, tab separated
+func deviceCells(row deviceRow) string {
+	cells := append([]string{shortName(row.name), shortName(row.subsystem)}, row.columns...)
+	if row.rates == nil {
+		cells = append(cells, "-", "-", "-", "-")
+	} else {
+		cells = append(cells,
+			fmt.Sprintf("%.0f", row.rates.readIops),
+			fmt.Sprintf("%.0f", row.rates.writeIops),
+			fmt.Sprintf("%.1f", row.rates.readBytesSec/1e6),
+			fmt.Sprintf("%.1f", row.rates.writeBytesSec/1e6))
+	}
+	return strings.Join(cells, "\t")
+}
+
+// toMap returns the fields of a response, with the names of the proto fields
+func toMap(m *dynamicpb.Message) (map[string]interface{}, error) {
+	content, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	value := make(map[string]interface{})
+	if err := json.Unmarshal(content, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// lookup returns a field of a response as text, the path of the nested ones dotted, empty when
+// it isn't set
+func lookup(value map[string]interface{}, path string) string {
+	var field interface{} = value
+	for _, key := range strings.Split(path, ".") {
+		m, ok := field.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		field = m[key]
+	}
+	switch v := field.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// number returns a numeric field of a response, the 64 bits ones being strings in JSON, 0 when
+// it isn't set
+func number(value map[string]interface{}, path string) float64 {
+	n, _ := strconv.ParseFloat(lookup(value, path), 64)
+	return n
+}
+
+// shortName returns the last segment of the name of a resource, i.e. subsys0 for
+// //storage.opiproject.org/nvmeSubsystems/subsys0
+func shortName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// orDash returns a cell, - when empty
+func orDash(cell string) string {
+	if cell == "" {
+		return "-"
+	}
+	return cell
+}
@@ -0,0 +1,294 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"context"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// dashboardKeys tells the keys of the interactive dashboard
+const dashboardKeys = "tab: next table  up/down: select  enter: drill into the subsystem  esc: all the subsystems  r: refresh  q: quit"
+
+// pageRows is the number of rows PgUp and PgDn move the selection by
+const pageRows = 10
+
+// Indexes of the tables of the dashboard, the events being the last one when listed
+const (
+	subsystemsTable = iota
+	controllersTable
+	namespacesTable
+)
+
+// dashboardAction is what a key asks the dashboard to do, besides changing its view
+type dashboardAction int
+
+const (
+	actionNone dashboardAction = iota
+	actionRefresh
+	actionQuit
+)
+
+// dashboardView is what the interactive dashboard shows of the frames, the focused table, the
+// selected row of each table and the subsystem drilled into
+type dashboardView struct {
+	focus    int
+	selected map[int]int
+	// subsystem is the name of the subsystem whose controllers and namespaces are shown only,
+	// all of them when empty
+	subsystem string
+	// top is the first line of the tables shown, so the selected row stays visible
+	top int
+}
+
+// newDashboardView returns a view of the first row of the subsystems
+func newDashboardView() *dashboardView {
+	return &dashboardView{selected: make(map[int]int)}
+}
+
+// show refreshes the dashboard every interval and draws it on the initialized screen, handling
+// the keys, until ctx is done or it is quit
+func (d *dashboard) show(ctx context.Context, screen tcell.Screen, timeout time.Duration) error {
+	done := make(chan struct{})
+	defer close(done)
+	screenEvents := make(chan tcell.Event)
+	go func() {
+		for {
+			event := screen.PollEvent()
+			if event == nil {
+				return
+			}
+			select {
+			case screenEvents <- event:
+			case <-done:
+				return
+			}
+		}
+	}()
+	// a single refresh runs at a time, its frame is kept until drawn
+	frames := make(chan *dashboardFrame, 1)
+	refreshing := false
+	refresh := func() {
+		if refreshing {
+			return
+		}
+		refreshing = true
+		go func() {
+			refreshCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			frames <- d.refresh(refreshCtx)
+		}()
+	}
+	ticker := time.NewTicker(d.options.interval)
+	defer ticker.Stop()
+	view := newDashboardView()
+	frame := &dashboardFrame{time: d.now()}
+	refresh()
+	for {
+		tables := view.filter(d.tables(frame))
+		view.draw(screen, d.header(frame), tables, frame.errors)
+		screen.Show()
+		select {
+		case <-ctx.Done():
+			return nil
+		case frame = <-frames:
+			refreshing = false
+		case <-ticker.C:
+			refresh()
+		case event := <-screenEvents:
+			switch event := event.(type) {
+			case *tcell.EventResize:
+				screen.Sync()
+			case *tcell.EventKey:
+				switch view.handle(event, tables) {
+				case actionQuit:
+					return nil
+				case actionRefresh:
+					refresh()
+				}
+			}
+		}
+	}
+}
+
+// filter keeps the controllers and the namespaces of the subsystem drilled into
+func (v *dashboardView) filter(tables []dashboardTable) []dashboardTable {
+	if v.subsystem == "" {
+		return tables
+	}
+	for _, i := range []int{controllersTable, namespacesTable} {
+		var rows []dashboardRow
+		for _, row := range tables[i].rows {
+			if row.subsystem == v.subsystem {
+				rows = append(rows, row)
+			}
+		}
+		tables[i].rows = rows
+	}
+	return tables
+}
+
+// handle changes the view on a key, it returns what else the key asks for
+func (v *dashboardView) handle(event *tcell.EventKey, tables []dashboardTable) dashboardAction {
+	rows := len(tables[v.focus].rows)
+	switch event.Key() {
+	case tcell.KeyCtrlC:
+		return actionQuit
+	case tcell.KeyTab, tcell.KeyRight:
+		v.focus = (v.focus + 1) % len(tables)
+	case tcell.KeyBacktab, tcell.KeyLeft:
+		v.focus = (v.focus + len(tables) - 1) % len(tables)
+	case tcell.KeyUp:
+		v.selected[v.focus]--
+	case tcell.KeyDown:
+		v.selected[v.focus]++
+	case tcell.KeyPgUp:
+		v.selected[v.focus] -= pageRows
+	case tcell.KeyPgDn:
+		v.selected[v.focus] += pageRows
+	case tcell.KeyHome:
+		v.selected[v.focus] = 0
+	case tcell.KeyEnd:
+		v.selected[v.focus] = rows - 1
+	case tcell.KeyEnter:
+		if v.focus == subsystemsTable && rows != 0 {
+			v.subsystem = tables[subsystemsTable].rows[v.clamp(tables)].subsystem
+			v.focus = controllersTable
+			v.selected[controllersTable], v.selected[namespacesTable] = 0, 0
+		}
+	case tcell.KeyEscape:
+		v.subsystem = ""
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'q':
+			return actionQuit
+		case 'r':
+			return actionRefresh
+		case 'k':
+			v.selected[v.focus]--
+		case 'j':
+			v.selected[v.focus]++
+		}
+	}
+	v.clamp(tables)
+	return actionNone
+}
+
+// clamp keeps the selected row of the focused table within its rows, the tables changing with
+// the refreshes, and returns it
+func (v *dashboardView) clamp(tables []dashboardTable) int {
+	if v.focus >= len(tables) {
+		v.focus = 0
+	}
+	selected := v.selected[v.focus]
+	if rows := len(tables[v.focus].rows); selected >= rows {
+		selected = rows - 1
+	}
+	if selected < 0 {
+		selected = 0
+	}
+	v.selected[v.focus] = selected
+	return selected
+}
+
+// draw draws the header, the tables with the selected row, the errors and the details of the
+// selected row below them
+func (v *dashboardView) draw(screen tcell.Screen, header string, tables []dashboardTable, errors []string) {
+	screen.Clear()
+	width, height := screen.Size()
+	bold := tcell.StyleDefault.Bold(true)
+	title := header
+	if v.subsystem != "" {
+		title += "  subsystem " + shortName(v.subsystem)
+	}
+	drawLine(screen, 0, width, bold, title)
+	drawLine(screen, 1, width, tcell.StyleDefault.Dim(true), dashboardKeys)
+
+	selected := v.clamp(tables)
+	type styledLine struct {
+		text  string
+		style tcell.Style
+	}
+	var lines []styledLine
+	selectedLine := 0
+	for i, table := range tables {
+		tableLines, err := table.lines()
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+		if i != 0 {
+			lines = append(lines, styledLine{})
+		}
+		headerStyle := bold
+		if i == v.focus {
+			headerStyle = headerStyle.Underline(true)
+		}
+		lines = append(lines, styledLine{text: tableLines[0], style: headerStyle})
+		for j, text := range tableLines[1:] {
+			style := tcell.StyleDefault
+			if i == v.focus && j == selected {
+				style = style.Reverse(true)
+				selectedLine = len(lines)
+			}
+			lines = append(lines, styledLine{text: text, style: style})
+		}
+	}
+	for _, message := range errors {
+		lines = append(lines, styledLine{}, styledLine{text: "Error: " + message, style: tcell.StyleDefault.Foreground(tcell.ColorRed)})
+	}
+
+	// the details take the lower third of the screen
+	var details []string
+	if rows := tables[v.focus].rows; len(rows) != 0 {
+		details = rows[selected].details
+	}
+	detailsHeight := height / 3
+	tablesTop, tablesHeight := 3, height-3-detailsHeight
+	if tablesHeight < 1 {
+		tablesHeight = 1
+	}
+	if selectedLine < v.top {
+		v.top = selectedLine
+	}
+	if selectedLine >= v.top+tablesHeight {
+		v.top = selectedLine - tablesHeight + 1
+	}
+	if v.top > len(lines)-1 {
+		v.top = 0
+	}
+	for y := 0; y < tablesHeight && v.top+y < len(lines); y++ {
+		drawLine(screen, tablesTop+y, width, lines[v.top+y].style, lines[v.top+y].text)
+	}
+	if detailsHeight < 2 {
+		return
+	}
+	detailsTop := height - detailsHeight
+	drawLine(screen, detailsTop, width, bold.Underline(true), "DETAILS")
+	for y := 1; y < detailsHeight && y-1 < len(details); y++ {
+		drawLine(screen, detailsTop+y, width, tcell.StyleDefault, details[y-1])
+	}
+}
+
+// drawLine draws a line of text at row y, cut at width
+func drawLine(screen tcell.Screen, y int, width int, style tcell.Style, text string) {
+	x := 0
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > width {
+			return
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+	// the styled lines span the screen, the selected row included
+	for ; x < width && style != tcell.StyleDefault; x++ {
+		screen.SetContent(x, y, ' ', nil, style)
+	}
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/opiproject/opi-marvell-bridge/pkg/apikey"
+	"github.com/opiproject/opi-marvell-bridge/pkg/events"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestCtl_Dashboard(t *testing.T) {
+	files := testFiles(t)
+	address := startTestServer(t, files)
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/events" || r.Header.Get(apikey.HeaderKey) != "secret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var in events.ListEventsRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out := &events.ListEventsResponse{}
+		if in.PageToken == "" {
+			out.Events = []*events.Event{
+				{Time: start, Name: testSubsystemName, Type: events.TypeCreated},
+				{Time: start.Add(time.Second), Name: testSubsystemName + "/nvmeControllers/ctrl0", Type: events.TypeCreated},
+			}
+			out.NextPageToken = "next"
+		} else {
+			out.Events = []*events.Event{
+				{Time: start.Add(2 * time.Second), Name: testSubsystemName + "/nvmeNamespaces/ns0", Type: events.TypeFirmwareError, Message: "no such bdev"},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	config := filepath.Join(t.TempDir(), "config.yaml")
+
+	output, err := run(files, "dashboard", "--once", "--events", "2", "--http_port", port, "-a", address, "--api_key", "secret", "--config", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"subsys0    nqn.2022-09.io.spdk:opi0  1            1\n",
+		"subsys1    nqn.2022-09.io.spdk:opi1  0            0\n",
+		"ctrl0       subsys0    PF 1 VF -  -          -           -          -\n",
+		"ns0        subsys0    1     Malloc0  -          -           -          -\n",
+		"10:00:02    ns0       FIRMWARE_ERROR  no such bdev\n10:00:01    ctrl0     CREATED",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Error("output: expected", expected, "received", output)
+		}
+	}
+	if strings.Contains(output, "10:00:00") || strings.Contains(output, "Error:") {
+		t.Error("output: expected the last 2 events only, received", output)
+	}
+
+	output, err = run(files, "dashboard", "--once", "--http_port", "0", "-a", address, "--config", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "Error: ListNvmeSubsystems: Unauthenticated: Missing API key") || strings.Contains(output, "EVENT") {
+		t.Error("output: expected the error without the events, received", output)
+	}
+
+	if _, err := run(files, "dashboard", "--interval", "0s", "--config", config); err == nil {
+		t.Error("expected an error for an invalid interval")
+	}
+}
+
+func TestCtl_DashboardRates(t *testing.T) {
+	files := testFiles(t)
+	profile := &Profile{Address: startTestServer(t, files), APIKey: "secret"}
+	conn, err := dial(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	descriptor, err := files.FindDescriptorByName(dashboardService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	controller := testSubsystemName + "/nvmeControllers/ctrl0"
+	namespace := testSubsystemName + "/nvmeNamespaces/ns0"
+	board := &dashboard{
+		service: descriptor.(protoreflect.ServiceDescriptor),
+		profile: profile,
+		conn:    conn,
+		previous: map[string]volumeStats{
+			controller: {time: now.Add(-2 * time.Second), writeOps: 10},
+			// the counters of the namespace were cleared since
+			namespace: {time: now.Add(-2 * time.Second), readOps: 10},
+			testSubsystemName + "/nvmeNamespaces/deleted": {time: now.Add(-2 * time.Second)},
+		},
+		now: func() time.Time { return now },
+	}
+
+	frame := board.refresh(context.Background())
+	if len(frame.errors) != 0 || len(frame.controllers) != 1 || len(frame.namespaces) != 1 {
+		t.Fatal("frame: expected a controller and a namespace, received", frame)
+	}
+	expected := volumeRates{readIops: 5, writeIops: 5, readBytesSec: 2048, writeBytesSec: 4096}
+	if frame.controllers[0].rates == nil || *frame.controllers[0].rates != expected {
+		t.Error("controller rates: expected", expected, "received", frame.controllers[0].rates)
+	}
+	if frame.namespaces[0].rates != nil {
+		t.Error("namespace rates: expected none, received", frame.namespaces[0].rates)
+	}
+	if len(board.previous) != 2 || board.previous[namespace].readOps != 5 {
+		t.Error("previous: expected the counters of this refresh, received", board.previous)
+	}
+}
+
+func TestCtl_DashboardScreen(t *testing.T) {
+	files := testFiles(t)
+	profile := &Profile{Address: startTestServer(t, files), APIKey: "secret"}
+	conn, err := dial(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	descriptor, err := files.FindDescriptorByName(dashboardService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := &dashboard{
+		service:  descriptor.(protoreflect.ServiceDescriptor),
+		profile:  profile,
+		conn:     conn,
+		options:  dashboardOptions{interval: time.Hour},
+		previous: make(map[string]volumeStats),
+		now:      time.Now,
+	}
+	screen := &testScreen{SimulationScreen: tcell.NewSimulationScreen("UTF-8")}
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(120, 45)
+	shown := make(chan error)
+	go func() {
+		shown <- board.show(context.Background(), screen, 10*time.Second)
+	}()
+
+	// the selected row is highlighted, its details shown below the tables
+	waitScreen(t, screen, "subsys1    nqn.2022-09.io.spdk:opi1", true)
+	if style := rowStyle(screen, "subsys0 "); style != tcell.StyleDefault.Reverse(true) {
+		t.Error("subsys0: expected selected, received", style)
+	}
+	waitScreen(t, screen, `"nqn": "nqn.2022-09.io.spdk:opi0"`, true)
+
+	// the selection moves down, enter drills into the subsystem without controllers and esc back
+	screen.InjectKey(tcell.KeyDown, 0, tcell.ModNone)
+	waitScreen(t, screen, `"nqn": "nqn.2022-09.io.spdk:opi1"`, true)
+	screen.InjectKey(tcell.KeyEnter, 0, tcell.ModNone)
+	waitScreen(t, screen, "ctrl0 ", false)
+	screen.InjectKey(tcell.KeyEscape, 0, tcell.ModNone)
+	waitScreen(t, screen, "ctrl0 ", true)
+
+	// the controllers are focused once drilled into, then tab moves to the namespaces
+	waitScreen(t, screen, `"name": "`+testSubsystemName+`/nvmeControllers/ctrl0"`, true)
+	screen.InjectKey(tcell.KeyTab, 0, tcell.ModNone)
+	waitScreen(t, screen, `"volume_name_ref": "Malloc0"`, true)
+
+	screen.InjectKey(tcell.KeyRune, 'q', tcell.ModNone)
+	select {
+	case err := <-shown:
+		if err != nil {
+			t.Error("expected no error, received", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the dashboard to be quit")
+	}
+}
+
+// testScreen is a simulation screen whose contents are read while not shown
+type testScreen struct {
+	tcell.SimulationScreen
+	mu sync.Mutex
+}
+
+func (s *testScreen) Show() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SimulationScreen.Show()
+}
+
+// contents returns a copy of the cells of the screen and its size
+func (s *testScreen) contents() ([]tcell.SimCell, int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cells, width, height := s.GetContents()
+	return append([]tcell.SimCell(nil), cells...), width, height
+}
+
+// screenLines returns the text of the lines of a test screen
+func screenLines(screen *testScreen) []string {
+	cells, width, height := screen.contents()
+	lines := make([]string, height)
+	for y := 0; y < height; y++ {
+		var line strings.Builder
+		for x := 0; x < width; x++ {
+			line.Write(cells[y*width+x].Bytes)
+		}
+		lines[y] = line.String()
+	}
+	return lines
+}
+
+// rowStyle returns the style of the first line of a test screen starting with prefix
+func rowStyle(screen *testScreen, prefix string) tcell.Style {
+	cells, width, _ := screen.contents()
+	for y, line := range screenLines(screen) {
+		if strings.HasPrefix(line, prefix) {
+			return cells[y*width].Style
+		}
+	}
+	return tcell.StyleDefault
+}
+
+// waitScreen waits for a test screen to show text, or not to
+func waitScreen(t *testing.T, screen *testScreen, text string, shown bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Contains(strings.Join(screenLines(screen), "\n"), text) != shown {
+		if time.Now().After(deadline) {
+			t.Fatal("screen: expected", text, "shown", shown, "received", strings.Join(screenLines(screen), "\n"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
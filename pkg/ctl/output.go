@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormats are the formats the responses are printed in
+var outputFormats = []string{"json", "yaml", "table"}
+
+// nextPageTokenField is the field of the list responses continuing the listing
+const nextPageTokenField = "next_page_token"
+
+// checkOutputFormat checks an output format, empty being the default one
+func checkOutputFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range outputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format %s, have to be %s", format, strings.Join(outputFormats, ", "))
+}
+
+// printMessage prints a response as JSON, with the names of the proto fields, as YAML or as a
+// table of its resources
+func printMessage(w io.Writer, format string, m proto.Message) error {
+	content, err := protojson.MarshalOptions{UseProtoNames: true, Multiline: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if format == "json" || format == "" {
+		_, err := fmt.Fprintln(w, string(content))
+		return err
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return err
+	}
+	if format == "yaml" {
+		if len(value) == 0 {
+			return nil
+		}
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return printTable(w, value)
+}
+
+// printTable prints the resources of a list response, or a single resource, one per row with a
+// column for each of their fields, the nested ones dotted
+func printTable(w io.Writer, value map[string]interface{}) error {
+	token, _ := value[nextPageTokenField].(string)
+	delete(value, nextPageTokenField)
+	var rows []map[string]interface{}
+	if len(value) != 0 {
+		rows = append(rows, value)
+	}
+	// a list response has a single field, the resources
+	if len(value) == 1 {
+		for _, field := range value {
+			if items, ok := field.([]interface{}); ok {
+				rows = rows[:0]
+				for _, item := range items {
+					if resource, ok := item.(map[string]interface{}); ok {
+						rows = append(rows, resource)
+					}
+				}
+			}
+		}
+	}
+	if len(rows) != 0 {
+		if err := printRows(w, rows); err != nil {
+			return err
+		}
+	}
+	if token != "" {
+		_, err := fmt.Fprintf(w, "\nNext page token: %s\n", token)
+		return err
+	}
+	return nil
+}
+
+// printRows prints the resources, the name first
+func printRows(w io.Writer, rows []map[string]interface{}) error {
+	var columns []string
+	seen := make(map[string]bool)
+	flattened := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make(map[string]string)
+		flatten("", row, cells)
+		keys := make([]string, 0, len(cells))
+		for key := range cells {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+		flattened = append(flattened, cells)
+	}
+	sort.SliceStable(columns, func(i, j int) bool { return columns[i] == "name" && columns[j] != "name" })
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	headers := make([]string, 0, len(columns))
+	for _, column := range columns {
+		headers = append(headers, strings.ToUpper(column))
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, cells := range flattened {
+		values := make([]string, 0, len(columns))
+		for _, column := range columns {
+			values = append(values, cells[column])
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// flatten sets the cells of the fields of a resource, the nested fields prefixed with the dotted
+// path of their parents and the lists as JSON
+func flatten(prefix string, value map[string]interface{}, cells map[string]string) {
+	for key, field := range value {
+		switch v := field.(type) {
+		case map[string]interface{}:
+			flatten(prefix+key+".", v, cells)
+		case []interface{}:
+			content, _ := json.Marshal(v)
+			cells[prefix+key] = string(content)
+		default:
+			cells[prefix+key] = fmt.Sprint(v)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCtl_PrintTable(t *testing.T) {
+	tests := map[string]struct {
+		value    map[string]interface{}
+		expected string
+	}{
+		"single resource": {
+			value: map[string]interface{}{
+				"spec": map[string]interface{}{"nqn": "nqn.2022-09.io.spdk:opi0"},
+				"name": "subsys0",
+			},
+			expected: "NAME     SPEC.NQN\nsubsys0  nqn.2022-09.io.spdk:opi0\n",
+		},
+		"list with missing fields": {
+			value: map[string]interface{}{
+				"nvme_controllers": []interface{}{
+					map[string]interface{}{"name": "ctrl0", "spec": map[string]interface{}{"trtype": "TCP"}},
+					map[string]interface{}{"name": "ctrl1", "hostnqn": "nqn.host"},
+				},
+				"next_page_token": "page2",
+			},
+			expected: "NAME   SPEC.TRTYPE  HOSTNQN\nctrl0  TCP          \nctrl1               nqn.host\n\nNext page token: page2\n",
+		},
+		"list field": {
+			value: map[string]interface{}{
+				"name":  "ns0",
+				"paths": []interface{}{"a", "b"},
+			},
+			expected: "NAME  PATHS\nns0   [\"a\",\"b\"]\n",
+		},
+		"empty response": {
+			value:    map[string]interface{}{},
+			expected: "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printTable(&out, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("table: expected %q received %q", tt.expected, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configEnv overrides the default file of the profiles
+const configEnv = "OPI_MARVELL_CTL_CONFIG"
+
+// Profile represents how to connect to a bridge
+type Profile struct {
+	// Address of the gRPC server of the bridge, in host:port format
+	Address string `yaml:"address,omitempty"`
+	// CaCert is the CA certificate of the bridge, the connection is plaintext when empty
+	CaCert string `yaml:"caCert,omitempty"`
+	// Cert and Key are the client certificate presented to the bridge
+	Cert string `yaml:"cert,omitempty"`
+	Key  string `yaml:"key,omitempty"`
+	// APIKey is sent with the calls
+	APIKey string `yaml:"apiKey,omitempty"`
+	// Token is the OIDC bearer token sent with the calls
+	Token string `yaml:"token,omitempty"`
+	// Output is the output format, json, yaml or table
+	Output string `yaml:"output,omitempty"`
+}
+
+// Profiles represents the saved connection profiles
+type Profiles struct {
+	// Current is the profile used when none is selected
+	Current  string              `yaml:"current,omitempty"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// defaultConfigPath returns the file of the profiles, in the configuration directory of the user
+func defaultConfigPath() string {
+	if path := os.Getenv(configEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".opi-marvell-ctl.yaml"
+	}
+	return filepath.Join(dir, "opi-marvell-ctl", "config.yaml")
+}
+
+// loadProfiles reads the profiles saved in path, none when it doesn't exist
+func loadProfiles(path string) (*Profiles, error) {
+	profiles := &Profiles{Profiles: make(map[string]*Profile)}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, profiles); err != nil {
+		return nil, fmt.Errorf("invalid profiles in %s: %w", path, err)
+	}
+	if profiles.Profiles == nil {
+		profiles.Profiles = make(map[string]*Profile)
+	}
+	return profiles, nil
+}
+
+// save writes the profiles to path, readable by the user only as they hold credentials
+func (p *Profiles) save(path string) error {
+	content, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o600)
+}
+
+// names returns the names of the profiles, sorted
+func (p *Profiles) names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// merge overrides the fields of a profile with the ones set in other
+func (p *Profile) merge(other *Profile) {
+	for _, field := range []struct {
+		to   *string
+		from string
+	}{
+		{&p.Address, other.Address},
+		{&p.CaCert, other.CaCert},
+		{&p.Cert, other.Cert},
+		{&p.Key, other.Key},
+		{&p.APIKey, other.APIKey},
+		{&p.Token, other.Token},
+		{&p.Output, other.Output},
+	} {
+		if field.from != "" {
+			*field.to = field.from
+		}
+	}
+}
+
+// resolveProfile returns the selected profile, or the current one, overridden by the flags
+func (o *options) resolveProfile() (*Profile, error) {
+	profiles, err := loadProfiles(o.config)
+	if err != nil {
+		return nil, err
+	}
+	profile := &Profile{Address: defaultAddress, Output: "json"}
+	name := o.profile
+	if name == "" {
+		name = profiles.Current
+	}
+	if name != "" {
+		saved, ok := profiles.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %s, have to be one of %s", name, strings.Join(profiles.names(), ", "))
+		}
+		profile.merge(saved)
+	}
+	profile.merge(&o.connection)
+	return profile, nil
+}
+
+// completeProfiles completes the names of the saved profiles
+func (o *options) completeProfiles(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	profiles, err := loadProfiles(o.config)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return profiles.names(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// newProfileCommand returns the command managing the connection profiles
+func (o *options) newProfileCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage the saved connection profiles",
+	}
+	completeName := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return o.completeProfiles(cmd, args, toComplete)
+	}
+	command.AddCommand(&cobra.Command{
+		Use:   "save NAME",
+		Short: "Save the connection flags as a profile, updating it when it exists",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if err := checkOutputFormat(o.connection.Output); err != nil {
+				return err
+			}
+			profiles, err := loadProfiles(o.config)
+			if err != nil {
+				return err
+			}
+			profile, ok := profiles.Profiles[args[0]]
+			if !ok {
+				profile = &Profile{}
+				profiles.Profiles[args[0]] = profile
+			}
+			profile.merge(&o.connection)
+			if profiles.Current == "" {
+				profiles.Current = args[0]
+			}
+			return profiles.save(o.config)
+		},
+	})
+	command.AddCommand(&cobra.Command{
+		Use:               "use NAME",
+		Short:             "Make a profile the current one",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeName,
+		RunE: func(_ *cobra.Command, args []string) error {
+			profiles, err := loadProfiles(o.config)
+			if err != nil {
+				return err
+			}
+			if _, ok := profiles.Profiles[args[0]]; !ok {
+				return fmt.Errorf("unknown profile %s", args[0])
+			}
+			profiles.Current = args[0]
+			return profiles.save(o.config)
+		},
+	})
+	command.AddCommand(&cobra.Command{
+		Use:               "delete NAME",
+		Short:             "Delete a profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeName,
+		RunE: func(_ *cobra.Command, args []string) error {
+			profiles, err := loadProfiles(o.config)
+			if err != nil {
+				return err
+			}
+			if _, ok := profiles.Profiles[args[0]]; !ok {
+				return fmt.Errorf("unknown profile %s", args[0])
+			}
+			delete(profiles.Profiles, args[0])
+			if profiles.Current == args[0] {
+				profiles.Current = ""
+			}
+			return profiles.save(o.config)
+		},
+	})
+	command.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the profiles, the current one marked with *",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			profiles, err := loadProfiles(o.config)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "CURRENT\tNAME\tADDRESS\tTLS\tOUTPUT")
+			for _, name := range profiles.names() {
+				profile := profiles.Profiles[name]
+				current := ""
+				if name == profiles.Current {
+					current = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\n", current, name, profile.Address, profile.CaCert != "", profile.Output)
+			}
+			return w.Flush()
+		},
+	})
+	return command
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ctl implements opi-marvell-ctl, the administrative command line of the bridge calling
+// the methods of its frontend, middleend and backend services
+package ctl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCtl_Profiles(t *testing.T) {
+	files := testFiles(t)
+	address := startTestServer(t, files)
+	config := filepath.Join(t.TempDir(), "ctl", "config.yaml")
+	steps := []struct {
+		args     []string
+		expected string
+		errMsg   string
+	}{
+		{args: []string{"profile", "save", "lab", "-a", address, "--api_key", "secret", "-o", "yaml"}},
+		{args: []string{"profile", "save", "prod", "-a", "prod:50051", "--api_key", "other"}},
+		{args: []string{"profile", "list"}, expected: "*        lab "},
+		// the current profile is used when none is selected
+		{args: []string{"frontend", "get-nvme-subsystem", "--name", testSubsystemName}, expected: "name: " + testSubsystemName + "\n"},
+		// the flags override the profile
+		{args: []string{"frontend", "get-nvme-subsystem", "--name", testSubsystemName, "-o", "table"}, expected: "SPEC.NQN"},
+		{args: []string{"frontend", "get-nvme-subsystem", "--name", testSubsystemName, "--api_key", "wrong"}, errMsg: "Unauthenticated: Missing API key"},
+		{args: []string{"profile", "use", "prod"}},
+		{args: []string{"frontend", "get-nvme-subsystem", "--name", testSubsystemName, "-p", "lab"}, expected: "name: " + testSubsystemName + "\n"},
+		{args: []string{"profile", "use", "staging"}, errMsg: "unknown profile staging"},
+		{args: []string{"profile", "delete", "prod"}},
+		{args: []string{"frontend", "get-nvme-subsystem", "-p", "prod"}, errMsg: "unknown profile prod, have to be one of lab"},
+		{args: []string{"profile", "save", "bad", "-o", "xml"}, errMsg: "invalid output format xml"},
+	}
+
+	// run tests
+	for i, step := range steps {
+		output, err := run(files, append(step.args, "--config", config)...)
+		if step.errMsg != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), step.errMsg) {
+				t.Error("step", i, "error: expected", step.errMsg, "received", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("step", i, err)
+		}
+		if !strings.Contains(output, step.expected) {
+			t.Error("step", i, "output: expected", step.expected, "received", output)
+		}
+	}
+
+	profiles, err := loadProfiles(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profiles.Current != "" || len(profiles.Profiles) != 1 || profiles.Profiles["lab"].Output != "yaml" {
+		t.Error("profiles: expected lab only, received", profiles)
+	}
+	info, err := os.Stat(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Error("mode: expected", os.FileMode(0o600), "received", info.Mode().Perm())
+	}
+}
+
+func TestCtl_ResolveProfile(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	saved := &Profiles{Current: "lab", Profiles: map[string]*Profile{
+		"lab": {Address: "lab:50051", APIKey: "secret", Output: "table"},
+	}}
+	if err := saved.save(config); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		options  options
+		expected Profile
+	}{
+		"current profile": {
+			options:  options{config: config},
+			expected: Profile{Address: "lab:50051", APIKey: "secret", Output: "table"},
+		},
+		"overridden by the flags": {
+			options:  options{config: config, connection: Profile{Address: "other:50051", Token: "token"}},
+			expected: Profile{Address: "other:50051", APIKey: "secret", Token: "token", Output: "table"},
+		},
+		"no profile": {
+			options:  options{config: filepath.Join(t.TempDir(), "missing.yaml")},
+			expected: Profile{Address: defaultAddress, Output: "json"},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			profile, err := tt.options.resolveProfile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *profile != tt.expected {
+				t.Error("profile: expected", tt.expected, "received", *profile)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package emulator emulates the JSON-RPC surface of the Marvell firmware in memory, so the whole
+// gRPC API of the bridge can be exercised without a DPU nor SPDK, i.e. by users trying it out and
+// in CI
+package emulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+const (
+	// Sku is the SKU the emulated firmware reports
+	Sku = "EMULATED"
+	// SdkVersion is the Marvell SDK release the emulated firmware reports
+	SdkVersion = "emulated"
+	// Version is the SPDK version the emulated firmware reports
+	Version = "SPDK v21.01 emulated"
+)
+
+// handler handles the parameters of a method, returning its result
+type handler func(e *Emulator, params json.RawMessage) (interface{}, error)
+
+// Emulator implements spdk.JSONRPC, keeping the state of the firmware in memory, the methods it
+// doesn't model succeed without changing it
+type Emulator struct {
+	id         uint64
+	mu         sync.Mutex
+	subsystems map[string]*subsystem
+	// bdevs are the block devices by name
+	bdevs map[string]bool
+}
+
+// build time check that struct implements interface
+var _ spdk.JSONRPC = (*Emulator)(nil)
+
+// New returns an emulated firmware without any resource
+func New() *Emulator {
+	slog.Info("Emulating the firmware in memory, no DPU nor SPDK is used")
+	return &Emulator{
+		subsystems: make(map[string]*subsystem),
+		bdevs:      make(map[string]bool),
+	}
+}
+
+// handlers are the methods the emulator models, by name
+var handlers = map[string]handler{
+	"spdk_get_version":               (*Emulator).getVersion,
+	"mrvl_platform_get_inventory":    (*Emulator).getInventory,
+	"mrvl_nvm_get_sku_caps":          (*Emulator).getSkuCaps,
+	"mrvl_nvm_create_subsystem":      (*Emulator).createSubsystem,
+	"mrvl_nvm_delete_subsystem":      (*Emulator).deleteSubsystem,
+	"mrvl_nvm_get_subsys_list":       (*Emulator).getSubsystemList,
+	"mrvl_nvm_subsys_get_info":       (*Emulator).getSubsystemInfo,
+	"mrvl_nvm_subsys_create_ctrlr":   (*Emulator).createController,
+	"mrvl_nvm_subsys_update_ctrlr":   (*Emulator).updateController,
+	"mrvl_nvm_subsys_remove_ctrlr":   (*Emulator).removeController,
+	"mrvl_nvm_subsys_get_ctrlr_list": (*Emulator).getControllerList,
+	"mrvl_nvm_ctrlr_get_info":        (*Emulator).getControllerInfo,
+	"mrvl_nvm_subsys_alloc_ns":       (*Emulator).allocateNamespace,
+	"mrvl_nvm_subsys_unalloc_ns":     (*Emulator).unallocateNamespace,
+	"mrvl_nvm_subsys_get_ns_list":    (*Emulator).getNamespaceList,
+	"mrvl_nvm_ns_get_info":           (*Emulator).getNamespaceInfo,
+	"mrvl_nvm_ctrlr_attach_ns":       (*Emulator).attachNamespace,
+	"mrvl_nvm_ctrlr_detach_ns":       (*Emulator).detachNamespace,
+	"mrvl_nvm_get_ctrlr_stats":       (*Emulator).getControllerStats,
+	"mrvl_nvm_get_ns_stats":          (*Emulator).getNamespaceStats,
+}
+
+// GetID returns the sequence number of the last call
+func (e *Emulator) GetID() uint64 {
+	return atomic.LoadUint64(&e.id)
+}
+
+// GetVersion returns the version of the emulated firmware
+func (e *Emulator) GetVersion(_ context.Context) string {
+	return Version
+}
+
+// StartUnixListener isn't supported, nothing listens for the emulated firmware
+func (e *Emulator) StartUnixListener() net.Listener {
+	return nil
+}
+
+// Call handles a method in memory, the arguments and the result go through JSON like they do
+// with the firmware, so the mismatches of their fields show as they would
+func (e *Emulator) Call(ctx context.Context, method string, args, result interface{}) error {
+	atomic.AddUint64(&e.id, 1)
+	params, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	e.mu.Lock()
+	var response interface{}
+	if handle, ok := handlers[method]; ok {
+		response, err = handle(e, params)
+	} else {
+		response, err = e.generic(method, params, result)
+	}
+	e.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%s: json response error: %s", method, err)
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	slog.DebugContext(ctx, "Emulated the firmware", "method", method, "params", string(params), "result", string(data))
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	return nil
+}
+
+// generic handles the methods the emulator doesn't model: the creations and the deletions of the
+// bdevs keep track of their names, everything else succeeds with the zero value of its result
+func (e *Emulator) generic(method string, params json.RawMessage, result interface{}) (interface{}, error) {
+	if !strings.HasPrefix(method, "mrvl_") && !strings.HasPrefix(method, "bdev_") && !strings.HasPrefix(method, "nvmf_") {
+		return nil, errors.New("method not found")
+	}
+	var named struct {
+		Name string `json:"name"`
+		UUID string `json:"uuid"`
+	}
+	_ = json.Unmarshal(params, &named)
+	status := models.StatusSuccess
+	kind := reflect.TypeOf(result)
+	if kind != nil && kind.Kind() == reflect.Pointer {
+		kind = kind.Elem()
+	}
+	isBdev := strings.Contains(method, "bdev_") && named.Name != ""
+	switch {
+	case isBdev && strings.HasSuffix(method, "_create"):
+		if e.bdevs[named.Name] {
+			status = models.StatusAlreadyExists
+			break
+		}
+		e.bdevs[named.Name] = true
+		if named.UUID == "" {
+			named.UUID = uuid.New().String()
+		}
+	case isBdev && strings.HasSuffix(method, "_delete"):
+		if !e.bdevs[named.Name] {
+			status = models.StatusNotFound
+			break
+		}
+		delete(e.bdevs, named.Name)
+	}
+	// the SPDK methods answer a bool or the name of what they created instead of a status, they
+	// fail with an error
+	if status != 0 && (kind == nil || kind.Kind() != reflect.Struct) {
+		return nil, fmt.Errorf("bdev %s: status %d", named.Name, status)
+	}
+	switch {
+	case kind == nil:
+		return nil, nil
+	case kind.Kind() == reflect.Bool:
+		return status == 0, nil
+	case kind.Kind() == reflect.String:
+		return named.Name, nil
+	case kind.Kind() == reflect.Struct:
+		return map[string]interface{}{"status": status, "uuid": named.UUID}, nil
+	default:
+		return reflect.Zero(kind).Interface(), nil
+	}
+}
+
+// getVersion answers the version of the emulated firmware
+func (e *Emulator) getVersion(_ json.RawMessage) (interface{}, error) {
+	return spdk.GetVersionResult{Version: Version}, nil
+}
+
+// getInventory answers the SKU and the SDK release of the emulated firmware, without any drive
+func (e *Emulator) getInventory(_ json.RawMessage) (interface{}, error) {
+	return models.MrvlPlatformGetInventoryResult{
+		Sku:          Sku,
+		SerialNumber: "EMULATED0001",
+		FwVersion:    Version,
+		SdkVersion:   SdkVersion,
+	}, nil
+}
+
+// getSkuCaps answers the capabilities of the emulated SKU
+func (e *Emulator) getSkuCaps(_ json.RawMessage) (interface{}, error) {
+	return models.MrvlNvmGetSkuCapsResult{MaxPfMsixVectors: 64, MaxVfMsixVectors: 16}, nil
+}
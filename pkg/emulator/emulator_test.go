@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package emulator emulates the JSON-RPC surface of the Marvell firmware in memory, so the whole
+// gRPC API of the bridge can be exercised without a DPU nor SPDK, i.e. by users trying it out and
+// in CI
+package emulator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+const testNqn = "nqn.2022-09.io.spdk:opi3"
+
+// call calls a method of the emulator and returns the status of its result
+func call(t *testing.T, e *Emulator, method string, params interface{}) models.Status {
+	t.Helper()
+	var result struct {
+		Status models.Status `json:"status"`
+	}
+	if err := e.Call(context.Background(), method, params, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result.Status
+}
+
+func TestEmulator_Subsystems(t *testing.T) {
+	e := New()
+	// the steps share the emulator, they run in order
+	steps := []struct {
+		name   string
+		method string
+		params interface{}
+		status models.Status
+	}{
+		{"create subsystem", "mrvl_nvm_create_subsystem", models.MrvlNvmCreateSubsystemParams{Subnqn: testNqn, MaxNamespaces: 1, MaxCtrlrID: 256}, 0},
+		{"create it again", "mrvl_nvm_create_subsystem", models.MrvlNvmCreateSubsystemParams{Subnqn: testNqn, MaxCtrlrID: 256}, models.StatusAlreadyExists},
+		{"create controller", "mrvl_nvm_subsys_create_ctrlr", models.MrvlNvmSubsysCreateCtrlrParams{Subnqn: testNqn, PfID: 1, CtrlrID: 17}, 0},
+		{"same PCIe function", "mrvl_nvm_subsys_create_ctrlr", models.MrvlNvmSubsysCreateCtrlrParams{Subnqn: testNqn, PfID: 1, CtrlrID: 18}, models.StatusBusy},
+		{"controller out of range", "mrvl_nvm_subsys_create_ctrlr", models.MrvlNvmSubsysCreateCtrlrParams{Subnqn: testNqn, PfID: 2, CtrlrID: 300}, models.StatusInvalid},
+		{"allocate namespace", "mrvl_nvm_subsys_alloc_ns", models.MrvlNvmSubsysAllocNsParams{Subnqn: testNqn, Bdev: "Malloc0"}, 0},
+		{"too many namespaces", "mrvl_nvm_subsys_alloc_ns", models.MrvlNvmSubsysAllocNsParams{Subnqn: testNqn, Bdev: "Malloc1"}, models.StatusNoSpace},
+		{"attach namespace", "mrvl_nvm_ctrlr_attach_ns", models.MrvlNvmCtrlrAttachNsParams{Subnqn: testNqn, CtrlrID: 17, NsInstanceID: 1}, 0},
+		{"attach it again", "mrvl_nvm_ctrlr_attach_ns", models.MrvlNvmCtrlrAttachNsParams{Subnqn: testNqn, CtrlrID: 17, NsInstanceID: 1}, models.StatusAlreadyExists},
+		{"attach unknown namespace", "mrvl_nvm_ctrlr_attach_ns", models.MrvlNvmCtrlrAttachNsParams{Subnqn: testNqn, CtrlrID: 17, NsInstanceID: 2}, models.StatusNotFound},
+		{"unknown subsystem", "mrvl_nvm_subsys_get_ctrlr_list", models.MrvlNvmSubsysGetCtrlrListParams{Subnqn: "nqn.2022-09.io.spdk:unknown"}, models.StatusNotFound},
+	}
+
+	// run tests
+	for _, tt := range steps {
+		if status := call(t, e, tt.method, tt.params); status != tt.status {
+			t.Error(tt.name, "expected", tt.status, "received", status)
+		}
+	}
+
+	var info models.MrvlNvmGetNsInfoResult
+	if err := e.Call(context.Background(), "mrvl_nvm_ns_get_info", models.MrvlNvmGetNsInfoParams{SubNqn: testNqn, NsInstanceID: 1}, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Bdev != "Malloc0" || len(info.CtrlrIDList) != 1 || info.CtrlrIDList[0].CtrlrID != 17 {
+		t.Error("namespace info: expected Malloc0 attached to 17, received", info)
+	}
+	// the namespaces are detached from the controllers removed
+	if status := call(t, e, "mrvl_nvm_subsys_remove_ctrlr", models.MrvlNvmSubsysRemoveCtrlrParams{Subnqn: testNqn, CtrlrID: 17}); status != 0 {
+		t.Error("remove controller: expected 0, received", status)
+	}
+	var list models.MrvlNvmSubsysGetNsListResult
+	if err := e.Call(context.Background(), "mrvl_nvm_subsys_get_ns_list", models.MrvlNvmSubsysGetNsListParams{Subnqn: testNqn}, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.NsList) != 1 || len(list.NsList[0].CtrlrIDList) != 0 {
+		t.Error("namespace list: expected one detached namespace, received", list)
+	}
+	if status := call(t, e, "mrvl_nvm_delete_subsystem", models.MrvlNvmDeleteSubsystemParams{Subnqn: testNqn}); status != 0 {
+		t.Error("delete subsystem: expected 0, received", status)
+	}
+	var subsystems models.MrvlNvmGetSubsysListResult
+	if err := e.Call(context.Background(), "mrvl_nvm_get_subsys_list", nil, &subsystems); err != nil {
+		t.Fatal(err)
+	}
+	if len(subsystems.SubsysList) != 0 {
+		t.Error("subsystem list: expected none, received", subsystems.SubsysList)
+	}
+}
+
+func TestEmulator_Generic(t *testing.T) {
+	e := New()
+	var created models.MrvlBdevNullCreateResult
+	if err := e.Call(context.Background(), "mrvl_bdev_null_create", models.MrvlBdevNullCreateParams{Name: "null0"}, &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != 0 || created.UUID == "" {
+		t.Error("create: expected a UUID to be generated, received", created)
+	}
+	if status := call(t, e, "mrvl_bdev_null_create", models.MrvlBdevNullCreateParams{Name: "null0"}); status != models.StatusAlreadyExists {
+		t.Error("create again: expected", models.StatusAlreadyExists, "received", status)
+	}
+	if status := call(t, e, "mrvl_bdev_null_delete", models.MrvlBdevNullDeleteParams{Name: "null0"}); status != 0 {
+		t.Error("delete: expected 0, received", status)
+	}
+	if status := call(t, e, "mrvl_bdev_null_delete", models.MrvlBdevNullDeleteParams{Name: "null0"}); status != models.StatusNotFound {
+		t.Error("delete again: expected", models.StatusNotFound, "received", status)
+	}
+
+	// the SPDK methods answer a bool or a name
+	var deleted bool
+	if err := e.Call(context.Background(), "bdev_malloc_create", map[string]string{"name": "Malloc0"}, new(string)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Call(context.Background(), "bdev_malloc_delete", map[string]string{"name": "Malloc0"}, &deleted); err != nil || !deleted {
+		t.Error("delete: expected true, received", deleted, err)
+	}
+	if err := e.Call(context.Background(), "bdev_malloc_delete", map[string]string{"name": "Malloc0"}, &deleted); err == nil {
+		t.Error("delete again: expected an error")
+	}
+
+	err := e.Call(context.Background(), "framework_unknown", nil, &deleted)
+	if err == nil || !strings.Contains(err.Error(), "method not found") {
+		t.Error("unknown method: expected method not found, received", err)
+	}
+	var version spdk.GetVersionResult
+	if err := e.Call(context.Background(), "spdk_get_version", nil, &version); err != nil || version.Version != Version {
+		t.Error("version: expected", Version, "received", version.Version, err)
+	}
+	if e.GetID() != 9 {
+		t.Error("ID: expected 9 calls, received", e.GetID())
+	}
+}
@@ -0,0 +1,412 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package emulator emulates the JSON-RPC surface of the Marvell firmware in memory, so the whole
+// gRPC API of the bridge can be exercised without a DPU nor SPDK, i.e. by users trying it out and
+// in CI
+package emulator
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+// subsystem is an emulated NVMe subsystem
+type subsystem struct {
+	params      models.MrvlNvmCreateSubsystemParams
+	controllers map[int]*models.MrvlNvmSubsysCreateCtrlrParams
+	namespaces  map[int]*namespace
+}
+
+// namespace is an emulated NVMe namespace, with the controllers it is attached to
+type namespace struct {
+	params      models.MrvlNvmSubsysAllocNsParams
+	controllers map[int]bool
+}
+
+// status is the result of the methods answering only a status
+type status struct {
+	Status models.Status `json:"status"`
+}
+
+// ctrlrID is an item of the controller ID lists
+type ctrlrID struct {
+	CtrlrID int `json:"ctrlr_id"`
+}
+
+// nsItem is an item of the namespace lists
+type nsItem struct {
+	NsInstanceID int       `json:"ns_instance_id"`
+	Bdev         string    `json:"bdev"`
+	CtrlrIDList  []ctrlrID `json:"ctrlr_id_list"`
+}
+
+// sortedKeys returns the keys of a map in increasing order
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// ctrlrIDList returns the IDs of the controllers a namespace is attached to
+func (n *namespace) ctrlrIDList() []ctrlrID {
+	list := []ctrlrID{}
+	for _, id := range sortedKeys(n.controllers) {
+		list = append(list, ctrlrID{CtrlrID: id})
+	}
+	return list
+}
+
+// nsList returns the namespaces of a subsystem
+func (s *subsystem) nsList() []nsItem {
+	list := []nsItem{}
+	for _, id := range sortedKeys(s.namespaces) {
+		list = append(list, nsItem{NsInstanceID: id, Bdev: s.namespaces[id].params.Bdev, CtrlrIDList: s.namespaces[id].ctrlrIDList()})
+	}
+	return list
+}
+
+// lookup decodes the parameters of a method of a subsystem and returns the subsystem, nil when
+// there is none of the NQN of the parameters
+func (e *Emulator) lookup(params json.RawMessage, v interface{}, nqn func() string) (*subsystem, error) {
+	if err := json.Unmarshal(params, v); err != nil {
+		return nil, err
+	}
+	return e.subsystems[nqn()], nil
+}
+
+// createSubsystem handles mrvl_nvm_create_subsystem
+func (e *Emulator) createSubsystem(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmCreateSubsystemParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.Subnqn == "" || p.MinCtrlrID > p.MaxCtrlrID:
+		return status{models.StatusInvalid}, nil
+	case e.subsystems[p.Subnqn] != nil:
+		return status{models.StatusAlreadyExists}, nil
+	}
+	e.subsystems[p.Subnqn] = &subsystem{
+		params:      p,
+		controllers: make(map[int]*models.MrvlNvmSubsysCreateCtrlrParams),
+		namespaces:  make(map[int]*namespace),
+	}
+	return status{}, nil
+}
+
+// deleteSubsystem handles mrvl_nvm_delete_subsystem
+func (e *Emulator) deleteSubsystem(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmDeleteSubsystemParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	// the controllers and the namespaces go with the subsystem
+	delete(e.subsystems, p.Subnqn)
+	return status{}, nil
+}
+
+// getSubsystemList handles mrvl_nvm_get_subsys_list
+func (e *Emulator) getSubsystemList(_ json.RawMessage) (interface{}, error) {
+	type item struct {
+		Subnqn string `json:"subnqn"`
+	}
+	list := []item{}
+	for nqn := range e.subsystems {
+		list = append(list, item{Subnqn: nqn})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Subnqn < list[j].Subnqn })
+	return map[string]interface{}{"status": 0, "subsys_list": list}, nil
+}
+
+// getSubsystemInfo handles mrvl_nvm_subsys_get_info
+func (e *Emulator) getSubsystemInfo(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmGetSubsysInfoParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	info := map[string]interface{}{
+		"subnqn":           subsys.params.Subnqn,
+		"mn":               subsys.params.Mn,
+		"sn":               subsys.params.Sn,
+		"max_namespaces":   subsys.params.MaxNamespaces,
+		"min_ctrlr_id":     subsys.params.MinCtrlrID,
+		"max_ctrlr_id":     subsys.params.MaxCtrlrID,
+		"num_ns":           len(subsys.namespaces),
+		"num_total_ctrlr":  len(subsys.controllers),
+		"num_active_ctrlr": len(subsys.controllers),
+		"ns_list":          subsys.nsList(),
+	}
+	return map[string]interface{}{"status": 0, "subsys_list": []interface{}{info}}, nil
+}
+
+// createController handles mrvl_nvm_subsys_create_ctrlr
+func (e *Emulator) createController(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmSubsysCreateCtrlrParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil:
+		return status{models.StatusNotFound}, nil
+	case p.CtrlrID < subsys.params.MinCtrlrID || p.CtrlrID > subsys.params.MaxCtrlrID:
+		return status{models.StatusInvalid}, nil
+	case subsys.controllers[p.CtrlrID] != nil:
+		return status{models.StatusAlreadyExists}, nil
+	}
+	// a PCIe function backs one controller at most, whatever its subsystem
+	for _, other := range e.subsystems {
+		for _, ctrlr := range other.controllers {
+			if ctrlr.PcieDomainID == p.PcieDomainID && ctrlr.PfID == p.PfID && ctrlr.VfID == p.VfID {
+				return status{models.StatusBusy}, nil
+			}
+		}
+	}
+	subsys.controllers[p.CtrlrID] = &p
+	return models.MrvlNvmSubsysCreateCtrlrResult{CtrlrID: p.CtrlrID}, nil
+}
+
+// updateController handles mrvl_nvm_subsys_update_ctrlr
+func (e *Emulator) updateController(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmSubsysUpdateCtrlrParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil || subsys.controllers[p.CtrlrID] == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	subsys.controllers[p.CtrlrID].MaxNsq = p.MaxNsq
+	subsys.controllers[p.CtrlrID].MaxNcq = p.MaxNcq
+	return status{}, nil
+}
+
+// removeController handles mrvl_nvm_subsys_remove_ctrlr
+func (e *Emulator) removeController(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmSubsysRemoveCtrlrParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil || subsys.controllers[p.CtrlrID] == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	delete(subsys.controllers, p.CtrlrID)
+	for _, ns := range subsys.namespaces {
+		delete(ns.controllers, p.CtrlrID)
+	}
+	return status{}, nil
+}
+
+// getControllerList handles mrvl_nvm_subsys_get_ctrlr_list
+func (e *Emulator) getControllerList(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmSubsysGetCtrlrListParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	list := []ctrlrID{}
+	for _, id := range sortedKeys(subsys.controllers) {
+		list = append(list, ctrlrID{CtrlrID: id})
+	}
+	return map[string]interface{}{"status": 0, "ctrlr_id_list": list}, nil
+}
+
+// getControllerInfo handles mrvl_nvm_ctrlr_get_info
+func (e *Emulator) getControllerInfo(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmGetCtrlrInfoParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil || subsys.controllers[p.CtrlrID] == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	ctrlr := subsys.controllers[p.CtrlrID]
+	active := 0
+	for _, ns := range subsys.namespaces {
+		if ns.controllers[p.CtrlrID] {
+			active++
+		}
+	}
+	return models.MrvlNvmGetCtrlrInfoResult{
+		PcieDomainID:  ctrlr.PcieDomainID,
+		PfID:          ctrlr.PfID,
+		VfID:          ctrlr.VfID,
+		CtrlrID:       ctrlr.CtrlrID,
+		MaxNsq:        ctrlr.MaxNsq,
+		MaxNcq:        ctrlr.MaxNcq,
+		Mqes:          ctrlr.Mqes,
+		Nn:            subsys.params.MaxNamespaces,
+		ActiveNsCount: active,
+		CmbSizeMib:    ctrlr.CmbSizeMib,
+		PmrSizeMib:    ctrlr.PmrSizeMib,
+		MsixVectors:   ctrlr.MsixVectors,
+		AggrThreshold: ctrlr.AggrThreshold,
+		AggrTime:      ctrlr.AggrTime,
+		AmsWrr:        ctrlr.AmsWrr,
+		ArbBurst:      ctrlr.ArbBurst,
+		ArbHpw:        ctrlr.ArbHpw,
+		ArbMpw:        ctrlr.ArbMpw,
+		ArbLpw:        ctrlr.ArbLpw,
+		BootNsid:      ctrlr.BootNsid,
+		KatoMs:        ctrlr.KatoMs,
+	}, nil
+}
+
+// allocateNamespace handles mrvl_nvm_subsys_alloc_ns
+func (e *Emulator) allocateNamespace(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmSubsysAllocNsParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil:
+		return status{models.StatusNotFound}, nil
+	case p.Bdev == "":
+		return status{models.StatusInvalid}, nil
+	case subsys.params.MaxNamespaces != 0 && len(subsys.namespaces) >= subsys.params.MaxNamespaces:
+		return status{models.StatusNoSpace}, nil
+	}
+	// the namespaces get the lowest free instance ID, like they do in the firmware
+	id := 1
+	for subsys.namespaces[id] != nil {
+		id++
+	}
+	subsys.namespaces[id] = &namespace{params: p, controllers: make(map[int]bool)}
+	return models.MrvlNvmSubsysAllocNsResult{NsInstanceID: id}, nil
+}
+
+// unallocateNamespace handles mrvl_nvm_subsys_unalloc_ns
+func (e *Emulator) unallocateNamespace(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmSubsysUnallocNsParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil || subsys.namespaces[p.NsInstanceID] == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	delete(subsys.namespaces, p.NsInstanceID)
+	return status{}, nil
+}
+
+// getNamespaceList handles mrvl_nvm_subsys_get_ns_list
+func (e *Emulator) getNamespaceList(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmSubsysGetNsListParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	return map[string]interface{}{"status": 0, "ns_list": subsys.nsList()}, nil
+}
+
+// getNamespaceInfo handles mrvl_nvm_ns_get_info
+func (e *Emulator) getNamespaceInfo(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmGetNsInfoParams
+	subsys, err := e.lookup(params, &p, func() string { return p.SubNqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil || subsys.namespaces[p.NsInstanceID] == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	ns := subsys.namespaces[p.NsInstanceID]
+	return map[string]interface{}{
+		"status":        0,
+		"nguid":         ns.params.Nguid,
+		"eui64":         ns.params.Eui64,
+		"uuid":          ns.params.UUID,
+		"nmic":          ns.params.ShareEnable,
+		"bdev":          ns.params.Bdev,
+		"num_ctrlrs":    len(ns.controllers),
+		"ctrlr_id_list": ns.ctrlrIDList(),
+	}, nil
+}
+
+// attachment decodes the parameters of an attachment of a namespace to a controller and returns
+// the namespace, nil when there is none or no such controller
+func (e *Emulator) attachment(params json.RawMessage) (*namespace, int, error) {
+	var p models.MrvlNvmCtrlrAttachNsParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	if err != nil || subsys == nil || subsys.controllers[p.CtrlrID] == nil {
+		return nil, 0, err
+	}
+	return subsys.namespaces[p.NsInstanceID], p.CtrlrID, nil
+}
+
+// attachNamespace handles mrvl_nvm_ctrlr_attach_ns
+func (e *Emulator) attachNamespace(params json.RawMessage) (interface{}, error) {
+	ns, id, err := e.attachment(params)
+	switch {
+	case err != nil:
+		return nil, err
+	case ns == nil:
+		return status{models.StatusNotFound}, nil
+	case ns.controllers[id]:
+		return status{models.StatusAlreadyExists}, nil
+	case ns.params.ShareEnable == 0 && len(ns.controllers) > 0:
+		// a private namespace is attached to one controller at most
+		return status{models.StatusBusy}, nil
+	}
+	ns.controllers[id] = true
+	return status{}, nil
+}
+
+// detachNamespace handles mrvl_nvm_ctrlr_detach_ns
+func (e *Emulator) detachNamespace(params json.RawMessage) (interface{}, error) {
+	ns, id, err := e.attachment(params)
+	switch {
+	case err != nil:
+		return nil, err
+	case ns == nil || !ns.controllers[id]:
+		return status{models.StatusNotFound}, nil
+	}
+	delete(ns.controllers, id)
+	return status{}, nil
+}
+
+// getControllerStats answers no IO, nothing does any on the emulated controllers
+func (e *Emulator) getControllerStats(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmGetCtrlrStatsParams
+	subsys, err := e.lookup(params, &p, func() string { return p.Subnqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil || subsys.controllers[p.CtrlrID] == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	return models.MrvlNvmGetCtrlrStatsResult{}, nil
+}
+
+// getNamespaceStats answers no IO, nothing does any on the emulated namespaces
+func (e *Emulator) getNamespaceStats(params json.RawMessage) (interface{}, error) {
+	var p models.MrvlNvmGetNsStatsParams
+	subsys, err := e.lookup(params, &p, func() string { return p.SubNqn })
+	switch {
+	case err != nil:
+		return nil, err
+	case subsys == nil || subsys.namespaces[p.NsInstanceID] == nil:
+		return status{models.StatusNotFound}, nil
+	}
+	return models.MrvlNvmGetNsStatsResult{}, nil
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package etag tags the resources returned by the bridge with a hash of their fields and checks
+// the tag given by the clients updating or deleting them, so a change made since they read the
+// resource isn't lost
+package etag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the gRPC header carrying the etag of the resource of a response
+const MetadataKey = "etag"
+
+// HeaderKey is the HTTP header carrying the etag of the resource of a response
+const HeaderKey = "Etag"
+
+// IfMatchMetadataKey is the gRPC metadata carrying the etag the resource updated or deleted has
+// to have
+const IfMatchMetadataKey = "if-match"
+
+// IfMatchHeaderKey is the HTTP header carrying the etag the resource updated or deleted has to
+// have
+const IfMatchHeaderKey = "If-Match"
+
+// anyTag matches the etag of any existing resource
+const anyTag = "*"
+
+// Compute returns the etag of a resource, quoted as in the HTTP headers
+func Compute(resource interface{}) (string, error) {
+	content, err := json.Marshal(resource)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// Match tells whether the etag of a resource matches the one given by a client, quoted or not
+func Match(tag string, given string) bool {
+	given = strings.TrimSpace(given)
+	return given == anyTag || strings.Trim(given, `"`) == strings.Trim(tag, `"`)
+}
+
+// UnaryServerInterceptor sends the etag of the resources returned by the Get, Create and Update
+// methods in the headers, and refuses the Update and Delete calls whose If-Match doesn't match
+// the etag of the current resource, as returned by the Get method of the service
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method := path.Base(info.FullMethod)
+		if given := ifMatch(ctx); given != "" && (strings.HasPrefix(method, "Update") || strings.HasPrefix(method, "Delete")) {
+			if err := check(ctx, info.Server, method, req, given); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		if _, ok := resp.(interface{ GetName() string }); ok && (strings.HasPrefix(method, "Get") || strings.HasPrefix(method, "Create") || strings.HasPrefix(method, "Update")) {
+			if tag, err := Compute(resp); err == nil {
+				_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, tag))
+			}
+		}
+		return resp, nil
+	}
+}
+
+// ifMatch returns the etag given by the client, empty when none is
+func ifMatch(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(IfMatchMetadataKey); len(values) != 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// check fetches the resource of an update or delete request and compares its etag with the given
+// one
+func check(ctx context.Context, server interface{}, method string, req interface{}, given string) error {
+	kind := strings.TrimPrefix(strings.TrimPrefix(method, "Update"), "Delete")
+	name := resourceName(req)
+	get := reflect.ValueOf(server).MethodByName("Get" + kind)
+	if name == "" || !get.IsValid() || get.Type().NumIn() != 2 || get.Type().NumOut() != 2 || get.Type().In(1).Kind() != reflect.Pointer {
+		msg := fmt.Sprintf("%s can't be called with %s", method, IfMatchHeaderKey)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	in := reflect.New(get.Type().In(1).Elem())
+	field := in.Elem().FieldByName("Name")
+	if !field.IsValid() || field.Kind() != reflect.String {
+		msg := fmt.Sprintf("%s can't be called with %s", method, IfMatchHeaderKey)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	field.SetString(name)
+	out := get.Call([]reflect.Value{reflect.ValueOf(ctx), in})
+	if err, _ := out[1].Interface().(error); err != nil {
+		return err
+	}
+	tag, err := Compute(out[0].Interface())
+	if err != nil {
+		return err
+	}
+	if !Match(tag, given) {
+		msg := fmt.Sprintf("%s changed since it was read, its etag is %s and not %s", name, tag, given)
+		return status.Errorf(codes.Aborted, msg)
+	}
+	return nil
+}
+
+// resourceName returns the name of the resource of a request, i.e. of the resource of an
+// UpdateNvmeControllerRequest
+func resourceName(req interface{}) string {
+	if r, ok := req.(interface{ GetName() string }); ok && r.GetName() != "" {
+		return r.GetName()
+	}
+	v := reflect.Indirect(reflect.ValueOf(req))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if r, ok := v.Field(i).Interface().(interface{ GetName() string }); ok && r.GetName() != "" {
+			return r.GetName()
+		}
+	}
+	return ""
+}
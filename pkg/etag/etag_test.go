@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package etag tags the resources returned by the bridge with a hash of their fields and checks
+// the tag given by the clients updating or deleting them, so a change made since they read the
+// resource isn't lost
+package etag
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+// testServer gets a single subsystem
+type testServer struct {
+	subsystem *pb.NvmeSubsystem
+}
+
+func (s *testServer) GetNvmeSubsystem(_ context.Context, in *pb.GetNvmeSubsystemRequest) (*pb.NvmeSubsystem, error) {
+	if in.Name != s.subsystem.Name {
+		return nil, status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+	}
+	return s.subsystem, nil
+}
+
+// testStream records the headers set by the interceptor
+type testStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *testStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestEtag_Compute(t *testing.T) {
+	subsystem := &pb.NvmeSubsystem{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi0"}}
+	tag, err := Compute(subsystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag) != 34 || tag[0] != '"' || tag[33] != '"' {
+		t.Error("etag: expected 32 quoted hex digits, received", tag)
+	}
+	if again, _ := Compute(subsystem); again != tag {
+		t.Error("etag: expected", tag, "received", again)
+	}
+	changed, _ := Compute(&pb.NvmeSubsystem{Name: subsystem.Name, Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi1"}})
+	if changed == tag {
+		t.Error("etag: expected a change with the spec, received", changed)
+	}
+	for given, expected := range map[string]bool{tag: true, tag[1:33]: true, " * ": true, `"0"`: false, "": false} {
+		if Match(tag, given) != expected {
+			t.Error("match", given, "expected", expected, "received", !expected)
+		}
+	}
+}
+
+func TestEtag_UnaryServerInterceptor(t *testing.T) {
+	server := &testServer{subsystem: &pb.NvmeSubsystem{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi0"}}}
+	tag, _ := Compute(server.subsystem)
+	tests := map[string]struct {
+		method  string
+		in      interface{}
+		out     interface{}
+		ifMatch string
+		called  bool
+		header  string
+		errCode codes.Code
+	}{
+		"get": {
+			method: "GetNvmeSubsystem",
+			in:     &pb.GetNvmeSubsystemRequest{Name: server.subsystem.Name},
+			out:    server.subsystem,
+			called: true,
+			header: tag,
+		},
+		"update with the etag": {
+			method:  "UpdateNvmeSubsystem",
+			in:      &pb.UpdateNvmeSubsystemRequest{NvmeSubsystem: server.subsystem},
+			out:     server.subsystem,
+			ifMatch: tag,
+			called:  true,
+			header:  tag,
+		},
+		"update with a stale etag": {
+			method:  "UpdateNvmeSubsystem",
+			in:      &pb.UpdateNvmeSubsystemRequest{NvmeSubsystem: server.subsystem},
+			ifMatch: `"0123"`,
+			errCode: codes.Aborted,
+		},
+		"delete with any etag": {
+			method:  "DeleteNvmeSubsystem",
+			in:      &pb.DeleteNvmeSubsystemRequest{Name: server.subsystem.Name},
+			out:     &emptypb.Empty{},
+			ifMatch: "*",
+			called:  true,
+		},
+		"delete of a missing resource": {
+			method:  "DeleteNvmeSubsystem",
+			in:      &pb.DeleteNvmeSubsystemRequest{Name: "//storage.opiproject.org/nvmeSubsystems/unknown"},
+			ifMatch: "*",
+			errCode: codes.NotFound,
+		},
+		"delete without get": {
+			method:  "DeleteNvmeController",
+			in:      &pb.DeleteNvmeControllerRequest{Name: server.subsystem.Name + "/nvmeControllers/ctrl0"},
+			ifMatch: tag,
+			errCode: codes.InvalidArgument,
+		},
+		"delete without etag": {
+			method: "DeleteNvmeController",
+			in:     &pb.DeleteNvmeControllerRequest{Name: server.subsystem.Name + "/nvmeControllers/ctrl0"},
+			out:    &emptypb.Empty{},
+			called: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			stream := &testStream{}
+			ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+			if tt.ifMatch != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(IfMatchMetadataKey, tt.ifMatch))
+			}
+			called := false
+			handler := func(_ context.Context, _ interface{}) (interface{}, error) {
+				called = true
+				return tt.out, nil
+			}
+			info := &grpc.UnaryServerInfo{Server: server, FullMethod: "/opi_api.storage.v1.FrontendNvmeService/" + tt.method}
+			_, err := UnaryServerInterceptor()(ctx, tt.in, info, handler)
+			if status.Code(err) != tt.errCode {
+				t.Error("error: expected", tt.errCode, "received", err)
+			}
+			if called != tt.called {
+				t.Error("called: expected", tt.called, "received", called)
+			}
+			if header := stream.header.Get(MetadataKey); (tt.header == "" && len(header) != 0) || (tt.header != "" && (len(header) != 1 || header[0] != tt.header)) {
+				t.Error("header: expected", tt.header, "received", header)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package events keeps the recent lifecycle events of each resource in memory, so what happened
+// to a resource can be listed without searching the logs
+package events
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/audit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/pagination"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxEventsPerResource is the number of events kept per resource, the oldest are dropped
+const maxEventsPerResource = 50
+
+// watchBuffer is the number of events kept for a watcher not reading them fast enough, it is
+// dropped once they are more
+const watchBuffer = 100
+
+// maxResources is the number of resources whose events are kept, the events of the resource
+// without event for the longest time are dropped
+const maxResources = 10000
+
+// Types of the events
+const (
+	// TypeCreated is raised when a resource is created
+	TypeCreated = "CREATED"
+	// TypeUpdated is raised when a resource is updated, paused, resumed or any other change
+	TypeUpdated = "UPDATED"
+	// TypeDeleted is raised when a resource is deleted
+	TypeDeleted = "DELETED"
+	// TypeFirmwareError is raised when a call on a resource failed in the firmware
+	TypeFirmwareError = "FIRMWARE_ERROR"
+	// TypePathDown is raised when an Nvme path disconnects or fails
+	TypePathDown = "PATH_DOWN"
+	// TypePathUp is raised when an Nvme path connects again
+	TypePathUp = "PATH_UP"
+	// TypeReconciled is raised when the resource was reconciled with its desired state, i.e.
+	// the remote controllers of a discovery service with a new log page
+	TypeReconciled = "RECONCILED"
+)
+
+// Event represents something which happened to a resource
+type Event struct {
+	// Time the event happened, UTC
+	Time time.Time `json:"time"`
+	// Name of the resource
+	Name string `json:"name"`
+	// Type of the event, i.e. CREATED
+	Type string `json:"type"`
+	// Method is the method which raised the event, empty for the events detected by the bridge
+	Method string `json:"method,omitempty"`
+	// RequestID is the ID of the request which raised the event, as in the logs
+	RequestID string `json:"requestId,omitempty"`
+	// Message describes the event
+	Message string `json:"message,omitempty"`
+}
+
+// ListEventsRequest represents a request to list the events, oldest first
+type ListEventsRequest struct {
+	// Name only lists the events of a resource and of its children when set, i.e. a remote
+	// controller and its paths
+	Name string `json:"name"`
+	// PageSize is the maximum number of events returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListEventsResponse represents a list of events
+type ListEventsResponse struct {
+	// Events is the page of events
+	Events []*Event `json:"events"`
+	// NextPageToken is set when more events are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// History keeps the last events of the resources
+type History struct {
+	mu         sync.Mutex
+	resources  map[string]*list.Element
+	lru        *list.List
+	Pagination *pagination.Paginator
+	// publish is given the events as they happen when set
+	publish func(event *Event)
+	// watchers are given the events as they happen
+	watchers map[*watcher]struct{}
+}
+
+// watcher is a WatchEvents call, given the events of a resource and of its children
+type watcher struct {
+	name   string
+	events chan *Event
+}
+
+// resourceEvents are the events of a resource, the oldest first
+type resourceEvents struct {
+	name   string
+	events []*Event
+}
+
+// New creates an empty history
+func New() *History {
+	return &History{
+		resources:  make(map[string]*list.Element),
+		lru:        list.New(),
+		Pagination: pagination.New(),
+		watchers:   make(map[*watcher]struct{}),
+	}
+}
+
+// SetPublisher sets what is given the events as they happen, i.e. to publish them to a message
+// broker. The imported events are not given
+func (h *History) SetPublisher(publish func(event *Event)) {
+	h.publish = publish
+}
+
+// Record adds an event of type to a resource, detected by the bridge
+func (h *History) Record(name string, eventType string, message string) {
+	event := &Event{
+		Time:    time.Now().UTC(),
+		Name:    name,
+		Type:    eventType,
+		Message: message,
+	}
+	h.add(event)
+	h.notify(event)
+}
+
+// notify gives an event to the publisher, when set, and to the watchers
+func (h *History) notify(event *Event) {
+	if event.Name == "" {
+		return
+	}
+	if h.publish != nil {
+		e := *event
+		h.publish(&e)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.watchers {
+		if !matchesName(event.Name, w.name) {
+			continue
+		}
+		e := *event
+		select {
+		case w.events <- &e:
+		default:
+			// the watcher is too slow, it is dropped rather than blocking the calls
+			close(w.events)
+			delete(h.watchers, w)
+		}
+	}
+}
+
+// matchesName tells if the resource of an event is the resource name or one of its children,
+// an empty name matches all the resources
+func matchesName(resource string, name string) bool {
+	return name == "" || resource == name || strings.HasPrefix(resource, name+"/")
+}
+
+// add adds an event, dropping the oldest events of the resource or the events of the least
+// recently changed resource over the limits
+func (h *History) add(event *Event) {
+	if event.Name == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	element, ok := h.resources[event.Name]
+	if !ok {
+		element = h.lru.PushFront(&resourceEvents{name: event.Name})
+		h.resources[event.Name] = element
+		if h.lru.Len() > maxResources {
+			oldest := h.lru.Remove(h.lru.Back()).(*resourceEvents)
+			delete(h.resources, oldest.name)
+		}
+	}
+	h.lru.MoveToFront(element)
+	resource := element.Value.(*resourceEvents)
+	resource.events = append(resource.events, event)
+	if len(resource.events) > maxEventsPerResource {
+		resource.events = resource.events[len(resource.events)-maxEventsPerResource:]
+	}
+}
+
+// ListEvents lists the events of a resource and of its children, or of all the resources
+func (h *History) ListEvents(_ context.Context, in *ListEventsRequest) (*ListEventsResponse, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	size, offset, perr := h.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	Blobarray := []*Event{}
+	for name, element := range h.resources {
+		if !matchesName(name, in.Name) {
+			continue
+		}
+		for _, event := range element.Value.(*resourceEvents).events {
+			e := *event
+			Blobarray = append(Blobarray, &e)
+		}
+	}
+	sort.SliceStable(Blobarray, func(i, j int) bool {
+		if Blobarray[i].Time.Equal(Blobarray[j].Time) {
+			return Blobarray[i].Name < Blobarray[j].Name
+		}
+		return Blobarray[i].Time.Before(Blobarray[j].Time)
+	})
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(Blobarray), "offset", offset, "size", size)
+	Blobarray, hasMoreElements = utils.LimitPagination(Blobarray, offset, size)
+	if hasMoreElements {
+		token = h.Pagination.NewToken(offset + size)
+	}
+	return &ListEventsResponse{Events: Blobarray, NextPageToken: token}, nil
+}
+
+// WatchEventsRequest represents a request to watch the events as they happen
+type WatchEventsRequest struct {
+	// Name only watches the events of a resource and of its children when set
+	Name string `json:"name"`
+}
+
+// WatchEvents sends the events of a resource and of its children, or of all the resources, as
+// they happen until the caller goes away, i.e. for the dashboards. A caller not reading the
+// events fast enough is dropped with a RESOURCE_EXHAUSTED error
+func (h *History) WatchEvents(ctx context.Context, in *WatchEventsRequest, send func(*Event) error) error {
+	w := &watcher{name: in.Name, events: make(chan *Event, watchBuffer)}
+	h.mu.Lock()
+	h.watchers[w] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.watchers, w)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-w.events:
+			if !ok {
+				msg := fmt.Sprintf("More than %d events were not read, the watch is dropped", watchBuffer)
+				return status.Errorf(codes.ResourceExhausted, msg)
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Export returns the events of all the resources, oldest first, i.e. to hand them over to the
+// next bridge on upgrade
+func (h *History) Export() []*Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := []*Event{}
+	for _, element := range h.resources {
+		for _, event := range element.Value.(*resourceEvents).events {
+			e := *event
+			events = append(events, &e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
+
+// Import adds events exported by another history, oldest first, the limits apply
+func (h *History) Import(events []*Event) {
+	for _, event := range events {
+		e := *event
+		h.add(&e)
+	}
+}
+
+// firmwareCallsKey is the context key of the failed firmware calls of a request
+type firmwareCallsKey struct{}
+
+// failedCalls collects the firmware calls of a request which failed
+type failedCalls struct {
+	mu      sync.Mutex
+	methods []string
+}
+
+// Observe runs a call of method on a resource, its parent for the creations, and records its
+// lifecycle event. Read only calls only raise an event when they failed in the firmware
+func (h *History) Observe(ctx context.Context, method string, resource string, call func(context.Context) (interface{}, error)) (interface{}, error) {
+	failed := new(failedCalls)
+	resp, err := call(context.WithValue(ctx, firmwareCallsKey{}, failed))
+	event := &Event{
+		Time:      time.Now().UTC(),
+		Name:      resource,
+		Method:    method,
+		RequestID: requestid.FromContext(ctx),
+	}
+	name := method[strings.LastIndex(method, "/")+1:]
+	switch {
+	case err != nil:
+		failed.mu.Lock()
+		methods := failed.methods
+		failed.mu.Unlock()
+		if len(methods) == 0 {
+			// the call was refused before reaching the firmware
+			return resp, err
+		}
+		event.Type = TypeFirmwareError
+		event.Message = fmt.Sprintf("%s failed: %s", strings.Join(methods, ", "), status.Convert(err).Message())
+	case !audit.IsMutating(method):
+		return resp, err
+	case strings.HasPrefix(name, "Create"):
+		event.Type = TypeCreated
+		if r, ok := resp.(interface{ GetName() string }); ok && r.GetName() != "" {
+			event.Name = r.GetName()
+		}
+	case strings.HasPrefix(name, "Delete"):
+		event.Type = TypeDeleted
+	default:
+		event.Type = TypeUpdated
+		if !strings.HasPrefix(name, "Update") {
+			event.Message = name
+		}
+	}
+	h.add(event)
+	h.notify(event)
+	return resp, err
+}
+
+// UnaryServerInterceptor records the lifecycle events of the gRPC calls, it comes after the
+// tenant one so the events have the names of the resources in the store
+func (h *History) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return h.Observe(ctx, strings.TrimPrefix(info.FullMethod, "/"), resourceName(req), func(ctx context.Context) (interface{}, error) {
+			return handler(ctx, req)
+		})
+	}
+}
+
+// resourceName returns the name of the resource of a request, its parent for the creations,
+// the name of the updated resource for the updates
+func resourceName(req interface{}) string {
+	if r, ok := req.(interface{ GetName() string }); ok && r.GetName() != "" {
+		return r.GetName()
+	}
+	if r, ok := req.(interface{ GetParent() string }); ok && r.GetParent() != "" {
+		return r.GetParent()
+	}
+	// i.e. UpdateNvmeControllerRequest
+	v := reflect.Indirect(reflect.ValueOf(req))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if r, ok := v.Field(i).Interface().(interface{ GetName() string }); ok && r.GetName() != "" {
+			return r.GetName()
+		}
+	}
+	return ""
+}
+
+// WrapJSONRPC tells the observed calls which of their calls to the firmware failed, either
+// without answer or with a non zero status
+func (h *History) WrapJSONRPC(rpc spdk.JSONRPC) spdk.JSONRPC {
+	return &observedJSONRPC{JSONRPC: rpc}
+}
+
+// observedJSONRPC is a JSON-RPC client noting the failed calls to the firmware
+type observedJSONRPC struct {
+	spdk.JSONRPC
+}
+
+// Call implements spdk.JSONRPC
+func (c *observedJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	failed, ok := ctx.Value(firmwareCallsKey{}).(*failedCalls)
+	if !ok || (err == nil && resultStatus(result) == 0) {
+		return err
+	}
+	failed.mu.Lock()
+	defer failed.mu.Unlock()
+	failed.methods = append(failed.methods, method)
+	return err
+}
+
+// resultStatus returns the status of the result of a Marvell method, 0 when it has none
+func resultStatus(result interface{}) int64 {
+	v := reflect.Indirect(reflect.ValueOf(result))
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+	field := v.FieldByName("Status")
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int()
+	default:
+		return 0
+	}
+}
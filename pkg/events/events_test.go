@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package events keeps the recent lifecycle events of each resource in memory, so what happened
+// to a resource can be listed without searching the logs
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/spdk/spdktest"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testControllerName = "//storage.opiproject.org/subsystems/subsystem-test/controllers/controller-test"
+
+func TestEvents_UnaryServerInterceptor(t *testing.T) {
+	tests := map[string]struct {
+		method  string
+		req     interface{}
+		resp    interface{}
+		rpc     *spdktest.JSONRPC
+		err     error
+		events  []string
+		message string
+	}{
+		"created": {
+			method: "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeController",
+			req:    &pb.CreateNvmeControllerRequest{Parent: "//storage.opiproject.org/subsystems/subsystem-test"},
+			resp:   &pb.NvmeController{Name: testControllerName},
+			rpc:    &spdktest.JSONRPC{},
+			events: []string{testControllerName + " CREATED"},
+		},
+		"updated": {
+			method: "/opi_api.storage.v1.FrontendNvmeService/UpdateNvmeController",
+			req:    &pb.UpdateNvmeControllerRequest{NvmeController: &pb.NvmeController{Name: testControllerName}},
+			resp:   &pb.NvmeController{Name: testControllerName},
+			rpc:    &spdktest.JSONRPC{},
+			events: []string{testControllerName + " UPDATED"},
+		},
+		"deleted": {
+			method: "/opi_api.storage.v1.FrontendNvmeService/DeleteNvmeController",
+			req:    &pb.DeleteNvmeControllerRequest{Name: testControllerName},
+			rpc:    &spdktest.JSONRPC{},
+			events: []string{testControllerName + " DELETED"},
+		},
+		"read only": {
+			method: "/opi_api.storage.v1.FrontendNvmeService/StatsNvmeController",
+			req:    &pb.StatsNvmeControllerRequest{Name: testControllerName},
+			rpc:    &spdktest.JSONRPC{},
+			events: []string{},
+		},
+		"firmware status": {
+			method:  "/opi_api.storage.v1.FrontendNvmeService/StatsNvmeController",
+			req:     &pb.StatsNvmeControllerRequest{Name: testControllerName},
+			rpc:     &spdktest.JSONRPC{Status: 1},
+			err:     status.Error(codes.InvalidArgument, "Could not stats CTRL"),
+			events:  []string{testControllerName + " FIRMWARE_ERROR"},
+			message: "mrvl_nvm_ctrlr_get_stats failed: Could not stats CTRL",
+		},
+		"firmware unreachable": {
+			method:  "/opi_api.storage.v1.FrontendNvmeService/DeleteNvmeController",
+			req:     &pb.DeleteNvmeControllerRequest{Name: testControllerName},
+			rpc:     &spdktest.JSONRPC{Err: errors.New("connection refused")},
+			err:     errors.New("connection refused"),
+			events:  []string{testControllerName + " FIRMWARE_ERROR"},
+			message: "mrvl_nvm_ctrlr_get_stats failed: connection refused",
+		},
+		"refused before the firmware": {
+			method: "/opi_api.storage.v1.FrontendNvmeService/DeleteNvmeController",
+			req:    &pb.DeleteNvmeControllerRequest{Name: testControllerName},
+			err:    status.Error(codes.NotFound, "unable to find key"),
+			events: []string{},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := New()
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if tt.rpc != nil {
+					_ = h.WrapJSONRPC(tt.rpc).Call(ctx, "mrvl_nvm_ctrlr_get_stats", nil, &spdktest.StatusResult{})
+				}
+				return tt.resp, tt.err
+			}
+			ctx := requestid.NewContext(context.Background(), "req-1")
+			info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+			_, _ = h.UnaryServerInterceptor()(ctx, tt.req, info, handler)
+
+			response, err := h.ListEvents(context.Background(), &ListEventsRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			events := []string{}
+			for _, event := range response.Events {
+				events = append(events, event.Name+" "+event.Type)
+				if event.Method != tt.method[1:] || event.RequestID != "req-1" || event.Message != tt.message {
+					t.Error("event: expected", tt.method, "req-1", tt.message, "received", event)
+				}
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Error("events: expected", tt.events, "received", events)
+			}
+		})
+	}
+}
+
+func TestEvents_ListEvents(t *testing.T) {
+	h := New()
+	remoteController := "//storage.opiproject.org/volumes/nvmetcp12"
+	h.Record(remoteController, TypeCreated, "")
+	h.Record(remoteController+"/nvmepaths/nvmetcp12path0", TypePathDown, "Path is FAILED, it was CONNECTED")
+	h.Record(remoteController+"0", TypeCreated, "")
+	for i := 0; i < maxEventsPerResource; i++ {
+		h.Record(testControllerName, TypeUpdated, fmt.Sprint(i))
+	}
+
+	tests := map[string]struct {
+		in     *ListEventsRequest
+		events int
+		token  bool
+	}{
+		"resource and its children": {
+			in:     &ListEventsRequest{Name: remoteController},
+			events: 2,
+		},
+		"oldest events dropped": {
+			in:     &ListEventsRequest{Name: testControllerName, PageSize: 100},
+			events: maxEventsPerResource,
+		},
+		"paged": {
+			in:     &ListEventsRequest{PageSize: 10},
+			events: 10,
+			token:  true,
+		},
+		"unknown resource": {
+			in:     &ListEventsRequest{Name: "//storage.opiproject.org/volumes/unknown"},
+			events: 0,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			response, err := h.ListEvents(context.Background(), tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Events) != tt.events {
+				t.Error("events: expected", tt.events, "received", len(response.Events))
+			}
+			if (response.NextPageToken != "") != tt.token {
+				t.Error("next page token: expected", tt.token, "received", response.NextPageToken)
+			}
+		})
+	}
+
+	// the events of the resource are listed oldest first, the oldest is dropped over the limit
+	response, _ := h.ListEvents(context.Background(), &ListEventsRequest{Name: testControllerName, PageSize: 1})
+	if response.Events[0].Message != "0" {
+		t.Error("oldest event: expected 0, received", response.Events[0].Message)
+	}
+	h.Record(testControllerName, TypeDeleted, "")
+	response, _ = h.ListEvents(context.Background(), &ListEventsRequest{Name: testControllerName, PageSize: 1})
+	if response.Events[0].Message != "1" {
+		t.Error("oldest event: expected 1, received", response.Events[0].Message)
+	}
+}
+
+func TestEvents_Export(t *testing.T) {
+	h := New()
+	h.Record(testControllerName, TypeCreated, "")
+	h.Record("//storage.opiproject.org/volumes/nvmetcp12", TypeCreated, "")
+	h.Record(testControllerName, TypeUpdated, "")
+
+	imported := New()
+	imported.Import(h.Export())
+	response, err := imported.ListEvents(context.Background(), &ListEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := h.ListEvents(context.Background(), &ListEventsRequest{})
+	if !reflect.DeepEqual(response.Events, expected.Events) {
+		t.Error("events: expected", expected.Events, "received", response.Events)
+	}
+}
+
+func TestEvents_SetPublisher(t *testing.T) {
+	h := New()
+	var published []*Event
+	h.SetPublisher(func(event *Event) { published = append(published, event) })
+	h.Record(testControllerName, TypePathDown, "Connection lost")
+	h.Import([]*Event{{Name: testControllerName, Type: TypeCreated}})
+
+	if len(published) != 1 || published[0].Name != testControllerName || published[0].Type != TypePathDown ||
+		published[0].Message != "Connection lost" {
+		t.Error("published: expected the path down event, received", published)
+	}
+}
+
+func TestEvents_WatchEvents(t *testing.T) {
+	h := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan *Event)
+	done := make(chan error)
+	go func() {
+		done <- h.WatchEvents(ctx, &WatchEventsRequest{Name: testControllerName}, func(event *Event) error {
+			received <- event
+			return nil
+		})
+	}()
+	waitWatchers(h, 1)
+
+	h.Record("//storage.opiproject.org/volumes/nvmetcp12", TypeCreated, "")
+	h.Record(testControllerName+"/nvmePaths/path0", TypePathDown, "Connection lost")
+	h.Record(testControllerName, TypeUpdated, "")
+	for _, expected := range []string{TypePathDown, TypeUpdated} {
+		if event := <-received; event.Type != expected {
+			t.Error("event: expected", expected, "received", event)
+		}
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Error("error: expected", context.Canceled, "received", err)
+	}
+	waitWatchers(h, 0)
+}
+
+func TestEvents_WatchEventsSlowWatcher(t *testing.T) {
+	h := New()
+	resume := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- h.WatchEvents(context.Background(), &WatchEventsRequest{}, func(*Event) error {
+			<-resume
+			return nil
+		})
+	}()
+	waitWatchers(h, 1)
+
+	// the calls are never blocked by the watcher
+	for i := 0; i < watchBuffer+2; i++ {
+		h.Record(testControllerName, TypeUpdated, "")
+	}
+	close(resume)
+	err := <-done
+	if er, _ := status.FromError(err); er.Code() != codes.ResourceExhausted {
+		t.Error("error code: expected", codes.ResourceExhausted, "received", er.Code())
+	}
+}
+
+// waitWatchers waits until count calls watch the events of h
+func waitWatchers(h *History, count int) {
+	for {
+		h.mu.Lock()
+		watching := len(h.watchers)
+		h.mu.Unlock()
+		if watching == count {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package export lists the resources of the bridge with the IDs they are created with, their
+// parent and their etag, along with the Terraform import blocks of them, so a provider built on
+// the API imports the existing resources without guessing their state
+package export
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/etag"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+// Frontend lists the Nvme subsystems, controllers and namespaces
+type Frontend interface {
+	ListNvmeSubsystems(context.Context, *pb.ListNvmeSubsystemsRequest) (*pb.ListNvmeSubsystemsResponse, error)
+	ListNvmeControllers(context.Context, *pb.ListNvmeControllersRequest) (*pb.ListNvmeControllersResponse, error)
+	ListNvmeNamespaces(context.Context, *pb.ListNvmeNamespacesRequest) (*pb.ListNvmeNamespacesResponse, error)
+}
+
+// Backend lists the remote controllers, their paths and the local volumes
+type Backend interface {
+	ListNvmeRemoteControllers(context.Context, *pb.ListNvmeRemoteControllersRequest) (*pb.ListNvmeRemoteControllersResponse, error)
+	ListNvmePaths(context.Context, *pb.ListNvmePathsRequest) (*pb.ListNvmePathsResponse, error)
+	ListNullVolumes(context.Context, *pb.ListNullVolumesRequest) (*pb.ListNullVolumesResponse, error)
+	ListMallocVolumes(context.Context, *pb.ListMallocVolumesRequest) (*pb.ListMallocVolumesResponse, error)
+	ListAioVolumes(context.Context, *pb.ListAioVolumesRequest) (*pb.ListAioVolumesResponse, error)
+}
+
+// Middleend lists the encrypted volumes
+type Middleend interface {
+	ListEncryptedVolumes(context.Context, *pb.ListEncryptedVolumesRequest) (*pb.ListEncryptedVolumesResponse, error)
+}
+
+// Resource represents a resource of the bridge as imported by Terraform
+type Resource struct {
+	// Type is the type of the Terraform resource, i.e. opi_nvme_subsystem
+	Type string `json:"type"`
+	// Label is the name of the resource in the Terraform configuration, unique per type
+	Label string `json:"label"`
+	// ID is the import ID of the resource, its full name
+	ID string `json:"id"`
+	// ResourceID is the ID the resource is created with, the last segment of its name
+	ResourceID string `json:"resourceId"`
+	// Parent is the name of the parent of the resource, empty for the ones which have none
+	Parent string `json:"parent,omitempty"`
+	// Etag is the etag of the resource, as in the headers of its Get
+	Etag string `json:"etag"`
+	// Resource is the resource as returned by its Get
+	Resource interface{} `json:"resource"`
+}
+
+// ExportResourcesRequest represents a request to export the resources
+type ExportResourcesRequest struct{}
+
+// ExportResourcesResponse represents the resources of the bridge, the parents before their
+// children
+type ExportResourcesResponse struct {
+	Resources []*Resource `json:"resources"`
+	// ImportBlocks are the Terraform import blocks of the resources
+	ImportBlocks string `json:"importBlocks"`
+}
+
+// Server exports the resources of the bridge
+type Server struct {
+	frontend  Frontend
+	backend   Backend
+	middleend Middleend
+}
+
+// NewServer returns a Server exporting the resources of frontend, backend and middleend
+func NewServer(frontend Frontend, backend Backend, middleend Middleend) *Server {
+	return &Server{frontend: frontend, backend: backend, middleend: middleend}
+}
+
+// invalidLabel matches the characters which can't be in a Terraform identifier
+var invalidLabel = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// tlsPskPrefix prefixes the names of the TLS PSKs
+const tlsPskPrefix = "//storage.opiproject.org/tlsPsks/"
+
+// named is a resource of the API
+type named interface {
+	GetName() string
+}
+
+// ExportResources lists all the resources of the bridge
+func (s *Server) ExportResources(ctx context.Context, _ *ExportResourcesRequest) (*ExportResourcesResponse, error) {
+	e := &exporter{labels: make(map[string]bool)}
+
+	subsystems, err := listAll(func(token string) ([]*pb.NvmeSubsystem, string, error) {
+		r, err := s.frontend.ListNvmeSubsystems(ctx, &pb.ListNvmeSubsystemsRequest{PageToken: token})
+		return r.GetNvmeSubsystems(), r.GetNextPageToken(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, subsystem := range subsystems {
+		if err := e.add("opi_nvme_subsystem", "", subsystem); err != nil {
+			return nil, err
+		}
+		controllers, err := listAll(func(token string) ([]*pb.NvmeController, string, error) {
+			r, err := s.frontend.ListNvmeControllers(ctx, &pb.ListNvmeControllersRequest{Parent: subsystem.Name, PageToken: token})
+			return r.GetNvmeControllers(), r.GetNextPageToken(), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := addAll(e, "opi_nvme_controller", subsystem.Name, controllers); err != nil {
+			return nil, err
+		}
+		namespaces, err := listAll(func(token string) ([]*pb.NvmeNamespace, string, error) {
+			r, err := s.frontend.ListNvmeNamespaces(ctx, &pb.ListNvmeNamespacesRequest{Parent: subsystem.Name, PageToken: token})
+			return r.GetNvmeNamespaces(), r.GetNextPageToken(), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := addAll(e, "opi_nvme_namespace", subsystem.Name, namespaces); err != nil {
+			return nil, err
+		}
+	}
+
+	controllers, err := listAll(func(token string) ([]*pb.NvmeRemoteController, string, error) {
+		r, err := s.backend.ListNvmeRemoteControllers(ctx, &pb.ListNvmeRemoteControllersRequest{PageToken: token})
+		return r.GetNvmeRemoteControllers(), r.GetNextPageToken(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, controller := range controllers {
+		// the raw keys aren't exported, only the references to the TLS PSKs are
+		exported := controller
+		if psk := controller.GetTcp().GetPsk(); len(psk) != 0 && !strings.HasPrefix(string(psk), tlsPskPrefix) {
+			exported = utils.ProtoClone(controller)
+			exported.Tcp.Psk = nil
+		}
+		if err := e.add("opi_nvme_remote_controller", "", exported); err != nil {
+			return nil, err
+		}
+		paths, err := listAll(func(token string) ([]*pb.NvmePath, string, error) {
+			r, err := s.backend.ListNvmePaths(ctx, &pb.ListNvmePathsRequest{Parent: controller.Name, PageToken: token})
+			return r.GetNvmePaths(), r.GetNextPageToken(), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := addAll(e, "opi_nvme_path", controller.Name, paths); err != nil {
+			return nil, err
+		}
+	}
+	nullVolumes, err := listAll(func(token string) ([]*pb.NullVolume, string, error) {
+		r, err := s.backend.ListNullVolumes(ctx, &pb.ListNullVolumesRequest{PageToken: token})
+		return r.GetNullVolumes(), r.GetNextPageToken(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := addAll(e, "opi_null_volume", "", nullVolumes); err != nil {
+		return nil, err
+	}
+	mallocVolumes, err := listAll(func(token string) ([]*pb.MallocVolume, string, error) {
+		r, err := s.backend.ListMallocVolumes(ctx, &pb.ListMallocVolumesRequest{PageToken: token})
+		return r.GetMallocVolumes(), r.GetNextPageToken(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := addAll(e, "opi_malloc_volume", "", mallocVolumes); err != nil {
+		return nil, err
+	}
+	aioVolumes, err := listAll(func(token string) ([]*pb.AioVolume, string, error) {
+		r, err := s.backend.ListAioVolumes(ctx, &pb.ListAioVolumesRequest{PageToken: token})
+		return r.GetAioVolumes(), r.GetNextPageToken(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := addAll(e, "opi_aio_volume", "", aioVolumes); err != nil {
+		return nil, err
+	}
+
+	encryptedVolumes, err := listAll(func(token string) ([]*pb.EncryptedVolume, string, error) {
+		r, err := s.middleend.ListEncryptedVolumes(ctx, &pb.ListEncryptedVolumesRequest{PageToken: token})
+		return r.GetEncryptedVolumes(), r.GetNextPageToken(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := addAll(e, "opi_encrypted_volume", "", encryptedVolumes); err != nil {
+		return nil, err
+	}
+
+	return &ExportResourcesResponse{Resources: e.resources, ImportBlocks: e.imports.String()}, nil
+}
+
+// exporter collects the exported resources, their labels being unique per type
+type exporter struct {
+	resources []*Resource
+	labels    map[string]bool
+	imports   strings.Builder
+}
+
+// add exports a resource
+func (e *exporter) add(resourceType string, parent string, resource named) error {
+	tag, err := etag.Compute(resource)
+	if err != nil {
+		return err
+	}
+	name := resource.GetName()
+	id := path.Base(name)
+	// the children are labeled after their parent, their IDs being unique in it only
+	label := id
+	if parent != "" {
+		label = path.Base(parent) + "_" + id
+	}
+	label = invalidLabel.ReplaceAllString(label, "_")
+	if label == "" || (label[0] >= '0' && label[0] <= '9') || label[0] == '-' {
+		label = "_" + label
+	}
+	base := label
+	for i := 2; e.labels[resourceType+"."+label]; i++ {
+		label = fmt.Sprintf("%s_%d", base, i)
+	}
+	e.labels[resourceType+"."+label] = true
+	e.resources = append(e.resources, &Resource{
+		Type:       resourceType,
+		Label:      label,
+		ID:         name,
+		ResourceID: id,
+		Parent:     parent,
+		Etag:       tag,
+		Resource:   resource,
+	})
+	fmt.Fprintf(&e.imports, "import {\n  to = %s.%s\n  id = %q\n}\n\n", resourceType, label, name)
+	return nil
+}
+
+// addAll exports resources of a type
+func addAll[R named](e *exporter, resourceType string, parent string, resources []R) error {
+	for _, resource := range resources {
+		if err := e.add(resourceType, parent, resource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listAll lists all the pages of a list method
+func listAll[R any](list func(token string) ([]R, string, error)) ([]R, error) {
+	var all []R
+	token := ""
+	for {
+		page, next, err := list(token)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" {
+			return all, nil
+		}
+		token = next
+	}
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package export lists the resources of the bridge with the IDs they are created with, their
+// parent and their etag, along with the Terraform import blocks of them, so a provider built on
+// the API imports the existing resources without guessing their state
+package export
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/etag"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+// testFrontend serves the subsystems subsys0 and subsys-1 one per page, with a controller and a
+// namespace in subsys0
+type testFrontend struct{}
+
+func (f *testFrontend) ListNvmeSubsystems(_ context.Context, in *pb.ListNvmeSubsystemsRequest) (*pb.ListNvmeSubsystemsResponse, error) {
+	if in.PageToken == "" {
+		return &pb.ListNvmeSubsystemsResponse{
+			NvmeSubsystems: []*pb.NvmeSubsystem{{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi0"}}},
+			NextPageToken:  "next",
+		}, nil
+	}
+	return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{{Name: "//storage.opiproject.org/nvmeSubsystems/subsys-1"}}}, nil
+}
+
+func (f *testFrontend) ListNvmeControllers(_ context.Context, in *pb.ListNvmeControllersRequest) (*pb.ListNvmeControllersResponse, error) {
+	if in.Parent != "//storage.opiproject.org/nvmeSubsystems/subsys0" {
+		return &pb.ListNvmeControllersResponse{}, nil
+	}
+	return &pb.ListNvmeControllersResponse{NvmeControllers: []*pb.NvmeController{{Name: in.Parent + "/nvmeControllers/ctrl0"}}}, nil
+}
+
+func (f *testFrontend) ListNvmeNamespaces(_ context.Context, in *pb.ListNvmeNamespacesRequest) (*pb.ListNvmeNamespacesResponse, error) {
+	if in.Parent != "//storage.opiproject.org/nvmeSubsystems/subsys0" {
+		return &pb.ListNvmeNamespacesResponse{}, nil
+	}
+	return &pb.ListNvmeNamespacesResponse{NvmeNamespaces: []*pb.NvmeNamespace{{Name: in.Parent + "/nvmeNamespaces/ns0"}}}, nil
+}
+
+// testBackend serves a remote controller with a raw key and a path, and a malloc volume
+type testBackend struct {
+	err error
+}
+
+func (b *testBackend) ListNvmeRemoteControllers(_ context.Context, _ *pb.ListNvmeRemoteControllersRequest) (*pb.ListNvmeRemoteControllersResponse, error) {
+	return &pb.ListNvmeRemoteControllersResponse{NvmeRemoteControllers: []*pb.NvmeRemoteController{
+		{Name: "//storage.opiproject.org/volumes/nvmetcp12", Tcp: &pb.TcpController{Psk: []byte("NVMeTLSkey-1:01:secret:")}},
+		{Name: "//storage.opiproject.org/volumes/nvmetcp13", Tcp: &pb.TcpController{Psk: []byte("//storage.opiproject.org/tlsPsks/psk0")}},
+	}}, nil
+}
+
+func (b *testBackend) ListNvmePaths(_ context.Context, in *pb.ListNvmePathsRequest) (*pb.ListNvmePathsResponse, error) {
+	return &pb.ListNvmePathsResponse{NvmePaths: []*pb.NvmePath{{Name: in.Parent + "/nvmePaths/path0"}}}, nil
+}
+
+func (b *testBackend) ListNullVolumes(_ context.Context, _ *pb.ListNullVolumesRequest) (*pb.ListNullVolumesResponse, error) {
+	return &pb.ListNullVolumesResponse{}, b.err
+}
+
+func (b *testBackend) ListMallocVolumes(_ context.Context, _ *pb.ListMallocVolumesRequest) (*pb.ListMallocVolumesResponse, error) {
+	return &pb.ListMallocVolumesResponse{MallocVolumes: []*pb.MallocVolume{{Name: "//storage.opiproject.org/volumes/Malloc0"}}}, nil
+}
+
+func (b *testBackend) ListAioVolumes(_ context.Context, _ *pb.ListAioVolumesRequest) (*pb.ListAioVolumesResponse, error) {
+	return &pb.ListAioVolumesResponse{}, nil
+}
+
+// testMiddleend serves no encrypted volume
+type testMiddleend struct{}
+
+func (m *testMiddleend) ListEncryptedVolumes(_ context.Context, _ *pb.ListEncryptedVolumesRequest) (*pb.ListEncryptedVolumesResponse, error) {
+	return &pb.ListEncryptedVolumesResponse{}, nil
+}
+
+func TestExport_ExportResources(t *testing.T) {
+	server := NewServer(&testFrontend{}, &testBackend{}, &testMiddleend{})
+	response, err := server.ExportResources(context.Background(), &ExportResourcesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		resourceType string
+		label        string
+		id           string
+		parent       string
+	}{
+		{"opi_nvme_subsystem", "subsys0", "//storage.opiproject.org/nvmeSubsystems/subsys0", ""},
+		{"opi_nvme_controller", "subsys0_ctrl0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0", "//storage.opiproject.org/nvmeSubsystems/subsys0"},
+		{"opi_nvme_namespace", "subsys0_ns0", "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeNamespaces/ns0", "//storage.opiproject.org/nvmeSubsystems/subsys0"},
+		{"opi_nvme_subsystem", "subsys-1", "//storage.opiproject.org/nvmeSubsystems/subsys-1", ""},
+		{"opi_nvme_remote_controller", "nvmetcp12", "//storage.opiproject.org/volumes/nvmetcp12", ""},
+		{"opi_nvme_path", "nvmetcp12_path0", "//storage.opiproject.org/volumes/nvmetcp12/nvmePaths/path0", "//storage.opiproject.org/volumes/nvmetcp12"},
+		{"opi_nvme_remote_controller", "nvmetcp13", "//storage.opiproject.org/volumes/nvmetcp13", ""},
+		{"opi_nvme_path", "nvmetcp13_path0", "//storage.opiproject.org/volumes/nvmetcp13/nvmePaths/path0", "//storage.opiproject.org/volumes/nvmetcp13"},
+		{"opi_malloc_volume", "Malloc0", "//storage.opiproject.org/volumes/Malloc0", ""},
+	}
+	if len(response.Resources) != len(expected) {
+		t.Fatal("resources: expected", len(expected), "received", len(response.Resources))
+	}
+	for i, resource := range response.Resources {
+		e := expected[i]
+		if resource.Type != e.resourceType || resource.Label != e.label || resource.ID != e.id || resource.Parent != e.parent {
+			t.Error("resource", i, "expected", e, "received", resource)
+		}
+		if tag, _ := etag.Compute(resource.Resource); resource.Etag != tag {
+			t.Error("resource", i, "etag: expected", tag, "received", resource.Etag)
+		}
+	}
+	if psk := response.Resources[4].Resource.(*pb.NvmeRemoteController).GetTcp().GetPsk(); psk != nil {
+		t.Error("psk: expected none, received", string(psk))
+	}
+	if psk := response.Resources[6].Resource.(*pb.NvmeRemoteController).GetTcp().GetPsk(); string(psk) != "//storage.opiproject.org/tlsPsks/psk0" {
+		t.Error("psk: expected the TLS PSK name, received", string(psk))
+	}
+	block := "import {\n  to = opi_nvme_controller.subsys0_ctrl0\n  id = \"//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0\"\n}\n"
+	if !strings.Contains(response.ImportBlocks, block) || strings.Count(response.ImportBlocks, "import {") != len(expected) {
+		t.Error("import blocks: expected", block, "received", response.ImportBlocks)
+	}
+
+	server = NewServer(&testFrontend{}, &testBackend{err: errors.New("spdk is down")}, &testMiddleend{})
+	if _, err := server.ExportResources(context.Background(), &ExportResourcesRequest{}); err == nil || err.Error() != "spdk is down" {
+		t.Error("error: expected spdk is down, received", err)
+	}
+}
+
+func TestExport_Labels(t *testing.T) {
+	tests := map[string]struct {
+		names  []string
+		labels []string
+	}{
+		"invalid characters": {
+			names:  []string{"//storage.opiproject.org/volumes/a.b:c"},
+			labels: []string{"a_b_c"},
+		},
+		"leading digit": {
+			names:  []string{"//storage.opiproject.org/volumes/0vol"},
+			labels: []string{"_0vol"},
+		},
+		"duplicates": {
+			names:  []string{"//storage.opiproject.org/volumes/a.b", "//storage.opiproject.org/volumes/a:b", "//storage.opiproject.org/volumes/a_b"},
+			labels: []string{"a_b", "a_b_2", "a_b_3"},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := &exporter{labels: make(map[string]bool)}
+			for _, name := range tt.names {
+				if err := e.add("opi_null_volume", "", &pb.NullVolume{Name: name}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, resource := range e.resources {
+				if resource.Label != tt.labels[i] {
+					t.Error("label: expected", tt.labels[i], "received", resource.Label)
+				}
+			}
+		})
+	}
+}
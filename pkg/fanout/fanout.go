@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package fanout makes the calls of the flows needing one call to the firmware per item
+// concurrently, with a bounded number of them in flight, so listing hundreds of controllers
+// doesn't take hundreds of round trips in a row
+package fanout
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultWorkers is the number of calls in flight when not set
+const DefaultWorkers = 16
+
+// Map calls fn on each item, with at most workers calls in flight, and returns their results in
+// the order of the items. The first error cancels the context of the calls in flight and the
+// items not called yet are skipped, it is returned once the calls in flight are done
+func Map[T, R any](ctx context.Context, workers int, items []T, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	results := make([]R, len(items))
+	if len(items) == 0 {
+		return results, nil
+	}
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	workers = min(workers, len(items))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	indexes := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result, err := fn(ctx, items[i])
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[i] = result
+			}
+		}()
+	}
+	for i := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, ctx.Err()
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package fanout makes the calls of the flows needing one call to the firmware per item
+// concurrently, with a bounded number of them in flight, so listing hundreds of controllers
+// doesn't take hundreds of round trips in a row
+package fanout
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanout_Map(t *testing.T) {
+	items := make([]int, 50)
+	for i := range items {
+		items[i] = i
+	}
+	var inFlight, maxInFlight atomic.Int32
+	results, err := Map(context.Background(), 4, items, func(_ context.Context, item int) (int, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := maxInFlight.Load()
+			if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		// the last items are done first
+		time.Sleep(time.Duration(50-item) * 20 * time.Microsecond)
+		return item * item, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result != i*i {
+			t.Error("result", i, ": expected", i*i, "received", result)
+		}
+	}
+	if maxInFlight.Load() > 4 || maxInFlight.Load() < 2 {
+		t.Error("in flight: expected at most 4 calls in parallel, received", maxInFlight.Load())
+	}
+
+	names, err := Map(context.Background(), 0, []string{}, func(_ context.Context, item string) (string, error) {
+		return item, nil
+	})
+	if err != nil || len(names) != 0 {
+		t.Error("empty: expected no result, received", names, err)
+	}
+}
+
+func TestFanout_MapError(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	failure := errors.New("mrvl_nvm_ctrlr_get_info: json response error: Invalid parameters")
+	var calls atomic.Int32
+	results, err := Map(context.Background(), 2, items, func(ctx context.Context, item int) (int, error) {
+		calls.Add(1)
+		if item == 3 {
+			return 0, failure
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+		return item, nil
+	})
+	if !errors.Is(err, failure) || results != nil {
+		t.Error("error: expected", failure, "received", results, err)
+	}
+	if calls.Load() > 10 {
+		t.Error("calls: expected the items after the error to be skipped, received", calls.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Map(ctx, 2, items, func(ctx context.Context, item int) (int, error) { return item, ctx.Err() }); !errors.Is(err, context.Canceled) {
+		t.Error("canceled: expected", context.Canceled, "received", err)
+	}
+}
@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package faults injects failures in the calls to the firmware, an error, a failed status, a
+// timeout or a malformed response, on every call of a method or on its Nth call, so the rollback
+// and retry logic of the bridge can be validated before hitting production hardware
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Kind is the failure a fault injects
+type Kind string
+
+const (
+	// KindError fails the call with an error, as when the firmware answers a JSON-RPC error
+	KindError Kind = "ERROR"
+	// KindStatus answers a failed status without calling the firmware
+	KindStatus Kind = "STATUS"
+	// KindTimeout fails the call once it timed out, without calling the firmware
+	KindTimeout Kind = "TIMEOUT"
+	// KindMalformed calls the firmware and fails the call as when its response can't be decoded,
+	// so the firmware did what was asked while the bridge doesn't know it
+	KindMalformed Kind = "MALFORMED"
+)
+
+// defaultTimeout is how long a call of a TIMEOUT fault waits when its context has no deadline
+const defaultTimeout = 30 * time.Second
+
+// Fault represents a failure injected in the calls of the methods matching a pattern
+type Fault struct {
+	// Name of the fault, assigned by the server
+	Name string `json:"name"`
+	// Method is the pattern of the methods of the fault, i.e. mrvl_nvm_subsys_* or
+	// mrvl_nvm_ctrlr_attach_ns
+	Method string `json:"method"`
+	// Kind of failure
+	Kind Kind `json:"kind"`
+	// Status answered by the STATUS faults, -1 when 0
+	Status int32 `json:"status"`
+	// Message of the error of the ERROR faults, a generic one when empty
+	Message string `json:"message"`
+	// TimeoutMs is how long the TIMEOUT faults wait when the call has no deadline, 30s when 0
+	TimeoutMs int32 `json:"timeoutMs"`
+	// Nth makes the fault fail only the Nth call of the methods after it is created, every call
+	// when 0
+	Nth int32 `json:"nth"`
+	// Count is the number of failures the fault injects before it is removed, unlimited when 0
+	Count int32 `json:"count"`
+	// Calls is the number of calls of the methods since the fault was created
+	Calls int32 `json:"calls"`
+	// Injected is the number of failures the fault injected
+	Injected int32 `json:"injected"`
+}
+
+// CreateFaultRequest represents a request to create a fault
+type CreateFaultRequest struct {
+	// FaultID is the ID of the fault, generated when empty
+	FaultID string `json:"faultId"`
+	// Fault to create
+	Fault *Fault `json:"fault"`
+}
+
+// DeleteFaultRequest represents a request to delete a fault
+type DeleteFaultRequest struct {
+	// Name of the fault
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the fault doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// ListFaultsRequest represents a request to list the faults
+type ListFaultsRequest struct{}
+
+// ListFaultsResponse represents the faults, in the order they were created
+type ListFaultsResponse struct {
+	Faults []*Fault `json:"faults"`
+}
+
+// Injector injects the faults in the calls to the firmware
+type Injector struct {
+	enabled bool
+	mu      sync.Mutex
+	faults  map[string]*Fault
+	// order is the sequence number of the faults by name, the first matching fault is injected
+	order map[string]int
+	next  int
+}
+
+// New creates an injector, the faults can only be created when it is enabled
+func New(enabled bool) *Injector {
+	return &Injector{
+		enabled: enabled,
+		faults:  make(map[string]*Fault),
+		order:   make(map[string]int),
+	}
+}
+
+// checkEnabled fails the calls managing the faults when the injector is disabled
+func (i *Injector) checkEnabled() error {
+	if !i.enabled {
+		msg := "Fault injection is disabled"
+		return status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return nil
+}
+
+// resourceIDToFaultName builds the name of a fault, they have their own collection as they are
+// not part of the OPI APIs
+func resourceIDToFaultName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"faults", resourceID,
+	)
+}
+
+// CreateFault creates a fault, it applies to the calls made after
+func (i *Injector) CreateFault(_ context.Context, in *CreateFaultRequest) (*Fault, error) {
+	if err := i.checkEnabled(); err != nil {
+		return nil, err
+	}
+	if in.Fault == nil {
+		msg := "missing required field: fault"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if _, err := path.Match(in.Fault.Method, ""); err != nil || in.Fault.Method == "" {
+		msg := fmt.Sprintf("Invalid method pattern %q", in.Fault.Method)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	switch in.Fault.Kind {
+	case KindError, KindStatus, KindTimeout, KindMalformed:
+	default:
+		msg := fmt.Sprintf("Invalid fault kind %q, has to be ERROR, STATUS, TIMEOUT or MALFORMED", in.Fault.Kind)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.Fault.Nth < 0 || in.Fault.Count < 0 || in.Fault.TimeoutMs < 0 {
+		msg := "The nth, count and timeoutMs of a fault can't be negative"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	resourceID := resourceid.NewSystemGenerated()
+	if in.FaultID != "" {
+		if err := resourceid.ValidateUserSettable(in.FaultID); err != nil {
+			msg := fmt.Sprintf("Invalid fault ID %q: %v", in.FaultID, err)
+			return nil, status.Errorf(codes.InvalidArgument, msg)
+		}
+		resourceID = in.FaultID
+	}
+	fault := *in.Fault
+	fault.Name = resourceIDToFaultName(resourceID)
+	fault.Calls = 0
+	fault.Injected = 0
+	if fault.Kind == KindStatus && fault.Status == 0 {
+		fault.Status = -1
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.faults[fault.Name]; ok {
+		msg := fmt.Sprintf("Fault %s already exists", fault.Name)
+		return nil, status.Errorf(codes.AlreadyExists, msg)
+	}
+	i.faults[fault.Name] = &fault
+	i.order[fault.Name] = i.next
+	i.next++
+	slog.Warn("Injecting a fault in the calls to the firmware", "name", fault.Name, "method", fault.Method, "kind", fault.Kind)
+	created := fault
+	return &created, nil
+}
+
+// DeleteFault deletes a fault, the calls made after aren't failed by it anymore
+func (i *Injector) DeleteFault(_ context.Context, in *DeleteFaultRequest) (*emptypb.Empty, error) {
+	if err := i.checkEnabled(); err != nil {
+		return nil, err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.faults[in.Name]; !ok {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	i.remove(in.Name)
+	return &emptypb.Empty{}, nil
+}
+
+// ListFaults lists the faults, with the number of calls they saw and failed
+func (i *Injector) ListFaults(_ context.Context, _ *ListFaultsRequest) (*ListFaultsResponse, error) {
+	if err := i.checkEnabled(); err != nil {
+		return nil, err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	faults := make([]*Fault, 0, len(i.faults))
+	for _, name := range i.sorted() {
+		fault := *i.faults[name]
+		faults = append(faults, &fault)
+	}
+	return &ListFaultsResponse{Faults: faults}, nil
+}
+
+// sorted returns the names of the faults, in the order they were created
+func (i *Injector) sorted() []string {
+	names := make([]string, 0, len(i.faults))
+	for name := range i.faults {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool { return i.order[names[a]] < i.order[names[b]] })
+	return names
+}
+
+// remove removes a fault
+func (i *Injector) remove(name string) {
+	delete(i.faults, name)
+	delete(i.order, name)
+}
+
+// match counts a call of a method in the faults of the method and returns the one to inject, nil
+// when the call goes to the firmware as is
+func (i *Injector) match(method string) *Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var injected *Fault
+	for _, name := range i.sorted() {
+		fault := i.faults[name]
+		if ok, _ := path.Match(fault.Method, method); !ok {
+			continue
+		}
+		fault.Calls++
+		if injected != nil || fault.Nth != 0 && fault.Calls != fault.Nth {
+			continue
+		}
+		fault.Injected++
+		copied := *fault
+		injected = &copied
+		if fault.Count != 0 && fault.Injected >= fault.Count || fault.Nth != 0 {
+			i.remove(name)
+		}
+	}
+	return injected
+}
+
+// WrapJSONRPC injects the faults in the calls of a JSON-RPC client
+func (i *Injector) WrapJSONRPC(rpc spdk.JSONRPC) spdk.JSONRPC {
+	if !i.enabled {
+		return rpc
+	}
+	return &faultyJSONRPC{JSONRPC: rpc, injector: i}
+}
+
+// faultyJSONRPC implements spdk.JSONRPC, injecting the faults
+type faultyJSONRPC struct {
+	spdk.JSONRPC
+	injector *Injector
+}
+
+// Call calls a method of the firmware unless a fault fails it
+func (c *faultyJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	fault := c.injector.match(method)
+	if fault == nil {
+		return c.JSONRPC.Call(ctx, method, args, result)
+	}
+	slog.WarnContext(ctx, "Injected a fault in a call to the firmware", "name", fault.Name, "method", method, "kind", fault.Kind)
+	switch fault.Kind {
+	case KindError:
+		message := fault.Message
+		if message == "" {
+			message = "injected fault"
+		}
+		return fmt.Errorf("%s: json response error: %s", method, message)
+	case KindStatus:
+		data, _ := json.Marshal(map[string]int32{"status": fault.Status})
+		if err := json.Unmarshal(data, &result); err != nil {
+			return fmt.Errorf("%s: %s", method, err)
+		}
+		return nil
+	case KindTimeout:
+		timeout := defaultTimeout
+		if fault.TimeoutMs != 0 {
+			timeout = time.Duration(fault.TimeoutMs) * time.Millisecond
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %s", method, ctx.Err())
+		case <-timer.C:
+			return fmt.Errorf("%s: %s", method, os.ErrDeadlineExceeded)
+		}
+	default:
+		if err := c.JSONRPC.Call(ctx, method, args, result); err != nil {
+			return err
+		}
+		var malformed json.RawMessage
+		err := json.Unmarshal([]byte(`{"status": 0, "result": `), &malformed)
+		return fmt.Errorf("%s: %s", method, err)
+	}
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package faults injects failures in the calls to the firmware, an error, a failed status, a
+// timeout or a malformed response, on every call of a method or on its Nth call, so the rollback
+// and retry logic of the bridge can be validated before hitting production hardware
+package faults
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testJSONRPC is a firmware answering a successful status, it counts the calls
+type testJSONRPC struct {
+	spdk.JSONRPC
+	calls int
+}
+
+func (c *testJSONRPC) Call(_ context.Context, _ string, _, result interface{}) error {
+	c.calls++
+	result.(*models.MrvlNvmCreateSubsystemResult).Status = 0
+	return nil
+}
+
+func TestFaults_Inject(t *testing.T) {
+	tests := map[string]struct {
+		fault    Fault
+		calls    int
+		failed   []bool
+		status   models.Status
+		errMsg   string
+		firmware int
+	}{
+		"error": {
+			fault:    Fault{Method: "mrvl_nvm_*", Kind: KindError, Message: "Invalid parameters"},
+			calls:    2,
+			failed:   []bool{true, true},
+			errMsg:   "mrvl_nvm_create_subsystem: json response error: Invalid parameters",
+			firmware: 0,
+		},
+		"status": {
+			fault:    Fault{Method: "mrvl_nvm_create_subsystem", Kind: KindStatus},
+			calls:    1,
+			failed:   []bool{false},
+			status:   -1,
+			firmware: 0,
+		},
+		"other method": {
+			fault:    Fault{Method: "mrvl_bdev_*", Kind: KindError},
+			calls:    1,
+			failed:   []bool{false},
+			firmware: 1,
+		},
+		"nth call": {
+			fault:    Fault{Method: "mrvl_nvm_create_subsystem", Kind: KindError, Nth: 2},
+			calls:    3,
+			failed:   []bool{false, true, false},
+			errMsg:   "mrvl_nvm_create_subsystem: json response error: injected fault",
+			firmware: 2,
+		},
+		"count": {
+			fault:    Fault{Method: "*", Kind: KindError, Count: 1},
+			calls:    2,
+			failed:   []bool{true, false},
+			errMsg:   "mrvl_nvm_create_subsystem: json response error: injected fault",
+			firmware: 1,
+		},
+		"malformed": {
+			fault:    Fault{Method: "mrvl_nvm_create_subsystem", Kind: KindMalformed},
+			calls:    1,
+			failed:   []bool{true},
+			errMsg:   "mrvl_nvm_create_subsystem: unexpected end of JSON input",
+			firmware: 1,
+		},
+		"timeout": {
+			fault:    Fault{Method: "mrvl_nvm_create_subsystem", Kind: KindTimeout, TimeoutMs: 1},
+			calls:    1,
+			failed:   []bool{true},
+			errMsg:   "mrvl_nvm_create_subsystem: i/o timeout",
+			firmware: 0,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			injector := New(true)
+			firmware := &testJSONRPC{}
+			rpc := injector.WrapJSONRPC(firmware)
+			if _, err := injector.CreateFault(context.Background(), &CreateFaultRequest{Fault: &tt.fault}); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.calls; i++ {
+				result := models.MrvlNvmCreateSubsystemResult{Status: 42}
+				err := rpc.Call(context.Background(), "mrvl_nvm_create_subsystem", &models.MrvlNvmCreateSubsystemParams{}, &result)
+				if (err != nil) != tt.failed[i] {
+					t.Error("call", i+1, "expected failed", tt.failed[i], "received", err)
+				}
+				if err != nil && err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				if err == nil && result.Status != tt.status {
+					t.Error("status: expected", tt.status, "received", result.Status)
+				}
+			}
+			if firmware.calls != tt.firmware {
+				t.Error("firmware calls: expected", tt.firmware, "received", firmware.calls)
+			}
+		})
+	}
+}
+
+func TestFaults_Manage(t *testing.T) {
+	injector := New(true)
+	fault, err := injector.CreateFault(context.Background(), &CreateFaultRequest{FaultID: "attach", Fault: &Fault{Method: "mrvl_nvm_ctrlr_attach_ns", Kind: KindError}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fault.Name != resourceIDToFaultName("attach") {
+		t.Error("name: expected", resourceIDToFaultName("attach"), "received", fault.Name)
+	}
+	_, err = injector.CreateFault(context.Background(), &CreateFaultRequest{FaultID: "attach", Fault: &Fault{Method: "*", Kind: KindError}})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Error("create again: expected", codes.AlreadyExists, "received", err)
+	}
+	for _, invalid := range []*Fault{{Method: "mrvl_[", Kind: KindError}, {Method: "*", Kind: "CRASH"}, {Method: "*", Kind: KindError, Nth: -1}} {
+		if _, err := injector.CreateFault(context.Background(), &CreateFaultRequest{Fault: invalid}); status.Code(err) != codes.InvalidArgument {
+			t.Error("invalid fault: expected", codes.InvalidArgument, "received", err)
+		}
+	}
+
+	_ = injector.WrapJSONRPC(&testJSONRPC{}).Call(context.Background(), "mrvl_nvm_ctrlr_attach_ns", nil, nil)
+	list, err := injector.ListFaults(context.Background(), &ListFaultsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Faults) != 1 || list.Faults[0].Calls != 1 || list.Faults[0].Injected != 1 {
+		t.Error("list: expected one fault injected once, received", list.Faults)
+	}
+	if _, err := injector.DeleteFault(context.Background(), &DeleteFaultRequest{Name: fault.Name}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := injector.DeleteFault(context.Background(), &DeleteFaultRequest{Name: fault.Name}); status.Code(err) != codes.NotFound {
+		t.Error("delete again: expected", codes.NotFound, "received", err)
+	}
+
+	disabled := New(false)
+	if _, err := disabled.CreateFault(context.Background(), &CreateFaultRequest{Fault: &Fault{Method: "*", Kind: KindError}}); status.Code(err) != codes.FailedPrecondition {
+		t.Error("disabled: expected", codes.FailedPrecondition, "received", err)
+	}
+	firmware := &testJSONRPC{}
+	if rpc := disabled.WrapJSONRPC(firmware); rpc != firmware {
+		t.Error("disabled: expected the firmware as is")
+	}
+}
+
+func TestFaults_TimeoutContext(t *testing.T) {
+	injector := New(true)
+	if _, err := injector.CreateFault(context.Background(), &CreateFaultRequest{Fault: &Fault{Method: "*", Kind: KindTimeout}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := injector.WrapJSONRPC(&testJSONRPC{}).Call(ctx, "mrvl_nvm_create_subsystem", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Error("timeout: expected the deadline of the call, received", err)
+	}
+}
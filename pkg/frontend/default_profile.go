@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+
+	"github.com/google/uuid"
+)
+
+// maxSerialNumberLength is the length of the serial numbers of the subsystems in Identify Controller
+const maxSerialNumberLength = 20
+
+// DefaultProfile is the tuning of the card applied to the controllers and the subsystems created
+// without it, so the clients don't hard-code it. The defaults applied are kept in the spec of the
+// resources as if the clients set them
+type DefaultProfile struct {
+	// MaxNsq and MaxNcq are the host submission and completion queues of the controllers
+	MaxNsq int32
+	MaxNcq int32
+	// Sqes and Cqes are the entries of the submission and completion queues of the controllers,
+	// as a power of 2, the firmware takes Sqes as their Maximum Queue Entries Supported
+	Sqes int32
+	Cqes int32
+	// SerialPrefix starts the serial numbers generated for the subsystems created without one
+	SerialPrefix string
+}
+
+// ParseDefaultProfile parses a default profile in key=value format, comma separated, the keys
+// being max_nsq, max_ncq, sqes, cqes and serial_prefix, i.e. max_nsq=16,max_ncq=16,sqes=10
+func ParseDefaultProfile(value string) (*DefaultProfile, error) {
+	profile := new(DefaultProfile)
+	for _, field := range strings.Split(value, ",") {
+		key, setting, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid default %q, have to be key=value", field)
+		}
+		if key == "serial_prefix" {
+			if setting == "" || len(setting) >= maxSerialNumberLength {
+				return nil, fmt.Errorf("invalid default %q, the prefix has to be between 1 and %d characters", field, maxSerialNumberLength-1)
+			}
+			profile.SerialPrefix = setting
+			continue
+		}
+		var target *int32
+		var limit int
+		switch key {
+		case "max_nsq":
+			target, limit = &profile.MaxNsq, 65535
+		case "max_ncq":
+			target, limit = &profile.MaxNcq, 65535
+		case "sqes":
+			target, limit = &profile.Sqes, 16
+		case "cqes":
+			target, limit = &profile.Cqes, 16
+		default:
+			return nil, fmt.Errorf("invalid default %q, unknown key %s", field, key)
+		}
+		number, err := strconv.Atoi(setting)
+		if err != nil || number < 1 || number > limit {
+			return nil, fmt.Errorf("invalid default %q, have to be between 1 and %d", field, limit)
+		}
+		*target = int32(number)
+	}
+	return profile, nil
+}
+
+// SetDefaultProfile sets the tuning applied to the controllers and the subsystems created without it
+func (s *Server) SetDefaultProfile(profile *DefaultProfile) {
+	s.defaultProfile = profile
+}
+
+// applyNvmeControllerDefaults fills the queues of a new controller left to 0 with the defaults
+func (s *Server) applyNvmeControllerDefaults(controller *pb.NvmeController) {
+	if s.defaultProfile == nil {
+		return
+	}
+	var applied []string
+	fill := func(field string, value *int32, defaultValue int32) {
+		if *value == 0 && defaultValue != 0 {
+			*value = defaultValue
+			applied = append(applied, field)
+		}
+	}
+	fill("max_nsq", &controller.Spec.MaxNsq, s.defaultProfile.MaxNsq)
+	fill("max_ncq", &controller.Spec.MaxNcq, s.defaultProfile.MaxNcq)
+	fill("sqes", &controller.Spec.Sqes, s.defaultProfile.Sqes)
+	fill("cqes", &controller.Spec.Cqes, s.defaultProfile.Cqes)
+	if len(applied) > 0 {
+		slog.Info("Applied defaults", "name", controller.Name, "fields", applied)
+	}
+}
+
+// applyNvmeSubsystemDefaults generates the serial number of a new subsystem created without one
+func (s *Server) applyNvmeSubsystemDefaults(subsys *pb.NvmeSubsystem) {
+	if s.defaultProfile == nil || s.defaultProfile.SerialPrefix == "" || subsys.Spec.SerialNumber != "" {
+		return
+	}
+	suffix := strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", ""))
+	subsys.Spec.SerialNumber = s.defaultProfile.SerialPrefix + suffix[:maxSerialNumberLength-len(s.defaultProfile.SerialPrefix)]
+	slog.Info("Applied defaults", "name", subsys.Name, "fields", []string{"serial_number"})
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_ParseDefaultProfile(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		profile *DefaultProfile
+		errMsg  string
+	}{
+		"all the defaults": {
+			value:   "max_nsq=16, max_ncq=16,sqes=10,cqes=4,serial_prefix=MRVL",
+			profile: &DefaultProfile{MaxNsq: 16, MaxNcq: 16, Sqes: 10, Cqes: 4, SerialPrefix: "MRVL"},
+		},
+		"some of the defaults": {
+			value:   "sqes=6",
+			profile: &DefaultProfile{Sqes: 6},
+		},
+		"missing value": {
+			value:  "max_nsq",
+			errMsg: `invalid default "max_nsq", have to be key=value`,
+		},
+		"unknown key": {
+			value:  "mqes=10",
+			errMsg: `invalid default "mqes=10", unknown key mqes`,
+		},
+		"queue entries out of range": {
+			value:  "sqes=17",
+			errMsg: `invalid default "sqes=17", have to be between 1 and 16`,
+		},
+		"bad number": {
+			value:  "max_ncq=many",
+			errMsg: `invalid default "max_ncq=many", have to be between 1 and 65535`,
+		},
+		"serial prefix too long": {
+			value:  "serial_prefix=01234567890123456789",
+			errMsg: `invalid default "serial_prefix=01234567890123456789", the prefix has to be between 1 and 19 characters`,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			profile, err := ParseDefaultProfile(tt.value)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !reflect.DeepEqual(profile, tt.profile) {
+				t.Error("profile: expected", tt.profile, "received", profile)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeControllerWithDefaultProfile(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`})
+	defer testEnv.Close()
+
+	testEnv.opiSpdkServer.SetDefaultProfile(&DefaultProfile{MaxNsq: 16, MaxNcq: 16, Sqes: 10, Cqes: 4})
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+
+	spec := utils.ProtoClone(testController.Spec)
+	spec.NvmeControllerId = nil
+	spec.MaxNsq = 8
+	spec.MaxNcq = 0
+	spec.Sqes = 0
+	spec.Cqes = 0
+	request := &pb.CreateNvmeControllerRequest{Parent: testSubsystemName, NvmeControllerId: "new-controller-id",
+		NvmeController: &pb.NvmeController{Spec: spec}}
+	response, err := testEnv.opiSpdkServer.CreateNvmeController(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+
+	// the values given by the client are kept, the defaults are recorded for the others
+	if response.Spec.MaxNsq != 8 || response.Spec.MaxNcq != 16 || response.Spec.Sqes != 10 || response.Spec.Cqes != 4 {
+		t.Error("spec: expected the defaults applied, received", response.Spec)
+	}
+	controller := new(pb.NvmeController)
+	found, err := testEnv.opiSpdkServer.store.Get(response.Name, controller)
+	if err != nil || !found {
+		t.Fatal("expected the controller saved, received", err)
+	}
+	if controller.Spec.MaxNcq != 16 || controller.Spec.Sqes != 10 || controller.Spec.Cqes != 4 {
+		t.Error("saved spec: expected the defaults applied, received", controller.Spec)
+	}
+}
+
+func TestFrontEnd_CreateNvmeSubsystemWithDefaultProfile(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"version": "SPDK v20.10"}}`,
+	})
+	defer testEnv.Close()
+
+	testEnv.opiSpdkServer.SetDefaultProfile(&DefaultProfile{SerialPrefix: "MRVL"})
+	request := &pb.CreateNvmeSubsystemRequest{NvmeSubsystemId: testSubsystemID,
+		NvmeSubsystem: &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{Nqn: testSubsystem.Spec.Nqn, ModelNumber: "OpiModelNumber"}}}
+	response, err := testEnv.client.CreateNvmeSubsystem(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	serial := response.Spec.SerialNumber
+	if !strings.HasPrefix(serial, "MRVL") || len(serial) != maxSerialNumberLength {
+		t.Error("expected a serial number generated with the prefix, received", serial)
+	}
+}
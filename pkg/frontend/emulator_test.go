@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/philippgille/gokv/gomap"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/emulator"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_Emulator(t *testing.T) {
+	options := gomap.DefaultOptions
+	options.Codec = utils.ProtoCodec{}
+	server := NewServer(emulator.New(), gomap.NewStore(options), operations.NewManager())
+	ctx := context.Background()
+
+	subsystem, err := server.CreateNvmeSubsystem(ctx, &pb.CreateNvmeSubsystemRequest{
+		NvmeSubsystemId: testSubsystemID,
+		NvmeSubsystem:   &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{Nqn: testSubsystem.Spec.Nqn, MaxNamespaces: 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subsystem.Status.FirmwareRevision != emulator.Version {
+		t.Error("firmware revision: expected", emulator.Version, "received", subsystem.Status.FirmwareRevision)
+	}
+	_, err = server.CreateNvmeController(ctx, &pb.CreateNvmeControllerRequest{
+		Parent:           testSubsystemName,
+		NvmeControllerId: testControllerID,
+		NvmeController:   &pb.NvmeController{Spec: utils.ProtoClone(testController.Spec)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the firmware gives the first namespace the instance ID 1
+	_, err = server.CreateNvmeNamespace(ctx, &pb.CreateNvmeNamespaceRequest{
+		Parent:          testSubsystemName,
+		NvmeNamespaceId: testNamespaceID,
+		NvmeNamespace:   &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{HostNsid: 1, VolumeNameRef: "Malloc0", Nguid: "0123456789abcdef0123456789abcdef"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	namespace, err := server.GetNvmeNamespace(ctx, &pb.GetNvmeNamespaceRequest{Name: testNamespaceName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if namespace.Spec.VolumeNameRef != "Malloc0" {
+		t.Error("namespace: expected Malloc0, received", namespace.Spec.VolumeNameRef)
+	}
+	controllers, err := server.ListNvmeControllers(ctx, &pb.ListNvmeControllersRequest{Parent: testSubsystemName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(controllers.NvmeControllers) != 1 {
+		t.Error("controllers: expected 1, received", len(controllers.NvmeControllers))
+	}
+
+	if _, err := server.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{Name: testNamespaceName}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{Name: testControllerName}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: testSubsystemName}); err != nil {
+		t.Fatal(err)
+	}
+	subsystems, err := server.ListNvmeSubsystems(ctx, &pb.ListNvmeSubsystemsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subsystems.NvmeSubsystems) != 0 {
+		t.Error("subsystems: expected none, received", subsystems.NvmeSubsystems)
+	}
+}
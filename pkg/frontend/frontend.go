@@ -7,35 +7,154 @@
 package frontend
 
 import (
+	"context"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/philippgille/gokv"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/fanout"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-marvell-bridge/pkg/pagination"
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
 )
 
+// defaultMigrationPollInterval is how often the progress of a namespace migration is polled
+const defaultMigrationPollInterval = 5 * time.Second
+
+// storagePoolPrefix is the prefix of the names of the storage pools namespaces can reference
+// instead of a volume
+const storagePoolPrefix = "//storage.opiproject.org/storagePools/"
+
+// VolumePlacer places the namespaces referencing a storage pool on one of its volumes
+type VolumePlacer interface {
+	// PlaceVolume chooses the volume of a new namespace following the policy of the pool
+	PlaceVolume(ctx context.Context, pool string) (string, error)
+	// ReleaseVolume undoes the placement of a namespace whose creation failed
+	ReleaseVolume(ctx context.Context, pool string, volume string) error
+}
+
 // Server contains frontend related OPI services
 type Server struct {
 	pb.UnimplementedFrontendNvmeServiceServer
 	ListHelper map[string]bool
-	Pagination map[string]int
+	Pagination *pagination.Paginator
 	store      gokv.Store
 	rpc        spdk.JSONRPC
+	operations *operations.Manager
+	// nsChangeAen makes the firmware raise the Namespace Attribute Changed
+	// event to the hosts when namespaces are attached or detached
+	nsChangeAen bool
+	// thinProvisioning makes the namespaces report the capacity allocated on their
+	// volume instead of their size, so the volumes can be overcommitted
+	thinProvisioning bool
+	// migrationPollInterval is how often the progress of a namespace migration is polled
+	migrationPollInterval time.Duration
+	// suspendPollInterval is how often the draining of a suspended controller is polled
+	suspendPollInterval time.Duration
+	// tracePollInterval is how often the traced IOs of a namespace are read from the firmware
+	tracePollInterval time.Duration
+	// volumePlacer places the namespaces referencing a storage pool, disabled when nil
+	volumePlacer VolumePlacer
+	// controllerStateReporter is told the controllers becoming active or inactive, disabled when nil
+	controllerStateReporter ControllerStateReporter
+	// skuLimiter limits the resources to the ones the SKU of the DPU allows, unlimited when nil
+	skuLimiter SkuLimiter
+	// pcieFunctionPool are the functions given to the controllers created without a PcieId,
+	// the PcieId is required when empty
+	pcieFunctionPool []PcieFunction
+	// defaultProfile is the tuning applied to the resources created without it, none when nil
+	defaultProfile *DefaultProfile
+	// validationMode tells if the values of the specs the firmware doesn't support are
+	// refused or ignored and clamped with warnings
+	validationMode validation.Mode
+	// clearedStats are the stats of the controllers and the namespaces when they were cleared
+	clearedStats   map[string]*pb.VolumeStats
+	clearedStatsMu sync.Mutex
+	// fanoutWorkers is the number of calls in flight of the flows making a call per resource
+	fanoutWorkers int
+	// requestsMu is held for reading by the requests and for writing by the checks and the
+	// replay of the resources in the background, so they never use ListHelper at the same time
+	requestsMu sync.RWMutex
 }
 
 // NewServer creates initialized instance of Nvme server
-func NewServer(jsonRPC spdk.JSONRPC, store gokv.Store) *Server {
+func NewServer(jsonRPC spdk.JSONRPC, store gokv.Store, ops *operations.Manager) *Server {
 	if jsonRPC == nil {
 		log.Panic("nil for JSONRPC is not allowed")
 	}
 	if store == nil {
 		log.Panic("nil for Store is not allowed")
 	}
+	if ops == nil {
+		log.Panic("nil for Operations is not allowed")
+	}
 	return &Server{
-		ListHelper: make(map[string]bool),
-		Pagination: make(map[string]int),
-		store:      store,
-		rpc:        jsonRPC,
+		ListHelper:            make(map[string]bool),
+		Pagination:            pagination.New(),
+		store:                 store,
+		rpc:                   jsonRPC,
+		operations:            ops,
+		nsChangeAen:           true,
+		migrationPollInterval: defaultMigrationPollInterval,
+		tracePollInterval:     defaultTracePollInterval,
+		suspendPollInterval:   defaultSuspendPollInterval,
+		clearedStats:          make(map[string]*pb.VolumeStats),
+		fanoutWorkers:         fanout.DefaultWorkers,
+		validationMode:        validation.Strict,
+	}
+}
+
+// SetNamespaceChangeAen enables or suppresses the Namespace Attribute Changed event raised
+// to the hosts when namespaces are created or deleted, hosts have to rescan when suppressed
+func (s *Server) SetNamespaceChangeAen(enable bool) {
+	s.nsChangeAen = enable
+}
+
+// SetThinProvisioning makes the namespaces created from now on thin provisioned, the hosts
+// see the space actually allocated on the volumes so the pools can be overcommitted
+func (s *Server) SetThinProvisioning(enable bool) {
+	s.thinProvisioning = enable
+}
+
+// SetFanoutWorkers sets the number of calls made in parallel by the flows making a call per
+// resource, i.e. the collection of the stats of all the controllers and namespaces
+func (s *Server) SetFanoutWorkers(workers int) {
+	s.fanoutWorkers = workers
+}
+
+// SetVolumePlacer sets how the namespaces referencing a storage pool are placed on its volumes
+func (s *Server) SetVolumePlacer(placer VolumePlacer) {
+	s.volumePlacer = placer
+}
+
+// isStoragePoolName tells if the volume_name_ref of a namespace refers to a storage pool
+func isStoragePoolName(volume string) bool {
+	return strings.HasPrefix(volume, storagePoolPrefix)
+}
+
+// validateResourceName checks the name of requests which are not part of
+// the OPI protobufs and so can't rely on the field behavior annotations
+func validateResourceName(name string) error {
+	v := new(validation.Validator)
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	v.ResourceName("name", name)
+	return v.Err()
+}
+
+// validateNameRequest checks the required fields and the name of the OPI requests
+// only made of the name of a resource, i.e. the Get, Delete and Stats ones
+func validateNameRequest(in proto.Message, name string) error {
+	v := new(validation.Validator)
+	// check required fields
+	if v.RequiredFields(in) {
+		// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+		v.ResourceName("name", name)
 	}
+	return v.Err()
 }
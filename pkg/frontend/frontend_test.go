@@ -11,6 +11,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
@@ -54,7 +56,10 @@ func createTestEnvironment(spdkResponses []string) *testEnv {
 	options := gomap.DefaultOptions
 	options.Codec = utils.ProtoCodec{}
 	store := gomap.NewStore(options)
-	env.opiSpdkServer = NewServer(env.jsonRPC, store)
+	env.opiSpdkServer = NewServer(env.jsonRPC, store, operations.NewManager())
+	env.opiSpdkServer.migrationPollInterval = time.Millisecond
+	env.opiSpdkServer.tracePollInterval = time.Millisecond
+	env.opiSpdkServer.suspendPollInterval = time.Millisecond
 
 	ctx := context.Background()
 	conn, err := grpc.DialContext(ctx,
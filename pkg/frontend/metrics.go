@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/fanout"
+	"github.com/opiproject/opi-marvell-bridge/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	controllerReadBytesDesc = prometheus.NewDesc(
+		"opi_nvme_controller_read_bytes_total",
+		"Bytes read by the hosts through an Nvme controller.",
+		[]string{"controller"}, nil,
+	)
+	controllerReadOpsDesc = prometheus.NewDesc(
+		"opi_nvme_controller_read_ops_total",
+		"Read commands issued by the hosts through an Nvme controller.",
+		[]string{"controller"}, nil,
+	)
+	controllerWriteBytesDesc = prometheus.NewDesc(
+		"opi_nvme_controller_write_bytes_total",
+		"Bytes written by the hosts through an Nvme controller.",
+		[]string{"controller"}, nil,
+	)
+	controllerWriteOpsDesc = prometheus.NewDesc(
+		"opi_nvme_controller_write_ops_total",
+		"Write commands issued by the hosts through an Nvme controller.",
+		[]string{"controller"}, nil,
+	)
+	controllerReadLatencyDesc = prometheus.NewDesc(
+		"opi_nvme_controller_read_latency_microseconds_total",
+		"Cumulated latency of the read commands of an Nvme controller in microseconds.",
+		[]string{"controller"}, nil,
+	)
+	controllerWriteLatencyDesc = prometheus.NewDesc(
+		"opi_nvme_controller_write_latency_microseconds_total",
+		"Cumulated latency of the write commands of an Nvme controller in microseconds.",
+		[]string{"controller"}, nil,
+	)
+)
+
+// ResourceCounts counts the frontend resources by collection
+func (s *Server) ResourceCounts() map[string]int {
+	return metrics.CountCollections(s.ListHelper)
+}
+
+// ControllerStatsCollector exports the I/O stats of the Nvme controllers as Prometheus metrics
+type ControllerStatsCollector struct {
+	server *Server
+}
+
+// NewControllerStatsCollector creates a collector reading the stats of the controllers on every scrape
+func NewControllerStatsCollector(server *Server) *ControllerStatsCollector {
+	return &ControllerStatsCollector{server: server}
+}
+
+// Describe implements prometheus.Collector
+func (c *ControllerStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- controllerReadBytesDesc
+	ch <- controllerReadOpsDesc
+	ch <- controllerWriteBytesDesc
+	ch <- controllerWriteOpsDesc
+	ch <- controllerReadLatencyDesc
+	ch <- controllerWriteLatencyDesc
+}
+
+// Collect implements prometheus.Collector, the controllers whose stats can't be read are
+// skipped so they don't fail the whole scrape
+func (c *ControllerStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+	names := c.server.listNames("/nvmeControllers/")
+	bulk, err := c.server.bulkStats(ctx, names, nil)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not collect the stats of the NvmeControllers", "error", err)
+		return
+	}
+	for _, name := range names {
+		stats := bulk[name]
+		if stats == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(controllerReadBytesDesc, prometheus.CounterValue, float64(stats.ReadBytesCount), name)
+		ch <- prometheus.MustNewConstMetric(controllerReadOpsDesc, prometheus.CounterValue, float64(stats.ReadOpsCount), name)
+		ch <- prometheus.MustNewConstMetric(controllerWriteBytesDesc, prometheus.CounterValue, float64(stats.WriteBytesCount), name)
+		ch <- prometheus.MustNewConstMetric(controllerWriteOpsDesc, prometheus.CounterValue, float64(stats.WriteOpsCount), name)
+		ch <- prometheus.MustNewConstMetric(controllerReadLatencyDesc, prometheus.CounterValue, float64(stats.ReadLatencyTicks), name)
+		ch <- prometheus.MustNewConstMetric(controllerWriteLatencyDesc, prometheus.CounterValue, float64(stats.WriteLatencyTicks), name)
+	}
+}
+
+// collectStats reads the stats of resources of a kind in parallel, in the order of their names,
+// nil for the ones whose stats can't be read, which are logged
+func (s *Server) collectStats(ctx context.Context, names []string, kind string, read func(ctx context.Context, name string) (*pb.VolumeStats, error)) []*pb.VolumeStats {
+	stats, _ := fanout.Map(ctx, s.fanoutWorkers, names, func(ctx context.Context, name string) (*pb.VolumeStats, error) {
+		stats, err := read(ctx, name)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not collect the stats of "+kind, "name", name, "error", err)
+			return nil, nil
+		}
+		return stats, nil
+	})
+	return stats
+}
+
+// controllerStats reads the stats of a controller
+func (s *Server) controllerStats(ctx context.Context, name string) (*pb.VolumeStats, error) {
+	response, err := s.StatsNvmeController(ctx, &pb.StatsNvmeControllerRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return response.GetStats(), nil
+}
+
+// namespaceStats reads the stats of a namespace
+func (s *Server) namespaceStats(ctx context.Context, name string) (*pb.VolumeStats, error) {
+	response, err := s.StatsNvmeNamespace(ctx, &pb.StatsNvmeNamespaceRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return response.GetStats(), nil
+}
+
+// listNames returns the sorted names of the resources of a collection, i.e. /nvmeControllers/
+func (s *Server) listNames(collection string) []string {
+	var names []string
+	for name := range s.ListHelper {
+		if strings.Contains(name, collection) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// statsInstruments are the OpenTelemetry counters of the I/O stats of a kind of resource
+type statsInstruments struct {
+	kind         string
+	readBytes    metric.Int64ObservableCounter
+	readOps      metric.Int64ObservableCounter
+	writeBytes   metric.Int64ObservableCounter
+	writeOps     metric.Int64ObservableCounter
+	readLatency  metric.Int64ObservableCounter
+	writeLatency metric.Int64ObservableCounter
+}
+
+// newStatsInstruments creates the counters of a kind of resource, controller or namespace
+func newStatsInstruments(meter metric.Meter, kind string) (*statsInstruments, error) {
+	var err error
+	counter := func(name string, unit string, description string) metric.Int64ObservableCounter {
+		c, cerr := meter.Int64ObservableCounter("opi.nvme."+kind+"."+name,
+			metric.WithUnit(unit), metric.WithDescription(description))
+		err = errors.Join(err, cerr)
+		return c
+	}
+	i := &statsInstruments{
+		kind:         kind,
+		readBytes:    counter("read.bytes", "By", "Bytes read by the hosts through an Nvme "+kind+"."),
+		readOps:      counter("read.ops", "{command}", "Read commands issued by the hosts through an Nvme "+kind+"."),
+		writeBytes:   counter("write.bytes", "By", "Bytes written by the hosts through an Nvme "+kind+"."),
+		writeOps:     counter("write.ops", "{command}", "Write commands issued by the hosts through an Nvme "+kind+"."),
+		readLatency:  counter("read.latency", "us", "Cumulated latency of the read commands of an Nvme "+kind+"."),
+		writeLatency: counter("write.latency", "us", "Cumulated latency of the write commands of an Nvme "+kind+"."),
+	}
+	return i, err
+}
+
+// observe reports the stats of a resource, with the attributes of the resource after its name
+func (i *statsInstruments) observe(o metric.Observer, name string, stats *pb.VolumeStats, extra ...attribute.KeyValue) {
+	attrs := metric.WithAttributes(append([]attribute.KeyValue{attribute.String(i.kind, name)}, extra...)...)
+	o.ObserveInt64(i.readBytes, int64(stats.ReadBytesCount), attrs)
+	o.ObserveInt64(i.readOps, int64(stats.ReadOpsCount), attrs)
+	o.ObserveInt64(i.writeBytes, int64(stats.WriteBytesCount), attrs)
+	o.ObserveInt64(i.writeOps, int64(stats.WriteOpsCount), attrs)
+	o.ObserveInt64(i.readLatency, int64(stats.ReadLatencyTicks), attrs)
+	o.ObserveInt64(i.writeLatency, int64(stats.WriteLatencyTicks), attrs)
+}
+
+// RegisterStatsInstruments reports the I/O stats of the Nvme controllers and namespaces to an
+// OpenTelemetry meter, they are read on every collection, i.e. every export to a collector. The
+// namespaces have the volume backing them as attribute, for the per-volume dashboards
+func (s *Server) RegisterStatsInstruments(meter metric.Meter) (metric.Registration, error) {
+	controllers, err := newStatsInstruments(meter, "controller")
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := newStatsInstruments(meter, "namespace")
+	if err != nil {
+		return nil, err
+	}
+	callback := func(ctx context.Context, o metric.Observer) error {
+		// the resources whose stats can't be read are skipped so they don't fail the whole collection
+		controllerNames := s.listNames("/nvmeControllers/")
+		namespaceNames := s.listNames("/nvmeNamespaces/")
+		bulk, err := s.bulkStats(ctx, controllerNames, namespaceNames)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not collect the stats of the NvmeControllers and NvmeNamespaces", "error", err)
+			return nil
+		}
+		for _, name := range controllerNames {
+			if stats := bulk[name]; stats != nil {
+				controllers.observe(o, name, stats)
+			}
+		}
+		volumes, err := s.NvmeNamespaceVolumes(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not collect the volumes of the NvmeNamespaces", "error", err)
+			return nil
+		}
+		for _, name := range namespaceNames {
+			if stats := bulk[name]; stats != nil {
+				namespaces.observe(o, name, stats, attribute.String("volume", volumes[name]))
+			}
+		}
+		return nil
+	}
+	return meter.RegisterCallback(callback,
+		controllers.readBytes, controllers.readOps, controllers.writeBytes, controllers.writeOps, controllers.readLatency, controllers.writeLatency,
+		namespaces.readBytes, namespaces.readOps, namespaces.writeBytes, namespaces.writeOps, namespaces.readLatency, namespaces.writeLatency,
+	)
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestFrontEnd_ControllerStatsCollector(t *testing.T) {
+	testEnv := createTestEnvironment([]string{`{"jsonrpc":"2.0","id":%d,"result":{"status":0,"ctrlr_stats":[{"subnqn":"nqn.2022-09.io.spdk:opi3","ctrlr_id":17,"num_read_cmds":4,"num_read_bytes":5,"num_write_cmds":6,"num_write_bytes":7,"total_read_latency_in_us":9,"total_write_latency_in_us":10}]}}`})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+	testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+	testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+
+	expected := fmt.Sprintf(`
+# HELP opi_nvme_controller_read_bytes_total Bytes read by the hosts through an Nvme controller.
+# TYPE opi_nvme_controller_read_bytes_total counter
+opi_nvme_controller_read_bytes_total{controller="%[1]s"} 5
+# HELP opi_nvme_controller_read_latency_microseconds_total Cumulated latency of the read commands of an Nvme controller in microseconds.
+# TYPE opi_nvme_controller_read_latency_microseconds_total counter
+opi_nvme_controller_read_latency_microseconds_total{controller="%[1]s"} 9
+# HELP opi_nvme_controller_read_ops_total Read commands issued by the hosts through an Nvme controller.
+# TYPE opi_nvme_controller_read_ops_total counter
+opi_nvme_controller_read_ops_total{controller="%[1]s"} 4
+# HELP opi_nvme_controller_write_bytes_total Bytes written by the hosts through an Nvme controller.
+# TYPE opi_nvme_controller_write_bytes_total counter
+opi_nvme_controller_write_bytes_total{controller="%[1]s"} 7
+# HELP opi_nvme_controller_write_latency_microseconds_total Cumulated latency of the write commands of an Nvme controller in microseconds.
+# TYPE opi_nvme_controller_write_latency_microseconds_total counter
+opi_nvme_controller_write_latency_microseconds_total{controller="%[1]s"} 10
+# HELP opi_nvme_controller_write_ops_total Write commands issued by the hosts through an Nvme controller.
+# TYPE opi_nvme_controller_write_ops_total counter
+opi_nvme_controller_write_ops_total{controller="%[1]s"} 6
+`, testControllerName)
+	collector := NewControllerStatsCollector(testEnv.opiSpdkServer)
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	counts := testEnv.opiSpdkServer.ResourceCounts()
+	if counts["nvmeSubsystems"] != 1 || counts["nvmeControllers"] != 1 {
+		t.Error("resource counts: expected 1 subsystem and 1 controller, received", counts)
+	}
+}
+
+func TestFrontEnd_RegisterStatsInstruments(t *testing.T) {
+	testEnv := createTestEnvironment([]string{
+		`{"jsonrpc":"2.0","id":%d,"result":{"status":0,` +
+			`"ctrlr_stats":[{"subnqn":"nqn.2022-09.io.spdk:opi3","ctrlr_id":17,"num_read_cmds":4,"num_read_bytes":5,"num_write_cmds":6,"num_write_bytes":7,"total_read_latency_in_us":9,"total_write_latency_in_us":10}],` +
+			`"ns_stats":[{"subnqn":"nqn.2022-09.io.spdk:opi3","ns_instance_id":22,"num_read_cmds":14,"num_read_bytes":15,"num_write_cmds":16,"num_write_bytes":17,"total_read_latency_in_us":19,"total_write_latency_in_us":20}]}}`,
+	})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+	testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+	testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+	testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	if _, err := testEnv.opiSpdkServer.RegisterStatsInstruments(provider.Meter("test")); err != nil {
+		t.Fatal(err)
+	}
+	var collected metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &collected); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(map[string]int64)
+	for _, scope := range collected.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok || !sum.IsMonotonic {
+				t.Error("expected a counter, received", m.Name, m.Data)
+				continue
+			}
+			for _, point := range sum.DataPoints {
+				received[m.Name+"{"+point.Attributes.Encoded(attribute.DefaultEncoder())+"}"] = point.Value
+			}
+		}
+	}
+	controller := "{controller=" + testControllerName + "}"
+	namespace := "{namespace=" + testNamespaceName + ",volume=" + testNamespace.Spec.VolumeNameRef + "}"
+	expected := map[string]int64{
+		"opi.nvme.controller.read.bytes" + controller:    5,
+		"opi.nvme.controller.read.ops" + controller:      4,
+		"opi.nvme.controller.write.bytes" + controller:   7,
+		"opi.nvme.controller.write.ops" + controller:     6,
+		"opi.nvme.controller.read.latency" + controller:  9,
+		"opi.nvme.controller.write.latency" + controller: 10,
+		"opi.nvme.namespace.read.bytes" + namespace:      15,
+		"opi.nvme.namespace.read.ops" + namespace:        14,
+		"opi.nvme.namespace.write.bytes" + namespace:     17,
+		"opi.nvme.namespace.write.ops" + namespace:       16,
+		"opi.nvme.namespace.read.latency" + namespace:    19,
+		"opi.nvme.namespace.write.latency" + namespace:   20,
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Error("metrics: expected", expected, "received", received)
+	}
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// isNvmeSubsystem tells whether a name is the one of an Nvme subsystem
+func isNvmeSubsystem(name string, _ string, id string) bool {
+	return name == utils.ResourceIDToSubsystemName(id)
+}
+
+// storedNvmeResources returns a page of the resources of a kind in the database, the ones of a
+// subsystem when subsysID isn't empty, for the List methods answering without the firmware.
+// The resources are sorted as the firmware lists them, the token of the next page is empty
+// when it is the last one
+func storedNvmeResources[T proto.Message](s *Server, kind func(name string, subsysID string, id string) bool, subsysID string, newResource func() T, sortResources func([]T), offset int, size int) ([]T, string, error) {
+	var resources []T
+	for _, name := range s.replayNames(kind) {
+		if subsysID != "" && utils.GetSubsystemIDFromNvmeName(name) != subsysID {
+			continue
+		}
+		resource := newResource()
+		found, err := s.store.Get(name, resource)
+		if err != nil {
+			return nil, "", err
+		}
+		if found {
+			resources = append(resources, resource)
+		}
+	}
+	sortResources(resources)
+	resources, hasMoreElements := utils.LimitPagination(resources, offset, size)
+	if !hasMoreElements {
+		return resources, "", nil
+	}
+	token := s.Pagination.NewToken(offset + size)
+	return resources, token, nil
+}
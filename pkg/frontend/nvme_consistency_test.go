@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/consistency"
+)
+
+func TestFrontEnd_CachedReadConsistency(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	// the firmware fails every call, the cached reads don't make any
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+	testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+	testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+	testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+	ctx := consistency.NewContext(testEnv.ctx, consistency.Cached)
+
+	subsystem, err := testEnv.opiSpdkServer.GetNvmeSubsystem(ctx, &pb.GetNvmeSubsystemRequest{Name: testSubsystemName})
+	if err != nil || !proto.Equal(subsystem, &testSubsystemWithStatus) {
+		t.Error("subsystem: expected", &testSubsystemWithStatus, "received", subsystem, err)
+	}
+	controller, err := testEnv.opiSpdkServer.GetNvmeController(ctx, &pb.GetNvmeControllerRequest{Name: testControllerName})
+	if err != nil || !proto.Equal(controller, &testControllerWithStatus) {
+		t.Error("controller: expected", &testControllerWithStatus, "received", controller, err)
+	}
+	namespace, err := testEnv.opiSpdkServer.GetNvmeNamespace(ctx, &pb.GetNvmeNamespaceRequest{Name: testNamespaceName})
+	if err != nil || !proto.Equal(namespace, &testNamespaceWithStatus) {
+		t.Error("namespace: expected", &testNamespaceWithStatus, "received", namespace, err)
+	}
+
+	subsystems, err := testEnv.opiSpdkServer.ListNvmeSubsystems(ctx, &pb.ListNvmeSubsystemsRequest{})
+	if err != nil || len(subsystems.GetNvmeSubsystems()) != 1 || subsystems.NvmeSubsystems[0].Name != testSubsystemName {
+		t.Error("subsystems: expected", testSubsystemName, "received", subsystems, err)
+	}
+	controllers, err := testEnv.opiSpdkServer.ListNvmeControllers(ctx, &pb.ListNvmeControllersRequest{Parent: testSubsystemName})
+	if err != nil || len(controllers.GetNvmeControllers()) != 1 || controllers.NvmeControllers[0].Name != testControllerName {
+		t.Error("controllers: expected", testControllerName, "received", controllers, err)
+	}
+	namespaces, err := testEnv.opiSpdkServer.ListNvmeNamespaces(ctx, &pb.ListNvmeNamespacesRequest{Parent: testSubsystemName, PageSize: 1})
+	if err != nil || len(namespaces.GetNvmeNamespaces()) != 1 || namespaces.NvmeNamespaces[0].Name != testNamespaceName || namespaces.NextPageToken != "" {
+		t.Error("namespaces: expected", testNamespaceName, "received", namespaces, err)
+	}
+
+	// the strong reads still verify with the firmware
+	if _, err := testEnv.opiSpdkServer.GetNvmeSubsystem(testEnv.ctx, &pb.GetNvmeSubsystemRequest{Name: testSubsystemName}); err == nil {
+		t.Error("strong read: expected the error of the firmware")
+	}
+}
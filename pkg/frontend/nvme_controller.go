@@ -9,16 +9,15 @@ package frontend
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"path"
 	"sort"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/consistency"
 	"github.com/opiproject/opi-marvell-bridge/pkg/models"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 
-	"github.com/google/uuid"
-	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
 	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
@@ -38,13 +37,14 @@ func sortNvmeControllers(controllers []*pb.NvmeController) {
 // CreateNvmeController creates an Nvme controller
 func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeControllerRequest) (*pb.NvmeController, error) {
 	// check input correctness
-	if err := s.validateCreateNvmeControllerRequest(in); err != nil {
+	warnings, err := s.validateCreateNvmeControllerRequest(in)
+	if err != nil {
 		return nil, err
 	}
 	// see https://google.aip.dev/133#user-specified-ids
 	resourceID := resourceid.NewSystemGenerated()
 	if in.NvmeControllerId != "" {
-		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NvmeControllerId, in.NvmeController.Name)
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.NvmeControllerId, "name", in.NvmeController.Name)
 		resourceID = in.NvmeControllerId
 	}
 	in.NvmeController.Name = utils.ResourceIDToControllerName(
@@ -57,7 +57,7 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 		return nil, err
 	}
 	if found {
-		log.Printf("Already existing NvmeController with id %v", in.NvmeController.Name)
+		slog.Info("Already existing NvmeController", "name", in.NvmeController.Name)
 		return controller, nil
 	}
 	// not found, so create a new one
@@ -70,6 +70,30 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Parent)
 		return nil, err
 	}
+	// the tenant owning the subsystem has to be under its quotas
+	if err := s.checkNvmeControllerQuotas(in.Parent); err != nil {
+		return nil, err
+	}
+	if err := s.checkSkuLimit(ctx, "nvmeControllers"); err != nil {
+		return nil, err
+	}
+	s.applyNvmeControllerDefaults(in.NvmeController)
+	if err := s.checkNvmeSubsystemLimits(in.Parent, in.NvmeController.Spec); err != nil {
+		return nil, err
+	}
+	if in.NvmeController.Spec.GetPcieId() == nil {
+		pcieID, err := s.allocatePcieFunction(in.Parent)
+		if err != nil {
+			return nil, err
+		}
+		in.NvmeController.Spec.Endpoint = &pb.NvmeControllerSpec_PcieId{PcieId: pcieID}
+	}
+	if err := s.checkPcieReservation(in.Parent, in.GetNvmeController().GetSpec().GetPcieId()); err != nil {
+		return nil, err
+	}
+	if err := s.checkSkuFunctionLimit(ctx, in.GetNvmeController().GetSpec().GetPcieId()); err != nil {
+		return nil, err
+	}
 
 	ctrlrID := autoCtrlrIDAllocation
 	if in.NvmeController.Spec.NvmeControllerId != nil {
@@ -85,15 +109,43 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 		MaxNcq:       int(in.GetNvmeController().GetSpec().GetMaxNcq()),
 		Mqes:         int(in.GetNvmeController().GetSpec().GetSqes()),
 	}
+	// apply the Marvell specific options set before the controller was created
+	options, found, err := s.getNvmeControllerOptions(in.NvmeController.Name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		params.ShadowDoorbell = boolToInt(options.ShadowDoorbell)
+		params.CmbSizeMib = int(options.CmbSizeMib)
+		params.PmrSizeMib = int(options.PmrSizeMib)
+		params.MsixVectors = int(options.MsixVectors)
+		params.BootNsid = int(options.BootNsid)
+		params.KatoMs = int(options.KeepAliveTimeoutMs)
+		if options.InterruptCoalescing != nil {
+			params.AggrThreshold = int(options.InterruptCoalescing.Threshold)
+			params.AggrTime = int(options.InterruptCoalescing.TimeUs / 100)
+		}
+		if options.Arbitration != nil {
+			params.AmsWrr = 1
+			params.ArbBurst = int(options.Arbitration.Burst)
+			params.ArbHpw = int(options.Arbitration.HighWeight)
+			params.ArbMpw = int(options.Arbitration.MediumWeight)
+			params.ArbLpw = int(options.Arbitration.LowWeight)
+		}
+	}
+	if params.MsixVectors > 0 {
+		if err := s.checkNvmeControllerMsixVectors(ctx, int32(params.MsixVectors), params.VfID != 0); err != nil {
+			return nil, err
+		}
+	}
 	var result models.MrvlNvmSubsysCreateCtrlrResult
 	err = s.rpc.Call(ctx, "mrvl_nvm_subsys_create_ctrlr", &params, &result)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not create CTRL: %s", in.NvmeController.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_create_ctrlr")
 	}
 	response := utils.ProtoClone(in.NvmeController)
 	response.Spec.NvmeControllerId = proto.Int32(int32(result.CtrlrID))
@@ -104,6 +156,10 @@ func (s *Server) CreateNvmeController(ctx context.Context, in *pb.CreateNvmeCont
 	if err != nil {
 		return nil, err
 	}
+	err = s.saveValidationWarnings(in.NvmeController.Name, warnings)
+	if err != nil {
+		return nil, err
+	}
 	return response, nil
 }
 
@@ -125,6 +181,10 @@ func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeCont
 		}
 		return nil, fmt.Errorf("error finding controller %s", in.Name)
 	}
+	// protected resources are only deleted once their protection is cleared
+	if err := s.checkNvmeDeletionProtection(in.Name); err != nil {
+		return nil, err
+	}
 	subsysName := utils.ResourceIDToSubsystemName(
 		utils.GetSubsystemIDFromNvmeName(in.Name),
 	)
@@ -149,10 +209,9 @@ func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeCont
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not delete CTRL: %s", controller.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_remove_ctrlr")
 	}
 	// remove from the Database
 	delete(s.ListHelper, controller.Name)
@@ -160,13 +219,27 @@ func (s *Server) DeleteNvmeController(ctx context.Context, in *pb.DeleteNvmeCont
 	if err != nil {
 		return nil, err
 	}
+	s.forgetClearedStats(controller.Name)
+	err = s.deleteNvmeControllerOptions(controller.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.deleteNvmeDeletionProtection(controller.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.saveValidationWarnings(controller.Name, nil)
+	if err != nil {
+		return nil, err
+	}
 	return &emptypb.Empty{}, nil
 }
 
 // UpdateNvmeController updates an Nvme controller
 func (s *Server) UpdateNvmeController(ctx context.Context, in *pb.UpdateNvmeControllerRequest) (*pb.NvmeController, error) {
 	// check input correctness
-	if err := s.validateUpdateNvmeControllerRequest(in); err != nil {
+	warnings, err := s.validateUpdateNvmeControllerRequest(in)
+	if err != nil {
 		return nil, err
 	}
 	// fetch object from the database
@@ -177,7 +250,7 @@ func (s *Server) UpdateNvmeController(ctx context.Context, in *pb.UpdateNvmeCont
 	}
 	if !found {
 		if in.AllowMissing {
-			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
+			slog.Debug("TODO: in case of AllowMissing, create a new resource, don;t return error")
 		}
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmeController.Name)
 		return nil, err
@@ -187,7 +260,7 @@ func (s *Server) UpdateNvmeController(ctx context.Context, in *pb.UpdateNvmeCont
 	if err := fieldmask.Validate(in.UpdateMask, in.NvmeController); err != nil {
 		return nil, err
 	}
-	log.Printf("TODO: use resourceID=%v", resourceID)
+	slog.Debug("TODO: use resourceID", "id", resourceID)
 	subsysName := utils.ResourceIDToSubsystemName(
 		utils.GetSubsystemIDFromNvmeName(in.NvmeController.Name),
 	)
@@ -221,29 +294,33 @@ func (s *Server) UpdateNvmeController(ctx context.Context, in *pb.UpdateNvmeCont
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not update CTRL: %s", in.NvmeController.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_update_ctrlr")
 	}
 	response := utils.ProtoClone(in.NvmeController)
 	response.Spec.NvmeControllerId = proto.Int32(int32(result.CtrlrID))
-	response.Status = &pb.NvmeControllerStatus{Active: true}
+	// a paused controller stays paused until it is resumed
+	response.Status = &pb.NvmeControllerStatus{Active: controller.GetStatus().GetActive()}
 	err = s.store.Set(in.NvmeController.Name, response)
 	if err != nil {
 		return nil, err
 	}
+	err = s.saveValidationWarnings(in.NvmeController.Name, warnings)
+	if err != nil {
+		return nil, err
+	}
 	return response, nil
 }
 
 // ListNvmeControllers lists Nvme controllers
 func (s *Server) ListNvmeControllers(ctx context.Context, in *pb.ListNvmeControllersRequest) (*pb.ListNvmeControllersResponse, error) {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+	// check input correctness
+	if err := s.validateListNvmeControllersRequest(in); err != nil {
 		return nil, err
 	}
 	// fetch object from the database
-	size, offset, perr := utils.ExtractPagination(in.PageSize, in.PageToken, s.Pagination)
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
 	if perr != nil {
 		return nil, perr
 	}
@@ -256,6 +333,13 @@ func (s *Server) ListNvmeControllers(ctx context.Context, in *pb.ListNvmeControl
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Parent)
 		return nil, err
 	}
+	if consistency.FromContext(ctx) == consistency.Cached {
+		controllers, token, err := storedNvmeResources(s, isNvmeController, path.Base(in.Parent), func() *pb.NvmeController { return new(pb.NvmeController) }, sortNvmeControllers, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ListNvmeControllersResponse{NvmeControllers: controllers, NextPageToken: token}, nil
+	}
 	params := models.MrvlNvmSubsysGetCtrlrListParams{
 		Subnqn: subsys.Spec.Nqn,
 	}
@@ -264,17 +348,15 @@ func (s *Server) ListNvmeControllers(ctx context.Context, in *pb.ListNvmeControl
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not list CTRLs: %v", in.Parent)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_get_ctrlr_list")
 	}
 	token, hasMoreElements := "", false
-	log.Printf("Limiting result len(%d) to [%d:%d]", len(result.CtrlrIDList), offset, size)
+	slog.Debug("Limiting result", "len", len(result.CtrlrIDList), "offset", offset, "size", size)
 	result.CtrlrIDList, hasMoreElements = utils.LimitPagination(result.CtrlrIDList, offset, size)
 	if hasMoreElements {
-		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		token = s.Pagination.NewToken(offset + size)
 	}
 	Blobarray := make([]*pb.NvmeController, len(result.CtrlrIDList))
 	for i := range result.CtrlrIDList {
@@ -282,7 +364,7 @@ func (s *Server) ListNvmeControllers(ctx context.Context, in *pb.ListNvmeControl
 		Blobarray[i] = &pb.NvmeController{Spec: &pb.NvmeControllerSpec{NvmeControllerId: proto.Int32(int32(r.CtrlrID))}}
 	}
 	sortNvmeControllers(Blobarray)
-	return &pb.ListNvmeControllersResponse{NvmeControllers: Blobarray}, nil
+	return &pb.ListNvmeControllersResponse{NvmeControllers: Blobarray, NextPageToken: token}, nil
 }
 
 // GetNvmeController gets an Nvme controller
@@ -313,6 +395,9 @@ func (s *Server) GetNvmeController(ctx context.Context, in *pb.GetNvmeController
 		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
 		return nil, err
 	}
+	if consistency.FromContext(ctx) == consistency.Cached {
+		return controller, nil
+	}
 
 	params := models.MrvlNvmGetCtrlrInfoParams{
 		Subnqn:  subsys.Spec.Nqn,
@@ -323,13 +408,13 @@ func (s *Server) GetNvmeController(ctx context.Context, in *pb.GetNvmeController
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not get CTRL: %s", in.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_get_info")
 	}
 
-	return &pb.NvmeController{Name: in.Name, Spec: &pb.NvmeControllerSpec{NvmeControllerId: controller.Spec.NvmeControllerId}, Status: &pb.NvmeControllerStatus{Active: true}}, nil
+	// the controller is returned as created, so its spec round-trips
+	return controller, nil
 }
 
 // StatsNvmeController gets an Nvme controller stats
@@ -370,17 +455,43 @@ func (s *Server) StatsNvmeController(ctx context.Context, in *pb.StatsNvmeContro
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not stats CTRL: %s", in.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_ctrlr_stats")
 	}
-	return &pb.StatsNvmeControllerResponse{Stats: &pb.VolumeStats{
+	stats := &pb.VolumeStats{
 		ReadBytesCount:    int32(result.NumReadBytes),
 		ReadOpsCount:      int32(result.NumReadCmds),
 		WriteBytesCount:   int32(result.NumWriteBytes),
 		WriteOpsCount:     int32(result.NumWriteCmds),
 		ReadLatencyTicks:  int32(result.TotalReadLatencyInUs),
 		WriteLatencyTicks: int32(result.TotalWriteLatencyInUs),
-	}}, nil
+	}
+	return &pb.StatsNvmeControllerResponse{Stats: s.statsSinceCleared(in.Name, stats)}, nil
+}
+
+// getNvmeControllerAndSubsystem fetches an Nvme controller and its parent subsystem from the database
+func (s *Server) getNvmeControllerAndSubsystem(name string) (*pb.NvmeController, *pb.NvmeSubsystem, error) {
+	controller := new(pb.NvmeController)
+	found, err := s.store.Get(name, controller)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+		return nil, nil, err
+	}
+	subsysName := utils.ResourceIDToSubsystemName(
+		utils.GetSubsystemIDFromNvmeName(name),
+	)
+	subsys := new(pb.NvmeSubsystem)
+	found, err = s.store.Get(subsysName, subsys)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
+		return nil, nil, err
+	}
+	return controller, subsys, nil
 }
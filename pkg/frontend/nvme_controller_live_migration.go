@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NvmeControllerDeviceState represents the device state of an Nvme controller, its queues, its
+// features and the accounting of its commands, as the firmware saved it. The state itself is
+// opaque, it is only restored by firmwares supporting its version
+type NvmeControllerDeviceState struct {
+	// Name of the Nvme controller the state was saved from
+	Name string `json:"name"`
+	// Function the controller was emulated on
+	Function PcieFunction `json:"function"`
+	// Version of the format of the state
+	Version int32 `json:"version"`
+	// State is the device state encoded by the firmware
+	State string `json:"state"`
+	// SubmissionQueues is the number of I/O submission queues of the state
+	SubmissionQueues int32 `json:"submissionQueues"`
+	// CompletionQueues is the number of I/O completion queues of the state
+	CompletionQueues int32 `json:"completionQueues"`
+	// InflightCommands is the number of commands fetched and not completed when the state was
+	// saved, they are completed by the restored controller
+	InflightCommands int32 `json:"inflightCommands"`
+}
+
+// SaveNvmeControllerStateRequest represents a request to save the device state of a paused Nvme
+// controller
+type SaveNvmeControllerStateRequest struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+}
+
+// RestoreNvmeControllerStateRequest represents a request to restore a device state on a paused
+// Nvme controller
+type RestoreNvmeControllerStateRequest struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+	// State saved from the source controller
+	State *NvmeControllerDeviceState `json:"state"`
+}
+
+// SaveNvmeControllerState saves the device state of an Nvme controller, for the live migration
+// of the VM it is assigned to. The controller has to be paused first, and is resumed on the
+// source when the migration is cancelled
+func (s *Server) SaveNvmeControllerState(ctx context.Context, in *SaveNvmeControllerStateRequest) (*NvmeControllerDeviceState, error) {
+	// check input correctness
+	if err := s.validateSaveNvmeControllerStateRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, subsys, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNvmeControllerPaused(controller, "saved"); err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmCtrlrSaveStateParams{
+		Subnqn:  subsys.Spec.Nqn,
+		CtrlrID: int(*controller.Spec.NvmeControllerId),
+	}
+	var result models.MrvlNvmCtrlrSaveStateResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_save_state", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not save state of CTRL: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_save_state")
+	}
+	return &NvmeControllerDeviceState{
+		Name:             in.Name,
+		Function:         pcieFunctionOf(controller.GetSpec().GetPcieId()),
+		Version:          int32(result.StateVersion),
+		State:            result.State,
+		SubmissionQueues: int32(result.NumSqs),
+		CompletionQueues: int32(result.NumCqs),
+		InflightCommands: int32(result.InflightCmds),
+	}, nil
+}
+
+// RestoreNvmeControllerState restores the device state saved from another controller, on this
+// DPU or another one, on a paused Nvme controller, which is resumed once the VM runs on its
+// destination
+func (s *Server) RestoreNvmeControllerState(ctx context.Context, in *RestoreNvmeControllerStateRequest) (*pb.NvmeController, error) {
+	// check input correctness
+	if err := s.validateRestoreNvmeControllerStateRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, subsys, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNvmeControllerPaused(controller, "restored"); err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmCtrlrRestoreStateParams{
+		Subnqn:       subsys.Spec.Nqn,
+		CtrlrID:      int(*controller.Spec.NvmeControllerId),
+		StateVersion: int(in.State.Version),
+		State:        in.State.State,
+	}
+	var result models.MrvlNvmCtrlrRestoreStateResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_restore_state", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not restore state of CTRL: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_restore_state")
+	}
+	return controller, nil
+}
+
+// checkNvmeControllerPaused checks the state of an Nvme controller can be saved or restored, it
+// doesn't fetch host commands
+func checkNvmeControllerPaused(controller *pb.NvmeController, action string) error {
+	if controller.GetStatus().GetActive() {
+		msg := fmt.Sprintf("Controller %s has to be paused before its state is %s", controller.Name, action)
+		return status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return nil
+}
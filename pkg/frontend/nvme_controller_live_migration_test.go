@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+var testControllerPaused = pb.NvmeController{
+	Name:   testControllerName,
+	Spec:   testController.Spec,
+	Status: &pb.NvmeControllerStatus{Active: false},
+}
+
+func TestFrontEnd_SaveNvmeControllerState(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in         *SaveNvmeControllerStateRequest
+		controller *pb.NvmeController
+		out        *NvmeControllerDeviceState
+		spdk       []string
+		errCode    codes.Code
+		errMsg     string
+	}{
+		"valid request with invalid SPDK response": {
+			in:         &SaveNvmeControllerStateRequest{Name: testControllerName},
+			controller: &testControllerPaused,
+			out:        nil,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("Could not save state of CTRL: %v", testControllerName),
+		},
+		"valid request with error code from SPDK response": {
+			in:         &SaveNvmeControllerStateRequest{Name: testControllerName},
+			controller: &testControllerPaused,
+			out:        nil,
+			spdk:       []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode:    codes.Unknown,
+			errMsg:     fmt.Sprintf("mrvl_nvm_ctrlr_save_state: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:         &SaveNvmeControllerStateRequest{Name: testControllerName},
+			controller: &testControllerPaused,
+			out: &NvmeControllerDeviceState{
+				Name:             testControllerName,
+				Function:         PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 2},
+				Version:          1,
+				State:            "c3RhdGU=",
+				SubmissionQueues: 4,
+				CompletionQueues: 4,
+				InflightCommands: 2,
+			},
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state_version": 1, "state": "c3RhdGU=",` +
+				`"num_sqs": 4, "num_cqs": 4, "inflight_cmds": 2}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"active controller": {
+			in:         &SaveNvmeControllerStateRequest{Name: testControllerName},
+			controller: &testControllerWithStatus,
+			out:        nil,
+			spdk:       []string{},
+			errCode:    codes.FailedPrecondition,
+			errMsg:     fmt.Sprintf("Controller %v has to be paused before its state is saved", testControllerName),
+		},
+		"valid request with unknown key": {
+			in:         &SaveNvmeControllerStateRequest{Name: utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")},
+			controller: &testControllerPaused,
+			out:        nil,
+			spdk:       []string{},
+			errCode:    codes.NotFound,
+			errMsg:     fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, tt.controller)
+
+			response, err := testEnv.opiSpdkServer.SaveNvmeControllerState(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_RestoreNvmeControllerState(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testState := &NvmeControllerDeviceState{Name: testControllerName, Version: 1, State: "c3RhdGU="}
+	tests := map[string]struct {
+		in         *RestoreNvmeControllerStateRequest
+		controller *pb.NvmeController
+		out        *pb.NvmeController
+		spdk       []string
+		errCode    codes.Code
+		errMsg     string
+	}{
+		"valid request with invalid SPDK response": {
+			in:         &RestoreNvmeControllerStateRequest{Name: testControllerName, State: testState},
+			controller: &testControllerPaused,
+			out:        nil,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode:    codes.InvalidArgument,
+			errMsg:     fmt.Sprintf("Could not restore state of CTRL: %v", testControllerName),
+		},
+		"valid request with valid SPDK response": {
+			in:         &RestoreNvmeControllerStateRequest{Name: testControllerName, State: testState},
+			controller: &testControllerPaused,
+			out:        &testControllerPaused,
+			spdk:       []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode:    codes.OK,
+			errMsg:     "",
+		},
+		"active controller": {
+			in:         &RestoreNvmeControllerStateRequest{Name: testControllerName, State: testState},
+			controller: &testControllerWithStatus,
+			out:        nil,
+			spdk:       []string{},
+			errCode:    codes.FailedPrecondition,
+			errMsg:     fmt.Sprintf("Controller %v has to be paused before its state is restored", testControllerName),
+		},
+		"no required state field": {
+			in:         &RestoreNvmeControllerStateRequest{Name: testControllerName},
+			controller: &testControllerPaused,
+			out:        nil,
+			spdk:       []string{},
+			errCode:    codes.InvalidArgument,
+			errMsg:     "missing required field: state",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, tt.controller)
+
+			response, err := testEnv.opiSpdkServer.RestoreNvmeControllerState(testEnv.ctx, tt.in)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// NvmeControllerOptions represents the Marvell specific options of an Nvme controller
+// which are not part of the OPI NvmeControllerSpec. Options can be set before the
+// controller is created (with a user specified id) and are applied on creation
+type NvmeControllerOptions struct {
+	// ShadowDoorbell advertises the Doorbell Buffer Config command, letting
+	// para-virtualized guests write doorbells to memory instead of trapping on MMIO
+	ShadowDoorbell bool `json:"shadowDoorbell"`
+	// CmbSizeMib is the size of the Controller Memory Buffer, 0 disables it.
+	// Can only be set before the controller is created
+	CmbSizeMib int32 `json:"cmbSizeMib"`
+	// PmrSizeMib is the size of the Persistent Memory Region, 0 disables it.
+	// Can only be set before the controller is created
+	PmrSizeMib int32 `json:"pmrSizeMib"`
+	// MsixVectors is the number of MSI-X vectors advertised by the function, 0 keeps the
+	// firmware default. Can only be set before the controller is created
+	MsixVectors int32 `json:"msixVectors"`
+	// InterruptCoalescing sets the Interrupt Coalescing feature, nil disables coalescing
+	InterruptCoalescing *NvmeInterruptCoalescing `json:"interruptCoalescing,omitempty"`
+	// Arbitration advertises Weighted Round Robin with Urgent Priority Class arbitration and
+	// sets its weights, nil keeps round robin. WRR can only be enabled before the controller
+	// is created while the weights can be changed afterwards
+	Arbitration *NvmeArbitration `json:"arbitration,omitempty"`
+	// BootNsid exposes the option ROM so the host firmware can boot from the namespace with
+	// this host NSID, 0 disables it. Boot can only be enabled before the controller is created
+	// while the boot namespace can be changed afterwards
+	BootNsid int32 `json:"bootNsid"`
+	// KeepAliveTimeoutMs is the Keep Alive Timeout advertised to the host, in 100 milliseconds
+	// increments, 0 keeps the firmware default. Some host drivers reset the controller when
+	// their keep alive commands don't fit the default timeout
+	KeepAliveTimeoutMs int32 `json:"keepAliveTimeoutMs"`
+	// Status is reported by the firmware for existing controllers, output only
+	Status *NvmeControllerOptionsStatus `json:"status,omitempty"`
+}
+
+// NvmeControllerOptionsStatus represents the options in effect on an Nvme controller
+type NvmeControllerOptionsStatus struct {
+	// CmbSizeMib is the size of the exposed Controller Memory Buffer, 0 if not supported by the hardware
+	CmbSizeMib int32 `json:"cmbSizeMib"`
+	// PmrSizeMib is the size of the exposed Persistent Memory Region, 0 if not supported by the hardware
+	PmrSizeMib int32 `json:"pmrSizeMib"`
+	// MsixVectors is the number of MSI-X vectors advertised by the function
+	MsixVectors int32 `json:"msixVectors"`
+	// InterruptCoalescing is the Interrupt Coalescing feature in effect
+	InterruptCoalescing *NvmeInterruptCoalescing `json:"interruptCoalescing,omitempty"`
+	// WeightedRoundRobin is set when the controller advertises WRR arbitration
+	WeightedRoundRobin bool `json:"weightedRoundRobin"`
+	// Arbitration is the Arbitration feature in effect
+	Arbitration *NvmeArbitration `json:"arbitration,omitempty"`
+	// BootNsid is the host NSID of the namespace exposed through the option ROM, 0 if none
+	BootNsid int32 `json:"bootNsid"`
+	// KeepAliveTimeoutMs is the Keep Alive Timeout in effect, as set by the host or the firmware default
+	KeepAliveTimeoutMs int32 `json:"keepAliveTimeoutMs"`
+}
+
+// NvmeInterruptCoalescing represents the Interrupt Coalescing feature (Feature Identifier 08h),
+// latency sensitive tenants keep it disabled while throughput oriented ones aggregate completions
+type NvmeInterruptCoalescing struct {
+	// Threshold is the number of completion queue entries to aggregate per interrupt vector, 0's based
+	Threshold int32 `json:"threshold"`
+	// TimeUs is the maximum time an interrupt is delayed, in 100 microseconds increments
+	TimeUs int32 `json:"timeUs"`
+}
+
+// NvmeArbitration represents the Arbitration feature (Feature Identifier 01h), the weights
+// are the number of commands fetched from the submission queues of each priority class
+type NvmeArbitration struct {
+	// Burst is the number of commands fetched at once from a submission queue, as a power of 2
+	Burst int32 `json:"burst"`
+	// HighWeight is the weight of the high priority class, 0's based
+	HighWeight int32 `json:"highWeight"`
+	// MediumWeight is the weight of the medium priority class, 0's based
+	MediumWeight int32 `json:"mediumWeight"`
+	// LowWeight is the weight of the low priority class, 0's based
+	LowWeight int32 `json:"lowWeight"`
+}
+
+// GetNvmeControllerOptionsRequest represents a request to get the options of an Nvme controller
+type GetNvmeControllerOptionsRequest struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+}
+
+// UpdateNvmeControllerOptionsRequest represents a request to update the options of an Nvme controller
+type UpdateNvmeControllerOptionsRequest struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+	// Options to set on the Nvme controller
+	Options *NvmeControllerOptions `json:"options"`
+}
+
+// GetNvmeControllerOptions gets the Marvell specific options of an Nvme controller
+func (s *Server) GetNvmeControllerOptions(ctx context.Context, in *GetNvmeControllerOptionsRequest) (*NvmeControllerOptions, error) {
+	// check input correctness
+	if err := s.validateGetNvmeControllerOptionsRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	options, found, err := s.getNvmeControllerOptions(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	// report what the firmware exposes once the controller exists
+	controller := new(pb.NvmeController)
+	found, err = s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		options.Status, err = s.getNvmeControllerOptionsStatus(ctx, in.Name, controller)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// UpdateNvmeControllerOptions sets the Marvell specific options of an Nvme controller, on an existing
+// controller interrupt coalescing applies immediately and the other options at the next controller reset
+func (s *Server) UpdateNvmeControllerOptions(ctx context.Context, in *UpdateNvmeControllerOptionsRequest) (*NvmeControllerOptions, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeControllerOptionsRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller := new(pb.NvmeController)
+	found, err := s.store.Get(in.Name, controller)
+	if err != nil {
+		return nil, err
+	}
+	options := *in.Options
+	options.Status = nil
+	if found {
+		saved, _, err := s.getNvmeControllerOptions(in.Name)
+		if err != nil {
+			return nil, err
+		}
+		if saved == nil {
+			saved = new(NvmeControllerOptions)
+		}
+		if option := changedCreateOnlyOption(saved, &options); option != "" {
+			msg := fmt.Sprintf("%s option of CTRL %s can only be set before it is created", option, in.Name)
+			return nil, status.Errorf(codes.FailedPrecondition, msg)
+		}
+		if err := s.setNvmeControllerOptions(ctx, in.Name, controller, &options); err != nil {
+			return nil, err
+		}
+	} else {
+		slog.Info("NvmeController doesn't exist yet, options are applied on creation", "name", in.Name)
+	}
+	// save object to the database
+	if err := s.saveNvmeControllerOptions(in.Name, &options); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+func (s *Server) setNvmeControllerOptions(ctx context.Context, name string, controller *pb.NvmeController, options *NvmeControllerOptions) error {
+	subsysName := utils.ResourceIDToSubsystemName(
+		utils.GetSubsystemIDFromNvmeName(name),
+	)
+	// fetch object from the database
+	subsys := new(pb.NvmeSubsystem)
+	found, err := s.store.Get(subsysName, subsys)
+	if err != nil {
+		return err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
+		return err
+	}
+	params := models.MrvlNvmCtrlrSetOptionsParams{
+		Subnqn:         subsys.Spec.Nqn,
+		CtrlrID:        int(*controller.Spec.NvmeControllerId),
+		ShadowDoorbell: boolToInt(options.ShadowDoorbell),
+		BootNsid:       int(options.BootNsid),
+		KatoMs:         int(options.KeepAliveTimeoutMs),
+	}
+	if options.InterruptCoalescing != nil {
+		params.AggrThreshold = int(options.InterruptCoalescing.Threshold)
+		params.AggrTime = int(options.InterruptCoalescing.TimeUs / 100)
+	}
+	if options.Arbitration != nil {
+		params.ArbBurst = int(options.Arbitration.Burst)
+		params.ArbHpw = int(options.Arbitration.HighWeight)
+		params.ArbMpw = int(options.Arbitration.MediumWeight)
+		params.ArbLpw = int(options.Arbitration.LowWeight)
+	}
+	var result models.MrvlNvmCtrlrSetOptionsResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_set_options", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not set options of CTRL: %s", name)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_set_options")
+	}
+	return nil
+}
+
+func (s *Server) getNvmeControllerOptionsStatus(ctx context.Context, name string, controller *pb.NvmeController) (*NvmeControllerOptionsStatus, error) {
+	subsysName := utils.ResourceIDToSubsystemName(
+		utils.GetSubsystemIDFromNvmeName(name),
+	)
+	// fetch object from the database
+	subsys := new(pb.NvmeSubsystem)
+	found, err := s.store.Get(subsysName, subsys)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
+		return nil, err
+	}
+	params := models.MrvlNvmGetCtrlrInfoParams{
+		Subnqn:  subsys.Spec.Nqn,
+		CtrlrID: int(*controller.Spec.NvmeControllerId),
+	}
+	var result models.MrvlNvmGetCtrlrInfoResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_get_info", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get CTRL: %s", name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_get_info")
+	}
+	return &NvmeControllerOptionsStatus{
+		CmbSizeMib:  int32(result.CmbSizeMib),
+		PmrSizeMib:  int32(result.PmrSizeMib),
+		MsixVectors: int32(result.MsixVectors),
+		InterruptCoalescing: &NvmeInterruptCoalescing{
+			Threshold: int32(result.AggrThreshold),
+			TimeUs:    int32(result.AggrTime * 100),
+		},
+		WeightedRoundRobin: result.AmsWrr != 0,
+		Arbitration: &NvmeArbitration{
+			Burst:        int32(result.ArbBurst),
+			HighWeight:   int32(result.ArbHpw),
+			MediumWeight: int32(result.ArbMpw),
+			LowWeight:    int32(result.ArbLpw),
+		},
+		BootNsid:           int32(result.BootNsid),
+		KeepAliveTimeoutMs: int32(result.KatoMs),
+	}, nil
+}
+
+// checkNvmeControllerMsixVectors checks the MSI-X vectors requested for a function
+// against the limits of the DPU SKU, which differ between physical and virtual functions
+func (s *Server) checkNvmeControllerMsixVectors(ctx context.Context, msixVectors int32, virtualFunction bool) error {
+	var result models.MrvlNvmGetSkuCapsResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_get_sku_caps", nil, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := "Could not get SKU capabilities"
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_sku_caps")
+	}
+	limit, function := result.MaxPfMsixVectors, "physical"
+	if virtualFunction {
+		limit, function = result.MaxVfMsixVectors, "virtual"
+	}
+	if int(msixVectors) > limit {
+		msg := fmt.Sprintf("MsixVectors value (%d) exceeds the SKU limit of %d for %s functions", msixVectors, limit, function)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// changedCreateOnlyOption returns the name of the first option which differs and can't change
+// once the host enumerated the function, as it is sized in the BARs or advertised in CAP
+func changedCreateOnlyOption(saved *NvmeControllerOptions, options *NvmeControllerOptions) string {
+	switch {
+	case saved.CmbSizeMib != options.CmbSizeMib:
+		return "CmbSizeMib"
+	case saved.PmrSizeMib != options.PmrSizeMib:
+		return "PmrSizeMib"
+	case saved.MsixVectors != options.MsixVectors:
+		return "MsixVectors"
+	case (saved.Arbitration == nil) != (options.Arbitration == nil):
+		return "Arbitration"
+	case (saved.BootNsid == 0) != (options.BootNsid == 0):
+		return "BootNsid"
+	}
+	return ""
+}
+
+// nvmeControllerOptionsKey is the database key of the options of an Nvme controller
+func nvmeControllerOptionsKey(name string) string {
+	return name + "/options"
+}
+
+// getNvmeControllerOptions fetches the options of an Nvme controller from the database,
+// options are not protobufs so they are stored JSON encoded
+func (s *Server) getNvmeControllerOptions(name string) (*NvmeControllerOptions, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(nvmeControllerOptionsKey(name), value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	options := new(NvmeControllerOptions)
+	if err := json.Unmarshal(value.Value, options); err != nil {
+		return nil, false, err
+	}
+	return options, true, nil
+}
+
+func (s *Server) saveNvmeControllerOptions(name string, options *NvmeControllerOptions) error {
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(nvmeControllerOptionsKey(name), wrapperspb.Bytes(data))
+}
+
+func (s *Server) deleteNvmeControllerOptions(name string) error {
+	return s.store.Delete(nvmeControllerOptionsKey(name))
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
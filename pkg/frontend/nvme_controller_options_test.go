@@ -0,0 +1,339 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_UpdateNvmeControllerOptions(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testNewControllerName := utils.ResourceIDToControllerName(testSubsystemID, "new-controller-id")
+	tests := map[string]struct {
+		in      *UpdateNvmeControllerOptionsRequest
+		out     *NvmeControllerOptions
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{ShadowDoorbell: true}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not set options of CTRL: %v", testControllerName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{ShadowDoorbell: true}},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_set_options: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{ShadowDoorbell: true}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_set_options: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{ShadowDoorbell: true}},
+			out:     &NvmeControllerOptions{ShadowDoorbell: true},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with interrupt coalescing": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{InterruptCoalescing: &NvmeInterruptCoalescing{Threshold: 7, TimeUs: 200}}},
+			out:     &NvmeControllerOptions{InterruptCoalescing: &NvmeInterruptCoalescing{Threshold: 7, TimeUs: 200}},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"interrupt coalescing threshold out of range": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{InterruptCoalescing: &NvmeInterruptCoalescing{Threshold: 256}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Threshold value (256) is out of range, have to be between 0 and 255",
+		},
+		"interrupt coalescing time not a multiple of 100": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{InterruptCoalescing: &NvmeInterruptCoalescing{TimeUs: 150}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "TimeUs value (150) have to be a multiple of 100 between 0 and 25500",
+		},
+		"valid request for a controller not created yet": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{ShadowDoorbell: true}},
+			out:     &NvmeControllerOptions{ShadowDoorbell: true},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with CMB and PMR for a controller not created yet": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{CmbSizeMib: 64, PmrSizeMib: 128, Status: &NvmeControllerOptionsStatus{CmbSizeMib: 1}}},
+			out:     &NvmeControllerOptions{CmbSizeMib: 64, PmrSizeMib: 128},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"CMB change on existing controller": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{CmbSizeMib: 64}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("%v option of CTRL %v can only be set before it is created", "CmbSizeMib", testControllerName),
+		},
+		"MSI-X vectors change on existing controller": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{MsixVectors: 8}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("%v option of CTRL %v can only be set before it is created", "MsixVectors", testControllerName),
+		},
+		"enabling WRR on existing controller": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{Arbitration: &NvmeArbitration{Burst: 3}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("%v option of CTRL %v can only be set before it is created", "Arbitration", testControllerName),
+		},
+		"enabling boot on existing controller": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{BootNsid: 22}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("%v option of CTRL %v can only be set before it is created", "BootNsid", testControllerName),
+		},
+		"valid request with boot for a controller not created yet": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{BootNsid: 22}},
+			out:     &NvmeControllerOptions{BootNsid: 22},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"negative boot NSID": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{BootNsid: -1}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "BootNsid value (-1) have to be positive or 0",
+		},
+		"keep alive timeout on existing controller": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName, Options: &NvmeControllerOptions{KeepAliveTimeoutMs: 30000}},
+			out:     &NvmeControllerOptions{KeepAliveTimeoutMs: 30000},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"keep alive timeout not a multiple of 100": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{KeepAliveTimeoutMs: 1050}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "KeepAliveTimeoutMs value (1050) have to be a multiple of 100 between 0 and 6553500",
+		},
+		"arbitration burst out of range": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{Arbitration: &NvmeArbitration{Burst: 8}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Burst value (8) is out of range, have to be between 0 and 7",
+		},
+		"arbitration weight out of range": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{Arbitration: &NvmeArbitration{MediumWeight: 300}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Weight value (300) is out of range, have to be between 0 and 255",
+		},
+		"MSI-X vectors out of range": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{MsixVectors: 4096}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "MsixVectors value (4096) is out of range, have to be between 0 and 2048",
+		},
+		"CMB size not a power of 2": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{CmbSizeMib: 48}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "CmbSizeMib value (48) have to be 0 or a power of 2",
+		},
+		"negative PMR size": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testNewControllerName, Options: &NvmeControllerOptions{PmrSizeMib: -1}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "PmrSizeMib value (-1) have to be 0 or a power of 2",
+		},
+		"malformed name": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: "-ABC-DEF", Options: &NvmeControllerOptions{}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required options field": {
+			in:      &UpdateNvmeControllerOptionsRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: options",
+		},
+		"no required name field": {
+			in:      &UpdateNvmeControllerOptionsRequest{Options: &NvmeControllerOptions{}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+
+			response, err := testEnv.opiSpdkServer.UpdateNvmeControllerOptions(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			// options are only saved once applied
+			if tt.errCode == codes.OK {
+				options, found, _ := testEnv.opiSpdkServer.getNvmeControllerOptions(tt.in.Name)
+				if !found || !reflect.DeepEqual(options, tt.out) {
+					t.Error("saved options: expected", tt.out, "received", options)
+				}
+			}
+		})
+	}
+}
+
+func TestFrontEnd_GetNvmeControllerOptions(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testNewControllerName := utils.ResourceIDToControllerName(testSubsystemID, "new-controller-id")
+	tests := map[string]struct {
+		in      *GetNvmeControllerOptionsRequest
+		out     *NvmeControllerOptions
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &GetNvmeControllerOptionsRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get CTRL: %v", testControllerName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &GetNvmeControllerOptionsRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_get_info: %v", "EOF"),
+		},
+		"valid request with valid SPDK response": {
+			in: &GetNvmeControllerOptionsRequest{Name: testControllerName},
+			out: &NvmeControllerOptions{
+				ShadowDoorbell: true,
+				CmbSizeMib:     64,
+				Status: &NvmeControllerOptionsStatus{
+					CmbSizeMib:          64,
+					PmrSizeMib:          0,
+					MsixVectors:         33,
+					InterruptCoalescing: &NvmeInterruptCoalescing{Threshold: 7, TimeUs: 200},
+					WeightedRoundRobin:  true,
+					Arbitration:         &NvmeArbitration{Burst: 3, HighWeight: 15, MediumWeight: 7, LowWeight: 1},
+					BootNsid:            22,
+					KeepAliveTimeoutMs:  120000,
+				},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 17, "cmb_size_mib": 64, "pmr_size_mib": 0, "msix_vectors": 33, "aggr_threshold": 7, "aggr_time": 2, "ams_wrr": 1, "arb_burst": 3, "arb_hpw": 15, "arb_mpw": 7, "arb_lpw": 1, "boot_nsid": 22, "kato_ms": 120000}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request for a controller not created yet": {
+			in:      &GetNvmeControllerOptionsRequest{Name: testNewControllerName},
+			out:     &NvmeControllerOptions{PmrSizeMib: 128},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &GetNvmeControllerOptionsRequest{Name: utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+		},
+		"malformed name": {
+			in:      &GetNvmeControllerOptionsRequest{Name: "-ABC-DEF"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &GetNvmeControllerOptionsRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.saveNvmeControllerOptions(testControllerName, &NvmeControllerOptions{ShadowDoorbell: true, CmbSizeMib: 64})
+			_ = testEnv.opiSpdkServer.saveNvmeControllerOptions(testNewControllerName, &NvmeControllerOptions{PmrSizeMib: 128})
+
+			response, err := testEnv.opiSpdkServer.GetNvmeControllerOptions(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetNvmeControllerPcieStatusRequest represents a request to get the PCIe status of the function
+// of an Nvme controller
+type GetNvmeControllerPcieStatusRequest struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+}
+
+// NvmeControllerPcieStatus represents the PCIe link and the AER error counters of the function an
+// Nvme controller is emulated on, as the host sees them
+type NvmeControllerPcieStatus struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+	// Function the controller is emulated on
+	Function PcieFunction `json:"function"`
+	// LinkSpeedGtps is the negotiated speed of the link, in GT/s
+	LinkSpeedGtps float64 `json:"linkSpeedGtps"`
+	// LinkWidth is the negotiated number of lanes of the link
+	LinkWidth int32 `json:"linkWidth"`
+	// MaxLinkSpeedGtps is the speed the link is capable of, in GT/s
+	MaxLinkSpeedGtps float64 `json:"maxLinkSpeedGtps"`
+	// MaxLinkWidth is the number of lanes the link is capable of
+	MaxLinkWidth int32 `json:"maxLinkWidth"`
+	// Degraded tells the link trained below its speed or its width, i.e. on a bad riser or slot
+	Degraded bool `json:"degraded"`
+	// CorrectableErrors is the number of correctable errors reported by AER
+	CorrectableErrors uint64 `json:"correctableErrors"`
+	// UncorrectableNonFatalErrors is the number of non-fatal uncorrectable errors reported by AER
+	UncorrectableNonFatalErrors uint64 `json:"uncorrectableNonFatalErrors"`
+	// UncorrectableFatalErrors is the number of fatal uncorrectable errors reported by AER
+	UncorrectableFatalErrors uint64 `json:"uncorrectableFatalErrors"`
+	// LastUncorrectableStatus is the AER uncorrectable error status register of the last
+	// uncorrectable error, 0 when there was none
+	LastUncorrectableStatus uint32 `json:"lastUncorrectableStatus"`
+}
+
+// GetNvmeControllerPcieStatus gets the link speed and width and the AER error counters of the
+// PCIe function of an Nvme controller from the firmware, to diagnose the flaky functions the
+// hosts see without access to the hosts
+func (s *Server) GetNvmeControllerPcieStatus(ctx context.Context, in *GetNvmeControllerPcieStatusRequest) (*NvmeControllerPcieStatus, error) {
+	// check input correctness
+	if err := s.validateGetNvmeControllerPcieStatusRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, _, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	pcieID := controller.GetSpec().GetPcieId()
+	if pcieID == nil {
+		msg := fmt.Sprintf("Controller %s is not on a PCIe function", in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	function := pcieFunctionOf(pcieID)
+	params := models.MrvlNvmGetPcieFuncStatusParams{
+		PcieDomainID: int(function.PortID),
+		PfID:         int(function.PhysicalFunction),
+		VfID:         int(function.VirtualFunction),
+	}
+	var result models.MrvlNvmGetPcieFuncStatusResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_get_pcie_func_status", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get PCIe status of CTRL: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_pcie_func_status")
+	}
+	return &NvmeControllerPcieStatus{
+		Name:                        in.Name,
+		Function:                    function,
+		LinkSpeedGtps:               result.LinkSpeedGtps,
+		LinkWidth:                   int32(result.LinkWidth),
+		MaxLinkSpeedGtps:            result.MaxLinkSpeedGtps,
+		MaxLinkWidth:                int32(result.MaxLinkWidth),
+		Degraded:                    result.LinkSpeedGtps < result.MaxLinkSpeedGtps || result.LinkWidth < result.MaxLinkWidth,
+		CorrectableErrors:           result.AerCorrectable,
+		UncorrectableNonFatalErrors: result.AerUncorrNonFatal,
+		UncorrectableFatalErrors:    result.AerUncorrFatal,
+		LastUncorrectableStatus:     result.AerLastUncorrStatus,
+	}, nil
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_GetNvmeControllerPcieStatus(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in      *GetNvmeControllerPcieStatusRequest
+		out     *NvmeControllerPcieStatus
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &GetNvmeControllerPcieStatusRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get PCIe status of CTRL: %v", testControllerName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &GetNvmeControllerPcieStatusRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_get_pcie_func_status: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &GetNvmeControllerPcieStatusRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_get_pcie_func_status: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: &GetNvmeControllerPcieStatusRequest{Name: testControllerName},
+			out: &NvmeControllerPcieStatus{
+				Name:                        testControllerName,
+				Function:                    PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 2},
+				LinkSpeedGtps:               16,
+				LinkWidth:                   16,
+				MaxLinkSpeedGtps:            16,
+				MaxLinkWidth:                16,
+				Degraded:                    false,
+				CorrectableErrors:           3,
+				UncorrectableNonFatalErrors: 0,
+				UncorrectableFatalErrors:    0,
+				LastUncorrectableStatus:     0,
+			},
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "link_speed_gtps": 16, "link_width": 16,` +
+				`"max_link_speed_gtps": 16, "max_link_width": 16, "aer_correctable": 3}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with degraded link": {
+			in: &GetNvmeControllerPcieStatusRequest{Name: testControllerName},
+			out: &NvmeControllerPcieStatus{
+				Name:                        testControllerName,
+				Function:                    PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 2},
+				LinkSpeedGtps:               8,
+				LinkWidth:                   8,
+				MaxLinkSpeedGtps:            16,
+				MaxLinkWidth:                16,
+				Degraded:                    true,
+				CorrectableErrors:           1024,
+				UncorrectableNonFatalErrors: 2,
+				UncorrectableFatalErrors:    1,
+				LastUncorrectableStatus:     0x4000,
+			},
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "link_speed_gtps": 8, "link_width": 8,` +
+				`"max_link_speed_gtps": 16, "max_link_width": 16, "aer_correctable": 1024, "aer_uncorr_nonfatal": 2,` +
+				`"aer_uncorr_fatal": 1, "aer_last_uncorr_status": 16384}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &GetNvmeControllerPcieStatusRequest{Name: utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+		},
+		"malformed name": {
+			in:      &GetNvmeControllerPcieStatusRequest{Name: "-ABC-DEF"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+
+			response, err := testEnv.opiSpdkServer.GetNvmeControllerPcieStatus(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
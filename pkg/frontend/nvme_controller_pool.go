@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// PcieFunction is a PCIe function of the DPU the controllers can be created on
+type PcieFunction struct {
+	PortID           int32 `json:"portId"`
+	PhysicalFunction int32 `json:"physicalFunction"`
+	VirtualFunction  int32 `json:"virtualFunction"`
+}
+
+// String returns the function in port:pf:vf format
+func (f PcieFunction) String() string {
+	return fmt.Sprintf("%d:%d:%d", f.PortID, f.PhysicalFunction, f.VirtualFunction)
+}
+
+// pcieFunctionOf returns the function of a PCIe endpoint
+func pcieFunctionOf(pcieID *pb.PciEndpoint) PcieFunction {
+	return PcieFunction{
+		PortID:           pcieID.GetPortId().GetValue(),
+		PhysicalFunction: pcieID.GetPhysicalFunction().GetValue(),
+		VirtualFunction:  pcieID.GetVirtualFunction().GetValue(),
+	}
+}
+
+// ParsePcieFunctionPool parses the PCIe functions in port:pf:vf format, comma separated, the
+// virtual functions being a number or a range, i.e. 0:0:0,0:1:1-63
+func ParsePcieFunctionPool(value string) ([]PcieFunction, error) {
+	var pool []PcieFunction
+	seen := make(map[PcieFunction]bool)
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(field), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid PCIe functions %q, have to be port:pf:vf", field)
+		}
+		port, err := strconv.ParseUint(parts[0], 10, 15)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PCIe functions %q, bad port %s", field, parts[0])
+		}
+		pf, err := strconv.ParseUint(parts[1], 10, 15)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PCIe functions %q, bad physical function %s", field, parts[1])
+		}
+		first, last, isRange := strings.Cut(parts[2], "-")
+		if !isRange {
+			last = first
+		}
+		firstVf, err := strconv.ParseUint(first, 10, 15)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PCIe functions %q, bad virtual function %s", field, first)
+		}
+		lastVf, err := strconv.ParseUint(last, 10, 15)
+		if err != nil || lastVf < firstVf {
+			return nil, fmt.Errorf("invalid PCIe functions %q, bad virtual function range %s", field, parts[2])
+		}
+		for vf := firstVf; vf <= lastVf; vf++ {
+			function := PcieFunction{PortID: int32(port), PhysicalFunction: int32(pf), VirtualFunction: int32(vf)}
+			if seen[function] {
+				return nil, fmt.Errorf("invalid PCIe functions %q, %s is already in the pool", field, function)
+			}
+			seen[function] = true
+			pool = append(pool, function)
+		}
+	}
+	return pool, nil
+}
+
+// SetPcieFunctionPool lets the controllers be created without a PcieId, they are given the first
+// function of the pool no controller is on, in the order of the pool
+func (s *Server) SetPcieFunctionPool(pool []PcieFunction) {
+	s.pcieFunctionPool = pool
+}
+
+// allocatePcieFunction returns the first function of the pool no controller is on, the functions
+// reserved for a subsystem come first and the ones reserved for others are skipped
+func (s *Server) allocatePcieFunction(subsysName string) (*pb.PciEndpoint, error) {
+	used := make(map[PcieFunction]bool)
+	for _, name := range s.listNames("/nvmeControllers/") {
+		controller := new(pb.NvmeController)
+		found, err := s.store.Get(name, controller)
+		if err != nil {
+			return nil, err
+		}
+		if pcieID := controller.GetSpec().GetPcieId(); found && pcieID != nil {
+			used[pcieFunctionOf(pcieID)] = true
+		}
+	}
+	reservations, err := s.pcieReservations()
+	if err != nil {
+		return nil, err
+	}
+	subsysID := utils.GetSubsystemIDFromNvmeName(subsysName)
+	var candidates []PcieFunction
+	reserved := make(map[PcieFunction]bool)
+	for _, reservation := range reservations {
+		reserved[reservation.Function] = true
+		if strings.HasPrefix(subsysID, reservation.SubsystemPrefix) {
+			candidates = append(candidates, reservation.Function)
+		}
+	}
+	for _, function := range s.pcieFunctionPool {
+		if !reserved[function] {
+			candidates = append(candidates, function)
+		}
+	}
+	for _, function := range candidates {
+		if !used[function] {
+			return &pb.PciEndpoint{
+				PortId:           wrapperspb.Int32(function.PortID),
+				PhysicalFunction: wrapperspb.Int32(function.PhysicalFunction),
+				VirtualFunction:  wrapperspb.Int32(function.VirtualFunction),
+			}, nil
+		}
+	}
+	msg := fmt.Sprintf("All the %d PCIe functions of the pool have a controller", len(s.pcieFunctionPool))
+	return nil, status.Errorf(codes.ResourceExhausted, msg)
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func TestFrontEnd_ParsePcieFunctionPool(t *testing.T) {
+	tests := map[string]struct {
+		value  string
+		pool   []PcieFunction
+		errMsg string
+	}{
+		"functions and ranges": {
+			value: "0:0:0, 0:1:1-3",
+			pool: []PcieFunction{
+				{PortID: 0, PhysicalFunction: 0, VirtualFunction: 0},
+				{PortID: 0, PhysicalFunction: 1, VirtualFunction: 1},
+				{PortID: 0, PhysicalFunction: 1, VirtualFunction: 2},
+				{PortID: 0, PhysicalFunction: 1, VirtualFunction: 3},
+			},
+		},
+		"missing virtual function": {
+			value:  "0:1",
+			errMsg: `invalid PCIe functions "0:1", have to be port:pf:vf`,
+		},
+		"bad physical function": {
+			value:  "0:pf:1",
+			errMsg: `invalid PCIe functions "0:pf:1", bad physical function pf`,
+		},
+		"reversed range": {
+			value:  "0:1:3-1",
+			errMsg: `invalid PCIe functions "0:1:3-1", bad virtual function range 3-1`,
+		},
+		"duplicated function": {
+			value:  "0:1:1-3,0:1:2",
+			errMsg: `invalid PCIe functions "0:1:2", 0:1:2 is already in the pool`,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pool, err := ParsePcieFunctionPool(tt.value)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !reflect.DeepEqual(pool, tt.pool) {
+				t.Error("pool: expected", tt.pool, "received", pool)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeControllerFromPool(t *testing.T) {
+	tests := map[string]struct {
+		pool     string
+		spdk     []string
+		function *PcieFunction
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"first free function": {
+			pool:     "0:1:1-3",
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			function: &PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 1},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"used functions skipped": {
+			pool:     "0:1:2-3",
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			function: &PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 3},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"pool exhausted": {
+			pool:     "0:1:2",
+			spdk:     []string{},
+			function: nil,
+			errCode:  codes.ResourceExhausted,
+			errMsg:   "All the 1 PCIe functions of the pool have a controller",
+		},
+		"no pool": {
+			pool:     "",
+			spdk:     []string{},
+			function: nil,
+			errCode:  codes.InvalidArgument,
+			errMsg:   "invalid endpoint type passed for transport",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.pool != "" {
+				pool, err := ParsePcieFunctionPool(tt.pool)
+				if err != nil {
+					t.Fatal("unexpected error", err)
+				}
+				testEnv.opiSpdkServer.SetPcieFunctionPool(pool)
+			}
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+
+			request := &pb.CreateNvmeControllerRequest{
+				Parent:           testSubsystemName,
+				NvmeControllerId: "controller-pool",
+				NvmeController: &pb.NvmeController{
+					Spec: &pb.NvmeControllerSpec{
+						Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+					},
+				},
+			}
+			response, err := testEnv.opiSpdkServer.CreateNvmeController(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if tt.function == nil {
+				return
+			}
+			pcieID := response.GetSpec().GetPcieId()
+			function := PcieFunction{
+				PortID:           pcieID.GetPortId().GetValue(),
+				PhysicalFunction: pcieID.GetPhysicalFunction().GetValue(),
+				VirtualFunction:  pcieID.GetVirtualFunction().GetValue(),
+			}
+			if function != *tt.function {
+				t.Error("function: expected", *tt.function, "received", function)
+			}
+		})
+	}
+}
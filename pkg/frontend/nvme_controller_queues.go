@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// StatsNvmeControllerQueuesRequest represents a request to get the queue stats of an Nvme controller
+type StatsNvmeControllerQueuesRequest struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+}
+
+// NvmeSubmissionQueueStats represents the utilization of an I/O submission queue
+type NvmeSubmissionQueueStats struct {
+	// ID of the submission queue
+	ID int32 `json:"id"`
+	// CompletionQueueID is the ID of the completion queue the commands complete on
+	CompletionQueueID int32 `json:"completionQueueId"`
+	// Depth is the number of entries of the queue
+	Depth int32 `json:"depth"`
+	// Occupancy is the number of commands submitted by the host and not fetched yet
+	Occupancy int32 `json:"occupancy"`
+	// MaxOccupancy is the highest occupancy since the queue was created
+	MaxOccupancy int32 `json:"maxOccupancy"`
+	// UtilizationPercent is MaxOccupancy in percent of Depth, a queue often close to 100
+	// percent is too shallow for the workload
+	UtilizationPercent float64 `json:"utilizationPercent"`
+	// Commands is the number of commands fetched from the queue
+	Commands uint64 `json:"commands"`
+	// Stalls is the number of times the fetching of the commands stopped, its completion
+	// queue being full or the firmware out of resources
+	Stalls uint64 `json:"stalls"`
+}
+
+// NvmeCompletionQueueStats represents the utilization of an I/O completion queue
+type NvmeCompletionQueueStats struct {
+	// ID of the completion queue
+	ID int32 `json:"id"`
+	// Depth is the number of entries of the queue
+	Depth int32 `json:"depth"`
+	// Occupancy is the number of completions posted and not consumed by the host yet
+	Occupancy int32 `json:"occupancy"`
+	// MaxOccupancy is the highest occupancy since the queue was created
+	MaxOccupancy int32 `json:"maxOccupancy"`
+	// UtilizationPercent is MaxOccupancy in percent of Depth
+	UtilizationPercent float64 `json:"utilizationPercent"`
+	// Completions is the number of completions posted to the queue
+	Completions uint64 `json:"completions"`
+	// Stalls is the number of times a completion waited for the host to free an entry
+	Stalls uint64 `json:"stalls"`
+	// InterruptVector is the MSI-X vector of the queue
+	InterruptVector int32 `json:"interruptVector"`
+}
+
+// NvmeControllerQueueStats represents the utilization of the I/O queues created by the host on an
+// Nvme controller, to size MaxNsq and MaxNcq for a workload
+type NvmeControllerQueueStats struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+	// MaxNsq is the number of submission queues the controller allows
+	MaxNsq int32 `json:"maxNsq"`
+	// MaxNcq is the number of completion queues the controller allows
+	MaxNcq int32 `json:"maxNcq"`
+	// SubmissionQueues are the created submission queues
+	SubmissionQueues []*NvmeSubmissionQueueStats `json:"submissionQueues"`
+	// CompletionQueues are the created completion queues
+	CompletionQueues []*NvmeCompletionQueueStats `json:"completionQueues"`
+}
+
+// StatsNvmeControllerQueues gets the depth, occupancy and stalls of the I/O queues of an Nvme controller
+func (s *Server) StatsNvmeControllerQueues(ctx context.Context, in *StatsNvmeControllerQueuesRequest) (*NvmeControllerQueueStats, error) {
+	// check input correctness
+	if err := s.validateStatsNvmeControllerQueuesRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, subsys, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmGetCtrlrQueueStatsParams{
+		Subnqn:  subsys.Spec.Nqn,
+		CtrlrID: int(*controller.Spec.NvmeControllerId),
+	}
+	var result models.MrvlNvmGetCtrlrQueueStatsResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_get_ctrlr_queue_stats", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not stats queues of CTRL: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_ctrlr_queue_stats")
+	}
+	response := &NvmeControllerQueueStats{
+		Name:             in.Name,
+		MaxNsq:           int32(result.MaxNsq),
+		MaxNcq:           int32(result.MaxNcq),
+		SubmissionQueues: make([]*NvmeSubmissionQueueStats, 0, len(result.Sqs)),
+		CompletionQueues: make([]*NvmeCompletionQueueStats, 0, len(result.Cqs)),
+	}
+	for _, sq := range result.Sqs {
+		response.SubmissionQueues = append(response.SubmissionQueues, &NvmeSubmissionQueueStats{
+			ID:                 int32(sq.Sqid),
+			CompletionQueueID:  int32(sq.Cqid),
+			Depth:              int32(sq.Qsize),
+			Occupancy:          int32(sq.Occupancy),
+			MaxOccupancy:       int32(sq.MaxOccupancy),
+			UtilizationPercent: queueUtilization(sq.MaxOccupancy, sq.Qsize),
+			Commands:           sq.NumCmds,
+			Stalls:             sq.NumStalls,
+		})
+	}
+	for _, cq := range result.Cqs {
+		response.CompletionQueues = append(response.CompletionQueues, &NvmeCompletionQueueStats{
+			ID:                 int32(cq.Cqid),
+			Depth:              int32(cq.Qsize),
+			Occupancy:          int32(cq.Occupancy),
+			MaxOccupancy:       int32(cq.MaxOccupancy),
+			UtilizationPercent: queueUtilization(cq.MaxOccupancy, cq.Qsize),
+			Completions:        cq.NumCpls,
+			Stalls:             cq.NumStalls,
+			InterruptVector:    int32(cq.Iv),
+		})
+	}
+	return response, nil
+}
+
+// queueUtilization returns the occupancy of a queue in percent of its depth
+func queueUtilization(occupancy int, depth int) float64 {
+	if depth == 0 {
+		return 0
+	}
+	return float64(occupancy) * 100 / float64(depth)
+}
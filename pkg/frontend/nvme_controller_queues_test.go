@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_StatsNvmeControllerQueues(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in      *StatsNvmeControllerQueuesRequest
+		out     *NvmeControllerQueueStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &StatsNvmeControllerQueuesRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not stats queues of CTRL: %v", testControllerName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &StatsNvmeControllerQueuesRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_get_ctrlr_queue_stats: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      &StatsNvmeControllerQueuesRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_get_ctrlr_queue_stats: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &StatsNvmeControllerQueuesRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_get_ctrlr_queue_stats: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: &StatsNvmeControllerQueuesRequest{Name: testControllerName},
+			out: &NvmeControllerQueueStats{
+				Name:   testControllerName,
+				MaxNsq: 4,
+				MaxNcq: 4,
+				SubmissionQueues: []*NvmeSubmissionQueueStats{
+					{ID: 1, CompletionQueueID: 1, Depth: 256, Occupancy: 12, MaxOccupancy: 240, UtilizationPercent: 93.75, Commands: 1048576, Stalls: 17},
+					{ID: 2, CompletionQueueID: 1, Depth: 256, Occupancy: 0, MaxOccupancy: 32, UtilizationPercent: 12.5, Commands: 4096, Stalls: 0},
+				},
+				CompletionQueues: []*NvmeCompletionQueueStats{
+					{ID: 1, Depth: 512, Occupancy: 3, MaxOccupancy: 128, UtilizationPercent: 25, Completions: 1052672, Stalls: 2, InterruptVector: 1},
+				},
+			},
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "max_nsq": 4, "max_ncq": 4,` +
+				`"sqs": [{"sqid": 1, "cqid": 1, "qsize": 256, "occupancy": 12, "max_occupancy": 240, "num_cmds": 1048576, "num_stalls": 17},` +
+				`{"sqid": 2, "cqid": 1, "qsize": 256, "occupancy": 0, "max_occupancy": 32, "num_cmds": 4096, "num_stalls": 0}],` +
+				`"cqs": [{"cqid": 1, "qsize": 512, "occupancy": 3, "max_occupancy": 128, "num_cpls": 1052672, "num_stalls": 2, "iv": 1}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request without IO queues": {
+			in: &StatsNvmeControllerQueuesRequest{Name: testControllerName},
+			out: &NvmeControllerQueueStats{
+				Name:             testControllerName,
+				MaxNsq:           4,
+				MaxNcq:           4,
+				SubmissionQueues: []*NvmeSubmissionQueueStats{},
+				CompletionQueues: []*NvmeCompletionQueueStats{},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "max_nsq": 4, "max_ncq": 4}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &StatsNvmeControllerQueuesRequest{Name: utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+		},
+		"malformed name": {
+			in:      &StatsNvmeControllerQueuesRequest{Name: "-ABC-DEF"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &StatsNvmeControllerQueuesRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+
+			response, err := testEnv.opiSpdkServer.StatsNvmeControllerQueues(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+)
+
+// PauseNvmeControllerRequest represents a request to quiesce an Nvme controller
+type PauseNvmeControllerRequest struct {
+	// Name of the Nvme controller to pause
+	Name string `json:"name"`
+}
+
+// ResumeNvmeControllerRequest represents a request to resume a paused Nvme controller
+type ResumeNvmeControllerRequest struct {
+	// Name of the Nvme controller to resume
+	Name string `json:"name"`
+}
+
+// PauseNvmeController stops an Nvme controller from fetching host commands and drains the
+// commands in flight, i.e. for maintenance or to swap the backing volumes. The controller
+// is reported inactive until it is resumed
+func (s *Server) PauseNvmeController(ctx context.Context, in *PauseNvmeControllerRequest) (*pb.NvmeController, error) {
+	// check input correctness
+	if err := s.validatePauseNvmeControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, subsys, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmCtrlrPauseParams{
+		Subnqn:  subsys.Spec.Nqn,
+		CtrlrID: int(*controller.Spec.NvmeControllerId),
+	}
+	var result models.MrvlNvmCtrlrPauseResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_pause", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not pause CTRL: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_pause")
+	}
+	return s.setNvmeControllerActive(controller, false)
+}
+
+// ResumeNvmeController resumes fetching host commands on a paused Nvme controller
+func (s *Server) ResumeNvmeController(ctx context.Context, in *ResumeNvmeControllerRequest) (*pb.NvmeController, error) {
+	// check input correctness
+	if err := s.validateResumeNvmeControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, subsys, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmCtrlrResumeParams{
+		Subnqn:  subsys.Spec.Nqn,
+		CtrlrID: int(*controller.Spec.NvmeControllerId),
+	}
+	var result models.MrvlNvmCtrlrResumeResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_resume", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not resume CTRL: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_resume")
+	}
+	return s.setNvmeControllerActive(controller, true)
+}
+
+// ControllerStateReporter is told the Nvme controllers becoming active or inactive
+type ControllerStateReporter func(name string, active bool)
+
+// SetControllerStateReporter sets who is told the Nvme controllers becoming active or inactive
+func (s *Server) SetControllerStateReporter(reporter ControllerStateReporter) {
+	s.controllerStateReporter = reporter
+}
+
+func (s *Server) setNvmeControllerActive(controller *pb.NvmeController, active bool) (*pb.NvmeController, error) {
+	response := utils.ProtoClone(controller)
+	response.Status = &pb.NvmeControllerStatus{Active: active}
+	// save object to the database
+	err := s.store.Set(response.Name, response)
+	if err != nil {
+		return nil, err
+	}
+	if s.controllerStateReporter != nil && controller.GetStatus().GetActive() != active {
+		s.controllerStateReporter(response.Name, active)
+	}
+	return response, nil
+}
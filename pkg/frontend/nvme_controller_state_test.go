@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_PauseNvmeController(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in      *PauseNvmeControllerRequest
+		out     *pb.NvmeController
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &PauseNvmeControllerRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not pause CTRL: %v", testControllerName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &PauseNvmeControllerRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_pause: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      &PauseNvmeControllerRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_pause: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &PauseNvmeControllerRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_pause: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: &PauseNvmeControllerRequest{Name: testControllerName},
+			out: &pb.NvmeController{
+				Name:   testControllerName,
+				Spec:   testController.Spec,
+				Status: &pb.NvmeControllerStatus{Active: false},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &PauseNvmeControllerRequest{Name: utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+		},
+		"malformed name": {
+			in:      &PauseNvmeControllerRequest{Name: "-ABC-DEF"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &PauseNvmeControllerRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			var reported []string
+			testEnv.opiSpdkServer.SetControllerStateReporter(func(name string, active bool) {
+				reported = append(reported, fmt.Sprintf("%s %v", name, active))
+			})
+
+			response, err := testEnv.opiSpdkServer.PauseNvmeController(testEnv.ctx, tt.in)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			// the controller is reported inactive only once paused
+			controller := new(pb.NvmeController)
+			_, _ = testEnv.opiSpdkServer.store.Get(testControllerName, controller)
+			if controller.Status.Active != (tt.errCode != codes.OK) {
+				t.Error("saved active: expected", tt.errCode != codes.OK, "received", controller.Status.Active)
+			}
+			var expectedReported []string
+			if tt.errCode == codes.OK {
+				expectedReported = []string{testControllerName + " false"}
+			}
+			if !reflect.DeepEqual(reported, expectedReported) {
+				t.Error("reported: expected", expectedReported, "received", reported)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_ResumeNvmeController(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testPausedController := pb.NvmeController{
+		Name:   testControllerName,
+		Spec:   testController.Spec,
+		Status: &pb.NvmeControllerStatus{Active: false},
+	}
+	tests := map[string]struct {
+		in      *ResumeNvmeControllerRequest
+		out     *pb.NvmeController
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &ResumeNvmeControllerRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not resume CTRL: %v", testControllerName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &ResumeNvmeControllerRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_resume: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &ResumeNvmeControllerRequest{Name: testControllerName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_resume: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      &ResumeNvmeControllerRequest{Name: testControllerName},
+			out:     &testControllerWithStatus,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &ResumeNvmeControllerRequest{Name: utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+		},
+		"no required field": {
+			in:      &ResumeNvmeControllerRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testPausedController)
+
+			response, err := testEnv.opiSpdkServer.ResumeNvmeController(testEnv.ctx, tt.in)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
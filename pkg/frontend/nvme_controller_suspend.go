@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSuspendPollInterval is how often the draining of a suspended controller is polled
+const defaultSuspendPollInterval = 100 * time.Millisecond
+
+// States of a controller suspension reported by the firmware
+const (
+	suspendStateDraining  = "draining"
+	suspendStateSuspended = "suspended"
+)
+
+// SuspendNvmeControllerRequest represents a request to suspend an Nvme controller
+type SuspendNvmeControllerRequest struct {
+	// Name of the Nvme controller to suspend
+	Name string `json:"name"`
+	// Flush makes the firmware flush the write caches of the volumes of the controller once its
+	// commands are drained, so the volumes can be swapped or taken over by another DPU
+	Flush bool `json:"flush"`
+}
+
+// SuspendNvmeControllerMetadata describes a suspend operation
+type SuspendNvmeControllerMetadata struct {
+	// Name of the Nvme controller
+	Name string `json:"name"`
+	// OutstandingCommands is the number of host commands in flight when the controller stopped
+	// fetching them, the progress of the operation is the part of them completed
+	OutstandingCommands int32 `json:"outstandingCommands"`
+}
+
+// SuspendNvmeController stops an Nvme controller from fetching host commands and returns a
+// long-running operation done once the commands in flight are completed, and flushed when asked,
+// reporting their completion as its progress. The controller is reported inactive once suspended
+// and fetches the host commands again when resumed with ResumeNvmeController
+func (s *Server) SuspendNvmeController(ctx context.Context, in *SuspendNvmeControllerRequest) (*operations.Operation, error) {
+	// check input correctness
+	if err := s.validateSuspendNvmeControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, subsys, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmCtrlrSuspendParams{
+		Subnqn:  subsys.Spec.Nqn,
+		CtrlrID: int(*controller.Spec.NvmeControllerId),
+		Flush:   boolToInt(in.Flush),
+	}
+	var result models.MrvlNvmCtrlrSuspendResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_suspend", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not suspend CTRL: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_suspend")
+	}
+	metadata := &SuspendNvmeControllerMetadata{
+		Name:                in.Name,
+		OutstandingCommands: int32(result.OutstandingCmds),
+	}
+	return s.operations.Start(metadata, func(ctx context.Context) (interface{}, error) {
+		return s.waitNvmeControllerSuspended(ctx, controller, subsys, result.OutstandingCmds)
+	}), nil
+}
+
+// waitNvmeControllerSuspended polls the firmware until the commands in flight of a suspended
+// controller are completed
+func (s *Server) waitNvmeControllerSuspended(ctx context.Context, controller *pb.NvmeController, subsys *pb.NvmeSubsystem, outstanding int) (*pb.NvmeController, error) {
+	for {
+		params := models.MrvlNvmCtrlrGetSuspendStatusParams{
+			Subnqn:  subsys.Spec.Nqn,
+			CtrlrID: int(*controller.Spec.NvmeControllerId),
+		}
+		var result models.MrvlNvmCtrlrGetSuspendStatusResult
+		err := s.rpc.Call(ctx, "mrvl_nvm_ctrlr_get_suspend_status", &params, &result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not get suspend status of CTRL: %s", controller.Name)
+			return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_get_suspend_status")
+		}
+		switch result.State {
+		case suspendStateDraining:
+			if outstanding > 0 && result.OutstandingCmds <= outstanding {
+				operations.ReportProgress(ctx, int32((outstanding-result.OutstandingCmds)*100/outstanding))
+			}
+		case suspendStateSuspended:
+			return s.setNvmeControllerActive(controller, false)
+		default:
+			// the firmware fetches the host commands again on failure
+			msg := fmt.Sprintf("Suspend of CTRL %s failed, it is still fetching host commands", controller.Name)
+			return nil, status.Errorf(codes.Aborted, msg)
+		}
+		time.Sleep(s.suspendPollInterval)
+	}
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_SuspendNvmeController(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testSuspendedController := &pb.NvmeController{
+		Name:   testControllerName,
+		Spec:   testController.Spec,
+		Status: &pb.NvmeControllerStatus{Active: false},
+	}
+	tests := map[string]struct {
+		in         *SuspendNvmeControllerRequest
+		spdk       []string
+		errCode    codes.Code
+		errMsg     string
+		opResponse *pb.NvmeController
+		opErr      *operations.Error
+		stored     *pb.NvmeController
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &SuspendNvmeControllerRequest{Name: testControllerName},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not suspend CTRL: %v", testControllerName),
+			stored:  &testControllerWithStatus,
+		},
+		"valid request with error code from SPDK response": {
+			in:      &SuspendNvmeControllerRequest{Name: testControllerName},
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_suspend: %v", "json response error: myopierr"),
+			stored:  &testControllerWithStatus,
+		},
+		"valid request with valid SPDK response": {
+			in: &SuspendNvmeControllerRequest{Name: testControllerName, Flush: true},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "outstanding_cmds": 8}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "draining", "outstanding_cmds": 2}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "suspended", "outstanding_cmds": 0}}`,
+			},
+			errCode:    codes.OK,
+			errMsg:     "",
+			opResponse: testSuspendedController,
+			stored:     testSuspendedController,
+		},
+		"suspend failed in the firmware": {
+			in: &SuspendNvmeControllerRequest{Name: testControllerName},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "outstanding_cmds": 8}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "failed", "outstanding_cmds": 8}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.Aborted, Message: fmt.Sprintf("Suspend of CTRL %s failed, it is still fetching host commands", testControllerName)},
+			stored:  &testControllerWithStatus,
+		},
+		"suspend status with invalid SPDK response": {
+			in: &SuspendNvmeControllerRequest{Name: testControllerName},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "outstanding_cmds": 8}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.InvalidArgument, Message: fmt.Sprintf("Could not get suspend status of CTRL: %s", testControllerName)},
+			stored:  &testControllerWithStatus,
+		},
+		"valid request with unknown key": {
+			in:      &SuspendNvmeControllerRequest{Name: utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")},
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+			stored:  &testControllerWithStatus,
+		},
+		"malformed name": {
+			in:      &SuspendNvmeControllerRequest{Name: "-ABC-DEF"},
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			stored:  &testControllerWithStatus,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+
+			response, err := testEnv.opiSpdkServer.SuspendNvmeController(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err == nil {
+				testEnv.opiSpdkServer.operations.Wait()
+				op, _ := testEnv.opiSpdkServer.operations.GetOperation(testEnv.ctx, &operations.GetOperationRequest{Name: response.Name})
+				if !op.Done {
+					t.Error("expected operation to be done")
+				}
+				if controller, _ := op.Response.(*pb.NvmeController); !proto.Equal(controller, tt.opResponse) {
+					t.Error("operation response: expected", tt.opResponse, "received", op.Response)
+				}
+				if (op.Error == nil) != (tt.opErr == nil) || (tt.opErr != nil && *op.Error != *tt.opErr) {
+					t.Error("operation error: expected", tt.opErr, "received", op.Error)
+				}
+			} else if response != nil {
+				t.Error("response: expected", nil, "received", response)
+			}
+
+			stored := new(pb.NvmeController)
+			_, _ = testEnv.opiSpdkServer.store.Get(testControllerName, stored)
+			if !proto.Equal(stored, tt.stored) {
+				t.Error("stored controller: expected", tt.stored, "received", stored)
+			}
+		})
+	}
+}
@@ -41,6 +41,7 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 		errMsg  string
 		exist   bool
 		subsys  string
+		options *NvmeControllerOptions
 	}{
 		"illegal resource_id": {
 			id: "CapitalLettersNotAllowed",
@@ -49,7 +50,7 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
 			exist:   false,
 			subsys:  testSubsystemName,
@@ -139,6 +140,41 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			exist:   false,
 			subsys:  testSubsystemName,
 		},
+		"valid request with MSI-X vectors above the SKU limit": {
+			id: testControllerID,
+			in: &pb.NvmeController{
+				Name: testControllerName,
+				Spec: spec,
+			},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "max_pf_msix_vectors": 256, "max_vf_msix_vectors": 32}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "MsixVectors value (64) exceeds the SKU limit of 32 for virtual functions",
+			exist:   false,
+			subsys:  testSubsystemName,
+			options: &NvmeControllerOptions{MsixVectors: 64},
+		},
+		"valid request with MSI-X vectors within the SKU limit": {
+			id: testControllerID,
+			in: &pb.NvmeController{
+				Name: testControllerName,
+				Spec: spec,
+			},
+			out: &pb.NvmeController{
+				Name:   testControllerName,
+				Spec:   spec,
+				Status: &pb.NvmeControllerStatus{Active: true},
+			},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "max_pf_msix_vectors": 256, "max_vf_msix_vectors": 32}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 1}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+			subsys:  testSubsystemName,
+			options: &NvmeControllerOptions{MsixVectors: 16},
+		},
 		"already exists": {
 			id: testControllerID,
 			in: &pb.NvmeController{
@@ -163,7 +199,7 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			exist:   false,
 			subsys:  "-ABC-DEF",
@@ -173,7 +209,7 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			in:      nil,
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: nvme_controller",
 			exist:   false,
 			subsys:  testSubsystemName,
@@ -188,7 +224,7 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: parent",
 			exist:   false,
 			subsys:  "",
@@ -210,7 +246,7 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("not supported transport type: %v", pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP),
 			exist:   false,
 			subsys:  testSubsystemName,
@@ -232,7 +268,7 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "invalid endpoint type passed for transport",
 			exist:   false,
 			subsys:  testSubsystemName,
@@ -251,6 +287,9 @@ func TestFrontEnd_CreateNvmeController(t *testing.T) {
 				_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
 				// testEnv.opiSpdkServer.Controllers[testControllerID].Spec.Id = &pc.ObjectKey{Value: testControllerID}
 			}
+			if tt.options != nil {
+				_ = testEnv.opiSpdkServer.saveNvmeControllerOptions(testControllerName, tt.options)
+			}
 			if tt.out != nil {
 				tt.out = utils.ProtoClone(tt.out)
 				tt.out.Name = testControllerName
@@ -348,7 +387,7 @@ func TestFrontEnd_DeleteNvmeController(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     &emptypb.Empty{},
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			missing: false,
 		},
@@ -356,7 +395,7 @@ func TestFrontEnd_DeleteNvmeController(t *testing.T) {
 			in:      "",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: name",
 			missing: false,
 		},
@@ -519,7 +558,7 @@ func TestFrontEnd_UpdateNvmeController(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 		"not supported transport type": {
@@ -539,8 +578,8 @@ func TestFrontEnd_UpdateNvmeController(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
-			errMsg:  fmt.Sprintf("not supported transport type: %v", pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP),
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("not supported transport type: %v (and 1 more violations)", pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP),
 		},
 		"not corresponding endpoint for pcie transport type": {
 			mask: nil,
@@ -559,8 +598,8 @@ func TestFrontEnd_UpdateNvmeController(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
-			errMsg:  "invalid endpoint type passed for transport",
+			errCode: codes.InvalidArgument,
+			errMsg:  "invalid endpoint type passed for transport (and 1 more violations)",
 		},
 	}
 
@@ -738,7 +777,7 @@ func TestFrontEnd_ListNvmeControllers(t *testing.T) {
 			in:      "",
 			out:     []*pb.NvmeController{},
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: parent",
 			size:    0,
 			token:   "",
@@ -754,7 +793,7 @@ func TestFrontEnd_ListNvmeControllers(t *testing.T) {
 			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
 			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
 			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.SetToken("existing-pagination-token", 1)
 
 			request := &pb.ListNvmeControllersRequest{Parent: tt.in, PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListNvmeControllers(testEnv.ctx, request)
@@ -820,14 +859,8 @@ func TestFrontEnd_GetNvmeController(t *testing.T) {
 			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_get_info: %v", "json response error: myopierr"),
 		},
 		"valid request with valid SPDK response": {
-			in: testControllerName,
-			out: &pb.NvmeController{
-				Name: testControllerName,
-				Spec: &pb.NvmeControllerSpec{
-					NvmeControllerId: proto.Int32(17),
-				},
-				Status: &pb.NvmeControllerStatus{Active: true},
-			},
+			in:      testControllerName,
+			out:     &testControllerWithStatus,
 			spdk:    []string{`{"jsonrpc":"2.0","id":%d,"result":{"status":0,"pcie_domain_id":1,"pf_id":1,"vf_id":1,"ctrlr_id":1,"max_nsq":4,"max_ncq":4,"mqes":2048,"ieee_oui":"005043","cmic":6,"nn":16,"active_ns_count":4,"active_nsq":2,"active_ncq":2,"mdts":9,"sqes":6,"cqes":4}}`},
 			errCode: codes.OK,
 			errMsg:  "",
@@ -843,14 +876,14 @@ func TestFrontEnd_GetNvmeController(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 		"no required field": {
 			in:      "",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: name",
 		},
 	}
@@ -955,7 +988,7 @@ func TestFrontEnd_StatsNvmeController(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 	}
@@ -5,81 +5,184 @@
 package frontend
 
 import (
-	"errors"
-	"fmt"
-
-	"go.einride.tech/aip/fieldbehavior"
-	"go.einride.tech/aip/resourceid"
-	"go.einride.tech/aip/resourcename"
-
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
 )
 
-func (s *Server) validateCreateNvmeControllerRequest(in *pb.CreateNvmeControllerRequest) error {
+func (s *Server) validateCreateNvmeControllerRequest(in *pb.CreateNvmeControllerRequest) ([]string, error) {
+	v := validation.NewValidator(s.validationMode)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	if !v.RequiredFields(in) {
+		return nil, v.Err()
 	}
 	// see https://google.aip.dev/133#user-specified-ids
-	if in.NvmeControllerId != "" {
-		if err := resourceid.ValidateUserSettable(in.NvmeControllerId); err != nil {
-			return err
-		}
-	}
-
-	if in.NvmeController.Spec.Trtype != pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE {
-		return fmt.Errorf("not supported transport type: %v", in.NvmeController.Spec.Trtype)
-	}
+	v.ResourceID("nvme_controller_id", in.NvmeControllerId)
+	// the controllers without a PcieId are given a function of the pool
+	pooled := in.NvmeController.Spec.Endpoint == nil && len(s.pcieFunctionPool) > 0
+	validateNvmeControllerSpec(v, in.NvmeController.Spec, pooled)
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	v.ResourceName("parent", in.Parent)
+	return v.Warnings(), v.Err()
+}
 
-	if in.NvmeController.Spec.GetPcieId() == nil {
-		return errors.New("invalid endpoint type passed for transport")
+// validateNvmeControllerSpec checks the transport and the queues of a controller, the PcieId is
+// only optional when the controller is given a function of the pool. The fields the firmware
+// doesn't support and the queues out of range are refused or fixed as the validation mode says
+func validateNvmeControllerSpec(v *validation.Validator, spec *pb.NvmeControllerSpec, pooled bool) {
+	if v.Check(spec.Trtype == pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE, "nvme_controller.spec.trtype",
+		"not supported transport type: %v", spec.Trtype) {
+		v.Check(spec.GetPcieId() != nil || pooled, "nvme_controller.spec.pcie_id",
+			"invalid endpoint type passed for transport")
 	}
-
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Parent)
+	// check the queues, the queue IDs are 16 bits wide and as powers of 2 the queues have up to 64K entries
+	v.Clamp("nvme_controller.spec.max_nsq", &spec.MaxNsq, 0, 65535)
+	v.Clamp("nvme_controller.spec.max_ncq", &spec.MaxNcq, 0, 65535)
+	v.Clamp("nvme_controller.spec.sqes", &spec.Sqes, 0, 16)
+	v.Clamp("nvme_controller.spec.cqes", &spec.Cqes, 0, 16)
+	// the namespaces are limited by the subsystem and the QoS by the volumes
+	v.Unsupported("nvme_controller.spec.max_namespaces", spec.MaxNamespaces != 0, func() { spec.MaxNamespaces = 0 })
+	v.Unsupported("nvme_controller.spec.min_limit", spec.MinLimit != nil, func() { spec.MinLimit = nil })
+	v.Unsupported("nvme_controller.spec.max_limit", spec.MaxLimit != nil, func() { spec.MaxLimit = nil })
 }
 
 func (s *Server) validateDeleteNvmeControllerRequest(in *pb.DeleteNvmeControllerRequest) error {
+	return validateNameRequest(in, in.Name)
+}
+
+func (s *Server) validateUpdateNvmeControllerRequest(in *pb.UpdateNvmeControllerRequest) ([]string, error) {
+	v := validation.NewValidator(s.validationMode)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	if !v.RequiredFields(in) {
+		return nil, v.Err()
 	}
+	validateNvmeControllerSpec(v, in.NvmeController.Spec, false)
 	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	v.ResourceName("nvme_controller.name", in.NvmeController.Name)
+	return v.Warnings(), v.Err()
 }
 
-func (s *Server) validateUpdateNvmeControllerRequest(in *pb.UpdateNvmeControllerRequest) error {
+func (s *Server) validateListNvmeControllersRequest(in *pb.ListNvmeControllersRequest) error {
+	v := new(validation.Validator)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
+	v.RequiredFields(in)
+	return v.Err()
+}
 
-	if in.NvmeController.Spec.Trtype != pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE {
-		return fmt.Errorf("not supported transport type: %v", in.NvmeController.Spec.Trtype)
-	}
+func (s *Server) validateGetNvmeControllerRequest(in *pb.GetNvmeControllerRequest) error {
+	return validateNameRequest(in, in.Name)
+}
 
-	if in.NvmeController.Spec.GetPcieId() == nil {
-		return errors.New("invalid endpoint type passed for transport")
-	}
+func (s *Server) validateStatsNvmeControllerRequest(in *pb.StatsNvmeControllerRequest) error {
+	return validateNameRequest(in, in.Name)
+}
 
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.NvmeController.Name)
+func (s *Server) validateNvmeMiPassthruRequest(in *NvmeMiPassthruRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	v.ResourceName("name", in.Name)
+	// check the command only queries the drive
+	_, allowed := nvmeMiAllowedOpcodes[in.Opcode]
+	v.Check(allowed, "opcode", "NVMe-MI opcode (%#x) is not allowed", in.Opcode)
+	return v.Err()
 }
 
-func (s *Server) validateGetNvmeControllerRequest(in *pb.GetNvmeControllerRequest) error {
+func (s *Server) validateGetNvmeControllerOptionsRequest(in *GetNvmeControllerOptionsRequest) error {
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	return validateResourceName(in.Name)
 }
 
-func (s *Server) validateStatsNvmeControllerRequest(in *pb.StatsNvmeControllerRequest) error {
+func (s *Server) validateUpdateNvmeControllerOptionsRequest(in *UpdateNvmeControllerOptionsRequest) error {
+	v := new(validation.Validator)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	v.ResourceName("name", in.Name)
+	if !v.Required("options", in.Options != nil) {
+		return v.Err()
 	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	// check CMB and PMR sizes, the BARs are sized in powers of two
+	v.Check(isZeroOrPowerOfTwo(in.Options.CmbSizeMib), "options.cmb_size_mib",
+		"CmbSizeMib value (%d) have to be 0 or a power of 2", in.Options.CmbSizeMib)
+	v.Check(isZeroOrPowerOfTwo(in.Options.PmrSizeMib), "options.pmr_size_mib",
+		"PmrSizeMib value (%d) have to be 0 or a power of 2", in.Options.PmrSizeMib)
+	// check MSI-X vectors range, the MSI-X table has up to 2048 entries
+	v.Range("options.msix_vectors", int64(in.Options.MsixVectors), 0, 2048)
+	// check Interrupt Coalescing, both fields are 8 bits wide
+	if coalescing := in.Options.InterruptCoalescing; coalescing != nil {
+		v.Range("options.interrupt_coalescing.threshold", int64(coalescing.Threshold), 0, 255)
+		v.Check(coalescing.TimeUs >= 0 && coalescing.TimeUs <= 25500 && coalescing.TimeUs%100 == 0, "options.interrupt_coalescing.time_us",
+			"TimeUs value (%d) have to be a multiple of 100 between 0 and 25500", coalescing.TimeUs)
+	}
+	// check BootNsid, 0 disables boot
+	v.Check(in.Options.BootNsid >= 0, "options.boot_nsid", "BootNsid value (%d) have to be positive or 0", in.Options.BootNsid)
+	// check Keep Alive Timeout, the granularity advertised in KAS is 100 milliseconds on 16 bits
+	v.Check(in.Options.KeepAliveTimeoutMs >= 0 && in.Options.KeepAliveTimeoutMs <= 6553500 && in.Options.KeepAliveTimeoutMs%100 == 0,
+		"options.keep_alive_timeout_ms", "KeepAliveTimeoutMs value (%d) have to be a multiple of 100 between 0 and 6553500", in.Options.KeepAliveTimeoutMs)
+	// check Arbitration, the burst is 3 bits wide and the weights 8 bits wide
+	if arbitration := in.Options.Arbitration; arbitration != nil {
+		v.Range("options.arbitration.burst", int64(arbitration.Burst), 0, 7)
+		weights := []struct {
+			field  string
+			weight int32
+		}{
+			{"high_weight", arbitration.HighWeight},
+			{"medium_weight", arbitration.MediumWeight},
+			{"low_weight", arbitration.LowWeight},
+		}
+		for _, w := range weights {
+			v.Check(w.weight >= 0 && w.weight <= 255, "options.arbitration."+w.field,
+				"Weight value (%d) is out of range, have to be between 0 and 255", w.weight)
+		}
+	}
+	return v.Err()
+}
+
+func isZeroOrPowerOfTwo(v int32) bool {
+	return v >= 0 && v&(v-1) == 0
+}
+
+func (s *Server) validatePauseNvmeControllerRequest(in *PauseNvmeControllerRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateResumeNvmeControllerRequest(in *ResumeNvmeControllerRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateResetNvmeControllerRequest(in *ResetNvmeControllerRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateStatsNvmeControllerQueuesRequest(in *StatsNvmeControllerQueuesRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetNvmeControllerPcieStatusRequest(in *GetNvmeControllerPcieStatusRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateSaveNvmeControllerStateRequest(in *SaveNvmeControllerStateRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateRestoreNvmeControllerStateRequest(in *RestoreNvmeControllerStateRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	v.Required("state", in.State != nil && in.State.State != "")
+	v.ResourceName("name", in.Name)
+	return v.Err()
+}
+
+func (s *Server) validateSuspendNvmeControllerRequest(in *SuspendNvmeControllerRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetNvmePhysicalFunctionRequest(in *GetNvmePhysicalFunctionRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
 }
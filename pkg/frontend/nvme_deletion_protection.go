@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// NvmeDeletionProtection represents the deletion protection of an Nvme subsystem, controller or
+// namespace, which is not part of the OPI specs. A protected resource can't be deleted until the
+// protection is cleared by its own update, so an automation deleting the wrong resource doesn't
+// take down the ones serving the hosts in production
+type NvmeDeletionProtection struct {
+	// DeletionProtection makes the Delete calls of the resource fail
+	DeletionProtection bool `json:"deletionProtection"`
+}
+
+// GetNvmeDeletionProtectionRequest represents a request to get the deletion protection of a resource
+type GetNvmeDeletionProtectionRequest struct {
+	// Name of the Nvme subsystem, controller or namespace
+	Name string `json:"name"`
+}
+
+// UpdateNvmeDeletionProtectionRequest represents a request to set or clear the deletion
+// protection of a resource
+type UpdateNvmeDeletionProtectionRequest struct {
+	// Name of the Nvme subsystem, controller or namespace
+	Name string `json:"name"`
+	// DeletionProtection to set on the resource
+	DeletionProtection *NvmeDeletionProtection `json:"deletionProtection"`
+}
+
+// GetNvmeDeletionProtection gets the deletion protection of an Nvme subsystem, controller or namespace
+func (s *Server) GetNvmeDeletionProtection(_ context.Context, in *GetNvmeDeletionProtectionRequest) (*NvmeDeletionProtection, error) {
+	// check input correctness
+	if err := s.validateGetNvmeDeletionProtectionRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	if err := s.checkNvmeResourceExists(in.Name); err != nil {
+		return nil, err
+	}
+	return s.nvmeDeletionProtection(in.Name)
+}
+
+// UpdateNvmeDeletionProtection sets or clears the deletion protection of an Nvme subsystem,
+// controller or namespace
+func (s *Server) UpdateNvmeDeletionProtection(_ context.Context, in *UpdateNvmeDeletionProtectionRequest) (*NvmeDeletionProtection, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeDeletionProtectionRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	if err := s.checkNvmeResourceExists(in.Name); err != nil {
+		return nil, err
+	}
+	protection := *in.DeletionProtection
+	// save object to the database
+	if err := s.saveNvmeDeletionProtection(in.Name, &protection); err != nil {
+		return nil, err
+	}
+	return &protection, nil
+}
+
+// checkNvmeResourceExists checks an Nvme subsystem, controller or namespace is in the database
+func (s *Server) checkNvmeResourceExists(name string) error {
+	var resource proto.Message
+	subsysID := utils.GetSubsystemIDFromNvmeName(name)
+	switch id := path.Base(name); {
+	case isNvmeSubsystem(name, subsysID, id):
+		resource = new(pb.NvmeSubsystem)
+	case isNvmeController(name, subsysID, id):
+		resource = new(pb.NvmeController)
+	default:
+		resource = new(pb.NvmeNamespace)
+	}
+	found, err := s.store.Get(name, resource)
+	if err != nil {
+		return err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+		return err
+	}
+	return nil
+}
+
+// checkNvmeDeletionProtection refuses the deletion of a protected resource
+func (s *Server) checkNvmeDeletionProtection(name string) error {
+	protection, err := s.nvmeDeletionProtection(name)
+	if err != nil {
+		return err
+	}
+	if protection.DeletionProtection {
+		msg := fmt.Sprintf("%s is protected from deletion, its deletion protection has to be cleared first", name)
+		return status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return nil
+}
+
+// nvmeDeletionProtectionKey is the database key of the deletion protection of a resource
+func nvmeDeletionProtectionKey(name string) string {
+	return name + "/deletionProtection"
+}
+
+// nvmeDeletionProtection fetches the deletion protection of a resource from the database, the
+// resources are not protected unless set, it is not a protobuf so it is stored JSON encoded
+func (s *Server) nvmeDeletionProtection(name string) (*NvmeDeletionProtection, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(nvmeDeletionProtectionKey(name), value)
+	if err != nil {
+		return nil, err
+	}
+	protection := new(NvmeDeletionProtection)
+	if !found {
+		return protection, nil
+	}
+	if err := json.Unmarshal(value.Value, protection); err != nil {
+		return nil, err
+	}
+	return protection, nil
+}
+
+func (s *Server) saveNvmeDeletionProtection(name string, protection *NvmeDeletionProtection) error {
+	data, err := json.Marshal(protection)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(nvmeDeletionProtectionKey(name), wrapperspb.Bytes(data))
+}
+
+func (s *Server) deleteNvmeDeletionProtection(name string) error {
+	return s.store.Delete(nvmeDeletionProtectionKey(name))
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestFrontEnd_UpdateNvmeDeletionProtection(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testUnknownControllerName := utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")
+	tests := map[string]struct {
+		in      *UpdateNvmeDeletionProtectionRequest
+		out     *NvmeDeletionProtection
+		errCode codes.Code
+		errMsg  string
+	}{
+		"protected subsystem": {
+			in:      &UpdateNvmeDeletionProtectionRequest{Name: testSubsystemName, DeletionProtection: &NvmeDeletionProtection{DeletionProtection: true}},
+			out:     &NvmeDeletionProtection{DeletionProtection: true},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"protected controller": {
+			in:      &UpdateNvmeDeletionProtectionRequest{Name: testControllerName, DeletionProtection: &NvmeDeletionProtection{DeletionProtection: true}},
+			out:     &NvmeDeletionProtection{DeletionProtection: true},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unprotected namespace": {
+			in:      &UpdateNvmeDeletionProtectionRequest{Name: testNamespaceName, DeletionProtection: &NvmeDeletionProtection{}},
+			out:     &NvmeDeletionProtection{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown controller": {
+			in:      &UpdateNvmeDeletionProtectionRequest{Name: testUnknownControllerName, DeletionProtection: &NvmeDeletionProtection{DeletionProtection: true}},
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", testUnknownControllerName),
+		},
+		"not an Nvme resource": {
+			in:      &UpdateNvmeDeletionProtectionRequest{Name: "nvmePhysicalFunctions/port0-pf1", DeletionProtection: &NvmeDeletionProtection{DeletionProtection: true}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "nvmePhysicalFunctions/port0-pf1 is not an Nvme subsystem, controller or namespace",
+		},
+		"no required deletion protection field": {
+			in:      &UpdateNvmeDeletionProtectionRequest{Name: testControllerName},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: deletion_protection",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+
+			response, err := testEnv.opiSpdkServer.UpdateNvmeDeletionProtection(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			if tt.errCode == codes.OK {
+				protection, _ := testEnv.opiSpdkServer.GetNvmeDeletionProtection(testEnv.ctx, &GetNvmeDeletionProtectionRequest{Name: tt.in.Name})
+				if !reflect.DeepEqual(protection, tt.out) {
+					t.Error("saved deletion protection: expected", tt.out, "received", protection)
+				}
+			}
+		})
+	}
+}
+
+func TestFrontEnd_DeleteProtectedNvmeResources(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		name   string
+		delete func(testEnv *testEnv) error
+	}{
+		"subsystem": {
+			name: testSubsystemName,
+			delete: func(testEnv *testEnv) error {
+				_, err := testEnv.opiSpdkServer.DeleteNvmeSubsystem(testEnv.ctx, &pb.DeleteNvmeSubsystemRequest{Name: testSubsystemName})
+				return err
+			},
+		},
+		"controller": {
+			name: testControllerName,
+			delete: func(testEnv *testEnv) error {
+				_, err := testEnv.opiSpdkServer.DeleteNvmeController(testEnv.ctx, &pb.DeleteNvmeControllerRequest{Name: testControllerName})
+				return err
+			},
+		},
+		"namespace": {
+			name: testNamespaceName,
+			delete: func(testEnv *testEnv) error {
+				_, err := testEnv.opiSpdkServer.DeleteNvmeNamespace(testEnv.ctx, &pb.DeleteNvmeNamespaceRequest{Name: testNamespaceName})
+				return err
+			},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// only the deletion once unprotected reaches the firmware
+			testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`})
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.ListHelper[tt.name] = false
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+			_ = testEnv.opiSpdkServer.saveNvmeDeletionProtection(tt.name, &NvmeDeletionProtection{DeletionProtection: true})
+
+			err := tt.delete(testEnv)
+			er, _ := status.FromError(err)
+			if er.Code() != codes.FailedPrecondition {
+				t.Error("error code: expected", codes.FailedPrecondition, "received", er.Code())
+			}
+			expectedMsg := fmt.Sprintf("%v is protected from deletion, its deletion protection has to be cleared first", tt.name)
+			if er.Message() != expectedMsg {
+				t.Error("error message: expected", expectedMsg, "received", er.Message())
+			}
+
+			_, err = testEnv.opiSpdkServer.UpdateNvmeDeletionProtection(testEnv.ctx, &UpdateNvmeDeletionProtectionRequest{Name: tt.name, DeletionProtection: &NvmeDeletionProtection{}})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if err := tt.delete(testEnv); err != nil {
+				t.Error("unexpected error", err)
+			}
+			if found, _ := testEnv.opiSpdkServer.store.Get(nvmeDeletionProtectionKey(tt.name), new(wrapperspb.BytesValue)); found {
+				t.Error("deletion protection: expected to be deleted with", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"path"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func (s *Server) validateGetNvmeDeletionProtectionRequest(in *GetNvmeDeletionProtectionRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	v.ResourceName("name", in.Name)
+	checkNvmeProtectableName(v, in.Name)
+	return v.Err()
+}
+
+func (s *Server) validateUpdateNvmeDeletionProtectionRequest(in *UpdateNvmeDeletionProtectionRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	v.ResourceName("name", in.Name)
+	checkNvmeProtectableName(v, in.Name)
+	v.Required("deletion_protection", in.DeletionProtection != nil)
+	return v.Err()
+}
+
+// checkNvmeProtectableName checks a name is the one of an Nvme subsystem, controller or namespace
+func checkNvmeProtectableName(v *validation.Validator, name string) {
+	subsysID := utils.GetSubsystemIDFromNvmeName(name)
+	id := path.Base(name)
+	v.Check(isNvmeSubsystem(name, subsysID, id) || isNvmeController(name, subsysID, id) || isNvmeNamespace(name, subsysID, id),
+		"name", "%s is not an Nvme subsystem, controller or namespace", name)
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// DeleteNvmeResources deletes all the namespaces, the controllers and the subsystems, in the
+// firmware and in the store, i.e. to bring the storage configuration back to its factory state.
+// The resources which can't be deleted are kept, their errors are returned
+func (s *Server) DeleteNvmeResources(ctx context.Context) error {
+	var errs []error
+	for _, name := range s.replayNames(isNvmeNamespace) {
+		_, err := s.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{Name: name, AllowMissing: true})
+		errs = append(errs, err)
+	}
+	for _, name := range s.replayNames(isNvmeController) {
+		_, err := s.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{Name: name, AllowMissing: true})
+		errs = append(errs, err)
+	}
+	for _, name := range s.replayNames(func(name string, subsysID string, id string) bool {
+		return name == utils.ResourceIDToSubsystemName(id)
+	}) {
+		_, err := s.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: name, AllowMissing: true})
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
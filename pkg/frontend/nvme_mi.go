@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// nvmeMiAllowedOpcodes lists the NVMe-MI commands which can be tunneled through the bridge,
+// only commands that query the drive (data structures, health, configuration, VPD) are allowed
+var nvmeMiAllowedOpcodes = map[int32]string{
+	0x00: "Read NVMe-MI Data Structure",
+	0x01: "NVM Subsystem Health Status Poll",
+	0x02: "Controller Health Status Poll",
+	0x04: "Configuration Get",
+	0x05: "VPD Read",
+	0x08: "SES Receive",
+	0x0a: "Management Endpoint Buffer Read",
+}
+
+// NvmeMiPassthruRequest represents an NVMe-MI command tunneled to an Nvme controller
+type NvmeMiPassthruRequest struct {
+	// Name of the Nvme controller the command is sent to
+	Name string `json:"name"`
+	// Opcode of the NVMe-MI command
+	Opcode int32 `json:"opcode"`
+	// Dword0 of the NVMe-MI request message
+	Dword0 uint32 `json:"dword0"`
+	// Dword1 of the NVMe-MI request message
+	Dword1 uint32 `json:"dword1"`
+	// Data is the optional request data
+	Data []byte `json:"data"`
+}
+
+// NvmeMiPassthruResponse represents an NVMe-MI command response
+type NvmeMiPassthruResponse struct {
+	// Status of the NVMe-MI response message
+	Status int32 `json:"status"`
+	// Data returned by the NVMe-MI command
+	Data []byte `json:"data"`
+}
+
+// NvmeMiPassthru sends an NVMe-MI command to an Nvme controller
+func (s *Server) NvmeMiPassthru(ctx context.Context, in *NvmeMiPassthruRequest) (*NvmeMiPassthruResponse, error) {
+	// check input correctness
+	if err := s.validateNvmeMiPassthruRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, subsys, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmCtrlrMiSendParams{
+		Subnqn:  subsys.Spec.Nqn,
+		CtrlrID: int(*controller.Spec.NvmeControllerId),
+		Opcode:  int(in.Opcode),
+		Cdw0:    in.Dword0,
+		Cdw1:    in.Dword1,
+		Data:    in.Data,
+	}
+	var result models.MrvlNvmCtrlrMiSendResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_mi_send", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("Received NVMe-MI response", "status", result.Status, "nmresp", result.Nmresp)
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not send NVMe-MI %s to CTRL: %s", nvmeMiAllowedOpcodes[in.Opcode], in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_mi_send")
+	}
+	return &NvmeMiPassthruResponse{Status: int32(result.Nmresp), Data: result.Data}, nil
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_NvmeMiPassthru(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in      *NvmeMiPassthruRequest
+		out     *NvmeMiPassthruResponse
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &NvmeMiPassthruRequest{Name: testControllerName, Opcode: 0x05},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not send NVMe-MI VPD Read to CTRL: %v", testControllerName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &NvmeMiPassthruRequest{Name: testControllerName, Opcode: 0x05},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_mi_send: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      &NvmeMiPassthruRequest{Name: testControllerName, Opcode: 0x05},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_mi_send: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &NvmeMiPassthruRequest{Name: testControllerName, Opcode: 0x05},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ctrlr_mi_send: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: &NvmeMiPassthruRequest{Name: testControllerName, Opcode: 0x05, Dword0: 0, Dword1: 2},
+			out: &NvmeMiPassthruResponse{
+				Status: 0,
+				Data:   []byte{0x01, 0x02},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "nmresp": 0, "data": "AQI="}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"not allowed opcode": {
+			in:      &NvmeMiPassthruRequest{Name: testControllerName, Opcode: 0x06},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "NVMe-MI opcode (0x6) is not allowed",
+		},
+		"valid request with unknown key": {
+			in:      &NvmeMiPassthruRequest{Name: utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id"), Opcode: 0x01},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+		},
+		"malformed name": {
+			in:      &NvmeMiPassthruRequest{Name: "-ABC-DEF", Opcode: 0x01},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &NvmeMiPassthruRequest{Opcode: 0x01},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+
+			response, err := testEnv.opiSpdkServer.NvmeMiPassthru(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
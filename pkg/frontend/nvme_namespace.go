@@ -9,18 +9,16 @@ package frontend
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"path"
 	"sort"
 	"strconv"
-	"strings"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/consistency"
 	"github.com/opiproject/opi-marvell-bridge/pkg/models"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 
-	"github.com/google/uuid"
-	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
 	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
@@ -43,7 +41,7 @@ func (s *Server) CreateNvmeNamespace(ctx context.Context, in *pb.CreateNvmeNames
 	// see https://google.aip.dev/133#user-specified-ids
 	resourceID := resourceid.NewSystemGenerated()
 	if in.NvmeNamespaceId != "" {
-		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NvmeNamespaceId, in.NvmeNamespace.Name)
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.NvmeNamespaceId, "name", in.NvmeNamespace.Name)
 		resourceID = in.NvmeNamespaceId
 	}
 	in.NvmeNamespace.Name = utils.ResourceIDToNamespaceName(
@@ -56,7 +54,7 @@ func (s *Server) CreateNvmeNamespace(ctx context.Context, in *pb.CreateNvmeNames
 		return nil, err
 	}
 	if found {
-		log.Printf("Already existing NvmeNamespace with id %v", in.NvmeNamespace.Name)
+		slog.Info("Already existing NvmeNamespace", "name", in.NvmeNamespace.Name)
 		return namespace, nil
 	}
 	// not found, so create a new one
@@ -69,56 +67,76 @@ func (s *Server) CreateNvmeNamespace(ctx context.Context, in *pb.CreateNvmeNames
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Parent)
 		return nil, err
 	}
+	// hosts identify the namespaces by their UUID, NGUID and EUI64, they have to be unique
+	if err := s.checkNvmeNamespaceIdentifiersUnique(in.NvmeNamespace.Spec, in.NvmeNamespace.Name); err != nil {
+		return nil, err
+	}
+	// a namespace referencing a storage pool is placed on one of its volumes by the bridge
+	pool := in.NvmeNamespace.Spec.VolumeNameRef
+	if !isStoragePoolName(pool) {
+		return s.createNvmeNamespace(ctx, in, subsys)
+	}
+	if s.volumePlacer == nil {
+		msg := fmt.Sprintf("Storage pools are not enabled, can't place NS %s on %s", in.NvmeNamespace.Name, pool)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	volume, err := s.volumePlacer.PlaceVolume(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	in.NvmeNamespace.Spec.VolumeNameRef = volume
+	response, err := s.createNvmeNamespace(ctx, in, subsys)
+	if err != nil {
+		if err := s.volumePlacer.ReleaseVolume(ctx, pool, volume); err != nil {
+			slog.Warn("Could not release volume", "volume", volume, "pool", pool, "error", err)
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// createNvmeNamespace creates an Nvme namespace on the volume it references
+func (s *Server) createNvmeNamespace(ctx context.Context, in *pb.CreateNvmeNamespaceRequest, subsys *pb.NvmeSubsystem) (*pb.NvmeNamespace, error) {
+	// the tenant owning the subsystem has to be under its quotas
+	if err := s.checkNvmeNamespaceQuotas(ctx, in.Parent, in.NvmeNamespace.Spec.VolumeNameRef); err != nil {
+		return nil, err
+	}
+	if err := s.checkSkuLimit(ctx, "nvmeNamespaces"); err != nil {
+		return nil, err
+	}
+	// the namespace is shared and attached to all the controllers unless its sharing was set
+	sharing, err := s.nvmeNamespaceSharing(in.NvmeNamespace.Name)
+	if err != nil {
+		return nil, err
+	}
+	controllers, err := s.sharedNvmeControllers(subsys, sharing)
+	if err != nil {
+		return nil, err
+	}
 	// TODO: do lookup through VolumeId key instead of using it's value
 	params := models.MrvlNvmSubsysAllocNsParams{
-		Subnqn:      subsys.Spec.Nqn,
-		Nguid:       in.NvmeNamespace.Spec.Nguid,
-		Eui64:       strconv.FormatInt(in.NvmeNamespace.Spec.Eui64, 10),
-		UUID:        in.NvmeNamespace.Spec.Uuid,
-		ShareEnable: 1,
-		Bdev:        in.NvmeNamespace.Spec.VolumeNameRef,
+		Subnqn:        subsys.Spec.Nqn,
+		Nguid:         in.NvmeNamespace.Spec.Nguid,
+		Eui64:         strconv.FormatInt(in.NvmeNamespace.Spec.Eui64, 10),
+		UUID:          in.NvmeNamespace.Spec.Uuid,
+		ShareEnable:   boolToInt(sharing.Shared),
+		Bdev:          in.NvmeNamespace.Spec.VolumeNameRef,
+		ThinProvision: boolToInt(s.thinProvisioning),
 	}
 	var result models.MrvlNvmSubsysAllocNsResult
 	err = s.rpc.Call(ctx, "mrvl_nvm_subsys_alloc_ns", &params, &result)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not create NS: %s", in.NvmeNamespace.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_alloc_ns")
 	}
-	// Now, attach this new NS to ALL controllers
-	for key := range s.ListHelper {
-		if !strings.HasPrefix(key, subsys.Name+"/nvmeControllers") {
-			continue
-		}
-		c := new(pb.NvmeController)
-		ok, err := s.store.Get(key, c)
-		if err != nil {
-			return nil, err
-		}
-		if !ok {
-			err := status.Errorf(codes.NotFound, "unable to find key %s", key)
+	// Now, attach this new NS to its controllers
+	for _, c := range controllers {
+		if err := s.attachNvmeNamespace(ctx, subsys, c, in.NvmeNamespace); err != nil {
 			return nil, err
 		}
-		if utils.GetSubsystemIDFromNvmeName(c.Name) != utils.GetSubsystemIDFromNvmeName(in.Parent) {
-			continue
-		}
-		params := models.MrvlNvmCtrlrAttachNsParams{
-			Subnqn:       subsys.Spec.Nqn,
-			CtrlrID:      int(*c.Spec.NvmeControllerId),
-			NsInstanceID: int(in.NvmeNamespace.Spec.HostNsid),
-		}
-		var result models.MrvlNvmCtrlrAttachNsResult
-		err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_attach_ns", &params, &result)
-		if err != nil {
-			return nil, err
-		}
-		if result.Status != 0 {
-			msg := fmt.Sprintf("Could not attach NS: %s", in.NvmeNamespace.Name)
-			return nil, status.Errorf(codes.InvalidArgument, msg)
-		}
 	}
 	response := utils.ProtoClone(in.NvmeNamespace)
 	response.Status = &pb.NvmeNamespaceStatus{
@@ -153,6 +171,10 @@ func (s *Server) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNames
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
+	// protected resources are only deleted once their protection is cleared
+	if err := s.checkNvmeDeletionProtection(in.Name); err != nil {
+		return nil, err
+	}
 	subsysName := utils.ResourceIDToSubsystemName(
 		utils.GetSubsystemIDFromNvmeName(in.Name),
 	)
@@ -166,38 +188,19 @@ func (s *Server) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNames
 		err := status.Errorf(codes.NotFound, "unable to find subsystem %s", subsysName)
 		return nil, err
 	}
-	// First, detach this NS from ALL controllers
-	for key := range s.ListHelper {
-		if !strings.HasPrefix(key, subsys.Name+"/nvmeControllers") {
-			continue
-		}
-		c := new(pb.NvmeController)
-		ok, err := s.store.Get(key, c)
-		if err != nil {
-			return nil, err
-		}
-		if !ok {
-			err := status.Errorf(codes.NotFound, "unable to find key %s", key)
-			return nil, err
-		}
-		if utils.GetSubsystemIDFromNvmeName(c.Name) != utils.GetSubsystemIDFromNvmeName(in.Name) {
-			continue
-		}
-		params := models.MrvlNvmCtrlrDetachNsParams{
-			Subnqn:       subsys.Spec.Nqn,
-			CtrlrID:      int(*c.Spec.NvmeControllerId),
-			NsInstanceID: int(namespace.Spec.HostNsid),
-		}
-		var result models.MrvlNvmCtrlrDetachNsResult
-		err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_detach_ns", &params, &result)
-		if err != nil {
+	// First, detach this NS from its controllers
+	sharing, err := s.nvmeNamespaceSharing(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	controllers, err := s.sharedNvmeControllers(subsys, sharing)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range controllers {
+		if err := s.detachNvmeNamespace(ctx, subsys, c, namespace); err != nil {
 			return nil, err
 		}
-		log.Printf("Received from SPDK: %v", result)
-		if result.Status != 0 {
-			msg := fmt.Sprintf("Could not detach NS: %s", in.Name)
-			return nil, status.Errorf(codes.InvalidArgument, msg)
-		}
 	}
 	params := models.MrvlNvmSubsysUnallocNsParams{
 		Subnqn:       subsys.Spec.Nqn,
@@ -208,10 +211,9 @@ func (s *Server) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNames
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not delete NS: %s", in.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_unalloc_ns")
 	}
 	// remove from the Database
 	delete(s.ListHelper, namespace.Name)
@@ -219,6 +221,15 @@ func (s *Server) DeleteNvmeNamespace(ctx context.Context, in *pb.DeleteNvmeNames
 	if err != nil {
 		return nil, err
 	}
+	s.forgetClearedStats(namespace.Name)
+	err = s.deleteNvmeNamespaceSharing(namespace.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.deleteNvmeDeletionProtection(namespace.Name)
+	if err != nil {
+		return nil, err
+	}
 	return &emptypb.Empty{}, nil
 }
 
@@ -236,7 +247,7 @@ func (s *Server) UpdateNvmeNamespace(_ context.Context, in *pb.UpdateNvmeNamespa
 	}
 	if !found {
 		if in.AllowMissing {
-			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
+			slog.Debug("TODO: in case of AllowMissing, create a new resource, don;t return error")
 		}
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmeNamespace.Name)
 		return nil, err
@@ -246,18 +257,18 @@ func (s *Server) UpdateNvmeNamespace(_ context.Context, in *pb.UpdateNvmeNamespa
 	if err := fieldmask.Validate(in.UpdateMask, in.NvmeNamespace); err != nil {
 		return nil, err
 	}
-	log.Printf("TODO: use resourceID=%v", resourceID)
+	slog.Debug("TODO: use resourceID", "id", resourceID)
 	return nil, status.Errorf(codes.Unimplemented, "UpdateNvmeNamespace method is not implemented")
 }
 
 // ListNvmeNamespaces lists Nvme namespaces
 func (s *Server) ListNvmeNamespaces(ctx context.Context, in *pb.ListNvmeNamespacesRequest) (*pb.ListNvmeNamespacesResponse, error) {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+	// check input correctness
+	if err := s.validateListNvmeNamespacesRequest(in); err != nil {
 		return nil, err
 	}
 	// fetch object from the database
-	size, offset, perr := utils.ExtractPagination(in.PageSize, in.PageToken, s.Pagination)
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
 	if perr != nil {
 		return nil, perr
 	}
@@ -271,6 +282,13 @@ func (s *Server) ListNvmeNamespaces(ctx context.Context, in *pb.ListNvmeNamespac
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Parent)
 		return nil, err
 	}
+	if consistency.FromContext(ctx) == consistency.Cached {
+		namespaces, token, err := storedNvmeResources(s, isNvmeNamespace, path.Base(in.Parent), func() *pb.NvmeNamespace { return new(pb.NvmeNamespace) }, sortNvmeNamespaces, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ListNvmeNamespacesResponse{NvmeNamespaces: namespaces, NextPageToken: token}, nil
+	}
 	params := models.MrvlNvmSubsysGetNsListParams{
 		Subnqn: subsys.Spec.Nqn,
 	}
@@ -279,17 +297,15 @@ func (s *Server) ListNvmeNamespaces(ctx context.Context, in *pb.ListNvmeNamespac
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not list NS: %s", in.Parent)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_get_ns_list")
 	}
 	token, hasMoreElements := "", false
-	log.Printf("Limiting result len(%d) to [%d:%d]", len(result.NsList), offset, size)
+	slog.Debug("Limiting result", "len", len(result.NsList), "offset", offset, "size", size)
 	result.NsList, hasMoreElements = utils.LimitPagination(result.NsList, offset, size)
 	if hasMoreElements {
-		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		token = s.Pagination.NewToken(offset + size)
 	}
 	Blobarray := make([]*pb.NvmeNamespace, len(result.NsList))
 	for i := range result.NsList {
@@ -297,7 +313,7 @@ func (s *Server) ListNvmeNamespaces(ctx context.Context, in *pb.ListNvmeNamespac
 		Blobarray[i] = &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{HostNsid: int32(r.NsInstanceID)}}
 	}
 	sortNvmeNamespaces(Blobarray)
-	return &pb.ListNvmeNamespacesResponse{NvmeNamespaces: Blobarray}, nil
+	return &pb.ListNvmeNamespacesResponse{NvmeNamespaces: Blobarray, NextPageToken: token}, nil
 }
 
 // GetNvmeNamespace gets an Nvme namespace
@@ -316,7 +332,7 @@ func (s *Server) GetNvmeNamespace(ctx context.Context, in *pb.GetNvmeNamespaceRe
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
-	log.Printf("namespace: %v", namespace)
+	slog.Debug("Fetched namespace", "namespace", namespace)
 	subsysName := utils.ResourceIDToSubsystemName(
 		utils.GetSubsystemIDFromNvmeName(in.Name),
 	)
@@ -330,6 +346,9 @@ func (s *Server) GetNvmeNamespace(ctx context.Context, in *pb.GetNvmeNamespaceRe
 		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
 		return nil, err
 	}
+	if consistency.FromContext(ctx) == consistency.Cached {
+		return namespace, nil
+	}
 
 	params := models.MrvlNvmGetNsInfoParams{
 		SubNqn:       subsys.Spec.Nqn,
@@ -340,20 +359,22 @@ func (s *Server) GetNvmeNamespace(ctx context.Context, in *pb.GetNvmeNamespaceRe
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not get NS: %s", in.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
-	}
-	return &pb.NvmeNamespace{Name: in.Name,
-		Spec: &pb.NvmeNamespaceSpec{
-			Nguid: result.Nguid,
-		},
-		Status: &pb.NvmeNamespaceStatus{
-			State:     pb.NvmeNamespaceStatus_STATE_ENABLED,
-			OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE,
-		},
-	}, nil
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ns_get_info")
+	}
+	// the namespace is returned as created, so its spec round-trips, with the identifiers assigned
+	// by the firmware when none were given, persisted so they stay the same across reattach
+	if adoptNvmeNamespaceIdentifiers(namespace.Spec, result.Nguid, result.Eui64, result.UUID) {
+		if err := s.store.Set(namespace.Name, namespace); err != nil {
+			return nil, err
+		}
+	}
+	namespace.Status = &pb.NvmeNamespaceStatus{
+		State:     pb.NvmeNamespaceStatus_STATE_ENABLED,
+		OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE,
+	}
+	return namespace, nil
 }
 
 // StatsNvmeNamespace gets an Nvme namespace stats
@@ -395,17 +416,17 @@ func (s *Server) StatsNvmeNamespace(ctx context.Context, in *pb.StatsNvmeNamespa
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not stats NS: %s", in.Name)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_ns_stats")
 	}
-	return &pb.StatsNvmeNamespaceResponse{Stats: &pb.VolumeStats{
+	stats := &pb.VolumeStats{
 		ReadBytesCount:    int32(result.NumReadBytes),
 		ReadOpsCount:      int32(result.NumReadCmds),
 		WriteBytesCount:   int32(result.NumWriteBytes),
 		WriteOpsCount:     int32(result.NumWriteCmds),
 		ReadLatencyTicks:  int32(result.TotalReadLatencyInUs),
 		WriteLatencyTicks: int32(result.TotalWriteLatencyInUs),
-	}}, nil
+	}
+	return &pb.StatsNvmeNamespaceResponse{Stats: s.statsSinceCleared(in.Name, stats)}, nil
 }
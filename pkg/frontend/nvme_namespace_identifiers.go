@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// normalizeNguid returns the 32 hex digits of an NGUID, given with or without a 0x prefix
+// and dashes, so the same NGUID is found whatever the way it was written
+func normalizeNguid(nguid string) (string, error) {
+	digits := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(nguid, "0x"), "-", ""))
+	if len(digits) != 32 {
+		return "", fmt.Errorf("NGUID %s has to be 32 hex digits", nguid)
+	}
+	if _, err := hex.DecodeString(digits); err != nil {
+		return "", fmt.Errorf("NGUID %s has to be 32 hex digits", nguid)
+	}
+	return digits, nil
+}
+
+// normalizeNamespaceUUID returns the canonical form of a UUID, the firmware reports them as
+// 32 hex digits with a 0x prefix
+func normalizeNamespaceUUID(id string) (string, error) {
+	value := id
+	if digits, err := normalizeNguid(id); err == nil {
+		value = digits
+	}
+	parsed, err := uuid.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("UUID %s is not valid: %v", id, err)
+	}
+	return parsed.String(), nil
+}
+
+// checkNvmeNamespaceIdentifiersUnique checks no other namespace of the bridge uses the UUID,
+// NGUID or EUI64 of a new namespace, the hosts would see the namespaces as paths to the same one
+func (s *Server) checkNvmeNamespaceIdentifiersUnique(spec *pb.NvmeNamespaceSpec, name string) error {
+	for _, other := range s.replayNames(isNvmeNamespace) {
+		if other == name {
+			continue
+		}
+		namespace := new(pb.NvmeNamespace)
+		found, err := s.store.Get(other, namespace)
+		if err != nil {
+			return err
+		}
+		if !found || namespace.Spec == nil {
+			continue
+		}
+		if identifier := sharedNvmeNamespaceIdentifier(spec, namespace.Spec); identifier != "" {
+			msg := fmt.Sprintf("%s of NS %s is already used by NS %s", identifier, name, other)
+			return status.Errorf(codes.AlreadyExists, msg)
+		}
+	}
+	return nil
+}
+
+// sharedNvmeNamespaceIdentifier returns which identifier two namespaces have in common, if any
+func sharedNvmeNamespaceIdentifier(spec *pb.NvmeNamespaceSpec, other *pb.NvmeNamespaceSpec) string {
+	if spec.Uuid != "" && other.Uuid != "" {
+		id, err := normalizeNamespaceUUID(spec.Uuid)
+		otherID, otherErr := normalizeNamespaceUUID(other.Uuid)
+		if err == nil && otherErr == nil && id == otherID {
+			return "UUID " + spec.Uuid
+		}
+	}
+	if spec.Nguid != "" && other.Nguid != "" {
+		nguid, err := normalizeNguid(spec.Nguid)
+		otherNguid, otherErr := normalizeNguid(other.Nguid)
+		if err == nil && otherErr == nil && nguid == otherNguid {
+			return "NGUID " + spec.Nguid
+		}
+	}
+	if spec.Eui64 != 0 && spec.Eui64 == other.Eui64 {
+		return "EUI64 " + strconv.FormatInt(spec.Eui64, 10)
+	}
+	return ""
+}
+
+// adoptNvmeNamespaceIdentifiers fills the identifiers a namespace was created without with the
+// ones assigned by the firmware, and tells whether any was, so they can be persisted and the
+// hosts see the same identifiers when the namespace is attached again
+func adoptNvmeNamespaceIdentifiers(spec *pb.NvmeNamespaceSpec, nguid string, eui64 string, id string) bool {
+	adopted := false
+	if spec.Nguid == "" && nguid != "" {
+		spec.Nguid = nguid
+		adopted = true
+	}
+	if spec.Eui64 == 0 && eui64 != "" {
+		if value, err := strconv.ParseUint(eui64, 0, 64); err == nil && value != 0 {
+			spec.Eui64 = int64(value)
+			adopted = true
+		}
+	}
+	if spec.Uuid == "" && id != "" {
+		if value, err := normalizeNamespaceUUID(id); err == nil {
+			spec.Uuid = value
+			adopted = true
+		}
+	}
+	return adopted
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_CreateNvmeNamespaceIdentifiers(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	otherName := utils.ResourceIDToNamespaceName(testSubsystemID, "other-namespace-id")
+	other := &pb.NvmeNamespace{
+		Name: otherName,
+		Spec: &pb.NvmeNamespaceSpec{
+			HostNsid:      21,
+			VolumeNameRef: "Malloc0",
+			Uuid:          "1b4e28ba-2fa1-11d2-883f-b9a761bde3fb",
+			Nguid:         "0x25f9cbc45d0f976fb9c1a14ff5aed4b0",
+			Eui64:         1967554867335598546,
+		},
+	}
+	tests := map[string]struct {
+		in      *pb.NvmeNamespaceSpec
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"malformed UUID": {
+			in:      &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1", Uuid: "not-a-uuid"},
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "UUID not-a-uuid is not valid: invalid UUID length: 10",
+		},
+		"malformed NGUID": {
+			in:      &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1", Nguid: "0x25f9cbc4"},
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "NGUID 0x25f9cbc4 has to be 32 hex digits",
+		},
+		"UUID already used": {
+			in:      &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1", Uuid: "1B4E28BA-2FA1-11D2-883F-B9A761BDE3FB"},
+			spdk:    []string{},
+			errCode: codes.AlreadyExists,
+			errMsg:  fmt.Sprintf("UUID 1B4E28BA-2FA1-11D2-883F-B9A761BDE3FB of NS %s is already used by NS %s", testNamespaceName, otherName),
+		},
+		"NGUID already used written differently": {
+			in:      &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1", Nguid: "25f9cbc4-5d0f-976f-b9c1-a14ff5aed4b0"},
+			spdk:    []string{},
+			errCode: codes.AlreadyExists,
+			errMsg:  fmt.Sprintf("NGUID 25f9cbc4-5d0f-976f-b9c1-a14ff5aed4b0 of NS %s is already used by NS %s", testNamespaceName, otherName),
+		},
+		"EUI64 already used": {
+			in:      &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1", Eui64: 1967554867335598546},
+			spdk:    []string{},
+			errCode: codes.AlreadyExists,
+			errMsg:  fmt.Sprintf("EUI64 1967554867335598546 of NS %s is already used by NS %s", testNamespaceName, otherName),
+		},
+		"unique identifiers": {
+			in:      &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: "Malloc1", Uuid: "b3563324-0b77-073b-8b4e-bda571120dfb", Nguid: "0x0123456789abcdef0123456789abcdef", Eui64: 42},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ns_instance_id": 17}}`, `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			testEnv.opiSpdkServer.ListHelper[otherName] = false
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(otherName, other)
+
+			request := &pb.CreateNvmeNamespaceRequest{Parent: testSubsystemName, NvmeNamespaceId: testNamespaceID,
+				NvmeNamespace: &pb.NvmeNamespace{Spec: tt.in}}
+			response, err := testEnv.client.CreateNvmeNamespace(testEnv.ctx, request)
+
+			if tt.errCode == codes.OK && response.GetSpec().GetUuid() != tt.in.Uuid {
+				t.Error("response: expected UUID", tt.in.Uuid, "received", response)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if er.Message() != tt.errMsg {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+		})
+	}
+}
+
+func TestFrontEnd_GetNvmeNamespacePersistsIdentifiers(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"jsonrpc":"2.0","id":%d,"result":{"status":0,"nguid":"0x25f9cbc45d0f976fb9c1a14ff5aed4b0","eui64":"0x00000000000000ff","uuid":"0xb35633240b77073b8b4ebda571120dfb","nmic":1,"bdev":"bdev01","num_ctrlrs":1,"ctrlr_id_list":[{"ctrlr_id":1}]}}`,
+	})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+
+	_, err := testEnv.client.GetNvmeNamespace(testEnv.ctx, &pb.GetNvmeNamespaceRequest{Name: testNamespaceName})
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	stored := new(pb.NvmeNamespace)
+	found, err := testEnv.opiSpdkServer.store.Get(testNamespaceName, stored)
+	if err != nil || !found {
+		t.Fatal("expected the namespace to be stored, received", err)
+	}
+	if stored.Spec.Uuid != "b3563324-0b77-073b-8b4e-bda571120dfb" || stored.Spec.Nguid != "0x25f9cbc45d0f976fb9c1a14ff5aed4b0" || stored.Spec.Eui64 != 255 {
+		t.Error("expected the identifiers assigned by the firmware to be persisted, received", stored.Spec)
+	}
+}
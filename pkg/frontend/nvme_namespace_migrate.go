@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// States of a namespace migration reported by the firmware
+const (
+	migrationStateCopying = "copying"
+	migrationStateSynced  = "synced"
+	migrationStateAborted = "aborted"
+)
+
+// MigrateNvmeNamespaceRequest represents a request to move an Nvme namespace to another volume
+type MigrateNvmeNamespaceRequest struct {
+	// Name of the Nvme namespace
+	Name string `json:"name"`
+	// VolumeNameRef is the volume the namespace is moved to, i.e. on another backend
+	VolumeNameRef string `json:"volumeNameRef"`
+}
+
+// MigrateNvmeNamespaceMetadata describes a migration operation
+type MigrateNvmeNamespaceMetadata struct {
+	// Name of the Nvme namespace
+	Name string `json:"name"`
+	// SourceVolumeNameRef is the volume the namespace is moved from
+	SourceVolumeNameRef string `json:"sourceVolumeNameRef"`
+	// VolumeNameRef is the volume the namespace is moved to
+	VolumeNameRef string `json:"volumeNameRef"`
+}
+
+// MigrateNvmeNamespace moves the data of an Nvme namespace to another volume, returning a
+// long-running operation. The firmware copies the data in the background while mirroring
+// the host writes, then briefly pauses the namespace to switch to the new volume, so the
+// controllers stay attached and the hosts only see a short latency spike. The operation can be
+// cancelled until the cutover, the namespace then stays on its volume
+func (s *Server) MigrateNvmeNamespace(ctx context.Context, in *MigrateNvmeNamespaceRequest) (*operations.Operation, error) {
+	// check input correctness
+	if err := s.validateMigrateNvmeNamespaceRequest(in); err != nil {
+		return nil, err
+	}
+	namespace, subsys, err := s.startNvmeNamespaceMigration(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &MigrateNvmeNamespaceMetadata{
+		Name:                namespace.Name,
+		SourceVolumeNameRef: namespace.Spec.VolumeNameRef,
+		VolumeNameRef:       in.VolumeNameRef,
+	}
+	return s.operations.StartCancellable(metadata, func(ctx context.Context) (interface{}, error) {
+		return s.waitNvmeNamespaceMigration(ctx, namespace, subsys, in.VolumeNameRef)
+	}, func(ctx context.Context) error {
+		return s.abortNvmeNamespaceMigration(ctx, namespace, subsys)
+	}), nil
+}
+
+// MigrateNvmeNamespaceVolume moves the data of an Nvme namespace to another volume, returning once
+// the namespace is backed by it, the storage pools rebalance their lvol stores with it
+func (s *Server) MigrateNvmeNamespaceVolume(ctx context.Context, name string, volume string) error {
+	in := &MigrateNvmeNamespaceRequest{Name: name, VolumeNameRef: volume}
+	// check input correctness
+	if err := s.validateMigrateNvmeNamespaceRequest(in); err != nil {
+		return err
+	}
+	namespace, subsys, err := s.startNvmeNamespaceMigration(ctx, in)
+	if err != nil {
+		return err
+	}
+	_, err = s.waitNvmeNamespaceMigration(ctx, namespace, subsys, volume)
+	return err
+}
+
+// NvmeNamespaceVolumes returns the volume backing each Nvme namespace, by namespace name
+func (s *Server) NvmeNamespaceVolumes(_ context.Context) (map[string]string, error) {
+	volumes := make(map[string]string)
+	for key := range s.ListHelper {
+		if !strings.Contains(key, "/nvmeNamespaces/") {
+			continue
+		}
+		namespace := new(pb.NvmeNamespace)
+		found, err := s.store.Get(key, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			volumes[key] = namespace.Spec.VolumeNameRef
+		}
+	}
+	return volumes, nil
+}
+
+// startNvmeNamespaceMigration makes the firmware start copying the data of an Nvme namespace to
+// another volume
+func (s *Server) startNvmeNamespaceMigration(ctx context.Context, in *MigrateNvmeNamespaceRequest) (*pb.NvmeNamespace, *pb.NvmeSubsystem, error) {
+	// fetch object from the database
+	namespace := new(pb.NvmeNamespace)
+	found, err := s.store.Get(in.Name, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, nil, err
+	}
+	if namespace.Spec.VolumeNameRef == in.VolumeNameRef {
+		msg := fmt.Sprintf("NS %s is already backed by %s", in.Name, in.VolumeNameRef)
+		return nil, nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	subsysName := utils.ResourceIDToSubsystemName(
+		utils.GetSubsystemIDFromNvmeName(in.Name),
+	)
+	// fetch object from the database
+	subsys := new(pb.NvmeSubsystem)
+	found, err = s.store.Get(subsysName, subsys)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
+		return nil, nil, err
+	}
+	params := models.MrvlNvmNsMigrateStartParams{
+		Subnqn:       subsys.Spec.Nqn,
+		NsInstanceID: int(namespace.Spec.HostNsid),
+		DstBdev:      in.VolumeNameRef,
+	}
+	var result models.MrvlNvmNsMigrateStartResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ns_migrate_start", &params, &result)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not start migration of NS: %s", in.Name)
+		return nil, nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ns_migrate_start")
+	}
+	return namespace, subsys, nil
+}
+
+// waitNvmeNamespaceMigration polls the firmware until the data of the namespace is copied,
+// then switches the namespace to the new volume
+func (s *Server) waitNvmeNamespaceMigration(ctx context.Context, namespace *pb.NvmeNamespace, subsys *pb.NvmeSubsystem, volume string) (*pb.NvmeNamespace, error) {
+	for {
+		params := models.MrvlNvmNsMigrateGetStatusParams{
+			Subnqn:       subsys.Spec.Nqn,
+			NsInstanceID: int(namespace.Spec.HostNsid),
+		}
+		var result models.MrvlNvmNsMigrateGetStatusResult
+		err := s.rpc.Call(ctx, "mrvl_nvm_ns_migrate_get_status", &params, &result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not get migration status of NS: %s", namespace.Name)
+			return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ns_migrate_get_status")
+		}
+		switch result.State {
+		case migrationStateCopying:
+			operations.ReportProgress(ctx, int32(result.Progress))
+			operations.ReportBytes(ctx, result.BytesDone, result.TotalBytes)
+		case migrationStateSynced:
+			return s.cutoverNvmeNamespaceMigration(ctx, namespace, subsys, volume)
+		case migrationStateAborted:
+			// the data copied so far is dropped, the destination volume can be reused
+			msg := fmt.Sprintf("Migration of NS %s cancelled, it is still backed by %s", namespace.Name, namespace.Spec.VolumeNameRef)
+			return nil, status.Errorf(codes.Canceled, msg)
+		default:
+			// the firmware keeps serving the namespace from the source volume on failure
+			msg := fmt.Sprintf("Migration of NS %s failed, it is still backed by %s", namespace.Name, namespace.Spec.VolumeNameRef)
+			return nil, status.Errorf(codes.Aborted, msg)
+		}
+		time.Sleep(s.migrationPollInterval)
+	}
+}
+
+// abortNvmeNamespaceMigration makes the firmware stop copying the data of a namespace, it fails
+// once the cutover started
+func (s *Server) abortNvmeNamespaceMigration(ctx context.Context, namespace *pb.NvmeNamespace, subsys *pb.NvmeSubsystem) error {
+	params := models.MrvlNvmNsMigrateAbortParams{
+		Subnqn:       subsys.Spec.Nqn,
+		NsInstanceID: int(namespace.Spec.HostNsid),
+	}
+	var result models.MrvlNvmNsMigrateAbortResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_ns_migrate_abort", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not abort migration of NS: %s", namespace.Name)
+		return result.Status.Err(codes.FailedPrecondition, msg, "mrvl_nvm_ns_migrate_abort")
+	}
+	return nil
+}
+
+// cutoverNvmeNamespaceMigration switches a namespace whose data is copied to the new volume
+func (s *Server) cutoverNvmeNamespaceMigration(ctx context.Context, namespace *pb.NvmeNamespace, subsys *pb.NvmeSubsystem, volume string) (*pb.NvmeNamespace, error) {
+	params := models.MrvlNvmNsMigrateCutoverParams{
+		Subnqn:       subsys.Spec.Nqn,
+		NsInstanceID: int(namespace.Spec.HostNsid),
+	}
+	var result models.MrvlNvmNsMigrateCutoverResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_ns_migrate_cutover", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Cutover of NS %s failed, it is still backed by %s", namespace.Name, namespace.Spec.VolumeNameRef)
+		return nil, result.Status.Err(codes.Aborted, msg, "mrvl_nvm_ns_migrate_cutover")
+	}
+	slog.Info("NS is now backed by a new volume", "name", namespace.Name, "volume", volume)
+	response := utils.ProtoClone(namespace)
+	response.Spec.VolumeNameRef = volume
+	// save object to the database
+	err = s.store.Set(response.Name, response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_MigrateNvmeNamespace(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testMigratedNamespace := &pb.NvmeNamespace{
+		Name: testNamespaceName,
+		Spec: &pb.NvmeNamespaceSpec{
+			HostNsid:      testNamespace.Spec.HostNsid,
+			VolumeNameRef: "Nvme0n1",
+		},
+		Status: testNamespaceWithStatus.Status,
+	}
+	tests := map[string]struct {
+		in         *MigrateNvmeNamespaceRequest
+		spdk       []string
+		errCode    codes.Code
+		errMsg     string
+		opResponse *pb.NvmeNamespace
+		opErr      *operations.Error
+		stored     *pb.NvmeNamespace
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not start migration of NS: %v", testNamespaceName),
+			stored:  &testNamespaceWithStatus,
+		},
+		"valid request with empty SPDK response": {
+			in:      &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ns_migrate_start: %v", "EOF"),
+			stored:  &testNamespaceWithStatus,
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ns_migrate_start: %v", "json response ID mismatch"),
+			stored:  &testNamespaceWithStatus,
+		},
+		"valid request with error code from SPDK response": {
+			in:      &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ns_migrate_start: %v", "json response error: myopierr"),
+			stored:  &testNamespaceWithStatus,
+		},
+		"valid request with valid SPDK response": {
+			in: &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "copying", "progress": 50}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "synced", "progress": 100}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+			},
+			errCode:    codes.OK,
+			errMsg:     "",
+			opResponse: testMigratedNamespace,
+			stored:     testMigratedNamespace,
+		},
+		"migration failed in the firmware": {
+			in: &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "failed", "progress": 20}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.Aborted, Message: fmt.Sprintf("Migration of NS %s failed, it is still backed by %s", testNamespaceName, "Malloc0")},
+			stored:  &testNamespaceWithStatus,
+		},
+		"migration cancelled in the firmware": {
+			in: &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "aborted", "progress": 20}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.Canceled, Message: fmt.Sprintf("Migration of NS %s cancelled, it is still backed by %s", testNamespaceName, "Malloc0")},
+			stored:  &testNamespaceWithStatus,
+		},
+		"cutover failed in the firmware": {
+			in: &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "synced", "progress": 100}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.Aborted, Message: fmt.Sprintf("Cutover of NS %s failed, it is still backed by %s", testNamespaceName, "Malloc0")},
+			stored:  &testNamespaceWithStatus,
+		},
+		"migration status with invalid SPDK response": {
+			in: &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Nvme0n1"},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.InvalidArgument, Message: fmt.Sprintf("Could not get migration status of NS: %s", testNamespaceName)},
+			stored:  &testNamespaceWithStatus,
+		},
+		"same volume": {
+			in:      &MigrateNvmeNamespaceRequest{Name: testNamespaceName, VolumeNameRef: "Malloc0"},
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("NS %s is already backed by %s", testNamespaceName, "Malloc0"),
+			stored:  &testNamespaceWithStatus,
+		},
+		"valid request with unknown key": {
+			in:      &MigrateNvmeNamespaceRequest{Name: utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id"), VolumeNameRef: "Nvme0n1"},
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")),
+			stored:  &testNamespaceWithStatus,
+		},
+		"malformed name": {
+			in:      &MigrateNvmeNamespaceRequest{Name: "-ABC-DEF", VolumeNameRef: "Nvme0n1"},
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			stored:  &testNamespaceWithStatus,
+		},
+		"no required field": {
+			in:      &MigrateNvmeNamespaceRequest{Name: testNamespaceName},
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: volume_name_ref",
+			stored:  &testNamespaceWithStatus,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+
+			response, err := testEnv.opiSpdkServer.MigrateNvmeNamespace(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err == nil {
+				testEnv.opiSpdkServer.operations.Wait()
+				op, _ := testEnv.opiSpdkServer.operations.GetOperation(testEnv.ctx, &operations.GetOperationRequest{Name: response.Name})
+				if !op.Done {
+					t.Error("expected operation to be done")
+				}
+				if namespace, _ := op.Response.(*pb.NvmeNamespace); !proto.Equal(namespace, tt.opResponse) {
+					t.Error("operation response: expected", tt.opResponse, "received", op.Response)
+				}
+				if (op.Error == nil) != (tt.opErr == nil) || (tt.opErr != nil && *op.Error != *tt.opErr) {
+					t.Error("operation error: expected", tt.opErr, "received", op.Error)
+				}
+			} else if response != nil {
+				t.Error("response: expected", nil, "received", response)
+			}
+
+			stored := new(pb.NvmeNamespace)
+			_, _ = testEnv.opiSpdkServer.store.Get(testNamespaceName, stored)
+			if !proto.Equal(stored, tt.stored) {
+				t.Error("stored namespace: expected", tt.stored, "received", stored)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_MigrateNvmeNamespaceVolume(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "synced", "progress": 100}}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+	})
+	defer testEnv.Close()
+
+	testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+
+	volumes, err := testEnv.opiSpdkServer.NvmeNamespaceVolumes(testEnv.ctx)
+	if err != nil || volumes[testNamespaceName] != "Malloc0" {
+		t.Error("volumes: expected", "Malloc0", "received", volumes, err)
+	}
+
+	// the migration is done when the call returns
+	if err := testEnv.opiSpdkServer.MigrateNvmeNamespaceVolume(testEnv.ctx, testNamespaceName, "lvol1"); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	volumes, err = testEnv.opiSpdkServer.NvmeNamespaceVolumes(testEnv.ctx)
+	if err != nil || volumes[testNamespaceName] != "lvol1" {
+		t.Error("volumes: expected", "lvol1", "received", volumes, err)
+	}
+
+	err = testEnv.opiSpdkServer.MigrateNvmeNamespaceVolume(testEnv.ctx, testNamespaceName, "lvol1")
+	if er, _ := status.FromError(err); er.Code() != codes.FailedPrecondition {
+		t.Error("error code: expected", codes.FailedPrecondition, "received", er.Code())
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NotifyNvmeNamespaceChangeRequest represents a request to notify the hosts of a namespace change
+type NotifyNvmeNamespaceChangeRequest struct {
+	// Name of the changed Nvme namespace
+	Name string `json:"name"`
+}
+
+// NotifyNvmeNamespaceChange forces the Namespace Attribute Changed event to be raised to the
+// hosts attached to an Nvme namespace, i.e. after its backing volume was resized or when the
+// events were suppressed while it was created
+func (s *Server) NotifyNvmeNamespaceChange(ctx context.Context, in *NotifyNvmeNamespaceChangeRequest) (*pb.NvmeNamespace, error) {
+	// check input correctness
+	if err := s.validateNotifyNvmeNamespaceChangeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	namespace := new(pb.NvmeNamespace)
+	found, err := s.store.Get(in.Name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	subsysName := utils.ResourceIDToSubsystemName(
+		utils.GetSubsystemIDFromNvmeName(in.Name),
+	)
+	// fetch object from the database
+	subsys := new(pb.NvmeSubsystem)
+	found, err = s.store.Get(subsysName, subsys)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
+		return nil, err
+	}
+	params := models.MrvlNvmSubsysNotifyNsChangeParams{
+		Subnqn:       subsys.Spec.Nqn,
+		NsInstanceID: int(namespace.Spec.HostNsid),
+	}
+	var result models.MrvlNvmSubsysNotifyNsChangeResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_subsys_notify_ns_change", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not notify change of NS: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_notify_ns_change")
+	}
+	return namespace, nil
+}
+
+// NotifyVolumeResize raises the Namespace Attribute Changed event to the hosts attached to the
+// Nvme namespaces backed by a volume whose size changed, so they read the new capacity
+func (s *Server) NotifyVolumeResize(ctx context.Context, volume string) error {
+	var names []string
+	for key := range s.ListHelper {
+		if strings.Contains(key, "/nvmeNamespaces/") {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		namespace := new(pb.NvmeNamespace)
+		found, err := s.store.Get(name, namespace)
+		if err != nil {
+			return err
+		}
+		if !found || namespace.Spec.VolumeNameRef != volume {
+			continue
+		}
+		if _, err := s.NotifyNvmeNamespaceChange(ctx, &NotifyNvmeNamespaceChangeRequest{Name: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_NotifyNvmeNamespaceChange(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in      *NotifyNvmeNamespaceChangeRequest
+		out     *pb.NvmeNamespace
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &NotifyNvmeNamespaceChangeRequest{Name: testNamespaceName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not notify change of NS: %v", testNamespaceName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &NotifyNvmeNamespaceChangeRequest{Name: testNamespaceName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_subsys_notify_ns_change: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      &NotifyNvmeNamespaceChangeRequest{Name: testNamespaceName},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_subsys_notify_ns_change: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &NotifyNvmeNamespaceChangeRequest{Name: testNamespaceName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_subsys_notify_ns_change: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      &NotifyNvmeNamespaceChangeRequest{Name: testNamespaceName},
+			out:     &testNamespaceWithStatus,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &NotifyNvmeNamespaceChangeRequest{Name: utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")),
+		},
+		"malformed name": {
+			in:      &NotifyNvmeNamespaceChangeRequest{Name: "-ABC-DEF"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &NotifyNvmeNamespaceChangeRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+
+			response, err := testEnv.opiSpdkServer.NotifyNvmeNamespaceChange(testEnv.ctx, tt.in)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_NotifyVolumeResize(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"volume backing a namespace": {
+			in:      testNamespaceWithStatus.Spec.VolumeNameRef,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"volume backing a namespace with invalid SPDK response": {
+			in:      testNamespaceWithStatus.Spec.VolumeNameRef,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not notify change of NS: %v", testNamespaceName),
+		},
+		"volume backing no namespace": {
+			in:      "Malloc9",
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+
+			err := testEnv.opiSpdkServer.NotifyVolumeResize(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
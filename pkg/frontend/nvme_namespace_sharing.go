@@ -0,0 +1,340 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// NvmeNamespaceSharing represents how an Nvme namespace is shared between the controllers of its
+// subsystem, which is not part of the OPI NvmeNamespaceSpec. The sharing can be set before the
+// namespace is created (with a user specified id) and is applied on creation. Without one the
+// namespace is shared and attached to all the controllers of its subsystem
+type NvmeNamespaceSharing struct {
+	// Shared advertises the namespace as possibly attached to several controllers (NMIC bit 0),
+	// i.e. the VFs of a multi-queue VM or the controllers of a failover pair, the hosts then
+	// coordinate their access with reservations. Can only be set before the namespace is created
+	Shared bool `json:"shared"`
+	// ControllerNameRefs are the controllers of the subsystem the namespace is attached to, all of
+	// them when empty. A private namespace is attached to exactly one controller
+	ControllerNameRefs []string `json:"controllerNameRefs,omitempty"`
+	// Status is reported by the firmware for existing namespaces, output only
+	Status *NvmeNamespaceSharingStatus `json:"status,omitempty"`
+}
+
+// NvmeNamespaceSharingStatus represents the sharing in effect on an Nvme namespace
+type NvmeNamespaceSharingStatus struct {
+	// Shared is set when the firmware advertises the namespace as shared
+	Shared bool `json:"shared"`
+	// ControllerNameRefs are the controllers the namespace is attached to
+	ControllerNameRefs []string `json:"controllerNameRefs"`
+}
+
+// GetNvmeNamespaceSharingRequest represents a request to get the sharing of an Nvme namespace
+type GetNvmeNamespaceSharingRequest struct {
+	// Name of the Nvme namespace
+	Name string `json:"name"`
+}
+
+// UpdateNvmeNamespaceSharingRequest represents a request to update the sharing of an Nvme namespace
+type UpdateNvmeNamespaceSharingRequest struct {
+	// Name of the Nvme namespace
+	Name string `json:"name"`
+	// Sharing to set on the Nvme namespace
+	Sharing *NvmeNamespaceSharing `json:"sharing"`
+}
+
+// defaultNvmeNamespaceSharing is the sharing of the namespaces which were given none
+var defaultNvmeNamespaceSharing = NvmeNamespaceSharing{Shared: true}
+
+// GetNvmeNamespaceSharing gets how an Nvme namespace is shared between the controllers of its subsystem
+func (s *Server) GetNvmeNamespaceSharing(ctx context.Context, in *GetNvmeNamespaceSharingRequest) (*NvmeNamespaceSharing, error) {
+	// check input correctness
+	if err := s.validateGetNvmeNamespaceSharingRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	sharing, found, err := s.getNvmeNamespaceSharing(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	namespace := new(pb.NvmeNamespace)
+	created, err := s.store.Get(in.Name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !found && !created {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if !found {
+		sharing = &defaultNvmeNamespaceSharing
+	}
+	response := *sharing
+	// report what the firmware exposes once the namespace exists
+	if created {
+		response.Status, err = s.getNvmeNamespaceSharingStatus(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &response, nil
+}
+
+// UpdateNvmeNamespaceSharing sets how an Nvme namespace is shared, on an existing namespace it is
+// attached to the controllers added and detached from the ones removed
+func (s *Server) UpdateNvmeNamespaceSharing(ctx context.Context, in *UpdateNvmeNamespaceSharingRequest) (*NvmeNamespaceSharing, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeNamespaceSharingRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	namespace := new(pb.NvmeNamespace)
+	found, err := s.store.Get(in.Name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	sharing := *in.Sharing
+	sharing.Status = nil
+	if found {
+		// the controllers the namespace is attached to have to exist
+		for _, name := range sharing.ControllerNameRefs {
+			if _, ok := s.ListHelper[name]; !ok {
+				err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+				return nil, err
+			}
+		}
+		saved, err := s.nvmeNamespaceSharing(in.Name)
+		if err != nil {
+			return nil, err
+		}
+		if saved.Shared != sharing.Shared {
+			msg := fmt.Sprintf("Shared option of NS %s can only be set before it is created", in.Name)
+			return nil, status.Errorf(codes.FailedPrecondition, msg)
+		}
+		if err := s.reattachNvmeNamespace(ctx, namespace, saved, &sharing); err != nil {
+			return nil, err
+		}
+	} else {
+		slog.Info("NvmeNamespace doesn't exist yet, sharing is applied on creation", "name", in.Name)
+	}
+	// save object to the database
+	if err := s.saveNvmeNamespaceSharing(in.Name, &sharing); err != nil {
+		return nil, err
+	}
+	return &sharing, nil
+}
+
+// reattachNvmeNamespace detaches a namespace from the controllers it is no longer shared with
+// first, so a private namespace is never attached to two controllers, then attaches it to the
+// new ones
+func (s *Server) reattachNvmeNamespace(ctx context.Context, namespace *pb.NvmeNamespace, saved *NvmeNamespaceSharing, sharing *NvmeNamespaceSharing) error {
+	subsys, err := s.nvmeNamespaceSubsystem(namespace.Name)
+	if err != nil {
+		return err
+	}
+	attached, err := s.sharedNvmeControllers(subsys, saved)
+	if err != nil {
+		return err
+	}
+	controllers, err := s.sharedNvmeControllers(subsys, sharing)
+	if err != nil {
+		return err
+	}
+	for name, controller := range attached {
+		if controllers[name] == nil {
+			if err := s.detachNvmeNamespace(ctx, subsys, controller, namespace); err != nil {
+				return err
+			}
+		}
+	}
+	for name, controller := range controllers {
+		if attached[name] == nil {
+			if err := s.attachNvmeNamespace(ctx, subsys, controller, namespace); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) getNvmeNamespaceSharingStatus(ctx context.Context, namespace *pb.NvmeNamespace) (*NvmeNamespaceSharingStatus, error) {
+	subsys, err := s.nvmeNamespaceSubsystem(namespace.Name)
+	if err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmGetNsInfoParams{
+		SubNqn:       subsys.Spec.Nqn,
+		NsInstanceID: int(namespace.Spec.HostNsid),
+	}
+	var result models.MrvlNvmGetNsInfoResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ns_get_info", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get NS: %s", namespace.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ns_get_info")
+	}
+	// the firmware identifies the controllers by their id, the names are the ones of the bridge
+	controllers, err := s.sharedNvmeControllers(subsys, &defaultNvmeNamespaceSharing)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string)
+	for name, controller := range controllers {
+		names[int(*controller.Spec.NvmeControllerId)] = name
+	}
+	response := &NvmeNamespaceSharingStatus{Shared: result.Nmic != 0, ControllerNameRefs: []string{}}
+	for _, ctrlr := range result.CtrlrIDList {
+		name, ok := names[ctrlr.CtrlrID]
+		if !ok {
+			name = fmt.Sprintf("CTRL %d", ctrlr.CtrlrID)
+		}
+		response.ControllerNameRefs = append(response.ControllerNameRefs, name)
+	}
+	sort.Strings(response.ControllerNameRefs)
+	return response, nil
+}
+
+// sharedNvmeControllers returns the controllers of a subsystem a namespace is attached to, by name.
+// The controllers referenced which don't exist are skipped, the firmware detached the namespace
+// from them when they were deleted
+func (s *Server) sharedNvmeControllers(subsys *pb.NvmeSubsystem, sharing *NvmeNamespaceSharing) (map[string]*pb.NvmeController, error) {
+	names := sharing.ControllerNameRefs
+	if len(names) == 0 {
+		for key := range s.ListHelper {
+			if strings.HasPrefix(key, subsys.Name+"/nvmeControllers") {
+				names = append(names, key)
+			}
+		}
+	}
+	controllers := make(map[string]*pb.NvmeController)
+	for _, name := range names {
+		controller := new(pb.NvmeController)
+		found, err := s.store.Get(name, controller)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			controllers[name] = controller
+		}
+	}
+	return controllers, nil
+}
+
+// attachNvmeNamespace attaches a namespace to a controller of its subsystem
+func (s *Server) attachNvmeNamespace(ctx context.Context, subsys *pb.NvmeSubsystem, controller *pb.NvmeController, namespace *pb.NvmeNamespace) error {
+	params := models.MrvlNvmCtrlrAttachNsParams{
+		Subnqn:       subsys.Spec.Nqn,
+		CtrlrID:      int(*controller.Spec.NvmeControllerId),
+		NsInstanceID: int(namespace.Spec.HostNsid),
+		NsChangeAen:  boolToInt(s.nsChangeAen),
+	}
+	var result models.MrvlNvmCtrlrAttachNsResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_ctrlr_attach_ns", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not attach NS: %s", namespace.Name)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_attach_ns")
+	}
+	return nil
+}
+
+// detachNvmeNamespace detaches a namespace from a controller of its subsystem
+func (s *Server) detachNvmeNamespace(ctx context.Context, subsys *pb.NvmeSubsystem, controller *pb.NvmeController, namespace *pb.NvmeNamespace) error {
+	params := models.MrvlNvmCtrlrDetachNsParams{
+		Subnqn:       subsys.Spec.Nqn,
+		CtrlrID:      int(*controller.Spec.NvmeControllerId),
+		NsInstanceID: int(namespace.Spec.HostNsid),
+		NsChangeAen:  boolToInt(s.nsChangeAen),
+	}
+	var result models.MrvlNvmCtrlrDetachNsResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_ctrlr_detach_ns", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not detach NS: %s", namespace.Name)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_detach_ns")
+	}
+	return nil
+}
+
+// nvmeNamespaceSubsystem fetches the subsystem of an Nvme namespace from the database
+func (s *Server) nvmeNamespaceSubsystem(name string) (*pb.NvmeSubsystem, error) {
+	subsysName := utils.ResourceIDToSubsystemName(
+		utils.GetSubsystemIDFromNvmeName(name),
+	)
+	subsys := new(pb.NvmeSubsystem)
+	found, err := s.store.Get(subsysName, subsys)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
+		return nil, err
+	}
+	return subsys, nil
+}
+
+// nvmeNamespaceSharingKey is the database key of the sharing of an Nvme namespace
+func nvmeNamespaceSharingKey(name string) string {
+	return name + "/sharing"
+}
+
+// nvmeNamespaceSharing returns the sharing of an Nvme namespace, the default one when none was set
+func (s *Server) nvmeNamespaceSharing(name string) (*NvmeNamespaceSharing, error) {
+	sharing, found, err := s.getNvmeNamespaceSharing(name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return &defaultNvmeNamespaceSharing, nil
+	}
+	return sharing, nil
+}
+
+// getNvmeNamespaceSharing fetches the sharing of an Nvme namespace from the database,
+// the sharing is not a protobuf so it is stored JSON encoded
+func (s *Server) getNvmeNamespaceSharing(name string) (*NvmeNamespaceSharing, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(nvmeNamespaceSharingKey(name), value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	sharing := new(NvmeNamespaceSharing)
+	if err := json.Unmarshal(value.Value, sharing); err != nil {
+		return nil, false, err
+	}
+	return sharing, true, nil
+}
+
+func (s *Server) saveNvmeNamespaceSharing(name string, sharing *NvmeNamespaceSharing) error {
+	data, err := json.Marshal(sharing)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(nvmeNamespaceSharingKey(name), wrapperspb.Bytes(data))
+}
+
+func (s *Server) deleteNvmeNamespaceSharing(name string) error {
+	return s.store.Delete(nvmeNamespaceSharingKey(name))
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFrontEnd_UpdateNvmeNamespaceSharing(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testNewNamespaceName := utils.ResourceIDToNamespaceName(testSubsystemID, "new-namespace-id")
+	testOtherControllerName := utils.ResourceIDToControllerName(testSubsystemID, "other-controller-id")
+	tests := map[string]struct {
+		in      *UpdateNvmeNamespaceSharingRequest
+		out     *NvmeNamespaceSharing
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"private namespace for a namespace not created yet": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNewNamespaceName, Sharing: &NvmeNamespaceSharing{ControllerNameRefs: []string{testControllerName}}},
+			out:     &NvmeNamespaceSharing{ControllerNameRefs: []string{testControllerName}},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"shared namespace restricted to one of its controllers": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNamespaceName, Sharing: &NvmeNamespaceSharing{Shared: true, ControllerNameRefs: []string{testControllerName}}},
+			out:     &NvmeNamespaceSharing{Shared: true, ControllerNameRefs: []string{testControllerName}},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"shared namespace with invalid SPDK response": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNamespaceName, Sharing: &NvmeNamespaceSharing{Shared: true, ControllerNameRefs: []string{testControllerName}}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not detach NS: %v", testNamespaceName),
+		},
+		"private existing namespace": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNamespaceName, Sharing: &NvmeNamespaceSharing{ControllerNameRefs: []string{testControllerName}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Shared option of NS %v can only be set before it is created", testNamespaceName),
+		},
+		"unknown controller for an existing namespace": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNamespaceName, Sharing: &NvmeNamespaceSharing{Shared: true, ControllerNameRefs: []string{utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToControllerName(testSubsystemID, "unknown-controller-id")),
+		},
+		"private namespace with two controllers": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNewNamespaceName, Sharing: &NvmeNamespaceSharing{ControllerNameRefs: []string{testControllerName, testOtherControllerName}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "A private namespace has to be attached to exactly one controller, 2 given",
+		},
+		"private namespace without controller": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNewNamespaceName, Sharing: &NvmeNamespaceSharing{}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "A private namespace has to be attached to exactly one controller, 0 given",
+		},
+		"controller given twice": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNewNamespaceName, Sharing: &NvmeNamespaceSharing{Shared: true, ControllerNameRefs: []string{testControllerName, testControllerName}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Controller %v is given more than once", testControllerName),
+		},
+		"controller of another subsystem": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNewNamespaceName, Sharing: &NvmeNamespaceSharing{Shared: true, ControllerNameRefs: []string{utils.ResourceIDToControllerName("other-subsystem-id", testControllerID)}}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Controller %v is not in the subsystem of the namespace", utils.ResourceIDToControllerName("other-subsystem-id", testControllerID)),
+		},
+		"malformed name": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: "-ABC-DEF", Sharing: &NvmeNamespaceSharing{Shared: true}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required sharing field": {
+			in:      &UpdateNvmeNamespaceSharingRequest{Name: testNamespaceName},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: sharing",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			otherController := utils.ProtoClone(&testControllerWithStatus)
+			otherController.Name = testOtherControllerName
+			otherController.Spec.NvmeControllerId = proto.Int32(18)
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			testEnv.opiSpdkServer.ListHelper[testOtherControllerName] = false
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testOtherControllerName, otherController)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+
+			response, err := testEnv.opiSpdkServer.UpdateNvmeNamespaceSharing(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			// the sharing is only saved once applied
+			if tt.errCode == codes.OK {
+				sharing, found, _ := testEnv.opiSpdkServer.getNvmeNamespaceSharing(tt.in.Name)
+				if !found || !reflect.DeepEqual(sharing, tt.out) {
+					t.Error("saved sharing: expected", tt.out, "received", sharing)
+				}
+			}
+		})
+	}
+}
+
+func TestFrontEnd_GetNvmeNamespaceSharing(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testNewNamespaceName := utils.ResourceIDToNamespaceName(testSubsystemID, "new-namespace-id")
+	tests := map[string]struct {
+		in      *GetNvmeNamespaceSharingRequest
+		out     *NvmeNamespaceSharing
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with valid SPDK response": {
+			in: &GetNvmeNamespaceSharingRequest{Name: testNamespaceName},
+			out: &NvmeNamespaceSharing{
+				Shared: true,
+				Status: &NvmeNamespaceSharingStatus{Shared: true, ControllerNameRefs: []string{"CTRL 3", testControllerName}},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "nmic": 1, "bdev": "Malloc0", "num_ctrlrs": 2, "ctrlr_id_list": [{"ctrlr_id": 17}, {"ctrlr_id": 3}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with invalid SPDK response": {
+			in:      &GetNvmeNamespaceSharingRequest{Name: testNamespaceName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get NS: %v", testNamespaceName),
+		},
+		"valid request for a namespace not created yet": {
+			in:      &GetNvmeNamespaceSharingRequest{Name: testNewNamespaceName},
+			out:     &NvmeNamespaceSharing{ControllerNameRefs: []string{testControllerName}},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &GetNvmeNamespaceSharingRequest{Name: utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")),
+		},
+		"no required field": {
+			in:      &GetNvmeNamespaceSharingRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+			_ = testEnv.opiSpdkServer.saveNvmeNamespaceSharing(testNewNamespaceName, &NvmeNamespaceSharing{ControllerNameRefs: []string{testControllerName}})
+
+			response, err := testEnv.opiSpdkServer.GetNvmeNamespaceSharing(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreatePrivateNvmeNamespace(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testOtherControllerName := utils.ResourceIDToControllerName(testSubsystemID, "other-controller-id")
+	// the namespace is only attached to its controller, a second attach would get no response
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ns_instance_id": 22}}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+	})
+	defer testEnv.Close()
+
+	otherController := utils.ProtoClone(&testControllerWithStatus)
+	otherController.Name = testOtherControllerName
+	otherController.Spec.NvmeControllerId = proto.Int32(18)
+	testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+	testEnv.opiSpdkServer.ListHelper[testOtherControllerName] = false
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testOtherControllerName, otherController)
+	_ = testEnv.opiSpdkServer.saveNvmeNamespaceSharing(testNamespaceName, &NvmeNamespaceSharing{ControllerNameRefs: []string{testOtherControllerName}})
+
+	request := &pb.CreateNvmeNamespaceRequest{Parent: testSubsystemName,
+		NvmeNamespace: &pb.NvmeNamespace{Spec: testNamespace.Spec}, NvmeNamespaceId: testNamespaceID}
+	response, err := testEnv.opiSpdkServer.CreateNvmeNamespace(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if response.Name != testNamespaceName {
+		t.Error("name: expected", testNamespaceName, "received", response.Name)
+	}
+}
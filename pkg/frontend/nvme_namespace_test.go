@@ -7,6 +7,7 @@
 package frontend
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -49,7 +50,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
 			exist:   false,
 			subsys:  testSubsystemName,
@@ -173,7 +174,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			exist:   false,
 			subsys:  "-ABC-DEF",
@@ -183,7 +184,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			in:      nil,
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: nvme_namespace",
 			exist:   false,
 			subsys:  testSubsystemName,
@@ -197,7 +198,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: parent",
 			exist:   false,
 			subsys:  "",
@@ -209,7 +210,7 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: nvme_namespace.spec.volume_name_ref",
 			exist:   false,
 			subsys:  testSubsystemName,
@@ -255,6 +256,96 @@ func TestFrontEnd_CreateNvmeNamespace(t *testing.T) {
 	}
 }
 
+// testVolumePlacer places the namespaces on a fixed volume and records the released volumes
+type testVolumePlacer struct {
+	volume   string
+	err      error
+	released []string
+}
+
+func (p *testVolumePlacer) PlaceVolume(_ context.Context, _ string) (string, error) {
+	return p.volume, p.err
+}
+
+func (p *testVolumePlacer) ReleaseVolume(_ context.Context, _ string, volume string) error {
+	p.released = append(p.released, volume)
+	return nil
+}
+
+func TestFrontEnd_CreateNvmeNamespaceOnStoragePool(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	pool := storagePoolPrefix + "pool0"
+	tests := map[string]struct {
+		placer   *testVolumePlacer
+		out      string
+		spdk     []string
+		errCode  codes.Code
+		errMsg   string
+		released []string
+	}{
+		"storage pools not enabled": {
+			placer:  nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Storage pools are not enabled, can't place NS %s on %s", testNamespaceName, pool),
+		},
+		"placement failure": {
+			placer:  &testVolumePlacer{err: status.Error(codes.ResourceExhausted, "full")},
+			spdk:    []string{},
+			errCode: codes.ResourceExhausted,
+			errMsg:  "full",
+		},
+		"namespace creation failure": {
+			placer:   &testVolumePlacer{volume: "lvol1"},
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode:  codes.InvalidArgument,
+			errMsg:   fmt.Sprintf("Could not create NS: %v", testNamespaceName),
+			released: []string{"lvol1"},
+		},
+		"namespace placed": {
+			placer:  &testVolumePlacer{volume: "lvol1"},
+			out:     "lvol1",
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ns_instance_id": 17}}`, `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			if tt.placer != nil {
+				testEnv.opiSpdkServer.SetVolumePlacer(tt.placer)
+			}
+
+			request := &pb.CreateNvmeNamespaceRequest{Parent: testSubsystemName, NvmeNamespaceId: testNamespaceID,
+				NvmeNamespace: &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{HostNsid: 22, VolumeNameRef: pool}}}
+			response, err := testEnv.opiSpdkServer.CreateNvmeNamespace(testEnv.ctx, request)
+
+			if response.GetSpec().GetVolumeNameRef() != tt.out {
+				t.Error("volume: expected", tt.out, "received", response.GetSpec().GetVolumeNameRef())
+			}
+			if tt.placer != nil && !reflect.DeepEqual(tt.placer.released, tt.released) {
+				t.Error("released: expected", tt.released, "received", tt.placer.released)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
 func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -333,7 +424,7 @@ func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     &emptypb.Empty{},
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			missing: false,
 		},
@@ -341,7 +432,7 @@ func TestFrontEnd_DeleteNvmeNamespace(t *testing.T) {
 			in:      "",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: name",
 			missing: false,
 		},
@@ -429,7 +520,7 @@ func TestFrontEnd_UpdateNvmeNamespace(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 	}
@@ -631,7 +722,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			in:      "",
 			out:     []*pb.NvmeNamespace{},
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: parent",
 			size:    0,
 			token:   "",
@@ -647,7 +738,7 @@ func TestFrontEnd_ListNvmeNamespaces(t *testing.T) {
 			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
 			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
 			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.SetToken("existing-pagination-token", 1)
 
 			request := &pb.ListNvmeNamespacesRequest{Parent: tt.in, PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListNvmeNamespaces(testEnv.ctx, request)
@@ -724,7 +815,11 @@ func TestFrontEnd_GetNvmeNamespace(t *testing.T) {
 			out: &pb.NvmeNamespace{
 				Name: testNamespaceName,
 				Spec: &pb.NvmeNamespaceSpec{
-					Nguid: "0x25f9cbc45d0f976fb9c1a14ff5aed4b0",
+					HostNsid:      22,
+					VolumeNameRef: "Malloc0",
+					Nguid:         "0x25f9cbc45d0f976fb9c1a14ff5aed4b0",
+					Eui64:         -6385207617996832190,
+					Uuid:          "b3563324-0b77-073b-8b4e-bda571120dfb",
 				},
 				Status: &pb.NvmeNamespaceStatus{
 					State:     pb.NvmeNamespaceStatus_STATE_ENABLED,
@@ -746,14 +841,14 @@ func TestFrontEnd_GetNvmeNamespace(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 		"no required field": {
 			in:      "",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: name",
 		},
 	}
@@ -858,7 +953,7 @@ func TestFrontEnd_StatsNvmeNamespace(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 	}
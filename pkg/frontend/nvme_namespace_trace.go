@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultTraceSampleRate traces one IO out of this many when the request doesn't say
+const defaultTraceSampleRate = 100
+
+// defaultTracePollInterval is how often the traced IOs are read from the firmware
+const defaultTracePollInterval = time.Second
+
+// traceGracePeriod is how long after its duration a trace is waited for to end in the firmware
+const traceGracePeriod = 10 * time.Second
+
+// nvmeOpcodes names the opcodes of the NVM command set
+var nvmeOpcodes = map[int]string{
+	0x00: "FLUSH",
+	0x01: "WRITE",
+	0x02: "READ",
+	0x04: "WRITE_UNCORRECTABLE",
+	0x05: "COMPARE",
+	0x08: "WRITE_ZEROES",
+	0x09: "DATASET_MANAGEMENT",
+	0x0c: "VERIFY",
+}
+
+// TraceNvmeNamespaceRequest represents a request to trace a sample of the IOs of an Nvme namespace
+type TraceNvmeNamespaceRequest struct {
+	// Name of the traced Nvme namespace
+	Name string `json:"name"`
+	// DurationSec is how long the IOs are traced, between 1 and 300 seconds
+	DurationSec int32 `json:"durationSec"`
+	// SampleRate traces one IO out of this many, 1 traces them all, 100 when 0
+	SampleRate int32 `json:"sampleRate"`
+}
+
+// NvmeIoTraceRecord represents a traced IO
+type NvmeIoTraceRecord struct {
+	// TimestampUs is when the IO was fetched, in microseconds since the trace started
+	TimestampUs uint64 `json:"timestampUs"`
+	// SubmissionQueueID is the ID of the submission queue the host sent the IO on
+	SubmissionQueueID int32 `json:"submissionQueueId"`
+	// Opcode of the IO, i.e. READ, or its number when not of the NVM command set
+	Opcode string `json:"opcode"`
+	// StartLba is the first logical block of the IO
+	StartLba uint64 `json:"startLba"`
+	// BlockCount is the number of logical blocks of the IO
+	BlockCount uint32 `json:"blockCount"`
+	// SizeBytes is the size of the data of the IO
+	SizeBytes uint64 `json:"sizeBytes"`
+	// LatencyUs is the time from the fetch of the IO to its completion, in microseconds
+	LatencyUs uint64 `json:"latencyUs"`
+	// StatusCode is the NVMe status of the completion, 0 on success
+	StatusCode int32 `json:"statusCode"`
+}
+
+// TraceNvmeNamespace traces a sample of the IOs of an Nvme namespace for a while, sending the
+// traced IOs as they are read from the firmware, i.e. to debug pathological host access
+// patterns. The trace is stopped when the caller goes away
+func (s *Server) TraceNvmeNamespace(ctx context.Context, in *TraceNvmeNamespaceRequest, send func(*NvmeIoTraceRecord) error) error {
+	// check input correctness
+	if err := s.validateTraceNvmeNamespaceRequest(in); err != nil {
+		return err
+	}
+	// fetch object from the database
+	namespace := new(pb.NvmeNamespace)
+	found, err := s.store.Get(in.Name, namespace)
+	if err != nil {
+		return err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return err
+	}
+	subsysName := utils.ResourceIDToSubsystemName(
+		utils.GetSubsystemIDFromNvmeName(in.Name),
+	)
+	// fetch object from the database
+	subsys := new(pb.NvmeSubsystem)
+	found, err = s.store.Get(subsysName, subsys)
+	if err != nil {
+		return err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", subsysName)
+		return err
+	}
+	sampleRate := int(in.SampleRate)
+	if sampleRate == 0 {
+		sampleRate = defaultTraceSampleRate
+	}
+	params := models.MrvlNvmNsTraceStartParams{
+		Subnqn:       subsys.Spec.Nqn,
+		NsInstanceID: int(namespace.Spec.HostNsid),
+		SampleRate:   sampleRate,
+		DurationSec:  int(in.DurationSec),
+	}
+	var result models.MrvlNvmNsTraceStartResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ns_trace_start", &params, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not start trace of NS: %s", in.Name)
+		return result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ns_trace_start")
+	}
+	done := false
+	defer func() {
+		if !done {
+			s.stopNvmeNamespaceTrace(in.Name, result.TraceID)
+		}
+	}()
+	deadline := time.Now().Add(time.Duration(in.DurationSec)*time.Second + traceGracePeriod)
+	for {
+		readParams := models.MrvlNvmNsTraceReadParams{
+			TraceID: result.TraceID,
+		}
+		var readResult models.MrvlNvmNsTraceReadResult
+		err = s.rpc.Call(ctx, "mrvl_nvm_ns_trace_read", &readParams, &readResult)
+		if err != nil {
+			return err
+		}
+		if readResult.Status != 0 {
+			msg := fmt.Sprintf("Could not read trace of NS: %s", in.Name)
+			return readResult.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ns_trace_read")
+		}
+		for _, record := range readResult.Records {
+			opcode, ok := nvmeOpcodes[record.Opcode]
+			if !ok {
+				opcode = fmt.Sprintf("%#x", record.Opcode)
+			}
+			err := send(&NvmeIoTraceRecord{
+				TimestampUs:       record.TimestampUs,
+				SubmissionQueueID: int32(record.Sqid),
+				Opcode:            opcode,
+				StartLba:          record.Slba,
+				BlockCount:        record.Nlb,
+				SizeBytes:         record.SizeBytes,
+				LatencyUs:         record.LatencyUs,
+				StatusCode:        int32(record.StatusCode),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if readResult.Dropped != 0 {
+			slog.WarnContext(ctx, "Traced IOs dropped by the firmware, the sample rate is too low", "name", in.Name, "dropped", readResult.Dropped)
+		}
+		if readResult.Done {
+			done = true
+			return nil
+		}
+		if time.Now().After(deadline) {
+			msg := fmt.Sprintf("Trace of NS %s did not end", in.Name)
+			return status.Errorf(codes.DeadlineExceeded, msg)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.tracePollInterval):
+		}
+	}
+}
+
+// stopNvmeNamespaceTrace stops a trace which didn't end, the caller may be gone already
+func (s *Server) stopNvmeNamespaceTrace(name string, traceID int) {
+	params := models.MrvlNvmNsTraceStopParams{
+		TraceID: traceID,
+	}
+	var result models.MrvlNvmNsTraceStopResult
+	err := s.rpc.Call(context.Background(), "mrvl_nvm_ns_trace_stop", &params, &result)
+	if err == nil && result.Status != 0 {
+		err = fmt.Errorf("status %s", result.Status.Name("mrvl_nvm_ns_trace_stop"))
+	}
+	if err != nil {
+		slog.Warn("Could not stop trace", "name", name, "trace_id", traceID, "error", err)
+	}
+}
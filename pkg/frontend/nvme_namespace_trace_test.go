@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_TraceNvmeNamespace(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testTraceStarted := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "trace_id": 7}}`
+	testRead := `{"timestamp_us": 120, "sqid": 1, "opcode": 2, "slba": 2048, "nlb": 8, "size_bytes": 4096, "latency_us": 85, "status_code": 0}`
+	testWrite := `{"timestamp_us": 250, "sqid": 2, "opcode": 1, "slba": 0, "nlb": 256, "size_bytes": 131072, "latency_us": 1900, "status_code": 0}`
+	testVendor := `{"timestamp_us": 400, "sqid": 1, "opcode": 129, "slba": 0, "nlb": 0, "size_bytes": 0, "latency_us": 12, "status_code": 2}`
+	readRecord := &NvmeIoTraceRecord{TimestampUs: 120, SubmissionQueueID: 1, Opcode: "READ", StartLba: 2048, BlockCount: 8, SizeBytes: 4096, LatencyUs: 85}
+	tests := map[string]struct {
+		in      *TraceNvmeNamespaceRequest
+		out     []*NvmeIoTraceRecord
+		spdk    []string
+		sendErr error
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &TraceNvmeNamespaceRequest{Name: testNamespaceName, DurationSec: 10},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not start trace of NS: %v", testNamespaceName),
+		},
+		"valid request with empty SPDK response": {
+			in:      &TraceNvmeNamespaceRequest{Name: testNamespaceName, DurationSec: 10},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ns_trace_start: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &TraceNvmeNamespaceRequest{Name: testNamespaceName, DurationSec: 10},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_ns_trace_start: %v", "json response error: myopierr"),
+		},
+		"valid request with invalid SPDK read response": {
+			in:  &TraceNvmeNamespaceRequest{Name: testNamespaceName, DurationSec: 10},
+			out: nil,
+			spdk: []string{
+				testTraceStarted,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not read trace of NS: %v", testNamespaceName),
+		},
+		"valid request with valid SPDK response": {
+			in: &TraceNvmeNamespaceRequest{Name: testNamespaceName, DurationSec: 10, SampleRate: 1},
+			out: []*NvmeIoTraceRecord{
+				readRecord,
+				{TimestampUs: 250, SubmissionQueueID: 2, Opcode: "WRITE", StartLba: 0, BlockCount: 256, SizeBytes: 131072, LatencyUs: 1900},
+				{TimestampUs: 400, SubmissionQueueID: 1, Opcode: "0x81", LatencyUs: 12, StatusCode: 2},
+			},
+			spdk: []string{
+				testTraceStarted,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "done": false, "records": [` + testRead + `, ` + testWrite + `]}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "done": false, "records": []}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "done": true, "dropped": 3, "records": [` + testVendor + `]}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"caller gone": {
+			in:  &TraceNvmeNamespaceRequest{Name: testNamespaceName, DurationSec: 10},
+			out: []*NvmeIoTraceRecord{readRecord},
+			spdk: []string{
+				testTraceStarted,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "done": false, "records": [` + testRead + `]}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+			},
+			sendErr: status.Error(codes.Canceled, "context canceled"),
+			errCode: codes.Canceled,
+			errMsg:  "context canceled",
+		},
+		"out of range duration": {
+			in:      &TraceNvmeNamespaceRequest{Name: testNamespaceName, DurationSec: 301},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("DurationSec value (%d) is out of range, have to be between 1 and 300", 301),
+		},
+		"out of range sample rate": {
+			in:      &TraceNvmeNamespaceRequest{Name: testNamespaceName, DurationSec: 10, SampleRate: -1},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("SampleRate value (%d) is out of range, have to be between 0 and 65536", -1),
+		},
+		"valid request with unknown key": {
+			in:      &TraceNvmeNamespaceRequest{Name: utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id"), DurationSec: 10},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToNamespaceName(testSubsystemID, "unknown-namespace-id")),
+		},
+		"malformed name": {
+			in:      &TraceNvmeNamespaceRequest{Name: "-ABC-DEF", DurationSec: 10},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &TraceNvmeNamespaceRequest{DurationSec: 10},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+
+			var records []*NvmeIoTraceRecord
+			err := testEnv.opiSpdkServer.TraceNvmeNamespace(testEnv.ctx, tt.in, func(record *NvmeIoTraceRecord) error {
+				records = append(records, record)
+				return tt.sendErr
+			})
+
+			if !reflect.DeepEqual(records, tt.out) {
+				t.Error("records: expected", tt.out, "received", records)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if tt.sendErr != nil && !errors.Is(err, tt.sendErr) {
+				t.Error("error: expected", tt.sendErr, "received", err)
+			}
+		})
+	}
+}
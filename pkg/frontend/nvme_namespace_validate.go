@@ -5,60 +5,118 @@
 package frontend
 
 import (
-	"go.einride.tech/aip/fieldbehavior"
-	"go.einride.tech/aip/resourceid"
-	"go.einride.tech/aip/resourcename"
+	"path"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
 func (s *Server) validateCreateNvmeNamespaceRequest(in *pb.CreateNvmeNamespaceRequest) error {
+	v := new(validation.Validator)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	if !v.RequiredFields(in) {
+		return v.Err()
 	}
 	// see https://google.aip.dev/133#user-specified-ids
-	if in.NvmeNamespaceId != "" {
-		if err := resourceid.ValidateUserSettable(in.NvmeNamespaceId); err != nil {
-			return err
+	v.ResourceID("nvme_namespace_id", in.NvmeNamespaceId)
+	// the identifiers the hosts see have to be well formed
+	validateNvmeNamespaceIdentifiers(v, in.NvmeNamespace.Spec)
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	v.ResourceName("parent", in.Parent)
+	return v.Err()
+}
+
+func validateNvmeNamespaceIdentifiers(v *validation.Validator, spec *pb.NvmeNamespaceSpec) {
+	if spec.Uuid != "" {
+		if _, err := normalizeNamespaceUUID(spec.Uuid); err != nil {
+			v.Violation("nvme_namespace.spec.uuid", "%s", err.Error())
+		}
+	}
+	if spec.Nguid != "" {
+		if _, err := normalizeNguid(spec.Nguid); err != nil {
+			v.Violation("nvme_namespace.spec.nguid", "%s", err.Error())
 		}
 	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Parent)
 }
 
 func (s *Server) validateDeleteNvmeNamespaceRequest(in *pb.DeleteNvmeNamespaceRequest) error {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	return validateNameRequest(in, in.Name)
 }
 
 func (s *Server) validateUpdateNvmeNamespaceRequest(in *pb.UpdateNvmeNamespaceRequest) error {
+	v := new(validation.Validator)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	if v.RequiredFields(in) {
+		// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+		v.ResourceName("nvme_namespace.name", in.NvmeNamespace.Name)
 	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.NvmeNamespace.Name)
+	return v.Err()
 }
 
-func (s *Server) validateGetNvmeNamespaceRequest(in *pb.GetNvmeNamespaceRequest) error {
+func (s *Server) validateListNvmeNamespacesRequest(in *pb.ListNvmeNamespacesRequest) error {
+	v := new(validation.Validator)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	v.RequiredFields(in)
+	return v.Err()
+}
+
+func (s *Server) validateGetNvmeNamespaceRequest(in *pb.GetNvmeNamespaceRequest) error {
+	return validateNameRequest(in, in.Name)
 }
 
 func (s *Server) validateStatsNvmeNamespaceRequest(in *pb.StatsNvmeNamespaceRequest) error {
+	return validateNameRequest(in, in.Name)
+}
+
+func (s *Server) validateNotifyNvmeNamespaceChangeRequest(in *NotifyNvmeNamespaceChangeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateMigrateNvmeNamespaceRequest(in *MigrateNvmeNamespaceRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	v.Required("volume_name_ref", in.VolumeNameRef != "")
+	v.ResourceName("name", in.Name)
+	return v.Err()
+}
+
+func (s *Server) validateTraceNvmeNamespaceRequest(in *TraceNvmeNamespaceRequest) error {
+	v := new(validation.Validator)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	v.ResourceName("name", in.Name)
+	// check the trace is time-bounded
+	v.Range("duration_sec", int64(in.DurationSec), 1, 300)
+	v.Range("sample_rate", int64(in.SampleRate), 0, 65536)
+	return v.Err()
+}
+
+func (s *Server) validateGetNvmeNamespaceSharingRequest(in *GetNvmeNamespaceSharingRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateUpdateNvmeNamespaceSharingRequest(in *UpdateNvmeNamespaceSharingRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	v.ResourceName("name", in.Name)
+	if !v.Required("sharing", in.Sharing != nil) {
+		return v.Err()
 	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	// check the namespace is only advertised as private when a single controller sees it,
+	// the hosts on the other controllers couldn't coordinate their access with reservations
+	refs := in.Sharing.ControllerNameRefs
+	v.Check(in.Sharing.Shared || len(refs) == 1, "sharing.controller_name_refs",
+		"A private namespace has to be attached to exactly one controller, %d given", len(refs))
+	// check the controllers are the ones of the subsystem of the namespace, once each
+	seen := make(map[string]bool)
+	subsysID := utils.GetSubsystemIDFromNvmeName(in.Name)
+	for _, name := range refs {
+		v.Check(!seen[name], "sharing.controller_name_refs", "Controller %s is given more than once", name)
+		v.Check(isNvmeController(name, subsysID, path.Base(name)), "sharing.controller_name_refs",
+			"Controller %s is not in the subsystem of the namespace", name)
+		seen[name] = true
+	}
+	return v.Err()
 }
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NvmePhysicalFunction groups the controllers on a PCIe physical function of the DPU and on its
+// virtual functions, so the operators of SR-IOV fleets reason per PF instead of on a flat list
+// of controllers. It is a view computed from the controllers, output only
+type NvmePhysicalFunction struct {
+	// Name of the physical function, i.e. nvmePhysicalFunctions/port0-pf1
+	Name string `json:"name"`
+	// PortID is the PCIe port of the physical function
+	PortID int32 `json:"portId"`
+	// PhysicalFunction is the number of the physical function on its port
+	PhysicalFunction int32 `json:"physicalFunction"`
+	// ControllerNameRefs are the controllers on the physical function then on its virtual
+	// functions, in the order of the functions
+	ControllerNameRefs []string `json:"controllerNameRefs"`
+	// VirtualFunctions is the number of virtual functions with a controller
+	VirtualFunctions int32 `json:"virtualFunctions"`
+	// FreeFunctions is the number of functions of the -pcie_function_pool on the physical
+	// function without a controller
+	FreeFunctions int32 `json:"freeFunctions"`
+	// Namespaces is the number of namespaces attached to the controllers
+	Namespaces int32 `json:"namespaces"`
+	// MaxNsq is the number of IO submission queues of the controllers
+	MaxNsq int32 `json:"maxNsq"`
+	// MaxNcq is the number of IO completion queues of the controllers
+	MaxNcq int32 `json:"maxNcq"`
+}
+
+// GetNvmePhysicalFunctionRequest represents a request to get a physical function
+type GetNvmePhysicalFunctionRequest struct {
+	// Name of the physical function
+	Name string `json:"name"`
+}
+
+// ListNvmePhysicalFunctionsRequest represents a request to list the physical functions
+type ListNvmePhysicalFunctionsRequest struct {
+	// PageSize is the maximum number of physical functions returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListNvmePhysicalFunctionsResponse represents a list of physical functions
+type ListNvmePhysicalFunctionsResponse struct {
+	// NvmePhysicalFunctions is the page of physical functions
+	NvmePhysicalFunctions []*NvmePhysicalFunction `json:"nvmePhysicalFunctions"`
+	// NextPageToken is set when more physical functions are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// nvmePhysicalFunctionName builds the name of a physical function, they have their own
+// collection as they are not part of the OPI APIs
+func nvmePhysicalFunctionName(portID int32, physicalFunction int32) string {
+	return resourcename.Join(
+		"nvmePhysicalFunctions", fmt.Sprintf("port%d-pf%d", portID, physicalFunction),
+	)
+}
+
+// ListNvmePhysicalFunctions lists the physical functions with a controller or in the
+// -pcie_function_pool, sorted by port and function
+func (s *Server) ListNvmePhysicalFunctions(_ context.Context, in *ListNvmePhysicalFunctionsRequest) (*ListNvmePhysicalFunctionsResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	functions, err := s.nvmePhysicalFunctions()
+	if err != nil {
+		return nil, err
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(functions), "offset", offset, "size", size)
+	functions, hasMoreElements = utils.LimitPagination(functions, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	return &ListNvmePhysicalFunctionsResponse{NvmePhysicalFunctions: functions, NextPageToken: token}, nil
+}
+
+// GetNvmePhysicalFunction gets a physical function
+func (s *Server) GetNvmePhysicalFunction(_ context.Context, in *GetNvmePhysicalFunctionRequest) (*NvmePhysicalFunction, error) {
+	// check input correctness
+	if err := s.validateGetNvmePhysicalFunctionRequest(in); err != nil {
+		return nil, err
+	}
+	functions, err := s.nvmePhysicalFunctions()
+	if err != nil {
+		return nil, err
+	}
+	for _, function := range functions {
+		if function.Name == in.Name {
+			return function, nil
+		}
+	}
+	err = status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+	return nil, err
+}
+
+// nvmePhysicalFunctions groups the PCIe controllers by physical function, the controllers over
+// other transports aren't on any
+func (s *Server) nvmePhysicalFunctions() ([]*NvmePhysicalFunction, error) {
+	groups := make(map[string]*NvmePhysicalFunction)
+	group := func(portID int32, physicalFunction int32) *NvmePhysicalFunction {
+		name := nvmePhysicalFunctionName(portID, physicalFunction)
+		if groups[name] == nil {
+			groups[name] = &NvmePhysicalFunction{Name: name, PortID: portID, PhysicalFunction: physicalFunction, ControllerNameRefs: []string{}}
+		}
+		return groups[name]
+	}
+	// the controllers of each group, in the order of their functions
+	functions := make(map[string]PcieFunction)
+	var names []string
+	for _, name := range s.listNames("/nvmeControllers/") {
+		controller := new(pb.NvmeController)
+		found, err := s.store.Get(name, controller)
+		if err != nil {
+			return nil, err
+		}
+		pcieID := controller.GetSpec().GetPcieId()
+		if !found || pcieID == nil {
+			continue
+		}
+		function := pcieFunctionOf(pcieID)
+		functions[name] = function
+		names = append(names, name)
+		pf := group(function.PortID, function.PhysicalFunction)
+		if function.VirtualFunction != 0 {
+			pf.VirtualFunctions++
+		}
+		pf.MaxNsq += controller.GetSpec().GetMaxNsq()
+		pf.MaxNcq += controller.GetSpec().GetMaxNcq()
+	}
+	sort.SliceStable(names, func(i int, j int) bool {
+		return functions[names[i]].VirtualFunction < functions[names[j]].VirtualFunction
+	})
+	used := make(map[PcieFunction]bool)
+	for _, name := range names {
+		function := functions[name]
+		used[function] = true
+		pf := group(function.PortID, function.PhysicalFunction)
+		pf.ControllerNameRefs = append(pf.ControllerNameRefs, name)
+	}
+	for _, function := range s.pcieFunctionPool {
+		pf := group(function.PortID, function.PhysicalFunction)
+		if !used[function] {
+			pf.FreeFunctions++
+		}
+	}
+	// a namespace shared between the controllers of a physical function is counted once
+	namespaces := make(map[string]map[string]bool)
+	for _, name := range s.listNames("/nvmeNamespaces/") {
+		subsys, err := s.nvmeNamespaceSubsystem(name)
+		if err != nil {
+			return nil, err
+		}
+		sharing, err := s.nvmeNamespaceSharing(name)
+		if err != nil {
+			return nil, err
+		}
+		controllers, err := s.sharedNvmeControllers(subsys, sharing)
+		if err != nil {
+			return nil, err
+		}
+		for controller := range controllers {
+			function, ok := functions[controller]
+			if !ok {
+				continue
+			}
+			pf := nvmePhysicalFunctionName(function.PortID, function.PhysicalFunction)
+			if namespaces[pf] == nil {
+				namespaces[pf] = make(map[string]bool)
+			}
+			namespaces[pf][name] = true
+		}
+	}
+	response := make([]*NvmePhysicalFunction, 0, len(groups))
+	for name, pf := range groups {
+		pf.Namespaces = int32(len(namespaces[name]))
+		response = append(response, pf)
+	}
+	sort.Slice(response, func(i int, j int) bool {
+		if response[i].PortID != response[j].PortID {
+			return response[i].PortID < response[j].PortID
+		}
+		return response[i].PhysicalFunction < response[j].PhysicalFunction
+	})
+	return response, nil
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"reflect"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestFrontEnd_ListNvmePhysicalFunctions(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	testPfControllerName := utils.ResourceIDToControllerName(testSubsystemID, "pf-controller-id")
+	testOtherPfControllerName := utils.ResourceIDToControllerName(testSubsystemID, "other-pf-controller-id")
+	testTCPControllerName := utils.ResourceIDToControllerName(testSubsystemID, "tcp-controller-id")
+	controllers := map[string]*pb.NvmeControllerSpec{
+		testControllerName:        {Endpoint: &pb.NvmeControllerSpec_PcieId{PcieId: &pb.PciEndpoint{PortId: wrapperspb.Int32(0), PhysicalFunction: wrapperspb.Int32(1), VirtualFunction: wrapperspb.Int32(2)}}, MaxNsq: 4, MaxNcq: 4},
+		testPfControllerName:      {Endpoint: &pb.NvmeControllerSpec_PcieId{PcieId: &pb.PciEndpoint{PortId: wrapperspb.Int32(0), PhysicalFunction: wrapperspb.Int32(1), VirtualFunction: wrapperspb.Int32(0)}}, MaxNsq: 16, MaxNcq: 8},
+		testOtherPfControllerName: {Endpoint: &pb.NvmeControllerSpec_PcieId{PcieId: &pb.PciEndpoint{PortId: wrapperspb.Int32(0), PhysicalFunction: wrapperspb.Int32(0), VirtualFunction: wrapperspb.Int32(0)}}, MaxNsq: 2, MaxNcq: 2},
+		testTCPControllerName:     {Trtype: pb.NvmeTransportType_NVME_TRANSPORT_TYPE_TCP, MaxNsq: 2, MaxNcq: 2},
+	}
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	for name, spec := range controllers {
+		testEnv.opiSpdkServer.ListHelper[name] = false
+		_ = testEnv.opiSpdkServer.store.Set(name, &pb.NvmeController{Name: name, Spec: spec})
+	}
+	// the namespace is private to the controller on the virtual function
+	testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+	_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+	_ = testEnv.opiSpdkServer.saveNvmeNamespaceSharing(testNamespaceName, &NvmeNamespaceSharing{ControllerNameRefs: []string{testControllerName}})
+	testEnv.opiSpdkServer.SetPcieFunctionPool([]PcieFunction{
+		{PortID: 0, PhysicalFunction: 1, VirtualFunction: 2},
+		{PortID: 0, PhysicalFunction: 1, VirtualFunction: 3},
+		{PortID: 1, PhysicalFunction: 0, VirtualFunction: 1},
+	})
+
+	expected := []*NvmePhysicalFunction{
+		{
+			Name:               "nvmePhysicalFunctions/port0-pf0",
+			ControllerNameRefs: []string{testOtherPfControllerName},
+			MaxNsq:             2,
+			MaxNcq:             2,
+		},
+		{
+			Name:               "nvmePhysicalFunctions/port0-pf1",
+			PhysicalFunction:   1,
+			ControllerNameRefs: []string{testPfControllerName, testControllerName},
+			VirtualFunctions:   1,
+			FreeFunctions:      1,
+			Namespaces:         1,
+			MaxNsq:             20,
+			MaxNcq:             12,
+		},
+		{
+			Name:               "nvmePhysicalFunctions/port1-pf0",
+			PortID:             1,
+			ControllerNameRefs: []string{},
+			FreeFunctions:      1,
+		},
+	}
+	list, err := testEnv.opiSpdkServer.ListNvmePhysicalFunctions(testEnv.ctx, &ListNvmePhysicalFunctionsRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !reflect.DeepEqual(list.NvmePhysicalFunctions, expected) {
+		t.Error("physical functions: expected", expected, "received", list.NvmePhysicalFunctions)
+	}
+
+	list, err = testEnv.opiSpdkServer.ListNvmePhysicalFunctions(testEnv.ctx, &ListNvmePhysicalFunctionsRequest{PageSize: 2})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(list.NvmePhysicalFunctions) != 2 || list.NextPageToken == "" {
+		t.Error("page: expected", 2, "physical functions and a token, received", len(list.NvmePhysicalFunctions), list.NextPageToken)
+	}
+
+	function, err := testEnv.opiSpdkServer.GetNvmePhysicalFunction(testEnv.ctx, &GetNvmePhysicalFunctionRequest{Name: "nvmePhysicalFunctions/port0-pf1"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !reflect.DeepEqual(function, expected[1]) {
+		t.Error("physical function: expected", expected[1], "received", function)
+	}
+	_, err = testEnv.opiSpdkServer.GetNvmePhysicalFunction(testEnv.ctx, &GetNvmePhysicalFunctionRequest{Name: "nvmePhysicalFunctions/port2-pf0"})
+	if er, _ := status.FromError(err); er.Code() != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", er.Code())
+	}
+}
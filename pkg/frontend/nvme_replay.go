@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+// ReplayNvmeResources creates the subsystems, the controllers and the namespaces of the store
+// again in the firmware, i.e. once the SPDK application restarted, so the hosts find their
+// controllers back. The resources which can't be created are kept, their errors are returned
+func (s *Server) ReplayNvmeResources(ctx context.Context) error {
+	// the resources are created as the requests do, none runs meanwhile
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+	var errs []error
+	for _, name := range s.replayNames(isNvmeSubsystem) {
+		errs = append(errs, replayNvmeResource(s, name, new(pb.NvmeSubsystem), func(resource *pb.NvmeSubsystem) error {
+			_, err := s.CreateNvmeSubsystem(ctx, &pb.CreateNvmeSubsystemRequest{
+				NvmeSubsystem:   resource,
+				NvmeSubsystemId: path.Base(name),
+			})
+			return err
+		}))
+	}
+	// the namespaces are attached to the controllers of their subsystem when created
+	for _, name := range s.replayNames(isNvmeController) {
+		errs = append(errs, replayNvmeResource(s, name, new(pb.NvmeController), func(resource *pb.NvmeController) error {
+			_, err := s.CreateNvmeController(ctx, &pb.CreateNvmeControllerRequest{
+				Parent:           utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(name)),
+				NvmeController:   resource,
+				NvmeControllerId: path.Base(name),
+			})
+			return err
+		}))
+	}
+	for _, name := range s.replayNames(isNvmeNamespace) {
+		errs = append(errs, replayNvmeResource(s, name, new(pb.NvmeNamespace), func(resource *pb.NvmeNamespace) error {
+			_, err := s.CreateNvmeNamespace(ctx, &pb.CreateNvmeNamespaceRequest{
+				Parent:          utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(name)),
+				NvmeNamespace:   resource,
+				NvmeNamespaceId: path.Base(name),
+			})
+			return err
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// UnaryServerInterceptor holds the requests while the resources are replayed
+func (s *Server) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		s.requestsMu.RLock()
+		defer s.requestsMu.RUnlock()
+		return handler(ctx, req)
+	}
+}
+
+// replayNames returns the sorted names of the resources matching a kind
+func (s *Server) replayNames(kind func(name string, subsysID string, id string) bool) []string {
+	var names []string
+	for name := range s.ListHelper {
+		if kind(name, utils.GetSubsystemIDFromNvmeName(name), path.Base(name)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// replayNvmeResource creates a resource of the store again as a client would, under the same
+// name, the stored resource is kept when the creation fails
+func replayNvmeResource[T proto.Message](s *Server, name string, resource T, create func(resource T) error) error {
+	found, err := s.store.Get(name, resource)
+	if err != nil {
+		return err
+	}
+	if !found {
+		delete(s.ListHelper, name)
+		return nil
+	}
+	if err := s.store.Delete(name); err != nil {
+		return err
+	}
+	delete(s.ListHelper, name)
+	if err := create(utils.ProtoClone(resource)); err != nil {
+		s.ListHelper[name] = false
+		if err := s.store.Set(name, resource); err != nil {
+			return err
+		}
+		return fmt.Errorf("could not replay %s: %w", name, err)
+	}
+	return nil
+}
+
+// NvmeSubsystemNqns returns the NQNs of the subsystems of the store, the SPDK application lost the
+// configuration of the bridge when it has none of them
+func (s *Server) NvmeSubsystemNqns() []string {
+	// the subsystems are checked in the background, while the requests update ListHelper
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+	var nqns []string
+	for _, name := range s.replayNames(isNvmeSubsystem) {
+		subsys := new(pb.NvmeSubsystem)
+		found, err := s.store.Get(name, subsys)
+		if err != nil || !found {
+			continue
+		}
+		nqns = append(nqns, subsys.GetSpec().GetNqn())
+	}
+	return nqns
+}
+
+// DeactivateNvmeResources reports the controllers inactive and the namespaces offline, i.e. once
+// the SPDK application restarted without them, until they are replayed
+func (s *Server) DeactivateNvmeResources(_ context.Context) error {
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+	var errs []error
+	for _, name := range s.replayNames(isNvmeController) {
+		controller := new(pb.NvmeController)
+		found, err := s.store.Get(name, controller)
+		if err != nil || !found {
+			errs = append(errs, err)
+			continue
+		}
+		_, err = s.setNvmeControllerActive(controller, false)
+		errs = append(errs, err)
+	}
+	for _, name := range s.replayNames(isNvmeNamespace) {
+		namespace := new(pb.NvmeNamespace)
+		found, err := s.store.Get(name, namespace)
+		if err != nil || !found {
+			errs = append(errs, err)
+			continue
+		}
+		namespace.Status = &pb.NvmeNamespaceStatus{
+			State:     pb.NvmeNamespaceStatus_STATE_ENABLED,
+			OperState: pb.NvmeNamespaceStatus_OPER_STATE_OFFLINE,
+		}
+		errs = append(errs, s.store.Set(name, namespace))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/consistency"
+)
+
+func TestFrontEnd_ReplayNvmeResources(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		spdk   []string
+		errMsg string
+	}{
+		"replayed": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"jsonrpc":"2.0","id":%d,"result":{"version":"SPDK v20.10","fields":{"major":20,"minor":10,"patch":0,"suffix":""}}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 17}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+			},
+			errMsg: "",
+		},
+		"namespace not replayed": {
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"jsonrpc":"2.0","id":%d,"result":{"version":"SPDK v20.10","fields":{"major":20,"minor":10,"patch":0,"suffix":""}}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 17}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+			},
+			errMsg: fmt.Sprintf("could not replay %s: rpc error: code = InvalidArgument desc = Could not create NS: %s", testNamespaceName, testNamespaceName),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+
+			err := testEnv.opiSpdkServer.ReplayNvmeResources(testEnv.ctx)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+
+			// the resources are kept, replayed or not
+			controller := new(pb.NvmeController)
+			if found, _ := testEnv.opiSpdkServer.store.Get(testControllerName, controller); !found || !proto.Equal(controller, &testControllerWithStatus) {
+				t.Error("controller: expected", &testControllerWithStatus, "received", controller)
+			}
+			namespace := new(pb.NvmeNamespace)
+			if found, _ := testEnv.opiSpdkServer.store.Get(testNamespaceName, namespace); !found || !proto.Equal(namespace, &testNamespaceWithStatus) {
+				t.Error("namespace: expected", &testNamespaceWithStatus, "received", namespace)
+			}
+			for _, name := range []string{testSubsystemName, testControllerName, testNamespaceName} {
+				if _, ok := testEnv.opiSpdkServer.ListHelper[name]; !ok {
+					t.Error("list helper: expected", name)
+				}
+			}
+		})
+	}
+}
+
+// run with -race, the requests run while the resources are replayed in the background
+func TestFrontEnd_ReplayNvmeResourcesWhileListing(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+		`{"jsonrpc":"2.0","id":%d,"result":{"version":"SPDK v20.10","fields":{"major":20,"minor":10,"patch":0,"suffix":""}}}`,
+	})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+
+	// the cached subsystems are listed from ListHelper, without calling the firmware
+	ctx := consistency.NewContext(testEnv.ctx, consistency.Cached)
+	interceptor := testEnv.opiSpdkServer.UnaryServerInterceptor()
+	list := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return testEnv.opiSpdkServer.ListNvmeSubsystems(ctx, req.(*pb.ListNvmeSubsystemsRequest))
+	}
+	listed := make(chan error)
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := interceptor(ctx, &pb.ListNvmeSubsystemsRequest{}, &grpc.UnaryServerInfo{}, list); err != nil {
+				listed <- err
+				return
+			}
+		}
+		listed <- nil
+	}()
+
+	if err := testEnv.opiSpdkServer.ReplayNvmeResources(testEnv.ctx); err != nil {
+		t.Error("unexpected error", err)
+	}
+	if err := <-listed; err != nil {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestFrontEnd_DeactivateNvmeResources(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+	testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+	testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+	testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+	var reported []string
+	testEnv.opiSpdkServer.SetControllerStateReporter(func(name string, active bool) {
+		reported = append(reported, fmt.Sprint(name, " ", active))
+	})
+
+	expectedNqns := []string{testSubsystemWithStatus.Spec.Nqn}
+	if nqns := testEnv.opiSpdkServer.NvmeSubsystemNqns(); !reflect.DeepEqual(nqns, expectedNqns) {
+		t.Error("nqns: expected", expectedNqns, "received", nqns)
+	}
+
+	if err := testEnv.opiSpdkServer.DeactivateNvmeResources(testEnv.ctx); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	controller := new(pb.NvmeController)
+	if found, _ := testEnv.opiSpdkServer.store.Get(testControllerName, controller); !found || controller.GetStatus().GetActive() {
+		t.Error("controller: expected inactive, received", controller)
+	}
+	namespace := new(pb.NvmeNamespace)
+	if found, _ := testEnv.opiSpdkServer.store.Get(testNamespaceName, namespace); !found || namespace.GetStatus().GetOperState() != pb.NvmeNamespaceStatus_OPER_STATE_OFFLINE {
+		t.Error("namespace: expected offline, received", namespace)
+	}
+	expectedReported := []string{testControllerName + " false"}
+	if !reflect.DeepEqual(reported, expectedReported) {
+		t.Error("reported: expected", expectedReported, "received", reported)
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ResetNvmeControllerRequest represents a request to reset an Nvme controller
+type ResetNvmeControllerRequest struct {
+	// Name of the Nvme controller to reset
+	Name string `json:"name"`
+}
+
+// ResetNvmeSubsystemRequest represents a request to reset an Nvme subsystem
+type ResetNvmeSubsystemRequest struct {
+	// Name of the Nvme subsystem to reset
+	Name string `json:"name"`
+}
+
+// ResetNvmeController resets an Nvme controller, aborting the commands in flight and deleting
+// the I/O queues as a Controller Level Reset does, so a wedged controller can be recovered
+// without resetting the DPU. The host has to re-initialize the controller afterwards
+func (s *Server) ResetNvmeController(ctx context.Context, in *ResetNvmeControllerRequest) (*pb.NvmeController, error) {
+	// check input correctness
+	if err := s.validateResetNvmeControllerRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	controller, subsys, err := s.getNvmeControllerAndSubsystem(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	params := models.MrvlNvmCtrlrResetParams{
+		Subnqn:  subsys.Spec.Nqn,
+		CtrlrID: int(*controller.Spec.NvmeControllerId),
+	}
+	var result models.MrvlNvmCtrlrResetResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_ctrlr_reset", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not reset CTRL: %s", in.Name)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_ctrlr_reset")
+	}
+	return controller, nil
+}
+
+// ResetNvmeSubsystem resets an Nvme subsystem as an NVM Subsystem Reset does, resetting
+// all of its controllers at once
+func (s *Server) ResetNvmeSubsystem(ctx context.Context, in *ResetNvmeSubsystemRequest) (*pb.NvmeSubsystem, error) {
+	// check input correctness
+	if err := s.validateResetNvmeSubsystemRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	subsys := new(pb.NvmeSubsystem)
+	found, err := s.store.Get(in.Name, subsys)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	params := models.MrvlNvmSubsysResetParams{
+		Subnqn: subsys.Spec.Nqn,
+	}
+	var result models.MrvlNvmSubsysResetResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_subsys_reset", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not reset NQN: %s", subsys.Spec.Nqn)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_reset")
+	}
+	return subsys, nil
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+)
+
+// StatsNvmeBulkRequest represents a request to get the stats of all the Nvme controllers and
+// namespaces at once
+type StatsNvmeBulkRequest struct{}
+
+// NvmeResourceStats represents the stats of an Nvme controller or namespace
+type NvmeResourceStats struct {
+	// Name of the Nvme controller or namespace
+	Name string `json:"name"`
+	// Stats of the controller or namespace, since they were cleared
+	Stats *pb.VolumeStats `json:"stats"`
+}
+
+// StatsNvmeBulkResponse represents the stats of the Nvme controllers and namespaces, sorted by
+// name, the ones whose stats can't be read are left out
+type StatsNvmeBulkResponse struct {
+	Stats []*NvmeResourceStats `json:"stats"`
+}
+
+// StatsNvmeBulk gets the stats of all the Nvme controllers and namespaces in one call to the
+// firmware, instead of one call each
+func (s *Server) StatsNvmeBulk(ctx context.Context, _ *StatsNvmeBulkRequest) (*StatsNvmeBulkResponse, error) {
+	controllers := s.listNames("/nvmeControllers/")
+	namespaces := s.listNames("/nvmeNamespaces/")
+	stats, err := s.bulkStats(ctx, controllers, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	// the controllers and namespaces are listed so the tenants only get theirs, as the names are
+	// the keys of a map
+	response := &StatsNvmeBulkResponse{Stats: make([]*NvmeResourceStats, 0, len(stats))}
+	for _, name := range append(controllers, namespaces...) {
+		if stats[name] != nil {
+			response.Stats = append(response.Stats, &NvmeResourceStats{Name: name, Stats: stats[name]})
+		}
+	}
+	return response, nil
+}
+
+// bulkStats reads the stats of Nvme controllers and namespaces with the bulk stats of the
+// firmware, keyed by name. The ones the firmware doesn't report, or all of them when it has no
+// bulk stats, are read one by one, the ones which can't be read are left out
+func (s *Server) bulkStats(ctx context.Context, controllers []string, namespaces []string) (map[string]*pb.VolumeStats, error) {
+	stats := make(map[string]*pb.VolumeStats, len(controllers)+len(namespaces))
+	if len(controllers)+len(namespaces) == 0 {
+		return stats, nil
+	}
+	var result models.MrvlNvmGetBulkStatsResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_get_bulk_stats", nil, &result)
+	switch {
+	case err != nil && strings.Contains(strings.ToLower(err.Error()), "method not found"):
+		slog.DebugContext(ctx, "The firmware has no bulk stats, reading them one by one", "error", err)
+	case err != nil:
+		return nil, err
+	case result.Status != 0:
+		msg := fmt.Sprintf("Could not get the bulk stats, status %s", result.Status.Name("mrvl_nvm_get_bulk_stats"))
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_bulk_stats")
+	}
+	bulk := make(map[string]*pb.VolumeStats, len(result.CtrlrStats)+len(result.NsStats))
+	for _, ctrlr := range result.CtrlrStats {
+		bulk[fmt.Sprintf("%s/nvmeControllers/%d", ctrlr.Subnqn, ctrlr.CtrlrID)] = &pb.VolumeStats{
+			ReadBytesCount:    int32(ctrlr.NumReadBytes),
+			ReadOpsCount:      int32(ctrlr.NumReadCmds),
+			WriteBytesCount:   int32(ctrlr.NumWriteBytes),
+			WriteOpsCount:     int32(ctrlr.NumWriteCmds),
+			ReadLatencyTicks:  int32(ctrlr.TotalReadLatencyInUs),
+			WriteLatencyTicks: int32(ctrlr.TotalWriteLatencyInUs),
+		}
+	}
+	for _, ns := range result.NsStats {
+		bulk[fmt.Sprintf("%s/nvmeNamespaces/%d", ns.Subnqn, ns.NsInstanceID)] = &pb.VolumeStats{
+			ReadBytesCount:    int32(ns.NumReadBytes),
+			ReadOpsCount:      int32(ns.NumReadCmds),
+			WriteBytesCount:   int32(ns.NumWriteBytes),
+			WriteOpsCount:     int32(ns.NumWriteCmds),
+			ReadLatencyTicks:  int32(ns.TotalReadLatencyInUs),
+			WriteLatencyTicks: int32(ns.TotalWriteLatencyInUs),
+		}
+	}
+
+	missingControllers := s.matchBulkStats(stats, bulk, controllers, s.bulkControllerKey)
+	for i, read := range s.collectStats(ctx, missingControllers, "NvmeController", s.controllerStats) {
+		if read != nil {
+			stats[missingControllers[i]] = read
+		}
+	}
+	missingNamespaces := s.matchBulkStats(stats, bulk, namespaces, s.bulkNamespaceKey)
+	for i, read := range s.collectStats(ctx, missingNamespaces, "NvmeNamespace", s.namespaceStats) {
+		if read != nil {
+			stats[missingNamespaces[i]] = read
+		}
+	}
+	return stats, nil
+}
+
+// matchBulkStats sets the stats of the resources the bulk stats report, since they were cleared,
+// and returns the names of the other ones
+func (s *Server) matchBulkStats(stats map[string]*pb.VolumeStats, bulk map[string]*pb.VolumeStats, names []string, key func(name string) string) []string {
+	var missing []string
+	for _, name := range names {
+		reported, ok := bulk[key(name)]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		stats[name] = s.statsSinceCleared(name, reported)
+	}
+	return missing
+}
+
+// bulkControllerKey returns the key of the bulk stats of a controller, empty when it can't be
+// found in the database
+func (s *Server) bulkControllerKey(name string) string {
+	controller := new(pb.NvmeController)
+	found, err := s.store.Get(name, controller)
+	if err != nil || !found || controller.GetSpec().NvmeControllerId == nil {
+		return ""
+	}
+	nqn := s.subsystemNqn(name)
+	if nqn == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/nvmeControllers/%d", nqn, *controller.Spec.NvmeControllerId)
+}
+
+// bulkNamespaceKey returns the key of the bulk stats of a namespace, empty when it can't be
+// found in the database
+func (s *Server) bulkNamespaceKey(name string) string {
+	namespace := new(pb.NvmeNamespace)
+	found, err := s.store.Get(name, namespace)
+	if err != nil || !found {
+		return ""
+	}
+	nqn := s.subsystemNqn(name)
+	if nqn == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/nvmeNamespaces/%d", nqn, namespace.GetSpec().GetHostNsid())
+}
+
+// subsystemNqn returns the NQN of the subsystem of an Nvme controller or namespace, empty when
+// it can't be found in the database
+func (s *Server) subsystemNqn(name string) string {
+	subsys := new(pb.NvmeSubsystem)
+	found, err := s.store.Get(utils.ResourceIDToSubsystemName(utils.GetSubsystemIDFromNvmeName(name)), subsys)
+	if err != nil || !found {
+		return ""
+	}
+	return subsys.GetSpec().GetNqn()
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func TestFrontEnd_StatsNvmeBulk(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	controllerStats := &pb.VolumeStats{ReadBytesCount: 5, ReadOpsCount: 4, WriteBytesCount: 7, WriteOpsCount: 6, ReadLatencyTicks: 9, WriteLatencyTicks: 10}
+	namespaceStats := &pb.VolumeStats{ReadBytesCount: 15, ReadOpsCount: 14, WriteBytesCount: 17, WriteOpsCount: 16, ReadLatencyTicks: 19, WriteLatencyTicks: 20}
+	bulkController := `{"subnqn":"nqn.2022-09.io.spdk:opi3","ctrlr_id":17,"num_read_cmds":4,"num_read_bytes":5,"num_write_cmds":6,"num_write_bytes":7,"total_read_latency_in_us":9,"total_write_latency_in_us":10}`
+	bulkNamespace := `{"subnqn":"nqn.2022-09.io.spdk:opi3","ns_instance_id":22,"num_read_cmds":14,"num_read_bytes":15,"num_write_cmds":16,"num_write_bytes":17,"total_read_latency_in_us":19,"total_write_latency_in_us":20}`
+	tests := map[string]struct {
+		out     []*NvmeResourceStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with bulk stats": {
+			out: []*NvmeResourceStats{{Name: testControllerName, Stats: controllerStats}, {Name: testNamespaceName, Stats: namespaceStats}},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"ctrlr_stats":[` + bulkController + `],"ns_stats":[` + bulkNamespace + `]}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with partial bulk stats": {
+			out: []*NvmeResourceStats{{Name: testControllerName, Stats: controllerStats}, {Name: testNamespaceName, Stats: namespaceStats}},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"ctrlr_stats":[` + bulkController + `],"ns_stats":[]}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"num_read_cmds":14,"num_read_bytes":15,"num_write_cmds":16,"num_write_bytes":17,"total_read_latency_in_us":19,"total_write_latency_in_us":20}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request without bulk stats in the firmware": {
+			out: []*NvmeResourceStats{{Name: testControllerName, Stats: controllerStats}},
+			spdk: []string{
+				`{"id":%d,"error":{"code":-32601,"message":"Method not found"},"result":null}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"num_read_cmds":4,"num_read_bytes":5,"num_write_cmds":6,"num_write_bytes":7,"total_read_latency_in_us":9,"total_write_latency_in_us":10}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status":1}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status":-12}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not get the bulk stats, status NO_MEMORY",
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status":1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_get_bulk_stats: %v", "json response error: myopierr"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+
+			response, err := testEnv.opiSpdkServer.StatsNvmeBulk(testEnv.ctx, &StatsNvmeBulkRequest{})
+
+			if tt.out == nil && response != nil {
+				t.Error("response: expected none, received", response)
+			}
+			if tt.out != nil {
+				if response == nil || len(response.Stats) != len(tt.out) {
+					t.Fatal("response: expected", tt.out, "received", response)
+				}
+				for i, stats := range tt.out {
+					if response.Stats[i].Name != stats.Name || !proto.Equal(response.Stats[i].Stats, stats.Stats) {
+						t.Error("stats: expected", stats, "received", response.Stats[i])
+					}
+				}
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/fanout"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClearNvmeStats clears the stats of an Nvme controller or namespace, or of all of them when
+// name is empty. The firmware counters can't be reset, the stats are reported from the values
+// they had when cleared on
+func (s *Server) ClearNvmeStats(ctx context.Context, name string) error {
+	var names []string
+	if name != "" {
+		if _, ok := s.ListHelper[name]; !ok {
+			return status.Errorf(codes.NotFound, "unable to find key %s", name)
+		}
+		names = []string{name}
+	} else {
+		names = append(s.replayNames(isNvmeController), s.replayNames(isNvmeNamespace)...)
+	}
+	reads := make([]func(ctx context.Context, name string) (*pb.VolumeStats, error), len(names))
+	for i, name := range names {
+		switch subsysID, id := utils.GetSubsystemIDFromNvmeName(name), path.Base(name); {
+		case isNvmeController(name, subsysID, id):
+			reads[i] = s.controllerStats
+		case isNvmeNamespace(name, subsysID, id):
+			reads[i] = s.namespaceStats
+		default:
+			msg := fmt.Sprintf("Could not clear the stats of %s, have to be an Nvme controller or namespace", name)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	indexes := make([]int, len(names))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	// the stats are read in parallel, a failure doesn't keep the others from being cleared
+	errs := make([]error, len(names))
+	_, _ = fanout.Map(ctx, s.fanoutWorkers, indexes, func(ctx context.Context, i int) (struct{}, error) {
+		s.forgetClearedStats(names[i])
+		stats, err := reads[i](ctx, names[i])
+		if err != nil {
+			errs[i] = err
+			return struct{}{}, nil
+		}
+		s.clearedStatsMu.Lock()
+		s.clearedStats[names[i]] = stats
+		s.clearedStatsMu.Unlock()
+		return struct{}{}, nil
+	})
+	return errors.Join(errs...)
+}
+
+// isNvmeController tells whether a name is the one of an Nvme controller
+func isNvmeController(name string, subsysID string, id string) bool {
+	return name == utils.ResourceIDToControllerName(subsysID, id)
+}
+
+// isNvmeNamespace tells whether a name is the one of an Nvme namespace
+func isNvmeNamespace(name string, subsysID string, id string) bool {
+	return name == utils.ResourceIDToNamespaceName(subsysID, id)
+}
+
+// statsSinceCleared returns the stats of a controller or namespace since they were cleared. The
+// counters lower than when cleared were reset by a restart of the firmware, they are reported as is
+func (s *Server) statsSinceCleared(name string, stats *pb.VolumeStats) *pb.VolumeStats {
+	s.clearedStatsMu.Lock()
+	defer s.clearedStatsMu.Unlock()
+	cleared, ok := s.clearedStats[name]
+	if !ok {
+		return stats
+	}
+	since := &pb.VolumeStats{
+		ReadBytesCount:    stats.ReadBytesCount - cleared.ReadBytesCount,
+		ReadOpsCount:      stats.ReadOpsCount - cleared.ReadOpsCount,
+		WriteBytesCount:   stats.WriteBytesCount - cleared.WriteBytesCount,
+		WriteOpsCount:     stats.WriteOpsCount - cleared.WriteOpsCount,
+		ReadLatencyTicks:  stats.ReadLatencyTicks - cleared.ReadLatencyTicks,
+		WriteLatencyTicks: stats.WriteLatencyTicks - cleared.WriteLatencyTicks,
+	}
+	if since.ReadBytesCount < 0 || since.ReadOpsCount < 0 || since.WriteBytesCount < 0 ||
+		since.WriteOpsCount < 0 || since.ReadLatencyTicks < 0 || since.WriteLatencyTicks < 0 {
+		delete(s.clearedStats, name)
+		return stats
+	}
+	return since
+}
+
+// forgetClearedStats forgets when the stats of a controller or namespace were cleared
+func (s *Server) forgetClearedStats(name string) {
+	s.clearedStatsMu.Lock()
+	defer s.clearedStatsMu.Unlock()
+	delete(s.clearedStats, name)
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func TestFrontEnd_ClearNvmeStats(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"jsonrpc":"2.0","id":%d,"result":{"status":0,"num_read_cmds":4,"num_read_bytes":5,"num_write_cmds":6,"num_write_bytes":7,"total_read_latency_in_us":9,"total_write_latency_in_us":10}}`,
+		`{"jsonrpc":"2.0","id":%d,"result":{"status":0,"num_read_cmds":14,"num_read_bytes":15,"num_write_cmds":16,"num_write_bytes":17,"total_read_latency_in_us":19,"total_write_latency_in_us":20}}`,
+		`{"jsonrpc":"2.0","id":%d,"result":{"status":0,"num_read_cmds":1,"num_read_bytes":2,"num_write_cmds":3,"num_write_bytes":4,"total_read_latency_in_us":5,"total_write_latency_in_us":6}}`,
+	})
+	defer testEnv.Close()
+	_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+	_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+	testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+	testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+
+	if err := testEnv.opiSpdkServer.ClearNvmeStats(testEnv.ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	// the stats count from when they were cleared
+	response, err := testEnv.client.StatsNvmeController(testEnv.ctx, &pb.StatsNvmeControllerRequest{Name: testControllerName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &pb.VolumeStats{ReadBytesCount: 10, ReadOpsCount: 10, WriteBytesCount: 10, WriteOpsCount: 10, ReadLatencyTicks: 10, WriteLatencyTicks: 10}
+	if !proto.Equal(response.GetStats(), expected) {
+		t.Error("response: expected", expected, "received", response.GetStats())
+	}
+	// the counters of a restarted firmware are reported as is
+	response, err = testEnv.client.StatsNvmeController(testEnv.ctx, &pb.StatsNvmeControllerRequest{Name: testControllerName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = &pb.VolumeStats{ReadBytesCount: 2, ReadOpsCount: 1, WriteBytesCount: 4, WriteOpsCount: 3, ReadLatencyTicks: 5, WriteLatencyTicks: 6}
+	if !proto.Equal(response.GetStats(), expected) {
+		t.Error("response: expected", expected, "received", response.GetStats())
+	}
+
+	err = testEnv.opiSpdkServer.ClearNvmeStats(testEnv.ctx, testNamespaceName)
+	if status.Code(err) != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", status.Code(err))
+	}
+}
@@ -9,18 +9,17 @@ package frontend
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"path"
 	"sort"
 	"strings"
 
 	"github.com/opiproject/gospdk/spdk"
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/consistency"
 	"github.com/opiproject/opi-marvell-bridge/pkg/models"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 
-	"github.com/google/uuid"
-	"go.einride.tech/aip/fieldbehavior"
 	"go.einride.tech/aip/fieldmask"
 	"go.einride.tech/aip/resourceid"
 	"google.golang.org/grpc/codes"
@@ -36,14 +35,20 @@ func sortNvmeSubsystems(subsystems []*pb.NvmeSubsystem) {
 
 // CreateNvmeSubsystem creates an Nvme Subsystem
 func (s *Server) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsystemRequest) (*pb.NvmeSubsystem, error) {
+	// subsystems created without an NQN are given one made of a UUID
+	if in.GetNvmeSubsystem().GetSpec() != nil && in.NvmeSubsystem.Spec.Nqn == "" {
+		in.NvmeSubsystem.Spec.Nqn = generateNqn()
+		slog.Info("Generated NQN", "id", in.NvmeSubsystemId, "nqn", in.NvmeSubsystem.Spec.Nqn)
+	}
 	// check input correctness
-	if err := s.validateCreateNvmeSubsystemRequest(in); err != nil {
+	warnings, err := s.validateCreateNvmeSubsystemRequest(in)
+	if err != nil {
 		return nil, err
 	}
 	// see https://google.aip.dev/133#user-specified-ids
 	resourceID := resourceid.NewSystemGenerated()
 	if in.NvmeSubsystemId != "" {
-		log.Printf("client provided the ID of a resource %v, ignoring the name field %v", in.NvmeSubsystemId, in.NvmeSubsystem.Name)
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.NvmeSubsystemId, "name", in.NvmeSubsystem.Name)
 		resourceID = in.NvmeSubsystemId
 	}
 	in.NvmeSubsystem.Name = utils.ResourceIDToSubsystemName(resourceID)
@@ -54,7 +59,7 @@ func (s *Server) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsy
 		return nil, err
 	}
 	if found {
-		log.Printf("Already existing NvmeSubsystem with id %v", in.NvmeSubsystem.Name)
+		slog.Info("Already existing NvmeSubsystem", "name", in.NvmeSubsystem.Name)
 		return subsys, nil
 	}
 	// check if another object exists with same NQN, it is not allowed
@@ -76,7 +81,12 @@ func (s *Server) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsy
 			return nil, status.Errorf(codes.AlreadyExists, msg)
 		}
 	}
+	// the SKU of the DPU has to allow one more subsystem
+	if err := s.checkSkuLimit(ctx, "nvmeSubsystems"); err != nil {
+		return nil, err
+	}
 	// not found, so create a new one
+	s.applyNvmeSubsystemDefaults(in.NvmeSubsystem)
 
 	// TODO: fix const values below
 	params := models.MrvlNvmCreateSubsystemParams{
@@ -85,24 +95,30 @@ func (s *Server) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsy
 		Sn:            in.NvmeSubsystem.Spec.SerialNumber,
 		MaxNamespaces: int(in.NvmeSubsystem.Spec.MaxNamespaces),
 		MinCtrlrID:    0, // bug in v21.01, should be 0 for now
-		MaxCtrlrID:    256,
+		MaxCtrlrID:    defaultMaxCtrlrID,
+	}
+	// apply the limits set before the subsystem was created
+	limits, found, err := s.getNvmeSubsystemLimits(in.NvmeSubsystem.Name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		applyNvmeSubsystemLimits(&params, limits)
 	}
 	var result models.MrvlNvmCreateSubsystemResult
 	err = s.rpc.Call(ctx, "mrvl_nvm_create_subsystem", &params, &result)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not create NQN: %s", in.NvmeSubsystem.Spec.Nqn)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_create_subsystem")
 	}
 	var ver spdk.GetVersionResult
 	err = s.rpc.Call(ctx, "spdk_get_version", nil, &ver)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", ver)
 	response := utils.ProtoClone(in.NvmeSubsystem)
 	response.Status = &pb.NvmeSubsystemStatus{FirmwareRevision: ver.Version}
 	// save object to the database
@@ -111,6 +127,10 @@ func (s *Server) CreateNvmeSubsystem(ctx context.Context, in *pb.CreateNvmeSubsy
 	if err != nil {
 		return nil, err
 	}
+	err = s.saveValidationWarnings(in.NvmeSubsystem.Name, warnings)
+	if err != nil {
+		return nil, err
+	}
 	return response, nil
 }
 
@@ -133,6 +153,10 @@ func (s *Server) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsy
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
+	// protected resources are only deleted once their protection is cleared
+	if err := s.checkNvmeDeletionProtection(in.Name); err != nil {
+		return nil, err
+	}
 	params := models.MrvlNvmDeleteSubsystemParams{
 		Subnqn: subsys.Spec.Nqn,
 	}
@@ -141,10 +165,9 @@ func (s *Server) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsy
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not delete NQN: %s", subsys.Spec.Nqn)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_delete_subsystem")
 	}
 	// remove from the Database
 	delete(s.ListHelper, subsys.Name)
@@ -152,6 +175,18 @@ func (s *Server) DeleteNvmeSubsystem(ctx context.Context, in *pb.DeleteNvmeSubsy
 	if err != nil {
 		return nil, err
 	}
+	err = s.deleteNvmeSubsystemLimits(subsys.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.deleteNvmeDeletionProtection(subsys.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.saveValidationWarnings(subsys.Name, nil)
+	if err != nil {
+		return nil, err
+	}
 	return &emptypb.Empty{}, nil
 }
 
@@ -169,7 +204,7 @@ func (s *Server) UpdateNvmeSubsystem(_ context.Context, in *pb.UpdateNvmeSubsyst
 	}
 	if !found {
 		if in.AllowMissing {
-			log.Printf("TODO: in case of AllowMissing, create a new resource, don;t return error")
+			slog.Debug("TODO: in case of AllowMissing, create a new resource, don;t return error")
 		}
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.NvmeSubsystem.Name)
 		return nil, err
@@ -179,37 +214,42 @@ func (s *Server) UpdateNvmeSubsystem(_ context.Context, in *pb.UpdateNvmeSubsyst
 	if err := fieldmask.Validate(in.UpdateMask, in.NvmeSubsystem); err != nil {
 		return nil, err
 	}
-	log.Printf("TODO: use resourceID=%v", resourceID)
+	slog.Debug("TODO: use resourceID", "id", resourceID)
 	return nil, status.Errorf(codes.Unimplemented, "UpdateNvmeSubsystem method is not implemented")
 }
 
 // ListNvmeSubsystems lists Nvme Subsystems
 func (s *Server) ListNvmeSubsystems(ctx context.Context, in *pb.ListNvmeSubsystemsRequest) (*pb.ListNvmeSubsystemsResponse, error) {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+	// check input correctness
+	if err := s.validateListNvmeSubsystemsRequest(in); err != nil {
 		return nil, err
 	}
 	// fetch object from the database
-	size, offset, perr := utils.ExtractPagination(in.PageSize, in.PageToken, s.Pagination)
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
 	if perr != nil {
 		return nil, perr
 	}
+	if consistency.FromContext(ctx) == consistency.Cached {
+		subsystems, token, err := storedNvmeResources(s, isNvmeSubsystem, "", func() *pb.NvmeSubsystem { return new(pb.NvmeSubsystem) }, sortNvmeSubsystems, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: subsystems, NextPageToken: token}, nil
+	}
 	var result models.MrvlNvmGetSubsysListResult
 	err := s.rpc.Call(ctx, "mrvl_nvm_get_subsys_list", nil, &result)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := "Could not list subsystems"
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_subsys_list")
 	}
 	token, hasMoreElements := "", false
-	log.Printf("Limiting result len(%d) to [%d:%d]", len(result.SubsysList), offset, size)
+	slog.Debug("Limiting result", "len", len(result.SubsysList), "offset", offset, "size", size)
 	result.SubsysList, hasMoreElements = utils.LimitPagination(result.SubsysList, offset, size)
 	if hasMoreElements {
-		token = uuid.New().String()
-		s.Pagination[token] = offset + size
+		token = s.Pagination.NewToken(offset + size)
 	}
 	Blobarray := make([]*pb.NvmeSubsystem, len(result.SubsysList))
 	for i := range result.SubsysList {
@@ -217,7 +257,7 @@ func (s *Server) ListNvmeSubsystems(ctx context.Context, in *pb.ListNvmeSubsyste
 		Blobarray[i] = &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{Nqn: r.Subnqn}}
 	}
 	sortNvmeSubsystems(Blobarray)
-	return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: Blobarray}, nil
+	return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: Blobarray, NextPageToken: token}, nil
 }
 
 // GetNvmeSubsystem gets Nvme Subsystems
@@ -236,21 +276,24 @@ func (s *Server) GetNvmeSubsystem(ctx context.Context, in *pb.GetNvmeSubsystemRe
 		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
 		return nil, err
 	}
+	if consistency.FromContext(ctx) == consistency.Cached {
+		return subsys, nil
+	}
 	// TODO: replace with MRVL code : mrvl_nvm_subsys_get_info ?
 	var result models.MrvlNvmGetSubsysListResult
 	err = s.rpc.Call(ctx, "mrvl_nvm_get_subsys_list", nil, &result)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not list NQN: %s", subsys.Spec.Nqn)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_get_subsys_list")
 	}
 	for i := range result.SubsysList {
 		r := &result.SubsysList[i]
+		// the subsystem is returned as created, so its spec round-trips
 		if r.Subnqn == subsys.Spec.Nqn {
-			return &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{Nqn: r.Subnqn}, Status: &pb.NvmeSubsystemStatus{FirmwareRevision: "TBD"}}, nil
+			return subsys, nil
 		}
 	}
 	msg := fmt.Sprintf("Could not find NQN: %s", subsys.Spec.Nqn)
@@ -281,10 +324,9 @@ func (s *Server) StatsNvmeSubsystem(ctx context.Context, in *pb.StatsNvmeSubsyst
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Received from SPDK: %v", result)
 	if result.Status != 0 {
 		msg := fmt.Sprintf("Could not stats NQN: %s", subsys.Spec.Nqn)
-		return nil, status.Errorf(codes.InvalidArgument, msg)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_nvm_subsys_get_info")
 	}
 	return &pb.StatsNvmeSubsystemResponse{Stats: &pb.VolumeStats{ReadOpsCount: -1, WriteOpsCount: -1}}, nil
 }
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// defaultMaxCtrlrID is the highest controller ID of the subsystems created without a limit
+// on their controllers
+const defaultMaxCtrlrID = 256
+
+// NvmeSubsystemLimits represents the limits of an Nvme subsystem which are not part of the OPI
+// NvmeSubsystemSpec. They are advertised to the hosts in Identify Controller, so they can only be
+// set before the subsystem is created (with a user specified id) and are applied on creation
+type NvmeSubsystemLimits struct {
+	// MaxControllers is the number of controllers the subsystem can have, 0 keeps the firmware default
+	MaxControllers int32 `json:"maxControllers"`
+	// MinSqes and MaxSqes bound the submission queue entries of the controllers, as a power of 2,
+	// 0 leaves the bound to the firmware
+	MinSqes int32 `json:"minSqes"`
+	MaxSqes int32 `json:"maxSqes"`
+	// MinCqes and MaxCqes bound the completion queue entries of the controllers, as a power of 2,
+	// 0 leaves the bound to the firmware
+	MinCqes int32 `json:"minCqes"`
+	MaxCqes int32 `json:"maxCqes"`
+	// IeeeOui is the Organizationally Unique Identifier of the vendor the hosts see, 6 hex digits,
+	// empty keeps the one of Marvell
+	IeeeOui string `json:"ieeeOui"`
+	// Status is reported by the firmware for existing subsystems, output only
+	Status *NvmeSubsystemLimitsStatus `json:"status,omitempty"`
+}
+
+// NvmeSubsystemLimitsStatus represents the limits in effect on an Nvme subsystem
+type NvmeSubsystemLimitsStatus struct {
+	// MaxControllers is the number of controllers the subsystem can have
+	MaxControllers int32 `json:"maxControllers"`
+	// Controllers is the number of controllers the subsystem has
+	Controllers int32 `json:"controllers"`
+	// MinSqes and MaxSqes bound the submission queue entries of the controllers
+	MinSqes int32 `json:"minSqes"`
+	MaxSqes int32 `json:"maxSqes"`
+	// MinCqes and MaxCqes bound the completion queue entries of the controllers
+	MinCqes int32 `json:"minCqes"`
+	MaxCqes int32 `json:"maxCqes"`
+	// IeeeOui is the Organizationally Unique Identifier the hosts see
+	IeeeOui string `json:"ieeeOui"`
+}
+
+// GetNvmeSubsystemLimitsRequest represents a request to get the limits of an Nvme subsystem
+type GetNvmeSubsystemLimitsRequest struct {
+	// Name of the Nvme subsystem
+	Name string `json:"name"`
+}
+
+// UpdateNvmeSubsystemLimitsRequest represents a request to set the limits of an Nvme subsystem
+type UpdateNvmeSubsystemLimitsRequest struct {
+	// Name of the Nvme subsystem
+	Name string `json:"name"`
+	// Limits to set on the Nvme subsystem
+	Limits *NvmeSubsystemLimits `json:"limits"`
+}
+
+// GetNvmeSubsystemLimits gets the limits of an Nvme subsystem, with the ones in effect once it exists
+func (s *Server) GetNvmeSubsystemLimits(ctx context.Context, in *GetNvmeSubsystemLimitsRequest) (*NvmeSubsystemLimits, error) {
+	// check input correctness
+	if err := s.validateGetNvmeSubsystemLimitsRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	limits, found, err := s.getNvmeSubsystemLimits(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	subsys := new(pb.NvmeSubsystem)
+	exists, err := s.store.Get(in.Name, subsys)
+	if err != nil {
+		return nil, err
+	}
+	if !found && !exists {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if limits == nil {
+		limits = new(NvmeSubsystemLimits)
+	}
+	// report what the firmware applied once the subsystem exists
+	if exists {
+		limits.Status, err = s.getNvmeSubsystemLimitsStatus(ctx, subsys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return limits, nil
+}
+
+// UpdateNvmeSubsystemLimits sets the limits of an Nvme subsystem, which is created with them
+func (s *Server) UpdateNvmeSubsystemLimits(_ context.Context, in *UpdateNvmeSubsystemLimitsRequest) (*NvmeSubsystemLimits, error) {
+	// check input correctness
+	if err := s.validateUpdateNvmeSubsystemLimitsRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	subsys := new(pb.NvmeSubsystem)
+	found, err := s.store.Get(in.Name, subsys)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		msg := fmt.Sprintf("Limits of subsystem %s can only be set before it is created", in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	limits := *in.Limits
+	limits.Status = nil
+	// save object to the database
+	if err := s.saveNvmeSubsystemLimits(in.Name, &limits); err != nil {
+		return nil, err
+	}
+	return &limits, nil
+}
+
+func (s *Server) getNvmeSubsystemLimitsStatus(ctx context.Context, subsys *pb.NvmeSubsystem) (*NvmeSubsystemLimitsStatus, error) {
+	params := models.MrvlNvmGetSubsysInfoParams{
+		Subnqn: subsys.Spec.Nqn,
+	}
+	var result models.MrvlNvmGetSubsysInfoResult
+	err := s.rpc.Call(ctx, "mrvl_nvm_subsys_get_info", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 || len(result.SubsysList) == 0 {
+		msg := fmt.Sprintf("Could not get NQN: %s", subsys.Spec.Nqn)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	r := &result.SubsysList[0]
+	return &NvmeSubsystemLimitsStatus{
+		MaxControllers: int32(r.MaxCtrlrID - r.MinCtrlrID),
+		Controllers:    int32(r.NumTotalCtrlr),
+		MinSqes:        int32(r.MinSqes),
+		MaxSqes:        int32(r.MaxSqes),
+		MinCqes:        int32(r.MinCqes),
+		MaxCqes:        int32(r.MaxCqes),
+		IeeeOui:        r.IeeeOui,
+	}, nil
+}
+
+// applyNvmeSubsystemLimits sets the limits saved before a subsystem is created on its creation
+func applyNvmeSubsystemLimits(params *models.MrvlNvmCreateSubsystemParams, limits *NvmeSubsystemLimits) {
+	if limits.MaxControllers > 0 {
+		params.MaxCtrlrID = params.MinCtrlrID + int(limits.MaxControllers)
+	}
+	params.MinSqes = int(limits.MinSqes)
+	params.MaxSqes = int(limits.MaxSqes)
+	params.MinCqes = int(limits.MinCqes)
+	params.MaxCqes = int(limits.MaxCqes)
+	params.IeeeOui = limits.IeeeOui
+}
+
+// checkNvmeSubsystemLimits checks a new controller is within the limits of its subsystem
+func (s *Server) checkNvmeSubsystemLimits(subsysName string, spec *pb.NvmeControllerSpec) error {
+	limits, found, err := s.getNvmeSubsystemLimits(subsysName)
+	if err != nil || !found {
+		return err
+	}
+	if limits.MaxControllers > 0 {
+		subsysID := utils.GetSubsystemIDFromNvmeName(subsysName)
+		controllers := 0
+		for _, name := range s.replayNames(isNvmeController) {
+			if utils.GetSubsystemIDFromNvmeName(name) == subsysID {
+				controllers++
+			}
+		}
+		if controllers >= int(limits.MaxControllers) {
+			msg := fmt.Sprintf("Subsystem %s already has its %d controllers", subsysName, limits.MaxControllers)
+			return status.Errorf(codes.ResourceExhausted, msg)
+		}
+	}
+	if err := checkQueueEntriesBounds("Sqes", spec.Sqes, limits.MinSqes, limits.MaxSqes); err != nil {
+		return err
+	}
+	return checkQueueEntriesBounds("Cqes", spec.Cqes, limits.MinCqes, limits.MaxCqes)
+}
+
+// checkQueueEntriesBounds checks the queue entries of a controller, 0 when left to the firmware,
+// are within the bounds of its subsystem, 0 when not bounded
+func checkQueueEntriesBounds(field string, entries int32, minEntries int32, maxEntries int32) error {
+	if entries == 0 {
+		return nil
+	}
+	if (minEntries > 0 && entries < minEntries) || (maxEntries > 0 && entries > maxEntries) {
+		msg := fmt.Sprintf("%s value (%d) is out of the bounds of the subsystem, have to be between %d and %d", field, entries, minEntries, maxEntries)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// nvmeSubsystemLimitsKey is the database key of the limits of an Nvme subsystem
+func nvmeSubsystemLimitsKey(name string) string {
+	return name + "/limits"
+}
+
+// getNvmeSubsystemLimits fetches the limits of an Nvme subsystem from the database,
+// limits are not protobufs so they are stored JSON encoded
+func (s *Server) getNvmeSubsystemLimits(name string) (*NvmeSubsystemLimits, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(nvmeSubsystemLimitsKey(name), value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	limits := new(NvmeSubsystemLimits)
+	if err := json.Unmarshal(value.Value, limits); err != nil {
+		return nil, false, err
+	}
+	return limits, true, nil
+}
+
+func (s *Server) saveNvmeSubsystemLimits(name string, limits *NvmeSubsystemLimits) error {
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(nvmeSubsystemLimitsKey(name), wrapperspb.Bytes(data))
+}
+
+func (s *Server) deleteNvmeSubsystemLimits(name string) error {
+	return s.store.Delete(nvmeSubsystemLimitsKey(name))
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_UpdateNvmeSubsystemLimits(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testNewSubsystemName := utils.ResourceIDToSubsystemName("new-subsystem-id")
+	tests := map[string]struct {
+		in      *UpdateNvmeSubsystemLimitsRequest
+		out     *NvmeSubsystemLimits
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request for a subsystem not created yet": {
+			in:      &UpdateNvmeSubsystemLimitsRequest{Name: testNewSubsystemName, Limits: &NvmeSubsystemLimits{MaxControllers: 4, MinSqes: 6, MaxSqes: 10, IeeeOui: "005043", Status: &NvmeSubsystemLimitsStatus{Controllers: 1}}},
+			out:     &NvmeSubsystemLimits{MaxControllers: 4, MinSqes: 6, MaxSqes: 10, IeeeOui: "005043"},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"existing subsystem": {
+			in:      &UpdateNvmeSubsystemLimitsRequest{Name: testSubsystemName, Limits: &NvmeSubsystemLimits{MaxControllers: 4}},
+			out:     nil,
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Limits of subsystem %v can only be set before it is created", testSubsystemName),
+		},
+		"max controllers out of range": {
+			in:      &UpdateNvmeSubsystemLimitsRequest{Name: testNewSubsystemName, Limits: &NvmeSubsystemLimits{MaxControllers: -1}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "MaxControllers value (-1) is out of range, have to be between 0 and 65519",
+		},
+		"SQES bounds out of range": {
+			in:      &UpdateNvmeSubsystemLimitsRequest{Name: testNewSubsystemName, Limits: &NvmeSubsystemLimits{MaxSqes: 17}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Sqes bounds (0, 17) are out of range, have to be between 0 and 16",
+		},
+		"CQES bounds inverted": {
+			in:      &UpdateNvmeSubsystemLimitsRequest{Name: testNewSubsystemName, Limits: &NvmeSubsystemLimits{MinCqes: 8, MaxCqes: 4}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "MinCqes value (8) is greater than MaxCqes value (4)",
+		},
+		"malformed IEEE OUI": {
+			in:      &UpdateNvmeSubsystemLimitsRequest{Name: testNewSubsystemName, Limits: &NvmeSubsystemLimits{IeeeOui: "00:50:43"}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "IeeeOui value (00:50:43) have to be 6 hex digits",
+		},
+		"no required limits field": {
+			in:      &UpdateNvmeSubsystemLimitsRequest{Name: testNewSubsystemName},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: limits",
+		},
+		"no required name field": {
+			in:      &UpdateNvmeSubsystemLimitsRequest{Limits: &NvmeSubsystemLimits{}},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+
+			response, err := testEnv.opiSpdkServer.UpdateNvmeSubsystemLimits(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			// limits are only saved when valid
+			if tt.errCode == codes.OK {
+				limits, found, _ := testEnv.opiSpdkServer.getNvmeSubsystemLimits(tt.in.Name)
+				if !found || !reflect.DeepEqual(limits, tt.out) {
+					t.Error("saved limits: expected", tt.out, "received", limits)
+				}
+			}
+		})
+	}
+}
+
+func TestFrontEnd_GetNvmeSubsystemLimits(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testNewSubsystemName := utils.ResourceIDToSubsystemName("new-subsystem-id")
+	tests := map[string]struct {
+		in      *GetNvmeSubsystemLimitsRequest
+		out     *NvmeSubsystemLimits
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &GetNvmeSubsystemLimitsRequest{Name: testSubsystemName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get NQN: %v", testSubsystem.Spec.Nqn),
+		},
+		"valid request with empty SPDK response": {
+			in:      &GetNvmeSubsystemLimitsRequest{Name: testSubsystemName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_nvm_subsys_get_info: %v", "EOF"),
+		},
+		"valid request with valid SPDK response": {
+			in: &GetNvmeSubsystemLimitsRequest{Name: testSubsystemName},
+			out: &NvmeSubsystemLimits{
+				MaxControllers: 4,
+				Status:         &NvmeSubsystemLimitsStatus{MaxControllers: 4, Controllers: 2, MinSqes: 6, MaxSqes: 10, MinCqes: 4, MaxCqes: 10, IeeeOui: "005043"},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "subsys_list": [{"subnqn": "nqn.2022-09.io.spdk:opi3", "min_ctrlr_id": 0, "max_ctrlr_id": 4, "min_sqes": 6, "max_sqes": 10, "min_cqes": 4, "max_cqes": 10, "ieee_oui": "005043", "num_total_ctrlr": 2}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request for a subsystem not created yet": {
+			in:      &GetNvmeSubsystemLimitsRequest{Name: testNewSubsystemName},
+			out:     &NvmeSubsystemLimits{MaxControllers: 2},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &GetNvmeSubsystemLimitsRequest{Name: utils.ResourceIDToSubsystemName("unknown-subsystem-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToSubsystemName("unknown-subsystem-id")),
+		},
+		"no required field": {
+			in:      &GetNvmeSubsystemLimitsRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.saveNvmeSubsystemLimits(testSubsystemName, &NvmeSubsystemLimits{MaxControllers: 4})
+			_ = testEnv.opiSpdkServer.saveNvmeSubsystemLimits(testNewSubsystemName, &NvmeSubsystemLimits{MaxControllers: 2})
+
+			response, err := testEnv.opiSpdkServer.GetNvmeSubsystemLimits(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeControllerWithinSubsystemLimits(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		limits  *NvmeSubsystemLimits
+		sqes    int32
+		cqes    int32
+		errCode codes.Code
+		errMsg  string
+	}{
+		"subsystem with all its controllers": {
+			limits:  &NvmeSubsystemLimits{MaxControllers: 1},
+			errCode: codes.ResourceExhausted,
+			errMsg:  fmt.Sprintf("Subsystem %v already has its %d controllers", testSubsystemName, 1),
+		},
+		"SQES below the bounds": {
+			limits:  &NvmeSubsystemLimits{MinSqes: 6, MaxSqes: 10},
+			sqes:    5,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Sqes value (5) is out of the bounds of the subsystem, have to be between 6 and 10",
+		},
+		"CQES above the bounds": {
+			limits:  &NvmeSubsystemLimits{MinCqes: 4, MaxCqes: 10},
+			cqes:    11,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Cqes value (11) is out of the bounds of the subsystem, have to be between 4 and 10",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			_ = testEnv.opiSpdkServer.saveNvmeSubsystemLimits(testSubsystemName, tt.limits)
+
+			spec := utils.ProtoClone(testController.Spec)
+			spec.NvmeControllerId = nil
+			spec.Sqes = tt.sqes
+			spec.Cqes = tt.cqes
+			request := &pb.CreateNvmeControllerRequest{Parent: testSubsystemName, NvmeControllerId: "new-controller-id",
+				NvmeController: &pb.NvmeController{Spec: spec}}
+			_, err := testEnv.opiSpdkServer.CreateNvmeController(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
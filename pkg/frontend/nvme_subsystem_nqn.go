@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxNqnLength is the maximum length in bytes of an NQN in the NVMe base specification
+const maxNqnLength = 223
+
+// uuidNqnPrefix is the prefix of the NQNs made of a UUID, for the subsystems which have no
+// naming authority
+const uuidNqnPrefix = "nqn.2014-08.org.nvmexpress:uuid:"
+
+var (
+	// nqnDate is the year and month the naming authority owned its domain
+	nqnDate = regexp.MustCompile(`^[0-9]{4}-(0[1-9]|1[0-2])$`)
+	// nqnDomainLabel is a label of the reverse domain name of the naming authority
+	nqnDomainLabel = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+	// nqnUserString is the string the naming authority chose to make the NQN unique
+	nqnUserString = regexp.MustCompile(`^[a-zA-Z0-9-.:]+$`)
+)
+
+// generateNqn returns an NQN made of a random UUID, for the subsystems created without one
+func generateNqn() string {
+	return uuidNqnPrefix + uuid.New().String()
+}
+
+// validateNqn checks an NQN has the nqn.yyyy-mm.reverse.domain:string format of the NVMe base
+// specification, and a valid UUID when made of one, telling which part of it is wrong
+func validateNqn(nqn string) error {
+	invalid := func(format string, args ...interface{}) error {
+		msg := fmt.Sprintf("NQN value (%s) ", nqn) + fmt.Sprintf(format, args...)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if !strings.HasPrefix(nqn, "nqn.") {
+		return invalid("has to start with nqn.")
+	}
+	authority, user, found := strings.Cut(strings.TrimPrefix(nqn, "nqn."), ":")
+	if !found || user == "" {
+		return invalid("has no string after the naming authority, separated by a colon")
+	}
+	date, domain, _ := strings.Cut(authority, ".")
+	if !nqnDate.MatchString(date) {
+		return invalid("has an invalid date %s, have to be the yyyy-mm the naming authority owned its domain", date)
+	}
+	if domain == "" {
+		return invalid("has no reverse domain name of the naming authority")
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if !nqnDomainLabel.MatchString(label) {
+			return invalid("has an invalid label %q in the reverse domain name %s", label, domain)
+		}
+	}
+	if !nqnUserString.MatchString(user) {
+		return invalid("has invalid characters in %s, only letters, digits, hyphens, dots and colons are allowed", user)
+	}
+	if strings.HasPrefix(nqn, uuidNqnPrefix) {
+		if _, err := uuid.Parse(strings.TrimPrefix(nqn, uuidNqnPrefix)); err != nil {
+			return invalid("has an invalid UUID: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func TestFrontEnd_ValidateNqn(t *testing.T) {
+	tests := map[string]struct {
+		in     string
+		errMsg string
+	}{
+		"valid NQN": {
+			in:     "nqn.2022-09.io.spdk:opi3",
+			errMsg: "",
+		},
+		"valid NQN with hyphenated domain": {
+			in:     "nqn.2016-06.com.opi-project.spdk:target0:ns1",
+			errMsg: "",
+		},
+		"valid UUID NQN": {
+			in:     "nqn.2014-08.org.nvmexpress:uuid:1b4e28ba-2fa1-11d2-883f-b9a761bde3fb",
+			errMsg: "",
+		},
+		"no nqn prefix": {
+			in:     "iqn.2022-09.io.spdk:opi3",
+			errMsg: "NQN value (iqn.2022-09.io.spdk:opi3) has to start with nqn.",
+		},
+		"no user string": {
+			in:     "nqn.2022-09.io.spdk",
+			errMsg: "NQN value (nqn.2022-09.io.spdk) has no string after the naming authority, separated by a colon",
+		},
+		"invalid month": {
+			in:     "nqn.2022-13.io.spdk:opi3",
+			errMsg: "NQN value (nqn.2022-13.io.spdk:opi3) has an invalid date 2022-13, have to be the yyyy-mm the naming authority owned its domain",
+		},
+		"no domain": {
+			in:     "nqn.2022-09:opi3",
+			errMsg: "NQN value (nqn.2022-09:opi3) has no reverse domain name of the naming authority",
+		},
+		"invalid domain label": {
+			in:     "nqn.2022-09.io..spdk:opi3",
+			errMsg: `NQN value (nqn.2022-09.io..spdk:opi3) has an invalid label "" in the reverse domain name io..spdk`,
+		},
+		"invalid user string": {
+			in:     "nqn.2022-09.io.spdk:opi 3",
+			errMsg: "NQN value (nqn.2022-09.io.spdk:opi 3) has invalid characters in opi 3, only letters, digits, hyphens, dots and colons are allowed",
+		},
+		"invalid UUID": {
+			in:     "nqn.2014-08.org.nvmexpress:uuid:1b4e28ba",
+			errMsg: "NQN value (nqn.2014-08.org.nvmexpress:uuid:1b4e28ba) has an invalid UUID: invalid UUID length: 8",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateNqn(tt.in)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Error("expected no error, received", err)
+				}
+				return
+			}
+			er, ok := status.FromError(err)
+			if !ok || er.Code() != codes.InvalidArgument || er.Message() != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", err)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeSubsystemGeneratesNqn(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+		`{"id":%d,"error":{"code":0,"message":""},"result":{"version": "SPDK v20.10"}}`,
+	})
+	defer testEnv.Close()
+
+	request := &pb.CreateNvmeSubsystemRequest{NvmeSubsystemId: testSubsystemID,
+		NvmeSubsystem: &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{SerialNumber: "OpiSerialNumber", ModelNumber: "OpiModelNumber"}}}
+	response, err := testEnv.client.CreateNvmeSubsystem(testEnv.ctx, request)
+	if err != nil {
+		t.Fatal("expected no error, received", err)
+	}
+	if !strings.HasPrefix(response.Spec.Nqn, uuidNqnPrefix) {
+		t.Error("expected a UUID NQN, received", response.Spec.Nqn)
+	}
+	if err := validateNqn(response.Spec.Nqn); err != nil {
+		t.Error("expected a valid NQN, received", err)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
 	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
 )
 
@@ -47,7 +48,7 @@ func TestFrontEnd_CreateNvmeSubsystem(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
 			exist:   false,
 		},
@@ -146,7 +147,7 @@ func TestFrontEnd_CreateNvmeSubsystem(t *testing.T) {
 			in:      nil,
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: nvme_subsystem",
 			exist:   false,
 		},
@@ -179,7 +180,7 @@ func TestFrontEnd_CreateNvmeSubsystem(t *testing.T) {
 			out:     nil,
 			spdk:    []string{},
 			errCode: codes.InvalidArgument,
-			errMsg:  fmt.Sprintf("ModelNumber value (%s) is too long, have to be between 1 and %d", strings.Repeat("c", 41), 40),
+			errMsg:  fmt.Sprintf("ModelNumber value (%s) is too long, have to be between 1 and %d (and 1 more violations)", strings.Repeat("c", 41), 40),
 			exist:   false,
 		},
 		"too long serial field": {
@@ -195,7 +196,7 @@ func TestFrontEnd_CreateNvmeSubsystem(t *testing.T) {
 			out:     nil,
 			spdk:    []string{},
 			errCode: codes.InvalidArgument,
-			errMsg:  fmt.Sprintf("SerialNumber value (%s) is too long, have to be between 1 and %d", strings.Repeat("b", 21), 20),
+			errMsg:  fmt.Sprintf("SerialNumber value (%s) is too long, have to be between 1 and %d (and 1 more violations)", strings.Repeat("b", 21), 20),
 			exist:   false,
 		},
 	}
@@ -239,6 +240,31 @@ func TestFrontEnd_CreateNvmeSubsystem(t *testing.T) {
 	}
 }
 
+func TestFrontEnd_CreateNvmeSubsystemStatusDetails(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	testEnv := createTestEnvironment([]string{`{"id":%d,"error":{"code":0,"message":""},"result": {"status": -17}}`})
+	defer testEnv.Close()
+
+	request := &pb.CreateNvmeSubsystemRequest{
+		NvmeSubsystem: &pb.NvmeSubsystem{
+			Spec: &pb.NvmeSubsystemSpec{
+				Nqn:          "nqn.2022-09.io.spdk:opi3",
+				SerialNumber: "OpiSerialNumber",
+				ModelNumber:  "OpiModelNumber",
+			},
+		},
+		NvmeSubsystemId: testSubsystemID,
+	}
+	_, err := testEnv.client.CreateNvmeSubsystem(testEnv.ctx, request)
+	if er, _ := status.FromError(err); er.Code() != codes.InvalidArgument {
+		t.Error("error code: expected", codes.InvalidArgument, "received", er.Code())
+	}
+	info := models.ErrorInfo(err)
+	if info == nil || info.Reason != "SUBSYS_ALREADY_EXISTS" || info.Metadata["method"] != "mrvl_nvm_create_subsystem" {
+		t.Error("error info: expected", "SUBSYS_ALREADY_EXISTS", "received", info)
+	}
+}
+
 func TestFrontEnd_DeleteNvmeSubsystem(t *testing.T) {
 	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
 	tests := map[string]struct {
@@ -309,7 +335,7 @@ func TestFrontEnd_DeleteNvmeSubsystem(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     &emptypb.Empty{},
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 			missing: false,
 		},
@@ -317,7 +343,7 @@ func TestFrontEnd_DeleteNvmeSubsystem(t *testing.T) {
 			in:      "",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: name",
 			missing: false,
 		},
@@ -407,7 +433,7 @@ func TestFrontEnd_UpdateNvmeSubsystem(t *testing.T) {
 			},
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 	}
@@ -556,7 +582,7 @@ func TestFrontEnd_ListNvmeSubsystem(t *testing.T) {
 			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
 			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
 			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
-			testEnv.opiSpdkServer.Pagination["existing-pagination-token"] = 1
+			testEnv.opiSpdkServer.Pagination.SetToken("existing-pagination-token", 1)
 
 			request := &pb.ListNvmeSubsystemsRequest{PageSize: tt.size, PageToken: tt.token}
 			response, err := testEnv.client.ListNvmeSubsystems(testEnv.ctx, request)
@@ -622,11 +648,8 @@ func TestFrontEnd_GetNvmeSubsystem(t *testing.T) {
 			errMsg:  fmt.Sprintf("mrvl_nvm_get_subsys_list: %v", "json response error: myopierr"),
 		},
 		"valid request with valid SPDK response": {
-			in: testSubsystemName,
-			out: &pb.NvmeSubsystem{
-				Spec:   &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi3"},
-				Status: &pb.NvmeSubsystemStatus{FirmwareRevision: "TBD"},
-			},
+			in:      testSubsystemName,
+			out:     &testSubsystemWithStatus,
 			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "subsys_list": [{"subnqn": "nqn.2022-09.io.spdk:opi1"},{"subnqn": "nqn.2022-09.io.spdk:opi2"},{"subnqn": "nqn.2022-09.io.spdk:opi3"}]}}`},
 			errCode: codes.OK,
 			errMsg:  "",
@@ -642,14 +665,14 @@ func TestFrontEnd_GetNvmeSubsystem(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 		"no required field": {
 			in:      "",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  "missing required field: name",
 		},
 	}
@@ -750,7 +773,7 @@ func TestFrontEnd_StatsNvmeSubsystem(t *testing.T) {
 			in:      "-ABC-DEF",
 			out:     nil,
 			spdk:    []string{},
-			errCode: codes.Unknown,
+			errCode: codes.InvalidArgument,
 			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
 		},
 	}
@@ -5,86 +5,112 @@
 package frontend
 
 import (
-	"fmt"
 	"regexp"
+	"strings"
 
-	"go.einride.tech/aip/fieldbehavior"
-	"go.einride.tech/aip/resourceid"
-	"go.einride.tech/aip/resourcename"
-
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
 )
 
-func (s *Server) validateCreateNvmeSubsystemRequest(in *pb.CreateNvmeSubsystemRequest) error {
+func (s *Server) validateCreateNvmeSubsystemRequest(in *pb.CreateNvmeSubsystemRequest) ([]string, error) {
+	v := validation.NewValidator(s.validationMode)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	if !v.RequiredFields(in) {
+		return nil, v.Err()
 	}
 	// see https://google.aip.dev/133#user-specified-ids
-	if in.NvmeSubsystemId != "" {
-		if err := resourceid.ValidateUserSettable(in.NvmeSubsystemId); err != nil {
-			return err
-		}
-	}
+	v.ResourceID("nvme_subsystem_id", in.NvmeSubsystemId)
+	spec := in.NvmeSubsystem.Spec
 	// check Nqn length
-	if len(in.NvmeSubsystem.Spec.Nqn) > 223 {
-		msg := fmt.Sprintf("Nqn value (%s) is too long, have to be between 1 and 223", in.NvmeSubsystem.Spec.Nqn)
-		return status.Errorf(codes.InvalidArgument, msg)
-	}
-	// check SerialNumber length
-	if len(in.NvmeSubsystem.Spec.SerialNumber) > 20 {
-		msg := fmt.Sprintf("SerialNumber value (%s) is too long, have to be between 1 and 20", in.NvmeSubsystem.Spec.SerialNumber)
-		return status.Errorf(codes.InvalidArgument, msg)
-	}
-	// check ModelNumber length
-	if len(in.NvmeSubsystem.Spec.ModelNumber) > 40 {
-		msg := fmt.Sprintf("ModelNumber value (%s) is too long, have to be between 1 and 40", in.NvmeSubsystem.Spec.ModelNumber)
-		return status.Errorf(codes.InvalidArgument, msg)
-	}
-	// check if the NQN matches the pattern
-	regex := regexp.MustCompile(`^nqn\.[0-9]{4}-[0-9]{2}(\.[a-zA-Z0-9]+)+(:[a-zA-Z0-9-.]+)+$`)
-	if !regex.MatchString(in.NvmeSubsystem.Spec.Nqn) {
-		msg := fmt.Sprintf("NQN value (%s) does not match pattern", in.NvmeSubsystem.Spec.Nqn)
-		return status.Errorf(codes.InvalidArgument, msg)
+	nqnLength := v.Check(len(spec.Nqn) <= maxNqnLength, "nvme_subsystem.spec.nqn",
+		"Nqn value (%s) is too long, have to be between 1 and %d", spec.Nqn, maxNqnLength)
+	// check SerialNumber and ModelNumber length, truncated in permissive mode
+	v.Truncate("nvme_subsystem.spec.serial_number", &spec.SerialNumber, 20)
+	v.Truncate("nvme_subsystem.spec.model_number", &spec.ModelNumber, 40)
+	// the subsystems are created for the hosts of the PCIe functions, not over fabrics
+	v.Unsupported("nvme_subsystem.spec.hostnqn", spec.Hostnqn != "", func() { spec.Hostnqn = "" })
+	v.Unsupported("nvme_subsystem.spec.psk", len(spec.Psk) != 0, func() { spec.Psk = nil })
+	// check the NQN has the format of the specification
+	if nqnLength {
+		if err := validateNqn(spec.Nqn); err != nil {
+			v.Violation("nvme_subsystem.spec.nqn", "%s", status.Convert(err).Message())
+		}
 	}
-	return nil
+	return v.Warnings(), v.Err()
 }
 
 func (s *Server) validateDeleteNvmeSubsystemRequest(in *pb.DeleteNvmeSubsystemRequest) error {
-	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	return validateNameRequest(in, in.Name)
 }
 
 func (s *Server) validateUpdateNvmeSubsystemRequest(in *pb.UpdateNvmeSubsystemRequest) error {
+	v := new(validation.Validator)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	if v.RequiredFields(in) {
+		// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+		v.ResourceName("nvme_subsystem.name", in.NvmeSubsystem.Name)
 	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.NvmeSubsystem.Name)
+	return v.Err()
 }
 
-func (s *Server) validateGetNvmeSubsystemRequest(in *pb.GetNvmeSubsystemRequest) error {
+func (s *Server) validateListNvmeSubsystemsRequest(in *pb.ListNvmeSubsystemsRequest) error {
+	v := new(validation.Validator)
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
-	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	v.RequiredFields(in)
+	return v.Err()
+}
+
+func (s *Server) validateGetNvmeSubsystemRequest(in *pb.GetNvmeSubsystemRequest) error {
+	return validateNameRequest(in, in.Name)
 }
 
 func (s *Server) validateStatsNvmeSubsystemRequest(in *pb.StatsNvmeSubsystemRequest) error {
+	return validateNameRequest(in, in.Name)
+}
+
+func (s *Server) validateResetNvmeSubsystemRequest(in *ResetNvmeSubsystemRequest) error {
 	// check required fields
-	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
-		return err
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetNvmeSubsystemLimitsRequest(in *GetNvmeSubsystemLimitsRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateUpdateNvmeSubsystemLimitsRequest(in *UpdateNvmeSubsystemLimitsRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	v.ResourceName("name", in.Name)
+	if !v.Required("limits", in.Limits != nil) {
+		return v.Err()
+	}
+	// check MaxControllers, the controller IDs are 16 bits wide and 0xFFF0 and above are reserved
+	v.Range("limits.max_controllers", int64(in.Limits.MaxControllers), 0, 0xFFEF)
+	// check the queue entries bounds, as powers of 2 the queues have up to 64K entries
+	bounds := []struct {
+		name     string
+		min, max int32
+	}{
+		{"Sqes", in.Limits.MinSqes, in.Limits.MaxSqes},
+		{"Cqes", in.Limits.MinCqes, in.Limits.MaxCqes},
 	}
-	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
-	return resourcename.Validate(in.Name)
+	for _, b := range bounds {
+		field := "limits.max_" + strings.ToLower(b.name)
+		if !v.Check(b.min >= 0 && b.min <= 16 && b.max >= 0 && b.max <= 16, field,
+			"%s bounds (%d, %d) are out of range, have to be between 0 and 16", b.name, b.min, b.max) {
+			continue
+		}
+		v.Check(b.min == 0 || b.max == 0 || b.min <= b.max, field,
+			"Min%s value (%d) is greater than Max%s value (%d)", b.name, b.min, b.name, b.max)
+	}
+	// check IeeeOui, it is 24 bits wide
+	v.Check(in.Limits.IeeeOui == "" || ieeeOuiPattern.MatchString(in.Limits.IeeeOui), "limits.ieee_oui",
+		"IeeeOui value (%s) have to be 6 hex digits", in.Limits.IeeeOui)
+	return v.Err()
 }
+
+// ieeeOuiPattern is the format of an IEEE OUI, 24 bits in hex
+var ieeeOuiPattern = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// PcieReservation pins a PCIe function to the subsystems of a tenant, the controllers of the
+// other subsystems can't be created on it, so the flows planning the functions of their
+// controllers don't take each other's before they apply
+type PcieReservation struct {
+	// Name of the reservation
+	Name string `json:"name"`
+	// Function reserved
+	Function PcieFunction `json:"function"`
+	// SubsystemPrefix is the prefix of the IDs of the subsystems the function is reserved for
+	SubsystemPrefix string `json:"subsystemPrefix"`
+	// Purpose of the reservation, for the operators
+	Purpose string `json:"purpose"`
+}
+
+// CreatePcieReservationRequest represents a request to reserve a PCIe function
+type CreatePcieReservationRequest struct {
+	// PcieReservationID is the ID of the reservation, generated when empty
+	PcieReservationID string `json:"pcieReservationId"`
+	// PcieReservation to create
+	PcieReservation *PcieReservation `json:"pcieReservation"`
+}
+
+// DeletePcieReservationRequest represents a request to release a PCIe function, the controllers
+// created on it are kept
+type DeletePcieReservationRequest struct {
+	// Name of the reservation
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the reservation doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetPcieReservationRequest represents a request to get a reservation
+type GetPcieReservationRequest struct {
+	// Name of the reservation
+	Name string `json:"name"`
+}
+
+// ListPcieReservationsRequest represents a request to list reservations
+type ListPcieReservationsRequest struct {
+	// PageSize is the maximum number of reservations returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListPcieReservationsResponse represents a list of reservations
+type ListPcieReservationsResponse struct {
+	// PcieReservations is the page of reservations
+	PcieReservations []*PcieReservation `json:"pcieReservations"`
+	// NextPageToken is set when more reservations are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToPcieReservationName builds the name of a reservation, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToPcieReservationName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"pcieReservations", resourceID,
+	)
+}
+
+// CreatePcieReservation reserves a PCIe function for the subsystems of a tenant, a function is
+// reserved once and can't be reserved while a controller of another tenant is on it
+func (s *Server) CreatePcieReservation(_ context.Context, in *CreatePcieReservationRequest) (*PcieReservation, error) {
+	// check input correctness
+	if err := s.validateCreatePcieReservationRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.PcieReservationID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.PcieReservationID, "name", in.PcieReservation.Name)
+		resourceID = in.PcieReservationID
+	}
+	name := resourceIDToPcieReservationName(resourceID)
+	// idempotent API when called with same key, should return same object
+	reservation, found, err := s.getPcieReservation(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing PcieReservation", "name", name)
+		return reservation, nil
+	}
+	// not found, so create a new one
+	reservations, err := s.pcieReservations()
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range reservations {
+		if other.Function == in.PcieReservation.Function {
+			msg := fmt.Sprintf("PCIe function %s is already reserved by %s", other.Function, other.Name)
+			return nil, status.Errorf(codes.AlreadyExists, msg)
+		}
+	}
+	for _, controllerName := range s.listNames("/nvmeControllers/") {
+		controller := new(pb.NvmeController)
+		found, err := s.store.Get(controllerName, controller)
+		if err != nil {
+			return nil, err
+		}
+		pcieID := controller.GetSpec().GetPcieId()
+		if !found || pcieID == nil || pcieFunctionOf(pcieID) != in.PcieReservation.Function {
+			continue
+		}
+		if !strings.HasPrefix(utils.GetSubsystemIDFromNvmeName(controllerName), in.PcieReservation.SubsystemPrefix) {
+			msg := fmt.Sprintf("PCIe function %s has the controller %s of another tenant", in.PcieReservation.Function, controllerName)
+			return nil, status.Errorf(codes.FailedPrecondition, msg)
+		}
+	}
+	reservation = &PcieReservation{
+		Name:            name,
+		Function:        in.PcieReservation.Function,
+		SubsystemPrefix: in.PcieReservation.SubsystemPrefix,
+		Purpose:         in.PcieReservation.Purpose,
+	}
+	err = s.savePcieReservation(reservation)
+	if err != nil {
+		return nil, err
+	}
+	s.ListHelper[name] = false
+	return reservation, nil
+}
+
+// DeletePcieReservation releases a PCIe function
+func (s *Server) DeletePcieReservation(_ context.Context, in *DeletePcieReservationRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeletePcieReservationRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	_, found, err := s.getPcieReservation(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	// remove from the Database
+	err = s.store.Delete(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.ListHelper, in.Name)
+	return &emptypb.Empty{}, nil
+}
+
+// ListPcieReservations lists reservations
+func (s *Server) ListPcieReservations(_ context.Context, in *ListPcieReservationsRequest) (*ListPcieReservationsResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.pcieReservationNames()
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*PcieReservation, 0, len(names))
+	for _, name := range names {
+		reservation, found, err := s.getPcieReservation(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, reservation)
+	}
+	return &ListPcieReservationsResponse{PcieReservations: Blobarray, NextPageToken: token}, nil
+}
+
+// GetPcieReservation gets a reservation
+func (s *Server) GetPcieReservation(_ context.Context, in *GetPcieReservationRequest) (*PcieReservation, error) {
+	// check input correctness
+	if err := s.validateGetPcieReservationRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	reservation, found, err := s.getPcieReservation(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// pcieReservationNames returns the sorted names of the reservations
+func (s *Server) pcieReservationNames() []string {
+	prefix := resourceIDToPcieReservationName("")
+	var names []string
+	for key := range s.ListHelper {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pcieReservations returns the reservations, sorted by name
+func (s *Server) pcieReservations() ([]*PcieReservation, error) {
+	var reservations []*PcieReservation
+	for _, name := range s.pcieReservationNames() {
+		reservation, found, err := s.getPcieReservation(name)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			reservations = append(reservations, reservation)
+		}
+	}
+	return reservations, nil
+}
+
+// checkPcieReservation checks a controller of a subsystem can be created on a PCIe function,
+// it isn't reserved for the subsystems of another tenant
+func (s *Server) checkPcieReservation(subsysName string, pcieID *pb.PciEndpoint) error {
+	if pcieID == nil {
+		return nil
+	}
+	reservations, err := s.pcieReservations()
+	if err != nil {
+		return err
+	}
+	function := pcieFunctionOf(pcieID)
+	subsysID := utils.GetSubsystemIDFromNvmeName(subsysName)
+	for _, reservation := range reservations {
+		if reservation.Function == function && !strings.HasPrefix(subsysID, reservation.SubsystemPrefix) {
+			msg := fmt.Sprintf("PCIe function %s is reserved by %s", function, reservation.Name)
+			return status.Errorf(codes.FailedPrecondition, msg)
+		}
+	}
+	return nil
+}
+
+// getPcieReservation fetches a reservation from the database,
+// reservations are not protobufs so they are stored JSON encoded
+func (s *Server) getPcieReservation(name string) (*PcieReservation, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	reservation := new(PcieReservation)
+	if err := json.Unmarshal(value.Value, reservation); err != nil {
+		return nil, false, err
+	}
+	return reservation, true, nil
+}
+
+func (s *Server) savePcieReservation(reservation *PcieReservation) error {
+	data, err := json.Marshal(reservation)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(reservation.Name, wrapperspb.Bytes(data))
+}
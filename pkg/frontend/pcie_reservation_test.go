@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+var (
+	testPcieReservationID   = "reservation-test"
+	testPcieReservationName = resourceIDToPcieReservationName(testPcieReservationID)
+	testPcieReservation     = PcieReservation{
+		Name:            testPcieReservationName,
+		Function:        PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 3},
+		SubsystemPrefix: "subsystem-",
+		Purpose:         "plan 42",
+	}
+)
+
+func TestFrontEnd_CreatePcieReservation(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *PcieReservation
+		out     *PcieReservation
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"valid request": {
+			id:      testPcieReservationID,
+			in:      &PcieReservation{Function: testPcieReservation.Function, SubsystemPrefix: "subsystem-", Purpose: "plan 42"},
+			out:     &testPcieReservation,
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testPcieReservationID,
+			in:      &PcieReservation{Function: PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 4}, SubsystemPrefix: "subsystem-"},
+			out:     &testPcieReservation,
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"function already reserved": {
+			id:      "reservation-other",
+			in:      &PcieReservation{Function: testPcieReservation.Function, SubsystemPrefix: "other-"},
+			out:     nil,
+			errCode: codes.AlreadyExists,
+			errMsg:  fmt.Sprintf("PCIe function 0:1:3 is already reserved by %v", testPcieReservationName),
+			exist:   true,
+		},
+		"controller of another tenant": {
+			id:      testPcieReservationID,
+			in:      &PcieReservation{Function: PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 2}, SubsystemPrefix: "other-"},
+			out:     nil,
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("PCIe function 0:1:2 has the controller %v of another tenant", testControllerName),
+			exist:   false,
+		},
+		"negative function": {
+			id:      testPcieReservationID,
+			in:      &PcieReservation{Function: PcieFunction{PortID: 0, PhysicalFunction: -1, VirtualFunction: 0}, SubsystemPrefix: "subsystem-"},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Function value (0:-1:0) can't be negative",
+			exist:   false,
+		},
+		"no required subsystem_prefix field": {
+			id:      testPcieReservationID,
+			in:      &PcieReservation{Function: testPcieReservation.Function},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: pcie_reservation.subsystem_prefix",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.savePcieReservation(&testPcieReservation)
+				testEnv.opiSpdkServer.ListHelper[testPcieReservationName] = false
+			}
+
+			request := &CreatePcieReservationRequest{PcieReservation: tt.in, PcieReservationID: tt.id}
+			response, err := testEnv.opiSpdkServer.CreatePcieReservation(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_DeletePcieReservation(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	_ = testEnv.opiSpdkServer.savePcieReservation(&testPcieReservation)
+	testEnv.opiSpdkServer.ListHelper[testPcieReservationName] = false
+
+	list, err := testEnv.opiSpdkServer.ListPcieReservations(testEnv.ctx, &ListPcieReservationsRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !reflect.DeepEqual(list.PcieReservations, []*PcieReservation{&testPcieReservation}) {
+		t.Error("reservations: expected", []*PcieReservation{&testPcieReservation}, "received", list.PcieReservations)
+	}
+	if _, err := testEnv.opiSpdkServer.DeletePcieReservation(testEnv.ctx, &DeletePcieReservationRequest{Name: testPcieReservationName}); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	_, err = testEnv.opiSpdkServer.GetPcieReservation(testEnv.ctx, &GetPcieReservationRequest{Name: testPcieReservationName})
+	if er, _ := status.FromError(err); er.Code() != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", er.Code())
+	}
+}
+
+func TestFrontEnd_CreateNvmeControllerPcieReservations(t *testing.T) {
+	tests := map[string]struct {
+		prefix   string
+		endpoint *pb.PciEndpoint
+		spdk     []string
+		function *PcieFunction
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"reserved for the subsystem": {
+			prefix:   "subsystem-",
+			endpoint: &pb.PciEndpoint{PortId: wrapperspb.Int32(0), PhysicalFunction: wrapperspb.Int32(1), VirtualFunction: wrapperspb.Int32(3)},
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			function: &PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 3},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"reserved for another tenant": {
+			prefix:   "other-",
+			endpoint: &pb.PciEndpoint{PortId: wrapperspb.Int32(0), PhysicalFunction: wrapperspb.Int32(1), VirtualFunction: wrapperspb.Int32(3)},
+			spdk:     []string{},
+			function: nil,
+			errCode:  codes.FailedPrecondition,
+			errMsg:   fmt.Sprintf("PCIe function 0:1:3 is reserved by %v", testPcieReservationName),
+		},
+		"reserved function allocated first": {
+			prefix:   "subsystem-",
+			endpoint: nil,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			function: &PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 3},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"function reserved for another tenant skipped": {
+			prefix:   "other-",
+			endpoint: nil,
+			spdk:     []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			function: &PcieFunction{PortID: 0, PhysicalFunction: 1, VirtualFunction: 1},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.SetPcieFunctionPool([]PcieFunction{
+				{PortID: 0, PhysicalFunction: 1, VirtualFunction: 3},
+				{PortID: 0, PhysicalFunction: 1, VirtualFunction: 1},
+			})
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+			reservation := testPcieReservation
+			reservation.SubsystemPrefix = tt.prefix
+			_ = testEnv.opiSpdkServer.savePcieReservation(&reservation)
+			testEnv.opiSpdkServer.ListHelper[testPcieReservationName] = false
+
+			spec := &pb.NvmeControllerSpec{
+				Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+				NvmeControllerId: proto.Int32(18),
+			}
+			if tt.endpoint != nil {
+				spec.Endpoint = &pb.NvmeControllerSpec_PcieId{PcieId: tt.endpoint}
+			}
+			request := &pb.CreateNvmeControllerRequest{
+				Parent:           testSubsystemName,
+				NvmeControllerId: "controller-reservation",
+				NvmeController:   &pb.NvmeController{Spec: spec},
+			}
+			response, err := testEnv.opiSpdkServer.CreateNvmeController(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if tt.function == nil {
+				return
+			}
+			if function := pcieFunctionOf(response.GetSpec().GetPcieId()); function != *tt.function {
+				t.Error("function: expected", *tt.function, "received", function)
+			}
+		})
+	}
+}
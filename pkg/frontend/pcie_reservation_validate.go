@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
+)
+
+func (s *Server) validateCreatePcieReservationRequest(in *CreatePcieReservationRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	if !v.Required("pcie_reservation", in.PcieReservation != nil) {
+		return v.Err()
+	}
+	v.Required("pcie_reservation.subsystem_prefix", in.PcieReservation.SubsystemPrefix != "")
+	// see https://google.aip.dev/133#user-specified-ids
+	v.ResourceID("pcie_reservation_id", in.PcieReservationID)
+	// check the function
+	function := in.PcieReservation.Function
+	v.Check(function.PortID >= 0 && function.PhysicalFunction >= 0 && function.VirtualFunction >= 0, "pcie_reservation.function",
+		"Function value (%s) can't be negative", function)
+	return v.Err()
+}
+
+func (s *Server) validateDeletePcieReservationRequest(in *DeletePcieReservationRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetPcieReservationRequest(in *GetPcieReservationRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
@@ -0,0 +1,349 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Quota represents the limits of the resources a tenant can create, the tenant owns the
+// subsystems whose ID starts with its prefix and what they contain. A limit of 0 is unlimited
+type Quota struct {
+	// Name of the quota
+	Name string `json:"name"`
+	// SubsystemPrefix is the prefix of the IDs of the subsystems of the tenant
+	SubsystemPrefix string `json:"subsystemPrefix"`
+	// MaxNamespaces is the maximum number of namespaces across the subsystems
+	MaxNamespaces int32 `json:"maxNamespaces"`
+	// MaxControllers is the maximum number of controllers across the subsystems
+	MaxControllers int32 `json:"maxControllers"`
+	// MaxCapacityBytes is the maximum size of the volumes the namespaces are backed by
+	MaxCapacityBytes int64 `json:"maxCapacityBytes"`
+}
+
+// CreateQuotaRequest represents a request to create a quota
+type CreateQuotaRequest struct {
+	// QuotaID is the ID of the quota, generated when empty
+	QuotaID string `json:"quotaId"`
+	// Quota to create
+	Quota *Quota `json:"quota"`
+}
+
+// DeleteQuotaRequest represents a request to delete a quota
+type DeleteQuotaRequest struct {
+	// Name of the quota
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the quota doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetQuotaRequest represents a request to get a quota
+type GetQuotaRequest struct {
+	// Name of the quota
+	Name string `json:"name"`
+}
+
+// ListQuotasRequest represents a request to list quotas
+type ListQuotasRequest struct {
+	// PageSize is the maximum number of quotas returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListQuotasResponse represents a list of quotas
+type ListQuotasResponse struct {
+	// Quotas is the page of quotas
+	Quotas []*Quota `json:"quotas"`
+	// NextPageToken is set when more quotas are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToQuotaName builds the name of a quota, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToQuotaName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"quotas", resourceID,
+	)
+}
+
+// CreateQuota creates a quota, it is enforced when namespaces and controllers are created
+// and doesn't affect the existing ones, even over the limits
+func (s *Server) CreateQuota(_ context.Context, in *CreateQuotaRequest) (*Quota, error) {
+	// check input correctness
+	if err := s.validateCreateQuotaRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.QuotaID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.QuotaID, "name", in.Quota.Name)
+		resourceID = in.QuotaID
+	}
+	name := resourceIDToQuotaName(resourceID)
+	// idempotent API when called with same key, should return same object
+	quota, found, err := s.getQuota(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing Quota", "name", name)
+		return quota, nil
+	}
+	// not found, so create a new one
+	quota = &Quota{
+		Name:             name,
+		SubsystemPrefix:  in.Quota.SubsystemPrefix,
+		MaxNamespaces:    in.Quota.MaxNamespaces,
+		MaxControllers:   in.Quota.MaxControllers,
+		MaxCapacityBytes: in.Quota.MaxCapacityBytes,
+	}
+	err = s.saveQuota(quota)
+	if err != nil {
+		return nil, err
+	}
+	s.ListHelper[name] = false
+	return quota, nil
+}
+
+// DeleteQuota deletes a quota
+func (s *Server) DeleteQuota(_ context.Context, in *DeleteQuotaRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteQuotaRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	_, found, err := s.getQuota(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	// remove from the Database
+	err = s.store.Delete(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.ListHelper, in.Name)
+	return &emptypb.Empty{}, nil
+}
+
+// ListQuotas lists quotas
+func (s *Server) ListQuotas(_ context.Context, in *ListQuotasRequest) (*ListQuotasResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	names := s.quotaNames()
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(names), "offset", offset, "size", size)
+	names, hasMoreElements = utils.LimitPagination(names, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*Quota, 0, len(names))
+	for _, name := range names {
+		quota, found, err := s.getQuota(name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			err := status.Errorf(codes.NotFound, "unable to find key %s", name)
+			return nil, err
+		}
+		Blobarray = append(Blobarray, quota)
+	}
+	return &ListQuotasResponse{Quotas: Blobarray, NextPageToken: token}, nil
+}
+
+// GetQuota gets a quota
+func (s *Server) GetQuota(_ context.Context, in *GetQuotaRequest) (*Quota, error) {
+	// check input correctness
+	if err := s.validateGetQuotaRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	quota, found, err := s.getQuota(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return quota, nil
+}
+
+// quotaNames returns the sorted names of the quotas
+func (s *Server) quotaNames() []string {
+	prefix := resourceIDToQuotaName("")
+	var names []string
+	for key := range s.ListHelper {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tenantQuotas returns the quotas applying to a subsystem
+func (s *Server) tenantQuotas(subsysName string) ([]*Quota, error) {
+	subsysID := utils.GetSubsystemIDFromNvmeName(subsysName)
+	var quotas []*Quota
+	for _, name := range s.quotaNames() {
+		quota, found, err := s.getQuota(name)
+		if err != nil {
+			return nil, err
+		}
+		if found && strings.HasPrefix(subsysID, quota.SubsystemPrefix) {
+			quotas = append(quotas, quota)
+		}
+	}
+	return quotas, nil
+}
+
+// tenantResources returns the names of the resources of a collection, i.e. nvmeNamespaces,
+// in the subsystems of a tenant
+func (s *Server) tenantResources(quota *Quota, collection string) []string {
+	var names []string
+	for key := range s.ListHelper {
+		if !strings.Contains(key, "/"+collection+"/") {
+			continue
+		}
+		if strings.HasPrefix(utils.GetSubsystemIDFromNvmeName(key), quota.SubsystemPrefix) {
+			names = append(names, key)
+		}
+	}
+	return names
+}
+
+// checkNvmeNamespaceQuotas checks the tenant owning a subsystem can create one more
+// namespace backed by the volume
+func (s *Server) checkNvmeNamespaceQuotas(ctx context.Context, subsysName string, volume string) error {
+	quotas, err := s.tenantQuotas(subsysName)
+	if err != nil {
+		return err
+	}
+	for _, quota := range quotas {
+		namespaces := s.tenantResources(quota, "nvmeNamespaces")
+		if quota.MaxNamespaces != 0 && len(namespaces) >= int(quota.MaxNamespaces) {
+			msg := fmt.Sprintf("Quota %s allows at most %d namespaces", quota.Name, quota.MaxNamespaces)
+			return status.Errorf(codes.ResourceExhausted, msg)
+		}
+		if quota.MaxCapacityBytes == 0 {
+			continue
+		}
+		// a volume shared by several namespaces is only counted once
+		volumes := map[string]bool{volume: true}
+		for _, name := range namespaces {
+			namespace := new(pb.NvmeNamespace)
+			found, err := s.store.Get(name, namespace)
+			if err != nil {
+				return err
+			}
+			if found {
+				volumes[namespace.Spec.VolumeNameRef] = true
+			}
+		}
+		refs := make([]string, 0, len(volumes))
+		for v := range volumes {
+			refs = append(refs, v)
+		}
+		sort.Strings(refs)
+		var capacity uint64
+		for _, v := range refs {
+			size, err := s.volumeSize(ctx, v)
+			if err != nil {
+				return err
+			}
+			capacity += size
+		}
+		if capacity > uint64(quota.MaxCapacityBytes) {
+			msg := fmt.Sprintf("Quota %s allows at most %d bytes, %d are requested", quota.Name, quota.MaxCapacityBytes, capacity)
+			return status.Errorf(codes.ResourceExhausted, msg)
+		}
+	}
+	return nil
+}
+
+// checkNvmeControllerQuotas checks the tenant owning a subsystem can create one more controller
+func (s *Server) checkNvmeControllerQuotas(subsysName string) error {
+	quotas, err := s.tenantQuotas(subsysName)
+	if err != nil {
+		return err
+	}
+	for _, quota := range quotas {
+		controllers := s.tenantResources(quota, "nvmeControllers")
+		if quota.MaxControllers != 0 && len(controllers) >= int(quota.MaxControllers) {
+			msg := fmt.Sprintf("Quota %s allows at most %d controllers", quota.Name, quota.MaxControllers)
+			return status.Errorf(codes.ResourceExhausted, msg)
+		}
+	}
+	return nil
+}
+
+// volumeSize gets the size advertised by a volume
+func (s *Server) volumeSize(ctx context.Context, volume string) (uint64, error) {
+	params := models.MrvlBdevGetAllocationParams{
+		Name: volume,
+	}
+	var result models.MrvlBdevGetAllocationResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_get_allocation", &params, &result)
+	if err != nil {
+		return 0, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get allocation of %s", volume)
+		return 0, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_get_allocation")
+	}
+	return result.SizeBytes, nil
+}
+
+// getQuota fetches a quota from the database,
+// quotas are not protobufs so they are stored JSON encoded
+func (s *Server) getQuota(name string) (*Quota, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	quota := new(Quota)
+	if err := json.Unmarshal(value.Value, quota); err != nil {
+		return nil, false, err
+	}
+	return quota, true, nil
+}
+
+func (s *Server) saveQuota(quota *Quota) error {
+	data, err := json.Marshal(quota)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(quota.Name, wrapperspb.Bytes(data))
+}
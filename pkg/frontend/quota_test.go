@@ -0,0 +1,454 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+var (
+	testQuotaID   = "quota-test"
+	testQuotaName = resourceIDToQuotaName(testQuotaID)
+	testQuota     = Quota{
+		Name:             testQuotaName,
+		SubsystemPrefix:  "subsystem-",
+		MaxNamespaces:    2,
+		MaxControllers:   2,
+		MaxCapacityBytes: 4294967296,
+	}
+	testAllocation1GiB = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "size_bytes": 1073741824}}`
+)
+
+func TestFrontEnd_CreateQuota(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *Quota
+		out     *Quota
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &Quota{SubsystemPrefix: "subsystem-", MaxNamespaces: 2},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"valid request": {
+			id:      testQuotaID,
+			in:      &Quota{SubsystemPrefix: "subsystem-", MaxNamespaces: 2, MaxControllers: 2, MaxCapacityBytes: 4294967296},
+			out:     &testQuota,
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testQuotaID,
+			in:      &Quota{SubsystemPrefix: "subsystem-", MaxNamespaces: 8},
+			out:     &testQuota,
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"negative limit": {
+			id:      testQuotaID,
+			in:      &Quota{SubsystemPrefix: "subsystem-", MaxControllers: -1},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "MaxControllers value (-1) can't be negative",
+			exist:   false,
+		},
+		"no required subsystem_prefix field": {
+			id:      testQuotaID,
+			in:      &Quota{MaxNamespaces: 2},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: quota.subsystem_prefix",
+			exist:   false,
+		},
+		"no required field": {
+			id:      testQuotaID,
+			in:      nil,
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: quota",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveQuota(&testQuota)
+			}
+
+			request := &CreateQuotaRequest{Quota: tt.in, QuotaID: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateQuota(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_DeleteQuota(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request": {
+			in:      testQuotaName,
+			out:     &emptypb.Empty{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToQuotaName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToQuotaName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToQuotaName("unknown-id"),
+			out:     &emptypb.Empty{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveQuota(&testQuota)
+			testEnv.opiSpdkServer.ListHelper[testQuotaName] = false
+
+			request := &DeleteQuotaRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteQuota(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_ListQuotas(t *testing.T) {
+	otherQuota := Quota{Name: resourceIDToQuotaName("quota-other"), SubsystemPrefix: "tenant-", MaxNamespaces: 4}
+	tests := map[string]struct {
+		out     []*Quota
+		errCode codes.Code
+		errMsg  string
+		size    int32
+		token   string
+	}{
+		"pagination negative": {
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+		},
+		"pagination error": {
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+		},
+		"pagination": {
+			out:     []*Quota{&otherQuota},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+		},
+		"valid request": {
+			out:     []*Quota{&otherQuota, &testQuota},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			for _, quota := range []*Quota{&testQuota, &otherQuota} {
+				_ = testEnv.opiSpdkServer.saveQuota(quota)
+				testEnv.opiSpdkServer.ListHelper[quota.Name] = false
+			}
+			testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+
+			request := &ListQuotasRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListQuotas(testEnv.ctx, request)
+
+			var quotas []*Quota
+			if response != nil {
+				quotas = response.Quotas
+				// Empty NextPageToken indicates end of results list
+				if tt.size != 1 && response.NextPageToken != "" {
+					t.Error("Expected end of results, receieved non-empty next page token", response.NextPageToken)
+				}
+			}
+			if !reflect.DeepEqual(quotas, tt.out) {
+				t.Error("response: expected", tt.out, "received", quotas)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_GetQuota(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *Quota
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testQuotaName,
+			out:     &testQuota,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToQuotaName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToQuotaName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveQuota(&testQuota)
+
+			request := &GetQuotaRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetQuota(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeNamespaceQuotas(t *testing.T) {
+	tests := map[string]struct {
+		quota   *Quota
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"under the quota": {
+			quota:   &testQuota,
+			spdk:    []string{testAllocation1GiB, testAllocation1GiB, `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"quota of another tenant": {
+			quota:   &Quota{Name: testQuotaName, SubsystemPrefix: "tenant-", MaxNamespaces: 1},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"namespaces exhausted": {
+			quota:   &Quota{Name: testQuotaName, SubsystemPrefix: "subsystem-", MaxNamespaces: 1},
+			spdk:    []string{},
+			errCode: codes.ResourceExhausted,
+			errMsg:  fmt.Sprintf("Quota %v allows at most %d namespaces", testQuotaName, 1),
+		},
+		"capacity exhausted": {
+			quota:   &Quota{Name: testQuotaName, SubsystemPrefix: "subsystem-", MaxCapacityBytes: 1073741824},
+			spdk:    []string{testAllocation1GiB, testAllocation1GiB},
+			errCode: codes.ResourceExhausted,
+			errMsg:  fmt.Sprintf("Quota %v allows at most %d bytes, %d are requested", testQuotaName, 1073741824, 2147483648),
+		},
+		"allocation with invalid SPDK response": {
+			quota:   &Quota{Name: testQuotaName, SubsystemPrefix: "subsystem-", MaxCapacityBytes: 1073741824},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get allocation of %v", "Malloc0"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testNamespaceName, &testNamespaceWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testNamespaceName] = false
+			_ = testEnv.opiSpdkServer.saveQuota(tt.quota)
+			testEnv.opiSpdkServer.ListHelper[tt.quota.Name] = false
+
+			request := &pb.CreateNvmeNamespaceRequest{
+				Parent:          testSubsystemName,
+				NvmeNamespaceId: "namespace-quota",
+				NvmeNamespace: &pb.NvmeNamespace{
+					Spec: &pb.NvmeNamespaceSpec{HostNsid: 23, VolumeNameRef: "Malloc1"},
+				},
+			}
+			_, err := testEnv.opiSpdkServer.CreateNvmeNamespace(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CreateNvmeControllerQuotas(t *testing.T) {
+	tests := map[string]struct {
+		quota   *Quota
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"under the quota": {
+			quota:   &testQuota,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"controllers exhausted": {
+			quota:   &Quota{Name: testQuotaName, SubsystemPrefix: "subsystem-", MaxControllers: 1},
+			spdk:    []string{},
+			errCode: codes.ResourceExhausted,
+			errMsg:  fmt.Sprintf("Quota %v allows at most %d controllers", testQuotaName, 1),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			_ = testEnv.opiSpdkServer.saveQuota(tt.quota)
+			testEnv.opiSpdkServer.ListHelper[tt.quota.Name] = false
+
+			request := &pb.CreateNvmeControllerRequest{
+				Parent:           testSubsystemName,
+				NvmeControllerId: "controller-quota",
+				NvmeController: &pb.NvmeController{
+					Spec: &pb.NvmeControllerSpec{
+						Endpoint:         testController.Spec.Endpoint,
+						Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+						NvmeControllerId: proto.Int32(18),
+					},
+				},
+			}
+			_, err := testEnv.opiSpdkServer.CreateNvmeController(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
+)
+
+func (s *Server) validateCreateQuotaRequest(in *CreateQuotaRequest) error {
+	v := new(validation.Validator)
+	// check required fields
+	if !v.Required("quota", in.Quota != nil) {
+		return v.Err()
+	}
+	v.Required("quota.subsystem_prefix", in.Quota.SubsystemPrefix != "")
+	// see https://google.aip.dev/133#user-specified-ids
+	v.ResourceID("quota_id", in.QuotaID)
+	// check the limits
+	v.Check(in.Quota.MaxNamespaces >= 0, "quota.max_namespaces",
+		"MaxNamespaces value (%d) can't be negative", in.Quota.MaxNamespaces)
+	v.Check(in.Quota.MaxControllers >= 0, "quota.max_controllers",
+		"MaxControllers value (%d) can't be negative", in.Quota.MaxControllers)
+	v.Check(in.Quota.MaxCapacityBytes >= 0, "quota.max_capacity_bytes",
+		"MaxCapacityBytes value (%d) can't be negative", in.Quota.MaxCapacityBytes)
+	return v.Err()
+}
+
+func (s *Server) validateDeleteQuotaRequest(in *DeleteQuotaRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetQuotaRequest(in *GetQuotaRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
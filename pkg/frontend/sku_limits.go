@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/sku"
+)
+
+// SkuLimiter tells the limits of the SKU of the DPU on the resources
+type SkuLimiter interface {
+	// Limits returns the maximum numbers of resources, 0 when unlimited
+	Limits(ctx context.Context) sku.Limits
+}
+
+// SetSkuLimiter makes the creations of subsystems, controllers and namespaces respect the limits
+// of the SKU of the DPU
+func (s *Server) SetSkuLimiter(limiter SkuLimiter) {
+	s.skuLimiter = limiter
+}
+
+// countResources returns the number of resources of a collection, i.e. nvmeControllers
+func (s *Server) countResources(collection string) int32 {
+	var count int32
+	for key := range s.ListHelper {
+		segments := strings.Split(strings.TrimPrefix(key, "//storage.opiproject.org/"), "/")
+		switch {
+		case collection == "nvmeSubsystems" && len(segments) == 2 && segments[0] == collection:
+			count++
+		case len(segments) == 4 && segments[0] == "nvmeSubsystems" && segments[2] == collection:
+			count++
+		}
+	}
+	return count
+}
+
+// checkSkuLimit checks one more resource of a collection can be created on the SKU of the DPU
+func (s *Server) checkSkuLimit(ctx context.Context, collection string) error {
+	if s.skuLimiter == nil {
+		return nil
+	}
+	limits := s.skuLimiter.Limits(ctx)
+	limit := map[string]int32{
+		"nvmeSubsystems":  limits.MaxSubsystems,
+		"nvmeControllers": limits.MaxControllers,
+		"nvmeNamespaces":  limits.MaxNamespaces,
+	}[collection]
+	if limit != 0 && s.countResources(collection) >= limit {
+		msg := fmt.Sprintf("The SKU of the DPU allows at most %d %s", limit, collection)
+		return status.Errorf(codes.ResourceExhausted, msg)
+	}
+	return nil
+}
+
+// checkSkuFunctionLimit checks one more controller can be created on a PCIe function, physical
+// or virtual, on the SKU of the DPU, so the over-allocations are refused with the limit and the
+// controllers of the function instead of the status of the firmware
+func (s *Server) checkSkuFunctionLimit(ctx context.Context, pcieID *pb.PciEndpoint) error {
+	if s.skuLimiter == nil || pcieID == nil {
+		return nil
+	}
+	limits := s.skuLimiter.Limits(ctx)
+	limit, function := limits.MaxControllersPerPf, "physical"
+	if pcieID.GetVirtualFunction().GetValue() != 0 {
+		limit, function = limits.MaxControllersPerVf, "virtual"
+	}
+	if limit == 0 {
+		return nil
+	}
+	var count int32
+	for _, name := range s.listNames("/nvmeControllers/") {
+		controller := new(pb.NvmeController)
+		found, err := s.store.Get(name, controller)
+		if err != nil {
+			return err
+		}
+		if found && sameFunction(controller.GetSpec().GetPcieId(), pcieID) {
+			count++
+		}
+	}
+	if count >= limit {
+		msg := fmt.Sprintf("The SKU of the DPU allows at most %d controllers per %s function, port %d physical function %d virtual function %d has %d",
+			limit, function, pcieID.GetPortId().GetValue(), pcieID.GetPhysicalFunction().GetValue(), pcieID.GetVirtualFunction().GetValue(), count)
+		return status.Errorf(codes.ResourceExhausted, msg)
+	}
+	return nil
+}
+
+// sameFunction tells whether two PCIe endpoints are the same function
+func sameFunction(a *pb.PciEndpoint, b *pb.PciEndpoint) bool {
+	return a != nil && b != nil &&
+		a.GetPortId().GetValue() == b.GetPortId().GetValue() &&
+		a.GetPhysicalFunction().GetValue() == b.GetPhysicalFunction().GetValue() &&
+		a.GetVirtualFunction().GetValue() == b.GetVirtualFunction().GetValue()
+}
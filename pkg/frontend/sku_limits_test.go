@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/sku"
+)
+
+// testSkuLimiter has fixed limits
+type testSkuLimiter struct {
+	limits sku.Limits
+}
+
+func (l *testSkuLimiter) Limits(_ context.Context) sku.Limits {
+	return l.limits
+}
+
+func TestFrontEnd_CreateNvmeControllerSkuLimits(t *testing.T) {
+	tests := map[string]struct {
+		limiter SkuLimiter
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"no limiter": {
+			limiter: nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unlimited": {
+			limiter: &testSkuLimiter{limits: sku.Limits{MaxSubsystems: 1}},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"under the limit": {
+			limiter: &testSkuLimiter{limits: sku.Limits{MaxControllers: 2}},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"controllers exhausted": {
+			limiter: &testSkuLimiter{limits: sku.Limits{MaxControllers: 1}},
+			spdk:    []string{},
+			errCode: codes.ResourceExhausted,
+			errMsg:  "The SKU of the DPU allows at most 1 nvmeControllers",
+		},
+		"physical functions limited": {
+			limiter: &testSkuLimiter{limits: sku.Limits{MaxControllersPerPf: 1}},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"virtual function under the limit": {
+			limiter: &testSkuLimiter{limits: sku.Limits{MaxControllersPerVf: 2}},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"virtual function exhausted": {
+			limiter: &testSkuLimiter{limits: sku.Limits{MaxControllersPerVf: 1}},
+			spdk:    []string{},
+			errCode: codes.ResourceExhausted,
+			errMsg:  "The SKU of the DPU allows at most 1 controllers per virtual function, port 0 physical function 1 virtual function 2 has 1",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.SetSkuLimiter(tt.limiter)
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+			_ = testEnv.opiSpdkServer.store.Set(testControllerName, &testControllerWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+
+			request := &pb.CreateNvmeControllerRequest{
+				Parent:           testSubsystemName,
+				NvmeControllerId: "controller-sku",
+				NvmeController: &pb.NvmeController{
+					Spec: &pb.NvmeControllerSpec{
+						Endpoint:         testController.Spec.Endpoint,
+						Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+						NvmeControllerId: proto.Int32(18),
+					},
+				},
+			}
+			_, err := testEnv.opiSpdkServer.CreateNvmeController(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestFrontEnd_CountResources(t *testing.T) {
+	testEnv := createTestEnvironment([]string{})
+	defer testEnv.Close()
+
+	for _, name := range []string{
+		testSubsystemName,
+		"//storage.opiproject.org/nvmeSubsystems/subsystem-other",
+		testControllerName,
+		testNamespaceName,
+		testQuotaName,
+	} {
+		testEnv.opiSpdkServer.ListHelper[name] = false
+	}
+	for collection, expected := range map[string]int32{"nvmeSubsystems": 2, "nvmeControllers": 1, "nvmeNamespaces": 1} {
+		if count := testEnv.opiSpdkServer.countResources(collection); count != expected {
+			t.Error(collection, "expected", expected, "received", count)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ValidationWarnings represents the values of the spec of an Nvme subsystem or controller
+// the firmware doesn't support, ignored or clamped when it was created in permissive mode
+type ValidationWarnings struct {
+	// Warnings tell which fields were changed, and how, output only
+	Warnings []string `json:"warnings"`
+}
+
+// GetValidationWarningsRequest represents a request to get the validation warnings of an Nvme
+// subsystem or controller
+type GetValidationWarningsRequest struct {
+	// Name of the Nvme subsystem or controller
+	Name string `json:"name"`
+}
+
+// SetValidationMode sets how the values of the specs the firmware doesn't support are handled,
+// refused in strict mode, the default, ignored or clamped with warnings in permissive mode
+func (s *Server) SetValidationMode(mode validation.Mode) {
+	s.validationMode = mode
+}
+
+// GetValidationWarnings gets the warnings recorded when an Nvme subsystem or controller was
+// created or updated in permissive mode, none when its spec was applied as is
+func (s *Server) GetValidationWarnings(_ context.Context, in *GetValidationWarningsRequest) (*ValidationWarnings, error) {
+	// check input correctness
+	if err := s.validateGetValidationWarningsRequest(in); err != nil {
+		return nil, err
+	}
+	// only the subsystems and the controllers are validated in permissive mode
+	if _, ok := s.ListHelper[in.Name]; !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	// fetch object from the database
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(validationWarningsKey(in.Name), value)
+	if err != nil {
+		return nil, err
+	}
+	warnings := &ValidationWarnings{Warnings: []string{}}
+	if found {
+		if err := json.Unmarshal(value.Value, warnings); err != nil {
+			return nil, err
+		}
+	}
+	return warnings, nil
+}
+
+func (s *Server) validateGetValidationWarningsRequest(in *GetValidationWarningsRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+// validationWarningsKey is the database key of the validation warnings of a resource
+func validationWarningsKey(name string) string {
+	return name + "/warnings"
+}
+
+// saveValidationWarnings records the warnings of the last creation or update of a resource,
+// they are not protobufs so they are stored JSON encoded. Without warnings the previous ones
+// are removed, i.e. when the resource is deleted
+func (s *Server) saveValidationWarnings(name string, warnings []string) error {
+	if len(warnings) == 0 {
+		return s.store.Delete(validationWarningsKey(name))
+	}
+	slog.Warn("Applied in permissive mode", "name", name, "warnings", warnings)
+	data, err := json.Marshal(&ValidationWarnings{Warnings: warnings})
+	if err != nil {
+		return err
+	}
+	return s.store.Set(validationWarningsKey(name), wrapperspb.Bytes(data))
+}
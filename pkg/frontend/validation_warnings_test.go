@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package frontend implememnts the FrontEnd APIs (host facing) of the storage Server
+package frontend
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/validation"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestFrontEnd_CreateNvmeControllerValidationMode(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		mode     validation.Mode
+		spdk     []string
+		errCode  codes.Code
+		errMsg   string
+		warnings []string
+	}{
+		"strict mode refuses": {
+			mode:    validation.Strict,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Sqes value (20) is out of range, have to be between 0 and 16 (and 1 more violations)",
+		},
+		"permissive mode clamps and ignores": {
+			mode:    validation.Permissive,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ctrlr_id": 18}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			warnings: []string{
+				"nvme_controller.spec.sqes: value (20) is out of range, clamped to 16",
+				"nvme_controller.spec.max_limit: not supported by the firmware, ignored",
+			},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.SetValidationMode(tt.mode)
+			_ = testEnv.opiSpdkServer.store.Set(testSubsystemName, &testSubsystemWithStatus)
+			testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+
+			spec := utils.ProtoClone(testController.Spec)
+			spec.NvmeControllerId = nil
+			spec.Sqes = 20
+			spec.MaxLimit = &pb.QosLimit{RdIopsKiops: 100}
+			request := &pb.CreateNvmeControllerRequest{Parent: testSubsystemName, NvmeControllerId: "new-controller-id",
+				NvmeController: &pb.NvmeController{Spec: spec}}
+			response, err := testEnv.opiSpdkServer.CreateNvmeController(testEnv.ctx, request)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if tt.errCode != codes.OK {
+				return
+			}
+			if response.Spec.Sqes != 16 || response.Spec.MaxLimit != nil {
+				t.Error("spec: expected the values fixed, received", response.Spec)
+			}
+			warnings, err := testEnv.opiSpdkServer.GetValidationWarnings(testEnv.ctx, &GetValidationWarningsRequest{Name: response.Name})
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			if !reflect.DeepEqual(warnings.Warnings, tt.warnings) {
+				t.Error("warnings: expected", tt.warnings, "received", warnings.Warnings)
+			}
+		})
+	}
+}
+
+func TestFrontEnd_GetValidationWarnings(t *testing.T) {
+	t.Cleanup(checkGlobalTestProtoObjectsNotChanged(t, t.Name()))
+	tests := map[string]struct {
+		in      *GetValidationWarningsRequest
+		out     *ValidationWarnings
+		errCode codes.Code
+		errMsg  string
+	}{
+		"subsystem with warnings": {
+			in:      &GetValidationWarningsRequest{Name: testSubsystemName},
+			out:     &ValidationWarnings{Warnings: []string{"nvme_subsystem.spec.psk: not supported by the firmware, ignored"}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"controller without warnings": {
+			in:      &GetValidationWarningsRequest{Name: testControllerName},
+			out:     &ValidationWarnings{Warnings: []string{}},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &GetValidationWarningsRequest{Name: utils.ResourceIDToSubsystemName("unknown-subsystem-id")},
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  "unable to find key " + utils.ResourceIDToSubsystemName("unknown-subsystem-id"),
+		},
+		"no required field": {
+			in:      &GetValidationWarningsRequest{},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.ListHelper[testSubsystemName] = false
+			testEnv.opiSpdkServer.ListHelper[testControllerName] = false
+			_ = testEnv.opiSpdkServer.saveValidationWarnings(testSubsystemName, []string{"nvme_subsystem.spec.psk: not supported by the firmware, ignored"})
+
+			response, err := testEnv.opiSpdkServer.GetValidationWarnings(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
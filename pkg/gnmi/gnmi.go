@@ -0,0 +1,428 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package gnmi streams the state and the stats of the Nvme controllers and namespaces as gNMI
+// notifications, so the storage telemetry of the DPU goes in the pipelines collecting its
+// networking telemetry
+package gnmi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/fanout"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Modes of a subscription list, as in gNMI
+const (
+	ModeStream = "STREAM"
+	ModeOnce   = "ONCE"
+)
+
+// Modes of a subscription, as in gNMI, TARGET_DEFINED leaves are sampled
+const (
+	ModeTargetDefined = "TARGET_DEFINED"
+	ModeOnChange      = "ON_CHANGE"
+	ModeSample        = "SAMPLE"
+)
+
+// defaultSampleInterval is the sample interval of the subscriptions which don't say
+const defaultSampleInterval = 10 * time.Second
+
+// minSampleInterval is the shortest sample interval, the stats are read from the firmware
+const minSampleInterval = time.Second
+
+// Subscription represents the subscription to the leaves under a path, i.e.
+// /nvme-subsystems/subsystem[name=*]/namespaces/namespace[name=ns0]/stats
+type Subscription struct {
+	// Path of the subscribed leaves, the keys can be *
+	Path string `json:"path"`
+	// Mode of the subscription, TARGET_DEFINED when empty
+	Mode string `json:"mode"`
+	// SampleInterval is how often the leaves are sent in nanoseconds, 10s when 0
+	SampleInterval uint64 `json:"sampleInterval,string"`
+}
+
+// SubscribeRequest represents a list of subscriptions
+type SubscribeRequest struct {
+	// Subscription are the subscribed paths
+	Subscription []*Subscription `json:"subscription"`
+	// Mode of the list, STREAM when empty
+	Mode string `json:"mode"`
+	// UpdatesOnly skips the first values of the leaves
+	UpdatesOnly bool `json:"updatesOnly"`
+}
+
+// Update represents the value of a leaf
+type Update struct {
+	Path string      `json:"path"`
+	Val  interface{} `json:"val"`
+}
+
+// Notification represents the values of the leaves at a time, and the leaves gone
+type Notification struct {
+	// Timestamp of the values, in nanoseconds since the epoch
+	Timestamp int64     `json:"timestamp,string"`
+	Update    []*Update `json:"update,omitempty"`
+	Delete    []string  `json:"delete,omitempty"`
+}
+
+// SubscribeResponse represents a notification, or the end of the first values of the leaves
+type SubscribeResponse struct {
+	Update       *Notification `json:"update,omitempty"`
+	SyncResponse bool          `json:"syncResponse,omitempty"`
+}
+
+// Server serves the subscriptions from the frontend of the bridge
+type Server struct {
+	frontend          pb.FrontendNvmeServiceServer
+	minSampleInterval time.Duration
+	// fanoutWorkers is the number of stats read in parallel
+	fanoutWorkers int
+}
+
+// NewServer returns a Server of the Nvme controllers and namespaces of frontend
+func NewServer(frontend pb.FrontendNvmeServiceServer) *Server {
+	return &Server{frontend: frontend, minSampleInterval: minSampleInterval, fanoutWorkers: fanout.DefaultWorkers}
+}
+
+// SetFanoutWorkers sets the number of stats of the controllers and the namespaces read in
+// parallel
+func (s *Server) SetFanoutWorkers(workers int) {
+	s.fanoutWorkers = workers
+}
+
+// Subscribe sends the leaves of the subscriptions once, or at the shortest sample interval of
+// the subscriptions until the caller goes away. The ON_CHANGE leaves are sent when they change,
+// and the leaves of the resources deleted are sent as deletes
+func (s *Server) Subscribe(ctx context.Context, in *SubscribeRequest, send func(*SubscribeResponse) error) error {
+	subscriptions, interval, err := s.parseSubscribeRequest(in)
+	if err != nil {
+		return err
+	}
+	last := make(map[string]interface{})
+	first := true
+	for {
+		leaves, err := s.collect(ctx, subscriptions)
+		if err != nil {
+			return err
+		}
+		notification := &Notification{Timestamp: time.Now().UnixNano()}
+		for _, leaf := range leaves {
+			previous, known := last[leaf.path]
+			changed := !known || previous != leaf.value
+			if first && in.UpdatesOnly || !changed && leaf.onChange {
+				continue
+			}
+			notification.Update = append(notification.Update, &Update{Path: leaf.path, Val: leaf.value})
+		}
+		current := make(map[string]interface{}, len(leaves))
+		for _, leaf := range leaves {
+			current[leaf.path] = leaf.value
+		}
+		for p := range last {
+			if _, ok := current[p]; !ok {
+				notification.Delete = append(notification.Delete, p)
+			}
+		}
+		last = current
+		if len(notification.Update) != 0 || len(notification.Delete) != 0 {
+			if err := send(&SubscribeResponse{Update: notification}); err != nil {
+				return err
+			}
+		}
+		if first {
+			if err := send(&SubscribeResponse{SyncResponse: true}); err != nil {
+				return err
+			}
+			first = false
+		}
+		if in.Mode == ModeOnce {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// parseSubscribeRequest parses the paths of the subscriptions and returns how often they are
+// sampled
+func (s *Server) parseSubscribeRequest(in *SubscribeRequest) ([]*subscription, time.Duration, error) {
+	if in.Mode != "" && in.Mode != ModeStream && in.Mode != ModeOnce {
+		msg := fmt.Sprintf("Mode %s is not supported, have to be %s or %s", in.Mode, ModeStream, ModeOnce)
+		return nil, 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if len(in.Subscription) == 0 {
+		msg := "Missing subscriptions"
+		return nil, 0, status.Errorf(codes.InvalidArgument, msg)
+	}
+	var subscriptions []*subscription
+	var interval time.Duration
+	for _, sub := range in.Subscription {
+		elems, err := parsePath(sub.Path)
+		if err != nil {
+			return nil, 0, status.Errorf(codes.InvalidArgument, err.Error())
+		}
+		switch sub.Mode {
+		case "", ModeTargetDefined, ModeSample, ModeOnChange:
+		default:
+			msg := fmt.Sprintf("Subscription mode %s of %s is not supported, have to be %s, %s or %s", sub.Mode, sub.Path, ModeTargetDefined, ModeOnChange, ModeSample)
+			return nil, 0, status.Errorf(codes.InvalidArgument, msg)
+		}
+		sampleInterval := time.Duration(sub.SampleInterval)
+		if sampleInterval == 0 {
+			sampleInterval = defaultSampleInterval
+		}
+		if sampleInterval < s.minSampleInterval {
+			msg := fmt.Sprintf("Sample interval %v of %s is invalid, have to be at least %v", sampleInterval, sub.Path, s.minSampleInterval)
+			return nil, 0, status.Errorf(codes.InvalidArgument, msg)
+		}
+		if interval == 0 || sampleInterval < interval {
+			interval = sampleInterval
+		}
+		subscriptions = append(subscriptions, &subscription{elems: elems, onChange: sub.Mode == ModeOnChange})
+	}
+	return subscriptions, interval, nil
+}
+
+// subscription is a parsed Subscription
+type subscription struct {
+	elems    []*pathElem
+	onChange bool
+}
+
+// leaf is the value of a leaf, and whether it is only sent when it changes
+type leaf struct {
+	elems    []*pathElem
+	path     string
+	value    interface{}
+	onChange bool
+}
+
+// collector gets the leaves under the paths the subscriptions want
+type collector struct {
+	subscriptions []*subscription
+	leaves        []*leaf
+}
+
+// wants tells whether the subscriptions want leaves under a path
+func (c *collector) wants(elems []*pathElem) bool {
+	for _, sub := range c.subscriptions {
+		if matches(sub.elems, elems) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the leaf of a path when a subscription wants it
+func (c *collector) add(elems []*pathElem, value interface{}) {
+	wanted, onChange := false, true
+	for _, sub := range c.subscriptions {
+		if len(sub.elems) <= len(elems) && matches(sub.elems, elems) {
+			wanted = true
+			onChange = onChange && sub.onChange
+		}
+	}
+	if wanted {
+		c.leaves = append(c.leaves, &leaf{elems: elems, path: formatPath(elems), value: value, onChange: onChange})
+	}
+}
+
+// addStats adds the leaves of stats under a path
+func (c *collector) addStats(elems []*pathElem, stats *pb.VolumeStats) {
+	for _, stat := range []struct {
+		name  string
+		value int32
+	}{
+		{"read-bytes", stats.GetReadBytesCount()},
+		{"read-ops", stats.GetReadOpsCount()},
+		{"write-bytes", stats.GetWriteBytesCount()},
+		{"write-ops", stats.GetWriteOpsCount()},
+		{"unmap-bytes", stats.GetUnmapBytesCount()},
+		{"unmap-ops", stats.GetUnmapOpsCount()},
+		{"read-latency-ticks", stats.GetReadLatencyTicks()},
+		{"write-latency-ticks", stats.GetWriteLatencyTicks()},
+		{"unmap-latency-ticks", stats.GetUnmapLatencyTicks()},
+	} {
+		c.add(appendElem(elems, stat.name, nil), int64(stat.value))
+	}
+}
+
+// namespaceStates names the states of the namespaces
+var namespaceStates = map[pb.NvmeNamespaceStatus_State]string{
+	pb.NvmeNamespaceStatus_STATE_UNSPECIFIED: "UNSPECIFIED",
+	pb.NvmeNamespaceStatus_STATE_DISABLED:    "DISABLED",
+	pb.NvmeNamespaceStatus_STATE_ENABLED:     "ENABLED",
+	pb.NvmeNamespaceStatus_STATE_DELETING:    "DELETING",
+}
+
+// namespaceOperStates names the operational states of the namespaces
+var namespaceOperStates = map[pb.NvmeNamespaceStatus_OperState]string{
+	pb.NvmeNamespaceStatus_OPER_STATE_UNSPECIFIED: "UNSPECIFIED",
+	pb.NvmeNamespaceStatus_OPER_STATE_ONLINE:      "ONLINE",
+	pb.NvmeNamespaceStatus_OPER_STATE_OFFLINE:     "OFFLINE",
+}
+
+// unscope returns the name of a resource seen by a tenant, false when it belongs to another
+// tenant. The callers without tenant see all the resources
+func unscope(tenantID string, name string) (string, bool) {
+	if tenantID == "" {
+		return name, true
+	}
+	return tenant.UnscopeName(tenantID, name)
+}
+
+// collect gets the leaves the subscriptions want, the stats are only read from the firmware
+// when wanted. The resources gone while collected are skipped, as the ones of the other tenants
+// than the one of the caller
+func (s *Server) collect(ctx context.Context, subscriptions []*subscription) ([]*leaf, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := &collector{subscriptions: subscriptions}
+	subsystems, err := s.listSubsystems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, subsystem := range subsystems {
+		name, owned := unscope(tenantID, subsystem.Name)
+		if !owned {
+			continue
+		}
+		subsystemElems := []*pathElem{{name: "nvme-subsystems"}, {name: "subsystem", keys: map[string]string{"name": path.Base(name)}}}
+		if !c.wants(subsystemElems) {
+			continue
+		}
+		c.add(appendElem(subsystemElems, "state", nil, "nqn"), subsystem.GetSpec().GetNqn())
+
+		controllersElems := appendElem(subsystemElems, "controllers", nil)
+		if c.wants(controllersElems) {
+			controllers, err := s.listControllers(ctx, subsystem.Name)
+			if err != nil {
+				return nil, err
+			}
+			// the stats are read in parallel, the ones which can't be read are left out
+			stats, err := fanout.Map(ctx, s.fanoutWorkers, controllers, func(ctx context.Context, controller *pb.NvmeController) (*pb.VolumeStats, error) {
+				elems := appendElem(controllersElems, "controller", map[string]string{"name": path.Base(controller.Name)})
+				if !c.wants(appendElem(elems, "stats", nil)) {
+					return nil, nil
+				}
+				stats, err := s.frontend.StatsNvmeController(ctx, &pb.StatsNvmeControllerRequest{Name: controller.Name})
+				if err != nil {
+					slog.Warn("cannot get the stats of the controller", "name", controller.Name, "error", err)
+					return nil, nil
+				}
+				return stats.GetStats(), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			for i, controller := range controllers {
+				elems := appendElem(controllersElems, "controller", map[string]string{"name": path.Base(controller.Name)})
+				c.add(appendElem(elems, "state", nil, "active"), controller.GetStatus().GetActive())
+				if stats[i] != nil {
+					c.addStats(appendElem(elems, "stats", nil), stats[i])
+				}
+			}
+		}
+
+		namespacesElems := appendElem(subsystemElems, "namespaces", nil)
+		if c.wants(namespacesElems) {
+			namespaces, err := s.listNamespaces(ctx, subsystem.Name)
+			if err != nil {
+				return nil, err
+			}
+			stats, err := fanout.Map(ctx, s.fanoutWorkers, namespaces, func(ctx context.Context, namespace *pb.NvmeNamespace) (*pb.VolumeStats, error) {
+				elems := appendElem(namespacesElems, "namespace", map[string]string{"name": path.Base(namespace.Name)})
+				if !c.wants(appendElem(elems, "stats", nil)) {
+					return nil, nil
+				}
+				stats, err := s.frontend.StatsNvmeNamespace(ctx, &pb.StatsNvmeNamespaceRequest{Name: namespace.Name})
+				if err != nil {
+					slog.Warn("cannot get the stats of the namespace", "name", namespace.Name, "error", err)
+					return nil, nil
+				}
+				return stats.GetStats(), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			for i, namespace := range namespaces {
+				elems := appendElem(namespacesElems, "namespace", map[string]string{"name": path.Base(namespace.Name)})
+				volume, _ := unscope(tenantID, namespace.GetSpec().GetVolumeNameRef())
+				c.add(appendElem(elems, "state", nil, "volume-name-ref"), volume)
+				c.add(appendElem(elems, "state", nil, "host-nsid"), int64(namespace.GetSpec().GetHostNsid()))
+				c.add(appendElem(elems, "state", nil, "admin-state"), namespaceStates[namespace.GetStatus().GetState()])
+				c.add(appendElem(elems, "state", nil, "oper-state"), namespaceOperStates[namespace.GetStatus().GetOperState()])
+				if stats[i] != nil {
+					c.addStats(appendElem(elems, "stats", nil), stats[i])
+				}
+			}
+		}
+	}
+	return c.leaves, nil
+}
+
+// listSubsystems lists the Nvme subsystems of all the pages
+func (s *Server) listSubsystems(ctx context.Context) ([]*pb.NvmeSubsystem, error) {
+	var subsystems []*pb.NvmeSubsystem
+	pageToken := ""
+	for {
+		response, err := s.frontend.ListNvmeSubsystems(ctx, &pb.ListNvmeSubsystemsRequest{PageToken: pageToken})
+		if err != nil {
+			return nil, err
+		}
+		subsystems = append(subsystems, response.NvmeSubsystems...)
+		if response.NextPageToken == "" {
+			return subsystems, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+// listControllers lists the Nvme controllers of a subsystem of all the pages
+func (s *Server) listControllers(ctx context.Context, parent string) ([]*pb.NvmeController, error) {
+	var controllers []*pb.NvmeController
+	pageToken := ""
+	for {
+		response, err := s.frontend.ListNvmeControllers(ctx, &pb.ListNvmeControllersRequest{Parent: parent, PageToken: pageToken})
+		if err != nil {
+			return nil, err
+		}
+		controllers = append(controllers, response.NvmeControllers...)
+		if response.NextPageToken == "" {
+			return controllers, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+// listNamespaces lists the Nvme namespaces of a subsystem of all the pages
+func (s *Server) listNamespaces(ctx context.Context, parent string) ([]*pb.NvmeNamespace, error) {
+	var namespaces []*pb.NvmeNamespace
+	pageToken := ""
+	for {
+		response, err := s.frontend.ListNvmeNamespaces(ctx, &pb.ListNvmeNamespacesRequest{Parent: parent, PageToken: pageToken})
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, response.NvmeNamespaces...)
+		if response.NextPageToken == "" {
+			return namespaces, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package gnmi streams the state and the stats of the Nvme controllers and namespaces as gNMI
+// notifications, so the storage telemetry of the DPU goes in the pipelines collecting its
+// networking telemetry
+package gnmi
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testFrontend serves the subsystem subsys0, with the controller ctrl0 and the namespaces
+type testFrontend struct {
+	pb.UnimplementedFrontendNvmeServiceServer
+	mu         sync.Mutex
+	subsystems []*pb.NvmeSubsystem
+	namespaces []*pb.NvmeNamespace
+	statsCalls int
+}
+
+func (f *testFrontend) ListNvmeSubsystems(_ context.Context, _ *pb.ListNvmeSubsystemsRequest) (*pb.ListNvmeSubsystemsResponse, error) {
+	return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: f.subsystems}, nil
+}
+
+func (f *testFrontend) ListNvmeControllers(_ context.Context, in *pb.ListNvmeControllersRequest) (*pb.ListNvmeControllersResponse, error) {
+	return &pb.ListNvmeControllersResponse{NvmeControllers: []*pb.NvmeController{
+		{Name: in.Parent + "/nvmeControllers/ctrl0", Status: &pb.NvmeControllerStatus{Active: true}},
+	}}, nil
+}
+
+func (f *testFrontend) StatsNvmeController(_ context.Context, _ *pb.StatsNvmeControllerRequest) (*pb.StatsNvmeControllerResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statsCalls++
+	return &pb.StatsNvmeControllerResponse{Stats: &pb.VolumeStats{ReadOpsCount: 10}}, nil
+}
+
+func (f *testFrontend) ListNvmeNamespaces(_ context.Context, _ *pb.ListNvmeNamespacesRequest) (*pb.ListNvmeNamespacesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &pb.ListNvmeNamespacesResponse{NvmeNamespaces: f.namespaces}, nil
+}
+
+func (f *testFrontend) StatsNvmeNamespace(_ context.Context, in *pb.StatsNvmeNamespaceRequest) (*pb.StatsNvmeNamespaceResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statsCalls++
+	return &pb.StatsNvmeNamespaceResponse{Stats: &pb.VolumeStats{WriteOpsCount: 20}}, nil
+}
+
+func newTestFrontend() *testFrontend {
+	return &testFrontend{subsystems: []*pb.NvmeSubsystem{
+		{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi0"}},
+	}, namespaces: []*pb.NvmeNamespace{
+		{
+			Name:   "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeNamespaces/ns0",
+			Spec:   &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc0", HostNsid: 1},
+			Status: &pb.NvmeNamespaceStatus{State: pb.NvmeNamespaceStatus_STATE_ENABLED, OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE},
+		},
+		{
+			Name:   "//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeNamespaces/ns1",
+			Spec:   &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc1", HostNsid: 2},
+			Status: &pb.NvmeNamespaceStatus{State: pb.NvmeNamespaceStatus_STATE_ENABLED, OperState: pb.NvmeNamespaceStatus_OPER_STATE_ONLINE},
+		},
+	}}
+}
+
+func TestGnmi_SubscribeOnce(t *testing.T) {
+	tests := map[string]struct {
+		in         *SubscribeRequest
+		out        []*Update
+		statsCalls int
+		errCode    codes.Code
+		errMsg     string
+	}{
+		"state of a namespace": {
+			in: &SubscribeRequest{Mode: ModeOnce, Subscription: []*Subscription{
+				{Path: "/nvme-subsystems/subsystem[name=*]/namespaces/namespace[name=ns0]/state"},
+			}},
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/volume-name-ref", Val: "Malloc0"},
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/host-nsid", Val: int64(1)},
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/admin-state", Val: "ENABLED"},
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/oper-state", Val: "ONLINE"},
+			},
+			statsCalls: 0,
+			errCode:    codes.OK,
+		},
+		"read ops of the controllers": {
+			in: &SubscribeRequest{Mode: ModeOnce, Subscription: []*Subscription{
+				{Path: "/nvme-subsystems/subsystem/controllers/controller/stats/read-ops"},
+			}},
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/controllers/controller[name=ctrl0]/stats/read-ops", Val: int64(10)},
+			},
+			statsCalls: 1,
+			errCode:    codes.OK,
+		},
+		"invalid path": {
+			in:      &SubscribeRequest{Mode: ModeOnce, Subscription: []*Subscription{{Path: "/nvme-subsystems/subsystem[name]"}}},
+			errCode: codes.InvalidArgument,
+			errMsg:  `invalid path "/nvme-subsystems/subsystem[name]", have to have keys as [key=value]`,
+		},
+		"invalid sample interval": {
+			in:      &SubscribeRequest{Subscription: []*Subscription{{Path: "/nvme-subsystems", SampleInterval: uint64(time.Millisecond)}}},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Sample interval 1ms of /nvme-subsystems is invalid, have to be at least 1s",
+		},
+		"unsupported mode": {
+			in:      &SubscribeRequest{Mode: "POLL", Subscription: []*Subscription{{Path: "/nvme-subsystems"}}},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Mode POLL is not supported, have to be STREAM or ONCE",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			frontend := newTestFrontend()
+			server := NewServer(frontend)
+			var responses []*SubscribeResponse
+
+			err := server.Subscribe(context.Background(), tt.in, func(response *SubscribeResponse) error {
+				responses = append(responses, response)
+				return nil
+			})
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err != nil {
+				return
+			}
+			if len(responses) != 2 || !responses[1].SyncResponse {
+				t.Fatal("responses: expected a notification and a sync response, received", responses)
+			}
+			if !reflect.DeepEqual(responses[0].Update.Update, tt.out) {
+				t.Error("updates: expected", tt.out, "received", responses[0].Update.Update)
+			}
+			if frontend.statsCalls != tt.statsCalls {
+				t.Error("stats calls: expected", tt.statsCalls, "received", frontend.statsCalls)
+			}
+		})
+	}
+}
+
+func TestGnmi_SubscribeStreamOnChange(t *testing.T) {
+	frontend := newTestFrontend()
+	server := NewServer(frontend)
+	server.minSampleInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := &SubscribeRequest{Subscription: []*Subscription{
+		{Path: "/nvme-subsystems/subsystem/namespaces/namespace/state/oper-state", Mode: ModeOnChange, SampleInterval: uint64(time.Millisecond)},
+	}}
+	var responses []*SubscribeResponse
+	errStop := errors.New("stop")
+
+	err := server.Subscribe(ctx, in, func(response *SubscribeResponse) error {
+		responses = append(responses, response)
+		switch len(responses) {
+		case 2:
+			// nothing is sent until a namespace changes, or is deleted
+			frontend.mu.Lock()
+			frontend.namespaces[0].Status.OperState = pb.NvmeNamespaceStatus_OPER_STATE_OFFLINE
+			frontend.namespaces = frontend.namespaces[:1]
+			frontend.mu.Unlock()
+		case 3:
+			return errStop
+		}
+		return nil
+	})
+
+	if !errors.Is(err, errStop) {
+		t.Fatal(err)
+	}
+	if len(responses[0].Update.Update) != 2 || !responses[1].SyncResponse {
+		t.Error("first responses: expected 2 updates and a sync response, received", responses[0].Update, responses[1])
+	}
+	expected := &Notification{
+		Timestamp: responses[2].Update.Timestamp,
+		Update:    []*Update{{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/oper-state", Val: "OFFLINE"}},
+		Delete:    []string{"/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns1]/state/oper-state"},
+	}
+	if !reflect.DeepEqual(responses[2].Update, expected) {
+		t.Error("notification: expected", expected, "received", responses[2].Update)
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package gnmi streams the state and the stats of the Nvme controllers and namespaces as gNMI
+// notifications, so the storage telemetry of the DPU goes in the pipelines collecting its
+// networking telemetry
+package gnmi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pathElem is an element of a gNMI path, i.e. namespace[name=ns0]
+type pathElem struct {
+	name string
+	keys map[string]string
+}
+
+// parsePath parses the string form of a gNMI path, i.e. /nvme-subsystems/subsystem[name=*]
+func parsePath(p string) ([]*pathElem, error) {
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid path %q, have to start with /", p)
+	}
+	var elems []*pathElem
+	rest := p[1:]
+	for rest != "" {
+		end := strings.IndexAny(rest, "/[")
+		if end < 0 {
+			end = len(rest)
+		}
+		elem := &pathElem{name: rest[:end]}
+		if elem.name == "" {
+			return nil, fmt.Errorf("invalid path %q, have an empty element", p)
+		}
+		rest = rest[end:]
+		for strings.HasPrefix(rest, "[") {
+			end = strings.Index(rest, "]")
+			key, value, found := strings.Cut(rest[1:max(end, 1)], "=")
+			if end < 0 || !found || key == "" {
+				return nil, fmt.Errorf("invalid path %q, have to have keys as [key=value]", p)
+			}
+			if elem.keys == nil {
+				elem.keys = make(map[string]string)
+			}
+			elem.keys[key] = value
+			rest = rest[end+1:]
+		}
+		if rest != "" && !strings.HasPrefix(rest, "/") {
+			return nil, fmt.Errorf("invalid path %q, have to have / after the keys", p)
+		}
+		rest = strings.TrimPrefix(rest, "/")
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
+
+// formatPath returns the string form of a gNMI path, the keys are sorted
+func formatPath(elems []*pathElem) string {
+	var b strings.Builder
+	for _, elem := range elems {
+		b.WriteString("/" + elem.name)
+		keys := make([]string, 0, len(elem.keys))
+		for key := range elem.keys {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString("[" + key + "=" + elem.keys[key] + "]")
+		}
+	}
+	return b.String()
+}
+
+// matches tells whether the elements of a subscribed path and of a path match, as far as both
+// go. The names and the key values of the subscribed path can be *, its missing keys match any
+func matches(subscribed []*pathElem, elems []*pathElem) bool {
+	for i := 0; i < len(subscribed) && i < len(elems); i++ {
+		if subscribed[i].name != "*" && subscribed[i].name != elems[i].name {
+			return false
+		}
+		for key, value := range subscribed[i].keys {
+			if value != "*" && value != elems[i].keys[key] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// appendElem returns the elements of a path followed by an element with keys and by elements
+// without, the path is not changed
+func appendElem(elems []*pathElem, name string, keys map[string]string, names ...string) []*pathElem {
+	appended := make([]*pathElem, 0, len(elems)+1+len(names))
+	appended = append(appended, elems...)
+	appended = append(appended, &pathElem{name: name, keys: keys})
+	for _, n := range names {
+		appended = append(appended, &pathElem{name: n})
+	}
+	return appended
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package gnmi streams the state and the stats of the Nvme controllers and namespaces as gNMI
+// notifications, so the storage telemetry of the DPU goes in the pipelines collecting its
+// networking telemetry
+package gnmi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Version is the version of the gNMI specification served
+const Version = "0.7.0"
+
+// Model describes the tree of the leaves, /nvme-subsystems
+var Model = &gpb.ModelData{Name: "opi-nvme-storage", Organization: "OPI", Version: "0.1.0"}
+
+// Service serves the gNMI service of gnmi.proto with the leaves of a Server, so the gNMI
+// collectors subscribe to them as to the ones of the networking of the DPU. The leaves are
+// scalars, sent as typed values whatever the encoding, and the resources are configured with
+// the OPI storage API, Set isn't supported
+type Service struct {
+	server *Server
+}
+
+// NewService returns a Service of the leaves of server
+func NewService(server *Server) *Service {
+	return &Service{server: server}
+}
+
+// FiltersTenant implements tenant.Filtering, the leaves of the resources of the other tenants
+// are left out and the names in the paths are the ones the tenant knows
+func (s *Service) FiltersTenant() {}
+
+// Capabilities implements gpb.GNMIServer
+func (s *Service) Capabilities(_ context.Context, _ *gpb.CapabilityRequest) (*gpb.CapabilityResponse, error) {
+	return &gpb.CapabilityResponse{
+		SupportedModels:    []*gpb.ModelData{Model},
+		SupportedEncodings: []gpb.Encoding{gpb.Encoding_JSON, gpb.Encoding_JSON_IETF, gpb.Encoding_PROTO},
+		GNMIVersion:        Version,
+	}, nil
+}
+
+// Get implements gpb.GNMIServer, the current leaves under the paths are returned in a single
+// notification
+func (s *Service) Get(ctx context.Context, in *gpb.GetRequest) (*gpb.GetResponse, error) {
+	if len(in.Path) == 0 {
+		msg := "Missing paths"
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	subscriptions := make([]*subscription, 0, len(in.Path))
+	for _, p := range in.Path {
+		elems, err := fromPath(in.Prefix, p)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, err.Error())
+		}
+		subscriptions = append(subscriptions, &subscription{elems: elems})
+	}
+	leaves, err := s.server.collect(ctx, subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	notification := &gpb.Notification{Timestamp: time.Now().UnixNano()}
+	for _, leaf := range leaves {
+		notification.Update = append(notification.Update, &gpb.Update{Path: toPath(leaf.elems), Val: toTypedValue(leaf.value)})
+	}
+	return &gpb.GetResponse{Notification: []*gpb.Notification{notification}}, nil
+}
+
+// Set implements gpb.GNMIServer
+func (s *Service) Set(_ context.Context, _ *gpb.SetRequest) (*gpb.SetResponse, error) {
+	msg := "Set is not supported, the resources are configured with the OPI storage API"
+	return nil, status.Errorf(codes.Unimplemented, msg)
+}
+
+// Subscribe implements gpb.GNMIServer, the subscription list of the first request is served as
+// by Server.Subscribe, the POLL lists aren't supported
+func (s *Service) Subscribe(stream gpb.GNMI_SubscribeServer) error {
+	in, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	list := in.GetSubscribe()
+	if list == nil {
+		msg := "Missing subscription list, the first request has to subscribe"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	request := &SubscribeRequest{Mode: list.Mode.String(), UpdatesOnly: list.UpdatesOnly}
+	for _, sub := range list.Subscription {
+		elems, err := fromPath(list.Prefix, sub.Path)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, err.Error())
+		}
+		request.Subscription = append(request.Subscription, &Subscription{Path: formatPath(elems), Mode: sub.Mode.String(), SampleInterval: sub.SampleInterval})
+	}
+	return s.server.Subscribe(stream.Context(), request, func(response *SubscribeResponse) error {
+		if response.SyncResponse {
+			return stream.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true}})
+		}
+		notification, err := toNotification(response.Update)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_Update{Update: notification}})
+	})
+}
+
+// fromPath returns the elements of a gNMI path under a prefix, their origin and target are
+// ignored
+func fromPath(prefix *gpb.Path, p *gpb.Path) ([]*pathElem, error) {
+	var elems []*pathElem
+	for _, q := range []*gpb.Path{prefix, p} {
+		if len(q.GetElement()) != 0 {
+			return nil, fmt.Errorf("invalid path %v, have to use elem rather than element", q)
+		}
+		for _, elem := range q.GetElem() {
+			if elem.Name == "" {
+				return nil, fmt.Errorf("invalid path %v, have an empty element", q)
+			}
+			elems = append(elems, &pathElem{name: elem.Name, keys: elem.Key})
+		}
+	}
+	return elems, nil
+}
+
+// toPath returns the gNMI path of elements
+func toPath(elems []*pathElem) *gpb.Path {
+	p := &gpb.Path{Elem: make([]*gpb.PathElem, 0, len(elems))}
+	for _, elem := range elems {
+		p.Elem = append(p.Elem, &gpb.PathElem{Name: elem.name, Key: elem.keys})
+	}
+	return p
+}
+
+// toTypedValue returns the gNMI value of a leaf
+func toTypedValue(value interface{}) *gpb.TypedValue {
+	switch v := value.(type) {
+	case int64:
+		return &gpb.TypedValue{Value: &gpb.TypedValue_IntVal{IntVal: v}}
+	case bool:
+		return &gpb.TypedValue{Value: &gpb.TypedValue_BoolVal{BoolVal: v}}
+	default:
+		return &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: fmt.Sprint(v)}}
+	}
+}
+
+// toNotification returns the gNMI notification of a Notification
+func toNotification(n *Notification) (*gpb.Notification, error) {
+	notification := &gpb.Notification{Timestamp: n.Timestamp}
+	for _, u := range n.Update {
+		elems, err := parsePath(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		notification.Update = append(notification.Update, &gpb.Update{Path: toPath(elems), Val: toTypedValue(u.Val)})
+	}
+	for _, d := range n.Delete {
+		elems, err := parsePath(d)
+		if err != nil {
+			return nil, err
+		}
+		notification.Delete = append(notification.Delete, toPath(elems))
+	}
+	return notification, nil
+}
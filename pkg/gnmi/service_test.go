@@ -0,0 +1,328 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package gnmi streams the state and the stats of the Nvme controllers and namespaces as gNMI
+// notifications, so the storage telemetry of the DPU goes in the pipelines collecting its
+// networking telemetry
+package gnmi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient serves the gNMI service of the leaves of frontend, with the tenants, and returns a
+// client of it
+func newTestClient(t *testing.T, frontend *testFrontend) gpb.GNMIClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(tenant.UnaryServerInterceptor()))
+	gpb.RegisterGNMIServer(server, NewService(NewServer(frontend)))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return gpb.NewGNMIClient(conn)
+}
+
+// testUpdates returns the updates of a gNMI notification with their paths formatted
+func testUpdates(notification *gpb.Notification) []*Update {
+	var updates []*Update
+	for _, u := range notification.GetUpdate() {
+		elems, _ := fromPath(nil, u.Path)
+		var val interface{}
+		switch v := u.Val.GetValue().(type) {
+		case *gpb.TypedValue_IntVal:
+			val = v.IntVal
+		case *gpb.TypedValue_BoolVal:
+			val = v.BoolVal
+		case *gpb.TypedValue_StringVal:
+			val = v.StringVal
+		}
+		updates = append(updates, &Update{Path: formatPath(elems), Val: val})
+	}
+	return updates
+}
+
+// testPath returns the gNMI path of p
+func testPath(t *testing.T, p string) *gpb.Path {
+	elems, err := parsePath(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toPath(elems)
+}
+
+func TestGnmi_Capabilities(t *testing.T) {
+	client := newTestClient(t, newTestFrontend())
+
+	response, err := client.Capabilities(context.Background(), &gpb.CapabilityRequest{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GNMIVersion != Version {
+		t.Error("version: expected", Version, "received", response.GNMIVersion)
+	}
+	if len(response.SupportedModels) != 1 || response.SupportedModels[0].Name != Model.Name {
+		t.Error("models: expected", Model, "received", response.SupportedModels)
+	}
+}
+
+func TestGnmi_Get(t *testing.T) {
+	tests := map[string]struct {
+		in      func(t *testing.T) *gpb.GetRequest
+		out     []*Update
+		errCode codes.Code
+		errMsg  string
+	}{
+		"state of a namespace": {
+			in: func(t *testing.T) *gpb.GetRequest {
+				return &gpb.GetRequest{Path: []*gpb.Path{
+					testPath(t, "/nvme-subsystems/subsystem[name=*]/namespaces/namespace[name=ns0]/state"),
+				}}
+			},
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/volume-name-ref", Val: "Malloc0"},
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/host-nsid", Val: int64(1)},
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/admin-state", Val: "ENABLED"},
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/oper-state", Val: "ONLINE"},
+			},
+			errCode: codes.OK,
+		},
+		"read ops of the controllers under a prefix": {
+			in: func(t *testing.T) *gpb.GetRequest {
+				return &gpb.GetRequest{
+					Prefix: testPath(t, "/nvme-subsystems/subsystem"),
+					Path:   []*gpb.Path{testPath(t, "/controllers/controller/stats/read-ops")},
+				}
+			},
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/controllers/controller[name=ctrl0]/stats/read-ops", Val: int64(10)},
+			},
+			errCode: codes.OK,
+		},
+		"missing paths": {
+			in: func(_ *testing.T) *gpb.GetRequest {
+				return &gpb.GetRequest{}
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Missing paths",
+		},
+		"deprecated element": {
+			in: func(_ *testing.T) *gpb.GetRequest {
+				return &gpb.GetRequest{Path: []*gpb.Path{{Element: []string{"nvme-subsystems"}}}}
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  `invalid path element:"nvme-subsystems" , have to use elem rather than element`,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, newTestFrontend())
+
+			response, err := client.Get(context.Background(), tt.in(t))
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err != nil {
+				return
+			}
+			if len(response.Notification) != 1 {
+				t.Fatal("notifications: expected 1, received", response.Notification)
+			}
+			if updates := testUpdates(response.Notification[0]); !reflect.DeepEqual(updates, tt.out) {
+				t.Error("updates: expected", tt.out, "received", updates)
+			}
+		})
+	}
+}
+
+func TestGnmi_Set(t *testing.T) {
+	client := newTestClient(t, newTestFrontend())
+
+	_, err := client.Set(context.Background(), &gpb.SetRequest{})
+
+	er, _ := status.FromError(err)
+	if er.Code() != codes.Unimplemented {
+		t.Error("error code: expected", codes.Unimplemented, "received", er.Code())
+	}
+}
+
+func TestGnmi_SubscribeService(t *testing.T) {
+	tests := map[string]struct {
+		in      func(t *testing.T) *gpb.SubscribeRequest
+		out     []*Update
+		errCode codes.Code
+		errMsg  string
+	}{
+		"once": {
+			in: func(t *testing.T) *gpb.SubscribeRequest {
+				return &gpb.SubscribeRequest{Request: &gpb.SubscribeRequest_Subscribe{Subscribe: &gpb.SubscriptionList{
+					Mode: gpb.SubscriptionList_ONCE,
+					Subscription: []*gpb.Subscription{
+						{Path: testPath(t, "/nvme-subsystems/subsystem/namespaces/namespace[name=ns1]/stats/write-ops")},
+					},
+				}}}
+			},
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns1]/stats/write-ops", Val: int64(20)},
+			},
+			errCode: codes.OK,
+		},
+		"poll": {
+			in: func(t *testing.T) *gpb.SubscribeRequest {
+				return &gpb.SubscribeRequest{Request: &gpb.SubscribeRequest_Subscribe{Subscribe: &gpb.SubscriptionList{
+					Mode:         gpb.SubscriptionList_POLL,
+					Subscription: []*gpb.Subscription{{Path: testPath(t, "/nvme-subsystems")}},
+				}}}
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Mode POLL is not supported, have to be STREAM or ONCE",
+		},
+		"missing subscription list": {
+			in: func(_ *testing.T) *gpb.SubscribeRequest {
+				return &gpb.SubscribeRequest{Request: &gpb.SubscribeRequest_Poll{Poll: &gpb.Poll{}}}
+			},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Missing subscription list, the first request has to subscribe",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, newTestFrontend())
+			stream, err := client.Subscribe(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := stream.Send(tt.in(t)); err != nil {
+				t.Fatal(err)
+			}
+
+			var responses []*gpb.SubscribeResponse
+			for {
+				response, err := stream.Recv()
+				if err != nil {
+					if errors.Is(err, io.EOF) {
+						err = nil
+					}
+					er, _ := status.FromError(err)
+					if er.Code() != tt.errCode {
+						t.Error("error code: expected", tt.errCode, "received", er.Code())
+					}
+					if er.Message() != tt.errMsg {
+						t.Error("error message: expected", tt.errMsg, "received", er.Message())
+					}
+					break
+				}
+				responses = append(responses, response)
+			}
+			if tt.errCode != codes.OK {
+				return
+			}
+			if len(responses) != 2 || !responses[1].GetSyncResponse() {
+				t.Fatal("responses: expected a notification and a sync response, received", responses)
+			}
+			if updates := testUpdates(responses[0].GetUpdate()); !reflect.DeepEqual(updates, tt.out) {
+				t.Error("updates: expected", tt.out, "received", updates)
+			}
+		})
+	}
+}
+
+func TestGnmi_GetTenants(t *testing.T) {
+	tests := map[string]struct {
+		tenant string
+		path   string
+		out    []*Update
+	}{
+		"tenant": {
+			tenant: "acme",
+			path:   "/nvme-subsystems/subsystem/state/nqn",
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/state/nqn", Val: "nqn.2022-09.io.spdk:opi0"},
+			},
+		},
+		"other tenant": {
+			tenant: "other",
+			path:   "/nvme-subsystems/subsystem/state/nqn",
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=subsys1]/state/nqn", Val: "nqn.2022-09.io.spdk:opi1"},
+			},
+		},
+		"without tenant": {
+			tenant: "",
+			path:   "/nvme-subsystems/subsystem/state/nqn",
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=acme-subsys0]/state/nqn", Val: "nqn.2022-09.io.spdk:opi0"},
+				{Path: "/nvme-subsystems/subsystem[name=other-subsys1]/state/nqn", Val: "nqn.2022-09.io.spdk:opi1"},
+			},
+		},
+		"volume of the tenant": {
+			tenant: "acme",
+			path:   "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/volume-name-ref",
+			out: []*Update{
+				{Path: "/nvme-subsystems/subsystem[name=subsys0]/namespaces/namespace[name=ns0]/state/volume-name-ref", Val: "Malloc0"},
+			},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			frontend := newTestFrontend()
+			frontend.subsystems = []*pb.NvmeSubsystem{
+				{Name: "//storage.opiproject.org/nvmeSubsystems/acme-subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi0"}},
+				{Name: "//storage.opiproject.org/nvmeSubsystems/other-subsys1", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi1"}},
+			}
+			frontend.namespaces[0].Spec.VolumeNameRef = "acme-Malloc0"
+			client := newTestClient(t, frontend)
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, tenant.MetadataKey, tt.tenant)
+			}
+
+			response, err := client.Get(ctx, &gpb.GetRequest{Path: []*gpb.Path{testPath(t, tt.path)}})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updates := testUpdates(response.Notification[0]); !reflect.DeepEqual(updates, tt.out) {
+				t.Error("updates: expected", tt.out, "received", updates)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package gnoi implements operational methods of the storage of the DPU modeled on the gNOI
+// System service, so the NOC automation operates it as the other functions of the DPU
+package gnoi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// maxPingCount bounds the pings of a Ping request
+const maxPingCount = 10
+
+// pingTimeout bounds each ping of the firmware
+const pingTimeout = 5 * time.Second
+
+// Restarter restarts the SPDK application
+type Restarter interface {
+	Restart()
+}
+
+// StatsClearer clears the stats of a resource, or of all of them when name is empty
+type StatsClearer interface {
+	ClearNvmeStats(ctx context.Context, name string) error
+}
+
+// ResetFunc deletes a part of the storage configuration
+type ResetFunc func(ctx context.Context) error
+
+// PingRequest represents a request to ping the firmware
+type PingRequest struct {
+	// Count is the number of pings, between 1 and 10, 1 when 0
+	Count int32 `json:"count"`
+}
+
+// PingResponse represents the round trip times of the pings of the firmware
+type PingResponse struct {
+	// Version is the SPDK version of the firmware
+	Version  string `json:"version"`
+	Sent     int32  `json:"sent"`
+	Received int32  `json:"received"`
+	// MinTimeUs, AvgTimeUs and MaxTimeUs are the round trip times of the answered pings
+	MinTimeUs int64 `json:"minTimeUs"`
+	AvgTimeUs int64 `json:"avgTimeUs"`
+	MaxTimeUs int64 `json:"maxTimeUs"`
+}
+
+// RebootRequest represents a request to restart the SPDK application
+type RebootRequest struct {
+	// Message tells why, it is logged
+	Message string `json:"message"`
+}
+
+// ClearStatsRequest represents a request to clear the stats
+type ClearStatsRequest struct {
+	// Name of the Nvme controller or namespace, all of them when empty
+	Name string `json:"name"`
+}
+
+// FactoryResetRequest represents a request to delete the storage configuration
+type FactoryResetRequest struct{}
+
+// Server serves the operational methods
+type Server struct {
+	rpc          spdk.JSONRPC
+	restarter    Restarter
+	statsClearer StatsClearer
+	resets       []ResetFunc
+}
+
+// NewServer returns a Server pinging the firmware with jsonRPC
+func NewServer(jsonRPC spdk.JSONRPC) *Server {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	return &Server{rpc: jsonRPC}
+}
+
+// SetRestarter sets what restarts the SPDK application, it can't be rebooted when not set
+func (s *Server) SetRestarter(restarter Restarter) {
+	s.restarter = restarter
+}
+
+// SetStatsClearer sets what clears the stats
+func (s *Server) SetStatsClearer(clearer StatsClearer) {
+	s.statsClearer = clearer
+}
+
+// SetFactoryReset sets the functions deleting the storage configuration, called in order, the
+// resources using others first
+func (s *Server) SetFactoryReset(resets ...ResetFunc) {
+	s.resets = resets
+}
+
+// Ping pings the firmware, the pings not answered are not errors
+func (s *Server) Ping(ctx context.Context, in *PingRequest) (*PingResponse, error) {
+	count := in.Count
+	if count == 0 {
+		count = 1
+	}
+	if count < 1 || count > maxPingCount {
+		msg := fmt.Sprintf("Count (%d) is invalid, have to be between 1 and %d", in.Count, maxPingCount)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	response := &PingResponse{}
+	var total time.Duration
+	for i := int32(0); i < count; i++ {
+		response.Sent++
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		started := time.Now()
+		var result spdk.GetVersionResult
+		err := s.rpc.Call(pingCtx, "spdk_get_version", nil, &result)
+		elapsed := time.Since(started)
+		cancel()
+		if err != nil {
+			slog.Warn("firmware not answering the ping", "error", err)
+			continue
+		}
+		response.Received++
+		response.Version = result.Version
+		total += elapsed
+		if response.Received == 1 || elapsed.Microseconds() < response.MinTimeUs {
+			response.MinTimeUs = elapsed.Microseconds()
+		}
+		response.MaxTimeUs = max(response.MaxTimeUs, elapsed.Microseconds())
+	}
+	if response.Received != 0 {
+		response.AvgTimeUs = total.Microseconds() / int64(response.Received)
+	}
+	return response, nil
+}
+
+// Reboot restarts the SPDK application, the configuration of the bridge is restored on it once
+// it answers. It returns once the restart is requested
+func (s *Server) Reboot(_ context.Context, in *RebootRequest) (*emptypb.Empty, error) {
+	if s.restarter == nil {
+		msg := "Could not restart the SPDK application, it is not run by the bridge, start it with -spdk_app"
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	slog.Warn("Restarting the SPDK application", "message", in.Message)
+	s.restarter.Restart()
+	return &emptypb.Empty{}, nil
+}
+
+// ClearStats clears the stats of an Nvme controller or namespace, or of all of them
+func (s *Server) ClearStats(ctx context.Context, in *ClearStatsRequest) (*emptypb.Empty, error) {
+	if s.statsClearer == nil {
+		msg := "Could not clear the stats, not supported"
+		return nil, status.Errorf(codes.Unimplemented, msg)
+	}
+	if err := s.statsClearer.ClearNvmeStats(ctx, in.Name); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// FactoryReset deletes the storage configuration, in the firmware and in the store. The
+// resources which can't be deleted are kept and reported, the reset can be done again
+func (s *Server) FactoryReset(ctx context.Context, _ *FactoryResetRequest) (*emptypb.Empty, error) {
+	slog.Warn("Deleting the storage configuration")
+	var errs []error
+	for _, reset := range s.resets {
+		errs = append(errs, reset(ctx))
+	}
+	if err := errors.Join(errs...); err != nil {
+		msg := fmt.Sprintf("Could not delete the whole storage configuration: %v", err)
+		return nil, status.Errorf(codes.Aborted, msg)
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package gnoi implements operational methods of the storage of the DPU modeled on the gNOI
+// System service, so the NOC automation operates it as the other functions of the DPU
+package gnoi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testJSONRPC answers the pings but the failing ones
+type testJSONRPC struct {
+	spdk.JSONRPC
+	calls   int
+	failing map[int]bool
+}
+
+func (c *testJSONRPC) Call(_ context.Context, _ string, _, result interface{}) error {
+	c.calls++
+	if c.failing[c.calls] {
+		return errors.New("connection refused")
+	}
+	return json.Unmarshal([]byte(`{"version": "SPDK v23.01"}`), result)
+}
+
+// testRestarter counts the restarts
+type testRestarter struct {
+	restarts int
+}
+
+func (r *testRestarter) Restart() {
+	r.restarts++
+}
+
+func TestGnoi_Ping(t *testing.T) {
+	tests := map[string]struct {
+		in       *PingRequest
+		failing  map[int]bool
+		sent     int32
+		received int32
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"one ping": {
+			in:       &PingRequest{},
+			sent:     1,
+			received: 1,
+			errCode:  codes.OK,
+		},
+		"lost pings": {
+			in:       &PingRequest{Count: 5},
+			failing:  map[int]bool{2: true, 4: true},
+			sent:     5,
+			received: 3,
+			errCode:  codes.OK,
+		},
+		"invalid count": {
+			in:      &PingRequest{Count: 11},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Count (11) is invalid, have to be between 1 and 10",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := NewServer(&testJSONRPC{failing: tt.failing})
+
+			response, err := server.Ping(context.Background(), tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err != nil {
+				return
+			}
+			if response.Sent != tt.sent || response.Received != tt.received {
+				t.Error("pings: expected", tt.sent, tt.received, "received", response.Sent, response.Received)
+			}
+			if response.Version != "SPDK v23.01" {
+				t.Error("version: expected SPDK v23.01, received", response.Version)
+			}
+			if response.MinTimeUs > response.AvgTimeUs || response.AvgTimeUs > response.MaxTimeUs {
+				t.Error("times: expected min <= avg <= max, received", response.MinTimeUs, response.AvgTimeUs, response.MaxTimeUs)
+			}
+		})
+	}
+}
+
+func TestGnoi_Reboot(t *testing.T) {
+	server := NewServer(&testJSONRPC{})
+	_, err := server.Reboot(context.Background(), &RebootRequest{Message: "maintenance"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Error("error code: expected", codes.FailedPrecondition, "received", status.Code(err))
+	}
+
+	restarter := &testRestarter{}
+	server.SetRestarter(restarter)
+	if _, err := server.Reboot(context.Background(), &RebootRequest{Message: "maintenance"}); err != nil {
+		t.Fatal(err)
+	}
+	if restarter.restarts != 1 {
+		t.Error("restarts: expected 1, received", restarter.restarts)
+	}
+}
+
+func TestGnoi_FactoryReset(t *testing.T) {
+	server := NewServer(&testJSONRPC{})
+	var resets []string
+	server.SetFactoryReset(func(context.Context) error {
+		resets = append(resets, "frontend")
+		return errors.New("namespace ns0 in use")
+	}, func(context.Context) error {
+		resets = append(resets, "backend")
+		return nil
+	})
+
+	_, err := server.FactoryReset(context.Background(), &FactoryResetRequest{})
+
+	// the reset goes on after a failure
+	if len(resets) != 2 || resets[0] != "frontend" {
+		t.Error("resets: expected [frontend backend], received", resets)
+	}
+	expected := "Could not delete the whole storage configuration: namespace ns0 in use"
+	if status.Code(err) != codes.Aborted || status.Convert(err).Message() != expected {
+		t.Error("error: expected", expected, "received", err)
+	}
+}
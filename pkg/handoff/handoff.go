@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package handoff upgrades the bridge without downtime, the new binary inherits the listening
+// sockets and the in-memory state of the running one, which drains once the new one serves
+package handoff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables the inherited file descriptors are passed in
+const (
+	// listenersEnv lists the inherited listeners in name=fd format, comma separated
+	listenersEnv = "OPI_HANDOFF_LISTENERS"
+	// stateEnv is the pipe the state of the previous bridge is read from
+	stateEnv = "OPI_HANDOFF_STATE_FD"
+	// readyEnv is the pipe the previous bridge is told the new one serves on
+	readyEnv = "OPI_HANDOFF_READY_FD"
+)
+
+// filer is a listener whose socket can be inherited, i.e. *net.TCPListener
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Listen returns the listener inherited under name, or else listens on the TCP address
+func Listen(name string, address string) (net.Listener, error) {
+	for _, listener := range strings.Split(os.Getenv(listenersEnv), ",") {
+		listenerName, fd, ok := strings.Cut(listener, "=")
+		if !ok || listenerName != name {
+			continue
+		}
+		file, err := inherited(fd, name)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = file.Close() }()
+		return net.FileListener(file)
+	}
+	return net.Listen("tcp", address)
+}
+
+// State returns the state handed over by the previous bridge, nil when the bridge wasn't
+// started by an upgrade
+func State() ([]byte, error) {
+	fd := os.Getenv(stateEnv)
+	if fd == "" {
+		return nil, nil
+	}
+	file, err := inherited(fd, "state")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return io.ReadAll(file)
+}
+
+// Ready tells the previous bridge the new one serves, it does nothing when the bridge wasn't
+// started by an upgrade
+func Ready() error {
+	fd := os.Getenv(readyEnv)
+	if fd == "" {
+		return nil
+	}
+	file, err := inherited(fd, "ready")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	_, err = file.Write([]byte{1})
+	return err
+}
+
+// inherited returns the file of an inherited file descriptor
+func inherited(fd string, name string) (*os.File, error) {
+	n, err := strconv.Atoi(fd)
+	if err != nil || n < 3 {
+		return nil, fmt.Errorf("invalid inherited file descriptor %q of %s", fd, name)
+	}
+	return os.NewFile(uintptr(n), name), nil
+}
+
+// Upgrade starts the binary of the bridge again, with the same arguments, the listeners and the
+// state, and returns its process once it called Ready. The process is killed when it isn't ready
+// within timeout, the running bridge then serves on
+func Upgrade(listeners map[string]net.Listener, state []byte, timeout time.Duration) (*os.Process, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// the descriptors of the ExtraFiles start at 3 in the new process
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}()
+	names := make([]string, 0, len(listeners))
+	for name := range listeners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var inheritedListeners []string
+	for _, name := range names {
+		l, ok := listeners[name].(filer)
+		if !ok {
+			return nil, fmt.Errorf("listener %s can't be handed over", name)
+		}
+		file, err := l.File()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		inheritedListeners = append(inheritedListeners, fmt.Sprintf("%s=%d", name, 2+len(files)))
+	}
+	stateReader, stateWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, stateReader)
+	stateFd := 2 + len(files)
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		_ = stateWriter.Close()
+		return nil, err
+	}
+	defer func() { _ = readyReader.Close() }()
+	files = append(files, readyWriter)
+	readyFd := 2 + len(files)
+	cmd.ExtraFiles = files
+	cmd.Env = append(environ(),
+		listenersEnv+"="+strings.Join(inheritedListeners, ","),
+		fmt.Sprintf("%s=%d", stateEnv, stateFd),
+		fmt.Sprintf("%s=%d", readyEnv, readyFd),
+	)
+	if err := cmd.Start(); err != nil {
+		_ = stateWriter.Close()
+		return nil, err
+	}
+	// the pipes end when the new process closes them or exits
+	_ = readyWriter.Close()
+	go func() {
+		defer func() { _ = stateWriter.Close() }()
+		_, _ = stateWriter.Write(state)
+	}()
+	ready := make(chan error, 1)
+	go func() {
+		if _, err := readyReader.Read(make([]byte, 1)); err != nil {
+			ready <- errors.New("new bridge exited before being ready")
+			return
+		}
+		ready <- nil
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(timeout):
+		err = fmt.Errorf("new bridge not ready after %v", timeout)
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+// environ returns the environment without the variables of a previous handoff
+func environ() []string {
+	var env []string
+	for _, variable := range os.Environ() {
+		if strings.HasPrefix(variable, listenersEnv+"=") || strings.HasPrefix(variable, stateEnv+"=") || strings.HasPrefix(variable, readyEnv+"=") {
+			continue
+		}
+		env = append(env, variable)
+	}
+	return env
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package handoff upgrades the bridge without downtime, the new binary inherits the listening
+// sockets and the in-memory state of the running one, which drains once the new one serves
+package handoff
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestHandoff_Listen(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	file, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	// Listen closes the descriptor it inherits
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		env     string
+		name    string
+		address string
+		errMsg  string
+	}{
+		"inherited": {
+			env:     fmt.Sprintf("http=1000,grpc=%d", fd),
+			name:    "grpc",
+			address: l.Addr().String(),
+		},
+		"not inherited": {
+			env:     fmt.Sprintf("grpc=%d", fd),
+			name:    "http",
+			address: "",
+		},
+		"invalid file descriptor": {
+			env:    "grpc=stdin",
+			name:   "grpc",
+			errMsg: "invalid inherited file descriptor \"stdin\" of grpc",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(listenersEnv, tt.env)
+			listener, err := Listen(tt.name, "127.0.0.1:0")
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = listener.Close() }()
+			if tt.address != "" && listener.Addr().String() != tt.address {
+				t.Error("address: expected", tt.address, "received", listener.Addr())
+			}
+			if tt.address == "" && listener.Addr().String() == l.Addr().String() {
+				t.Error("address: expected a new listener, received", listener.Addr())
+			}
+		})
+	}
+}
+
+func TestHandoff_StateAndReady(t *testing.T) {
+	stateReader, stateWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = readyReader.Close() }()
+	// State and Ready close the descriptors they inherit
+	stateFd, err := syscall.Dup(int(stateReader.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = stateReader.Close()
+	readyFd, err := syscall.Dup(int(readyWriter.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = readyWriter.Close()
+	t.Setenv(stateEnv, fmt.Sprint(stateFd))
+	t.Setenv(readyEnv, fmt.Sprint(readyFd))
+	go func() {
+		_, _ = stateWriter.Write([]byte(`{"events":[]}`))
+		_ = stateWriter.Close()
+	}()
+
+	state, err := State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(state) != `{"events":[]}` {
+		t.Error("state: expected", `{"events":[]}`, "received", string(state))
+	}
+	if err := Ready(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := readyReader.Read(make([]byte, 1)); n != 1 || err != nil {
+		t.Error("ready: expected a byte, received", n, err)
+	}
+}
+
+func TestHandoff_NotUpgraded(t *testing.T) {
+	t.Setenv(stateEnv, "")
+	t.Setenv(readyEnv, "")
+	state, err := State()
+	if state != nil || err != nil {
+		t.Error("state: expected none, received", state, err)
+	}
+	if err := Ready(); err != nil {
+		t.Error("ready: expected no error, received", err)
+	}
+}
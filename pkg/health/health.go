@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package health reports whether the services of the bridge can serve, the firmware has to be
+// reachable and the last reconciliation of their resources successful, and whether the bridge
+// is ready
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/philippgille/gokv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// probeTimeout bounds a probe of the firmware, a stuck firmware is unreachable
+const probeTimeout = 5 * time.Second
+
+// Checker sets the statuses of the gRPC health service, the overall one, the empty service,
+// and the one of each service
+type Checker struct {
+	mu     sync.Mutex
+	rpc    spdk.JSONRPC
+	server *health.Server
+	// services are all the services, they need the firmware
+	services []string
+	// reconciled are the services which also need the last reconciliation to succeed
+	reconciled map[string]bool
+	// probeErr is the error of the last probe of the firmware, set until the first probe
+	probeErr error
+	// reconcileErr is the error of the last reconciliation
+	reconcileErr error
+	// store keeps the state of the bridge, it is probed for the readiness when set
+	store gokv.Store
+	// storeErr is the error of the last probe of the store
+	storeErr error
+	// draining is set once the bridge stops, the services don't serve anymore
+	draining bool
+}
+
+// storeProbeKey is the key read to probe the store
+const storeProbeKey = "//storage.opiproject.org/health/probe"
+
+// errNotProbed is the state of the firmware until it is probed
+var errNotProbed = errors.New("firmware not probed yet")
+
+// errDraining is the state of the bridge once it stops
+var errDraining = errors.New("bridge is stopping")
+
+// NewChecker creates a checker probing the firmware with rpc, the reconciled services, i.e.
+// opi_api.storage.v1.NvmeRemoteControllerService, also need the last reconciliation to succeed
+func NewChecker(rpc spdk.JSONRPC, reconciled ...string) *Checker {
+	c := &Checker{
+		rpc:        rpc,
+		server:     health.NewServer(),
+		reconciled: make(map[string]bool),
+		probeErr:   errNotProbed,
+	}
+	for _, service := range reconciled {
+		c.reconciled[service] = true
+	}
+	c.server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// Register registers the health service on a gRPC server, after all the other services so
+// they all get a status
+func (c *Checker) Register(s *grpc.Server) {
+	c.mu.Lock()
+	for service := range s.GetServiceInfo() {
+		c.services = append(c.services, service)
+	}
+	c.update()
+	c.mu.Unlock()
+	healthpb.RegisterHealthServer(s, c.server)
+}
+
+// Server returns the health service, i.e. to check it without a gRPC server
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// ReportReconcile records the outcome of a reconciliation, it is a backend.ReconcileReporter
+func (c *Checker) ReportReconcile(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && c.reconcileErr == nil {
+		slog.Warn("Reconciliation failed, the reconciled services are not serving", "error", err)
+	}
+	if err == nil && c.reconcileErr != nil {
+		slog.Info("Reconciliation succeeded again")
+	}
+	c.reconcileErr = err
+	c.update()
+}
+
+// SetStore sets the store keeping the state of the bridge, the bridge is not ready while it
+// can't be read
+func (c *Checker) SetStore(store gokv.Store) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+	c.storeErr = errNotProbed
+}
+
+// Probe checks the firmware answers, the statuses are updated, and the store can be read
+func (c *Checker) Probe(ctx context.Context) error {
+	c.probeStore()
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	var result spdk.GetVersionResult
+	err := c.rpc.Call(ctx, "spdk_get_version", nil, &result)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && (c.probeErr == nil || errors.Is(c.probeErr, errNotProbed)) {
+		slog.Warn("Firmware unreachable, the services are not serving", "error", err)
+	}
+	if err == nil && c.probeErr != nil {
+		slog.Info("Firmware reachable, the services are serving", "version", result.Version)
+	}
+	c.probeErr = err
+	c.update()
+	return err
+}
+
+// probeStore reads a key which is never written, the store answers not found when it is reachable
+func (c *Checker) probeStore() {
+	c.mu.Lock()
+	store := c.store
+	c.mu.Unlock()
+	if store == nil {
+		return
+	}
+	_, err := store.Get(storeProbeKey, new(wrapperspb.BytesValue))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && (c.storeErr == nil || errors.Is(c.storeErr, errNotProbed)) {
+		slog.Warn("Store unreachable, the bridge is not ready", "error", err)
+	}
+	c.storeErr = err
+}
+
+// Drain sets all the services as not serving for good, the load balancers stop sending requests
+// while the ones in flight complete
+func (c *Checker) Drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.draining = true
+	c.server.Shutdown()
+}
+
+// Ready returns why the bridge is not ready, nil once the firmware answers and the state of the
+// bridge can be read from the store
+func (c *Checker) Ready() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draining {
+		return errDraining
+	}
+	if c.probeErr != nil {
+		return fmt.Errorf("firmware not connected: %w", c.probeErr)
+	}
+	if c.storeErr != nil {
+		return fmt.Errorf("state not loaded: %w", c.storeErr)
+	}
+	return nil
+}
+
+// ServeLive answers the liveness probes, the bridge is alive as long as it serves HTTP
+func (c *Checker) ServeLive(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// ServeReady answers the readiness probes, with 503 Service Unavailable and the reason when the
+// bridge is not ready
+func (c *Checker) ServeReady(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if err := c.Ready(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// Watch probes the firmware every interval until ctx is done
+func (c *Checker) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_ = c.Probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update sets the statuses from the last probe and reconciliation, the overall status is
+// serving when all the services are
+func (c *Checker) update() {
+	overall := healthpb.HealthCheckResponse_SERVING
+	for _, service := range c.services {
+		serving := c.probeErr == nil && (!c.reconciled[service] || c.reconcileErr == nil)
+		status := healthpb.HealthCheckResponse_SERVING
+		if !serving {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		c.server.SetServingStatus(service, status)
+	}
+	if c.probeErr != nil {
+		overall = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	c.server.SetServingStatus("", overall)
+}
@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package health reports whether the services of the bridge can serve, the firmware has to be
+// reachable and the last reconciliation of their resources successful, and whether the bridge
+// is ready
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/philippgille/gokv"
+	"github.com/philippgille/gokv/gomap"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	testFrontendService = "opi_api.storage.v1.FrontendNvmeService"
+	testBackendService  = "opi_api.storage.v1.NvmeRemoteControllerService"
+)
+
+// testJSONRPC answers the calls with an error
+type testJSONRPC struct {
+	spdk.JSONRPC
+	err error
+}
+
+func (c *testJSONRPC) Call(_ context.Context, _ string, _, _ interface{}) error {
+	return c.err
+}
+
+// testServer creates a gRPC server with the test services
+func testServer() *grpc.Server {
+	s := grpc.NewServer()
+	for _, service := range []string{testFrontendService, testBackendService} {
+		s.RegisterService(&grpc.ServiceDesc{ServiceName: service, HandlerType: (*interface{})(nil)}, struct{}{})
+	}
+	return s
+}
+
+func TestHealth_Statuses(t *testing.T) {
+	serving, notServing := healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_NOT_SERVING
+	tests := map[string]struct {
+		probe     bool
+		probeErr  error
+		reconcile error
+		statuses  map[string]healthpb.HealthCheckResponse_ServingStatus
+	}{
+		"not probed yet": {
+			probe:     false,
+			probeErr:  nil,
+			reconcile: nil,
+			statuses:  map[string]healthpb.HealthCheckResponse_ServingStatus{"": notServing, testFrontendService: notServing, testBackendService: notServing},
+		},
+		"all serving": {
+			probe:     true,
+			probeErr:  nil,
+			reconcile: nil,
+			statuses:  map[string]healthpb.HealthCheckResponse_ServingStatus{"": serving, testFrontendService: serving, testBackendService: serving},
+		},
+		"firmware unreachable": {
+			probe:     true,
+			probeErr:  errors.New("dial unix /var/tmp/spdk.sock: connect: no such file or directory"),
+			reconcile: nil,
+			statuses:  map[string]healthpb.HealthCheckResponse_ServingStatus{"": notServing, testFrontendService: notServing, testBackendService: notServing},
+		},
+		"failed reconciliation": {
+			probe:     true,
+			probeErr:  nil,
+			reconcile: errors.New("cdc0: discovery log of cdc0 not available, status 1"),
+			statuses:  map[string]healthpb.HealthCheckResponse_ServingStatus{"": notServing, testFrontendService: serving, testBackendService: notServing},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			checker := NewChecker(&testJSONRPC{err: tt.probeErr}, testBackendService)
+			checker.Register(testServer())
+			checker.ReportReconcile(tt.reconcile)
+			if tt.probe {
+				if err := checker.Probe(context.Background()); !errors.Is(err, tt.probeErr) {
+					t.Error("probe: expected", tt.probeErr, "received", err)
+				}
+			}
+
+			for service, expected := range tt.statuses {
+				response, err := checker.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response.GetStatus() != expected {
+					t.Error("status of", service, ": expected", expected, "received", response.GetStatus())
+				}
+			}
+		})
+	}
+}
+
+func TestHealth_Recovery(t *testing.T) {
+	rpc := &testJSONRPC{err: errors.New("firmware restarting")}
+	checker := NewChecker(rpc, testBackendService)
+	checker.Register(testServer())
+	_ = checker.Probe(context.Background())
+	rpc.err = nil
+	_ = checker.Probe(context.Background())
+
+	response, err := checker.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Error("status: expected", healthpb.HealthCheckResponse_SERVING, "received", response.GetStatus())
+	}
+}
+
+func TestHealth_Drain(t *testing.T) {
+	checker := NewChecker(&testJSONRPC{}, testBackendService)
+	checker.Register(testServer())
+	_ = checker.Probe(context.Background())
+	checker.Drain()
+	// the probes after the drain don't change the statuses
+	_ = checker.Probe(context.Background())
+
+	for _, service := range []string{"", testBackendService} {
+		response, err := checker.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Error("status of", service, ": expected", healthpb.HealthCheckResponse_NOT_SERVING, "received", response.GetStatus())
+		}
+	}
+	w := httptest.NewRecorder()
+	checker.ServeReady(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Error("code: expected", http.StatusServiceUnavailable, "received", w.Code)
+	}
+	if w.Body.String() != "bridge is stopping\n" {
+		t.Error("body: expected bridge is stopping, received", w.Body.String())
+	}
+}
+
+// testStore is a store which can't be reached
+type testStore struct {
+	gokv.Store
+}
+
+func (s *testStore) Get(_ string, _ interface{}) (bool, error) {
+	return false, errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+}
+
+func TestHealth_ServeReady(t *testing.T) {
+	tests := map[string]struct {
+		probeErr error
+		store    gokv.Store
+		probe    bool
+		code     int
+		body     string
+	}{
+		"ready": {
+			probeErr: nil,
+			store:    gomap.NewStore(gomap.DefaultOptions),
+			probe:    true,
+			code:     http.StatusOK,
+			body:     "ok\n",
+		},
+		"not probed yet": {
+			probeErr: nil,
+			store:    gomap.NewStore(gomap.DefaultOptions),
+			probe:    false,
+			code:     http.StatusServiceUnavailable,
+			body:     "firmware not connected: firmware not probed yet\n",
+		},
+		"firmware unreachable": {
+			probeErr: errors.New("connection refused"),
+			store:    gomap.NewStore(gomap.DefaultOptions),
+			probe:    true,
+			code:     http.StatusServiceUnavailable,
+			body:     "firmware not connected: connection refused\n",
+		},
+		"store unreachable": {
+			probeErr: nil,
+			store:    &testStore{},
+			probe:    true,
+			code:     http.StatusServiceUnavailable,
+			body:     "state not loaded: dial tcp 127.0.0.1:6379: connect: connection refused\n",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			checker := NewChecker(&testJSONRPC{err: tt.probeErr})
+			checker.SetStore(tt.store)
+			if tt.probe {
+				_ = checker.Probe(context.Background())
+			}
+
+			w := httptest.NewRecorder()
+			checker.ServeReady(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tt.code {
+				t.Error("code: expected", tt.code, "received", w.Code)
+			}
+			if w.Body.String() != tt.body {
+				t.Error("body: expected", tt.body, "received", w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			checker.ServeLive(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if w.Code != http.StatusOK {
+				t.Error("liveness: expected", http.StatusOK, "received", w.Code)
+			}
+		})
+	}
+}
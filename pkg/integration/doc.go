@@ -0,0 +1,7 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package integration runs the gRPC APIs end-to-end against a running bridge, calling the Marvell
+// firmware of an SPDK target or the emulated one, its tests only build with the integration tag
+// and are run by docker-compose.integration.yml
+package integration
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+//go:build integration
+
+// Package integration runs the gRPC APIs end-to-end against a running bridge, calling the Marvell
+// firmware of an SPDK target or the emulated one, its tests only build with the integration tag
+// and are run by docker-compose.integration.yml
+package integration
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+const (
+	testVolumeID     = "integration-malloc"
+	testSubsystemID  = "integration-subsystem"
+	testControllerID = "integration-controller"
+	testNamespaceID  = "integration-namespace"
+	testNqn          = "nqn.2022-09.io.spdk:integration"
+)
+
+// dial connects to the bridge at OPI_BRIDGE_ADDR, localhost:50051 when unset
+func dial(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	address := os.Getenv("OPI_BRIDGE_ADDR")
+	if address == "" {
+		address = "localhost:50051"
+	}
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestIntegration_NvmeLifecycle(t *testing.T) {
+	conn := dial(t)
+	backend := pb.NewMallocVolumeServiceClient(conn)
+	frontend := pb.NewFrontendNvmeServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	volumeName := utils.ResourceIDToVolumeName(testVolumeID)
+	subsystemName := utils.ResourceIDToSubsystemName(testSubsystemID)
+	controllerName := utils.ResourceIDToControllerName(testSubsystemID, testControllerID)
+	namespaceName := utils.ResourceIDToNamespaceName(testSubsystemID, testNamespaceID)
+	// the resources of a failed run are removed first, and once the test is done
+	cleanup := func() {
+		_, _ = frontend.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{Name: namespaceName, AllowMissing: true})
+		_, _ = frontend.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{Name: controllerName, AllowMissing: true})
+		_, _ = frontend.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: subsystemName, AllowMissing: true})
+		_, _ = backend.DeleteMallocVolume(ctx, &pb.DeleteMallocVolumeRequest{Name: volumeName, AllowMissing: true})
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	_, err := backend.CreateMallocVolume(ctx, &pb.CreateMallocVolumeRequest{
+		MallocVolumeId: testVolumeID,
+		MallocVolume:   &pb.MallocVolume{BlockSize: 512, BlocksCount: 2048},
+	})
+	if err != nil {
+		t.Fatal("create volume:", err)
+	}
+	subsystem, err := frontend.CreateNvmeSubsystem(ctx, &pb.CreateNvmeSubsystemRequest{
+		NvmeSubsystemId: testSubsystemID,
+		NvmeSubsystem:   &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{Nqn: testNqn, MaxNamespaces: 4}},
+	})
+	if err != nil {
+		t.Fatal("create subsystem:", err)
+	}
+	if subsystem.Name != subsystemName {
+		t.Error("subsystem: expected", subsystemName, "received", subsystem.Name)
+	}
+	if subsystem.Status.GetFirmwareRevision() == "" {
+		t.Error("subsystem: expected the firmware revision to be reported")
+	}
+	_, err = frontend.CreateNvmeController(ctx, &pb.CreateNvmeControllerRequest{
+		Parent:           subsystemName,
+		NvmeControllerId: testControllerID,
+		NvmeController: &pb.NvmeController{Spec: &pb.NvmeControllerSpec{
+			Endpoint: &pb.NvmeControllerSpec_PcieId{
+				PcieId: &pb.PciEndpoint{
+					PhysicalFunction: wrapperspb.Int32(1),
+					VirtualFunction:  wrapperspb.Int32(0),
+					PortId:           wrapperspb.Int32(0)},
+			},
+			Trtype:           pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+			NvmeControllerId: proto.Int32(17),
+		}},
+	})
+	if err != nil {
+		t.Fatal("create controller:", err)
+	}
+	_, err = frontend.CreateNvmeNamespace(ctx, &pb.CreateNvmeNamespaceRequest{
+		Parent:          subsystemName,
+		NvmeNamespaceId: testNamespaceID,
+		NvmeNamespace:   &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{HostNsid: 1, VolumeNameRef: testVolumeID}},
+	})
+	if err != nil {
+		t.Fatal("create namespace:", err)
+	}
+
+	namespace, err := frontend.GetNvmeNamespace(ctx, &pb.GetNvmeNamespaceRequest{Name: namespaceName})
+	if err != nil {
+		t.Fatal("get namespace:", err)
+	}
+	if namespace.Spec.VolumeNameRef != testVolumeID {
+		t.Error("namespace: expected", testVolumeID, "received", namespace.Spec.VolumeNameRef)
+	}
+	controllers, err := frontend.ListNvmeControllers(ctx, &pb.ListNvmeControllersRequest{Parent: subsystemName})
+	if err != nil {
+		t.Fatal("list controllers:", err)
+	}
+	if len(controllers.NvmeControllers) != 1 {
+		t.Error("controllers: expected 1, received", len(controllers.NvmeControllers))
+	}
+	if _, err := frontend.StatsNvmeNamespace(ctx, &pb.StatsNvmeNamespaceRequest{Name: namespaceName}); err != nil {
+		t.Error("namespace stats:", err)
+	}
+
+	if _, err := frontend.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{Name: namespaceName}); err != nil {
+		t.Fatal("delete namespace:", err)
+	}
+	if _, err := frontend.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{Name: controllerName}); err != nil {
+		t.Fatal("delete controller:", err)
+	}
+	if _, err := frontend.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{Name: subsystemName}); err != nil {
+		t.Fatal("delete subsystem:", err)
+	}
+	if _, err := backend.DeleteMallocVolume(ctx, &pb.DeleteMallocVolumeRequest{Name: volumeName}); err != nil {
+		t.Fatal("delete volume:", err)
+	}
+	_, err = frontend.GetNvmeSubsystem(ctx, &pb.GetNvmeSubsystemRequest{Name: subsystemName})
+	if status.Code(err) != codes.NotFound {
+		t.Error("get deleted subsystem: expected", codes.NotFound, "received", err)
+	}
+}
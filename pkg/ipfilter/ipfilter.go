@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ipfilter accepts the connections of the clients by their source address, from allowed
+// and denied CIDR lists, as the DPU management networks are often restricted at the network level
+// on top of the authentication
+package ipfilter
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// Filter decides the source addresses the connections are accepted from. The denied prefixes
+// win over the allowed ones, every address is allowed when none is, and the loopback addresses
+// are always accepted as the HTTP gateway calls the gRPC server through them
+type Filter struct {
+	mu      sync.RWMutex
+	allowed []netip.Prefix
+	denied  []netip.Prefix
+}
+
+// New returns a Filter of comma separated CIDR lists, a single address is a prefix of its full
+// length, i.e. 10.0.0.0/8,192.168.1.5
+func New(allowed string, denied string) (*Filter, error) {
+	f := &Filter{}
+	if err := f.SetPrefixes(allowed, denied); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetPrefixes changes the lists, as New does, the connections already accepted are kept
+func (f *Filter) SetPrefixes(allowed string, denied string) error {
+	allowedPrefixes, err := ParsePrefixes(allowed)
+	if err != nil {
+		return err
+	}
+	deniedPrefixes, err := ParsePrefixes(denied)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allowed, f.denied = allowedPrefixes, deniedPrefixes
+	return nil
+}
+
+// ParsePrefixes parses a comma separated CIDR list, an empty one has no prefix
+func ParsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q, have to be an address or a prefix, i.e. 10.0.0.0/8", s)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q, have to be an address or a prefix, i.e. 10.0.0.0/8", s)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allowed checks the connections from addr are accepted
+func (f *Filter) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() {
+		return true
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, prefix := range f.denied {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(f.allowed) == 0 {
+		return true
+	}
+	for _, prefix := range f.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Listen returns a listener accepting the connections of lis the Filter allows, the others are
+// closed as soon as they are accepted
+func (f *Filter) Listen(lis net.Listener) net.Listener {
+	return &listener{Listener: lis, filter: f}
+}
+
+// listener closes the connections the filter denies
+type listener struct {
+	net.Listener
+	filter *Filter
+}
+
+// Accept waits for the next connection the filter allows
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		addr, err := remoteAddr(conn)
+		if err != nil || l.filter.Allowed(addr) {
+			// the connections without an IP address, i.e. on a Unix socket, aren't filtered
+			return conn, nil
+		}
+		slog.Warn("Refused connection of a denied address", "address", conn.RemoteAddr().String(), "listener", l.Addr().String())
+		_ = conn.Close()
+	}
+}
+
+// remoteAddr returns the IP address a connection comes from
+func remoteAddr(conn net.Conn) (netip.Addr, error) {
+	tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return netip.Addr{}, errors.New("not a TCP connection")
+	}
+	return tcpAddr.AddrPort().Addr(), nil
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ipfilter accepts the connections of the clients by their source address, from allowed
+// and denied CIDR lists, as the DPU management networks are often restricted at the network level
+// on top of the authentication
+package ipfilter
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestIPFilter_Allowed(t *testing.T) {
+	tests := map[string]struct {
+		allowed  string
+		denied   string
+		addr     string
+		expected bool
+	}{
+		"no list":                 {addr: "203.0.113.7", expected: true},
+		"allowed prefix":          {allowed: "10.0.0.0/8, 192.168.1.5", addr: "10.1.2.3", expected: true},
+		"allowed address":         {allowed: "10.0.0.0/8, 192.168.1.5", addr: "192.168.1.5", expected: true},
+		"not allowed":             {allowed: "10.0.0.0/8, 192.168.1.5", addr: "192.168.1.6", expected: false},
+		"denied":                  {denied: "10.9.0.0/16", addr: "10.9.1.1", expected: false},
+		"denied wins":             {allowed: "10.0.0.0/8", denied: "10.9.0.0/16", addr: "10.9.1.1", expected: false},
+		"IPv4 mapped":             {allowed: "10.0.0.0/8", addr: "::ffff:10.1.2.3", expected: true},
+		"IPv6":                    {allowed: "fd00::/8", addr: "fd12::1", expected: true},
+		"loopback always allowed": {allowed: "10.0.0.0/8", denied: "127.0.0.0/8", addr: "127.0.0.1", expected: true},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			filter, err := New(tt.allowed, tt.denied)
+			if err != nil {
+				t.Fatal(err)
+			}
+			allowed := filter.Allowed(netip.MustParseAddr(tt.addr))
+			if allowed != tt.expected {
+				t.Error("allowed: expected", tt.expected, "received", allowed)
+			}
+		})
+	}
+}
+
+func TestIPFilter_New(t *testing.T) {
+	_, err := New("10.0.0.0/33", "")
+	expected := `invalid CIDR "10.0.0.0/33", have to be an address or a prefix, i.e. 10.0.0.0/8`
+	if err == nil || err.Error() != expected {
+		t.Error("error: expected", expected, "received", err)
+	}
+}
+
+func TestIPFilter_Listen(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := New("10.0.0.0/8", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := filter.Listen(lis)
+	defer func() { _ = filtered.Close() }()
+
+	go func() {
+		conn, err := net.Dial("tcp", lis.Addr().String())
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+	// the loopback connection is accepted whatever the lists
+	conn, err := filtered.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+}
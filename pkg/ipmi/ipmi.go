@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ipmi pushes the health of the storage emulation to sensors of the BMC of the platform,
+// so the hardware monitoring sees it as the other components of the server
+package ipmi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/fanout"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+// Indicators of the storage health pushed to the sensors
+const (
+	// ControllersActive is the number of the active Nvme controllers
+	ControllersActive = "controllers_active"
+	// MediaWear is the highest percentage used of the NVMe drives attached to the DPU
+	MediaWear = "media_wear"
+	// PathFailures is the number of the Nvme paths not connected
+	PathFailures = "path_failures"
+)
+
+// maxReading is the highest reading of a sensor, the higher values are pushed as it
+const maxReading = 255
+
+// PathCounter counts the Nvme paths to the remote targets which are not connected
+type PathCounter interface {
+	CountDisconnectedNvmePaths() int
+}
+
+// Sender sends the IPMI requests to the BMC
+type Sender interface {
+	// SetSensorReading sets the reading of a sensor
+	SetSensorReading(ctx context.Context, sensor uint8, reading uint8) error
+}
+
+// ParseSensors parses indicator=sensor pairs, comma separated, the sensor numbers being decimal
+// or hexadecimal, i.e. controllers_active=0x60,media_wear=0x61
+func ParseSensors(value string) (map[string]uint8, error) {
+	sensors := make(map[string]uint8)
+	for _, pair := range strings.Split(value, ",") {
+		indicator, number, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid sensor %q, have to be indicator=number", pair)
+		}
+		switch indicator {
+		case ControllersActive, MediaWear, PathFailures:
+		default:
+			return nil, fmt.Errorf("invalid indicator %s, have to be %s, %s or %s", indicator, ControllersActive, MediaWear, PathFailures)
+		}
+		sensor, err := strconv.ParseUint(number, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensor number %s of %s, have to be between 0 and 255", number, indicator)
+		}
+		sensors[indicator] = uint8(sensor)
+	}
+	return sensors, nil
+}
+
+// Reporter pushes the indicators of the storage health to their sensors
+type Reporter struct {
+	sender   Sender
+	sensors  map[string]uint8
+	frontend pb.FrontendNvmeServiceServer
+	paths    PathCounter
+	rpc      spdk.JSONRPC
+}
+
+// NewReporter returns a Reporter pushing the indicators of sensors with sender, the controllers
+// are listed from frontend, the paths counted by paths and the drives asked with jsonRPC
+func NewReporter(sender Sender, sensors map[string]uint8, frontend pb.FrontendNvmeServiceServer, paths PathCounter, jsonRPC spdk.JSONRPC) *Reporter {
+	if sender == nil {
+		log.Panic("nil for Sender is not allowed")
+	}
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	return &Reporter{
+		sender:   sender,
+		sensors:  sensors,
+		frontend: frontend,
+		paths:    paths,
+		rpc:      jsonRPC,
+	}
+}
+
+// Run pushes the indicators every interval until ctx is done
+func (r *Reporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.Push(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push pushes the indicators once, the ones which can't be read or sent are logged and pushed
+// again next time
+func (r *Reporter) Push(ctx context.Context) {
+	indicators := make([]string, 0, len(r.sensors))
+	for indicator := range r.sensors {
+		indicators = append(indicators, indicator)
+	}
+	sort.Strings(indicators)
+	for _, indicator := range indicators {
+		value, err := r.read(ctx, indicator)
+		if err != nil {
+			slog.Warn("Could not read storage health indicator", "indicator", indicator, "error", err)
+			continue
+		}
+		reading := uint8(min(max(value, 0), maxReading))
+		if err := r.sender.SetSensorReading(ctx, r.sensors[indicator], reading); err != nil {
+			slog.Warn("Could not set BMC sensor reading", "indicator", indicator, "sensor", r.sensors[indicator], "error", err)
+		}
+	}
+}
+
+// read reads the value of an indicator
+func (r *Reporter) read(ctx context.Context, indicator string) (int, error) {
+	switch indicator {
+	case ControllersActive:
+		return r.activeControllers(ctx)
+	case MediaWear:
+		return r.mediaWear(ctx)
+	default:
+		return r.paths.CountDisconnectedNvmePaths(), nil
+	}
+}
+
+// activeControllers counts the active Nvme controllers of all the subsystems
+func (r *Reporter) activeControllers(ctx context.Context) (int, error) {
+	active := 0
+	subsystemToken := ""
+	for {
+		subsystems, err := r.frontend.ListNvmeSubsystems(ctx, &pb.ListNvmeSubsystemsRequest{PageToken: subsystemToken})
+		if err != nil {
+			return 0, err
+		}
+		for _, subsystem := range subsystems.NvmeSubsystems {
+			controllerToken := ""
+			for {
+				controllers, err := r.frontend.ListNvmeControllers(ctx, &pb.ListNvmeControllersRequest{Parent: subsystem.Name, PageToken: controllerToken})
+				if err != nil {
+					return 0, err
+				}
+				for _, controller := range controllers.NvmeControllers {
+					if controller.GetStatus().GetActive() {
+						active++
+					}
+				}
+				if controllerToken = controllers.NextPageToken; controllerToken == "" {
+					break
+				}
+			}
+		}
+		if subsystemToken = subsystems.NextPageToken; subsystemToken == "" {
+			return active, nil
+		}
+	}
+}
+
+// mediaWear returns the highest percentage used of the NVMe drives attached to the DPU
+func (r *Reporter) mediaWear(ctx context.Context) (int, error) {
+	var inventory models.MrvlPlatformGetInventoryResult
+	err := r.rpc.Call(ctx, "mrvl_platform_get_inventory", nil, &inventory)
+	if err != nil {
+		return 0, err
+	}
+	if inventory.Status != 0 {
+		return 0, fmt.Errorf("could not get the platform inventory, status %s", inventory.Status.Name("mrvl_platform_get_inventory"))
+	}
+	devices := make([]string, 0, len(inventory.Drives))
+	for _, drive := range inventory.Drives {
+		devices = append(devices, drive.Device)
+	}
+	// the SMART logs of the drives are read in parallel
+	used, err := fanout.Map(ctx, fanout.DefaultWorkers, devices, func(ctx context.Context, device string) (int, error) {
+		params := models.MrvlNvmGetSmartLogParams{Device: device}
+		var result models.MrvlNvmGetSmartLogResult
+		err := r.rpc.Call(ctx, "mrvl_nvm_get_smart_log", &params, &result)
+		if err != nil {
+			return 0, err
+		}
+		if result.Status != 0 {
+			return 0, fmt.Errorf("could not get the SMART log of %s, status %s", device, result.Status.Name("mrvl_nvm_get_smart_log"))
+		}
+		return result.PercentageUsed, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	wear := 0
+	for _, percentage := range used {
+		wear = max(wear, percentage)
+	}
+	return wear, nil
+}
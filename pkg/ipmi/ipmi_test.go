@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ipmi pushes the health of the storage emulation to sensors of the BMC of the platform,
+// so the hardware monitoring sees it as the other components of the server
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+// testFrontend serves the subsystems subsys0 and subsys1, with an active and an inactive
+// controller each
+type testFrontend struct {
+	pb.UnimplementedFrontendNvmeServiceServer
+}
+
+func (f *testFrontend) ListNvmeSubsystems(_ context.Context, in *pb.ListNvmeSubsystemsRequest) (*pb.ListNvmeSubsystemsResponse, error) {
+	if in.PageToken == "" {
+		return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0"}}, NextPageToken: "next"}, nil
+	}
+	return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{{Name: "//storage.opiproject.org/nvmeSubsystems/subsys1"}}}, nil
+}
+
+func (f *testFrontend) ListNvmeControllers(_ context.Context, in *pb.ListNvmeControllersRequest) (*pb.ListNvmeControllersResponse, error) {
+	return &pb.ListNvmeControllersResponse{NvmeControllers: []*pb.NvmeController{
+		{Name: in.Parent + "/nvmeControllers/ctrl0", Status: &pb.NvmeControllerStatus{Active: true}},
+		{Name: in.Parent + "/nvmeControllers/ctrl1", Status: &pb.NvmeControllerStatus{Active: false}},
+	}}, nil
+}
+
+// testJSONRPC answers the inventory with two drives and their SMART logs
+type testJSONRPC struct {
+	spdk.JSONRPC
+	wear []int
+	err  error
+}
+
+func (c *testJSONRPC) Call(_ context.Context, method string, params, result interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	switch r := result.(type) {
+	case *models.MrvlPlatformGetInventoryResult:
+		for _, device := range []string{"Nvme0", "Nvme1"} {
+			r.Drives = append(r.Drives, struct {
+				Device    string `json:"device"`
+				PcieAddr  string `json:"pcie_addr"`
+				Model     string `json:"model"`
+				Serial    string `json:"serial"`
+				FwRev     string `json:"fw_rev"`
+				SizeBytes uint64 `json:"size_bytes"`
+			}{Device: device})
+		}
+	case *models.MrvlNvmGetSmartLogResult:
+		if params.(*models.MrvlNvmGetSmartLogParams).Device == "Nvme0" {
+			r.PercentageUsed = c.wear[0]
+		} else {
+			r.PercentageUsed = c.wear[1]
+		}
+	default:
+		return errors.New("unexpected method " + method)
+	}
+	return nil
+}
+
+// testPaths counts the disconnected paths
+type testPaths int
+
+func (p testPaths) CountDisconnectedNvmePaths() int {
+	return int(p)
+}
+
+// testSender records the readings by sensor
+type testSender struct {
+	readings map[uint8]uint8
+	err      error
+}
+
+func (s *testSender) SetSensorReading(_ context.Context, sensor uint8, reading uint8) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.readings[sensor] = reading
+	return nil
+}
+
+func TestIpmi_ParseSensors(t *testing.T) {
+	tests := map[string]struct {
+		in       string
+		expected map[string]uint8
+		errMsg   string
+	}{
+		"all indicators": {
+			in:       "controllers_active=0x60, media_wear=97,path_failures=0x62",
+			expected: map[string]uint8{ControllersActive: 0x60, MediaWear: 97, PathFailures: 0x62},
+		},
+		"unknown indicator": {
+			in:     "temperature=0x60",
+			errMsg: "invalid indicator temperature, have to be controllers_active, media_wear or path_failures",
+		},
+		"sensor number too high": {
+			in:     "media_wear=256",
+			errMsg: "invalid sensor number 256 of media_wear, have to be between 0 and 255",
+		},
+		"no sensor number": {
+			in:     "media_wear",
+			errMsg: "invalid sensor \"media_wear\", have to be indicator=number",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sensors, err := ParseSensors(tt.in)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sensors, tt.expected) {
+				t.Error("sensors: expected", tt.expected, "received", sensors)
+			}
+		})
+	}
+}
+
+func TestIpmi_Push(t *testing.T) {
+	sensors := map[string]uint8{ControllersActive: 0x60, MediaWear: 0x61, PathFailures: 0x62}
+	tests := map[string]struct {
+		wear     []int
+		paths    int
+		spdkErr  error
+		expected map[uint8]uint8
+	}{
+		"healthy": {
+			wear:     []int{3, 12},
+			expected: map[uint8]uint8{0x60: 2, 0x61: 12, 0x62: 0},
+		},
+		"worn drive and failed paths": {
+			wear:     []int{300, 12},
+			paths:    3,
+			expected: map[uint8]uint8{0x60: 2, 0x61: 255, 0x62: 3},
+		},
+		"firmware failure": {
+			wear:     []int{3, 12},
+			paths:    1,
+			spdkErr:  errors.New("connection refused"),
+			expected: map[uint8]uint8{0x60: 2, 0x62: 1},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sender := &testSender{readings: make(map[uint8]uint8)}
+			reporter := NewReporter(sender, sensors, &testFrontend{}, testPaths(tt.paths), &testJSONRPC{wear: tt.wear, err: tt.spdkErr})
+			reporter.Push(context.Background())
+			if !reflect.DeepEqual(sender.readings, tt.expected) {
+				t.Error("readings: expected", tt.expected, "received", sender.readings)
+			}
+		})
+	}
+}
+
+func TestIpmi_IpmitoolSender(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "ipmitool")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > "+output+"\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	sender := NewIpmitoolSender([]string{"-I", "lanplus", "-H", "bmc"})
+	sender.path = script
+	if err := sender.SetSensorReading(context.Background(), 0x61, 12); err != nil {
+		t.Fatal(err)
+	}
+	args, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "-I lanplus -H bmc raw 0x04 0x30 0x61 0x01 0x0c"
+	if strings.TrimSpace(string(args)) != expected {
+		t.Error("args: expected", expected, "received", string(args))
+	}
+
+	sender.path = filepath.Join(dir, "missing")
+	if err := sender.SetSensorReading(context.Background(), 0x61, 12); err == nil {
+		t.Error("error: expected the missing ipmitool, received nil")
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ipmi pushes the health of the storage emulation to sensors of the BMC of the platform,
+// so the hardware monitoring sees it as the other components of the server
+package ipmi
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Set Sensor Reading And Event Status, see IPMI specification
+const (
+	netFnSensorEvent        = 0x04
+	cmdSetSensorReading     = 0x30
+	operationSetSensorValue = 0x01 // write the given value to the sensor reading byte
+)
+
+// IpmitoolSender sends the IPMI requests as raw commands of ipmitool, through the local
+// interface of the BMC or a remote one as selected by its arguments
+type IpmitoolSender struct {
+	path string
+	args []string
+}
+
+// NewIpmitoolSender returns an IpmitoolSender running ipmitool with args before the raw command,
+// i.e. -I lanplus -H bmc -U admin -E
+func NewIpmitoolSender(args []string) *IpmitoolSender {
+	return &IpmitoolSender{path: "ipmitool", args: args}
+}
+
+// SetSensorReading sets the reading of a sensor, leaving its event status to the BMC
+func (s *IpmitoolSender) SetSensorReading(ctx context.Context, sensor uint8, reading uint8) error {
+	args := append(append([]string{}, s.args...), "raw",
+		fmt.Sprintf("0x%02x", netFnSensorEvent),
+		fmt.Sprintf("0x%02x", cmdSetSensorReading),
+		fmt.Sprintf("0x%02x", sensor),
+		fmt.Sprintf("0x%02x", operationSetSensorValue),
+		fmt.Sprintf("0x%02x", reading))
+	output, err := exec.CommandContext(ctx, s.path, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
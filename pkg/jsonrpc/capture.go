@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package jsonrpc implements the JSON-RPC client of the Marvell firmware, the ID of each call
+// carries the ID of the request it is made for, so the firmware logs can be tied back to it
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// maxExchangeSize bounds the size of a captured exchange read back for a replay
+const maxExchangeSize = 16 * 1024 * 1024
+
+// Exchange represents a captured call to the firmware
+type Exchange struct {
+	// Time the call was made, UTC
+	Time time.Time `json:"time"`
+	// Request sent to the firmware, as is
+	Request json.RawMessage `json:"request"`
+	// Response of the firmware, as received, empty when the firmware didn't answer
+	Response json.RawMessage `json:"response,omitempty"`
+	// Error of the call when the firmware didn't answer, i.e. connection refused
+	Error string `json:"error,omitempty"`
+}
+
+// Capture appends the calls to the firmware to a writer, one JSON exchange per line, so they can
+// be replayed to reproduce a firmware bug. The requests carry the key material of the volumes, w
+// is expected to redact it
+type Capture struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewCapture creates a capture appending the exchanges to w
+func NewCapture(w io.Writer) *Capture {
+	return &Capture{w: w}
+}
+
+// record appends an exchange, a failure is logged since it doesn't fail the call
+func (c *Capture) record(request []byte, response json.RawMessage, err error) {
+	if c == nil {
+		return
+	}
+	exchange := Exchange{Time: time.Now().UTC(), Request: request, Response: response}
+	if err != nil && response == nil {
+		exchange.Error = err.Error()
+	}
+	data, merr := json.Marshal(exchange)
+	if merr != nil {
+		slog.Error("Could not capture the call to the firmware", "error", merr)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, werr := c.w.Write(append(data, '\n')); werr != nil {
+		slog.Error("Could not capture the call to the firmware", "error", werr)
+	}
+}
+
+// Divergence represents a replayed call whose response differs from the captured one
+type Divergence struct {
+	// Index of the exchange in the capture, from 1
+	Index int
+	// Method of the call
+	Method string
+	// Captured is the captured response, or error
+	Captured string
+	// Replayed is the response received during the replay, or error
+	Replayed string
+}
+
+// Replay re-issues the captured calls in order to the firmware of the client, as captured, and
+// compares their results and errors to the captured ones. It returns the number of replayed calls
+// and the divergent ones, it stops when the capture can't be read or ctx is done
+func (c *Client) Replay(ctx context.Context, capture io.Reader) (int, []*Divergence, error) {
+	var divergences []*Divergence
+	scanner := bufio.NewScanner(capture)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxExchangeSize)
+	replayed := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return replayed, divergences, err
+		}
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return replayed, divergences, fmt.Errorf("invalid exchange %d: %w", replayed+1, err)
+		}
+		var req request
+		if err := json.Unmarshal(exchange.Request, &req); err != nil {
+			return replayed, divergences, fmt.Errorf("invalid request of exchange %d: %w", replayed+1, err)
+		}
+		replayed++
+		var resp response
+		raw, err := c.communicate(ctx, exchange.Request, &resp)
+		callErr := ""
+		if err != nil {
+			callErr = err.Error()
+		}
+		captured, replay := outcome(exchange.Response, exchange.Error), outcome(raw, callErr)
+		if captured != replay {
+			divergences = append(divergences, &Divergence{
+				Index:    replayed,
+				Method:   req.Method,
+				Captured: captured,
+				Replayed: replay,
+			})
+		}
+	}
+	return replayed, divergences, scanner.Err()
+}
+
+// outcome returns the result and the error of a response in a canonical form, without its ID
+// which can't diverge, or the error of the call when the firmware didn't answer
+func outcome(raw json.RawMessage, callErr string) string {
+	if raw == nil {
+		return callErr
+	}
+	var resp struct {
+		Result interface{} `json:"result"`
+		Error  interface{} `json:"error"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&resp); err != nil {
+		return string(raw)
+	}
+	// an error without code is no error
+	if e, ok := resp.Error.(map[string]interface{}); ok && (e["code"] == nil || e["code"] == json.Number("0")) {
+		resp.Error = nil
+	}
+	canonical := map[string]interface{}{"result": resp.Result}
+	if resp.Error != nil {
+		canonical["error"] = resp.Error
+	}
+	// the keys of the maps are sorted, the same outcomes have the same encoding
+	data, _ := json.Marshal(canonical)
+	return string(data)
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package jsonrpc implements the JSON-RPC client of the Marvell firmware, the ID of each call
+// carries the ID of the request it is made for, so the firmware logs can be tied back to it
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/spdk/spdktest"
+)
+
+// serve answers the calls with the responses, in order
+func serve(t *testing.T, client *Client, responses []string) {
+	ln := client.StartUnixListener()
+	t.Cleanup(func() { _ = ln.Close() })
+	ids := make(chan string, len(responses))
+	go func() {
+		for _, response := range responses {
+			serveOnce(t, ln, response, ids)
+		}
+	}()
+}
+
+func TestJSONRPC_CaptureAndReplay(t *testing.T) {
+	var capture bytes.Buffer
+	client := NewClient(filepath.Join(t.TempDir(), "spdk.sock"))
+	client.SetCapture(NewCapture(&capture))
+	serve(t, client, []string{
+		`{"id":%s,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+		`{"id":%s,"error":{"code":0,"message":""},"result":{"status": 0,"uuid":"8f8a1d2e"}}`,
+		`{"id":%s,"error":{"code":-32601,"message":"Method not found"},"result":null}`,
+	})
+	var result spdktest.StatusResult
+	_ = client.Call(context.Background(), "mrvl_nvm_subsys_alloc", map[string]string{"subnqn": "nqn.2022-09.io.spdk:opi1"}, &result)
+	_ = client.Call(context.Background(), "mrvl_nvm_ctrlr_alloc", nil, &result)
+	_ = client.Call(context.Background(), "mrvl_nvm_unknown", nil, &result)
+
+	var exchanges []*Exchange
+	for _, line := range strings.Split(strings.TrimSpace(capture.String()), "\n") {
+		exchange := new(Exchange)
+		if err := json.Unmarshal([]byte(line), exchange); err != nil {
+			t.Fatal(err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	if len(exchanges) != 3 {
+		t.Fatal("exchanges: expected 3, received", len(exchanges))
+	}
+	expected := `{"jsonrpc":"2.0","method":"mrvl_nvm_subsys_alloc","id":1,"params":{"subnqn":"nqn.2022-09.io.spdk:opi1"}}`
+	if string(exchanges[0].Request) != expected || exchanges[0].Response == nil || exchanges[0].Time.IsZero() {
+		t.Error("exchange: expected", expected, "received", exchanges[0])
+	}
+
+	// the firmware allocates another uuid and now knows the method
+	replayClient := NewClient(filepath.Join(t.TempDir(), "spdk.sock"))
+	serve(t, replayClient, []string{
+		`{"id":%s,"error":{"code":0,"message":""},"result":{"status":0}}`,
+		`{"id":%s,"error":{"code":0,"message":""},"result":{"status": 0,"uuid":"5c2b9e41"}}`,
+		`{"id":%s,"result":{"status": 0}}`,
+	})
+	replayed, divergences, err := replayClient.Replay(context.Background(), &capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 3 {
+		t.Error("replayed: expected 3, received", replayed)
+	}
+	expectedDivergences := []*Divergence{
+		{
+			Index:    2,
+			Method:   "mrvl_nvm_ctrlr_alloc",
+			Captured: `{"result":{"status":0,"uuid":"8f8a1d2e"}}`,
+			Replayed: `{"result":{"status":0,"uuid":"5c2b9e41"}}`,
+		},
+		{
+			Index:    3,
+			Method:   "mrvl_nvm_unknown",
+			Captured: `{"error":{"code":-32601,"message":"Method not found"},"result":null}`,
+			Replayed: `{"result":{"status":0}}`,
+		},
+	}
+	if !reflect.DeepEqual(divergences, expectedDivergences) {
+		t.Error("divergences: expected", expectedDivergences, "received", divergences)
+	}
+}
+
+func TestJSONRPC_CaptureUnreachable(t *testing.T) {
+	var capture bytes.Buffer
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+	client.SetCapture(NewCapture(&capture))
+	var result spdktest.StatusResult
+	_ = client.Call(context.Background(), "mrvl_nvm_get_version", nil, &result)
+
+	exchange := new(Exchange)
+	if err := json.Unmarshal(capture.Bytes(), exchange); err != nil {
+		t.Fatal(err)
+	}
+	if exchange.Response != nil || !strings.Contains(exchange.Error, "missing.sock") {
+		t.Error("exchange: expected the error of the call, received", exchange)
+	}
+
+	// the firmware still can't be reached, the replay doesn't diverge
+	replayed, divergences, err := client.Replay(context.Background(), strings.NewReader(capture.String()+"\n"))
+	if err != nil || replayed != 1 || len(divergences) != 0 {
+		t.Error("replay: expected 1 call without divergence, received", replayed, divergences, err)
+	}
+
+	if _, _, err := client.Replay(context.Background(), strings.NewReader("{")); err == nil {
+		t.Error("expected an error on an invalid capture")
+	}
+}
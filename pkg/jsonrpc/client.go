@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package jsonrpc implements the JSON-RPC client of the Marvell firmware, the ID of each call
+// carries the ID of the request it is made for, so the firmware logs can be tied back to it
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+)
+
+// request is a JSON-RPC request, its ID is a number or a string
+type request struct {
+	RPCVersion string      `json:"jsonrpc"`
+	Method     string      `json:"method"`
+	ID         interface{} `json:"id"`
+	Params     interface{} `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response, its ID is compared to the one of the request as is
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  spdk.RPCError   `json:"error"`
+}
+
+// Client implements spdk.JSONRPC
+type Client struct {
+	transport string
+	socket    string
+	id        uint64
+	// capture records the calls, nil when they are not captured
+	capture *Capture
+}
+
+// build time check that struct implements interface
+var _ spdk.JSONRPC = (*Client)(nil)
+
+// NewClient creates a client of the firmware listening on a unix domain socket, e.g.:
+// /var/tmp/spdk.sock or on a tcp ip and port tuple, e.g.: 10.1.1.2:1234
+func NewClient(socketPath string) *Client {
+	if socketPath == "" {
+		log.Panic("empty socketPath is not allowed")
+	}
+	protocol := "tcp"
+	if _, _, err := net.SplitHostPort(socketPath); err != nil {
+		protocol = "unix"
+	}
+	slog.Info("Connection to the firmware detected", "transport", protocol, "address", socketPath)
+	return &Client{
+		transport: protocol,
+		socket:    socketPath,
+	}
+}
+
+// SetCapture records all the calls of the client to a capture, it is set before the first call
+func (c *Client) SetCapture(capture *Capture) {
+	c.capture = capture
+}
+
+// GetID returns the sequence number of the last call
+func (c *Client) GetID() uint64 {
+	return atomic.LoadUint64(&c.id)
+}
+
+// GetVersion returns the version of the firmware, empty when it can't be reached
+func (c *Client) GetVersion(ctx context.Context) string {
+	var ver spdk.GetVersionResult
+	if err := c.Call(ctx, "spdk_get_version", nil, &ver); err != nil {
+		slog.WarnContext(ctx, "Could not get firmware version", "error", err)
+		return ""
+	}
+	return ver.Version
+}
+
+// StartUnixListener creates a listener on the socket of the client, used in tests
+func (c *Client) StartUnixListener() net.Listener {
+	if err := os.RemoveAll(c.socket); err != nil {
+		log.Fatal(err)
+	}
+	ln, err := net.Listen("unix", c.socket)
+	if err != nil {
+		log.Fatal("listen error:", err)
+	}
+	return ln
+}
+
+// Call calls a method of the firmware, the ID of the call is <request ID>-<sequence number>
+// for the calls made for a request, the sequence number for the others
+func (c *Client) Call(ctx context.Context, method string, args, result interface{}) error {
+	seq := atomic.AddUint64(&c.id, 1)
+	var id interface{} = seq
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		id = requestID + "-" + strconv.FormatUint(seq, 10)
+	}
+	data, err := json.Marshal(request{
+		RPCVersion: spdk.JSONRPCVersion,
+		ID:         id,
+		Method:     method,
+		Params:     args,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	expectedID, _ := json.Marshal(id)
+
+	slog.DebugContext(ctx, "Sending to the firmware", "request", string(data))
+	var resp response
+	raw, err := c.communicate(ctx, data, &resp)
+	c.capture.record(data, raw, err)
+	if err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	return decodeResult(ctx, method, &resp, expectedID, result)
+}
+
+// decodeResult checks a response of the firmware is the one of the call and not an error, and
+// decodes its result, whatever the firmware answered it fails rather than panics
+func decodeResult(ctx context.Context, method string, resp *response, expectedID []byte, result interface{}) error {
+	if !bytes.Equal(resp.ID, expectedID) {
+		return fmt.Errorf("%s: json response ID mismatch", method)
+	}
+	if resp.Error.Code != 0 {
+		return fmt.Errorf("%s: json response error: %s", method, resp.Error.Message)
+	}
+	slog.DebugContext(ctx, "Received from the firmware", "result", string(resp.Result))
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	return nil
+}
+
+// communicate sends a request on a new connection and decodes the response, it returns the
+// response as received
+func (c *Client) communicate(ctx context.Context, data []byte, resp *response) (json.RawMessage, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.transport, c.socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := conn.Write(data); err != nil {
+		return nil, err
+	}
+	// the firmware answers once the request is complete
+	switch conn := conn.(type) {
+	case *net.TCPConn:
+		err = conn.CloseWrite()
+	case *net.UnixConn:
+		err = conn.CloseWrite()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(conn).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, json.Unmarshal(raw, resp)
+}
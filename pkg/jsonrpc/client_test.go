@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package jsonrpc implements the JSON-RPC client of the Marvell firmware, the ID of each call
+// carries the ID of the request it is made for, so the firmware logs can be tied back to it
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/spdk/spdktest"
+)
+
+// serveOnce answers a call with a response formatted with the ID of the request, and reports
+// the ID it received
+func serveOnce(t *testing.T, ln net.Listener, response string, ids chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	var req map[string]json.RawMessage
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		t.Error(err)
+		return
+	}
+	ids <- string(req["id"])
+	if _, err := fmt.Fprintf(conn, response, req["id"]); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONRPC_Call(t *testing.T) {
+	tests := map[string]struct {
+		requestID string
+		response  string
+		id        string
+		status    int
+		errMsg    string
+	}{
+		"call without request": {
+			requestID: "",
+			response:  `{"id":%s,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+			id:        `1`,
+			status:    0,
+			errMsg:    "",
+		},
+		"call of a request": {
+			requestID: "c0ffee",
+			response:  `{"id":%s,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+			id:        `"c0ffee-1"`,
+			status:    1,
+			errMsg:    "",
+		},
+		"response of another call": {
+			requestID: "c0ffee",
+			response:  `{"id":"other-1%.0s","error":{"code":0,"message":""},"result":{"status": 0}}`,
+			id:        `"c0ffee-1"`,
+			status:    0,
+			errMsg:    "mrvl_nvm_get_version: json response ID mismatch",
+		},
+		"error response": {
+			requestID: "",
+			response:  `{"id":%s,"error":{"code":-32601,"message":"myopierr"},"result":null}`,
+			id:        `1`,
+			status:    0,
+			errMsg:    "mrvl_nvm_get_version: json response error: myopierr",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewClient(filepath.Join(t.TempDir(), "spdk.sock"))
+			ln := client.StartUnixListener()
+			defer ln.Close()
+			ids := make(chan string, 1)
+			go serveOnce(t, ln, tt.response, ids)
+
+			ctx := context.Background()
+			if tt.requestID != "" {
+				ctx = requestid.NewContext(ctx, tt.requestID)
+			}
+			var result spdktest.StatusResult
+			err := client.Call(ctx, "mrvl_nvm_get_version", nil, &result)
+
+			if id := <-ids; id != tt.id {
+				t.Error("id: expected", tt.id, "received", id)
+			}
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != tt.status {
+				t.Error("status: expected", tt.status, "received", result.Status)
+			}
+			if client.GetID() != 1 {
+				t.Error("sequence: expected 1, received", client.GetID())
+			}
+		})
+	}
+}
+
+func TestJSONRPC_CallUnreachable(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+	var result spdktest.StatusResult
+	if err := client.Call(context.Background(), "mrvl_nvm_get_version", nil, &result); err == nil {
+		t.Error("expected an error when the firmware is unreachable")
+	}
+	if version := client.GetVersion(context.Background()); version != "" {
+		t.Error("version: expected empty, received", version)
+	}
+}
+
+func FuzzJSONRPC_DecodeResult(f *testing.F) {
+	f.Add([]byte(`{"id":1,"error":{"code":0,"message":""},"result":{"status": 0}}`))
+	f.Add([]byte(`{"id":1,"error":{"code":-32601,"message":"Method not found"},"result":null}`))
+	f.Add([]byte(`{"id":1,"result":{"status": -22, "ns_list": [{"ns_instance_id": 1, "ctrlr_id_list": null}]}}`))
+	f.Add([]byte(`{"id":"c0ffee-1","result":{"subsys_list": [{"subnqn": 7}]}}`))
+	f.Add([]byte(`{"id":1,"result":true}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var resp response
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return
+		}
+		// the results decoded in the provisioning path, and the ones of the SPDK methods
+		results := []interface{}{
+			&spdktest.StatusResult{},
+			&models.MrvlNvmCreateSubsystemResult{},
+			&models.MrvlNvmGetSubsysListResult{},
+			&models.MrvlNvmSubsysGetNsListResult{},
+			&models.MrvlNvmGetNsInfoResult{},
+			&models.MrvlNvmGetCtrlrInfoResult{},
+			&models.MrvlNvmGetCtrlrStatsResult{},
+			new(bool),
+			new(string),
+			nil,
+		}
+		for _, result := range results {
+			err := decodeResult(context.Background(), "mrvl_nvm_get_version", &resp, []byte(`1`), result)
+			if err == nil && resp.Error.Code != 0 {
+				t.Error("expected the error of the response, received none")
+			}
+			_ = models.ResultStatus(result)
+		}
+	})
+}
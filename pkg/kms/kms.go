@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package kms fetches the data encryption keys of the volumes from an external key management service
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// KeyManager fetches the data encryption keys of the volumes from a key management service,
+// other services (i.e. KMIP) are plugged in by implementing it
+type KeyManager interface {
+	// GetKey returns the unwrapped key material of keyID
+	GetKey(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// HTTPKeyManager fetches the keys from a key management service over HTTP(S),
+// the key of keyID is read from GET <url>/keys/<keyID> answering {"key": "<base64 key>"}
+type HTTPKeyManager struct {
+	url    string
+	client *http.Client
+}
+
+// keyResponse represents the answer of the key management service
+type keyResponse struct {
+	Key []byte `json:"key"`
+}
+
+// NewHTTPKeyManager creates initialized instance of HTTP key manager
+func NewHTTPKeyManager(baseURL string, client *http.Client) *HTTPKeyManager {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPKeyManager{
+		url:    strings.TrimSuffix(baseURL, "/"),
+		client: client,
+	}
+}
+
+// GetKey returns the unwrapped key material of keyID
+func (m *HTTPKeyManager) GetKey(ctx context.Context, keyID string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.url+"/keys/"+url.PathEscape(keyID), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		msg := fmt.Sprintf("Could not get key %s from the key management service: %v", keyID, err)
+		return nil, status.Errorf(codes.Unavailable, msg)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		msg := fmt.Sprintf("Could not find key %s in the key management service", keyID)
+		return nil, status.Errorf(codes.NotFound, msg)
+	default:
+		msg := fmt.Sprintf("Could not get key %s from the key management service: %s", keyID, resp.Status)
+		return nil, status.Errorf(codes.Unavailable, msg)
+	}
+	var result keyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		msg := fmt.Sprintf("Could not decode key %s from the key management service", keyID)
+		return nil, status.Errorf(codes.Internal, msg)
+	}
+	return result.Key, nil
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package kms fetches the data encryption keys of the volumes from an external key management service
+package kms
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestKms_GetKey(t *testing.T) {
+	tests := map[string]struct {
+		keyID   string
+		out     []byte
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid key": {
+			keyID:   "key-42",
+			out:     bytes.Repeat([]byte{0x01}, 32),
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unknown key": {
+			keyID:   "unknown-key",
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("Could not find key %s in the key management service", "unknown-key"),
+		},
+		"service failure": {
+			keyID:   "failing-key",
+			out:     nil,
+			errCode: codes.Unavailable,
+			errMsg:  fmt.Sprintf("Could not get key %s from the key management service: %s", "failing-key", "500 Internal Server Error"),
+		},
+		"malformed answer": {
+			keyID:   "malformed-key",
+			out:     nil,
+			errCode: codes.Internal,
+			errMsg:  fmt.Sprintf("Could not decode key %s from the key management service", "malformed-key"),
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/kms/keys/key-42":
+			_, _ = w.Write([]byte(`{"key": "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="}`))
+		case "/kms/keys/failing-key":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/kms/keys/malformed-key":
+			_, _ = w.Write([]byte(`{"key": 42}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := NewHTTPKeyManager(server.URL+"/kms/", server.Client())
+
+			response, err := m.GetKey(context.Background(), tt.keyID)
+
+			if !bytes.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package logger sets up the structured logging of the bridge, the records of a request carry
+// its ID, its method and the name of the resource it is about
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"os"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The targets the records can be written to
+const (
+	TargetStderr  = "stderr"
+	TargetFile    = "file"
+	TargetSyslog  = "syslog"
+	TargetJournal = "journal"
+)
+
+// syslogTag is the tag of the records written to syslog and the journal
+const syslogTag = "opi-marvell-bridge"
+
+// LogConfig represents the level and the target of the bridge logs
+type LogConfig struct {
+	// Level of the logged records, debug, info, warn or error
+	Level string `json:"level"`
+	// Target the records are written to, stderr, file, syslog or journal
+	Target string `json:"target"`
+	// Path of the file the records are appended to, for the file target
+	Path string `json:"path,omitempty"`
+}
+
+// GetLogConfigRequest represents a request to get the level and the target of the bridge logs
+type GetLogConfigRequest struct{}
+
+// UpdateLogConfigRequest represents a request to change the level and the target of the bridge
+// logs, the empty fields are left unchanged
+type UpdateLogConfigRequest struct {
+	// Level of the logged records, debug, info, warn or error
+	Level string `json:"level"`
+	// Target the records are written to, stderr, file, syslog or journal
+	Target string `json:"target"`
+	// Path of the file the records are appended to, required for the file target
+	Path string `json:"path"`
+}
+
+// Control holds the bridge logger, its level and its target can be changed at runtime so an
+// incident can be debugged without restarting the bridge
+type Control struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	level  *slog.LevelVar
+	format string
+	filter func(io.Writer) io.Writer
+	stderr io.Writer
+	// handler handles the records of the current target, closer closes it when it is a file,
+	// syslog or the journal
+	handler slog.Handler
+	closer  io.Closer
+	config  LogConfig
+}
+
+// NewControl creates a logger writing records above a level in a format, text or json, to stderr,
+// os.Stderr outside the tests. filter wraps the targets, i.e. to redact the records, it can be nil
+func NewControl(stderr io.Writer, level string, format string, filter func(io.Writer) io.Writer) (*Control, error) {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = func(w io.Writer) io.Writer { return w }
+	}
+	c := &Control{
+		level:  new(slog.LevelVar),
+		format: format,
+		filter: filter,
+		stderr: stderr,
+		config: LogConfig{Level: level, Target: TargetStderr},
+	}
+	c.level.Set(lvl)
+	c.handler, err = newFormatHandler(filter(stderr), c.level, format)
+	if err != nil {
+		return nil, err
+	}
+	c.logger = slog.New(contextHandler{Handler: controlHandler{control: c}})
+	return c, nil
+}
+
+// Logger returns the logger, it follows the changes of level and target
+func (c *Control) Logger() *slog.Logger {
+	return c.logger
+}
+
+// GetLogConfig gets the level and the target of the bridge logs
+func (c *Control) GetLogConfig(_ context.Context, _ *GetLogConfigRequest) (*LogConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	config := c.config
+	return &config, nil
+}
+
+// UpdateLogConfig changes the level and the target of the bridge logs, the previous file, syslog
+// or journal target is closed once the records go to the new one
+func (c *Control) UpdateLogConfig(_ context.Context, in *UpdateLogConfigRequest) (*LogConfig, error) {
+	// check input correctness
+	if err := c.validateUpdateLogConfigRequest(in); err != nil {
+		return nil, err
+	}
+	config, previous, err := c.updateLogConfig(in)
+	if err != nil {
+		return nil, err
+	}
+	// the records no longer go to the previous target, it is closed without holding the lock
+	// since closing it can log
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			slog.Warn("Could not close the previous log target", "error", err)
+		}
+	}
+	return config, nil
+}
+
+// updateLogConfig applies a validated request, it returns the closer of the replaced target
+func (c *Control) updateLogConfig(in *UpdateLogConfigRequest) (*LogConfig, io.Closer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	config := c.config
+	var previous io.Closer
+	if in.Target != "" && (in.Target != config.Target || in.Path != config.Path) {
+		handler, closer, err := c.openTarget(in.Target, in.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		previous = c.closer
+		c.handler, c.closer = handler, closer
+		config.Target, config.Path = in.Target, in.Path
+	}
+	if in.Level != "" {
+		lvl, _ := parseLevel(in.Level)
+		c.level.Set(lvl)
+		config.Level = in.Level
+	}
+	c.config = config
+	return &config, previous, nil
+}
+
+// openTarget opens a target of the records and creates the handler of its records, the closer
+// is nil for stderr which is never closed
+func (c *Control) openTarget(target string, path string) (slog.Handler, io.Closer, error) {
+	switch target {
+	case TargetFile:
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+		if err != nil {
+			msg := fmt.Sprintf("Could not open log file %s: %v", path, err)
+			return nil, nil, status.Errorf(codes.FailedPrecondition, msg)
+		}
+		handler, _ := newFormatHandler(c.filter(file), c.level, c.format)
+		return handler, file, nil
+	case TargetSyslog:
+		writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, syslogTag)
+		if err != nil {
+			msg := fmt.Sprintf("Could not connect to syslog: %v", err)
+			return nil, nil, status.Errorf(codes.Unavailable, msg)
+		}
+		return newSyslogHandler(writer, c.filter, c.level, c.format), writer, nil
+	case TargetJournal:
+		conn, err := net.Dial("unixgram", journalSocket)
+		if err != nil {
+			msg := fmt.Sprintf("Could not connect to the journal: %v", err)
+			return nil, nil, status.Errorf(codes.Unavailable, msg)
+		}
+		return newJournalHandler(conn, c.filter), conn, nil
+	default:
+		handler, _ := newFormatHandler(c.filter(c.stderr), c.level, c.format)
+		return handler, nil, nil
+	}
+}
+
+func (c *Control) validateUpdateLogConfigRequest(in *UpdateLogConfigRequest) error {
+	if in.Level != "" {
+		if _, err := parseLevel(in.Level); err != nil {
+			msg := fmt.Sprintf("Level value (%s) is not supported, have to be debug, info, warn or error", in.Level)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	switch in.Target {
+	case "", TargetStderr, TargetSyslog, TargetJournal:
+		if in.Path != "" {
+			msg := fmt.Sprintf("Path is only supported by the %s target", TargetFile)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	case TargetFile:
+		if in.Path == "" {
+			msg := fmt.Sprintf("Path is required by the %s target", TargetFile)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	default:
+		msg := fmt.Sprintf("Target value (%s) is not supported, have to be %s, %s, %s or %s", in.Target, TargetStderr, TargetFile, TargetSyslog, TargetJournal)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// controlHandler hands the records to the handler of the current target of a control, the
+// attributes and groups of the derived loggers are applied to it on every record
+type controlHandler struct {
+	control *Control
+	derive  []func(slog.Handler) slog.Handler
+}
+
+// Enabled implements slog.Handler
+func (h controlHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.control.level.Level()
+}
+
+// Handle implements slog.Handler, the records are handled under the lock so none is written to
+// a target being closed
+func (h controlHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.control.mu.Lock()
+	defer h.control.mu.Unlock()
+	handler := h.control.handler
+	for _, derive := range h.derive {
+		handler = derive(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h controlHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+// WithGroup implements slog.Handler
+func (h controlHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+// with returns a handler deriving the handler of the target once more
+func (h controlHandler) with(derive func(slog.Handler) slog.Handler) controlHandler {
+	return controlHandler{
+		control: h.control,
+		derive:  append(h.derive[:len(h.derive):len(h.derive)], derive),
+	}
+}
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package logger sets up the structured logging of the bridge, the records of a request carry
+// its ID, its method and the name of the resource it is about
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testUpperWriter mimics a filter of the records
+type testUpperWriter struct {
+	w io.Writer
+}
+
+func (u testUpperWriter) Write(p []byte) (int, error) {
+	if _, err := u.w.Write(bytes.ToUpper(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func TestLogger_UpdateLogConfig(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "bridge.log")
+	tests := map[string]struct {
+		in      *UpdateLogConfigRequest
+		out     *LogConfig
+		errCode codes.Code
+		errMsg  string
+		stderr  bool
+		file    bool
+	}{
+		"valid level change": {
+			in:      &UpdateLogConfigRequest{Level: "debug"},
+			out:     &LogConfig{Level: "debug", Target: TargetStderr},
+			errCode: codes.OK,
+			errMsg:  "",
+			stderr:  true,
+			file:    false,
+		},
+		"valid file target": {
+			in:      &UpdateLogConfigRequest{Level: "debug", Target: TargetFile, Path: logFile},
+			out:     &LogConfig{Level: "debug", Target: TargetFile, Path: logFile},
+			errCode: codes.OK,
+			errMsg:  "",
+			stderr:  false,
+			file:    true,
+		},
+		"invalid level": {
+			in:      &UpdateLogConfigRequest{Level: "verbose"},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Level value (verbose) is not supported, have to be debug, info, warn or error",
+			stderr:  false,
+			file:    false,
+		},
+		"invalid target": {
+			in:      &UpdateLogConfigRequest{Target: "kafka"},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Target value (kafka) is not supported, have to be stderr, file, syslog or journal",
+			stderr:  false,
+			file:    false,
+		},
+		"file target without path": {
+			in:      &UpdateLogConfigRequest{Target: TargetFile},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Path is required by the file target",
+			stderr:  false,
+			file:    false,
+		},
+		"path of another target": {
+			in:      &UpdateLogConfigRequest{Target: TargetStderr, Path: logFile},
+			out:     nil,
+			errCode: codes.InvalidArgument,
+			errMsg:  "Path is only supported by the file target",
+			stderr:  false,
+			file:    false,
+		},
+		"file that can't be opened": {
+			in:      &UpdateLogConfigRequest{Target: TargetFile, Path: filepath.Join(logFile, "missing", "bridge.log")},
+			out:     nil,
+			errCode: codes.FailedPrecondition,
+			errMsg:  "Could not open log file",
+			stderr:  false,
+			file:    false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_ = os.Remove(logFile)
+			var stderr bytes.Buffer
+			control, err := NewControl(&stderr, "info", "text", func(w io.Writer) io.Writer { return testUpperWriter{w: w} })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := control.UpdateLogConfig(context.Background(), tt.in)
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			if er, ok := status.FromError(err); ok {
+				if er.Code() != tt.errCode {
+					t.Error("error code: expected", tt.errCode, "received", er.Code())
+				}
+				if !strings.HasPrefix(er.Message(), tt.errMsg) {
+					t.Error("error message: expected", tt.errMsg, "received", er.Message())
+				}
+			} else {
+				t.Error("expected grpc error status")
+			}
+
+			config, _ := control.GetLogConfig(context.Background(), &GetLogConfigRequest{})
+			if tt.out != nil && !reflect.DeepEqual(config, tt.out) {
+				t.Error("config: expected", tt.out, "received", config)
+			}
+
+			control.Logger().Debug("my debug record")
+			content, _ := os.ReadFile(logFile)
+			for target, written := range map[string]string{"stderr": stderr.String(), "file": string(content)} {
+				expected := (target == "stderr" && tt.stderr) || (target == "file" && tt.file)
+				if strings.Contains(written, "MY DEBUG RECORD") != expected {
+					t.Error(target, fmt.Sprintf("expected filtered debug record %v, received", expected), written)
+				}
+			}
+		})
+	}
+}
+
+func TestLogger_UpdateLogConfigBackToStderr(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "bridge.log")
+	var stderr bytes.Buffer
+	control, err := NewControl(&stderr, "info", "text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := control.UpdateLogConfig(context.Background(), &UpdateLogConfigRequest{Target: TargetFile, Path: logFile}); err != nil {
+		t.Fatal(err)
+	}
+	control.Logger().Info("to the file")
+	if _, err := control.UpdateLogConfig(context.Background(), &UpdateLogConfigRequest{Target: TargetStderr}); err != nil {
+		t.Fatal(err)
+	}
+	control.Logger().Info("to stderr")
+
+	content, _ := os.ReadFile(logFile)
+	if !strings.Contains(string(content), "to the file") || strings.Contains(string(content), "to stderr") {
+		t.Error("file: expected only the first record, received", string(content))
+	}
+	if strings.Contains(stderr.String(), "to the file") || !strings.Contains(stderr.String(), "to stderr") {
+		t.Error("stderr: expected only the second record, received", stderr.String())
+	}
+	if control.closer != nil {
+		t.Error("expected the file target to be closed")
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package logger sets up the structured logging of the bridge, the records of a request carry
+// its ID, its method and the name of the resource it is about
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// journalSocket is the socket of the native protocol of the systemd journal
+var journalSocket = "/run/systemd/journal/socket"
+
+// journalHandler writes the records to the systemd journal with its native protocol, the
+// message, the priority of the level and every attribute are fields of the entry, i.e.
+// REQUEST_ID, so the entries can be matched with journalctl REQUEST_ID=<id>
+type journalHandler struct {
+	conn   io.Writer
+	filter func(io.Writer) io.Writer
+	// fields are the encoded attributes of the derived handlers
+	fields []byte
+	// prefix is the prefix of the attributes in the groups of the derived handlers
+	prefix string
+}
+
+// newJournalHandler creates the handler of the records written to the journal through conn,
+// filter wraps the values of the fields
+func newJournalHandler(conn io.Writer, filter func(io.Writer) io.Writer) *journalHandler {
+	return &journalHandler{conn: conn, filter: filter}
+}
+
+// Enabled implements slog.Handler, the level is checked by the control
+func (h *journalHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler, a record is one datagram
+func (h *journalHandler) Handle(_ context.Context, r slog.Record) error {
+	entry := appendJournalField(nil, "MESSAGE", h.redact(r.Message))
+	entry = appendJournalField(entry, "PRIORITY", strconv.Itoa(int(priority(r.Level))))
+	entry = appendJournalField(entry, "SYSLOG_IDENTIFIER", syslogTag)
+	entry = append(entry, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		entry = h.appendAttr(entry, h.prefix, a)
+		return true
+	})
+	_, err := h.conn.Write(entry)
+	return err
+}
+
+// WithAttrs implements slog.Handler
+func (h *journalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := append([]byte(nil), h.fields...)
+	for _, a := range attrs {
+		fields = h.appendAttr(fields, h.prefix, a)
+	}
+	return &journalHandler{conn: h.conn, filter: h.filter, fields: fields, prefix: h.prefix}
+}
+
+// WithGroup implements slog.Handler
+func (h *journalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &journalHandler{conn: h.conn, filter: h.filter, fields: h.fields, prefix: h.prefix + name + "_"}
+}
+
+// appendAttr appends an attribute as a field, the attributes of a group are prefixed by its name
+func (h *journalHandler) appendAttr(entry []byte, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return entry
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "_"
+		}
+		for _, ga := range a.Value.Group() {
+			entry = h.appendAttr(entry, prefix, ga)
+		}
+		return entry
+	}
+	return appendJournalField(entry, journalFieldName(prefix+a.Key), h.redact(a.Value.String()))
+}
+
+// redact passes a value through the filter
+func (h *journalHandler) redact(value string) string {
+	var buf bytes.Buffer
+	_, _ = h.filter(&buf).Write([]byte(value))
+	return buf.String()
+}
+
+// journalFieldName turns a key into a field name, uppercase letters, digits and underscores not
+// starting with an underscore, which would be a trusted field, nor a digit
+func journalFieldName(key string) string {
+	name := strings.TrimLeft(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		default:
+			return '_'
+		}
+	}, key), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "FIELD_" + name
+	}
+	return name
+}
+
+// appendJournalField appends a field in the native protocol, the values spanning several lines
+// are prefixed by their length
+func appendJournalField(entry []byte, name string, value string) []byte {
+	if !strings.Contains(value, "\n") {
+		entry = append(entry, name...)
+		entry = append(entry, '=')
+		entry = append(entry, value...)
+		return append(entry, '\n')
+	}
+	entry = append(entry, name...)
+	entry = append(entry, '\n')
+	entry = binary.LittleEndian.AppendUint64(entry, uint64(len(value)))
+	entry = append(entry, value...)
+	return append(entry, '\n')
+}
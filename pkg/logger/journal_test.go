@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package logger sets up the structured logging of the bridge, the records of a request carry
+// its ID, its method and the name of the resource it is about
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+)
+
+// parseJournalEntry decodes a datagram of the native protocol of the journal
+func parseJournalEntry(t *testing.T, entry []byte) map[string]string {
+	fields := make(map[string]string)
+	for len(entry) > 0 {
+		end := bytes.IndexByte(entry, '\n')
+		if end < 0 {
+			t.Fatal("unterminated field", string(entry))
+		}
+		line := entry[:end]
+		if name, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(name)] = string(value)
+			entry = entry[end+1:]
+			continue
+		}
+		entry = entry[end+1:]
+		size := binary.LittleEndian.Uint64(entry)
+		fields[string(line)] = string(entry[8 : 8+size])
+		entry = entry[8+size+1:]
+	}
+	return fields
+}
+
+func TestLogger_JournalTarget(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	journal, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = journal.Close() }()
+	previous := journalSocket
+	journalSocket = socket
+	defer func() { journalSocket = previous }()
+
+	control, err := NewControl(io.Discard, "info", "text", func(w io.Writer) io.Writer { return testUpperWriter{w: w} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := control.UpdateLogConfig(context.Background(), &UpdateLogConfigRequest{Target: TargetJournal}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := requestid.NewContext(context.Background(), "c0ffee")
+	control.Logger().With("method", "mrvl_nvm_get_ns_stats").WarnContext(ctx, "slow rpc", slog.Group("rpc", "latency", time.Second), "payload", "{\n}")
+	control.Logger().DebugContext(ctx, "filtered by the level")
+
+	buf := make([]byte, 4096)
+	_ = journal.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := journal.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"MESSAGE":           "SLOW RPC",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": syslogTag,
+		"METHOD":            "MRVL_NVM_GET_NS_STATS",
+		"RPC_LATENCY":       "1S",
+		"PAYLOAD":           "{\n}",
+		"REQUEST_ID":        "C0FFEE",
+	}
+	if received := parseJournalEntry(t, buf[:n]); !reflect.DeepEqual(received, expected) {
+		t.Error("entry: expected", expected, "received", received)
+	}
+	_ = journal.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := journal.Read(buf); err == nil {
+		t.Error("expected no other entry, received", string(buf[:n]))
+	}
+
+	if _, err := control.UpdateLogConfig(context.Background(), &UpdateLogConfigRequest{Target: TargetStderr}); err != nil {
+		t.Fatal(err)
+	}
+	if control.closer != nil {
+		t.Error("expected the journal target to be closed")
+	}
+}
+
+func TestLogger_JournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"request_id":  "REQUEST_ID",
+		"grpc.method": "GRPC_METHOD",
+		"_hostname":   "HOSTNAME",
+		"2fa":         "FIELD_2FA",
+		"":            "FIELD_",
+	}
+
+	// run tests
+	for key, expected := range tests {
+		t.Run(key, func(t *testing.T) {
+			if received := journalFieldName(key); received != expected {
+				t.Error("field name: expected", expected, "received", received)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package logger sets up the structured logging of the bridge, the records of a request carry
+// its ID, its method and the name of the resource it is about
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+
+	"google.golang.org/grpc"
+)
+
+// New creates a logger writing records above a level, debug, info, warn or error, to w in
+// a format, text or json
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	handler, err := newHandler(w, lvl, format)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(handler), nil
+}
+
+// parseLevel parses a level, debug, info, warn or error
+func parseLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return lvl, fmt.Errorf("invalid log level %q, have to be debug, info, warn or error", level)
+	}
+	return lvl, nil
+}
+
+// newHandler creates the handler of the records above a level in a format, text or json
+func newHandler(w io.Writer, level slog.Leveler, format string) (slog.Handler, error) {
+	handler, err := newFormatHandler(w, level, format)
+	if err != nil {
+		return nil, err
+	}
+	return contextHandler{Handler: handler}, nil
+}
+
+// newFormatHandler creates the handler formatting the records above a level, text or json
+func newFormatHandler(w io.Writer, level slog.Leveler, format string) (slog.Handler, error) {
+	options := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "text":
+		return slog.NewTextHandler(w, options), nil
+	case "json":
+		return slog.NewJSONHandler(w, options), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, have to be text or json", format)
+	}
+}
+
+// resourceNameKey is the context key of the name of the resource a request is about
+type resourceNameKey struct{}
+
+// contextHandler adds the ID of the request and the name of its resource to the records logged
+// with its context
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if name, ok := ctx.Value(resourceNameKey{}).(string); ok {
+		r.AddAttrs(slog.String("resource", name))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// UnaryServerInterceptor puts the name of the resource a request is about in its context, so
+// it is logged with the records of the request, it comes before the logging interceptor
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if name := resourceName(req); name != "" {
+			ctx = context.WithValue(ctx, resourceNameKey{}, name)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// resourceName returns the name of the resource of a request, its parent for the create and
+// list requests, empty when it has none
+func resourceName(req interface{}) string {
+	if r, ok := req.(interface{ GetName() string }); ok && r.GetName() != "" {
+		return r.GetName()
+	}
+	if r, ok := req.(interface{ GetParent() string }); ok {
+		return r.GetParent()
+	}
+	return ""
+}
+
+// InterceptorLogger adapts a logger to the gRPC logging interceptor, their levels match
+func InterceptorLogger(l *slog.Logger) logging.Logger {
+	return logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
+		l.Log(ctx, slog.Level(lvl), msg, fields...)
+	})
+}
+
+// debugEvents are the events of the gRPC calls logged at debug level, the start of the calls and
+// their payloads included
+var debugEvents = []logging.LoggableEvent{logging.StartCall, logging.FinishCall, logging.PayloadReceived, logging.PayloadSent}
+
+// LoggingUnaryServerInterceptor logs the gRPC calls with l, the start of the calls and their
+// payloads only when l is at debug level. The level is checked at each call, so it follows the
+// changes of the level at runtime
+func LoggingUnaryServerInterceptor(l *slog.Logger) grpc.UnaryServerInterceptor {
+	debug := logging.UnaryServerInterceptor(InterceptorLogger(l), logging.WithLogOnEvents(debugEvents...))
+	finish := logging.UnaryServerInterceptor(InterceptorLogger(l), logging.WithLogOnEvents(logging.FinishCall))
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if l.Enabled(ctx, slog.LevelDebug) {
+			return debug(ctx, req, info, handler)
+		}
+		return finish(ctx, req, info, handler)
+	}
+}
+
+// WrapJSONRPC logs the calls to the firmware with their method, latency and status, at debug
+// level, the failed ones at warn level
+func WrapJSONRPC(rpc spdk.JSONRPC, l *slog.Logger) spdk.JSONRPC {
+	return &loggedJSONRPC{JSONRPC: rpc, logger: l}
+}
+
+// loggedJSONRPC is a JSON-RPC client logging the calls to the firmware
+type loggedJSONRPC struct {
+	spdk.JSONRPC
+	logger *slog.Logger
+}
+
+// Call implements spdk.JSONRPC
+func (c *loggedJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	start := time.Now()
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	latency := time.Since(start)
+	if err != nil {
+		c.logger.WarnContext(ctx, "Marvell RPC failed", "rpc", method, "latency", latency, "error", err)
+		return err
+	}
+	// the Marvell methods report their failures in the status of their result
+	if resultStatus := models.ResultStatus(result); resultStatus != 0 {
+		c.logger.WarnContext(ctx, "Marvell RPC returned an error status", "rpc", method, "latency", latency, "status", int(resultStatus), "status_name", resultStatus.Name(method))
+		return nil
+	}
+	c.logger.DebugContext(ctx, "Marvell RPC done", "rpc", method, "latency", latency, "status", 0)
+	return nil
+}
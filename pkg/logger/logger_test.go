@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package logger sets up the structured logging of the bridge, the records of a request carry
+// its ID, its method and the name of the resource it is about
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/spdk/spdktest"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testNameRequest mimics the Get and Delete requests
+type testNameRequest struct {
+	name string
+}
+
+func (r *testNameRequest) GetName() string {
+	return r.name
+}
+
+// testParentRequest mimics the Create and List requests
+type testParentRequest struct {
+	parent string
+}
+
+func (r *testParentRequest) GetParent() string {
+	return r.parent
+}
+
+// decodeRecords decodes the records logged in the json format
+func decodeRecords(t *testing.T, buffer *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal("invalid record", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogger_New(t *testing.T) {
+	tests := map[string]struct {
+		level  string
+		format string
+		errMsg string
+	}{
+		"text": {
+			level:  "info",
+			format: "text",
+			errMsg: "",
+		},
+		"json": {
+			level:  "debug",
+			format: "json",
+			errMsg: "",
+		},
+		"invalid level": {
+			level:  "verbose",
+			format: "text",
+			errMsg: `invalid log level "verbose", have to be debug, info, warn or error`,
+		},
+		"invalid format": {
+			level:  "info",
+			format: "xml",
+			errMsg: `invalid log format "xml", have to be text or json`,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			l, err := New(&buffer, tt.level, tt.format)
+
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", errMsg)
+			}
+			if (l == nil) != (tt.errMsg != "") {
+				t.Error("logger: expected", tt.errMsg == "", "received", l != nil)
+			}
+		})
+	}
+}
+
+func TestLogger_UnaryServerInterceptor(t *testing.T) {
+	tests := map[string]struct {
+		req      interface{}
+		resource interface{}
+	}{
+		"name": {
+			req:      &testNameRequest{name: "//storage.opiproject.org/nvmeSubsystems/subsys0"},
+			resource: "//storage.opiproject.org/nvmeSubsystems/subsys0",
+		},
+		"parent": {
+			req:      &testParentRequest{parent: "//storage.opiproject.org/nvmeSubsystems/subsys0"},
+			resource: "//storage.opiproject.org/nvmeSubsystems/subsys0",
+		},
+		"no name": {
+			req:      &testNameRequest{},
+			resource: nil,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			l, _ := New(&buffer, "info", "json")
+			interceptor := UnaryServerInterceptor()
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				InterceptorLogger(l).Log(ctx, logging.LevelInfo, "finished call")
+				return req, nil
+			}
+			_, _ = interceptor(context.Background(), tt.req, &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"}, handler)
+
+			records := decodeRecords(t, &buffer)
+			if len(records) != 1 {
+				t.Fatal("records: expected", 1, "received", len(records))
+			}
+			if resource := records[0]["resource"]; resource != tt.resource {
+				t.Error("resource: expected", tt.resource, "received", resource)
+			}
+		})
+	}
+}
+
+func TestLogger_RequestID(t *testing.T) {
+	var buffer bytes.Buffer
+	l, _ := New(&buffer, "info", "json")
+	l.InfoContext(requestid.NewContext(context.Background(), "c0ffee"), "finished call")
+	l.InfoContext(context.Background(), "volume rescan")
+
+	records := decodeRecords(t, &buffer)
+	if len(records) != 2 {
+		t.Fatal("records: expected", 2, "received", len(records))
+	}
+	if id := records[0]["request_id"]; id != "c0ffee" {
+		t.Error("request id: expected c0ffee, received", id)
+	}
+	if id, ok := records[1]["request_id"]; ok {
+		t.Error("request id: expected none, received", id)
+	}
+}
+
+func TestLogger_LoggingUnaryServerInterceptor(t *testing.T) {
+	tests := map[string]struct {
+		level    string
+		messages []string
+	}{
+		"debug level logs the payloads": {
+			level:    "debug",
+			messages: []string{"started call", "request received", "response sent", "finished call"},
+		},
+		"info level doesn't log the payloads": {
+			level:    "info",
+			messages: []string{"finished call"},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			// the level is changed after the interceptor is created, as by UpdateLogConfig
+			control, _ := NewControl(&buffer, "warn", "json", nil)
+			interceptor := LoggingUnaryServerInterceptor(control.Logger())
+			if _, err := control.UpdateLogConfig(context.Background(), &UpdateLogConfigRequest{Level: tt.level}); err != nil {
+				t.Fatal(err)
+			}
+			handler := func(_ context.Context, req interface{}) (interface{}, error) {
+				return req, nil
+			}
+			_, _ = interceptor(context.Background(), &emptypb.Empty{}, &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"}, handler)
+
+			var messages []string
+			for _, record := range decodeRecords(t, &buffer) {
+				messages = append(messages, record["msg"].(string))
+			}
+			if !reflect.DeepEqual(messages, tt.messages) {
+				t.Error("messages: expected", tt.messages, "received", messages)
+			}
+		})
+	}
+}
+
+func TestLogger_WrapJSONRPC(t *testing.T) {
+	tests := map[string]struct {
+		level   string
+		status  int
+		err     error
+		records []map[string]interface{}
+	}{
+		"successful call at info level": {
+			level:   "info",
+			status:  0,
+			err:     nil,
+			records: nil,
+		},
+		"successful call at debug level": {
+			level:   "debug",
+			status:  0,
+			err:     nil,
+			records: []map[string]interface{}{{"level": "DEBUG", "msg": "Marvell RPC done", "rpc": "mrvl_nvm_create_subsystem", "status": float64(0)}},
+		},
+		"non zero status": {
+			level:   "info",
+			status:  -17,
+			err:     nil,
+			records: []map[string]interface{}{{"level": "WARN", "msg": "Marvell RPC returned an error status", "rpc": "mrvl_nvm_create_subsystem", "status": float64(-17), "status_name": "SUBSYS_ALREADY_EXISTS"}},
+		},
+		"failed call": {
+			level:   "info",
+			status:  0,
+			err:     errors.New("json response error: myopierr"),
+			records: []map[string]interface{}{{"level": "WARN", "msg": "Marvell RPC failed", "rpc": "mrvl_nvm_create_subsystem", "error": "json response error: myopierr"}},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			l, _ := New(&buffer, tt.level, "json")
+			rpc := WrapJSONRPC(&spdktest.JSONRPC{Status: tt.status, Err: tt.err}, l)
+
+			var result spdktest.StatusResult
+			err := rpc.Call(context.Background(), "mrvl_nvm_create_subsystem", nil, &result)
+			if err != tt.err {
+				t.Error("error: expected", tt.err, "received", err)
+			}
+
+			records := decodeRecords(t, &buffer)
+			// the time and the latency change on every call
+			for _, record := range records {
+				if _, ok := record["latency"]; !ok {
+					t.Error("expected the latency in", record)
+				}
+				delete(record, "time")
+				delete(record, "latency")
+			}
+			if !reflect.DeepEqual(records, tt.records) {
+				t.Error("records: expected", tt.records, "received", records)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package logger sets up the structured logging of the bridge, the records of a request carry
+// its ID, its method and the name of the resource it is about
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"log/syslog"
+)
+
+// syslogWriter writes messages to syslog with a priority, it is implemented by *syslog.Writer
+type syslogWriter interface {
+	Debug(m string) error
+	Info(m string) error
+	Warning(m string) error
+	Err(m string) error
+}
+
+// build time check that struct implements interface
+var _ syslogWriter = (*syslog.Writer)(nil)
+
+// priority returns the syslog severity of a level
+func priority(level slog.Level) syslog.Priority {
+	switch {
+	case level >= slog.LevelError:
+		return syslog.LOG_ERR
+	case level >= slog.LevelWarn:
+		return syslog.LOG_WARNING
+	case level >= slog.LevelInfo:
+		return syslog.LOG_INFO
+	default:
+		return syslog.LOG_DEBUG
+	}
+}
+
+// syslogHandler writes the records to syslog in the format of the bridge, each with the
+// priority of its level. It is only called under the lock of the control, so the buffer the
+// records are formatted to is not shared
+type syslogHandler struct {
+	writer  syslogWriter
+	buf     *bytes.Buffer
+	handler slog.Handler
+}
+
+// newSyslogHandler creates the handler of the records above a level in a format, text or json,
+// written to syslog, filter wraps the formatted records
+func newSyslogHandler(writer syslogWriter, filter func(io.Writer) io.Writer, level slog.Leveler, format string) *syslogHandler {
+	buf := new(bytes.Buffer)
+	handler, _ := newFormatHandler(filter(buf), level, format)
+	return &syslogHandler{writer: writer, buf: buf, handler: handler}
+}
+
+// Enabled implements slog.Handler
+func (h *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.buf.Reset()
+	if err := h.handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	message := string(bytes.TrimSuffix(h.buf.Bytes(), []byte("\n")))
+	switch priority(r.Level) {
+	case syslog.LOG_ERR:
+		return h.writer.Err(message)
+	case syslog.LOG_WARNING:
+		return h.writer.Warning(message)
+	case syslog.LOG_INFO:
+		return h.writer.Info(message)
+	default:
+		return h.writer.Debug(message)
+	}
+}
+
+// WithAttrs implements slog.Handler
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{writer: h.writer, buf: h.buf, handler: h.handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{writer: h.writer, buf: h.buf, handler: h.handler.WithGroup(name)}
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package logger sets up the structured logging of the bridge, the records of a request carry
+// its ID, its method and the name of the resource it is about
+package logger
+
+import (
+	"io"
+	"log/slog"
+	"log/syslog"
+	"strings"
+	"testing"
+)
+
+// testSyslogWriter keeps the messages written to syslog with their priority
+type testSyslogWriter struct {
+	messages []string
+}
+
+func (w *testSyslogWriter) write(severity string, m string) error {
+	w.messages = append(w.messages, severity+" "+m)
+	return nil
+}
+
+func (w *testSyslogWriter) Debug(m string) error   { return w.write("debug", m) }
+func (w *testSyslogWriter) Info(m string) error    { return w.write("info", m) }
+func (w *testSyslogWriter) Warning(m string) error { return w.write("warning", m) }
+func (w *testSyslogWriter) Err(m string) error     { return w.write("err", m) }
+
+func TestLogger_SyslogHandler(t *testing.T) {
+	writer := &testSyslogWriter{}
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelDebug)
+	handler := newSyslogHandler(writer, func(w io.Writer) io.Writer { return testUpperWriter{w: w} }, level, "json")
+	l := slog.New(handler).With("component", "backend")
+
+	l.Debug("probing")
+	l.Info("listening", "port", 50051)
+	l.Warn("slow rpc")
+	l.Error("failed")
+
+	expected := []string{"debug {", "info {", "warning {", "err {"}
+	if len(writer.messages) != len(expected) {
+		t.Fatal("messages: expected", len(expected), "received", writer.messages)
+	}
+	for i, message := range writer.messages {
+		if !strings.HasPrefix(message, expected[i]) || strings.HasSuffix(message, "\n") {
+			t.Error("message: expected", expected[i], "received", message)
+		}
+	}
+	if !strings.HasSuffix(writer.messages[1], `"MSG":"LISTENING","COMPONENT":"BACKEND","PORT":50051}`) {
+		t.Error("message: expected the filtered attributes, received", writer.messages[1])
+	}
+}
+
+func TestLogger_Priority(t *testing.T) {
+	tests := map[slog.Level]syslog.Priority{
+		slog.LevelDebug: syslog.LOG_DEBUG,
+		slog.LevelInfo:  syslog.LOG_INFO,
+		slog.LevelWarn:  syslog.LOG_WARNING,
+		slog.LevelError: syslog.LOG_ERR,
+		slog.Level(12):  syslog.LOG_ERR,
+	}
+
+	// run tests
+	for level, expected := range tests {
+		t.Run(level.String(), func(t *testing.T) {
+			if received := priority(level); received != expected {
+				t.Error("priority: expected", expected, "received", received)
+			}
+		})
+	}
+}
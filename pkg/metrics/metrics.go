@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package metrics measures the gRPC requests served by the bridge and the Marvell RPCs it issues
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics holds the request and RPC metrics, it is a prometheus.Collector
+type Metrics struct {
+	grpcRequests *prometheus.CounterVec
+	grpcDuration *prometheus.HistogramVec
+	rpcRequests  *prometheus.CounterVec
+	rpcErrors    *prometheus.CounterVec
+	rpcDuration  *prometheus.HistogramVec
+	// firmwareDuration is the time the gRPC requests spent in the firmware
+	firmwareDuration *prometheus.HistogramVec
+	slowRequests     *prometheus.CounterVec
+	// budgets are the latency budgets of the gRPC methods, nil when the requests are not checked,
+	// they can be set at runtime
+	budgets atomic.Pointer[LatencyBudgets]
+}
+
+// New creates the metrics, they have to be registered to be exported
+func New() *Metrics {
+	return &Metrics{
+		grpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "opi_grpc_requests_total",
+			Help: "Number of gRPC requests served, by method and status code.",
+		}, []string{"method", "code"}),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "opi_grpc_request_duration_seconds",
+			Help:    "Duration of the gRPC requests in seconds, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		rpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "opi_marvell_rpc_requests_total",
+			Help: "Number of JSON-RPC calls to the Marvell firmware, by method.",
+		}, []string{"method"}),
+		rpcErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "opi_marvell_rpc_errors_total",
+			Help: "Number of JSON-RPC calls to the Marvell firmware which failed or returned a non zero status, by method.",
+		}, []string{"method"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "opi_marvell_rpc_duration_seconds",
+			Help:    "Duration of the JSON-RPC calls to the Marvell firmware in seconds, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		firmwareDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "opi_grpc_request_firmware_duration_seconds",
+			Help:    "Time the gRPC requests spent in the calls to the Marvell firmware in seconds, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		slowRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "opi_grpc_slow_requests_total",
+			Help: "Number of gRPC requests exceeding the latency budget of their method, by method.",
+		}, []string{"method"}),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.grpcRequests.Describe(ch)
+	m.grpcDuration.Describe(ch)
+	m.rpcRequests.Describe(ch)
+	m.rpcErrors.Describe(ch)
+	m.rpcDuration.Describe(ch)
+	m.firmwareDuration.Describe(ch)
+	m.slowRequests.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.grpcRequests.Collect(ch)
+	m.grpcDuration.Collect(ch)
+	m.rpcRequests.Collect(ch)
+	m.rpcErrors.Collect(ch)
+	m.rpcDuration.Collect(ch)
+	m.firmwareDuration.Collect(ch)
+	m.slowRequests.Collect(ch)
+}
+
+// UnaryServerInterceptor counts the gRPC requests and measures their duration and the time they
+// spent in the firmware, it comes first so the requests refused by the other interceptors are
+// counted too
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, firmware := withFirmwareTime(ctx)
+		resp, err := handler(ctx, req)
+		duration := time.Since(start)
+		m.grpcDuration.WithLabelValues(info.FullMethod).Observe(duration.Seconds())
+		m.grpcRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+		firmware.mu.Lock()
+		defer firmware.mu.Unlock()
+		m.firmwareDuration.WithLabelValues(info.FullMethod).Observe(firmware.total.Seconds())
+		budgets := m.budgets.Load()
+		if budgets == nil {
+			return resp, err
+		}
+		if budget := budgets.Budget(info.FullMethod); budget != 0 && duration > budget {
+			m.slowRequests.WithLabelValues(info.FullMethod).Inc()
+			// the time spent in the bridge tells whether the bridge or the firmware is slow
+			slog.WarnContext(ctx, "Slow gRPC request", "method", info.FullMethod, "resource", resourceName(req),
+				"code", status.Code(err).String(), "latency", duration, "budget", budget,
+				"bridge_latency", duration-firmware.total, "firmware_latency", firmware.total,
+				"firmware_calls", firmware.count, "calls", firmware.calls)
+		}
+		return resp, err
+	}
+}
+
+// WrapJSONRPC instruments a JSON-RPC client, the calls to the firmware are counted and timed
+func (m *Metrics) WrapJSONRPC(rpc spdk.JSONRPC) spdk.JSONRPC {
+	return &instrumentedJSONRPC{JSONRPC: rpc, metrics: m}
+}
+
+// instrumentedJSONRPC is a JSON-RPC client recording the calls to the firmware
+type instrumentedJSONRPC struct {
+	spdk.JSONRPC
+	metrics *Metrics
+}
+
+// Call implements spdk.JSONRPC
+func (c *instrumentedJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	start := time.Now()
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	duration := time.Since(start)
+	addFirmwareTime(ctx, method, duration)
+	c.metrics.rpcDuration.WithLabelValues(method).Observe(duration.Seconds())
+	c.metrics.rpcRequests.WithLabelValues(method).Inc()
+	// the Marvell methods report their failures in the status of their result
+	if err != nil || models.ResultStatus(result) != 0 {
+		c.metrics.rpcErrors.WithLabelValues(method).Inc()
+	}
+	return err
+}
+
+// ResourceCounter counts the resources of a server by collection, i.e. nvmeSubsystems
+type ResourceCounter interface {
+	ResourceCounts() map[string]int
+}
+
+var resourcesDesc = prometheus.NewDesc(
+	"opi_resources",
+	"Number of resources managed by the bridge, by collection.",
+	[]string{"collection"}, nil,
+)
+
+// ResourceCollector exports the number of resources of the servers
+type ResourceCollector struct {
+	counters []ResourceCounter
+}
+
+// NewResourceCollector creates a collector counting the resources on every scrape
+func NewResourceCollector(counters ...ResourceCounter) *ResourceCollector {
+	return &ResourceCollector{counters: counters}
+}
+
+// Describe implements prometheus.Collector
+func (c *ResourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resourcesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *ResourceCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := make(map[string]int)
+	for _, counter := range c.counters {
+		for collection, count := range counter.ResourceCounts() {
+			counts[collection] += count
+		}
+	}
+	for collection, count := range counts {
+		ch <- prometheus.MustNewConstMetric(resourcesDesc, prometheus.GaugeValue, float64(count), collection)
+	}
+}
+
+// CountCollections counts resource names by the collection of their last segment, i.e.
+// //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0 is an nvmeControllers
+func CountCollections(names map[string]bool) map[string]int {
+	counts := make(map[string]int)
+	for name := range names {
+		segments := strings.Split(strings.Trim(name, "/"), "/")
+		// full resource names start with the service name
+		if strings.HasPrefix(name, "//") {
+			segments = segments[1:]
+		}
+		if len(segments) < 2 {
+			continue
+		}
+		counts[segments[len(segments)-2]]++
+	}
+	return counts
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package metrics measures the gRPC requests served by the bridge and the Marvell RPCs it issues
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/spdk/spdktest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testMethod = "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"
+
+type testResourceCounter map[string]int
+
+func (c testResourceCounter) ResourceCounts() map[string]int {
+	return c
+}
+
+func TestMetrics_UnaryServerInterceptor(t *testing.T) {
+	metrics := New()
+	interceptor := metrics.UnaryServerInterceptor()
+	handlerErrors := []error{nil, nil, status.Error(codes.NotFound, "unable to find key")}
+	for _, handlerErr := range handlerErrors {
+		err := handlerErr
+		handler := func(_ context.Context, req interface{}) (interface{}, error) {
+			return req, err
+		}
+		_, received := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: testMethod}, handler)
+		if received != handlerErr {
+			t.Error("error: expected", handlerErr, "received", received)
+		}
+	}
+
+	if count := testutil.ToFloat64(metrics.grpcRequests.WithLabelValues(testMethod, codes.OK.String())); count != 2 {
+		t.Error("OK requests: expected", 2, "received", count)
+	}
+	if count := testutil.ToFloat64(metrics.grpcRequests.WithLabelValues(testMethod, codes.NotFound.String())); count != 1 {
+		t.Error("NotFound requests: expected", 1, "received", count)
+	}
+	if count := testutil.CollectAndCount(metrics.grpcDuration); count != 1 {
+		t.Error("duration series: expected", 1, "received", count)
+	}
+}
+
+func TestMetrics_WrapJSONRPC(t *testing.T) {
+	tests := map[string]struct {
+		status int
+		err    error
+		errors float64
+	}{
+		"successful call": {
+			status: 0,
+			err:    nil,
+			errors: 0,
+		},
+		"non zero status": {
+			status: 1,
+			err:    nil,
+			errors: 1,
+		},
+		"failed call": {
+			status: 0,
+			err:    errors.New("json response error: myopierr"),
+			errors: 1,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			metrics := New()
+			rpc := metrics.WrapJSONRPC(&spdktest.JSONRPC{Status: tt.status, Err: tt.err})
+
+			var result spdktest.StatusResult
+			err := rpc.Call(context.Background(), "mrvl_nvm_get_subsys_info", nil, &result)
+			if err != tt.err {
+				t.Error("error: expected", tt.err, "received", err)
+			}
+
+			if count := testutil.ToFloat64(metrics.rpcRequests.WithLabelValues("mrvl_nvm_get_subsys_info")); count != 1 {
+				t.Error("requests: expected", 1, "received", count)
+			}
+			if count := testutil.ToFloat64(metrics.rpcErrors.WithLabelValues("mrvl_nvm_get_subsys_info")); count != tt.errors {
+				t.Error("errors: expected", tt.errors, "received", count)
+			}
+		})
+	}
+}
+
+func TestMetrics_ResourceCollector(t *testing.T) {
+	frontend := testResourceCounter(CountCollections(map[string]bool{
+		"//storage.opiproject.org/nvmeSubsystems/subsys0":                         false,
+		"//storage.opiproject.org/nvmeSubsystems/subsys1":                         false,
+		"//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0":   false,
+		"//storage.opiproject.org/nvmeSubsystems/subsys0/nvmeNamespaces/ns0":      false,
+		"//storage.opiproject.org/nvmeRemoteControllers/nvmetcp12/nvmePaths/path": false,
+	}))
+	backend := testResourceCounter{"nvmeRemoteControllers": 1, "volumes": 2}
+
+	expected := `
+# HELP opi_resources Number of resources managed by the bridge, by collection.
+# TYPE opi_resources gauge
+opi_resources{collection="nvmeControllers"} 1
+opi_resources{collection="nvmeNamespaces"} 1
+opi_resources{collection="nvmePaths"} 1
+opi_resources{collection="nvmeRemoteControllers"} 1
+opi_resources{collection="nvmeSubsystems"} 2
+opi_resources{collection="volumes"} 2
+`
+	collector := NewResourceCollector(frontend, backend)
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package metrics measures the gRPC requests served by the bridge and the Marvell RPCs it issues
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLoggedFirmwareCalls bounds the firmware calls logged with a slow request
+const maxLoggedFirmwareCalls = 32
+
+// defaultBudgetMethod names the budget of the methods without their own
+const defaultBudgetMethod = "*"
+
+// LatencyBudgets are the latency budgets of the gRPC methods, a request taking longer is slow
+type LatencyBudgets struct {
+	methods  map[string]time.Duration
+	fallback time.Duration
+}
+
+// ParseLatencyBudgets parses comma separated method=duration budgets, i.e.
+// "*=1s,CreateNvmeController=5s,opi_api.storage.v1.FrontendNvmeService/StatsNvmeController=200ms".
+// A method is either a full gRPC method or a method name of any service, * is the budget of the
+// other methods
+func ParseLatencyBudgets(budgets string) (*LatencyBudgets, error) {
+	b := &LatencyBudgets{methods: make(map[string]time.Duration)}
+	for _, budget := range strings.Split(budgets, ",") {
+		method, value, ok := strings.Cut(strings.TrimSpace(budget), "=")
+		if !ok || method == "" {
+			return nil, fmt.Errorf("invalid latency budget %q, have to be method=duration", budget)
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid latency budget %q, have to be a positive duration, i.e. 500ms", budget)
+		}
+		if method == defaultBudgetMethod {
+			b.fallback = duration
+			continue
+		}
+		b.methods[strings.TrimPrefix(method, "/")] = duration
+	}
+	return b, nil
+}
+
+// Budget returns the budget of a full gRPC method, 0 when it has none
+func (b *LatencyBudgets) Budget(fullMethod string) time.Duration {
+	method := strings.TrimPrefix(fullMethod, "/")
+	if budget, ok := b.methods[method]; ok {
+		return budget
+	}
+	if budget, ok := b.methods[method[strings.LastIndex(method, "/")+1:]]; ok {
+		return budget
+	}
+	return b.fallback
+}
+
+// firmwareCall is a call to the firmware made by a request
+type firmwareCall struct {
+	method   string
+	duration time.Duration
+}
+
+// String implements fmt.Stringer
+func (c firmwareCall) String() string {
+	return c.method + "=" + c.duration.String()
+}
+
+// firmwareTime accumulates the time a request spent in the firmware, its calls are made
+// concurrently by some requests
+type firmwareTime struct {
+	mu    sync.Mutex
+	total time.Duration
+	count int
+	calls []firmwareCall
+}
+
+// add accounts a call to the firmware, only the first calls are kept to be logged
+func (f *firmwareTime) add(method string, duration time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.total += duration
+	f.count++
+	if len(f.calls) < maxLoggedFirmwareCalls {
+		f.calls = append(f.calls, firmwareCall{method: method, duration: duration})
+	}
+}
+
+// firmwareTimeKey is the context key of the time a request spent in the firmware
+type firmwareTimeKey struct{}
+
+// withFirmwareTime returns a context accumulating the time spent in the firmware
+func withFirmwareTime(ctx context.Context) (context.Context, *firmwareTime) {
+	f := new(firmwareTime)
+	return context.WithValue(ctx, firmwareTimeKey{}, f), f
+}
+
+// addFirmwareTime accounts a call to the firmware to the request of a context, if any
+func addFirmwareTime(ctx context.Context, method string, duration time.Duration) {
+	if f, ok := ctx.Value(firmwareTimeKey{}).(*firmwareTime); ok {
+		f.add(method, duration)
+	}
+}
+
+// SetLatencyBudgets sets the budgets of the gRPC methods, the requests exceeding theirs are
+// counted and logged with the time they spent in the bridge and in the firmware, nil disables them
+func (m *Metrics) SetLatencyBudgets(budgets *LatencyBudgets) {
+	m.budgets.Store(budgets)
+}
+
+// resourceName returns the name of the resource of a request, its parent for the creations
+func resourceName(req interface{}) string {
+	if r, ok := req.(interface{ GetName() string }); ok && r.GetName() != "" {
+		return r.GetName()
+	}
+	if r, ok := req.(interface{ GetParent() string }); ok {
+		return r.GetParent()
+	}
+	return ""
+}
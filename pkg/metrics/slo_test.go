@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package metrics measures the gRPC requests served by the bridge and the Marvell RPCs it issues
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/spdk/spdktest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"google.golang.org/grpc"
+)
+
+// testSlowJSONRPC answers the calls after a delay
+type testSlowJSONRPC struct {
+	spdktest.JSONRPC
+	delay time.Duration
+}
+
+func (c *testSlowJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	time.Sleep(c.delay)
+	return c.JSONRPC.Call(ctx, method, args, result)
+}
+
+func TestMetrics_ParseLatencyBudgets(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		budgets map[string]time.Duration
+		errMsg  string
+	}{
+		"valid budgets": {
+			in: "*=1s, CreateNvmeController=5s,/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem=200ms",
+			budgets: map[string]time.Duration{
+				testMethod: 200 * time.Millisecond,
+				"/opi_api.storage.v1.FrontendNvmeService/CreateNvmeController":               5 * time.Second,
+				"/opi_api.storage.v1.NvmeRemoteControllerService/CreateNvmeRemoteController": 1 * time.Second,
+			},
+			errMsg: "",
+		},
+		"method name only": {
+			in: "GetNvmeSubsystem=10ms",
+			budgets: map[string]time.Duration{
+				testMethod: 10 * time.Millisecond,
+				"/opi_api.storage.v1.FrontendNvmeService/CreateNvmeController": 0,
+			},
+			errMsg: "",
+		},
+		"missing duration": {
+			in:      "*",
+			budgets: nil,
+			errMsg:  `invalid latency budget "*", have to be method=duration`,
+		},
+		"invalid duration": {
+			in:      "*=fast",
+			budgets: nil,
+			errMsg:  `invalid latency budget "*=fast", have to be a positive duration, i.e. 500ms`,
+		},
+		"negative duration": {
+			in:      "*=-1s",
+			budgets: nil,
+			errMsg:  `invalid latency budget "*=-1s", have to be a positive duration, i.e. 500ms`,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			budgets, err := ParseLatencyBudgets(tt.in)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for method, expected := range tt.budgets {
+				if budget := budgets.Budget(method); budget != expected {
+					t.Error("budget of", method, "expected", expected, "received", budget)
+				}
+			}
+		})
+	}
+}
+
+func TestMetrics_SlowRequests(t *testing.T) {
+	tests := map[string]struct {
+		budgets string
+		slow    float64
+	}{
+		"within budget": {
+			budgets: "*=1h",
+			slow:    0,
+		},
+		"over budget": {
+			budgets: "GetNvmeSubsystem=1ms",
+			slow:    1,
+		},
+		"without budget": {
+			budgets: "CreateNvmeController=1ms",
+			slow:    0,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs bytes.Buffer
+			previous := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+			defer slog.SetDefault(previous)
+
+			metrics := New()
+			budgets, err := ParseLatencyBudgets(tt.budgets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			metrics.SetLatencyBudgets(budgets)
+			rpc := metrics.WrapJSONRPC(&testSlowJSONRPC{delay: 5 * time.Millisecond})
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				var result spdktest.StatusResult
+				return req, rpc.Call(ctx, "mrvl_nvm_get_subsys_info", nil, &result)
+			}
+			in := &pb.GetNvmeSubsystemRequest{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0"}
+			if _, err := metrics.UnaryServerInterceptor()(context.Background(), in, &grpc.UnaryServerInfo{FullMethod: testMethod}, handler); err != nil {
+				t.Fatal(err)
+			}
+
+			if count := testutil.ToFloat64(metrics.slowRequests.WithLabelValues(testMethod)); count != tt.slow {
+				t.Error("slow requests: expected", tt.slow, "received", count)
+			}
+			if count := testutil.CollectAndCount(metrics.firmwareDuration); count != 1 {
+				t.Error("firmware duration series: expected", 1, "received", count)
+			}
+			logged := strings.Contains(logs.String(), "Slow gRPC request")
+			if logged != (tt.slow != 0) {
+				t.Error("expected slow request logged", tt.slow != 0, "received", logs.String())
+			}
+			for _, field := range []string{"resource=//storage.opiproject.org/nvmeSubsystems/subsys0", "budget=1ms", "firmware_calls=1", `calls="[mrvl_nvm_get_subsys_info=`, "bridge_latency="} {
+				if logged && !strings.Contains(logs.String(), field) {
+					t.Error("expected", field, "logged, received", logs.String())
+				}
+			}
+		})
+	}
+}
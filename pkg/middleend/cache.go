@@ -0,0 +1,404 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Modes of the cache of a cached volume
+const (
+	cacheModeWriteThrough = "WRITE_THROUGH"
+	cacheModeWriteBack    = "WRITE_BACK"
+)
+
+// cacheModes maps the cache modes to the ones of the firmware
+var cacheModes = map[string]string{
+	cacheModeWriteThrough: "wt",
+	cacheModeWriteBack:    "wb",
+}
+
+// CachedVolume represents a slow backend volume, i.e. NVMe-oF or RBD, cached on a local
+// NVMe device of the DPU
+type CachedVolume struct {
+	// Name of the cached volume, its resource ID is the volume name used by the namespaces
+	Name string `json:"name"`
+	// VolumeNameRef is the backend volume which is cached
+	VolumeNameRef string `json:"volumeNameRef"`
+	// CacheVolumeNameRef is the local volume the data is cached on
+	CacheVolumeNameRef string `json:"cacheVolumeNameRef"`
+	// Mode is WRITE_THROUGH (default) or WRITE_BACK, with write-back the writes are
+	// acknowledged once cached and flushed to the backend volume later
+	Mode string `json:"mode"`
+}
+
+// CreateCachedVolumeRequest represents a request to create a cached volume
+type CreateCachedVolumeRequest struct {
+	// CachedVolumeID is the ID of the cached volume, generated when empty
+	CachedVolumeID string `json:"cachedVolumeId"`
+	// CachedVolume to create
+	CachedVolume *CachedVolume `json:"cachedVolume"`
+}
+
+// DeleteCachedVolumeRequest represents a request to delete a cached volume
+type DeleteCachedVolumeRequest struct {
+	// Name of the cached volume
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the volume doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetCachedVolumeRequest represents a request to get a cached volume
+type GetCachedVolumeRequest struct {
+	// Name of the cached volume
+	Name string `json:"name"`
+}
+
+// ListCachedVolumesRequest represents a request to list cached volumes
+type ListCachedVolumesRequest struct {
+	// PageSize is the maximum number of volumes returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListCachedVolumesResponse represents a list of cached volumes
+type ListCachedVolumesResponse struct {
+	// CachedVolumes is the page of volumes
+	CachedVolumes []*CachedVolume `json:"cachedVolumes"`
+	// NextPageToken is set when more volumes are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// SetCachedVolumeModeRequest represents a request to change the cache mode of a cached volume
+type SetCachedVolumeModeRequest struct {
+	// Name of the cached volume
+	Name string `json:"name"`
+	// Mode is WRITE_THROUGH or WRITE_BACK
+	Mode string `json:"mode"`
+}
+
+// StatsCachedVolumeRequest represents a request to get the cache statistics of a volume
+type StatsCachedVolumeRequest struct {
+	// Name of the cached volume
+	Name string `json:"name"`
+}
+
+// CachedVolumeStats represents the cache statistics of a volume
+type CachedVolumeStats struct {
+	// ReadHits is the number of reads served from the cache
+	ReadHits uint64 `json:"readHits"`
+	// ReadMisses is the number of reads served from the backend volume
+	ReadMisses uint64 `json:"readMisses"`
+	// WriteHits is the number of writes to blocks already in the cache
+	WriteHits uint64 `json:"writeHits"`
+	// WriteMisses is the number of writes to blocks not in the cache
+	WriteMisses uint64 `json:"writeMisses"`
+	// DirtyBytes is the amount of data not flushed yet to the backend volume
+	DirtyBytes uint64 `json:"dirtyBytes"`
+	// ReadHitRatePercent is ReadHits in percent of all reads
+	ReadHitRatePercent float64 `json:"readHitRatePercent"`
+	// HitRatePercent is the hits in percent of all reads and writes
+	HitRatePercent float64 `json:"hitRatePercent"`
+}
+
+// resourceIDToCachedVolumeName builds the name of a cached volume, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToCachedVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"cachedVolumes", resourceID,
+	)
+}
+
+func sortCachedVolumes(volumes []*CachedVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// cacheModeFromFirmware maps a cache mode of the firmware back to the one of the API
+func cacheModeFromFirmware(mode string) string {
+	for m, fw := range cacheModes {
+		if fw == mode {
+			return m
+		}
+	}
+	return mode
+}
+
+// hitRate computes the hits in percent of all accesses, 0 when there was no access
+func hitRate(hits uint64, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) * 100 / float64(hits+misses)
+}
+
+// CreateCachedVolume creates a cached volume, the data of the backend volume is cached on
+// the local volume by the Open CAS Framework of the DPU
+func (s *Server) CreateCachedVolume(ctx context.Context, in *CreateCachedVolumeRequest) (*CachedVolume, error) {
+	// check input correctness
+	if err := s.validateCreateCachedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.CachedVolumeID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.CachedVolumeID, "name", in.CachedVolume.Name)
+		resourceID = in.CachedVolumeID
+	}
+	name := resourceIDToCachedVolumeName(resourceID)
+	// idempotent API when called with same key, should return same object
+	volume, found, err := s.getCachedVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing CachedVolume", "name", name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	volume = &CachedVolume{
+		Name:               name,
+		VolumeNameRef:      in.CachedVolume.VolumeNameRef,
+		CacheVolumeNameRef: in.CachedVolume.CacheVolumeNameRef,
+		Mode:               in.CachedVolume.Mode,
+	}
+	if volume.Mode == "" {
+		volume.Mode = cacheModeWriteThrough
+	}
+	params := models.MrvlBdevOcfCreateParams{
+		Name:          resourceID,
+		Mode:          cacheModes[volume.Mode],
+		CacheBdevName: volume.CacheVolumeNameRef,
+		CoreBdevName:  volume.VolumeNameRef,
+	}
+	var result models.MrvlBdevOcfCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_ocf_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create OCF Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_ocf_create")
+	}
+	err = s.saveCachedVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// DeleteCachedVolume deletes a cached volume, the dirty data is flushed to the backend volume first
+func (s *Server) DeleteCachedVolume(ctx context.Context, in *DeleteCachedVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteCachedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getCachedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevOcfDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevOcfDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_ocf_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete OCF Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_ocf_delete")
+	}
+	// remove from the Database
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListCachedVolumes lists cached volumes
+func (s *Server) ListCachedVolumes(ctx context.Context, in *ListCachedVolumesRequest) (*ListCachedVolumesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	var result models.MrvlBdevOcfGetListResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_ocf_get_list", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list OCF Devs"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_ocf_get_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.OcfList), "offset", offset, "size", size)
+	result.OcfList, hasMoreElements = utils.LimitPagination(result.OcfList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*CachedVolume, len(result.OcfList))
+	for i := range result.OcfList {
+		r := &result.OcfList[i]
+		Blobarray[i] = &CachedVolume{
+			Name:               resourceIDToCachedVolumeName(r.Name),
+			VolumeNameRef:      r.CoreBdevName,
+			CacheVolumeNameRef: r.CacheBdevName,
+			Mode:               cacheModeFromFirmware(r.Mode),
+		}
+	}
+	sortCachedVolumes(Blobarray)
+	return &ListCachedVolumesResponse{CachedVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetCachedVolume gets a cached volume
+func (s *Server) GetCachedVolume(_ context.Context, in *GetCachedVolumeRequest) (*CachedVolume, error) {
+	// check input correctness
+	if err := s.validateGetCachedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getCachedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// SetCachedVolumeMode switches a cached volume between write-through and write-back,
+// switching to write-through flushes the dirty data to the backend volume
+func (s *Server) SetCachedVolumeMode(ctx context.Context, in *SetCachedVolumeModeRequest) (*CachedVolume, error) {
+	// check input correctness
+	if err := s.validateSetCachedVolumeModeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getCachedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if volume.Mode == in.Mode {
+		return volume, nil
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevOcfSetCacheModeParams{
+		Name: resourceID,
+		Mode: cacheModes[in.Mode],
+	}
+	var result models.MrvlBdevOcfSetCacheModeResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_ocf_set_cache_mode", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not set cache mode of OCF Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_ocf_set_cache_mode")
+	}
+	volume.Mode = in.Mode
+	err = s.saveCachedVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// StatsCachedVolume gets the hit rate and the dirty data of a cached volume
+func (s *Server) StatsCachedVolume(ctx context.Context, in *StatsCachedVolumeRequest) (*CachedVolumeStats, error) {
+	// check input correctness
+	if err := s.validateStatsCachedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getCachedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevOcfGetStatsParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevOcfGetStatsResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_ocf_get_stats", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get stats of OCF Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_ocf_get_stats")
+	}
+	return &CachedVolumeStats{
+		ReadHits:           result.ReadHits,
+		ReadMisses:         result.ReadMisses,
+		WriteHits:          result.WriteHits,
+		WriteMisses:        result.WriteMisses,
+		DirtyBytes:         result.DirtyBytes,
+		ReadHitRatePercent: hitRate(result.ReadHits, result.ReadMisses),
+		HitRatePercent:     hitRate(result.ReadHits+result.WriteHits, result.ReadMisses+result.WriteMisses),
+	}, nil
+}
+
+// getCachedVolume fetches a cached volume from the database,
+// cached volumes are not protobufs so they are stored JSON encoded
+func (s *Server) getCachedVolume(name string) (*CachedVolume, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	volume := new(CachedVolume)
+	if err := json.Unmarshal(value.Value, volume); err != nil {
+		return nil, false, err
+	}
+	return volume, true, nil
+}
+
+func (s *Server) saveCachedVolume(volume *CachedVolume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(volume.Name, wrapperspb.Bytes(data))
+}
@@ -0,0 +1,642 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestMiddleEnd_CreateCachedVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *CachedVolume
+		out     *CachedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"unsupported algorithm": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43", Mode: "WRITE_AROUND"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Mode value (%s) is not supported, have to be WRITE_THROUGH or WRITE_BACK", "WRITE_AROUND"),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create OCF Dev: %v", testCachedVolumeID),
+			exist:   false,
+		},
+		"valid request with empty SPDK response": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43"},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_create: %v", "EOF"),
+			exist:   false,
+		},
+		"valid request with ID mismatch SPDK response": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43"},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_create: %v", "json response ID mismatch"),
+			exist:   false,
+		},
+		"valid request with error code from SPDK response": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_create: %v", "json response error: myopierr"),
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43"},
+			out:     &testCachedVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"valid request with deflate": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43", Mode: "WRITE_BACK"},
+			out:     &CachedVolume{Name: testCachedVolumeName, VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43", Mode: "WRITE_BACK"},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43"},
+			out:     &testCachedVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"backend volume as its own cache": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Volume %s can't be its own cache", "volume-42"),
+			exist:   false,
+		},
+		"no required cache_volume_name_ref field": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: cached_volume.cache_volume_name_ref",
+			exist:   false,
+		},
+		"no required volume_name_ref field": {
+			id:      testCachedVolumeID,
+			in:      &CachedVolume{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: cached_volume.volume_name_ref",
+			exist:   false,
+		},
+		"no required field": {
+			id:      testCachedVolumeID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: cached_volume",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveCachedVolume(&testCachedVolume)
+			}
+
+			request := &CreateCachedVolumeRequest{CachedVolume: tt.in, CachedVolumeID: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateCachedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteCachedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testCachedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete OCF Dev: %v", testCachedVolumeID),
+			missing: false,
+		},
+		"valid request with empty SPDK response": {
+			in:      testCachedVolumeName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_delete: %v", "EOF"),
+			missing: false,
+		},
+		"valid request with error code from SPDK response": {
+			in:      testCachedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_delete: %v", "json response error: myopierr"),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testCachedVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCachedVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCachedVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToCachedVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveCachedVolume(&testCachedVolume)
+
+			request := &DeleteCachedVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteCachedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_ListCachedVolumes(t *testing.T) {
+	testOcfList := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "ocf_list": [{"name": "cached1", "core_bdev_name": "Nvme1n1", "cache_bdev_name": "Malloc1", "mode": "wb"},{"name": "cached0", "core_bdev_name": "Nvme0n1", "cache_bdev_name": "Malloc0", "mode": "wt"}]}}`
+	tests := map[string]struct {
+		out     []*CachedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		size    int32
+		token   string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list OCF Devs",
+			size:    0,
+			token:   "",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_get_list: %v", "EOF"),
+			size:    0,
+			token:   "",
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_get_list: %v", "json response error: myopierr"),
+			size:    0,
+			token:   "",
+		},
+		"pagination negative": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+		},
+		"pagination error": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+		},
+		"pagination": {
+			out: []*CachedVolume{
+				{Name: resourceIDToCachedVolumeName("cached1"), VolumeNameRef: "Nvme1n1", CacheVolumeNameRef: "Malloc1", Mode: "WRITE_BACK"},
+			},
+			spdk:    []string{testOcfList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+		},
+		"valid request with valid SPDK response": {
+			out: []*CachedVolume{
+				{Name: resourceIDToCachedVolumeName("cached0"), VolumeNameRef: "Nvme0n1", CacheVolumeNameRef: "Malloc0", Mode: "WRITE_THROUGH"},
+				{Name: resourceIDToCachedVolumeName("cached1"), VolumeNameRef: "Nvme1n1", CacheVolumeNameRef: "Malloc1", Mode: "WRITE_BACK"},
+			},
+			spdk:    []string{testOcfList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &ListCachedVolumesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListCachedVolumes(testEnv.ctx, request)
+
+			var volumes []*CachedVolume
+			if response != nil {
+				volumes = response.CachedVolumes
+				// Empty NextPageToken indicates end of results list
+				if tt.size != 1 && response.NextPageToken != "" {
+					t.Error("Expected end of results, receieved non-empty next page token", response.NextPageToken)
+				}
+			}
+			if !reflect.DeepEqual(volumes, tt.out) {
+				t.Error("response: expected", tt.out, "received", volumes)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_GetCachedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *CachedVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testCachedVolumeName,
+			out:     &testCachedVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCachedVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCachedVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveCachedVolume(&testCachedVolume)
+
+			request := &GetCachedVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetCachedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_SetCachedVolumeMode(t *testing.T) {
+	tests := map[string]struct {
+		in      *SetCachedVolumeModeRequest
+		out     *CachedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &SetCachedVolumeModeRequest{Name: testCachedVolumeName, Mode: "WRITE_BACK"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not set cache mode of OCF Dev: %v", testCachedVolumeID),
+		},
+		"valid request with empty SPDK response": {
+			in:      &SetCachedVolumeModeRequest{Name: testCachedVolumeName, Mode: "WRITE_BACK"},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_set_cache_mode: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &SetCachedVolumeModeRequest{Name: testCachedVolumeName, Mode: "WRITE_BACK"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_set_cache_mode: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      &SetCachedVolumeModeRequest{Name: testCachedVolumeName, Mode: "WRITE_BACK"},
+			out:     &CachedVolume{Name: testCachedVolumeName, VolumeNameRef: "volume-42", CacheVolumeNameRef: "volume-43", Mode: "WRITE_BACK"},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"same mode": {
+			in:      &SetCachedVolumeModeRequest{Name: testCachedVolumeName, Mode: "WRITE_THROUGH"},
+			out:     &testCachedVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"unsupported mode": {
+			in:      &SetCachedVolumeModeRequest{Name: testCachedVolumeName, Mode: "WRITE_AROUND"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Mode value (%s) is not supported, have to be WRITE_THROUGH or WRITE_BACK", "WRITE_AROUND"),
+		},
+		"valid request with unknown key": {
+			in:      &SetCachedVolumeModeRequest{Name: resourceIDToCachedVolumeName("unknown-id"), Mode: "WRITE_BACK"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCachedVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      &SetCachedVolumeModeRequest{Name: "-ABC-DEF", Mode: "WRITE_BACK"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &SetCachedVolumeModeRequest{Name: testCachedVolumeName},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: mode",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveCachedVolume(&testCachedVolume)
+
+			response, err := testEnv.opiSpdkServer.SetCachedVolumeMode(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_StatsCachedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *CachedVolumeStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testCachedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get stats of OCF Dev: %v", testCachedVolumeID),
+		},
+		"valid request with empty SPDK response": {
+			in:      testCachedVolumeName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_get_stats: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      testCachedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_get_stats: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      testCachedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_ocf_get_stats: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: testCachedVolumeName,
+			out: &CachedVolumeStats{
+				ReadHits:           75,
+				ReadMisses:         25,
+				WriteHits:          5,
+				WriteMisses:        95,
+				DirtyBytes:         8192,
+				ReadHitRatePercent: 75,
+				HitRatePercent:     40,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "read_hits": 75, "read_misses": 25, "write_hits": 5, "write_misses": 95, "dirty_bytes": 8192}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request on an idle volume": {
+			in:      testCachedVolumeName,
+			out:     &CachedVolumeStats{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCachedVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCachedVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveCachedVolume(&testCachedVolume)
+
+			request := &StatsCachedVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsCachedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateCachedVolumeRequest(in *CreateCachedVolumeRequest) error {
+	// check required fields
+	if in.CachedVolume == nil {
+		return errors.New("missing required field: cached_volume")
+	}
+	if in.CachedVolume.VolumeNameRef == "" {
+		return errors.New("missing required field: cached_volume.volume_name_ref")
+	}
+	if in.CachedVolume.CacheVolumeNameRef == "" {
+		return errors.New("missing required field: cached_volume.cache_volume_name_ref")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.CachedVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(in.CachedVolumeID); err != nil {
+			return err
+		}
+	}
+	if in.CachedVolume.VolumeNameRef == in.CachedVolume.CacheVolumeNameRef {
+		msg := fmt.Sprintf("Volume %s can't be its own cache", in.CachedVolume.VolumeNameRef)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if in.CachedVolume.Mode != "" {
+		return validateCacheMode(in.CachedVolume.Mode)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteCachedVolumeRequest(in *DeleteCachedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetCachedVolumeRequest(in *GetCachedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateSetCachedVolumeModeRequest(in *SetCachedVolumeModeRequest) error {
+	// check required fields
+	if in.Mode == "" {
+		return errors.New("missing required field: mode")
+	}
+	if err := validateResourceName(in.Name); err != nil {
+		return err
+	}
+	return validateCacheMode(in.Mode)
+}
+
+func (s *Server) validateStatsCachedVolumeRequest(in *StatsCachedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+// validateCacheMode checks the mode is one of the cache modes of the firmware
+func validateCacheMode(mode string) error {
+	if _, ok := cacheModes[mode]; !ok {
+		msg := fmt.Sprintf("Mode value (%s) is not supported, have to be WRITE_THROUGH or WRITE_BACK", mode)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// maxCloneLineageDepth bounds the walk of the lineage of a clone
+const maxCloneLineageDepth = 64
+
+// Clone represents a writable thin copy of a snapshot, only the blocks written to the
+// clone are allocated, the others are read from the snapshot
+type Clone struct {
+	// Name of the clone, its resource ID is the volume name used by the namespaces
+	Name string `json:"name"`
+	// SnapshotNameRef is the snapshot the clone is created from
+	SnapshotNameRef string `json:"snapshotNameRef"`
+}
+
+// CreateCloneRequest represents a request to create a clone of a snapshot
+type CreateCloneRequest struct {
+	// CloneID is the ID of the clone, generated when empty
+	CloneID string `json:"cloneId"`
+	// Clone to create
+	Clone *Clone `json:"clone"`
+}
+
+// DeleteCloneRequest represents a request to delete a clone
+type DeleteCloneRequest struct {
+	// Name of the clone
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the clone doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetCloneRequest represents a request to get a clone
+type GetCloneRequest struct {
+	// Name of the clone
+	Name string `json:"name"`
+}
+
+// ListClonesRequest represents a request to list clones
+type ListClonesRequest struct {
+	// PageSize is the maximum number of clones returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListClonesResponse represents a list of clones
+type ListClonesResponse struct {
+	// Clones is the page of clones
+	Clones []*Clone `json:"clones"`
+	// NextPageToken is set when more clones are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// GetCloneLineageRequest represents a request to get the lineage of a clone
+type GetCloneLineageRequest struct {
+	// Name of the clone
+	Name string `json:"name"`
+}
+
+// CloneLineage represents the snapshots a clone descends from
+type CloneLineage struct {
+	// Name of the clone
+	Name string `json:"name"`
+	// Snapshots lists the ancestors of the clone, the snapshot it was created from first,
+	// the volume of the last one is the original volume, i.e. the golden image
+	Snapshots []*Snapshot `json:"snapshots"`
+}
+
+// resourceIDToCloneName builds the name of a clone, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToCloneName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"clones", resourceID,
+	)
+}
+
+func sortClones(clones []*Clone) {
+	sort.Slice(clones, func(i int, j int) bool {
+		return clones[i].Name < clones[j].Name
+	})
+}
+
+// CreateClone creates a writable copy-on-write clone of a snapshot, i.e. to provision
+// VM volumes from a golden image without copying it
+func (s *Server) CreateClone(ctx context.Context, in *CreateCloneRequest) (*Clone, error) {
+	// check input correctness
+	if err := s.validateCreateCloneRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.CloneID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.CloneID, "name", in.Clone.Name)
+		resourceID = in.CloneID
+	}
+	name := resourceIDToCloneName(resourceID)
+	// idempotent API when called with same key, should return same object
+	clone, found, err := s.getClone(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing Clone", "name", name)
+		return clone, nil
+	}
+	// fetch the snapshot from the database
+	snapshot, found, err := s.getSnapshot(in.Clone.SnapshotNameRef)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Clone.SnapshotNameRef)
+		return nil, err
+	}
+	// not found, so create a new one
+	params := models.MrvlBdevCloneCreateParams{
+		Name:         resourceID,
+		SnapshotName: path.Base(snapshot.Name),
+	}
+	var result models.MrvlBdevCloneCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_clone_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Clone: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_clone_create")
+	}
+	clone = &Clone{
+		Name:            name,
+		SnapshotNameRef: snapshot.Name,
+	}
+	err = s.saveClone(clone)
+	if err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// DeleteClone deletes a clone
+func (s *Server) DeleteClone(ctx context.Context, in *DeleteCloneRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteCloneRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	clone, found, err := s.getClone(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(clone.Name)
+	params := models.MrvlBdevCloneDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevCloneDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_clone_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Clone: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_clone_delete")
+	}
+	// remove from the Database
+	err = s.store.Delete(clone.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListClones lists clones
+func (s *Server) ListClones(ctx context.Context, in *ListClonesRequest) (*ListClonesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	var result models.MrvlBdevCloneGetListResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_clone_get_list", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list Clones"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_clone_get_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.CloneList), "offset", offset, "size", size)
+	result.CloneList, hasMoreElements = utils.LimitPagination(result.CloneList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*Clone, len(result.CloneList))
+	for i := range result.CloneList {
+		r := &result.CloneList[i]
+		Blobarray[i] = &Clone{
+			Name:            resourceIDToCloneName(r.Name),
+			SnapshotNameRef: resourceIDToSnapshotName(r.SnapshotName),
+		}
+	}
+	sortClones(Blobarray)
+	return &ListClonesResponse{Clones: Blobarray, NextPageToken: token}, nil
+}
+
+// GetClone gets a clone
+func (s *Server) GetClone(_ context.Context, in *GetCloneRequest) (*Clone, error) {
+	// check input correctness
+	if err := s.validateGetCloneRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	clone, found, err := s.getClone(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return clone, nil
+}
+
+// GetCloneLineage walks the snapshots a clone descends from, through the clones the
+// snapshots were taken from, up to the original volume
+func (s *Server) GetCloneLineage(_ context.Context, in *GetCloneLineageRequest) (*CloneLineage, error) {
+	// check input correctness
+	if err := s.validateGetCloneLineageRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	clone, found, err := s.getClone(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	lineage := &CloneLineage{Name: clone.Name}
+	for clone != nil && len(lineage.Snapshots) < maxCloneLineageDepth {
+		snapshot, found, err := s.getSnapshot(clone.SnapshotNameRef)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// the lineage is reported up to the snapshots known to the bridge
+			slog.Warn("Lineage is broken", "name", lineage.Name, "snapshot", clone.SnapshotNameRef)
+			break
+		}
+		lineage.Snapshots = append(lineage.Snapshots, snapshot)
+		// the snapshot may have been taken from another clone
+		clone, _, err = s.getClone(resourceIDToCloneName(snapshot.VolumeNameRef))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lineage, nil
+}
+
+// getClone fetches a clone from the database,
+// clones are not protobufs so they are stored JSON encoded
+func (s *Server) getClone(name string) (*Clone, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	clone := new(Clone)
+	if err := json.Unmarshal(value.Value, clone); err != nil {
+		return nil, false, err
+	}
+	return clone, true, nil
+}
+
+func (s *Server) saveClone(clone *Clone) error {
+	data, err := json.Marshal(clone)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(clone.Name, wrapperspb.Bytes(data))
+}
@@ -0,0 +1,504 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestMiddleEnd_CreateClone(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *Clone
+		out     *Clone
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &Clone{SnapshotNameRef: testSnapshotName},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			id:      testCloneID,
+			in:      &Clone{SnapshotNameRef: testSnapshotName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Clone: %v", testCloneID),
+			exist:   false,
+		},
+		"valid request with empty SPDK response": {
+			id:      testCloneID,
+			in:      &Clone{SnapshotNameRef: testSnapshotName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_clone_create: %v", "EOF"),
+			exist:   false,
+		},
+		"valid request with ID mismatch SPDK response": {
+			id:      testCloneID,
+			in:      &Clone{SnapshotNameRef: testSnapshotName},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_clone_create: %v", "json response ID mismatch"),
+			exist:   false,
+		},
+		"valid request with error code from SPDK response": {
+			id:      testCloneID,
+			in:      &Clone{SnapshotNameRef: testSnapshotName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_clone_create: %v", "json response error: myopierr"),
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			id:      testCloneID,
+			in:      &Clone{SnapshotNameRef: testSnapshotName},
+			out:     &testClone,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testCloneID,
+			in:      &Clone{SnapshotNameRef: testSnapshotName},
+			out:     &testClone,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"unknown snapshot": {
+			id:      testCloneID,
+			in:      &Clone{SnapshotNameRef: resourceIDToSnapshotName("unknown-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToSnapshotName("unknown-id")),
+			exist:   false,
+		},
+		"malformed snapshot name": {
+			id:      testCloneID,
+			in:      &Clone{SnapshotNameRef: "-ABC-DEF"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			exist:   false,
+		},
+		"no required snapshot_name_ref field": {
+			id:      testCloneID,
+			in:      &Clone{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: clone.snapshot_name_ref",
+			exist:   false,
+		},
+		"no required field": {
+			id:      testCloneID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: clone",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveSnapshot(&testSnapshot)
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveClone(&testClone)
+			}
+
+			request := &CreateCloneRequest{Clone: tt.in, CloneID: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateClone(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteClone(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testCloneName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Clone: %v", testCloneID),
+			missing: false,
+		},
+		"valid request with empty SPDK response": {
+			in:      testCloneName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_clone_delete: %v", "EOF"),
+			missing: false,
+		},
+		"valid request with error code from SPDK response": {
+			in:      testCloneName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_clone_delete: %v", "json response error: myopierr"),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testCloneName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCloneName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCloneName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToCloneName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveClone(&testClone)
+
+			request := &DeleteCloneRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteClone(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_ListClones(t *testing.T) {
+	testCloneList := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "clone_list": [{"name": "clone1", "snapshot_name": "snapshot1"},{"name": "clone0", "snapshot_name": "snapshot0"}]}}`
+	tests := map[string]struct {
+		out     []*Clone
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		size    int32
+		token   string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list Clones",
+			size:    0,
+			token:   "",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_clone_get_list: %v", "EOF"),
+			size:    0,
+			token:   "",
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_clone_get_list: %v", "json response error: myopierr"),
+			size:    0,
+			token:   "",
+		},
+		"pagination negative": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+		},
+		"pagination error": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+		},
+		"pagination": {
+			out: []*Clone{
+				{Name: resourceIDToCloneName("clone1"), SnapshotNameRef: resourceIDToSnapshotName("snapshot1")},
+			},
+			spdk:    []string{testCloneList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+		},
+		"valid request with valid SPDK response": {
+			out: []*Clone{
+				{Name: resourceIDToCloneName("clone0"), SnapshotNameRef: resourceIDToSnapshotName("snapshot0")},
+				{Name: resourceIDToCloneName("clone1"), SnapshotNameRef: resourceIDToSnapshotName("snapshot1")},
+			},
+			spdk:    []string{testCloneList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &ListClonesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListClones(testEnv.ctx, request)
+
+			var volumes []*Clone
+			if response != nil {
+				volumes = response.Clones
+				// Empty NextPageToken indicates end of results list
+				if tt.size != 1 && response.NextPageToken != "" {
+					t.Error("Expected end of results, receieved non-empty next page token", response.NextPageToken)
+				}
+			}
+			if !reflect.DeepEqual(volumes, tt.out) {
+				t.Error("response: expected", tt.out, "received", volumes)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_GetClone(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *Clone
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testCloneName,
+			out:     &testClone,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCloneName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCloneName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveClone(&testClone)
+
+			request := &GetCloneRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetClone(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_GetCloneLineage(t *testing.T) {
+	// golden image snapshot -> clone-42 -> snapshot of clone-42 -> clone-43
+	testCloneSnapshot := Snapshot{
+		Name:          resourceIDToSnapshotName("snapshot-43"),
+		VolumeNameRef: testCloneID,
+		CreateTime:    "2024-01-03T03:04:05Z",
+	}
+	testGrandClone := Clone{
+		Name:            resourceIDToCloneName("clone-43"),
+		SnapshotNameRef: testCloneSnapshot.Name,
+	}
+	tests := map[string]struct {
+		in      string
+		out     *CloneLineage
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in: testCloneName,
+			out: &CloneLineage{
+				Name:      testCloneName,
+				Snapshots: []*Snapshot{&testSnapshot},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request on a clone of a clone": {
+			in: testGrandClone.Name,
+			out: &CloneLineage{
+				Name:      testGrandClone.Name,
+				Snapshots: []*Snapshot{&testCloneSnapshot, &testSnapshot},
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCloneName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCloneName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveSnapshot(&testSnapshot)
+			_ = testEnv.opiSpdkServer.saveClone(&testClone)
+			_ = testEnv.opiSpdkServer.saveSnapshot(&testCloneSnapshot)
+			_ = testEnv.opiSpdkServer.saveClone(&testGrandClone)
+
+			request := &GetCloneLineageRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetCloneLineage(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
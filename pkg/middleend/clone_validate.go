@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+)
+
+func (s *Server) validateCreateCloneRequest(in *CreateCloneRequest) error {
+	// check required fields
+	if in.Clone == nil {
+		return errors.New("missing required field: clone")
+	}
+	if in.Clone.SnapshotNameRef == "" {
+		return errors.New("missing required field: clone.snapshot_name_ref")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.CloneID != "" {
+		if err := resourceid.ValidateUserSettable(in.CloneID); err != nil {
+			return err
+		}
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Clone.SnapshotNameRef)
+}
+
+func (s *Server) validateDeleteCloneRequest(in *DeleteCloneRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetCloneRequest(in *GetCloneRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetCloneLineageRequest(in *GetCloneLineageRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Algorithms of the compression engine of the DPU
+const (
+	compressionAlgorithmLz4     = "LZ4"
+	compressionAlgorithmDeflate = "DEFLATE"
+)
+
+// CompressedVolume represents a volume compressed by the DPU
+type CompressedVolume struct {
+	// Name of the compressed volume
+	Name string `json:"name"`
+	// VolumeNameRef is the backend volume which is compressed
+	VolumeNameRef string `json:"volumeNameRef"`
+	// Algorithm is LZ4 (default) or DEFLATE
+	Algorithm string `json:"algorithm"`
+}
+
+// CreateCompressedVolumeRequest represents a request to create a compressed volume
+type CreateCompressedVolumeRequest struct {
+	// CompressedVolumeID is the ID of the compressed volume, generated when empty
+	CompressedVolumeID string `json:"compressedVolumeId"`
+	// CompressedVolume to create
+	CompressedVolume *CompressedVolume `json:"compressedVolume"`
+}
+
+// DeleteCompressedVolumeRequest represents a request to delete a compressed volume
+type DeleteCompressedVolumeRequest struct {
+	// Name of the compressed volume
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the volume doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetCompressedVolumeRequest represents a request to get a compressed volume
+type GetCompressedVolumeRequest struct {
+	// Name of the compressed volume
+	Name string `json:"name"`
+}
+
+// ListCompressedVolumesRequest represents a request to list compressed volumes
+type ListCompressedVolumesRequest struct {
+	// PageSize is the maximum number of volumes returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListCompressedVolumesResponse represents a list of compressed volumes
+type ListCompressedVolumesResponse struct {
+	// CompressedVolumes is the page of volumes
+	CompressedVolumes []*CompressedVolume `json:"compressedVolumes"`
+	// NextPageToken is set when more volumes are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// StatsCompressedVolumeRequest represents a request to get the compression statistics of a volume
+type StatsCompressedVolumeRequest struct {
+	// Name of the compressed volume
+	Name string `json:"name"`
+}
+
+// CompressedVolumeStats represents the compression statistics of a volume
+type CompressedVolumeStats struct {
+	// LogicalBytes is the amount of data written by the hosts
+	LogicalBytes uint64 `json:"logicalBytes"`
+	// PhysicalBytes is the amount of data stored on the backend volume
+	PhysicalBytes uint64 `json:"physicalBytes"`
+	// SavedBytes is the space saved by the compression
+	SavedBytes uint64 `json:"savedBytes"`
+	// CompressionRatio is LogicalBytes over PhysicalBytes, 1 when nothing is stored
+	CompressionRatio float64 `json:"compressionRatio"`
+	// SavingsPercent is SavedBytes in percent of LogicalBytes
+	SavingsPercent float64 `json:"savingsPercent"`
+}
+
+// resourceIDToCompressedVolumeName builds the name of a compressed volume, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToCompressedVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"compressedVolumes", resourceID,
+	)
+}
+
+func sortCompressedVolumes(volumes []*CompressedVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// CreateCompressedVolume creates a compressed volume on top of a backend volume,
+// the data is compressed by the compression engine of the DPU
+func (s *Server) CreateCompressedVolume(ctx context.Context, in *CreateCompressedVolumeRequest) (*CompressedVolume, error) {
+	// check input correctness
+	if err := s.validateCreateCompressedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.CompressedVolumeID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.CompressedVolumeID, "name", in.CompressedVolume.Name)
+		resourceID = in.CompressedVolumeID
+	}
+	name := resourceIDToCompressedVolumeName(resourceID)
+	// idempotent API when called with same key, should return same object
+	volume, found, err := s.getCompressedVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing CompressedVolume", "name", name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	volume = &CompressedVolume{
+		Name:          name,
+		VolumeNameRef: in.CompressedVolume.VolumeNameRef,
+		Algorithm:     in.CompressedVolume.Algorithm,
+	}
+	if volume.Algorithm == "" {
+		volume.Algorithm = compressionAlgorithmLz4
+	}
+	params := models.MrvlBdevCompressCreateParams{
+		Name:         resourceID,
+		BaseBdevName: volume.VolumeNameRef,
+		Algorithm:    volume.Algorithm,
+	}
+	var result models.MrvlBdevCompressCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_compress_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Compress Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_compress_create")
+	}
+	err = s.saveCompressedVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// DeleteCompressedVolume deletes a compressed volume
+func (s *Server) DeleteCompressedVolume(ctx context.Context, in *DeleteCompressedVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteCompressedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getCompressedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevCompressDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevCompressDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_compress_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Compress Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_compress_delete")
+	}
+	// remove from the Database
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListCompressedVolumes lists compressed volumes
+func (s *Server) ListCompressedVolumes(ctx context.Context, in *ListCompressedVolumesRequest) (*ListCompressedVolumesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	var result models.MrvlBdevCompressGetListResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_compress_get_list", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list Compress Devs"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_compress_get_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.CompressList), "offset", offset, "size", size)
+	result.CompressList, hasMoreElements = utils.LimitPagination(result.CompressList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*CompressedVolume, len(result.CompressList))
+	for i := range result.CompressList {
+		r := &result.CompressList[i]
+		Blobarray[i] = &CompressedVolume{
+			Name:          resourceIDToCompressedVolumeName(r.Name),
+			VolumeNameRef: r.BaseBdevName,
+			Algorithm:     r.Algorithm,
+		}
+	}
+	sortCompressedVolumes(Blobarray)
+	return &ListCompressedVolumesResponse{CompressedVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetCompressedVolume gets a compressed volume
+func (s *Server) GetCompressedVolume(_ context.Context, in *GetCompressedVolumeRequest) (*CompressedVolume, error) {
+	// check input correctness
+	if err := s.validateGetCompressedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getCompressedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// StatsCompressedVolume gets the compression ratio and the space saved on a compressed volume
+func (s *Server) StatsCompressedVolume(ctx context.Context, in *StatsCompressedVolumeRequest) (*CompressedVolumeStats, error) {
+	// check input correctness
+	if err := s.validateStatsCompressedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getCompressedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevCompressGetStatsParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevCompressGetStatsResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_compress_get_stats", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get stats of Compress Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_compress_get_stats")
+	}
+	stats := &CompressedVolumeStats{
+		LogicalBytes:  result.LogicalBytes,
+		PhysicalBytes: result.PhysicalBytes,
+	}
+	stats.SavedBytes, stats.CompressionRatio, stats.SavingsPercent = spaceSavings(result.LogicalBytes, result.PhysicalBytes)
+	return stats, nil
+}
+
+// getCompressedVolume fetches a compressed volume from the database,
+// compressed volumes are not protobufs so they are stored JSON encoded
+func (s *Server) getCompressedVolume(name string) (*CompressedVolume, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	volume := new(CompressedVolume)
+	if err := json.Unmarshal(value.Value, volume); err != nil {
+		return nil, false, err
+	}
+	return volume, true, nil
+}
+
+func (s *Server) saveCompressedVolume(volume *CompressedVolume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(volume.Name, wrapperspb.Bytes(data))
+}
@@ -0,0 +1,524 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestMiddleEnd_CreateCompressedVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *CompressedVolume
+		out     *CompressedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &CompressedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"unsupported algorithm": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{VolumeNameRef: "volume-42", Algorithm: "ZSTD"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Algorithm value (%s) is not supported, have to be LZ4 or DEFLATE", "ZSTD"),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Compress Dev: %v", testCompressedVolumeID),
+			exist:   false,
+		},
+		"valid request with empty SPDK response": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_create: %v", "EOF"),
+			exist:   false,
+		},
+		"valid request with ID mismatch SPDK response": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_create: %v", "json response ID mismatch"),
+			exist:   false,
+		},
+		"valid request with error code from SPDK response": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_create: %v", "json response error: myopierr"),
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{VolumeNameRef: "volume-42"},
+			out:     &testCompressedVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"valid request with deflate": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{VolumeNameRef: "volume-42", Algorithm: "DEFLATE"},
+			out:     &CompressedVolume{Name: testCompressedVolumeName, VolumeNameRef: "volume-42", Algorithm: "DEFLATE"},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{VolumeNameRef: "volume-42"},
+			out:     &testCompressedVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"no required volume_name_ref field": {
+			id:      testCompressedVolumeID,
+			in:      &CompressedVolume{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: compressed_volume.volume_name_ref",
+			exist:   false,
+		},
+		"no required field": {
+			id:      testCompressedVolumeID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: compressed_volume",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveCompressedVolume(&testCompressedVolume)
+			}
+
+			request := &CreateCompressedVolumeRequest{CompressedVolume: tt.in, CompressedVolumeID: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateCompressedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteCompressedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testCompressedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Compress Dev: %v", testCompressedVolumeID),
+			missing: false,
+		},
+		"valid request with empty SPDK response": {
+			in:      testCompressedVolumeName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_delete: %v", "EOF"),
+			missing: false,
+		},
+		"valid request with error code from SPDK response": {
+			in:      testCompressedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_delete: %v", "json response error: myopierr"),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testCompressedVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCompressedVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCompressedVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToCompressedVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveCompressedVolume(&testCompressedVolume)
+
+			request := &DeleteCompressedVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteCompressedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_ListCompressedVolumes(t *testing.T) {
+	testCompressList := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "compress_list": [{"name": "compress1", "base_bdev_name": "Malloc1", "algorithm": "DEFLATE"},{"name": "compress0", "base_bdev_name": "Malloc0", "algorithm": "LZ4"}]}}`
+	tests := map[string]struct {
+		out     []*CompressedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		size    int32
+		token   string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list Compress Devs",
+			size:    0,
+			token:   "",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_get_list: %v", "EOF"),
+			size:    0,
+			token:   "",
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_get_list: %v", "json response error: myopierr"),
+			size:    0,
+			token:   "",
+		},
+		"pagination negative": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+		},
+		"pagination error": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+		},
+		"pagination": {
+			out: []*CompressedVolume{
+				{Name: resourceIDToCompressedVolumeName("compress1"), VolumeNameRef: "Malloc1", Algorithm: "DEFLATE"},
+			},
+			spdk:    []string{testCompressList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+		},
+		"valid request with valid SPDK response": {
+			out: []*CompressedVolume{
+				{Name: resourceIDToCompressedVolumeName("compress0"), VolumeNameRef: "Malloc0", Algorithm: "LZ4"},
+				{Name: resourceIDToCompressedVolumeName("compress1"), VolumeNameRef: "Malloc1", Algorithm: "DEFLATE"},
+			},
+			spdk:    []string{testCompressList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &ListCompressedVolumesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListCompressedVolumes(testEnv.ctx, request)
+
+			var volumes []*CompressedVolume
+			if response != nil {
+				volumes = response.CompressedVolumes
+				// Empty NextPageToken indicates end of results list
+				if tt.size != 1 && response.NextPageToken != "" {
+					t.Error("Expected end of results, receieved non-empty next page token", response.NextPageToken)
+				}
+			}
+			if !reflect.DeepEqual(volumes, tt.out) {
+				t.Error("response: expected", tt.out, "received", volumes)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_GetCompressedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *CompressedVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testCompressedVolumeName,
+			out:     &testCompressedVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCompressedVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCompressedVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveCompressedVolume(&testCompressedVolume)
+
+			request := &GetCompressedVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetCompressedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_StatsCompressedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *CompressedVolumeStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testCompressedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get stats of Compress Dev: %v", testCompressedVolumeID),
+		},
+		"valid request with empty SPDK response": {
+			in:      testCompressedVolumeName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_get_stats: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      testCompressedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_get_stats: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      testCompressedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_compress_get_stats: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: testCompressedVolumeName,
+			out: &CompressedVolumeStats{
+				LogicalBytes:     4096,
+				PhysicalBytes:    1024,
+				SavedBytes:       3072,
+				CompressionRatio: 4,
+				SavingsPercent:   75,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "logical_bytes": 4096, "physical_bytes": 1024}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request on an empty volume": {
+			in:      testCompressedVolumeName,
+			out:     &CompressedVolumeStats{CompressionRatio: 1},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "logical_bytes": 0, "physical_bytes": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToCompressedVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToCompressedVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveCompressedVolume(&testCompressedVolume)
+
+			request := &StatsCompressedVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsCompressedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateCompressedVolumeRequest(in *CreateCompressedVolumeRequest) error {
+	// check required fields
+	if in.CompressedVolume == nil {
+		return errors.New("missing required field: compressed_volume")
+	}
+	if in.CompressedVolume.VolumeNameRef == "" {
+		return errors.New("missing required field: compressed_volume.volume_name_ref")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.CompressedVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(in.CompressedVolumeID); err != nil {
+			return err
+		}
+	}
+	// check Algorithm, the compression engine only implements LZ4 and DEFLATE
+	switch in.CompressedVolume.Algorithm {
+	case "", compressionAlgorithmLz4, compressionAlgorithmDeflate:
+	default:
+		msg := fmt.Sprintf("Algorithm value (%s) is not supported, have to be LZ4 or DEFLATE", in.CompressedVolume.Algorithm)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteCompressedVolumeRequest(in *DeleteCompressedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetCompressedVolumeRequest(in *GetCompressedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateStatsCompressedVolumeRequest(in *StatsCompressedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
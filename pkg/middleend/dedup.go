@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// defaultDedupChunkSize is the size of the chunks fingerprinted by the dedup layer,
+// it matches the page size of the flash pools
+const defaultDedupChunkSize = 4096
+
+// DeduplicatedVolume represents a volume deduplicated by the DPU
+type DeduplicatedVolume struct {
+	// Name of the deduplicated volume
+	Name string `json:"name"`
+	// VolumeNameRef is the backend volume which is deduplicated
+	VolumeNameRef string `json:"volumeNameRef"`
+	// ChunkSize is the size in bytes of the deduplicated chunks, 4096 by default
+	ChunkSize int32 `json:"chunkSize"`
+}
+
+// CreateDeduplicatedVolumeRequest represents a request to create a deduplicated volume
+type CreateDeduplicatedVolumeRequest struct {
+	// DeduplicatedVolumeID is the ID of the deduplicated volume, generated when empty
+	DeduplicatedVolumeID string `json:"deduplicatedVolumeId"`
+	// DeduplicatedVolume to create
+	DeduplicatedVolume *DeduplicatedVolume `json:"deduplicatedVolume"`
+}
+
+// DeleteDeduplicatedVolumeRequest represents a request to delete a deduplicated volume
+type DeleteDeduplicatedVolumeRequest struct {
+	// Name of the deduplicated volume
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the volume doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetDeduplicatedVolumeRequest represents a request to get a deduplicated volume
+type GetDeduplicatedVolumeRequest struct {
+	// Name of the deduplicated volume
+	Name string `json:"name"`
+}
+
+// ListDeduplicatedVolumesRequest represents a request to list deduplicated volumes
+type ListDeduplicatedVolumesRequest struct {
+	// PageSize is the maximum number of volumes returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListDeduplicatedVolumesResponse represents a list of deduplicated volumes
+type ListDeduplicatedVolumesResponse struct {
+	// DeduplicatedVolumes is the page of volumes
+	DeduplicatedVolumes []*DeduplicatedVolume `json:"deduplicatedVolumes"`
+	// NextPageToken is set when more volumes are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// StatsDeduplicatedVolumeRequest represents a request to get the dedup statistics of a volume
+type StatsDeduplicatedVolumeRequest struct {
+	// Name of the deduplicated volume
+	Name string `json:"name"`
+}
+
+// DeduplicatedVolumeStats represents the dedup statistics of a volume
+type DeduplicatedVolumeStats struct {
+	// LogicalBytes is the amount of data written by the hosts
+	LogicalBytes uint64 `json:"logicalBytes"`
+	// UniqueBytes is the amount of unique data stored on the backend volume
+	UniqueBytes uint64 `json:"uniqueBytes"`
+	// SavedBytes is the space saved by the deduplication
+	SavedBytes uint64 `json:"savedBytes"`
+	// DedupRatio is LogicalBytes over UniqueBytes, 1 when nothing is stored
+	DedupRatio float64 `json:"dedupRatio"`
+	// SavingsPercent is SavedBytes in percent of LogicalBytes
+	SavingsPercent float64 `json:"savingsPercent"`
+}
+
+// resourceIDToDeduplicatedVolumeName builds the name of a deduplicated volume, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToDeduplicatedVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"deduplicatedVolumes", resourceID,
+	)
+}
+
+func sortDeduplicatedVolumes(volumes []*DeduplicatedVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// CreateDeduplicatedVolume creates a deduplicated volume on top of a backend volume, identical
+// chunks written by the hosts are only stored once, i.e. the OS images of VDI workloads
+func (s *Server) CreateDeduplicatedVolume(ctx context.Context, in *CreateDeduplicatedVolumeRequest) (*DeduplicatedVolume, error) {
+	// check input correctness
+	if err := s.validateCreateDeduplicatedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.DeduplicatedVolumeID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.DeduplicatedVolumeID, "name", in.DeduplicatedVolume.Name)
+		resourceID = in.DeduplicatedVolumeID
+	}
+	name := resourceIDToDeduplicatedVolumeName(resourceID)
+	// idempotent API when called with same key, should return same object
+	volume, found, err := s.getDeduplicatedVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing DeduplicatedVolume", "name", name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	volume = &DeduplicatedVolume{
+		Name:          name,
+		VolumeNameRef: in.DeduplicatedVolume.VolumeNameRef,
+		ChunkSize:     in.DeduplicatedVolume.ChunkSize,
+	}
+	if volume.ChunkSize == 0 {
+		volume.ChunkSize = defaultDedupChunkSize
+	}
+	params := models.MrvlBdevDedupCreateParams{
+		Name:         resourceID,
+		BaseBdevName: volume.VolumeNameRef,
+		ChunkSize:    int(volume.ChunkSize),
+	}
+	var result models.MrvlBdevDedupCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_dedup_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Dedup Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_dedup_create")
+	}
+	err = s.saveDeduplicatedVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// DeleteDeduplicatedVolume deletes a deduplicated volume
+func (s *Server) DeleteDeduplicatedVolume(ctx context.Context, in *DeleteDeduplicatedVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteDeduplicatedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getDeduplicatedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevDedupDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevDedupDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_dedup_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Dedup Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_dedup_delete")
+	}
+	// remove from the Database
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListDeduplicatedVolumes lists deduplicated volumes
+func (s *Server) ListDeduplicatedVolumes(ctx context.Context, in *ListDeduplicatedVolumesRequest) (*ListDeduplicatedVolumesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	var result models.MrvlBdevDedupGetListResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_dedup_get_list", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list Dedup Devs"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_dedup_get_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.DedupList), "offset", offset, "size", size)
+	result.DedupList, hasMoreElements = utils.LimitPagination(result.DedupList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*DeduplicatedVolume, len(result.DedupList))
+	for i := range result.DedupList {
+		r := &result.DedupList[i]
+		Blobarray[i] = &DeduplicatedVolume{
+			Name:          resourceIDToDeduplicatedVolumeName(r.Name),
+			VolumeNameRef: r.BaseBdevName,
+			ChunkSize:     int32(r.ChunkSize),
+		}
+	}
+	sortDeduplicatedVolumes(Blobarray)
+	return &ListDeduplicatedVolumesResponse{DeduplicatedVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetDeduplicatedVolume gets a deduplicated volume
+func (s *Server) GetDeduplicatedVolume(_ context.Context, in *GetDeduplicatedVolumeRequest) (*DeduplicatedVolume, error) {
+	// check input correctness
+	if err := s.validateGetDeduplicatedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getDeduplicatedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// StatsDeduplicatedVolume gets the dedup ratio and the space saved on a deduplicated volume
+func (s *Server) StatsDeduplicatedVolume(ctx context.Context, in *StatsDeduplicatedVolumeRequest) (*DeduplicatedVolumeStats, error) {
+	// check input correctness
+	if err := s.validateStatsDeduplicatedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getDeduplicatedVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevDedupGetStatsParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevDedupGetStatsResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_dedup_get_stats", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get stats of Dedup Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_dedup_get_stats")
+	}
+	stats := &DeduplicatedVolumeStats{
+		LogicalBytes: result.LogicalBytes,
+		UniqueBytes:  result.UniqueBytes,
+	}
+	stats.SavedBytes, stats.DedupRatio, stats.SavingsPercent = spaceSavings(result.LogicalBytes, result.UniqueBytes)
+	return stats, nil
+}
+
+// getDeduplicatedVolume fetches a deduplicated volume from the database,
+// deduplicated volumes are not protobufs so they are stored JSON encoded
+func (s *Server) getDeduplicatedVolume(name string) (*DeduplicatedVolume, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	volume := new(DeduplicatedVolume)
+	if err := json.Unmarshal(value.Value, volume); err != nil {
+		return nil, false, err
+	}
+	return volume, true, nil
+}
+
+func (s *Server) saveDeduplicatedVolume(volume *DeduplicatedVolume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(volume.Name, wrapperspb.Bytes(data))
+}
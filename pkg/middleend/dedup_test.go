@@ -0,0 +1,524 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestMiddleEnd_CreateDeduplicatedVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *DeduplicatedVolume
+		out     *DeduplicatedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"unsupported chunk size": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42", ChunkSize: 5000},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("ChunkSize value (%d) is not supported, have to be a power of 2 between 4096 and 65536", 5000),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Dedup Dev: %v", testDeduplicatedVolumeID),
+			exist:   false,
+		},
+		"valid request with empty SPDK response": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_create: %v", "EOF"),
+			exist:   false,
+		},
+		"valid request with ID mismatch SPDK response": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_create: %v", "json response ID mismatch"),
+			exist:   false,
+		},
+		"valid request with error code from SPDK response": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_create: %v", "json response error: myopierr"),
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42"},
+			out:     &testDeduplicatedVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"valid request with chunk size": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42", ChunkSize: 16384},
+			out:     &DeduplicatedVolume{Name: testDeduplicatedVolumeName, VolumeNameRef: "volume-42", ChunkSize: 16384},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{VolumeNameRef: "volume-42"},
+			out:     &testDeduplicatedVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"no required volume_name_ref field": {
+			id:      testDeduplicatedVolumeID,
+			in:      &DeduplicatedVolume{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: deduplicated_volume.volume_name_ref",
+			exist:   false,
+		},
+		"no required field": {
+			id:      testDeduplicatedVolumeID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: deduplicated_volume",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveDeduplicatedVolume(&testDeduplicatedVolume)
+			}
+
+			request := &CreateDeduplicatedVolumeRequest{DeduplicatedVolume: tt.in, DeduplicatedVolumeID: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateDeduplicatedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteDeduplicatedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testDeduplicatedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Dedup Dev: %v", testDeduplicatedVolumeID),
+			missing: false,
+		},
+		"valid request with empty SPDK response": {
+			in:      testDeduplicatedVolumeName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_delete: %v", "EOF"),
+			missing: false,
+		},
+		"valid request with error code from SPDK response": {
+			in:      testDeduplicatedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_delete: %v", "json response error: myopierr"),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testDeduplicatedVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToDeduplicatedVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToDeduplicatedVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToDeduplicatedVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveDeduplicatedVolume(&testDeduplicatedVolume)
+
+			request := &DeleteDeduplicatedVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteDeduplicatedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_ListDeduplicatedVolumes(t *testing.T) {
+	testDedupList := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "dedup_list": [{"name": "dedup1", "base_bdev_name": "Malloc1", "chunk_size": 8192},{"name": "dedup0", "base_bdev_name": "Malloc0", "chunk_size": 4096}]}}`
+	tests := map[string]struct {
+		out     []*DeduplicatedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		size    int32
+		token   string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list Dedup Devs",
+			size:    0,
+			token:   "",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_get_list: %v", "EOF"),
+			size:    0,
+			token:   "",
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_get_list: %v", "json response error: myopierr"),
+			size:    0,
+			token:   "",
+		},
+		"pagination negative": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+		},
+		"pagination error": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+		},
+		"pagination": {
+			out: []*DeduplicatedVolume{
+				{Name: resourceIDToDeduplicatedVolumeName("dedup1"), VolumeNameRef: "Malloc1", ChunkSize: 8192},
+			},
+			spdk:    []string{testDedupList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+		},
+		"valid request with valid SPDK response": {
+			out: []*DeduplicatedVolume{
+				{Name: resourceIDToDeduplicatedVolumeName("dedup0"), VolumeNameRef: "Malloc0", ChunkSize: 4096},
+				{Name: resourceIDToDeduplicatedVolumeName("dedup1"), VolumeNameRef: "Malloc1", ChunkSize: 8192},
+			},
+			spdk:    []string{testDedupList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &ListDeduplicatedVolumesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListDeduplicatedVolumes(testEnv.ctx, request)
+
+			var volumes []*DeduplicatedVolume
+			if response != nil {
+				volumes = response.DeduplicatedVolumes
+				// Empty NextPageToken indicates end of results list
+				if tt.size != 1 && response.NextPageToken != "" {
+					t.Error("Expected end of results, receieved non-empty next page token", response.NextPageToken)
+				}
+			}
+			if !reflect.DeepEqual(volumes, tt.out) {
+				t.Error("response: expected", tt.out, "received", volumes)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_GetDeduplicatedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *DeduplicatedVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testDeduplicatedVolumeName,
+			out:     &testDeduplicatedVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToDeduplicatedVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToDeduplicatedVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveDeduplicatedVolume(&testDeduplicatedVolume)
+
+			request := &GetDeduplicatedVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetDeduplicatedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_StatsDeduplicatedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *DeduplicatedVolumeStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testDeduplicatedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get stats of Dedup Dev: %v", testDeduplicatedVolumeID),
+		},
+		"valid request with empty SPDK response": {
+			in:      testDeduplicatedVolumeName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_get_stats: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      testDeduplicatedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_get_stats: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      testDeduplicatedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_dedup_get_stats: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: testDeduplicatedVolumeName,
+			out: &DeduplicatedVolumeStats{
+				LogicalBytes:   4096,
+				UniqueBytes:    1024,
+				SavedBytes:     3072,
+				DedupRatio:     4,
+				SavingsPercent: 75,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "logical_bytes": 4096, "unique_bytes": 1024}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request on an empty volume": {
+			in:      testDeduplicatedVolumeName,
+			out:     &DeduplicatedVolumeStats{DedupRatio: 1},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "logical_bytes": 0, "unique_bytes": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToDeduplicatedVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToDeduplicatedVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveDeduplicatedVolume(&testDeduplicatedVolume)
+
+			request := &StatsDeduplicatedVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.StatsDeduplicatedVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateDeduplicatedVolumeRequest(in *CreateDeduplicatedVolumeRequest) error {
+	// check required fields
+	if in.DeduplicatedVolume == nil {
+		return errors.New("missing required field: deduplicated_volume")
+	}
+	if in.DeduplicatedVolume.VolumeNameRef == "" {
+		return errors.New("missing required field: deduplicated_volume.volume_name_ref")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.DeduplicatedVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(in.DeduplicatedVolumeID); err != nil {
+			return err
+		}
+	}
+	// check ChunkSize, a power of 2 between 4KiB and 64KiB
+	if size := in.DeduplicatedVolume.ChunkSize; size != 0 && (size < 4096 || size > 65536 || size&(size-1) != 0) {
+		msg := fmt.Sprintf("ChunkSize value (%d) is not supported, have to be a power of 2 between 4096 and 65536", size)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteDeduplicatedVolumeRequest(in *DeleteDeduplicatedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetDeduplicatedVolumeRequest(in *GetDeduplicatedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateStatsDeduplicatedVolumeRequest(in *StatsDeduplicatedVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// cipherAesXts is the only cipher offloaded to the inline crypto engine of the DPU
+const cipherAesXts = "AES_XTS"
+
+func sortEncryptedVolumes(volumes []*pb.EncryptedVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// CreateEncryptedVolume creates an encrypted volume on top of a backend volume,
+// the data is encrypted with AES-XTS by the inline crypto engine of the DPU
+func (s *Server) CreateEncryptedVolume(ctx context.Context, in *pb.CreateEncryptedVolumeRequest) (*pb.EncryptedVolume, error) {
+	// the key is only needed to program the crypto engine, do not keep it in memory
+	defer func() {
+		if in.EncryptedVolume != nil {
+			for i := range in.EncryptedVolume.Key {
+				in.EncryptedVolume.Key[i] = 0
+			}
+		}
+	}()
+	// check input correctness
+	if err := s.validateCreateEncryptedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.EncryptedVolumeId != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.EncryptedVolumeId, "name", in.EncryptedVolume.Name)
+		resourceID = in.EncryptedVolumeId
+	}
+	name := utils.ResourceIDToVolumeName(resourceID)
+	// idempotent API when called with same key, should return same object
+	volume := new(pb.EncryptedVolume)
+	found, err := s.store.Get(name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing EncryptedVolume", "name", name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	volume = &pb.EncryptedVolume{
+		Name:          name,
+		VolumeNameRef: in.EncryptedVolume.VolumeNameRef,
+		Cipher:        in.EncryptedVolume.Cipher,
+	}
+	return s.createEncryptedVolume(ctx, volume, in.EncryptedVolume.Key)
+}
+
+// createEncryptedVolume programs the crypto engine with the key and saves the volume, the key
+// is never stored nor returned
+func (s *Server) createEncryptedVolume(ctx context.Context, volume *pb.EncryptedVolume, key []byte) (*pb.EncryptedVolume, error) {
+	resourceID := path.Base(volume.Name)
+	key1, key2 := splitXtsKey(key)
+	params := models.MrvlBdevCryptoCreateParams{
+		Name:         resourceID,
+		BaseBdevName: volume.VolumeNameRef,
+		Cipher:       cipherAesXts,
+		Key:          key1,
+		Key2:         key2,
+	}
+	var result models.MrvlBdevCryptoCreateResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_crypto_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Crypto Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_crypto_create")
+	}
+	err = s.store.Set(volume.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// DeleteEncryptedVolume deletes an encrypted volume
+func (s *Server) DeleteEncryptedVolume(ctx context.Context, in *pb.DeleteEncryptedVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteEncryptedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.EncryptedVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevCryptoDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevCryptoDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_crypto_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Crypto Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_crypto_delete")
+	}
+	// remove from the Database
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.Delete(encryptedVolumeKeyIDKey(volume.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListEncryptedVolumes lists encrypted volumes
+func (s *Server) ListEncryptedVolumes(ctx context.Context, in *pb.ListEncryptedVolumesRequest) (*pb.ListEncryptedVolumesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	var result models.MrvlBdevCryptoGetListResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_crypto_get_list", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list Crypto Devs"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_crypto_get_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.CryptoList), "offset", offset, "size", size)
+	result.CryptoList, hasMoreElements = utils.LimitPagination(result.CryptoList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*pb.EncryptedVolume, len(result.CryptoList))
+	for i := range result.CryptoList {
+		r := &result.CryptoList[i]
+		Blobarray[i] = &pb.EncryptedVolume{
+			Name:          utils.ResourceIDToVolumeName(r.Name),
+			VolumeNameRef: r.BaseBdevName,
+			Cipher:        aesXtsCipher(r.KeySize),
+		}
+	}
+	sortEncryptedVolumes(Blobarray)
+	return &pb.ListEncryptedVolumesResponse{EncryptedVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetEncryptedVolume gets an encrypted volume
+func (s *Server) GetEncryptedVolume(_ context.Context, in *pb.GetEncryptedVolumeRequest) (*pb.EncryptedVolume, error) {
+	// check input correctness
+	if err := s.validateGetEncryptedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.EncryptedVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// splitXtsKey splits an AES-XTS key into its data and tweak keys, hex encoded as the firmware expects them
+func splitXtsKey(key []byte) (string, string) {
+	half := len(key) / 2
+	return hex.EncodeToString(key[:half]), hex.EncodeToString(key[half:])
+}
+
+// aesXtsCipher maps the size of an AES-XTS key reported by the firmware, in bytes, to its OPI cipher
+func aesXtsCipher(keySize int) pb.EncryptionType {
+	switch keySize {
+	case 16:
+		return pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128
+	case 32:
+		return pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256
+	default:
+		return pb.EncryptionType_ENCRYPTION_TYPE_UNSPECIFIED
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"log/slog"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// CreateKmsEncryptedVolumeRequest represents a request to create an encrypted volume
+// with a key fetched from the key management service
+type CreateKmsEncryptedVolumeRequest struct {
+	// EncryptedVolumeID is the ID of the encrypted volume, generated when empty
+	EncryptedVolumeID string `json:"encryptedVolumeId"`
+	// VolumeNameRef is the backend volume which is encrypted
+	VolumeNameRef string `json:"volumeNameRef"`
+	// Cipher used with the key, i.e. ENCRYPTION_TYPE_AES_XTS_256
+	Cipher string `json:"cipher"`
+	// KeyID identifies the key in the key management service
+	KeyID string `json:"keyId"`
+}
+
+// CreateKmsEncryptedVolume creates an encrypted volume on top of a backend volume, the key is
+// fetched from the key management service so the key material never goes through the API
+func (s *Server) CreateKmsEncryptedVolume(ctx context.Context, in *CreateKmsEncryptedVolumeRequest) (*pb.EncryptedVolume, error) {
+	// check input correctness
+	if err := s.validateCreateKmsEncryptedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.EncryptedVolumeID != "" {
+		resourceID = in.EncryptedVolumeID
+	}
+	name := utils.ResourceIDToVolumeName(resourceID)
+	// idempotent API when called with same key, should return same object
+	volume := new(pb.EncryptedVolume)
+	found, err := s.store.Get(name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing EncryptedVolume", "name", name)
+		return volume, nil
+	}
+	cipher := pb.EncryptionType(pb.EncryptionType_value[in.Cipher])
+	key, err := s.getKmsKey(ctx, in.KeyID, cipher)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+	// not found, so create a new one
+	volume = &pb.EncryptedVolume{
+		Name:          name,
+		VolumeNameRef: in.VolumeNameRef,
+		Cipher:        cipher,
+	}
+	response, err := s.createEncryptedVolume(ctx, volume, key)
+	if err != nil {
+		return nil, err
+	}
+	// the key is fetched again by ID when the volume is re-created
+	err = s.saveEncryptedVolumeKeyID(name, in.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// getKmsKey fetches the key of keyID from the key management service and checks it suits the cipher
+func (s *Server) getKmsKey(ctx context.Context, keyID string, cipher pb.EncryptionType) ([]byte, error) {
+	if s.keys == nil {
+		msg := "No key management service is configured"
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	key, err := s.keys.GetKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if keySize := aesXtsKeySizes[cipher]; len(key) != keySize {
+		for i := range key {
+			key[i] = 0
+		}
+		msg := "Key " + keyID + " does not match the cipher " + cipher.String()
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return key, nil
+}
+
+func encryptedVolumeKeyIDKey(name string) string {
+	return name + "/keyId"
+}
+
+func (s *Server) saveEncryptedVolumeKeyID(name string, keyID string) error {
+	return s.store.Set(encryptedVolumeKeyIDKey(name), wrapperspb.String(keyID))
+}
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+// fakeKeyManager serves keys from memory
+type fakeKeyManager map[string][]byte
+
+func (m fakeKeyManager) GetKey(_ context.Context, keyID string) ([]byte, error) {
+	key, ok := m[keyID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find key %s in the key management service", keyID)
+	}
+	return append([]byte(nil), key...), nil
+}
+
+var testKeyManager = fakeKeyManager{
+	"key-128": bytes.Repeat([]byte{0x01}, 32),
+	"key-256": bytes.Repeat([]byte{0x02}, 64),
+}
+
+func TestMiddleEnd_CreateKmsEncryptedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      *CreateKmsEncryptedVolumeRequest
+		out     *pb.EncryptedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+		noKms   bool
+	}{
+		"illegal resource_id": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: "CapitalLettersNotAllowed", VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "key-128"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+		},
+		"unsupported cipher": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_CBC_128", KeyID: "key-128"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Cipher value (%s) is not supported, have to be AES-XTS 128 or 256", "ENCRYPTION_TYPE_AES_CBC_128"),
+		},
+		"no key management service": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "key-128"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  "No key management service is configured",
+			noKms:   true,
+		},
+		"unknown key in the key management service": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "unknown-key"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("Could not find key %s in the key management service", "unknown-key"),
+		},
+		"key not matching the cipher": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "key-256"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Key %s does not match the cipher %s", "key-256", "ENCRYPTION_TYPE_AES_XTS_128"),
+		},
+		"valid request with invalid SPDK response": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "key-128"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Crypto Dev: %v", testEncryptedVolumeID),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "key-128"},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_create: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "key-128"},
+			out:     &testEncryptedVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"already exists": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "key-128"},
+			out:     &testEncryptedVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"no required key_id field": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, VolumeNameRef: "volume-42", Cipher: "ENCRYPTION_TYPE_AES_XTS_128"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: key_id",
+		},
+		"no required field": {
+			in:      &CreateKmsEncryptedVolumeRequest{EncryptedVolumeID: testEncryptedVolumeID, Cipher: "ENCRYPTION_TYPE_AES_XTS_128", KeyID: "key-128"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: volume_name_ref",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if !tt.noKms {
+				testEnv.opiSpdkServer.SetKeyManager(testKeyManager)
+			}
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.store.Set(testEncryptedVolumeName, &testEncryptedVolume)
+			}
+
+			response, err := testEnv.opiSpdkServer.CreateKmsEncryptedVolume(testEnv.ctx, tt.in)
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			keyID := new(wrapperspb.StringValue)
+			found, _ := testEnv.opiSpdkServer.store.Get(encryptedVolumeKeyIDKey(testEncryptedVolumeName), keyID)
+			if expected := err == nil && !tt.exist; found != expected {
+				t.Error("key ID stored: expected", expected, "received", found)
+			}
+			if found && keyID.Value != tt.in.KeyID {
+				t.Error("key ID: expected", tt.in.KeyID, "received", keyID.Value)
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_RekeyEncryptedVolumeWithKms(t *testing.T) {
+	tests := map[string]struct {
+		in      *RekeyEncryptedVolumeRequest
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		keyID   string
+	}{
+		"valid request with key from the key management service": {
+			in: &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, KeyID: "key-256", Cipher: "ENCRYPTION_TYPE_AES_XTS_256"},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "done", "progress": 100}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			keyID:   "key-256",
+		},
+		"valid request with raw key": {
+			in: &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "done", "progress": 100}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			keyID:   "",
+		},
+		"key not matching the cipher": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, KeyID: "key-256"},
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Key %s does not match the cipher %s", "key-256", "ENCRYPTION_TYPE_AES_XTS_128"),
+			keyID:   "key-128",
+		},
+		"both key and key_id": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32), KeyID: "key-128"},
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Only one of key and key_id can be set",
+			keyID:   "key-128",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+			testEnv.opiSpdkServer.SetKeyManager(testKeyManager)
+
+			_ = testEnv.opiSpdkServer.store.Set(testEncryptedVolumeName, &testEncryptedVolume)
+			_ = testEnv.opiSpdkServer.saveEncryptedVolumeKeyID(testEncryptedVolumeName, "key-128")
+
+			_, err := testEnv.opiSpdkServer.RekeyEncryptedVolume(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if !bytes.Equal(tt.in.Key, make([]byte, len(tt.in.Key))) {
+				t.Error("expected key to be wiped out after the call, received", tt.in.Key)
+			}
+
+			testEnv.opiSpdkServer.operations.Wait()
+			keyID := new(wrapperspb.StringValue)
+			_, _ = testEnv.opiSpdkServer.store.Get(encryptedVolumeKeyIDKey(testEncryptedVolumeName), keyID)
+			if keyID.Value != tt.keyID {
+				t.Error("key ID: expected", tt.keyID, "received", keyID.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// States of a volume re-key reported by the firmware
+const (
+	rekeyStateRunning = "running"
+	rekeyStateDone    = "done"
+)
+
+// RekeyEncryptedVolumeRequest represents a request to rotate the key of an encrypted volume
+type RekeyEncryptedVolumeRequest struct {
+	// Name of the encrypted volume
+	Name string `json:"name"`
+	// Key is the new key, it is never stored nor logged
+	Key []byte `json:"key"`
+	// KeyID identifies the new key in the key management service, used instead of Key
+	KeyID string `json:"keyId"`
+	// Cipher used with the new key, i.e. ENCRYPTION_TYPE_AES_XTS_256, defaults to the current one
+	Cipher string `json:"cipher"`
+}
+
+// RekeyEncryptedVolumeMetadata describes a re-key operation
+type RekeyEncryptedVolumeMetadata struct {
+	// Name of the encrypted volume
+	Name string `json:"name"`
+	// Cipher used with the new key
+	Cipher string `json:"cipher"`
+}
+
+// RekeyEncryptedVolume rotates the key of an encrypted volume, returning a long-running operation.
+// The data is re-encrypted by the firmware in the background while the volume stays online,
+// so the hosts don't see any downtime
+func (s *Server) RekeyEncryptedVolume(ctx context.Context, in *RekeyEncryptedVolumeRequest) (*operations.Operation, error) {
+	// the key is only needed to program the crypto engine, do not keep it in memory
+	defer func() {
+		for i := range in.Key {
+			in.Key[i] = 0
+		}
+	}()
+	// check input correctness
+	if err := s.validateRekeyEncryptedVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.EncryptedVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	cipher := volume.Cipher
+	if in.Cipher != "" {
+		cipher = pb.EncryptionType(pb.EncryptionType_value[in.Cipher])
+	}
+	if in.KeyID != "" {
+		in.Key, err = s.getKmsKey(ctx, in.KeyID, cipher)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if keySize := aesXtsKeySizes[cipher]; len(in.Key) != keySize {
+		msg := fmt.Sprintf("Key size (%d) does not match the cipher, have to be %d bytes", len(in.Key), keySize)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	resourceID := path.Base(volume.Name)
+	key, key2 := splitXtsKey(in.Key)
+	params := models.MrvlBdevCryptoRekeyParams{
+		Name:   resourceID,
+		Cipher: cipherAesXts,
+		Key:    key,
+		Key2:   key2,
+	}
+	var result models.MrvlBdevCryptoRekeyResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_crypto_rekey", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not re-key Crypto Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_crypto_rekey")
+	}
+	metadata := &RekeyEncryptedVolumeMetadata{
+		Name:   volume.Name,
+		Cipher: cipher.String(),
+	}
+	return s.operations.Start(metadata, func(ctx context.Context) (interface{}, error) {
+		return s.waitEncryptedVolumeRekey(ctx, volume, cipher, in.KeyID)
+	}), nil
+}
+
+// waitEncryptedVolumeRekey polls the firmware until the data of the volume is re-encrypted
+func (s *Server) waitEncryptedVolumeRekey(ctx context.Context, volume *pb.EncryptedVolume, cipher pb.EncryptionType, keyID string) (*pb.EncryptedVolume, error) {
+	resourceID := path.Base(volume.Name)
+	for {
+		params := models.MrvlBdevCryptoGetRekeyStatusParams{
+			Name: resourceID,
+		}
+		var result models.MrvlBdevCryptoGetRekeyStatusResult
+		err := s.rpc.Call(ctx, "mrvl_bdev_crypto_get_rekey_status", &params, &result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not get re-key status of Crypto Dev: %s", resourceID)
+			return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_crypto_get_rekey_status")
+		}
+		switch result.State {
+		case rekeyStateRunning:
+			operations.ReportProgress(ctx, int32(result.Progress))
+			operations.ReportBytes(ctx, result.BytesDone, result.TotalBytes)
+		case rekeyStateDone:
+			slog.Info("Re-key of Crypto Dev is done", "name", resourceID)
+			volume.Cipher = cipher
+			if err := s.store.Set(volume.Name, volume); err != nil {
+				return nil, err
+			}
+			// a raw key replaces the one of the key management service
+			if keyID == "" {
+				err = s.store.Delete(encryptedVolumeKeyIDKey(volume.Name))
+			} else {
+				err = s.saveEncryptedVolumeKeyID(volume.Name, keyID)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return volume, nil
+		default:
+			// the firmware keeps the volume readable with the old key on failure
+			msg := fmt.Sprintf("Re-key of Crypto Dev %s failed, the previous key is still in use", resourceID)
+			return nil, status.Errorf(codes.Aborted, msg)
+		}
+		time.Sleep(s.rekeyPollInterval)
+	}
+}
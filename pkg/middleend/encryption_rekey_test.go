@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestMiddleEnd_RekeyEncryptedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in         *RekeyEncryptedVolumeRequest
+		spdk       []string
+		errCode    codes.Code
+		errMsg     string
+		opResponse *pb.EncryptedVolume
+		opErr      *operations.Error
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not re-key Crypto Dev: %v", testEncryptedVolumeID),
+		},
+		"valid request with empty SPDK response": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_rekey: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_rekey: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_rekey: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "running", "progress": 50}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "done", "progress": 100}}`,
+			},
+			errCode:    codes.OK,
+			errMsg:     "",
+			opResponse: &testEncryptedVolume,
+		},
+		"valid request changing the cipher": {
+			in: &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 64), Cipher: "ENCRYPTION_TYPE_AES_XTS_256"},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "done", "progress": 100}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opResponse: &pb.EncryptedVolume{
+				Name:          testEncryptedVolumeName,
+				VolumeNameRef: testEncryptedVolume.VolumeNameRef,
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256,
+			},
+		},
+		"re-key failed in the firmware": {
+			in: &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "failed", "progress": 30}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.Aborted, Message: fmt.Sprintf("Re-key of Crypto Dev %s failed, the previous key is still in use", testEncryptedVolumeID)},
+		},
+		"re-key status with invalid SPDK response": {
+			in: &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.InvalidArgument, Message: fmt.Sprintf("Could not get re-key status of Crypto Dev: %s", testEncryptedVolumeID)},
+		},
+		"unsupported cipher": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 32), Cipher: "ENCRYPTION_TYPE_AES_CBC_256"},
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Cipher value (%s) is not supported, have to be AES-XTS 128 or 256", "ENCRYPTION_TYPE_AES_CBC_256"),
+		},
+		"key size not matching the cipher": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName, Key: bytes.Repeat([]byte{0x02}, 64)},
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Key size (%d) does not match the cipher, have to be %d bytes", 64, 32),
+		},
+		"valid request with unknown key": {
+			in:      &RekeyEncryptedVolumeRequest{Name: utils.ResourceIDToVolumeName("unknown-id"), Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      &RekeyEncryptedVolumeRequest{Name: "-ABC-DEF", Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required key field": {
+			in:      &RekeyEncryptedVolumeRequest{Name: testEncryptedVolumeName},
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: key",
+		},
+		"no required field": {
+			in:      &RekeyEncryptedVolumeRequest{Key: bytes.Repeat([]byte{0x02}, 32)},
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testEncryptedVolumeName, &testEncryptedVolume)
+
+			response, err := testEnv.opiSpdkServer.RekeyEncryptedVolume(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if !bytes.Equal(tt.in.Key, make([]byte, len(tt.in.Key))) {
+				t.Error("expected key to be wiped out after the call, received", tt.in.Key)
+			}
+			if err != nil {
+				if response != nil {
+					t.Error("response: expected", nil, "received", response)
+				}
+				return
+			}
+
+			testEnv.opiSpdkServer.operations.Wait()
+			op, _ := testEnv.opiSpdkServer.operations.GetOperation(testEnv.ctx, &operations.GetOperationRequest{Name: response.Name})
+			if !op.Done {
+				t.Error("expected operation to be done")
+			}
+			if volume, _ := op.Response.(*pb.EncryptedVolume); !proto.Equal(volume, tt.opResponse) {
+				t.Error("operation response: expected", tt.opResponse, "received", op.Response)
+			}
+			if (op.Error == nil) != (tt.opErr == nil) || (tt.opErr != nil && *op.Error != *tt.opErr) {
+				t.Error("operation error: expected", tt.opErr, "received", op.Error)
+			}
+		})
+	}
+}
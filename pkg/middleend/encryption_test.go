@@ -0,0 +1,478 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestMiddleEnd_CreateEncryptedVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *pb.EncryptedVolume
+		out     *pb.EncryptedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id: "CapitalLettersNotAllowed",
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 32),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128,
+			},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"unsupported cipher": {
+			id: testEncryptedVolumeID,
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 16),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_CBC_128,
+			},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Cipher value (%s) is not supported, have to be AES-XTS 128 or 256", pb.EncryptionType_ENCRYPTION_TYPE_AES_CBC_128),
+			exist:   false,
+		},
+		"key size not matching the cipher": {
+			id: testEncryptedVolumeID,
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 32),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256,
+			},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Key size (%d) does not match the cipher, have to be %d bytes", 32, 64),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			id: testEncryptedVolumeID,
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 32),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128,
+			},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Crypto Dev: %v", testEncryptedVolumeID),
+			exist:   false,
+		},
+		"valid request with empty SPDK response": {
+			id: testEncryptedVolumeID,
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 32),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128,
+			},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_create: %v", "EOF"),
+			exist:   false,
+		},
+		"valid request with ID mismatch SPDK response": {
+			id: testEncryptedVolumeID,
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 32),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128,
+			},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_create: %v", "json response ID mismatch"),
+			exist:   false,
+		},
+		"valid request with error code from SPDK response": {
+			id: testEncryptedVolumeID,
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 32),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128,
+			},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_create: %v", "json response error: myopierr"),
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			id: testEncryptedVolumeID,
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 32),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128,
+			},
+			out:     &testEncryptedVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id: testEncryptedVolumeID,
+			in: &pb.EncryptedVolume{
+				VolumeNameRef: "volume-42",
+				Key:           bytes.Repeat([]byte{0x01}, 32),
+				Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128,
+			},
+			out:     &testEncryptedVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"no required field": {
+			id:      testEncryptedVolumeID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: encrypted_volume",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.store.Set(testEncryptedVolumeName, &testEncryptedVolume)
+			}
+
+			request := &pb.CreateEncryptedVolumeRequest{EncryptedVolume: tt.in, EncryptedVolumeId: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateEncryptedVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			if tt.in != nil && !bytes.Equal(tt.in.Key, make([]byte, len(tt.in.Key))) {
+				t.Error("expected key to be wiped out after the call, received", tt.in.Key)
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteEncryptedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testEncryptedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Crypto Dev: %v", testEncryptedVolumeID),
+			missing: false,
+		},
+		"valid request with empty SPDK response": {
+			in:      testEncryptedVolumeName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_delete: %v", "EOF"),
+			missing: false,
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      testEncryptedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_delete: %v", "json response ID mismatch"),
+			missing: false,
+		},
+		"valid request with error code from SPDK response": {
+			in:      testEncryptedVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_delete: %v", "json response error: myopierr"),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testEncryptedVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      utils.ResourceIDToVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      utils.ResourceIDToVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testEncryptedVolumeName, &testEncryptedVolume)
+
+			request := &pb.DeleteEncryptedVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteEncryptedVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_ListEncryptedVolumes(t *testing.T) {
+	tests := map[string]struct {
+		out     []*pb.EncryptedVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		size    int32
+		token   string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list Crypto Devs",
+			size:    0,
+			token:   "",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_get_list: %v", "EOF"),
+			size:    0,
+			token:   "",
+		},
+		"valid request with ID mismatch SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_get_list: %v", "json response ID mismatch"),
+			size:    0,
+			token:   "",
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_crypto_get_list: %v", "json response error: myopierr"),
+			size:    0,
+			token:   "",
+		},
+		"pagination negative": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+		},
+		"pagination error": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+		},
+		"pagination": {
+			out: []*pb.EncryptedVolume{
+				{Name: utils.ResourceIDToVolumeName("crypto0"), VolumeNameRef: "Malloc0", Cipher: pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "crypto_list": [{"name": "crypto0", "base_bdev_name": "Malloc0", "cipher": "AES_XTS", "key_size": 16},{"name": "crypto1", "base_bdev_name": "Malloc1", "cipher": "AES_XTS", "key_size": 32}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+		},
+		"pagination offset": {
+			out: []*pb.EncryptedVolume{
+				{Name: utils.ResourceIDToVolumeName("crypto1"), VolumeNameRef: "Malloc1", Cipher: pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "crypto_list": [{"name": "crypto0", "base_bdev_name": "Malloc0", "cipher": "AES_XTS", "key_size": 16},{"name": "crypto1", "base_bdev_name": "Malloc1", "cipher": "AES_XTS", "key_size": 32}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "existing-pagination-token",
+		},
+		"valid request with valid SPDK response": {
+			out: []*pb.EncryptedVolume{
+				{Name: utils.ResourceIDToVolumeName("crypto0"), VolumeNameRef: "Malloc0", Cipher: pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128},
+				{Name: utils.ResourceIDToVolumeName("crypto1"), VolumeNameRef: "Malloc1", Cipher: pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "crypto_list": [{"name": "crypto1", "base_bdev_name": "Malloc1", "cipher": "AES_XTS", "key_size": 32},{"name": "crypto0", "base_bdev_name": "Malloc0", "cipher": "AES_XTS", "key_size": 16}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			testEnv.opiSpdkServer.Pagination.SetToken("existing-pagination-token", 1)
+
+			request := &pb.ListEncryptedVolumesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListEncryptedVolumes(testEnv.ctx, request)
+
+			if !utils.EqualProtoSlices(response.GetEncryptedVolumes(), tt.out) {
+				t.Error("response: expected", tt.out, "received", response.GetEncryptedVolumes())
+			}
+
+			// Empty NextPageToken indicates end of results list
+			if tt.size != 1 && response.GetNextPageToken() != "" {
+				t.Error("Expected end of results, receieved non-empty next page token", response.GetNextPageToken())
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_GetEncryptedVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *pb.EncryptedVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testEncryptedVolumeName,
+			out:     &testEncryptedVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      utils.ResourceIDToVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testEncryptedVolumeName, &testEncryptedVolume)
+
+			request := &pb.GetEncryptedVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetEncryptedVolume(testEnv.ctx, request)
+
+			if !proto.Equal(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
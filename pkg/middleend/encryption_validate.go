@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+// aesXtsKeySizes lists the key sizes, in bytes, the crypto engine supports for each cipher,
+// XTS keys are made of two AES keys of the same size
+var aesXtsKeySizes = map[pb.EncryptionType]int{
+	pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128: 32,
+	pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_256: 64,
+}
+
+func (s *Server) validateCreateEncryptedVolumeRequest(in *pb.CreateEncryptedVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.EncryptedVolumeId != "" {
+		if err := resourceid.ValidateUserSettable(in.EncryptedVolumeId); err != nil {
+			return err
+		}
+	}
+	// check Cipher, only AES-XTS is offloaded to the crypto engine
+	keySize, ok := aesXtsKeySizes[in.EncryptedVolume.Cipher]
+	if !ok {
+		msg := fmt.Sprintf("Cipher value (%s) is not supported, have to be AES-XTS 128 or 256", in.EncryptedVolume.Cipher)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check Key length, the key itself is never part of the message
+	if len(in.EncryptedVolume.Key) != keySize {
+		msg := fmt.Sprintf("Key size (%d) does not match the cipher, have to be %d bytes", len(in.EncryptedVolume.Key), keySize)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteEncryptedVolumeRequest(in *pb.DeleteEncryptedVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateGetEncryptedVolumeRequest(in *pb.GetEncryptedVolumeRequest) error {
+	// check required fields
+	if err := fieldbehavior.ValidateRequiredFields(in); err != nil {
+		return err
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(in.Name)
+}
+
+func (s *Server) validateRekeyEncryptedVolumeRequest(in *RekeyEncryptedVolumeRequest) error {
+	// check required fields
+	if err := validateResourceName(in.Name); err != nil {
+		return err
+	}
+	if len(in.Key) == 0 && in.KeyID == "" {
+		return errors.New("missing required field: key")
+	}
+	if len(in.Key) != 0 && in.KeyID != "" {
+		msg := "Only one of key and key_id can be set"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// check Cipher, only AES-XTS is offloaded to the crypto engine
+	if in.Cipher != "" {
+		if _, ok := aesXtsKeySizes[pb.EncryptionType(pb.EncryptionType_value[in.Cipher])]; !ok {
+			msg := fmt.Sprintf("Cipher value (%s) is not supported, have to be AES-XTS 128 or 256", in.Cipher)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+	}
+	return nil
+}
+
+func (s *Server) validateCreateKmsEncryptedVolumeRequest(in *CreateKmsEncryptedVolumeRequest) error {
+	// check required fields
+	if in.VolumeNameRef == "" {
+		return errors.New("missing required field: volume_name_ref")
+	}
+	if in.KeyID == "" {
+		return errors.New("missing required field: key_id")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.EncryptedVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(in.EncryptedVolumeID); err != nil {
+			return err
+		}
+	}
+	// check Cipher, only AES-XTS is offloaded to the crypto engine
+	if _, ok := aesXtsKeySizes[pb.EncryptionType(pb.EncryptionType_value[in.Cipher])]; !ok {
+		msg := fmt.Sprintf("Cipher value (%s) is not supported, have to be AES-XTS 128 or 256", in.Cipher)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/philippgille/gokv"
+	"go.einride.tech/aip/resourcename"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/kms"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-marvell-bridge/pkg/pagination"
+)
+
+// defaultRekeyPollInterval is how often the progress of a volume re-key is polled
+const defaultRekeyPollInterval = time.Second
+
+// defaultRebuildPollInterval is how often the progress of a RAID volume rebuild is polled
+const defaultRebuildPollInterval = 5 * time.Second
+
+// Server contains middleend related Marvell services
+type Server struct {
+	pb.UnimplementedMiddleendEncryptionServiceServer
+	Pagination *pagination.Paginator
+	store      gokv.Store
+	rpc        spdk.JSONRPC
+	operations *operations.Manager
+	// rekeyPollInterval is how often the progress of a volume re-key is polled
+	rekeyPollInterval time.Duration
+	// rebuildPollInterval is how often the progress of a RAID volume rebuild is polled
+	rebuildPollInterval time.Duration
+	// keys fetches the keys of the encrypted volumes, nil when no key management service is used
+	keys kms.KeyManager
+}
+
+// NewServer creates initialized instance of middleend server
+func NewServer(jsonRPC spdk.JSONRPC, store gokv.Store, ops *operations.Manager) *Server {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	if store == nil {
+		log.Panic("nil for Store is not allowed")
+	}
+	if ops == nil {
+		log.Panic("nil for Operations is not allowed")
+	}
+	return &Server{
+		Pagination:          pagination.New(),
+		store:               store,
+		rpc:                 jsonRPC,
+		operations:          ops,
+		rekeyPollInterval:   defaultRekeyPollInterval,
+		rebuildPollInterval: defaultRebuildPollInterval,
+	}
+}
+
+// SetKeyManager sets the key management service the keys of the encrypted volumes are fetched from
+func (s *Server) SetKeyManager(keys kms.KeyManager) {
+	s.keys = keys
+}
+
+// validateResourceName checks the name of requests which are not part of
+// the OPI protobufs and so can't rely on the field behavior annotations
+func validateResourceName(name string) error {
+	if name == "" {
+		return errors.New("missing required field: name")
+	}
+	// Validate that a resource name conforms to the restrictions outlined in AIP-122.
+	return resourcename.Validate(name)
+}
+
+// spaceSavings computes the space saved by a data reduction layer from the amount of data
+// written by the hosts and the amount stored on the backend volume, the ratio is 1 when
+// nothing is stored
+func spaceSavings(logicalBytes uint64, physicalBytes uint64) (savedBytes uint64, ratio float64, percent float64) {
+	ratio = 1
+	// data which can't be reduced is stored as is, so physical can't exceed logical
+	if logicalBytes > physicalBytes {
+		savedBytes = logicalBytes - physicalBytes
+		percent = float64(savedBytes) * 100 / float64(logicalBytes)
+	}
+	if physicalBytes != 0 {
+		ratio = float64(logicalBytes) / float64(physicalBytes)
+	}
+	return savedBytes, ratio, percent
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/philippgille/gokv/gomap"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+type testEnv struct {
+	opiSpdkServer *Server
+	ln            net.Listener
+	testSocket    string
+	ctx           context.Context
+	jsonRPC       spdk.JSONRPC
+}
+
+func (e *testEnv) Close() {
+	utils.CloseListener(e.ln)
+	if err := os.RemoveAll(e.testSocket); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func createTestEnvironment(spdkResponses []string) *testEnv {
+	env := &testEnv{}
+	env.testSocket = utils.GenerateSocketName("middleend")
+	env.ln, env.jsonRPC = utils.CreateTestSpdkServer(env.testSocket, spdkResponses)
+	options := gomap.DefaultOptions
+	options.Codec = utils.ProtoCodec{}
+	store := gomap.NewStore(options)
+	env.opiSpdkServer = NewServer(env.jsonRPC, store, operations.NewManager())
+	env.opiSpdkServer.rekeyPollInterval = time.Millisecond
+	env.opiSpdkServer.rebuildPollInterval = time.Millisecond
+	env.ctx = context.Background()
+	return env
+}
+
+var (
+	testQosVolumeID   = "qos-volume-42"
+	testQosVolumeName = utils.ResourceIDToVolumeName(testQosVolumeID)
+	testQosVolume     = pb.QosVolume{
+		Name:          testQosVolumeName,
+		VolumeNameRef: "volume-42",
+		Limits: &pb.Limits{
+			Max: &pb.QosLimit{RwIopsKiops: 3},
+		},
+	}
+	testEncryptedVolumeID   = "crypto-volume-42"
+	testEncryptedVolumeName = utils.ResourceIDToVolumeName(testEncryptedVolumeID)
+	testEncryptedVolume     = pb.EncryptedVolume{
+		Name:          testEncryptedVolumeName,
+		VolumeNameRef: "volume-42",
+		Cipher:        pb.EncryptionType_ENCRYPTION_TYPE_AES_XTS_128,
+	}
+	testCompressedVolumeID   = "compressed-volume-42"
+	testCompressedVolumeName = resourceIDToCompressedVolumeName(testCompressedVolumeID)
+	testCompressedVolume     = CompressedVolume{
+		Name:          testCompressedVolumeName,
+		VolumeNameRef: "volume-42",
+		Algorithm:     "LZ4",
+	}
+	testDeduplicatedVolumeID   = "dedup-volume-42"
+	testDeduplicatedVolumeName = resourceIDToDeduplicatedVolumeName(testDeduplicatedVolumeID)
+	testDeduplicatedVolume     = DeduplicatedVolume{
+		Name:          testDeduplicatedVolumeName,
+		VolumeNameRef: "volume-42",
+		ChunkSize:     4096,
+	}
+	testSnapshotID   = "snapshot-42"
+	testSnapshotName = resourceIDToSnapshotName(testSnapshotID)
+	testSnapshot     = Snapshot{
+		Name:          testSnapshotName,
+		VolumeNameRef: "volume-42",
+		CreateTime:    "2024-01-02T03:04:05Z",
+	}
+	testCloneID   = "clone-42"
+	testCloneName = resourceIDToCloneName(testCloneID)
+	testClone     = Clone{
+		Name:            testCloneName,
+		SnapshotNameRef: testSnapshotName,
+	}
+	testRaidVolumeID   = "raid-volume-42"
+	testRaidVolumeName = resourceIDToRaidVolumeName(testRaidVolumeID)
+	testRaidVolume     = RaidVolume{
+		Name:           testRaidVolumeName,
+		Level:          "RAID1",
+		VolumeNameRefs: []string{"volume-42", "volume-43"},
+	}
+	testCachedVolumeID   = "cached-volume-42"
+	testCachedVolumeName = resourceIDToCachedVolumeName(testCachedVolumeID)
+	testCachedVolume     = CachedVolume{
+		Name:               testCachedVolumeName,
+		VolumeNameRef:      "volume-42",
+		CacheVolumeNameRef: "volume-43",
+		Mode:               "WRITE_THROUGH",
+	}
+	testThrottleGroupID   = "throttle-group-42"
+	testThrottleGroupName = resourceIDToThrottleGroupName(testThrottleGroupID)
+	testThrottleGroup     = ThrottleGroup{
+		Name:           testThrottleGroupName,
+		VolumeNameRefs: []string{"volume-42", "volume-43"},
+		Limits:         &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000},
+	}
+)
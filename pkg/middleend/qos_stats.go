@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatsQosVolumeThrottlingRequest represents a request to get the throttling counters of a QoS volume
+type StatsQosVolumeThrottlingRequest struct {
+	// Name of the QoS volume
+	Name string `json:"name"`
+}
+
+// QosVolumeThrottlingStats represents the throttling counters of a QoS volume
+type QosVolumeThrottlingStats struct {
+	// ThrottledIos is the number of I/Os which were delayed by the limits
+	ThrottledIos uint64 `json:"throttledIos"`
+	// ThrottledBytes is the number of bytes which were delayed by the limits
+	ThrottledBytes uint64 `json:"throttledBytes"`
+	// QueuedTimeUs is the total time the throttled I/Os spent queued because of the limits
+	QueuedTimeUs uint64 `json:"queuedTimeUs"`
+	// IosCredit is the number of I/Os left in the current timeslice before throttling starts
+	IosCredit uint64 `json:"iosCredit"`
+	// BytesCredit is the number of bytes left in the current timeslice before throttling starts
+	BytesCredit uint64 `json:"bytesCredit"`
+	// CurrentlyThrottled is set when I/Os are queued waiting for credit
+	CurrentlyThrottled bool `json:"currentlyThrottled"`
+}
+
+// StatsQosVolumeThrottling gets the throttling counters of a QoS volume, they tell whether
+// the latency seen by a host is caused by its limits or by a saturated backend
+func (s *Server) StatsQosVolumeThrottling(ctx context.Context, in *StatsQosVolumeThrottlingRequest) (*QosVolumeThrottlingStats, error) {
+	// check input correctness
+	if err := s.validateStatsQosVolumeThrottlingRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume := new(pb.QosVolume)
+	found, err := s.store.Get(in.Name, volume)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	params := models.MrvlBdevGetQosStatsParams{
+		Name: volume.VolumeNameRef,
+	}
+	var result models.MrvlBdevGetQosStatsResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_get_qos_stats", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not get QoS stats of volume: %s", volume.VolumeNameRef)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_get_qos_stats")
+	}
+	return &QosVolumeThrottlingStats{
+		ThrottledIos:       result.ThrottledIos,
+		ThrottledBytes:     result.ThrottledBytes,
+		QueuedTimeUs:       result.QueuedTimeUs,
+		IosCredit:          result.IosCredit,
+		BytesCredit:        result.BytesCredit,
+		CurrentlyThrottled: result.CurrentlyThrottled,
+	}, nil
+}
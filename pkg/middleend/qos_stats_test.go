@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+func TestMiddleEnd_StatsQosVolumeThrottling(t *testing.T) {
+	tests := map[string]struct {
+		in      *StatsQosVolumeThrottlingRequest
+		out     *QosVolumeThrottlingStats
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &StatsQosVolumeThrottlingRequest{Name: testQosVolumeName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not get QoS stats of volume: %v", testQosVolume.VolumeNameRef),
+		},
+		"valid request with empty SPDK response": {
+			in:      &StatsQosVolumeThrottlingRequest{Name: testQosVolumeName},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_get_qos_stats: %v", "EOF"),
+		},
+		"valid request with ID mismatch SPDK response": {
+			in:      &StatsQosVolumeThrottlingRequest{Name: testQosVolumeName},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_get_qos_stats: %v", "json response ID mismatch"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &StatsQosVolumeThrottlingRequest{Name: testQosVolumeName},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_get_qos_stats: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in: &StatsQosVolumeThrottlingRequest{Name: testQosVolumeName},
+			out: &QosVolumeThrottlingStats{
+				ThrottledIos:       1200,
+				ThrottledBytes:     4915200,
+				QueuedTimeUs:       350000,
+				IosCredit:          3,
+				BytesCredit:        12288,
+				CurrentlyThrottled: true,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "throttled_ios": 1200, "throttled_bytes": 4915200, "queued_time_us": 350000, "ios_credit": 3, "bytes_credit": 12288, "currently_throttled": true}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      &StatsQosVolumeThrottlingRequest{Name: utils.ResourceIDToVolumeName("unknown-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", utils.ResourceIDToVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      &StatsQosVolumeThrottlingRequest{Name: "-ABC-DEF"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &StatsQosVolumeThrottlingRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.store.Set(testQosVolumeName, &testQosVolume)
+
+			response, err := testEnv.opiSpdkServer.StatsQosVolumeThrottling(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+func (s *Server) validateStatsQosVolumeThrottlingRequest(in *StatsQosVolumeThrottlingRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
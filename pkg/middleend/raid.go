@@ -0,0 +1,400 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// RAID levels of the RAID volumes
+const (
+	raidLevel0 = "RAID0"
+	raidLevel1 = "RAID1"
+	raidLevel5 = "RAID5"
+)
+
+// defaultRaidStripSizeKb is the strip size of the striped RAID levels when none is given
+const defaultRaidStripSizeKb = 64
+
+// States of a RAID volume rebuild reported by the firmware
+const (
+	rebuildStateRunning = "running"
+	rebuildStateDone    = "done"
+)
+
+// raidMinVolumes is the minimum number of backend volumes of each RAID level
+var raidMinVolumes = map[string]int{
+	raidLevel0: 2,
+	raidLevel1: 2,
+	raidLevel5: 3,
+}
+
+// RaidVolume represents a volume striped or mirrored across backend volumes by the DPU
+type RaidVolume struct {
+	// Name of the RAID volume, its resource ID is the volume name used by the namespaces
+	Name string `json:"name"`
+	// Level is RAID0, RAID1 or RAID5, RAID5 requires a firmware with parity support
+	Level string `json:"level"`
+	// VolumeNameRefs are the backend volumes the RAID volume is built from
+	VolumeNameRefs []string `json:"volumeNameRefs"`
+	// StripSizeKb is the strip size of RAID0 and RAID5 volumes, 64 by default
+	StripSizeKb int32 `json:"stripSizeKb,omitempty"`
+}
+
+// CreateRaidVolumeRequest represents a request to create a RAID volume
+type CreateRaidVolumeRequest struct {
+	// RaidVolumeID is the ID of the RAID volume, generated when empty
+	RaidVolumeID string `json:"raidVolumeId"`
+	// RaidVolume to create
+	RaidVolume *RaidVolume `json:"raidVolume"`
+}
+
+// DeleteRaidVolumeRequest represents a request to delete a RAID volume
+type DeleteRaidVolumeRequest struct {
+	// Name of the RAID volume
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the volume doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetRaidVolumeRequest represents a request to get a RAID volume
+type GetRaidVolumeRequest struct {
+	// Name of the RAID volume
+	Name string `json:"name"`
+}
+
+// ListRaidVolumesRequest represents a request to list RAID volumes
+type ListRaidVolumesRequest struct {
+	// PageSize is the maximum number of volumes returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListRaidVolumesResponse represents a list of RAID volumes
+type ListRaidVolumesResponse struct {
+	// RaidVolumes is the page of volumes
+	RaidVolumes []*RaidVolume `json:"raidVolumes"`
+	// NextPageToken is set when more volumes are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// ReplaceRaidVolumeMemberRequest represents a request to replace a backend volume of a RAID volume
+type ReplaceRaidVolumeMemberRequest struct {
+	// Name of the RAID volume
+	Name string `json:"name"`
+	// VolumeNameRef is the backend volume to replace, i.e. a failed one
+	VolumeNameRef string `json:"volumeNameRef"`
+	// NewVolumeNameRef is the backend volume the data is rebuilt on
+	NewVolumeNameRef string `json:"newVolumeNameRef"`
+}
+
+// RebuildRaidVolumeMetadata describes a rebuild operation
+type RebuildRaidVolumeMetadata struct {
+	// Name of the RAID volume
+	Name string `json:"name"`
+	// VolumeNameRef is the backend volume being rebuilt
+	VolumeNameRef string `json:"volumeNameRef"`
+}
+
+// resourceIDToRaidVolumeName builds the name of a RAID volume, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToRaidVolumeName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"raidVolumes", resourceID,
+	)
+}
+
+func sortRaidVolumes(volumes []*RaidVolume) {
+	sort.Slice(volumes, func(i int, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+}
+
+// CreateRaidVolume creates a RAID volume across backend volumes, the data is striped or
+// mirrored by the DPU so the hosts don't need to run their own software RAID
+func (s *Server) CreateRaidVolume(ctx context.Context, in *CreateRaidVolumeRequest) (*RaidVolume, error) {
+	// check input correctness
+	if err := s.validateCreateRaidVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.RaidVolumeID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.RaidVolumeID, "name", in.RaidVolume.Name)
+		resourceID = in.RaidVolumeID
+	}
+	name := resourceIDToRaidVolumeName(resourceID)
+	// idempotent API when called with same key, should return same object
+	volume, found, err := s.getRaidVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing RaidVolume", "name", name)
+		return volume, nil
+	}
+	// not found, so create a new one
+	volume = &RaidVolume{
+		Name:           name,
+		Level:          in.RaidVolume.Level,
+		VolumeNameRefs: in.RaidVolume.VolumeNameRefs,
+		StripSizeKb:    in.RaidVolume.StripSizeKb,
+	}
+	// mirrors are not striped
+	if volume.Level == raidLevel1 {
+		volume.StripSizeKb = 0
+	} else if volume.StripSizeKb == 0 {
+		volume.StripSizeKb = defaultRaidStripSizeKb
+	}
+	params := models.MrvlBdevRaidCreateParams{
+		Name:        resourceID,
+		RaidLevel:   strings.ToLower(volume.Level),
+		StripSizeKb: int(volume.StripSizeKb),
+		BaseBdevs:   volume.VolumeNameRefs,
+	}
+	var result models.MrvlBdevRaidCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_raid_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Raid Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_raid_create")
+	}
+	err = s.saveRaidVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// DeleteRaidVolume deletes a RAID volume, the backend volumes are left untouched
+func (s *Server) DeleteRaidVolume(ctx context.Context, in *DeleteRaidVolumeRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteRaidVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getRaidVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevRaidDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevRaidDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_raid_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Raid Dev: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_raid_delete")
+	}
+	// remove from the Database
+	err = s.store.Delete(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListRaidVolumes lists RAID volumes
+func (s *Server) ListRaidVolumes(ctx context.Context, in *ListRaidVolumesRequest) (*ListRaidVolumesResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	var result models.MrvlBdevRaidGetListResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_raid_get_list", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list Raid Devs"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_raid_get_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.RaidList), "offset", offset, "size", size)
+	result.RaidList, hasMoreElements = utils.LimitPagination(result.RaidList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*RaidVolume, len(result.RaidList))
+	for i := range result.RaidList {
+		r := &result.RaidList[i]
+		Blobarray[i] = &RaidVolume{
+			Name:           resourceIDToRaidVolumeName(r.Name),
+			Level:          strings.ToUpper(r.RaidLevel),
+			VolumeNameRefs: r.BaseBdevs,
+			StripSizeKb:    int32(r.StripSizeKb),
+		}
+	}
+	sortRaidVolumes(Blobarray)
+	return &ListRaidVolumesResponse{RaidVolumes: Blobarray, NextPageToken: token}, nil
+}
+
+// GetRaidVolume gets a RAID volume
+func (s *Server) GetRaidVolume(_ context.Context, in *GetRaidVolumeRequest) (*RaidVolume, error) {
+	// check input correctness
+	if err := s.validateGetRaidVolumeRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getRaidVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// ReplaceRaidVolumeMember replaces a backend volume of a redundant RAID volume, i.e. a failed
+// one, returning a long-running operation tracking the rebuild of the data on the new volume.
+// The RAID volume stays online while it is rebuilt
+func (s *Server) ReplaceRaidVolumeMember(ctx context.Context, in *ReplaceRaidVolumeMemberRequest) (*operations.Operation, error) {
+	// check input correctness
+	if err := s.validateReplaceRaidVolumeMemberRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	volume, found, err := s.getRaidVolume(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if volume.Level == raidLevel0 {
+		msg := fmt.Sprintf("Raid volume %s has no redundancy to rebuild from", volume.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	member := -1
+	for i, ref := range volume.VolumeNameRefs {
+		if ref == in.VolumeNameRef {
+			member = i
+		}
+	}
+	if member < 0 {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.VolumeNameRef)
+		return nil, err
+	}
+	resourceID := path.Base(volume.Name)
+	params := models.MrvlBdevRaidReplaceBaseBdevParams{
+		Name:            resourceID,
+		BaseBdevName:    in.VolumeNameRef,
+		NewBaseBdevName: in.NewVolumeNameRef,
+	}
+	var result models.MrvlBdevRaidReplaceBaseBdevResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_raid_replace_base_bdev", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not replace %s of Raid Dev: %s", in.VolumeNameRef, resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_raid_replace_base_bdev")
+	}
+	// the firmware uses the new volume from now on, even while it is rebuilt
+	volume.VolumeNameRefs[member] = in.NewVolumeNameRef
+	err = s.saveRaidVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &RebuildRaidVolumeMetadata{
+		Name:          volume.Name,
+		VolumeNameRef: in.NewVolumeNameRef,
+	}
+	return s.operations.Start(metadata, func(ctx context.Context) (interface{}, error) {
+		return s.waitRaidVolumeRebuild(ctx, volume)
+	}), nil
+}
+
+// waitRaidVolumeRebuild polls the firmware until the data of the RAID volume is rebuilt
+func (s *Server) waitRaidVolumeRebuild(ctx context.Context, volume *RaidVolume) (*RaidVolume, error) {
+	resourceID := path.Base(volume.Name)
+	for {
+		params := models.MrvlBdevRaidGetRebuildStatusParams{
+			Name: resourceID,
+		}
+		var result models.MrvlBdevRaidGetRebuildStatusResult
+		err := s.rpc.Call(ctx, "mrvl_bdev_raid_get_rebuild_status", &params, &result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != 0 {
+			msg := fmt.Sprintf("Could not get rebuild status of Raid Dev: %s", resourceID)
+			return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_raid_get_rebuild_status")
+		}
+		switch result.State {
+		case rebuildStateRunning:
+			operations.ReportProgress(ctx, int32(result.Progress))
+			operations.ReportBytes(ctx, result.BytesDone, result.TotalBytes)
+		case rebuildStateDone:
+			slog.Info("Rebuild of Raid Dev is done", "name", resourceID)
+			return volume, nil
+		default:
+			// the volume keeps running degraded on failure
+			msg := fmt.Sprintf("Rebuild of Raid Dev %s failed, the volume is degraded", resourceID)
+			return nil, status.Errorf(codes.Aborted, msg)
+		}
+		time.Sleep(s.rebuildPollInterval)
+	}
+}
+
+// getRaidVolume fetches a RAID volume from the database,
+// RAID volumes are not protobufs so they are stored JSON encoded
+func (s *Server) getRaidVolume(name string) (*RaidVolume, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	volume := new(RaidVolume)
+	if err := json.Unmarshal(value.Value, volume); err != nil {
+		return nil, false, err
+	}
+	return volume, true, nil
+}
+
+func (s *Server) saveRaidVolume(volume *RaidVolume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(volume.Name, wrapperspb.Bytes(data))
+}
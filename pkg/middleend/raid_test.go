@@ -0,0 +1,632 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/operations"
+)
+
+func TestMiddleEnd_CreateRaidVolume(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *RaidVolume
+		out     *RaidVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &RaidVolume{Level: "RAID1", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"unsupported level": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID6", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Level value (%s) is not supported, have to be RAID0, RAID1 or RAID5", "RAID6"),
+			exist:   false,
+		},
+		"not enough volumes": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID5", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "RAID5 needs at least 3 volumes, got 2",
+			exist:   false,
+		},
+		"volume used twice": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID1", VolumeNameRefs: []string{"volume-42", "volume-42"}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Volume %s is used more than once", "volume-42"),
+			exist:   false,
+		},
+		"strip size not a power of 2": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID0", VolumeNameRefs: []string{"volume-42", "volume-43"}, StripSizeKb: 48},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("StripSizeKb value (%d) is not supported, have to be a power of 2 between 4 and 1024", 48),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID1", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create Raid Dev: %v", testRaidVolumeID),
+			exist:   false,
+		},
+		"valid request with empty SPDK response": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID1", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_create: %v", "EOF"),
+			exist:   false,
+		},
+		"valid request with ID mismatch SPDK response": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID1", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_create: %v", "json response ID mismatch"),
+			exist:   false,
+		},
+		"valid request with error code from SPDK response": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID1", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_create: %v", "json response error: myopierr"),
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID1", VolumeNameRefs: []string{"volume-42", "volume-43"}, StripSizeKb: 128},
+			out:     &testRaidVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"valid striped request with default strip size": {
+			id: testRaidVolumeID,
+			in: &RaidVolume{Level: "RAID0", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out: &RaidVolume{
+				Name:           testRaidVolumeName,
+				Level:          "RAID0",
+				VolumeNameRefs: []string{"volume-42", "volume-43"},
+				StripSizeKb:    64,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID1", VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     &testRaidVolume,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"no required volume_name_refs field": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{Level: "RAID1"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: raid_volume.volume_name_refs",
+			exist:   false,
+		},
+		"no required level field": {
+			id:      testRaidVolumeID,
+			in:      &RaidVolume{VolumeNameRefs: []string{"volume-42", "volume-43"}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: raid_volume.level",
+			exist:   false,
+		},
+		"no required field": {
+			id:      testRaidVolumeID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: raid_volume",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveRaidVolume(&testRaidVolume)
+			}
+
+			request := &CreateRaidVolumeRequest{RaidVolume: tt.in, RaidVolumeID: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateRaidVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteRaidVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testRaidVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete Raid Dev: %v", testRaidVolumeID),
+			missing: false,
+		},
+		"valid request with empty SPDK response": {
+			in:      testRaidVolumeName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_delete: %v", "EOF"),
+			missing: false,
+		},
+		"valid request with error code from SPDK response": {
+			in:      testRaidVolumeName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_delete: %v", "json response error: myopierr"),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testRaidVolumeName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRaidVolumeName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRaidVolumeName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToRaidVolumeName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveRaidVolume(&testRaidVolume)
+
+			request := &DeleteRaidVolumeRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteRaidVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_ListRaidVolumes(t *testing.T) {
+	testRaidList := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "raid_list": [{"name": "raid1", "raid_level": "raid1", "base_bdevs": ["volume2", "volume3"]},{"name": "raid0", "raid_level": "raid0", "strip_size_kb": 64, "base_bdevs": ["volume0", "volume1"]}]}}`
+	tests := map[string]struct {
+		out     []*RaidVolume
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		size    int32
+		token   string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list Raid Devs",
+			size:    0,
+			token:   "",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_get_list: %v", "EOF"),
+			size:    0,
+			token:   "",
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_get_list: %v", "json response error: myopierr"),
+			size:    0,
+			token:   "",
+		},
+		"pagination negative": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+		},
+		"pagination error": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+		},
+		"pagination": {
+			out: []*RaidVolume{
+				{Name: resourceIDToRaidVolumeName("raid1"), Level: "RAID1", VolumeNameRefs: []string{"volume2", "volume3"}},
+			},
+			spdk:    []string{testRaidList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+		},
+		"valid request with valid SPDK response": {
+			out: []*RaidVolume{
+				{Name: resourceIDToRaidVolumeName("raid0"), Level: "RAID0", VolumeNameRefs: []string{"volume0", "volume1"}, StripSizeKb: 64},
+				{Name: resourceIDToRaidVolumeName("raid1"), Level: "RAID1", VolumeNameRefs: []string{"volume2", "volume3"}},
+			},
+			spdk:    []string{testRaidList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &ListRaidVolumesRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListRaidVolumes(testEnv.ctx, request)
+
+			var volumes []*RaidVolume
+			if response != nil {
+				volumes = response.RaidVolumes
+				// Empty NextPageToken indicates end of results list
+				if tt.size != 1 && response.NextPageToken != "" {
+					t.Error("Expected end of results, receieved non-empty next page token", response.NextPageToken)
+				}
+			}
+			if !reflect.DeepEqual(volumes, tt.out) {
+				t.Error("response: expected", tt.out, "received", volumes)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_GetRaidVolume(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *RaidVolume
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testRaidVolumeName,
+			out:     &testRaidVolume,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToRaidVolumeName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRaidVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveRaidVolume(&testRaidVolume)
+
+			request := &GetRaidVolumeRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetRaidVolume(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_ReplaceRaidVolumeMember(t *testing.T) {
+	testRebuiltRaidVolume := RaidVolume{
+		Name:           testRaidVolumeName,
+		Level:          "RAID1",
+		VolumeNameRefs: []string{"volume-42", "volume-44"},
+	}
+	tests := map[string]struct {
+		in         *ReplaceRaidVolumeMemberRequest
+		volume     RaidVolume
+		spdk       []string
+		errCode    codes.Code
+		errMsg     string
+		opResponse *RaidVolume
+		opErr      *operations.Error
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume:  testRaidVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not replace %v of Raid Dev: %v", "volume-43", testRaidVolumeID),
+		},
+		"valid request with empty SPDK response": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume:  testRaidVolume,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_replace_base_bdev: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume:  testRaidVolume,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_raid_replace_base_bdev: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			in:     &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume: testRaidVolume,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "running", "progress": 50}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "done", "progress": 100}}`,
+			},
+			errCode:    codes.OK,
+			errMsg:     "",
+			opResponse: &testRebuiltRaidVolume,
+		},
+		"rebuild failed in the firmware": {
+			in:     &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume: testRaidVolume,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "state": "failed", "progress": 20}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.Aborted, Message: fmt.Sprintf("Rebuild of Raid Dev %s failed, the volume is degraded", testRaidVolumeID)},
+		},
+		"rebuild status with invalid SPDK response": {
+			in:     &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume: testRaidVolume,
+			spdk: []string{
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`,
+				`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`,
+			},
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &operations.Error{Code: codes.InvalidArgument, Message: fmt.Sprintf("Could not get rebuild status of Raid Dev: %s", testRaidVolumeID)},
+		},
+		"no redundancy": {
+			in: &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume: RaidVolume{
+				Name:           testRaidVolumeName,
+				Level:          "RAID0",
+				VolumeNameRefs: []string{"volume-42", "volume-43"},
+				StripSizeKb:    64,
+			},
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  fmt.Sprintf("Raid volume %s has no redundancy to rebuild from", testRaidVolumeName),
+		},
+		"unknown member": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-45", NewVolumeNameRef: "volume-44"},
+			volume:  testRaidVolume,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", "volume-45"),
+		},
+		"replacing with itself": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-43"},
+			volume:  testRaidVolume,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Volume %s can't replace itself", "volume-43"),
+		},
+		"valid request with unknown key": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: resourceIDToRaidVolumeName("unknown-id"), VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume:  testRaidVolume,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToRaidVolumeName("unknown-id")),
+		},
+		"malformed name": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: "-ABC-DEF", VolumeNameRef: "volume-43", NewVolumeNameRef: "volume-44"},
+			volume:  testRaidVolume,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required new_volume_name_ref field": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName, VolumeNameRef: "volume-43"},
+			volume:  testRaidVolume,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: new_volume_name_ref",
+		},
+		"no required field": {
+			in:      &ReplaceRaidVolumeMemberRequest{Name: testRaidVolumeName},
+			volume:  testRaidVolume,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: volume_name_ref",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveRaidVolume(&tt.volume)
+
+			response, err := testEnv.opiSpdkServer.ReplaceRaidVolumeMember(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if err != nil {
+				if response != nil {
+					t.Error("response: expected", nil, "received", response)
+				}
+				return
+			}
+
+			testEnv.opiSpdkServer.operations.Wait()
+			op, _ := testEnv.opiSpdkServer.operations.GetOperation(testEnv.ctx, &operations.GetOperationRequest{Name: response.Name})
+			if !op.Done {
+				t.Error("expected operation to be done")
+			}
+			if volume, _ := op.Response.(*RaidVolume); !reflect.DeepEqual(volume, tt.opResponse) {
+				t.Error("operation response: expected", tt.opResponse, "received", op.Response)
+			}
+			if (op.Error == nil) != (tt.opErr == nil) || (tt.opErr != nil && *op.Error != *tt.opErr) {
+				t.Error("operation error: expected", tt.opErr, "received", op.Error)
+			}
+			// the new volume is used even when the rebuild fails
+			volume, _, _ := testEnv.opiSpdkServer.getRaidVolume(testRaidVolumeName)
+			if !reflect.DeepEqual(volume, &testRebuiltRaidVolume) {
+				t.Error("stored volume: expected", &testRebuiltRaidVolume, "received", volume)
+			}
+		})
+	}
+}
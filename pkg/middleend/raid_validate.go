@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateRaidVolumeRequest(in *CreateRaidVolumeRequest) error {
+	// check required fields
+	if in.RaidVolume == nil {
+		return errors.New("missing required field: raid_volume")
+	}
+	if in.RaidVolume.Level == "" {
+		return errors.New("missing required field: raid_volume.level")
+	}
+	if len(in.RaidVolume.VolumeNameRefs) == 0 {
+		return errors.New("missing required field: raid_volume.volume_name_refs")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.RaidVolumeID != "" {
+		if err := resourceid.ValidateUserSettable(in.RaidVolumeID); err != nil {
+			return err
+		}
+	}
+	// check Level
+	minVolumes, ok := raidMinVolumes[in.RaidVolume.Level]
+	if !ok {
+		msg := fmt.Sprintf("Level value (%s) is not supported, have to be RAID0, RAID1 or RAID5", in.RaidVolume.Level)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	if len(in.RaidVolume.VolumeNameRefs) < minVolumes {
+		msg := fmt.Sprintf("%s needs at least %d volumes, got %d", in.RaidVolume.Level, minVolumes, len(in.RaidVolume.VolumeNameRefs))
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	// a volume can only be used once
+	refs := make(map[string]bool)
+	for _, ref := range in.RaidVolume.VolumeNameRefs {
+		if refs[ref] {
+			msg := fmt.Sprintf("Volume %s is used more than once", ref)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		refs[ref] = true
+	}
+	// check StripSizeKb, a power of 2 between 4KiB and 1MiB
+	if size := in.RaidVolume.StripSizeKb; size != 0 && (size < 4 || size > 1024 || size&(size-1) != 0) {
+		msg := fmt.Sprintf("StripSizeKb value (%d) is not supported, have to be a power of 2 between 4 and 1024", size)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteRaidVolumeRequest(in *DeleteRaidVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetRaidVolumeRequest(in *GetRaidVolumeRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateReplaceRaidVolumeMemberRequest(in *ReplaceRaidVolumeMemberRequest) error {
+	// check required fields
+	if in.VolumeNameRef == "" {
+		return errors.New("missing required field: volume_name_ref")
+	}
+	if in.NewVolumeNameRef == "" {
+		return errors.New("missing required field: new_volume_name_ref")
+	}
+	if in.VolumeNameRef == in.NewVolumeNameRef {
+		msg := fmt.Sprintf("Volume %s can't replace itself", in.VolumeNameRef)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return validateResourceName(in.Name)
+}
@@ -0,0 +1,300 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Snapshot represents a point-in-time copy of a volume
+type Snapshot struct {
+	// Name of the snapshot
+	Name string `json:"name"`
+	// VolumeNameRef is the volume the snapshot is taken from
+	VolumeNameRef string `json:"volumeNameRef"`
+	// CreateTime is when the snapshot was taken, in RFC 3339 format
+	CreateTime string `json:"createTime"`
+}
+
+// CreateSnapshotRequest represents a request to take a snapshot of a volume
+type CreateSnapshotRequest struct {
+	// SnapshotID is the ID of the snapshot, generated when empty
+	SnapshotID string `json:"snapshotId"`
+	// Snapshot to take
+	Snapshot *Snapshot `json:"snapshot"`
+}
+
+// DeleteSnapshotRequest represents a request to delete a snapshot
+type DeleteSnapshotRequest struct {
+	// Name of the snapshot
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the snapshot doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// GetSnapshotRequest represents a request to get a snapshot
+type GetSnapshotRequest struct {
+	// Name of the snapshot
+	Name string `json:"name"`
+}
+
+// ListSnapshotsRequest represents a request to list snapshots
+type ListSnapshotsRequest struct {
+	// VolumeNameRef only lists the snapshots of this volume when set
+	VolumeNameRef string `json:"volumeNameRef"`
+	// PageSize is the maximum number of snapshots returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListSnapshotsResponse represents a list of snapshots
+type ListSnapshotsResponse struct {
+	// Snapshots is the page of snapshots
+	Snapshots []*Snapshot `json:"snapshots"`
+	// NextPageToken is set when more snapshots are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// RevertSnapshotRequest represents a request to revert a volume to a snapshot
+type RevertSnapshotRequest struct {
+	// Name of the snapshot
+	Name string `json:"name"`
+}
+
+// resourceIDToSnapshotName builds the name of a snapshot, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToSnapshotName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"snapshots", resourceID,
+	)
+}
+
+func sortSnapshots(snapshots []*Snapshot) {
+	sort.Slice(snapshots, func(i int, j int) bool {
+		return snapshots[i].Name < snapshots[j].Name
+	})
+}
+
+// snapshotCreateTime formats the creation time reported by the firmware, in seconds since the epoch
+func snapshotCreateTime(creationTime int64) string {
+	return time.Unix(creationTime, 0).UTC().Format(time.RFC3339)
+}
+
+// CreateSnapshot takes a crash-consistent snapshot of a volume, the firmware holds the I/Os
+// of the volume while the snapshot is taken
+func (s *Server) CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest) (*Snapshot, error) {
+	// check input correctness
+	if err := s.validateCreateSnapshotRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.SnapshotID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.SnapshotID, "name", in.Snapshot.Name)
+		resourceID = in.SnapshotID
+	}
+	name := resourceIDToSnapshotName(resourceID)
+	// idempotent API when called with same key, should return same object
+	snapshot, found, err := s.getSnapshot(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing Snapshot", "name", name)
+		return snapshot, nil
+	}
+	// not found, so create a new one
+	params := models.MrvlBdevSnapshotCreateParams{
+		Name:         resourceID,
+		BaseBdevName: in.Snapshot.VolumeNameRef,
+	}
+	var result models.MrvlBdevSnapshotCreateResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_snapshot_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create Snapshot: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_snapshot_create")
+	}
+	snapshot = &Snapshot{
+		Name:          name,
+		VolumeNameRef: in.Snapshot.VolumeNameRef,
+		CreateTime:    snapshotCreateTime(result.CreationTime),
+	}
+	err = s.saveSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// DeleteSnapshot deletes a snapshot
+func (s *Server) DeleteSnapshot(ctx context.Context, in *DeleteSnapshotRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteSnapshotRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	snapshot, found, err := s.getSnapshot(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(snapshot.Name)
+	params := models.MrvlBdevSnapshotDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevSnapshotDeleteResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_snapshot_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete Snapshot: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_snapshot_delete")
+	}
+	// remove from the Database
+	err = s.store.Delete(snapshot.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListSnapshots lists snapshots, of all the volumes or of a single one
+func (s *Server) ListSnapshots(ctx context.Context, in *ListSnapshotsRequest) (*ListSnapshotsResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	params := models.MrvlBdevSnapshotGetListParams{
+		BaseBdevName: in.VolumeNameRef,
+	}
+	var result models.MrvlBdevSnapshotGetListResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_snapshot_get_list", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list Snapshots"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_snapshot_get_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.SnapshotList), "offset", offset, "size", size)
+	result.SnapshotList, hasMoreElements = utils.LimitPagination(result.SnapshotList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*Snapshot, len(result.SnapshotList))
+	for i := range result.SnapshotList {
+		r := &result.SnapshotList[i]
+		Blobarray[i] = &Snapshot{
+			Name:          resourceIDToSnapshotName(r.Name),
+			VolumeNameRef: r.BaseBdevName,
+			CreateTime:    snapshotCreateTime(r.CreationTime),
+		}
+	}
+	sortSnapshots(Blobarray)
+	return &ListSnapshotsResponse{Snapshots: Blobarray, NextPageToken: token}, nil
+}
+
+// GetSnapshot gets a snapshot
+func (s *Server) GetSnapshot(_ context.Context, in *GetSnapshotRequest) (*Snapshot, error) {
+	// check input correctness
+	if err := s.validateGetSnapshotRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	snapshot, found, err := s.getSnapshot(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// RevertSnapshot reverts the volume of a snapshot to the content it had when the snapshot
+// was taken, the data written since then is lost and the snapshot is kept
+func (s *Server) RevertSnapshot(ctx context.Context, in *RevertSnapshotRequest) (*Snapshot, error) {
+	// check input correctness
+	if err := s.validateRevertSnapshotRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	snapshot, found, err := s.getSnapshot(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(snapshot.Name)
+	params := models.MrvlBdevSnapshotRevertParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevSnapshotRevertResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_snapshot_revert", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not revert %s to Snapshot: %s", snapshot.VolumeNameRef, resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_snapshot_revert")
+	}
+	return snapshot, nil
+}
+
+// getSnapshot fetches a snapshot from the database,
+// snapshots are not protobufs so they are stored JSON encoded
+func (s *Server) getSnapshot(name string) (*Snapshot, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	snapshot := new(Snapshot)
+	if err := json.Unmarshal(value.Value, snapshot); err != nil {
+		return nil, false, err
+	}
+	return snapshot, true, nil
+}
+
+func (s *Server) saveSnapshot(snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(snapshot.Name, wrapperspb.Bytes(data))
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+
+	"go.einride.tech/aip/resourceid"
+)
+
+func (s *Server) validateCreateSnapshotRequest(in *CreateSnapshotRequest) error {
+	// check required fields
+	if in.Snapshot == nil {
+		return errors.New("missing required field: snapshot")
+	}
+	if in.Snapshot.VolumeNameRef == "" {
+		return errors.New("missing required field: snapshot.volume_name_ref")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.SnapshotID != "" {
+		if err := resourceid.ValidateUserSettable(in.SnapshotID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) validateDeleteSnapshotRequest(in *DeleteSnapshotRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateGetSnapshotRequest(in *GetSnapshotRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateRevertSnapshotRequest(in *RevertSnapshotRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
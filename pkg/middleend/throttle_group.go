@@ -0,0 +1,336 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ThrottleGroupLimits represents the performance budget shared by the volumes of a
+// throttle group, a limit of 0 is unlimited
+type ThrottleGroupLimits struct {
+	// RwIopsKiops is the maximum of read and write I/Os per second, in thousands
+	RwIopsKiops int64 `json:"rwIopsKiops"`
+	// RdBandwidthMbs is the maximum read bandwidth in MB/s
+	RdBandwidthMbs int64 `json:"rdBandwidthMbs"`
+	// WrBandwidthMbs is the maximum write bandwidth in MB/s
+	WrBandwidthMbs int64 `json:"wrBandwidthMbs"`
+	// RwBandwidthMbs is the maximum read and write bandwidth in MB/s
+	RwBandwidthMbs int64 `json:"rwBandwidthMbs"`
+}
+
+// ThrottleGroup represents volumes sharing an aggregate IOPS and bandwidth budget, i.e. all
+// the volumes of a tenant so it can't get more performance by creating more namespaces
+type ThrottleGroup struct {
+	// Name of the throttle group
+	Name string `json:"name"`
+	// VolumeNameRefs are the volumes sharing the budget, a volume belongs to one group only
+	VolumeNameRefs []string `json:"volumeNameRefs"`
+	// Limits is the budget of the group
+	Limits *ThrottleGroupLimits `json:"limits"`
+}
+
+// CreateThrottleGroupRequest represents a request to create a throttle group
+type CreateThrottleGroupRequest struct {
+	// ThrottleGroupID is the ID of the throttle group, generated when empty
+	ThrottleGroupID string `json:"throttleGroupId"`
+	// ThrottleGroup to create
+	ThrottleGroup *ThrottleGroup `json:"throttleGroup"`
+}
+
+// DeleteThrottleGroupRequest represents a request to delete a throttle group
+type DeleteThrottleGroupRequest struct {
+	// Name of the throttle group
+	Name string `json:"name"`
+	// AllowMissing makes the deletion succeed when the group doesn't exist
+	AllowMissing bool `json:"allowMissing"`
+}
+
+// UpdateThrottleGroupRequest represents a request to change the volumes or the limits of a throttle group
+type UpdateThrottleGroupRequest struct {
+	// Name of the throttle group
+	Name string `json:"name"`
+	// VolumeNameRefs replace the volumes of the group, kept when empty
+	VolumeNameRefs []string `json:"volumeNameRefs"`
+	// Limits replace the budget of the group, kept when nil
+	Limits *ThrottleGroupLimits `json:"limits"`
+}
+
+// GetThrottleGroupRequest represents a request to get a throttle group
+type GetThrottleGroupRequest struct {
+	// Name of the throttle group
+	Name string `json:"name"`
+}
+
+// ListThrottleGroupsRequest represents a request to list throttle groups
+type ListThrottleGroupsRequest struct {
+	// PageSize is the maximum number of groups returned
+	PageSize int32 `json:"pageSize"`
+	// PageToken is the NextPageToken of the previous call
+	PageToken string `json:"pageToken"`
+}
+
+// ListThrottleGroupsResponse represents a list of throttle groups
+type ListThrottleGroupsResponse struct {
+	// ThrottleGroups is the page of groups
+	ThrottleGroups []*ThrottleGroup `json:"throttleGroups"`
+	// NextPageToken is set when more groups are available
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// resourceIDToThrottleGroupName builds the name of a throttle group, they have their own
+// collection as they are not part of the OPI APIs
+func resourceIDToThrottleGroupName(resourceID string) string {
+	return resourcename.Join(
+		"//storage.opiproject.org",
+		"throttleGroups", resourceID,
+	)
+}
+
+func sortThrottleGroups(groups []*ThrottleGroup) {
+	sort.Slice(groups, func(i int, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+}
+
+// throttleGroupParams builds the firmware parameters of a throttle group
+func throttleGroupParams(resourceID string, group *ThrottleGroup) models.MrvlBdevQosGroupParams {
+	return models.MrvlBdevQosGroupParams{
+		Name:           resourceID,
+		Bdevs:          group.VolumeNameRefs,
+		RwIosPerSec:    group.Limits.RwIopsKiops * 1000,
+		RMbytesPerSec:  group.Limits.RdBandwidthMbs,
+		WMbytesPerSec:  group.Limits.WrBandwidthMbs,
+		RwMbytesPerSec: group.Limits.RwBandwidthMbs,
+	}
+}
+
+// CreateThrottleGroup creates a throttle group, the firmware throttles the I/Os of all its
+// volumes against the budget of the group on top of the limits of each QoS volume
+func (s *Server) CreateThrottleGroup(ctx context.Context, in *CreateThrottleGroupRequest) (*ThrottleGroup, error) {
+	// check input correctness
+	if err := s.validateCreateThrottleGroupRequest(in); err != nil {
+		return nil, err
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	resourceID := resourceid.NewSystemGenerated()
+	if in.ThrottleGroupID != "" {
+		slog.Warn("client provided the ID of a resource, ignoring the name field", "id", in.ThrottleGroupID, "name", in.ThrottleGroup.Name)
+		resourceID = in.ThrottleGroupID
+	}
+	name := resourceIDToThrottleGroupName(resourceID)
+	// idempotent API when called with same key, should return same object
+	group, found, err := s.getThrottleGroup(name)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		slog.Info("Already existing ThrottleGroup", "name", name)
+		return group, nil
+	}
+	// not found, so create a new one
+	limits := *in.ThrottleGroup.Limits
+	group = &ThrottleGroup{
+		Name:           name,
+		VolumeNameRefs: in.ThrottleGroup.VolumeNameRefs,
+		Limits:         &limits,
+	}
+	params := throttleGroupParams(resourceID, group)
+	var result models.MrvlBdevQosGroupResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_qos_group_create", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not create QoS Group: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_qos_group_create")
+	}
+	err = s.saveThrottleGroup(group)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// DeleteThrottleGroup deletes a throttle group, its volumes are only limited by their QoS volumes afterwards
+func (s *Server) DeleteThrottleGroup(ctx context.Context, in *DeleteThrottleGroupRequest) (*emptypb.Empty, error) {
+	// check input correctness
+	if err := s.validateDeleteThrottleGroupRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	group, found, err := s.getThrottleGroup(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if in.AllowMissing {
+			return &emptypb.Empty{}, nil
+		}
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	resourceID := path.Base(group.Name)
+	params := models.MrvlBdevQosGroupDeleteParams{
+		Name: resourceID,
+	}
+	var result models.MrvlBdevQosGroupResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_qos_group_delete", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not delete QoS Group: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_qos_group_delete")
+	}
+	// remove from the Database
+	err = s.store.Delete(group.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UpdateThrottleGroup changes the volumes or the budget of a throttle group, i.e. when a
+// tenant creates a namespace or upgrades its plan
+func (s *Server) UpdateThrottleGroup(ctx context.Context, in *UpdateThrottleGroupRequest) (*ThrottleGroup, error) {
+	// check input correctness
+	if err := s.validateUpdateThrottleGroupRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	group, found, err := s.getThrottleGroup(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	updated := &ThrottleGroup{
+		Name:           group.Name,
+		VolumeNameRefs: group.VolumeNameRefs,
+		Limits:         group.Limits,
+	}
+	if len(in.VolumeNameRefs) != 0 {
+		updated.VolumeNameRefs = in.VolumeNameRefs
+	}
+	if in.Limits != nil {
+		limits := *in.Limits
+		updated.Limits = &limits
+	}
+	resourceID := path.Base(group.Name)
+	params := throttleGroupParams(resourceID, updated)
+	var result models.MrvlBdevQosGroupResult
+	err = s.rpc.Call(ctx, "mrvl_bdev_qos_group_modify", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := fmt.Sprintf("Could not modify QoS Group: %s", resourceID)
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_qos_group_modify")
+	}
+	err = s.saveThrottleGroup(updated)
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// ListThrottleGroups lists throttle groups
+func (s *Server) ListThrottleGroups(ctx context.Context, in *ListThrottleGroupsRequest) (*ListThrottleGroupsResponse, error) {
+	// fetch object from the database
+	size, offset, perr := s.Pagination.Extract(in.PageSize, in.PageToken)
+	if perr != nil {
+		return nil, perr
+	}
+	var result models.MrvlBdevQosGroupGetListResult
+	err := s.rpc.Call(ctx, "mrvl_bdev_qos_group_get_list", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not list QoS Groups"
+		return nil, result.Status.Err(codes.InvalidArgument, msg, "mrvl_bdev_qos_group_get_list")
+	}
+	token, hasMoreElements := "", false
+	slog.Debug("Limiting result", "len", len(result.GroupList), "offset", offset, "size", size)
+	result.GroupList, hasMoreElements = utils.LimitPagination(result.GroupList, offset, size)
+	if hasMoreElements {
+		token = s.Pagination.NewToken(offset + size)
+	}
+	Blobarray := make([]*ThrottleGroup, len(result.GroupList))
+	for i := range result.GroupList {
+		r := &result.GroupList[i]
+		Blobarray[i] = &ThrottleGroup{
+			Name:           resourceIDToThrottleGroupName(r.Name),
+			VolumeNameRefs: r.Bdevs,
+			Limits: &ThrottleGroupLimits{
+				RwIopsKiops:    r.RwIosPerSec / 1000,
+				RdBandwidthMbs: r.RMbytesPerSec,
+				WrBandwidthMbs: r.WMbytesPerSec,
+				RwBandwidthMbs: r.RwMbytesPerSec,
+			},
+		}
+	}
+	sortThrottleGroups(Blobarray)
+	return &ListThrottleGroupsResponse{ThrottleGroups: Blobarray, NextPageToken: token}, nil
+}
+
+// GetThrottleGroup gets a throttle group
+func (s *Server) GetThrottleGroup(_ context.Context, in *GetThrottleGroupRequest) (*ThrottleGroup, error) {
+	// check input correctness
+	if err := s.validateGetThrottleGroupRequest(in); err != nil {
+		return nil, err
+	}
+	// fetch object from the database
+	group, found, err := s.getThrottleGroup(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	return group, nil
+}
+
+// getThrottleGroup fetches a throttle group from the database,
+// throttle groups are not protobufs so they are stored JSON encoded
+func (s *Server) getThrottleGroup(name string) (*ThrottleGroup, bool, error) {
+	value := new(wrapperspb.BytesValue)
+	found, err := s.store.Get(name, value)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	group := new(ThrottleGroup)
+	if err := json.Unmarshal(value.Value, group); err != nil {
+		return nil, false, err
+	}
+	return group, true, nil
+}
+
+func (s *Server) saveThrottleGroup(group *ThrottleGroup) error {
+	data, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(group.Name, wrapperspb.Bytes(data))
+}
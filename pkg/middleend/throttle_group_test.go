@@ -0,0 +1,543 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestMiddleEnd_CreateThrottleGroup(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		in      *ThrottleGroup
+		out     *ThrottleGroup
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		exist   bool
+	}{
+		"illegal resource_id": {
+			id:      "CapitalLettersNotAllowed",
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42", "volume-43"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("user-settable ID must only contain lowercase, numbers and hyphens (%v)", "got: 'C' in position 0"),
+			exist:   false,
+		},
+		"valid request with invalid SPDK response": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42", "volume-43"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not create QoS Group: %v", testThrottleGroupID),
+			exist:   false,
+		},
+		"valid request with empty SPDK response": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42", "volume-43"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000}},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_create: %v", "EOF"),
+			exist:   false,
+		},
+		"valid request with ID mismatch SPDK response": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42", "volume-43"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000}},
+			out:     nil,
+			spdk:    []string{`{"id":0,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_create: %v", "json response ID mismatch"),
+			exist:   false,
+		},
+		"valid request with error code from SPDK response": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42", "volume-43"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_create: %v", "json response error: myopierr"),
+			exist:   false,
+		},
+		"valid request with valid SPDK response": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42", "volume-43"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000}},
+			out:     &testThrottleGroup,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   false,
+		},
+		"already exists": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42", "volume-43"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000}},
+			out:     &testThrottleGroup,
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			exist:   true,
+		},
+		"volume used twice": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42", "volume-42"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Volume %s is used more than once", "volume-42"),
+			exist:   false,
+		},
+		"negative limit": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42"}, Limits: &ThrottleGroupLimits{RdBandwidthMbs: -1}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "RdBandwidthMbs value (-1) can't be negative",
+			exist:   false,
+		},
+		"no limit set": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42"}, Limits: &ThrottleGroupLimits{}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Limits have to set at least one of RwIopsKiops, RdBandwidthMbs, WrBandwidthMbs or RwBandwidthMbs",
+			exist:   false,
+		},
+		"no required limits field": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{VolumeNameRefs: []string{"volume-42"}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: throttle_group.limits",
+			exist:   false,
+		},
+		"no required volume_name_refs field": {
+			id:      testThrottleGroupID,
+			in:      &ThrottleGroup{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: throttle_group.volume_name_refs",
+			exist:   false,
+		},
+		"no required field": {
+			id:      testThrottleGroupID,
+			in:      nil,
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: throttle_group",
+			exist:   false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			if tt.exist {
+				_ = testEnv.opiSpdkServer.saveThrottleGroup(&testThrottleGroup)
+			}
+
+			request := &CreateThrottleGroupRequest{ThrottleGroup: tt.in, ThrottleGroupID: tt.id}
+			response, err := testEnv.opiSpdkServer.CreateThrottleGroup(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_DeleteThrottleGroup(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *emptypb.Empty
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		missing bool
+	}{
+		"valid request with invalid SPDK response": {
+			in:      testThrottleGroupName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not delete QoS Group: %v", testThrottleGroupID),
+			missing: false,
+		},
+		"valid request with empty SPDK response": {
+			in:      testThrottleGroupName,
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_delete: %v", "EOF"),
+			missing: false,
+		},
+		"valid request with error code from SPDK response": {
+			in:      testThrottleGroupName,
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_delete: %v", "json response error: myopierr"),
+			missing: false,
+		},
+		"valid request with valid SPDK response": {
+			in:      testThrottleGroupName,
+			out:     &emptypb.Empty{},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: false,
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToThrottleGroupName("unknown-id"),
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToThrottleGroupName("unknown-id")),
+			missing: false,
+		},
+		"unknown key with missing allowed": {
+			in:      resourceIDToThrottleGroupName("unknown-id"),
+			out:     &emptypb.Empty{},
+			spdk:    []string{},
+			errCode: codes.OK,
+			errMsg:  "",
+			missing: true,
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+			missing: false,
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+			missing: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveThrottleGroup(&testThrottleGroup)
+
+			request := &DeleteThrottleGroupRequest{Name: tt.in, AllowMissing: tt.missing}
+			response, err := testEnv.opiSpdkServer.DeleteThrottleGroup(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_UpdateThrottleGroup(t *testing.T) {
+	tests := map[string]struct {
+		in      *UpdateThrottleGroupRequest
+		out     *ThrottleGroup
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			in:      &UpdateThrottleGroupRequest{Name: testThrottleGroupName, VolumeNameRefs: []string{"volume-42"}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Could not modify QoS Group: %v", testThrottleGroupID),
+		},
+		"valid request with empty SPDK response": {
+			in:      &UpdateThrottleGroupRequest{Name: testThrottleGroupName, VolumeNameRefs: []string{"volume-42"}},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_modify: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			in:      &UpdateThrottleGroupRequest{Name: testThrottleGroupName, VolumeNameRefs: []string{"volume-42"}},
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_modify: %v", "json response error: myopierr"),
+		},
+		"add volume": {
+			in: &UpdateThrottleGroupRequest{Name: testThrottleGroupName, VolumeNameRefs: []string{"volume-42", "volume-43", "volume-44"}},
+			out: &ThrottleGroup{
+				Name:           testThrottleGroupName,
+				VolumeNameRefs: []string{"volume-42", "volume-43", "volume-44"},
+				Limits:         &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"change limits": {
+			in: &UpdateThrottleGroupRequest{Name: testThrottleGroupName, Limits: &ThrottleGroupLimits{RwIopsKiops: 200}},
+			out: &ThrottleGroup{
+				Name:           testThrottleGroupName,
+				VolumeNameRefs: []string{"volume-42", "volume-43"},
+				Limits:         &ThrottleGroupLimits{RwIopsKiops: 200},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no limit set": {
+			in:      &UpdateThrottleGroupRequest{Name: testThrottleGroupName, Limits: &ThrottleGroupLimits{}},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Limits have to set at least one of RwIopsKiops, RdBandwidthMbs, WrBandwidthMbs or RwBandwidthMbs",
+		},
+		"valid request with unknown key": {
+			in:      &UpdateThrottleGroupRequest{Name: resourceIDToThrottleGroupName("unknown-id")},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToThrottleGroupName("unknown-id")),
+		},
+		"malformed name": {
+			in:      &UpdateThrottleGroupRequest{Name: "-ABC-DEF"},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      &UpdateThrottleGroupRequest{},
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveThrottleGroup(&testThrottleGroup)
+
+			response, err := testEnv.opiSpdkServer.UpdateThrottleGroup(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_ListThrottleGroups(t *testing.T) {
+	testGroupList := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "group_list": [{"name": "tenant1", "bdevs": ["Malloc2"], "rw_ios_per_sec": 50000},{"name": "tenant0", "bdevs": ["Malloc0", "Malloc1"], "rw_ios_per_sec": 100000, "rw_mbytes_per_sec": 1000}]}}`
+	tests := map[string]struct {
+		out     []*ThrottleGroup
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+		size    int32
+		token   string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Could not list QoS Groups",
+			size:    0,
+			token:   "",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_get_list: %v", "EOF"),
+			size:    0,
+			token:   "",
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_bdev_qos_group_get_list: %v", "json response error: myopierr"),
+			size:    0,
+			token:   "",
+		},
+		"pagination negative": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "negative PageSize is not allowed",
+			size:    -10,
+			token:   "",
+		},
+		"pagination error": {
+			out:     nil,
+			spdk:    []string{},
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find pagination token %s", "unknown-pagination-token"),
+			size:    0,
+			token:   "unknown-pagination-token",
+		},
+		"pagination": {
+			out: []*ThrottleGroup{
+				{Name: resourceIDToThrottleGroupName("tenant1"), VolumeNameRefs: []string{"Malloc2"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 50}},
+			},
+			spdk:    []string{testGroupList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    1,
+			token:   "",
+		},
+		"valid request with valid SPDK response": {
+			out: []*ThrottleGroup{
+				{Name: resourceIDToThrottleGroupName("tenant0"), VolumeNameRefs: []string{"Malloc0", "Malloc1"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 100, RwBandwidthMbs: 1000}},
+				{Name: resourceIDToThrottleGroupName("tenant1"), VolumeNameRefs: []string{"Malloc2"}, Limits: &ThrottleGroupLimits{RwIopsKiops: 50}},
+			},
+			spdk:    []string{testGroupList},
+			errCode: codes.OK,
+			errMsg:  "",
+			size:    0,
+			token:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk)
+			defer testEnv.Close()
+
+			request := &ListThrottleGroupsRequest{PageSize: tt.size, PageToken: tt.token}
+			response, err := testEnv.opiSpdkServer.ListThrottleGroups(testEnv.ctx, request)
+
+			var groups []*ThrottleGroup
+			if response != nil {
+				groups = response.ThrottleGroups
+				// Empty NextPageToken indicates end of results list
+				if tt.size != 1 && response.NextPageToken != "" {
+					t.Error("Expected end of results, receieved non-empty next page token", response.NextPageToken)
+				}
+			}
+			if !reflect.DeepEqual(groups, tt.out) {
+				t.Error("response: expected", tt.out, "received", groups)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestMiddleEnd_GetThrottleGroup(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		out     *ThrottleGroup
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			in:      testThrottleGroupName,
+			out:     &testThrottleGroup,
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with unknown key": {
+			in:      resourceIDToThrottleGroupName("unknown-id"),
+			out:     nil,
+			errCode: codes.NotFound,
+			errMsg:  fmt.Sprintf("unable to find key %v", resourceIDToThrottleGroupName("unknown-id")),
+		},
+		"malformed name": {
+			in:      "-ABC-DEF",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("segment '%s': not a valid DNS name", "-ABC-DEF"),
+		},
+		"no required field": {
+			in:      "",
+			out:     nil,
+			errCode: codes.Unknown,
+			errMsg:  "missing required field: name",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{})
+			defer testEnv.Close()
+
+			_ = testEnv.opiSpdkServer.saveThrottleGroup(&testThrottleGroup)
+
+			request := &GetThrottleGroupRequest{Name: tt.in}
+			response, err := testEnv.opiSpdkServer.GetThrottleGroup(testEnv.ctx, request)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
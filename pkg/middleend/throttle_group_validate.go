@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package middleend implememnts the MiddleEnd APIs (service) of the storage Server
+package middleend
+
+import (
+	"errors"
+	"fmt"
+
+	"go.einride.tech/aip/resourceid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) validateCreateThrottleGroupRequest(in *CreateThrottleGroupRequest) error {
+	// check required fields
+	if in.ThrottleGroup == nil {
+		return errors.New("missing required field: throttle_group")
+	}
+	if len(in.ThrottleGroup.VolumeNameRefs) == 0 {
+		return errors.New("missing required field: throttle_group.volume_name_refs")
+	}
+	if in.ThrottleGroup.Limits == nil {
+		return errors.New("missing required field: throttle_group.limits")
+	}
+	// see https://google.aip.dev/133#user-specified-ids
+	if in.ThrottleGroupID != "" {
+		if err := resourceid.ValidateUserSettable(in.ThrottleGroupID); err != nil {
+			return err
+		}
+	}
+	if err := validateThrottleGroupVolumes(in.ThrottleGroup.VolumeNameRefs); err != nil {
+		return err
+	}
+	return validateThrottleGroupLimits(in.ThrottleGroup.Limits)
+}
+
+func (s *Server) validateDeleteThrottleGroupRequest(in *DeleteThrottleGroupRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+func (s *Server) validateUpdateThrottleGroupRequest(in *UpdateThrottleGroupRequest) error {
+	// check required fields
+	if err := validateResourceName(in.Name); err != nil {
+		return err
+	}
+	if err := validateThrottleGroupVolumes(in.VolumeNameRefs); err != nil {
+		return err
+	}
+	if in.Limits != nil {
+		return validateThrottleGroupLimits(in.Limits)
+	}
+	return nil
+}
+
+func (s *Server) validateGetThrottleGroupRequest(in *GetThrottleGroupRequest) error {
+	// check required fields
+	return validateResourceName(in.Name)
+}
+
+// validateThrottleGroupVolumes checks a volume is not listed twice in a group
+func validateThrottleGroupVolumes(refs []string) error {
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if seen[ref] {
+			msg := fmt.Sprintf("Volume %s is used more than once", ref)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		seen[ref] = true
+	}
+	return nil
+}
+
+// validateThrottleGroupLimits checks the limits are not negative and at least one is set,
+// a group without limits would only cost a lookup on every I/O
+func validateThrottleGroupLimits(limits *ThrottleGroupLimits) error {
+	values := []struct {
+		field string
+		value int64
+	}{
+		{"RwIopsKiops", limits.RwIopsKiops},
+		{"RdBandwidthMbs", limits.RdBandwidthMbs},
+		{"WrBandwidthMbs", limits.WrBandwidthMbs},
+		{"RwBandwidthMbs", limits.RwBandwidthMbs},
+	}
+	set := false
+	for _, v := range values {
+		if v.value < 0 {
+			msg := fmt.Sprintf("%s value (%d) can't be negative", v.field, v.value)
+			return status.Errorf(codes.InvalidArgument, msg)
+		}
+		set = set || v.value != 0
+	}
+	if !set {
+		msg := "Limits have to set at least one of RwIopsKiops, RdBandwidthMbs, WrBandwidthMbs or RwBandwidthMbs"
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
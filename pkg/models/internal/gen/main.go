@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// main is the main package of the generator of the structs of the Marvell Nvme JSON-RPC methods
+// of package models from their schema, run by go generate in pkg/models
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models/internal/schema"
+)
+
+func main() {
+	var schemaFile string
+	flag.StringVar(&schemaFile, "schema", "mrvl_nvm.yaml", "schema of the Marvell Nvme JSON-RPC methods")
+
+	var outFile string
+	flag.StringVar(&outFile, "out", "mrvl_nvm_gen.go", "Go file generated from the schema")
+
+	flag.Parse()
+
+	if err := generate(schemaFile, outFile); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func generate(schemaFile string, outFile string) error {
+	s, err := schema.Load(schemaFile)
+	if err != nil {
+		return err
+	}
+	source, err := s.Generate(filepath.Base(schemaFile))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outFile, source, 0o644)
+}
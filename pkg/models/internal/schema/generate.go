@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package schema defines the Marvell Nvme JSON-RPC methods of the firmware, and generates the Go
+// structs of their parameters and results from it, so pkg/models follows the firmware instead of
+// being written by hand
+package schema
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// commentWidth is the column the doc comments are wrapped at
+const commentWidth = 100
+
+// Generate returns the Go source of package models for the schema read from source: the structs
+// of the parameters and of the results of the methods, and the MrvlNvmMethods table decoding them
+func (s *Schema) Generate(source string) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("// SPDX-License-Identifier: Apache-2.0\n")
+	b.WriteString("// Copyright (C) 2024 Marvell International Ltd.\n\n")
+	fmt.Fprintf(&b, "// Code generated by go run ./internal/gen from %s. DO NOT EDIT.\n\n", source)
+	b.WriteString("package models\n\n")
+	writeComment(&b, "", "RPCSchemaVersion is the version of SPDK the Marvell JSON-RPC methods of the models are defined for")
+	fmt.Fprintf(&b, "const RPCSchemaVersion = %q\n", s.Version)
+	for _, m := range s.Methods {
+		writeMessage(&b, m.Type+"Params", m.Params, "represents the parameters to a "+m.Method+" request")
+		writeMessage(&b, m.Type+"Result", m.Result, "represents a "+m.Method+" result")
+	}
+	b.WriteString("\n")
+	writeComment(&b, "", "MrvlNvmMethods are the Marvell Nvme JSON-RPC methods of the schema by name, with the structs of their parameters and results")
+	b.WriteString("var MrvlNvmMethods = map[string]MrvlNvmMethod{\n")
+	for _, m := range s.Methods {
+		fmt.Fprintf(&b, "%q: {\n", m.Method)
+		if m.Params != nil {
+			fmt.Fprintf(&b, "NewParams: func() interface{} { return &%sParams{} },\n", m.Type)
+		}
+		if m.Result != nil {
+			fmt.Fprintf(&b, "NewResult: func() interface{} { return &%sResult{} },\n", m.Type)
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+	return format.Source([]byte(b.String()))
+}
+
+// writeMessage writes the struct of a message, nothing for the messages the method doesn't have
+func writeMessage(b *strings.Builder, name string, msg *Message, doc string) {
+	if msg == nil {
+		return
+	}
+	if msg.Doc != "" {
+		doc = msg.Doc
+	}
+	b.WriteString("\n")
+	writeComment(b, "", name+" "+doc)
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	writeFields(b, msg.Fields, "\t")
+	b.WriteString("}\n")
+}
+
+func writeFields(b *strings.Builder, fields []*Field, indent string) {
+	for _, f := range fields {
+		if f.Doc != "" {
+			writeComment(b, indent, f.Doc)
+		}
+		fmt.Fprintf(b, "%s%s ", indent, f.GoName())
+		switch f.Type {
+		case "object", "[]object":
+			fmt.Fprintf(b, "%sstruct {\n", strings.TrimSuffix(f.Type, "object"))
+			writeFields(b, f.Fields, indent+"\t")
+			fmt.Fprintf(b, "%s}", indent)
+		default:
+			b.WriteString(f.GoType())
+		}
+		tag := f.Name
+		if f.OmitEmpty {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(b, " `json:\"%s\"`\n", tag)
+	}
+}
+
+// writeComment writes text as line comments wrapped at commentWidth
+func writeComment(b *strings.Builder, indent string, text string) {
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(indent)+len("// ")+len(line)+1+len(word) > commentWidth {
+			fmt.Fprintf(b, "%s// %s\n", indent, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	fmt.Fprintf(b, "%s// %s\n", indent, line)
+}
@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package schema defines the Marvell Nvme JSON-RPC methods of the firmware, and generates the Go
+// structs of their parameters and results from it, so pkg/models follows the firmware instead of
+// being written by hand
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is the schema of the Marvell Nvme JSON-RPC methods of a firmware release
+type Schema struct {
+	// Version of SPDK the methods are defined for
+	Version string    `yaml:"version"`
+	Methods []*Method `yaml:"methods"`
+}
+
+// Method is a JSON-RPC method, without parameters or result when the firmware takes or returns
+// none
+type Method struct {
+	// Method is the JSON-RPC method name, i.e. mrvl_nvm_create_subsystem
+	Method string `yaml:"method"`
+	// Type prefixes the names of the Params and Result structs
+	Type   string   `yaml:"type"`
+	Params *Message `yaml:"params"`
+	Result *Message `yaml:"result"`
+}
+
+// Message is the JSON object of the parameters or of the result of a method
+type Message struct {
+	// Doc completes the doc comment of the struct, after its name
+	Doc    string   `yaml:"doc"`
+	Fields []*Field `yaml:"fields"`
+}
+
+// Field is a member of a JSON object
+type Field struct {
+	// Name is the JSON name of the field
+	Name string `yaml:"name"`
+	// Type is a Go scalar type, status, []byte, a slice of a scalar type, object or []object
+	Type string `yaml:"type"`
+	// Go is the Go name of the field, derived from its JSON name when empty
+	Go        string `yaml:"go"`
+	OmitEmpty bool   `yaml:"omitempty"`
+	// Doc is written as a comment before the field
+	Doc string `yaml:"doc"`
+	// Fields are the members of an object or of the objects of an []object
+	Fields []*Field `yaml:"fields"`
+}
+
+// scalars are the Go types of the scalar types the fields can have, alone or in a slice
+var scalars = map[string]string{
+	"bool":    "bool",
+	"int":     "int",
+	"int64":   "int64",
+	"uint32":  "uint32",
+	"uint64":  "uint64",
+	"float64": "float64",
+	"string":  "string",
+	// the status the methods report their failures in
+	"status": "Status",
+}
+
+var (
+	methodPattern = regexp.MustCompile(`^mrvl_nvm_[a-z0-9_]+$`)
+	typePattern   = regexp.MustCompile(`^MrvlNvm[A-Za-z0-9]+$`)
+	goPattern     = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+)
+
+// initialisms are the words of the JSON names written in upper case in the Go names
+var initialisms = map[string]string{
+	"id":   "ID",
+	"uuid": "UUID",
+}
+
+// Load reads and checks the schema in file
+func Load(file string) (*Schema, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %v", file, err)
+	}
+	return schema, nil
+}
+
+// Parse decodes and checks a schema, the unknown keys are refused so typos don't go unnoticed
+func Parse(data []byte) (*Schema, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	schema := new(Schema)
+	if err := decoder.Decode(schema); err != nil {
+		return nil, err
+	}
+	if err := schema.check(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func (s *Schema) check() error {
+	if s.Version == "" {
+		return fmt.Errorf("missing version")
+	}
+	methods := make(map[string]bool)
+	types := make(map[string]bool)
+	for _, m := range s.Methods {
+		if !methodPattern.MatchString(m.Method) {
+			return fmt.Errorf("invalid method name %q, have to be mrvl_nvm_ in snake case", m.Method)
+		}
+		if !typePattern.MatchString(m.Type) {
+			return fmt.Errorf("%s: invalid type %q, have to start with MrvlNvm", m.Method, m.Type)
+		}
+		if methods[m.Method] {
+			return fmt.Errorf("%s: duplicate method", m.Method)
+		}
+		if types[m.Type] {
+			return fmt.Errorf("%s: duplicate type %s", m.Method, m.Type)
+		}
+		methods[m.Method], types[m.Type] = true, true
+		for _, msg := range []*Message{m.Params, m.Result} {
+			if msg == nil {
+				continue
+			}
+			if err := checkFields(m.Method, msg.Fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkFields(path string, fields []*Field) error {
+	names := make(map[string]bool)
+	goNames := make(map[string]bool)
+	for _, f := range fields {
+		if f.Name == "" {
+			return fmt.Errorf("%s: missing field name", path)
+		}
+		path := path + "." + f.Name
+		goName := f.GoName()
+		if !goPattern.MatchString(goName) {
+			return fmt.Errorf("%s: invalid Go name %q", path, goName)
+		}
+		if names[f.Name] || goNames[goName] {
+			return fmt.Errorf("%s: duplicate field", path)
+		}
+		names[f.Name], goNames[goName] = true, true
+		switch {
+		case f.Type == "object" || f.Type == "[]object":
+			if len(f.Fields) == 0 {
+				return fmt.Errorf("%s: missing fields of %s", path, f.Type)
+			}
+			if err := checkFields(path, f.Fields); err != nil {
+				return err
+			}
+		case f.GoType() != "":
+			if len(f.Fields) != 0 {
+				return fmt.Errorf("%s: fields of %s, only the objects have fields", path, f.Type)
+			}
+		default:
+			return fmt.Errorf("%s: invalid type %q", path, f.Type)
+		}
+	}
+	return nil
+}
+
+// GoType returns the Go type of a field but an object or []object, empty when the type is
+// invalid
+func (f *Field) GoType() string {
+	if f.Type == "[]byte" {
+		return f.Type
+	}
+	if elem, ok := strings.CutPrefix(f.Type, "[]"); ok {
+		if scalar, ok := scalars[elem]; ok {
+			return "[]" + scalar
+		}
+		return ""
+	}
+	return scalars[f.Type]
+}
+
+// GoName returns the Go name of the field, its JSON name in camel case unless overridden, i.e.
+// ctrlr_id is CtrlrID
+func (f *Field) GoName() string {
+	if f.Go != "" {
+		return f.Go
+	}
+	var name strings.Builder
+	for _, word := range strings.Split(f.Name, "_") {
+		if word == "" {
+			continue
+		}
+		if initialism, ok := initialisms[word]; ok {
+			name.WriteString(initialism)
+			continue
+		}
+		name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return name.String()
+}
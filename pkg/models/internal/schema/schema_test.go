@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package schema defines the Marvell Nvme JSON-RPC methods of the firmware, and generates the Go
+// structs of their parameters and results from it, so pkg/models follows the firmware instead of
+// being written by hand
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchema_Parse(t *testing.T) {
+	tests := map[string]struct {
+		in     string
+		errMsg string
+	}{
+		"valid": {
+			in: `
+version: v21.01
+methods:
+  - method: mrvl_nvm_get_subsys_list
+    type: MrvlNvmGetSubsysList
+    result:
+      fields:
+        - {name: status, type: int}
+        - name: subsys_list
+          type: "[]object"
+          fields:
+            - {name: subnqn, type: string}
+`,
+		},
+		"missing version": {
+			in:     "methods: []",
+			errMsg: "missing version",
+		},
+		"unknown key": {
+			in:     "version: v21.01\nmethods:\n  - method: mrvl_nvm_de_init\n    type: MrvlNvmDeInit\n    reply: {}\n",
+			errMsg: "field reply not found in type schema.Method",
+		},
+		"not a Marvell Nvme method": {
+			in:     "version: v21.01\nmethods:\n  - {method: bdev_get_bdevs, type: MrvlNvmGetBdevs}\n",
+			errMsg: `invalid method name "bdev_get_bdevs", have to be mrvl_nvm_ in snake case`,
+		},
+		"duplicate method": {
+			in:     "version: v21.01\nmethods:\n  - {method: mrvl_nvm_de_init, type: MrvlNvmDeInit}\n  - {method: mrvl_nvm_de_init, type: MrvlNvmDeInit2}\n",
+			errMsg: "mrvl_nvm_de_init: duplicate method",
+		},
+		"invalid type": {
+			in:     "version: v21.01\nmethods:\n  - method: mrvl_nvm_de_init\n    type: MrvlNvmDeInit\n    result:\n      fields: [{name: status, type: int32}]\n",
+			errMsg: `mrvl_nvm_de_init.status: invalid type "int32"`,
+		},
+		"object without fields": {
+			in:     "version: v21.01\nmethods:\n  - method: mrvl_nvm_de_init\n    type: MrvlNvmDeInit\n    result:\n      fields: [{name: list, type: \"[]object\"}]\n",
+			errMsg: "mrvl_nvm_de_init.list: missing fields of []object",
+		},
+		"duplicate Go name": {
+			in:     "version: v21.01\nmethods:\n  - method: mrvl_nvm_de_init\n    type: MrvlNvmDeInit\n    result:\n      fields: [{name: status, type: int}, {name: rc, type: int, go: Status}]\n",
+			errMsg: "mrvl_nvm_de_init.rc: duplicate field",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.in))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Error("expected no error, received", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Error("error: expected", tt.errMsg, "received", err)
+			}
+		})
+	}
+}
+
+func TestSchema_GoName(t *testing.T) {
+	for _, f := range []*Field{
+		{Name: "ctrlr_id", Go: "CtrlrID"},
+		{Name: "ns_uuid", Go: "NsUUID"},
+		{Name: "Stats_time_window_in_us", Go: "StatsTimeWindowInUs"},
+	} {
+		expected := f.Go
+		f.Go = ""
+		if received := f.GoName(); received != expected {
+			t.Error("Go name of", f.Name, "expected", expected, "received", received)
+		}
+	}
+}
+
+func TestSchema_Generate(t *testing.T) {
+	s, err := Parse([]byte(`
+version: v21.01
+methods:
+  - method: mrvl_nvm_subsys_reset
+    type: MrvlNvmSubsysReset
+    params:
+      fields:
+        - {name: subnqn, type: string, go: SubNqn, doc: NQN of the subsystem}
+    result:
+      doc: represents a Marvell subsystem reset result
+      fields:
+        - {name: status, type: int, omitempty: true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := s.Generate("test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"// Code generated by go run ./internal/gen from test.yaml. DO NOT EDIT.",
+		"const RPCSchemaVersion = \"v21.01\"",
+		"// MrvlNvmSubsysResetParams represents the parameters to a mrvl_nvm_subsys_reset request\ntype MrvlNvmSubsysResetParams struct {\n\t// NQN of the subsystem\n\tSubNqn string `json:\"subnqn\"`\n}",
+		"// MrvlNvmSubsysResetResult represents a Marvell subsystem reset result",
+		"Status int `json:\"status,omitempty\"`",
+		"\"mrvl_nvm_subsys_reset\": {\n\t\tNewParams: func() interface{} { return &MrvlNvmSubsysResetParams{} },",
+	} {
+		if !strings.Contains(string(source), expected) {
+			t.Error("expected the source to contain", expected, "received", string(source))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package models holds definitions for SPDK json RPC structs
+package models
+
+//go:generate go run ./internal/gen -schema mrvl_nvm.yaml -out mrvl_nvm_gen.go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MrvlNvmMethod creates the structs of the parameters and of the result of a Marvell Nvme
+// JSON-RPC method, NewParams or NewResult is nil when the method has no parameters or no result
+type MrvlNvmMethod struct {
+	NewParams func() interface{}
+	NewResult func() interface{}
+}
+
+// DecodeMrvlNvmParams decodes the parameters of a Marvell Nvme JSON-RPC method, refusing the
+// fields the schema doesn't define
+func DecodeMrvlNvmParams(method string, data []byte) (interface{}, error) {
+	m, ok := MrvlNvmMethods[method]
+	if !ok {
+		return nil, fmt.Errorf("unknown method %s", method)
+	}
+	if m.NewParams == nil {
+		return nil, fmt.Errorf("%s: the method takes no parameters", method)
+	}
+	return decodeStrict(method, data, m.NewParams())
+}
+
+// DecodeMrvlNvmResult decodes the result of a Marvell Nvme JSON-RPC method, refusing the fields
+// the schema doesn't define, so the firmware drifting from the schema is noticed
+func DecodeMrvlNvmResult(method string, data []byte) (interface{}, error) {
+	m, ok := MrvlNvmMethods[method]
+	if !ok {
+		return nil, fmt.Errorf("unknown method %s", method)
+	}
+	if m.NewResult == nil {
+		return nil, fmt.Errorf("%s: the method returns no result", method)
+	}
+	return decodeStrict(method, data, m.NewResult())
+}
+
+func decodeStrict(method string, data []byte, v interface{}) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return nil, fmt.Errorf("%s: %v", method, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,1046 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Code generated by go run ./internal/gen from mrvl_nvm.yaml. DO NOT EDIT.
+
+package models
+
+// RPCSchemaVersion is the version of SPDK the Marvell JSON-RPC methods of the models are defined
+// for
+const RPCSchemaVersion = "v21.01"
+
+// MrvlNvmGetSubsysListResult represents a Marvell subsystem list result
+type MrvlNvmGetSubsysListResult struct {
+	Status     Status `json:"status"`
+	SubsysList []struct {
+		Subnqn string `json:"subnqn"`
+	} `json:"subsys_list"`
+}
+
+// MrvlNvmCreateSubsystemParams represents the parameters to a Marvell create subsystem request
+type MrvlNvmCreateSubsystemParams struct {
+	Subnqn        string `json:"subnqn"`
+	Mn            string `json:"mn"`
+	Sn            string `json:"sn"`
+	MaxNamespaces int    `json:"max_namespaces"`
+	MinCtrlrID    int    `json:"min_ctrlr_id"`
+	MaxCtrlrID    int    `json:"max_ctrlr_id"`
+	MinSqes       int    `json:"min_sqes,omitempty"`
+	MaxSqes       int    `json:"max_sqes,omitempty"`
+	MinCqes       int    `json:"min_cqes,omitempty"`
+	MaxCqes       int    `json:"max_cqes,omitempty"`
+	IeeeOui       string `json:"ieee_oui,omitempty"`
+}
+
+// MrvlNvmCreateSubsystemResult represents a Marvell create subsystem result
+type MrvlNvmCreateSubsystemResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmDeleteSubsystemParams represents the parameters to a Marvell delete subsystem request
+type MrvlNvmDeleteSubsystemParams struct {
+	Subnqn string `json:"subnqn"`
+}
+
+// MrvlNvmDeleteSubsystemResult represents a Marvell delete subsystem result
+type MrvlNvmDeleteSubsystemResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmDeInitResult represents a Marvell de-init result
+type MrvlNvmDeInitResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmGetSubsysInfoParams represents the parameters to a Marvell get subsystem info request
+type MrvlNvmGetSubsysInfoParams struct {
+	Subnqn string `json:"subnqn"`
+}
+
+// MrvlNvmGetSubsysInfoResult represents a Marvell get subsystem info result
+type MrvlNvmGetSubsysInfoResult struct {
+	Status     Status `json:"status"`
+	SubsysList []struct {
+		Subnqn         string `json:"subnqn"`
+		Mn             string `json:"mn"`
+		Sn             string `json:"sn"`
+		MaxNamespaces  int    `json:"max_namespaces"`
+		MinCtrlrID     int    `json:"min_ctrlr_id"`
+		MaxCtrlrID     int    `json:"max_ctrlr_id"`
+		MinSqes        int    `json:"min_sqes"`
+		MaxSqes        int    `json:"max_sqes"`
+		MinCqes        int    `json:"min_cqes"`
+		MaxCqes        int    `json:"max_cqes"`
+		IeeeOui        string `json:"ieee_oui"`
+		NumNs          int    `json:"num_ns"`
+		NumTotalCtrlr  int    `json:"num_total_ctrlr"`
+		NumActiveCtrlr int    `json:"num_active_ctrlr"`
+		NsList         []struct {
+			NsInstanceID int    `json:"ns_instance_id"`
+			Bdev         string `json:"bdev"`
+			CtrlrIDList  []struct {
+				CtrlrID int `json:"ctrlr_id"`
+			} `json:"ctrlr_id_list"`
+		} `json:"ns_list"`
+	} `json:"subsys_list"`
+}
+
+// MrvlNvmSubsysAllocNsParams represents the parameters to a Marvell get subsystem allocate
+// namespace request
+type MrvlNvmSubsysAllocNsParams struct {
+	Subnqn        string `json:"subnqn"`
+	Nguid         string `json:"nguid"`
+	Eui64         string `json:"eui64"`
+	UUID          string `json:"uuid"`
+	ShareEnable   int    `json:"share_enable"`
+	Bdev          string `json:"bdev"`
+	ThinProvision int    `json:"thin_provision"`
+}
+
+// MrvlNvmSubsysAllocNsResult represents a Marvell get subsystem alloc namespace result
+type MrvlNvmSubsysAllocNsResult struct {
+	Status       Status `json:"status"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmSubsysUnallocNsParams represents the parameters to a Marvell get subsystem unallocate
+// namespace request
+type MrvlNvmSubsysUnallocNsParams struct {
+	Subnqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmSubsysUnallocNsResult represents a Marvell get subsystem unalloc namespace result
+type MrvlNvmSubsysUnallocNsResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmSubsysGetNsListParams represents the parameters to a Marvell get subsystem namespace list
+// request
+type MrvlNvmSubsysGetNsListParams struct {
+	Subnqn string `json:"subnqn"`
+}
+
+// MrvlNvmSubsysGetNsListResult represents a Marvell get subsystem namespace list result
+type MrvlNvmSubsysGetNsListResult struct {
+	Status Status `json:"status"`
+	NsList []struct {
+		NsInstanceID int    `json:"ns_instance_id"`
+		Bdev         string `json:"bdev"`
+		CtrlrIDList  []struct {
+			CtrlrID int `json:"ctrlr_id"`
+		} `json:"ctrlr_id_list"`
+	} `json:"ns_list"`
+}
+
+// MrvlNvmSubsysCreateCtrlrParams represents the parameters to a Marvell create subsystem controller
+// request
+type MrvlNvmSubsysCreateCtrlrParams struct {
+	Subnqn       string `json:"subnqn"`
+	PcieDomainID int    `json:"pcie_domain_id"`
+	PfID         int    `json:"pf_id"`
+	VfID         int    `json:"vf_id"`
+	CtrlrID      int    `json:"ctrlr_id"`
+	MaxNsq       int    `json:"max_nsq"`
+	MaxNcq       int    `json:"max_ncq"`
+	Mqes         int    `json:"mqes"`
+	// Marvell specific options, see frontend.NvmeControllerOptions
+	ShadowDoorbell int `json:"shadow_doorbell,omitempty"`
+	CmbSizeMib     int `json:"cmb_size_mib,omitempty"`
+	PmrSizeMib     int `json:"pmr_size_mib,omitempty"`
+	MsixVectors    int `json:"msix_vectors,omitempty"`
+	AggrThreshold  int `json:"aggr_threshold,omitempty"`
+	AggrTime       int `json:"aggr_time,omitempty"`
+	AmsWrr         int `json:"ams_wrr,omitempty"`
+	ArbBurst       int `json:"arb_burst,omitempty"`
+	ArbHpw         int `json:"arb_hpw,omitempty"`
+	ArbMpw         int `json:"arb_mpw,omitempty"`
+	ArbLpw         int `json:"arb_lpw,omitempty"`
+	BootNsid       int `json:"boot_nsid,omitempty"`
+	KatoMs         int `json:"kato_ms,omitempty"`
+}
+
+// MrvlNvmSubsysCreateCtrlrResult represents a Marvell create subsystem controller result
+type MrvlNvmSubsysCreateCtrlrResult struct {
+	Status  Status `json:"status"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmSubsysUpdateCtrlrParams represents the parameters to a Marvell update subsystem controller
+// request
+type MrvlNvmSubsysUpdateCtrlrParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+	MaxNsq  int    `json:"max_nsq"`
+	MaxNcq  int    `json:"max_ncq"`
+}
+
+// MrvlNvmSubsysUpdateCtrlrResult represents a Marvell update subsystem controller result
+type MrvlNvmSubsysUpdateCtrlrResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmSubsysRemoveCtrlrParams represents the parameters to a Marvell remove subsystem controller
+// request
+type MrvlNvmSubsysRemoveCtrlrParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+	Force   int    `json:"force"`
+}
+
+// MrvlNvmSubsysRemoveCtrlrResult represents a Marvell remove subsystem controller result
+type MrvlNvmSubsysRemoveCtrlrResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmSubsysGetCtrlrListParams represents the parameters to a Marvell get subsystem controller
+// list request
+type MrvlNvmSubsysGetCtrlrListParams struct {
+	Subnqn string `json:"subnqn"`
+}
+
+// MrvlNvmSubsysGetCtrlrListResult represents a Marvell get subsystem controller list result
+type MrvlNvmSubsysGetCtrlrListResult struct {
+	Status      Status `json:"status"`
+	CtrlrIDList []struct {
+		CtrlrID int `json:"ctrlr_id"`
+	} `json:"ctrlr_id_list"`
+}
+
+// MrvlNvmGetNsStatsParams represents the parameters to a Marvell get namespace status request
+type MrvlNvmGetNsStatsParams struct {
+	SubNqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmGetNsStatsResult represents a Marvell get namespace status result
+type MrvlNvmGetNsStatsResult struct {
+	Status                Status `json:"status"`
+	NumReadCmds           int    `json:"num_read_cmds"`
+	NumReadBytes          int    `json:"num_read_bytes"`
+	NumWriteCmds          int    `json:"num_write_cmds"`
+	NumWriteBytes         int    `json:"num_write_bytes"`
+	NumErrors             int    `json:"num_errors"`
+	TotalReadLatencyInUs  int    `json:"total_read_latency_in_us"`
+	TotalWriteLatencyInUs int    `json:"total_write_latency_in_us"`
+	StatsTimeWindowInUs   int    `json:"Stats_time_window_in_us"`
+}
+
+// MrvlNvmNsGetCtrlrListParams represents the parameters to a Marvell get namespace controller list
+// request
+type MrvlNvmNsGetCtrlrListParams struct {
+	SubNqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmNsGetCtrlrListResult represents a Marvell get namespace controller list result
+type MrvlNvmNsGetCtrlrListResult struct {
+	Status      Status `json:"status"`
+	CtrlrIDList []struct {
+		CtrlrID int `json:"ctrlr_id"`
+	} `json:"ctrlr_id_list"`
+}
+
+// MrvlNvmGetNsInfoParams represents the parameters to a Marvell get namespace info request
+type MrvlNvmGetNsInfoParams struct {
+	SubNqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmGetNsInfoResult represents the a Marvell get namespace info result
+type MrvlNvmGetNsInfoResult struct {
+	Status      Status `json:"status"`
+	Nguid       string `json:"nguid"`
+	Eui64       string `json:"eui64"`
+	UUID        string `json:"uuid"`
+	Nmic        int    `json:"nmic"`
+	Bdev        string `json:"bdev"`
+	NumCtrlrs   int    `json:"num_ctrlrs"`
+	CtrlrIDList []struct {
+		CtrlrID int `json:"ctrlr_id"`
+	} `json:"ctrlr_id_list"`
+}
+
+// MrvlNvmCtrlrAttachNsParams represents the parameters to a Marvell controller attach namespace
+// request
+type MrvlNvmCtrlrAttachNsParams struct {
+	Subnqn       string `json:"subnqn"`
+	CtrlrID      int    `json:"ctrlr_id"`
+	NsInstanceID int    `json:"ns_instance_id"`
+	NsChangeAen  int    `json:"ns_change_aen"`
+}
+
+// MrvlNvmCtrlrAttachNsResult represents a Marvell controller attach namespace result
+type MrvlNvmCtrlrAttachNsResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmCtrlrDetachNsParams represents the parameters to a Marvell controller detach namespace
+// request
+type MrvlNvmCtrlrDetachNsParams struct {
+	Subnqn       string `json:"subnqn"`
+	CtrlrID      int    `json:"ctrlr_id"`
+	NsInstanceID int    `json:"ns_instance_id"`
+	NsChangeAen  int    `json:"ns_change_aen"`
+}
+
+// MrvlNvmCtrlrDetachNsResult represents a Marvell controller detach namespace result
+type MrvlNvmCtrlrDetachNsResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmGetCtrlrInfoParams represents the parameters to a Marvell get controller info request
+type MrvlNvmGetCtrlrInfoParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmGetCtrlrInfoResult represents a Marvell get controller info result
+type MrvlNvmGetCtrlrInfoResult struct {
+	Status        Status `json:"status"`
+	PcieDomainID  int    `json:"pcie_domain_id"`
+	PfID          int    `json:"pf_id"`
+	VfID          int    `json:"vf_id"`
+	CtrlrID       int    `json:"ctrlr_id"`
+	MaxNsq        int    `json:"max_nsq"`
+	MaxNcq        int    `json:"max_ncq"`
+	Mqes          int    `json:"mqes"`
+	IeeeOui       string `json:"ieee_oui"`
+	Cmic          int    `json:"cmic"`
+	Nn            int    `json:"nn"`
+	ActiveNsCount int    `json:"active_ns_count"`
+	ActiveNsq     int    `json:"active_nsq"`
+	ActiveNcq     int    `json:"active_ncq"`
+	Mdts          int    `json:"mdts"`
+	Sqes          int    `json:"sqes"`
+	Cqes          int    `json:"cqes"`
+	CmbSizeMib    int    `json:"cmb_size_mib"`
+	PmrSizeMib    int    `json:"pmr_size_mib"`
+	MsixVectors   int    `json:"msix_vectors"`
+	AggrThreshold int    `json:"aggr_threshold"`
+	AggrTime      int    `json:"aggr_time"`
+	AmsWrr        int    `json:"ams_wrr"`
+	ArbBurst      int    `json:"arb_burst"`
+	ArbHpw        int    `json:"arb_hpw"`
+	ArbMpw        int    `json:"arb_mpw"`
+	ArbLpw        int    `json:"arb_lpw"`
+	BootNsid      int    `json:"boot_nsid"`
+	KatoMs        int    `json:"kato_ms"`
+}
+
+// MrvlNvmGetCtrlrStatsParams represents the parameters to a Marvell get controller status request
+type MrvlNvmGetCtrlrStatsParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmGetCtrlrStatsResult represents a Marvell get controller status result
+type MrvlNvmGetCtrlrStatsResult struct {
+	Status                Status `json:"status"`
+	NumAdminCmds          int    `json:"num_admin_cmds"`
+	NumAdminCmdErrors     int    `json:"num_admin_cmd_errors"`
+	NumAsyncEvents        int    `json:"num_async_events"`
+	NumReadCmds           int    `json:"num_read_cmds"`
+	NumReadBytes          int    `json:"num_read_bytes"`
+	NumWriteCmds          int    `json:"num_write_cmds"`
+	NumWriteBytes         int    `json:"num_write_bytes"`
+	NumErrors             int    `json:"num_errors"`
+	TotalReadLatencyInUs  int    `json:"total_read_latency_in_us"`
+	TotalWriteLatencyInUs int    `json:"total_write_latency_in_us"`
+	StatsTimeWindowInUs   int    `json:"Stats_time_window_in_us"`
+}
+
+// MrvlNvmGetBulkStatsResult represents a Marvell get bulk stats result, the stats of all the
+// controllers and namespaces of all the subsystems
+type MrvlNvmGetBulkStatsResult struct {
+	Status     Status `json:"status"`
+	CtrlrStats []struct {
+		Subnqn                string `json:"subnqn"`
+		CtrlrID               int    `json:"ctrlr_id"`
+		NumReadCmds           int    `json:"num_read_cmds"`
+		NumReadBytes          int    `json:"num_read_bytes"`
+		NumWriteCmds          int    `json:"num_write_cmds"`
+		NumWriteBytes         int    `json:"num_write_bytes"`
+		TotalReadLatencyInUs  int    `json:"total_read_latency_in_us"`
+		TotalWriteLatencyInUs int    `json:"total_write_latency_in_us"`
+	} `json:"ctrlr_stats"`
+	NsStats []struct {
+		Subnqn                string `json:"subnqn"`
+		NsInstanceID          int    `json:"ns_instance_id"`
+		NumReadCmds           int    `json:"num_read_cmds"`
+		NumReadBytes          int    `json:"num_read_bytes"`
+		NumWriteCmds          int    `json:"num_write_cmds"`
+		NumWriteBytes         int    `json:"num_write_bytes"`
+		TotalReadLatencyInUs  int    `json:"total_read_latency_in_us"`
+		TotalWriteLatencyInUs int    `json:"total_write_latency_in_us"`
+	} `json:"ns_stats"`
+}
+
+// MrvlNvmCtrlrGetNsStatsParams represents the parameters to a Marvell get namespace status request
+type MrvlNvmCtrlrGetNsStatsParams struct {
+	Subnqn       string `json:"subnqn"`
+	CtrlrID      int    `json:"ctrlr_id"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmCtrlrGetNsStatsResult represents a Marvell get namespace status result
+type MrvlNvmCtrlrGetNsStatsResult struct {
+	Status                Status `json:"status"`
+	NumReadCmds           int    `json:"num_read_cmds"`
+	NumReadBytes          int    `json:"num_read_bytes"`
+	NumWriteCmds          int    `json:"num_write_cmds"`
+	NumWriteBytes         int    `json:"num_write_bytes"`
+	NumErrors             int    `json:"num_errors"`
+	TotalReadLatencyInUs  int    `json:"total_read_latency_in_us"`
+	TotalWriteLatencyInUs int    `json:"total_write_latency_in_us"`
+	StatsTimeWindowInUs   int    `json:"stats_time_window_in_us"`
+}
+
+// MrvlNvmCtrlrMiSendParams represents the parameters to a Marvell controller NVMe-MI send request
+type MrvlNvmCtrlrMiSendParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+	Opcode  int    `json:"opcode"`
+	Cdw0    uint32 `json:"cdw0"`
+	Cdw1    uint32 `json:"cdw1"`
+	Data    []byte `json:"data,omitempty"`
+}
+
+// MrvlNvmCtrlrMiSendResult represents a Marvell controller NVMe-MI send result
+type MrvlNvmCtrlrMiSendResult struct {
+	Status Status `json:"status"`
+	Nmresp int    `json:"nmresp"`
+	Data   []byte `json:"data"`
+}
+
+// MrvlNvmCtrlrSetOptionsParams represents the parameters to a Marvell controller set options
+// request
+type MrvlNvmCtrlrSetOptionsParams struct {
+	Subnqn         string `json:"subnqn"`
+	CtrlrID        int    `json:"ctrlr_id"`
+	ShadowDoorbell int    `json:"shadow_doorbell"`
+	AggrThreshold  int    `json:"aggr_threshold"`
+	AggrTime       int    `json:"aggr_time"`
+	ArbBurst       int    `json:"arb_burst"`
+	ArbHpw         int    `json:"arb_hpw"`
+	ArbMpw         int    `json:"arb_mpw"`
+	ArbLpw         int    `json:"arb_lpw"`
+	BootNsid       int    `json:"boot_nsid"`
+	KatoMs         int    `json:"kato_ms"`
+}
+
+// MrvlNvmCtrlrSetOptionsResult represents a Marvell controller set options result
+type MrvlNvmCtrlrSetOptionsResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmSubsysNotifyNsChangeParams represents the parameters to a Marvell subsystem notify
+// namespace change request
+type MrvlNvmSubsysNotifyNsChangeParams struct {
+	Subnqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmSubsysNotifyNsChangeResult represents a Marvell subsystem notify namespace change result
+type MrvlNvmSubsysNotifyNsChangeResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmNsTraceStartParams represents the parameters to a Marvell namespace trace start request
+type MrvlNvmNsTraceStartParams struct {
+	Subnqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+	SampleRate   int    `json:"sample_rate"`
+	DurationSec  int    `json:"duration_sec"`
+}
+
+// MrvlNvmNsTraceStartResult represents a Marvell namespace trace start result
+type MrvlNvmNsTraceStartResult struct {
+	Status  Status `json:"status"`
+	TraceID int    `json:"trace_id"`
+}
+
+// MrvlNvmNsTraceReadParams represents the parameters to a Marvell namespace trace read request
+type MrvlNvmNsTraceReadParams struct {
+	TraceID int `json:"trace_id"`
+}
+
+// MrvlNvmNsTraceReadResult represents a Marvell namespace trace read result
+type MrvlNvmNsTraceReadResult struct {
+	Status  Status `json:"status"`
+	Done    bool   `json:"done"`
+	Dropped uint64 `json:"dropped"`
+	Records []struct {
+		TimestampUs uint64 `json:"timestamp_us"`
+		Sqid        int    `json:"sqid"`
+		Opcode      int    `json:"opcode"`
+		Slba        uint64 `json:"slba"`
+		Nlb         uint32 `json:"nlb"`
+		SizeBytes   uint64 `json:"size_bytes"`
+		LatencyUs   uint64 `json:"latency_us"`
+		StatusCode  int    `json:"status_code"`
+	} `json:"records"`
+}
+
+// MrvlNvmNsTraceStopParams represents the parameters to a Marvell namespace trace stop request
+type MrvlNvmNsTraceStopParams struct {
+	TraceID int `json:"trace_id"`
+}
+
+// MrvlNvmNsTraceStopResult represents a Marvell namespace trace stop result
+type MrvlNvmNsTraceStopResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmGetCtrlrQueueStatsParams represents the parameters to a Marvell get controller queue stats
+// request
+type MrvlNvmGetCtrlrQueueStatsParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmGetCtrlrQueueStatsResult represents a Marvell get controller queue stats result
+type MrvlNvmGetCtrlrQueueStatsResult struct {
+	Status Status `json:"status"`
+	MaxNsq int    `json:"max_nsq"`
+	MaxNcq int    `json:"max_ncq"`
+	Sqs    []struct {
+		Sqid         int    `json:"sqid"`
+		Cqid         int    `json:"cqid"`
+		Qsize        int    `json:"qsize"`
+		Occupancy    int    `json:"occupancy"`
+		MaxOccupancy int    `json:"max_occupancy"`
+		NumCmds      uint64 `json:"num_cmds"`
+		NumStalls    uint64 `json:"num_stalls"`
+	} `json:"sqs"`
+	Cqs []struct {
+		Cqid         int    `json:"cqid"`
+		Qsize        int    `json:"qsize"`
+		Occupancy    int    `json:"occupancy"`
+		MaxOccupancy int    `json:"max_occupancy"`
+		NumCpls      uint64 `json:"num_cpls"`
+		NumStalls    uint64 `json:"num_stalls"`
+		Iv           int    `json:"iv"`
+	} `json:"cqs"`
+}
+
+// MrvlNvmGetPcieFuncStatusParams represents the parameters to a Marvell get PCIe function status
+// request
+type MrvlNvmGetPcieFuncStatusParams struct {
+	PcieDomainID int `json:"pcie_domain_id"`
+	PfID         int `json:"pf_id"`
+	VfID         int `json:"vf_id"`
+}
+
+// MrvlNvmGetPcieFuncStatusResult represents a Marvell get PCIe function status result
+type MrvlNvmGetPcieFuncStatusResult struct {
+	Status              Status  `json:"status"`
+	LinkSpeedGtps       float64 `json:"link_speed_gtps"`
+	LinkWidth           int     `json:"link_width"`
+	MaxLinkSpeedGtps    float64 `json:"max_link_speed_gtps"`
+	MaxLinkWidth        int     `json:"max_link_width"`
+	AerCorrectable      uint64  `json:"aer_correctable"`
+	AerUncorrNonFatal   uint64  `json:"aer_uncorr_nonfatal"`
+	AerUncorrFatal      uint64  `json:"aer_uncorr_fatal"`
+	AerLastUncorrStatus uint32  `json:"aer_last_uncorr_status"`
+}
+
+// MrvlNvmCtrlrPauseParams represents the parameters to a Marvell controller pause request
+type MrvlNvmCtrlrPauseParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmCtrlrPauseResult represents a Marvell controller pause result
+type MrvlNvmCtrlrPauseResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmCtrlrResumeParams represents the parameters to a Marvell controller resume request
+type MrvlNvmCtrlrResumeParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmCtrlrResumeResult represents a Marvell controller resume result
+type MrvlNvmCtrlrResumeResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmCtrlrSuspendParams represents the parameters to a Marvell controller suspend request
+type MrvlNvmCtrlrSuspendParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+	Flush   int    `json:"flush"`
+}
+
+// MrvlNvmCtrlrSuspendResult represents a Marvell controller suspend result
+type MrvlNvmCtrlrSuspendResult struct {
+	Status          Status `json:"status"`
+	OutstandingCmds int    `json:"outstanding_cmds"`
+}
+
+// MrvlNvmCtrlrGetSuspendStatusParams represents the parameters to a Marvell controller get suspend
+// status request
+type MrvlNvmCtrlrGetSuspendStatusParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmCtrlrGetSuspendStatusResult represents a Marvell controller get suspend status result
+type MrvlNvmCtrlrGetSuspendStatusResult struct {
+	Status          Status `json:"status"`
+	State           string `json:"state"`
+	OutstandingCmds int    `json:"outstanding_cmds"`
+}
+
+// MrvlNvmCtrlrSaveStateParams represents the parameters to a Marvell controller save state request
+type MrvlNvmCtrlrSaveStateParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmCtrlrSaveStateResult represents a Marvell controller save state result
+type MrvlNvmCtrlrSaveStateResult struct {
+	Status       Status `json:"status"`
+	StateVersion int    `json:"state_version"`
+	State        string `json:"state"`
+	NumSqs       int    `json:"num_sqs"`
+	NumCqs       int    `json:"num_cqs"`
+	InflightCmds int    `json:"inflight_cmds"`
+}
+
+// MrvlNvmCtrlrRestoreStateParams represents the parameters to a Marvell controller restore state
+// request
+type MrvlNvmCtrlrRestoreStateParams struct {
+	Subnqn       string `json:"subnqn"`
+	CtrlrID      int    `json:"ctrlr_id"`
+	StateVersion int    `json:"state_version"`
+	State        string `json:"state"`
+}
+
+// MrvlNvmCtrlrRestoreStateResult represents a Marvell controller restore state result
+type MrvlNvmCtrlrRestoreStateResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmCtrlrResetParams represents the parameters to a Marvell controller reset request
+type MrvlNvmCtrlrResetParams struct {
+	Subnqn  string `json:"subnqn"`
+	CtrlrID int    `json:"ctrlr_id"`
+}
+
+// MrvlNvmCtrlrResetResult represents a Marvell controller reset result
+type MrvlNvmCtrlrResetResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmSubsysResetParams represents the parameters to a Marvell subsystem reset request
+type MrvlNvmSubsysResetParams struct {
+	Subnqn string `json:"subnqn"`
+}
+
+// MrvlNvmSubsysResetResult represents a Marvell subsystem reset result
+type MrvlNvmSubsysResetResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmGetSkuCapsResult represents a Marvell get SKU capabilities result
+type MrvlNvmGetSkuCapsResult struct {
+	Status           Status `json:"status"`
+	MaxPfMsixVectors int    `json:"max_pf_msix_vectors"`
+	MaxVfMsixVectors int    `json:"max_vf_msix_vectors"`
+}
+
+// MrvlNvmFwGetSlotInfoParams represents the parameters to a Marvell get firmware slot info request
+type MrvlNvmFwGetSlotInfoParams struct {
+	Device string `json:"device"`
+}
+
+// MrvlNvmFwGetSlotInfoResult represents a Marvell get firmware slot info result
+type MrvlNvmFwGetSlotInfoResult struct {
+	Status     Status `json:"status"`
+	ActiveSlot int    `json:"active_slot"`
+	NumSlots   int    `json:"num_slots"`
+	SlotList   []struct {
+		Slot     int    `json:"slot"`
+		Revision string `json:"revision"`
+	} `json:"slot_list"`
+}
+
+// MrvlNvmFwDownloadParams represents the parameters to a Marvell firmware image download request
+type MrvlNvmFwDownloadParams struct {
+	Device string `json:"device"`
+	Offset int    `json:"offset"`
+	Data   []byte `json:"data"`
+}
+
+// MrvlNvmFwDownloadResult represents a Marvell firmware image download result
+type MrvlNvmFwDownloadResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmFwCommitParams represents the parameters to a Marvell firmware commit request
+type MrvlNvmFwCommitParams struct {
+	Device string `json:"device"`
+	Slot   int    `json:"slot"`
+	Action int    `json:"action"`
+}
+
+// MrvlNvmFwCommitResult represents a Marvell firmware commit result
+type MrvlNvmFwCommitResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmOpalGetInfoParams represents the parameters to a Marvell get Opal info request
+type MrvlNvmOpalGetInfoParams struct {
+	Device string `json:"device"`
+}
+
+// MrvlNvmOpalGetInfoResult represents a Marvell get Opal info result
+type MrvlNvmOpalGetInfoResult struct {
+	Status         Status `json:"status"`
+	Supported      bool   `json:"supported"`
+	Owned          bool   `json:"owned"`
+	LockingEnabled bool   `json:"locking_enabled"`
+	LockingRanges  []struct {
+		LockingRangeID int    `json:"locking_range_id"`
+		RangeStart     uint64 `json:"range_start"`
+		RangeLength    uint64 `json:"range_length"`
+		ReadLocked     bool   `json:"read_locked"`
+		WriteLocked    bool   `json:"write_locked"`
+	} `json:"locking_ranges"`
+}
+
+// MrvlNvmOpalTakeOwnershipParams represents the parameters to a Marvell Opal take ownership request
+type MrvlNvmOpalTakeOwnershipParams struct {
+	Device   string `json:"device"`
+	Password string `json:"password"`
+}
+
+// MrvlNvmOpalTakeOwnershipResult represents a Marvell Opal take ownership result
+type MrvlNvmOpalTakeOwnershipResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmOpalSetLockingRangeParams represents the parameters to a Marvell Opal set locking range
+// request
+type MrvlNvmOpalSetLockingRangeParams struct {
+	Device         string `json:"device"`
+	Password       string `json:"password"`
+	LockingRangeID int    `json:"locking_range_id"`
+	RangeStart     uint64 `json:"range_start"`
+	RangeLength    uint64 `json:"range_length"`
+}
+
+// MrvlNvmOpalSetLockingRangeResult represents a Marvell Opal set locking range result
+type MrvlNvmOpalSetLockingRangeResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmOpalSetLockStateParams represents the parameters to a Marvell Opal set lock state request
+type MrvlNvmOpalSetLockStateParams struct {
+	Device         string `json:"device"`
+	Password       string `json:"password"`
+	LockingRangeID int    `json:"locking_range_id"`
+	LockState      string `json:"lock_state"`
+}
+
+// MrvlNvmOpalSetLockStateResult represents a Marvell Opal set lock state result
+type MrvlNvmOpalSetLockStateResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmGetSmartLogParams represents the parameters to a Marvell get SMART log request
+type MrvlNvmGetSmartLogParams struct {
+	Device string `json:"device"`
+}
+
+// MrvlNvmGetSmartLogResult represents a Marvell get SMART log result
+type MrvlNvmGetSmartLogResult struct {
+	Status          Status `json:"status"`
+	CriticalWarning int    `json:"critical_warning"`
+	Temperature     int    `json:"temperature"`
+	AvailableSpare  int    `json:"available_spare"`
+	PercentageUsed  int    `json:"percentage_used"`
+	MediaErrors     int    `json:"media_errors"`
+}
+
+// MrvlNvmGetTelemetryLogParams represents the parameters to a Marvell get telemetry log request
+type MrvlNvmGetTelemetryLogParams struct {
+	Device string `json:"device"`
+	// reads the host-initiated log, else the controller-initiated one
+	HostInitiated bool `json:"host_initiated"`
+	// captures a new host-initiated log before it is read
+	Create bool   `json:"create,omitempty"`
+	Offset uint64 `json:"offset"`
+	Length uint32 `json:"length"`
+}
+
+// MrvlNvmGetTelemetryLogResult represents a Marvell get telemetry log result
+type MrvlNvmGetTelemetryLogResult struct {
+	Status         Status `json:"status"`
+	Available      bool   `json:"available"`
+	TotalBytes     uint64 `json:"total_bytes"`
+	DataGeneration int    `json:"data_generation"`
+	Data           []byte `json:"data"`
+}
+
+// MrvlNvmNsMigrateStartParams represents the parameters to a Marvell start namespace migration
+// request
+type MrvlNvmNsMigrateStartParams struct {
+	Subnqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+	DstBdev      string `json:"dst_bdev"`
+}
+
+// MrvlNvmNsMigrateStartResult represents a Marvell start namespace migration result
+type MrvlNvmNsMigrateStartResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmNsMigrateGetStatusParams represents the parameters to a Marvell get namespace migration
+// status request
+type MrvlNvmNsMigrateGetStatusParams struct {
+	Subnqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmNsMigrateGetStatusResult represents a Marvell get namespace migration status result
+type MrvlNvmNsMigrateGetStatusResult struct {
+	Status    Status `json:"status"`
+	State     string `json:"state"`
+	Progress  int    `json:"progress"`
+	BytesDone uint64 `json:"bytes_done"`
+	// 0 when the firmware doesn't tell
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+// MrvlNvmNsMigrateCutoverParams represents the parameters to a Marvell namespace migration cutover
+// request
+type MrvlNvmNsMigrateCutoverParams struct {
+	Subnqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmNsMigrateCutoverResult represents a Marvell namespace migration cutover result
+type MrvlNvmNsMigrateCutoverResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmNsMigrateAbortParams represents the parameters to a Marvell namespace migration abort
+// request
+type MrvlNvmNsMigrateAbortParams struct {
+	Subnqn       string `json:"subnqn"`
+	NsInstanceID int    `json:"ns_instance_id"`
+}
+
+// MrvlNvmNsMigrateAbortResult represents a Marvell namespace migration abort result
+type MrvlNvmNsMigrateAbortResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlNvmMethods are the Marvell Nvme JSON-RPC methods of the schema by name, with the structs of
+// their parameters and results
+var MrvlNvmMethods = map[string]MrvlNvmMethod{
+	"mrvl_nvm_get_subsys_list": {
+		NewResult: func() interface{} { return &MrvlNvmGetSubsysListResult{} },
+	},
+	"mrvl_nvm_create_subsystem": {
+		NewParams: func() interface{} { return &MrvlNvmCreateSubsystemParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCreateSubsystemResult{} },
+	},
+	"mrvl_nvm_delete_subsystem": {
+		NewParams: func() interface{} { return &MrvlNvmDeleteSubsystemParams{} },
+		NewResult: func() interface{} { return &MrvlNvmDeleteSubsystemResult{} },
+	},
+	"mrvl_nvm_de_init": {
+		NewResult: func() interface{} { return &MrvlNvmDeInitResult{} },
+	},
+	"mrvl_nvm_subsys_get_info": {
+		NewParams: func() interface{} { return &MrvlNvmGetSubsysInfoParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetSubsysInfoResult{} },
+	},
+	"mrvl_nvm_subsys_alloc_ns": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysAllocNsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysAllocNsResult{} },
+	},
+	"mrvl_nvm_subsys_unalloc_ns": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysUnallocNsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysUnallocNsResult{} },
+	},
+	"mrvl_nvm_subsys_get_ns_list": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysGetNsListParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysGetNsListResult{} },
+	},
+	"mrvl_nvm_subsys_create_ctrlr": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysCreateCtrlrParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysCreateCtrlrResult{} },
+	},
+	"mrvl_nvm_subsys_update_ctrlr": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysUpdateCtrlrParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysUpdateCtrlrResult{} },
+	},
+	"mrvl_nvm_subsys_remove_ctrlr": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysRemoveCtrlrParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysRemoveCtrlrResult{} },
+	},
+	"mrvl_nvm_subsys_get_ctrlr_list": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysGetCtrlrListParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysGetCtrlrListResult{} },
+	},
+	"mrvl_nvm_get_ns_stats": {
+		NewParams: func() interface{} { return &MrvlNvmGetNsStatsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetNsStatsResult{} },
+	},
+	"mrvl_nvm_ns_get_ctrlr_list": {
+		NewParams: func() interface{} { return &MrvlNvmNsGetCtrlrListParams{} },
+		NewResult: func() interface{} { return &MrvlNvmNsGetCtrlrListResult{} },
+	},
+	"mrvl_nvm_ns_get_info": {
+		NewParams: func() interface{} { return &MrvlNvmGetNsInfoParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetNsInfoResult{} },
+	},
+	"mrvl_nvm_ctrlr_attach_ns": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrAttachNsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrAttachNsResult{} },
+	},
+	"mrvl_nvm_ctrlr_detach_ns": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrDetachNsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrDetachNsResult{} },
+	},
+	"mrvl_nvm_ctrlr_get_info": {
+		NewParams: func() interface{} { return &MrvlNvmGetCtrlrInfoParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetCtrlrInfoResult{} },
+	},
+	"mrvl_nvm_get_ctrlr_stats": {
+		NewParams: func() interface{} { return &MrvlNvmGetCtrlrStatsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetCtrlrStatsResult{} },
+	},
+	"mrvl_nvm_get_bulk_stats": {
+		NewResult: func() interface{} { return &MrvlNvmGetBulkStatsResult{} },
+	},
+	"mrvl_nvm_ctrlr_get_ns_stats": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrGetNsStatsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrGetNsStatsResult{} },
+	},
+	"mrvl_nvm_ctrlr_mi_send": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrMiSendParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrMiSendResult{} },
+	},
+	"mrvl_nvm_ctrlr_set_options": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrSetOptionsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrSetOptionsResult{} },
+	},
+	"mrvl_nvm_subsys_notify_ns_change": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysNotifyNsChangeParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysNotifyNsChangeResult{} },
+	},
+	"mrvl_nvm_ns_trace_start": {
+		NewParams: func() interface{} { return &MrvlNvmNsTraceStartParams{} },
+		NewResult: func() interface{} { return &MrvlNvmNsTraceStartResult{} },
+	},
+	"mrvl_nvm_ns_trace_read": {
+		NewParams: func() interface{} { return &MrvlNvmNsTraceReadParams{} },
+		NewResult: func() interface{} { return &MrvlNvmNsTraceReadResult{} },
+	},
+	"mrvl_nvm_ns_trace_stop": {
+		NewParams: func() interface{} { return &MrvlNvmNsTraceStopParams{} },
+		NewResult: func() interface{} { return &MrvlNvmNsTraceStopResult{} },
+	},
+	"mrvl_nvm_get_ctrlr_queue_stats": {
+		NewParams: func() interface{} { return &MrvlNvmGetCtrlrQueueStatsParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetCtrlrQueueStatsResult{} },
+	},
+	"mrvl_nvm_get_pcie_func_status": {
+		NewParams: func() interface{} { return &MrvlNvmGetPcieFuncStatusParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetPcieFuncStatusResult{} },
+	},
+	"mrvl_nvm_ctrlr_pause": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrPauseParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrPauseResult{} },
+	},
+	"mrvl_nvm_ctrlr_resume": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrResumeParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrResumeResult{} },
+	},
+	"mrvl_nvm_ctrlr_suspend": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrSuspendParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrSuspendResult{} },
+	},
+	"mrvl_nvm_ctrlr_get_suspend_status": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrGetSuspendStatusParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrGetSuspendStatusResult{} },
+	},
+	"mrvl_nvm_ctrlr_save_state": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrSaveStateParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrSaveStateResult{} },
+	},
+	"mrvl_nvm_ctrlr_restore_state": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrRestoreStateParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrRestoreStateResult{} },
+	},
+	"mrvl_nvm_ctrlr_reset": {
+		NewParams: func() interface{} { return &MrvlNvmCtrlrResetParams{} },
+		NewResult: func() interface{} { return &MrvlNvmCtrlrResetResult{} },
+	},
+	"mrvl_nvm_subsys_reset": {
+		NewParams: func() interface{} { return &MrvlNvmSubsysResetParams{} },
+		NewResult: func() interface{} { return &MrvlNvmSubsysResetResult{} },
+	},
+	"mrvl_nvm_get_sku_caps": {
+		NewResult: func() interface{} { return &MrvlNvmGetSkuCapsResult{} },
+	},
+	"mrvl_nvm_fw_get_slot_info": {
+		NewParams: func() interface{} { return &MrvlNvmFwGetSlotInfoParams{} },
+		NewResult: func() interface{} { return &MrvlNvmFwGetSlotInfoResult{} },
+	},
+	"mrvl_nvm_fw_download": {
+		NewParams: func() interface{} { return &MrvlNvmFwDownloadParams{} },
+		NewResult: func() interface{} { return &MrvlNvmFwDownloadResult{} },
+	},
+	"mrvl_nvm_fw_commit": {
+		NewParams: func() interface{} { return &MrvlNvmFwCommitParams{} },
+		NewResult: func() interface{} { return &MrvlNvmFwCommitResult{} },
+	},
+	"mrvl_nvm_opal_get_info": {
+		NewParams: func() interface{} { return &MrvlNvmOpalGetInfoParams{} },
+		NewResult: func() interface{} { return &MrvlNvmOpalGetInfoResult{} },
+	},
+	"mrvl_nvm_opal_take_ownership": {
+		NewParams: func() interface{} { return &MrvlNvmOpalTakeOwnershipParams{} },
+		NewResult: func() interface{} { return &MrvlNvmOpalTakeOwnershipResult{} },
+	},
+	"mrvl_nvm_opal_set_locking_range": {
+		NewParams: func() interface{} { return &MrvlNvmOpalSetLockingRangeParams{} },
+		NewResult: func() interface{} { return &MrvlNvmOpalSetLockingRangeResult{} },
+	},
+	"mrvl_nvm_opal_set_lock_state": {
+		NewParams: func() interface{} { return &MrvlNvmOpalSetLockStateParams{} },
+		NewResult: func() interface{} { return &MrvlNvmOpalSetLockStateResult{} },
+	},
+	"mrvl_nvm_get_smart_log": {
+		NewParams: func() interface{} { return &MrvlNvmGetSmartLogParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetSmartLogResult{} },
+	},
+	"mrvl_nvm_get_telemetry_log": {
+		NewParams: func() interface{} { return &MrvlNvmGetTelemetryLogParams{} },
+		NewResult: func() interface{} { return &MrvlNvmGetTelemetryLogResult{} },
+	},
+	"mrvl_nvm_ns_migrate_start": {
+		NewParams: func() interface{} { return &MrvlNvmNsMigrateStartParams{} },
+		NewResult: func() interface{} { return &MrvlNvmNsMigrateStartResult{} },
+	},
+	"mrvl_nvm_ns_migrate_get_status": {
+		NewParams: func() interface{} { return &MrvlNvmNsMigrateGetStatusParams{} },
+		NewResult: func() interface{} { return &MrvlNvmNsMigrateGetStatusResult{} },
+	},
+	"mrvl_nvm_ns_migrate_cutover": {
+		NewParams: func() interface{} { return &MrvlNvmNsMigrateCutoverParams{} },
+		NewResult: func() interface{} { return &MrvlNvmNsMigrateCutoverResult{} },
+	},
+	"mrvl_nvm_ns_migrate_abort": {
+		NewParams: func() interface{} { return &MrvlNvmNsMigrateAbortParams{} },
+		NewResult: func() interface{} { return &MrvlNvmNsMigrateAbortResult{} },
+	},
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package models holds definitions for SPDK json RPC structs
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models/internal/schema"
+)
+
+// populate sets every field of v, the slices with one element, so the round trips cover all of
+// them and not only the zero values omitted or left as is
+func populate(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		populate(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			populate(v.Field(i))
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		populate(v.Index(0))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(-7)
+	case reflect.Uint8, reflect.Uint32, reflect.Uint64:
+		v.SetUint(7)
+	case reflect.Float64:
+		v.SetFloat(0.5)
+	case reflect.String:
+		v.SetString("opi")
+	}
+}
+
+func TestModels_MrvlNvmRoundTrip(t *testing.T) {
+	for method, m := range MrvlNvmMethods {
+		for kind, newMessage := range map[string]func() interface{}{"params": m.NewParams, "result": m.NewResult} {
+			if newMessage == nil {
+				continue
+			}
+			t.Run(method+" "+kind, func(t *testing.T) {
+				expected := newMessage()
+				populate(reflect.ValueOf(expected))
+				data, err := json.Marshal(expected)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decode := DecodeMrvlNvmParams
+				if kind == "result" {
+					decode = DecodeMrvlNvmResult
+				}
+				received, err := decode(method, data)
+				if err != nil {
+					t.Fatal("decode: expected no error, received", err)
+				}
+				if !reflect.DeepEqual(received, expected) {
+					t.Error("round trip: expected", expected, "received", received)
+				}
+			})
+		}
+	}
+}
+
+func TestModels_DecodeMrvlNvm(t *testing.T) {
+	tests := map[string]struct {
+		decode func(method string, data []byte) (interface{}, error)
+		method string
+		data   string
+		errMsg string
+	}{
+		"valid result": {
+			decode: DecodeMrvlNvmResult,
+			method: "mrvl_nvm_subsys_create_ctrlr",
+			data:   `{"status": 0, "ctrlr_id": 18}`,
+		},
+		"field unknown to the schema": {
+			decode: DecodeMrvlNvmResult,
+			method: "mrvl_nvm_subsys_create_ctrlr",
+			data:   `{"status": 0, "ctrlr_id": 18, "cntlid": 18}`,
+			errMsg: `mrvl_nvm_subsys_create_ctrlr: json: unknown field "cntlid"`,
+		},
+		"field of the wrong type": {
+			decode: DecodeMrvlNvmParams,
+			method: "mrvl_nvm_delete_subsystem",
+			data:   `{"subnqn": 1}`,
+			errMsg: "mrvl_nvm_delete_subsystem: json: cannot unmarshal number into Go struct field MrvlNvmDeleteSubsystemParams.subnqn of type string",
+		},
+		"unknown method": {
+			decode: DecodeMrvlNvmResult,
+			method: "mrvl_nvm_unknown",
+			data:   `{}`,
+			errMsg: "unknown method mrvl_nvm_unknown",
+		},
+		"method without parameters": {
+			decode: DecodeMrvlNvmParams,
+			method: "mrvl_nvm_get_subsys_list",
+			data:   `{}`,
+			errMsg: "mrvl_nvm_get_subsys_list: the method takes no parameters",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tt.decode(tt.method, []byte(tt.data))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Error("expected no error, received", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", err)
+			}
+		})
+	}
+}
+
+func TestModels_MrvlNvmGenerated(t *testing.T) {
+	s, err := schema.Load("mrvl_nvm.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := s.Generate("mrvl_nvm.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := os.ReadFile("mrvl_nvm_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, expected) {
+		t.Error("mrvl_nvm_gen.go is not up to date with mrvl_nvm.yaml, run go generate ./pkg/models")
+	}
+}
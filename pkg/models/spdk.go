@@ -5,320 +5,1078 @@
 // Package models holds definitions for SPDK json RPC structs
 package models
 
-// MrvlNvmGetSubsysCountParams is empty
+// MrvlBdevGetAllocationParams represents the parameters to a Marvell get bdev allocation request
+type MrvlBdevGetAllocationParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevGetAllocationResult represents a Marvell get bdev allocation result
+type MrvlBdevGetAllocationResult struct {
+	Status         Status `json:"status"`
+	Pool           string `json:"pool"`
+	ThinProvision  bool   `json:"thin_provision"`
+	SizeBytes      uint64 `json:"size_bytes"`
+	AllocatedBytes uint64 `json:"allocated_bytes"`
+}
+
+// MrvlPoolGetListResult represents a Marvell get pool list result
+type MrvlPoolGetListResult struct {
+	Status   Status `json:"status"`
+	PoolList []struct {
+		Name            string `json:"name"`
+		CapacityBytes   uint64 `json:"capacity_bytes"`
+		AllocatedBytes  uint64 `json:"allocated_bytes"`
+		AdvertisedBytes uint64 `json:"advertised_bytes"`
+	} `json:"pool_list"`
+}
+
+// MrvlBdevVerifyStartParams represents the parameters to a Marvell start bdev verify request
+type MrvlBdevVerifyStartParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevVerifyStartResult represents a Marvell start bdev verify result
+type MrvlBdevVerifyStartResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevVerifyGetStatusParams represents the parameters to a Marvell get bdev verify status request
+type MrvlBdevVerifyGetStatusParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevVerifyGetStatusResult represents a Marvell get bdev verify status result
+type MrvlBdevVerifyGetStatusResult struct {
+	Status      Status `json:"status"`
+	State       string `json:"state"`
+	Progress    int    `json:"progress"`
+	MediaErrors []struct {
+		Lba    uint64 `json:"lba"`
+		Blocks uint32 `json:"blocks"`
+	} `json:"media_errors"`
+}
+
+// MrvlBdevCopyStartParams represents the parameters to a Marvell start bdev copy request
+type MrvlBdevCopyStartParams struct {
+	SrcBdev string `json:"src_bdev"`
+	DstBdev string `json:"dst_bdev"`
+}
+
+// MrvlBdevCopyStartResult represents a Marvell start bdev copy result
+type MrvlBdevCopyStartResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevWriteZeroesStartParams represents the parameters to a Marvell start bdev write zeroes request
+type MrvlBdevWriteZeroesStartParams struct {
+	Name        string `json:"name"`
+	OffsetBytes uint64 `json:"offset_bytes"`
+	LengthBytes uint64 `json:"length_bytes"`
+	Deallocate  bool   `json:"deallocate"`
+}
+
+// MrvlBdevWriteZeroesStartResult represents a Marvell start bdev write zeroes result
+type MrvlBdevWriteZeroesStartResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevOffloadGetStatusParams represents the parameters to a Marvell get bdev copy or write zeroes status request
+type MrvlBdevOffloadGetStatusParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevOffloadGetStatusResult represents a Marvell get bdev copy or write zeroes status result
+type MrvlBdevOffloadGetStatusResult struct {
+	Status     Status `json:"status"`
+	State      string `json:"state"`
+	Progress   int    `json:"progress"`
+	BytesDone  uint64 `json:"bytes_done"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+// MrvlBdevOffloadAbortParams represents the parameters to a Marvell abort bdev copy or write zeroes request
+type MrvlBdevOffloadAbortParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevOffloadAbortResult represents a Marvell abort bdev copy or write zeroes result
+type MrvlBdevOffloadAbortResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlPlatformGetTelemetryResult represents a Marvell get platform telemetry result
+type MrvlPlatformGetTelemetryResult struct {
+	Status        Status `json:"status"`
+	SocTempMilliC int    `json:"soc_temp_mc"`
+	PowerMilliW   int    `json:"power_mw"`
+	Throttled     bool   `json:"throttled"`
+}
+
+// MrvlPlatformGetResourceUsageResult represents a Marvell get platform resource usage result
+type MrvlPlatformGetResourceUsageResult struct {
+	Status    Status `json:"status"`
+	Hugepages []struct {
+		PageSizeKb int `json:"page_size_kb"`
+		Total      int `json:"total"`
+		Free       int `json:"free"`
+	} `json:"hugepages"`
+	Cores []struct {
+		Lcore   int `json:"lcore"`
+		LoadPct int `json:"load_pct"`
+		Pollers int `json:"pollers"`
+	} `json:"cores"`
+	Mempools []struct {
+		Name      string `json:"name"`
+		Size      int    `json:"size"`
+		Available int    `json:"available"`
+		EltSize   int    `json:"elt_size"`
+	} `json:"mempools"`
+}
+
+// MrvlPlatformGetMeasurementsParams represents the parameters to a Marvell get platform SPDM
+// measurements request
+type MrvlPlatformGetMeasurementsParams struct {
+	Nonce string `json:"nonce"`
+}
+
+// MrvlPlatformGetMeasurementsResult represents a Marvell get platform SPDM measurements result,
+// the signature covers the SPDM measurements response for the nonce
+type MrvlPlatformGetMeasurementsResult struct {
+	Status       Status `json:"status"`
+	SpdmVersion  string `json:"spdm_version"`
+	Measurements []struct {
+		Index       int    `json:"index"`
+		Description string `json:"description"`
+		Digest      string `json:"digest"`
+	} `json:"measurements"`
+	Response  string `json:"response"`
+	Signature string `json:"signature"`
+	CertChain string `json:"cert_chain"`
+}
+
+// MrvlPlatformGetInventoryResult represents a Marvell get platform inventory result
+type MrvlPlatformGetInventoryResult struct {
+	Status       Status `json:"status"`
+	Sku          string `json:"sku"`
+	SerialNumber string `json:"serial_number"`
+	FwVersion    string `json:"fw_version"`
+	SdkVersion   string `json:"sdk_version"`
+	Pfs          []struct {
+		PfID   int `json:"pf_id"`
+		MaxVfs int `json:"max_vfs"`
+		NumVfs int `json:"num_vfs"`
+	} `json:"pfs"`
+	Drives []struct {
+		Device    string `json:"device"`
+		PcieAddr  string `json:"pcie_addr"`
+		Model     string `json:"model"`
+		Serial    string `json:"serial"`
+		FwRev     string `json:"fw_rev"`
+		SizeBytes uint64 `json:"size_bytes"`
+	} `json:"drives"`
+	Features []string `json:"features"`
+}
+
+// MrvlBdevGetQosStatsParams represents the parameters to a Marvell get bdev QoS statistics request
+type MrvlBdevGetQosStatsParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevGetQosStatsResult represents a Marvell get bdev QoS statistics result
+type MrvlBdevGetQosStatsResult struct {
+	Status             Status `json:"status"`
+	ThrottledIos       uint64 `json:"throttled_ios"`
+	ThrottledBytes     uint64 `json:"throttled_bytes"`
+	QueuedTimeUs       uint64 `json:"queued_time_us"`
+	IosCredit          uint64 `json:"ios_credit"`
+	BytesCredit        uint64 `json:"bytes_credit"`
+	CurrentlyThrottled bool   `json:"currently_throttled"`
+}
+
+// MrvlBdevCryptoCreateParams represents the parameters to a Marvell create crypto bdev request
+type MrvlBdevCryptoCreateParams struct {
+	Name         string `json:"name"`
+	BaseBdevName string `json:"base_bdev_name"`
+	Cipher       string `json:"cipher"`
+	Key          string `json:"key"`
+	Key2         string `json:"key2"`
+}
 
-// MrvlNvmGetSubMrvvNvmGetSubsysListParams is empty
+// MrvlBdevCryptoCreateResult represents a Marvell create crypto bdev result
+type MrvlBdevCryptoCreateResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevCryptoDeleteParams represents the parameters to a Marvell delete crypto bdev request
+type MrvlBdevCryptoDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevCryptoDeleteResult represents a Marvell delete crypto bdev result
+type MrvlBdevCryptoDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevCryptoGetListParams is empty
+
+// MrvlBdevCryptoGetListResult represents a Marvell crypto bdev list result
+type MrvlBdevCryptoGetListResult struct {
+	Status     Status `json:"status"`
+	CryptoList []struct {
+		Name         string `json:"name"`
+		BaseBdevName string `json:"base_bdev_name"`
+		Cipher       string `json:"cipher"`
+		KeySize      int    `json:"key_size"`
+	} `json:"crypto_list"`
+}
 
-// MrvlNvmGetSubsysListResult represents a Marvell subsystem list result
-type MrvlNvmGetSubsysListResult struct {
-	Status     int `json:"status"`
-	SubsysList []struct {
-		Subnqn string `json:"subnqn"`
-	} `json:"subsys_list"`
+// MrvlBdevCryptoRekeyParams represents the parameters to a Marvell crypto bdev re-key request
+type MrvlBdevCryptoRekeyParams struct {
+	Name   string `json:"name"`
+	Cipher string `json:"cipher"`
+	Key    string `json:"key"`
+	Key2   string `json:"key2"`
 }
 
-// MrvlNvmCreateSubsystemParams represents the parameters to a Marvell create subsystem request
-type MrvlNvmCreateSubsystemParams struct {
-	Subnqn        string `json:"subnqn"`
-	Mn            string `json:"mn"`
-	Sn            string `json:"sn"`
-	MaxNamespaces int    `json:"max_namespaces"`
-	MinCtrlrID    int    `json:"min_ctrlr_id"`
-	MaxCtrlrID    int    `json:"max_ctrlr_id"`
+// MrvlBdevCryptoRekeyResult represents a Marvell crypto bdev re-key result
+type MrvlBdevCryptoRekeyResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmCreateSubsystemResult represents a Marvell create subsystem result
-type MrvlNvmCreateSubsystemResult struct {
-	Status int `json:"status"`
+// MrvlBdevCryptoGetRekeyStatusParams represents the parameters to a Marvell get crypto bdev re-key status request
+type MrvlBdevCryptoGetRekeyStatusParams struct {
+	Name string `json:"name"`
 }
 
-// MrvlNvmDeleteSubsystemParams represents the parameters to a Marvell delete subsystem request
-type MrvlNvmDeleteSubsystemParams struct {
-	Subnqn string `json:"subnqn"`
+// MrvlBdevCryptoGetRekeyStatusResult represents a Marvell get crypto bdev re-key status result
+type MrvlBdevCryptoGetRekeyStatusResult struct {
+	Status     Status `json:"status"`
+	State      string `json:"state"`
+	Progress   int    `json:"progress"`
+	BytesDone  uint64 `json:"bytes_done"`
+	TotalBytes uint64 `json:"total_bytes"`
 }
 
-// MrvlNvmDeleteSubsystemResult represents a Marvell delete subsystem result
-type MrvlNvmDeleteSubsystemResult struct {
-	Status int `json:"status"`
+// MrvlBdevCompressCreateParams represents the parameters to a Marvell create compress bdev request
+type MrvlBdevCompressCreateParams struct {
+	Name         string `json:"name"`
+	BaseBdevName string `json:"base_bdev_name"`
+	Algorithm    string `json:"algorithm"`
 }
 
-// MrvlNvmDeInitParams is empty
+// MrvlBdevCompressCreateResult represents a Marvell create compress bdev result
+type MrvlBdevCompressCreateResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevCompressDeleteParams represents the parameters to a Marvell delete compress bdev request
+type MrvlBdevCompressDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevCompressDeleteResult represents a Marvell delete compress bdev result
+type MrvlBdevCompressDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevCompressGetListResult represents a Marvell get compress bdev list result
+type MrvlBdevCompressGetListResult struct {
+	Status       Status `json:"status"`
+	CompressList []struct {
+		Name         string `json:"name"`
+		BaseBdevName string `json:"base_bdev_name"`
+		Algorithm    string `json:"algorithm"`
+	} `json:"compress_list"`
+}
 
-// MrvlNvmDeInitResult represents a Marvell de-init result
-type MrvlNvmDeInitResult struct {
-	Status int `json:"status"`
+// MrvlBdevCompressGetStatsParams represents the parameters to a Marvell get compress bdev statistics request
+type MrvlBdevCompressGetStatsParams struct {
+	Name string `json:"name"`
 }
 
-// MrvlNvmGetSubsysInfoParams represents the parameters to a Marvell get subsystem info request
-type MrvlNvmGetSubsysInfoParams struct {
-	Subnqn string `json:"subnqn"`
+// MrvlBdevCompressGetStatsResult represents a Marvell get compress bdev statistics result
+type MrvlBdevCompressGetStatsResult struct {
+	Status        Status `json:"status"`
+	LogicalBytes  uint64 `json:"logical_bytes"`
+	PhysicalBytes uint64 `json:"physical_bytes"`
 }
 
-// MrvlNvmGetSubsysInfoResult represents a Marvell get subsystem info result
-type MrvlNvmGetSubsysInfoResult struct {
-	Status     int `json:"status"`
-	SubsysList []struct {
-		Subnqn         string `json:"subnqn"`
-		Mn             string `json:"mn"`
-		Sn             string `json:"sn"`
-		MaxNamespaces  int    `json:"max_namespaces"`
-		MinCtrlrID     int    `json:"min_ctrlr_id"`
-		MaxCtrlrID     int    `json:"max_ctrlr_id"`
-		NumNs          int    `json:"num_ns"`
-		NumTotalCtrlr  int    `json:"num_total_ctrlr"`
-		NumActiveCtrlr int    `json:"num_active_ctrlr"`
-		NsList         []struct {
-			NsInstanceID int    `json:"ns_instance_id"`
-			Bdev         string `json:"bdev"`
-			CtrlrIDList  []struct {
-				CtrlrID int `json:"ctrlr_id"`
-			} `json:"ctrlr_id_list"`
-		} `json:"ns_list"`
-	} `json:"subsys_list"`
+// MrvlBdevDedupCreateParams represents the parameters to a Marvell create dedup bdev request
+type MrvlBdevDedupCreateParams struct {
+	Name         string `json:"name"`
+	BaseBdevName string `json:"base_bdev_name"`
+	ChunkSize    int    `json:"chunk_size"`
 }
 
-// MrvlNvmSubsysAllocNsParams represents the parameters to a Marvell get subsystem allocate namespace request
-type MrvlNvmSubsysAllocNsParams struct {
+// MrvlBdevDedupCreateResult represents a Marvell create dedup bdev result
+type MrvlBdevDedupCreateResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevDedupDeleteParams represents the parameters to a Marvell delete dedup bdev request
+type MrvlBdevDedupDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevDedupDeleteResult represents a Marvell delete dedup bdev result
+type MrvlBdevDedupDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevDedupGetListResult represents a Marvell get dedup bdev list result
+type MrvlBdevDedupGetListResult struct {
+	Status    Status `json:"status"`
+	DedupList []struct {
+		Name         string `json:"name"`
+		BaseBdevName string `json:"base_bdev_name"`
+		ChunkSize    int    `json:"chunk_size"`
+	} `json:"dedup_list"`
+}
+
+// MrvlBdevDedupGetStatsParams represents the parameters to a Marvell get dedup bdev statistics request
+type MrvlBdevDedupGetStatsParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevDedupGetStatsResult represents a Marvell get dedup bdev statistics result
+type MrvlBdevDedupGetStatsResult struct {
+	Status       Status `json:"status"`
+	LogicalBytes uint64 `json:"logical_bytes"`
+	UniqueBytes  uint64 `json:"unique_bytes"`
+}
+
+// MrvlBdevSnapshotCreateParams represents the parameters to a Marvell create snapshot request
+type MrvlBdevSnapshotCreateParams struct {
+	Name         string `json:"name"`
+	BaseBdevName string `json:"base_bdev_name"`
+}
+
+// MrvlBdevSnapshotCreateResult represents a Marvell create snapshot result
+type MrvlBdevSnapshotCreateResult struct {
+	Status       Status `json:"status"`
+	CreationTime int64  `json:"creation_time"`
+}
+
+// MrvlBdevSnapshotDeleteParams represents the parameters to a Marvell delete snapshot request
+type MrvlBdevSnapshotDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevSnapshotDeleteResult represents a Marvell delete snapshot result
+type MrvlBdevSnapshotDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevSnapshotGetListParams represents the parameters to a Marvell get snapshot list request
+type MrvlBdevSnapshotGetListParams struct {
+	BaseBdevName string `json:"base_bdev_name,omitempty"`
+}
+
+// MrvlBdevSnapshotGetListResult represents a Marvell get snapshot list result
+type MrvlBdevSnapshotGetListResult struct {
+	Status       Status `json:"status"`
+	SnapshotList []struct {
+		Name         string `json:"name"`
+		BaseBdevName string `json:"base_bdev_name"`
+		CreationTime int64  `json:"creation_time"`
+	} `json:"snapshot_list"`
+}
+
+// MrvlBdevSnapshotRevertParams represents the parameters to a Marvell revert snapshot request
+type MrvlBdevSnapshotRevertParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevSnapshotRevertResult represents a Marvell revert snapshot result
+type MrvlBdevSnapshotRevertResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevCloneCreateParams represents the parameters to a Marvell create clone request
+type MrvlBdevCloneCreateParams struct {
+	Name         string `json:"name"`
+	SnapshotName string `json:"snapshot_name"`
+}
+
+// MrvlBdevCloneCreateResult represents a Marvell create clone result
+type MrvlBdevCloneCreateResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevCloneDeleteParams represents the parameters to a Marvell delete clone request
+type MrvlBdevCloneDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevCloneDeleteResult represents a Marvell delete clone result
+type MrvlBdevCloneDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevCloneGetListResult represents a Marvell get clone list result
+type MrvlBdevCloneGetListResult struct {
+	Status    Status `json:"status"`
+	CloneList []struct {
+		Name         string `json:"name"`
+		SnapshotName string `json:"snapshot_name"`
+	} `json:"clone_list"`
+}
+
+// MrvlBdevRaidCreateParams represents the parameters to a Marvell create raid bdev request
+type MrvlBdevRaidCreateParams struct {
+	Name        string   `json:"name"`
+	RaidLevel   string   `json:"raid_level"`
+	StripSizeKb int      `json:"strip_size_kb,omitempty"`
+	BaseBdevs   []string `json:"base_bdevs"`
+}
+
+// MrvlBdevRaidCreateResult represents a Marvell create raid bdev result
+type MrvlBdevRaidCreateResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevRaidDeleteParams represents the parameters to a Marvell delete raid bdev request
+type MrvlBdevRaidDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevRaidDeleteResult represents a Marvell delete raid bdev result
+type MrvlBdevRaidDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevRaidGetListResult represents a Marvell get raid bdev list result
+type MrvlBdevRaidGetListResult struct {
+	Status   Status `json:"status"`
+	RaidList []struct {
+		Name        string   `json:"name"`
+		RaidLevel   string   `json:"raid_level"`
+		StripSizeKb int      `json:"strip_size_kb"`
+		BaseBdevs   []string `json:"base_bdevs"`
+	} `json:"raid_list"`
+}
+
+// MrvlBdevRaidReplaceBaseBdevParams represents the parameters to a Marvell replace raid bdev base bdev request
+type MrvlBdevRaidReplaceBaseBdevParams struct {
+	Name            string `json:"name"`
+	BaseBdevName    string `json:"base_bdev_name"`
+	NewBaseBdevName string `json:"new_base_bdev_name"`
+}
+
+// MrvlBdevRaidReplaceBaseBdevResult represents a Marvell replace raid bdev base bdev result
+type MrvlBdevRaidReplaceBaseBdevResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevRaidGetRebuildStatusParams represents the parameters to a Marvell get raid bdev rebuild status request
+type MrvlBdevRaidGetRebuildStatusParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevRaidGetRebuildStatusResult represents a Marvell get raid bdev rebuild status result
+type MrvlBdevRaidGetRebuildStatusResult struct {
+	Status     Status `json:"status"`
+	State      string `json:"state"`
+	Progress   int    `json:"progress"`
+	BytesDone  uint64 `json:"bytes_done"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+// MrvlBdevOcfCreateParams represents the parameters to a Marvell create OCF bdev request
+type MrvlBdevOcfCreateParams struct {
+	Name          string `json:"name"`
+	Mode          string `json:"mode"`
+	CacheBdevName string `json:"cache_bdev_name"`
+	CoreBdevName  string `json:"core_bdev_name"`
+}
+
+// MrvlBdevOcfCreateResult represents a Marvell create OCF bdev result
+type MrvlBdevOcfCreateResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevOcfDeleteParams represents the parameters to a Marvell delete OCF bdev request
+type MrvlBdevOcfDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevOcfDeleteResult represents a Marvell delete OCF bdev result
+type MrvlBdevOcfDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevOcfGetListResult represents a Marvell get OCF bdev list result
+type MrvlBdevOcfGetListResult struct {
+	Status  Status `json:"status"`
+	OcfList []struct {
+		Name          string `json:"name"`
+		Mode          string `json:"mode"`
+		CacheBdevName string `json:"cache_bdev_name"`
+		CoreBdevName  string `json:"core_bdev_name"`
+	} `json:"ocf_list"`
+}
+
+// MrvlBdevOcfSetCacheModeParams represents the parameters to a Marvell set OCF bdev cache mode request
+type MrvlBdevOcfSetCacheModeParams struct {
+	Name string `json:"name"`
+	Mode string `json:"mode"`
+}
+
+// MrvlBdevOcfSetCacheModeResult represents a Marvell set OCF bdev cache mode result
+type MrvlBdevOcfSetCacheModeResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevOcfGetStatsParams represents the parameters to a Marvell get OCF bdev statistics request
+type MrvlBdevOcfGetStatsParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevOcfGetStatsResult represents a Marvell get OCF bdev statistics result
+type MrvlBdevOcfGetStatsResult struct {
+	Status      Status `json:"status"`
+	ReadHits    uint64 `json:"read_hits"`
+	ReadMisses  uint64 `json:"read_misses"`
+	WriteHits   uint64 `json:"write_hits"`
+	WriteMisses uint64 `json:"write_misses"`
+	DirtyBytes  uint64 `json:"dirty_bytes"`
+}
+
+// MrvlBdevQosGroupParams represents the parameters to a Marvell create or modify bdev QoS group request
+type MrvlBdevQosGroupParams struct {
+	Name           string   `json:"name"`
+	Bdevs          []string `json:"bdevs"`
+	RwIosPerSec    int64    `json:"rw_ios_per_sec"`
+	RMbytesPerSec  int64    `json:"r_mbytes_per_sec"`
+	WMbytesPerSec  int64    `json:"w_mbytes_per_sec"`
+	RwMbytesPerSec int64    `json:"rw_mbytes_per_sec"`
+}
+
+// MrvlBdevQosGroupResult represents a Marvell create, modify or delete bdev QoS group result
+type MrvlBdevQosGroupResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevQosGroupDeleteParams represents the parameters to a Marvell delete bdev QoS group request
+type MrvlBdevQosGroupDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevQosGroupGetListResult represents a Marvell get bdev QoS group list result
+type MrvlBdevQosGroupGetListResult struct {
+	Status    Status `json:"status"`
+	GroupList []struct {
+		Name           string   `json:"name"`
+		Bdevs          []string `json:"bdevs"`
+		RwIosPerSec    int64    `json:"rw_ios_per_sec"`
+		RMbytesPerSec  int64    `json:"r_mbytes_per_sec"`
+		WMbytesPerSec  int64    `json:"w_mbytes_per_sec"`
+		RwMbytesPerSec int64    `json:"rw_mbytes_per_sec"`
+	} `json:"group_list"`
+}
+
+// MrvlBdevNvmeAttachControllerParams represents the parameters to a Marvell attach remote NVMe controller request
+type MrvlBdevNvmeAttachControllerParams struct {
+	Name        string `json:"name"`
+	Trtype      string `json:"trtype"`
+	Traddr      string `json:"traddr"`
+	Adrfam      string `json:"adrfam"`
+	Trsvcid     string `json:"trsvcid"`
 	Subnqn      string `json:"subnqn"`
-	Nguid       string `json:"nguid"`
-	Eui64       string `json:"eui64"`
-	UUID        string `json:"uuid"`
-	ShareEnable int    `json:"share_enable"`
-	Bdev        string `json:"bdev"`
+	Hostnqn     string `json:"hostnqn,omitempty"`
+	Hdgst       bool   `json:"hdgst"`
+	Ddgst       bool   `json:"ddgst"`
+	Psk         string `json:"psk,omitempty"`
+	Multipath   string `json:"multipath"`
+	NumIoQueues int64  `json:"num_io_queues,omitempty"`
+	IoQueueSize int64  `json:"io_queue_size,omitempty"`
+	HcaName     string `json:"hca_name,omitempty"`
+	HcaPort     int    `json:"hca_port,omitempty"`
+	RdmaCqSize  int    `json:"rdma_cq_size,omitempty"`
+	// keep alive and reconnect policy, 0 keeps the firmware defaults
+	KeepAliveTimeoutMs   int `json:"keep_alive_timeout_ms,omitempty"`
+	ReconnectRetryCount  int `json:"reconnect_retry_count,omitempty"`
+	ReconnectDelaySec    int `json:"reconnect_delay_sec,omitempty"`
+	MaxReconnectDelaySec int `json:"max_reconnect_delay_sec,omitempty"`
+	FastIoFailTimeoutSec int `json:"fast_io_fail_timeout_sec,omitempty"`
+	// DH-HMAC-CHAP keys are names of keys of the firmware keyring, not the secrets
+	DhchapKey      string   `json:"dhchap_key,omitempty"`
+	DhchapCtrlrKey string   `json:"dhchap_ctrlr_key,omitempty"`
+	DhchapDigests  []string `json:"dhchap_digests,omitempty"`
+	DhchapDhgroups []string `json:"dhchap_dhgroups,omitempty"`
+}
+
+// MrvlBdevNvmeAttachControllerResult represents a Marvell attach remote NVMe controller result
+type MrvlBdevNvmeAttachControllerResult struct {
+	Status   Status   `json:"status"`
+	BdevList []string `json:"bdev_list"`
+}
+
+// MrvlBdevNvmeDetachControllerParams represents the parameters to a Marvell detach remote NVMe controller request
+type MrvlBdevNvmeDetachControllerParams struct {
+	Name    string `json:"name"`
+	Trtype  string `json:"trtype"`
+	Traddr  string `json:"traddr"`
+	Adrfam  string `json:"adrfam"`
+	Trsvcid string `json:"trsvcid"`
+	Subnqn  string `json:"subnqn"`
+}
+
+// MrvlBdevNvmeDetachControllerResult represents a Marvell detach remote NVMe controller result
+type MrvlBdevNvmeDetachControllerResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmSubsysAllocNsResult represents a Marvell get subsystem alloc namespace result
-type MrvlNvmSubsysAllocNsResult struct {
-	Status       int `json:"status"`
-	NsInstanceID int `json:"ns_instance_id"`
+// MrvlBdevNvmeResetControllerParams represents the parameters to a Marvell reset remote NVMe controller request
+type MrvlBdevNvmeResetControllerParams struct {
+	Name string `json:"name"`
 }
 
-// MrvlNvmSubsysUnallocNsParams represents the parameters to a Marvell get subsystem unallocate namespace request
-type MrvlNvmSubsysUnallocNsParams struct {
-	Subnqn       string `json:"subnqn"`
-	NsInstanceID int    `json:"ns_instance_id"`
+// MrvlBdevNvmeResetControllerResult represents a Marvell reset remote NVMe controller result
+type MrvlBdevNvmeResetControllerResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmSubsysUnallocNsResult represents a Marvell get subsystem unalloc namespace result
-type MrvlNvmSubsysUnallocNsResult struct {
-	Status int `json:"status"`
+// MrvlBdevNvmeGetStatsParams represents the parameters to a Marvell get remote NVMe controller stats request,
+// the stats are limited to one path when its address is set
+type MrvlBdevNvmeGetStatsParams struct {
+	Name    string `json:"name"`
+	Traddr  string `json:"traddr,omitempty"`
+	Trsvcid string `json:"trsvcid,omitempty"`
 }
 
-// MrvlNvmSubsysGetNsListParams represents the parameters to a Marvell get subsystem namespace list request
-type MrvlNvmSubsysGetNsListParams struct {
-	Subnqn string `json:"subnqn"`
+// MrvlBdevNvmeGetStatsResult represents a Marvell get remote NVMe controller stats result
+type MrvlBdevNvmeGetStatsResult struct {
+	Status                Status `json:"status"`
+	NumReadCmds           int    `json:"num_read_cmds"`
+	NumReadBytes          int    `json:"num_read_bytes"`
+	NumWriteCmds          int    `json:"num_write_cmds"`
+	NumWriteBytes         int    `json:"num_write_bytes"`
+	TotalReadLatencyInUs  int    `json:"total_read_latency_in_us"`
+	TotalWriteLatencyInUs int    `json:"total_write_latency_in_us"`
 }
 
-// MrvlNvmSubsysGetNsListResult represents a Marvell get subsystem namespace list result
-type MrvlNvmSubsysGetNsListResult struct {
-	Status int `json:"status"`
+// MrvlBdevNvmeGetNsListParams represents the parameters to a Marvell get remote NVMe namespace list request
+type MrvlBdevNvmeGetNsListParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevNvmeGetNsListResult represents a Marvell get remote NVMe namespace list result
+type MrvlBdevNvmeGetNsListResult struct {
+	Status Status `json:"status"`
 	NsList []struct {
-		NsInstanceID int    `json:"ns_instance_id"`
-		Bdev         string `json:"bdev"`
-		CtrlrIDList  []struct {
-			CtrlrID int `json:"ctrlr_id"`
-		} `json:"ctrlr_id_list"`
+		Nsid     int    `json:"nsid"`
+		BdevName string `json:"bdev_name"`
+		Nguid    string `json:"nguid"`
+		Eui64    int64  `json:"eui64"`
+		UUID     string `json:"uuid"`
 	} `json:"ns_list"`
 }
 
-// MrvlNvmSubsysCreateCtrlrParams represents the parameters to a Marvell create subsystem controller request
-type MrvlNvmSubsysCreateCtrlrParams struct {
-	Subnqn       string `json:"subnqn"`
-	PcieDomainID int    `json:"pcie_domain_id"`
-	PfID         int    `json:"pf_id"`
-	VfID         int    `json:"vf_id"`
-	CtrlrID      int    `json:"ctrlr_id"`
-	MaxNsq       int    `json:"max_nsq"`
-	MaxNcq       int    `json:"max_ncq"`
-	Mqes         int    `json:"mqes"`
+// MrvlBdevNvmeGetIoPathsParams represents the parameters to a Marvell get remote NVMe IO paths request
+type MrvlBdevNvmeGetIoPathsParams struct {
+	Name string `json:"name"`
 }
 
-// MrvlNvmSubsysCreateCtrlrResult represents a Marvell create subsystem controller result
-type MrvlNvmSubsysCreateCtrlrResult struct {
-	Status  int `json:"status"`
-	CtrlrID int `json:"ctrlr_id"`
+// MrvlBdevNvmeGetIoPathsResult represents a Marvell get remote NVMe IO paths result
+type MrvlBdevNvmeGetIoPathsResult struct {
+	Status  Status `json:"status"`
+	IoPaths []struct {
+		Traddr    string `json:"traddr"`
+		Trsvcid   string `json:"trsvcid"`
+		Connected bool   `json:"connected"`
+		Failed    bool   `json:"failed"`
+		AnaState  string `json:"ana_state"`
+		Current   bool   `json:"current"`
+	} `json:"io_paths"`
 }
 
-// MrvlNvmSubsysUpdateCtrlrParams represents the parameters to a Marvell update subsystem controller request
-type MrvlNvmSubsysUpdateCtrlrParams struct {
-	Subnqn  string `json:"subnqn"`
-	CtrlrID int    `json:"ctrlr_id"`
-	MaxNsq  int    `json:"max_nsq"`
-	MaxNcq  int    `json:"max_ncq"`
+// MrvlBdevNvmeGetPathStatsParams represents the parameters to a Marvell get remote NVMe path stats request
+type MrvlBdevNvmeGetPathStatsParams struct {
+	Name    string `json:"name"`
+	Traddr  string `json:"traddr"`
+	Trsvcid string `json:"trsvcid,omitempty"`
 }
 
-// MrvlNvmSubsysUpdateCtrlrResult represents  a Marvell update subsystem controller result
-type MrvlNvmSubsysUpdateCtrlrResult struct {
-	Status int `json:"status"`
+// MrvlBdevNvmeGetPathStatsResult represents a Marvell get remote NVMe path stats result
+type MrvlBdevNvmeGetPathStatsResult struct {
+	Status        Status `json:"status"`
+	Connected     bool   `json:"connected"`
+	AnaState      string `json:"ana_state"`
+	NumReadCmds   uint64 `json:"num_read_cmds"`
+	NumWriteCmds  uint64 `json:"num_write_cmds"`
+	NumReadBytes  uint64 `json:"num_read_bytes"`
+	NumWriteBytes uint64 `json:"num_write_bytes"`
+	IoErrors      uint64 `json:"io_errors"`
+	Timeouts      uint64 `json:"timeouts"`
+	Reconnects    uint64 `json:"reconnects"`
+	RttUs         uint64 `json:"rtt_us"`
+	MaxRttUs      uint64 `json:"max_rtt_us"`
 }
 
-// MrvlNvmSubsysRemoveCtrlrParams represents the parameters to a Marvell remove subsystem controller request
-type MrvlNvmSubsysRemoveCtrlrParams struct {
-	Subnqn  string `json:"subnqn"`
-	CtrlrID int    `json:"ctrlr_id"`
-	Force   int    `json:"force"`
+// MrvlBdevNvmeSetMultipathPolicyParams represents the parameters to a Marvell set remote NVMe multipath policy request
+type MrvlBdevNvmeSetMultipathPolicyParams struct {
+	Name     string `json:"name"`
+	Policy   string `json:"policy"`
+	Selector string `json:"selector,omitempty"`
 }
 
-// MrvlNvmSubsysRemoveCtrlrResult represents  a Marvell remove subsystem controller result
-type MrvlNvmSubsysRemoveCtrlrResult struct {
-	Status int `json:"status"`
+// MrvlBdevNvmeSetMultipathPolicyResult represents a Marvell set remote NVMe multipath policy result
+type MrvlBdevNvmeSetMultipathPolicyResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmSubsysGetCtrlrListParams represents the parameters to a Marvell get subsystem controller list request
-type MrvlNvmSubsysGetCtrlrListParams struct {
-	Subnqn string `json:"subnqn"`
+// MrvlBdevNvmeStartDiscoveryParams represents the parameters to a Marvell start NVMe discovery request
+type MrvlBdevNvmeStartDiscoveryParams struct {
+	Name    string `json:"name"`
+	Trtype  string `json:"trtype"`
+	Traddr  string `json:"traddr"`
+	Adrfam  string `json:"adrfam"`
+	Trsvcid string `json:"trsvcid"`
+	Hostnqn string `json:"hostnqn,omitempty"`
 }
 
-// MrvlNvmSubsysGetCtrlrListResult represents a Marvell get subsystem controller list result
-type MrvlNvmSubsysGetCtrlrListResult struct {
-	Status      int `json:"status"`
-	CtrlrIDList []struct {
-		CtrlrID int `json:"ctrlr_id"`
-	} `json:"ctrlr_id_list"`
+// MrvlBdevNvmeStartDiscoveryResult represents a Marvell start NVMe discovery result
+type MrvlBdevNvmeStartDiscoveryResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmGetNsStatsParams represents the parameters to a Marvell get namespace status request
-type MrvlNvmGetNsStatsParams struct {
-	SubNqn       string `json:"subnqn"`
-	NsInstanceID int    `json:"ns_instance_id"`
+// MrvlBdevNvmeStopDiscoveryParams represents the parameters to a Marvell stop NVMe discovery request
+type MrvlBdevNvmeStopDiscoveryParams struct {
+	Name string `json:"name"`
 }
 
-// MrvlNvmGetNsStatsResult represents a Marvell get namespace status result
-type MrvlNvmGetNsStatsResult struct {
-	Status                int `json:"status"`
-	NumReadCmds           int `json:"num_read_cmds"`
-	NumReadBytes          int `json:"num_read_bytes"`
-	NumWriteCmds          int `json:"num_write_cmds"`
-	NumWriteBytes         int `json:"num_write_bytes"`
-	NumErrors             int `json:"num_errors"`
-	TotalReadLatencyInUs  int `json:"total_read_latency_in_us"`
-	TotalWriteLatencyInUs int `json:"total_write_latency_in_us"`
-	StatsTimeWindowInUs   int `json:"Stats_time_window_in_us"`
+// MrvlBdevNvmeStopDiscoveryResult represents a Marvell stop NVMe discovery result
+type MrvlBdevNvmeStopDiscoveryResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmNsGetCtrlrListParams represents the parameters to a Marvell get namespace controller list request
-type MrvlNvmNsGetCtrlrListParams struct {
-	SubNqn       string `json:"subnqn"`
-	NsInstanceID int    `json:"ns_instance_id"`
+// MrvlBdevNvmeGetDiscoveryLogParams represents the parameters to a Marvell get NVMe discovery log page request
+type MrvlBdevNvmeGetDiscoveryLogParams struct {
+	Name string `json:"name"`
 }
 
-// MrvlNvmNsGetCtrlrListResult represents a Marvell get namespace controller list result
-type MrvlNvmNsGetCtrlrListResult struct {
-	Status      int `json:"status"`
-	CtrlrIDList []struct {
-		CtrlrID int `json:"ctrlr_id"`
-	} `json:"ctrlr_id_list"`
+// MrvlBdevNvmeGetDiscoveryLogResult represents a Marvell get NVMe discovery log page result
+type MrvlBdevNvmeGetDiscoveryLogResult struct {
+	Status  Status `json:"status"`
+	Genctr  uint64 `json:"genctr"`
+	Entries []struct {
+		Trtype  string `json:"trtype"`
+		Adrfam  string `json:"adrfam"`
+		Subtype string `json:"subtype"`
+		Traddr  string `json:"traddr"`
+		Trsvcid string `json:"trsvcid"`
+		Subnqn  string `json:"subnqn"`
+	} `json:"entries"`
 }
 
-// MrvlNvmGetNsInfoParams represents the parameters to a Marvell get namespace info request
-type MrvlNvmGetNsInfoParams struct {
-	SubNqn       string `json:"subnqn"`
-	NsInstanceID int    `json:"ns_instance_id"`
+// MrvlBdevNvmeSetReconnectOptionsParams represents the parameters to a Marvell set remote NVMe reconnect options request
+type MrvlBdevNvmeSetReconnectOptionsParams struct {
+	Name                 string `json:"name"`
+	KeepAliveTimeoutMs   int    `json:"keep_alive_timeout_ms"`
+	ReconnectRetryCount  int    `json:"reconnect_retry_count"`
+	ReconnectDelaySec    int    `json:"reconnect_delay_sec"`
+	MaxReconnectDelaySec int    `json:"max_reconnect_delay_sec"`
+	FastIoFailTimeoutSec int    `json:"fast_io_fail_timeout_sec"`
 }
 
-// MrvlNvmGetNsInfoResult represents the a Marvell get namespace info result
-type MrvlNvmGetNsInfoResult struct {
-	Status      int    `json:"status"`
-	Nguid       string `json:"nguid"`
-	Eui64       string `json:"eui64"`
-	UUID        string `json:"uuid"`
-	Nmic        int    `json:"nmic"`
-	Bdev        string `json:"bdev"`
-	NumCtrlrs   int    `json:"num_ctrlrs"`
-	CtrlrIDList []struct {
-		CtrlrID int `json:"ctrlr_id"`
-	} `json:"ctrlr_id_list"`
+// MrvlBdevNvmeSetReconnectOptionsResult represents a Marvell set remote NVMe reconnect options result
+type MrvlBdevNvmeSetReconnectOptionsResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmCtrlrAttachNsParams represents the parameters to a Marvell controller attach namespace request
-type MrvlNvmCtrlrAttachNsParams struct {
-	Subnqn       string `json:"subnqn"`
-	CtrlrID      int    `json:"ctrlr_id"`
-	NsInstanceID int    `json:"ns_instance_id"`
+// MrvlKeyringAddKeyParams represents the parameters to a Marvell add keyring key request
+type MrvlKeyringAddKeyParams struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
 }
 
-// MrvlNvmCtrlrAttachNsResult represents a Marvell controller attach namespace result
-type MrvlNvmCtrlrAttachNsResult struct {
-	Status int `json:"status"`
+// MrvlKeyringAddKeyResult represents a Marvell add keyring key result
+type MrvlKeyringAddKeyResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmCtrlrDetachNsParams represents the parameters to a Marvell controller detach namespace request
-type MrvlNvmCtrlrDetachNsParams struct {
-	Subnqn       string `json:"subnqn"`
-	CtrlrID      int    `json:"ctrlr_id"`
-	NsInstanceID int    `json:"ns_instance_id"`
+// MrvlKeyringRemoveKeyParams represents the parameters to a Marvell remove keyring key request
+type MrvlKeyringRemoveKeyParams struct {
+	Name string `json:"name"`
 }
 
-// MrvlNvmCtrlrDetachNsResult represents a Marvell controller detach namespace result
-type MrvlNvmCtrlrDetachNsResult struct {
-	Status int `json:"status"`
+// MrvlKeyringRemoveKeyResult represents a Marvell remove keyring key result
+type MrvlKeyringRemoveKeyResult struct {
+	Status Status `json:"status"`
 }
 
-// MrvlNvmGetCtrlrInfoParams represents the parameters to a Marvell get controller info request
-type MrvlNvmGetCtrlrInfoParams struct {
-	Subnqn  string `json:"subnqn"`
-	CtrlrID int    `json:"ctrlr_id"`
-}
-
-// MrvlNvmGetCtrlrInfoResult represents a Marvell get controller info result
-type MrvlNvmGetCtrlrInfoResult struct {
-	Status        int    `json:"status"`
-	PcieDomainID  int    `json:"pcie_domain_id"`
-	PfID          int    `json:"pf_id"`
-	VfID          int    `json:"vf_id"`
-	CtrlrID       int    `json:"ctrlr_id"`
-	MaxNsq        int    `json:"max_nsq"`
-	MaxNcq        int    `json:"max_ncq"`
-	Mqes          int    `json:"mqes"`
-	IeeeOui       string `json:"ieee_oui"`
-	Cmic          int    `json:"cmic"`
-	Nn            int    `json:"nn"`
-	ActiveNsCount int    `json:"active_ns_count"`
-	ActiveNsq     int    `json:"active_nsq"`
-	ActiveNcq     int    `json:"active_ncq"`
-	Mdts          int    `json:"mdts"`
-	Sqes          int    `json:"sqes"`
-	Cqes          int    `json:"cqes"`
-}
-
-// MrvlNvmGetCtrlrStatsParams represents the parameters to a Marvell get controller status request
-type MrvlNvmGetCtrlrStatsParams struct {
-	Subnqn  string `json:"subnqn"`
-	CtrlrID int    `json:"ctrlr_id"`
-}
-
-// MrvlNvmGetCtrlrStatsResult represents a Marvell get controller status result
-type MrvlNvmGetCtrlrStatsResult struct {
-	Status                int `json:"status"`
-	NumAdminCmds          int `json:"num_admin_cmds"`
-	NumAdminCmdErrors     int `json:"num_admin_cmd_errors"`
-	NumAsyncEvents        int `json:"num_async_events"`
-	NumReadCmds           int `json:"num_read_cmds"`
-	NumReadBytes          int `json:"num_read_bytes"`
-	NumWriteCmds          int `json:"num_write_cmds"`
-	NumWriteBytes         int `json:"num_write_bytes"`
-	NumErrors             int `json:"num_errors"`
-	TotalReadLatencyInUs  int `json:"total_read_latency_in_us"`
-	TotalWriteLatencyInUs int `json:"total_write_latency_in_us"`
-	StatsTimeWindowInUs   int `json:"Stats_time_window_in_us"`
-}
-
-// MrvlNvmCtrlrGetNsStatsParams represents the parameters to a Marvell get namespace status request
-type MrvlNvmCtrlrGetNsStatsParams struct {
-	Subnqn       string `json:"subnqn"`
-	CtrlrID      int    `json:"ctrlr_id"`
-	NsInstanceID int    `json:"ns_instance_id"`
-}
-
-// MrvlNvmCtrlrGetNsStatsResult represents a Marvell get namespace status result
-type MrvlNvmCtrlrGetNsStatsResult struct {
-	Status                int `json:"status"`
-	NumReadCmds           int `json:"num_read_cmds"`
-	NumReadBytes          int `json:"num_read_bytes"`
-	NumWriteCmds          int `json:"num_write_cmds"`
-	NumWriteBytes         int `json:"num_write_bytes"`
-	NumErrors             int `json:"num_errors"`
-	TotalReadLatencyInUs  int `json:"total_read_latency_in_us"`
-	TotalWriteLatencyInUs int `json:"total_write_latency_in_us"`
-	StatsTimeWindowInUs   int `json:"stats_time_window_in_us"`
+// MrvlBdevAioCreateParams represents the parameters to a Marvell create AIO bdev request
+type MrvlBdevAioCreateParams struct {
+	Name      string `json:"name"`
+	Filename  string `json:"filename"`
+	BlockSize int64  `json:"block_size,omitempty"`
+}
+
+// MrvlBdevAioCreateResult represents a Marvell create AIO bdev result
+type MrvlBdevAioCreateResult struct {
+	Status    Status `json:"status"`
+	UUID      string `json:"uuid"`
+	BlockSize int64  `json:"block_size"`
+	NumBlocks int64  `json:"num_blocks"`
+}
+
+// MrvlBdevAioDeleteParams represents the parameters to a Marvell delete AIO bdev request
+type MrvlBdevAioDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevAioDeleteResult represents a Marvell delete AIO bdev result
+type MrvlBdevAioDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevGetIostatParams represents the parameters to a Marvell get bdev IO stats request
+type MrvlBdevGetIostatParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevGetIostatResult represents a Marvell get bdev IO stats result
+type MrvlBdevGetIostatResult struct {
+	Status                Status `json:"status"`
+	NumReadOps            int    `json:"num_read_ops"`
+	BytesRead             int    `json:"bytes_read"`
+	NumWriteOps           int    `json:"num_write_ops"`
+	BytesWritten          int    `json:"bytes_written"`
+	NumUnmapOps           int    `json:"num_unmap_ops"`
+	BytesUnmapped         int    `json:"bytes_unmapped"`
+	TotalReadLatencyInUs  int    `json:"total_read_latency_in_us"`
+	TotalWriteLatencyInUs int    `json:"total_write_latency_in_us"`
+	TotalUnmapLatencyInUs int    `json:"total_unmap_latency_in_us"`
+}
+
+// MrvlBdevNullCreateParams represents the parameters to a Marvell create null bdev request
+type MrvlBdevNullCreateParams struct {
+	Name      string `json:"name"`
+	BlockSize int64  `json:"block_size"`
+	NumBlocks int64  `json:"num_blocks"`
+	UUID      string `json:"uuid,omitempty"`
+}
+
+// MrvlBdevNullCreateResult represents a Marvell create null bdev result
+type MrvlBdevNullCreateResult struct {
+	Status Status `json:"status"`
+	UUID   string `json:"uuid"`
+}
+
+// MrvlBdevNullDeleteParams represents the parameters to a Marvell delete null bdev request
+type MrvlBdevNullDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevNullDeleteResult represents a Marvell delete null bdev result
+type MrvlBdevNullDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevMallocCreateParams represents the parameters to a Marvell create malloc bdev request
+type MrvlBdevMallocCreateParams struct {
+	Name      string `json:"name"`
+	BlockSize int64  `json:"block_size"`
+	NumBlocks int64  `json:"num_blocks"`
+	MdSize    int64  `json:"md_size,omitempty"`
+	UUID      string `json:"uuid,omitempty"`
+}
+
+// MrvlBdevMallocCreateResult represents a Marvell create malloc bdev result
+type MrvlBdevMallocCreateResult struct {
+	Status Status `json:"status"`
+	UUID   string `json:"uuid"`
+}
+
+// MrvlBdevMallocDeleteParams represents the parameters to a Marvell delete malloc bdev request
+type MrvlBdevMallocDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevMallocDeleteResult represents a Marvell delete malloc bdev result
+type MrvlBdevMallocDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlLvolCreateLvstoreParams represents the parameters to a Marvell create lvol store request
+type MrvlLvolCreateLvstoreParams struct {
+	BdevName  string `json:"bdev_name"`
+	LvsName   string `json:"lvs_name"`
+	ClusterSz int64  `json:"cluster_sz,omitempty"`
+}
+
+// MrvlLvolCreateLvstoreResult represents a Marvell create lvol store result
+type MrvlLvolCreateLvstoreResult struct {
+	Status            Status `json:"status"`
+	UUID              string `json:"uuid"`
+	ClusterSize       int64  `json:"cluster_size"`
+	TotalDataClusters int64  `json:"total_data_clusters"`
+}
+
+// MrvlLvolDeleteLvstoreParams represents the parameters to a Marvell delete lvol store request
+type MrvlLvolDeleteLvstoreParams struct {
+	LvsName string `json:"lvs_name"`
+}
+
+// MrvlLvolDeleteLvstoreResult represents a Marvell delete lvol store result
+type MrvlLvolDeleteLvstoreResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlLvolCreateParams represents the parameters to a Marvell create lvol request
+type MrvlLvolCreateParams struct {
+	LvsName       string `json:"lvs_name"`
+	LvolName      string `json:"lvol_name"`
+	Size          int64  `json:"size"`
+	ThinProvision bool   `json:"thin_provision"`
+}
+
+// MrvlLvolCreateResult represents a Marvell create lvol result
+type MrvlLvolCreateResult struct {
+	Status Status `json:"status"`
+	UUID   string `json:"uuid"`
+}
+
+// MrvlLvolDeleteParams represents the parameters to a Marvell delete lvol request
+type MrvlLvolDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlLvolDeleteResult represents a Marvell delete lvol result
+type MrvlLvolDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevRbdRegisterClusterParams represents the parameters to a Marvell register Ceph cluster request
+type MrvlBdevRbdRegisterClusterParams struct {
+	Name    string `json:"name"`
+	UserID  string `json:"user_id"`
+	MonHost string `json:"mon_host"`
+	KeyName string `json:"key_name"`
+}
+
+// MrvlBdevRbdRegisterClusterResult represents a Marvell register Ceph cluster result
+type MrvlBdevRbdRegisterClusterResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevRbdUnregisterClusterParams represents the parameters to a Marvell unregister Ceph cluster request
+type MrvlBdevRbdUnregisterClusterParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevRbdUnregisterClusterResult represents a Marvell unregister Ceph cluster result
+type MrvlBdevRbdUnregisterClusterResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevRbdCreateParams represents the parameters to a Marvell create RBD bdev request
+type MrvlBdevRbdCreateParams struct {
+	Name        string `json:"name"`
+	ClusterName string `json:"cluster_name"`
+	PoolName    string `json:"pool_name"`
+	RbdName     string `json:"rbd_name"`
+	BlockSize   int64  `json:"block_size,omitempty"`
+}
+
+// MrvlBdevRbdCreateResult represents a Marvell create RBD bdev result
+type MrvlBdevRbdCreateResult struct {
+	Status    Status `json:"status"`
+	UUID      string `json:"uuid"`
+	BlockSize int64  `json:"block_size"`
+	NumBlocks int64  `json:"num_blocks"`
+}
+
+// MrvlBdevRbdDeleteParams represents the parameters to a Marvell delete RBD bdev request
+type MrvlBdevRbdDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevRbdDeleteResult represents a Marvell delete RBD bdev result
+type MrvlBdevRbdDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevXnvmeCreateParams represents the parameters to a Marvell create xNVMe bdev request
+type MrvlBdevXnvmeCreateParams struct {
+	Name        string `json:"name"`
+	Filename    string `json:"filename"`
+	IoMechanism string `json:"io_mechanism"`
+	ConserveCPU bool   `json:"conserve_cpu"`
+}
+
+// MrvlBdevXnvmeCreateResult represents a Marvell create xNVMe bdev result
+type MrvlBdevXnvmeCreateResult struct {
+	Status    Status `json:"status"`
+	UUID      string `json:"uuid"`
+	BlockSize int64  `json:"block_size"`
+	NumBlocks int64  `json:"num_blocks"`
+}
+
+// MrvlBdevXnvmeDeleteParams represents the parameters to a Marvell delete xNVMe bdev request
+type MrvlBdevXnvmeDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevXnvmeDeleteResult represents a Marvell delete xNVMe bdev result
+type MrvlBdevXnvmeDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevIscsiCreateParams represents the parameters to a Marvell create iSCSI bdev request
+type MrvlBdevIscsiCreateParams struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	InitiatorIqn string `json:"initiator_iqn"`
+	ChapUser     string `json:"chap_user,omitempty"`
+	ChapKeyName  string `json:"chap_key_name,omitempty"`
+}
+
+// MrvlBdevIscsiCreateResult represents a Marvell create iSCSI bdev result
+type MrvlBdevIscsiCreateResult struct {
+	Status    Status `json:"status"`
+	UUID      string `json:"uuid"`
+	BlockSize int64  `json:"block_size"`
+	NumBlocks int64  `json:"num_blocks"`
+}
+
+// MrvlBdevIscsiDeleteParams represents the parameters to a Marvell delete iSCSI bdev request
+type MrvlBdevIscsiDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// MrvlBdevIscsiDeleteResult represents a Marvell delete iSCSI bdev result
+type MrvlBdevIscsiDeleteResult struct {
+	Status Status `json:"status"`
+}
+
+// MrvlBdevRescanParams represents the parameters to a Marvell rescan bdev request
+type MrvlBdevRescanParams struct {
+	Name string `json:"name,omitempty"`
+}
+
+// MrvlBdevRescanResult represents a Marvell rescan bdev result
+type MrvlBdevRescanResult struct {
+	Status  Status `json:"status"`
+	Resized []struct {
+		Name         string `json:"name"`
+		OldSizeBytes uint64 `json:"old_size_bytes"`
+		SizeBytes    uint64 `json:"size_bytes"`
+	} `json:"resized"`
+}
+
+// MrvlBdevNvmeSetQosLimitsParams represents the parameters to a Marvell set remote NVMe QoS limits request
+type MrvlBdevNvmeSetQosLimitsParams struct {
+	Name                   string `json:"name"`
+	RwIosPerSec            int64  `json:"rw_ios_per_sec"`
+	RMbytesPerSec          int64  `json:"r_mbytes_per_sec"`
+	WMbytesPerSec          int64  `json:"w_mbytes_per_sec"`
+	RwMbytesPerSec         int64  `json:"rw_mbytes_per_sec"`
+	BackgroundMbytesPerSec int64  `json:"background_mbytes_per_sec"`
+}
+
+// MrvlBdevNvmeSetQosLimitsResult represents a Marvell set remote NVMe QoS limits result
+type MrvlBdevNvmeSetQosLimitsResult struct {
+	Status Status `json:"status"`
 }
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package models holds definitions for SPDK json RPC structs
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// fuzzedModels are the structs decoded from the firmware in the provisioning path, the results,
+// and the parameters the emulated firmware decodes
+var fuzzedModels = map[string]func() interface{}{
+	"MrvlNvmCreateSubsystemResult":    func() interface{} { return &MrvlNvmCreateSubsystemResult{} },
+	"MrvlNvmGetSubsysListResult":      func() interface{} { return &MrvlNvmGetSubsysListResult{} },
+	"MrvlNvmGetSubsysInfoResult":      func() interface{} { return &MrvlNvmGetSubsysInfoResult{} },
+	"MrvlNvmSubsysAllocNsResult":      func() interface{} { return &MrvlNvmSubsysAllocNsResult{} },
+	"MrvlNvmSubsysGetNsListResult":    func() interface{} { return &MrvlNvmSubsysGetNsListResult{} },
+	"MrvlNvmSubsysCreateCtrlrResult":  func() interface{} { return &MrvlNvmSubsysCreateCtrlrResult{} },
+	"MrvlNvmSubsysGetCtrlrListResult": func() interface{} { return &MrvlNvmSubsysGetCtrlrListResult{} },
+	"MrvlNvmGetNsInfoResult":          func() interface{} { return &MrvlNvmGetNsInfoResult{} },
+	"MrvlNvmGetCtrlrInfoResult":       func() interface{} { return &MrvlNvmGetCtrlrInfoResult{} },
+	"MrvlNvmGetCtrlrStatsResult":      func() interface{} { return &MrvlNvmGetCtrlrStatsResult{} },
+	"MrvlNvmGetNsStatsResult":         func() interface{} { return &MrvlNvmGetNsStatsResult{} },
+	"MrvlNvmGetBulkStatsResult":       func() interface{} { return &MrvlNvmGetBulkStatsResult{} },
+	"MrvlNvmGetSkuCapsResult":         func() interface{} { return &MrvlNvmGetSkuCapsResult{} },
+	"MrvlNvmGetPcieFuncStatusResult":  func() interface{} { return &MrvlNvmGetPcieFuncStatusResult{} },
+	"MrvlNvmCtrlrSaveStateResult":     func() interface{} { return &MrvlNvmCtrlrSaveStateResult{} },
+	"MrvlPlatformGetInventoryResult":  func() interface{} { return &MrvlPlatformGetInventoryResult{} },
+	"MrvlBdevNvmeGetNsListResult":     func() interface{} { return &MrvlBdevNvmeGetNsListResult{} },
+	"MrvlBdevMallocCreateResult":      func() interface{} { return &MrvlBdevMallocCreateResult{} },
+	"MrvlNvmCreateSubsystemParams":    func() interface{} { return &MrvlNvmCreateSubsystemParams{} },
+	"MrvlNvmSubsysCreateCtrlrParams":  func() interface{} { return &MrvlNvmSubsysCreateCtrlrParams{} },
+	"MrvlNvmSubsysAllocNsParams":      func() interface{} { return &MrvlNvmSubsysAllocNsParams{} },
+	"MrvlNvmCtrlrAttachNsParams":      func() interface{} { return &MrvlNvmCtrlrAttachNsParams{} },
+}
+
+func FuzzModels_RoundTrip(f *testing.F) {
+	f.Add([]byte(`{"status": 0}`))
+	f.Add([]byte(`{"status": -22, "subsys_list": [{"subnqn": "nqn.2022-09.io.spdk:opi3"}]}`))
+	f.Add([]byte(`{"status": 0, "ns_list": [{"ns_instance_id": 1, "bdev": "Malloc0", "ctrlr_id_list": [{"ctrlr_id": 17}]}]}`))
+	f.Add([]byte(`{"status": "0", "ns_list": null, "ctrlr_id_list": {}}`))
+	f.Add([]byte(`{"subnqn": "nqn.2022-09.io.spdk:opi3", "pf_id": 1e3, "ctrlr_id": -1}`))
+	f.Add([]byte(`[]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for name, model := range fuzzedModels {
+			decoded := model()
+			if err := json.Unmarshal(data, decoded); err != nil {
+				continue
+			}
+			_ = ResultStatus(decoded)
+			// what was decoded encodes back to the same struct
+			encoded, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatal(name, "encode:", err)
+			}
+			again := model()
+			if err := json.Unmarshal(encoded, again); err != nil {
+				t.Fatal(name, "decode again:", err)
+			}
+			if !reflect.DeepEqual(decoded, again) {
+				t.Error(name, ": expected", decoded, "received", again)
+			}
+		}
+	})
+}
+
+func TestModels_ResultStatus(t *testing.T) {
+	var typed *MrvlNvmCreateSubsystemResult
+	var result interface{} = &MrvlNvmCreateSubsystemResult{Status: -22}
+	tests := map[string]struct {
+		result interface{}
+		status Status
+	}{
+		"status":           {&MrvlNvmCreateSubsystemResult{Status: -17}, -17},
+		"value":            {MrvlNvmCreateSubsystemResult{Status: -2}, -2},
+		"interface":        {&result, -22},
+		"nil":              {nil, 0},
+		"typed nil":        {typed, 0},
+		"without a status": {&struct{ Name string }{}, 0},
+		"not a status":     {&struct{ Status string }{"-1"}, 0},
+		"bool":             {new(bool), 0},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if status := ResultStatus(tt.result); status != tt.status {
+				t.Error("status: expected", tt.status, "received", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package models holds definitions for SPDK json RPC structs
+package models
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatusDomain is the domain of the google.rpc.ErrorInfo details of the statuses of the firmware
+const StatusDomain = "marvell.com"
+
+// Status is the status the Marvell methods report their failures in, a negated errno
+type Status int
+
+const (
+	// StatusSuccess is the status of the methods that succeeded
+	StatusSuccess Status = 0
+	// StatusNotPermitted is the status of the operations the state of the resource forbids
+	StatusNotPermitted Status = -1
+	// StatusNotFound is the status of the operations on a resource that doesn't exist
+	StatusNotFound Status = -2
+	// StatusIOError is the status of the operations the hardware failed
+	StatusIOError Status = -5
+	// StatusNoMemory is the status of the operations the firmware ran out of memory for
+	StatusNoMemory Status = -12
+	// StatusBusy is the status of the operations on a resource in use
+	StatusBusy Status = -16
+	// StatusAlreadyExists is the status of the creations of a resource that already exists
+	StatusAlreadyExists Status = -17
+	// StatusNoDevice is the status of the operations on a PCIe function or a bdev that is missing
+	StatusNoDevice Status = -19
+	// StatusInvalid is the status of the operations with invalid parameters
+	StatusInvalid Status = -22
+	// StatusNoSpace is the status of the operations exceeding the capacity of the firmware
+	StatusNoSpace Status = -28
+	// StatusNotSupported is the status of the operations the firmware doesn't support
+	StatusNotSupported Status = -95
+	// StatusTimedOut is the status of the operations the hardware didn't complete in time
+	StatusTimedOut Status = -110
+)
+
+// statusMeanings are the names and the meanings of the statuses the firmware reports
+var statusMeanings = map[Status]struct {
+	name    string
+	meaning string
+}{
+	StatusSuccess:       {"SUCCESS", "succeeded"},
+	StatusNotPermitted:  {"NOT_PERMITTED", "not permitted in the current state"},
+	StatusNotFound:      {"NOT_FOUND", "not found"},
+	StatusIOError:       {"IO_ERROR", "input/output error"},
+	StatusNoMemory:      {"NO_MEMORY", "out of memory"},
+	StatusBusy:          {"BUSY", "in use"},
+	StatusAlreadyExists: {"ALREADY_EXISTS", "already exists"},
+	StatusNoDevice:      {"NO_DEVICE", "no such device"},
+	StatusInvalid:       {"INVALID", "invalid parameters"},
+	StatusNoSpace:       {"NO_SPACE", "no space left, a limit of the firmware is reached"},
+	StatusNotSupported:  {"NOT_SUPPORTED", "not supported by the firmware"},
+	StatusTimedOut:      {"TIMED_OUT", "timed out"},
+}
+
+// statusResources are the resources the methods act on, by the words of the method names, the
+// first word of a method naming one tells which
+var statusResources = map[string]string{
+	"subsys":    "SUBSYS",
+	"subsystem": "SUBSYS",
+	"ctrlr":     "CTRLR",
+	"ns":        "NS",
+	"bdev":      "BDEV",
+	"fw":        "FW",
+	"opal":      "OPAL",
+	"pcie":      "PCIE_FUNC",
+}
+
+// String returns the name of the status, i.e. NOT_FOUND, STATUS(-7) when it is unknown
+func (s Status) String() string {
+	if meaning, ok := statusMeanings[s]; ok {
+		return meaning.name
+	}
+	return fmt.Sprintf("STATUS(%d)", int(s))
+}
+
+// Meaning returns what the status means, i.e. not found
+func (s Status) Meaning() string {
+	if meaning, ok := statusMeanings[s]; ok {
+		return meaning.meaning
+	}
+	return fmt.Sprintf("unknown status %d", int(s))
+}
+
+// Name returns the name of the status prefixed with the resource method acts on, i.e.
+// SUBSYS_NOT_FOUND for mrvl_nvm_subsys_alloc_ns, unprefixed for the success and the unknown
+// statuses
+func (s Status) Name(method string) string {
+	if _, ok := statusMeanings[s]; !ok || s == StatusSuccess {
+		return s.String()
+	}
+	for _, word := range strings.Split(method, "_") {
+		if resource, ok := statusResources[word]; ok {
+			return resource + "_" + s.String()
+		}
+	}
+	return s.String()
+}
+
+// LogValue logs the name of the status, not its number
+func (s Status) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// Err returns a gRPC error of code with msg, carrying the status of method as google.rpc.ErrorInfo
+// details, its name as reason, i.e. SUBSYS_NOT_FOUND, so the clients know why the firmware failed
+func (s Status) Err(code codes.Code, msg string, method string) error {
+	st, err := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason: s.Name(method),
+		Domain: StatusDomain,
+		Metadata: map[string]string{
+			"method":  method,
+			"status":  strconv.Itoa(int(s)),
+			"meaning": s.Meaning(),
+		},
+	})
+	if err != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// ErrorInfo returns the google.rpc.ErrorInfo details of the status of the firmware err carries,
+// nil for the other errors
+func ErrorInfo(err error) *errdetails.ErrorInfo {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == StatusDomain {
+			return info
+		}
+	}
+	return nil
+}
+
+// ResultStatus returns the status the Marvell methods report their failures in, 0 when the
+// result has none
+func ResultStatus(result interface{}) Status {
+	value := reflect.ValueOf(result)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return 0
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return 0
+	}
+	field := value.FieldByName("Status")
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Status(field.Int())
+	default:
+		return 0
+	}
+}
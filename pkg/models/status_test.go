@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package models holds definitions for SPDK json RPC structs
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestModels_StatusName(t *testing.T) {
+	tests := map[string]struct {
+		status  Status
+		method  string
+		name    string
+		meaning string
+	}{
+		"subsystem not found": {StatusNotFound, "mrvl_nvm_subsys_alloc_ns", "SUBSYS_NOT_FOUND", "not found"},
+		"subsystem exists":    {StatusAlreadyExists, "mrvl_nvm_create_subsystem", "SUBSYS_ALREADY_EXISTS", "already exists"},
+		"controller busy":     {StatusBusy, "mrvl_nvm_ctrlr_detach_ns", "CTRLR_BUSY", "in use"},
+		"namespace not found": {StatusNotFound, "mrvl_nvm_get_ns_stats", "NS_NOT_FOUND", "not found"},
+		"without resource":    {StatusInvalid, "mrvl_nvm_get_sku_caps", "INVALID", "invalid parameters"},
+		"success":             {StatusSuccess, "mrvl_nvm_create_subsystem", "SUCCESS", "succeeded"},
+		"unknown status":      {-7, "mrvl_nvm_create_subsystem", "STATUS(-7)", "unknown status -7"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if received := tt.status.Name(tt.method); received != tt.name {
+				t.Error("name: expected", tt.name, "received", received)
+			}
+			if received := tt.status.Meaning(); received != tt.meaning {
+				t.Error("meaning: expected", tt.meaning, "received", received)
+			}
+		})
+	}
+}
+
+func TestModels_StatusJSON(t *testing.T) {
+	// the status stays a number on the wire
+	var result MrvlNvmCreateSubsystemResult
+	if err := json.Unmarshal([]byte(`{"status": -17}`), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != StatusAlreadyExists {
+		t.Error("status: expected", StatusAlreadyExists, "received", result.Status)
+	}
+	data, err := json.Marshal(&result)
+	if err != nil || string(data) != `{"status":-17}` {
+		t.Error("encoded: expected", `{"status":-17}`, "received", string(data), err)
+	}
+}
+
+func TestModels_StatusErr(t *testing.T) {
+	err := StatusNotFound.Err(codes.InvalidArgument, "Could not create NS: ns0", "mrvl_nvm_subsys_alloc_ns")
+	er, _ := status.FromError(err)
+	if er.Code() != codes.InvalidArgument || er.Message() != "Could not create NS: ns0" {
+		t.Error("error: expected", "Could not create NS: ns0", "received", err)
+	}
+	info := ErrorInfo(err)
+	if info == nil {
+		t.Fatal("expected error info details, received none")
+	}
+	if info.Reason != "SUBSYS_NOT_FOUND" || info.Domain != StatusDomain {
+		t.Error("reason: expected", "SUBSYS_NOT_FOUND", "received", info.Reason, info.Domain)
+	}
+	if info.Metadata["method"] != "mrvl_nvm_subsys_alloc_ns" || info.Metadata["status"] != "-2" {
+		t.Error("metadata: expected the method and the status, received", info.Metadata)
+	}
+	if info := ErrorInfo(errors.New("EOF")); info != nil {
+		t.Error("expected no error info, received", info)
+	}
+	if info := ErrorInfo(status.Error(codes.NotFound, "unable to find key")); info != nil {
+		t.Error("expected no error info, received", info)
+	}
+}
@@ -0,0 +1,434 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package oidc authenticates the callers of the bridge with the bearer tokens of an OpenID
+// Connect issuer, the claims of the verified tokens are passed down in the context
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	// the hashes of the signing algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the gRPC metadata carrying the bearer token, the HTTP gateway forwards the
+// Authorization header in it
+const MetadataKey = "authorization"
+
+// HeaderKey is the HTTP header carrying the bearer token
+const HeaderKey = "Authorization"
+
+// bearerPrefix precedes the token in the Authorization header
+const bearerPrefix = "Bearer "
+
+// clockSkew is the difference tolerated between the clocks of the issuer and of the bridge
+const clockSkew = time.Minute
+
+// keysRefreshInterval bounds how often the keys of the issuer are fetched when a token is
+// signed with an unknown key, i.e. after a key rotation
+const keysRefreshInterval = time.Minute
+
+// Token represents a verified token
+type Token struct {
+	// Identity is the value of the identity claim of the token, sub by default
+	Identity string
+	// Claims are all the claims of the token
+	Claims map[string]interface{}
+}
+
+// tokenKey is the context key of the verified token
+type tokenKey struct{}
+
+// NewContext returns a context carrying a verified token
+func NewContext(ctx context.Context, token *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// FromContext returns the verified token of a request, if any
+func FromContext(ctx context.Context) (*Token, bool) {
+	token, ok := ctx.Value(tokenKey{}).(*Token)
+	return token, ok
+}
+
+// Verifier verifies the tokens signed by an issuer for an audience
+type Verifier struct {
+	issuer        string
+	audience      string
+	identityClaim string
+	client        *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewVerifier creates a verifier of the tokens of issuer for audience, the keys of the issuer
+// are discovered from its OpenID configuration on the first token
+func NewVerifier(issuer string, audience string, identityClaim string, client *http.Client) *Verifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Verifier{
+		issuer:        strings.TrimSuffix(issuer, "/"),
+		audience:      audience,
+		identityClaim: identityClaim,
+		client:        client,
+	}
+}
+
+// header represents the JOSE header of a token
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature, the issuer, the audience and the validity period of a token
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %v", err)
+	}
+	key, err := v.key(ctx, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+	claims := make(map[string]interface{})
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	identity, _ := claims[v.identityClaim].(string)
+	if identity == "" {
+		return nil, fmt.Errorf("token has no %s claim", v.identityClaim)
+	}
+	return &Token{Identity: identity, Claims: claims}, nil
+}
+
+// VerifyHeader verifies the bearer token of an Authorization header
+func (v *Verifier) VerifyHeader(ctx context.Context, value string) (*Token, error) {
+	if !strings.HasPrefix(value, bearerPrefix) {
+		msg := "Missing bearer token, set the Authorization header"
+		return nil, status.Errorf(codes.Unauthenticated, msg)
+	}
+	token, err := v.Verify(ctx, strings.TrimPrefix(value, bearerPrefix))
+	if err != nil {
+		msg := fmt.Sprintf("Invalid bearer token: %v", err)
+		return nil, status.Errorf(codes.Unauthenticated, msg)
+	}
+	return token, nil
+}
+
+// checkClaims checks the registered claims of a token at a time
+func (v *Verifier) checkClaims(claims map[string]interface{}, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != v.issuer {
+		return fmt.Errorf("token issued by %q, expected %q", iss, v.issuer)
+	}
+	if !hasAudience(claims["aud"], v.audience) {
+		return fmt.Errorf("token is not for audience %s", v.audience)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no expiration")
+	}
+	if now.Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token is not valid yet")
+	}
+	return nil
+}
+
+// hasAudience tells whether the aud claim, a string or an array of strings, has an audience
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor refuses the gRPC calls without a valid bearer token and passes the
+// verified token down in the context
+func (v *Verifier) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := v.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor refuses the gRPC streams without a valid bearer token and passes the
+// verified token down in the context of the stream
+func (v *Verifier) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := v.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate verifies the bearer token of the metadata of a call
+func (v *Verifier) authenticate(ctx context.Context) (context.Context, error) {
+	var value string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(MetadataKey); len(values) != 0 {
+			value = values[0]
+		}
+	}
+	token, err := v.VerifyHeader(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	return NewContext(ctx, token), nil
+}
+
+// authenticatedStream is a server stream with the verified token in its context
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// key returns the key of the issuer with an ID, the only one when the token has no key ID
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.lookup(kid)
+	if ok || time.Since(v.fetched) < keysRefreshInterval {
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the keys of %s: %v", v.issuer, err)
+	}
+	v.keys, v.fetched = keys, time.Now()
+	if key, ok = v.lookup(kid); !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// fetchKeys discovers the JWKS of the issuer and fetches its signing keys
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var configuration struct {
+		Issuer  string `json:"issuer"`
+		JwksURI string `json:"jwks_uri"`
+	}
+	if err := v.get(ctx, v.issuer+"/.well-known/openid-configuration", &configuration); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(configuration.Issuer, "/") != v.issuer {
+		return nil, fmt.Errorf("configuration is of issuer %q", configuration.Issuer)
+	}
+	var jwks struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err := v.get(ctx, configuration.JwksURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// the keys of other types are skipped, the tokens they sign are refused
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (v *Verifier) get(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonWebKey represents a public key of a JWKS, RSA or EC
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+// verifySignature checks the signature of a token with the key of the issuer, the algorithm
+// has to match the type of the key, none and the symmetric algorithms are refused
+func verifySignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	family := alg[:2]
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch family {
+		case "RS":
+			return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+		case "PS":
+			return rsa.VerifyPSS(key, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		if family != "ES" {
+			break
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("signing algorithm %q does not match the key", alg)
+}
+
+// hashes are the hashes of the signing algorithms by their suffix, i.e. 256 for RS256
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
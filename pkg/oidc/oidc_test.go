@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package oidc authenticates the callers of the bridge with the bearer tokens of an OpenID
+// Connect issuer, the claims of the verified tokens are passed down in the context
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testAudience = "opi-marvell-bridge"
+
+// testIssuer serves the OpenID configuration and the keys of an issuer
+type testIssuer struct {
+	server   *httptest.Server
+	rsaKey   *rsa.PrivateKey
+	ecKey    *ecdsa.PrivateKey
+	requests int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		issuer.requests++
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.server.URL, "jwks_uri": issuer.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+			{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		}})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// sign returns a token of claims signed with the key of kid, RS256 or ES256
+func (i *testIssuer) sign(t *testing.T, alg string, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (i *testIssuer) claims(changes map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss": i.server.URL,
+		"aud": testAudience,
+		"sub": "provisioner",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+	}
+	return claims
+}
+
+// tamper returns a token with the claims of other and the signature of token
+func tamper(token string, other string) string {
+	parts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+	return parts[0] + "." + otherParts[1] + "." + parts[2]
+}
+
+func TestOIDC_Verify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := NewVerifier(issuer.server.URL+"/", testAudience, "sub", issuer.server.Client())
+	tests := map[string]struct {
+		token    string
+		identity string
+		errMsg   string
+	}{
+		"RSA signed": {
+			token:    issuer.sign(t, "RS256", "rsa", issuer.claims(nil)),
+			identity: "provisioner",
+		},
+		"EC signed": {
+			token:    issuer.sign(t, "ES256", "ec", issuer.claims(map[string]interface{}{"aud": []string{"other", testAudience}})),
+			identity: "provisioner",
+		},
+		"expired": {
+			token:  issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"exp": time.Now().Add(-2 * clockSkew).Unix()})),
+			errMsg: "token is expired",
+		},
+		"expired within the clock skew": {
+			token:    issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"exp": time.Now().Add(-clockSkew / 2).Unix()})),
+			identity: "provisioner",
+		},
+		"not valid yet": {
+			token:  issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"nbf": time.Now().Add(2 * clockSkew).Unix()})),
+			errMsg: "token is not valid yet",
+		},
+		"without expiration": {
+			token:  issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"exp": nil})),
+			errMsg: "token has no expiration",
+		},
+		"other audience": {
+			token:  issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"aud": "other"})),
+			errMsg: "token is not for audience " + testAudience,
+		},
+		"other issuer": {
+			token:  issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"iss": "https://evil.example.org"})),
+			errMsg: `token issued by "https://evil.example.org", expected "` + issuer.server.URL + `"`,
+		},
+		"without identity": {
+			token:  issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"sub": nil})),
+			errMsg: "token has no sub claim",
+		},
+		"unknown key": {
+			token:  issuer.sign(t, "RS256", "other", issuer.claims(nil)),
+			errMsg: `unknown signing key "other"`,
+		},
+		"symmetric key": {
+			token:  issuer.sign(t, "RS256", "hmac", issuer.claims(nil)),
+			errMsg: `unknown signing key "hmac"`,
+		},
+		"algorithm not matching the key": {
+			token:  issuer.sign(t, "ES256", "rsa", issuer.claims(nil)),
+			errMsg: `signing algorithm "ES256" does not match the key`,
+		},
+		"unsigned": {
+			token:  issuer.sign(t, "none", "rsa", issuer.claims(nil)),
+			errMsg: `unsupported signing algorithm "none"`,
+		},
+		"tampered": {
+			token:  tamper(issuer.sign(t, "ES256", "ec", issuer.claims(nil)), issuer.sign(t, "ES256", "ec", issuer.claims(map[string]interface{}{"sub": "admin"}))),
+			errMsg: "invalid token signature",
+		},
+		"malformed": {
+			token:  "not-a-token",
+			errMsg: "malformed token",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			token, err := verifier.Verify(context.Background(), tt.token)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", errMsg)
+			}
+			identity := ""
+			if token != nil {
+				identity = token.Identity
+			}
+			if identity != tt.identity {
+				t.Error("identity: expected", tt.identity, "received", identity)
+			}
+		})
+	}
+
+	// the keys are fetched again at most every keysRefreshInterval
+	if issuer.requests != 1 {
+		t.Error("configuration requests: expected 1, received", issuer.requests)
+	}
+}
+
+func TestOIDC_UnaryServerInterceptor(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := NewVerifier(issuer.server.URL, testAudience, "email", issuer.server.Client())
+	token := issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"email": "ops@example.org"}))
+	interceptor := verifier.UnaryServerInterceptor()
+	tests := map[string]struct {
+		md       metadata.MD
+		identity string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"valid token": {
+			md:       metadata.Pairs(MetadataKey, "Bearer "+token),
+			identity: "ops@example.org",
+			errCode:  codes.OK,
+		},
+		"without token": {
+			md:      metadata.Pairs("opi-identity", "admin"),
+			errCode: codes.Unauthenticated,
+			errMsg:  "Missing bearer token, set the Authorization header",
+		},
+		"invalid token": {
+			md:      metadata.Pairs(MetadataKey, "Bearer "+token[:len(token)-4]),
+			errCode: codes.Unauthenticated,
+			errMsg:  "Invalid bearer token: crypto/rsa: verification error",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			identity := ""
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if token, ok := FromContext(ctx); ok {
+					identity = token.Identity
+				}
+				return req, nil
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"}, handler)
+			if identity != tt.identity {
+				t.Error("identity: expected", tt.identity, "received", identity)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
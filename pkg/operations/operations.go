@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package operations implements the long-running operations of the bridge, see https://google.aip.dev/151
+package operations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"go.einride.tech/aip/resourceid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// operationsPrefix is the collection all long-running operations belong to
+const operationsPrefix = "//storage.opiproject.org/operations/"
+
+// Error represents the error result of a failed long-running operation
+type Error struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+// Operation represents a long-running operation
+type Operation struct {
+	// Name of the operation
+	Name string `json:"name"`
+	// Metadata describes the operation, i.e. the resource it applies to
+	Metadata interface{} `json:"metadata,omitempty"`
+	// ProgressPercent is the completion of the operation as reported by its work, 100 once done
+	ProgressPercent int32 `json:"progressPercent,omitempty"`
+	// BytesDone is the amount of data processed so far as reported by its work, i.e. copied
+	BytesDone uint64 `json:"bytesDone,omitempty"`
+	// TotalBytes is the amount of data processed by the operation, 0 when its work doesn't tell
+	TotalBytes uint64 `json:"totalBytes,omitempty"`
+	// Done is true once the operation has completed, either with an error or a response
+	Done bool `json:"done"`
+	// Error is set if the operation failed
+	Error *Error `json:"error,omitempty"`
+	// Response is set if the operation succeeded
+	Response interface{} `json:"response,omitempty"`
+	// abort stops the work of a cancellable operation, nil when it can't be cancelled
+	abort AbortFunc
+}
+
+// Func is the work executed by a long-running operation
+type Func func(ctx context.Context) (interface{}, error)
+
+// AbortFunc asks the firmware to stop the work of a cancellable operation, the work then ends
+// with a CANCELLED error describing the state the resources were left in
+type AbortFunc func(ctx context.Context) error
+
+// progressKey is the context key of the operation a Func is running for
+type progressKey struct{}
+
+// ReportProgress records the completion percentage of the operation running the calling Func,
+// it does nothing when ctx does not belong to an operation
+func ReportProgress(ctx context.Context, percent int32) {
+	reportUpdate(ctx, func(op *Operation) {
+		op.ProgressPercent = percent
+	})
+}
+
+// ReportBytes records the amount of data processed by the operation running the calling Func,
+// out of total, i.e. as polled from the firmware. It does nothing when total is 0, the work
+// not telling, or when ctx does not belong to an operation
+func ReportBytes(ctx context.Context, done uint64, total uint64) {
+	if total == 0 {
+		return
+	}
+	reportUpdate(ctx, func(op *Operation) {
+		op.BytesDone = done
+		op.TotalBytes = total
+	})
+}
+
+// reportUpdate applies update to the operation running the calling Func
+func reportUpdate(ctx context.Context, update func(op *Operation)) {
+	report, ok := ctx.Value(progressKey{}).(func(func(*Operation)))
+	if !ok {
+		return
+	}
+	report(update)
+}
+
+// Manager keeps track of the long-running operations
+type Manager struct {
+	mu         sync.Mutex
+	operations map[string]*Operation
+	wg         sync.WaitGroup
+}
+
+// NewManager creates initialized instance of long-running operations manager
+func NewManager() *Manager {
+	return &Manager{
+		operations: make(map[string]*Operation),
+	}
+}
+
+// Start runs fn in the background and returns the operation tracking it
+func (m *Manager) Start(metadata interface{}, fn Func) *Operation {
+	return m.start(metadata, fn, nil)
+}
+
+// StartCancellable runs fn in the background and returns the operation tracking it, which
+// CancelOperation cancels by calling abort
+func (m *Manager) StartCancellable(metadata interface{}, fn Func, abort AbortFunc) *Operation {
+	return m.start(metadata, fn, abort)
+}
+
+func (m *Manager) start(metadata interface{}, fn Func, abort AbortFunc) *Operation {
+	op := &Operation{
+		Name:     operationsPrefix + resourceid.NewSystemGenerated(),
+		Metadata: metadata,
+		abort:    abort,
+	}
+	m.mu.Lock()
+	m.operations[op.Name] = op
+	response := *op
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		// operations outlive the request which started them
+		ctx := context.WithValue(context.Background(), progressKey{}, func(update func(*Operation)) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			update(op)
+		})
+		result, err := fn(ctx)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		op.Done = true
+		op.abort = nil
+		if err != nil {
+			st := status.Convert(err)
+			if st.Code() == codes.Canceled {
+				slog.Info("Operation cancelled", "name", op.Name, "error", err)
+			} else {
+				slog.Warn("Operation failed", "name", op.Name, "error", err)
+			}
+			op.Error = &Error{Code: st.Code(), Message: st.Message()}
+			return
+		}
+		op.ProgressPercent = 100
+		op.BytesDone = op.TotalBytes
+		op.Response = result
+	}()
+	return &response
+}
+
+// Wait blocks until all started operations are done
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Export returns the operations, i.e. to hand them over to the next bridge on upgrade, it fails
+// while one is running since its work can't be handed over
+func (m *Manager) Export() ([]*Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	operations := make([]*Operation, 0, len(m.operations))
+	for _, op := range m.operations {
+		if !op.Done {
+			return nil, fmt.Errorf("operation %s is running", op.Name)
+		}
+		response := *op
+		operations = append(operations, &response)
+	}
+	sort.Slice(operations, func(i int, j int) bool {
+		return operations[i].Name < operations[j].Name
+	})
+	return operations, nil
+}
+
+// Import adds the operations exported by another manager, they are done
+func (m *Manager) Import(operations []*Operation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, op := range operations {
+		imported := *op
+		m.operations[op.Name] = &imported
+	}
+}
+
+// GetOperationRequest represents a request to get a long-running operation
+type GetOperationRequest struct {
+	// Name of the operation
+	Name string `json:"name"`
+}
+
+// GetOperation gets the latest state of a long-running operation
+func (m *Manager) GetOperation(_ context.Context, in *GetOperationRequest) (*Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	op, ok := m.operations[in.Name]
+	if !ok {
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	response := *op
+	return &response, nil
+}
+
+// CancelOperationRequest represents a request to cancel a long-running operation
+type CancelOperationRequest struct {
+	// Name of the operation
+	Name string `json:"name"`
+}
+
+// CancelOperation asks the work of a running long-running operation to stop, the operation is
+// done with a CANCELLED error once it stopped, or with its response if it completed first
+func (m *Manager) CancelOperation(ctx context.Context, in *CancelOperationRequest) (*Operation, error) {
+	m.mu.Lock()
+	op, ok := m.operations[in.Name]
+	if !ok {
+		m.mu.Unlock()
+		err := status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+		return nil, err
+	}
+	if op.Done {
+		m.mu.Unlock()
+		msg := fmt.Sprintf("Operation %s is already done", in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	abort := op.abort
+	m.mu.Unlock()
+	if abort == nil {
+		msg := fmt.Sprintf("Operation %s can't be cancelled", in.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	// the firmware is called without holding the lock, the work keeps reporting its progress
+	if err := abort(ctx); err != nil {
+		return nil, err
+	}
+	slog.Info("Operation cancellation requested", "name", in.Name)
+	return m.GetOperation(ctx, &GetOperationRequest{Name: in.Name})
+}
+
+// ListOperationsRequest represents a request to list long-running operations
+type ListOperationsRequest struct{}
+
+// ListOperationsResponse represents the list of long-running operations
+type ListOperationsResponse struct {
+	Operations []*Operation `json:"operations"`
+}
+
+// ListOperations lists long-running operations
+func (m *Manager) ListOperations(_ context.Context, _ *ListOperationsRequest) (*ListOperationsResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	Blobarray := make([]*Operation, 0, len(m.operations))
+	for _, op := range m.operations {
+		response := *op
+		Blobarray = append(Blobarray, &response)
+	}
+	sort.Slice(Blobarray, func(i int, j int) bool {
+		return Blobarray[i].Name < Blobarray[j].Name
+	})
+	return &ListOperationsResponse{Operations: Blobarray}, nil
+}
@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package operations implements the long-running operations of the bridge, see https://google.aip.dev/151
+package operations
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOperations_Start(t *testing.T) {
+	tests := map[string]struct {
+		fn       Func
+		response interface{}
+		err      *Error
+	}{
+		"successful operation": {
+			fn:       func(context.Context) (interface{}, error) { return "done", nil },
+			response: "done",
+			err:      nil,
+		},
+		"failed operation with status": {
+			fn: func(context.Context) (interface{}, error) {
+				return nil, status.Error(codes.InvalidArgument, "bad slot")
+			},
+			response: nil,
+			err:      &Error{Code: codes.InvalidArgument, Message: "bad slot"},
+		},
+		"failed operation with plain error": {
+			fn:       func(context.Context) (interface{}, error) { return nil, errors.New("myopierr") },
+			response: nil,
+			err:      &Error{Code: codes.Unknown, Message: "myopierr"},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager()
+			op := m.Start("metadata", tt.fn)
+			if !strings.HasPrefix(op.Name, operationsPrefix) {
+				t.Error("name: expected prefix", operationsPrefix, "received", op.Name)
+			}
+			m.Wait()
+
+			response, err := m.GetOperation(context.Background(), &GetOperationRequest{Name: op.Name})
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !response.Done {
+				t.Error("expected operation to be done")
+			}
+			if tt.err == nil && response.ProgressPercent != 100 {
+				t.Error("progress: expected", 100, "received", response.ProgressPercent)
+			}
+			if response.Metadata != "metadata" {
+				t.Error("metadata: expected", "metadata", "received", response.Metadata)
+			}
+			if response.Response != tt.response {
+				t.Error("response: expected", tt.response, "received", response.Response)
+			}
+			if (response.Error == nil) != (tt.err == nil) || (tt.err != nil && *response.Error != *tt.err) {
+				t.Error("error: expected", tt.err, "received", response.Error)
+			}
+		})
+	}
+}
+
+func TestOperations_GetOperation(t *testing.T) {
+	m := NewManager()
+	_, err := m.GetOperation(context.Background(), &GetOperationRequest{Name: "unknown-id"})
+	if er, _ := status.FromError(err); er.Code() != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", er.Code())
+	}
+}
+
+func TestOperations_ListOperations(t *testing.T) {
+	m := NewManager()
+	for i := 0; i < 3; i++ {
+		m.Start(nil, func(context.Context) (interface{}, error) { return nil, nil })
+	}
+	m.Wait()
+
+	response, err := m.ListOperations(context.Background(), &ListOperationsRequest{})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(response.Operations) != 3 {
+		t.Error("operations: expected", 3, "received", len(response.Operations))
+	}
+	for i := 1; i < len(response.Operations); i++ {
+		if response.Operations[i-1].Name > response.Operations[i].Name {
+			t.Error("expected operations sorted by name")
+		}
+	}
+}
+
+func TestOperations_ReportProgress(t *testing.T) {
+	m := NewManager()
+	reported := make(chan struct{})
+	resume := make(chan struct{})
+	op := m.Start(nil, func(ctx context.Context) (interface{}, error) {
+		ReportProgress(ctx, 42)
+		close(reported)
+		<-resume
+		return nil, errors.New("myopierr")
+	})
+	<-reported
+
+	response, err := m.GetOperation(context.Background(), &GetOperationRequest{Name: op.Name})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if response.Done || response.ProgressPercent != 42 {
+		t.Error("progress: expected", 42, "received", response.ProgressPercent, "done", response.Done)
+	}
+	close(resume)
+	m.Wait()
+
+	// a failed operation keeps the last reported progress
+	response, _ = m.GetOperation(context.Background(), &GetOperationRequest{Name: op.Name})
+	if !response.Done || response.ProgressPercent != 42 {
+		t.Error("progress: expected", 42, "received", response.ProgressPercent, "done", response.Done)
+	}
+
+	// outside of an operation reporting is a no-op
+	ReportProgress(context.Background(), 50)
+	ReportBytes(context.Background(), 512, 1024)
+}
+
+func TestOperations_ReportBytes(t *testing.T) {
+	m := NewManager()
+	reported := make(chan struct{})
+	resume := make(chan struct{})
+	op := m.Start(nil, func(ctx context.Context) (interface{}, error) {
+		ReportProgress(ctx, 25)
+		ReportBytes(ctx, 256, 1024)
+		// the work doesn't know the total, the bytes are kept
+		ReportBytes(ctx, 0, 0)
+		close(reported)
+		<-resume
+		return "done", nil
+	})
+	<-reported
+
+	response, err := m.GetOperation(context.Background(), &GetOperationRequest{Name: op.Name})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if response.ProgressPercent != 25 || response.BytesDone != 256 || response.TotalBytes != 1024 {
+		t.Error("progress: expected", 25, 256, 1024, "received", response.ProgressPercent, response.BytesDone, response.TotalBytes)
+	}
+	close(resume)
+	m.Wait()
+
+	// a done operation processed all the bytes
+	response, _ = m.GetOperation(context.Background(), &GetOperationRequest{Name: op.Name})
+	if response.ProgressPercent != 100 || response.BytesDone != 1024 || response.TotalBytes != 1024 {
+		t.Error("progress: expected", 100, 1024, 1024, "received", response.ProgressPercent, response.BytesDone, response.TotalBytes)
+	}
+}
+
+func TestOperations_Export(t *testing.T) {
+	m := NewManager()
+	resume := make(chan struct{})
+	op := m.Start("metadata", func(context.Context) (interface{}, error) {
+		<-resume
+		return "done", nil
+	})
+	if _, err := m.Export(); err == nil || err.Error() != "operation "+op.Name+" is running" {
+		t.Error("running operation: expected an error, received", err)
+	}
+	close(resume)
+	m.Wait()
+
+	operations, err := m.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := NewManager()
+	imported.Import(operations)
+	response, err := imported.GetOperation(context.Background(), &GetOperationRequest{Name: op.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Done || response.Response != "done" || response.Metadata != "metadata" {
+		t.Error("imported operation: expected done, received", response)
+	}
+}
+
+func TestOperations_CancelOperation(t *testing.T) {
+	tests := map[string]struct {
+		abort   AbortFunc
+		done    bool
+		errCode codes.Code
+		errMsg  string
+		opErr   *Error
+	}{
+		"cancelled operation": {
+			abort:   func(context.Context) error { return nil },
+			errCode: codes.OK,
+			errMsg:  "",
+			opErr:   &Error{Code: codes.Canceled, Message: "cancelled"},
+		},
+		"abort failure": {
+			abort:   func(context.Context) error { return status.Error(codes.FailedPrecondition, "cutover started") },
+			errCode: codes.FailedPrecondition,
+			errMsg:  "cutover started",
+			opErr:   nil,
+		},
+		"not cancellable operation": {
+			abort:   nil,
+			errCode: codes.FailedPrecondition,
+			errMsg:  "Operation %s can't be cancelled",
+			opErr:   nil,
+		},
+		"done operation": {
+			abort:   func(context.Context) error { return nil },
+			done:    true,
+			errCode: codes.FailedPrecondition,
+			errMsg:  "Operation %s is already done",
+			opErr:   nil,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager()
+			aborted := make(chan struct{})
+			resume := make(chan struct{})
+			abort := tt.abort
+			if abort != nil {
+				abort = func(ctx context.Context) error {
+					err := tt.abort(ctx)
+					if err == nil {
+						close(aborted)
+					}
+					return err
+				}
+			}
+			op := m.StartCancellable(nil, func(context.Context) (interface{}, error) {
+				select {
+				case <-aborted:
+					return nil, status.Error(codes.Canceled, "cancelled")
+				case <-resume:
+					return "done", nil
+				}
+			}, abort)
+			if tt.done {
+				close(resume)
+				m.Wait()
+			}
+
+			response, err := m.CancelOperation(context.Background(), &CancelOperationRequest{Name: op.Name})
+			errMsg := strings.ReplaceAll(tt.errMsg, "%s", op.Name)
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != errMsg {
+				t.Error("error message: expected", errMsg, "received", er.Message())
+			}
+			if err == nil && response.Name != op.Name {
+				t.Error("name: expected", op.Name, "received", response.Name)
+			}
+			if !tt.done && tt.opErr == nil {
+				close(resume)
+			}
+			m.Wait()
+
+			response, _ = m.GetOperation(context.Background(), &GetOperationRequest{Name: op.Name})
+			if (response.Error == nil) != (tt.opErr == nil) || (tt.opErr != nil && *response.Error != *tt.opErr) {
+				t.Error("operation error: expected", tt.opErr, "received", response.Error)
+			}
+		})
+	}
+
+	m := NewManager()
+	_, err := m.CancelOperation(context.Background(), &CancelOperationRequest{Name: "unknown-id"})
+	if er, _ := status.FromError(err); er.Code() != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", er.Code())
+	}
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package operator reconciles the Nvme subsystems, controllers and namespaces declared as
+// Kubernetes custom resources into the bridge, so gitops managed clusters configure the DPU
+// declaratively without a separate operator
+package operator
+
+import (
+	"embed"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// manifests are the CustomResourceDefinitions of the kinds the operator reconciles and the
+// service account of the bridge allowed to reconcile them
+//
+//go:embed crds/*.yaml
+var manifests embed.FS
+
+// WriteManifests writes the manifests to install in a cluster before running the bridge with
+// -k8s_operator, as a single YAML stream for kubectl apply -f -
+func WriteManifests(w io.Writer) error {
+	names, err := fs.Glob(manifests, "crds/*.yaml")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := manifests.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
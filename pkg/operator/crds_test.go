@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package operator reconciles the Nvme subsystems, controllers and namespaces declared as
+// Kubernetes custom resources into the bridge, so gitops managed clusters configure the DPU
+// declaratively without a separate operator
+package operator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// crd is the part of a CustomResourceDefinition the tests check
+type crd struct {
+	Kind string `yaml:"kind"`
+	Spec struct {
+		Group string `yaml:"group"`
+		Names struct {
+			Kind   string `yaml:"kind"`
+			Plural string `yaml:"plural"`
+		} `yaml:"names"`
+		Versions []struct {
+			Name         string `yaml:"name"`
+			Subresources struct {
+				Status map[string]interface{} `yaml:"status"`
+			} `yaml:"subresources"`
+			Schema struct {
+				OpenAPIV3Schema struct {
+					Properties struct {
+						Spec struct {
+							Properties map[string]interface{} `yaml:"properties"`
+						} `yaml:"spec"`
+						Status struct {
+							Properties map[string]interface{} `yaml:"properties"`
+						} `yaml:"status"`
+					} `yaml:"properties"`
+				} `yaml:"openAPIV3Schema"`
+			} `yaml:"schema"`
+		} `yaml:"versions"`
+	} `yaml:"spec"`
+}
+
+// jsonFields returns the sorted JSON names of the fields of a struct
+func jsonFields(v interface{}) []string {
+	var fields []string
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		fields = append(fields, strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// keys returns the sorted keys of a map
+func keys(m map[string]interface{}) []string {
+	var result []string
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestOperator_WriteManifests(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteManifests(&buf); err != nil {
+		t.Fatal(err)
+	}
+	crds := make(map[string]*crd)
+	kinds := make(map[string]bool)
+	decoder := yaml.NewDecoder(&buf)
+	for {
+		var document crd
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds[document.Kind] = true
+		if document.Kind == "CustomResourceDefinition" {
+			crds[document.Spec.Names.Plural] = &document
+		}
+	}
+	for _, kind := range []string{"ServiceAccount", "Role", "RoleBinding"} {
+		if !kinds[kind] {
+			t.Error("manifests: expected a", kind)
+		}
+	}
+
+	// the schemas declare the fields the operator decodes, for each kind it reconciles
+	specs := map[string]interface{}{
+		"nvmesubsystems":  SubsystemSpec{},
+		"nvmecontrollers": ControllerSpec{},
+		"nvmenamespaces":  NamespaceSpec{},
+	}
+	for _, k := range New(nil, nil).kinds {
+		definition, ok := crds[k.plural]
+		if !ok {
+			t.Error("crds: expected", k.plural)
+			continue
+		}
+		if definition.Spec.Group != Group || len(definition.Spec.Versions) != 1 || definition.Spec.Versions[0].Name != Version {
+			t.Error(k.plural, "version: expected", Group+"/"+Version, "received", definition.Spec.Group, definition.Spec.Versions)
+			continue
+		}
+		version := definition.Spec.Versions[0]
+		if version.Subresources.Status == nil {
+			t.Error(k.plural, "status: expected a subresource")
+		}
+		schema := version.Schema.OpenAPIV3Schema.Properties
+		if fields, expected := keys(schema.Spec.Properties), jsonFields(specs[k.plural]); !reflect.DeepEqual(fields, expected) {
+			t.Error(k.plural, "spec: expected", expected, "received", fields)
+		}
+		if fields, expected := keys(schema.Status.Properties), jsonFields(Status{}); !reflect.DeepEqual(fields, expected) {
+			t.Error(k.plural, "status: expected", expected, "received", fields)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package operator reconciles the Nvme subsystems, controllers and namespaces declared as
+// Kubernetes custom resources into the bridge, so gitops managed clusters configure the DPU
+// declaratively without a separate operator
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Group and Version of the custom resources
+const (
+	Group   = "storage.opiproject.org"
+	Version = "v1alpha1"
+)
+
+// serviceAccountDir is where the pods get the token and the CA of their service account
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// errExpired is returned by a watch whose resource version is too old, the resources have to be
+// listed again
+var errExpired = errors.New("resource version expired")
+
+// Kube calls the Kubernetes API server for the custom resources of a namespace
+type Kube struct {
+	url    string
+	client *http.Client
+	token  string
+	// tokenFile is read again for each request when set, instead of token, the kubelet rotates
+	// the bound service account tokens before they expire
+	tokenFile string
+	namespace string
+}
+
+// NewKube returns a Kube calling the API server at url with the bearer token, for the custom
+// resources of namespace
+func NewKube(url string, client *http.Client, token string, namespace string) *Kube {
+	return &Kube{url: url, client: client, token: token, namespace: namespace}
+}
+
+// InClusterKube returns a Kube calling the API server of the cluster the bridge runs in, with
+// the service account of its pod. The resources are the ones of namespace, or else of the one of
+// the pod
+func InClusterKube(namespace string) (*Kube, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid CA %s, have to be PEM", filepath.Join(serviceAccountDir, "ca.crt"))
+	}
+	if namespace == "" {
+		data, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, err
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+	}
+	kube := NewKube("https://"+net.JoinHostPort(host, port), client, "", namespace)
+	kube.tokenFile = filepath.Join(serviceAccountDir, "token")
+	if _, err := kube.bearerToken(); err != nil {
+		return nil, err
+	}
+	return kube, nil
+}
+
+// bearerToken returns the token of the requests
+func (k *Kube) bearerToken() (string, error) {
+	if k.tokenFile == "" {
+		return k.token, nil
+	}
+	token, err := os.ReadFile(k.tokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// path returns the path of the resources of a kind, or of one of them when name is set
+func (k *Kube) path(plural string, name string) string {
+	path := fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, k.namespace, plural)
+	if name != "" {
+		path += "/" + name
+	}
+	return path
+}
+
+// list lists the custom resources of a kind, with the resource version to watch them from
+func (k *Kube) list(ctx context.Context, plural string) ([]*Resource, string, error) {
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []*Resource `json:"items"`
+	}
+	if err := k.do(ctx, http.MethodGet, k.path(plural, ""), "", nil, &list); err != nil {
+		return nil, "", err
+	}
+	return list.Items, list.Metadata.ResourceVersion, nil
+}
+
+// watchEvent is a change of a watched custom resource, or the error ending the watch
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// watch calls handle with the changes of the custom resources of a kind since resourceVersion,
+// until ctx is done or the API server ends the watch. It returns the resource version of the
+// last change, to watch again from
+func (k *Kube) watch(ctx context.Context, plural string, resourceVersion string, handle func(eventType string, r *Resource)) (string, error) {
+	query := url.Values{"watch": {"true"}, "resourceVersion": {resourceVersion}, "allowWatchBookmarks": {"true"}}
+	path := k.path(plural, "") + "?" + query.Encode()
+	req, err := k.newRequest(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return resourceVersion, err
+	}
+	// the watch outlasts the timeout of the requests
+	client := *k.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return resourceVersion, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusGone {
+		return resourceVersion, errExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resourceVersion, statusError(http.MethodGet, path, resp)
+	}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return resourceVersion, nil
+			}
+			return resourceVersion, err
+		}
+		if event.Type == "ERROR" {
+			var kubeStatus struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(event.Object, &kubeStatus)
+			if kubeStatus.Code == http.StatusGone {
+				return resourceVersion, errExpired
+			}
+			return resourceVersion, fmt.Errorf("watch %s: %s", plural, kubeStatus.Message)
+		}
+		var r Resource
+		if err := json.Unmarshal(event.Object, &r); err != nil {
+			return resourceVersion, err
+		}
+		resourceVersion = r.Metadata.ResourceVersion
+		if event.Type != "BOOKMARK" {
+			handle(event.Type, &r)
+		}
+	}
+}
+
+// patch merges patch into a custom resource, or into its status when status is set
+func (k *Kube) patch(ctx context.Context, plural string, name string, status bool, patch interface{}) error {
+	path := k.path(plural, name)
+	if status {
+		path += "/status"
+	}
+	return k.do(ctx, http.MethodPatch, path, "application/merge-patch+json", patch, nil)
+}
+
+// newRequest returns a request with in as its JSON body, when set
+func (k *Kube) newRequest(ctx context.Context, method string, path string, contentType string, in interface{}) (*http.Request, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, k.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	token, err := k.bearerToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// do sends in as the JSON body of the request and decodes the response in out, when set
+func (k *Kube) do(ctx context.Context, method string, path string, contentType string, in interface{}, out interface{}) error {
+	req, err := k.newRequest(ctx, method, path, contentType, in)
+	if err != nil {
+		return err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return statusError(method, path, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// statusError returns the error of a failed response, with the message of its Kubernetes status
+func statusError(method string, path string, resp *http.Response) error {
+	var kubeStatus struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&kubeStatus)
+	return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, kubeStatus.Message)
+}
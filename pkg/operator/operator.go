@@ -0,0 +1,430 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package operator reconciles the Nvme subsystems, controllers and namespaces declared as
+// Kubernetes custom resources into the bridge, so gitops managed clusters configure the DPU
+// declaratively without a separate operator
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Finalizer keeps a custom resource until the bridge deleted what it declares
+const Finalizer = "storage.opiproject.org/opi-marvell-bridge"
+
+// watchRetry is the time to wait before listing and watching the custom resources again, when
+// they couldn't be
+const watchRetry = 5 * time.Second
+
+// Phases of the custom resources
+const (
+	PhaseReady  = "Ready"
+	PhaseFailed = "Failed"
+)
+
+// Metadata is the part of the metadata of a custom resource the operator uses
+type Metadata struct {
+	Name              string   `json:"name"`
+	Generation        int64    `json:"generation"`
+	ResourceVersion   string   `json:"resourceVersion,omitempty"`
+	DeletionTimestamp string   `json:"deletionTimestamp,omitempty"`
+	Finalizers        []string `json:"finalizers,omitempty"`
+}
+
+// Status is the status of a custom resource, as reconciled by the operator
+type Status struct {
+	// Phase is Ready once the bridge has the resource, or else Failed
+	Phase string `json:"phase"`
+	// Message tells why the resource failed
+	Message string `json:"message,omitempty"`
+	// Name of the resource in the bridge
+	Name string `json:"name,omitempty"`
+	// ObservedGeneration is the generation of the spec the phase is for
+	ObservedGeneration int64 `json:"observedGeneration"`
+}
+
+// Resource is a custom resource, its spec depends on its kind
+type Resource struct {
+	Metadata Metadata        `json:"metadata"`
+	Spec     json.RawMessage `json:"spec"`
+	Status   *Status         `json:"status,omitempty"`
+}
+
+// SubsystemSpec is the spec of the NvmeSubsystem resources
+type SubsystemSpec struct {
+	Nqn           string `json:"nqn"`
+	SerialNumber  string `json:"serialNumber"`
+	ModelNumber   string `json:"modelNumber"`
+	MaxNamespaces int64  `json:"maxNamespaces"`
+}
+
+// ControllerSpec is the spec of the NvmeController resources, emulated on a PCIe function
+type ControllerSpec struct {
+	// Subsystem is the name of the NvmeSubsystem resource of the controller
+	Subsystem        string `json:"subsystem"`
+	PortID           int32  `json:"portId"`
+	PhysicalFunction int32  `json:"physicalFunction"`
+	VirtualFunction  int32  `json:"virtualFunction"`
+	MaxNsq           int32  `json:"maxNsq"`
+	MaxNcq           int32  `json:"maxNcq"`
+	MaxNamespaces    int32  `json:"maxNamespaces"`
+}
+
+// NamespaceSpec is the spec of the NvmeNamespace resources
+type NamespaceSpec struct {
+	// Subsystem is the name of the NvmeSubsystem resource of the namespace
+	Subsystem     string `json:"subsystem"`
+	VolumeNameRef string `json:"volumeNameRef"`
+	HostNsid      int32  `json:"hostNsid"`
+}
+
+// kind reconciles the custom resources of a kind into the bridge
+type kind struct {
+	plural string
+	// create creates the resource in the bridge, it returns the existing one when it already does
+	create func(ctx context.Context, r *Resource) (string, error)
+	// delete deletes the resource from the bridge, deleting it again succeeds
+	delete func(ctx context.Context, r *Resource) error
+}
+
+// Operator reconciles the custom resources into the frontend of the bridge
+type Operator struct {
+	kube     *Kube
+	frontend pb.FrontendNvmeServiceServer
+	// kinds are in creation order, the subsystems before their controllers and namespaces
+	kinds []*kind
+}
+
+// New returns an Operator reconciling the custom resources of kube into frontend
+func New(kube *Kube, frontend pb.FrontendNvmeServiceServer) *Operator {
+	o := &Operator{kube: kube, frontend: frontend}
+	o.kinds = []*kind{
+		{plural: "nvmesubsystems", create: o.createSubsystem, delete: o.deleteSubsystem},
+		{plural: "nvmecontrollers", create: o.createController, delete: o.deleteController},
+		{plural: "nvmenamespaces", create: o.createNamespace, delete: o.deleteNamespace},
+	}
+	return o
+}
+
+// Run reconciles the custom resources as they change until ctx is done, watching them. They are
+// all listed and reconciled again every resync, the failed ones being retried then
+func (o *Operator) Run(ctx context.Context, resync time.Duration) {
+	for ctx.Err() == nil {
+		err := o.watch(ctx, resync)
+		if err == nil {
+			continue
+		}
+		slog.Warn("Could not watch the custom resources", "error", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchRetry):
+		}
+	}
+}
+
+// change is a change of a watched custom resource of the kind at index kind
+type change struct {
+	kind      int
+	eventType string
+	resource  *Resource
+}
+
+// watch lists and reconciles the custom resources, then reconciles them again on each change
+// until resync elapses or a watch fails
+func (o *Operator) watch(ctx context.Context, resync time.Duration) error {
+	cache := make([]map[string]*Resource, len(o.kinds))
+	versions := make([]string, len(o.kinds))
+	for i, k := range o.kinds {
+		resources, version, err := o.kube.list(ctx, k.plural)
+		if err != nil {
+			return err
+		}
+		cache[i] = make(map[string]*Resource, len(resources))
+		for _, r := range resources {
+			cache[i][r.Metadata.Name] = r
+		}
+		versions[i] = version
+	}
+	watchCtx, cancel := context.WithTimeout(ctx, resync)
+	defer cancel()
+	changes := make(chan change)
+	// each watch fails at most once
+	errs := make(chan error, len(o.kinds))
+	for i, k := range o.kinds {
+		go func(i int, k *kind, version string) {
+			for watchCtx.Err() == nil {
+				var err error
+				version, err = o.kube.watch(watchCtx, k.plural, version, func(eventType string, r *Resource) {
+					select {
+					case changes <- change{kind: i, eventType: eventType, resource: r}:
+					case <-watchCtx.Done():
+					}
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i, k, versions[i])
+	}
+	for {
+		o.reconcileAll(ctx, sortedResources(cache))
+		select {
+		case <-watchCtx.Done():
+			return nil
+		case err := <-errs:
+			if errors.Is(err, errExpired) {
+				return nil
+			}
+			return err
+		case c := <-changes:
+			applyChange(cache, c)
+		}
+		// the pending changes are reconciled at once
+		for pending := true; pending; {
+			select {
+			case c := <-changes:
+				applyChange(cache, c)
+			default:
+				pending = false
+			}
+		}
+	}
+}
+
+// applyChange applies a change to the cache of the watched custom resources
+func applyChange(cache []map[string]*Resource, c change) {
+	if c.eventType == "DELETED" {
+		delete(cache[c.kind], c.resource.Metadata.Name)
+		return
+	}
+	cache[c.kind][c.resource.Metadata.Name] = c.resource
+}
+
+// sortedResources returns the cached custom resources of each kind, sorted by name as listed
+func sortedResources(cache []map[string]*Resource) [][]*Resource {
+	resources := make([][]*Resource, len(cache))
+	for i, byName := range cache {
+		for _, r := range byName {
+			resources[i] = append(resources[i], r)
+		}
+		slices.SortFunc(resources[i], func(a, b *Resource) int { return strings.Compare(a.Metadata.Name, b.Metadata.Name) })
+	}
+	return resources
+}
+
+// Reconcile creates the resources declared and deletes the ones being deleted, the namespaces
+// and the controllers before their subsystem. A resource failing doesn't stop the others
+func (o *Operator) Reconcile(ctx context.Context) error {
+	resources := make([][]*Resource, len(o.kinds))
+	for i, k := range o.kinds {
+		var err error
+		if resources[i], _, err = o.kube.list(ctx, k.plural); err != nil {
+			return err
+		}
+	}
+	o.reconcileAll(ctx, resources)
+	return nil
+}
+
+// reconcileAll reconciles the custom resources of each kind
+func (o *Operator) reconcileAll(ctx context.Context, resources [][]*Resource) {
+	for i := len(o.kinds) - 1; i >= 0; i-- {
+		for _, r := range resources[i] {
+			if r.Metadata.DeletionTimestamp != "" {
+				o.finalize(ctx, o.kinds[i], r)
+			}
+		}
+	}
+	for i, k := range o.kinds {
+		for _, r := range resources[i] {
+			if r.Metadata.DeletionTimestamp == "" {
+				o.reconcile(ctx, k, r)
+			}
+		}
+	}
+}
+
+// reconcile creates the resource in the bridge and reports it in its status. The specs can't
+// change once the resource is created, the controllers and namespaces would be detached
+func (o *Operator) reconcile(ctx context.Context, k *kind, r *Resource) {
+	status := r.Status
+	if status != nil && status.Phase == PhaseReady {
+		if status.ObservedGeneration != r.Metadata.Generation {
+			o.setStatus(ctx, k, r, &Status{
+				Phase:              PhaseFailed,
+				Message:            "the spec can't change once the resource is created, delete and create it again",
+				Name:               status.Name,
+				ObservedGeneration: r.Metadata.Generation,
+			})
+		}
+		return
+	}
+	if status != nil && status.Phase == PhaseFailed && status.Name != "" {
+		// the resource was created from a previous spec
+		return
+	}
+	if !slices.Contains(r.Metadata.Finalizers, Finalizer) {
+		finalizers := append(slices.Clone(r.Metadata.Finalizers), Finalizer)
+		patch := map[string]interface{}{"metadata": map[string]interface{}{"finalizers": finalizers}}
+		if err := o.kube.patch(ctx, k.plural, r.Metadata.Name, false, patch); err != nil {
+			slog.Warn("Could not add the finalizer", "kind", k.plural, "name", r.Metadata.Name, "error", err)
+			return
+		}
+		r.Metadata.Finalizers = finalizers
+	}
+	name, err := k.create(ctx, r)
+	if err != nil {
+		o.setStatus(ctx, k, r, &Status{Phase: PhaseFailed, Message: err.Error(), ObservedGeneration: r.Metadata.Generation})
+		return
+	}
+	slog.Info("Reconciled custom resource", "kind", k.plural, "name", r.Metadata.Name, "resource", name)
+	o.setStatus(ctx, k, r, &Status{Phase: PhaseReady, Name: name, ObservedGeneration: r.Metadata.Generation})
+}
+
+// finalize deletes the resource from the bridge, then lets Kubernetes delete the custom resource
+func (o *Operator) finalize(ctx context.Context, k *kind, r *Resource) {
+	if !slices.Contains(r.Metadata.Finalizers, Finalizer) {
+		return
+	}
+	if err := k.delete(ctx, r); err != nil {
+		slog.Warn("Could not delete the resource of the custom resource", "kind", k.plural, "name", r.Metadata.Name, "error", err)
+		return
+	}
+	finalizers := slices.DeleteFunc(slices.Clone(r.Metadata.Finalizers), func(f string) bool { return f == Finalizer })
+	patch := map[string]interface{}{"metadata": map[string]interface{}{"finalizers": finalizers}}
+	if err := o.kube.patch(ctx, k.plural, r.Metadata.Name, false, patch); err != nil {
+		slog.Warn("Could not remove the finalizer", "kind", k.plural, "name", r.Metadata.Name, "error", err)
+		return
+	}
+	slog.Info("Finalized custom resource", "kind", k.plural, "name", r.Metadata.Name)
+}
+
+// setStatus reports the status of the custom resource, and keeps it in r until the watch reports it
+func (o *Operator) setStatus(ctx context.Context, k *kind, r *Resource, status *Status) {
+	if err := o.kube.patch(ctx, k.plural, r.Metadata.Name, true, map[string]*Status{"status": status}); err != nil {
+		slog.Warn("Could not update the status", "kind", k.plural, "name", r.Metadata.Name, "error", err)
+		return
+	}
+	r.Status = status
+}
+
+// decodeSpec decodes the spec of a custom resource
+func decodeSpec(r *Resource, spec interface{}) error {
+	if err := json.Unmarshal(r.Spec, spec); err != nil {
+		return fmt.Errorf("invalid spec: %v", err)
+	}
+	return nil
+}
+
+func (o *Operator) createSubsystem(ctx context.Context, r *Resource) (string, error) {
+	var spec SubsystemSpec
+	if err := decodeSpec(r, &spec); err != nil {
+		return "", err
+	}
+	subsystem, err := o.frontend.CreateNvmeSubsystem(ctx, &pb.CreateNvmeSubsystemRequest{
+		NvmeSubsystemId: r.Metadata.Name,
+		NvmeSubsystem: &pb.NvmeSubsystem{Spec: &pb.NvmeSubsystemSpec{
+			Nqn:           spec.Nqn,
+			SerialNumber:  spec.SerialNumber,
+			ModelNumber:   spec.ModelNumber,
+			MaxNamespaces: spec.MaxNamespaces,
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return subsystem.Name, nil
+}
+
+func (o *Operator) deleteSubsystem(ctx context.Context, r *Resource) error {
+	_, err := o.frontend.DeleteNvmeSubsystem(ctx, &pb.DeleteNvmeSubsystemRequest{
+		Name:         utils.ResourceIDToSubsystemName(r.Metadata.Name),
+		AllowMissing: true,
+	})
+	return err
+}
+
+func (o *Operator) createController(ctx context.Context, r *Resource) (string, error) {
+	var spec ControllerSpec
+	if err := decodeSpec(r, &spec); err != nil {
+		return "", err
+	}
+	controller, err := o.frontend.CreateNvmeController(ctx, &pb.CreateNvmeControllerRequest{
+		Parent:           utils.ResourceIDToSubsystemName(spec.Subsystem),
+		NvmeControllerId: r.Metadata.Name,
+		NvmeController: &pb.NvmeController{Spec: &pb.NvmeControllerSpec{
+			Endpoint: &pb.NvmeControllerSpec_PcieId{
+				PcieId: &pb.PciEndpoint{
+					PortId:           wrapperspb.Int32(spec.PortID),
+					PhysicalFunction: wrapperspb.Int32(spec.PhysicalFunction),
+					VirtualFunction:  wrapperspb.Int32(spec.VirtualFunction),
+				},
+			},
+			Trtype:        pb.NvmeTransportType_NVME_TRANSPORT_TYPE_PCIE,
+			MaxNsq:        spec.MaxNsq,
+			MaxNcq:        spec.MaxNcq,
+			MaxNamespaces: spec.MaxNamespaces,
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return controller.Name, nil
+}
+
+func (o *Operator) deleteController(ctx context.Context, r *Resource) error {
+	var spec ControllerSpec
+	if err := decodeSpec(r, &spec); err != nil {
+		return err
+	}
+	_, err := o.frontend.DeleteNvmeController(ctx, &pb.DeleteNvmeControllerRequest{
+		Name:         utils.ResourceIDToControllerName(spec.Subsystem, r.Metadata.Name),
+		AllowMissing: true,
+	})
+	return err
+}
+
+func (o *Operator) createNamespace(ctx context.Context, r *Resource) (string, error) {
+	var spec NamespaceSpec
+	if err := decodeSpec(r, &spec); err != nil {
+		return "", err
+	}
+	namespace, err := o.frontend.CreateNvmeNamespace(ctx, &pb.CreateNvmeNamespaceRequest{
+		Parent:          utils.ResourceIDToSubsystemName(spec.Subsystem),
+		NvmeNamespaceId: r.Metadata.Name,
+		NvmeNamespace: &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{
+			VolumeNameRef: spec.VolumeNameRef,
+			HostNsid:      spec.HostNsid,
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return namespace.Name, nil
+}
+
+func (o *Operator) deleteNamespace(ctx context.Context, r *Resource) error {
+	var spec NamespaceSpec
+	if err := decodeSpec(r, &spec); err != nil {
+		return err
+	}
+	_, err := o.frontend.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{
+		Name:         utils.ResourceIDToNamespaceName(spec.Subsystem, r.Metadata.Name),
+		AllowMissing: true,
+	})
+	return err
+}
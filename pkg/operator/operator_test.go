@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package operator reconciles the Nvme subsystems, controllers and namespaces declared as
+// Kubernetes custom resources into the bridge, so gitops managed clusters configure the DPU
+// declaratively without a separate operator
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testFrontend records the calls of the operator, the namespaces fail without their subsystem
+type testFrontend struct {
+	pb.UnimplementedFrontendNvmeServiceServer
+	mu         sync.Mutex
+	calls      []string
+	subsystems map[string]bool
+}
+
+// record records a call of the operator
+func (f *testFrontend) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+// recorded returns the calls of the operator so far
+func (f *testFrontend) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+func (f *testFrontend) CreateNvmeSubsystem(_ context.Context, in *pb.CreateNvmeSubsystemRequest) (*pb.NvmeSubsystem, error) {
+	f.record("CreateNvmeSubsystem " + in.NvmeSubsystemId + " " + in.NvmeSubsystem.Spec.Nqn)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subsystems[in.NvmeSubsystemId] = true
+	return &pb.NvmeSubsystem{Name: "nvmeSubsystems/" + in.NvmeSubsystemId}, nil
+}
+
+func (f *testFrontend) DeleteNvmeSubsystem(_ context.Context, in *pb.DeleteNvmeSubsystemRequest) (*emptypb.Empty, error) {
+	f.record("DeleteNvmeSubsystem " + in.Name)
+	return &emptypb.Empty{}, nil
+}
+
+func (f *testFrontend) CreateNvmeController(_ context.Context, in *pb.CreateNvmeControllerRequest) (*pb.NvmeController, error) {
+	f.record("CreateNvmeController " + in.Parent + " " + in.NvmeControllerId)
+	return &pb.NvmeController{Name: in.Parent + "/nvmeControllers/" + in.NvmeControllerId}, nil
+}
+
+func (f *testFrontend) DeleteNvmeController(_ context.Context, in *pb.DeleteNvmeControllerRequest) (*emptypb.Empty, error) {
+	f.record("DeleteNvmeController " + in.Name)
+	return &emptypb.Empty{}, nil
+}
+
+func (f *testFrontend) CreateNvmeNamespace(_ context.Context, in *pb.CreateNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
+	f.record("CreateNvmeNamespace " + in.Parent + " " + in.NvmeNamespaceId)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.subsystems[strings.TrimPrefix(in.Parent, "nvmeSubsystems/")] {
+		return nil, status.Errorf(codes.NotFound, "unable to find key %s", in.Parent)
+	}
+	return &pb.NvmeNamespace{Name: in.Parent + "/nvmeNamespaces/" + in.NvmeNamespaceId}, nil
+}
+
+func (f *testFrontend) DeleteNvmeNamespace(_ context.Context, in *pb.DeleteNvmeNamespaceRequest) (*emptypb.Empty, error) {
+	f.record("DeleteNvmeNamespace " + in.Name)
+	return &emptypb.Empty{}, nil
+}
+
+// testKube serves the custom resources of the namespace default, applying the merge patches, and
+// streams the events sent on the watch channel of their kind to their watches
+type testKube struct {
+	mu        sync.Mutex
+	resources map[string][]*Resource
+	watches   map[string]chan watchEvent
+}
+
+func (k *testKube) find(plural string, name string) *Resource {
+	for _, r := range k.resources[plural] {
+		if r.Metadata.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func newTestKube(t *testing.T, resources map[string][]*Resource) (*testKube, *httptest.Server) {
+	kube := &testKube{resources: resources, watches: make(map[string]chan watchEvent)}
+	for _, plural := range []string{"nvmesubsystems", "nvmecontrollers", "nvmenamespaces"} {
+		kube.watches[plural] = make(chan watchEvent, 8)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/apis/storage.opiproject.org/v1alpha1/namespaces/default/"), "/")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case event := <-kube.watches[path[0]]:
+					_ = json.NewEncoder(w).Encode(event)
+					w.(http.Flusher).Flush()
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		kube.mu.Lock()
+		defer kube.mu.Unlock()
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"metadata": map[string]string{"resourceVersion": "1"},
+				"items":    kube.resources[path[0]],
+			})
+			return
+		}
+		resource := kube.find(path[0], path[1])
+		var patch Resource
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			t.Error(err)
+		}
+		if len(path) == 3 {
+			resource.Status = patch.Status
+		} else {
+			resource.Metadata.Finalizers = patch.Metadata.Finalizers
+		}
+		// the resource being deleted is gone once it has no finalizer
+		if resource.Metadata.DeletionTimestamp != "" && len(resource.Metadata.Finalizers) == 0 {
+			kube.resources[path[0]] = removeResource(kube.resources[path[0]], resource)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	return kube, server
+}
+
+// removeResource returns the resources but resource
+func removeResource(resources []*Resource, resource *Resource) []*Resource {
+	var kept []*Resource
+	for _, r := range resources {
+		if r != resource {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+func TestOperator_Reconcile(t *testing.T) {
+	kube, server := newTestKube(t, map[string][]*Resource{
+		"nvmesubsystems": {
+			{Metadata: Metadata{Name: "subsys0", Generation: 1}, Spec: json.RawMessage(`{"nqn": "nqn.2022-09.io.spdk:opi0", "maxNamespaces": 32}`)},
+		},
+		"nvmecontrollers": {
+			{Metadata: Metadata{Name: "ctrl0", Generation: 1}, Spec: json.RawMessage(`{"subsystem": "subsys0", "physicalFunction": 1}`)},
+		},
+		"nvmenamespaces": {
+			{Metadata: Metadata{Name: "ns0", Generation: 1}, Spec: json.RawMessage(`{"subsystem": "subsys0", "volumeNameRef": "Malloc0", "hostNsid": 1}`)},
+			{Metadata: Metadata{Name: "ns1", Generation: 1}, Spec: json.RawMessage(`{"subsystem": "subsys1", "volumeNameRef": "Malloc1", "hostNsid": 1}`)},
+		},
+	})
+	defer server.Close()
+	frontend := &testFrontend{subsystems: make(map[string]bool)}
+	o := New(NewKube(server.URL, server.Client(), "token", "default"), frontend)
+	ctx := context.Background()
+
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"CreateNvmeSubsystem subsys0 nqn.2022-09.io.spdk:opi0",
+		"CreateNvmeController nvmeSubsystems/subsys0 ctrl0",
+		"CreateNvmeNamespace nvmeSubsystems/subsys0 ns0",
+		"CreateNvmeNamespace nvmeSubsystems/subsys1 ns1",
+	}
+	if !reflect.DeepEqual(frontend.calls, expected) {
+		t.Error("calls: expected", expected, "received", frontend.calls)
+	}
+	ready := &Status{Phase: PhaseReady, Name: "nvmeSubsystems/subsys0/nvmeNamespaces/ns0", ObservedGeneration: 1}
+	if ns0 := kube.find("nvmenamespaces", "ns0"); !reflect.DeepEqual(ns0.Status, ready) || !reflect.DeepEqual(ns0.Metadata.Finalizers, []string{Finalizer}) {
+		t.Error("ns0: expected", ready, "received", ns0.Status, ns0.Metadata.Finalizers)
+	}
+	failed := &Status{Phase: PhaseFailed, Message: "rpc error: code = NotFound desc = unable to find key nvmeSubsystems/subsys1", ObservedGeneration: 1}
+	if ns1 := kube.find("nvmenamespaces", "ns1"); !reflect.DeepEqual(ns1.Status, failed) {
+		t.Error("ns1: expected", failed, "received", ns1.Status)
+	}
+
+	// the ready resources are left alone, the failed ones retried, and the spec can't change
+	frontend.calls = nil
+	kube.find("nvmesubsystems", "subsys0").Metadata.Generation = 2
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"CreateNvmeNamespace nvmeSubsystems/subsys1 ns1"}
+	if !reflect.DeepEqual(frontend.calls, expected) {
+		t.Error("calls: expected", expected, "received", frontend.calls)
+	}
+	if subsys0 := kube.find("nvmesubsystems", "subsys0"); subsys0.Status.Phase != PhaseFailed || subsys0.Status.ObservedGeneration != 2 {
+		t.Error("subsys0: expected failed generation 2, received", subsys0.Status)
+	}
+
+	// the deletions go through the bridge first, the namespaces before their subsystem
+	frontend.calls = nil
+	for _, plural := range []string{"nvmesubsystems", "nvmenamespaces"} {
+		for _, r := range kube.resources[plural] {
+			r.Metadata.DeletionTimestamp = "2024-05-01T00:00:00Z"
+		}
+	}
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"DeleteNvmeNamespace nvmeSubsystems/subsys0/nvmeNamespaces/ns0",
+		"DeleteNvmeNamespace nvmeSubsystems/subsys1/nvmeNamespaces/ns1",
+		"DeleteNvmeSubsystem nvmeSubsystems/subsys0",
+	}
+	if !reflect.DeepEqual(frontend.calls, expected) {
+		t.Error("calls: expected", expected, "received", frontend.calls)
+	}
+	if len(kube.resources["nvmenamespaces"]) != 0 || len(kube.resources["nvmesubsystems"]) != 0 {
+		t.Error("resources: expected deleted, received", kube.resources)
+	}
+}
+
+func TestOperator_TokenFile(t *testing.T) {
+	_, server := newTestKube(t, map[string][]*Resource{})
+	defer server.Close()
+	kube := NewKube(server.URL, server.Client(), "", "default")
+	kube.tokenFile = filepath.Join(t.TempDir(), "token")
+	o := New(kube, &testFrontend{subsystems: make(map[string]bool)})
+
+	// the token rotated by the kubelet is used from the next request
+	if err := os.WriteFile(kube.tokenFile, []byte("expired\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Reconcile(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Error("expected unauthorized, received", err)
+	}
+	if err := os.WriteFile(kube.tokenFile, []byte("token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Reconcile(context.Background()); err != nil {
+		t.Error("expected no error, received", err)
+	}
+}
+
+func TestOperator_Run(t *testing.T) {
+	kube, server := newTestKube(t, map[string][]*Resource{
+		"nvmesubsystems": {
+			{Metadata: Metadata{Name: "subsys0", Generation: 1}, Spec: json.RawMessage(`{"nqn": "nqn.2022-09.io.spdk:opi0"}`)},
+		},
+	})
+	defer server.Close()
+	frontend := &testFrontend{subsystems: make(map[string]bool)}
+	o := New(NewKube(server.URL, server.Client(), "token", "default"), frontend)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		o.Run(ctx, time.Hour)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// the resources are listed once, then their changes watched
+	waitCalls(t, frontend, []string{"CreateNvmeSubsystem subsys0 nqn.2022-09.io.spdk:opi0"})
+	ns0 := &Resource{Metadata: Metadata{Name: "ns0", Generation: 1, ResourceVersion: "2"}, Spec: json.RawMessage(`{"subsystem": "subsys0", "volumeNameRef": "Malloc0", "hostNsid": 1}`)}
+	kube.mu.Lock()
+	kube.resources["nvmenamespaces"] = append(kube.resources["nvmenamespaces"], ns0)
+	object, err := json.Marshal(ns0)
+	kube.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kube.watches["nvmenamespaces"] <- watchEvent{Type: "ADDED", Object: object}
+	waitCalls(t, frontend, []string{
+		"CreateNvmeSubsystem subsys0 nqn.2022-09.io.spdk:opi0",
+		"CreateNvmeNamespace nvmeSubsystems/subsys0 ns0",
+	})
+}
+
+// waitCalls waits for the calls of the operator to be the expected ones
+func waitCalls(t *testing.T, frontend *testFrontend, expected []string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(frontend.recorded(), expected) {
+		if time.Now().After(deadline) {
+			t.Fatal("calls: expected", expected, "received", frontend.recorded())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
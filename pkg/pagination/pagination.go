@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package pagination pages the results of the List methods with limits set per deployment, the
+// small edge cards and the deployments with a thousand controllers not wanting the same pages,
+// and drops the page tokens once expired so the servers don't keep them forever
+package pagination
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxPageSize is the highest maximum page size which can be set, the bigger pages taking too
+// long to be read from the firmware and to be encoded
+const MaxPageSize = 10000
+
+// MaxTokenLifetime is the highest lifetime of the page tokens which can be set
+const MaxTokenLifetime = 24 * time.Hour
+
+// Limits bound the pages of the List methods
+type Limits struct {
+	// MaxPageSize caps the page sizes of the requests, the bigger ones are lowered to it
+	MaxPageSize int
+	// DefaultPageSize is the size of the pages of the requests without a page size
+	DefaultPageSize int
+	// TokenLifetime is the time the page tokens can be used after being returned, they don't
+	// expire when 0
+	TokenLifetime time.Duration
+}
+
+// DefaultLimits are the limits when not set, as the ones of the OPI bridges
+var DefaultLimits = Limits{MaxPageSize: 250, DefaultPageSize: 50, TokenLifetime: time.Hour}
+
+// Paginator pages the results of the List methods of a server, it keeps the offsets of the pages
+// of the tokens it returned until they expire
+type Paginator struct {
+	mu     sync.Mutex
+	limits Limits
+	// tokens are the offsets of the pages by token
+	tokens map[string]int
+	// expiries are the expirations of the tokens, the ones returned without a lifetime have none
+	expiries map[string]time.Time
+	now      func() time.Time
+}
+
+// New returns a Paginator with the default limits
+func New() *Paginator {
+	return &Paginator{
+		limits:   DefaultLimits,
+		tokens:   make(map[string]int),
+		expiries: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Validate checks the limits can be set
+func (l Limits) Validate() error {
+	if l.MaxPageSize < 1 || l.MaxPageSize > MaxPageSize {
+		return fmt.Errorf("invalid maximum page size %d, have to be between 1 and %d", l.MaxPageSize, MaxPageSize)
+	}
+	if l.DefaultPageSize < 1 || l.DefaultPageSize > l.MaxPageSize {
+		return fmt.Errorf("invalid default page size %d, have to be between 1 and the maximum page size %d", l.DefaultPageSize, l.MaxPageSize)
+	}
+	if l.TokenLifetime < 0 || l.TokenLifetime > MaxTokenLifetime {
+		return fmt.Errorf("invalid page token lifetime %v, have to be between 0 and %v", l.TokenLifetime, MaxTokenLifetime)
+	}
+	return nil
+}
+
+// SetLimits sets the limits of the List methods of the server
+func (p *Paginator) SetLimits(l Limits) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limits = l
+	return nil
+}
+
+// Extract returns the size and the offset of the page of a request, the offset being the one of
+// its token
+func (p *Paginator) Extract(pageSize int32, pageToken string) (size int, offset int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case pageSize < 0:
+		return -1, -1, status.Error(codes.InvalidArgument, "negative PageSize is not allowed")
+	case pageSize == 0:
+		size = p.limits.DefaultPageSize
+	case int(pageSize) > p.limits.MaxPageSize:
+		slog.Debug("Capping page size", "pageSize", pageSize, "max", p.limits.MaxPageSize)
+		size = p.limits.MaxPageSize
+	default:
+		size = int(pageSize)
+	}
+	// fetch offset from the database using opaque token
+	if pageToken == "" {
+		return size, 0, nil
+	}
+	offset, ok := p.tokens[pageToken]
+	if !ok {
+		return -1, -1, status.Errorf(codes.NotFound, "unable to find pagination token %s", pageToken)
+	}
+	if at, ok := p.expiries[pageToken]; ok && p.now().After(at) {
+		delete(p.tokens, pageToken)
+		delete(p.expiries, pageToken)
+		return -1, -1, status.Errorf(codes.NotFound, "pagination token %s expired, list again from the first page", pageToken)
+	}
+	slog.Debug("Found offset from pagination token", "offset", offset, "token", pageToken)
+	return size, offset, nil
+}
+
+// NewToken returns a token of the page at offset, kept until it expires. The expired tokens are
+// dropped meanwhile
+func (p *Paginator) NewToken(offset int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.now()
+	for token, at := range p.expiries {
+		if t.After(at) {
+			delete(p.tokens, token)
+			delete(p.expiries, token)
+		}
+	}
+	token := uuid.New().String()
+	p.tokens[token] = offset
+	if p.limits.TokenLifetime > 0 {
+		p.expiries[token] = t.Add(p.limits.TokenLifetime)
+	}
+	return token
+}
+
+// SetToken keeps a token of the page at offset, without expiry
+func (p *Paginator) SetToken(token string, offset int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens[token] = offset
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package pagination pages the results of the List methods with limits set per deployment, the
+// small edge cards and the deployments with a thousand controllers not wanting the same pages,
+// and drops the page tokens once expired so the servers don't keep them forever
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPagination_SetLimits(t *testing.T) {
+	tests := map[string]struct {
+		limits  Limits
+		wantErr bool
+	}{
+		"defaults":                {limits: DefaultLimits},
+		"edge card":               {limits: Limits{MaxPageSize: 20, DefaultPageSize: 10, TokenLifetime: time.Minute}},
+		"tokens never expiring":   {limits: Limits{MaxPageSize: 1000, DefaultPageSize: 1000}},
+		"max page size too small": {limits: Limits{MaxPageSize: 0, DefaultPageSize: 1}, wantErr: true},
+		"max page size too big":   {limits: Limits{MaxPageSize: MaxPageSize + 1, DefaultPageSize: 1}, wantErr: true},
+		"default above max":       {limits: Limits{MaxPageSize: 10, DefaultPageSize: 11}, wantErr: true},
+		"no default":              {limits: Limits{MaxPageSize: 10}, wantErr: true},
+		"negative lifetime":       {limits: Limits{MaxPageSize: 10, DefaultPageSize: 5, TokenLifetime: -time.Second}, wantErr: true},
+		"lifetime too long":       {limits: Limits{MaxPageSize: 10, DefaultPageSize: 5, TokenLifetime: 25 * time.Hour}, wantErr: true},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := New().SetLimits(tt.limits); (err != nil) != tt.wantErr {
+				t.Error("error: expected", tt.wantErr, "received", err)
+			}
+		})
+	}
+}
+
+func TestPagination_Extract(t *testing.T) {
+	p := New()
+	if err := p.SetLimits(Limits{MaxPageSize: 100, DefaultPageSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	p.SetToken("existing-pagination-token", 30)
+	tests := map[string]struct {
+		pageSize  int32
+		pageToken string
+		size      int
+		offset    int
+		code      codes.Code
+	}{
+		"default page size":  {pageSize: 0, size: 10},
+		"page size":          {pageSize: 42, size: 42},
+		"capped page size":   {pageSize: 2147483647, size: 100},
+		"negative page size": {pageSize: -1, code: codes.InvalidArgument},
+		"page token":         {pageSize: 5, pageToken: "existing-pagination-token", size: 5, offset: 30},
+		"unknown page token": {pageToken: "unknown-pagination-token", code: codes.NotFound},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			size, offset, err := p.Extract(tt.pageSize, tt.pageToken)
+			if er, _ := status.FromError(err); er.Code() != tt.code {
+				t.Error("error code: expected", tt.code, "received", er.Code())
+			}
+			if err == nil && (size != tt.size || offset != tt.offset) {
+				t.Error("page: expected", tt.size, tt.offset, "received", size, offset)
+			}
+		})
+	}
+}
+
+func TestPagination_TokenLifetime(t *testing.T) {
+	p := New()
+	if err := p.SetLimits(Limits{MaxPageSize: 100, DefaultPageSize: 10, TokenLifetime: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	current := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return current }
+
+	expiring := p.NewToken(10)
+	if _, offset, err := p.Extract(10, expiring); err != nil || offset != 10 {
+		t.Error("offset: expected", 10, "received", offset, err)
+	}
+	current = current.Add(2 * time.Minute)
+	_, _, err := p.Extract(10, expiring)
+	if er, _ := status.FromError(err); er.Code() != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", er.Code())
+	}
+	if _, ok := p.tokens[expiring]; ok {
+		t.Error("tokens: expected the expired token to be dropped")
+	}
+
+	// the expired tokens are dropped when new ones are returned
+	expired := p.NewToken(20)
+	current = current.Add(2 * time.Minute)
+	valid := p.NewToken(30)
+	if _, ok := p.tokens[expired]; ok || p.tokens[valid] != 30 || len(p.tokens) != 1 || len(p.expiries) != 1 {
+		t.Error("tokens: expected only", valid, "received", p.tokens)
+	}
+
+	if err := p.SetLimits(Limits{MaxPageSize: 100, DefaultPageSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	forever := p.NewToken(40)
+	current = current.Add(MaxTokenLifetime)
+	if _, offset, err := p.Extract(10, forever); err != nil || offset != 40 {
+		t.Error("offset: expected", 40, "received", offset, err)
+	}
+}
+
+func TestPagination_PerServer(t *testing.T) {
+	frontend, backend := New(), New()
+	if err := frontend.SetLimits(Limits{MaxPageSize: 20, DefaultPageSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the limits and the tokens of a server aren't the ones of the others
+	if size, _, _ := backend.Extract(0, ""); size != DefaultLimits.DefaultPageSize {
+		t.Error("size: expected", DefaultLimits.DefaultPageSize, "received", size)
+	}
+	token := frontend.NewToken(10)
+	_, _, err := backend.Extract(10, token)
+	if er, _ := status.FromError(err); er.Code() != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", er.Code())
+	}
+	if _, offset, err := frontend.Extract(10, token); err != nil || offset != 10 {
+		t.Error("offset: expected", 10, "received", offset, err)
+	}
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package placement spreads the resources of the bridge over several instances of the firmware,
+// i.e. one per NUMA node or group of physical functions, the calls to the firmware go to the
+// instance of the resource of their request and the lists are merged across the instances
+package placement
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/fanout"
+
+	"google.golang.org/grpc"
+)
+
+// DefaultInstance is the name of the instance of the resources no rule places
+const DefaultInstance = "default"
+
+// servicePrefix is the service all the resource names belong to
+const servicePrefix = "//storage.opiproject.org/"
+
+// Rule places the resources whose top level ID starts with Prefix on Instance, i.e. the
+// controllers and the namespaces of the subsystem numa1-subsys0 with the prefix numa1-
+type Rule struct {
+	Prefix   string
+	Instance string
+}
+
+// ParseInstances parses the instances in name=address format, comma separated, i.e.
+// numa1=/var/tmp/spdk1.sock
+func ParseInstances(value string) (map[string]string, error) {
+	instances := make(map[string]string)
+	for _, field := range strings.Split(value, ",") {
+		name, address, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || name == "" || address == "" || name == DefaultInstance {
+			return nil, fmt.Errorf("invalid SPDK instance %q, have to be name=address with a name other than %s", field, DefaultInstance)
+		}
+		if _, ok := instances[name]; ok {
+			return nil, fmt.Errorf("invalid SPDK instance %q, %s is already defined", field, name)
+		}
+		instances[name] = address
+	}
+	return instances, nil
+}
+
+// ParseRules parses the placement rules in prefix=instance format, comma separated, the first
+// rule matching a resource places it, i.e. numa1-=numa1,acme-=numa1
+func ParseRules(value string) ([]Rule, error) {
+	var rules []Rule
+	for _, field := range strings.Split(value, ",") {
+		prefix, instance, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || prefix == "" || instance == "" {
+			return nil, fmt.Errorf("invalid placement rule %q, have to be prefix=instance", field)
+		}
+		rules = append(rules, Rule{Prefix: prefix, Instance: instance})
+	}
+	return rules, nil
+}
+
+// instanceKey is the context key of the instance of a request
+type instanceKey struct{}
+
+// Router implements spdk.JSONRPC over several instances of the firmware, a call goes to the
+// instance of its request, or to all of them for a list made for no resource in particular
+type Router struct {
+	instances map[string]spdk.JSONRPC
+	// names of the instances, sorted so the lists are merged in the same order
+	names []string
+	rules []Rule
+}
+
+// build time check that struct implements interface
+var _ spdk.JSONRPC = (*Router)(nil)
+
+// NewRouter creates a router of the calls to the instances, DefaultInstance included, placing
+// the resources with the rules
+func NewRouter(instances map[string]spdk.JSONRPC, rules []Rule) (*Router, error) {
+	if _, ok := instances[DefaultInstance]; !ok {
+		return nil, fmt.Errorf("missing %s SPDK instance", DefaultInstance)
+	}
+	for _, rule := range rules {
+		if _, ok := instances[rule.Instance]; !ok {
+			return nil, fmt.Errorf("unknown SPDK instance %s of placement rule %s", rule.Instance, rule.Prefix)
+		}
+	}
+	names := make([]string, 0, len(instances))
+	for name := range instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &Router{instances: instances, names: names, rules: rules}, nil
+}
+
+// Place returns a context whose calls go to the instance of a resource name, a name whose top
+// level ID matches no rule is placed on DefaultInstance
+func (r *Router) Place(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, instanceKey{}, r.instance(topLevelID(name)))
+}
+
+// instance returns the instance of a top level resource ID
+func (r *Router) instance(id string) string {
+	for _, rule := range r.rules {
+		if strings.HasPrefix(id, rule.Prefix) {
+			return rule.Instance
+		}
+	}
+	return DefaultInstance
+}
+
+// UnaryServerInterceptor places the requests on the instance of their resource, of its parent
+// for the creations of nested resources or of its ID for the creations of top level ones. It
+// comes after the tenant scoping, the rules see the scoped IDs
+func (r *Router) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if id, ok := requestResourceID(info.FullMethod, req); ok {
+			ctx = context.WithValue(ctx, instanceKey{}, r.instance(id))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// requestResourceID returns the top level resource ID of a request, false when the request is
+// made for no resource in particular, i.e. a list of the top level resources
+func requestResourceID(fullMethod string, req interface{}) (string, bool) {
+	if m, ok := req.(interface{ GetName() string }); ok && m.GetName() != "" {
+		return topLevelID(m.GetName()), true
+	}
+	if m, ok := req.(interface{ GetParent() string }); ok && m.GetParent() != "" {
+		return topLevelID(m.GetParent()), true
+	}
+	// i.e. CreateNvmeSubsystem has its ID in GetNvmeSubsystemId
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if !strings.HasPrefix(method, "Create") {
+		return "", false
+	}
+	getter := reflect.ValueOf(req).MethodByName("Get" + strings.TrimPrefix(method, "Create") + "Id")
+	if !getter.IsValid() || getter.Type().NumIn() != 0 || getter.Type().NumOut() != 1 || getter.Type().Out(0).Kind() != reflect.String {
+		return "", false
+	}
+	id := getter.Call(nil)[0].String()
+	// the resources with a generated ID are placed on the default instance
+	return id, id != ""
+}
+
+// topLevelID returns the ID of the top level resource of a name, i.e. subsys0 for
+// //storage.opiproject.org/nvmeSubsystems/subsys0/nvmeControllers/ctrl0
+func topLevelID(name string) string {
+	segments := strings.Split(strings.TrimPrefix(name, servicePrefix), "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[1]
+}
+
+// GetID returns the sequence number of the last call of the default instance
+func (r *Router) GetID() uint64 {
+	return r.instances[DefaultInstance].GetID()
+}
+
+// GetVersion returns the version of the default instance
+func (r *Router) GetVersion(ctx context.Context) string {
+	return r.instances[DefaultInstance].GetVersion(ctx)
+}
+
+// StartUnixListener creates a listener on the socket of the default instance, used in tests
+func (r *Router) StartUnixListener() net.Listener {
+	return r.instances[DefaultInstance].StartUnixListener()
+}
+
+// Call calls a method of the instance of the request, a list made for no resource in particular
+// is made on every instance and their results merged
+func (r *Router) Call(ctx context.Context, method string, args, result interface{}) error {
+	if instance, ok := ctx.Value(instanceKey{}).(string); ok {
+		return r.instances[instance].Call(ctx, method, args, result)
+	}
+	if !strings.HasSuffix(method, "_list") || len(r.instances) == 1 {
+		return r.instances[DefaultInstance].Call(ctx, method, args, result)
+	}
+	// a partial list would hide resources, every instance has to answer, they are called in
+	// parallel
+	results, err := fanout.Map(ctx, len(r.names), r.names, func(ctx context.Context, name string) (json.RawMessage, error) {
+		var raw json.RawMessage
+		if err := r.instances[name].Call(ctx, method, args, &raw); err != nil {
+			slog.WarnContext(ctx, "Could not list from SPDK instance", "instance", name, "rpc", method, "error", err)
+			return nil, err
+		}
+		return raw, nil
+	})
+	if err != nil {
+		return err
+	}
+	merged, err := mergeResults(results)
+	if err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	return json.Unmarshal(merged, &result)
+}
+
+// mergeResults concatenates the lists of the results of several instances, either the results
+// themselves or their fields, the other fields are the ones of the first result with a status
+func mergeResults(results []json.RawMessage) (json.RawMessage, error) {
+	merged := make(map[string]json.RawMessage)
+	var lists [][]json.RawMessage
+	for _, result := range results {
+		var list []json.RawMessage
+		if err := json.Unmarshal(result, &list); err == nil {
+			lists = append(lists, list)
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(result, &fields); err != nil {
+			return nil, err
+		}
+		for key, value := range fields {
+			previous, ok := merged[key]
+			if !ok {
+				merged[key] = value
+				continue
+			}
+			var previousList, list []json.RawMessage
+			if json.Unmarshal(previous, &previousList) == nil && json.Unmarshal(value, &list) == nil {
+				merged[key], _ = json.Marshal(append(previousList, list...))
+				continue
+			}
+			// a failed instance fails the list
+			if key == "status" && string(previous) == "0" {
+				merged[key] = value
+			}
+		}
+	}
+	if lists != nil {
+		var all []json.RawMessage
+		for _, list := range lists {
+			all = append(all, list...)
+		}
+		if all == nil {
+			all = []json.RawMessage{}
+		}
+		return json.Marshal(all)
+	}
+	return json.Marshal(merged)
+}
@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package placement spreads the resources of the bridge over several instances of the firmware,
+// i.e. one per NUMA node or group of physical functions, the calls to the firmware go to the
+// instance of the resource of their request and the lists are merged across the instances
+package placement
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"google.golang.org/grpc"
+)
+
+func TestPlacement_ParseRules(t *testing.T) {
+	tests := map[string]struct {
+		in     string
+		out    []Rule
+		errMsg string
+	}{
+		"valid rules": {
+			in:     "numa1-=numa1, acme-=numa1",
+			out:    []Rule{{Prefix: "numa1-", Instance: "numa1"}, {Prefix: "acme-", Instance: "numa1"}},
+			errMsg: "",
+		},
+		"missing instance": {
+			in:     "numa1-",
+			out:    nil,
+			errMsg: "invalid placement rule \"numa1-\", have to be prefix=instance",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules, err := ParseRules(tt.in)
+			if !reflect.DeepEqual(rules, tt.out) {
+				t.Error("rules: expected", tt.out, "received", rules)
+			}
+			if tt.errMsg == "" && err != nil || tt.errMsg != "" && (err == nil || err.Error() != tt.errMsg) {
+				t.Error("error: expected", tt.errMsg, "received", err)
+			}
+		})
+	}
+}
+
+func TestPlacement_ParseInstances(t *testing.T) {
+	tests := map[string]struct {
+		in     string
+		out    map[string]string
+		errMsg string
+	}{
+		"valid instances": {
+			in:     "numa0=/var/tmp/spdk0.sock,numa1=10.1.1.2:1234",
+			out:    map[string]string{"numa0": "/var/tmp/spdk0.sock", "numa1": "10.1.1.2:1234"},
+			errMsg: "",
+		},
+		"default instance": {
+			in:     "default=/var/tmp/spdk0.sock",
+			out:    nil,
+			errMsg: "invalid SPDK instance \"default=/var/tmp/spdk0.sock\", have to be name=address with a name other than default",
+		},
+		"duplicated instance": {
+			in:     "numa1=/var/tmp/spdk0.sock,numa1=/var/tmp/spdk1.sock",
+			out:    nil,
+			errMsg: "invalid SPDK instance \"numa1=/var/tmp/spdk1.sock\", numa1 is already defined",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			instances, err := ParseInstances(tt.in)
+			if !reflect.DeepEqual(instances, tt.out) {
+				t.Error("instances: expected", tt.out, "received", instances)
+			}
+			if tt.errMsg == "" && err != nil || tt.errMsg != "" && (err == nil || err.Error() != tt.errMsg) {
+				t.Error("error: expected", tt.errMsg, "received", err)
+			}
+		})
+	}
+}
+
+func TestPlacement_NewRouter(t *testing.T) {
+	_, err := NewRouter(map[string]spdk.JSONRPC{DefaultInstance: nil}, []Rule{{Prefix: "numa1-", Instance: "numa1"}})
+	if err == nil || err.Error() != "unknown SPDK instance numa1 of placement rule numa1-" {
+		t.Error("error: expected unknown SPDK instance, received", err)
+	}
+}
+
+func TestPlacement_Call(t *testing.T) {
+	tests := map[string]struct {
+		name        string
+		method      string
+		defaultSpdk []string
+		numa1Spdk   []string
+		out         map[string]interface{}
+		errMsg      string
+	}{
+		"resource placed by a rule": {
+			name:        "//storage.opiproject.org/nvmeSubsystems/numa1-subsys0/nvmeControllers/ctrl0",
+			method:      "mrvl_nvm_ctrlr_get_info",
+			defaultSpdk: []string{},
+			numa1Spdk:   []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"ctrlr_id":1}}`},
+			out:         map[string]interface{}{"status": float64(0), "ctrlr_id": float64(1)},
+			errMsg:      "",
+		},
+		"resource placed by no rule": {
+			name:        "//storage.opiproject.org/nvmeSubsystems/subsys0",
+			method:      "mrvl_nvm_subsys_get_ctrlr_list",
+			defaultSpdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"ctrlr_id_list":[{"ctrlr_id":2}]}}`},
+			numa1Spdk:   []string{},
+			out:         map[string]interface{}{"status": float64(0), "ctrlr_id_list": []interface{}{map[string]interface{}{"ctrlr_id": float64(2)}}},
+			errMsg:      "",
+		},
+		"list merged across the instances": {
+			name:        "",
+			method:      "mrvl_nvm_get_subsys_list",
+			defaultSpdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"subsys_list":[{"subnqn":"nqn.2022-09.io.spdk:opi0"}]}}`},
+			numa1Spdk:   []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"subsys_list":[{"subnqn":"nqn.2022-09.io.spdk:opi1"}]}}`},
+			out: map[string]interface{}{"status": float64(0), "subsys_list": []interface{}{
+				map[string]interface{}{"subnqn": "nqn.2022-09.io.spdk:opi0"},
+				map[string]interface{}{"subnqn": "nqn.2022-09.io.spdk:opi1"},
+			}},
+			errMsg: "",
+		},
+		"list failed on an instance": {
+			name:        "",
+			method:      "mrvl_nvm_get_subsys_list",
+			defaultSpdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"subsys_list":[]}}`},
+			numa1Spdk:   []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status":1,"subsys_list":[]}}`},
+			out:         map[string]interface{}{"status": float64(1), "subsys_list": []interface{}{}},
+			errMsg:      "",
+		},
+		"list not answered by an instance": {
+			name:        "",
+			method:      "mrvl_nvm_get_subsys_list",
+			defaultSpdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status":0,"subsys_list":[]}}`},
+			numa1Spdk:   []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status":1}}`},
+			out:         nil,
+			errMsg:      "mrvl_nvm_get_subsys_list: json response error: myopierr",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defaultSocket := utils.GenerateSocketName("placement")
+			defaultLn, defaultRPC := utils.CreateTestSpdkServer(defaultSocket, tt.defaultSpdk)
+			defer utils.CloseListener(defaultLn)
+			numa1Socket := utils.GenerateSocketName("placement")
+			numa1Ln, numa1RPC := utils.CreateTestSpdkServer(numa1Socket, tt.numa1Spdk)
+			defer utils.CloseListener(numa1Ln)
+			router, err := NewRouter(map[string]spdk.JSONRPC{DefaultInstance: defaultRPC, "numa1": numa1RPC}, []Rule{{Prefix: "numa1-", Instance: "numa1"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			if tt.name != "" {
+				ctx = router.Place(ctx, tt.name)
+			}
+
+			var result map[string]interface{}
+			err = router.Call(ctx, tt.method, nil, &result)
+
+			if !reflect.DeepEqual(result, tt.out) {
+				t.Error("result: expected", tt.out, "received", result)
+			}
+			if tt.errMsg == "" && err != nil || tt.errMsg != "" && (err == nil || err.Error() != tt.errMsg) {
+				t.Error("error: expected", tt.errMsg, "received", err)
+			}
+		})
+	}
+}
+
+func TestPlacement_UnaryServerInterceptor(t *testing.T) {
+	tests := map[string]struct {
+		method   string
+		req      interface{}
+		instance string
+	}{
+		"creation of a top level resource": {
+			method:   "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem",
+			req:      &pb.CreateNvmeSubsystemRequest{NvmeSubsystemId: "numa1-subsys0"},
+			instance: "numa1",
+		},
+		"creation of a nested resource": {
+			method:   "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeController",
+			req:      &pb.CreateNvmeControllerRequest{Parent: "//storage.opiproject.org/nvmeSubsystems/numa1-subsys0"},
+			instance: "numa1",
+		},
+		"resource placed by no rule": {
+			method:   "/opi_api.storage.v1.FrontendNvmeService/DeleteNvmeSubsystem",
+			req:      &pb.DeleteNvmeSubsystemRequest{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0"},
+			instance: DefaultInstance,
+		},
+		"creation with a generated ID": {
+			method:   "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem",
+			req:      &pb.CreateNvmeSubsystemRequest{},
+			instance: "",
+		},
+		"list of top level resources": {
+			method:   "/opi_api.storage.v1.FrontendNvmeService/ListNvmeSubsystems",
+			req:      &pb.ListNvmeSubsystemsRequest{},
+			instance: "",
+		},
+	}
+
+	router, err := NewRouter(map[string]spdk.JSONRPC{DefaultInstance: nil, "numa1": nil}, []Rule{{Prefix: "numa1-", Instance: "numa1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var instance string
+			_, _ = router.UnaryServerInterceptor()(context.Background(), tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				instance, _ = ctx.Value(instanceKey{}).(string)
+				return nil, nil
+			})
+			if instance != tt.instance {
+				t.Error("instance: expected", tt.instance, "received", instance)
+			}
+		})
+	}
+}
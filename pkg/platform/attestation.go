@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sizes of the nonces of the attestations, they keep an evidence from being replayed
+const (
+	minNonceSize = 16
+	maxNonceSize = 64
+)
+
+// attestationKey signs the evidences of the bridge
+type attestationKey struct {
+	signer crypto.Signer
+	// certificates of the key, DER, leaf first
+	certificates [][]byte
+	// bridgeDigest is the SHA-256 of the binary of the bridge, measured when the key is loaded
+	bridgeDigest string
+}
+
+// GetDpuAttestationRequest represents a request to attest the firmware of the DPU
+type GetDpuAttestationRequest struct {
+	// Nonce chosen by the verifier, 16 to 64 bytes, base64 in JSON
+	Nonce []byte `json:"nonce"`
+}
+
+// DpuMeasurement represents an SPDM measurement of the firmware, i.e. the digest of an image
+type DpuMeasurement struct {
+	// Index of the measurement block
+	Index int32 `json:"index"`
+	// Description of the measured component, i.e. firmware
+	Description string `json:"description"`
+	// Digest of the component, in hexadecimal
+	Digest string `json:"digest"`
+}
+
+// DpuSpdmEvidence represents the SPDM measurements of the DPU, signed by its root of trust
+type DpuSpdmEvidence struct {
+	// Version of SPDM, i.e. 1.2
+	Version string `json:"version"`
+	// Measurements of the firmware
+	Measurements []*DpuMeasurement `json:"measurements"`
+	// Response is the SPDM MEASUREMENTS response for the nonce, as signed
+	Response []byte `json:"response"`
+	// Signature of Response by the root of trust of the DPU
+	Signature []byte `json:"signature"`
+	// CertificateChain of the root of trust, in the SPDM format
+	CertificateChain []byte `json:"certificateChain"`
+}
+
+// DpuEvidence represents the measurements of the DPU serving the volumes of a tenant
+type DpuEvidence struct {
+	// Nonce of the request
+	Nonce []byte `json:"nonce"`
+	// Time the evidence was collected
+	Time time.Time `json:"time"`
+	// BridgeDigest is the SHA-256 of the binary of the bridge, in hexadecimal
+	BridgeDigest string `json:"bridgeDigest"`
+	// SpdkVersion is the version of the SPDK application of the firmware
+	SpdkVersion string `json:"spdkVersion"`
+	// FirmwareVersion is the version of the Marvell firmware
+	FirmwareVersion string `json:"firmwareVersion"`
+	// Spdm are the measurements of the firmware by the root of trust of the DPU
+	Spdm *DpuSpdmEvidence `json:"spdm"`
+}
+
+// DpuAttestation represents an evidence signed by the bridge, a tenant verifies the certificates,
+// the signature and the nonce before trusting the DPU with its keys
+type DpuAttestation struct {
+	// Evidence is the JSON of a DpuEvidence, as signed
+	Evidence []byte `json:"evidence"`
+	// Signature of Evidence with the attestation key of the bridge, of its SHA-256 for ECDSA and
+	// RSA keys
+	Signature []byte `json:"signature"`
+	// Certificates of the attestation key, DER, leaf first
+	Certificates [][]byte `json:"certificates"`
+}
+
+// LoadAttestationKey loads the key signing the evidences and its certificates from PEM files, the
+// binary of the bridge is measured too
+func (s *Server) LoadAttestationKey(keyFile string, certFile string) error {
+	keyPEM, err := os.ReadFile(filepath.Clean(keyFile))
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return fmt.Errorf("invalid attestation key %s, have to be PEM", keyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return fmt.Errorf("invalid attestation key %s: %w", keyFile, err)
+		}
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("invalid attestation key %s, can't sign", keyFile)
+	}
+	certPEM, err := os.ReadFile(filepath.Clean(certFile))
+	if err != nil {
+		return err
+	}
+	var certificates [][]byte
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			certificates = append(certificates, block.Bytes)
+		}
+	}
+	if len(certificates) == 0 {
+		return fmt.Errorf("invalid attestation certificate %s, have to be PEM", certFile)
+	}
+	leaf, err := x509.ParseCertificate(certificates[0])
+	if err != nil {
+		return fmt.Errorf("invalid attestation certificate %s: %w", certFile, err)
+	}
+	if public, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !public.Equal(signer.Public()) {
+		return fmt.Errorf("invalid attestation certificate %s, not the one of the key", certFile)
+	}
+	bridgeDigest, err := measureExecutable()
+	if err != nil {
+		return err
+	}
+	s.attestationKey = &attestationKey{signer: signer, certificates: certificates, bridgeDigest: bridgeDigest}
+	return nil
+}
+
+// measureExecutable returns the SHA-256 of the binary running, in hexadecimal
+func measureExecutable() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(filepath.Clean(executable))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GetDpuAttestation collects the SPDM measurements of the firmware for the nonce of a verifier,
+// the versions of the firmware and the measurement of the bridge, and signs them
+func (s *Server) GetDpuAttestation(ctx context.Context, in *GetDpuAttestationRequest) (*DpuAttestation, error) {
+	if len(in.Nonce) < minNonceSize || len(in.Nonce) > maxNonceSize {
+		msg := fmt.Sprintf("Nonce size (%d) is invalid, have to be between %d and %d bytes", len(in.Nonce), minNonceSize, maxNonceSize)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	if s.attestationKey == nil {
+		msg := "No attestation key is configured"
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	params := models.MrvlPlatformGetMeasurementsParams{
+		Nonce: hex.EncodeToString(in.Nonce),
+	}
+	var result models.MrvlPlatformGetMeasurementsResult
+	err := s.rpc.Call(ctx, "mrvl_platform_get_measurements", &params, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not get DPU measurements"
+		return nil, result.Status.Err(codes.Unavailable, msg, "mrvl_platform_get_measurements")
+	}
+	spdm, err := newDpuSpdmEvidence(&result)
+	if err != nil {
+		msg := fmt.Sprintf("Could not decode DPU measurements: %v", err)
+		return nil, status.Errorf(codes.Internal, msg)
+	}
+	inventory, err := s.GetDpuInventory(ctx, &GetDpuInventoryRequest{})
+	if err != nil {
+		return nil, err
+	}
+	evidence, err := json.Marshal(&DpuEvidence{
+		Nonce:           in.Nonce,
+		Time:            time.Now().UTC(),
+		BridgeDigest:    s.attestationKey.bridgeDigest,
+		SpdkVersion:     s.rpc.GetVersion(ctx),
+		FirmwareVersion: inventory.FirmwareVersion,
+		Spdm:            spdm,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := s.attestationKey.sign(evidence)
+	if err != nil {
+		msg := fmt.Sprintf("Could not sign DPU evidence: %v", err)
+		return nil, status.Errorf(codes.Internal, msg)
+	}
+	return &DpuAttestation{
+		Evidence:     evidence,
+		Signature:    signature,
+		Certificates: s.attestationKey.certificates,
+	}, nil
+}
+
+// newDpuSpdmEvidence decodes the SPDM measurements of the firmware
+func newDpuSpdmEvidence(result *models.MrvlPlatformGetMeasurementsResult) (*DpuSpdmEvidence, error) {
+	spdm := &DpuSpdmEvidence{
+		Version:      result.SpdmVersion,
+		Measurements: make([]*DpuMeasurement, 0, len(result.Measurements)),
+	}
+	for _, measurement := range result.Measurements {
+		spdm.Measurements = append(spdm.Measurements, &DpuMeasurement{
+			Index:       int32(measurement.Index),
+			Description: measurement.Description,
+			Digest:      measurement.Digest,
+		})
+	}
+	var err error
+	if spdm.Response, err = base64.StdEncoding.DecodeString(result.Response); err != nil {
+		return nil, errors.New("invalid SPDM response")
+	}
+	if spdm.Signature, err = base64.StdEncoding.DecodeString(result.Signature); err != nil {
+		return nil, errors.New("invalid SPDM signature")
+	}
+	if spdm.CertificateChain, err = base64.StdEncoding.DecodeString(result.CertChain); err != nil {
+		return nil, errors.New("invalid SPDM certificate chain")
+	}
+	return spdm, nil
+}
+
+// sign signs an evidence, Ed25519 keys sign it as is, the other keys its SHA-256
+func (k *attestationKey) sign(evidence []byte) ([]byte, error) {
+	if _, ok := k.signer.(ed25519.PrivateKey); ok {
+		return k.signer.Sign(rand.Reader, evidence, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(evidence)
+	return k.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
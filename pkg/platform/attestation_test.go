@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// createTestAttestationKey writes a P-256 key and its self signed certificate as PEM files
+func createTestAttestationKey(t *testing.T, dir string) (*ecdsa.PrivateKey, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "opi-marvell-bridge attestation"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "attestation.key")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "attestation.crt")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return key, keyFile, certFile
+}
+
+func TestPlatform_LoadAttestationKey(t *testing.T) {
+	_, keyFile, _ := createTestAttestationKey(t, t.TempDir())
+	_, _, otherCertFile := createTestAttestationKey(t, t.TempDir())
+	testEnv := createTestEnvironment([]string{}, "")
+	defer testEnv.Close()
+
+	err := testEnv.opiSpdkServer.LoadAttestationKey(keyFile, otherCertFile)
+	expected := "invalid attestation certificate " + otherCertFile + ", not the one of the key"
+	if err == nil || err.Error() != expected {
+		t.Error("error: expected", expected, "received", err)
+	}
+	if testEnv.opiSpdkServer.attestationKey != nil {
+		t.Error("attestation key: expected none, received", testEnv.opiSpdkServer.attestationKey)
+	}
+}
+
+func TestPlatform_GetDpuAttestation(t *testing.T) {
+	testMeasurementsResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "spdm_version": "1.2", ` +
+		`"measurements": [{"index": 1, "description": "firmware", "digest": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}], ` +
+		`"response": "AQID", "signature": "BAUG", "cert_chain": "BwgJ"}}`
+	testInventoryResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "sku": "CN106XX", "fw_version": "1.4.2", "sdk_version": "SDK12.23.11"}}`
+	testSkuCapsResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "max_pf_msix_vectors": 128, "max_vf_msix_vectors": 32}}`
+	testVersionResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"version": "SPDK v21.01.1", "fields": {"major": 21, "minor": 1, "patch": 1, "suffix": ""}}}`
+	testNonce := []byte("0123456789abcdef")
+	tests := map[string]struct {
+		in      *GetDpuAttestationRequest
+		key     bool
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"nonce too short": {
+			in:      &GetDpuAttestationRequest{Nonce: []byte("0123")},
+			key:     true,
+			spdk:    []string{},
+			errCode: codes.InvalidArgument,
+			errMsg:  "Nonce size (4) is invalid, have to be between 16 and 64 bytes",
+		},
+		"no attestation key": {
+			in:      &GetDpuAttestationRequest{Nonce: testNonce},
+			key:     false,
+			spdk:    []string{},
+			errCode: codes.FailedPrecondition,
+			errMsg:  "No attestation key is configured",
+		},
+		"valid request with invalid SPDK response": {
+			in:      &GetDpuAttestationRequest{Nonce: testNonce},
+			key:     true,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.Unavailable,
+			errMsg:  "Could not get DPU measurements",
+		},
+		"valid request with invalid SPDM signature": {
+			in:      &GetDpuAttestationRequest{Nonce: testNonce},
+			key:     true,
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "response": "AQID", "signature": "not base64"}}`},
+			errCode: codes.Internal,
+			errMsg:  "Could not decode DPU measurements: invalid SPDM signature",
+		},
+		"valid request with valid SPDK response": {
+			in:      &GetDpuAttestationRequest{Nonce: testNonce},
+			key:     true,
+			spdk:    []string{testMeasurementsResponse, testInventoryResponse, testSkuCapsResponse, testVersionResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk, t.TempDir())
+			defer testEnv.Close()
+			key, keyFile, certFile := createTestAttestationKey(t, t.TempDir())
+			if tt.key {
+				if err := testEnv.opiSpdkServer.LoadAttestationKey(keyFile, certFile); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			response, err := testEnv.opiSpdkServer.GetDpuAttestation(testEnv.ctx, tt.in)
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+			if tt.errCode != codes.OK {
+				if response != nil {
+					t.Error("response: expected none, received", response)
+				}
+				return
+			}
+
+			digest := sha256.Sum256(response.Evidence)
+			if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], response.Signature) {
+				t.Error("signature: expected valid, received", response.Signature)
+			}
+			if len(response.Certificates) != 1 {
+				t.Error("certificates: expected 1, received", len(response.Certificates))
+			}
+			var evidence DpuEvidence
+			if err := json.Unmarshal(response.Evidence, &evidence); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(evidence.Nonce, testNonce) {
+				t.Error("nonce: expected", testNonce, "received", evidence.Nonce)
+			}
+			if evidence.SpdkVersion != "SPDK v21.01.1" || evidence.FirmwareVersion != "1.4.2" {
+				t.Error("versions: expected SPDK v21.01.1 and 1.4.2, received", evidence.SpdkVersion, evidence.FirmwareVersion)
+			}
+			if len(evidence.BridgeDigest) != 64 {
+				t.Error("bridge digest: expected a SHA-256, received", evidence.BridgeDigest)
+			}
+			if evidence.Spdm == nil || evidence.Spdm.Version != "1.2" || len(evidence.Spdm.Measurements) != 1 ||
+				!bytes.Equal(evidence.Spdm.Response, []byte{1, 2, 3}) || !bytes.Equal(evidence.Spdm.Signature, []byte{4, 5, 6}) ||
+				!bytes.Equal(evidence.Spdm.CertificateChain, []byte{7, 8, 9}) {
+				t.Error("SPDM evidence: expected the measurements of the firmware, received", evidence.Spdm)
+			}
+		})
+	}
+}
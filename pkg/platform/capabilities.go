@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+	"slices"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/sku"
+)
+
+// Reasons a capability isn't supported
+const (
+	notSupportedByBridge   = "not supported by the bridge"
+	notSupportedByFirmware = "not supported by the firmware"
+	notSupportedBySku      = "not supported by the SKU profile"
+)
+
+// bridgeCapabilities are the optional features the bridge supports or not whatever the firmware
+var bridgeCapabilities = []*Capability{
+	{Name: "nvme-pcie", Supported: true},
+	{Name: "nvme-tcp", Supported: false, Reason: notSupportedByBridge},
+	{Name: "nvme-zns", Supported: false, Reason: notSupportedByBridge},
+}
+
+// firmwareCapabilities are the optional features the bridge supports when the firmware reports
+// them in its features
+var firmwareCapabilities = []string{"virtio-blk", "crypto", "compress", "dedup"}
+
+// GetCapabilitiesRequest represents a request to get the capabilities of the bridge and of its
+// firmware
+type GetCapabilitiesRequest struct{}
+
+// Capability represents an optional feature
+type Capability struct {
+	// Name of the feature, i.e. nvme-tcp or crypto
+	Name string `json:"name"`
+	// Supported is set when the bridge and the firmware both support the feature
+	Supported bool `json:"supported"`
+	// Reason the feature isn't supported, empty when it is
+	Reason string `json:"reason,omitempty"`
+}
+
+// CapabilityLimits represents the limits of the firmware on the resources
+type CapabilityLimits struct {
+	// PhysicalFunctions is the number of PCIe physical functions the controllers can be created on
+	PhysicalFunctions int32 `json:"physicalFunctions"`
+	// MaxVirtualFunctions is the number of virtual functions of the physical function having the most
+	MaxVirtualFunctions int32 `json:"maxVirtualFunctions"`
+	// MaxMsixVectors is the number of MSI-X vectors a controller of a physical function can have
+	MaxMsixVectors int32 `json:"maxMsixVectors"`
+	// MaxVirtualFunctionMsixVectors is the number of MSI-X vectors a controller of a virtual
+	// function can have
+	MaxVirtualFunctionMsixVectors int32 `json:"maxVirtualFunctionMsixVectors"`
+	// MaxSubsystems is the number of Nvme subsystems the SKU profile allows, 0 when unlimited
+	MaxSubsystems int32 `json:"maxSubsystems,omitempty"`
+	// MaxControllers is the number of Nvme controllers the SKU profile allows, 0 when unlimited
+	MaxControllers int32 `json:"maxControllers,omitempty"`
+	// MaxNamespaces is the number of Nvme namespaces the SKU profile allows, 0 when unlimited
+	MaxNamespaces int32 `json:"maxNamespaces,omitempty"`
+}
+
+// Capabilities represents the optional features the bridge and its firmware support, so the
+// clients can adapt to them instead of probing with failing calls
+type Capabilities struct {
+	// Capabilities are the optional features, supported or not
+	Capabilities []*Capability `json:"capabilities"`
+	// Limits of the firmware
+	Limits *CapabilityLimits `json:"limits"`
+	// Profile is the name of the profile of the SKU, empty when the bridge has none
+	Profile string `json:"profile,omitempty"`
+}
+
+// GetCapabilities gets the optional features the bridge and its firmware support, and the
+// limits of the firmware
+func (s *Server) GetCapabilities(ctx context.Context, _ *GetCapabilitiesRequest) (*Capabilities, error) {
+	inventory, err := s.GetDpuInventory(ctx, &GetDpuInventoryRequest{})
+	if err != nil {
+		return nil, err
+	}
+	capabilities := &Capabilities{
+		Capabilities: make([]*Capability, 0, len(bridgeCapabilities)+len(firmwareCapabilities)),
+		Limits:       &CapabilityLimits{PhysicalFunctions: int32(len(inventory.PhysicalFunctions))},
+	}
+	for _, capability := range bridgeCapabilities {
+		c := *capability
+		capabilities.Capabilities = append(capabilities.Capabilities, &c)
+	}
+	var profile *sku.Profile
+	if s.skuSelector != nil {
+		profile = s.skuSelector.Select(inventory.Sku)
+		capabilities.Profile = profile.Name
+		capabilities.Limits.MaxSubsystems = profile.Limits.MaxSubsystems
+		capabilities.Limits.MaxControllers = profile.Limits.MaxControllers
+		capabilities.Limits.MaxNamespaces = profile.Limits.MaxNamespaces
+	}
+	for _, name := range firmwareCapabilities {
+		capability := &Capability{Name: name, Supported: slices.Contains(inventory.Features, name)}
+		switch {
+		case !capability.Supported:
+			capability.Reason = notSupportedByFirmware
+		case profile != nil && profile.Features != nil && !slices.Contains(profile.Features, name):
+			capability.Supported = false
+			capability.Reason = notSupportedBySku
+		}
+		capabilities.Capabilities = append(capabilities.Capabilities, capability)
+	}
+	for _, pf := range inventory.PhysicalFunctions {
+		capabilities.Limits.MaxVirtualFunctions = max(capabilities.Limits.MaxVirtualFunctions, pf.MaxVirtualFunctions)
+		capabilities.Limits.MaxMsixVectors = pf.MaxMsixVectors
+		capabilities.Limits.MaxVirtualFunctionMsixVectors = pf.MaxVirtualFunctionMsixVectors
+	}
+	return capabilities, nil
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/sku"
+)
+
+func TestPlatform_GetCapabilities(t *testing.T) {
+	testInventoryResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "sku": "CN106XX", ` +
+		`"pfs": [{"pf_id": 0, "max_vfs": 64, "num_vfs": 16}, {"pf_id": 1, "max_vfs": 128, "num_vfs": 0}], ` +
+		`"features": ["crypto", "virtio-blk"]}}`
+	testSkuCapsResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "max_pf_msix_vectors": 128, "max_vf_msix_vectors": 32}}`
+	tests := map[string]struct {
+		out      *Capabilities
+		profiles []*sku.Profile
+		spdk     []string
+		errCode  codes.Code
+		errMsg   string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.Unavailable,
+			errMsg:  "Could not get DPU inventory",
+		},
+		"valid request with valid SPDK response": {
+			out: &Capabilities{
+				Capabilities: []*Capability{
+					{Name: "nvme-pcie", Supported: true},
+					{Name: "nvme-tcp", Supported: false, Reason: "not supported by the bridge"},
+					{Name: "nvme-zns", Supported: false, Reason: "not supported by the bridge"},
+					{Name: "virtio-blk", Supported: true},
+					{Name: "crypto", Supported: true},
+					{Name: "compress", Supported: false, Reason: "not supported by the firmware"},
+					{Name: "dedup", Supported: false, Reason: "not supported by the firmware"},
+				},
+				Limits: &CapabilityLimits{
+					PhysicalFunctions:             2,
+					MaxVirtualFunctions:           128,
+					MaxMsixVectors:                128,
+					MaxVirtualFunctionMsixVectors: 32,
+				},
+			},
+			spdk:    []string{testInventoryResponse, testSkuCapsResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request with a SKU profile": {
+			out: &Capabilities{
+				Capabilities: []*Capability{
+					{Name: "nvme-pcie", Supported: true},
+					{Name: "nvme-tcp", Supported: false, Reason: "not supported by the bridge"},
+					{Name: "nvme-zns", Supported: false, Reason: "not supported by the bridge"},
+					{Name: "virtio-blk", Supported: false, Reason: "not supported by the SKU profile"},
+					{Name: "crypto", Supported: true},
+					{Name: "compress", Supported: false, Reason: "not supported by the firmware"},
+					{Name: "dedup", Supported: false, Reason: "not supported by the firmware"},
+				},
+				Limits: &CapabilityLimits{
+					PhysicalFunctions:             2,
+					MaxVirtualFunctions:           128,
+					MaxMsixVectors:                128,
+					MaxVirtualFunctionMsixVectors: 32,
+					MaxSubsystems:                 16,
+					MaxNamespaces:                 256,
+				},
+				Profile: "cn106",
+			},
+			profiles: []*sku.Profile{{Name: "cn106", Skus: []string{"CN106*"}, Features: []string{"crypto", "compress"}, Limits: sku.Limits{MaxSubsystems: 16, MaxNamespaces: 256}}},
+			spdk:     []string{testInventoryResponse, testSkuCapsResponse},
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk, t.TempDir())
+			defer testEnv.Close()
+			if tt.profiles != nil {
+				selector, err := sku.NewSelector(testEnv.opiSpdkServer.rpc, tt.profiles, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				testEnv.opiSpdkServer.SetSkuSelector(selector)
+			}
+
+			response, err := testEnv.opiSpdkServer.GetCapabilities(testEnv.ctx, &GetCapabilitiesRequest{})
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// GetDpuInventoryRequest represents a request to get the hardware inventory of the DPU
+type GetDpuInventoryRequest struct{}
+
+// DpuPhysicalFunction represents a PCIe physical function of the DPU and its virtual functions
+type DpuPhysicalFunction struct {
+	// ID of the physical function
+	ID int32 `json:"id"`
+	// MaxVirtualFunctions is the number of virtual functions the physical function supports
+	MaxVirtualFunctions int32 `json:"maxVirtualFunctions"`
+	// VirtualFunctions is the number of virtual functions enabled
+	VirtualFunctions int32 `json:"virtualFunctions"`
+	// MaxMsixVectors is the number of MSI-X vectors a controller of the physical function can have
+	MaxMsixVectors int32 `json:"maxMsixVectors"`
+	// MaxVirtualFunctionMsixVectors is the number of MSI-X vectors a controller of one of its
+	// virtual functions can have
+	MaxVirtualFunctionMsixVectors int32 `json:"maxVirtualFunctionMsixVectors"`
+}
+
+// DpuDrive represents a physical drive attached to the DPU
+type DpuDrive struct {
+	// Device is the name of the drive, as in the firmware and Opal methods, i.e. Nvme0
+	Device string `json:"device"`
+	// PcieAddress is the PCIe address of the drive, i.e. 0000:01:00.0
+	PcieAddress string `json:"pcieAddress"`
+	// Model of the drive
+	Model string `json:"model"`
+	// SerialNumber of the drive
+	SerialNumber string `json:"serialNumber"`
+	// FirmwareRevision is the firmware running on the drive
+	FirmwareRevision string `json:"firmwareRevision"`
+	// SizeBytes is the capacity of the drive
+	SizeBytes uint64 `json:"sizeBytes"`
+}
+
+// DpuInventory represents the hardware and software of a DPU, to place the workloads per card
+type DpuInventory struct {
+	// Sku is the Marvell SKU of the card, i.e. CN106XX
+	Sku string `json:"sku"`
+	// SerialNumber of the card
+	SerialNumber string `json:"serialNumber"`
+	// FirmwareVersion is the version of the Marvell firmware
+	FirmwareVersion string `json:"firmwareVersion"`
+	// SdkVersion is the version of the Marvell SDK the firmware is built with
+	SdkVersion string `json:"sdkVersion"`
+	// PhysicalFunctions are the PCIe physical functions the card exposes to the hosts
+	PhysicalFunctions []*DpuPhysicalFunction `json:"physicalFunctions"`
+	// Drives are the physical drives attached to the card
+	Drives []*DpuDrive `json:"drives"`
+	// Features are the optional features of the firmware, i.e. crypto or compress
+	Features []string `json:"features"`
+}
+
+// GetDpuInventory gets the SKU, versions, PCIe functions, drives and features of the DPU
+func (s *Server) GetDpuInventory(ctx context.Context, _ *GetDpuInventoryRequest) (*DpuInventory, error) {
+	var result models.MrvlPlatformGetInventoryResult
+	err := s.rpc.Call(ctx, "mrvl_platform_get_inventory", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not get DPU inventory"
+		return nil, result.Status.Err(codes.Unavailable, msg, "mrvl_platform_get_inventory")
+	}
+	var caps models.MrvlNvmGetSkuCapsResult
+	err = s.rpc.Call(ctx, "mrvl_nvm_get_sku_caps", nil, &caps)
+	if err != nil {
+		return nil, err
+	}
+	if caps.Status != 0 {
+		msg := "Could not get SKU capabilities"
+		return nil, caps.Status.Err(codes.Unavailable, msg, "mrvl_nvm_get_sku_caps")
+	}
+	inventory := &DpuInventory{
+		Sku:               result.Sku,
+		SerialNumber:      result.SerialNumber,
+		FirmwareVersion:   result.FwVersion,
+		SdkVersion:        result.SdkVersion,
+		PhysicalFunctions: make([]*DpuPhysicalFunction, 0, len(result.Pfs)),
+		Drives:            make([]*DpuDrive, 0, len(result.Drives)),
+		Features:          result.Features,
+	}
+	if inventory.Features == nil {
+		inventory.Features = []string{}
+	}
+	for _, pf := range result.Pfs {
+		inventory.PhysicalFunctions = append(inventory.PhysicalFunctions, &DpuPhysicalFunction{
+			ID:                            int32(pf.PfID),
+			MaxVirtualFunctions:           int32(pf.MaxVfs),
+			VirtualFunctions:              int32(pf.NumVfs),
+			MaxMsixVectors:                int32(caps.MaxPfMsixVectors),
+			MaxVirtualFunctionMsixVectors: int32(caps.MaxVfMsixVectors),
+		})
+	}
+	for _, drive := range result.Drives {
+		inventory.Drives = append(inventory.Drives, &DpuDrive{
+			Device:           drive.Device,
+			PcieAddress:      drive.PcieAddr,
+			Model:            drive.Model,
+			SerialNumber:     drive.Serial,
+			FirmwareRevision: drive.FwRev,
+			SizeBytes:        drive.SizeBytes,
+		})
+	}
+	return inventory, nil
+}
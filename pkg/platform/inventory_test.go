@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPlatform_GetDpuInventory(t *testing.T) {
+	testInventoryResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, ` +
+		`"sku": "CN106XX", "serial_number": "WA-1234", "fw_version": "1.4.2", "sdk_version": "SDK12.23.11", ` +
+		`"pfs": [{"pf_id": 0, "max_vfs": 64, "num_vfs": 16}], ` +
+		`"drives": [{"device": "Nvme0", "pcie_addr": "0000:01:00.0", "model": "PM1733", "serial": "S4YN", "fw_rev": "EPK9", "size_bytes": 3840755982336}], ` +
+		`"features": ["crypto", "compress"]}}`
+	testSkuCapsResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "max_pf_msix_vectors": 128, "max_vf_msix_vectors": 32}}`
+	tests := map[string]struct {
+		out     *DpuInventory
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.Unavailable,
+			errMsg:  "Could not get DPU inventory",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_platform_get_inventory: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_platform_get_inventory: %v", "json response error: myopierr"),
+		},
+		"valid request with invalid SPDK SKU capabilities response": {
+			out:     nil,
+			spdk:    []string{testInventoryResponse, testFailureResponse},
+			errCode: codes.Unavailable,
+			errMsg:  "Could not get SKU capabilities",
+		},
+		"valid request with valid SPDK response": {
+			out: &DpuInventory{
+				Sku:             "CN106XX",
+				SerialNumber:    "WA-1234",
+				FirmwareVersion: "1.4.2",
+				SdkVersion:      "SDK12.23.11",
+				PhysicalFunctions: []*DpuPhysicalFunction{
+					{ID: 0, MaxVirtualFunctions: 64, VirtualFunctions: 16, MaxMsixVectors: 128, MaxVirtualFunctionMsixVectors: 32},
+				},
+				Drives: []*DpuDrive{
+					{Device: "Nvme0", PcieAddress: "0000:01:00.0", Model: "PM1733", SerialNumber: "S4YN", FirmwareRevision: "EPK9", SizeBytes: 3840755982336},
+				},
+				Features: []string{"crypto", "compress"},
+			},
+			spdk:    []string{testInventoryResponse, testSkuCapsResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid request without drives nor features": {
+			out: &DpuInventory{
+				Sku:               "CN103XX",
+				PhysicalFunctions: []*DpuPhysicalFunction{},
+				Drives:            []*DpuDrive{},
+				Features:          []string{},
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "sku": "CN103XX"}}`, testSkuCapsResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk, t.TempDir())
+			defer testEnv.Close()
+
+			response, err := testEnv.opiSpdkServer.GetDpuInventory(testEnv.ctx, &GetDpuInventoryRequest{})
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	socTemperatureDesc = prometheus.NewDesc(
+		"opi_dpu_soc_temperature_celsius",
+		"Temperature of the DPU SoC in degrees Celsius.",
+		nil, nil,
+	)
+	powerDesc = prometheus.NewDesc(
+		"opi_dpu_power_watts",
+		"Power drawn by the DPU in watts.",
+		nil, nil,
+	)
+	throttledDesc = prometheus.NewDesc(
+		"opi_dpu_throttled",
+		"Whether the DPU SoC is throttling (1) or not (0).",
+		nil, nil,
+	)
+)
+
+// TelemetryCollector exports the DPU telemetry as Prometheus metrics
+type TelemetryCollector struct {
+	server *Server
+}
+
+// NewTelemetryCollector creates a collector reading the telemetry on every scrape
+func NewTelemetryCollector(server *Server) *TelemetryCollector {
+	return &TelemetryCollector{server: server}
+}
+
+// Describe implements prometheus.Collector
+func (c *TelemetryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- socTemperatureDesc
+	ch <- powerDesc
+	ch <- throttledDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *TelemetryCollector) Collect(ch chan<- prometheus.Metric) {
+	telemetry, err := c.server.GetDpuTelemetry(context.Background(), &GetDpuTelemetryRequest{})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(socTemperatureDesc, err)
+		return
+	}
+	throttled := 0.0
+	if telemetry.Throttled {
+		throttled = 1
+	}
+	ch <- prometheus.MustNewConstMetric(socTemperatureDesc, prometheus.GaugeValue, telemetry.SocTemperatureCelsius)
+	ch <- prometheus.MustNewConstMetric(powerDesc, prometheus.GaugeValue, telemetry.PowerWatts)
+	ch <- prometheus.MustNewConstMetric(throttledDesc, prometheus.GaugeValue, throttled)
+}
+
+// RegisterTelemetryInstruments reports the DPU telemetry to an OpenTelemetry meter, it is read on
+// every collection, i.e. every export to a collector
+func (s *Server) RegisterTelemetryInstruments(meter metric.Meter) (metric.Registration, error) {
+	socTemperature, err := meter.Float64ObservableGauge("opi.dpu.soc.temperature",
+		metric.WithUnit("Cel"), metric.WithDescription("Temperature of the DPU SoC in degrees Celsius."))
+	if err != nil {
+		return nil, err
+	}
+	power, err := meter.Float64ObservableGauge("opi.dpu.power",
+		metric.WithUnit("W"), metric.WithDescription("Power drawn by the DPU in watts."))
+	if err != nil {
+		return nil, err
+	}
+	throttled, err := meter.Int64ObservableGauge("opi.dpu.throttled",
+		metric.WithDescription("Whether the DPU SoC is throttling (1) or not (0)."))
+	if err != nil {
+		return nil, err
+	}
+	callback := func(ctx context.Context, o metric.Observer) error {
+		telemetry, err := s.GetDpuTelemetry(ctx, &GetDpuTelemetryRequest{})
+		if err != nil {
+			return err
+		}
+		o.ObserveFloat64(socTemperature, telemetry.SocTemperatureCelsius)
+		o.ObserveFloat64(power, telemetry.PowerWatts)
+		if telemetry.Throttled {
+			o.ObserveInt64(throttled, 1)
+		} else {
+			o.ObserveInt64(throttled, 0)
+		}
+		return nil
+	}
+	return meter.RegisterCallback(callback, socTemperature, power, throttled)
+}
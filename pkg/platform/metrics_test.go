@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestPlatform_TelemetryCollector(t *testing.T) {
+	testEnv := createTestEnvironment([]string{testTelemetryResponse}, t.TempDir())
+	defer testEnv.Close()
+
+	expected := `
+# HELP opi_dpu_power_watts Power drawn by the DPU in watts.
+# TYPE opi_dpu_power_watts gauge
+opi_dpu_power_watts 42.25
+# HELP opi_dpu_soc_temperature_celsius Temperature of the DPU SoC in degrees Celsius.
+# TYPE opi_dpu_soc_temperature_celsius gauge
+opi_dpu_soc_temperature_celsius 65.5
+# HELP opi_dpu_throttled Whether the DPU SoC is throttling (1) or not (0).
+# TYPE opi_dpu_throttled gauge
+opi_dpu_throttled 0
+`
+	collector := NewTelemetryCollector(testEnv.opiSpdkServer)
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPlatform_RegisterTelemetryInstruments(t *testing.T) {
+	testEnv := createTestEnvironment([]string{testTelemetryResponse}, t.TempDir())
+	defer testEnv.Close()
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	if _, err := testEnv.opiSpdkServer.RegisterTelemetryInstruments(provider.Meter("test")); err != nil {
+		t.Fatal(err)
+	}
+	var collected metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &collected); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(map[string]float64)
+	for _, scope := range collected.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[float64]:
+				received[m.Name] = data.DataPoints[0].Value
+			case metricdata.Gauge[int64]:
+				received[m.Name] = float64(data.DataPoints[0].Value)
+			default:
+				t.Error("expected a gauge, received", m.Name, m.Data)
+			}
+		}
+	}
+	expected := map[string]float64{
+		"opi.dpu.soc.temperature": 65.5,
+		"opi.dpu.power":           42.25,
+		"opi.dpu.throttled":       0,
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Error("metrics: expected", expected, "received", received)
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"log"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/sku"
+)
+
+// defaultHwmonPath is where the kernel exposes the hardware monitoring sensors
+const defaultHwmonPath = "/sys/class/hwmon"
+
+// Server contains DPU platform related Marvell services
+type Server struct {
+	rpc           spdk.JSONRPC
+	hwmonPath     string
+	hugepagesPath string
+	meminfoPath   string
+	cpuOnlinePath string
+	// attestationKey signs the evidences, nil when the DPU can't be attested
+	attestationKey *attestationKey
+	// skuSelector selects the profile of the SKU of the DPU, nil when the bridge has none
+	skuSelector *sku.Selector
+}
+
+// NewServer creates initialized instance of platform server
+func NewServer(jsonRPC spdk.JSONRPC) *Server {
+	if jsonRPC == nil {
+		log.Panic("nil for JSONRPC is not allowed")
+	}
+	return &Server{
+		rpc:           jsonRPC,
+		hwmonPath:     defaultHwmonPath,
+		hugepagesPath: defaultHugepagesPath,
+		meminfoPath:   defaultMeminfoPath,
+		cpuOnlinePath: defaultCPUOnlinePath,
+	}
+}
+
+// SetSkuSelector makes the capabilities follow the profile of the SKU of the DPU
+func (s *Server) SetSkuSelector(selector *sku.Selector) {
+	s.skuSelector = selector
+}
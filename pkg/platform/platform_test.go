@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+)
+
+type testEnv struct {
+	opiSpdkServer *Server
+	ln            net.Listener
+	testSocket    string
+	ctx           context.Context
+	jsonRPC       spdk.JSONRPC
+}
+
+func (e *testEnv) Close() {
+	utils.CloseListener(e.ln)
+	if err := os.RemoveAll(e.testSocket); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func createTestEnvironment(spdkResponses []string, hwmonPath string) *testEnv {
+	env := &testEnv{}
+	env.testSocket = utils.GenerateSocketName("platform")
+	env.ln, env.jsonRPC = utils.CreateTestSpdkServer(env.testSocket, spdkResponses)
+	env.opiSpdkServer = NewServer(env.jsonRPC)
+	env.opiSpdkServer.hwmonPath = hwmonPath
+	env.ctx = context.Background()
+	return env
+}
+
+var (
+	testTelemetryResponse = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "soc_temp_mc": 65500, "power_mw": 42250, "throttled": false}}`
+	testFailureResponse   = `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 1}}`
+)
+
+// createTestHwmon creates a fake hwmon sysfs tree with the given attribute files
+func createTestHwmon(root string, attributes map[string]string) string {
+	device := root + "/hwmon0"
+	if err := os.MkdirAll(device, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	for name, value := range attributes {
+		if err := os.WriteFile(device+"/"+name, []byte(value+"\n"), 0o600); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return root
+}
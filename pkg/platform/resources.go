@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// GetDpuResourceUsageRequest represents a request to get the resource usage of the DPU
+type GetDpuResourceUsageRequest struct{}
+
+// DpuHugepages represents the usage of the hugepages of a size
+type DpuHugepages struct {
+	// PageSizeKib is the size of the hugepages, i.e. 2048 or 1048576
+	PageSizeKib int64 `json:"pageSizeKib"`
+	// Total is the number of hugepages reserved
+	Total int64 `json:"total"`
+	// Free is the number of hugepages not used
+	Free int64 `json:"free"`
+	// UsedPercent is the used hugepages in percent of Total
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+// DpuCoreUsage represents the load of a core running SPDK pollers
+type DpuCoreUsage struct {
+	// Core is the logical core
+	Core int32 `json:"core"`
+	// LoadPercent is the time the pollers of the core did work in percent, over the last second
+	LoadPercent int32 `json:"loadPercent"`
+	// Pollers is the number of pollers running on the core
+	Pollers int32 `json:"pollers"`
+}
+
+// DpuMemoryPool represents the usage of a memory pool of the firmware
+type DpuMemoryPool struct {
+	// Name of the pool, i.e. bdev_io
+	Name string `json:"name"`
+	// Size is the number of elements of the pool
+	Size int64 `json:"size"`
+	// Available is the number of elements not used
+	Available int64 `json:"available"`
+	// ElementSizeBytes is the size of an element
+	ElementSizeBytes int64 `json:"elementSizeBytes"`
+	// UsedPercent is the used elements in percent of Size, a pool close to 100 percent delays
+	// the IOs
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+// DpuResourceUsage represents the resources of the DPU used by the firmware, to tell whether a card
+// is saturated on CPU or memory rather than on media
+type DpuResourceUsage struct {
+	// Hugepages is the usage of the hugepages, per size
+	Hugepages []*DpuHugepages `json:"hugepages"`
+	// Cores is the load of the cores running the pollers
+	Cores []*DpuCoreUsage `json:"cores"`
+	// MemoryPools is the usage of the memory pools
+	MemoryPools []*DpuMemoryPool `json:"memoryPools"`
+}
+
+// GetDpuResourceUsage gets the hugepages, poller cores and memory pools used by the firmware
+func (s *Server) GetDpuResourceUsage(ctx context.Context, _ *GetDpuResourceUsageRequest) (*DpuResourceUsage, error) {
+	var result models.MrvlPlatformGetResourceUsageResult
+	err := s.rpc.Call(ctx, "mrvl_platform_get_resource_usage", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != 0 {
+		msg := "Could not get DPU resource usage"
+		return nil, result.Status.Err(codes.Unavailable, msg, "mrvl_platform_get_resource_usage")
+	}
+	usage := &DpuResourceUsage{
+		Hugepages:   make([]*DpuHugepages, 0, len(result.Hugepages)),
+		Cores:       make([]*DpuCoreUsage, 0, len(result.Cores)),
+		MemoryPools: make([]*DpuMemoryPool, 0, len(result.Mempools)),
+	}
+	for _, hugepages := range result.Hugepages {
+		usage.Hugepages = append(usage.Hugepages, &DpuHugepages{
+			PageSizeKib: int64(hugepages.PageSizeKb),
+			Total:       int64(hugepages.Total),
+			Free:        int64(hugepages.Free),
+			UsedPercent: usedPercent(hugepages.Total-hugepages.Free, hugepages.Total),
+		})
+	}
+	for _, core := range result.Cores {
+		usage.Cores = append(usage.Cores, &DpuCoreUsage{
+			Core:        int32(core.Lcore),
+			LoadPercent: int32(core.LoadPct),
+			Pollers:     int32(core.Pollers),
+		})
+	}
+	for _, pool := range result.Mempools {
+		usage.MemoryPools = append(usage.MemoryPools, &DpuMemoryPool{
+			Name:             pool.Name,
+			Size:             int64(pool.Size),
+			Available:        int64(pool.Available),
+			ElementSizeBytes: int64(pool.EltSize),
+			UsedPercent:      usedPercent(pool.Size-pool.Available, pool.Size),
+		})
+	}
+	return usage, nil
+}
+
+// usedPercent returns used in percent of total
+func usedPercent(used int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(used) * 100 / float64(total)
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPlatform_GetDpuResourceUsage(t *testing.T) {
+	tests := map[string]struct {
+		out     *DpuResourceUsage
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.Unavailable,
+			errMsg:  "Could not get DPU resource usage",
+		},
+		"valid request with empty SPDK response": {
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_platform_get_resource_usage: %v", "EOF"),
+		},
+		"valid request with error code from SPDK response": {
+			out:     nil,
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.Unknown,
+			errMsg:  fmt.Sprintf("mrvl_platform_get_resource_usage: %v", "json response error: myopierr"),
+		},
+		"valid request with valid SPDK response": {
+			out: &DpuResourceUsage{
+				Hugepages: []*DpuHugepages{
+					{PageSizeKib: 2048, Total: 1024, Free: 256, UsedPercent: 75},
+					{PageSizeKib: 1048576, Total: 0, Free: 0, UsedPercent: 0},
+				},
+				Cores: []*DpuCoreUsage{
+					{Core: 0, LoadPercent: 97, Pollers: 12},
+					{Core: 1, LoadPercent: 40, Pollers: 5},
+				},
+				MemoryPools: []*DpuMemoryPool{
+					{Name: "bdev_io", Size: 65535, Available: 0, ElementSizeBytes: 512, UsedPercent: 100},
+				},
+			},
+			spdk: []string{`{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, ` +
+				`"hugepages": [{"page_size_kb": 2048, "total": 1024, "free": 256}, {"page_size_kb": 1048576, "total": 0, "free": 0}], ` +
+				`"cores": [{"lcore": 0, "load_pct": 97, "pollers": 12}, {"lcore": 1, "load_pct": 40, "pollers": 5}], ` +
+				`"mempools": [{"name": "bdev_io", "size": 65535, "available": 0, "elt_size": 512}]}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk, t.TempDir())
+			defer testEnv.Close()
+
+			response, err := testEnv.opiSpdkServer.GetDpuResourceUsage(testEnv.ctx, &GetDpuResourceUsageRequest{})
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
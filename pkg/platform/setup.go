@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Kernel interfaces the setup reads the resources of the SoC from and reserves the hugepages in
+const (
+	defaultHugepagesPath = "/sys/kernel/mm/hugepages"
+	defaultMeminfoPath   = "/proc/meminfo"
+	defaultCPUOnlinePath = "/sys/devices/system/cpu/online"
+)
+
+// systemMemoryKib is the memory kept for the kernel, the bridge and the other processes of the
+// SoC, out of the hugepages
+const systemMemoryKib = 2 * 1024 * 1024
+
+// setupProfile represents the resources reserved for the firmware on a DPU
+type setupProfile struct {
+	name string
+	// hugepagesKib is the memory reserved in hugepages
+	hugepagesKib int64
+	// cores is the number of cores running the SPDK pollers, the first online core is kept for
+	// the system
+	cores int
+}
+
+// setupProfiles are the profiles a DPU can be set up for, from the smallest
+var setupProfiles = []setupProfile{
+	{name: "small", hugepagesKib: 2 * 1024 * 1024, cores: 2},
+	{name: "medium", hugepagesKib: 8 * 1024 * 1024, cores: 4},
+	{name: "large", hugepagesKib: 16 * 1024 * 1024, cores: 8},
+}
+
+// SetupDpuRequest represents a request to set up the hugepages and the cores of the DPU for the
+// firmware
+type SetupDpuRequest struct {
+	// Profile is the size of the setup, small, medium or large
+	Profile string `json:"profile"`
+	// ValidateOnly checks the DPU has the resources of the profile without reserving them
+	ValidateOnly bool `json:"validateOnly"`
+}
+
+// DpuSetup represents the resources of the DPU set up for the firmware
+type DpuSetup struct {
+	// Profile the DPU is set up for
+	Profile string `json:"profile"`
+	// HugepageSizeKib is the size of the hugepages reserved, the default size of the kernel
+	HugepageSizeKib int64 `json:"hugepageSizeKib"`
+	// Hugepages is the number of hugepages reserved
+	Hugepages int64 `json:"hugepages"`
+	// Cores are the logical cores of the SPDK pollers
+	Cores []int32 `json:"cores"`
+	// CoreMask is the mask of Cores, in hexadecimal, the SPDK application is started with, i.e.
+	// with -m 0x1e
+	CoreMask string `json:"coreMask"`
+	// Applied is set when the hugepages were reserved, not when the request only validated them
+	Applied bool `json:"applied"`
+}
+
+// SetupDpu reserves the hugepages of a profile and returns the mask of the cores the SPDK
+// application has to run on, the resources of the SoC are validated first
+func (s *Server) SetupDpu(_ context.Context, in *SetupDpuRequest) (*DpuSetup, error) {
+	profile, ok := findSetupProfile(in.Profile)
+	if !ok {
+		names := make([]string, 0, len(setupProfiles))
+		for _, p := range setupProfiles {
+			names = append(names, p.name)
+		}
+		msg := fmt.Sprintf("Unknown DPU setup profile %s, have to be one of %s", in.Profile, strings.Join(names, ", "))
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	meminfo, err := readMeminfo(s.meminfoPath)
+	if err != nil {
+		slog.Error("Could not read the memory of the DPU", "path", s.meminfoPath, "error", err)
+		msg := "Could not read the memory of the DPU"
+		return nil, status.Errorf(codes.Unavailable, msg)
+	}
+	cpus, err := readCPUList(s.cpuOnlinePath)
+	if err != nil {
+		slog.Error("Could not read the cores of the DPU", "path", s.cpuOnlinePath, "error", err)
+		msg := "Could not read the cores of the DPU"
+		return nil, status.Errorf(codes.Unavailable, msg)
+	}
+	pageSizeKib := meminfo["Hugepagesize"]
+	if pageSizeKib == 0 {
+		msg := "Could not find the hugepage size of the DPU"
+		return nil, status.Errorf(codes.Unavailable, msg)
+	}
+	if profile.hugepagesKib+systemMemoryKib > meminfo["MemTotal"] {
+		msg := fmt.Sprintf("Profile %s needs %d MiB of hugepages, the DPU has %d MiB of memory and keeps %d MiB for the system",
+			profile.name, profile.hugepagesKib/1024, meminfo["MemTotal"]/1024, systemMemoryKib/1024)
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	if profile.cores+1 > len(cpus) {
+		msg := fmt.Sprintf("Profile %s needs %d cores, the DPU has %d and keeps one for the system", profile.name, profile.cores, len(cpus))
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	setup := &DpuSetup{
+		Profile:         profile.name,
+		HugepageSizeKib: pageSizeKib,
+		// a partial hugepage is a whole one
+		Hugepages: (profile.hugepagesKib + pageSizeKib - 1) / pageSizeKib,
+		Cores:     make([]int32, 0, profile.cores),
+	}
+	mask := new(big.Int)
+	for _, cpu := range cpus[1 : profile.cores+1] {
+		setup.Cores = append(setup.Cores, int32(cpu))
+		mask.SetBit(mask, cpu, 1)
+	}
+	setup.CoreMask = fmt.Sprintf("0x%x", mask)
+	if in.ValidateOnly {
+		return setup, nil
+	}
+
+	path := filepath.Join(s.hugepagesPath, fmt.Sprintf("hugepages-%dkB", pageSizeKib), "nr_hugepages")
+	if err := os.WriteFile(filepath.Clean(path), []byte(strconv.FormatInt(setup.Hugepages, 10)), 0o600); err != nil {
+		slog.Error("Could not reserve the hugepages of the DPU", "path", path, "error", err)
+		msg := "Could not reserve the hugepages of the DPU"
+		return nil, status.Errorf(codes.Internal, msg)
+	}
+	// the kernel reserves fewer hugepages than requested when the memory is fragmented
+	reserved, err := readSysfsInt(path)
+	if err != nil {
+		slog.Error("Could not read the hugepages of the DPU", "path", path, "error", err)
+		msg := "Could not read the hugepages of the DPU"
+		return nil, status.Errorf(codes.Internal, msg)
+	}
+	if reserved < setup.Hugepages {
+		msg := fmt.Sprintf("Only %d of the %d hugepages could be reserved, reserve them on the kernel command line", reserved, setup.Hugepages)
+		return nil, status.Errorf(codes.ResourceExhausted, msg)
+	}
+	setup.Applied = true
+	slog.Info("Set up the DPU", "profile", setup.Profile, "hugepages", setup.Hugepages, "hugepage_size_kib", setup.HugepageSizeKib, "core_mask", setup.CoreMask)
+	return setup, nil
+}
+
+// findSetupProfile returns the profile named name
+func findSetupProfile(name string) (setupProfile, bool) {
+	for _, profile := range setupProfiles {
+		if profile.name == name {
+			return profile, true
+		}
+	}
+	return setupProfile{}, false
+}
+
+// readMeminfo returns the sizes of /proc/meminfo, in kiB, by name
+func readMeminfo(path string) (map[string]int64, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	meminfo := make(map[string]int64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// i.e. MemTotal:       16283904 kB
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		meminfo[name] = size
+	}
+	return meminfo, scanner.Err()
+}
+
+// readCPUList returns the cores of a kernel CPU list, i.e. 0-3,8-11
+func readCPUList(path string) ([]int, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var cpus []int
+	for _, cpuRange := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		first, last, isRange := strings.Cut(cpuRange, "-")
+		if !isRange {
+			last = first
+		}
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q", data)
+		}
+		to, err := strconv.Atoi(last)
+		if err != nil || to < from {
+			return nil, fmt.Errorf("invalid CPU list %q", data)
+		}
+		for cpu := from; cpu <= to; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
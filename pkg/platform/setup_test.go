@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPlatform_SetupDpu(t *testing.T) {
+	tests := map[string]struct {
+		in        *SetupDpuRequest
+		memTotal  string
+		cpus      string
+		hugepages bool
+		out       *DpuSetup
+		reserved  string
+		errCode   codes.Code
+		errMsg    string
+	}{
+		"valid request": {
+			in:        &SetupDpuRequest{Profile: "medium"},
+			memTotal:  "16283904",
+			cpus:      "0-23",
+			hugepages: true,
+			out: &DpuSetup{
+				Profile:         "medium",
+				HugepageSizeKib: 2048,
+				Hugepages:       4096,
+				Cores:           []int32{1, 2, 3, 4},
+				CoreMask:        "0x1e",
+				Applied:         true,
+			},
+			reserved: "4096",
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"validate only on sparse cores": {
+			in:        &SetupDpuRequest{Profile: "small", ValidateOnly: true},
+			memTotal:  "8141952",
+			cpus:      "0,2,4-5",
+			hugepages: true,
+			out: &DpuSetup{
+				Profile:         "small",
+				HugepageSizeKib: 2048,
+				Hugepages:       1024,
+				Cores:           []int32{2, 4},
+				CoreMask:        "0x14",
+				Applied:         false,
+			},
+			reserved: "0",
+			errCode:  codes.OK,
+			errMsg:   "",
+		},
+		"unknown profile": {
+			in:        &SetupDpuRequest{Profile: "huge"},
+			memTotal:  "16283904",
+			cpus:      "0-23",
+			hugepages: true,
+			out:       nil,
+			reserved:  "0",
+			errCode:   codes.InvalidArgument,
+			errMsg:    "Unknown DPU setup profile huge, have to be one of small, medium, large",
+		},
+		"not enough memory": {
+			in:        &SetupDpuRequest{Profile: "large"},
+			memTotal:  "16283904",
+			cpus:      "0-23",
+			hugepages: true,
+			out:       nil,
+			reserved:  "0",
+			errCode:   codes.FailedPrecondition,
+			errMsg:    "Profile large needs 16384 MiB of hugepages, the DPU has 15902 MiB of memory and keeps 2048 MiB for the system",
+		},
+		"not enough cores": {
+			in:        &SetupDpuRequest{Profile: "medium"},
+			memTotal:  "16283904",
+			cpus:      "0-3",
+			hugepages: true,
+			out:       nil,
+			reserved:  "0",
+			errCode:   codes.FailedPrecondition,
+			errMsg:    "Profile medium needs 4 cores, the DPU has 4 and keeps one for the system",
+		},
+		"hugepages not supported": {
+			in:        &SetupDpuRequest{Profile: "small"},
+			memTotal:  "16283904",
+			cpus:      "0-23",
+			hugepages: false,
+			out:       nil,
+			reserved:  "",
+			errCode:   codes.Internal,
+			errMsg:    "Could not reserve the hugepages of the DPU",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment([]string{}, "")
+			defer testEnv.Close()
+			root := t.TempDir()
+			testEnv.opiSpdkServer.meminfoPath = filepath.Join(root, "meminfo")
+			testEnv.opiSpdkServer.cpuOnlinePath = filepath.Join(root, "online")
+			testEnv.opiSpdkServer.hugepagesPath = filepath.Join(root, "hugepages")
+			meminfo := "MemTotal:       " + tt.memTotal + " kB\nHugePages_Total:       0\nHugepagesize:       2048 kB\n"
+			if err := os.WriteFile(testEnv.opiSpdkServer.meminfoPath, []byte(meminfo), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(testEnv.opiSpdkServer.cpuOnlinePath, []byte(tt.cpus+"\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			nrHugepages := filepath.Join(testEnv.opiSpdkServer.hugepagesPath, "hugepages-2048kB", "nr_hugepages")
+			if tt.hugepages {
+				if err := os.MkdirAll(filepath.Dir(nrHugepages), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(nrHugepages, []byte("0\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			response, err := testEnv.opiSpdkServer.SetupDpu(testEnv.ctx, tt.in)
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+
+			if tt.hugepages {
+				reserved, err := os.ReadFile(nrHugepages)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(reserved) != tt.reserved && string(reserved) != tt.reserved+"\n" {
+					t.Error("hugepages: expected", tt.reserved, "received", string(reserved))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sources the telemetry can be read from
+const (
+	telemetrySourceRPC   = "rpc"
+	telemetrySourceSysfs = "sysfs"
+)
+
+// GetDpuTelemetryRequest represents a request to get the DPU telemetry
+type GetDpuTelemetryRequest struct{}
+
+// DpuTelemetry represents the thermal and power state of the DPU
+type DpuTelemetry struct {
+	// SocTemperatureCelsius is the temperature of the SoC
+	SocTemperatureCelsius float64 `json:"socTemperatureCelsius"`
+	// PowerWatts is the power drawn by the card, 0 if not reported
+	PowerWatts float64 `json:"powerWatts"`
+	// Throttled is set when the SoC reduces its clocks to stay within thermal or power limits
+	Throttled bool `json:"throttled"`
+	// Source the telemetry was read from, either rpc or sysfs
+	Source string `json:"source"`
+}
+
+// GetDpuTelemetry gets the SoC temperature, power draw and throttling state of the DPU
+func (s *Server) GetDpuTelemetry(ctx context.Context, _ *GetDpuTelemetryRequest) (*DpuTelemetry, error) {
+	var result models.MrvlPlatformGetTelemetryResult
+	err := s.rpc.Call(ctx, "mrvl_platform_get_telemetry", nil, &result)
+	if err != nil {
+		// the platform RPCs are not available on every firmware, fallback to the kernel sensors
+		slog.Warn("Could not get telemetry from SPDK, falling back to sysfs", "error", err)
+		return s.getDpuTelemetryFromSysfs()
+	}
+	if result.Status != 0 {
+		msg := "Could not get DPU telemetry"
+		return nil, result.Status.Err(codes.Unavailable, msg, "mrvl_platform_get_telemetry")
+	}
+	return &DpuTelemetry{
+		SocTemperatureCelsius: float64(result.SocTempMilliC) / 1000,
+		PowerWatts:            float64(result.PowerMilliW) / 1000,
+		Throttled:             result.Throttled,
+		Source:                telemetrySourceRPC,
+	}, nil
+}
+
+// getDpuTelemetryFromSysfs reads the first hwmon device reporting a temperature,
+// the kernel reports temperatures in millidegrees Celsius and power in microwatts
+func (s *Server) getDpuTelemetryFromSysfs() (*DpuTelemetry, error) {
+	devices, err := filepath.Glob(filepath.Join(s.hwmonPath, "hwmon*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		temp, err := readSysfsInt(filepath.Join(device, "temp1_input"))
+		if err != nil {
+			continue
+		}
+		telemetry := &DpuTelemetry{
+			SocTemperatureCelsius: float64(temp) / 1000,
+			Source:                telemetrySourceSysfs,
+		}
+		if power, err := readSysfsInt(filepath.Join(device, "power1_input")); err == nil {
+			telemetry.PowerWatts = float64(power) / 1000000
+		}
+		// the critical alarm is raised when the SoC starts throttling
+		if alarm, err := readSysfsInt(filepath.Join(device, "temp1_crit_alarm")); err == nil {
+			telemetry.Throttled = alarm != 0
+		}
+		return telemetry, nil
+	}
+	slog.Warn("No hwmon device reports a temperature", "path", s.hwmonPath)
+	msg := "Could not find DPU temperature sensor"
+	return nil, status.Errorf(codes.Unavailable, msg)
+}
+
+func readSysfsInt(path string) (int64, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
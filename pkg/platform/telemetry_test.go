@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPlatform_GetDpuTelemetry(t *testing.T) {
+	tests := map[string]struct {
+		hwmon   map[string]string
+		out     *DpuTelemetry
+		spdk    []string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request with invalid SPDK response": {
+			hwmon:   nil,
+			out:     nil,
+			spdk:    []string{testFailureResponse},
+			errCode: codes.Unavailable,
+			errMsg:  "Could not get DPU telemetry",
+		},
+		"valid request with valid SPDK response": {
+			hwmon: nil,
+			out: &DpuTelemetry{
+				SocTemperatureCelsius: 65.5,
+				PowerWatts:            42.25,
+				Throttled:             false,
+				Source:                telemetrySourceRPC,
+			},
+			spdk:    []string{testTelemetryResponse},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"empty SPDK response falls back to sysfs": {
+			hwmon: map[string]string{"temp1_input": "71000", "power1_input": "38500000", "temp1_crit_alarm": "1"},
+			out: &DpuTelemetry{
+				SocTemperatureCelsius: 71,
+				PowerWatts:            38.5,
+				Throttled:             true,
+				Source:                telemetrySourceSysfs,
+			},
+			spdk:    []string{""},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"error code from SPDK response falls back to sysfs without power": {
+			hwmon: map[string]string{"temp1_input": "55250"},
+			out: &DpuTelemetry{
+				SocTemperatureCelsius: 55.25,
+				Source:                telemetrySourceSysfs,
+			},
+			spdk:    []string{`{"id":%d,"error":{"code":1,"message":"myopierr"},"result":{"status": 1}}`},
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"empty SPDK response and no sensor": {
+			hwmon:   map[string]string{"name": "cn10k"},
+			out:     nil,
+			spdk:    []string{""},
+			errCode: codes.Unavailable,
+			errMsg:  "Could not find DPU temperature sensor",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			hwmonPath := createTestHwmon(t.TempDir(), tt.hwmon)
+			testEnv := createTestEnvironment(tt.spdk, hwmonPath)
+			defer testEnv.Close()
+
+			response, err := testEnv.opiSpdkServer.GetDpuTelemetry(testEnv.ctx, &GetDpuTelemetryRequest{})
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
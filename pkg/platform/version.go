@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+// opiAPIModule is the module of the OPI APIs the bridge implements
+const opiAPIModule = "github.com/opiproject/opi-api"
+
+// Version and commit of the bridge, set at build time with
+// -ldflags "-X github.com/opiproject/opi-marvell-bridge/pkg/platform.bridgeVersion=v1.2.0", the
+// ones of the build information of the binary when not
+var (
+	bridgeVersion string
+	gitCommit     string
+)
+
+// GetBridgeVersionRequest represents a request to get the versions of the bridge and of its
+// firmware
+type GetBridgeVersionRequest struct{}
+
+// BridgeVersion represents the versions of the bridge, of the APIs it implements and of the
+// firmware it drives, to audit the compatibility of a fleet
+type BridgeVersion struct {
+	// Version of the bridge
+	Version string `json:"version"`
+	// GitCommit the bridge is built from
+	GitCommit string `json:"gitCommit"`
+	// GoVersion the bridge is built with
+	GoVersion string `json:"goVersion"`
+	// OpiAPIVersion is the version of the OPI APIs module the bridge implements
+	OpiAPIVersion string `json:"opiApiVersion"`
+	// RPCSchemaVersion is the version of SPDK whose JSON-RPC methods the bridge calls
+	RPCSchemaVersion string `json:"rpcSchemaVersion"`
+	// SpdkVersion is the version of SPDK the firmware reports, empty when it doesn't answer
+	SpdkVersion string `json:"spdkVersion"`
+	// FirmwareVersion is the version of the Marvell firmware, empty when it doesn't answer
+	FirmwareVersion string `json:"firmwareVersion"`
+	// SdkVersion is the version of the Marvell SDK the firmware is built with, empty when it
+	// doesn't answer
+	SdkVersion string `json:"sdkVersion"`
+}
+
+// GetBridgeVersion gets the versions of the bridge and of the firmware it detects, the versions
+// of the bridge are returned even when the firmware doesn't answer
+func (s *Server) GetBridgeVersion(ctx context.Context, _ *GetBridgeVersionRequest) (*BridgeVersion, error) {
+	version := &BridgeVersion{
+		Version:          bridgeVersion,
+		GitCommit:        gitCommit,
+		RPCSchemaVersion: models.RPCSchemaVersion,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version.GoVersion = info.GoVersion
+		if version.Version == "" {
+			version.Version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && version.GitCommit == "" {
+				version.GitCommit = setting.Value
+			}
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == opiAPIModule {
+				version.OpiAPIVersion = dep.Version
+			}
+		}
+	}
+	version.SpdkVersion = s.rpc.GetVersion(ctx)
+	inventory, err := s.GetDpuInventory(ctx, &GetDpuInventoryRequest{})
+	if err != nil {
+		slog.WarnContext(ctx, "Could not detect the firmware version", "error", err)
+		return version, nil
+	}
+	version.FirmwareVersion = inventory.FirmwareVersion
+	version.SdkVersion = inventory.SdkVersion
+	return version, nil
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package platform implements the DPU platform APIs of the bridge
+package platform
+
+import (
+	"testing"
+)
+
+func TestPlatform_GetBridgeVersion(t *testing.T) {
+	testVersionResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"version": "SPDK v21.01.1", "fields": {"major": 21, "minor": 1, "patch": 1, "suffix": ""}}}`
+	testInventoryResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "sku": "CN106XX", "fw_version": "1.4.2", "sdk_version": "SDK12.23.11"}}`
+	testSkuCapsResponse := `{"id":%d,"error":{"code":0,"message":""},"result":{"status": 0, "max_pf_msix_vectors": 128, "max_vf_msix_vectors": 32}}`
+	tests := map[string]struct {
+		version         string
+		commit          string
+		spdk            []string
+		spdkVersion     string
+		firmwareVersion string
+		sdkVersion      string
+	}{
+		"valid request with valid SPDK response": {
+			version:         "v1.2.0",
+			commit:          "2b1f0c4",
+			spdk:            []string{testVersionResponse, testInventoryResponse, testSkuCapsResponse},
+			spdkVersion:     "SPDK v21.01.1",
+			firmwareVersion: "1.4.2",
+			sdkVersion:      "SDK12.23.11",
+		},
+		"valid request with invalid SPDK response": {
+			version:         "v1.2.0",
+			commit:          "2b1f0c4",
+			spdk:            []string{"", testFailureResponse},
+			spdkVersion:     "",
+			firmwareVersion: "",
+			sdkVersion:      "",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testEnv := createTestEnvironment(tt.spdk, t.TempDir())
+			defer testEnv.Close()
+			bridgeVersion, gitCommit = tt.version, tt.commit
+			defer func() { bridgeVersion, gitCommit = "", "" }()
+
+			response, err := testEnv.opiSpdkServer.GetBridgeVersion(testEnv.ctx, &GetBridgeVersionRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if response.Version != tt.version {
+				t.Error("version: expected", tt.version, "received", response.Version)
+			}
+			if response.GitCommit != tt.commit {
+				t.Error("commit: expected", tt.commit, "received", response.GitCommit)
+			}
+			if response.RPCSchemaVersion != "v21.01" {
+				t.Error("RPC schema version: expected v21.01, received", response.RPCSchemaVersion)
+			}
+			if response.SpdkVersion != tt.spdkVersion {
+				t.Error("SPDK version: expected", tt.spdkVersion, "received", response.SpdkVersion)
+			}
+			if response.FirmwareVersion != tt.firmwareVersion {
+				t.Error("firmware version: expected", tt.firmwareVersion, "received", response.FirmwareVersion)
+			}
+			if response.SdkVersion != tt.sdkVersion {
+				t.Error("SDK version: expected", tt.sdkVersion, "received", response.SdkVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ratelimit limits the request rate and the mutating calls in flight of each client of the
+// bridge, so a runaway automation loop can't starve the others of the single SPDK connection
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/audit"
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the gRPC metadata key of the delegation of a call already limited, its calls to
+// the bridge itself, i.e. the steps of a config, aren't limited again
+const MetadataKey = "opi-rate-limit-delegation"
+
+// pruneInterval is how often the clients back to a full bucket without call in flight are forgotten
+const pruneInterval = time.Minute
+
+// client holds the token bucket and the mutating calls in flight of an identity
+type client struct {
+	tokens   float64
+	last     time.Time
+	inFlight int
+}
+
+// Limiter limits the calls of each identity
+type Limiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       int
+	maxInFlight int
+	clients     map[string]*client
+	lastPrune   time.Time
+	// delegations maps the keys of the calls in flight delegating their limits to their identity
+	delegations map[string]string
+}
+
+// New returns a Limiter allowing each identity rate requests per second with bursts of burst
+// requests, and maxInFlight mutating calls at a time. A zero rate or maxInFlight disables the limit
+func New(rate float64, burst int, maxInFlight int) *Limiter {
+	return &Limiter{
+		rate:        rate,
+		burst:       burst,
+		maxInFlight: maxInFlight,
+		clients:     make(map[string]*client),
+		delegations: make(map[string]string),
+	}
+}
+
+// Validate checks the limits a Limiter can be created with
+func Validate(rate float64, burst int, maxInFlight int) error {
+	if rate < 0 || rate > 100000 {
+		return fmt.Errorf("invalid rate limit %g, have to be between 0 and 100000", rate)
+	}
+	if burst < 1 || burst > 100000 {
+		return fmt.Errorf("invalid rate limit burst %d, have to be between 1 and 100000", burst)
+	}
+	if maxInFlight < 0 || maxInFlight > 1000 {
+		return fmt.Errorf("invalid max mutating calls %d, have to be between 0 and 1000", maxInFlight)
+	}
+	return nil
+}
+
+// SetLimits changes the limits, as New does, the calls in flight are kept
+func (l *Limiter) SetLimits(rate float64, burst int, maxInFlight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate, l.burst, l.maxInFlight = rate, burst, maxInFlight
+}
+
+// Acquire checks identity may call method now, the returned release has to be called when the call
+// completes
+func (l *Limiter) Acquire(identity string, method string) (func(), error) {
+	return l.acquire(identity, method, time.Now())
+}
+
+// AcquireDelegation checks identity may call method now as Acquire does, and returns the key the
+// calls made on behalf of the call pass in MetadataKey, so they aren't limited again until release
+func (l *Limiter) AcquireDelegation(identity string, method string) (func(), string, error) {
+	release, err := l.Acquire(identity, method)
+	if err != nil {
+		return nil, "", err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		release()
+		return nil, "", status.Errorf(codes.Internal, "cannot generate the rate limit delegation: %v", err)
+	}
+	key := hex.EncodeToString(b)
+	l.mu.Lock()
+	l.delegations[key] = identity
+	l.mu.Unlock()
+	return func() {
+		l.mu.Lock()
+		delete(l.delegations, key)
+		l.mu.Unlock()
+		release()
+	}, key, nil
+}
+
+// delegated tells whether a gRPC call is made on behalf of a call in flight of the same identity,
+// which was limited already
+func (l *Limiter) delegated(ctx context.Context, identity string) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delegator, ok := l.delegations[values[0]]
+	return ok && delegator == identity
+}
+
+func (l *Limiter) acquire(identity string, method string, now time.Time) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	c, ok := l.clients[identity]
+	if !ok {
+		c = &client{tokens: float64(l.burst), last: now}
+		l.clients[identity] = c
+	}
+	if l.rate > 0 {
+		c.tokens = math.Min(float64(l.burst), c.tokens+now.Sub(c.last).Seconds()*l.rate)
+		c.last = now
+		if c.tokens < 1 {
+			msg := fmt.Sprintf("Client %s exceeded %g requests per second, retry later", name(identity), l.rate)
+			return nil, status.Errorf(codes.ResourceExhausted, msg)
+		}
+	}
+	mutating := l.maxInFlight > 0 && audit.IsMutating(method)
+	if mutating && c.inFlight >= l.maxInFlight {
+		msg := fmt.Sprintf("Client %s already has %d mutating calls in flight, retry when they complete", name(identity), c.inFlight)
+		return nil, status.Errorf(codes.ResourceExhausted, msg)
+	}
+	if l.rate > 0 {
+		c.tokens--
+	}
+	if !mutating {
+		return func() {}, nil
+	}
+	c.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			c.inFlight--
+		})
+	}, nil
+}
+
+// prune forgets the clients which would be back to a full bucket without call in flight
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for identity, c := range l.clients {
+		full := l.rate <= 0 || c.tokens+now.Sub(c.last).Seconds()*l.rate >= float64(l.burst)
+		if full && c.inFlight == 0 {
+			delete(l.clients, identity)
+		}
+	}
+}
+
+// name returns the identity as shown in the errors
+func name(identity string) string {
+	if identity == "" {
+		return "anonymous"
+	}
+	return identity
+}
+
+// UnaryServerInterceptor refuses the gRPC calls over the limits of their identity, but the ones
+// made on behalf of a call delegating its limits
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		identity := authz.IdentityFromContext(ctx)
+		if l.delegated(ctx, identity) {
+			return handler(ctx, req)
+		}
+		release, err := l.Acquire(identity, strings.TrimPrefix(info.FullMethod, "/"))
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor refuses the gRPC streams over the limits of their identity
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		identity := authz.IdentityFromContext(ss.Context())
+		if l.delegated(ss.Context(), identity) {
+			return handler(srv, ss)
+		}
+		release, err := l.Acquire(identity, strings.TrimPrefix(info.FullMethod, "/"))
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package ratelimit limits the request rate and the mutating calls in flight of each client of the
+// bridge, so a runaway automation loop can't starve the others of the single SPDK connection
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testGet    = "opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"
+	testCreate = "opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem"
+)
+
+func TestRateLimit_Rate(t *testing.T) {
+	limiter := New(2, 3, 0)
+	start := time.Now()
+	tests := []struct {
+		identity string
+		after    time.Duration
+		errMsg   string
+	}{
+		{identity: "ci", after: 0},
+		{identity: "ci", after: 0},
+		{identity: "ci", after: 0},
+		{identity: "ci", after: 0, errMsg: "Client ci exceeded 2 requests per second, retry later"},
+		// the other clients have their own bucket
+		{identity: "ops", after: 0},
+		{identity: "", after: 0},
+		// a token is back every half second
+		{identity: "ci", after: 500 * time.Millisecond},
+		{identity: "ci", after: 500 * time.Millisecond, errMsg: "Client ci exceeded 2 requests per second, retry later"},
+		// the bucket holds at most burst tokens
+		{identity: "ci", after: time.Hour},
+		{identity: "ci", after: time.Hour},
+		{identity: "ci", after: time.Hour},
+		{identity: "ci", after: time.Hour, errMsg: "Client ci exceeded 2 requests per second, retry later"},
+	}
+
+	// run tests
+	for i, tt := range tests {
+		release, err := limiter.acquire(tt.identity, testGet, start.Add(tt.after))
+		errMsg := ""
+		if err != nil {
+			errMsg = status.Convert(err).Message()
+		} else {
+			release()
+		}
+		if errMsg != tt.errMsg {
+			t.Error("call", i, "error: expected", tt.errMsg, "received", errMsg)
+		}
+	}
+}
+
+func TestRateLimit_InFlight(t *testing.T) {
+	limiter := New(0, 0, 2)
+	first, err := limiter.Acquire("ci", testCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := limiter.Acquire("ci", testCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = limiter.Acquire("ci", testCreate)
+	if er, _ := status.FromError(err); er.Code() != codes.ResourceExhausted {
+		t.Error("error code: expected", codes.ResourceExhausted, "received", er.Code())
+	}
+	if msg := status.Convert(err).Message(); msg != "Client ci already has 2 mutating calls in flight, retry when they complete" {
+		t.Error("error message: received", msg)
+	}
+	// the calls not changing the resources and the other clients aren't limited
+	if _, err := limiter.Acquire("ci", testGet); err != nil {
+		t.Error("get: expected no error, received", err)
+	}
+	if _, err := limiter.Acquire("ops", testCreate); err != nil {
+		t.Error("other client: expected no error, received", err)
+	}
+	// releasing twice frees a single call
+	first()
+	first()
+	third, err := limiter.Acquire("ci", testCreate)
+	if err != nil {
+		t.Error("after release: expected no error, received", err)
+	}
+	if _, err := limiter.Acquire("ci", testCreate); err == nil {
+		t.Error("after double release: expected an error")
+	}
+	second()
+	third()
+}
+
+func TestRateLimit_Prune(t *testing.T) {
+	limiter := New(1, 1, 1)
+	start := time.Now()
+	if _, err := limiter.acquire("busy", testCreate, start); err != nil {
+		t.Fatal(err)
+	}
+	release, err := limiter.acquire("idle", testCreate, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, err := limiter.acquire("ops", testGet, start.Add(2*pruneInterval)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := limiter.clients["idle"]; ok {
+		t.Error("idle client: expected to be pruned")
+	}
+	if _, ok := limiter.clients["busy"]; !ok {
+		t.Error("client with a call in flight: expected to be kept")
+	}
+}
+
+func TestRateLimit_UnaryServerInterceptor(t *testing.T) {
+	interceptor := New(1, 1, 0).UnaryServerInterceptor()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(authz.MetadataKey, "ci"))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + testGet}
+	if _, err := interceptor(ctx, nil, info, handler); err != nil {
+		t.Error("first call: expected no error, received", err)
+	}
+	_, err := interceptor(ctx, nil, info, handler)
+	if er, _ := status.FromError(err); er.Code() != codes.ResourceExhausted {
+		t.Error("error code: expected", codes.ResourceExhausted, "received", er.Code())
+	}
+}
+
+func TestRateLimit_SetLimits(t *testing.T) {
+	limiter := New(0, 1, 1)
+	release, err := limiter.Acquire("ci", testCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the calls in flight are kept with the new limits
+	limiter.SetLimits(0, 1, 2)
+	if _, err := limiter.Acquire("ci", testCreate); err != nil {
+		t.Error("raised limit: expected no error, received", err)
+	}
+	limiter.SetLimits(0, 1, 1)
+	release()
+	if _, err := limiter.Acquire("ci", testCreate); err == nil {
+		t.Error("lowered limit: expected an error")
+	}
+}
+
+func TestRateLimit_Delegation(t *testing.T) {
+	limiter := New(0, 1, 1)
+	interceptor := limiter.UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + testCreate}
+	release, key, err := limiter.AcquireDelegation("ci", "marvell.apply/ApplyConfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the other identity has its mutating call in flight too
+	releaseOther, err := limiter.Acquire("other", testCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseOther()
+	tests := map[string]struct {
+		ctx     context.Context
+		errCode codes.Code
+	}{
+		"delegated": {
+			ctx:     authz.NewContext(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, key)), "ci"),
+			errCode: codes.OK,
+		},
+		"other identity": {
+			ctx:     authz.NewContext(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, key)), "other"),
+			errCode: codes.ResourceExhausted,
+		},
+		"unknown key": {
+			ctx:     authz.NewContext(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "unknown")), "ci"),
+			errCode: codes.ResourceExhausted,
+		},
+		"without key": {
+			ctx:     authz.NewContext(context.Background(), "ci"),
+			errCode: codes.ResourceExhausted,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := interceptor(tt.ctx, nil, info, handler)
+			if er, _ := status.FromError(err); er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+		})
+	}
+
+	// the key isn't delegating once the call completes
+	release()
+	ctx := authz.NewContext(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, key)), "ci")
+	release, err = limiter.Acquire("ci", testCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	_, err = interceptor(ctx, nil, info, handler)
+	if er, _ := status.FromError(err); er.Code() != codes.ResourceExhausted {
+		t.Error("released delegation: expected", codes.ResourceExhausted, "received", er.Code())
+	}
+}
@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package recorder keeps the last gRPC requests and the Marvell RPCs they made in memory, a
+// flight recorder dumped after an incident without running with verbose logging
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxPayloadSize bounds the size of a recorded payload, the longer ones are truncated
+const maxPayloadSize = 16 * 1024
+
+// Call represents a Marvell RPC made by a gRPC request
+type Call struct {
+	// Time the call was made, UTC
+	Time time.Time `json:"time"`
+	// Method of the call, i.e. mrvl_nvm_create_subsystem
+	Method string `json:"method"`
+	// Params of the call, as JSON
+	Params string `json:"params"`
+	// Result of the call, as JSON, empty when it failed
+	Result string `json:"result"`
+	// Error of the call, i.e. the firmware didn't answer
+	Error string `json:"error,omitempty"`
+	// Latency of the call
+	Latency string `json:"latency"`
+}
+
+// Exchange represents a gRPC request, its response and the Marvell RPCs it made
+type Exchange struct {
+	// Time the request was received, UTC
+	Time time.Time `json:"time"`
+	// RequestID is the ID of the request, as in the logs
+	RequestID string `json:"requestId"`
+	// Method of the request, i.e. opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem
+	Method string `json:"method"`
+	// Request is the gRPC request, as JSON
+	Request string `json:"request"`
+	// Response is the gRPC response, as JSON, empty when it failed
+	Response string `json:"response"`
+	// Code is the gRPC status code of the outcome, empty while the request is running
+	Code string `json:"code"`
+	// Message is the error message of a failed request
+	Message string `json:"message,omitempty"`
+	// Latency of the request, empty while it is running
+	Latency string `json:"latency"`
+	// Calls are the Marvell RPCs made by the request, in order
+	Calls []*Call `json:"calls"`
+}
+
+// GetFlightRecordRequest represents a request to get the recorded exchanges
+type GetFlightRecordRequest struct{}
+
+// FlightRecord represents the recorded exchanges, oldest first
+type FlightRecord struct {
+	// Exchanges are the last gRPC requests, the running ones included
+	Exchanges []*Exchange `json:"exchanges"`
+}
+
+// Recorder keeps the last exchanges in a ring buffer
+type Recorder struct {
+	mu        sync.Mutex
+	exchanges []*Exchange
+	next      int
+	filter    func(io.Writer) io.Writer
+}
+
+// New creates a recorder keeping the last size exchanges, 0 disables it. filter wraps the
+// recorded payloads, i.e. to redact them, it can be nil
+func New(size int, filter func(io.Writer) io.Writer) *Recorder {
+	if filter == nil {
+		filter = func(w io.Writer) io.Writer { return w }
+	}
+	return &Recorder{
+		exchanges: make([]*Exchange, 0, size),
+		filter:    filter,
+	}
+}
+
+// enabled tells whether the recorder keeps exchanges
+func (r *Recorder) enabled() bool {
+	return cap(r.exchanges) > 0
+}
+
+// GetFlightRecord gets the recorded exchanges
+func (r *Recorder) GetFlightRecord(_ context.Context, _ *GetFlightRecordRequest) (*FlightRecord, error) {
+	if !r.enabled() {
+		msg := "Flight recorder is disabled"
+		return nil, status.Errorf(codes.FailedPrecondition, msg)
+	}
+	return r.record(), nil
+}
+
+// Dump writes the recorded exchanges to w, as indented JSON
+func (r *Recorder) Dump(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.record())
+}
+
+// record copies the exchanges, oldest first, so they can be encoded while the running requests
+// go on
+func (r *Recorder) record() *FlightRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := &FlightRecord{Exchanges: make([]*Exchange, 0, len(r.exchanges))}
+	for i := range r.exchanges {
+		recorded := r.exchanges[(r.next+i)%len(r.exchanges)]
+		exchange := *recorded
+		exchange.Calls = make([]*Call, 0, len(recorded.Calls))
+		for _, call := range recorded.Calls {
+			c := *call
+			exchange.Calls = append(exchange.Calls, &c)
+		}
+		record.Exchanges = append(record.Exchanges, &exchange)
+	}
+	return record
+}
+
+// add adds an exchange, replacing the oldest one when the ring buffer is full
+func (r *Recorder) add(exchange *Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.exchanges) < cap(r.exchanges) {
+		r.exchanges = append(r.exchanges, exchange)
+		return
+	}
+	r.exchanges[r.next] = exchange
+	r.next = (r.next + 1) % len(r.exchanges)
+}
+
+// payload encodes a request, a response or the params and result of a call, filtered and
+// truncated
+func (r *Recorder) payload(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	var buf bytes.Buffer
+	_, _ = r.filter(&buf).Write(data)
+	if buf.Len() > maxPayloadSize {
+		return buf.String()[:maxPayloadSize] + "...(truncated)"
+	}
+	return buf.String()
+}
+
+// exchangeKey is the context key of the exchange of a request
+type exchangeKey struct{}
+
+// UnaryServerInterceptor records the gRPC requests, it comes after the request ID one and
+// before the others so the refused requests are recorded too
+func (r *Recorder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !r.enabled() {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		exchange := &Exchange{
+			Time:      start.UTC(),
+			RequestID: requestid.FromContext(ctx),
+			Method:    strings.TrimPrefix(info.FullMethod, "/"),
+			Request:   r.payload(req),
+			Calls:     []*Call{},
+		}
+		r.add(exchange)
+		resp, err := handler(context.WithValue(ctx, exchangeKey{}, exchange), req)
+		var response string
+		if err == nil {
+			response = r.payload(resp)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		exchange.Response = response
+		exchange.Code = status.Code(err).String()
+		if err != nil {
+			exchange.Message = status.Convert(err).Message()
+		}
+		exchange.Latency = time.Since(start).String()
+		return resp, err
+	}
+}
+
+// WrapJSONRPC records the calls to the firmware made by the recorded gRPC requests
+func (r *Recorder) WrapJSONRPC(rpc spdk.JSONRPC) spdk.JSONRPC {
+	return &recordedJSONRPC{JSONRPC: rpc, recorder: r}
+}
+
+// recordedJSONRPC is a JSON-RPC client recording the calls to the firmware
+type recordedJSONRPC struct {
+	spdk.JSONRPC
+	recorder *Recorder
+}
+
+// Call implements spdk.JSONRPC
+func (c *recordedJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	exchange, ok := ctx.Value(exchangeKey{}).(*Exchange)
+	if !ok {
+		return c.JSONRPC.Call(ctx, method, args, result)
+	}
+	start := time.Now()
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	call := &Call{
+		Time:    start.UTC(),
+		Method:  method,
+		Params:  c.recorder.payload(args),
+		Latency: time.Since(start).String(),
+	}
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Result = c.recorder.payload(result)
+	}
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	exchange.Calls = append(exchange.Calls, call)
+	return err
+}
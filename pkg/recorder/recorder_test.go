@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package recorder keeps the last gRPC requests and the Marvell RPCs they made in memory, a
+// flight recorder dumped after an incident without running with verbose logging
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/requestid"
+	"github.com/opiproject/opi-marvell-bridge/pkg/spdk/spdktest"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testUpperWriter mimics a filter of the payloads
+type testUpperWriter struct {
+	w io.Writer
+}
+
+func (u testUpperWriter) Write(p []byte) (int, error) {
+	if _, err := u.w.Write(bytes.ToUpper(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func TestRecorder_UnaryServerInterceptor(t *testing.T) {
+	r := New(2, func(w io.Writer) io.Writer { return testUpperWriter{w: w} })
+	rpc := r.WrapJSONRPC(&spdktest.JSONRPC{Status: 1})
+	interceptor := r.UnaryServerInterceptor()
+	for i, name := range []string{"subsys0", "subsys1", "subsys2"} {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			if err := rpc.Call(ctx, "mrvl_nvm_get_subsys_info", map[string]string{"subnqn": name}, &spdktest.StatusResult{}); err != nil {
+				return nil, err
+			}
+			if i == 2 {
+				return nil, status.Error(codes.InvalidArgument, "Could not get subsystem")
+			}
+			return req, nil
+		}
+		ctx := requestid.NewContext(context.Background(), name)
+		info := &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"}
+		_, _ = interceptor(ctx, map[string]string{"name": name}, info, handler)
+	}
+	// the firmware calls outside of a recorded request are not recorded
+	_ = rpc.Call(context.Background(), "spdk_get_version", nil, &spdktest.StatusResult{})
+
+	record, err := r.GetFlightRecord(context.Background(), &GetFlightRecordRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Exchanges) != 2 {
+		t.Fatal("exchanges: expected the last 2, received", len(record.Exchanges))
+	}
+	tests := []struct {
+		requestID string
+		request   string
+		response  string
+		code      string
+		message   string
+		params    string
+	}{
+		{"subsys1", `{"NAME":"SUBSYS1"}`, `{"NAME":"SUBSYS1"}`, "OK", "", `{"SUBNQN":"SUBSYS1"}`},
+		{"subsys2", `{"NAME":"SUBSYS2"}`, "", "InvalidArgument", "Could not get subsystem", `{"SUBNQN":"SUBSYS2"}`},
+	}
+	for i, tt := range tests {
+		exchange := record.Exchanges[i]
+		if exchange.RequestID != tt.requestID || exchange.Request != tt.request || exchange.Response != tt.response ||
+			exchange.Code != tt.code || exchange.Message != tt.message || exchange.Latency == "" {
+			t.Error("exchange: expected", tt, "received", exchange)
+		}
+		expected := []*Call{{
+			Time:    exchange.Calls[0].Time,
+			Method:  "mrvl_nvm_get_subsys_info",
+			Params:  tt.params,
+			Result:  `{"STATUS":1}`,
+			Latency: exchange.Calls[0].Latency,
+		}}
+		if !reflect.DeepEqual(exchange.Calls, expected) {
+			t.Error("calls: expected", expected, "received", exchange.Calls)
+		}
+	}
+
+	var dump bytes.Buffer
+	if err := r.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	dumped := new(FlightRecord)
+	if err := json.Unmarshal(dump.Bytes(), dumped); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dumped, record) {
+		t.Error("dump: expected", record, "received", dumped)
+	}
+}
+
+func TestRecorder_FailedCall(t *testing.T) {
+	r := New(1, nil)
+	rpc := r.WrapJSONRPC(&spdktest.JSONRPC{Status: 1, Err: errors.New("connection refused")})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, rpc.Call(ctx, "mrvl_nvm_get_subsys_info", map[string]string{"subnqn": strings.Repeat("x", 2*maxPayloadSize)}, &spdktest.StatusResult{})
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/GetNvmeSubsystem"}
+	_, _ = r.UnaryServerInterceptor()(context.Background(), nil, info, handler)
+
+	record, _ := r.GetFlightRecord(context.Background(), &GetFlightRecordRequest{})
+	call := record.Exchanges[0].Calls[0]
+	if call.Error != "connection refused" || call.Result != "" {
+		t.Error("call: expected the error and no result, received", call)
+	}
+	if len(call.Params) != maxPayloadSize+len("...(truncated)") || !strings.HasSuffix(call.Params, "...(truncated)") {
+		t.Error("params: expected truncated, received", len(call.Params))
+	}
+	if record.Exchanges[0].Code != "Unknown" {
+		t.Error("code: expected Unknown, received", record.Exchanges[0].Code)
+	}
+}
+
+func TestRecorder_Disabled(t *testing.T) {
+	r := New(0, nil)
+	called := false
+	handler := func(_ context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return req, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/opi_api.storage.v1.FrontendNvmeService/CreateNvmeSubsystem"}
+	if _, err := r.UnaryServerInterceptor()(context.Background(), nil, info, handler); err != nil || !called {
+		t.Error("expected the call to go through, received", err)
+	}
+	_, err := r.GetFlightRecord(context.Background(), &GetFlightRecordRequest{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Error("error code: expected", codes.FailedPrecondition, "received", status.Code(err))
+	}
+}
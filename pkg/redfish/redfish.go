@@ -0,0 +1,425 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package redfish serves the Nvme subsystems and namespaces of the bridge as the Storage and the
+// Volumes of a Redfish system, for the datacenter tooling built around the BMCs
+package redfish
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+
+	"go.einride.tech/aip/resourceid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// SystemID is the ID of the Redfish system of the DPU, the only one
+const SystemID = "DPU"
+
+// Root is the path of the Redfish service
+const Root = "/redfish/v1"
+
+// Link refers to a Redfish resource
+type Link struct {
+	ID string `json:"@odata.id"`
+}
+
+// Collection represents a Redfish collection of resources
+type Collection struct {
+	ID      string  `json:"@odata.id"`
+	Type    string  `json:"@odata.type"`
+	Name    string  `json:"Name"`
+	Count   int     `json:"Members@odata.count"`
+	Members []*Link `json:"Members"`
+}
+
+// Health represents the status of a Redfish resource
+type Health struct {
+	State  string `json:"State"`
+	Health string `json:"Health"`
+}
+
+// Identifier represents a durable name of a Redfish resource
+type Identifier struct {
+	DurableName       string `json:"DurableName"`
+	DurableNameFormat string `json:"DurableNameFormat"`
+}
+
+// ServiceRoot represents the root of the Redfish service
+type ServiceRoot struct {
+	ID             string `json:"@odata.id"`
+	Type           string `json:"@odata.type"`
+	Name           string `json:"Name"`
+	RedfishVersion string `json:"RedfishVersion"`
+	Systems        *Link  `json:"Systems"`
+}
+
+// System represents the DPU as a Redfish computer system
+type System struct {
+	ID         string  `json:"@odata.id"`
+	Type       string  `json:"@odata.type"`
+	SystemID   string  `json:"Id"`
+	Name       string  `json:"Name"`
+	SystemType string  `json:"SystemType"`
+	Status     *Health `json:"Status"`
+	Storage    *Link   `json:"Storage"`
+}
+
+// Storage represents an Nvme subsystem as Redfish storage
+type Storage struct {
+	ID          string        `json:"@odata.id"`
+	Type        string        `json:"@odata.type"`
+	StorageID   string        `json:"Id"`
+	Name        string        `json:"Name"`
+	Identifiers []*Identifier `json:"Identifiers"`
+	Status      *Health       `json:"Status"`
+	Volumes     *Link         `json:"Volumes"`
+}
+
+// VolumeOem are the properties of a volume specific to the bridge
+type VolumeOem struct {
+	// VolumeNameRef is the backend volume of the namespace
+	VolumeNameRef string `json:"VolumeNameRef"`
+	// HostNsid is the namespace ID of the volume for the host
+	HostNsid int32 `json:"HostNsid"`
+}
+
+// Volume represents an Nvme namespace as a Redfish volume
+type Volume struct {
+	ID          string                `json:"@odata.id"`
+	Type        string                `json:"@odata.type"`
+	VolumeID    string                `json:"Id"`
+	Name        string                `json:"Name"`
+	Identifiers []*Identifier         `json:"Identifiers"`
+	Status      *Health               `json:"Status"`
+	Oem         map[string]*VolumeOem `json:"Oem"`
+}
+
+// oemKey is the key of the properties of the bridge in the Oem objects
+const oemKey = "Marvell"
+
+// GetServiceRootRequest represents a request to get the root of the service
+type GetServiceRootRequest struct{}
+
+// ListSystemsRequest represents a request to list the systems
+type ListSystemsRequest struct{}
+
+// GetSystemRequest represents a request to get a system
+type GetSystemRequest struct {
+	System string `json:"system"`
+}
+
+// ListStorageRequest represents a request to list the storage of a system
+type ListStorageRequest struct {
+	System string `json:"system"`
+}
+
+// GetStorageRequest represents a request to get a storage
+type GetStorageRequest struct {
+	System  string `json:"system"`
+	Storage string `json:"storage"`
+}
+
+// ListVolumesRequest represents a request to list the volumes of a storage
+type ListVolumesRequest struct {
+	System  string `json:"system"`
+	Storage string `json:"storage"`
+}
+
+// GetVolumeRequest represents a request to get a volume
+type GetVolumeRequest struct {
+	System  string `json:"system"`
+	Storage string `json:"storage"`
+	Volume  string `json:"volume"`
+}
+
+// CreateVolumeRequest represents a request to create a volume, the ID of the namespace is the
+// Name of the volume, generated when empty
+type CreateVolumeRequest struct {
+	System  string                `json:"system"`
+	Storage string                `json:"storage"`
+	Name    string                `json:"Name"`
+	Oem     map[string]*VolumeOem `json:"Oem"`
+}
+
+// DeleteVolumeRequest represents a request to delete a volume
+type DeleteVolumeRequest struct {
+	System  string `json:"system"`
+	Storage string `json:"storage"`
+	Volume  string `json:"volume"`
+}
+
+// Server serves the Redfish resources from the frontend of the bridge, the tenant of the context
+// only sees its own, under the IDs it knows them by
+type Server struct {
+	frontend pb.FrontendNvmeServiceServer
+}
+
+// NewServer returns a Server of the Nvme subsystems and namespaces of frontend
+func NewServer(frontend pb.FrontendNvmeServiceServer) *Server {
+	return &Server{frontend: frontend}
+}
+
+// GetServiceRoot gets the root of the Redfish service
+func (s *Server) GetServiceRoot(_ context.Context, _ *GetServiceRootRequest) (*ServiceRoot, error) {
+	return &ServiceRoot{
+		ID:             Root,
+		Type:           "#ServiceRoot.v1_5_0.ServiceRoot",
+		Name:           "OPI Marvell bridge",
+		RedfishVersion: "1.6.0",
+		Systems:        &Link{ID: Root + "/Systems"},
+	}, nil
+}
+
+// ListSystems lists the systems, the DPU
+func (s *Server) ListSystems(_ context.Context, _ *ListSystemsRequest) (*Collection, error) {
+	return newCollection(Root+"/Systems", "#ComputerSystemCollection.ComputerSystemCollection", "Systems", []string{SystemID}), nil
+}
+
+// GetSystem gets the DPU
+func (s *Server) GetSystem(_ context.Context, in *GetSystemRequest) (*System, error) {
+	if err := checkSystem(in.System); err != nil {
+		return nil, err
+	}
+	id := Root + "/Systems/" + SystemID
+	return &System{
+		ID:         id,
+		Type:       "#ComputerSystem.v1_13_0.ComputerSystem",
+		SystemID:   SystemID,
+		Name:       "Marvell DPU",
+		SystemType: "DPU",
+		Status:     &Health{State: "Enabled", Health: "OK"},
+		Storage:    &Link{ID: id + "/Storage"},
+	}, nil
+}
+
+// ListStorage lists the Nvme subsystems as storage
+func (s *Server) ListStorage(ctx context.Context, in *ListStorageRequest) (*Collection, error) {
+	if err := checkSystem(in.System); err != nil {
+		return nil, err
+	}
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	pageToken := ""
+	for {
+		response, err := s.frontend.ListNvmeSubsystems(ctx, &pb.ListNvmeSubsystemsRequest{PageToken: pageToken})
+		if err != nil {
+			return nil, err
+		}
+		for _, subsystem := range response.NvmeSubsystems {
+			if name, owned := unscopeName(tenantID, subsystem.Name); owned {
+				ids = append(ids, path.Base(name))
+			}
+		}
+		if response.NextPageToken == "" {
+			break
+		}
+		pageToken = response.NextPageToken
+	}
+	return newCollection(Root+"/Systems/"+SystemID+"/Storage", "#StorageCollection.StorageCollection", "Storage", ids), nil
+}
+
+// GetStorage gets an Nvme subsystem as storage, identified by its NQN
+func (s *Server) GetStorage(ctx context.Context, in *GetStorageRequest) (*Storage, error) {
+	if err := checkSystem(in.System); err != nil {
+		return nil, err
+	}
+	subsystemID, err := scopedSubsystemID(ctx, in.Storage)
+	if err != nil {
+		return nil, err
+	}
+	subsystem, err := s.frontend.GetNvmeSubsystem(ctx, &pb.GetNvmeSubsystemRequest{Name: utils.ResourceIDToSubsystemName(subsystemID)})
+	if err != nil {
+		return nil, err
+	}
+	id := Root + "/Systems/" + SystemID + "/Storage/" + in.Storage
+	return &Storage{
+		ID:          id,
+		Type:        "#Storage.v1_9_0.Storage",
+		StorageID:   in.Storage,
+		Name:        in.Storage,
+		Identifiers: []*Identifier{{DurableName: subsystem.GetSpec().GetNqn(), DurableNameFormat: "NQN"}},
+		Status:      &Health{State: "Enabled", Health: "OK"},
+		Volumes:     &Link{ID: id + "/Volumes"},
+	}, nil
+}
+
+// ListVolumes lists the Nvme namespaces of a subsystem as volumes
+func (s *Server) ListVolumes(ctx context.Context, in *ListVolumesRequest) (*Collection, error) {
+	if err := checkSystem(in.System); err != nil {
+		return nil, err
+	}
+	subsystemID, err := scopedSubsystemID(ctx, in.Storage)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	pageToken := ""
+	for {
+		response, err := s.frontend.ListNvmeNamespaces(ctx, &pb.ListNvmeNamespacesRequest{
+			Parent:    utils.ResourceIDToSubsystemName(subsystemID),
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, namespace := range response.NvmeNamespaces {
+			ids = append(ids, path.Base(namespace.Name))
+		}
+		if response.NextPageToken == "" {
+			break
+		}
+		pageToken = response.NextPageToken
+	}
+	return newCollection(Root+"/Systems/"+SystemID+"/Storage/"+in.Storage+"/Volumes", "#VolumeCollection.VolumeCollection", "Volumes", ids), nil
+}
+
+// GetVolume gets an Nvme namespace as a volume, identified by its UUID and NGUID
+func (s *Server) GetVolume(ctx context.Context, in *GetVolumeRequest) (*Volume, error) {
+	if err := checkSystem(in.System); err != nil {
+		return nil, err
+	}
+	subsystemID, err := scopedSubsystemID(ctx, in.Storage)
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := s.frontend.GetNvmeNamespace(ctx, &pb.GetNvmeNamespaceRequest{
+		Name: utils.ResourceIDToNamespaceName(subsystemID, in.Volume),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newVolume(ctx, in.Storage, namespace), nil
+}
+
+// CreateVolume creates an Nvme namespace on the backend volume of the Oem properties
+func (s *Server) CreateVolume(ctx context.Context, in *CreateVolumeRequest) (*Volume, error) {
+	if err := checkSystem(in.System); err != nil {
+		return nil, err
+	}
+	oem := in.Oem[oemKey]
+	if oem == nil || oem.VolumeNameRef == "" {
+		msg := fmt.Sprintf("Missing Oem.%s.VolumeNameRef, the backend volume of the namespace", oemKey)
+		return nil, status.Errorf(codes.InvalidArgument, msg)
+	}
+	subsystemID, err := scopedSubsystemID(ctx, in.Storage)
+	if err != nil {
+		return nil, err
+	}
+	id := in.Name
+	if id == "" {
+		id = resourceid.NewSystemGenerated()
+	}
+	// the backend volume is one of the tenant too
+	volumeNameRef := oem.VolumeNameRef
+	if tenantID, _ := tenant.FromContext(ctx); tenantID != "" {
+		volumeNameRef = tenant.ScopeName(tenantID, volumeNameRef)
+	}
+	namespace, err := s.frontend.CreateNvmeNamespace(ctx, &pb.CreateNvmeNamespaceRequest{
+		Parent:          utils.ResourceIDToSubsystemName(subsystemID),
+		NvmeNamespaceId: id,
+		NvmeNamespace: &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{
+			VolumeNameRef: volumeNameRef,
+			HostNsid:      oem.HostNsid,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newVolume(ctx, in.Storage, namespace), nil
+}
+
+// DeleteVolume deletes the Nvme namespace of a volume
+func (s *Server) DeleteVolume(ctx context.Context, in *DeleteVolumeRequest) (*emptypb.Empty, error) {
+	if err := checkSystem(in.System); err != nil {
+		return nil, err
+	}
+	subsystemID, err := scopedSubsystemID(ctx, in.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return s.frontend.DeleteNvmeNamespace(ctx, &pb.DeleteNvmeNamespaceRequest{
+		Name: utils.ResourceIDToNamespaceName(subsystemID, in.Volume),
+	})
+}
+
+// scopedSubsystemID returns the ID of the Nvme subsystem of a storage of the tenant of the
+// context, the one of the storage itself without tenant
+func scopedSubsystemID(ctx context.Context, storage string) (string, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil || tenantID == "" {
+		return storage, err
+	}
+	return tenant.ScopeID(tenantID, storage), nil
+}
+
+// unscopeName returns the name of a resource seen by a tenant, false when it belongs to another
+// tenant. The callers without tenant see all the resources
+func unscopeName(tenantID string, name string) (string, bool) {
+	if tenantID == "" {
+		return name, true
+	}
+	return tenant.UnscopeName(tenantID, name)
+}
+
+// checkSystem checks the system is the DPU
+func checkSystem(system string) error {
+	if system != SystemID {
+		msg := fmt.Sprintf("unable to find key %s, the only system is %s", system, SystemID)
+		return status.Errorf(codes.NotFound, msg)
+	}
+	return nil
+}
+
+// newCollection returns the collection of the resources of ids under id
+func newCollection(id string, odataType string, name string, ids []string) *Collection {
+	collection := &Collection{ID: id, Type: odataType, Name: name, Count: len(ids), Members: make([]*Link, 0, len(ids))}
+	for _, member := range ids {
+		collection.Members = append(collection.Members, &Link{ID: id + "/" + member})
+	}
+	return collection
+}
+
+// newVolume returns the volume of a namespace of the storage, with the backend volume the tenant
+// of the context knows
+func newVolume(ctx context.Context, storage string, namespace *pb.NvmeNamespace) *Volume {
+	id := path.Base(namespace.Name)
+	tenantID, _ := tenant.FromContext(ctx)
+	volumeNameRef, _ := unscopeName(tenantID, namespace.GetSpec().GetVolumeNameRef())
+	volume := &Volume{
+		ID:          Root + "/Systems/" + SystemID + "/Storage/" + storage + "/Volumes/" + id,
+		Type:        "#Volume.v1_6_0.Volume",
+		VolumeID:    id,
+		Name:        id,
+		Identifiers: []*Identifier{},
+		Status:      &Health{State: "Enabled", Health: "OK"},
+		Oem: map[string]*VolumeOem{oemKey: {
+			VolumeNameRef: volumeNameRef,
+			HostNsid:      namespace.GetSpec().GetHostNsid(),
+		}},
+	}
+	if uuid := namespace.GetSpec().GetUuid(); uuid != "" {
+		volume.Identifiers = append(volume.Identifiers, &Identifier{DurableName: uuid, DurableNameFormat: "UUID"})
+	}
+	if nguid := namespace.GetSpec().GetNguid(); nguid != "" {
+		volume.Identifiers = append(volume.Identifiers, &Identifier{DurableName: nguid, DurableNameFormat: "NGUID"})
+	}
+	switch {
+	case namespace.GetStatus().GetState() == pb.NvmeNamespaceStatus_STATE_DISABLED:
+		volume.Status.State = "Disabled"
+	case namespace.GetStatus().GetOperState() == pb.NvmeNamespaceStatus_OPER_STATE_OFFLINE:
+		volume.Status.State, volume.Status.Health = "UnavailableOffline", "Critical"
+	}
+	return volume
+}
@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package redfish serves the Nvme subsystems and namespaces of the bridge as the Storage and the
+// Volumes of a Redfish system, for the datacenter tooling built around the BMCs
+package redfish
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-marvell-bridge/pkg/tenant"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// testFrontend serves the subsystems, subsys0 and subsys1 by default, and their namespaces one
+// per page
+type testFrontend struct {
+	pb.UnimplementedFrontendNvmeServiceServer
+	subsystems []*pb.NvmeSubsystem
+	namespaces []*pb.NvmeNamespace
+}
+
+func newTestFrontend() *testFrontend {
+	return &testFrontend{subsystems: []*pb.NvmeSubsystem{
+		{Name: "nvmeSubsystems/subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi0"}},
+		{Name: "nvmeSubsystems/subsys1", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi1"}},
+	}}
+}
+
+func (f *testFrontend) ListNvmeSubsystems(_ context.Context, _ *pb.ListNvmeSubsystemsRequest) (*pb.ListNvmeSubsystemsResponse, error) {
+	return &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: f.subsystems}, nil
+}
+
+func (f *testFrontend) GetNvmeSubsystem(_ context.Context, in *pb.GetNvmeSubsystemRequest) (*pb.NvmeSubsystem, error) {
+	for _, subsystem := range f.subsystems {
+		if subsystem.Name == in.Name {
+			return subsystem, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+}
+
+func (f *testFrontend) ListNvmeNamespaces(_ context.Context, in *pb.ListNvmeNamespacesRequest) (*pb.ListNvmeNamespacesResponse, error) {
+	var namespaces []*pb.NvmeNamespace
+	for _, namespace := range f.namespaces {
+		if strings.HasPrefix(namespace.Name, in.Parent+"/") {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	response := &pb.ListNvmeNamespacesResponse{}
+	for i, namespace := range namespaces {
+		if in.PageToken == "" && i == 0 || in.PageToken == namespace.Name {
+			response.NvmeNamespaces = []*pb.NvmeNamespace{namespace}
+			if i+1 < len(namespaces) {
+				response.NextPageToken = namespaces[i+1].Name
+			}
+		}
+	}
+	return response, nil
+}
+
+func (f *testFrontend) CreateNvmeNamespace(_ context.Context, in *pb.CreateNvmeNamespaceRequest) (*pb.NvmeNamespace, error) {
+	namespace := &pb.NvmeNamespace{Name: in.Parent + "/nvmeNamespaces/" + in.NvmeNamespaceId, Spec: in.NvmeNamespace.Spec}
+	f.namespaces = append(f.namespaces, namespace)
+	return namespace, nil
+}
+
+func (f *testFrontend) DeleteNvmeNamespace(_ context.Context, in *pb.DeleteNvmeNamespaceRequest) (*emptypb.Empty, error) {
+	var kept []*pb.NvmeNamespace
+	for _, namespace := range f.namespaces {
+		if namespace.Name != in.Name {
+			kept = append(kept, namespace)
+		}
+	}
+	f.namespaces = kept
+	return &emptypb.Empty{}, nil
+}
+
+func TestRedfish_ListStorage(t *testing.T) {
+	tests := map[string]struct {
+		system  string
+		out     *Collection
+		errCode codes.Code
+		errMsg  string
+	}{
+		"valid request": {
+			system: SystemID,
+			out: &Collection{
+				ID:    "/redfish/v1/Systems/DPU/Storage",
+				Type:  "#StorageCollection.StorageCollection",
+				Name:  "Storage",
+				Count: 2,
+				Members: []*Link{
+					{ID: "/redfish/v1/Systems/DPU/Storage/subsys0"},
+					{ID: "/redfish/v1/Systems/DPU/Storage/subsys1"},
+				},
+			},
+			errCode: codes.OK,
+		},
+		"unknown system": {
+			system:  "host",
+			errCode: codes.NotFound,
+			errMsg:  "unable to find key host, the only system is DPU",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := NewServer(newTestFrontend())
+
+			response, err := server.ListStorage(context.Background(), &ListStorageRequest{System: tt.system})
+
+			if !reflect.DeepEqual(response, tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestRedfish_GetStorage(t *testing.T) {
+	server := NewServer(newTestFrontend())
+
+	storage, err := server.GetStorage(context.Background(), &GetStorageRequest{System: SystemID, Storage: "subsys0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Identifier{{DurableName: "nqn.2022-09.io.spdk:opi0", DurableNameFormat: "NQN"}}
+	if !reflect.DeepEqual(storage.Identifiers, expected) {
+		t.Error("identifiers: expected", expected, "received", storage.Identifiers)
+	}
+	if storage.Volumes.ID != "/redfish/v1/Systems/DPU/Storage/subsys0/Volumes" {
+		t.Error("volumes: expected /redfish/v1/Systems/DPU/Storage/subsys0/Volumes, received", storage.Volumes.ID)
+	}
+
+	_, err = server.GetStorage(context.Background(), &GetStorageRequest{System: SystemID, Storage: "subsys2"})
+	if status.Code(err) != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", status.Code(err))
+	}
+}
+
+func TestRedfish_VolumeLifecycle(t *testing.T) {
+	frontend := newTestFrontend()
+	server := NewServer(frontend)
+	ctx := context.Background()
+
+	_, err := server.CreateVolume(ctx, &CreateVolumeRequest{System: SystemID, Storage: "subsys0", Name: "ns0"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error("error code: expected", codes.InvalidArgument, "received", status.Code(err))
+	}
+	for i, id := range []string{"ns0", "ns1"} {
+		volume, err := server.CreateVolume(ctx, &CreateVolumeRequest{
+			System:  SystemID,
+			Storage: "subsys0",
+			Name:    id,
+			Oem:     map[string]*VolumeOem{"Marvell": {VolumeNameRef: "Malloc" + id[2:], HostNsid: int32(i + 1)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &Volume{
+			ID:          "/redfish/v1/Systems/DPU/Storage/subsys0/Volumes/" + id,
+			Type:        "#Volume.v1_6_0.Volume",
+			VolumeID:    id,
+			Name:        id,
+			Identifiers: []*Identifier{},
+			Status:      &Health{State: "Enabled", Health: "OK"},
+			Oem:         map[string]*VolumeOem{"Marvell": {VolumeNameRef: "Malloc" + id[2:], HostNsid: int32(i + 1)}},
+		}
+		if !reflect.DeepEqual(volume, expected) {
+			t.Error("volume: expected", expected, "received", volume)
+		}
+	}
+
+	// the volumes of all the pages are listed
+	volumes, err := server.ListVolumes(ctx, &ListVolumesRequest{System: SystemID, Storage: "subsys0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Link{
+		{ID: "/redfish/v1/Systems/DPU/Storage/subsys0/Volumes/ns0"},
+		{ID: "/redfish/v1/Systems/DPU/Storage/subsys0/Volumes/ns1"},
+	}
+	if !reflect.DeepEqual(volumes.Members, expected) || volumes.Count != 2 {
+		t.Error("members: expected", expected, "received", volumes.Members)
+	}
+
+	if _, err := server.DeleteVolume(ctx, &DeleteVolumeRequest{System: SystemID, Storage: "subsys0", Volume: "ns0"}); err != nil {
+		t.Fatal(err)
+	}
+	volumes, err = server.ListVolumes(ctx, &ListVolumesRequest{System: SystemID, Storage: "subsys0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(volumes.Members, expected[1:]) {
+		t.Error("members: expected", expected[1:], "received", volumes.Members)
+	}
+}
+
+func TestRedfish_Tenants(t *testing.T) {
+	frontend := newTestFrontend()
+	frontend.subsystems = []*pb.NvmeSubsystem{
+		{Name: "nvmeSubsystems/acme-subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi0"}},
+		{Name: "nvmeSubsystems/other-subsys0", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi1"}},
+		{Name: "nvmeSubsystems/other-subsys1", Spec: &pb.NvmeSubsystemSpec{Nqn: "nqn.2022-09.io.spdk:opi2"}},
+	}
+	server := NewServer(frontend)
+	acme := tenant.NewContext(context.Background(), "acme")
+	other := tenant.NewContext(context.Background(), "other")
+
+	volume, err := server.CreateVolume(acme, &CreateVolumeRequest{
+		System:  SystemID,
+		Storage: "subsys0",
+		Name:    "ns0",
+		Oem:     map[string]*VolumeOem{"Marvell": {VolumeNameRef: "Malloc0", HostNsid: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if volume.ID != "/redfish/v1/Systems/DPU/Storage/subsys0/Volumes/ns0" || volume.Oem["Marvell"].VolumeNameRef != "Malloc0" {
+		t.Error("volume: expected ns0 of subsys0 on Malloc0, received", volume.ID, volume.Oem["Marvell"])
+	}
+	expected := []string{"nvmeSubsystems/acme-subsys0/nvmeNamespaces/ns0 acme-Malloc0"}
+	var namespaces []string
+	for _, namespace := range frontend.namespaces {
+		namespaces = append(namespaces, namespace.Name+" "+namespace.Spec.VolumeNameRef)
+	}
+	if !reflect.DeepEqual(namespaces, expected) {
+		t.Error("namespaces: expected", expected, "received", namespaces)
+	}
+
+	tests := map[string]struct {
+		ctx     context.Context
+		storage []*Link
+		volumes []*Link
+	}{
+		"tenant": {
+			ctx:     acme,
+			storage: []*Link{{ID: "/redfish/v1/Systems/DPU/Storage/subsys0"}},
+			volumes: []*Link{{ID: "/redfish/v1/Systems/DPU/Storage/subsys0/Volumes/ns0"}},
+		},
+		"other tenant": {
+			ctx: other,
+			storage: []*Link{
+				{ID: "/redfish/v1/Systems/DPU/Storage/subsys0"},
+				{ID: "/redfish/v1/Systems/DPU/Storage/subsys1"},
+			},
+			volumes: []*Link{},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			storage, err := server.ListStorage(tt.ctx, &ListStorageRequest{System: SystemID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(storage.Members, tt.storage) {
+				t.Error("storage: expected", tt.storage, "received", storage.Members)
+			}
+			volumes, err := server.ListVolumes(tt.ctx, &ListVolumesRequest{System: SystemID, Storage: "subsys0"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(volumes.Members, tt.volumes) {
+				t.Error("volumes: expected", tt.volumes, "received", volumes.Members)
+			}
+		})
+	}
+
+	// the storage of the other tenants can't be reached by its ID
+	if _, err := server.GetStorage(acme, &GetStorageRequest{System: SystemID, Storage: "subsys1"}); status.Code(err) != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", status.Code(err))
+	}
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package requestid correlates the records of a request, from the client to the firmware, with
+// an ID taken from the client or generated by the bridge
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata carrying the ID of a request, it is sent back in the headers
+const MetadataKey = "x-request-id"
+
+// HeaderKey is the HTTP header carrying the ID of a request, it is sent back in the response
+const HeaderKey = "X-Request-Id"
+
+// idPattern keeps the IDs of the clients short and printable, the others are replaced
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// idKey is the context key of the ID of a request
+type idKey struct{}
+
+// New generates a request ID
+func New() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// NewContext returns a context carrying a request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// FromContext returns the ID of the request of a context, empty when it has none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// orNew returns the ID provided by a client, or a generated one when it is missing or invalid
+func orNew(id string) string {
+	if idPattern.MatchString(id) {
+		return id
+	}
+	return New()
+}
+
+// UnaryServerInterceptor gives an ID to the gRPC requests, the one in the metadata or a generated
+// one, and sends it back in the headers. It comes first so all the records of a request carry it
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(MetadataKey); len(values) != 0 {
+				id = values[0]
+			}
+		}
+		id = orNew(id)
+		// the headers can't be sent outside of a gRPC server, i.e. in the tests
+		_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+		return handler(NewContext(ctx, id), req)
+	}
+}
+
+// FromHTTPRequest gives an ID to an HTTP request, the one in its header or a generated one, and
+// sets it in the header of the response
+func FromHTTPRequest(w http.ResponseWriter, r *http.Request) context.Context {
+	id := orNew(r.Header.Get(HeaderKey))
+	w.Header().Set(HeaderKey, id)
+	return NewContext(r.Context(), id)
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package requestid correlates the records of a request, from the client to the firmware, with
+// an ID taken from the client or generated by the bridge
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var generatedIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+func TestRequestID_UnaryServerInterceptor(t *testing.T) {
+	tests := map[string]struct {
+		md        metadata.MD
+		id        string
+		generated bool
+	}{
+		"id of the client": {
+			md:        metadata.Pairs(MetadataKey, "c0ffee-42"),
+			id:        "c0ffee-42",
+			generated: false,
+		},
+		"missing id": {
+			md:        nil,
+			id:        "",
+			generated: true,
+		},
+		"invalid id": {
+			md:        metadata.Pairs(MetadataKey, "a b\nc"),
+			id:        "",
+			generated: true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			var id string
+			handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+				id = FromContext(ctx)
+				return nil, nil
+			}
+			if _, err := UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+				t.Fatal(err)
+			}
+			if tt.generated && !generatedIDPattern.MatchString(id) {
+				t.Error("id: expected a generated one, received", id)
+			}
+			if !tt.generated && id != tt.id {
+				t.Error("id: expected", tt.id, "received", id)
+			}
+		})
+	}
+}
+
+func TestRequestID_FromHTTPRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/dpu/telemetry", nil)
+	r.Header.Set(HeaderKey, "c0ffee-42")
+	w := httptest.NewRecorder()
+	if id := FromContext(FromHTTPRequest(w, r)); id != "c0ffee-42" {
+		t.Error("id: expected c0ffee-42, received", id)
+	}
+	if id := w.Header().Get(HeaderKey); id != "c0ffee-42" {
+		t.Error("response header: expected c0ffee-42, received", id)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/dpu/telemetry", nil)
+	w = httptest.NewRecorder()
+	id := FromContext(FromHTTPRequest(w, r))
+	if !generatedIDPattern.MatchString(id) || w.Header().Get(HeaderKey) != id {
+		t.Error("id: expected a generated one sent back, received", id, w.Header().Get(HeaderKey))
+	}
+}
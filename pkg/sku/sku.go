@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package sku selects the profile of the Marvell SKU the bridge runs on, i.e. an OCTEON 10
+// CN106, with the features the bridge offers, the limits it enforces on the resources and the
+// variants of the firmware methods of the SKU, so one binary serves the whole product line
+package sku
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/compat"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is the profile of the SKUs no other profile matches
+const DefaultProfile = "default"
+
+// Limits are the maximum numbers of resources of a SKU, 0 when the firmware decides alone
+type Limits struct {
+	MaxSubsystems  int32 `yaml:"max_subsystems" json:"maxSubsystems"`
+	MaxControllers int32 `yaml:"max_controllers" json:"maxControllers"`
+	MaxNamespaces  int32 `yaml:"max_namespaces" json:"maxNamespaces"`
+	// MaxControllersPerPf and MaxControllersPerVf are the maximum numbers of controllers on
+	// each physical and virtual function
+	MaxControllersPerPf int32 `yaml:"max_controllers_per_pf" json:"maxControllersPerPf"`
+	MaxControllersPerVf int32 `yaml:"max_controllers_per_vf" json:"maxControllersPerVf"`
+}
+
+// Profile represents the features, the limits and the firmware method variants of SKUs
+type Profile struct {
+	// Name of the profile, i.e. cn106
+	Name string `yaml:"name" json:"name"`
+	// Skus are the patterns of the SKUs of the profile, i.e. CN106*
+	Skus []string `yaml:"skus" json:"skus"`
+	// Features the bridge offers on the SKUs when the firmware reports them too, all the ones
+	// of the firmware when unset
+	Features []string `yaml:"features" json:"features,omitempty"`
+	// Limits enforced by the bridge on the resources
+	Limits Limits `yaml:"limits" json:"limits"`
+	// Shims are the variants of the firmware methods on the SKUs
+	Shims []compat.Shim `yaml:"shims" json:"-"`
+}
+
+// builtinProfiles are the profiles of the product line, they only tell the SKUs apart, the
+// features and the limits being the ones the firmware reports until -sku_profiles refines them
+var builtinProfiles = []*Profile{
+	{Name: "cn106", Skus: []string{"CN106*"}},
+	{Name: "cn103", Skus: []string{"CN103*"}},
+	{Name: "cn102", Skus: []string{"CN102*"}},
+	{Name: "cn98", Skus: []string{"CN98*"}},
+	{Name: "cn96", Skus: []string{"CN96*"}},
+	{Name: DefaultProfile, Skus: []string{"*"}},
+}
+
+// profilesFile is the YAML file of the profiles
+type profilesFile struct {
+	Profiles []*Profile `yaml:"profiles"`
+}
+
+// LoadProfiles reads the profiles of a YAML file, a list of them under profiles, they are
+// matched before the built-in ones and replace the built-in ones of the same name
+func LoadProfiles(file string) ([]*Profile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var content profilesFile
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("invalid SKU profiles %s: %w", file, err)
+	}
+	names := make(map[string]bool)
+	for i, profile := range content.Profiles {
+		if profile.Name == "" {
+			return nil, fmt.Errorf("invalid SKU profile %d, the name has to be set", i+1)
+		}
+		if names[profile.Name] {
+			return nil, fmt.Errorf("invalid SKU profile %s, it is defined twice", profile.Name)
+		}
+		names[profile.Name] = true
+		for _, pattern := range profile.Skus {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid SKU profile %s, bad SKU pattern %q", profile.Name, pattern)
+			}
+		}
+		if profile.Limits.MaxSubsystems < 0 || profile.Limits.MaxControllers < 0 || profile.Limits.MaxNamespaces < 0 ||
+			profile.Limits.MaxControllersPerPf < 0 || profile.Limits.MaxControllersPerVf < 0 {
+			return nil, fmt.Errorf("invalid SKU profile %s, the limits can't be negative", profile.Name)
+		}
+		for j := range profile.Shims {
+			if profile.Shims[j].Method == "" {
+				return nil, fmt.Errorf("invalid SKU profile %s, the method of shim %d has to be set", profile.Name, j+1)
+			}
+			if err := profile.Shims[j].Validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return content.Profiles, nil
+}
+
+// Selector selects the profile of the SKU of the firmware, detected on the first call and
+// unless a profile is forced
+type Selector struct {
+	rpc      spdk.JSONRPC
+	profiles []*Profile
+	// forced is the profile chosen by the configuration, nil when it is detected
+	forced *Profile
+	mu     sync.Mutex
+	// detected is the profile of the SKU of the firmware, nil until it answers
+	detected *Profile
+}
+
+// NewSelector returns a Selector of the given profiles and the built-in ones, forced names the
+// profile to use whatever the SKU, the one of the SKU is detected when empty
+func NewSelector(rpc spdk.JSONRPC, profiles []*Profile, forced string) (*Selector, error) {
+	s := &Selector{rpc: rpc, profiles: append([]*Profile{}, profiles...)}
+	for _, builtin := range builtinProfiles {
+		if s.byName(builtin.Name) == nil {
+			s.profiles = append(s.profiles, builtin)
+		}
+	}
+	if forced != "" {
+		if s.forced = s.byName(forced); s.forced == nil {
+			return nil, fmt.Errorf("unknown SKU profile %q, known profiles are %s", forced, strings.Join(s.Names(), ", "))
+		}
+	}
+	return s, nil
+}
+
+// byName returns the profile of a name, nil when there is none
+func (s *Selector) byName(name string) *Profile {
+	for _, profile := range s.profiles {
+		if profile.Name == name {
+			return profile
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the profiles, in the order they are matched
+func (s *Selector) Names() []string {
+	names := make([]string, 0, len(s.profiles))
+	for _, profile := range s.profiles {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+// Select returns the profile of a SKU, the forced one when there is one
+func (s *Selector) Select(sku string) *Profile {
+	if s.forced != nil {
+		return s.forced
+	}
+	for _, profile := range s.profiles {
+		if compat.MatchSku(profile.Skus, sku) {
+			return profile
+		}
+	}
+	return s.byName(DefaultProfile)
+}
+
+// Profile returns the profile of the SKU of the firmware, the default one as long as the
+// firmware doesn't answer
+func (s *Selector) Profile(ctx context.Context) *Profile {
+	if s.forced != nil {
+		return s.forced
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.detected != nil {
+		return s.detected
+	}
+	var result models.MrvlPlatformGetInventoryResult
+	if err := s.rpc.Call(ctx, "mrvl_platform_get_inventory", nil, &result); err != nil || result.Status != 0 {
+		slog.WarnContext(ctx, "Could not detect the SKU of the firmware", "error", err, "status", result.Status)
+		return s.byName(DefaultProfile)
+	}
+	s.detected = s.Select(result.Sku)
+	slog.InfoContext(ctx, "Selected the SKU profile", "sku", result.Sku, "profile", s.detected.Name)
+	return s.detected
+}
+
+// Limits returns the limits of the profile of the SKU of the firmware
+func (s *Selector) Limits(ctx context.Context) Limits {
+	return s.Profile(ctx).Limits
+}
+
+// Shims returns the firmware method variants of all the profiles, each restricted to the SKUs of
+// its profile unless the profile is forced
+func (s *Selector) Shims() []compat.Shim {
+	var shims []compat.Shim
+	for _, profile := range s.profiles {
+		if s.forced != nil && profile != s.forced {
+			continue
+		}
+		for _, shim := range profile.Shims {
+			if s.forced == nil && len(shim.Skus) == 0 {
+				shim.Skus = profile.Skus
+			}
+			shims = append(shims, shim)
+		}
+	}
+	return shims
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package sku selects the profile of the Marvell SKU the bridge runs on, i.e. an OCTEON 10
+// CN106, with the features the bridge offers, the limits it enforces on the resources and the
+// variants of the firmware methods of the SKU, so one binary serves the whole product line
+package sku
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/compat"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+// testJSONRPC is a firmware reporting a SKU, it counts the calls
+type testJSONRPC struct {
+	spdk.JSONRPC
+	sku   string
+	err   error
+	calls int
+}
+
+func (c *testJSONRPC) Call(_ context.Context, _ string, _, result interface{}) error {
+	c.calls++
+	if c.err != nil {
+		return c.err
+	}
+	result.(*models.MrvlPlatformGetInventoryResult).Sku = c.sku
+	return nil
+}
+
+// testProfiles limit a revision of the CN106 and define the CN106 profile anew
+var testProfiles = []*Profile{
+	{
+		Name:     "cn106-a0",
+		Skus:     []string{"CN106-A0*"},
+		Features: []string{"crypto"},
+		Limits:   Limits{MaxSubsystems: 8, MaxControllers: 32, MaxNamespaces: 64},
+		Shims:    []compat.Shim{{Method: "mrvl_nvm_get_ctrlr_stats", Rename: "mrvl_nvm_ctrlr_get_stats"}},
+	},
+	{
+		Name:   "cn106",
+		Skus:   []string{"CN106*"},
+		Limits: Limits{MaxSubsystems: 16},
+	},
+}
+
+func TestSku_Select(t *testing.T) {
+	selector, err := NewSelector(&testJSONRPC{}, testProfiles, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		sku     string
+		profile string
+	}{
+		"revision":      {"CN106-A0-25G", "cn106-a0"},
+		"lower case":    {"cn106-b0", "cn106"},
+		"built-in":      {"CN9880", "cn98"},
+		"unknown":       {"CN5000", DefaultProfile},
+		"no SKU at all": {"", DefaultProfile},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		if profile := selector.Select(tt.sku); profile.Name != tt.profile {
+			t.Error(name, "expected", tt.profile, "received", profile.Name)
+		}
+	}
+	expected := "cn106-a0 cn106 cn103 cn102 cn98 cn96 default"
+	if names := strings.Join(selector.Names(), " "); names != expected {
+		t.Error("names: expected", expected, "received", names)
+	}
+}
+
+func TestSku_Profile(t *testing.T) {
+	firmware := &testJSONRPC{err: errors.New("connection refused")}
+	selector, err := NewSelector(firmware, testProfiles, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile := selector.Profile(context.Background()); profile.Name != DefaultProfile {
+		t.Error("profile: expected the default one while the firmware doesn't answer, received", profile.Name)
+	}
+	firmware.err = nil
+	firmware.sku = "CN106-A0-25G"
+	if limits := selector.Limits(context.Background()); limits != testProfiles[0].Limits {
+		t.Error("limits: expected", testProfiles[0].Limits, "received", limits)
+	}
+	_ = selector.Profile(context.Background())
+	if firmware.calls != 2 {
+		t.Error("calls: expected the SKU to be detected once, received", firmware.calls)
+	}
+
+	selector, err = NewSelector(firmware, testProfiles, "cn98")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile := selector.Profile(context.Background()); profile.Name != "cn98" || firmware.calls != 2 {
+		t.Error("profile: expected the forced one without detection, received", profile.Name)
+	}
+	if _, err := NewSelector(firmware, testProfiles, "cn42"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+func TestSku_Shims(t *testing.T) {
+	selector, _ := NewSelector(&testJSONRPC{}, testProfiles, "")
+	expected := []compat.Shim{{Method: "mrvl_nvm_get_ctrlr_stats", Rename: "mrvl_nvm_ctrlr_get_stats", Skus: []string{"CN106-A0*"}}}
+	if shims := selector.Shims(); !reflect.DeepEqual(shims, expected) {
+		t.Error("shims: expected", expected, "received", shims)
+	}
+	if testProfiles[0].Shims[0].Skus != nil {
+		t.Error("shims: expected the profile unchanged, received", testProfiles[0].Shims)
+	}
+	selector, _ = NewSelector(&testJSONRPC{}, testProfiles, "cn106-a0")
+	if shims := selector.Shims(); !reflect.DeepEqual(shims, testProfiles[0].Shims) {
+		t.Error("shims: expected", testProfiles[0].Shims, "received", shims)
+	}
+	selector, _ = NewSelector(&testJSONRPC{}, testProfiles, "cn106")
+	if shims := selector.Shims(); len(shims) != 0 {
+		t.Error("shims: expected none, received", shims)
+	}
+}
+
+func TestSku_LoadProfiles(t *testing.T) {
+	tests := map[string]struct {
+		content  string
+		profiles []*Profile
+		errMsg   string
+	}{
+		"valid": {
+			content:  "profiles:\n- name: cn106\n  skus: [\"CN106*\"]\n  features: [crypto]\n  limits:\n    max_subsystems: 16\n",
+			profiles: []*Profile{{Name: "cn106", Skus: []string{"CN106*"}, Features: []string{"crypto"}, Limits: Limits{MaxSubsystems: 16}}},
+		},
+		"missing name": {
+			content: "profiles:\n- skus: [\"CN106*\"]\n",
+			errMsg:  "invalid SKU profile 1, the name has to be set",
+		},
+		"defined twice": {
+			content: "profiles:\n- name: cn106\n- name: cn106\n",
+			errMsg:  "invalid SKU profile cn106, it is defined twice",
+		},
+		"bad pattern": {
+			content: "profiles:\n- name: cn106\n  skus: [\"CN106[\"]\n",
+			errMsg:  `invalid SKU profile cn106, bad SKU pattern "CN106["`,
+		},
+		"negative limit": {
+			content: "profiles:\n- name: cn106\n  limits:\n    max_namespaces: -1\n",
+			errMsg:  "invalid SKU profile cn106, the limits can't be negative",
+		},
+		"negative function limit": {
+			content: "profiles:\n- name: cn106\n  limits:\n    max_controllers_per_vf: -1\n",
+			errMsg:  "invalid SKU profile cn106, the limits can't be negative",
+		},
+		"shim without method": {
+			content: "profiles:\n- name: cn106\n  shims:\n  - rename: mrvl_nvm_ctrlr_get_stats\n",
+			errMsg:  "invalid SKU profile cn106, the method of shim 1 has to be set",
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "profiles.yaml")
+			if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			profiles, err := LoadProfiles(file)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(profiles, tt.profiles) {
+				t.Error("profiles: expected", tt.profiles, "received", profiles)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package spdktest provides the JSON-RPC client the tests of the wrappers of the firmware calls
+// use in place of SPDK
+package spdktest
+
+import (
+	"context"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// StatusResult mimics the results of the Marvell methods
+type StatusResult struct {
+	Status int `json:"status"`
+}
+
+// JSONRPC answers the calls with a status, set in the StatusResult, and an error
+type JSONRPC struct {
+	spdk.JSONRPC
+	Status int
+	Err    error
+}
+
+// Call implements spdk.JSONRPC
+func (c *JSONRPC) Call(_ context.Context, _ string, _, result interface{}) error {
+	if r, ok := result.(*StatusResult); ok {
+		r.Status = c.Status
+	}
+	return c.Err
+}
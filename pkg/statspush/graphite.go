@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package statspush pushes the OpenTelemetry metrics of the bridge to statsd, Graphite or a
+// Prometheus remote-write endpoint, for the sites which can't scrape the DPUs behind their
+// management NAT
+package statspush
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// graphiteTimeout bounds the exchange with the Graphite server
+const graphiteTimeout = 10 * time.Second
+
+// graphiteSink sends the points over TCP in the plaintext protocol with tags, a connection is
+// made for each push as Graphite closes the idle ones
+type graphiteSink struct {
+	address string
+}
+
+func (s *graphiteSink) temporality() metricdata.Temporality {
+	return metricdata.CumulativeTemporality
+}
+
+// push sends the lines of the points then closes the connection
+func (s *graphiteSink) push(ctx context.Context, points []point) error {
+	dialer := net.Dialer{Timeout: graphiteTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+	writer := bufio.NewWriter(conn)
+	for _, p := range points {
+		if _, err := writer.WriteString(graphiteLine(p)); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// graphiteLine formats a point, i.e.
+// opi.nvme.controller.read.bytes;controller=ctrl1 4096 1700000000
+func graphiteLine(p point) string {
+	const separators = ";~="
+	var line strings.Builder
+	line.WriteString(sanitize(p.name, separators))
+	for _, l := range p.labels {
+		line.WriteString(";" + sanitize(l.key, separators) + "=" + sanitize(l.value, separators))
+	}
+	line.WriteString(" " + strconv.FormatFloat(p.value, 'f', -1, 64))
+	line.WriteString(" " + strconv.FormatInt(p.time.Unix(), 10) + "\n")
+	return line.String()
+}
+
+// String returns the URL of the sink
+func (s *graphiteSink) String() string {
+	return fmt.Sprintf("graphite://%s", s.address)
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package statspush pushes the OpenTelemetry metrics of the bridge to statsd, Graphite or a
+// Prometheus remote-write endpoint, for the sites which can't scrape the DPUs behind their
+// management NAT
+package statspush
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/golang/snappy"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteSink posts the points as a snappy compressed WriteRequest of the version 1 of the
+// Prometheus remote-write protocol
+type remoteWriteSink struct {
+	url    string
+	client *http.Client
+}
+
+// newRemoteWriteSink returns a sink posting to url
+func newRemoteWriteSink(url string, client *http.Client) *remoteWriteSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &remoteWriteSink{url: url, client: client}
+}
+
+func (s *remoteWriteSink) temporality() metricdata.Temporality {
+	return metricdata.CumulativeTemporality
+}
+
+// push posts the points in a single request
+func (s *remoteWriteSink) push(ctx context.Context, points []point) error {
+	data := snappy.Encode(nil, writeRequest(points))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+// writeRequest encodes the prometheus.WriteRequest of the points, each point being a time
+// series with a single sample. The messages are small enough to be encoded by hand instead
+// of depending on the Prometheus protos
+func writeRequest(points []point) []byte {
+	var request []byte
+	for _, p := range points {
+		var series []byte
+		for _, l := range remoteWriteLabels(p) {
+			var labelPair []byte
+			labelPair = protowire.AppendTag(labelPair, 1, protowire.BytesType)
+			labelPair = protowire.AppendString(labelPair, l.key)
+			labelPair = protowire.AppendTag(labelPair, 2, protowire.BytesType)
+			labelPair = protowire.AppendString(labelPair, l.value)
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, labelPair)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(p.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(p.time.UnixMilli()))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, sample)
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, series)
+	}
+	return request
+}
+
+// remoteWriteLabels returns the labels of the time series of a point sorted by name, as
+// required by the protocol, the name of the metric being the __name__ label, i.e.
+// opi_nvme_controller_read_bytes_total
+func remoteWriteLabels(p point) []label {
+	name := prometheusName(p.name)
+	if p.counter {
+		name += "_total"
+	}
+	result := []label{{key: "__name__", value: name}}
+	for _, l := range p.labels {
+		result = append(result, label{key: prometheusName(l.key), value: l.value})
+	}
+	sort.Slice(result, func(i int, j int) bool {
+		return result[i].key < result[j].key
+	})
+	return result
+}
+
+// prometheusName replaces the characters not allowed in the names of the metrics and labels
+func prometheusName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// String returns the URL of the sink
+func (s *remoteWriteSink) String() string {
+	return s.url
+}
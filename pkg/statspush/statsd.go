@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package statspush pushes the OpenTelemetry metrics of the bridge to statsd, Graphite or a
+// Prometheus remote-write endpoint, for the sites which can't scrape the DPUs behind their
+// management NAT
+package statspush
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// statsdTimeout bounds the sending of the datagrams of a push
+const statsdTimeout = 10 * time.Second
+
+// maxStatsdPacket is the size of the datagrams, so they aren't fragmented on an ethernet link
+const maxStatsdPacket = 1432
+
+// statsdSink sends the points as UDP datagrams of statsd lines with DogStatsD tags, the counters
+// are the increments since the previous push
+type statsdSink struct {
+	address string
+}
+
+func (s *statsdSink) temporality() metricdata.Temporality {
+	return metricdata.DeltaTemporality
+}
+
+// push sends the lines in as few datagrams as possible
+func (s *statsdSink) push(ctx context.Context, points []point) error {
+	dialer := net.Dialer{Timeout: statsdTimeout}
+	conn, err := dialer.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetWriteDeadline(time.Now().Add(statsdTimeout))
+	var packet bytes.Buffer
+	for _, p := range points {
+		line := statsdLine(p)
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsdPacket {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	_, err = conn.Write(packet.Bytes())
+	return err
+}
+
+// statsdLine formats a point, i.e. opi.nvme.controller.read.bytes:4096|c|#controller:ctrl1
+func statsdLine(p point) string {
+	const separators = ":|#,@"
+	kind := "g"
+	if p.counter {
+		kind = "c"
+	}
+	line := sanitize(p.name, separators) + ":" + strconv.FormatFloat(p.value, 'f', -1, 64) + "|" + kind
+	if len(p.labels) == 0 {
+		return line
+	}
+	tags := make([]string, 0, len(p.labels))
+	for _, l := range p.labels {
+		tags = append(tags, sanitize(l.key, separators)+":"+sanitize(l.value, separators))
+	}
+	return line + "|#" + strings.Join(tags, ",")
+}
+
+// String returns the URL of the sink
+func (s *statsdSink) String() string {
+	return fmt.Sprintf("statsd://%s", s.address)
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package statspush pushes the OpenTelemetry metrics of the bridge to statsd, Graphite or a
+// Prometheus remote-write endpoint, for the sites which can't scrape the DPUs behind their
+// management NAT
+package statspush
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// point is a value of a metric at the time it was collected
+type point struct {
+	// name of the metric, with the prefix of the exporter, i.e. opi.nvme.controller.read.bytes
+	name string
+	// labels of the point, sorted by key
+	labels []label
+	value  float64
+	// counter is set for the monotonic sums, the other metrics are gauges
+	counter bool
+	time    time.Time
+}
+
+// label is an attribute of a point
+type label struct {
+	key   string
+	value string
+}
+
+// sink sends the points to a metrics backend
+type sink interface {
+	// temporality of the counters the backend expects
+	temporality() metricdata.Temporality
+	// push sends the points collected at once
+	push(ctx context.Context, points []point) error
+	// String describes the sink in the logs
+	String() string
+}
+
+// Exporter is an OpenTelemetry metric exporter pushing the metrics to statsd, Graphite or a
+// Prometheus remote-write endpoint, to be read periodically with sdkmetric.NewPeriodicReader
+type Exporter struct {
+	sink   sink
+	prefix string
+
+	mu       sync.Mutex
+	shutdown bool
+}
+
+// New returns the exporter of a URL, statsd://host:port sends UDP datagrams with DogStatsD tags,
+// graphite://host:port sends the Graphite plaintext protocol with tags over TCP and http and https
+// URLs are Prometheus remote-write endpoints. The names of the metrics are prefixed with prefix
+// when not empty, i.e. site1.opi.nvme.controller.read.bytes
+func New(pushURL string, prefix string, client *http.Client) (*Exporter, error) {
+	u, err := url.Parse(pushURL)
+	if err != nil {
+		return nil, err
+	}
+	var s sink
+	switch u.Scheme {
+	case "statsd":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid statsd endpoint %s, have to be statsd://host:port", pushURL)
+		}
+		s = &statsdSink{address: u.Host}
+	case "graphite":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid Graphite endpoint %s, have to be graphite://host:port", pushURL)
+		}
+		s = &graphiteSink{address: u.Host}
+	case "http", "https":
+		s = newRemoteWriteSink(pushURL, client)
+	default:
+		return nil, fmt.Errorf("invalid stats push endpoint %s, the scheme has to be statsd, graphite, http or https", pushURL)
+	}
+	return &Exporter{sink: s, prefix: prefix}, nil
+}
+
+// String describes the exporter in the logs
+func (e *Exporter) String() string {
+	return e.sink.String()
+}
+
+// Temporality implements sdkmetric.Exporter, statsd counts the increments while the other
+// backends get the totals
+func (e *Exporter) Temporality(sdkmetric.InstrumentKind) metricdata.Temporality {
+	return e.sink.temporality()
+}
+
+// Aggregation implements sdkmetric.Exporter
+func (e *Exporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+// Export implements sdkmetric.Exporter, the sums and the gauges are pushed and the histograms,
+// which the bridge doesn't report, are skipped
+func (e *Exporter) Export(ctx context.Context, metrics *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	shutdown := e.shutdown
+	e.mu.Unlock()
+	if shutdown {
+		return errors.New("the stats push exporter is shut down")
+	}
+	var points []point
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			points = appendPoints(points, e.metricName(m.Name), m.Data)
+		}
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	if err := e.sink.push(ctx, points); err != nil {
+		return fmt.Errorf("could not push %d stats to %s: %w", len(points), e.sink, err)
+	}
+	slog.DebugContext(ctx, "Pushed the stats", "sink", e.sink.String(), "points", len(points))
+	return nil
+}
+
+// ForceFlush implements sdkmetric.Exporter, the points are pushed as soon as exported
+func (e *Exporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+// Shutdown implements sdkmetric.Exporter
+func (e *Exporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return nil
+}
+
+// metricName prefixes the name of a metric
+func (e *Exporter) metricName(name string) string {
+	if e.prefix == "" {
+		return name
+	}
+	return e.prefix + "." + name
+}
+
+// appendPoints appends the points of the sums and the gauges of a metric
+func appendPoints(points []point, name string, data metricdata.Aggregation) []point {
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		return appendDataPoints(points, name, d.DataPoints, d.IsMonotonic)
+	case metricdata.Sum[float64]:
+		return appendDataPoints(points, name, d.DataPoints, d.IsMonotonic)
+	case metricdata.Gauge[int64]:
+		return appendDataPoints(points, name, d.DataPoints, false)
+	case metricdata.Gauge[float64]:
+		return appendDataPoints(points, name, d.DataPoints, false)
+	}
+	return points
+}
+
+func appendDataPoints[N int64 | float64](points []point, name string, dataPoints []metricdata.DataPoint[N], counter bool) []point {
+	for _, dp := range dataPoints {
+		points = append(points, point{
+			name:    name,
+			labels:  labels(dp.Attributes),
+			value:   float64(dp.Value),
+			counter: counter,
+			time:    dp.Time,
+		})
+	}
+	return points
+}
+
+// labels returns the attributes of a point, the sets are sorted by key
+func labels(attributes attribute.Set) []label {
+	var result []label
+	for _, kv := range attributes.ToSlice() {
+		result = append(result, label{key: string(kv.Key), value: kv.Value.Emit()})
+	}
+	return result
+}
+
+// sanitize replaces the characters a line protocol uses as separators
+func sanitize(value string, separators string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || strings.ContainsRune(separators, r) {
+			return '_'
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package statspush pushes the OpenTelemetry metrics of the bridge to statsd, Graphite or a
+// Prometheus remote-write endpoint, for the sites which can't scrape the DPUs behind their
+// management NAT
+package statspush
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var testTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// testMetrics are a counter of a controller and a gauge of a namespace
+var testMetrics = &metricdata.ResourceMetrics{
+	ScopeMetrics: []metricdata.ScopeMetrics{{
+		Metrics: []metricdata.Metrics{
+			{
+				Name: "opi.nvme.controller.read.bytes",
+				Data: metricdata.Sum[int64]{
+					IsMonotonic: true,
+					DataPoints: []metricdata.DataPoint[int64]{{
+						Attributes: attribute.NewSet(attribute.String("controller", "nvmeSubsystems/subsys0/nvmeControllers/ctrl0")),
+						Time:       testTime,
+						Value:      4096,
+					}},
+				},
+			},
+			{
+				Name: "opi.nvme.namespace.queue.depth",
+				Data: metricdata.Gauge[float64]{
+					DataPoints: []metricdata.DataPoint[float64]{{
+						Attributes: attribute.NewSet(attribute.String("namespace", "ns 0"), attribute.String("volume", "Malloc0")),
+						Time:       testTime,
+						Value:      1.5,
+					}},
+				},
+			},
+			{
+				Name: "opi.nvme.controller.latency",
+				Data: metricdata.Histogram[float64]{},
+			},
+		},
+	}},
+}
+
+func TestStatsPush_New(t *testing.T) {
+	tests := map[string]struct {
+		url     string
+		sink    string
+		wantErr bool
+	}{
+		"statsd":                {url: "statsd://127.0.0.1:8125", sink: "statsd://127.0.0.1:8125"},
+		"graphite":              {url: "graphite://graphite:2003", sink: "graphite://graphite:2003"},
+		"remote-write":          {url: "https://prometheus/api/v1/write", sink: "https://prometheus/api/v1/write"},
+		"statsd without host":   {url: "statsd:///metrics", wantErr: true},
+		"graphite without host": {url: "graphite:", wantErr: true},
+		"unknown scheme":        {url: "influx://influx:8086", wantErr: true},
+		"invalid URL":           {url: "://", wantErr: true},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			exporter, err := New(tt.url, "", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatal("error: expected", tt.wantErr, "received", err)
+			}
+			if err == nil && exporter.String() != tt.sink {
+				t.Error("sink: expected", tt.sink, "received", exporter.String())
+			}
+		})
+	}
+}
+
+func TestStatsPush_Statsd(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	exporter, err := New("statsd://"+conn.LocalAddr().String(), "site1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exporter.Temporality(0) != metricdata.DeltaTemporality {
+		t.Error("temporality: expected delta, received", exporter.Temporality(0))
+	}
+	if err := exporter.Export(context.Background(), testMetrics); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, maxStatsdPacket)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "site1.opi.nvme.controller.read.bytes:4096|c|#controller:nvmeSubsystems/subsys0/nvmeControllers/ctrl0\n" +
+		"site1.opi.nvme.namespace.queue.depth:1.5|g|#namespace:ns_0,volume:Malloc0"
+	if string(buf[:n]) != expected {
+		t.Error("datagram: expected", expected, "received", string(buf[:n]))
+	}
+}
+
+func TestStatsPush_StatsdPackets(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	sink := &statsdSink{address: conn.LocalAddr().String()}
+	points := make([]point, 100)
+	for i := range points {
+		points[i] = point{name: "opi.nvme.controller.read.bytes", labels: []label{{key: "controller", value: strings.Repeat("c", 30)}}, value: 1, counter: true}
+	}
+	if err := sink.push(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := 0
+	buf := make([]byte, 2*maxStatsdPacket)
+	for lines < len(points) {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > maxStatsdPacket {
+			t.Error("datagram: expected at most", maxStatsdPacket, "bytes, received", n)
+		}
+		lines += len(strings.Split(string(buf[:n]), "\n"))
+	}
+	if lines != len(points) {
+		t.Error("lines: expected", len(points), "received", lines)
+	}
+}
+
+func TestStatsPush_Graphite(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		var lines []string
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		received <- lines
+	}()
+	exporter, err := New("graphite://"+listener.Addr().String(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exporter.Temporality(0) != metricdata.CumulativeTemporality {
+		t.Error("temporality: expected cumulative, received", exporter.Temporality(0))
+	}
+	if err := exporter.Export(context.Background(), testMetrics); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"opi.nvme.controller.read.bytes;controller=nvmeSubsystems/subsys0/nvmeControllers/ctrl0 4096 1714557600",
+		"opi.nvme.namespace.queue.depth;namespace=ns_0;volume=Malloc0 1.5 1714557600",
+	}
+	select {
+	case lines := <-received:
+		if !reflect.DeepEqual(lines, expected) {
+			t.Error("lines: expected", expected, "received", lines)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lines: expected", expected, "received none")
+	}
+}
+
+func TestStatsPush_RemoteWrite(t *testing.T) {
+	tests := map[string]struct {
+		status  int
+		wantErr bool
+	}{
+		"accepted": {status: http.StatusNoContent},
+		"rejected": {status: http.StatusBadRequest, wantErr: true},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			received := make(chan []string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" ||
+					r.Header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+					t.Error("headers: expected a remote-write request, received", r.Header)
+				}
+				body, _ := io.ReadAll(r.Body)
+				data, err := snappy.Decode(nil, body)
+				if err != nil {
+					t.Error(err)
+				}
+				received <- decodeWriteRequest(t, data)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			exporter, err := New(server.URL, "", server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = exporter.Export(context.Background(), testMetrics)
+			if (err != nil) != tt.wantErr {
+				t.Error("error: expected", tt.wantErr, "received", err)
+			}
+			expected := []string{
+				`{__name__="opi_nvme_controller_read_bytes_total",controller="nvmeSubsystems/subsys0/nvmeControllers/ctrl0"} 4096 1714557600000`,
+				`{__name__="opi_nvme_namespace_queue_depth",namespace="ns 0",volume="Malloc0"} 1.5 1714557600000`,
+			}
+			if series := <-received; !reflect.DeepEqual(series, expected) {
+				t.Error("series: expected", expected, "received", series)
+			}
+		})
+	}
+}
+
+func TestStatsPush_Shutdown(t *testing.T) {
+	exporter, err := New("statsd://127.0.0.1:8125", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = exporter.Shutdown(context.Background())
+	if err := exporter.Export(context.Background(), testMetrics); err == nil {
+		t.Error("error: expected the exporter to be shut down")
+	}
+}
+
+// field is a field of a protobuf message
+type field struct {
+	num   protowire.Number
+	raw   []byte
+	value string
+}
+
+// decodeFields decodes the fields of a protobuf message, the fixed64 being doubles
+func decodeFields(t *testing.T, b []byte) []field {
+	t.Helper()
+	var result []field
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		f := field{num: num}
+		switch typ {
+		case protowire.BytesType:
+			f.raw, n = protowire.ConsumeBytes(b)
+			f.value = string(f.raw)
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(b)
+			f.value = strconv.FormatFloat(math.Float64frombits(v), 'f', -1, 64)
+		default:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			f.value = strconv.FormatUint(v, 10)
+		}
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		result = append(result, f)
+	}
+	return result
+}
+
+// decodeWriteRequest formats the time series of a WriteRequest as their labels followed by the
+// value and the timestamp of their sample
+func decodeWriteRequest(t *testing.T, data []byte) []string {
+	t.Helper()
+	var result []string
+	for _, series := range decodeFields(t, data) {
+		var labels []string
+		var sample string
+		for _, f := range decodeFields(t, series.raw) {
+			message := decodeFields(t, f.raw)
+			if f.num == 1 {
+				labels = append(labels, message[0].value+`="`+message[1].value+`"`)
+			} else {
+				sample = message[0].value + " " + message[1].value
+			}
+		}
+		result = append(result, "{"+strings.Join(labels, ",")+"} "+sample)
+	}
+	return result
+}
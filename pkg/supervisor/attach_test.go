@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package supervisor runs the Marvell SPDK application, restarts it when it crashes or stops
+// answering, and has the configuration of the bridge replayed to each new instance
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+)
+
+// testSubsystemsJSONRPC lists the subsystems it has, after failing the first failures calls
+type testSubsystemsJSONRPC struct {
+	spdk.JSONRPC
+	mu         sync.Mutex
+	failures   int
+	subsystems []string
+	calls      int
+}
+
+func (c *testSubsystemsJSONRPC) Call(_ context.Context, _ string, _, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.calls <= c.failures {
+		return errors.New("connection reset by peer")
+	}
+	list := result.(*models.MrvlNvmGetSubsysListResult)
+	for _, nqn := range c.subsystems {
+		list.SubsysList = append(list.SubsysList, struct {
+			Subnqn string `json:"subnqn"`
+		}{Subnqn: nqn})
+	}
+	return nil
+}
+
+func (c *testSubsystemsJSONRPC) checks() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func TestSupervisor_Attach(t *testing.T) {
+	tests := map[string]struct {
+		failures   int
+		subsystems []string
+		expected   []string
+		restored   bool
+		// checks is the number of checks before the restore
+		checks int
+	}{
+		"restarted": {
+			subsystems: []string{"nqn.2022-09.io.spdk:other"},
+			expected:   []string{"nqn.2022-09.io.spdk:opi0", "nqn.2022-09.io.spdk:opi1"},
+			restored:   true,
+			// a single check finding none of the subsystems may race with their deletion
+			checks: 2,
+		},
+		"reconnected after a restart": {
+			failures: 2,
+			expected: []string{"nqn.2022-09.io.spdk:opi0"},
+			restored: true,
+			checks:   3,
+		},
+		"reconnected": {
+			failures:   2,
+			subsystems: []string{"nqn.2022-09.io.spdk:opi0"},
+			expected:   []string{"nqn.2022-09.io.spdk:opi0", "nqn.2022-09.io.spdk:opi1"},
+			restored:   false,
+		},
+		"no subsystem": {
+			restored: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rpc := &testSubsystemsJSONRPC{failures: tt.failures, subsystems: tt.subsystems}
+			var mu sync.Mutex
+			var steps []string
+			var checks []int
+			record := func(step string) {
+				mu.Lock()
+				defer mu.Unlock()
+				steps = append(steps, step)
+				checks = append(checks, rpc.checks())
+			}
+			restored := make(chan struct{}, 10)
+			supervisor := Attach(rpc, func() []string { return tt.expected }, func(context.Context) error {
+				record("deactivate")
+				return nil
+			}, func(context.Context) error {
+				record("restore")
+				restored <- struct{}{}
+				return nil
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				supervisor.Run(ctx, time.Millisecond)
+				close(done)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			if tt.restored {
+				waitRestored(t, restored)
+				mu.Lock()
+				defer mu.Unlock()
+				if steps[0] != "deactivate" || steps[1] != "restore" {
+					t.Error("steps: expected deactivate then restore, received", steps)
+				}
+				if checks[1] != tt.checks {
+					t.Error("checks: expected", tt.checks, "received", checks[1])
+				}
+				return
+			}
+			for rpc.checks() < tt.failures+10 {
+				time.Sleep(time.Millisecond)
+			}
+			select {
+			case <-restored:
+				t.Error("restore: expected none")
+			default:
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package supervisor runs the Marvell SPDK application, restarts it when it crashes or stops
+// answering, and has the configuration of the bridge replayed to each new instance
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Timings of the supervision
+const (
+	// startTimeout bounds the initialization of the application, until it answers
+	startTimeout = time.Minute
+	// pingTimeout bounds a ping of the application
+	pingTimeout = 5 * time.Second
+	// maxPingFailures is the number of consecutive pings the application can miss before it
+	// is considered hung and restarted
+	maxPingFailures = 3
+	// minRestartDelay and maxRestartDelay bound the delay before a restart, doubled on each
+	// restart of an application which didn't run longer than maxRestartDelay
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+	// stopTimeout bounds the shutdown of the application when the bridge stops, it is killed after
+	stopTimeout = 10 * time.Second
+)
+
+// errRestartRequested stops the application when a restart is requested
+var errRestartRequested = errors.New("restart requested")
+
+// RestoreFunc replays the configuration of the bridge to a new instance of the application
+type RestoreFunc func(ctx context.Context) error
+
+// DeactivateFunc marks the resources the application lost inactive, until they are restored
+type DeactivateFunc func(ctx context.Context) error
+
+// Supervisor keeps the application running
+type Supervisor struct {
+	args    []string
+	rpc     spdk.JSONRPC
+	restore RestoreFunc
+	// subsystems returns the NQNs of the subsystems an attached application is expected to have,
+	// nil when the application is launched by the supervisor
+	subsystems func() []string
+	// deactivate marks the resources an attached application lost inactive
+	deactivate DeactivateFunc
+	// restartDelay is the delay before the first restart, for the tests
+	restartDelay time.Duration
+	// restart requests a restart of the application
+	restart chan struct{}
+}
+
+// New returns a Supervisor running the command line args, pinging the application with rpc and
+// restoring the configuration with restore each time the application answers after a start
+func New(args []string, rpc spdk.JSONRPC, restore RestoreFunc) *Supervisor {
+	return &Supervisor{
+		args:         args,
+		rpc:          rpc,
+		restore:      restore,
+		restartDelay: minRestartDelay,
+		restart:      make(chan struct{}, 1),
+	}
+}
+
+// Attach returns a Supervisor of an application the bridge doesn't launch, i.e. by systemd or by
+// the Marvell SDK. Its restarts are detected from the configuration of the bridge missing in the
+// application, the subsystems listed with rpc compared with subsystems, then the resources are
+// deactivated with deactivate and the configuration restored with restore
+func Attach(rpc spdk.JSONRPC, subsystems func() []string, deactivate DeactivateFunc, restore RestoreFunc) *Supervisor {
+	s := New(nil, rpc, restore)
+	s.subsystems = subsystems
+	s.deactivate = deactivate
+	return s
+}
+
+// Restart has the application stopped and started again right away, the configuration of the
+// bridge is restored on the new instance as after a crash
+func (s *Supervisor) Restart() {
+	select {
+	case s.restart <- struct{}{}:
+	default:
+	}
+}
+
+// Run starts the application and pings it every interval, it is restarted when it exits or
+// misses maxPingFailures pings, until ctx is done and it is stopped. An attached application is
+// only checked, it is waited for when it stops answering
+func (s *Supervisor) Run(ctx context.Context, interval time.Duration) {
+	if s.args == nil {
+		for {
+			err := s.watch(ctx, interval, nil)
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("SPDK application stopped answering, waiting for it", "error", err)
+		}
+	}
+	delay := s.restartDelay
+	for {
+		started := time.Now()
+		err := s.runOnce(ctx, interval)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errRestartRequested) {
+			slog.Info("Restarting SPDK application on request")
+			delay = s.restartDelay
+			continue
+		}
+		// an application crashing on start is restarted less and less often
+		if time.Since(started) > maxRestartDelay {
+			delay = s.restartDelay
+		}
+		slog.Error("SPDK application stopped, restarting it", "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRestartDelay)
+	}
+}
+
+// runOnce runs the application until it exits, stops answering or ctx is done
+func (s *Supervisor) runOnce(ctx context.Context, interval time.Duration) error {
+	cmd := exec.Command(s.args[0], s.args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	slog.Info("Started SPDK application", "pid", cmd.Process.Pid)
+	exited := make(chan struct{})
+	var exitErr error
+	go func() {
+		exitErr = cmd.Wait()
+		close(exited)
+	}()
+	err := s.watch(ctx, interval, exited)
+	// the application is stopped cleanly with the bridge or on request, a hung one is killed,
+	// killing an application which exited does nothing
+	if ctx.Err() != nil || errors.Is(err, errRestartRequested) {
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-exited:
+		case <-time.After(stopTimeout):
+		}
+	}
+	_ = cmd.Process.Kill()
+	<-exited
+	if err == nil {
+		err = fmt.Errorf("exited: %v", exitErr)
+	}
+	return err
+}
+
+// watch restores the configuration once the application answers, then checks it, it returns nil
+// when the application exited. A launched application starts without the configuration, the
+// restarts of an attached one are detected once it answers again after missing a check, or when
+// two checks in a row find none of the subsystems, so a subsystem deleted while checked isn't
+// taken for one
+func (s *Supervisor) watch(ctx context.Context, interval time.Duration, exited <-chan struct{}) error {
+	deadline := time.Now().Add(startTimeout)
+	reconnected := false
+	lost, err := s.check(ctx)
+	for err != nil {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not answering %v after start", startTimeout)
+		}
+		select {
+		case <-exited:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.restart:
+			return errRestartRequested
+		case <-time.After(interval):
+		}
+		reconnected = true
+		lost, err = s.check(ctx)
+	}
+	suspected := false
+	switch {
+	case s.subsystems == nil, lost && reconnected:
+		s.restoreAll(ctx, reconnected)
+	case lost:
+		suspected = true
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-exited:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.restart:
+			return errRestartRequested
+		case <-ticker.C:
+		}
+		lost, err := s.check(ctx)
+		if err != nil {
+			failures++
+			slog.Warn("SPDK application not answering", "error", err, "failures", failures)
+			if failures >= maxPingFailures {
+				return fmt.Errorf("not answering: %w", err)
+			}
+			continue
+		}
+		reconnected := failures > 0
+		failures = 0
+		if !lost {
+			suspected = false
+			continue
+		}
+		if !reconnected && !suspected {
+			suspected = true
+			continue
+		}
+		suspected = false
+		s.restoreAll(ctx, reconnected)
+	}
+}
+
+// restoreAll deactivates the resources of an attached application then restores them, the ones
+// not restored stay inactive
+func (s *Supervisor) restoreAll(ctx context.Context, reconnected bool) {
+	if s.deactivate != nil {
+		slog.Warn("SPDK application restarted and lost the configuration of the bridge, restoring it", "reconnected", reconnected)
+		if err := s.deactivate(ctx); err != nil {
+			slog.Error("Could not mark the resources lost by the SPDK application inactive", "error", err)
+		}
+	}
+	// a partial restore is better than none, the resources not restored are logged
+	if err := s.restore(ctx); err != nil {
+		slog.Error("Could not restore the whole configuration of the bridge on the SPDK application", "error", err)
+	} else {
+		slog.Info("Restored the configuration of the bridge on the SPDK application")
+	}
+}
+
+// check pings the application, it tells whether an attached application lost the subsystems of
+// the bridge, one without any is never considered as restarted
+func (s *Supervisor) check(ctx context.Context) (bool, error) {
+	if s.subsystems == nil {
+		return false, s.ping(ctx)
+	}
+	expected := s.subsystems()
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	var result models.MrvlNvmGetSubsysListResult
+	if err := s.rpc.Call(ctx, "mrvl_nvm_get_subsys_list", nil, &result); err != nil {
+		return false, err
+	}
+	if result.Status != 0 {
+		return false, result.Status.Err(codes.Unavailable, "Could not list subsystems", "mrvl_nvm_get_subsys_list")
+	}
+	if len(expected) == 0 {
+		return false, nil
+	}
+	present := make(map[string]bool)
+	for _, subsys := range result.SubsysList {
+		present[subsys.Subnqn] = true
+	}
+	for _, nqn := range expected {
+		if present[nqn] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ping checks the application answers
+func (s *Supervisor) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	var result spdk.GetVersionResult
+	return s.rpc.Call(ctx, "spdk_get_version", nil, &result)
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package supervisor runs the Marvell SPDK application, restarts it when it crashes or stops
+// answering, and has the configuration of the bridge replayed to each new instance
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opiproject/gospdk/spdk"
+)
+
+// testJSONRPC answers the pings while answering is set
+type testJSONRPC struct {
+	spdk.JSONRPC
+	answering atomic.Bool
+	failures  atomic.Int32
+}
+
+func (c *testJSONRPC) Call(_ context.Context, _ string, _, _ interface{}) error {
+	if c.answering.Load() {
+		return nil
+	}
+	c.failures.Add(1)
+	return errors.New("connection refused")
+}
+
+// run runs a supervisor of args until the test ends, each restore is sent to restored
+func run(t *testing.T, args []string, rpc *testJSONRPC) (*Supervisor, <-chan struct{}) {
+	restored := make(chan struct{}, 10)
+	supervisor := New(args, rpc, func(context.Context) error {
+		restored <- struct{}{}
+		return nil
+	})
+	supervisor.restartDelay = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		supervisor.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return supervisor, restored
+}
+
+// waitRestored waits for a restore of the configuration
+func waitRestored(t *testing.T, restored <-chan struct{}) {
+	select {
+	case <-restored:
+	case <-time.After(10 * time.Second):
+		t.Fatal("restore: expected a restore of the configuration")
+	}
+}
+
+func TestSupervisor_RestartOnExit(t *testing.T) {
+	rpc := &testJSONRPC{}
+	rpc.answering.Store(true)
+	_, restored := run(t, []string{"sh", "-c", "sleep 0.1"}, rpc)
+	// the configuration is restored on each start
+	waitRestored(t, restored)
+	waitRestored(t, restored)
+}
+
+func TestSupervisor_RestartWhenHung(t *testing.T) {
+	rpc := &testJSONRPC{}
+	rpc.answering.Store(true)
+	_, restored := run(t, []string{"sleep", "60"}, rpc)
+	waitRestored(t, restored)
+	rpc.answering.Store(false)
+	// the hung application is killed, the new one answers
+	for rpc.failures.Load() <= maxPingFailures {
+		time.Sleep(time.Millisecond)
+	}
+	rpc.answering.Store(true)
+	waitRestored(t, restored)
+}
+
+func TestSupervisor_Restart(t *testing.T) {
+	rpc := &testJSONRPC{}
+	rpc.answering.Store(true)
+	supervisor, restored := run(t, []string{"sleep", "60"}, rpc)
+	waitRestored(t, restored)
+	// the application answering is restarted all the same
+	supervisor.Restart()
+	waitRestored(t, restored)
+}
+
+func TestSupervisor_NotStarted(t *testing.T) {
+	supervisor := New([]string{"/nonexistent/spdk_tgt"}, &testJSONRPC{}, nil)
+	err := supervisor.runOnce(context.Background(), time.Millisecond)
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
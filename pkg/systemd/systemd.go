@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package systemd notifies systemd of the state of the bridge, for the services of Type=notify
+package systemd
+
+import (
+	"net"
+	"os"
+)
+
+// socketEnv is the environment variable systemd passes the notification socket in
+const socketEnv = "NOTIFY_SOCKET"
+
+// States of the bridge
+const (
+	// Ready tells the bridge serves, its listeners are bound
+	Ready = "READY=1"
+	// Stopping tells the bridge drains its requests before exiting
+	Stopping = "STOPPING=1"
+)
+
+// Notify sends a state to systemd, it does nothing when the bridge isn't started by systemd
+func Notify(state string) error {
+	socket := os.Getenv(socketEnv)
+	if socket == "" {
+		return nil
+	}
+	// an abstract socket starts with @, which net handles
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package systemd notifies systemd of the state of the bridge, for the services of Type=notify
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSystemd_Notify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	t.Setenv(socketEnv, path)
+
+	for _, state := range []string{Ready, Stopping} {
+		if err := Notify(state); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 64)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != state {
+			t.Error("state: expected", state, "received", string(buf[:n]))
+		}
+	}
+}
+
+func TestSystemd_NotifyWithoutSystemd(t *testing.T) {
+	t.Setenv(socketEnv, "")
+	if err := Notify(Ready); err != nil {
+		t.Error("expected no error, received", err)
+	}
+	t.Setenv(socketEnv, filepath.Join(t.TempDir(), "missing.sock"))
+	if err := Notify(Ready); err == nil {
+		t.Error("missing socket: expected an error")
+	}
+}
@@ -0,0 +1,489 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package tenant scopes the resource names of the tenants sharing the bridge, so they can use the same resource IDs
+package tenant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.einride.tech/aip/resourceid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MetadataKey is the gRPC metadata carrying the tenant of a request
+const MetadataKey = "opi-tenant"
+
+// HeaderKey is the HTTP header carrying the tenant of a request
+const HeaderKey = "Opi-Tenant"
+
+// servicePrefix is the service all the resource names belong to
+const servicePrefix = "//storage.opiproject.org/"
+
+// unscopedCollections are shared by all the tenants, their resources are not renamed
+var unscopedCollections = map[string]bool{
+	"operations": true,
+}
+
+// tenantIDPattern has no hyphen, so a scoped ID maps back to a single tenant
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]{1,32}$`)
+
+// Validate checks a tenant ID, an empty one is the administrator seeing all the resources
+func Validate(tenant string) error {
+	if tenant != "" && !tenantIDPattern.MatchString(tenant) {
+		msg := fmt.Sprintf("Tenant value (%s) is not supported, have to be 1 to 32 lowercase letters and digits", tenant)
+		return status.Errorf(codes.InvalidArgument, msg)
+	}
+	return nil
+}
+
+// tenantKey is the context key of the tenant of a request served outside of the gRPC server
+type tenantKey struct{}
+
+// NewContext returns a context carrying the tenant of a request, i.e. of the custom methods
+// served by the HTTP gateway
+func NewContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Filtering is implemented by the servers filtering the resources of the tenant of the context
+// themselves, i.e. the ones listing the resources under other names, the interceptors pass their
+// requests and responses as is
+type Filtering interface {
+	FiltersTenant()
+}
+
+// FromContext returns the tenant of a request, set in the context or in the gRPC metadata, empty
+// when none is set
+func FromContext(ctx context.Context) (string, error) {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		return tenant, Validate(tenant)
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", nil
+	}
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return "", nil
+	}
+	if err := Validate(values[0]); err != nil {
+		return "", err
+	}
+	return values[0], nil
+}
+
+// ScopeID prefixes a resource ID with the tenant
+func ScopeID(tenant string, id string) string {
+	return tenant + "-" + id
+}
+
+// unscopeID strips the tenant from a resource ID, false when the resource belongs to another tenant
+func unscopeID(tenant string, id string) (string, bool) {
+	if !strings.HasPrefix(id, tenant+"-") {
+		return id, false
+	}
+	return strings.TrimPrefix(id, tenant+"-"), true
+}
+
+// splitName splits a name into its service prefix, absent from the names returned by the
+// servers, i.e. nvmeSubsystems/subsys0, and its segments
+func splitName(name string) (string, []string) {
+	prefix := ""
+	if strings.HasPrefix(name, servicePrefix) {
+		prefix = servicePrefix
+	}
+	return prefix, strings.Split(strings.TrimPrefix(name, prefix), "/")
+}
+
+// ScopeName prefixes the ID of the top level resource of a name with the tenant, i.e.
+// nvmeSubsystems/subsys0/nvmeNamespaces/ns0 becomes nvmeSubsystems/acme-subsys0/nvmeNamespaces/ns0,
+// with or without the service prefix, the children are scoped by their parent. Names which
+// are plain IDs, i.e. Marvell volumes, are prefixed as a whole
+func ScopeName(tenant string, name string) string {
+	prefix, segments := splitName(name)
+	if prefix == "" && len(segments) < 2 {
+		return ScopeID(tenant, name)
+	}
+	if len(segments) < 2 || unscopedCollections[segments[0]] {
+		return name
+	}
+	segments[1] = ScopeID(tenant, segments[1])
+	return prefix + strings.Join(segments, "/")
+}
+
+// UnscopeName strips the tenant from a name, false when the resource belongs to another tenant
+func UnscopeName(tenant string, name string) (string, bool) {
+	prefix, segments := splitName(name)
+	if prefix == "" && len(segments) < 2 {
+		return unscopeID(tenant, name)
+	}
+	if len(segments) < 2 || unscopedCollections[segments[0]] {
+		return name, true
+	}
+	id, ok := unscopeID(tenant, segments[1])
+	if !ok {
+		return name, false
+	}
+	segments[1] = id
+	return prefix + strings.Join(segments, "/"), true
+}
+
+// isNameField tells whether a field, in snake or camel case, holds a resource name
+func isNameField(field string) bool {
+	return field == "name" || field == "parent" ||
+		strings.HasSuffix(field, "_name_ref") || strings.HasSuffix(field, "NameRef")
+}
+
+// isNameRefsField tells whether a field, in snake or camel case, holds a list of resource names
+func isNameRefsField(field string) bool {
+	return strings.HasSuffix(field, "_name_refs") || strings.HasSuffix(field, "NameRefs")
+}
+
+// isVolumeField tells whether a field of a Marvell specific method holds a volume name
+func isVolumeField(field string) bool {
+	return field == "volume" || strings.HasSuffix(field, "Volume")
+}
+
+// Scope rewrites the resource names of a request to the ones of the tenant. The resource ID
+// of a top level resource is scoped too, and generated when empty so the resource is owned
+// by the tenant
+func Scope(tenant string, m proto.Message) {
+	scopeMessage(tenant, m.ProtoReflect(), true)
+}
+
+func scopeMessage(tenant string, m protoreflect.Message, top bool) {
+	fields := m.Descriptor().Fields()
+	hasParent := fields.ByName("parent") != nil
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		field := string(fd.Name())
+		switch {
+		case fd.IsMap():
+			continue
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			list := m.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				scopeMessage(tenant, list.Get(j).Message(), false)
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			if m.Has(fd) {
+				scopeMessage(tenant, m.Mutable(fd).Message(), false)
+			}
+		case fd.Kind() != protoreflect.StringKind:
+			continue
+		case fd.IsList():
+			if !isNameRefsField(field) || !m.Has(fd) {
+				continue
+			}
+			list := m.Mutable(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				list.Set(j, protoreflect.ValueOfString(ScopeName(tenant, list.Get(j).String())))
+			}
+		case isNameField(field):
+			if name := m.Get(fd).String(); name != "" {
+				m.Set(fd, protoreflect.ValueOfString(ScopeName(tenant, name)))
+			}
+		case top && !hasParent && strings.HasSuffix(field, "_id"):
+			id := m.Get(fd).String()
+			if id == "" {
+				id = resourceid.NewSystemGenerated()
+			}
+			m.Set(fd, protoreflect.ValueOfString(ScopeID(tenant, id)))
+		}
+	}
+}
+
+// Unscope rewrites the resource names of a response back to the ones seen by the tenant,
+// the resources of other tenants are dropped from the lists so the pages can be shorter
+func Unscope(tenant string, m proto.Message) {
+	unscopeMessage(tenant, m.ProtoReflect(), false)
+}
+
+// unscopeMessage unscopes a message listed under a resource of the tenant or not
+func unscopeMessage(tenant string, m protoreflect.Message, parentOwned bool) {
+	owned := ownsMessage(tenant, m, parentOwned)
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		field := string(fd.Name())
+		switch {
+		case fd.IsMap():
+			continue
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			if !m.Has(fd) {
+				continue
+			}
+			list := m.Mutable(fd).List()
+			var kept []protoreflect.Value
+			for j := 0; j < list.Len(); j++ {
+				item := list.Get(j).Message()
+				if !ownsMessage(tenant, item, owned) {
+					continue
+				}
+				unscopeMessage(tenant, item, owned)
+				kept = append(kept, list.Get(j))
+			}
+			list.Truncate(0)
+			for _, v := range kept {
+				list.Append(v)
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			if m.Has(fd) {
+				unscopeMessage(tenant, m.Mutable(fd).Message(), owned)
+			}
+		case fd.Kind() != protoreflect.StringKind:
+			continue
+		case fd.IsList():
+			if !isNameRefsField(field) || !m.Has(fd) {
+				continue
+			}
+			list := m.Mutable(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				name, _ := UnscopeName(tenant, list.Get(j).String())
+				list.Set(j, protoreflect.ValueOfString(name))
+			}
+		case isNameField(field):
+			if name := m.Get(fd).String(); name != "" {
+				name, _ = UnscopeName(tenant, name)
+				m.Set(fd, protoreflect.ValueOfString(name))
+			}
+		}
+	}
+}
+
+// ownsMessage tells whether a listed message belongs to the tenant, the messages without a
+// name, i.e. listed by the firmware, only when they are listed under a resource of the tenant
+func ownsMessage(tenant string, m protoreflect.Message, parentOwned bool) bool {
+	fd := m.Descriptor().Fields().ByName("name")
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+		return parentOwned
+	}
+	name := m.Get(fd).String()
+	if name == "" {
+		return parentOwned
+	}
+	_, ok := UnscopeName(tenant, name)
+	return ok
+}
+
+// ScopeJSON rewrites the resource names of a JSON encoded request of a Marvell specific
+// method to the ones of the tenant, like Scope. The ID of a resource is only scoped when
+// set, the tenants have to provide it for the resources to be owned by them
+func ScopeJSON(tenant string, request map[string]interface{}) {
+	_, hasParent := request["parent"]
+	for key, value := range request {
+		if id, ok := value.(string); ok && !hasParent && strings.HasSuffix(key, "Id") && id != "" {
+			request[key] = ScopeID(tenant, id)
+		}
+	}
+	scopeJSONObject(tenant, request)
+}
+
+func scopeJSONObject(tenant string, object map[string]interface{}) {
+	for key, value := range object {
+		switch v := value.(type) {
+		case string:
+			if v != "" && (isNameField(key) || isVolumeField(key)) {
+				object[key] = ScopeName(tenant, v)
+			}
+		case map[string]interface{}:
+			scopeJSONObject(tenant, v)
+		case []interface{}:
+			for j, item := range v {
+				switch i := item.(type) {
+				case string:
+					if isNameRefsField(key) {
+						v[j] = ScopeName(tenant, i)
+					}
+				case map[string]interface{}:
+					scopeJSONObject(tenant, i)
+				}
+			}
+		}
+	}
+}
+
+// UnscopeJSON encodes the response of a Marvell specific method to generic JSON with the
+// resource names seen by the tenant, like Unscope
+func UnscopeJSON(tenant string, response interface{}) (interface{}, error) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	// numbers are kept as is, float64 can't hold all the 64 bits integers
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	if object, ok := generic.(map[string]interface{}); ok {
+		unscopeJSONObject(tenant, object, false)
+	}
+	return generic, nil
+}
+
+// unscopeJSONObject unscopes an object listed under a resource of the tenant or not
+func unscopeJSONObject(tenant string, object map[string]interface{}, parentOwned bool) {
+	owned := ownsJSONObject(tenant, object, parentOwned)
+	for key, value := range object {
+		switch v := value.(type) {
+		case string:
+			if v != "" && (isNameField(key) || isVolumeField(key)) {
+				object[key], _ = UnscopeName(tenant, v)
+			}
+		case map[string]interface{}:
+			unscopeJSONObject(tenant, v, owned)
+		case []interface{}:
+			kept := make([]interface{}, 0, len(v))
+			for _, item := range v {
+				switch i := item.(type) {
+				case string:
+					if isNameRefsField(key) {
+						item, _ = UnscopeName(tenant, i)
+					}
+				case map[string]interface{}:
+					if !ownsJSONObject(tenant, i, owned) {
+						continue
+					}
+					unscopeJSONObject(tenant, i, owned)
+				}
+				kept = append(kept, item)
+			}
+			object[key] = kept
+		}
+	}
+}
+
+// ownsJSONObject tells whether a listed object belongs to the tenant, like ownsMessage
+func ownsJSONObject(tenant string, object map[string]interface{}, parentOwned bool) bool {
+	name, ok := object["name"].(string)
+	if !ok {
+		name, ok = object["volume"].(string)
+	}
+	if !ok || name == "" {
+		return parentOwned
+	}
+	_, owned := UnscopeName(tenant, name)
+	return owned
+}
+
+// scopedPattern matches the tenant prefix of the scoped IDs in a text, i.e. an error message
+func scopedPattern(tenant string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|[^A-Za-z0-9_-])` + tenant + `-`)
+}
+
+// UnscopeError rewrites the resource names of an error back to the ones seen by the tenant, in
+// its message, its resource info and the metadata of its error info, so the scoped names of the
+// bridge don't leak
+func UnscopeError(tenant string, err error) error {
+	st, ok := status.FromError(err)
+	if err == nil || !ok || tenant == "" {
+		return err
+	}
+	pattern := scopedPattern(tenant)
+	unscope := func(text string) string {
+		return pattern.ReplaceAllString(text, "$1")
+	}
+	p := st.Proto()
+	p.Message = unscope(p.Message)
+	for _, detail := range p.Details {
+		m, err := detail.UnmarshalNew()
+		if err != nil {
+			continue
+		}
+		switch d := m.(type) {
+		case *errdetails.ResourceInfo:
+			d.ResourceName = unscope(d.ResourceName)
+		case *errdetails.ErrorInfo:
+			for key, value := range d.Metadata {
+				d.Metadata[key] = unscope(value)
+			}
+		default:
+			continue
+		}
+		_ = detail.MarshalFrom(m)
+	}
+	return status.ErrorProto(p)
+}
+
+// UnaryServerInterceptor scopes the requests of the tenant set in the gRPC metadata and
+// unscopes the responses and the errors, the requests without tenant, and the ones of Filtering
+// servers, are served as is
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		tenant, err := FromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := info.Server.(Filtering); tenant == "" || ok {
+			return handler(ctx, req)
+		}
+		if m, ok := req.(proto.Message); ok {
+			Scope(tenant, m)
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, UnscopeError(tenant, err)
+		}
+		if m, ok := resp.(proto.Message); ok {
+			Unscope(tenant, m)
+		}
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor scopes the requests of the streams of the tenant set in the gRPC
+// metadata and unscopes their responses and their errors, as UnaryServerInterceptor
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		tenant, err := FromContext(ss.Context())
+		if err != nil {
+			return err
+		}
+		if _, ok := srv.(Filtering); tenant == "" || ok {
+			return handler(srv, ss)
+		}
+		return UnscopeError(tenant, handler(srv, &scopedStream{ServerStream: ss, tenant: tenant}))
+	}
+}
+
+// scopedStream scopes the messages received from the tenant and unscopes the ones sent to it
+type scopedStream struct {
+	grpc.ServerStream
+	tenant string
+}
+
+// RecvMsg implements grpc.ServerStream
+func (s *scopedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if msg, ok := m.(proto.Message); ok {
+		Scope(s.tenant, msg)
+	}
+	return nil
+}
+
+// SendMsg implements grpc.ServerStream, the sent message is unscoped as a copy, the servers may
+// send the same message to several streams
+func (s *scopedStream) SendMsg(m interface{}) error {
+	if msg, ok := m.(proto.Message); ok {
+		msg = proto.Clone(msg)
+		Unscope(s.tenant, msg)
+		m = msg
+	}
+	return s.ServerStream.SendMsg(m)
+}
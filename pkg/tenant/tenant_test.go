@@ -0,0 +1,427 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package tenant scopes the resource names of the tenants sharing the bridge, so they can use the same resource IDs
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+	"github.com/opiproject/opi-spdk-bridge/pkg/utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+func TestTenant_UnaryServerInterceptorNames(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	tests := map[string]struct {
+		in       proto.Message
+		received proto.Message
+		response proto.Message
+		out      proto.Message
+	}{
+		"create top level resource": {
+			in:       &pb.CreateNvmeSubsystemRequest{NvmeSubsystemId: "subsys0", NvmeSubsystem: &pb.NvmeSubsystem{}},
+			received: &pb.CreateNvmeSubsystemRequest{NvmeSubsystemId: "acme-subsys0", NvmeSubsystem: &pb.NvmeSubsystem{}},
+			response: &pb.NvmeSubsystem{Name: utils.ResourceIDToSubsystemName("acme-subsys0")},
+			out:      &pb.NvmeSubsystem{Name: utils.ResourceIDToSubsystemName("subsys0")},
+		},
+		"create child resource": {
+			in: &pb.CreateNvmeNamespaceRequest{
+				Parent:          utils.ResourceIDToSubsystemName("subsys0"),
+				NvmeNamespaceId: "ns0",
+				NvmeNamespace:   &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc0"}},
+			},
+			received: &pb.CreateNvmeNamespaceRequest{
+				Parent:          utils.ResourceIDToSubsystemName("acme-subsys0"),
+				NvmeNamespaceId: "ns0",
+				NvmeNamespace:   &pb.NvmeNamespace{Spec: &pb.NvmeNamespaceSpec{VolumeNameRef: "acme-Malloc0"}},
+			},
+			response: &pb.NvmeNamespace{
+				Name: utils.ResourceIDToNamespaceName("acme-subsys0", "ns0"),
+				Spec: &pb.NvmeNamespaceSpec{VolumeNameRef: "acme-Malloc0"},
+			},
+			out: &pb.NvmeNamespace{
+				Name: utils.ResourceIDToNamespaceName("subsys0", "ns0"),
+				Spec: &pb.NvmeNamespaceSpec{VolumeNameRef: "Malloc0"},
+			},
+		},
+		"get child resource": {
+			in:       &pb.GetNvmeControllerRequest{Name: utils.ResourceIDToControllerName("subsys0", "ctrl0")},
+			received: &pb.GetNvmeControllerRequest{Name: utils.ResourceIDToControllerName("acme-subsys0", "ctrl0")},
+			response: &pb.NvmeController{Name: utils.ResourceIDToControllerName("acme-subsys0", "ctrl0")},
+			out:      &pb.NvmeController{Name: utils.ResourceIDToControllerName("subsys0", "ctrl0")},
+		},
+		"get resource with service prefix": {
+			in:       &pb.GetNvmeSubsystemRequest{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0"},
+			received: &pb.GetNvmeSubsystemRequest{Name: "//storage.opiproject.org/nvmeSubsystems/acme-subsys0"},
+			response: &pb.NvmeSubsystem{Name: "//storage.opiproject.org/nvmeSubsystems/acme-subsys0"},
+			out:      &pb.NvmeSubsystem{Name: "//storage.opiproject.org/nvmeSubsystems/subsys0"},
+		},
+		"list resources of several tenants": {
+			in:       &pb.ListNvmeSubsystemsRequest{},
+			received: &pb.ListNvmeSubsystemsRequest{},
+			response: &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{
+				{Name: utils.ResourceIDToSubsystemName("acme-subsys0")},
+				{Name: utils.ResourceIDToSubsystemName("other-subsys0")},
+				{Name: utils.ResourceIDToSubsystemName("subsys1")},
+				{Name: utils.ResourceIDToSubsystemName("acme-subsys2")},
+			}},
+			out: &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{
+				{Name: utils.ResourceIDToSubsystemName("subsys0")},
+				{Name: utils.ResourceIDToSubsystemName("subsys2")},
+			}},
+		},
+		"list children of a shared collection": {
+			in:       &pb.ListNvmeSubsystemsRequest{},
+			received: &pb.ListNvmeSubsystemsRequest{},
+			response: &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{
+				{Name: "operations/op0"},
+			}},
+			out: &pb.ListNvmeSubsystemsResponse{NvmeSubsystems: []*pb.NvmeSubsystem{
+				{Name: "operations/op0"},
+			}},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "acme"))
+			handler := func(_ context.Context, req interface{}) (interface{}, error) {
+				if !proto.Equal(req.(proto.Message), tt.received) {
+					t.Error("request: expected", tt.received, "received", req)
+				}
+				return proto.Clone(tt.response), nil
+			}
+			response, err := interceptor(ctx, tt.in, &grpc.UnaryServerInfo{}, handler)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !proto.Equal(response.(proto.Message), tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+		})
+	}
+}
+
+func TestTenant_FromContext(t *testing.T) {
+	tests := map[string]struct {
+		md      metadata.MD
+		out     string
+		errCode codes.Code
+		errMsg  string
+	}{
+		"no metadata": {
+			md:      nil,
+			out:     "",
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"no tenant": {
+			md:      metadata.Pairs("other", "value"),
+			out:     "",
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"valid tenant": {
+			md:      metadata.Pairs(MetadataKey, "acme"),
+			out:     "acme",
+			errCode: codes.OK,
+			errMsg:  "",
+		},
+		"tenant with hyphen": {
+			md:      metadata.Pairs(MetadataKey, "acme-corp"),
+			out:     "",
+			errCode: codes.InvalidArgument,
+			errMsg:  fmt.Sprintf("Tenant value (%s) is not supported, have to be 1 to 32 lowercase letters and digits", "acme-corp"),
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			out, err := FromContext(ctx)
+			if out != tt.out {
+				t.Error("response: expected", tt.out, "received", out)
+			}
+
+			er, _ := status.FromError(err)
+			if er.Code() != tt.errCode {
+				t.Error("error code: expected", tt.errCode, "received", er.Code())
+			}
+			if er.Message() != tt.errMsg {
+				t.Error("error message: expected", tt.errMsg, "received", er.Message())
+			}
+		})
+	}
+}
+
+func TestTenant_UnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	tests := map[string]struct {
+		tenant   string
+		in       *typepb.Type
+		received string
+		out      *typepb.Type
+	}{
+		"without tenant": {
+			tenant:   "",
+			in:       &typepb.Type{Name: "//storage.opiproject.org/types/type0"},
+			received: "//storage.opiproject.org/types/type0",
+			out: &typepb.Type{
+				Name:   "//storage.opiproject.org/types/type0",
+				Fields: []*typepb.Field{{Name: "acme-field0"}, {Name: "other-field1"}},
+			},
+		},
+		"with tenant": {
+			tenant:   "acme",
+			in:       &typepb.Type{Name: "//storage.opiproject.org/types/type0"},
+			received: "//storage.opiproject.org/types/acme-type0",
+			out: &typepb.Type{
+				Name:   "//storage.opiproject.org/types/type0",
+				Fields: []*typepb.Field{{Name: "field0"}},
+			},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, tt.tenant))
+			}
+			handler := func(_ context.Context, req interface{}) (interface{}, error) {
+				in := req.(*typepb.Type)
+				if in.Name != tt.received {
+					t.Error("request: expected", tt.received, "received", in.Name)
+				}
+				return &typepb.Type{
+					Name:   in.Name,
+					Fields: []*typepb.Field{{Name: "acme-field0"}, {Name: "other-field1"}},
+				}, nil
+			}
+			response, err := interceptor(ctx, tt.in, &grpc.UnaryServerInfo{}, handler)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if !proto.Equal(response.(*typepb.Type), tt.out) {
+				t.Error("response: expected", tt.out, "received", response)
+			}
+		})
+	}
+}
+
+func TestTenant_ScopeJSON(t *testing.T) {
+	tests := map[string]struct {
+		in  string
+		out string
+	}{
+		"create request": {
+			in:  `{"cachedVolumeId": "cached0", "cachedVolume": {"volumeNameRef": "Nvme0n1", "cacheVolumeNameRef": "Nvme1n1"}}`,
+			out: `{"cachedVolumeId": "acme-cached0", "cachedVolume": {"volumeNameRef": "acme-Nvme0n1", "cacheVolumeNameRef": "acme-Nvme1n1"}}`,
+		},
+		"request with name and lists": {
+			in:  `{"name": "//storage.opiproject.org/raidVolumes/raid0", "volumeNameRefs": ["Malloc0", "Malloc1"], "stripSizeKb": 64}`,
+			out: `{"name": "//storage.opiproject.org/raidVolumes/acme-raid0", "volumeNameRefs": ["acme-Malloc0", "acme-Malloc1"], "stripSizeKb": 64}`,
+		},
+		"request with volumes": {
+			in:  `{"volume": "Malloc0", "destinationVolume": "Malloc1"}`,
+			out: `{"volume": "acme-Malloc0", "destinationVolume": "acme-Malloc1"}`,
+		},
+		"request with parent": {
+			in:  `{"parent": "//storage.opiproject.org/nvmeSubsystems/subsys0", "nvmeNamespaceId": "ns0"}`,
+			out: `{"parent": "//storage.opiproject.org/nvmeSubsystems/acme-subsys0", "nvmeNamespaceId": "ns0"}`,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var in, out map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.in), &in); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.out), &out); err != nil {
+				t.Fatal(err)
+			}
+			ScopeJSON("acme", in)
+			if !reflect.DeepEqual(in, out) {
+				t.Error("request: expected", out, "received", in)
+			}
+		})
+	}
+}
+
+func TestTenant_UnscopeJSON(t *testing.T) {
+	type queue struct {
+		ID int `json:"id"`
+	}
+	type volume struct {
+		Name           string   `json:"name"`
+		VolumeNameRefs []string `json:"volumeNameRefs"`
+		SizeBytes      uint64   `json:"sizeBytes"`
+		Queues         []*queue `json:"queues"`
+	}
+	type event struct {
+		Message string `json:"message"`
+	}
+	type volumes struct {
+		Volumes []*volume `json:"volumes"`
+		Events  []*event  `json:"events"`
+	}
+	// the nameless objects are kept when listed under a resource of the tenant only
+	in := &volumes{
+		Volumes: []*volume{
+			{Name: "//storage.opiproject.org/raidVolumes/acme-raid0", VolumeNameRefs: []string{"acme-Malloc0"}, SizeBytes: 18446744073709551615, Queues: []*queue{{ID: 1}}},
+			{Name: "//storage.opiproject.org/raidVolumes/other-raid0", VolumeNameRefs: []string{"other-Malloc0"}, Queues: []*queue{{ID: 2}}},
+			{VolumeNameRefs: []string{"other-Malloc1"}},
+		},
+		Events: []*event{{Message: "other-raid0 degraded"}},
+	}
+	out := `{"events":[],"volumes":[{"name":"//storage.opiproject.org/raidVolumes/raid0","queues":[{"id":1}],"sizeBytes":18446744073709551615,"volumeNameRefs":["Malloc0"]}]}`
+
+	response, err := UnscopeJSON("acme", in)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if string(data) != out {
+		t.Error("response: expected", out, "received", string(data))
+	}
+}
+
+func TestTenant_UnscopeNameless(t *testing.T) {
+	in := &typepb.Type{
+		Name:   "//storage.opiproject.org/types/acme-type0",
+		Fields: []*typepb.Field{{Name: "acme-field0", Options: []*typepb.Option{{}}}, {Name: "other-field1"}, {Number: 2}},
+	}
+	// the nameless fields are listed under a type of the tenant
+	out := &typepb.Type{
+		Name:   "//storage.opiproject.org/types/type0",
+		Fields: []*typepb.Field{{Name: "field0", Options: []*typepb.Option{{}}}, {Number: 2}},
+	}
+
+	Unscope("acme", in)
+
+	if !proto.Equal(in, out) {
+		t.Error("response: expected", out, "received", in)
+	}
+
+	// the nameless fields are listed under a type of another tenant
+	in = &typepb.Type{Name: "//storage.opiproject.org/types/other-type0", Fields: []*typepb.Field{{Number: 2}}}
+	Unscope("acme", in)
+	if len(in.Fields) != 0 {
+		t.Error("fields: expected none, received", in.Fields)
+	}
+}
+
+func TestTenant_UnscopeError(t *testing.T) {
+	st, err := status.New(codes.NotFound, "unable to find key //storage.opiproject.org/nvmeSubsystems/acme-subsys0 on acme-Malloc0").WithDetails(
+		&errdetails.ResourceInfo{ResourceType: "opi_nvme_subsystem", ResourceName: "nvmeSubsystems/acme-subsys0"},
+		&errdetails.ErrorInfo{Reason: "NOT_FOUND", Metadata: map[string]string{"parent": "nvmeSubsystems/acme-subsys0", "tenant": "acmecorp-1"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unscoped := status.Convert(UnscopeError("acme", st.Err()))
+
+	if unscoped.Code() != codes.NotFound {
+		t.Error("error code: expected", codes.NotFound, "received", unscoped.Code())
+	}
+	expected := "unable to find key //storage.opiproject.org/nvmeSubsystems/subsys0 on Malloc0"
+	if unscoped.Message() != expected {
+		t.Error("error message: expected", expected, "received", unscoped.Message())
+	}
+	details := unscoped.Details()
+	if len(details) != 2 {
+		t.Fatal("details: expected 2, received", details)
+	}
+	if name := details[0].(*errdetails.ResourceInfo).ResourceName; name != "nvmeSubsystems/subsys0" {
+		t.Error("resource name: expected nvmeSubsystems/subsys0, received", name)
+	}
+	metadata := map[string]string{"parent": "nvmeSubsystems/subsys0", "tenant": "acmecorp-1"}
+	if m := details[1].(*errdetails.ErrorInfo).Metadata; !reflect.DeepEqual(m, metadata) {
+		t.Error("metadata: expected", metadata, "received", m)
+	}
+	if UnscopeError("acme", nil) != nil {
+		t.Error("error: expected nil")
+	}
+}
+
+// testServerStream receives a request and records the responses
+type testServerStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	request   proto.Message
+	responses []proto.Message
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testServerStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), s.request)
+	return nil
+}
+
+func (s *testServerStream) SendMsg(m interface{}) error {
+	s.responses = append(s.responses, m.(proto.Message))
+	return nil
+}
+
+func TestTenant_StreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor()
+	// the servers may send the same message to several streams
+	shared := &typepb.Type{Name: "//storage.opiproject.org/types/acme-type0"}
+	stream := &testServerStream{
+		ctx:     metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "acme")),
+		request: &typepb.Type{Name: "//storage.opiproject.org/types/type0"},
+	}
+	handler := func(_ interface{}, ss grpc.ServerStream) error {
+		in := new(typepb.Type)
+		if err := ss.RecvMsg(in); err != nil {
+			return err
+		}
+		if in.Name != "//storage.opiproject.org/types/acme-type0" {
+			t.Error("request: expected //storage.opiproject.org/types/acme-type0, received", in.Name)
+		}
+		if err := ss.SendMsg(shared); err != nil {
+			return err
+		}
+		return status.Errorf(codes.NotFound, "unable to find key %s", in.Name)
+	}
+
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{}, handler)
+
+	expected := "unable to find key //storage.opiproject.org/types/type0"
+	if er, _ := status.FromError(err); er.Message() != expected {
+		t.Error("error message: expected", expected, "received", er.Message())
+	}
+	if len(stream.responses) != 1 || stream.responses[0].(*typepb.Type).Name != "//storage.opiproject.org/types/type0" {
+		t.Error("responses: expected //storage.opiproject.org/types/type0, received", stream.responses)
+	}
+	if shared.Name != "//storage.opiproject.org/types/acme-type0" {
+		t.Error("shared response: expected unchanged, received", shared.Name)
+	}
+}
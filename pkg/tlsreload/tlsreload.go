@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package tlsreload serves the TLS certificates of the bridge and the CA verifying its clients,
+// reloading them when their files change, or as the SPIFFE Workload API rotates them, so they
+// can be rotated without a restart
+package tlsreload
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Reloader holds the certificate of the bridge and the CA of its clients, as last loaded from
+// the files or received from the Workload API
+type Reloader struct {
+	certFile    string
+	keyFile     string
+	caFile      string
+	trustDomain string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTimes    []time.Time
+}
+
+// New loads the certificate and the key of the bridge and the CA certificates its clients are
+// verified with, all in PEM format
+func New(certFile string, keyFile string, caFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetTrustDomain only accepts the clients presenting an X.509 SVID of a SPIFFE trust domain, i.e.
+// example.org, the CA file being its trust bundle. It is set before serving
+func (r *Reloader) SetTrustDomain(trustDomain string) {
+	r.trustDomain = trustDomain
+}
+
+// Reload loads the files again, the previous certificates are kept when they can't be loaded,
+// i.e. when the certificate was rotated and not its key yet
+func (r *Reloader) Reload() error {
+	if r.certFile == "" {
+		// the SVID of the Workload API is updated as the agent rotates it
+		return nil
+	}
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	ca, err := os.ReadFile(filepath.Clean(r.caFile))
+	if err != nil {
+		return err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("no CA certificate found in %s", r.caFile)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
+
+// Watch reloads the files every interval when one of them changed, until ctx is done
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !r.changed() {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			slog.Error("Could not reload TLS certificates, keeping the previous ones", "cert", r.certFile, "error", err)
+			continue
+		}
+		slog.Info("Reloaded TLS certificates", "cert", r.certFile, "ca", r.caFile)
+	}
+}
+
+// changed tells whether a file was modified since the last successful load
+func (r *Reloader) changed() bool {
+	modTimes, err := r.stat()
+	if err != nil {
+		// the file may be in the middle of being replaced, it is checked again on the next tick
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, modTime := range modTimes {
+		if !modTime.Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) stat() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+// current returns the certificate and the CA last loaded
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.certificate, r.clientCAs
+}
+
+// ServerConfig returns the TLS configuration of a server requiring the clients to present a
+// certificate signed by the CA, each connection gets the certificates last loaded
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, clientCAs := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*certificate},
+				ClientCAs:    clientCAs,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				// gRPC requires HTTP/2 to be negotiated
+				NextProtos:       []string{"h2"},
+				VerifyConnection: r.verifyTrustDomain,
+			}, nil
+		},
+	}
+}
+
+// verifyTrustDomain checks the client presented an X.509 SVID of the trust domain, if any
+func (r *Reloader) verifyTrustDomain(state tls.ConnectionState) error {
+	if r.trustDomain == "" {
+		return nil
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("missing client certificate")
+	}
+	id := authz.SpiffeID(state.PeerCertificates[0])
+	if !strings.HasPrefix(id, "spiffe://"+r.trustDomain+"/") {
+		return fmt.Errorf("client SPIFFE ID %q is not in trust domain %s", id, r.trustDomain)
+	}
+	return nil
+}
+
+// LoopbackInfo is the authentication information of the connections of the bridge to itself, i.e.
+// from the HTTP gateway, it isn't a credentials.TLSInfo so the identity of their requests is taken
+// from the metadata the gateway forwards rather than from the certificate of the bridge
+type LoopbackInfo struct {
+	credentials.TLSInfo
+}
+
+// Loopback tells the peer presented the certificate of the bridge, the identity of its requests
+// is trusted from their metadata
+func (LoopbackInfo) Loopback() bool {
+	return true
+}
+
+// ServerCredentials returns the gRPC credentials of a server with the ServerConfig, telling the
+// connections of the bridge to itself apart with a LoopbackInfo
+func (r *Reloader) ServerCredentials() credentials.TransportCredentials {
+	return &serverCredentials{TransportCredentials: credentials.NewTLS(r.ServerConfig()), reloader: r}
+}
+
+// serverCredentials are TLS credentials recognizing the certificate of the bridge
+type serverCredentials struct {
+	credentials.TransportCredentials
+	reloader *Reloader
+}
+
+// ServerHandshake implements credentials.TransportCredentials
+func (c *serverCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := c.TransportCredentials.ServerHandshake(rawConn)
+	if err != nil {
+		return conn, authInfo, err
+	}
+	if info, ok := authInfo.(credentials.TLSInfo); ok && c.reloader.isBridge(info.State) {
+		return conn, LoopbackInfo{TLSInfo: info}, nil
+	}
+	return conn, authInfo, nil
+}
+
+// Clone implements credentials.TransportCredentials
+func (c *serverCredentials) Clone() credentials.TransportCredentials {
+	return &serverCredentials{TransportCredentials: c.TransportCredentials.Clone(), reloader: c.reloader}
+}
+
+// isBridge tells whether the peer of a connection presented the certificate of the bridge
+func (r *Reloader) isBridge(state tls.ConnectionState) bool {
+	certificate, _ := r.current()
+	return len(state.PeerCertificates) != 0 && bytes.Equal(state.PeerCertificates[0].Raw, certificate.Certificate[0])
+}
+
+// LoopbackConfig returns the TLS configuration of a client of the bridge itself, i.e. the
+// HTTP gateway, presenting the certificate of the bridge and only accepting it from the server
+func (r *Reloader) LoopbackConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the server is checked against the certificate of the bridge in VerifyConnection instead,
+		// the certificate may not be issued for localhost
+		InsecureSkipVerify: true, // #nosec G402
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			certificate, _ := r.current()
+			return certificate, nil
+		},
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if !r.isBridge(state) {
+				return errors.New("server certificate is not the certificate of the bridge")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,418 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package tlsreload serves the TLS certificates of the bridge and the CA verifying its clients,
+// reloading them when their files change, or as the SPIFFE Workload API rotates them, so they
+// can be rotated without a restart
+package tlsreload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// testIssuer is a CA issuing the certificates of the tests
+type testIssuer struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testIssuer{certificate: certificate, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate for both server and client authentication and its key, in PEM
+// format, an X.509 SVID when name is a SPIFFE ID
+func (i *testIssuer) issue(t *testing.T, name string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if id, err := url.Parse(name); err == nil && id.Scheme == "spiffe" {
+		template.Subject, template.DNSNames, template.URIs = pkix.Name{}, nil, []*url.URL{id}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, i.certificate, &key.PublicKey, i.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// writeFile writes a file with its modification time moved by age, so a change is seen even
+// within the resolution of the file system clock
+func writeFile(t *testing.T, path string, data []byte, age time.Duration) {
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake connects a client to a server over the loopback, it returns the name of the
+// certificate of the server
+func handshake(t *testing.T, server *tls.Config, client *tls.Config) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		errs <- tls.Server(conn, server).Handshake()
+	}()
+	conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+	if err != nil {
+		<-errs
+		return "", err
+	}
+	defer func() { _ = conn.Close() }()
+	// TLS 1.3 clients are done before the server verified their certificate
+	if err := <-errs; err != nil {
+		return "", err
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestTLSReload_ServerConfig(t *testing.T) {
+	issuer := newTestIssuer(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.pem")
+	cert, key := issuer.issue(t, "server-1")
+	writeFile(t, certFile, cert, -time.Minute)
+	writeFile(t, keyFile, key, -time.Minute)
+	writeFile(t, caFile, issuer.pem, -time.Minute)
+
+	reloader, err := New(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(issuer.certificate)
+	clientCert, clientKey := issuer.issue(t, "client")
+	clientCertificate, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strangerCert, strangerKey := newTestIssuer(t).issue(t, "stranger")
+	strangerCertificate, err := tls.X509KeyPair(strangerCert, strangerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		certificates []tls.Certificate
+		server       string
+		fails        bool
+	}{
+		"client certificate signed by the CA": {
+			certificates: []tls.Certificate{clientCertificate},
+			server:       "server-1",
+			fails:        false,
+		},
+		"no client certificate": {
+			certificates: nil,
+			fails:        true,
+		},
+		"client certificate signed by another CA": {
+			certificates: []tls.Certificate{strangerCertificate},
+			fails:        true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots, ServerName: "server-1", Certificates: tt.certificates}
+			server, err := handshake(t, reloader.ServerConfig(), client)
+			if (err != nil) != tt.fails {
+				t.Error("error: expected", tt.fails, "received", err)
+			}
+			if server != tt.server {
+				t.Error("server: expected", tt.server, "received", server)
+			}
+		})
+	}
+
+	// a certificate without its key yet is not loaded
+	cert, key = issuer.issue(t, "server-2")
+	writeFile(t, certFile, cert, time.Minute)
+	if err := reloader.Reload(); err == nil {
+		t.Error("reload: expected an error, received", err)
+	}
+	client := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots, ServerName: "server-2", Certificates: []tls.Certificate{clientCertificate}}
+	if server, _ := handshake(t, reloader.ServerConfig(), client); server != "" {
+		t.Error("server: expected none, received", server)
+	}
+
+	// the rotated certificate is picked up by the new connections
+	writeFile(t, keyFile, key, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, time.Millisecond)
+	server := ""
+	for i := 0; i < 1000 && server != "server-2"; i++ {
+		time.Sleep(time.Millisecond)
+		server, _ = handshake(t, reloader.ServerConfig(), client)
+	}
+	if server != "server-2" {
+		t.Error("server: expected server-2, received", server)
+	}
+}
+
+func TestTLSReload_LoopbackConfig(t *testing.T) {
+	issuer := newTestIssuer(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.pem")
+	cert, key := issuer.issue(t, "server")
+	writeFile(t, certFile, cert, -time.Minute)
+	writeFile(t, keyFile, key, -time.Minute)
+	writeFile(t, caFile, issuer.pem, -time.Minute)
+	reloader, err := New(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the gateway accepts the bridge itself
+	if server, err := handshake(t, reloader.ServerConfig(), reloader.LoopbackConfig()); err != nil || server != "server" {
+		t.Error("loopback: expected server, received", server, err)
+	}
+
+	// and no other server, even signed by the same CA
+	otherCert, otherKey := issuer.issue(t, "other")
+	otherCertificate, err := tls.X509KeyPair(otherCert, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{otherCertificate}}
+	if _, err := handshake(t, other, reloader.LoopbackConfig()); err == nil {
+		t.Error("other server: expected an error, received", err)
+	}
+}
+
+func TestTLSReload_New(t *testing.T) {
+	issuer := newTestIssuer(t)
+	dir := t.TempDir()
+	cert, key := issuer.issue(t, "server")
+	writeFile(t, filepath.Join(dir, "server.pem"), cert, 0)
+	writeFile(t, filepath.Join(dir, "server.key"), key, 0)
+	writeFile(t, filepath.Join(dir, "ca.pem"), issuer.pem, 0)
+	writeFile(t, filepath.Join(dir, "empty.pem"), []byte{}, 0)
+
+	tests := map[string]struct {
+		cert  string
+		key   string
+		ca    string
+		fails bool
+	}{
+		"valid files":      {cert: "server.pem", key: "server.key", ca: "ca.pem", fails: false},
+		"missing ca":       {cert: "server.pem", key: "server.key", ca: "missing.pem", fails: true},
+		"empty ca":         {cert: "server.pem", key: "server.key", ca: "empty.pem", fails: true},
+		"key mismatch":     {cert: "server.pem", key: "ca.pem", ca: "ca.pem", fails: true},
+		"missing server":   {cert: "missing.pem", key: "server.key", ca: "ca.pem", fails: true},
+		"ca as server key": {cert: "ca.pem", key: "server.key", ca: "ca.pem", fails: true},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(filepath.Join(dir, tt.cert), filepath.Join(dir, tt.key), filepath.Join(dir, tt.ca))
+			if (err != nil) != tt.fails {
+				t.Error("error: expected", tt.fails, "received", err)
+			}
+		})
+	}
+}
+
+func TestTLSReload_ServerCredentials(t *testing.T) {
+	issuer := newTestIssuer(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.pem")
+	cert, key := issuer.issue(t, "server")
+	writeFile(t, certFile, cert, -time.Minute)
+	writeFile(t, keyFile, key, -time.Minute)
+	writeFile(t, caFile, issuer.pem, -time.Minute)
+	reloader, err := New(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(issuer.certificate)
+	clientCert, clientKey := issuer.issue(t, "client")
+	clientCertificate, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		client   *tls.Config
+		loopback bool
+	}{
+		"gateway": {
+			client:   reloader.LoopbackConfig(),
+			loopback: true,
+		},
+		"client": {
+			client:   &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots, ServerName: "server", Certificates: []tls.Certificate{clientCertificate}},
+			loopback: false,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = ln.Close() }()
+			infos := make(chan credentials.AuthInfo, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					infos <- nil
+					return
+				}
+				defer func() { _ = conn.Close() }()
+				_, info, _ := reloader.ServerCredentials().ServerHandshake(conn)
+				infos <- info
+			}()
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = conn.Close() }()
+			client := credentials.NewTLS(tt.client)
+			if _, _, err := client.ClientHandshake(context.Background(), "server", conn); err != nil {
+				t.Fatal(err)
+			}
+			info := <-infos
+			if _, ok := info.(LoopbackInfo); ok != tt.loopback {
+				t.Error("loopback: expected", tt.loopback, "received", info)
+			}
+			if _, ok := info.(credentials.TLSInfo); ok == tt.loopback {
+				t.Error("TLS info: expected", !tt.loopback, "received", info)
+			}
+		})
+	}
+}
+
+func TestTLSReload_SetTrustDomain(t *testing.T) {
+	issuer := newTestIssuer(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "svid.pem"), filepath.Join(dir, "svid_key.pem"), filepath.Join(dir, "bundle.pem")
+	cert, key := issuer.issue(t, "spiffe://example.org/opi-marvell-bridge")
+	writeFile(t, certFile, cert, -time.Minute)
+	writeFile(t, keyFile, key, -time.Minute)
+	writeFile(t, caFile, issuer.pem, -time.Minute)
+	reloader, err := New(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader.SetTrustDomain("example.org")
+
+	tests := map[string]struct {
+		client string
+		fails  bool
+	}{
+		"SVID of the trust domain": {
+			client: "spiffe://example.org/ns/ops/sa/provisioner",
+			fails:  false,
+		},
+		"SVID of another trust domain": {
+			client: "spiffe://example.org.evil/ns/ops/sa/provisioner",
+			fails:  true,
+		},
+		"certificate without SPIFFE ID": {
+			client: "provisioner",
+			fails:  true,
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientCert, clientKey := issuer.issue(t, tt.client)
+			clientCertificate, err := tls.X509KeyPair(clientCert, clientKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the SVIDs have no DNS name, the server is checked by the SPIFFE ID instead
+			client := &tls.Config{
+				MinVersion:         tls.VersionTLS12,
+				InsecureSkipVerify: true,
+				Certificates:       []tls.Certificate{clientCertificate},
+			}
+			_, err = handshake(t, reloader.ServerConfig(), client)
+			if (err != nil) != tt.fails {
+				t.Error("error: expected", tt.fails, "received", err)
+			}
+		})
+	}
+
+	// the gateway presents the SVID of the bridge
+	if _, err := handshake(t, reloader.ServerConfig(), reloader.LoopbackConfig()); err != nil {
+		t.Error("loopback: expected no error, received", err)
+	}
+}
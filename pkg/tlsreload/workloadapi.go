@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package tlsreload serves the TLS certificates of the bridge and the CA verifying its clients,
+// reloading them when their files change, or as the SPIFFE Workload API rotates them, so they
+// can be rotated without a restart
+package tlsreload
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/authz"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fetchX509SVIDMethod is the method of the SPIFFE Workload API streaming the X.509 SVIDs of the
+// workload and the trust bundle, a response each time the agent rotates them
+const fetchX509SVIDMethod = "/SpiffeWorkloadAPI/FetchX509SVID"
+
+// Numbers of the fields of the X509SVIDResponse and X509SVID messages of the Workload API
+const (
+	responseSvidsField protowire.Number = 1
+	svidCertsField     protowire.Number = 2
+	svidKeyField       protowire.Number = 3
+	svidBundleField    protowire.Number = 4
+)
+
+// workloadAPIMetadata is the header the agent requires from the workloads calling it
+const workloadAPIMetadata = "workload.spiffe.io"
+
+// workloadAPIRetry is the delay before the stream of the Workload API is opened again once it
+// failed, i.e. when the agent restarts
+const workloadAPIRetry = 5 * time.Second
+
+// NewWorkloadAPI gets the X.509 SVID of the bridge and the trust bundle its clients are verified
+// with from the SPIFFE Workload API of the agent at socket, i.e.
+// unix:///run/spire/sockets/agent.sock, and updates them each time the agent rotates them until
+// ctx is done
+func NewWorkloadAPI(ctx context.Context, socket string) (*Reloader, error) {
+	conn, err := grpc.Dial(socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	r := &Reloader{}
+	stream, err := fetchX509SVID(ctx, conn)
+	if err == nil {
+		_, err = r.receiveSVID(stream)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go r.watchWorkloadAPI(ctx, conn, stream)
+	return r, nil
+}
+
+// fetchX509SVID opens the stream of the X.509 SVIDs of the Workload API
+func fetchX509SVID(ctx context.Context, conn *grpc.ClientConn) (grpc.ClientStream, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, workloadAPIMetadata, "true")
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fetchX509SVIDMethod, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return nil, err
+	}
+	// the X509SVIDRequest has no field
+	request := []byte{}
+	if err := stream.SendMsg(&request); err != nil {
+		return nil, err
+	}
+	return stream, stream.CloseSend()
+}
+
+// watchWorkloadAPI updates the SVID from the responses of the stream, opening it again when it
+// fails, until ctx is done
+func (r *Reloader) watchWorkloadAPI(ctx context.Context, conn *grpc.ClientConn, stream grpc.ClientStream) {
+	defer func() { _ = conn.Close() }()
+	for ctx.Err() == nil {
+		if stream == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(workloadAPIRetry):
+			}
+			var err error
+			if stream, err = fetchX509SVID(ctx, conn); err != nil {
+				slog.Error("Could not open the stream of the X.509 SVIDs of the Workload API", "error", err)
+				stream = nil
+				continue
+			}
+		}
+		id, err := r.receiveSVID(stream)
+		var invalid *invalidSVIDError
+		switch {
+		case errors.As(err, &invalid):
+			slog.Error("Could not load the X.509 SVID of the Workload API, keeping the previous one", "error", err)
+		case err != nil:
+			if ctx.Err() == nil {
+				slog.Error("Could not receive the X.509 SVID from the Workload API, opening its stream again", "error", err)
+			}
+			stream = nil
+		default:
+			slog.Info("Rotated the X.509 SVID of the Workload API", "id", id)
+		}
+	}
+}
+
+// invalidSVIDError is an SVID received from the Workload API which can't be loaded, the stream
+// itself is fine
+type invalidSVIDError struct {
+	err error
+}
+
+func (e *invalidSVIDError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidSVIDError) Unwrap() error {
+	return e.err
+}
+
+// receiveSVID waits for the next response of the stream and loads its first SVID, the default
+// one of the workload, it returns its SPIFFE ID
+func (r *Reloader) receiveSVID(stream grpc.ClientStream) (string, error) {
+	var response []byte
+	if err := stream.RecvMsg(&response); err != nil {
+		return "", err
+	}
+	certificate, clientCAs, err := parseSVID(response)
+	if err != nil {
+		return "", &invalidSVIDError{err: err}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificate = certificate
+	r.clientCAs = clientCAs
+	return authz.SpiffeID(certificate.Leaf), nil
+}
+
+// parseSVID returns the certificate of the first SVID of an X509SVIDResponse and the CA of its
+// trust bundle
+func parseSVID(response []byte) (*tls.Certificate, *x509.CertPool, error) {
+	fields, err := bytesFields(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(fields[responseSvidsField]) == 0 {
+		return nil, nil, errors.New("no X.509 SVID in the response of the Workload API")
+	}
+	svid, err := bytesFields(fields[responseSvidsField][0])
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, err := x509.ParseCertificates(first(svid[svidCertsField]))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chain) == 0 {
+		return nil, nil, errors.New("no certificate in the X.509 SVID")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(first(svid[svidKeyField]))
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("the key of the X.509 SVID can't sign")
+	}
+	public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(chain[0].PublicKey) {
+		return nil, nil, errors.New("the X.509 SVID does not match its key")
+	}
+	bundle, err := x509.ParseCertificates(first(svid[svidBundleField]))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(bundle) == 0 {
+		return nil, nil, errors.New("no CA certificate in the trust bundle of the X.509 SVID")
+	}
+	certificate := &tls.Certificate{PrivateKey: key, Leaf: chain[0]}
+	for _, c := range chain {
+		certificate.Certificate = append(certificate.Certificate, c.Raw)
+	}
+	clientCAs := x509.NewCertPool()
+	for _, ca := range bundle {
+		clientCAs.AddCert(ca)
+	}
+	return certificate, clientCAs, nil
+}
+
+// bytesFields returns the values of the length-delimited fields of a protobuf message by
+// number, the other fields are skipped
+func bytesFields(message []byte) (map[protowire.Number][][]byte, error) {
+	fields := make(map[protowire.Number][][]byte)
+	for len(message) > 0 {
+		number, wireType, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		message = message[n:]
+		if wireType != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(number, wireType, message)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			message = message[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(message)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		fields[number] = append(fields[number], value)
+		message = message[n:]
+	}
+	return fields, nil
+}
+
+// first returns the first value of a field, nil when it is missing
+func first(values [][]byte) []byte {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// rawCodec passes the messages of the Workload API as they are encoded, they are decoded with
+// protowire rather than generated code
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
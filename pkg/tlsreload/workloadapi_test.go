@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package tlsreload serves the TLS certificates of the bridge and the CA verifying its clients,
+// reloading them when their files change, or as the SPIFFE Workload API rotates them, so they
+// can be rotated without a restart
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// testAgent serves the responses sent to it on the stream of the X.509 SVIDs of the Workload API
+type testAgent struct {
+	responses chan []byte
+}
+
+func (a *testAgent) fetchX509SVID(_ interface{}, stream grpc.ServerStream) error {
+	if md, _ := metadata.FromIncomingContext(stream.Context()); len(md.Get(workloadAPIMetadata)) == 0 {
+		return status.Error(codes.InvalidArgument, "security header missing from request")
+	}
+	var request []byte
+	if err := stream.RecvMsg(&request); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case response := <-a.responses:
+			if err := stream.SendMsg(&response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// startTestAgent serves a testAgent on a unix socket, it returns its address
+func startTestAgent(t *testing.T, agent *testAgent) string {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "SpiffeWorkloadAPI",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "FetchX509SVID",
+			Handler:       agent.fetchX509SVID,
+			ServerStreams: true,
+		}},
+	}, agent)
+	go func() {
+		_ = server.Serve(ln)
+	}()
+	t.Cleanup(server.Stop)
+	return "unix://" + socket
+}
+
+// svidResponse returns an X509SVIDResponse of an SVID issued for name and of the trust bundle of
+// the issuer
+func svidResponse(t *testing.T, issuer *testIssuer, name string) []byte {
+	cert, key := issuer.issue(t, name)
+	certBlock, _ := pem.Decode(cert)
+	keyBlock, _ := pem.Decode(key)
+	ecKey, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var svid []byte
+	svid = protowire.AppendTag(svid, 1, protowire.BytesType)
+	svid = protowire.AppendString(svid, name)
+	svid = protowire.AppendTag(svid, svidCertsField, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, certBlock.Bytes)
+	svid = protowire.AppendTag(svid, svidKeyField, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, pkcs8)
+	svid = protowire.AppendTag(svid, svidBundleField, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, issuer.certificate.Raw)
+	var response []byte
+	response = protowire.AppendTag(response, responseSvidsField, protowire.BytesType)
+	return protowire.AppendBytes(response, svid)
+}
+
+func TestTLSReload_NewWorkloadAPI(t *testing.T) {
+	issuer := newTestIssuer(t)
+	clientCert, clientKey := issuer.issue(t, "client")
+	clientCertificate, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		Certificates:       []tls.Certificate{clientCertificate},
+	}
+	agent := &testAgent{responses: make(chan []byte, 3)}
+	socket := startTestAgent(t, agent)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	agent.responses <- svidResponse(t, issuer, "bridge-1")
+	reloader, err := NewWorkloadAPI(ctx, socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := handshake(t, reloader.ServerConfig(), client); err != nil || name != "bridge-1" {
+		t.Error("certificate: expected bridge-1, received", name, err)
+	}
+
+	// an SVID which can't be loaded is skipped, the next one is used once rotated
+	agent.responses <- []byte{0xff}
+	agent.responses <- svidResponse(t, issuer, "bridge-2")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		name, err := handshake(t, reloader.ServerConfig(), client)
+		if err == nil && name == "bridge-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("certificate: expected bridge-2, received", name, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the files are only reloaded without the Workload API
+	if err := reloader.Reload(); err != nil {
+		t.Error("reload: expected no error, received", err)
+	}
+
+	if _, err := NewWorkloadAPI(ctx, "unix://"+filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Error("missing agent: expected an error")
+	}
+}
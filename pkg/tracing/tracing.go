@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package tracing traces the Marvell RPCs issued by the bridge, as children of the spans of the
+// gRPC requests, so a slow request can be traced to the firmware call that stalled
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opiproject/gospdk/spdk"
+	"github.com/opiproject/opi-marvell-bridge/pkg/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the Marvell RPCs
+const instrumentationName = "github.com/opiproject/opi-marvell-bridge/pkg/tracing"
+
+// WrapJSONRPC instruments a JSON-RPC client, every call to the firmware becomes a span
+// carrying its method and status
+func WrapJSONRPC(rpc spdk.JSONRPC, provider trace.TracerProvider) spdk.JSONRPC {
+	return &tracedJSONRPC{JSONRPC: rpc, tracer: provider.Tracer(instrumentationName)}
+}
+
+// tracedJSONRPC is a JSON-RPC client tracing the calls to the firmware
+type tracedJSONRPC struct {
+	spdk.JSONRPC
+	tracer trace.Tracer
+}
+
+// Call implements spdk.JSONRPC
+func (c *tracedJSONRPC) Call(ctx context.Context, method string, args, result interface{}) error {
+	ctx, span := c.tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", method),
+		),
+	)
+	defer span.End()
+	err := c.JSONRPC.Call(ctx, method, args, result)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	// the Marvell methods report their failures in the status of their result
+	resultStatus := models.ResultStatus(result)
+	span.SetAttributes(
+		attribute.Int64("marvell.status", int64(resultStatus)),
+		attribute.String("marvell.status_name", resultStatus.Name(method)),
+	)
+	if resultStatus != 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%s returned status %s (%d)", method, resultStatus.Name(method), resultStatus))
+	}
+	return nil
+}
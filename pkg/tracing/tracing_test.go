@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package tracing traces the Marvell RPCs issued by the bridge, as children of the spans of the
+// gRPC requests, so a slow request can be traced to the firmware call that stalled
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opiproject/opi-marvell-bridge/pkg/spdk/spdktest"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing_WrapJSONRPC(t *testing.T) {
+	tests := map[string]struct {
+		status     int
+		err        error
+		spanStatus codes.Code
+		attributes []attribute.KeyValue
+	}{
+		"successful call": {
+			status:     0,
+			err:        nil,
+			spanStatus: codes.Unset,
+			attributes: []attribute.KeyValue{
+				attribute.String("rpc.system", "jsonrpc"),
+				attribute.String("rpc.method", "mrvl_nvm_create_subsystem"),
+				attribute.Int64("marvell.status", 0),
+				attribute.String("marvell.status_name", "SUCCESS"),
+			},
+		},
+		"non zero status": {
+			status:     -2,
+			err:        nil,
+			spanStatus: codes.Error,
+			attributes: []attribute.KeyValue{
+				attribute.String("rpc.system", "jsonrpc"),
+				attribute.String("rpc.method", "mrvl_nvm_create_subsystem"),
+				attribute.Int64("marvell.status", -2),
+				attribute.String("marvell.status_name", "SUBSYS_NOT_FOUND"),
+			},
+		},
+		"failed call": {
+			status:     0,
+			err:        errors.New("json response error: myopierr"),
+			spanStatus: codes.Error,
+			attributes: []attribute.KeyValue{
+				attribute.String("rpc.system", "jsonrpc"),
+				attribute.String("rpc.method", "mrvl_nvm_create_subsystem"),
+			},
+		},
+	}
+
+	// run tests
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			rpc := WrapJSONRPC(&spdktest.JSONRPC{Status: tt.status, Err: tt.err}, provider)
+
+			// the span of the gRPC request
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "CreateNvmeSubsystem")
+			var result spdktest.StatusResult
+			err := rpc.Call(ctx, "mrvl_nvm_create_subsystem", nil, &result)
+			parent.End()
+			if err != tt.err {
+				t.Error("error: expected", tt.err, "received", err)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 2 {
+				t.Fatal("spans: expected", 2, "received", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != "mrvl_nvm_create_subsystem" {
+				t.Error("span name: expected", "mrvl_nvm_create_subsystem", "received", span.Name())
+			}
+			if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Error("parent span: expected", parent.SpanContext().SpanID(), "received", span.Parent().SpanID())
+			}
+			if span.Status().Code != tt.spanStatus {
+				t.Error("span status: expected", tt.spanStatus, "received", span.Status().Code)
+			}
+			attributes := attribute.NewSet(span.Attributes()...)
+			if expected := attribute.NewSet(tt.attributes...); !attributes.Equals(&expected) {
+				t.Error("attributes: expected", tt.attributes, "received", span.Attributes())
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package validation collects the violations of the fields of the requests, so the clients are
+// told every field to fix, as google.rpc.BadRequest details of an InvalidArgument error, instead
+// of a message about the first one
+package validation
+
+import (
+	"fmt"
+)
+
+// Mode tells how the values the firmware doesn't support are handled
+type Mode string
+
+const (
+	// Strict refuses the requests with unsupported fields or out of range values
+	Strict Mode = "strict"
+	// Permissive ignores the unsupported fields and clamps the out of range values, with a
+	// warning recorded for each of them, i.e. while the firmware of the cards is upgraded
+	Permissive Mode = "permissive"
+)
+
+// ParseMode parses a validation mode, strict or permissive
+func ParseMode(value string) (Mode, error) {
+	switch mode := Mode(value); mode {
+	case Strict, Permissive:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid validation mode %q, have to be %s or %s", value, Strict, Permissive)
+	}
+}
+
+// NewValidator creates a validator handling the unsupported and out of range values as the mode says
+func NewValidator(mode Mode) *Validator {
+	return &Validator{mode: mode}
+}
+
+// Warn records a warning about a value changed or ignored in permissive mode
+func (v *Validator) Warn(field string, format string, args ...interface{}) {
+	v.warnings = append(v.warnings, field+": "+fmt.Sprintf(format, args...))
+}
+
+// Warnings returns the warnings recorded in permissive mode
+func (v *Validator) Warnings() []string {
+	return v.warnings
+}
+
+// Unsupported handles a field the firmware doesn't support when it is set, a violation in strict
+// mode, cleared with a warning in permissive mode
+func (v *Validator) Unsupported(field string, set bool, clear func()) {
+	if !set {
+		return
+	}
+	if v.mode != Permissive {
+		v.Violation(field, "%s is not supported by the firmware", FieldName(field))
+		return
+	}
+	clear()
+	v.Warn(field, "not supported by the firmware, ignored")
+}
+
+// Clamp handles a field out of the [minValue, maxValue] range, a violation in strict mode, set
+// to the closest bound with a warning in permissive mode
+func (v *Validator) Clamp(field string, value *int32, minValue int32, maxValue int32) {
+	if *value >= minValue && *value <= maxValue {
+		return
+	}
+	if v.mode != Permissive {
+		v.Range(field, int64(*value), int64(minValue), int64(maxValue))
+		return
+	}
+	clamped := min(max(*value, minValue), maxValue)
+	v.Warn(field, "value (%d) is out of range, clamped to %d", *value, clamped)
+	*value = clamped
+}
+
+// Truncate handles a field longer than maxLength, a violation in strict mode, truncated with a
+// warning in permissive mode
+func (v *Validator) Truncate(field string, value *string, maxLength int) {
+	if len(*value) <= maxLength {
+		return
+	}
+	if v.mode != Permissive {
+		v.Violation(field, "%s value (%s) is too long, have to be between 1 and %d", FieldName(field), *value, maxLength)
+		return
+	}
+	v.Warn(field, "value (%s) is too long, truncated to %d characters", *value, maxLength)
+	*value = (*value)[:maxLength]
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package validation collects the violations of the fields of the requests, so the clients are
+// told every field to fix, as google.rpc.BadRequest details of an InvalidArgument error, instead
+// of a message about the first one
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/status"
+)
+
+func TestValidation_ParseMode(t *testing.T) {
+	for _, value := range []string{"strict", "permissive"} {
+		if mode, err := ParseMode(value); err != nil || string(mode) != value {
+			t.Error("mode: expected", value, "received", mode, err)
+		}
+	}
+	if _, err := ParseMode("lenient"); err == nil || err.Error() != `invalid validation mode "lenient", have to be strict or permissive` {
+		t.Error("expected an invalid mode error, received", err)
+	}
+}
+
+func TestValidation_Modes(t *testing.T) {
+	tests := map[string]struct {
+		mode     Mode
+		sqes     int32
+		serial   string
+		limit    bool
+		errMsg   string
+		warnings []string
+	}{
+		"strict within range": {
+			mode:   Strict,
+			sqes:   10,
+			serial: "OpiSerialNumber",
+		},
+		"strict refuses": {
+			mode:   Strict,
+			sqes:   20,
+			serial: "OpiSerialNumber0123456789",
+			limit:  true,
+			errMsg: "Sqes value (20) is out of range, have to be between 0 and 16 (and 2 more violations)",
+		},
+		"permissive fixes with warnings": {
+			mode:   Permissive,
+			sqes:   20,
+			serial: "OpiSerialNumber0123456789",
+			limit:  true,
+			warnings: []string{
+				"spec.sqes: value (20) is out of range, clamped to 16",
+				"spec.serial_number: value (OpiSerialNumber0123456789) is too long, truncated to 20 characters",
+				"spec.max_limit: not supported by the firmware, ignored",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := NewValidator(tt.mode)
+			sqes, serial, limit := tt.sqes, tt.serial, tt.limit
+			v.Clamp("spec.sqes", &sqes, 0, 16)
+			v.Truncate("spec.serial_number", &serial, 20)
+			v.Unsupported("spec.max_limit", limit, func() { limit = false })
+
+			err := v.Err()
+			if tt.errMsg != "" {
+				if status.Convert(err).Message() != tt.errMsg || len(FieldViolations(err)) != 3 {
+					t.Error("error: expected", tt.errMsg, "received", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("expected no error, received", err)
+			}
+			if !reflect.DeepEqual(v.Warnings(), tt.warnings) {
+				t.Error("warnings: expected", tt.warnings, "received", v.Warnings())
+			}
+			if sqes > 16 || len(serial) > 20 || limit {
+				t.Error("expected the values fixed, received", sqes, serial, limit)
+			}
+		})
+	}
+}
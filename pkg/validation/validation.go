@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package validation collects the violations of the fields of the requests, so the clients are
+// told every field to fix, as google.rpc.BadRequest details of an InvalidArgument error, instead
+// of a message about the first one
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"go.einride.tech/aip/fieldbehavior"
+	"go.einride.tech/aip/resourceid"
+	"go.einride.tech/aip/resourcename"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// missingRequiredField is how fieldbehavior reports the first required field not set
+const missingRequiredField = "missing required field: "
+
+// Validator collects the violations of the fields of a request, the zero value is ready to use
+// and strict
+type Validator struct {
+	mode       Mode
+	violations []*errdetails.BadRequest_FieldViolation
+	warnings   []string
+}
+
+// Violation records a violation of a field, given by its path in the request, i.e. nvme_controller.spec.sqes
+func (v *Validator) Violation(field string, format string, args ...interface{}) {
+	v.violations = append(v.violations, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// Check records a violation of a field when ok is false, it returns ok
+func (v *Validator) Check(ok bool, field string, format string, args ...interface{}) bool {
+	if !ok {
+		v.Violation(field, format, args...)
+	}
+	return ok
+}
+
+// Required records a violation of a field which is not part of the OPI protobufs when it is
+// not set, it returns if it is set
+func (v *Validator) Required(field string, set bool) bool {
+	return v.Check(set, field, "%s%s", missingRequiredField, field)
+}
+
+// RequiredFields records a violation of the first required field of a protobuf request not set,
+// it returns if they are all set, so the fields under them can be checked without nil dereferences
+func (v *Validator) RequiredFields(in proto.Message) bool {
+	err := fieldbehavior.ValidateRequiredFields(in)
+	if err == nil {
+		return true
+	}
+	field := strings.TrimPrefix(err.Error(), missingRequiredField)
+	v.Violation(field, "%s", err.Error())
+	return false
+}
+
+// Range records a violation of a field out of the [minValue, maxValue] range
+func (v *Validator) Range(field string, value int64, minValue int64, maxValue int64) bool {
+	return v.Check(value >= minValue && value <= maxValue, field,
+		"%s value (%d) is out of range, have to be between %d and %d", FieldName(field), value, minValue, maxValue)
+}
+
+// ResourceName records a violation of a field which is not set or not a resource name conforming
+// to the restrictions outlined in AIP-122
+func (v *Validator) ResourceName(field string, name string) bool {
+	if !v.Required(field, name != "") {
+		return false
+	}
+	if err := resourcename.Validate(name); err != nil {
+		v.Violation(field, "%s", err.Error())
+		return false
+	}
+	return true
+}
+
+// ResourceID records a violation of a user specified ID not conforming to AIP-133, an empty ID
+// is generated by the server so it is valid
+func (v *Validator) ResourceID(field string, id string) bool {
+	if id == "" {
+		return true
+	}
+	if err := resourceid.ValidateUserSettable(id); err != nil {
+		v.Violation(field, "%s", err.Error())
+		return false
+	}
+	return true
+}
+
+// Violations returns the violations recorded
+func (v *Validator) Violations() []*errdetails.BadRequest_FieldViolation {
+	return v.violations
+}
+
+// Err returns nil when no violation was recorded, an InvalidArgument error with all of them as
+// google.rpc.BadRequest details otherwise, its message being the description of the first one
+func (v *Validator) Err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	msg := v.violations[0].Description
+	if len(v.violations) > 1 {
+		msg = fmt.Sprintf("%s (and %d more violations)", msg, len(v.violations)-1)
+	}
+	st, err := status.New(codes.InvalidArgument, msg).WithDetails(&errdetails.BadRequest{FieldViolations: v.violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, msg)
+	}
+	return st.Err()
+}
+
+// FieldViolations returns the field violations of an error returned by Err, none for the other errors
+func FieldViolations(err error) []*errdetails.BadRequest_FieldViolation {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			return badRequest.FieldViolations
+		}
+	}
+	return nil
+}
+
+// FieldName returns the name of a field, as written in the messages of the errors, from its path
+// in the request, i.e. MsixVectors for options.msix_vectors
+func FieldName(field string) string {
+	if i := strings.LastIndex(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+	var name strings.Builder
+	for _, word := range strings.Split(field, "_") {
+		if word != "" {
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return name.String()
+}
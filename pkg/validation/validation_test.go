@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2024 Marvell International Ltd.
+
+// Package validation collects the violations of the fields of the requests, so the clients are
+// told every field to fix, as google.rpc.BadRequest details of an InvalidArgument error, instead
+// of a message about the first one
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/opiproject/opi-api/storage/v1alpha1/gen/go"
+)
+
+func TestValidation_Err(t *testing.T) {
+	tests := map[string]struct {
+		validate func(v *Validator)
+		errMsg   string
+		fields   []string
+	}{
+		"no violation": {
+			validate: func(v *Validator) {
+				v.Range("options.msix_vectors", 16, 0, 2048)
+				v.ResourceID("nvme_controller_id", "")
+				v.ResourceName("name", "//storage.opiproject.org/subsystems/subsys0")
+			},
+		},
+		"one violation": {
+			validate: func(v *Validator) {
+				v.Range("options.msix_vectors", 4096, 0, 2048)
+			},
+			errMsg: "MsixVectors value (4096) is out of range, have to be between 0 and 2048",
+			fields: []string{"options.msix_vectors"},
+		},
+		"all the violations": {
+			validate: func(v *Validator) {
+				v.Required("limits", false)
+				v.ResourceID("quota_id", "Quota")
+				v.Check(false, "quota.max_namespaces", "MaxNamespaces value (%d) can't be negative", -1)
+			},
+			errMsg: "missing required field: limits (and 2 more violations)",
+			fields: []string{"limits", "quota_id", "quota.max_namespaces"},
+		},
+		"missing required field of a protobuf": {
+			validate: func(v *Validator) {
+				if v.RequiredFields(&pb.CreateNvmeControllerRequest{Parent: "subsys0"}) {
+					t.Error("expected the required fields not all set")
+				}
+			},
+			errMsg: "missing required field: nvme_controller",
+			fields: []string{"nvme_controller"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := new(Validator)
+			tt.validate(v)
+			err := v.Err()
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Error("expected no error, received", err)
+				}
+				return
+			}
+			er, _ := status.FromError(err)
+			if er.Code() != codes.InvalidArgument || er.Message() != tt.errMsg {
+				t.Error("error: expected", tt.errMsg, "received", err)
+			}
+			violations := FieldViolations(err)
+			if len(violations) != len(tt.fields) {
+				t.Fatal("violations: expected", tt.fields, "received", violations)
+			}
+			for i, field := range tt.fields {
+				if violations[i].Field != field {
+					t.Error("violation: expected", field, "received", violations[i].Field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidation_FieldViolationsOfOtherErrors(t *testing.T) {
+	if violations := FieldViolations(errors.New("EOF")); violations != nil {
+		t.Error("expected no violations, received", violations)
+	}
+	if violations := FieldViolations(status.Error(codes.NotFound, "unable to find key")); violations != nil {
+		t.Error("expected no violations, received", violations)
+	}
+}
+
+func TestValidation_FieldName(t *testing.T) {
+	for field, name := range map[string]string{
+		"duration_sec":                         "DurationSec",
+		"options.interrupt_coalescing.time_us": "TimeUs",
+		"limits.max_controllers":               "MaxControllers",
+	} {
+		if got := FieldName(field); got != name {
+			t.Error("field name of", field, "expected", name, "received", got)
+		}
+	}
+}